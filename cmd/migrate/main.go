@@ -0,0 +1,69 @@
+// Command migrate applies the schema migrations built into the binary to the database in
+// .env, or lists which are applied; see the migrations package. The server applies them
+// itself on start unless MIGRATE_ON_START is false.
+//
+// Usage:
+//
+//	go run erp/cmd/migrate [up|status]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"erp/models/db"
+	"erp/models/db/migrations"
+)
+
+func main() {
+	ctx := context.Background()
+	flag.Parse()
+	command := "up"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+
+	conn, err := db.InitDB(ctx)
+	if err != nil {
+		log.Fatal("Failed to connect to the database:", err)
+	}
+	defer conn.Close()
+	runner, err := migrations.New(conn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner.Logf = log.Printf
+
+	switch command {
+	case "up":
+		applied, err := runner.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("The schema is up to date")
+		}
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(statuses) == 0 {
+			fmt.Println("No migrations have been recorded; run migrate up")
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04")
+			}
+			if status.Modified {
+				state += " (modified since)"
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		log.Fatal("usage: migrate [up|status]")
+	}
+}
@@ -0,0 +1,43 @@
+// Command querygen writes type-safe Go functions for the annotated SQL files in a
+// directory. It is run with go generate; see the querygen package for the file format.
+//
+// Usage:
+//
+//	go run erp/cmd/querygen [-pkg name] [dir]
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"erp/models/db/querygen"
+)
+
+func main() {
+	pkg := flag.String("pkg", "", "package name of the generated files (default: the directory name)")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if *pkg == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*pkg = filepath.Base(abs)
+	}
+
+	files, err := querygen.GenerateDir(dir, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, code := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), code, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
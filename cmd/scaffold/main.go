@@ -0,0 +1,138 @@
+// Command scaffold writes the starting code of a new CRUD module from a JSON spec; see the
+// scaffold package for the spec format. The store, handlers and tests are written next to
+// the spec, in the package named after its directory. The model is written to the models
+// package and the table is created by a new migration in models/db/migrations.
+//
+// Files that already exist are kept unless -force is given, so the command can stay in a
+// go:generate line of the module and only restores files that were removed.
+//
+// Usage:
+//
+//	go run erp/cmd/scaffold [-force] spec.json
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"erp/internal/scaffold"
+)
+
+func main() {
+	force := flag.Bool("force", false, "overwrite files that already exist")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: scaffold [-force] spec.json")
+	}
+
+	specPath, err := filepath.Abs(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := os.ReadFile(specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	spec, err := scaffold.ParseSpec(src)
+	if err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+
+	dir := filepath.Dir(specPath)
+	root, err := moduleRoot(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg := filepath.Base(dir)
+	out, err := scaffold.Generate(spec, pkg, filepath.Base(specPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := scaffold.FileName(spec)
+	files := []struct {
+		path string
+		code []byte
+	}{
+		{filepath.Join(root, "models", name+".go"), out.Model},
+		{filepath.Join(dir, "store.go"), out.Store},
+		{filepath.Join(dir, name+".go"), out.Handlers},
+		{filepath.Join(dir, name+"_test.go"), out.Tests},
+	}
+	for _, file := range files {
+		if err := writeFile(file.path, file.code, *force); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := addMigration(filepath.Join(root, "models", "db", "migrations"), spec.Table, out.Migration); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Register the routes in controllers/routes/routes.go:\n\n"+
+		"\t%sRouter := router.PathPrefix(\"/%s\").Subrouter()\n"+
+		"\t%s.RegisterRoutes(%sRouter, &%s.DB%sStore{DB: db})\n",
+		strings.ReplaceAll(name, "_", ""), spec.Table, pkg, strings.ReplaceAll(name, "_", ""), pkg, spec.Entity)
+}
+
+// moduleRoot returns the closest directory at or above dir that holds a go.mod file.
+func moduleRoot(dir string) (string, error) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("no go.mod found above the spec")
+		}
+		dir = parent
+	}
+}
+
+// writeFile writes code to path unless the file exists and force is false.
+func writeFile(path string, code []byte, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		fmt.Printf("kept    %s\n", path)
+		return nil
+	}
+	if err := os.WriteFile(path, code, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote   %s\n", path)
+	return nil
+}
+
+// addMigration writes the CREATE TABLE statement to a migration numbered after the last
+// one in dir, unless a migration already creates the table.
+func addMigration(dir, table, statement string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9][0-9]_*.sql"))
+	if err != nil {
+		return err
+	}
+	last := 0
+	for _, path := range paths {
+		migration, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(migration), "CREATE TABLE "+table+" (") {
+			fmt.Printf("kept    %s (table %s exists)\n", path, table)
+			return nil
+		}
+		if version, err := strconv.Atoi(filepath.Base(path)[:4]); err == nil && version > last {
+			last = version
+		}
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%04d_create_%s.sql", last+1, table))
+	if err := os.WriteFile(path, []byte(statement), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote   %s\n", path)
+	return nil
+}
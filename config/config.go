@@ -0,0 +1,596 @@
+// Package config centralizes application configuration loaded from environment variables.
+// Each subsystem reads its settings from a dedicated section so defaults live in one place.
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the configuration for all application subsystems.
+type Config struct {
+	Events       EventsConfig
+	Mail         MailConfig
+	Outbox       OutboxConfig
+	Reports      ReportsConfig
+	Storage      StorageConfig
+	Catalog      CatalogConfig
+	Shipping     ShippingConfig
+	Settings     SettingsConfig
+	Features     FeaturesConfig
+	RBAC         RBACConfig
+	Backup       BackupConfig
+	Loyalty      LoyaltyConfig
+	GiftCards    GiftCardConfig
+	Registers    RegisterConfig
+	Signature    SignatureConfig
+	Export       ExportConfig
+	GraphQL      GraphQLConfig
+	Provision    ProvisioningConfig
+	Sandbox      SandboxConfig
+	Retention    RetentionConfig
+	Leave        LeaveConfig
+	Allocation   AllocationConfig
+	Expenses     ExpenseConfig
+	Installments InstallmentConfig
+	LateFees     LateFeeConfig
+	Recognition  RecognitionConfig
+	Payslips     PayslipConfig
+	Antivirus    AntivirusConfig
+	HRCases      HRCaseConfig
+	Settlements  SettlementConfig
+	Capacity     CapacityConfig
+	Auth         AuthConfig
+	Bank         BankConfig
+	Forecast     ForecastConfig
+	StockAlerts  StockAlertConfig
+	Putaway      PutawayConfig
+	Snapshots    SnapshotConfig
+	Payroll      PayrollConfig
+	Standing     StandingOrderConfig
+	Migrations   MigrationConfig
+}
+
+// EventsConfig configures publishing of domain events to a message broker.
+type EventsConfig struct {
+	Broker        string // "log", "nats" or "kafka" (via the Kafka REST proxy)
+	NATSURL       string // Address of the NATS server, e.g. "localhost:4222"
+	KafkaRESTURL  string // Base URL of the Kafka REST proxy, e.g. "http://localhost:8082"
+	SubjectPrefix string // Prefix for NATS subjects and Kafka topics, e.g. "erp"
+}
+
+// MailConfig configures outgoing email.
+type MailConfig struct {
+	Driver       string // "log" or "smtp"
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	Locale       string // Locale of the email templates used when none exists for the recipient's
+}
+
+// OutboxConfig configures the dispatcher that delivers side effects from the outbox.
+type OutboxConfig struct {
+	Interval    time.Duration // How often the outbox is polled for due messages
+	BatchSize   int           // Maximum number of messages delivered per poll
+	MaxAttempts int           // Attempts before a message is marked dead
+	BaseBackoff time.Duration // Delay before the first retry
+	MaxBackoff  time.Duration // Upper bound for the retry delay
+}
+
+// ReportsConfig configures the summary tables behind the trial balance and AR aging reports.
+type ReportsConfig struct {
+	RefreshInterval time.Duration // How often the summaries are rebuilt in the background
+	MaxStaleness    time.Duration // Age after which a report is flagged as stale
+	ExpenseAccounts []string      // Ledger accounts allocated as expenses in the profitability report
+	KPIInterval     time.Duration // How often the KPI alert rules are evaluated
+	BuilderMaxRows  int           // Most rows a custom report returns
+	DeadStockDays   int           // Days without movement after which stock is reported as dead
+}
+
+// StorageConfig configures where file attachments such as product images are stored.
+type StorageConfig struct {
+	Driver        string // "local"
+	Dir           string // Directory used by the local driver
+	BaseURL       string // URL prefix the stored files are served from
+	MaxUploadSize int64  // Maximum accepted upload size in bytes
+}
+
+// AntivirusConfig configures scanning of uploaded files.
+type AntivirusConfig struct {
+	Driver        string        // "clamav", or empty to disable scanning
+	Address       string        // clamd's host:port or Unix socket path
+	Timeout       time.Duration // Time allowed for one scan
+	FailOpen      bool          // Accept uploads when the scanner cannot be reached
+	QuarantineDir string        // Directory flagged files are kept in; it must not be publicly served
+}
+
+// HRCaseConfig configures the confidential disciplinary and grievance cases.
+type HRCaseConfig struct {
+	Dir string // Directory the case documents are kept in; it must not be publicly served
+}
+
+// SettlementConfig configures the final settlement of employees who leave.
+type SettlementConfig struct {
+	EncashableLeave []string // Leave types whose unused balance is paid out on leaving
+}
+
+// AuthConfig configures the lifetime of the tokens issued at sign-in.
+type AuthConfig struct {
+	AccessTTL  time.Duration // How long an access token is valid
+	RefreshTTL time.Duration // How long a refresh token can be exchanged for a new access token
+	ResetURL   string        // Page of the frontend that password reset links open, with the token in "token"
+	ResetTTL   time.Duration // How long a password reset link can be used
+}
+
+// BankConfig configures the ledger accounts bank statement lines are posted to.
+type BankConfig struct {
+	BankAccount     string // Debited with receipts and credited with payments on the statement
+	SuspenseAccount string // Holds the lines that match no invoice until they are reclassified
+}
+
+// ForecastConfig configures the cash flow forecast.
+type ForecastConfig struct {
+	TermsDays        int // Days customers without a payment history are expected to take to pay
+	PayableTermsDays int // Days after the bill date that supplier bills are paid
+	PayrollDay       int // Day of the month payroll is paid; the last day of shorter months
+	DoubtfulDays     int // Days past their expected payment that receivables are left out of the pessimistic scenario
+	LookbackDays     int // Days of payments that customers' payment behavior is learned from
+}
+
+// StockAlertConfig configures the low-stock check.
+type StockAlertConfig struct {
+	Interval      time.Duration // How often stock is checked against its reorder level; 0 disables the check
+	Emails        []string      // Addresses emailed when stock falls below its reorder level
+	WebhookURL    string        // URL posted to when stock falls below its reorder level
+	WebhookSecret string        // Signs the webhook body when set
+}
+
+// PutawayConfig configures putaway suggestions.
+type PutawayConfig struct {
+	LookbackDays int // Days of fulfilled orders that products' picking frequency is counted over
+	FastPercent  int // Share of the picked products, most picked first, stored nearest dispatch
+}
+
+// MigrationConfig configures the schema migrations.
+type MigrationConfig struct {
+	OnStart bool // Apply pending migrations when the server starts; otherwise run cmd/migrate
+}
+
+// StandingOrderConfig configures the daily generation of standing orders' sales orders.
+type StandingOrderConfig struct {
+	Hour int // Hour of the day (0-23) sales orders are generated; negative disables it
+}
+
+// SnapshotConfig configures the nightly stock snapshots that past stock balances are worked
+// out from.
+type SnapshotConfig struct {
+	Hour int // Hour of the day (0-23) the snapshot is taken; negative disables it
+}
+
+// PayrollConfig configures the monthly payroll. Pay rates are kept per salary grade.
+type PayrollConfig struct {
+	HoursPerDay        float64  // Regular hours of a day; hours worked beyond them are overtime
+	OvertimeMultiplier float64  // Overtime pay over the hourly rate, for grades without their own
+	UnpaidLeave        []string // Leave types that are not paid
+}
+
+// CapacityConfig configures capacity planning.
+type CapacityConfig struct {
+	HoursPerDay      float64 // Scheduled hours of a working day, Monday to Friday
+	UnderUtilization float64 // Percentage of the available hours below which capacity is under-allocated
+}
+
+// CatalogConfig configures the public catalog API used by the e-commerce site.
+type CatalogConfig struct {
+	RateLimit          int           // Default requests per minute per API key
+	ProductsMaxAge     time.Duration // Cache lifetime of product responses
+	AvailabilityMaxAge time.Duration // Cache lifetime of availability responses
+}
+
+// ShippingConfig configures the shipping carriers used to quote rates and buy labels.
+type ShippingConfig struct {
+	Currency        string  // Currency of shipping rates
+	FlatRateCarrier string  // Name of the flat rate courier; empty disables it
+	FlatRateBase    float64 // Base price per parcel
+	FlatRatePerKg   float64 // Price per started kilogram
+}
+
+// SettingsConfig configures the organization-wide settings service.
+type SettingsConfig struct {
+	CacheTTL time.Duration // How long settings are cached before being reloaded
+}
+
+// FeaturesConfig configures feature flag evaluation.
+type FeaturesConfig struct {
+	CacheTTL time.Duration // How long flags are cached before being reloaded
+}
+
+// RBACConfig configures the permission checks on routes.
+type RBACConfig struct {
+	CacheTTL time.Duration // How long roles' permissions are cached before being reloaded
+}
+
+// BackupConfig configures logical backups of the application data.
+type BackupConfig struct {
+	Dir  string // Directory backups are written to; it must not be publicly served
+	Hour int    // Hour of the day (0-23) the nightly backup runs; negative disables it
+	Keep int    // Number of nightly backups kept; 0 keeps all
+}
+
+// RetentionConfig configures the nightly purge of expired records.
+type RetentionConfig struct {
+	Hour             int // Hour of the day (0-23) the purge runs; negative disables it
+	FinancialMinimum int // Days financial records are kept at least, whatever the policy
+}
+
+// LeaveConfig configures leave balances.
+type LeaveConfig struct {
+	YearEndHour int // Hour of the day (0-23) the previous year is closed in January; negative disables it
+}
+
+// AllocationConfig configures the monthly allocation of shared expenses to cost centers.
+type AllocationConfig struct {
+	Hour int // Hour of the day (0-23) the previous month is allocated; negative disables it
+	Day  int // Day of the month from which the previous month is allocated, leaving time for late postings
+}
+
+// ExpenseConfig configures the review of expense claims.
+type ExpenseConfig struct {
+	Approvers     []string // Roles that review and reimburse claims
+	OverrideRoles []string // Roles that may accept claims with hard policy violations
+}
+
+// InstallmentConfig configures reminders for invoice installments.
+type InstallmentConfig struct {
+	ReminderHour int   // Hour of the day (0-23) reminders are sent; negative disables them
+	ReminderDays []int // Days relative to the due date a reminder is sent on; negative days are before it
+}
+
+// LateFeeConfig configures the daily late fee run.
+type LateFeeConfig struct {
+	Hour int // Hour of the day (0-23) late fees are charged; negative disables the run
+}
+
+// RecognitionConfig configures the monthly recognition of deferred service revenue.
+type RecognitionConfig struct {
+	Hour int // Hour of the day (0-23) the previous month is recognized; negative disables it
+	Day  int // Day of the month from which the previous month is recognized
+}
+
+// PayslipConfig configures the payslip notices emailed to employees.
+type PayslipConfig struct {
+	BaseURL string // Public address of the API the download links point to
+}
+
+// SandboxConfig configures sandbox deployments used for sales demos.
+type SandboxConfig struct {
+	Enabled bool   // Captures outgoing emails and webhooks instead of sending them and allows resets
+	Dir     string // Directory the seed snapshot is kept in; it must not be publicly served
+}
+
+// LoyaltyConfig configures the customer loyalty program.
+type LoyaltyConfig struct {
+	PointsPerUnit float64 // Points earned per unit of currency invoiced
+	PointValue    float64 // Discount given per point redeemed
+	ExpiryDays    int     // Days after accrual that unspent points expire
+}
+
+// GiftCardConfig configures gift cards and store credit.
+type GiftCardConfig struct {
+	ExpiryDays int // Days after issue that a card expires unless another date is given; 0 never expires
+}
+
+// RegisterConfig configures the ledger accounts that cash register variances are posted to.
+type RegisterConfig struct {
+	OverAccount  string // Credited when a drawer holds more cash than expected
+	ShortAccount string // Debited when a drawer holds less cash than expected
+}
+
+// SignatureConfig configures the public links customers use to accept documents.
+type SignatureConfig struct {
+	Secret       string        // Signs the links; a random secret is used if unset, so links break on restart
+	BaseURL      string        // Public address of the API the links point to
+	LinkTTL      time.Duration // How long a link can be used
+	MaxImageSize int64         // Largest signature image accepted, in bytes
+}
+
+// ExportConfig configures the nightly incremental extracts for the data warehouse.
+type ExportConfig struct {
+	Dir    string        // Directory the extract files are written to
+	Hour   int           // Hour of the day (0-23) the nightly export runs; negative disables it
+	Lag    time.Duration // Rows changed more recently are left for the next run, so slow transactions are not skipped
+	Tables []string      // Tables exported; each needs id and updated_at columns
+}
+
+// GraphQLConfig limits the queries accepted by the GraphQL endpoint.
+type GraphQLConfig struct {
+	MaxDepth      int // Deepest field nesting allowed
+	MaxComplexity int // Highest query cost allowed; fields below a list count once per expected item
+}
+
+// ProvisioningConfig maps the users synced from the HR system to ERP roles and departments.
+type ProvisioningConfig struct {
+	DefaultRole string   // Role of users no rule matches
+	RoleRules   []string // "field=pattern:Role", field being title or department; the first match wins
+	Departments []string // "HR department:ERP department"; unlisted departments are kept as they are
+}
+
+// Load reads the configuration from the environment, applying defaults for unset values.
+//
+// Returns:
+//   - *Config: The loaded configuration.
+func Load() *Config {
+	return &Config{
+		Events: EventsConfig{
+			Broker:        strings.ToLower(getEnv("EVENT_BROKER", "log")),
+			NATSURL:       getEnv("NATS_URL", "localhost:4222"),
+			KafkaRESTURL:  getEnv("KAFKA_REST_URL", "http://localhost:8082"),
+			SubjectPrefix: getEnv("EVENT_SUBJECT_PREFIX", "erp"),
+		},
+		Mail: MailConfig{
+			Driver:       strings.ToLower(getEnv("MAIL_DRIVER", "log")),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnvInt("SMTP_PORT", 587),
+			SMTPUsername: os.Getenv("SMTP_USERNAME"),
+			SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+			From:         getEnv("MAIL_FROM", "erp@localhost"),
+			Locale:       getEnv("MAIL_DEFAULT_LOCALE", "en"),
+		},
+		Outbox: OutboxConfig{
+			Interval:    getEnvDuration("OUTBOX_INTERVAL", 5*time.Second),
+			BatchSize:   getEnvInt("OUTBOX_BATCH_SIZE", 100),
+			MaxAttempts: getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+			BaseBackoff: getEnvDuration("OUTBOX_BASE_BACKOFF", 10*time.Second),
+			MaxBackoff:  getEnvDuration("OUTBOX_MAX_BACKOFF", time.Hour),
+		},
+		Reports: ReportsConfig{
+			RefreshInterval: getEnvDuration("REPORT_REFRESH_INTERVAL", 15*time.Minute),
+			MaxStaleness:    getEnvDuration("REPORT_MAX_STALENESS", time.Hour),
+			ExpenseAccounts: getEnvList("PROFITABILITY_EXPENSE_ACCOUNTS", []string{"expense"}),
+			KPIInterval:     getEnvDuration("KPI_EVALUATION_INTERVAL", time.Hour),
+			BuilderMaxRows:  getEnvInt("REPORT_BUILDER_MAX_ROWS", 10000),
+			DeadStockDays:   getEnvInt("REPORT_DEAD_STOCK_DAYS", 90),
+		},
+		Storage: StorageConfig{
+			Driver:        strings.ToLower(getEnv("STORAGE_DRIVER", "local")),
+			Dir:           getEnv("STORAGE_DIR", "uploads"),
+			BaseURL:       getEnv("STORAGE_BASE_URL", "/files"),
+			MaxUploadSize: int64(getEnvInt("STORAGE_MAX_UPLOAD_MB", 10)) << 20,
+		},
+		Antivirus: AntivirusConfig{
+			Driver:        strings.ToLower(getEnv("ANTIVIRUS_DRIVER", "")),
+			Address:       getEnv("CLAMAV_ADDRESS", "localhost:3310"),
+			Timeout:       getEnvDuration("ANTIVIRUS_TIMEOUT", 30*time.Second),
+			FailOpen:      getEnvBool("ANTIVIRUS_FAIL_OPEN", false),
+			QuarantineDir: getEnv("QUARANTINE_DIR", "quarantine"),
+		},
+		HRCases: HRCaseConfig{
+			Dir: getEnv("HR_CASE_DIR", "hr_cases"),
+		},
+		Settlements: SettlementConfig{
+			EncashableLeave: getEnvList("SETTLEMENT_ENCASHABLE_LEAVE", []string{"Vacation"}),
+		},
+		Snapshots: SnapshotConfig{
+			Hour: getEnvInt("STOCK_SNAPSHOT_HOUR", 23),
+		},
+		Standing: StandingOrderConfig{
+			Hour: getEnvInt("STANDING_ORDER_HOUR", 5),
+		},
+		Migrations: MigrationConfig{
+			OnStart: getEnvBool("MIGRATE_ON_START", true),
+		},
+		Payroll: PayrollConfig{
+			HoursPerDay:        getEnvFloat("PAYROLL_HOURS_PER_DAY", 8),
+			OvertimeMultiplier: getEnvFloat("PAYROLL_OVERTIME_MULTIPLIER", 1.5),
+			UnpaidLeave:        getEnvList("PAYROLL_UNPAID_LEAVE", []string{"Unpaid"}),
+		},
+		Capacity: CapacityConfig{
+			HoursPerDay:      getEnvFloat("CAPACITY_HOURS_PER_DAY", 8),
+			UnderUtilization: getEnvFloat("CAPACITY_UNDER_UTILIZATION", 70),
+		},
+		Auth: AuthConfig{
+			AccessTTL:  getEnvDuration("AUTH_ACCESS_TTL", 24*time.Hour),
+			RefreshTTL: getEnvDuration("AUTH_REFRESH_TTL", 30*24*time.Hour),
+			ResetURL:   getEnv("AUTH_RESET_URL", "http://localhost:8080/reset-password.html"),
+			ResetTTL:   getEnvDuration("AUTH_RESET_TTL", time.Hour),
+		},
+		Bank: BankConfig{
+			BankAccount:     getEnv("BANK_ACCOUNT", "cash"),
+			SuspenseAccount: getEnv("BANK_SUSPENSE_ACCOUNT", "suspense"),
+		},
+		Forecast: ForecastConfig{
+			TermsDays:        getEnvInt("CASH_FORECAST_TERMS_DAYS", 30),
+			PayableTermsDays: getEnvInt("CASH_FORECAST_PAYABLE_TERMS_DAYS", 30),
+			PayrollDay:       getEnvInt("CASH_FORECAST_PAYROLL_DAY", 28),
+			DoubtfulDays:     getEnvInt("CASH_FORECAST_DOUBTFUL_DAYS", 90),
+			LookbackDays:     getEnvInt("CASH_FORECAST_LOOKBACK_DAYS", 365),
+		},
+		StockAlerts: StockAlertConfig{
+			Interval:      getEnvDuration("STOCK_ALERT_INTERVAL", 15*time.Minute),
+			Emails:        getEnvList("STOCK_ALERT_EMAILS", nil),
+			WebhookURL:    getEnv("STOCK_ALERT_WEBHOOK_URL", ""),
+			WebhookSecret: getEnv("STOCK_ALERT_WEBHOOK_SECRET", ""),
+		},
+		Putaway: PutawayConfig{
+			LookbackDays: getEnvInt("PUTAWAY_LOOKBACK_DAYS", 90),
+			FastPercent:  getEnvInt("PUTAWAY_FAST_PERCENT", 20),
+		},
+		Catalog: CatalogConfig{
+			RateLimit:          getEnvInt("CATALOG_RATE_LIMIT", 120),
+			ProductsMaxAge:     getEnvDuration("CATALOG_PRODUCTS_MAX_AGE", 5*time.Minute),
+			AvailabilityMaxAge: getEnvDuration("CATALOG_AVAILABILITY_MAX_AGE", 30*time.Second),
+		},
+		Shipping: ShippingConfig{
+			Currency:        getEnv("SHIPPING_CURRENCY", "USD"),
+			FlatRateCarrier: os.Getenv("SHIPPING_FLAT_RATE_CARRIER"),
+			FlatRateBase:    getEnvFloat("SHIPPING_FLAT_RATE_BASE", 5),
+			FlatRatePerKg:   getEnvFloat("SHIPPING_FLAT_RATE_PER_KG", 1.5),
+		},
+		Settings: SettingsConfig{
+			CacheTTL: getEnvDuration("SETTINGS_CACHE_TTL", time.Minute),
+		},
+		Features: FeaturesConfig{
+			CacheTTL: getEnvDuration("FEATURE_FLAGS_CACHE_TTL", 30*time.Second),
+		},
+		RBAC: RBACConfig{
+			CacheTTL: getEnvDuration("ROLE_CACHE_TTL", time.Minute),
+		},
+		Backup: BackupConfig{
+			Dir:  getEnv("BACKUP_DIR", "backups"),
+			Hour: getEnvInt("BACKUP_HOUR", 2),
+			Keep: getEnvInt("BACKUP_KEEP", 7),
+		},
+		Retention: RetentionConfig{
+			Hour:             getEnvInt("RETENTION_HOUR", 4),
+			FinancialMinimum: getEnvInt("RETENTION_FINANCIAL_MIN_DAYS", 2557),
+		},
+		Leave: LeaveConfig{
+			YearEndHour: getEnvInt("LEAVE_YEAR_END_HOUR", 3),
+		},
+		Allocation: AllocationConfig{
+			Hour: getEnvInt("ALLOCATION_HOUR", 5),
+			Day:  getEnvInt("ALLOCATION_DAY", 1),
+		},
+		Expenses: ExpenseConfig{
+			Approvers:     getEnvList("EXPENSE_APPROVER_ROLES", []string{"Admin", "Accountant"}),
+			OverrideRoles: getEnvList("EXPENSE_OVERRIDE_ROLES", []string{"Admin"}),
+		},
+		Installments: InstallmentConfig{
+			ReminderHour: getEnvInt("INSTALLMENT_REMINDER_HOUR", 8),
+			ReminderDays: getEnvIntList("INSTALLMENT_REMINDER_DAYS", []int{-3, 1, 7, 14}),
+		},
+		LateFees: LateFeeConfig{
+			Hour: getEnvInt("LATE_FEE_HOUR", 6),
+		},
+		Recognition: RecognitionConfig{
+			Hour: getEnvInt("REVENUE_RECOGNITION_HOUR", 4),
+			Day:  getEnvInt("REVENUE_RECOGNITION_DAY", 1),
+		},
+		Payslips: PayslipConfig{
+			BaseURL: getEnv("PAYSLIP_BASE_URL", "http://localhost:8080"),
+		},
+		Loyalty: LoyaltyConfig{
+			PointsPerUnit: getEnvFloat("LOYALTY_POINTS_PER_UNIT", 1),
+			PointValue:    getEnvFloat("LOYALTY_POINT_VALUE", 0.01),
+			ExpiryDays:    getEnvInt("LOYALTY_EXPIRY_DAYS", 365),
+		},
+		GiftCards: GiftCardConfig{
+			ExpiryDays: getEnvInt("GIFT_CARD_EXPIRY_DAYS", 0),
+		},
+		Registers: RegisterConfig{
+			OverAccount:  getEnv("REGISTER_OVER_ACCOUNT", "cash_over_short"),
+			ShortAccount: getEnv("REGISTER_SHORT_ACCOUNT", "cash_over_short"),
+		},
+		Signature: SignatureConfig{
+			Secret:       getEnv("SIGNATURE_SECRET", ""),
+			BaseURL:      getEnv("SIGNATURE_BASE_URL", "http://localhost:8080"),
+			LinkTTL:      getEnvDuration("SIGNATURE_LINK_TTL", 14*24*time.Hour),
+			MaxImageSize: int64(getEnvInt("SIGNATURE_MAX_IMAGE_SIZE", 1<<20)),
+		},
+		Export: ExportConfig{
+			Dir:  getEnv("EXPORT_DIR", "exports"),
+			Hour: getEnvInt("EXPORT_HOUR", 3),
+			Lag:  getEnvDuration("EXPORT_SAFETY_LAG", 5*time.Minute),
+			Tables: getEnvList("EXPORT_TABLES", []string{
+				"customers", "products", "warehouses", "stock", "sales_orders", "invoices", "payments",
+				"financial_transactions", "pos_sales", "pos_sale_lines", "gift_cards",
+				"gift_card_transactions", "loyalty_transactions",
+			}),
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      getEnvInt("GRAPHQL_MAX_DEPTH", 6),
+			MaxComplexity: getEnvInt("GRAPHQL_MAX_COMPLEXITY", 5000),
+		},
+		Provision: ProvisioningConfig{
+			DefaultRole: getEnv("PROVISIONING_DEFAULT_ROLE", "Employee"),
+			RoleRules:   getEnvList("PROVISIONING_ROLE_RULES", nil),
+			Departments: getEnvList("PROVISIONING_DEPARTMENTS", nil),
+		},
+		Sandbox: SandboxConfig{
+			Enabled: getEnvBool("SANDBOX_MODE", false),
+			Dir:     getEnv("SANDBOX_DIR", "sandbox"),
+		},
+	}
+}
+
+// getEnv returns the value of an environment variable or the fallback if it is unset.
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt returns an integer environment variable or the fallback if it is unset or invalid.
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvBool returns the boolean value of an environment variable, or the fallback if it
+// is unset or not a boolean.
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvFloat returns a decimal environment variable or the fallback if it is unset or invalid.
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList returns a comma-separated environment variable as a list, or the fallback if
+// it is unset or holds no values.
+func getEnvList(key string, fallback []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
+
+// getEnvIntList returns a comma-separated environment variable as a list of integers, or
+// the fallback if it is unset or any value is not an integer.
+func getEnvIntList(key string, fallback []int) []int {
+	var values []int
+	for _, text := range getEnvList(key, nil) {
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return fallback
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
+
+// getEnvDuration returns a duration environment variable (e.g. "10s") or the fallback if it
+// is unset or invalid.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
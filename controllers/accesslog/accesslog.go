@@ -0,0 +1,93 @@
+// Package accesslog records sign-in attempts for security review and warns users about
+// sign-ins to their account from a new location or device.
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"erp/models"
+)
+
+// maxUserAgent caps the stored user agent; clients control the header.
+const maxUserAgent = 500
+
+// Notifier sends in-app notifications. It is satisfied by the notification store.
+type Notifier interface {
+	NotifyUsers(ctx context.Context, emails []string, notification *models.Notification) (int, error)
+}
+
+// Service records sign-in attempts.
+type Service struct {
+	Store    models.AccessLogStore
+	Notifier Notifier // Warns users about new locations and devices; nil disables the warnings
+	Now      func() time.Time
+}
+
+// NewService creates an access log service.
+func NewService(store models.AccessLogStore, notifier Notifier) *Service {
+	return &Service{Store: store, Notifier: notifier, Now: time.Now}
+}
+
+// Record stores a sign-in attempt made with the request. Failures are logged rather than
+// returned so that signing in never depends on the access log.
+//
+// Parameters:
+//   - r: The sign-in request, for the client's address and user agent.
+//   - email: The email the attempt was made for.
+//   - success: Whether the user was signed in.
+//   - reason: For failures, one of the models.Access* reasons.
+func (s *Service) Record(r *http.Request, email string, success bool, reason string) {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+	event := &models.AccessEvent{
+		Email:        email,
+		Success:      success,
+		Reason:       reason,
+		IP:           ip,
+		Network:      Network(ip),
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		UserAgent:    userAgent,
+		OccurredAt:   s.Now(),
+	}
+	if err := s.Store.RecordAccess(r.Context(), event); err != nil {
+		log.Printf("accesslog: could not record sign-in of %s: %v", email, err)
+		return
+	}
+	if (!event.NewLocation && !event.NewDevice) || s.Notifier == nil {
+		return
+	}
+
+	notification := &models.Notification{
+		Type:  "NewSignIn",
+		Title: "New sign-in to your account",
+		Body: fmt.Sprintf("Your account was signed in to from %s using %q at %s. If this was not you, change your password and contact an administrator.",
+			event.IP, event.UserAgent, event.OccurredAt.UTC().Format("2006-01-02 15:04 MST")),
+	}
+	if _, err := s.Notifier.NotifyUsers(r.Context(), []string{email}, notification); err != nil {
+		log.Printf("accesslog: could not notify %s of a new sign-in: %v", email, err)
+	}
+}
+
+// Network returns the network an address belongs to: its /24 prefix for IPv4 and its /48
+// prefix for IPv6. Addresses that cannot be parsed are returned unchanged.
+func Network(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
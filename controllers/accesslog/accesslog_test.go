@@ -0,0 +1,75 @@
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockStore flags every success as a new device.
+type mockStore struct {
+	events []*models.AccessEvent
+	err    error
+}
+
+func (m *mockStore) RecordAccess(ctx context.Context, event *models.AccessEvent) error {
+	if m.err != nil {
+		return m.err
+	}
+	event.NewDevice = event.Success
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *mockStore) ListAccessEvents(ctx context.Context, filter models.AccessLogFilter) ([]models.AccessEvent, error) {
+	return nil, nil
+}
+
+type mockNotifier struct {
+	sent []*models.Notification
+}
+
+func (m *mockNotifier) NotifyUsers(ctx context.Context, emails []string, notification *models.Notification) (int, error) {
+	m.sent = append(m.sent, notification)
+	return 1, nil
+}
+
+func TestNetwork(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", Network("203.0.113.77"))
+	assert.Equal(t, "2001:db8:85a3::/48", Network("2001:db8:85a3:8d3:1319:8a2e:370:7348"))
+	assert.Equal(t, "unknown", Network("unknown"))
+}
+
+func TestRecord(t *testing.T) {
+	store, notifier := &mockStore{}, &mockNotifier{}
+	service := NewService(store, notifier)
+	service.Now = func() time.Time { return time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC) }
+
+	req := httptest.NewRequest("POST", "/auth/login", nil)
+	req.RemoteAddr = "203.0.113.77:51234"
+	req.Header.Set("User-Agent", "Firefox/131.0")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	service.Record(req, "jane@example.com", false, models.AccessInvalidPassword)
+	assert.Empty(t, notifier.sent, "Failures are not notified")
+
+	service.Record(req, "jane@example.com", true, "")
+	assert.Len(t, store.events, 2)
+	assert.Equal(t, models.AccessEvent{
+		Email: "jane@example.com", Success: true, IP: "203.0.113.77", Network: "203.0.113.0/24",
+		ForwardedFor: "10.0.0.1", UserAgent: "Firefox/131.0", NewDevice: true, OccurredAt: service.Now(),
+	}, *store.events[1])
+	assert.Len(t, notifier.sent, 1)
+	assert.Contains(t, notifier.sent[0].Body, `from 203.0.113.77 using "Firefox/131.0" at 2026-10-16 09:30 UTC`)
+
+	// Signing in does not depend on the access log
+	store.err = errors.New("database is down")
+	service.Record(req, "jane@example.com", true, "")
+	assert.Len(t, notifier.sent, 1)
+}
@@ -0,0 +1,130 @@
+// Package accounts holds the rules of the chart of accounts: hierarchical codes, the five
+// account types and which accounts may be used on financial records.
+package accounts
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"erp/controllers/validation"
+	"erp/models"
+)
+
+// codePattern matches account codes: numbers separated by dots, such as "1" or "1.1.20".
+var codePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// Service applies the chart of accounts rules on top of an AccountStore.
+type Service struct {
+	Store models.AccountStore
+}
+
+// NewService creates an accounts service backed by store.
+func NewService(store models.AccountStore) *Service {
+	return &Service{Store: store}
+}
+
+// Create checks an account and adds it, active, to the chart. An account with a parent
+// code must be of its parent's type, and the parent must be active.
+//
+// Parameters:
+//   - account: The account; Code, Name, Type and Description are read. The ID, parent and
+//     times are set.
+//
+// Returns:
+//   - error: A validation error if the account is incomplete or its parent is missing,
+//     inactive or of another type, a conflict if the code is taken, or the store's error.
+func (s *Service) Create(ctx context.Context, account *models.Account) error {
+	account.Code = strings.TrimSpace(account.Code)
+	account.Name = strings.TrimSpace(account.Name)
+	var c validation.Checker
+	c.Check(codePattern.MatchString(account.Code), "code", "must be numbers separated by dots, such as 1.1.20")
+	c.Required("name", account.Name)
+	c.Check(ValidType(account.Type), "type", "must be asset, liability, equity, income or expense")
+	if err := c.Err(); err != nil {
+		return err
+	}
+
+	account.ParentID = nil
+	if parentCode := ParentCode(account.Code); parentCode != "" {
+		parent, err := s.Store.GetAccountByCode(ctx, parentCode)
+		if errors.Is(err, models.ErrNotFound) {
+			return models.Invalid("parent account %s does not exist", parentCode)
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case !parent.Active:
+			return models.Invalid("parent account %s is inactive", parentCode)
+		case parent.Type != account.Type:
+			return models.Invalid("account %s must be of its parent's type, %s", account.Code, parent.Type)
+		}
+		account.ParentID = &parent.ID
+	}
+	account.Active = true
+	return s.Store.CreateAccount(ctx, account)
+}
+
+// Get returns an account.
+func (s *Service) Get(ctx context.Context, id int) (*models.Account, error) {
+	return s.Store.GetAccount(ctx, id)
+}
+
+// List returns the accounts of a type, or all of them if accountType is empty, in code
+// order.
+func (s *Service) List(ctx context.Context, accountType string, activeOnly bool) ([]models.Account, error) {
+	if accountType != "" && !ValidType(accountType) {
+		return nil, models.Invalid("unknown account type %q", accountType)
+	}
+	return s.Store.ListAccounts(ctx, accountType, activeOnly)
+}
+
+// Rename changes an account's name and description, if the account is still at version.
+// Its code and type are fixed, since records already posted to it rely on them.
+func (s *Service) Rename(ctx context.Context, id, version int, name, description string) (*models.Account, error) {
+	name = strings.TrimSpace(name)
+	var c validation.Checker
+	c.Required("name", name)
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	account, err := s.Store.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	account.Name, account.Description, account.Version = name, strings.TrimSpace(description), version
+	if err := s.Store.UpdateAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Activate lets an account be used on new records again. Its parent must be active.
+func (s *Service) Activate(ctx context.Context, id int) (*models.Account, error) {
+	return s.Store.SetAccountActive(ctx, id, true)
+}
+
+// Deactivate stops an account being used on new records; existing records keep it. Its
+// sub-accounts must be deactivated first.
+func (s *Service) Deactivate(ctx context.Context, id int) (*models.Account, error) {
+	return s.Store.SetAccountActive(ctx, id, false)
+}
+
+// ValidType reports whether t is one of the account types.
+func ValidType(t string) bool {
+	switch t {
+	case models.AccountAsset, models.AccountLiability, models.AccountEquity, models.AccountIncome, models.AccountExpense:
+		return true
+	}
+	return false
+}
+
+// ParentCode returns the code of an account's parent, or "" for a top-level account.
+func ParentCode(code string) string {
+	if i := strings.LastIndex(code, "."); i >= 0 {
+		return code[:i]
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps accounts by code.
+type memoryStore struct {
+	accounts map[string]*models.Account
+}
+
+func (m *memoryStore) CreateAccount(ctx context.Context, account *models.Account) error {
+	if _, ok := m.accounts[account.Code]; ok {
+		return models.Conflict("an account with code %q already exists", account.Code)
+	}
+	account.ID = len(m.accounts) + 1
+	m.accounts[account.Code] = account
+	return nil
+}
+
+func (m *memoryStore) GetAccount(ctx context.Context, id int) (*models.Account, error) {
+	for _, a := range m.accounts {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return nil, models.NotFound("account %d not found", id)
+}
+
+func (m *memoryStore) GetAccountByCode(ctx context.Context, code string) (*models.Account, error) {
+	if a, ok := m.accounts[code]; ok {
+		return a, nil
+	}
+	return nil, models.NotFound("account %s not found", code)
+}
+
+func (m *memoryStore) ListAccounts(ctx context.Context, accountType string, activeOnly bool) ([]models.Account, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) UpdateAccount(ctx context.Context, account *models.Account) error { return nil }
+
+func (m *memoryStore) SetAccountActive(ctx context.Context, id int, active bool) (*models.Account, error) {
+	return nil, nil
+}
+
+func TestParentCode(t *testing.T) {
+	assert.Equal(t, "", ParentCode("1"))
+	assert.Equal(t, "1", ParentCode("1.1"))
+	assert.Equal(t, "1.1", ParentCode("1.1.20"))
+}
+
+func TestCreateChecksTheHierarchy(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{accounts: map[string]*models.Account{}}
+	s := NewService(store)
+	assets := models.Account{Code: "1", Name: "Assets", Type: models.AccountAsset}
+	require.NoError(t, s.Create(ctx, &assets))
+	assert.True(t, assets.Active)
+	assert.Nil(t, assets.ParentID)
+
+	cash := models.Account{Code: " 1.1 ", Name: "Cash", Type: models.AccountAsset}
+	require.NoError(t, s.Create(ctx, &cash))
+	assert.Equal(t, "1.1", cash.Code)
+	require.NotNil(t, cash.ParentID)
+	assert.Equal(t, assets.ID, *cash.ParentID)
+
+	for _, account := range []models.Account{
+		{Code: "1.a", Name: "Bank", Type: models.AccountAsset},
+		{Code: "1.2", Name: " ", Type: models.AccountAsset},
+		{Code: "1.2", Name: "Bank", Type: "cash"},
+		{Code: "1.2", Name: "Bank", Type: models.AccountExpense},
+		{Code: "2.1", Name: "Payables", Type: models.AccountLiability},
+	} {
+		err := s.Create(ctx, &account)
+		assert.True(t, errors.Is(err, models.ErrValidation), "%+v", account)
+	}
+
+	cash.Active = false
+	err := s.Create(ctx, &models.Account{Code: "1.1.1", Name: "Petty cash", Type: models.AccountAsset})
+	assert.ErrorIs(t, err, models.ErrValidation, "accounts cannot be added under an inactive one")
+
+	err = s.Create(ctx, &models.Account{Code: "1", Name: "Assets", Type: models.AccountAsset})
+	assert.ErrorIs(t, err, models.ErrConflict)
+}
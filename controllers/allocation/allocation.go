@@ -0,0 +1,283 @@
+// Package allocation splits shared expenses, such as rent and utilities, across cost
+// centers. Each rule moves the monthly balance of a source account to the accounts of its
+// cost centers, by fixed percentages or in proportion to headcount. A month is allocated
+// once, by the scheduler or on request, and every share posted is traced.
+package allocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// periodLayout is the format of allocation periods.
+const periodLayout = "2006-01"
+
+// Service validates allocation rules and runs monthly allocations.
+type Service struct {
+	Store models.AllocationStore
+	Day   int              // Day of the month from which the scheduler allocates the previous month
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates an allocation service. The scheduler allocates the previous month from
+// day on.
+func NewService(store models.AllocationStore, day int) *Service {
+	return &Service{Store: store, Day: day, Now: time.Now}
+}
+
+// Rules returns the allocation rules.
+func (s *Service) Rules(ctx context.Context) ([]models.AllocationRule, error) {
+	return s.Store.GetAllocationRules(ctx)
+}
+
+// CreateRule checks a rule and saves it.
+//
+// Parameters:
+//   - rule: The rule; its ID and update time are set.
+//   - actor: Email of the user creating the rule.
+//
+// Returns:
+//   - error: A validation error if the rule is incomplete or its percentages do not add up
+//     to 100, or the store's error.
+func (s *Service) CreateRule(ctx context.Context, rule *models.AllocationRule, actor string) error {
+	if err := Validate(rule); err != nil {
+		return err
+	}
+	rule.UpdatedBy, rule.UpdatedAt = actor, s.Now()
+	return s.Store.CreateAllocationRule(ctx, rule)
+}
+
+// UpdateRule checks a rule and replaces the stored one. Months already allocated are not
+// changed.
+func (s *Service) UpdateRule(ctx context.Context, rule *models.AllocationRule, actor string) error {
+	if err := Validate(rule); err != nil {
+		return err
+	}
+	rule.UpdatedBy, rule.UpdatedAt = actor, s.Now()
+	return s.Store.UpdateAllocationRule(ctx, rule)
+}
+
+// DeleteRule removes a rule. Its past allocations stay in the ledger and the trace.
+func (s *Service) DeleteRule(ctx context.Context, id int) error {
+	return s.Store.DeleteAllocationRule(ctx, id)
+}
+
+// Validate checks that a rule names a source account and method, that its targets have
+// distinct cost centers and accounts other than the source, and that the percentages of a
+// percentage rule add up to 100. It trims the names in place.
+func Validate(rule *models.AllocationRule) error {
+	rule.Name, rule.SourceAccount = strings.TrimSpace(rule.Name), strings.TrimSpace(rule.SourceAccount)
+	switch {
+	case rule.Name == "":
+		return models.Invalid("name is required")
+	case rule.SourceAccount == "":
+		return models.Invalid("source_account is required")
+	case rule.Method != models.AllocationPercentage && rule.Method != models.AllocationHeadcount:
+		return models.Invalid("method must be %q or %q", models.AllocationPercentage, models.AllocationHeadcount)
+	case len(rule.Targets) == 0:
+		return models.Invalid("a rule needs at least one target")
+	}
+
+	seen := map[string]bool{}
+	total := 0.0
+	for i := range rule.Targets {
+		target := &rule.Targets[i]
+		target.CostCenter, target.Account = strings.TrimSpace(target.CostCenter), strings.TrimSpace(target.Account)
+		switch {
+		case target.CostCenter == "" || target.Account == "":
+			return models.Invalid("target %d: cost_center and account are required", i+1)
+		case target.Account == rule.SourceAccount:
+			return models.Invalid("target %d: the account must differ from the source account", i+1)
+		case seen[target.CostCenter]:
+			return models.Invalid("target %d: cost center %q is listed twice", i+1, target.CostCenter)
+		}
+		seen[target.CostCenter] = true
+		if rule.Method == models.AllocationHeadcount {
+			target.Percent = 0
+			continue
+		}
+		if target.Percent <= 0 {
+			return models.Invalid("target %d: percent must be positive", i+1)
+		}
+		total += target.Percent
+	}
+	if rule.Method == models.AllocationPercentage && math.Abs(total-100) > 0.001 {
+		return models.Invalid("percentages add up to %g, not 100", total)
+	}
+	return nil
+}
+
+// Preview computes the allocation of a month without posting it.
+//
+// Parameters:
+//   - period: The month, as YYYY-MM.
+//
+// Returns:
+//   - *models.AllocationRun: The shares each active rule would post, marked as a preview.
+//   - error: A validation error if the period is invalid or has not ended, or the store's error.
+func (s *Service) Preview(ctx context.Context, period string) (*models.AllocationRun, error) {
+	run, _, err := s.compute(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	run.Preview = true
+	return run, nil
+}
+
+// Run allocates a month: for every active rule, the source account is credited with its
+// balance for the month and each cost center's account is debited with its share, dated the
+// last day of the month.
+//
+// Parameters:
+//   - period: The month, as YYYY-MM.
+//   - actor: Email of the user, or "scheduler".
+//
+// Returns:
+//   - *models.AllocationRun: The posted run with the trace of every share.
+//   - error: A validation error if the period is invalid or has not ended, a conflict if
+//     it has been allocated already, or the store's error.
+func (s *Service) Run(ctx context.Context, period, actor string) (*models.AllocationRun, error) {
+	run, postingDate, err := s.compute(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	run.Actor = actor
+	if err := s.Store.SaveAllocationRun(ctx, run, postingDate); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Monthly allocates the previous month if it has not been allocated yet. It is run daily
+// by the scheduler and acts from Day of the month on, so late postings can be made first
+// and a server that was down on that day catches up.
+func (s *Service) Monthly(ctx context.Context) error {
+	now := s.Now()
+	if now.Day() < s.Day {
+		return nil
+	}
+	period := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0).Format(periodLayout)
+	_, err := s.Run(ctx, period, "scheduler")
+	if errors.Is(err, models.ErrConflict) {
+		return nil
+	}
+	return err
+}
+
+// Runs returns the allocated months, latest first, without their lines.
+func (s *Service) Runs(ctx context.Context) ([]models.AllocationRun, error) {
+	return s.Store.GetAllocationRuns(ctx)
+}
+
+// Trace returns the allocation of a month with every share posted.
+func (s *Service) Trace(ctx context.Context, period string) (*models.AllocationRun, error) {
+	if _, err := time.Parse(periodLayout, period); err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	return s.Store.GetAllocationRun(ctx, period)
+}
+
+// compute works out the shares of every active rule for a month, and the date they are
+// posted on.
+func (s *Service) compute(ctx context.Context, period string) (*models.AllocationRun, time.Time, error) {
+	from, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return nil, time.Time{}, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	to := from.AddDate(0, 1, 0)
+	now := s.Now()
+	if to.After(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)) {
+		return nil, time.Time{}, models.Invalid("%s has not ended", period)
+	}
+
+	rules, err := s.Store.GetAllocationRules(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var headcount map[string]int
+	run := &models.AllocationRun{Period: period, Lines: []models.AllocationLine{}}
+	for _, rule := range rules {
+		if !rule.Active {
+			continue
+		}
+		amount, err := s.Store.GetAccountActivity(ctx, rule.SourceAccount, from, to)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if math.Round(amount*100) == 0 {
+			run.Skipped = append(run.Skipped, fmt.Sprintf("%s: nothing to allocate from %s", rule.Name, rule.SourceAccount))
+			continue
+		}
+
+		drivers := make([]float64, len(rule.Targets))
+		for i, target := range rule.Targets {
+			drivers[i] = target.Percent
+		}
+		if rule.Method == models.AllocationHeadcount {
+			if headcount == nil {
+				if headcount, err = s.Store.GetHeadcount(ctx); err != nil {
+					return nil, time.Time{}, err
+				}
+			}
+			for i, target := range rule.Targets {
+				drivers[i] = float64(headcount[target.CostCenter])
+			}
+		}
+		lines, ok := Split(rule, amount, drivers)
+		if !ok {
+			run.Skipped = append(run.Skipped, fmt.Sprintf("%s: no active users in its departments", rule.Name))
+			continue
+		}
+		run.Lines = append(run.Lines, lines...)
+		run.Total += amount
+	}
+	run.Total = roundCents(run.Total)
+	return run, to.AddDate(0, 0, -1), nil
+}
+
+// Split divides amount between a rule's targets in proportion to drivers. Shares are rounded
+// to cents and the rounding difference goes to the last target, so the lines add up to the
+// amount exactly. It returns false if the drivers add up to zero.
+func Split(rule models.AllocationRule, amount float64, drivers []float64) ([]models.AllocationLine, bool) {
+	total := 0.0
+	for _, driver := range drivers {
+		total += driver
+	}
+	if total <= 0 {
+		return nil, false
+	}
+
+	lines := make([]models.AllocationLine, len(rule.Targets))
+	allocated := 0.0
+	for i, target := range rule.Targets {
+		line := models.AllocationLine{
+			RuleID:        rule.ID,
+			RuleName:      rule.Name,
+			SourceAccount: rule.SourceAccount,
+			SourceAmount:  roundCents(amount),
+			CostCenter:    target.CostCenter,
+			Account:       target.Account,
+			Driver:        drivers[i],
+			Share:         drivers[i] / total,
+		}
+		if i == len(rule.Targets)-1 {
+			line.Amount = roundCents(amount - allocated)
+		} else {
+			line.Amount = roundCents(amount * line.Share)
+			allocated += line.Amount
+		}
+		lines[i] = line
+	}
+	return lines, true
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,198 @@
+package allocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps rules and runs in memory. Activity is keyed by account.
+type fakeStore struct {
+	rules     []models.AllocationRule
+	activity  map[string]float64
+	headcount map[string]int
+	runs      map[string]*models.AllocationRun
+	posted    time.Time
+}
+
+func (f *fakeStore) CreateAllocationRule(ctx context.Context, rule *models.AllocationRule) error {
+	rule.ID = len(f.rules) + 1
+	f.rules = append(f.rules, *rule)
+	return nil
+}
+
+func (f *fakeStore) GetAllocationRules(ctx context.Context) ([]models.AllocationRule, error) {
+	return f.rules, nil
+}
+
+func (f *fakeStore) UpdateAllocationRule(ctx context.Context, rule *models.AllocationRule) error {
+	return nil
+}
+
+func (f *fakeStore) DeleteAllocationRule(ctx context.Context, id int) error { return nil }
+
+func (f *fakeStore) GetAccountActivity(ctx context.Context, account string, from, to time.Time) (float64, error) {
+	return f.activity[account], nil
+}
+
+func (f *fakeStore) GetHeadcount(ctx context.Context) (map[string]int, error) {
+	return f.headcount, nil
+}
+
+func (f *fakeStore) SaveAllocationRun(ctx context.Context, run *models.AllocationRun, postingDate time.Time) error {
+	if f.runs[run.Period] != nil {
+		return models.Conflict("%s has been allocated already", run.Period)
+	}
+	f.runs[run.Period], f.posted = run, postingDate
+	return nil
+}
+
+func (f *fakeStore) GetAllocationRuns(ctx context.Context) ([]models.AllocationRun, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) GetAllocationRun(ctx context.Context, period string) (*models.AllocationRun, error) {
+	if run := f.runs[period]; run != nil {
+		return run, nil
+	}
+	return nil, models.NotFound("%s has not been allocated", period)
+}
+
+func newTestService(now time.Time) (*Service, *fakeStore) {
+	store := &fakeStore{
+		rules: []models.AllocationRule{
+			{ID: 1, Name: "Rent", SourceAccount: "Rent", Method: models.AllocationPercentage, Active: true,
+				Targets: []models.AllocationTarget{
+					{CostCenter: "Sales", Account: "Rent - Sales", Percent: 60},
+					{CostCenter: "Support", Account: "Rent - Support", Percent: 40},
+				}},
+			{ID: 2, Name: "IT", SourceAccount: "IT Services", Method: models.AllocationHeadcount, Active: true,
+				Targets: []models.AllocationTarget{
+					{CostCenter: "Sales", Account: "IT - Sales"},
+					{CostCenter: "Support", Account: "IT - Support"},
+					{CostCenter: "Finance", Account: "IT - Finance"},
+				}},
+			{ID: 3, Name: "Utilities", SourceAccount: "Utilities", Method: models.AllocationPercentage, Active: false,
+				Targets: []models.AllocationTarget{{CostCenter: "Sales", Account: "Utilities - Sales", Percent: 100}}},
+		},
+		activity:  map[string]float64{"Rent": 1000, "IT Services": 100, "Utilities": 300},
+		headcount: map[string]int{"Sales": 1, "Support": 1, "Finance": 1},
+		runs:      map[string]*models.AllocationRun{},
+	}
+	service := NewService(store, 3)
+	service.Now = func() time.Time { return now }
+	return service, store
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *models.AllocationRule {
+		return &models.AllocationRule{Name: " Rent ", SourceAccount: "Rent", Method: models.AllocationPercentage,
+			Targets: []models.AllocationTarget{
+				{CostCenter: "Sales", Account: "Rent - Sales", Percent: 70},
+				{CostCenter: "Support", Account: "Rent - Support", Percent: 30},
+			}}
+	}
+	rule := valid()
+	require.NoError(t, Validate(rule))
+	assert.Equal(t, "Rent", rule.Name)
+
+	tests := map[string]func(rule *models.AllocationRule){
+		"no name":           func(rule *models.AllocationRule) { rule.Name = " " },
+		"unknown method":    func(rule *models.AllocationRule) { rule.Method = "revenue" },
+		"no targets":        func(rule *models.AllocationRule) { rule.Targets = nil },
+		"not 100 percent":   func(rule *models.AllocationRule) { rule.Targets[1].Percent = 20 },
+		"negative percent":  func(rule *models.AllocationRule) { rule.Targets[0].Percent, rule.Targets[1].Percent = -10, 110 },
+		"duplicate center":  func(rule *models.AllocationRule) { rule.Targets[1].CostCenter = "Sales" },
+		"target is source":  func(rule *models.AllocationRule) { rule.Targets[0].Account = "Rent" },
+		"target no account": func(rule *models.AllocationRule) { rule.Targets[0].Account = "" },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			rule := valid()
+			change(rule)
+			assert.ErrorIs(t, Validate(rule), models.ErrValidation)
+		})
+	}
+
+	// Headcount rules ignore percentages
+	rule = valid()
+	rule.Method, rule.Targets[1].Percent = models.AllocationHeadcount, 5
+	require.NoError(t, Validate(rule))
+	assert.Zero(t, rule.Targets[1].Percent)
+}
+
+func TestSplitPutsRoundingOnLastTarget(t *testing.T) {
+	rule := models.AllocationRule{Targets: []models.AllocationTarget{{CostCenter: "A"}, {CostCenter: "B"}, {CostCenter: "C"}}}
+	lines, ok := Split(rule, 100, []float64{1, 1, 1})
+	require.True(t, ok)
+	assert.Equal(t, 33.33, lines[0].Amount)
+	assert.Equal(t, 33.33, lines[1].Amount)
+	assert.Equal(t, 33.34, lines[2].Amount)
+
+	_, ok = Split(rule, 100, []float64{0, 0, 0})
+	assert.False(t, ok)
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService(time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC))
+
+	run, err := service.Run(ctx, "2025-06", "accountant@example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC), store.posted)
+	assert.Equal(t, 1100.0, run.Total)
+	assert.Equal(t, "accountant@example.com", run.Actor)
+	require.Len(t, run.Lines, 5)
+	assert.Equal(t, models.AllocationLine{RuleID: 1, RuleName: "Rent", SourceAccount: "Rent", SourceAmount: 1000,
+		CostCenter: "Sales", Account: "Rent - Sales", Driver: 60, Share: 0.6, Amount: 600}, run.Lines[0])
+	assert.Equal(t, 400.0, run.Lines[1].Amount)
+	assert.Equal(t, []float64{33.33, 33.33, 33.34}, []float64{run.Lines[2].Amount, run.Lines[3].Amount, run.Lines[4].Amount})
+	assert.Equal(t, 1.0, run.Lines[4].Driver)
+
+	_, err = service.Run(ctx, "2025-06", "accountant@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+}
+
+func TestRunSkipsEmptyRules(t *testing.T) {
+	service, store := newTestService(time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC))
+	store.activity["Rent"] = 0
+	store.headcount = map[string]int{"Engineering": 4}
+
+	run, err := service.Preview(context.Background(), "2025-06")
+	require.NoError(t, err)
+	assert.True(t, run.Preview)
+	assert.Empty(t, run.Lines)
+	assert.Len(t, run.Skipped, 2)
+	assert.Empty(t, store.runs)
+}
+
+func TestRunRejectsOpenPeriods(t *testing.T) {
+	service, _ := newTestService(time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC))
+	for _, period := range []string{"2025-07", "2025-13", "June"} {
+		_, err := service.Run(context.Background(), period, "accountant@example.com")
+		assert.ErrorIs(t, err, models.ErrValidation, period)
+	}
+}
+
+func TestMonthly(t *testing.T) {
+	ctx := context.Background()
+	// Before the configured day nothing is allocated
+	service, store := newTestService(time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, service.Monthly(ctx))
+	assert.Empty(t, store.runs)
+
+	service.Now = func() time.Time { return time.Date(2025, 7, 3, 5, 0, 0, 0, time.UTC) }
+	require.NoError(t, service.Monthly(ctx))
+	assert.Contains(t, store.runs, "2025-06")
+	assert.Equal(t, "scheduler", store.runs["2025-06"].Actor)
+
+	// The following days find the month allocated
+	service.Now = func() time.Time { return time.Date(2025, 7, 4, 5, 0, 0, 0, time.UTC) }
+	assert.NoError(t, service.Monthly(ctx))
+}
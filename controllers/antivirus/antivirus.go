@@ -0,0 +1,121 @@
+// Package antivirus scans uploaded files for malware before they are stored. Scanners are
+// pluggable; ClamAV is supported through its clamd daemon. Flagged files are rejected and
+// moved to a private quarantine storage where admins can review and delete them.
+package antivirus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"erp/config"
+	"erp/controllers/storage"
+	"erp/models"
+)
+
+// ErrUnavailable is returned when a file cannot be scanned and the service fails closed.
+var ErrUnavailable = errors.New("virus scanner unavailable")
+
+// Verdict is the outcome of a scan.
+type Verdict struct {
+	Infected  bool
+	Signature string // Name of the malware found
+}
+
+// Scanner is a virus scanner adapter.
+type Scanner interface {
+	// Scan checks data for malware.
+	Scan(ctx context.Context, data []byte) (*Verdict, error)
+}
+
+// ScannerFunc adapts a function to the Scanner interface.
+type ScannerFunc func(ctx context.Context, data []byte) (*Verdict, error)
+
+// Scan calls f.
+func (f ScannerFunc) Scan(ctx context.Context, data []byte) (*Verdict, error) {
+	return f(ctx, data)
+}
+
+// Service scans uploads and quarantines the infected ones.
+type Service struct {
+	Scanner    Scanner
+	Quarantine storage.Storage        // Where flagged files are kept; must not be publicly served
+	Store      models.QuarantineStore // Record of the quarantined files
+	Timeout    time.Duration          // Time allowed for one scan
+	FailOpen   bool                   // Accept uploads when the scanner cannot be reached
+}
+
+// New creates the scanning service selected by the configuration.
+//
+// Parameters:
+//   - cfg: The antivirus configuration.
+//   - store: The record of quarantined files.
+//
+// Returns:
+//   - *Service: The service, or nil if scanning is disabled.
+//   - error: An error if the driver is unknown.
+func New(cfg config.AntivirusConfig, store models.QuarantineStore) (*Service, error) {
+	var scanner Scanner
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case "clamav":
+		scanner = &ClamAV{Address: cfg.Address}
+	default:
+		return nil, fmt.Errorf("unknown antivirus driver %q", cfg.Driver)
+	}
+	return &Service{
+		Scanner:    scanner,
+		Quarantine: &storage.LocalStorage{Dir: cfg.QuarantineDir},
+		Store:      store,
+		Timeout:    cfg.Timeout,
+		FailOpen:   cfg.FailOpen,
+	}, nil
+}
+
+// Check scans an upload before it is stored. An infected file is moved to quarantine and
+// rejected.
+//
+// Parameters:
+//   - ctx: The request context; the scan is also limited to the service's timeout.
+//   - data: The uploaded file.
+//   - upload: Describes the upload: its source, entity, file name, content type and uploader.
+//
+// Returns:
+//   - error: A validation error naming the malware if the file is infected, ErrUnavailable
+//     if it cannot be scanned and the service fails closed, or nil if it may be stored.
+func (s *Service) Check(ctx context.Context, data []byte, upload models.QuarantinedFile) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	verdict, err := s.Scanner.Scan(ctx, data)
+	if err != nil {
+		if s.FailOpen {
+			log.Printf("antivirus: %s accepted unscanned: %v", upload.FileName, err)
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if !verdict.Infected {
+		return nil
+	}
+
+	upload.Signature = verdict.Signature
+	upload.SizeBytes = len(data)
+	upload.DetectedAt = time.Now()
+	upload.StorageKey = fmt.Sprintf("%s/%d-%s", upload.Source, upload.DetectedAt.UnixNano(), path.Base(upload.FileName))
+	if err := s.Quarantine.Put(upload.StorageKey, data, upload.ContentType); err != nil {
+		return fmt.Errorf("quarantine %s: %w", upload.FileName, err)
+	}
+	if err := s.Store.CreateQuarantinedFile(ctx, &upload); err != nil {
+		s.Quarantine.Delete(upload.StorageKey)
+		return fmt.Errorf("quarantine %s: %w", upload.FileName, err)
+	}
+	log.Printf("antivirus: quarantined %s uploaded by %s: %s", upload.FileName, upload.UploadedBy, upload.Signature)
+	return models.Invalid("%s was rejected because it contains malware (%s)", upload.FileName, upload.Signature)
+}
@@ -0,0 +1,110 @@
+package antivirus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eicar is the standard antivirus test file.
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM requests, flagging streams that contain the EICAR test file.
+func fakeClamd(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			command := make([]byte, len("zINSTREAM\x00"))
+			io.ReadFull(conn, command)
+			var data []byte
+			for {
+				var size [4]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					break
+				}
+				n := binary.BigEndian.Uint32(size[:])
+				if n == 0 {
+					break
+				}
+				chunk := make([]byte, n)
+				io.ReadFull(conn, chunk)
+				data = append(data, chunk...)
+			}
+			if string(data) == eicar {
+				conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestClamAV(t *testing.T) {
+	scanner := &ClamAV{Address: fakeClamd(t)}
+
+	verdict, err := scanner.Scan(context.Background(), []byte("Approved by the CFO"))
+	require.NoError(t, err)
+	assert.False(t, verdict.Infected)
+
+	verdict, err = scanner.Scan(context.Background(), []byte(eicar))
+	require.NoError(t, err)
+	assert.True(t, verdict.Infected)
+	assert.Equal(t, "Win.Test.EICAR_HDB-1", verdict.Signature)
+}
+
+// fakeQuarantineStore keeps quarantined files in memory.
+type fakeQuarantineStore struct {
+	models.QuarantineStore
+	files []models.QuarantinedFile
+}
+
+func (f *fakeQuarantineStore) CreateQuarantinedFile(ctx context.Context, file *models.QuarantinedFile) error {
+	file.ID = len(f.files) + 1
+	f.files = append(f.files, *file)
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	store := &fakeQuarantineStore{}
+	files := storage.NewMemoryStorage()
+	service := &Service{Scanner: &ClamAV{Address: fakeClamd(t)}, Quarantine: files, Store: store}
+	upload := models.QuarantinedFile{Source: models.UploadJournalAttachment, EntityID: 3, FileName: "receipt.pdf", UploadedBy: "clerk@example.com"}
+
+	assert.NoError(t, service.Check(context.Background(), []byte("%PDF-1.4"), upload))
+	assert.Empty(t, store.files)
+
+	err := service.Check(context.Background(), []byte(eicar), upload)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Contains(t, err.Error(), "Win.Test.EICAR_HDB-1")
+	require.Len(t, store.files, 1)
+	assert.Equal(t, "Win.Test.EICAR_HDB-1", store.files[0].Signature)
+	assert.Equal(t, []byte(eicar), files.Files[store.files[0].StorageKey])
+}
+
+func TestCheckWhenScannerIsDown(t *testing.T) {
+	down := ScannerFunc(func(ctx context.Context, data []byte) (*Verdict, error) {
+		return nil, errors.New("connection refused")
+	})
+	service := &Service{Scanner: down}
+
+	assert.ErrorIs(t, service.Check(context.Background(), []byte("x"), models.QuarantinedFile{}), ErrUnavailable)
+	service.FailOpen = true
+	assert.NoError(t, service.Check(context.Background(), []byte("x"), models.QuarantinedFile{}))
+}
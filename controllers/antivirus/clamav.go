@@ -0,0 +1,70 @@
+package antivirus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// chunkSize is the size of the chunks streamed to clamd.
+const chunkSize = 64 << 10
+
+// ClamAV scans files with a clamd daemon using its INSTREAM command.
+type ClamAV struct {
+	Address string // host:port of clamd's TCP socket, or the path of its Unix socket
+}
+
+// Scan streams data to clamd and parses its reply, e.g. "stream: OK" or
+// "stream: Win.Test.EICAR_HDB-1 FOUND".
+func (c *ClamAV) Scan(ctx context.Context, data []byte) (*Verdict, error) {
+	network := "tcp"
+	if strings.HasPrefix(c.Address, "/") {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+	var size [4]byte
+	for len(data) > 0 {
+		n := min(len(data), chunkSize)
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		if _, err := conn.Write(size[:]); err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(data[:n]); err != nil {
+			return nil, err
+		}
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := conn.Write(size[:]); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return nil, fmt.Errorf("read clamd reply: %w", err)
+	}
+	reply = strings.TrimPrefix(strings.TrimSuffix(reply, "\x00"), "stream: ")
+	switch {
+	case reply == "OK":
+		return &Verdict{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Verdict{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
@@ -0,0 +1,54 @@
+package approvals
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	engine := NewEngine(db)
+
+	mock.ExpectBegin()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	// Different users, and documents without a known creator, need no policy lookup
+	assert.NoError(t, engine.Authorize(ctx, tx, models.DocumentPayment, 1, "clerk@example.com", "manager@example.com"))
+	assert.NoError(t, engine.Authorize(ctx, tx, models.DocumentPayment, 1, "", "manager@example.com"))
+
+	// Enforced: refused, and the attempt is logged outside the approving transaction
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT enabled FROM sod_policies")).WithArgs(models.DocumentPayment).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sod_violations")).
+		WithArgs(models.DocumentPayment, 2, "Clerk@example.com", true, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	err = engine.Authorize(ctx, tx, models.DocumentPayment, 2, "clerk@example.com", "Clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrSameCreatorApprover)
+	assert.ErrorIs(t, err, models.ErrPermissionDenied)
+
+	// Document types without a policy row are enforced too
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT enabled FROM sod_policies")).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sod_violations")).WillReturnResult(sqlmock.NewResult(2, 1))
+	assert.ErrorIs(t, engine.Authorize(ctx, tx, "credit_note", 3, "clerk@example.com", "clerk@example.com"), models.ErrSameCreatorApprover)
+
+	// Disabled: allowed, but still logged for compliance review
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT enabled FROM sod_policies")).WithArgs(models.DocumentJournalEntry).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sod_violations")).
+		WithArgs(models.DocumentJournalEntry, 4, "cfo@example.com", false, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	assert.NoError(t, engine.Authorize(ctx, tx, models.DocumentJournalEntry, 4, "cfo@example.com", "cfo@example.com"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
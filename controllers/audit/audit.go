@@ -0,0 +1,58 @@
+// Package audit writes entries to the audit log. Entries are written with the caller's
+// transaction so an action and its audit record are committed together.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/models"
+	"time"
+)
+
+// Action names recorded in the audit log
+const (
+	ActionVoid                  = "void"
+	ActionPriceUpdate           = "price_update"
+	ActionSettingsUpdate        = "settings_update"
+	ActionSystemMode            = "system_mode"
+	ActionJournalPost           = "journal_post"
+	ActionSoDPolicy             = "sod_policy_update"
+	ActionRetentionPolicy       = "retention_policy_update"
+	ActionLeavePolicy           = "leave_policy_update"
+	ActionExpensePolicy         = "expense_policy_update"
+	ActionExpenseRate           = "expense_rate_update"
+	ActionDepositRefund         = "deposit_refund"
+	ActionDisputeResolve        = "dispute_resolve"
+	ActionReconciliationSignOff = "reconciliation_sign_off"
+	ActionSuspenseResolve       = "suspense_resolve"
+)
+
+// Execer is satisfied by both *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Record inserts an audit entry. CreatedAt is set to the current time when empty.
+//
+// Parameters:
+//   - e: The transaction (or database) to write with.
+//   - entry: The audit entry to record.
+//
+// Returns:
+//   - error: An error if the insert fails.
+func Record(ctx context.Context, e Execer, entry *models.AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	details := entry.Details
+	if len(details) == 0 {
+		details = json.RawMessage("{}")
+	}
+	_, err := e.ExecContext(ctx,
+		`INSERT INTO audit_log (actor, action, entity_type, entity_id, reason, details, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.Actor, entry.Action, entry.EntityType, entry.EntityID, entry.Reason, []byte(details), entry.CreatedAt,
+	)
+	return err
+}
@@ -0,0 +1,271 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/controllers/middleware"
+	"erp/models"
+)
+
+// maxPayload caps the request and response bodies read for the audit trail. Larger bodies,
+// such as file uploads, pass through without their changes being recorded.
+const maxPayload = 64 << 10
+
+// redacted replaces the values of fields holding secrets.
+const redacted = "[redacted]"
+
+// maxEntityType is the size of the audit_logs.entity_type column.
+const maxEntityType = 100
+
+// Trail records every change made through the API in the audit trail.
+type Trail struct {
+	Store models.RequestAuditStore
+	// Before serves the GET that reads an entity before it is changed, typically the router;
+	// nil records the new values only
+	Before http.Handler
+	Now    func() time.Time
+}
+
+// NewTrail creates an audit trail.
+func NewTrail(store models.RequestAuditStore, before http.Handler) *Trail {
+	return &Trail{Store: store, Before: before, Now: time.Now}
+}
+
+// change is the value of a field before and after a request.
+type change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Middleware records POST, PUT, PATCH and DELETE requests once they are served, with the
+// user signed in with the JWT, if any, and the response status. The entity is the first
+// numeric segment of the path, or the "id" of the JSON response of a create. Before a
+// request for an entity is served, the entity is read with a GET of its path as the same
+// user, so the fields of the JSON payload can be recorded with their previous values.
+// Failures are logged rather than returned so that changes never depend on the audit trail.
+func (t *Trail) Middleware(next http.Handler) http.Handler {
+	record := middleware.OptionalJWTAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.serve(w, r, next)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			record.ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// serve serves a change and records it.
+func (t *Trail) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	entry := &models.RequestAudit{Actor: actor, Method: r.Method, Path: r.URL.Path, CreatedAt: t.Now()}
+	var entityPath string
+	entry.EntityType, entry.EntityID, entityPath = entity(r.URL.Path)
+
+	payload := readPayload(r)
+	var before map[string]interface{}
+	if entityPath != "" && t.Before != nil {
+		before = t.read(r, entityPath)
+	}
+
+	response := &recorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(response, r)
+	entry.Status = response.status
+	if entry.EntityID == "" {
+		entry.EntityID = createdID(response.body)
+	}
+	if entry.Status < http.StatusBadRequest {
+		if changes := diff(r.Method, before, payload); changes != nil {
+			entry.Changes, _ = json.Marshal(changes)
+		}
+	}
+	if err := t.Store.RecordRequestAudit(r.Context(), entry); err != nil {
+		log.Printf("audit: failed to record %s %s: %v", entry.Method, entry.Path, err)
+	}
+}
+
+// read returns the JSON object served at the entity's path, or nil if it cannot be read.
+func (t *Trail) read(r *http.Request, path string) map[string]interface{} {
+	get, err := http.NewRequestWithContext(r.Context(), http.MethodGet, path, nil)
+	if err != nil {
+		return nil
+	}
+	get.Header = r.Header.Clone()
+	get.Header.Del("Content-Type")
+	get.RemoteAddr = r.RemoteAddr
+	response := &recorder{ResponseWriter: &discard{header: http.Header{}}, status: http.StatusOK}
+	t.Before.ServeHTTP(response, get)
+	if response.status != http.StatusOK {
+		return nil
+	}
+	return object(response.body)
+}
+
+// readPayload returns the JSON object sent with the request and puts the body back for the
+// handler, or returns nil if the body is not a JSON object or is too large.
+func readPayload(r *http.Request) map[string]interface{} {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Body == nil || mediaType != "application/json" || r.ContentLength > maxPayload {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayload+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return nil
+	}
+	return object(body)
+}
+
+// object decodes a JSON object, or returns nil if the body is not one or was cut short.
+func object(body []byte) map[string]interface{} {
+	if len(body) > maxPayload {
+		return nil
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return nil
+	}
+	return fields
+}
+
+// entity splits a path into the entity type, the segments before the first numeric one,
+// and the entity ID, that segment, and returns the path of the entity. Paths without an ID
+// return the whole path as the type.
+func entity(path string) (entityType, entityID, entityPath string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	entityType = strings.Join(segments, "/")
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil && i > 0 {
+			entityType, entityID = strings.Join(segments[:i], "/"), segment
+			entityPath = "/" + strings.Join(segments[:i+1], "/")
+			break
+		}
+	}
+	if len(entityType) > maxEntityType {
+		entityType = entityType[:maxEntityType]
+	}
+	return entityType, entityID, entityPath
+}
+
+// createdID returns the "id" of a JSON response, the entity a POST created.
+func createdID(body []byte) string {
+	switch id := object(body)["id"].(type) {
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	case string:
+		return id
+	}
+	return ""
+}
+
+// diff compares the fields of the payload with their values before the request and leaves
+// out those it does not change. A DELETE removes every field of the entity. It returns nil
+// when there is nothing to compare, such as a payload that is not a JSON object.
+func diff(method string, before, payload map[string]interface{}) map[string]change {
+	if method == http.MethodDelete {
+		if before == nil {
+			return nil
+		}
+		changes := map[string]change{}
+		for field, value := range before {
+			changes[field] = change{From: redact(field, value)}
+		}
+		return changes
+	}
+	if payload == nil {
+		return nil
+	}
+	changes := map[string]change{}
+	for field, value := range payload {
+		old, found := before[field]
+		switch {
+		case secret(field):
+			changes[field] = change{From: redacted, To: redacted}
+		case !found || !reflect.DeepEqual(old, value):
+			changes[field] = change{From: redact(field, old), To: redact(field, value)}
+		}
+	}
+	return changes
+}
+
+// secret reports whether a field holds a secret, such as a password or token.
+func secret(field string) bool {
+	field = strings.ToLower(field)
+	for _, word := range []string{"password", "secret", "token", "api_key"} {
+		if strings.Contains(field, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces secrets in a value, including in nested objects and lists.
+func redact(field string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if secret(field) {
+		return redacted
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(v))
+		for name, nested := range v {
+			fields[name] = redact(name, nested)
+		}
+		return fields
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redact("", item)
+		}
+		return items
+	}
+	return value
+}
+
+// recorder passes a response through, keeping its status and the start of its body.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        []byte
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	if room := maxPayload + 1 - len(r.body); room > 0 {
+		r.body = append(r.body, b[:min(room, len(b))]...)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// discard is a ResponseWriter that drops the response, for the GET of an entity.
+type discard struct {
+	header http.Header
+}
+
+func (d *discard) Header() http.Header         { return d.header }
+func (d *discard) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discard) WriteHeader(int)             {}
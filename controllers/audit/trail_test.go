@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"erp/controllers/utils"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRequestAuditStore struct {
+	audits []*models.RequestAudit
+}
+
+func (m *mockRequestAuditStore) RecordRequestAudit(ctx context.Context, audit *models.RequestAudit) error {
+	m.audits = append(m.audits, audit)
+	return nil
+}
+
+func (m *mockRequestAuditStore) ListRequestAudits(ctx context.Context, filter models.RequestAuditFilter) ([]models.RequestAudit, error) {
+	return nil, nil
+}
+
+func (m *mockRequestAuditStore) GetRequestAudit(ctx context.Context, id int) (*models.RequestAudit, error) {
+	return nil, nil
+}
+
+// newProductRouter serves a product that the trail reads before each change. The handlers
+// echo the payload they receive, so the test can check the body reached them.
+func newProductRouter(store *mockRequestAuditStore) *mux.Router {
+	router := mux.NewRouter()
+	trail := NewTrail(store, router)
+	trail.Now = func() time.Time { return time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC) }
+	router.Use(trail.Middleware)
+	echo := func(status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			io.Copy(w, r.Body)
+		}
+	}
+	router.HandleFunc("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id": 9, "name": "Pen", "price": 10, "secret_note": "vault"}`))
+	}).Methods("GET")
+	router.HandleFunc("/products/{id}", echo(http.StatusOK)).Methods("PUT")
+	router.HandleFunc("/products/{id}", echo(http.StatusNoContent)).Methods("DELETE")
+	router.HandleFunc("/products/{id}/price", echo(http.StatusUnprocessableEntity)).Methods("POST")
+	router.HandleFunc("/products", echo(http.StatusCreated)).Methods("POST")
+	return router
+}
+
+func send(t *testing.T, router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	token, err := utils.GenerateJWT("jane@example.com", "admin")
+	require.NoError(t, err)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func changes(t *testing.T, audit *models.RequestAudit) map[string]change {
+	var fields map[string]change
+	require.NoError(t, json.Unmarshal(audit.Changes, &fields))
+	return fields
+}
+
+func TestTrailRecordsUpdates(t *testing.T) {
+	store := &mockRequestAuditStore{}
+	router := newProductRouter(store)
+
+	rr := send(t, router, "PUT", "/products/9", `{"name": "Pen", "price": 12, "password": "hunter2"}`)
+	assert.Equal(t, `{"name": "Pen", "price": 12, "password": "hunter2"}`, rr.Body.String(), "The handler gets the whole body")
+	require.Len(t, store.audits, 1)
+	audit := store.audits[0]
+	assert.Equal(t, "jane@example.com", audit.Actor)
+	assert.Equal(t, "PUT", audit.Method)
+	assert.Equal(t, "products", audit.EntityType)
+	assert.Equal(t, "9", audit.EntityID)
+	assert.Equal(t, http.StatusOK, audit.Status)
+	assert.Equal(t, time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), audit.CreatedAt)
+	assert.Equal(t, map[string]change{
+		"price":    {From: float64(10), To: float64(12)},
+		"password": {From: redacted, To: redacted},
+	}, changes(t, audit), "Unchanged fields are left out and secrets are redacted")
+
+	// Reads are not recorded
+	send(t, router, "GET", "/products/9", "")
+	assert.Len(t, store.audits, 1)
+}
+
+func TestTrailRecordsCreatesAndDeletes(t *testing.T) {
+	store := &mockRequestAuditStore{}
+	router := newProductRouter(store)
+
+	send(t, router, "POST", "/products", `{"id": 12, "name": "Ink"}`)
+	require.Len(t, store.audits, 1)
+	assert.Equal(t, "products", store.audits[0].EntityType)
+	assert.Equal(t, "12", store.audits[0].EntityID, "The ID of a create is read from the response")
+	assert.Equal(t, map[string]change{"id": {To: float64(12)}, "name": {To: "Ink"}}, changes(t, store.audits[0]))
+
+	send(t, router, "DELETE", "/products/9", "")
+	require.Len(t, store.audits, 2)
+	assert.Equal(t, map[string]change{
+		"id":          {From: float64(9)},
+		"name":        {From: "Pen"},
+		"price":       {From: float64(10)},
+		"secret_note": {From: redacted},
+	}, changes(t, store.audits[1]))
+
+	// Failed requests change nothing
+	send(t, router, "POST", "/products/9/price", `{"price": 11}`)
+	require.Len(t, store.audits, 3)
+	assert.Equal(t, http.StatusUnprocessableEntity, store.audits[2].Status)
+	assert.Equal(t, "9", store.audits[2].EntityID)
+	assert.Nil(t, store.audits[2].Changes)
+}
+
+func TestTrailWithoutUser(t *testing.T) {
+	store := &mockRequestAuditStore{}
+	router := newProductRouter(store)
+
+	// The previous values cannot be read without signing in, so only the new ones are known
+	req := httptest.NewRequest("PUT", "/products/9", strings.NewReader(`{"price": 12}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	require.Len(t, store.audits, 1)
+	assert.Empty(t, store.audits[0].Actor)
+	assert.Equal(t, map[string]change{"price": {To: float64(12)}}, changes(t, store.audits[0]))
+
+	// Bodies that are not JSON are not compared
+	req = httptest.NewRequest("PUT", "/products/9", strings.NewReader("price=12"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	require.Len(t, store.audits, 2)
+	assert.Nil(t, store.audits[1].Changes)
+}
+
+func TestEntity(t *testing.T) {
+	for path, want := range map[string][3]string{
+		"/products/9":              {"products", "9", "/products/9"},
+		"/invoices/12/approve":     {"invoices", "12", "/invoices/12"},
+		"/stock/policies/4":        {"stock/policies", "4", "/stock/policies/4"},
+		"/auth/logout":             {"auth/logout", "", ""},
+		"/gift_cards/ABC-123/void": {"gift_cards/ABC-123/void", "", ""},
+	} {
+		entityType, entityID, entityPath := entity(path)
+		assert.Equal(t, want, [3]string{entityType, entityID, entityPath}, path)
+	}
+}
@@ -0,0 +1,454 @@
+// Package backup exports the application tables to a compressed file and verifies that a
+// backup can be read back in full. It gives small deployments without a DBA a safety net
+// next to, not instead of, database-level backups such as pg_dump.
+//
+// A backup is a gzip-compressed file of JSON lines, taken from one consistent snapshot:
+//
+//	{"format":"erp-backup","version":1,"created_at":"..."}
+//	{"table":"users"}
+//	{"row":{"id":1,"email":"..."}}
+//	{"end":"users","rows":1}
+//	...
+//	{"manifest":{"users":1,...}}
+//
+// The manifest is written last, so a truncated file is detected by its absence.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"erp/controllers/jobs"
+	"erp/controllers/storage"
+	"erp/models"
+	"erp/models/db"
+
+	"github.com/lib/pq"
+)
+
+// Job kinds
+const (
+	KindBackup = "backup"
+	KindVerify = "backup_verify"
+)
+
+// Format and Version identify the backup file format.
+const (
+	Format  = "erp-backup"
+	Version = 1
+)
+
+// maxLineSize bounds a single row in a backup file.
+const maxLineSize = 64 << 20
+
+// Result describes a backup file. It is stored as the result of backup and verify jobs.
+type Result struct {
+	Key       string           `json:"key"`        // Storage key of the backup file
+	SizeBytes int64            `json:"size_bytes"` // Size of the compressed file
+	SHA256    string           `json:"sha256"`     // Checksum of the compressed file
+	Tables    map[string]int64 `json:"tables"`     // Row count per table
+	CreatedAt time.Time        `json:"created_at"`
+	BackupJob int              `json:"backup_job,omitempty"` // For verify jobs, the backup that was verified
+}
+
+// ErrNotBackup is returned when a job is not a successful backup.
+var ErrNotBackup = errors.New("job is not a successful backup")
+
+// Service takes and verifies backups as background jobs.
+type Service struct {
+	DB      *sql.DB
+	Storage storage.Storage // Where backup files are written; must not be publicly served
+	Jobs    *jobs.Runner
+	Keep    int              // Number of nightly backups kept; 0 keeps all
+	Now     func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a backup service.
+func NewService(db *sql.DB, store storage.Storage, runner *jobs.Runner, keep int) *Service {
+	return &Service{DB: db, Storage: store, Jobs: runner, Keep: keep, Now: time.Now}
+}
+
+// Start begins a backup in the background.
+//
+// Parameters:
+//   - actor: Email of the admin who requested the backup.
+//
+// Returns:
+//   - *Job: The queued backup job.
+//   - error: An error if the job cannot be created.
+func (s *Service) Start(ctx context.Context, actor string) (*models.Job, error) {
+	return s.Jobs.Start(ctx, KindBackup, actor, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		return s.Backup(ctx, p)
+	})
+}
+
+// StartVerify begins verifying a backup in the background.
+//
+// Parameters:
+//   - backupJob: The backup job whose file is verified.
+//   - actor: Email of the admin who requested the verification.
+//
+// Returns:
+//   - *Job: The queued verify job.
+//   - error: ErrNotBackup if backupJob did not produce a backup, or an error if the job
+//     cannot be created.
+func (s *Service) StartVerify(ctx context.Context, backupJob *models.Job, actor string) (*models.Job, error) {
+	var expected Result
+	if backupJob.Kind != KindBackup || backupJob.Status != models.JobSucceeded ||
+		json.Unmarshal(backupJob.Result, &expected) != nil || expected.Key == "" {
+		return nil, ErrNotBackup
+	}
+	return s.Jobs.Start(ctx, KindVerify, actor, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		result, err := s.Verify(ctx, &expected, p)
+		if err != nil {
+			return nil, err
+		}
+		result.BackupJob = backupJob.ID
+		return result, nil
+	})
+}
+
+// Nightly takes a backup and then deletes the files of backups older than the newest Keep.
+// It is run by the scheduler.
+func (s *Service) Nightly(ctx context.Context) error {
+	_, err := s.Jobs.Run(ctx, KindBackup, "scheduler", func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		return s.Backup(ctx, p)
+	})
+	if err != nil {
+		return err
+	}
+	return s.Prune(ctx)
+}
+
+// Prune deletes the files of all but the newest Keep successful backups.
+func (s *Service) Prune(ctx context.Context) error {
+	if s.Keep <= 0 {
+		return nil
+	}
+	backups, err := s.Jobs.Store.ListJobs(ctx, KindBackup, 1000)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, job := range backups {
+		var result Result
+		if job.Status != models.JobSucceeded || json.Unmarshal(job.Result, &result) != nil || result.Key == "" {
+			continue
+		}
+		if kept < s.Keep {
+			kept++
+			continue
+		}
+		if err := s.Storage.Delete(result.Key); err != nil {
+			return fmt.Errorf("delete backup %s: %w", result.Key, err)
+		}
+	}
+	return nil
+}
+
+// Backup exports every table in the public schema from a single read-only snapshot.
+//
+// Parameters:
+//   - ctx: Cancels the export. Its statements are not bound by the query timeout.
+//   - p: Receives the number of rows exported.
+//
+// Returns:
+//   - *Result: The stored file and its row counts.
+//   - error: An error if a table cannot be read or the file cannot be written. A partially
+//     written file is not kept.
+func (s *Service) Backup(ctx context.Context, p *jobs.Progress) (*Result, error) {
+	ctx = db.WithoutTimeout(ctx)
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tables, err := listTables(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	var estimate int64
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(GREATEST(c.reltuples, 0)), 0)::BIGINT FROM pg_class c
+		 JOIN pg_namespace n ON n.oid = c.relnamespace
+		 WHERE n.nspname = 'public' AND c.relname = ANY($1)`, pq.Array(tables),
+	).Scan(&estimate); err == nil {
+		p.SetTotal(estimate)
+	}
+
+	result := &Result{CreatedAt: s.Now().UTC(), Tables: make(map[string]int64, len(tables))}
+	result.Key = fmt.Sprintf("backups/%s-%s.jsonl.gz", Format, result.CreatedAt.Format("20060102T150405Z"))
+
+	file, err := storage.Create(s.Storage, result.Key, "application/gzip")
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	counter := &countingWriter{}
+	zw := gzip.NewWriter(io.MultiWriter(file, hash, counter))
+	out := bufio.NewWriter(zw)
+
+	err = writeLine(out, map[string]interface{}{"format": Format, "version": Version, "created_at": result.CreatedAt})
+	for _, table := range tables {
+		if err != nil {
+			break
+		}
+		result.Tables[table], err = exportTable(ctx, tx, out, table, p)
+	}
+	if err == nil {
+		err = writeLine(out, map[string]interface{}{"manifest": result.Tables})
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		file.Close()
+		s.Storage.Delete(result.Key)
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	result.SizeBytes = counter.n
+	result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	log.Printf("backup: wrote %s (%d tables, %d bytes)", result.Key, len(tables), result.SizeBytes)
+	return result, nil
+}
+
+// listTables returns the application tables in the public schema.
+func listTables(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT table_name FROM information_schema.tables
+		 WHERE table_schema = 'public' AND table_type = 'BASE TABLE' ORDER BY table_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// exportTable writes the rows of one table, streaming them from the database.
+func exportTable(ctx context.Context, tx *sql.Tx, out io.Writer, table string, p *jobs.Progress) (int64, error) {
+	if err := writeLine(out, map[string]string{"table": table}); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT row_to_json(t)::TEXT FROM %s t", pq.QuoteIdentifier(table)))
+	if err != nil {
+		return 0, fmt.Errorf("export %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return count, fmt.Errorf("export %s: %w", table, err)
+		}
+		if err := writeLine(out, map[string]json.RawMessage{"row": row}); err != nil {
+			return count, err
+		}
+		count++
+		p.Add(1)
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("export %s: %w", table, err)
+	}
+	return count, writeLine(out, map[string]interface{}{"end": table, "rows": count})
+}
+
+// writeLine writes v as one line of JSON.
+func writeLine(out io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(data, '\n'))
+	return err
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// line is any line of a backup file.
+type line struct {
+	Format   string           `json:"format"`
+	Version  int              `json:"version"`
+	Table    string           `json:"table"`
+	Row      json.RawMessage  `json:"row"`
+	End      string           `json:"end"`
+	Rows     int64            `json:"rows"`
+	Manifest map[string]int64 `json:"manifest"`
+}
+
+// Verify reads a backup file in full and checks that it is intact: the checksum and size
+// match, every row is valid JSON, and the row counts agree with each table's trailer and
+// with the manifest. This is what a restore would read, without touching the database.
+//
+// Parameters:
+//   - expected: The result recorded when the backup was taken.
+//   - p: Receives the number of rows read.
+//
+// Returns:
+//   - *Result: What was found in the file.
+//   - error: An error describing the first problem found.
+func (s *Service) Verify(ctx context.Context, expected *Result, p *jobs.Progress) (*Result, error) {
+	file, err := storage.Open(s.Storage, expected.Key)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", expected.Key, err)
+	}
+	defer file.Close()
+	p.SetTotal(sum(expected.Tables))
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	raw := io.TeeReader(file, io.MultiWriter(hash, counter))
+	zr, err := gzip.NewReader(raw)
+	if err != nil {
+		return nil, fmt.Errorf("not a gzip file: %w", err)
+	}
+	tables, err := readBackup(ctx, zr, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(io.Discard, raw); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Key:       expected.Key,
+		SizeBytes: counter.n,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+		Tables:    tables,
+		CreatedAt: expected.CreatedAt,
+	}
+	if expected.SHA256 != "" && result.SHA256 != expected.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch: file has %s, backup recorded %s", result.SHA256, expected.SHA256)
+	}
+	if expected.SizeBytes != 0 && result.SizeBytes != expected.SizeBytes {
+		return nil, fmt.Errorf("size mismatch: file has %d bytes, backup recorded %d", result.SizeBytes, expected.SizeBytes)
+	}
+	return result, nil
+}
+
+// readBackup parses a decompressed backup and returns its row counts per table. Each row
+// is passed to onRow if it is not nil.
+func readBackup(ctx context.Context, r io.Reader, p *jobs.Progress, onRow func(table string, row json.RawMessage)) (map[string]int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+
+	tables := map[string]int64{}
+	lineNo := 0
+	current := ""
+	var manifest map[string]int64
+	for scanner.Scan() {
+		lineNo++
+		if manifest != nil {
+			return nil, fmt.Errorf("line %d: data after the manifest", lineNo)
+		}
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		switch {
+		case lineNo == 1:
+			if l.Format != Format || l.Version != Version {
+				return nil, fmt.Errorf("unsupported backup format %q version %d", l.Format, l.Version)
+			}
+		case l.Row != nil:
+			if current == "" {
+				return nil, fmt.Errorf("line %d: row outside of a table", lineNo)
+			}
+			tables[current]++
+			p.Add(1)
+			if onRow != nil {
+				onRow(current, l.Row)
+			}
+		case l.Table != "":
+			if current != "" {
+				return nil, fmt.Errorf("line %d: table %s starts before %s ends", lineNo, l.Table, current)
+			}
+			if _, seen := tables[l.Table]; seen {
+				return nil, fmt.Errorf("line %d: table %s appears twice", lineNo, l.Table)
+			}
+			current = l.Table
+			tables[current] = 0
+		case l.End != "":
+			if l.End != current {
+				return nil, fmt.Errorf("line %d: unexpected end of table %s", lineNo, l.End)
+			}
+			if l.Rows != tables[current] {
+				return nil, fmt.Errorf("table %s: trailer says %d rows, found %d", current, l.Rows, tables[current])
+			}
+			current = ""
+		case l.Manifest != nil:
+			if current != "" {
+				return nil, fmt.Errorf("line %d: manifest before table %s ends", lineNo, current)
+			}
+			manifest = l.Manifest
+		default:
+			return nil, fmt.Errorf("line %d: unrecognized line", lineNo)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lineNo == 0 {
+		return nil, errors.New("backup is empty")
+	}
+	if manifest == nil {
+		return nil, errors.New("backup is truncated: manifest missing")
+	}
+
+	if len(manifest) != len(tables) {
+		return nil, fmt.Errorf("manifest lists %d tables, found %d", len(manifest), len(tables))
+	}
+	for table, rows := range manifest {
+		if found, ok := tables[table]; !ok || found != rows {
+			return nil, fmt.Errorf("table %s: manifest says %d rows, found %d", table, rows, found)
+		}
+	}
+	return tables, nil
+}
+
+// sum adds up row counts.
+func sum(counts map[string]int64) int64 {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,237 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"erp/controllers/jobs"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryJobStore keeps jobs in memory.
+type memoryJobStore struct {
+	mu   sync.Mutex
+	jobs []*models.Job
+}
+
+func (m *memoryJobStore) CreateJob(ctx context.Context, job *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.ID = len(m.jobs) + 1
+	copy := *job
+	m.jobs = append(m.jobs, &copy)
+	return nil
+}
+
+func (m *memoryJobStore) StartJob(ctx context.Context, id int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id-1].Status, m.jobs[id-1].StartedAt = models.JobRunning, &at
+	return nil
+}
+
+func (m *memoryJobStore) UpdateJobProgress(ctx context.Context, id int, processed, total int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id-1].Processed, m.jobs[id-1].Total = processed, total
+	return nil
+}
+
+func (m *memoryJobStore) FinishJob(ctx context.Context, id int, result json.RawMessage, errMsg string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id-1]
+	job.Status, job.Result, job.Error, job.FinishedAt = models.JobSucceeded, result, errMsg, &at
+	if errMsg != "" {
+		job.Status = models.JobFailed
+	}
+	return nil
+}
+
+func (m *memoryJobStore) GetJob(ctx context.Context, id int) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.jobs) {
+		return nil, models.ErrNotFound
+	}
+	copy := *m.jobs[id-1]
+	return &copy, nil
+}
+
+func (m *memoryJobStore) ListJobs(ctx context.Context, kind string, limit int) ([]models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := []models.Job{}
+	for i := len(m.jobs) - 1; i >= 0 && len(list) < limit; i-- {
+		if m.jobs[i].Kind == kind {
+			list = append(list, *m.jobs[i])
+		}
+	}
+	return list, nil
+}
+
+var backupTime = time.Date(2026, 10, 16, 2, 0, 0, 0, time.UTC)
+
+// expectExport sets up the queries of a backup of a users table with two rows and an
+// empty settings table.
+func expectExport(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT table_name FROM information_schema.tables").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("settings").AddRow("users"))
+	mock.ExpectQuery("FROM pg_class").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT row_to_json(t)::TEXT FROM "settings" t`)).
+		WillReturnRows(sqlmock.NewRows([]string{"row_to_json"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT row_to_json(t)::TEXT FROM "users" t`)).
+		WillReturnRows(sqlmock.NewRows([]string{"row_to_json"}).
+			AddRow(`{"id":1,"email":"admin@example.com"}`).
+			AddRow(`{"id":2,"email":"clerk@example.com"}`))
+	mock.ExpectRollback()
+}
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock, *storage.MemoryStorage, *memoryJobStore) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	files := storage.NewMemoryStorage()
+	jobStore := &memoryJobStore{}
+	service := NewService(db, files, jobs.NewRunner(jobStore), 1)
+	service.Now = func() time.Time { return backupTime }
+	return service, mock, files, jobStore
+}
+
+func TestBackupAndVerify(t *testing.T) {
+	ctx := context.Background()
+	service, mock, files, jobStore := newTestService(t)
+	expectExport(mock)
+
+	job, err := service.Start(ctx, "admin@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobQueued, job.Status)
+	service.Jobs.Wait()
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	done, err := jobStore.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.JobSucceeded, done.Status, done.Error)
+	assert.Equal(t, int64(2), done.Processed)
+
+	var result Result
+	require.NoError(t, json.Unmarshal(done.Result, &result))
+	assert.Equal(t, "backups/erp-backup-20261016T020000Z.jsonl.gz", result.Key)
+	assert.Equal(t, map[string]int64{"settings": 0, "users": 2}, result.Tables)
+	assert.Len(t, result.SHA256, 64)
+	assert.Equal(t, int64(len(files.Files[result.Key])), result.SizeBytes)
+
+	verify, err := service.StartVerify(ctx, done, "admin@example.com")
+	require.NoError(t, err)
+	service.Jobs.Wait()
+	verified, err := jobStore.GetJob(ctx, verify.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.JobSucceeded, verified.Status, verified.Error)
+
+	var verifyResult Result
+	require.NoError(t, json.Unmarshal(verified.Result, &verifyResult))
+	assert.Equal(t, result.Tables, verifyResult.Tables)
+	assert.Equal(t, result.SHA256, verifyResult.SHA256)
+	assert.Equal(t, job.ID, verifyResult.BackupJob)
+}
+
+func TestBackupFailureKeepsNoFile(t *testing.T) {
+	service, mock, files, _ := newTestService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT table_name").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("users"))
+	mock.ExpectQuery("FROM pg_class").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+	mock.ExpectQuery("SELECT row_to_json").WillReturnError(errors.New("permission denied"))
+	mock.ExpectRollback()
+
+	_, err := service.Backup(context.Background(), &jobs.Progress{})
+	assert.ErrorContains(t, err, "export users: permission denied")
+	assert.Empty(t, files.Files)
+}
+
+func TestStartVerifyRejectsFailedBackup(t *testing.T) {
+	service, _, _, _ := newTestService(t)
+	_, err := service.StartVerify(context.Background(), &models.Job{ID: 1, Kind: KindBackup, Status: models.JobFailed}, "admin@example.com")
+	assert.Equal(t, ErrNotBackup, err)
+}
+
+// compress gzips a backup body for the verification tests.
+func compress(body string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(body))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestVerifyDetectsDamage(t *testing.T) {
+	header := `{"format":"erp-backup","version":1,"created_at":"2026-10-16T02:00:00Z"}` + "\n"
+	users := `{"table":"users"}` + "\n" + `{"row":{"id":1}}` + "\n" + `{"end":"users","rows":1}` + "\n"
+	manifest := `{"manifest":{"users":1}}` + "\n"
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"intact", compress(header + users + manifest), ""},
+		{"truncated", compress(header + users), "manifest missing"},
+		{"missing row", compress(header + `{"table":"users"}` + "\n" + `{"end":"users","rows":1}` + "\n" + manifest), "trailer says 1 rows, found 0"},
+		{"manifest mismatch", compress(header + users + `{"manifest":{"users":1,"stock":3}}` + "\n"), "manifest lists 2 tables"},
+		{"corrupt row", compress(header + `{"table":"users"}` + "\n" + `{"row":{"id":` + "\n"), "line 3"},
+		{"wrong format", compress(`{"format":"other","version":1}` + "\n"), "unsupported backup format"},
+		{"not gzip", []byte("plain text"), "not a gzip file"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			service, _, files, _ := newTestService(t)
+			files.Files["backups/test.jsonl.gz"] = tc.data
+
+			result, err := service.Verify(context.Background(), &Result{Key: "backups/test.jsonl.gz"}, &jobs.Progress{})
+			if tc.want == "" {
+				require.NoError(t, err)
+				assert.Equal(t, map[string]int64{"users": 1}, result.Tables)
+				return
+			}
+			assert.ErrorContains(t, err, tc.want)
+		})
+	}
+}
+
+func TestVerifyDetectsChecksumMismatch(t *testing.T) {
+	service, _, files, _ := newTestService(t)
+	files.Files["backups/test.jsonl.gz"] = compress(`{"format":"erp-backup","version":1}` + "\n" + `{"manifest":{}}` + "\n")
+
+	_, err := service.Verify(context.Background(), &Result{Key: "backups/test.jsonl.gz", SHA256: "0000"}, &jobs.Progress{})
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestPruneKeepsNewestBackups(t *testing.T) {
+	ctx := context.Background()
+	service, _, files, jobStore := newTestService(t)
+	for _, key := range []string{"backups/old.jsonl.gz", "backups/new.jsonl.gz"} {
+		files.Files[key] = []byte("data")
+		result, _ := json.Marshal(Result{Key: key})
+		job := &models.Job{Kind: KindBackup}
+		jobStore.CreateJob(ctx, job)
+		jobStore.FinishJob(ctx, job.ID, result, "", backupTime)
+	}
+
+	require.NoError(t, service.Prune(ctx))
+	assert.NotContains(t, files.Files, "backups/old.jsonl.gz")
+	assert.Contains(t, files.Files, "backups/new.jsonl.gz")
+}
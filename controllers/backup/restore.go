@@ -0,0 +1,180 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"erp/controllers/jobs"
+
+	"github.com/lib/pq"
+)
+
+// restoreChunk is the number of rows inserted per statement.
+const restoreChunk = 500
+
+// Restore replaces the data of every table in the public schema with the rows of a backup,
+// in the caller's transaction so a failed restore changes nothing. Tables the backup does
+// not contain are left empty, tables are filled parents first so foreign keys hold, and
+// serial sequences continue after the restored IDs. The whole backup is held in memory, so
+// Restore is meant for small data sets such as demo data, not for disaster recovery.
+//
+// Parameters:
+//   - ctx: Cancels the restore.
+//   - tx: The transaction the tables are replaced in.
+//   - r: The compressed backup file.
+//
+// Returns:
+//   - map[string]int64: The number of rows restored per table.
+//   - error: An error if the file is damaged, a table in it no longer exists, or a statement fails.
+func Restore(ctx context.Context, tx *sql.Tx, r io.Reader) (map[string]int64, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a gzip file: %w", err)
+	}
+	data := map[string][]json.RawMessage{}
+	counts, err := readBackup(ctx, zr, &jobs.Progress{}, func(table string, row json.RawMessage) {
+		data[table] = append(data[table], row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := listTables(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(tables))
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		exists[table] = true
+		quoted[i] = pq.QuoteIdentifier(table)
+	}
+	for table := range counts {
+		if !exists[table] {
+			return nil, fmt.Errorf("table %s in the backup does not exist", table)
+		}
+	}
+	if len(tables) > 0 {
+		if _, err := tx.ExecContext(ctx, "TRUNCATE "+strings.Join(quoted, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := insertOrder(ctx, tx, tables)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range order {
+		rows := data[table]
+		for start := 0; start < len(rows); start += restoreChunk {
+			chunk := rows[start:min(start+restoreChunk, len(rows))]
+			batch, err := json.Marshal(chunk)
+			if err != nil {
+				return nil, err
+			}
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(
+				"INSERT INTO %[1]s SELECT * FROM json_populate_recordset(NULL::%[1]s, $1)", pq.QuoteIdentifier(table)), batch)
+			if err != nil {
+				return nil, fmt.Errorf("restore %s: %w", table, err)
+			}
+		}
+	}
+	return counts, resetSequences(ctx, tx)
+}
+
+// insertOrder sorts tables so that every table comes after the tables its foreign keys
+// reference. Tables in a reference cycle keep their name order at the end.
+func insertOrder(ctx context.Context, tx *sql.Tx, tables []string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT c.conrelid::regclass::TEXT, c.confrelid::regclass::TEXT
+		 FROM pg_constraint c JOIN pg_namespace n ON n.oid = c.connamespace
+		 WHERE c.contype = 'f' AND n.nspname = 'public' AND c.conrelid <> c.confrelid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	parents := map[string][]string{}
+	for rows.Next() {
+		var child, parent string
+		if err := rows.Scan(&child, &parent); err != nil {
+			return nil, err
+		}
+		parents[child] = append(parents[child], parent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	remaining := append([]string(nil), tables...)
+	sort.Strings(remaining)
+	done := make(map[string]bool, len(tables))
+	var order []string
+	for len(remaining) > 0 {
+		var blocked []string
+		for _, table := range remaining {
+			ready := true
+			for _, parent := range parents[table] {
+				if !done[parent] && parent != table {
+					ready = false
+				}
+			}
+			if ready {
+				order = append(order, table)
+				done[table] = true
+			} else {
+				blocked = append(blocked, table)
+			}
+		}
+		if len(blocked) == len(remaining) {
+			return append(order, blocked...), nil
+		}
+		remaining = blocked
+	}
+	return order, nil
+}
+
+// resetSequences moves every serial sequence in the public schema past the highest ID in
+// its column.
+func resetSequences(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT table_name, column_name, pg_get_serial_sequence(quote_ident(table_name), column_name)
+		 FROM information_schema.columns
+		 WHERE table_schema = 'public' AND column_default LIKE 'nextval(%'`)
+	if err != nil {
+		return err
+	}
+	type serial struct{ table, column, sequence string }
+	var serials []serial
+	for rows.Next() {
+		var s serial
+		var sequence sql.NullString
+		if err := rows.Scan(&s.table, &s.column, &sequence); err != nil {
+			rows.Close()
+			return err
+		}
+		if sequence.Valid {
+			s.sequence = sequence.String
+			serials = append(serials, s)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range serials {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf("SELECT setval($1, COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)",
+			pq.QuoteIdentifier(s.column), pq.QuoteIdentifier(s.table)), s.sequence)
+		if err != nil {
+			return fmt.Errorf("reset sequence %s: %w", s.sequence, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const restoreBackup = `{"format":"erp-backup","version":1}
+{"table":"roles"}
+{"row":{"id":1,"role_name":"Admin"}}
+{"end":"roles","rows":1}
+{"table":"users"}
+{"row":{"id":1,"email":"demo@example.com","role_id":1}}
+{"row":{"id":5,"email":"sales@example.com","role_id":1}}
+{"end":"users","rows":2}
+{"manifest":{"roles":1,"users":2}}
+`
+
+func TestRestore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT table_name FROM information_schema.tables").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("roles").AddRow("sandbox_captures").AddRow("users"))
+	mock.ExpectExec(regexp.QuoteMeta(`TRUNCATE "roles", "sandbox_captures", "users" RESTART IDENTITY CASCADE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM pg_constraint").
+		WillReturnRows(sqlmock.NewRows([]string{"child", "parent"}).AddRow("users", "roles"))
+	// Parents are filled first; tables missing from the backup stay empty
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "roles" SELECT * FROM json_populate_recordset(NULL::"roles", $1)`)).
+		WithArgs([]byte(`[{"id":1,"role_name":"Admin"}]`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users"`)).
+		WithArgs([]byte(`[{"id":1,"email":"demo@example.com","role_id":1},{"id":5,"email":"sales@example.com","role_id":1}]`)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("pg_get_serial_sequence").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "column_name", "sequence"}).AddRow("users", "id", "public.users_id_seq"))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT setval($1, COALESCE((SELECT MAX("id") FROM "users"), 0) + 1, false)`)).
+		WithArgs("public.users_id_seq").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	counts, err := Restore(context.Background(), tx, bytes.NewReader(compress(restoreBackup)))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"roles": 1, "users": 2}, counts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreRejectsUnknownTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT table_name FROM information_schema.tables").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("users"))
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	_, err = Restore(context.Background(), tx, bytes.NewReader(compress(restoreBackup)))
+	assert.EqualError(t, err, "table roles in the backup does not exist")
+
+	_, err = Restore(context.Background(), tx, bytes.NewReader(compress(`{"format":"erp-backup","version":1}`+"\n")))
+	assert.ErrorContains(t, err, "manifest missing", "Damaged files are rejected before anything is deleted")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
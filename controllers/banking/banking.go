@@ -0,0 +1,230 @@
+// Package banking imports bank statement lines into the ledger. A receipt whose reference or
+// description names an invoice with that much outstanding is applied to it as a payment.
+// Every other line is posted against the suspense account, so no movement is lost, and waits
+// in a work queue until it is reclassified to a customer's invoice or to the right account.
+// Open suspense items are aged so that old ones get attention.
+package banking
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// invoicePattern finds an invoice number in a bank reference, e.g. "INV-42" or "Invoice #42".
+var invoicePattern = regexp.MustCompile(`(?i)\binv(?:oice)?\s*(?:no\.?|number)?\s*[#:-]?\s*(\d+)\b`)
+
+// agingBuckets are the age ranges open suspense items are reported in, by their first day.
+var agingBuckets = []models.SuspenseAgingBucket{
+	{Bucket: "0-30", MinDays: 0},
+	{Bucket: "31-60", MinDays: 31},
+	{Bucket: "61-90", MinDays: 61},
+	{Bucket: "90+", MinDays: 91},
+}
+
+// Service imports bank statement lines and resolves the suspense items they leave.
+type Service struct {
+	Store models.BankStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a banking service backed by store.
+func NewService(store models.BankStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Import records bank statement lines, applying receipts to the invoices they name and
+// posting the rest to the suspense account. Lines imported before under the same external
+// ID are skipped and reported as duplicates.
+//
+// Parameters:
+//   - lines: Date, Amount, Description, Reference and ExternalID are read; the rest is set.
+//   - actor: Email of the user importing the statement.
+//
+// Returns:
+//   - []models.BankStatementLine: The lines imported, with their outcome.
+//   - error: A validation error if a line is incomplete, in which case none is imported, or
+//     the store's error, in which case the lines before it are imported.
+func (s *Service) Import(ctx context.Context, lines []models.BankStatementLine, actor string) ([]models.BankStatementLine, error) {
+	if len(lines) == 0 {
+		return nil, models.Invalid("the statement has no lines")
+	}
+	now := s.Now()
+	for i := range lines {
+		line := &lines[i]
+		line.ExternalID = strings.TrimSpace(line.ExternalID)
+		line.Description = strings.TrimSpace(line.Description)
+		line.Reference = strings.TrimSpace(line.Reference)
+		line.Amount = round(line.Amount)
+		switch {
+		case line.Date.IsZero():
+			return nil, models.Invalid("line %d has no date", i+1)
+		case line.Amount == 0:
+			return nil, models.Invalid("line %d has no amount", i+1)
+		case line.Description == "" && line.Reference == "":
+			return nil, models.Invalid("line %d needs a description or a reference", i+1)
+		}
+		line.ImportedBy, line.ImportedAt = actor, now
+	}
+	for i := range lines {
+		err := s.Store.ImportBankLine(ctx, &lines[i], MatchInvoice(&lines[i]))
+		if errors.Is(err, models.ErrConflict) && lines[i].ExternalID != "" {
+			lines[i].Status = models.BankLineDuplicate
+			continue
+		}
+		if err != nil {
+			return lines[:i], err
+		}
+	}
+	return lines, nil
+}
+
+// MatchInvoice returns the invoice a receipt names in its reference or, failing that, its
+// description. Payments out of the bank are never matched.
+func MatchInvoice(line *models.BankStatementLine) *int {
+	if line.Amount <= 0 {
+		return nil
+	}
+	for _, text := range []string{line.Reference, line.Description} {
+		if match := invoicePattern.FindStringSubmatch(text); match != nil {
+			if id, err := strconv.Atoi(match[1]); err == nil && id > 0 {
+				return &id
+			}
+		}
+	}
+	return nil
+}
+
+// Queue returns the suspense items with a status, or all of them if it is empty, oldest
+// first, with their age.
+func (s *Service) Queue(ctx context.Context, status string) ([]models.SuspenseItem, error) {
+	switch status {
+	case "", models.SuspenseOpen, models.SuspenseResolved:
+	default:
+		return nil, models.Invalid("unknown suspense item status %q", status)
+	}
+	items, err := s.Store.ListSuspenseItems(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	now := s.Now()
+	for i := range items {
+		setAge(&items[i], now)
+	}
+	return items, nil
+}
+
+// Item returns a suspense item with its age.
+func (s *Service) Item(ctx context.Context, id int) (*models.SuspenseItem, error) {
+	item, err := s.Store.GetSuspenseItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	setAge(item, s.Now())
+	return item, nil
+}
+
+// Resolve reclassifies an open suspense item to the invoice it pays or to another account.
+//
+// Parameters:
+//   - id: The suspense item.
+//   - res: Either InvoiceID or Account, and a Note saying why.
+//   - actor: Email of the user resolving it.
+//
+// Returns:
+//   - *models.SuspenseItem: The resolved item.
+//   - error: A validation error if the resolution is incomplete, names the suspense account
+//     or applies a payment out of the bank to an invoice, a conflict if the item is resolved
+//     already, or the store's error.
+func (s *Service) Resolve(ctx context.Context, id int, res models.SuspenseResolution, actor string) (*models.SuspenseItem, error) {
+	res.Account, res.Note = strings.TrimSpace(res.Account), strings.TrimSpace(res.Note)
+	if (res.InvoiceID == nil) == (res.Account == "") {
+		return nil, models.Invalid("give either invoice_id or account")
+	}
+	if res.Note == "" {
+		return nil, models.Invalid("note is required")
+	}
+	item, err := s.Store.GetSuspenseItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case item.Status != models.SuspenseOpen:
+		return nil, models.Conflict("suspense item %d is %s", id, item.Status)
+	case res.Account == item.Account:
+		return nil, models.Invalid("suspense item %d is already in %s", id, item.Account)
+	case res.InvoiceID != nil && item.Amount < 0:
+		return nil, models.Invalid("suspense item %d is a payment out of the bank and cannot pay an invoice", id)
+	}
+	resolved, err := s.Store.ResolveSuspenseItem(ctx, id, res, actor, s.Now())
+	if err != nil {
+		return nil, err
+	}
+	setAge(resolved, s.Now())
+	return resolved, nil
+}
+
+// Aging returns the open suspense items by age on a day, today if asOf is zero.
+func (s *Service) Aging(ctx context.Context, asOf time.Time) (*models.SuspenseAging, error) {
+	if asOf.IsZero() {
+		asOf = s.Now()
+	}
+	items, err := s.Store.ListSuspenseItems(ctx, models.SuspenseOpen)
+	if err != nil {
+		return nil, err
+	}
+	return Age(items, asOf), nil
+}
+
+// Age buckets suspense items by their age on asOf. Items of bank lines after asOf are left
+// out.
+func Age(items []models.SuspenseItem, asOf time.Time) *models.SuspenseAging {
+	aging := &models.SuspenseAging{AsOf: asOf, Buckets: append([]models.SuspenseAgingBucket(nil), agingBuckets...)}
+	for _, item := range items {
+		age := days(item.Date, asOf)
+		if age < 0 {
+			continue
+		}
+		bucket := &aging.Buckets[0]
+		for i := range aging.Buckets {
+			if age >= aging.Buckets[i].MinDays {
+				bucket = &aging.Buckets[i]
+			}
+		}
+		bucket.Count++
+		bucket.Amount = round(bucket.Amount + item.Amount)
+		aging.Count++
+		aging.Amount = round(aging.Amount + item.Amount)
+	}
+	return aging
+}
+
+// setAge sets an item's age: the days from its bank line to its resolution, or to now.
+func setAge(item *models.SuspenseItem, now time.Time) {
+	end := now
+	if item.ResolvedAt != nil {
+		end = *item.ResolvedAt
+	}
+	item.AgeDays = days(item.Date, end)
+	if item.AgeDays < 0 {
+		item.AgeDays = 0
+	}
+}
+
+// days counts the calendar days from one date to another.
+func days(from, to time.Time) int {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start).Hours() / 24)
+}
+
+// round rounds an amount to cents.
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,170 @@
+package banking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore matches lines to the invoices it knows and keeps suspense items in memory.
+type fakeStore struct {
+	invoices map[int]bool    // Invoices that can take a payment
+	imported map[string]bool // External IDs imported
+	items    map[int]*models.SuspenseItem
+	resolved *models.SuspenseResolution
+}
+
+func (f *fakeStore) ImportBankLine(ctx context.Context, line *models.BankStatementLine, invoiceID *int) error {
+	if line.ExternalID != "" && f.imported[line.ExternalID] {
+		return models.Conflict("bank line %s was imported before", line.ExternalID)
+	}
+	f.imported[line.ExternalID] = true
+	line.ID = len(f.imported)
+	if invoiceID != nil && f.invoices[*invoiceID] {
+		line.Status, line.InvoiceID = models.BankLineMatched, invoiceID
+		return nil
+	}
+	line.Status = models.BankLineSuspense
+	return nil
+}
+
+func (f *fakeStore) GetSuspenseItem(ctx context.Context, id int) (*models.SuspenseItem, error) {
+	item, ok := f.items[id]
+	if !ok {
+		return nil, models.NotFound("suspense item %d not found", id)
+	}
+	copied := *item
+	return &copied, nil
+}
+
+func (f *fakeStore) ListSuspenseItems(ctx context.Context, status string) ([]models.SuspenseItem, error) {
+	var items []models.SuspenseItem
+	for _, item := range f.items {
+		if status == "" || item.Status == status {
+			items = append(items, *item)
+		}
+	}
+	return items, nil
+}
+
+func (f *fakeStore) ResolveSuspenseItem(ctx context.Context, id int, res models.SuspenseResolution, actor string, now time.Time) (*models.SuspenseItem, error) {
+	f.resolved = &res
+	item := f.items[id]
+	item.Status, item.ResolvedAt = models.SuspenseResolved, &now
+	copied := *item
+	return &copied, nil
+}
+
+func newService(store *fakeStore) *Service {
+	s := NewService(store)
+	s.Now = func() time.Time { return time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC) }
+	return s
+}
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestImportMatchesReceiptsAndKeepsTheRestInSuspense(t *testing.T) {
+	store := &fakeStore{invoices: map[int]bool{42: true}, imported: map[string]bool{"b-1": true}}
+	lines := []models.BankStatementLine{
+		{Date: day(6, 2), Amount: 150, Description: "ACME LTD", Reference: "INV-42"},
+		{Date: day(6, 3), Amount: 80, Description: "Payment for invoice #7"}, // Invoice 7 cannot take it
+		{Date: day(6, 4), Amount: -25.5, Description: "Bank fee INV 42"},     // Payments are never matched
+		{Date: day(6, 5), Amount: 10, Description: "Repeated", ExternalID: "b-1"},
+	}
+
+	imported, err := newService(store).Import(context.Background(), lines, "clerk@example.com")
+	require.NoError(t, err)
+	require.Len(t, imported, 4)
+	assert.Equal(t, models.BankLineMatched, imported[0].Status)
+	assert.Equal(t, 42, *imported[0].InvoiceID)
+	assert.Equal(t, models.BankLineSuspense, imported[1].Status)
+	assert.Equal(t, models.BankLineSuspense, imported[2].Status)
+	assert.Equal(t, models.BankLineDuplicate, imported[3].Status)
+	assert.Equal(t, "clerk@example.com", imported[0].ImportedBy)
+}
+
+func TestImportRejectsIncompleteLines(t *testing.T) {
+	store := &fakeStore{imported: map[string]bool{}}
+	_, err := newService(store).Import(context.Background(), []models.BankStatementLine{
+		{Date: day(6, 2), Amount: 10, Description: "ok"},
+		{Date: day(6, 2), Amount: 0.001, Description: "rounds to nothing"},
+	}, "clerk@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation), "%v", err)
+	assert.Empty(t, store.imported, "nothing is imported when a line is invalid")
+}
+
+func TestMatchInvoice(t *testing.T) {
+	for text, want := range map[string]int{
+		"INV-42":            42,
+		"Invoice #108":      108,
+		"inv no. 9 june":    9,
+		"invoice number 31": 31,
+		"INVESTMENT 12":     0,
+		"order 42":          0,
+	} {
+		got := MatchInvoice(&models.BankStatementLine{Amount: 1, Description: text})
+		if want == 0 {
+			assert.Nil(t, got, text)
+		} else if assert.NotNil(t, got, text) {
+			assert.Equal(t, want, *got, text)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{items: map[int]*models.SuspenseItem{
+		1: {ID: 1, Date: day(6, 1), Amount: 100, Account: "suspense", Status: models.SuspenseOpen},
+		2: {ID: 2, Date: day(6, 1), Amount: -40, Account: "suspense", Status: models.SuspenseOpen},
+		3: {ID: 3, Date: day(6, 1), Amount: 10, Account: "suspense", Status: models.SuspenseResolved},
+	}}
+	s := newService(store)
+	invoice := 42
+
+	_, err := s.Resolve(ctx, 1, models.SuspenseResolution{InvoiceID: &invoice, Account: "revenue", Note: "both"}, "a@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation), "either an invoice or an account")
+	_, err = s.Resolve(ctx, 1, models.SuspenseResolution{Account: "revenue"}, "a@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation), "a note is required")
+	_, err = s.Resolve(ctx, 1, models.SuspenseResolution{Account: "suspense", Note: "same"}, "a@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation), "the suspense account itself")
+	_, err = s.Resolve(ctx, 2, models.SuspenseResolution{InvoiceID: &invoice, Note: "refund"}, "a@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation), "payments out cannot pay an invoice")
+	_, err = s.Resolve(ctx, 3, models.SuspenseResolution{Account: "revenue", Note: "again"}, "a@example.com")
+	assert.True(t, errors.Is(err, models.ErrConflict))
+	assert.Nil(t, store.resolved)
+
+	item, err := s.Resolve(ctx, 1, models.SuspenseResolution{InvoiceID: &invoice, Note: " Paid by the parent company "}, "a@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.SuspenseResolved, item.Status)
+	assert.Equal(t, "Paid by the parent company", store.resolved.Note)
+	assert.Equal(t, 29, item.AgeDays)
+}
+
+func TestAge(t *testing.T) {
+	items := []models.SuspenseItem{
+		{Date: day(6, 30), Amount: 10},
+		{Date: day(6, 1), Amount: 20},   // 29 days
+		{Date: day(5, 31), Amount: -5},  // 30 days
+		{Date: day(5, 30), Amount: 40},  // 31 days
+		{Date: day(4, 1), Amount: 60},   // 90 days
+		{Date: day(3, 31), Amount: 100}, // 91 days
+		{Date: day(7, 1), Amount: 1000}, // After the day aged
+	}
+
+	aging := Age(items, day(6, 30))
+	require.Len(t, aging.Buckets, 4)
+	assert.Equal(t, models.SuspenseAgingBucket{Bucket: "0-30", MinDays: 0, Count: 3, Amount: 25}, aging.Buckets[0])
+	assert.Equal(t, models.SuspenseAgingBucket{Bucket: "31-60", MinDays: 31, Count: 1, Amount: 40}, aging.Buckets[1])
+	assert.Equal(t, models.SuspenseAgingBucket{Bucket: "61-90", MinDays: 61, Count: 1, Amount: 60}, aging.Buckets[2])
+	assert.Equal(t, models.SuspenseAgingBucket{Bucket: "90+", MinDays: 91, Count: 1, Amount: 100}, aging.Buckets[3])
+	assert.Equal(t, 6, aging.Count)
+	assert.Equal(t, 225.0, aging.Amount)
+}
@@ -0,0 +1,291 @@
+// Package benefits keeps the benefit plans employees may elect, such as health insurance
+// tiers and allowances, the enrollment windows in which they elect them, and their
+// elections. The elections in force in a pay period become payroll lines of the employee's
+// statutory record, and their costs are reported by department.
+package benefits
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// periodLayout is the format of a pay period.
+const periodLayout = "2006-01"
+
+// Service validates benefit plans, enrollment windows and elections.
+type Service struct {
+	Store models.BenefitStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a benefits service.
+func NewService(store models.BenefitStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Plans returns every benefit plan.
+func (s *Service) Plans(ctx context.Context) ([]models.BenefitPlan, error) {
+	return s.Store.GetBenefitPlans(ctx)
+}
+
+// CreatePlan checks a plan and saves it.
+//
+// Parameters:
+//   - plan: The plan; its ID and update time are set.
+//   - actor: Email of the user creating it.
+//
+// Returns:
+//   - error: A validation error if the plan is incomplete, a conflict if its code is taken,
+//     or the store's error.
+func (s *Service) CreatePlan(ctx context.Context, plan *models.BenefitPlan, actor string) error {
+	if err := ValidatePlan(plan); err != nil {
+		return err
+	}
+	plan.UpdatedBy, plan.UpdatedAt = actor, s.Now()
+	return s.Store.CreateBenefitPlan(ctx, plan)
+}
+
+// UpdatePlan checks a plan and replaces the stored one. Elections already made keep the
+// costs of their tier.
+func (s *Service) UpdatePlan(ctx context.Context, plan *models.BenefitPlan, actor string) error {
+	if err := ValidatePlan(plan); err != nil {
+		return err
+	}
+	plan.UpdatedBy, plan.UpdatedAt = actor, s.Now()
+	return s.Store.UpdateBenefitPlan(ctx, plan)
+}
+
+// ValidatePlan checks a plan's code, name, kind and tiers.
+func ValidatePlan(plan *models.BenefitPlan) error {
+	plan.Code = strings.TrimSpace(plan.Code)
+	plan.Name = strings.TrimSpace(plan.Name)
+	switch {
+	case plan.Code == "" || plan.Name == "":
+		return models.Invalid("code and name are required")
+	case len(plan.Code) > 30 || len(plan.Name) > 60:
+		return models.Invalid("code is limited to 30 characters and name to 60")
+	case plan.Kind != models.BenefitHealthInsurance && plan.Kind != models.BenefitAllowance:
+		return models.Invalid("kind must be %q or %q", models.BenefitHealthInsurance, models.BenefitAllowance)
+	case len(plan.Tiers) == 0:
+		return models.Invalid("a plan needs at least one tier")
+	}
+	names := map[string]bool{}
+	for i := range plan.Tiers {
+		tier := &plan.Tiers[i]
+		tier.Name = strings.TrimSpace(tier.Name)
+		switch {
+		case tier.Name == "" || len(tier.Name) > 30:
+			return models.Invalid("tier %d: name is required and limited to 30 characters", i+1)
+		case names[strings.ToLower(tier.Name)]:
+			return models.Invalid("tier %q is listed twice", tier.Name)
+		case tier.EmployeeCost < 0 || tier.EmployerCost < 0:
+			return models.Invalid("tier %q: costs cannot be negative", tier.Name)
+		case plan.Kind == models.BenefitAllowance && tier.EmployeeCost != 0:
+			return models.Invalid("tier %q: allowances are funded by the employer, so employee_cost must be 0", tier.Name)
+		}
+		names[strings.ToLower(tier.Name)] = true
+		tier.EmployeeCost, tier.EmployerCost = roundCents(tier.EmployeeCost), roundCents(tier.EmployerCost)
+	}
+	return nil
+}
+
+// Windows returns every enrollment window, newest first.
+func (s *Service) Windows(ctx context.Context) ([]models.EnrollmentWindow, error) {
+	return s.Store.GetEnrollmentWindows(ctx)
+}
+
+// CreateWindow checks an enrollment window and saves it. The dates are kept as days and the
+// coverage start is moved to the first of its month, as payroll is monthly.
+//
+// Parameters:
+//   - window: The window; its ID and creation time are set.
+//   - actor: Email of the user creating it.
+//
+// Returns:
+//   - error: A validation error if the name is missing or the dates are out of order, or
+//     the store's error.
+func (s *Service) CreateWindow(ctx context.Context, window *models.EnrollmentWindow, actor string) error {
+	window.Name = strings.TrimSpace(window.Name)
+	window.OpensOn, window.ClosesOn = day(window.OpensOn), day(window.ClosesOn)
+	start := day(window.CoverageStart)
+	window.CoverageStart = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	switch {
+	case window.Name == "":
+		return models.Invalid("name is required")
+	case window.OpensOn.IsZero() || window.ClosesOn.IsZero() || start.IsZero():
+		return models.Invalid("opens_on, closes_on and coverage_start are required")
+	case window.ClosesOn.Before(window.OpensOn):
+		return models.Invalid("closes_on cannot be before opens_on")
+	case window.CoverageStart.Before(window.OpensOn):
+		return models.Invalid("coverage_start cannot be before opens_on")
+	}
+	window.CreatedBy, window.CreatedAt = actor, s.Now()
+	return s.Store.CreateEnrollmentWindow(ctx, window)
+}
+
+// Elect records an employee's election in an open enrollment window, replacing the one they
+// made in the same plan and window. The tier's costs are copied to the election, so later
+// changes to the plan do not change it. An election without a tier waives the plan.
+//
+// Parameters:
+//   - windowID: The enrollment window.
+//   - election: The employee (UserID, or Email to look them up), PlanID and Tier; the rest
+//     is set.
+//   - actor: Email of the user making the election.
+//
+// Returns:
+//   - error: models.ErrNotFound if the window, plan or employee does not exist, a conflict
+//     if the window is not open, a validation error if the plan is inactive or has no such
+//     tier, or the store's error.
+func (s *Service) Elect(ctx context.Context, windowID int, election *models.BenefitElection, actor string) error {
+	window, err := s.Store.GetEnrollmentWindow(ctx, windowID)
+	if err != nil {
+		return err
+	}
+	today := day(s.Now())
+	if today.Before(window.OpensOn) || today.After(window.ClosesOn) {
+		return models.Conflict("enrollment window %q is open from %s to %s", window.Name,
+			window.OpensOn.Format("2006-01-02"), window.ClosesOn.Format("2006-01-02"))
+	}
+	plan, err := s.Store.GetBenefitPlan(ctx, election.PlanID)
+	if err != nil {
+		return err
+	}
+	if !plan.Active {
+		return models.Invalid("benefit plan %q is not open for enrollment", plan.Code)
+	}
+
+	election.Tier = strings.TrimSpace(election.Tier)
+	election.EmployeeCost, election.EmployerCost = 0, 0
+	if election.Tier != "" {
+		tier, ok := findTier(plan, election.Tier)
+		if !ok {
+			return models.Invalid("benefit plan %q has no tier %q", plan.Code, election.Tier)
+		}
+		election.Tier, election.EmployeeCost, election.EmployerCost = tier.Name, tier.EmployeeCost, tier.EmployerCost
+	}
+	election.WindowID, election.PlanCode, election.PlanName = window.ID, plan.Code, plan.Name
+	election.EffectiveFrom, election.ElectedBy, election.ElectedAt = window.CoverageStart, actor, s.Now()
+	return s.Store.SaveBenefitElection(ctx, election)
+}
+
+// Elections lists elections, newest first. A userID of 0 or an empty email matches every
+// employee.
+func (s *Service) Elections(ctx context.Context, userID int, email string) ([]models.BenefitElection, error) {
+	return s.Store.GetBenefitElections(ctx, userID, email)
+}
+
+// Mine lists the signed-in employee's elections.
+func (s *Service) Mine(ctx context.Context, email string) ([]models.BenefitElection, error) {
+	if email == "" {
+		return nil, models.PermissionDenied("sign in to see your benefit elections")
+	}
+	return s.Store.GetBenefitElections(ctx, 0, email)
+}
+
+// PayrollLines returns the payroll lines of an employee's elections in force in a pay
+// period: what is withheld from their pay and what the employer pays. Waived plans have no
+// line. It lets the statutory service add benefits to the employee's record.
+func (s *Service) PayrollLines(ctx context.Context, userID int, period string) ([]models.StatutoryLine, error) {
+	start, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	elections, err := s.Store.GetElectionsInForce(ctx, userID, start)
+	if err != nil {
+		return nil, err
+	}
+	lines := []models.StatutoryLine{}
+	for _, election := range elections {
+		if election.Tier == "" {
+			continue
+		}
+		lines = append(lines, models.StatutoryLine{
+			Code:     election.PlanCode,
+			Name:     fmt.Sprintf("%s (%s)", election.PlanName, election.Tier),
+			Kind:     models.StatutoryBenefit,
+			Employee: election.EmployeeCost,
+			Employer: election.EmployerCost,
+		})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Code < lines[j].Code })
+	return lines, nil
+}
+
+// CostReport totals the monthly cost of the elections in force in a period by department,
+// in department order. Employees without a department are reported under "Unassigned".
+func (s *Service) CostReport(ctx context.Context, period string) (*models.BenefitCostReport, error) {
+	start, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	elections, err := s.Store.GetElectionsInForce(ctx, 0, start)
+	if err != nil {
+		return nil, err
+	}
+	return Costs(period, elections), nil
+}
+
+// Costs totals elections by department; waivers are left out.
+func Costs(period string, elections []models.BenefitElection) *models.BenefitCostReport {
+	report := &models.BenefitCostReport{Period: period, Lines: []models.BenefitCostLine{}}
+	byDepartment := map[string]*models.BenefitCostLine{}
+	enrolled := map[string]map[int]bool{}
+	for _, election := range elections {
+		if election.Tier == "" {
+			continue
+		}
+		department := election.Department
+		if department == "" {
+			department = "Unassigned"
+		}
+		line := byDepartment[department]
+		if line == nil {
+			line = &models.BenefitCostLine{Department: department}
+			byDepartment[department], enrolled[department] = line, map[int]bool{}
+		}
+		enrolled[department][election.UserID] = true
+		line.Employees = len(enrolled[department])
+		line.Elections++
+		line.EmployeeCost = roundCents(line.EmployeeCost + election.EmployeeCost)
+		line.EmployerCost = roundCents(line.EmployerCost + election.EmployerCost)
+		line.Total = roundCents(line.EmployeeCost + line.EmployerCost)
+	}
+	for _, line := range byDepartment {
+		report.Lines = append(report.Lines, *line)
+		report.EmployeeCost = roundCents(report.EmployeeCost + line.EmployeeCost)
+		report.EmployerCost = roundCents(report.EmployerCost + line.EmployerCost)
+	}
+	sort.Slice(report.Lines, func(i, j int) bool { return report.Lines[i].Department < report.Lines[j].Department })
+	report.Total = roundCents(report.EmployeeCost + report.EmployerCost)
+	return report
+}
+
+// findTier returns the plan's tier with the given name, ignoring case.
+func findTier(plan *models.BenefitPlan, name string) (models.BenefitTier, bool) {
+	for _, tier := range plan.Tiers {
+		if strings.EqualFold(tier.Name, name) {
+			return tier, true
+		}
+	}
+	return models.BenefitTier{}, false
+}
+
+// day returns the date of t at midnight UTC, or the zero time for the zero time.
+func day(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
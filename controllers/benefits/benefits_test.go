@@ -0,0 +1,184 @@
+package benefits
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps plans, windows and elections in memory.
+type fakeStore struct {
+	plans     map[int]*models.BenefitPlan
+	windows   map[int]*models.EnrollmentWindow
+	elections []models.BenefitElection
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{plans: map[int]*models.BenefitPlan{}, windows: map[int]*models.EnrollmentWindow{}}
+}
+
+func (f *fakeStore) CreateBenefitPlan(ctx context.Context, plan *models.BenefitPlan) error {
+	plan.ID = len(f.plans) + 1
+	f.plans[plan.ID] = plan
+	return nil
+}
+
+func (f *fakeStore) GetBenefitPlan(ctx context.Context, id int) (*models.BenefitPlan, error) {
+	if plan, ok := f.plans[id]; ok {
+		return plan, nil
+	}
+	return nil, models.NotFound("benefit plan %d not found", id)
+}
+
+func (f *fakeStore) GetBenefitPlans(ctx context.Context) ([]models.BenefitPlan, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) UpdateBenefitPlan(ctx context.Context, plan *models.BenefitPlan) error {
+	return nil
+}
+
+func (f *fakeStore) CreateEnrollmentWindow(ctx context.Context, window *models.EnrollmentWindow) error {
+	window.ID = len(f.windows) + 1
+	f.windows[window.ID] = window
+	return nil
+}
+
+func (f *fakeStore) GetEnrollmentWindow(ctx context.Context, id int) (*models.EnrollmentWindow, error) {
+	if window, ok := f.windows[id]; ok {
+		return window, nil
+	}
+	return nil, models.NotFound("enrollment window %d not found", id)
+}
+
+func (f *fakeStore) GetEnrollmentWindows(ctx context.Context) ([]models.EnrollmentWindow, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) SaveBenefitElection(ctx context.Context, election *models.BenefitElection) error {
+	election.ID = len(f.elections) + 1
+	f.elections = append(f.elections, *election)
+	return nil
+}
+
+func (f *fakeStore) GetBenefitElections(ctx context.Context, userID int, email string) ([]models.BenefitElection, error) {
+	return f.elections, nil
+}
+
+func (f *fakeStore) GetElectionsInForce(ctx context.Context, userID int, on time.Time) ([]models.BenefitElection, error) {
+	in := []models.BenefitElection{}
+	for _, election := range f.elections {
+		if (userID == 0 || election.UserID == userID) && !election.EffectiveFrom.After(on) {
+			in = append(in, election)
+		}
+	}
+	return in, nil
+}
+
+func health() models.BenefitPlan {
+	return models.BenefitPlan{Code: "HEALTH", Name: "Health insurance", Kind: models.BenefitHealthInsurance, Active: true,
+		Tiers: []models.BenefitTier{{Name: "employee", EmployeeCost: 40, EmployerCost: 120}, {Name: "family", EmployeeCost: 95.5, EmployerCost: 210}}}
+}
+
+// newService returns a service whose clock reads 10 December 2024, with the health plan and
+// a window open in December for cover from January.
+func newService(t *testing.T) (*Service, *fakeStore) {
+	ctx := context.Background()
+	store := newFakeStore()
+	s := NewService(store)
+	s.Now = func() time.Time { return time.Date(2024, 12, 10, 9, 0, 0, 0, time.UTC) }
+	plan := health()
+	require.NoError(t, s.CreatePlan(ctx, &plan, "hr@example.com"))
+	require.NoError(t, s.CreateWindow(ctx, &models.EnrollmentWindow{Name: "Open enrollment 2025",
+		OpensOn: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), ClosesOn: time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC),
+		CoverageStart: time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)}, "hr@example.com"))
+	return s, store
+}
+
+func TestValidatePlan(t *testing.T) {
+	plan := health()
+	assert.NoError(t, ValidatePlan(&plan))
+
+	plan = health()
+	plan.Kind = "pension"
+	assert.True(t, errors.Is(ValidatePlan(&plan), models.ErrValidation))
+
+	plan = health()
+	plan.Tiers = append(plan.Tiers, models.BenefitTier{Name: "Family"})
+	assert.True(t, errors.Is(ValidatePlan(&plan), models.ErrValidation), "tier names are unique ignoring case")
+
+	allowance := models.BenefitPlan{Code: "MEAL", Name: "Meal allowance", Kind: models.BenefitAllowance,
+		Tiers: []models.BenefitTier{{Name: "standard", EmployeeCost: 5, EmployerCost: 50}}}
+	assert.True(t, errors.Is(ValidatePlan(&allowance), models.ErrValidation), "allowances cost the employee nothing")
+}
+
+func TestCreateWindowStartsCoverageOnTheFirst(t *testing.T) {
+	_, store := newService(t)
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), store.windows[1].CoverageStart)
+}
+
+func TestElectCopiesTheTierCosts(t *testing.T) {
+	ctx := context.Background()
+	s, store := newService(t)
+	election := models.BenefitElection{Email: "ana@example.com", PlanID: 1, Tier: "FAMILY"}
+	require.NoError(t, s.Elect(ctx, 1, &election, "ana@example.com"))
+
+	saved := store.elections[0]
+	assert.Equal(t, "family", saved.Tier)
+	assert.Equal(t, 95.5, saved.EmployeeCost)
+	assert.Equal(t, 210.0, saved.EmployerCost)
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), saved.EffectiveFrom)
+
+	err := s.Elect(ctx, 1, &models.BenefitElection{Email: "ana@example.com", PlanID: 1, Tier: "gold"}, "ana@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
+
+func TestElectNeedsAnOpenWindow(t *testing.T) {
+	s, _ := newService(t)
+	s.Now = func() time.Time { return time.Date(2024, 12, 16, 9, 0, 0, 0, time.UTC) }
+	err := s.Elect(context.Background(), 1, &models.BenefitElection{Email: "ana@example.com", PlanID: 1, Tier: "employee"}, "ana@example.com")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, models.ErrValidation))
+}
+
+func TestPayrollLinesSkipWaivers(t *testing.T) {
+	ctx := context.Background()
+	s, store := newService(t)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.elections = []models.BenefitElection{
+		{UserID: 1, PlanCode: "HEALTH", PlanName: "Health insurance", Tier: "employee", EmployeeCost: 40, EmployerCost: 120, EffectiveFrom: start},
+		{UserID: 1, PlanCode: "DENTAL", PlanName: "Dental", EffectiveFrom: start},
+	}
+
+	lines, err := s.PayrollLines(ctx, 1, "2024-12")
+	require.NoError(t, err)
+	assert.Empty(t, lines, "elections take effect from the coverage start")
+
+	lines, err = s.PayrollLines(ctx, 1, "2025-01")
+	require.NoError(t, err)
+	require.Len(t, lines, 1)
+	assert.Equal(t, models.StatutoryLine{Code: "HEALTH", Name: "Health insurance (employee)", Kind: models.StatutoryBenefit,
+		Employee: 40, Employer: 120}, lines[0])
+}
+
+func TestCostsByDepartment(t *testing.T) {
+	report := Costs("2025-01", []models.BenefitElection{
+		{UserID: 1, Department: "Sales", Tier: "employee", EmployeeCost: 40, EmployerCost: 120},
+		{UserID: 1, Department: "Sales", Tier: "standard", EmployerCost: 50},
+		{UserID: 2, Department: "Sales", Tier: "family", EmployeeCost: 95.5, EmployerCost: 210},
+		{UserID: 3, Tier: "employee", EmployeeCost: 40, EmployerCost: 120},
+		{UserID: 4, Department: "Finance"},
+	})
+
+	require.Len(t, report.Lines, 2)
+	assert.Equal(t, models.BenefitCostLine{Department: "Sales", Employees: 2, Elections: 3, EmployeeCost: 135.5,
+		EmployerCost: 380, Total: 515.5}, report.Lines[0])
+	assert.Equal(t, "Unassigned", report.Lines[1].Department)
+	assert.Equal(t, 675.5, report.Total)
+}
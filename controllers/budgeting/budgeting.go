@@ -0,0 +1,476 @@
+// Package budgeting keeps the budgets of fiscal years as scenarios. A scenario budgets an
+// amount on ledger accounts for each month of a calendar year. Scenarios can be cloned and
+// adjusted by percentage rules to try out what-if variants, compared side by side against
+// the actual movements of the ledger, and one of them per year is marked as the active plan
+// that monthly variances are reported against.
+package budgeting
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Service manages budget scenarios and reports them against the ledger.
+type Service struct {
+	Store models.BudgetStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a budgeting service.
+func NewService(store models.BudgetStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// periodLayout is the layout of budget months.
+const periodLayout = "2006-01"
+
+// Create validates and records a scenario with its lines.
+//
+// Returns:
+//   - error: A validation error if a field or line is invalid, a conflict if the year already
+//     has a scenario of the name, or the store error.
+func (s *Service) Create(ctx context.Context, scenario *models.BudgetScenario, actor string) error {
+	scenario.Name = strings.TrimSpace(scenario.Name)
+	if scenario.Name == "" {
+		return models.Invalid("name is required")
+	}
+	if scenario.FiscalYear < 2000 || scenario.FiscalYear > 2100 {
+		return models.Invalid("fiscal_year must be between 2000 and 2100")
+	}
+	if scenario.Kind == "" {
+		scenario.Kind = models.BudgetBase
+	}
+	if err := validKind(scenario.Kind); err != nil {
+		return err
+	}
+	lines, err := validLines(scenario.FiscalYear, scenario.Lines)
+	if err != nil {
+		return err
+	}
+	scenario.Lines = lines
+	scenario.Income, scenario.Expense = Totals(lines)
+	scenario.Active = false
+	scenario.ActivatedBy, scenario.ActivatedAt = "", nil
+	scenario.CreatedBy = actor
+	scenario.CreatedAt = s.Now()
+	return s.Store.CreateBudgetScenario(ctx, scenario)
+}
+
+// Scenario returns a scenario with its lines.
+func (s *Service) Scenario(ctx context.Context, id int) (*models.BudgetScenario, error) {
+	return s.Store.GetBudgetScenario(ctx, id)
+}
+
+// Scenarios lists the scenarios of a fiscal year, or of every year if it is 0.
+func (s *Service) Scenarios(ctx context.Context, fiscalYear int) ([]models.BudgetScenario, error) {
+	return s.Store.ListBudgetScenarios(ctx, fiscalYear)
+}
+
+// SetLines replaces the lines of a scenario.
+//
+// Returns:
+//   - *models.BudgetScenario: The scenario with its new lines.
+//   - error: A not found error if the scenario does not exist, a validation error if a line
+//     is invalid, or the store error.
+func (s *Service) SetLines(ctx context.Context, id int, lines []models.BudgetLine) (*models.BudgetScenario, error) {
+	scenario, err := s.Store.GetBudgetScenario(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if lines, err = validLines(scenario.FiscalYear, lines); err != nil {
+		return nil, err
+	}
+	if err := s.Store.ReplaceBudgetLines(ctx, id, lines); err != nil {
+		return nil, err
+	}
+	scenario.Lines = lines
+	scenario.Income, scenario.Expense = Totals(lines)
+	return scenario, nil
+}
+
+// Clone records a copy of a scenario under a new name, with its lines adjusted by the rules.
+//
+// Returns:
+//   - *models.BudgetScenario: The new scenario, inactive.
+//   - error: A not found error if the source does not exist, a validation error if the name,
+//     kind or a rule is invalid, a conflict if the name is taken, or the store error.
+func (s *Service) Clone(ctx context.Context, id int, clone models.BudgetClone, actor string) (*models.BudgetScenario, error) {
+	source, err := s.Store.GetBudgetScenario(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validRules(clone.Rules); err != nil {
+		return nil, err
+	}
+	kind := clone.Kind
+	if kind == "" {
+		kind = source.Kind
+	}
+	scenario := &models.BudgetScenario{
+		Name:       clone.Name,
+		FiscalYear: source.FiscalYear,
+		Kind:       kind,
+		BasedOnID:  &source.ID,
+		Lines:      Apply(source.Lines, clone.Rules),
+	}
+	if err := s.Create(ctx, scenario, actor); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// Adjust changes the lines of a scenario by the rules.
+//
+// Returns:
+//   - *models.BudgetScenario: The scenario with its adjusted lines.
+//   - error: A not found error if the scenario does not exist, a validation error if there is
+//     no rule or a rule is invalid, or the store error.
+func (s *Service) Adjust(ctx context.Context, id int, rules []models.BudgetRule) (*models.BudgetScenario, error) {
+	if len(rules) == 0 {
+		return nil, models.Invalid("at least one rule is required")
+	}
+	if err := validRules(rules); err != nil {
+		return nil, err
+	}
+	scenario, err := s.Store.GetBudgetScenario(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	lines := Apply(scenario.Lines, rules)
+	if err := s.Store.ReplaceBudgetLines(ctx, id, lines); err != nil {
+		return nil, err
+	}
+	scenario.Lines = lines
+	scenario.Income, scenario.Expense = Totals(lines)
+	return scenario, nil
+}
+
+// Activate makes a scenario the active plan of its fiscal year.
+func (s *Service) Activate(ctx context.Context, id int, actor string) (*models.BudgetScenario, error) {
+	return s.Store.ActivateBudgetScenario(ctx, id, actor, s.Now())
+}
+
+// Delete removes a scenario that is not the active plan.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	return s.Store.DeleteBudgetScenario(ctx, id)
+}
+
+// Compare sets scenarios of a fiscal year side by side against the actuals of the year.
+//
+// Parameters:
+//   - fiscalYear: The year compared.
+//   - ids: The scenarios compared, in order; every scenario of the year if empty.
+//
+// Returns:
+//   - *models.BudgetComparison: The comparison, by account.
+//   - error: A validation error if the year is missing or a scenario belongs to another
+//     year, a not found error if a scenario does not exist, or the store error.
+func (s *Service) Compare(ctx context.Context, fiscalYear int, ids []int) (*models.BudgetComparison, error) {
+	if fiscalYear == 0 {
+		return nil, models.Invalid("fiscal_year is required")
+	}
+	var scenarios []models.BudgetScenario
+	if len(ids) == 0 {
+		list, err := s.Store.ListBudgetScenarios(ctx, fiscalYear)
+		if err != nil {
+			return nil, err
+		}
+		for _, scenario := range list {
+			ids = append(ids, scenario.ID)
+		}
+	}
+	for _, id := range ids {
+		scenario, err := s.Store.GetBudgetScenario(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if scenario.FiscalYear != fiscalYear {
+			return nil, models.Invalid("scenario %d budgets %d, not %d", id, scenario.FiscalYear, fiscalYear)
+		}
+		scenarios = append(scenarios, *scenario)
+	}
+	movements, err := s.Store.AccountMovements(ctx, period(fiscalYear, time.January), period(fiscalYear, time.December))
+	if err != nil {
+		return nil, err
+	}
+	return Comparison(fiscalYear, scenarios, movements), nil
+}
+
+// Comparison totals the lines of each scenario by account and sets them against the actual
+// movements of the accounts.
+func Comparison(fiscalYear int, scenarios []models.BudgetScenario, movements []models.AccountMovement) *models.BudgetComparison {
+	comparison := &models.BudgetComparison{
+		FiscalYear: fiscalYear,
+		Scenarios:  []models.BudgetScenario{},
+		Lines:      []models.BudgetComparisonLine{},
+		Net:        make([]float64, len(scenarios)),
+	}
+	index := map[string]int{}
+	for i, scenario := range scenarios {
+		for _, line := range scenario.Lines {
+			key := line.Type + "/" + line.Account
+			n, ok := index[key]
+			if !ok {
+				n = len(comparison.Lines)
+				index[key] = n
+				comparison.Lines = append(comparison.Lines, models.BudgetComparisonLine{
+					Account: line.Account, Type: line.Type, Budgets: make([]float64, len(scenarios)),
+				})
+			}
+			comparison.Lines[n].Budgets[i] += line.Amount
+		}
+		scenario.Lines = nil
+		comparison.Scenarios = append(comparison.Scenarios, scenario)
+	}
+	sort.SliceStable(comparison.Lines, func(i, j int) bool {
+		a, b := comparison.Lines[i], comparison.Lines[j]
+		return before(a.Type, a.Account, b.Type, b.Account)
+	})
+	for i := range comparison.Lines {
+		line := &comparison.Lines[i]
+		line.Actual = actual(line.Type, line.Account, "", "", movements)
+		sign := 1.0
+		if line.Type == models.AccountExpense {
+			sign = -1
+		}
+		for n := range line.Budgets {
+			line.Budgets[n] = round(line.Budgets[n])
+			comparison.Net[n] += sign * line.Budgets[n]
+		}
+		comparison.NetActual += sign * line.Actual
+	}
+	for n := range comparison.Net {
+		comparison.Net[n] = round(comparison.Net[n])
+	}
+	comparison.NetActual = round(comparison.NetActual)
+	return comparison
+}
+
+// Variance reports the actuals of a month against the active plan of its year.
+//
+// Parameters:
+//   - month: The month reported, YYYY-MM; the current month if empty.
+//
+// Returns:
+//   - *models.BudgetVariance: The variances of each account budgeted in the active plan.
+//   - error: A validation error if the month is malformed, a not found error if its year has
+//     no active plan, or the store error.
+func (s *Service) Variance(ctx context.Context, month string) (*models.BudgetVariance, error) {
+	if month == "" {
+		month = s.Now().Format(periodLayout)
+	}
+	t, err := time.Parse(periodLayout, month)
+	if err != nil {
+		return nil, models.Invalid("period must be a month, YYYY-MM")
+	}
+	list, err := s.Store.ListBudgetScenarios(ctx, t.Year())
+	if err != nil {
+		return nil, err
+	}
+	var plan *models.BudgetScenario
+	for _, scenario := range list {
+		if scenario.Active {
+			if plan, err = s.Store.GetBudgetScenario(ctx, scenario.ID); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if plan == nil {
+		return nil, models.NotFound("%d has no active budget", t.Year())
+	}
+	movements, err := s.Store.AccountMovements(ctx, period(t.Year(), time.January), month)
+	if err != nil {
+		return nil, err
+	}
+	return Variances(month, *plan, movements), nil
+}
+
+// Variances compares the actual movements of each account budgeted in a plan with the plan,
+// for a month and for its year to date.
+func Variances(month string, plan models.BudgetScenario, movements []models.AccountMovement) *models.BudgetVariance {
+	report := &models.BudgetVariance{Period: month, Lines: []models.BudgetVarianceLine{}}
+	first := month[:4] + "-01"
+	index := map[string]int{}
+	for _, line := range plan.Lines {
+		key := line.Type + "/" + line.Account
+		n, ok := index[key]
+		if !ok {
+			n = len(report.Lines)
+			index[key] = n
+			report.Lines = append(report.Lines, models.BudgetVarianceLine{Account: line.Account, Type: line.Type})
+		}
+		if line.Period == month {
+			report.Lines[n].Budget += line.Amount
+		}
+		if line.Period <= month {
+			report.Lines[n].YearBudget += line.Amount
+		}
+	}
+	sort.SliceStable(report.Lines, func(i, j int) bool {
+		a, b := report.Lines[i], report.Lines[j]
+		return before(a.Type, a.Account, b.Type, b.Account)
+	})
+	for i := range report.Lines {
+		line := &report.Lines[i]
+		line.Budget, line.YearBudget = round(line.Budget), round(line.YearBudget)
+		line.Actual = actual(line.Type, line.Account, month, month, movements)
+		line.YearActual = actual(line.Type, line.Account, first, month, movements)
+		line.Variance, line.VariancePercent, line.Favorable = variance(line.Type, line.Budget, line.Actual)
+		line.YearVariance, line.YearVariancePercent, line.YearFavorable = variance(line.Type, line.YearBudget, line.YearActual)
+	}
+	plan.Lines = nil
+	report.Scenario = plan
+	return report
+}
+
+// Apply returns the lines changed by each matching rule in turn, rounded to cents.
+func Apply(lines []models.BudgetLine, rules []models.BudgetRule) []models.BudgetLine {
+	adjusted := make([]models.BudgetLine, len(lines))
+	for i, line := range lines {
+		for _, rule := range rules {
+			if matches(rule, line) {
+				line.Amount = round(line.Amount * (1 + rule.Percent/100))
+			}
+		}
+		adjusted[i] = line
+	}
+	return adjusted
+}
+
+// Totals returns the income and expense budgeted by lines.
+func Totals(lines []models.BudgetLine) (income, expense float64) {
+	for _, line := range lines {
+		if line.Type == models.AccountIncome {
+			income += line.Amount
+		} else {
+			expense += line.Amount
+		}
+	}
+	return round(income), round(expense)
+}
+
+func matches(rule models.BudgetRule, line models.BudgetLine) bool {
+	return (rule.Account == "" || rule.Account == line.Account) &&
+		(rule.Type == "" || rule.Type == line.Type) &&
+		(rule.From == "" || line.Period >= rule.From) &&
+		(rule.To == "" || line.Period <= rule.To)
+}
+
+// actual returns the movement of an account between two months, inclusive, on its normal
+// side: credits less debits for income, debits less credits for expense. Empty months leave
+// the range open.
+func actual(accountType, account, from, to string, movements []models.AccountMovement) float64 {
+	var total float64
+	for _, m := range movements {
+		if m.Account != account || (from != "" && m.Period < from) || (to != "" && m.Period > to) {
+			continue
+		}
+		if accountType == models.AccountIncome {
+			total += m.Credit - m.Debit
+		} else {
+			total += m.Debit - m.Credit
+		}
+	}
+	return round(total)
+}
+
+// variance returns actual less budget, as an amount and a percentage of the budget, and
+// whether it is favorable.
+func variance(accountType string, budget, actual float64) (float64, float64, bool) {
+	amount := round(actual - budget)
+	var percent float64
+	if budget != 0 {
+		percent = round(amount / budget * 100)
+	}
+	if accountType == models.AccountIncome {
+		return amount, percent, amount >= 0
+	}
+	return amount, percent, amount <= 0
+}
+
+func validKind(kind string) error {
+	switch kind {
+	case models.BudgetBase, models.BudgetOptimistic, models.BudgetPessimistic:
+		return nil
+	}
+	return models.Invalid("kind must be %s, %s or %s", models.BudgetBase, models.BudgetOptimistic, models.BudgetPessimistic)
+}
+
+func validType(accountType string) error {
+	if accountType != models.AccountIncome && accountType != models.AccountExpense {
+		return models.Invalid("type must be %s or %s", models.AccountIncome, models.AccountExpense)
+	}
+	return nil
+}
+
+// validLines trims and checks lines. Each account may be budgeted once a month, within the
+// fiscal year.
+func validLines(fiscalYear int, lines []models.BudgetLine) ([]models.BudgetLine, error) {
+	seen := map[string]bool{}
+	valid := make([]models.BudgetLine, 0, len(lines))
+	for _, line := range lines {
+		line.Account = strings.TrimSpace(line.Account)
+		if line.Account == "" {
+			return nil, models.Invalid("account is required on every line")
+		}
+		if err := validType(line.Type); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(periodLayout, line.Period)
+		if err != nil || t.Year() != fiscalYear {
+			return nil, models.Invalid("period %q must be a month of %d, YYYY-MM", line.Period, fiscalYear)
+		}
+		if line.Amount < 0 {
+			return nil, models.Invalid("amount of %s in %s must not be negative", line.Account, line.Period)
+		}
+		key := line.Account + "/" + line.Period
+		if seen[key] {
+			return nil, models.Invalid("%s is budgeted twice in %s", line.Account, line.Period)
+		}
+		seen[key] = true
+		line.Amount = round(line.Amount)
+		valid = append(valid, line)
+	}
+	return valid, nil
+}
+
+func validRules(rules []models.BudgetRule) error {
+	for _, rule := range rules {
+		if rule.Type != "" {
+			if err := validType(rule.Type); err != nil {
+				return err
+			}
+		}
+		for _, month := range []string{rule.From, rule.To} {
+			if _, err := time.Parse(periodLayout, month); month != "" && err != nil {
+				return models.Invalid("rule months must be YYYY-MM")
+			}
+		}
+		if rule.Percent <= -100 {
+			return models.Invalid("percent must be above -100")
+		}
+	}
+	return nil
+}
+
+// before orders report lines by type, income first, then account.
+func before(typeA, accountA, typeB, accountB string) bool {
+	if typeA != typeB {
+		return typeA == models.AccountIncome
+	}
+	return accountA < accountB
+}
+
+func period(year int, month time.Month) string {
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Format(periodLayout)
+}
+
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
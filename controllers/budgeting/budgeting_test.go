@@ -0,0 +1,222 @@
+package budgeting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBudgetStore keeps scenarios in memory.
+type memoryBudgetStore struct {
+	scenarios map[int]*models.BudgetScenario
+	movements []models.AccountMovement
+}
+
+func newMemoryBudgetStore() *memoryBudgetStore {
+	return &memoryBudgetStore{scenarios: map[int]*models.BudgetScenario{}}
+}
+
+func (m *memoryBudgetStore) CreateBudgetScenario(ctx context.Context, scenario *models.BudgetScenario) error {
+	for _, s := range m.scenarios {
+		if s.FiscalYear == scenario.FiscalYear && s.Name == scenario.Name {
+			return models.Conflict("duplicate")
+		}
+	}
+	scenario.ID = len(m.scenarios) + 1
+	copied := *scenario
+	m.scenarios[scenario.ID] = &copied
+	return nil
+}
+
+func (m *memoryBudgetStore) GetBudgetScenario(ctx context.Context, id int) (*models.BudgetScenario, error) {
+	s, ok := m.scenarios[id]
+	if !ok {
+		return nil, models.NotFound("budget scenario %d not found", id)
+	}
+	copied := *s
+	copied.Lines = append([]models.BudgetLine{}, s.Lines...)
+	return &copied, nil
+}
+
+func (m *memoryBudgetStore) ListBudgetScenarios(ctx context.Context, fiscalYear int) ([]models.BudgetScenario, error) {
+	list := []models.BudgetScenario{}
+	for id := 1; id <= len(m.scenarios); id++ {
+		if s, ok := m.scenarios[id]; ok && (fiscalYear == 0 || s.FiscalYear == fiscalYear) {
+			copied := *s
+			copied.Lines = nil
+			list = append(list, copied)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryBudgetStore) ReplaceBudgetLines(ctx context.Context, id int, lines []models.BudgetLine) error {
+	s, ok := m.scenarios[id]
+	if !ok {
+		return models.NotFound("budget scenario %d not found", id)
+	}
+	s.Lines = lines
+	return nil
+}
+
+func (m *memoryBudgetStore) ActivateBudgetScenario(ctx context.Context, id int, actor string, now time.Time) (*models.BudgetScenario, error) {
+	target, ok := m.scenarios[id]
+	if !ok {
+		return nil, models.NotFound("budget scenario %d not found", id)
+	}
+	for _, s := range m.scenarios {
+		if s.FiscalYear == target.FiscalYear {
+			s.Active = false
+		}
+	}
+	target.Active, target.ActivatedBy, target.ActivatedAt = true, actor, &now
+	return m.GetBudgetScenario(ctx, id)
+}
+
+func (m *memoryBudgetStore) DeleteBudgetScenario(ctx context.Context, id int) error {
+	delete(m.scenarios, id)
+	return nil
+}
+
+func (m *memoryBudgetStore) AccountMovements(ctx context.Context, first, last string) ([]models.AccountMovement, error) {
+	list := []models.AccountMovement{}
+	for _, movement := range m.movements {
+		if movement.Period >= first && movement.Period <= last {
+			list = append(list, movement)
+		}
+	}
+	return list, nil
+}
+
+func newTestService() (*Service, *memoryBudgetStore) {
+	store := newMemoryBudgetStore()
+	return &Service{Store: store, Now: func() time.Time { return time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC) }}, store
+}
+
+func baseScenario() *models.BudgetScenario {
+	return &models.BudgetScenario{Name: "Plan", FiscalYear: 2025, Lines: []models.BudgetLine{
+		{Account: "revenue", Type: models.AccountIncome, Period: "2025-05", Amount: 1000},
+		{Account: "revenue", Type: models.AccountIncome, Period: "2025-06", Amount: 1000},
+		{Account: "rent_expense", Type: models.AccountExpense, Period: "2025-06", Amount: 400},
+	}}
+}
+
+func TestCreateValidatesLines(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService()
+	for _, lines := range [][]models.BudgetLine{
+		{{Account: "revenue", Type: "asset", Period: "2025-01", Amount: 1}},
+		{{Account: "revenue", Type: models.AccountIncome, Period: "2024-12", Amount: 1}},
+		{{Account: "", Type: models.AccountIncome, Period: "2025-01", Amount: 1}},
+		{{Account: "revenue", Type: models.AccountIncome, Period: "2025-01", Amount: -1}},
+		{{Account: "revenue", Type: models.AccountIncome, Period: "2025-01", Amount: 1},
+			{Account: "revenue", Type: models.AccountIncome, Period: "2025-01", Amount: 2}},
+	} {
+		err := s.Create(ctx, &models.BudgetScenario{Name: "Plan", FiscalYear: 2025, Lines: lines}, "cfo@example.com")
+		assert.True(t, errors.Is(err, models.ErrValidation), "%v", lines)
+	}
+
+	scenario := baseScenario()
+	require.NoError(t, s.Create(ctx, scenario, "cfo@example.com"))
+	assert.Equal(t, models.BudgetBase, scenario.Kind)
+	assert.Equal(t, 2000.0, scenario.Income)
+	assert.Equal(t, 400.0, scenario.Expense)
+}
+
+func TestCloneAppliesRules(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService()
+	base := baseScenario()
+	require.NoError(t, s.Create(ctx, base, "cfo@example.com"))
+
+	clone, err := s.Clone(ctx, base.ID, models.BudgetClone{Name: "Stretch", Kind: models.BudgetOptimistic, Rules: []models.BudgetRule{
+		{Type: models.AccountIncome, From: "2025-06", Percent: 10},
+		{Percent: -50, Account: "rent_expense"},
+	}}, "cfo@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, base.ID, *clone.BasedOnID)
+	assert.Equal(t, models.BudgetOptimistic, clone.Kind)
+	assert.Equal(t, []float64{1000, 1100, 200}, []float64{clone.Lines[0].Amount, clone.Lines[1].Amount, clone.Lines[2].Amount})
+	assert.Equal(t, 2100.0, clone.Income)
+
+	source, err := s.Scenario(ctx, base.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1000.0, source.Lines[1].Amount, "the source is left alone")
+
+	_, err = s.Clone(ctx, base.ID, models.BudgetClone{Name: "Stretch"}, "cfo@example.com")
+	assert.True(t, errors.Is(err, models.ErrConflict))
+	_, err = s.Clone(ctx, base.ID, models.BudgetClone{Name: "Wipe", Rules: []models.BudgetRule{{Percent: -100}}}, "cfo@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
+
+func TestCompareAgainstActuals(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTestService()
+	base := baseScenario()
+	require.NoError(t, s.Create(ctx, base, "cfo@example.com"))
+	low, err := s.Clone(ctx, base.ID, models.BudgetClone{Name: "Low", Kind: models.BudgetPessimistic,
+		Rules: []models.BudgetRule{{Type: models.AccountIncome, Percent: -20}}}, "cfo@example.com")
+	require.NoError(t, err)
+	store.movements = []models.AccountMovement{
+		{Account: "revenue", Period: "2025-05", Debit: 50, Credit: 950},
+		{Account: "rent_expense", Period: "2025-06", Debit: 400},
+		{Account: "revenue", Period: "2024-12", Credit: 9999}, // Another year
+	}
+
+	comparison, err := s.Compare(ctx, 2025, nil)
+	require.NoError(t, err)
+	require.Len(t, comparison.Scenarios, 2)
+	assert.Nil(t, comparison.Scenarios[0].Lines)
+	assert.Equal(t, []models.BudgetComparisonLine{
+		{Account: "revenue", Type: models.AccountIncome, Budgets: []float64{2000, 1600}, Actual: 900},
+		{Account: "rent_expense", Type: models.AccountExpense, Budgets: []float64{400, 400}, Actual: 400},
+	}, comparison.Lines)
+	assert.Equal(t, []float64{1600, 1200}, comparison.Net)
+	assert.Equal(t, 500.0, comparison.NetActual)
+
+	_, err = s.Compare(ctx, 2024, []int{low.ID})
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
+
+func TestVarianceOfActivePlan(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTestService()
+	_, err := s.Variance(ctx, "")
+	assert.True(t, errors.Is(err, models.ErrNotFound), "no active plan")
+
+	base := baseScenario()
+	require.NoError(t, s.Create(ctx, base, "cfo@example.com"))
+	_, err = s.Activate(ctx, base.ID, "cfo@example.com")
+	require.NoError(t, err)
+	store.movements = []models.AccountMovement{
+		{Account: "revenue", Period: "2025-05", Credit: 1200},
+		{Account: "revenue", Period: "2025-06", Credit: 700},
+		{Account: "rent_expense", Period: "2025-06", Debit: 500},
+	}
+
+	report, err := s.Variance(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, "2025-06", report.Period)
+	assert.Equal(t, base.ID, report.Scenario.ID)
+	assert.Equal(t, []models.BudgetVarianceLine{
+		{Account: "revenue", Type: models.AccountIncome, Budget: 1000, Actual: 700, Variance: -300, VariancePercent: -30,
+			YearBudget: 2000, YearActual: 1900, YearVariance: -100, YearVariancePercent: -5},
+		{Account: "rent_expense", Type: models.AccountExpense, Budget: 400, Actual: 500, Variance: 100, VariancePercent: 25,
+			YearBudget: 400, YearActual: 500, YearVariance: 100, YearVariancePercent: 25},
+	}, report.Lines)
+
+	report, err = s.Variance(ctx, "2025-05")
+	require.NoError(t, err)
+	assert.True(t, report.Lines[0].Favorable)
+	assert.Equal(t, 200.0, report.Lines[0].Variance)
+	assert.True(t, report.Lines[1].Favorable, "nothing spent on rent yet")
+
+	_, err = s.Variance(ctx, "June")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
@@ -0,0 +1,136 @@
+// Package bundles holds the rules of kits: bundle products made of other products and sold
+// as one line. Selling a bundle reserves and ships its components, its price is fixed or
+// the components' prices less a discount, and its availability follows from the
+// components' free stock.
+package bundles
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"erp/models"
+)
+
+// Service applies the bundle rules on top of a BundleStore.
+type Service struct {
+	Store models.BundleStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a bundle service backed by store.
+func NewService(store models.BundleStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Save makes a product a bundle of components or replaces its definition.
+//
+// Parameters:
+//   - bundle: The product, pricing, discount and components (product and quantity).
+//   - actor: Email of the user saving it.
+//
+// Returns:
+//   - error: A validation error if the pricing is unknown, the discount is out of range or
+//     given for a fixed price, there are no components, a quantity is not positive or a
+//     component is listed twice or is the bundle itself; otherwise the store's error.
+func (s *Service) Save(ctx context.Context, bundle *models.Bundle, actor string) error {
+	switch bundle.Pricing {
+	case models.BundlePriceFixed:
+		if bundle.DiscountPercent != 0 {
+			return models.Invalid("discount_percent only applies to %s pricing", models.BundlePriceComponents)
+		}
+	case models.BundlePriceComponents:
+		if bundle.DiscountPercent < 0 || bundle.DiscountPercent >= 100 {
+			return models.Invalid("discount_percent must be at least 0 and below 100")
+		}
+	default:
+		return models.Invalid("pricing must be %s or %s", models.BundlePriceFixed, models.BundlePriceComponents)
+	}
+	if len(bundle.Components) == 0 {
+		return models.Invalid("a bundle needs at least one component")
+	}
+	seen := make(map[int]bool, len(bundle.Components))
+	for _, component := range bundle.Components {
+		switch {
+		case component.ProductID == bundle.ProductID:
+			return models.Invalid("a bundle cannot contain itself")
+		case component.Quantity <= 0:
+			return models.Invalid("component %d: quantity must be positive", component.ProductID)
+		case seen[component.ProductID]:
+			return models.Invalid("component %d is listed more than once", component.ProductID)
+		}
+		seen[component.ProductID] = true
+	}
+	bundle.UpdatedBy, bundle.UpdatedAt = actor, s.Now()
+	return s.Store.SaveBundle(ctx, bundle)
+}
+
+// Get returns a bundle with its price and availability.
+func (s *Service) Get(ctx context.Context, productID int) (*models.Bundle, error) {
+	return s.Store.GetBundle(ctx, productID)
+}
+
+// Delete makes a bundle a plain product again.
+func (s *Service) Delete(ctx context.Context, productID int) error {
+	return s.Store.DeleteBundle(ctx, productID)
+}
+
+// Price returns what a bundle sells for: the bundle product's own price for fixed pricing,
+// or its components' prices added up less the discount.
+func Price(bundle *models.Bundle, ownPrice float64) float64 {
+	if bundle.Pricing != models.BundlePriceComponents {
+		return ownPrice
+	}
+	total := 0.0
+	for _, component := range bundle.Components {
+		total += float64(component.Quantity) * component.UnitPrice
+	}
+	return roundCents(total * (1 - bundle.DiscountPercent/100))
+}
+
+// Available returns how many of a bundle can be made from the free stock of its
+// components, keyed by product.
+func Available(bundle *models.Bundle, free map[int]int) int {
+	available := -1
+	for _, component := range bundle.Components {
+		n := max(free[component.ProductID], 0) / component.Quantity
+		if available < 0 || n < available {
+			available = n
+		}
+	}
+	return max(available, 0)
+}
+
+// Expand turns quantities of products, some of them bundles, into the quantities of the
+// products that hold stock, keyed by product.
+func Expand(quantities map[int]int, bundles map[int]*models.Bundle) map[int]int {
+	expanded := make(map[int]int, len(quantities))
+	for productID, quantity := range quantities {
+		bundle, ok := bundles[productID]
+		if !ok {
+			expanded[productID] += quantity
+			continue
+		}
+		for _, component := range bundle.Components {
+			expanded[component.ProductID] += quantity * component.Quantity
+		}
+	}
+	return expanded
+}
+
+// ProductIDs returns the products of quantities in ascending order, so stock is locked in
+// the same order by every transaction.
+func ProductIDs(quantities map[int]int) []int {
+	ids := make([]int, 0, len(quantities))
+	for productID := range quantities {
+		ids = append(ids, productID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,86 @@
+package bundles
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore keeps the bundle it is asked to save.
+type mockStore struct {
+	models.BundleStore
+	saved *models.Bundle
+}
+
+func (m *mockStore) SaveBundle(ctx context.Context, bundle *models.Bundle) error {
+	m.saved = bundle
+	return nil
+}
+
+var now = time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)
+
+func newService() (*Service, *mockStore) {
+	store := &mockStore{}
+	service := NewService(store)
+	service.Now = func() time.Time { return now }
+	return service, store
+}
+
+func TestSaveValidation(t *testing.T) {
+	ctx := context.Background()
+	service, store := newService()
+	two := []models.BundleComponent{{ProductID: 3, Quantity: 2}, {ProductID: 5, Quantity: 1}}
+
+	for name, bundle := range map[string]models.Bundle{
+		"unknown pricing":        {ProductID: 9, Pricing: "cheap", Components: two},
+		"discount on fixed":      {ProductID: 9, Pricing: models.BundlePriceFixed, DiscountPercent: 5, Components: two},
+		"full discount":          {ProductID: 9, Pricing: models.BundlePriceComponents, DiscountPercent: 100, Components: two},
+		"negative discount":      {ProductID: 9, Pricing: models.BundlePriceComponents, DiscountPercent: -1, Components: two},
+		"no components":          {ProductID: 9, Pricing: models.BundlePriceFixed},
+		"contains itself":        {ProductID: 9, Pricing: models.BundlePriceFixed, Components: []models.BundleComponent{{ProductID: 9, Quantity: 1}}},
+		"zero quantity":          {ProductID: 9, Pricing: models.BundlePriceFixed, Components: []models.BundleComponent{{ProductID: 3}}},
+		"component listed twice": {ProductID: 9, Pricing: models.BundlePriceFixed, Components: append(two, models.BundleComponent{ProductID: 3, Quantity: 1})},
+	} {
+		assert.True(t, errors.Is(service.Save(ctx, &bundle, "sales@example.com"), models.ErrValidation), name)
+	}
+	assert.Nil(t, store.saved)
+
+	bundle := &models.Bundle{ProductID: 9, Pricing: models.BundlePriceComponents, DiscountPercent: 10, Components: two}
+	require.NoError(t, service.Save(ctx, bundle, "sales@example.com"))
+	assert.Equal(t, "sales@example.com", store.saved.UpdatedBy)
+	assert.Equal(t, now, store.saved.UpdatedAt)
+}
+
+func TestPrice(t *testing.T) {
+	bundle := &models.Bundle{Pricing: models.BundlePriceComponents, DiscountPercent: 10,
+		Components: []models.BundleComponent{{ProductID: 3, Quantity: 2, UnitPrice: 4.99}, {ProductID: 5, Quantity: 1, UnitPrice: 12}}}
+	assert.Equal(t, 19.78, Price(bundle, 25))
+
+	bundle.Pricing = models.BundlePriceFixed
+	assert.Equal(t, 25.0, Price(bundle, 25))
+}
+
+func TestAvailableFollowsScarcestComponent(t *testing.T) {
+	bundle := &models.Bundle{Components: []models.BundleComponent{{ProductID: 3, Quantity: 2}, {ProductID: 5, Quantity: 1}}}
+
+	assert.Equal(t, 3, Available(bundle, map[int]int{3: 7, 5: 10}))
+	assert.Equal(t, 1, Available(bundle, map[int]int{3: 7, 5: 1}))
+	// Over-reserved and missing components make none
+	assert.Equal(t, 0, Available(bundle, map[int]int{3: -2, 5: 10}))
+	assert.Equal(t, 0, Available(bundle, map[int]int{3: 7}))
+}
+
+func TestExpand(t *testing.T) {
+	found := map[int]*models.Bundle{9: {ProductID: 9,
+		Components: []models.BundleComponent{{ProductID: 3, Quantity: 2}, {ProductID: 5, Quantity: 1}}}}
+
+	expanded := Expand(map[int]int{9: 3, 3: 1, 7: 4}, found)
+	assert.Equal(t, map[int]int{3: 7, 5: 3, 7: 4}, expanded)
+	assert.Equal(t, []int{3, 5, 7}, ProductIDs(expanded))
+}
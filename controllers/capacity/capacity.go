@@ -0,0 +1,231 @@
+// Package capacity compares the hours the workforce has available in a month with the
+// hours planned for projects. The hours available are each active employee's working days
+// at the configured hours per day, less approved leave; the demand is the employees'
+// project allocations. Employees and departments are marked over-allocated when more is
+// planned than they have, and under-allocated when less than a set share is.
+package capacity
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// periodLayout is the format of capacity periods.
+const periodLayout = "2006-01"
+
+// unassigned is the department employees without one are reported under.
+const unassigned = "Unassigned"
+
+// Service records project allocations and reports capacity.
+type Service struct {
+	Store            models.CapacityStore
+	HoursPerDay      float64          // Scheduled hours of a working day
+	UnderUtilization float64          // Percentage of the available hours below which capacity is under-allocated
+	Now              func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a capacity service.
+func NewService(store models.CapacityStore, hoursPerDay, underUtilization float64) *Service {
+	return &Service{Store: store, HoursPerDay: hoursPerDay, UnderUtilization: underUtilization, Now: time.Now}
+}
+
+// Allocate checks a project allocation and records it.
+//
+// Parameters:
+//   - allocation: UserID, Project, Period and Hours are read; the ID and creation are set.
+//   - actor: Email of the user planning the hours.
+//
+// Returns:
+//   - error: A validation error if the allocation is incomplete or the user does not exist,
+//     a conflict if the employee already has hours on the project in the month, or the
+//     store's error.
+func (s *Service) Allocate(ctx context.Context, allocation *models.ProjectAllocation, actor string) error {
+	allocation.Project = strings.TrimSpace(allocation.Project)
+	switch {
+	case allocation.UserID <= 0:
+		return models.Invalid("user_id is required")
+	case allocation.Project == "":
+		return models.Invalid("project is required")
+	case allocation.Hours <= 0:
+		return models.Invalid("hours must be positive")
+	}
+	if _, err := time.Parse(periodLayout, allocation.Period); err != nil {
+		return models.Invalid("period must be formatted as YYYY-MM")
+	}
+	allocation.CreatedBy, allocation.CreatedAt = actor, s.Now()
+	return s.Store.CreateProjectAllocation(ctx, allocation)
+}
+
+// Allocations returns the project allocations of a month.
+func (s *Service) Allocations(ctx context.Context, period string) ([]models.ProjectAllocation, error) {
+	if _, err := time.Parse(periodLayout, period); err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	return s.Store.ListProjectAllocations(ctx, period)
+}
+
+// Deallocate removes a project allocation.
+func (s *Service) Deallocate(ctx context.Context, id int) error {
+	return s.Store.DeleteProjectAllocation(ctx, id)
+}
+
+// Report compares the hours available in a month with the hours planned, by department.
+//
+// Parameters:
+//   - period: The month, formatted as YYYY-MM; the current month if empty.
+//
+// Returns:
+//   - *models.CapacityReport: The departments with their employees, and the total.
+//   - error: A validation error if the period is invalid, or the store's error.
+func (s *Service) Report(ctx context.Context, period string) (*models.CapacityReport, error) {
+	if period == "" {
+		period = s.Now().Format(periodLayout)
+	}
+	first, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	last := first.AddDate(0, 1, -1)
+	employees, err := s.Store.CapacityEmployees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	leave, err := s.Store.ApprovedLeave(ctx, first, last)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := s.Store.ListProjectAllocations(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	return s.Plan(first, employees, leave, allocations), nil
+}
+
+// Plan works out the capacity report of the month starting on first. Employees with hours
+// planned who are no longer active are reported with no hours available.
+func (s *Service) Plan(first time.Time, employees []models.CapacityEmployee, leave []models.Leave,
+	allocations []models.ProjectAllocation) *models.CapacityReport {
+	last := first.AddDate(0, 1, -1)
+	report := &models.CapacityReport{Period: first.Format(periodLayout), WorkingDays: workingDays(first, last, nil),
+		HoursPerDay: s.HoursPerDay, Departments: []models.CapacityDepartment{}}
+
+	lines := map[int]*models.CapacityLine{}
+	for _, e := range employees {
+		lines[e.UserID] = &models.CapacityLine{UserID: e.UserID, Name: e.Name, Department: department(e.Department),
+			Headcount: 1, ScheduledHours: float64(report.WorkingDays) * s.HoursPerDay}
+	}
+	onLeave := map[int]map[time.Time]bool{}
+	for _, l := range leave {
+		if lines[l.UserID] == nil {
+			continue
+		}
+		if onLeave[l.UserID] == nil {
+			onLeave[l.UserID] = map[time.Time]bool{}
+		}
+		start, end := l.StartDate, l.EndDate
+		if start.Before(first) {
+			start = first
+		}
+		if end.After(last) {
+			end = last
+		}
+		workingDays(start, end, onLeave[l.UserID])
+	}
+	for id, days := range onLeave {
+		lines[id].LeaveHours = float64(len(days)) * s.HoursPerDay
+	}
+	for _, a := range allocations {
+		line := lines[a.UserID]
+		if line == nil {
+			line = &models.CapacityLine{UserID: a.UserID, Name: a.Employee, Department: department(a.Department)}
+			lines[a.UserID] = line
+		}
+		line.DemandHours += a.Hours
+	}
+
+	departments := map[string]*models.CapacityDepartment{}
+	for _, line := range lines {
+		line.AvailableHours = line.ScheduledHours - line.LeaveHours
+		s.assess(line)
+		d := departments[line.Department]
+		if d == nil {
+			d = &models.CapacityDepartment{CapacityLine: models.CapacityLine{Department: line.Department}}
+			departments[line.Department] = d
+		}
+		d.Employees = append(d.Employees, *line)
+		for _, total := range []*models.CapacityLine{&d.CapacityLine, &report.Total} {
+			total.Headcount += line.Headcount
+			total.ScheduledHours += line.ScheduledHours
+			total.LeaveHours += line.LeaveHours
+			total.AvailableHours += line.AvailableHours
+			total.DemandHours += line.DemandHours
+		}
+	}
+	for _, d := range departments {
+		s.assess(&d.CapacityLine)
+		sort.Slice(d.Employees, func(i, j int) bool {
+			if d.Employees[i].Name != d.Employees[j].Name {
+				return d.Employees[i].Name < d.Employees[j].Name
+			}
+			return d.Employees[i].UserID < d.Employees[j].UserID
+		})
+		report.Departments = append(report.Departments, *d)
+	}
+	sort.Slice(report.Departments, func(i, j int) bool {
+		return report.Departments[i].Department < report.Departments[j].Department
+	})
+	s.assess(&report.Total)
+	return report
+}
+
+// assess rounds a line's hours and sets its utilization and status. A line with no hours
+// available is over-allocated if any are planned, and balanced otherwise.
+func (s *Service) assess(line *models.CapacityLine) {
+	line.ScheduledHours, line.LeaveHours = round(line.ScheduledHours), round(line.LeaveHours)
+	line.AvailableHours, line.DemandHours = round(line.AvailableHours), round(line.DemandHours)
+	line.Utilization = 0
+	if line.AvailableHours > 0 {
+		line.Utilization = round(line.DemandHours / line.AvailableHours * 100)
+	}
+	switch {
+	case line.DemandHours > line.AvailableHours:
+		line.Status = models.CapacityOverAllocated
+	case line.AvailableHours > 0 && line.Utilization < s.UnderUtilization:
+		line.Status = models.CapacityUnderAllocated
+	default:
+		line.Status = models.CapacityBalanced
+	}
+}
+
+// workingDays counts the weekdays from first to last, adding them to days if it is not nil.
+func workingDays(first, last time.Time, days map[time.Time]bool) int {
+	count := 0
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		count++
+		if days != nil {
+			days[day] = true
+		}
+	}
+	return count
+}
+
+// department returns the department an employee is reported under.
+func department(name string) string {
+	if name == "" {
+		return unassigned
+	}
+	return name
+}
+
+// round rounds hours and percentages to two decimals.
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,85 @@
+package capacity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestPlan(t *testing.T) {
+	s := NewService(nil, 8, 70)
+	employees := []models.CapacityEmployee{
+		{UserID: 1, Name: "Ana", Department: "Engineering"},
+		{UserID: 2, Name: "Bo", Department: "Engineering"},
+		{UserID: 3, Name: "Cal", Department: "Sales"},
+	}
+	// Ana's leave overlaps the month by two weekdays and the second request adds one more
+	leave := []models.Leave{
+		{UserID: 1, StartDate: day("2025-05-29"), EndDate: day("2025-06-03")},
+		{UserID: 1, StartDate: day("2025-06-03"), EndDate: day("2025-06-04")},
+	}
+	allocations := []models.ProjectAllocation{
+		{UserID: 1, Project: "Portal", Hours: 100},
+		{UserID: 1, Project: "Billing", Hours: 60},
+		{UserID: 2, Project: "Portal", Hours: 120},
+		{UserID: 3, Project: "Roadshow", Hours: 50},
+		{UserID: 4, Employee: "Dee", Department: "Sales", Project: "Roadshow", Hours: 10}, // Has left
+	}
+	report := s.Plan(day("2025-06-01"), employees, leave, allocations)
+
+	assert.Equal(t, "2025-06", report.Period)
+	assert.Equal(t, 21, report.WorkingDays)
+	require.Len(t, report.Departments, 2)
+
+	engineering := report.Departments[0]
+	assert.Equal(t, "Engineering", engineering.Department)
+	assert.Equal(t, 24.0, engineering.Employees[0].LeaveHours)
+	assert.Equal(t, 144.0, engineering.Employees[0].AvailableHours)
+	assert.Equal(t, models.CapacityOverAllocated, engineering.Employees[0].Status)
+	assert.Equal(t, models.CapacityBalanced, engineering.Employees[1].Status)
+	assert.Equal(t, 312.0, engineering.AvailableHours)
+	assert.Equal(t, 280.0, engineering.DemandHours)
+	assert.Equal(t, 89.74, engineering.Utilization)
+	assert.Equal(t, models.CapacityBalanced, engineering.Status)
+
+	sales := report.Departments[1]
+	assert.Equal(t, 1, sales.Headcount, "Dee's hours count, but Dee has left and is not in the headcount")
+	assert.Equal(t, []string{"Cal", "Dee"}, []string{sales.Employees[0].Name, sales.Employees[1].Name})
+	assert.Equal(t, models.CapacityOverAllocated, sales.Employees[1].Status)
+	assert.Equal(t, 35.71, sales.Utilization)
+	assert.Equal(t, models.CapacityUnderAllocated, sales.Status)
+
+	assert.Equal(t, 3, report.Total.Headcount)
+	assert.Equal(t, 480.0, report.Total.AvailableHours)
+	assert.Equal(t, 340.0, report.Total.DemandHours)
+	assert.Equal(t, models.CapacityBalanced, report.Total.Status)
+}
+
+func TestAllocateChecksTheAllocation(t *testing.T) {
+	s := NewService(nil, 8, 70)
+	for _, allocation := range []models.ProjectAllocation{
+		{Project: "Portal", Period: "2025-06", Hours: 10},
+		{UserID: 1, Project: " ", Period: "2025-06", Hours: 10},
+		{UserID: 1, Project: "Portal", Period: "June", Hours: 10},
+		{UserID: 1, Project: "Portal", Period: "2025-06"},
+	} {
+		err := s.Allocate(context.Background(), &allocation, "planner@example.com")
+		assert.True(t, errors.Is(err, models.ErrValidation), "%+v", allocation)
+	}
+}
+
+func TestReportChecksThePeriod(t *testing.T) {
+	_, err := NewService(nil, 8, 70).Report(context.Background(), "2025-13")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
@@ -0,0 +1,528 @@
+// Package deliveryroutes plans the company's own deliveries. Local shipments, those sent
+// without a carrier, are grouped into a route per vehicle and driver for a day and put in
+// order: stops with coordinates by nearest neighbour from the depot, then the others by
+// postal code. When the driver leaves, the shipments go out for delivery; at each stop the
+// driver app records the recipient's signature or a photo, which marks the shipment
+// delivered, or the reason the delivery failed.
+package deliveryroutes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/controllers/storage"
+	"erp/models"
+)
+
+// clockSkew is how far in the future a device's delivery time may be before it is refused.
+const clockSkew = 5 * time.Minute
+
+// imageTypes maps the accepted proof image content types to file extensions.
+var imageTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+}
+
+// Service plans delivery routes and records proof of delivery.
+type Service struct {
+	Store   models.DeliveryRouteStore
+	Storage storage.Storage  // Keeps the signatures and photos
+	Now     func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a delivery route service.
+func NewService(store models.DeliveryRouteStore, files storage.Storage) *Service {
+	return &Service{Store: store, Storage: files, Now: time.Now}
+}
+
+// Proof is what the driver records at a stop.
+type Proof struct {
+	RecipientName string
+	Signature     []byte // PNG or JPEG; a signature or a photo is required
+	Photo         []byte // PNG or JPEG
+	Note          string
+	DeliveredAt   time.Time // When it was delivered, on the device; now if zero
+}
+
+// Plan checks a route and records it as planned, with its stops in sequence.
+//
+// Parameters:
+//   - route: The route; DeliveryDate, Vehicle, DriverEmail, the depot and the stops'
+//     ShipmentID and coordinates are read. The ID, status and sequence are set.
+//   - actor: Email of the dispatcher.
+//
+// Returns:
+//   - error: A validation error if the route is incomplete or a shipment cannot be routed,
+//     a conflict if a shipment is on another route, or the store's error.
+func (s *Service) Plan(ctx context.Context, route *models.DeliveryRoute, actor string) error {
+	route.Vehicle = strings.TrimSpace(route.Vehicle)
+	route.DriverEmail = strings.TrimSpace(route.DriverEmail)
+	switch {
+	case route.DeliveryDate.IsZero():
+		return models.Invalid("delivery_date is required")
+	case route.Vehicle == "":
+		return models.Invalid("vehicle is required")
+	case route.DriverEmail == "":
+		return models.Invalid("driver_email is required")
+	}
+	if err := checkPoint("depot", route.DepotLatitude, route.DepotLongitude); err != nil {
+		return err
+	}
+	seen := make(map[int]bool, len(route.Stops))
+	for i, stop := range route.Stops {
+		if stop.ShipmentID <= 0 {
+			return models.Invalid("stop %d has no shipment_id", i+1)
+		}
+		if seen[stop.ShipmentID] {
+			return models.Invalid("shipment %d is listed twice", stop.ShipmentID)
+		}
+		seen[stop.ShipmentID] = true
+		if err := checkPoint(fmt.Sprintf("stop %d", i+1), stop.Latitude, stop.Longitude); err != nil {
+			return err
+		}
+	}
+
+	route.DeliveryDate = date(route.DeliveryDate)
+	route.Status = models.RoutePlanned
+	route.CreatedBy, route.CreatedAt = actor, s.Now()
+	for i := range route.Stops {
+		route.Stops[i].Status = models.StopPending
+	}
+	if err := s.Store.CreateDeliveryRoute(ctx, route); err != nil {
+		return err
+	}
+	// The store reads the addresses, which sequencing falls back on
+	stored, err := s.Store.GetDeliveryRoute(ctx, route.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.sequence(ctx, stored); err != nil {
+		return err
+	}
+	*route = *stored
+	return nil
+}
+
+// Get returns a route with its stops in sequence and the URLs of their proof.
+func (s *Service) Get(ctx context.Context, id int) (*models.DeliveryRoute, error) {
+	route, err := s.Store.GetDeliveryRoute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.fill(route)
+	return route, nil
+}
+
+// Route returns a route for a user: drivers may read their own routes, privileged users
+// every route.
+func (s *Service) Route(ctx context.Context, id int, actor string, privileged bool) (*models.DeliveryRoute, error) {
+	route, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !privileged && !strings.EqualFold(route.DriverEmail, actor) {
+		return nil, models.PermissionDenied("route %d is another driver's", id)
+	}
+	return route, nil
+}
+
+// List returns the routes of a day, of one driver, or both.
+func (s *Service) List(ctx context.Context, day time.Time, driverEmail string) ([]models.DeliveryRoute, error) {
+	if !day.IsZero() {
+		day = date(day)
+	}
+	routes, err := s.Store.ListDeliveryRoutes(ctx, day, driverEmail)
+	if err != nil {
+		return nil, err
+	}
+	for i := range routes {
+		s.fill(&routes[i])
+	}
+	return routes, nil
+}
+
+// Unrouted returns the local shipments waiting for a route.
+func (s *Service) Unrouted(ctx context.Context) ([]models.UnroutedShipment, error) {
+	return s.Store.UnroutedShipments(ctx)
+}
+
+// Delete removes a planned route; its shipments wait for another route.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	return s.Store.DeleteDeliveryRoute(ctx, id)
+}
+
+// AddStop adds a shipment at the end of a planned route. Optimize puts it in place.
+func (s *Service) AddStop(ctx context.Context, stop *models.DeliveryStop) (*models.DeliveryRoute, error) {
+	if stop.ShipmentID <= 0 {
+		return nil, models.Invalid("shipment_id is required")
+	}
+	if err := checkPoint("the stop", stop.Latitude, stop.Longitude); err != nil {
+		return nil, err
+	}
+	stop.Status = models.StopPending
+	if err := s.Store.AddDeliveryStop(ctx, stop); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, stop.RouteID)
+}
+
+// RemoveStop takes a shipment off a planned route.
+func (s *Service) RemoveStop(ctx context.Context, routeID, stopID int) (*models.DeliveryRoute, error) {
+	if err := s.Store.RemoveDeliveryStop(ctx, routeID, stopID); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, routeID)
+}
+
+// Optimize puts a planned route's stops back in the computed order.
+func (s *Service) Optimize(ctx context.Context, id int) (*models.DeliveryRoute, error) {
+	route, err := s.Store.GetDeliveryRoute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if route.Status != models.RoutePlanned {
+		return nil, models.Conflict("route %d is %s", id, route.Status)
+	}
+	return s.sequence(ctx, route)
+}
+
+// Reorder puts a planned route's stops in the dispatcher's order.
+//
+// Returns:
+//   - *models.DeliveryRoute: The route in its new order.
+//   - error: A validation error unless stopIDs lists every stop of the route once, a
+//     conflict if the route is no longer planned, or the store's error.
+func (s *Service) Reorder(ctx context.Context, id int, stopIDs []int) (*models.DeliveryRoute, error) {
+	route, err := s.Store.GetDeliveryRoute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	onRoute := make(map[int]bool, len(route.Stops))
+	for _, stop := range route.Stops {
+		onRoute[stop.ID] = true
+	}
+	if len(stopIDs) != len(route.Stops) {
+		return nil, models.Invalid("stop_ids must list each of the route's %d stops once", len(route.Stops))
+	}
+	for _, stopID := range stopIDs {
+		if !onRoute[stopID] {
+			return nil, models.Invalid("stop_ids must list each of the route's %d stops once", len(route.Stops))
+		}
+		delete(onRoute, stopID)
+	}
+	if err := s.Store.SequenceDeliveryStops(ctx, id, stopIDs); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Dispatch sends a planned route out: its shipments are out for delivery.
+func (s *Service) Dispatch(ctx context.Context, id int) (*models.DeliveryRoute, error) {
+	route, err := s.Store.GetDeliveryRoute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(route.Stops) == 0 {
+		return nil, models.Invalid("route %d has no stops", id)
+	}
+	if route, err = s.Store.DispatchDeliveryRoute(ctx, id, s.Now()); err != nil {
+		return nil, err
+	}
+	s.fill(route)
+	return route, nil
+}
+
+// Deliver records the proof of delivery at a stop. The images are stored first and removed
+// again if the stop cannot be completed.
+//
+// Parameters:
+//   - routeID, stopID: The stop.
+//   - proof: The recipient's name and a signature or photo.
+//   - actor: Email of the user recording it.
+//   - privileged: Whether the user may record stops of other drivers' routes.
+//
+// Returns:
+//   - *models.DeliveryStop: The delivered stop.
+//   - error: models.ErrNotFound if the stop is not on the route, a permission error if
+//     the route is another driver's, a validation error for a missing name or proof, an
+//     unsupported image or a delivery time in the future, a conflict if the route is not
+//     dispatched or the stop was already completed, or the store's error.
+func (s *Service) Deliver(ctx context.Context, routeID, stopID int, proof Proof, actor string, privileged bool) (*models.DeliveryStop, error) {
+	stop, err := s.pendingStop(ctx, routeID, stopID, actor, privileged)
+	if err != nil {
+		return nil, err
+	}
+	proof.RecipientName = strings.TrimSpace(proof.RecipientName)
+	switch {
+	case proof.RecipientName == "":
+		return nil, models.Invalid("recipient_name is required")
+	case len(proof.Signature) == 0 && len(proof.Photo) == 0:
+		return nil, models.Invalid("a signature or a photo is required")
+	}
+	completedAt, err := s.deviceTime(proof.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []string
+	for _, image := range []struct {
+		data []byte
+		kind string
+		key  *string
+	}{{proof.Signature, "signature", &stop.SignatureKey}, {proof.Photo, "photo", &stop.PhotoKey}} {
+		if len(image.data) == 0 {
+			continue
+		}
+		key, err := s.store(routeID, stopID, image.kind, image.data)
+		if err != nil {
+			s.remove(stored)
+			return nil, err
+		}
+		*image.key = key
+		stored = append(stored, key)
+	}
+
+	stop.Status = models.StopDelivered
+	stop.RecipientName = proof.RecipientName
+	stop.Note = strings.TrimSpace(proof.Note)
+	stop.CompletedAt, stop.RecordedBy = &completedAt, actor
+	if err := s.Store.CompleteDeliveryStop(ctx, stop); err != nil {
+		s.remove(stored)
+		return nil, err
+	}
+	s.fillStop(stop)
+	return stop, nil
+}
+
+// Fail records that a stop could not be delivered; its shipment becomes an exception and
+// can be put on another route.
+//
+// Parameters:
+//   - routeID, stopID: The stop.
+//   - reason: Why the delivery failed; required.
+//   - at: When the driver gave up, on the device; now if zero.
+//   - actor: Email of the user recording it.
+//   - privileged: Whether the user may record stops of other drivers' routes.
+//
+// Returns:
+//   - *models.DeliveryStop: The failed stop.
+//   - error: As for Deliver.
+func (s *Service) Fail(ctx context.Context, routeID, stopID int, reason string, at time.Time, actor string, privileged bool) (*models.DeliveryStop, error) {
+	stop, err := s.pendingStop(ctx, routeID, stopID, actor, privileged)
+	if err != nil {
+		return nil, err
+	}
+	if reason = strings.TrimSpace(reason); reason == "" {
+		return nil, models.Invalid("reason is required")
+	}
+	completedAt, err := s.deviceTime(at)
+	if err != nil {
+		return nil, err
+	}
+	stop.Status, stop.Note = models.StopFailed, reason
+	stop.CompletedAt, stop.RecordedBy = &completedAt, actor
+	if err := s.Store.CompleteDeliveryStop(ctx, stop); err != nil {
+		return nil, err
+	}
+	return stop, nil
+}
+
+// pendingStop returns a stop the user may complete: one on a dispatched route of theirs,
+// or of anyone's if they are privileged.
+func (s *Service) pendingStop(ctx context.Context, routeID, stopID int, actor string, privileged bool) (*models.DeliveryStop, error) {
+	route, err := s.Store.GetDeliveryRoute(ctx, routeID)
+	if err != nil {
+		return nil, err
+	}
+	if !privileged && !strings.EqualFold(route.DriverEmail, actor) {
+		return nil, models.PermissionDenied("route %d is another driver's", routeID)
+	}
+	if route.Status != models.RouteDispatched {
+		return nil, models.Conflict("route %d is %s", routeID, route.Status)
+	}
+	for i := range route.Stops {
+		if route.Stops[i].ID != stopID {
+			continue
+		}
+		if route.Stops[i].Status != models.StopPending {
+			return nil, models.Conflict("stop %d is already %s", stopID, route.Stops[i].Status)
+		}
+		return &route.Stops[i], nil
+	}
+	return nil, models.NotFound("stop %d is not on route %d", stopID, routeID)
+}
+
+// deviceTime checks a time reported by the driver app, which may be offline for a while
+// and send it later. Zero means now.
+func (s *Service) deviceTime(at time.Time) (time.Time, error) {
+	now := s.Now()
+	if at.IsZero() {
+		return now, nil
+	}
+	if at.After(now.Add(clockSkew)) {
+		return time.Time{}, models.Invalid("the delivery time cannot be in the future")
+	}
+	return at, nil
+}
+
+// store keeps a proof image under a key that cannot be guessed.
+func (s *Service) store(routeID, stopID int, kind string, data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return "", models.Invalid("the %s must be a PNG or JPEG image", kind)
+	}
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("deliveries/%d/%d-%s-%s.%s", routeID, stopID, kind, hex.EncodeToString(suffix), ext)
+	if err := s.Storage.Put(key, data, contentType); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", kind, err)
+	}
+	return key, nil
+}
+
+// remove deletes images stored for a stop that could not be completed.
+func (s *Service) remove(keys []string) {
+	for _, key := range keys {
+		s.Storage.Delete(key)
+	}
+}
+
+// sequence orders a route's stops and saves the order.
+func (s *Service) sequence(ctx context.Context, route *models.DeliveryRoute) (*models.DeliveryRoute, error) {
+	stops := Sequence(route.Stops, route.DepotLatitude, route.DepotLongitude)
+	ids := make([]int, len(stops))
+	for i, stop := range stops {
+		ids[i] = stop.ID
+	}
+	if len(ids) > 0 {
+		if err := s.Store.SequenceDeliveryStops(ctx, route.ID, ids); err != nil {
+			return nil, err
+		}
+	}
+	route.Stops = stops
+	s.fill(route)
+	return route, nil
+}
+
+// fill works out a route's distance and the URLs of its proof images.
+func (s *Service) fill(route *models.DeliveryRoute) {
+	route.DistanceKm = Distance(route.Stops, route.DepotLatitude, route.DepotLongitude)
+	for i := range route.Stops {
+		s.fillStop(&route.Stops[i])
+	}
+}
+
+// fillStop sets the URLs of a stop's proof images.
+func (s *Service) fillStop(stop *models.DeliveryStop) {
+	if stop.SignatureKey != "" {
+		stop.SignatureURL = s.Storage.URL(stop.SignatureKey)
+	}
+	if stop.PhotoKey != "" {
+		stop.PhotoURL = s.Storage.URL(stop.PhotoKey)
+	}
+}
+
+// Sequence returns stops in delivery order, numbered from 1. Stops with coordinates come
+// first, each followed by the nearest one not yet visited, starting nearest the depot or,
+// without a depot, from the first of them. Stops without coordinates follow, by postal
+// code and street.
+func Sequence(stops []models.DeliveryStop, depotLat, depotLng *float64) []models.DeliveryStop {
+	var located, unlocated []models.DeliveryStop
+	for _, stop := range stops {
+		if stop.Latitude != nil && stop.Longitude != nil {
+			located = append(located, stop)
+		} else {
+			unlocated = append(unlocated, stop)
+		}
+	}
+
+	ordered := make([]models.DeliveryStop, 0, len(stops))
+	if len(located) > 0 {
+		var lat, lng float64
+		if depotLat != nil && depotLng != nil {
+			lat, lng = *depotLat, *depotLng
+		} else {
+			lat, lng = *located[0].Latitude, *located[0].Longitude
+		}
+		for len(located) > 0 {
+			nearest := 0
+			for i := range located {
+				if haversine(lat, lng, *located[i].Latitude, *located[i].Longitude) <
+					haversine(lat, lng, *located[nearest].Latitude, *located[nearest].Longitude) {
+					nearest = i
+				}
+			}
+			next := located[nearest]
+			ordered = append(ordered, next)
+			lat, lng = *next.Latitude, *next.Longitude
+			located = append(located[:nearest], located[nearest+1:]...)
+		}
+	}
+	sort.SliceStable(unlocated, func(i, j int) bool {
+		a, b := unlocated[i].Address, unlocated[j].Address
+		if a.PostalCode != b.PostalCode {
+			return a.PostalCode < b.PostalCode
+		}
+		return a.Line1 < b.Line1
+	})
+	ordered = append(ordered, unlocated...)
+	for i := range ordered {
+		ordered[i].Sequence = i + 1
+	}
+	return ordered
+}
+
+// Distance is the straight-line length, in kilometres, of a route from the depot through
+// its stops with coordinates, in sequence.
+func Distance(stops []models.DeliveryStop, depotLat, depotLng *float64) float64 {
+	total := 0.0
+	var lat, lng *float64 = depotLat, depotLng
+	for _, stop := range stops {
+		if stop.Latitude == nil || stop.Longitude == nil {
+			continue
+		}
+		if lat != nil && lng != nil {
+			total += haversine(*lat, *lng, *stop.Latitude, *stop.Longitude)
+		}
+		lat, lng = stop.Latitude, stop.Longitude
+	}
+	return math.Round(total*10) / 10
+}
+
+// haversine returns the great-circle distance between two points, in kilometres.
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := math.Pi / 180
+	dLat, dLng := (lat2-lat1)*rad, (lng2-lng1)*rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// checkPoint checks that a point has both coordinates or neither, within range.
+func checkPoint(name string, lat, lng *float64) error {
+	switch {
+	case lat == nil && lng == nil:
+		return nil
+	case lat == nil || lng == nil:
+		return models.Invalid("%s needs both a latitude and a longitude", name)
+	case *lat < -90 || *lat > 90 || *lng < -180 || *lng > 180:
+		return models.Invalid("%s has coordinates out of range", name)
+	}
+	return nil
+}
+
+// date returns the day of t at midnight UTC.
+func date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
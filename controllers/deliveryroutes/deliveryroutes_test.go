@@ -0,0 +1,211 @@
+package deliveryroutes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngImage is a PNG header, enough for content type detection.
+var pngImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// memoryDeliveryRouteStore holds one route and records what the service asks of it.
+type memoryDeliveryRouteStore struct {
+	models.DeliveryRouteStore
+	route     *models.DeliveryRoute
+	sequenced []int
+	completed *models.DeliveryStop
+	failNext  error
+}
+
+func (m *memoryDeliveryRouteStore) CreateDeliveryRoute(ctx context.Context, route *models.DeliveryRoute) error {
+	route.ID = 1
+	for i := range route.Stops {
+		route.Stops[i].ID = 10 + i
+		route.Stops[i].RouteID = 1
+	}
+	stored := *route
+	stored.Stops = append([]models.DeliveryStop(nil), route.Stops...)
+	m.route = &stored
+	return nil
+}
+
+func (m *memoryDeliveryRouteStore) GetDeliveryRoute(ctx context.Context, id int) (*models.DeliveryRoute, error) {
+	if m.route == nil || m.route.ID != id {
+		return nil, models.NotFound("delivery route %d not found", id)
+	}
+	route := *m.route
+	route.Stops = append([]models.DeliveryStop(nil), m.route.Stops...)
+	return &route, nil
+}
+
+func (m *memoryDeliveryRouteStore) SequenceDeliveryStops(ctx context.Context, routeID int, stopIDs []int) error {
+	m.sequenced = stopIDs
+	return nil
+}
+
+func (m *memoryDeliveryRouteStore) CompleteDeliveryStop(ctx context.Context, stop *models.DeliveryStop) error {
+	if m.failNext != nil {
+		return m.failNext
+	}
+	completed := *stop
+	m.completed = &completed
+	return nil
+}
+
+func float(value float64) *float64 {
+	return &value
+}
+
+// dispatchedRoute is a route of one pending stop driven by driver@example.com.
+func dispatchedRoute() *models.DeliveryRoute {
+	return &models.DeliveryRoute{ID: 1, DriverEmail: "driver@example.com", Status: models.RouteDispatched,
+		Stops: []models.DeliveryStop{{ID: 10, RouteID: 1, ShipmentID: 5, Status: models.StopPending}}}
+}
+
+func newService(store *memoryDeliveryRouteStore, files storage.Storage) *Service {
+	service := NewService(store, files)
+	service.Now = func() time.Time { return time.Date(2026, 10, 17, 14, 0, 0, 0, time.UTC) }
+	return service
+}
+
+func TestSequenceVisitsNearestStopFirst(t *testing.T) {
+	stops := []models.DeliveryStop{
+		{ID: 1, Address: models.Address{PostalCode: "1212", Line1: "Road 9"}},
+		{ID: 2, Latitude: float(23.80), Longitude: float(90.42)},
+		{ID: 3, Address: models.Address{PostalCode: "1205", Line1: "Road 2"}},
+		{ID: 4, Latitude: float(23.75), Longitude: float(90.39)},
+		{ID: 5, Latitude: float(23.78), Longitude: float(90.40)},
+	}
+
+	ordered := Sequence(stops, float(23.73), float(90.38))
+	var ids, sequences []int
+	for _, stop := range ordered {
+		ids = append(ids, stop.ID)
+		sequences = append(sequences, stop.Sequence)
+	}
+	// From the depot the nearest stop is 4, then 5 and 2; stops without coordinates last, by postal code
+	assert.Equal(t, []int{4, 5, 2, 3, 1}, ids)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, sequences)
+	assert.InDelta(t, 8.9, Distance(ordered, float(23.73), float(90.38)), 0.05)
+	assert.InDelta(t, 6.5, Distance(ordered, nil, nil), 0.05)
+}
+
+func TestPlanValidatesAndSequences(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryDeliveryRouteStore{}
+	service := newService(store, storage.NewMemoryStorage())
+
+	route := &models.DeliveryRoute{DeliveryDate: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC), Vehicle: "Van 2",
+		DriverEmail: "driver@example.com", DepotLatitude: float(23.73)}
+	err := service.Plan(ctx, route, "dispatch@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Contains(t, err.Error(), "depot needs both a latitude and a longitude")
+
+	route.DepotLongitude = float(90.38)
+	route.Stops = []models.DeliveryStop{{ShipmentID: 7}, {ShipmentID: 7}}
+	err = service.Plan(ctx, route, "dispatch@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Contains(t, err.Error(), "shipment 7 is listed twice")
+
+	route.Stops = []models.DeliveryStop{
+		{ShipmentID: 7},
+		{ShipmentID: 8, Latitude: float(23.78), Longitude: float(90.40)},
+	}
+	require.NoError(t, service.Plan(ctx, route, "dispatch@example.com"))
+	assert.Equal(t, models.RoutePlanned, route.Status)
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), route.DeliveryDate)
+	assert.Equal(t, []int{11, 10}, store.sequenced)
+	assert.Equal(t, 8, route.Stops[0].ShipmentID)
+	assert.Equal(t, 1, route.Stops[0].Sequence)
+}
+
+func TestReorderRequiresEveryStopOnce(t *testing.T) {
+	ctx := context.Background()
+	route := dispatchedRoute()
+	route.Status = models.RoutePlanned
+	route.Stops = append(route.Stops, models.DeliveryStop{ID: 11, RouteID: 1, ShipmentID: 6})
+	store := &memoryDeliveryRouteStore{route: route}
+	service := newService(store, storage.NewMemoryStorage())
+
+	_, err := service.Reorder(ctx, 1, []int{10, 10})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = service.Reorder(ctx, 1, []int{11})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	_, err = service.Reorder(ctx, 1, []int{11, 10})
+	require.NoError(t, err)
+	assert.Equal(t, []int{11, 10}, store.sequenced)
+}
+
+func TestDeliverStoresProof(t *testing.T) {
+	store := &memoryDeliveryRouteStore{route: dispatchedRoute()}
+	files := storage.NewMemoryStorage()
+	service := newService(store, files)
+	deliveredAt := time.Date(2026, 10, 17, 13, 40, 0, 0, time.UTC)
+
+	stop, err := service.Deliver(context.Background(), 1, 10, Proof{RecipientName: " Rahim ", Signature: pngImage, DeliveredAt: deliveredAt},
+		"driver@example.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, models.StopDelivered, stop.Status)
+	assert.Equal(t, "Rahim", stop.RecipientName)
+	assert.Equal(t, deliveredAt, *stop.CompletedAt)
+	assert.Contains(t, stop.SignatureKey, "deliveries/1/10-signature-")
+	assert.Equal(t, "/files/"+stop.SignatureKey, stop.SignatureURL)
+	assert.Empty(t, stop.PhotoKey)
+	assert.Equal(t, stop.SignatureKey, store.completed.SignatureKey)
+	assert.Contains(t, files.Files, stop.SignatureKey)
+}
+
+func TestDeliverRefusesOtherDriversAndBadProof(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryDeliveryRouteStore{route: dispatchedRoute()}
+	files := storage.NewMemoryStorage()
+	service := newService(store, files)
+	proof := Proof{RecipientName: "Rahim", Photo: pngImage}
+
+	_, err := service.Deliver(ctx, 1, 10, proof, "other@example.com", false)
+	assert.ErrorIs(t, err, models.ErrPermissionDenied)
+	_, err = service.Deliver(ctx, 1, 99, proof, "driver@example.com", false)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	_, err = service.Deliver(ctx, 1, 10, Proof{RecipientName: "Rahim"}, "driver@example.com", false)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = service.Deliver(ctx, 1, 10, Proof{RecipientName: "Rahim", Photo: []byte("%PDF-1.4")}, "driver@example.com", false)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	future := proof
+	future.DeliveredAt = service.Now().Add(time.Hour)
+	_, err = service.Deliver(ctx, 1, 10, future, "driver@example.com", false)
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	// The images are removed when the stop cannot be recorded
+	store.failNext = errors.New("connection reset")
+	_, err = service.Deliver(ctx, 1, 10, proof, "dispatch@example.com", true)
+	assert.Error(t, err)
+	assert.Empty(t, files.Files)
+
+	store.route.Status = models.RoutePlanned
+	_, err = service.Deliver(ctx, 1, 10, proof, "driver@example.com", false)
+	assert.ErrorIs(t, err, models.ErrConflict)
+}
+
+func TestFailRequiresReason(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryDeliveryRouteStore{route: dispatchedRoute()}
+	service := newService(store, storage.NewMemoryStorage())
+
+	_, err := service.Fail(ctx, 1, 10, " ", time.Time{}, "driver@example.com", false)
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	stop, err := service.Fail(ctx, 1, 10, "Nobody home", time.Time{}, "driver@example.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, models.StopFailed, stop.Status)
+	assert.Equal(t, "Nobody home", store.completed.Note)
+	assert.Equal(t, service.Now(), *store.completed.CompletedAt)
+}
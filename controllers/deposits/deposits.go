@@ -0,0 +1,112 @@
+// Package deposits handles deposits customers pay on sales orders before they are invoiced.
+// A deposit is held as a liability (the customer_deposits account) until the order's
+// invoice is posted, when it is applied to the receivable; if the order is cancelled, what
+// is left of the order's deposits is refunded.
+package deposits
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Ledger accounts used by customer deposits.
+const (
+	DepositAccount    = "customer_deposits" // Liability for deposits not yet applied
+	ReceivableAccount = "accounts_receivable"
+	CashAccount       = "cash"
+)
+
+// Service validates customer deposits and refunds.
+type Service struct {
+	Store models.CustomerDepositStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a customer deposit service.
+func NewService(store models.CustomerDepositStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Receive records a deposit paid against a sales order. Cash is debited and the deposit
+// liability credited until the order is invoiced.
+//
+// Parameters:
+//   - deposit: The deposit with its sales order, amount and optional receipt date, method and reference.
+//   - actor: Email of the user recording it.
+//
+// Returns:
+//   - error: A validation error if the sales order or amount is missing, models.ErrNotFound
+//     if the sales order does not exist, or the store's error.
+func (s *Service) Receive(ctx context.Context, deposit *models.CustomerDeposit, actor string) error {
+	switch {
+	case deposit.SalesOrderID <= 0:
+		return models.Invalid("sales_order_id is required")
+	case deposit.Amount <= 0:
+		return models.Invalid("amount must be positive")
+	}
+	now := s.Now()
+	if deposit.ReceivedOn.IsZero() {
+		deposit.ReceivedOn = now
+	}
+	deposit.Amount = roundCents(deposit.Amount)
+	deposit.Applied, deposit.Refunded, deposit.RefundReason, deposit.RefundedAt = 0, 0, "", nil
+	deposit.CreatedBy, deposit.CreatedAt = actor, now
+	return s.Store.CreateCustomerDeposit(ctx, deposit)
+}
+
+// Deposit returns a deposit.
+func (s *Service) Deposit(ctx context.Context, id int) (*models.CustomerDeposit, error) {
+	return s.Store.GetCustomerDeposit(ctx, id)
+}
+
+// Deposits lists deposits, oldest first. salesOrderID 0 lists every order's.
+func (s *Service) Deposits(ctx context.Context, salesOrderID int, unappliedOnly bool) ([]models.CustomerDeposit, error) {
+	return s.Store.GetCustomerDeposits(ctx, salesOrderID, unappliedOnly)
+}
+
+// RefundOrder refunds what is left of a cancelled sales order's deposits. Parts already
+// applied to invoices are not refunded.
+//
+// Parameters:
+//   - salesOrderID: The cancelled sales order.
+//   - reason: Why the order was cancelled, recorded in the audit log.
+//   - actor: Email of the user refunding.
+//
+// Returns:
+//   - []models.CustomerDeposit: The deposits refunded.
+//   - error: A validation error if the reason is missing, a conflict if the order has
+//     nothing left to refund, or the store's error.
+func (s *Service) RefundOrder(ctx context.Context, salesOrderID int, reason, actor string) ([]models.CustomerDeposit, error) {
+	if reason = strings.TrimSpace(reason); reason == "" {
+		return nil, models.Invalid("reason is required")
+	}
+	return s.Store.RefundCustomerDeposits(ctx, salesOrderID, reason, actor, s.Now())
+}
+
+// Apply applies deposits, oldest first, to an invoice amount. It returns the amount taken
+// from each deposit, in the order given, and the total applied.
+func Apply(deposits []models.CustomerDeposit, amount float64) ([]float64, float64) {
+	applied := make([]float64, len(deposits))
+	remaining := roundCents(amount)
+	for i, deposit := range deposits {
+		if remaining <= 0 {
+			break
+		}
+		available := roundCents(deposit.Unapplied())
+		if available <= 0 {
+			continue
+		}
+		applied[i] = math.Min(available, remaining)
+		remaining = roundCents(remaining - applied[i])
+	}
+	return applied, roundCents(amount - math.Max(remaining, 0))
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,95 @@
+package deposits
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore records the deposits and refunds it is given.
+type fakeStore struct {
+	deposits []models.CustomerDeposit
+	refunded []int
+	reason   string
+}
+
+func (f *fakeStore) CreateCustomerDeposit(ctx context.Context, deposit *models.CustomerDeposit) error {
+	deposit.ID = len(f.deposits) + 1
+	f.deposits = append(f.deposits, *deposit)
+	return nil
+}
+
+func (f *fakeStore) GetCustomerDeposit(ctx context.Context, id int) (*models.CustomerDeposit, error) {
+	return nil, models.NotFound("deposit %d not found", id)
+}
+
+func (f *fakeStore) GetCustomerDeposits(ctx context.Context, salesOrderID int, unappliedOnly bool) ([]models.CustomerDeposit, error) {
+	return f.deposits, nil
+}
+
+func (f *fakeStore) RefundCustomerDeposits(ctx context.Context, salesOrderID int, reason, actor string, at time.Time) ([]models.CustomerDeposit, error) {
+	f.refunded = append(f.refunded, salesOrderID)
+	f.reason = reason
+	return nil, nil
+}
+
+var day = time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+
+func newTestService() (*Service, *fakeStore) {
+	store := &fakeStore{}
+	service := NewService(store)
+	service.Now = func() time.Time { return day }
+	return service, store
+}
+
+func TestApply(t *testing.T) {
+	list := []models.CustomerDeposit{
+		{ID: 1, Amount: 100, Applied: 100},
+		{ID: 2, Amount: 300, Applied: 50, Refunded: 50},
+		{ID: 3, Amount: 500},
+	}
+
+	applied, total := Apply(list, 350)
+	assert.Equal(t, []float64{0, 200, 150}, applied, "used up deposits are skipped and the oldest is used first")
+	assert.Equal(t, 350.0, total)
+
+	applied, total = Apply(list, 1000)
+	assert.Equal(t, []float64{0, 200, 500}, applied, "only what is left of the deposits is applied")
+	assert.Equal(t, 700.0, total)
+
+	applied, total = Apply(nil, 100)
+	assert.Empty(t, applied)
+	assert.Equal(t, 0.0, total)
+}
+
+func TestReceive(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+	deposit := &models.CustomerDeposit{SalesOrderID: 4, Amount: 120.456, Applied: 50}
+	require.NoError(t, service.Receive(ctx, deposit, "sales@example.com"))
+	assert.Equal(t, 120.46, deposit.Amount)
+	assert.Equal(t, 0.0, deposit.Applied, "a new deposit has nothing applied")
+	assert.Equal(t, day, deposit.ReceivedOn)
+	assert.Equal(t, "sales@example.com", store.deposits[0].CreatedBy)
+
+	assert.ErrorIs(t, service.Receive(ctx, &models.CustomerDeposit{Amount: 10}, "sales@example.com"), models.ErrValidation)
+	assert.ErrorIs(t, service.Receive(ctx, &models.CustomerDeposit{SalesOrderID: 4}, "sales@example.com"), models.ErrValidation)
+}
+
+func TestRefundOrderNeedsReason(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+	_, err := service.RefundOrder(ctx, 4, "  ", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Empty(t, store.refunded)
+
+	_, err = service.RefundOrder(ctx, 4, " Order cancelled ", "finance@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, store.refunded)
+	assert.Equal(t, "Order cancelled", store.reason)
+}
@@ -0,0 +1,196 @@
+// Package disputes handles customers' disputes of charges on posted invoices. While a
+// dispute is open, dunning of the invoice is suspended: no late fees are charged and no
+// installment reminders are sent. A dispute is resolved by issuing a credit note, adjusting
+// the receivable, or upholding the charge.
+package disputes
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Ledger accounts used by dispute resolutions. The receivable is credited with the amount
+// allowed and the account of the resolution debited.
+const (
+	ReceivableAccount = "accounts_receivable"
+	CreditNoteAccount = "sales_allowances"       // Contra revenue for credit notes
+	AdjustmentAccount = "receivable_adjustments" // Expense for receivables written down
+)
+
+// agingBuckets are the upper bounds, in days since a dispute was raised, of the aging report
+// buckets; the last bucket has no bound.
+var agingBuckets = []struct {
+	Name string
+	Days int
+}{
+	{"0-30", 30},
+	{"31-60", 60},
+	{"61-90", 90},
+	{"90+", math.MaxInt},
+}
+
+// Service validates and resolves invoice disputes.
+type Service struct {
+	Store models.DisputeStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates an invoice dispute service.
+func NewService(store models.DisputeStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Raise opens a dispute on a posted invoice.
+//
+// Parameters:
+//   - dispute: The dispute with its invoice, reason and disputed amount; its ID is set.
+//   - actor: Email of the user recording it.
+//
+// Returns:
+//   - error: A validation error if the reason or amount is missing or the amount exceeds
+//     what is owed, models.ErrNotFound if the invoice does not exist, a conflict if it is
+//     not posted or already disputed, or the store's error.
+func (s *Service) Raise(ctx context.Context, dispute *models.InvoiceDispute, actor string) error {
+	dispute.Reason = strings.TrimSpace(dispute.Reason)
+	dispute.Amount = roundCents(dispute.Amount)
+	switch {
+	case dispute.Reason == "":
+		return models.Invalid("reason is required")
+	case dispute.Amount <= 0:
+		return models.Invalid("amount must be positive")
+	}
+	balance, err := s.Store.GetInvoiceBalance(ctx, dispute.InvoiceID)
+	if err != nil {
+		return err
+	}
+	if balance.Status != models.InvoiceStatusPosted {
+		return models.Conflict("only posted invoices can be disputed")
+	}
+	if owed := roundCents(balance.Amount - balance.Settled); dispute.Amount > owed {
+		return models.Invalid("amount exceeds the %.2f owed on the invoice", owed)
+	}
+
+	dispute.CustomerID = balance.CustomerID
+	dispute.Status, dispute.Resolution, dispute.ResolvedAmount, dispute.ResolutionNote = models.DisputeOpen, "", 0, ""
+	dispute.CreditNoteID, dispute.ResolvedBy, dispute.ResolvedAt = nil, "", nil
+	dispute.RaisedBy, dispute.RaisedAt = actor, s.Now()
+	return s.Store.CreateDispute(ctx, dispute)
+}
+
+// Dispute returns a dispute.
+func (s *Service) Dispute(ctx context.Context, id int) (*models.InvoiceDispute, error) {
+	return s.Store.GetDispute(ctx, id)
+}
+
+// Disputes lists disputes, newest first. invoiceID 0 and status "" match any.
+func (s *Service) Disputes(ctx context.Context, invoiceID int, status string) ([]models.InvoiceDispute, error) {
+	if status != "" && status != models.DisputeOpen && status != models.DisputeResolved {
+		return nil, models.Invalid("status must be %q or %q", models.DisputeOpen, models.DisputeResolved)
+	}
+	return s.Store.GetDisputes(ctx, invoiceID, status)
+}
+
+// Resolve closes an open dispute. A credit note or adjustment allows the amount given, or
+// the whole disputed amount if it is 0; upholding allows nothing.
+//
+// Parameters:
+//   - id: The dispute.
+//   - resolution: models.ResolutionCreditNote, models.ResolutionAdjustment or models.ResolutionUphold.
+//   - amount: The amount allowed, at most the disputed amount.
+//   - note: Why the dispute was resolved this way, recorded in the audit log.
+//   - actor: Email of the user resolving it.
+//
+// Returns:
+//   - *models.InvoiceDispute: The resolved dispute, with its credit note if one was issued.
+//   - error: A validation error if the resolution, amount or note is invalid,
+//     models.ErrNotFound if the dispute does not exist, a conflict if it is resolved
+//     already, or the store's error.
+func (s *Service) Resolve(ctx context.Context, id int, resolution string, amount float64, note, actor string) (*models.InvoiceDispute, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, models.Invalid("note is required")
+	}
+	dispute, err := s.Store.GetDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != models.DisputeOpen {
+		return nil, models.Conflict("dispute %d is resolved already", id)
+	}
+
+	amount = roundCents(amount)
+	switch resolution {
+	case models.ResolutionCreditNote, models.ResolutionAdjustment:
+		if amount == 0 {
+			amount = dispute.Amount
+		}
+		if amount < 0 || amount > dispute.Amount {
+			return nil, models.Invalid("amount must be positive and at most the %.2f disputed", dispute.Amount)
+		}
+	case models.ResolutionUphold:
+		if amount != 0 {
+			return nil, models.Invalid("an upheld dispute allows no amount")
+		}
+	default:
+		return nil, models.Invalid("resolution must be %q, %q or %q",
+			models.ResolutionCreditNote, models.ResolutionAdjustment, models.ResolutionUphold)
+	}
+
+	now := s.Now()
+	dispute.Status, dispute.Resolution, dispute.ResolvedAmount, dispute.ResolutionNote = models.DisputeResolved, resolution, amount, note
+	dispute.ResolvedBy, dispute.ResolvedAt = actor, &now
+	if err := s.Store.ResolveDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// CreditNote returns a credit note.
+func (s *Service) CreditNote(ctx context.Context, id int) (*models.CreditNote, error) {
+	return s.Store.GetCreditNote(ctx, id)
+}
+
+// Aging totals the open disputes by the days since they were raised.
+func (s *Service) Aging(ctx context.Context) (*models.DisputeAging, error) {
+	open, err := s.Store.GetDisputes(ctx, 0, models.DisputeOpen)
+	if err != nil {
+		return nil, err
+	}
+	return Age(open, s.Now()), nil
+}
+
+// Age buckets open disputes by the whole days between when they were raised and now.
+func Age(open []models.InvoiceDispute, now time.Time) *models.DisputeAging {
+	report := &models.DisputeAging{AsOf: now, Buckets: make([]models.DisputeAgingBucket, len(agingBuckets))}
+	for i, bucket := range agingBuckets {
+		report.Buckets[i].Bucket = bucket.Name
+	}
+	for _, dispute := range open {
+		days := int(date(now).Sub(date(dispute.RaisedAt)).Hours() / 24)
+		for i, bucket := range agingBuckets {
+			if days <= bucket.Days {
+				report.Buckets[i].Disputes++
+				report.Buckets[i].Amount = roundCents(report.Buckets[i].Amount + dispute.Amount)
+				break
+			}
+		}
+		report.Disputes++
+		report.Amount = roundCents(report.Amount + dispute.Amount)
+	}
+	return report
+}
+
+// date returns the day of t at midnight UTC, so that days can be counted across zones.
+func date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,115 @@
+package disputes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps one invoice balance and the disputes in memory.
+type fakeStore struct {
+	balance  models.InvoiceBalance
+	disputes []models.InvoiceDispute
+}
+
+func (f *fakeStore) GetInvoiceBalance(ctx context.Context, invoiceID int) (*models.InvoiceBalance, error) {
+	balance := f.balance
+	return &balance, nil
+}
+
+func (f *fakeStore) CreateDispute(ctx context.Context, dispute *models.InvoiceDispute) error {
+	dispute.ID = len(f.disputes) + 1
+	f.disputes = append(f.disputes, *dispute)
+	return nil
+}
+
+func (f *fakeStore) GetDispute(ctx context.Context, id int) (*models.InvoiceDispute, error) {
+	dispute := f.disputes[id-1]
+	return &dispute, nil
+}
+
+func (f *fakeStore) GetDisputes(ctx context.Context, invoiceID int, status string) ([]models.InvoiceDispute, error) {
+	return f.disputes, nil
+}
+
+func (f *fakeStore) ResolveDispute(ctx context.Context, dispute *models.InvoiceDispute) error {
+	f.disputes[dispute.ID-1] = *dispute
+	return nil
+}
+
+func (f *fakeStore) GetCreditNote(ctx context.Context, id int) (*models.CreditNote, error) {
+	return nil, models.ErrNotFound
+}
+
+func newService(store *fakeStore) *Service {
+	service := NewService(store)
+	service.Now = func() time.Time { return time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC) }
+	return service
+}
+
+func TestRaise(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{balance: models.InvoiceBalance{InvoiceID: 7, CustomerID: 9, Status: models.InvoiceStatusPosted,
+		Amount: 500, Settled: 300}}
+	service := newService(store)
+
+	dispute := &models.InvoiceDispute{InvoiceID: 7, Reason: " Wrong quantity ", Amount: 150}
+	require.NoError(t, service.Raise(ctx, dispute, "sales@example.com"))
+	assert.Equal(t, models.DisputeOpen, dispute.Status)
+	assert.Equal(t, 9, dispute.CustomerID)
+	assert.Equal(t, "Wrong quantity", dispute.Reason)
+
+	err := service.Raise(ctx, &models.InvoiceDispute{InvoiceID: 7, Reason: "Too much", Amount: 250}, "sales@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "more than is owed cannot be disputed")
+
+	store.balance.Status = models.InvoiceStatusDraft
+	err = service.Raise(ctx, &models.InvoiceDispute{InvoiceID: 7, Reason: "Draft", Amount: 10}, "sales@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{disputes: []models.InvoiceDispute{{ID: 1, InvoiceID: 7, Amount: 150, Status: models.DisputeOpen}}}
+	service := newService(store)
+
+	_, err := service.Resolve(ctx, 1, models.ResolutionAdjustment, 200, "Partial", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "more than was disputed cannot be allowed")
+	_, err = service.Resolve(ctx, 1, models.ResolutionUphold, 10, "Upheld", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = service.Resolve(ctx, 1, "refund", 0, "Refund", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = service.Resolve(ctx, 1, models.ResolutionCreditNote, 0, " ", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "a note is required")
+
+	dispute, err := service.Resolve(ctx, 1, models.ResolutionCreditNote, 0, "Damaged goods", "finance@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.DisputeResolved, dispute.Status)
+	assert.Equal(t, 150.0, dispute.ResolvedAmount, "the whole disputed amount is allowed by default")
+
+	_, err = service.Resolve(ctx, 1, models.ResolutionUphold, 0, "Again", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+}
+
+func TestAge(t *testing.T) {
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	open := []models.InvoiceDispute{
+		{Amount: 10, RaisedAt: now.AddDate(0, 0, -30)},
+		{Amount: 20, RaisedAt: now.AddDate(0, 0, -31)},
+		{Amount: 30, RaisedAt: now.AddDate(0, 0, -75)},
+		{Amount: 40, RaisedAt: now.AddDate(0, -6, 0)},
+		{Amount: 5, RaisedAt: now},
+	}
+	report := Age(open, now)
+	require.Len(t, report.Buckets, 4)
+	assert.Equal(t, models.DisputeAgingBucket{Bucket: "0-30", Disputes: 2, Amount: 15}, report.Buckets[0])
+	assert.Equal(t, models.DisputeAgingBucket{Bucket: "31-60", Disputes: 1, Amount: 20}, report.Buckets[1])
+	assert.Equal(t, models.DisputeAgingBucket{Bucket: "61-90", Disputes: 1, Amount: 30}, report.Buckets[2])
+	assert.Equal(t, models.DisputeAgingBucket{Bucket: "90+", Disputes: 1, Amount: 40}, report.Buckets[3])
+	assert.Equal(t, 5, report.Disputes)
+	assert.Equal(t, 105.0, report.Amount)
+}
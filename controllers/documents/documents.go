@@ -0,0 +1,112 @@
+// Package documents renders printable business documents, such as delivery notes and
+// purchase orders, as HTML or PDF, and numbers them.
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"erp/models"
+)
+
+// Formats a document can be rendered in.
+const (
+	FormatPDF  = "pdf"
+	FormatHTML = "html"
+)
+
+var htmlTemplate = template.Must(template.New("document").Funcs(template.FuncMap{
+	"date":  func(d models.PrintedDocument) string { return d.Date.Format("2006-01-02") },
+	"lines": func(text string) []string { return strings.Split(text, "\n") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 12px; margin: 40px; }
+header { display: flex; justify-content: space-between; }
+h1 { font-size: 22px; margin: 0; }
+.parties { display: flex; gap: 60px; margin: 24px 0; }
+table { border-collapse: collapse; width: 100%; margin: 16px 0; }
+th, td { border-bottom: 1px solid #ccc; padding: 6px; text-align: left; }
+dt { font-weight: bold; float: left; width: 140px; }
+</style>
+</head>
+<body>
+<header>
+<div>
+{{- with .Company}}{{if .LogoURL}}<img src="{{.LogoURL}}" alt="" height="48"><br>{{end}}
+<strong>{{.Name}}</strong><br>{{range lines .Address}}{{.}}<br>{{end}}{{end}}
+</div>
+<div><h1>{{.Title}}</h1>No. {{.Number}}<br>Date: {{date .}}</div>
+</header>
+<div class="parties">
+{{- range .Parties}}
+<div><strong>{{.Label}}</strong><br>{{range .Lines}}{{.}}<br>{{end}}</div>
+{{- end}}
+</div>
+{{- if .Details}}
+<dl>{{range .Details}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>{{end}}</dl>
+{{- end}}
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Lines}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- if .Totals}}
+<dl>{{range .Totals}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>{{end}}</dl>
+{{- end}}
+{{- if .Notes}}
+<p>{{range lines .Notes}}{{.}}<br>{{end}}</p>
+{{- end}}
+{{- with .Company}}{{if .InvoiceFooter}}<footer>{{.InvoiceFooter}}</footer>{{end}}{{end}}
+</body>
+</html>
+`))
+
+// HTML renders a document as an HTML page.
+func HTML(doc *models.PrintedDocument) ([]byte, error) {
+	var out bytes.Buffer
+	if err := htmlTemplate.Execute(&out, doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Write sends a document in the format the request asks for: HTML with ?format=html or
+// an Accept header preferring text/html, PDF otherwise.
+//
+// Parameters:
+//   - w: The response writer.
+//   - r: The request.
+//   - doc: The document; its number names the file.
+//
+// Returns:
+//   - error: An error if the document cannot be rendered. Nothing is written in that case.
+func Write(w http.ResponseWriter, r *http.Request, doc *models.PrintedDocument) error {
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.HasPrefix(r.Header.Get("Accept"), "text/html") {
+		format = FormatHTML
+	}
+
+	content, contentType := []byte(nil), "application/pdf"
+	if format == FormatHTML {
+		var err error
+		if content, err = HTML(doc); err != nil {
+			return err
+		}
+		contentType = "text/html; charset=utf-8"
+	} else {
+		format = FormatPDF
+		content = PDF(doc)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.%s"`, doc.Number, format))
+	_, err := w.Write(content)
+	return err
+}
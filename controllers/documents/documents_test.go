@@ -0,0 +1,109 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDocument() *models.PrintedDocument {
+	return &models.PrintedDocument{
+		Title:   "Delivery Note",
+		Number:  "DN-00007",
+		Date:    time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+		Company: &models.CompanyProfile{Name: "Acme (BD) Ltd", Address: "1 Depot Rd\nDhaka", InvoiceFooter: "Thank you"},
+		Parties: []models.DocumentParty{{Label: "Ship to", Lines: []string{"<Jane>", "Chittagong"}}},
+		Columns: []string{"Product", "Quantity"},
+		Lines:   [][]string{{"Café table", "2"}, {"Chair ✓", "4"}},
+	}
+}
+
+func TestHTMLEscapesValues(t *testing.T) {
+	out, err := HTML(testDocument())
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "<h1>Delivery Note</h1>No. DN-00007<br>Date: 2026-10-16")
+	assert.Contains(t, string(out), "&lt;Jane&gt;")
+	assert.Contains(t, string(out), "<td>Café table</td><td>2</td>")
+	assert.Contains(t, string(out), "<footer>Thank you</footer>")
+}
+
+func TestPDF(t *testing.T) {
+	out := PDF(testDocument())
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), `(Acme \(BD\) Ltd) Tj`)
+	assert.Contains(t, string(out), `(Caf\351 table) Tj`, "Latin-1 characters use WinAnsiEncoding")
+	assert.Contains(t, string(out), `(Chair ?) Tj`)
+
+	// Long documents continue on further pages
+	doc := testDocument()
+	for i := 0; i < 100; i++ {
+		doc.Lines = append(doc.Lines, []string{"Item", "1"})
+	}
+	assert.Contains(t, string(PDF(doc)), "/Count 3")
+}
+
+func TestWriteChoosesFormat(t *testing.T) {
+	rr := httptest.NewRecorder()
+	require.NoError(t, Write(rr, httptest.NewRequest("GET", "/?format=html", nil), testDocument()))
+	assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `inline; filename="DN-00007.html"`, rr.Header().Get("Content-Disposition"))
+
+	rr = httptest.NewRecorder()
+	require.NoError(t, Write(rr, httptest.NewRequest("GET", "/", nil), testDocument()))
+	assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `inline; filename="DN-00007.pdf"`, rr.Header().Get("Content-Disposition"))
+}
+
+func TestNumber(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	numbers := &Numbers{DB: db}
+
+	// First generation takes the next value of the series
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT number FROM document_numbers")).
+		WithArgs("delivery_note", 12).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE document_series SET next_value = next_value + 1")).
+		WithArgs("delivery_note").
+		WillReturnRows(sqlmock.NewRows([]string{"prefix", "value", "padding"}).AddRow("DN-", 42, 5))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO document_numbers")).
+		WithArgs("delivery_note", 12, "DN-00042").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	number, err := numbers.Number(ctx, "delivery_note", 12)
+	require.NoError(t, err)
+	assert.Equal(t, "DN-00042", number)
+
+	// Reprints keep the number
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT number FROM document_numbers")).
+		WithArgs("delivery_note", 12).WillReturnRows(sqlmock.NewRows([]string{"number"}).AddRow("DN-00042"))
+	mock.ExpectRollback()
+
+	number, err = numbers.Number(ctx, "delivery_note", 12)
+	require.NoError(t, err)
+	assert.Equal(t, "DN-00042", number)
+
+	// Unknown series
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT number FROM document_numbers")).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE document_series")).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err = numbers.Number(ctx, "invoice", 1)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
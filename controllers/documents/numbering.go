@@ -0,0 +1,78 @@
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"erp/models"
+)
+
+// Numberer assigns document numbers.
+type Numberer interface {
+	// Number returns the number of a record's document in a series, assigning the next
+	// one the first time the document is generated.
+	Number(ctx context.Context, series string, entityID int) (string, error)
+}
+
+// Numbers is the numbering service, backed by the document_series and document_numbers
+// tables. Numbers in a series are consecutive: the counter is only advanced in the
+// transaction that records the number.
+type Numbers struct {
+	DB *sql.DB
+}
+
+// Number returns the number of a record's document in a series, assigning the next one
+// the first time the document is generated.
+//
+// Parameters:
+//   - series: The series, e.g. "delivery_note".
+//   - entityID: The ID of the record the document is generated for.
+//
+// Returns:
+//   - string: The document number, e.g. "DN-00042".
+//   - error: models.NotFound if the series does not exist, or a database error.
+func (n *Numbers) Number(ctx context.Context, series string, entityID int) (string, error) {
+	number, err := n.assign(ctx, series, entityID)
+	if models.IsUniqueViolation(err) {
+		// Generated concurrently for the same record; use the number the other request kept
+		number, err = n.assign(ctx, series, entityID)
+	}
+	return number, err
+}
+
+func (n *Numbers) assign(ctx context.Context, series string, entityID int) (string, error) {
+	tx, err := n.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var number string
+	err = tx.QueryRowContext(ctx, `SELECT number FROM document_numbers WHERE series = $1 AND entity_id = $2`, series, entityID).Scan(&number)
+	if err == nil {
+		return number, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	var prefix string
+	var value, padding int
+	err = tx.QueryRowContext(ctx,
+		`UPDATE document_series SET next_value = next_value + 1 WHERE series = $1
+		 RETURNING prefix, next_value - 1, padding`, series).Scan(&prefix, &value, &padding)
+	if err == sql.ErrNoRows {
+		return "", models.NotFound("document series %s not found", series)
+	}
+	if err != nil {
+		return "", err
+	}
+	number = fmt.Sprintf("%s%0*d", prefix, padding, value)
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO document_numbers (series, entity_id, number) VALUES ($1, $2, $3)`,
+		series, entityID, number); err != nil {
+		return "", err
+	}
+	return number, tx.Commit()
+}
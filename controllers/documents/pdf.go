@@ -0,0 +1,217 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"erp/models"
+)
+
+// A4 page size and layout, in points.
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 50.0
+	lineHeight = 14.0
+)
+
+// PDF renders a document as a PDF file with the standard Helvetica fonts. Characters
+// outside Latin-1 are printed as "?".
+func PDF(doc *models.PrintedDocument) []byte {
+	p := &pdfPages{}
+	p.newPage()
+
+	// Company on the left, title, number and date on the right
+	top := p.y
+	if doc.Company != nil {
+		p.text(margin, p.y, 11, true, doc.Company.Name)
+		for _, line := range strings.Split(doc.Company.Address, "\n") {
+			p.y -= lineHeight
+			p.text(margin, p.y, 10, false, line)
+		}
+	}
+	right := pageWidth / 2
+	p.text(right, top, 18, true, doc.Title)
+	p.text(right, top-22, 10, false, "No. "+doc.Number)
+	p.text(right, top-22-lineHeight, 10, false, "Date: "+doc.Date.Format("2006-01-02"))
+	p.y = min(p.y, top-22-lineHeight) - 2*lineHeight
+
+	// Parties side by side
+	if len(doc.Parties) > 0 {
+		width := (pageWidth - 2*margin) / float64(len(doc.Parties))
+		top, bottom := p.y, p.y
+		for i, party := range doc.Parties {
+			x, y := margin+float64(i)*width, top
+			p.text(x, y, 10, true, party.Label)
+			for _, line := range party.Lines {
+				y -= lineHeight
+				p.text(x, y, 10, false, clip(line, width-10, 10))
+			}
+			bottom = min(bottom, y)
+		}
+		p.y = bottom - 2*lineHeight
+	}
+
+	p.fields(doc.Details)
+
+	// Lines, the first column twice as wide as the others
+	if len(doc.Columns) > 0 {
+		unit := (pageWidth - 2*margin) / float64(len(doc.Columns)+1)
+		x := func(i int) float64 {
+			if i == 0 {
+				return margin
+			}
+			return margin + float64(i+1)*unit
+		}
+		width := func(i int) float64 {
+			if i == 0 {
+				return 2 * unit
+			}
+			return unit
+		}
+		header := func() {
+			for i, column := range doc.Columns {
+				p.text(x(i), p.y, 10, true, clip(column, width(i)-6, 10))
+			}
+			p.y -= 4
+			p.line(margin, p.y, pageWidth-margin, p.y)
+			p.y -= lineHeight
+		}
+		header()
+		for _, row := range doc.Lines {
+			if p.y < margin+lineHeight {
+				p.newPage()
+				header()
+			}
+			for i, value := range row {
+				if i < len(doc.Columns) {
+					p.text(x(i), p.y, 10, false, clip(value, width(i)-6, 10))
+				}
+			}
+			p.y -= lineHeight
+		}
+		p.y -= lineHeight
+	}
+
+	p.fields(doc.Totals)
+	if doc.Notes != "" {
+		for _, line := range strings.Split(doc.Notes, "\n") {
+			p.ensure()
+			p.text(margin, p.y, 10, false, line)
+			p.y -= lineHeight
+		}
+	}
+	if doc.Company != nil && doc.Company.InvoiceFooter != "" {
+		p.text(margin, margin/2, 8, false, doc.Company.InvoiceFooter)
+	}
+	return p.bytes()
+}
+
+// pdfPages collects the content streams of the pages being laid out.
+type pdfPages struct {
+	pages []*bytes.Buffer
+	y     float64 // Baseline of the next line on the current page
+}
+
+func (p *pdfPages) newPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pageHeight - margin
+}
+
+// ensure starts a new page if the current one is full.
+func (p *pdfPages) ensure() {
+	if p.y < margin {
+		p.newPage()
+	}
+}
+
+func (p *pdfPages) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.pages[len(p.pages)-1], "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+func (p *pdfPages) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(p.pages[len(p.pages)-1], "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// fields prints labelled values, one per line.
+func (p *pdfPages) fields(fields []models.DocumentField) {
+	for _, field := range fields {
+		p.ensure()
+		p.text(margin, p.y, 10, true, field.Label)
+		p.text(margin+140, p.y, 10, false, field.Value)
+		p.y -= lineHeight
+	}
+	if len(fields) > 0 {
+		p.y -= lineHeight
+	}
+}
+
+// bytes assembles the PDF file: catalog, page tree, fonts, then each page and its content.
+func (p *pdfPages) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range p.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfString encodes text as a PDF string literal in WinAnsiEncoding.
+func pdfString(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r < 32:
+			out.WriteByte(' ')
+		case r < 127:
+			out.WriteRune(r)
+		case r >= 160 && r < 256:
+			fmt.Fprintf(&out, "\\%03o", r)
+		default:
+			out.WriteByte('?')
+		}
+	}
+	return out.String()
+}
+
+// clip shortens text that would be wider than width, estimating Helvetica's average
+// character width as half the font size.
+func clip(s string, width, size float64) string {
+	limit := int(width / (size * 0.5))
+	runes := []rune(s)
+	if len(runes) <= limit || limit < 2 {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
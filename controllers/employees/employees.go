@@ -0,0 +1,146 @@
+// Package employees keeps the HR profiles of employees: their designation, salary grade,
+// department, hire date and the employee they report to. A profile may be linked to the user
+// account the employee signs in with, and attendance and leave are only accepted for users
+// that have one.
+package employees
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"erp/controllers/validation"
+	"erp/models"
+)
+
+// SearchLimit is the most employees a search returns.
+const SearchLimit = 20
+
+// Service manages employee profiles.
+type Service struct {
+	Store models.EmployeeStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates an employee service backed by store.
+func NewService(store models.EmployeeStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// validate trims and checks a profile, returning a *models.ValidationError that lists every
+// invalid field.
+func (s *Service) validate(e *models.Employee) error {
+	e.Name = strings.TrimSpace(e.Name)
+	e.Email = strings.ToLower(strings.TrimSpace(e.Email))
+	e.Designation = strings.TrimSpace(e.Designation)
+	e.SalaryGrade = strings.TrimSpace(e.SalaryGrade)
+	e.Department = strings.TrimSpace(e.Department)
+
+	var c validation.Checker
+	c.Required("name", e.Name)
+	c.Required("email", e.Email)
+	c.Email("email", e.Email)
+	c.Required("designation", e.Designation)
+	c.Date("hire_date", e.HireDate)
+	c.Check(!e.HireDate.After(s.Now()), "hire_date", "cannot be in the future")
+	if e.UserID != nil {
+		c.ID("user_id", *e.UserID)
+	}
+	if e.ManagerID != nil {
+		c.ID("manager_id", *e.ManagerID)
+	}
+	return c.Err()
+}
+
+// Create validates and records a profile.
+//
+// Returns:
+//   - error: A validation error if a field is missing or invalid or the user or manager does
+//     not exist, a conflict if the user or email already has a profile, or the store error.
+func (s *Service) Create(ctx context.Context, e *models.Employee) error {
+	if err := s.validate(e); err != nil {
+		return err
+	}
+	now := s.Now()
+	e.CreatedAt, e.UpdatedAt = now, now
+	return s.Store.CreateEmployee(ctx, e)
+}
+
+// Get returns a profile.
+func (s *Service) Get(ctx context.Context, id int) (*models.Employee, error) {
+	return s.Store.GetEmployee(ctx, id)
+}
+
+// Update validates and changes a profile. The manager cannot be the employee or anyone who
+// reports to them, directly or through others.
+//
+// Returns:
+//   - error: The errors of Create, a not found error if the employee does not exist, or a
+//     validation error if the manager reports to the employee.
+func (s *Service) Update(ctx context.Context, e *models.Employee) error {
+	if err := s.validate(e); err != nil {
+		return err
+	}
+	current, err := s.Store.GetEmployee(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+	if e.ManagerID != nil {
+		if err := s.checkManager(ctx, e.ID, *e.ManagerID); err != nil {
+			return err
+		}
+	}
+	e.CreatedAt, e.UpdatedAt = current.CreatedAt, s.Now()
+	return s.Store.UpdateEmployee(ctx, e)
+}
+
+// checkManager walks up from managerID and returns a validation error if it reaches the
+// employee.
+func (s *Service) checkManager(ctx context.Context, id, managerID int) error {
+	seen := map[int]bool{}
+	for above := managerID; !seen[above]; {
+		if above == id {
+			if above == managerID {
+				return models.Invalid("employee %d cannot report to themselves", id)
+			}
+			return models.Invalid("employee %d reports to employee %d", managerID, id)
+		}
+		seen[above] = true
+		manager, err := s.Store.GetEmployee(ctx, above)
+		if err != nil {
+			if above == managerID && errors.Is(err, models.ErrNotFound) {
+				return models.Invalid("manager %d does not exist", managerID)
+			}
+			return err
+		}
+		if manager.ManagerID == nil {
+			break
+		}
+		above = *manager.ManagerID
+	}
+	return nil
+}
+
+// Delete removes a profile. Attendance and leave stay with the user account.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	return s.Store.DeleteEmployee(ctx, id)
+}
+
+// List returns a page of the employees matching the query.
+func (s *Service) List(ctx context.Context, query models.ListQuery) ([]models.Employee, int, error) {
+	return s.Store.ListEmployees(ctx, query)
+}
+
+// Search returns the employees whose name, email or designation contains text.
+//
+// Returns:
+//   - []models.Employee: Up to SearchLimit employees, ordered by name.
+//   - error: A validation error if text is shorter than two characters, or the store error.
+func (s *Service) Search(ctx context.Context, text string) ([]models.Employee, error) {
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) < 2 {
+		return nil, models.Invalid("search text must have at least 2 characters")
+	}
+	return s.Store.SearchEmployees(ctx, text, SearchLimit)
+}
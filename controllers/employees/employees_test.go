@@ -0,0 +1,154 @@
+package employees
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryEmployeeStore keeps profiles in a map.
+type memoryEmployeeStore struct {
+	employees map[int]models.Employee
+	searched  string
+}
+
+func (m *memoryEmployeeStore) CreateEmployee(ctx context.Context, e *models.Employee) error {
+	e.ID = len(m.employees) + 1
+	m.employees[e.ID] = *e
+	return nil
+}
+
+func (m *memoryEmployeeStore) GetEmployee(ctx context.Context, id int) (*models.Employee, error) {
+	e, ok := m.employees[id]
+	if !ok {
+		return nil, models.NotFound("employee %d not found", id)
+	}
+	return &e, nil
+}
+
+func (m *memoryEmployeeStore) UpdateEmployee(ctx context.Context, e *models.Employee) error {
+	m.employees[e.ID] = *e
+	return nil
+}
+
+func (m *memoryEmployeeStore) DeleteEmployee(ctx context.Context, id int) error {
+	delete(m.employees, id)
+	return nil
+}
+
+func (m *memoryEmployeeStore) ListEmployees(ctx context.Context, query models.ListQuery) ([]models.Employee, int, error) {
+	return nil, 0, nil
+}
+
+func (m *memoryEmployeeStore) SearchEmployees(ctx context.Context, text string, limit int) ([]models.Employee, error) {
+	m.searched = text
+	return []models.Employee{}, nil
+}
+
+var today = time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+
+func newService() (*Service, *memoryEmployeeStore) {
+	store := &memoryEmployeeStore{employees: map[int]models.Employee{}}
+	service := NewService(store)
+	service.Now = func() time.Time { return today }
+	return service, store
+}
+
+func employee(name string, managerID *int) models.Employee {
+	return models.Employee{Name: name, Email: name + "@example.com", Designation: "Clerk",
+		HireDate: today.AddDate(-1, 0, 0), ManagerID: managerID}
+}
+
+func TestCreateTrimsAndStamps(t *testing.T) {
+	service, store := newService()
+
+	e := models.Employee{Name: " Alice ", Email: " Alice@Example.com", Designation: "Accountant ", Department: " Finance",
+		HireDate: today}
+	require.NoError(t, service.Create(context.Background(), &e))
+	saved := store.employees[e.ID]
+	assert.Equal(t, "Alice", saved.Name)
+	assert.Equal(t, "alice@example.com", saved.Email)
+	assert.Equal(t, "Finance", saved.Department)
+	assert.Equal(t, today, saved.CreatedAt)
+}
+
+func TestCreateRejects(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newService()
+	zero := 0
+
+	for name, e := range map[string]models.Employee{
+		"no name":        {Email: "a@example.com", Designation: "Clerk", HireDate: today},
+		"no email":       {Name: "A", Designation: "Clerk", HireDate: today},
+		"bad email":      {Name: "A", Email: "not an address", Designation: "Clerk", HireDate: today},
+		"no designation": {Name: "A", Email: "a@example.com", HireDate: today},
+		"no hire date":   {Name: "A", Email: "a@example.com", Designation: "Clerk"},
+		"future hire":    {Name: "A", Email: "a@example.com", Designation: "Clerk", HireDate: today.AddDate(0, 0, 1)},
+		"bad user":       {Name: "A", Email: "a@example.com", Designation: "Clerk", HireDate: today, UserID: &zero},
+	} {
+		assert.True(t, errors.Is(service.Create(ctx, &e), models.ErrValidation), name)
+	}
+
+	// Every invalid field is reported at once
+	err := service.Create(ctx, &models.Employee{Email: "a@example", HireDate: today})
+	var invalid *models.ValidationError
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, []models.FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "email", Message: "must be an email address such as name@example.com"},
+		{Field: "designation", Message: "is required"},
+	}, invalid.Fields)
+}
+
+func TestUpdateRefusesReportingLoops(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newService()
+	ceo := employee("ceo", nil)
+	require.NoError(t, service.Create(ctx, &ceo))
+	head := employee("head", &ceo.ID)
+	require.NoError(t, service.Create(ctx, &head))
+	clerk := employee("clerk", &head.ID)
+	require.NoError(t, service.Create(ctx, &clerk))
+
+	ceo.ManagerID = &clerk.ID
+	err := service.Update(ctx, &ceo)
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	assert.EqualError(t, err, "employee 3 reports to employee 1")
+
+	ceo.ManagerID = &ceo.ID
+	assert.EqualError(t, service.Update(ctx, &ceo), "employee 1 cannot report to themselves")
+
+	missing := 9
+	clerk.ManagerID = &missing
+	assert.EqualError(t, service.Update(ctx, &clerk), "manager 9 does not exist")
+
+	clerk.ManagerID = &ceo.ID
+	clerk.Designation = "Senior Clerk"
+	require.NoError(t, service.Update(ctx, &clerk))
+	assert.Equal(t, today, clerk.CreatedAt)
+}
+
+func TestUpdateMissingEmployee(t *testing.T) {
+	service, _ := newService()
+	e := employee("ghost", nil)
+	e.ID = 4
+	assert.True(t, errors.Is(service.Update(context.Background(), &e), models.ErrNotFound))
+}
+
+func TestSearchNeedsTwoCharacters(t *testing.T) {
+	ctx := context.Background()
+	service, store := newService()
+
+	_, err := service.Search(ctx, " a ")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+
+	_, err = service.Search(ctx, " al ")
+	require.NoError(t, err)
+	assert.Equal(t, "al", store.searched)
+}
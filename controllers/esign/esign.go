@@ -0,0 +1,221 @@
+// Package esign lets customers accept documents through signed public links. Staff create
+// a signature request for a document and send its link; the customer opens the link, sees
+// a summary of the document and accepts it with their name and a signature image.
+package esign
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/config"
+	"erp/controllers/storage"
+	"erp/models"
+)
+
+// DocumentTypes lists the documents that can be sent for acceptance.
+var DocumentTypes = map[string]bool{
+	"invoice": true,
+}
+
+// imageTypes maps the accepted signature image content types to file extensions.
+var imageTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+}
+
+// errInvalidLink is returned for links that were not issued by this server. It reads like
+// a missing record so a forged link reveals nothing.
+var errInvalidLink = models.NotFound("signing link not found")
+
+// Service creates signature requests and accepts them through their links.
+type Service struct {
+	Store   models.SignatureStore
+	Storage storage.Storage // Keeps the signature images
+	Config  config.SignatureConfig
+
+	secret []byte
+}
+
+// NewService creates a signature service. Without a configured secret a random one is
+// used, so links stop working when the server restarts.
+func NewService(store models.SignatureStore, files storage.Storage, cfg config.SignatureConfig) *Service {
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		log.Println("esign: SIGNATURE_SECRET is not set; signing links will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	return &Service{Store: store, Storage: files, Config: cfg, secret: secret}
+}
+
+// Request creates a signature request for a document and returns it with its link.
+//
+// Parameters:
+//   - documentType: The kind of document, one of DocumentTypes.
+//   - documentID: The document's ID.
+//   - owner: Email of the user sending the request, who is notified when it is accepted.
+//
+// Returns:
+//   - *models.SignatureRequest: The request, including the link to send to the customer.
+//   - error: A validation error for an unsupported type, models.ErrNotFound if the document
+//     does not exist, or the store's error.
+func (s *Service) Request(ctx context.Context, documentType string, documentID int, owner string) (*models.SignatureRequest, error) {
+	if !DocumentTypes[documentType] {
+		return nil, models.Invalid("documents of type %q cannot be signed", documentType)
+	}
+	now := time.Now()
+	request := &models.SignatureRequest{
+		DocumentType: documentType,
+		DocumentID:   documentID,
+		Owner:        owner,
+		Status:       models.SignaturePending,
+		ExpiresAt:    now.Add(s.Config.LinkTTL).Truncate(time.Second),
+		CreatedAt:    now,
+	}
+	if err := s.Store.CreateSignatureRequest(ctx, request); err != nil {
+		return nil, err
+	}
+	request.Link = strings.TrimRight(s.Config.BaseURL, "/") + "/public/signatures/" + s.Token(request)
+	return request, nil
+}
+
+// Get returns a signature request with the URL of its signature image.
+func (s *Service) Get(ctx context.Context, id int) (*models.SignatureRequest, error) {
+	request, err := s.Store.GetSignatureRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.fillURL(request)
+	return request, nil
+}
+
+// List returns the signature requests for a document.
+func (s *Service) List(ctx context.Context, documentType string, documentID int) ([]models.SignatureRequest, error) {
+	requests, err := s.Store.ListSignatureRequests(ctx, documentType, documentID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range requests {
+		s.fillURL(&requests[i])
+	}
+	return requests, nil
+}
+
+// Open returns the request and the document summary behind a link, for the signing page.
+// Expired and accepted requests are returned too, so the page can say so.
+func (s *Service) Open(ctx context.Context, token string) (*models.SignatureRequest, *models.SignableDocument, error) {
+	request, err := s.verify(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	document, err := s.Store.GetSignableDocument(ctx, request.DocumentType, request.DocumentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return request, document, nil
+}
+
+// Signer describes who accepted a document and from where.
+type Signer struct {
+	Name         string
+	IP           string
+	ForwardedFor string
+	Image        []byte // PNG or JPEG
+}
+
+// Accept accepts the document behind a link. The signature image is stored first and
+// removed again if the request cannot be accepted.
+//
+// Returns:
+//   - *models.SignatureRequest: The accepted request.
+//   - error: models.ErrNotFound for a link that was not issued here, a validation error
+//     for a missing name or an unsupported image, models.ErrSignatureLinkExpired or
+//     models.ErrAlreadySigned, or the store's error.
+func (s *Service) Accept(ctx context.Context, token string, signer Signer) (*models.SignatureRequest, error) {
+	request, err := s.verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	signer.Name = strings.TrimSpace(signer.Name)
+	if signer.Name == "" {
+		return nil, models.Invalid("signer_name is required")
+	}
+	contentType := http.DetectContentType(signer.Image)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return nil, models.Invalid("the signature must be a PNG or JPEG image")
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	request.SignatureKey = fmt.Sprintf("signatures/%d/%s.%s", request.ID, hex.EncodeToString(suffix), ext)
+	if err := s.Storage.Put(request.SignatureKey, signer.Image, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store signature image: %w", err)
+	}
+
+	request.SignerName = signer.Name
+	request.SignerIP = signer.IP
+	request.ForwardedFor = signer.ForwardedFor
+	if err := s.Store.AcceptSignatureRequest(ctx, request, time.Now()); err != nil {
+		s.Storage.Delete(request.SignatureKey)
+		return nil, err
+	}
+	s.fillURL(request)
+	return request, nil
+}
+
+// Token returns the signed token of a request's link: its ID and expiry, signed with the
+// service's secret.
+func (s *Service) Token(request *models.SignatureRequest) string {
+	payload := fmt.Sprintf("%d.%d", request.ID, request.ExpiresAt.Unix())
+	return payload + "." + s.sign(payload)
+}
+
+// verify checks a token's signature and returns its request.
+func (s *Service) verify(ctx context.Context, token string) (*models.SignatureRequest, error) {
+	cut := strings.LastIndexByte(token, '.')
+	if cut < 0 || !hmac.Equal([]byte(token[cut+1:]), []byte(s.sign(token[:cut]))) {
+		return nil, errInvalidLink
+	}
+	parts := strings.SplitN(token[:cut], ".", 2)
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) != 2 {
+		return nil, errInvalidLink
+	}
+	request, err := s.Store.GetSignatureRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if strconv.FormatInt(request.ExpiresAt.Unix(), 10) != parts[1] {
+		return nil, errInvalidLink
+	}
+	return request, nil
+}
+
+// sign returns the URL-safe HMAC-SHA256 of payload.
+func (s *Service) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// fillURL sets the public URL of a request's signature image, if it has one.
+func (s *Service) fillURL(request *models.SignatureRequest) {
+	if request.SignatureKey != "" {
+		request.SignatureURL = s.Storage.URL(request.SignatureKey)
+	}
+}
@@ -0,0 +1,157 @@
+package esign
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngImage is the PNG signature, enough for content type detection.
+var pngImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// memorySignatureStore keeps signature requests in memory.
+type memorySignatureStore struct {
+	requests map[int]*models.SignatureRequest
+	fail     error // Returned by AcceptSignatureRequest when set
+}
+
+func newMemorySignatureStore() *memorySignatureStore {
+	return &memorySignatureStore{requests: make(map[int]*models.SignatureRequest)}
+}
+
+func (m *memorySignatureStore) CreateSignatureRequest(ctx context.Context, request *models.SignatureRequest) error {
+	request.ID = len(m.requests) + 1
+	stored := *request
+	m.requests[request.ID] = &stored
+	return nil
+}
+
+func (m *memorySignatureStore) GetSignatureRequest(ctx context.Context, id int) (*models.SignatureRequest, error) {
+	request, ok := m.requests[id]
+	if !ok {
+		return nil, models.NotFound("signature request %d not found", id)
+	}
+	copied := *request
+	return &copied, nil
+}
+
+func (m *memorySignatureStore) ListSignatureRequests(ctx context.Context, documentType string, documentID int) ([]models.SignatureRequest, error) {
+	return nil, nil
+}
+
+func (m *memorySignatureStore) GetSignableDocument(ctx context.Context, documentType string, documentID int) (*models.SignableDocument, error) {
+	return &models.SignableDocument{Type: documentType, ID: documentID, CustomerName: "Acme", Amount: 120, Status: "posted"}, nil
+}
+
+func (m *memorySignatureStore) AcceptSignatureRequest(ctx context.Context, request *models.SignatureRequest, now time.Time) error {
+	if m.fail != nil {
+		return m.fail
+	}
+	if m.requests[request.ID].Status != models.SignaturePending {
+		return models.ErrAlreadySigned
+	}
+	request.Status = models.SignatureAccepted
+	request.SignedAt = &now
+	stored := *request
+	m.requests[request.ID] = &stored
+	return nil
+}
+
+func newTestService() (*Service, *memorySignatureStore, *storage.MemoryStorage) {
+	store := newMemorySignatureStore()
+	files := storage.NewMemoryStorage()
+	cfg := config.SignatureConfig{Secret: "test-secret", BaseURL: "https://erp.example.com/", LinkTTL: time.Hour, MaxImageSize: 1 << 10}
+	return NewService(store, files, cfg), store, files
+}
+
+func TestRequestLinkOpensDocument(t *testing.T) {
+	ctx := context.Background()
+	service, _, _ := newTestService()
+	request, err := service.Request(ctx, "invoice", 7, "owner@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.SignaturePending, request.Status)
+	require.True(t, strings.HasPrefix(request.Link, "https://erp.example.com/public/signatures/"))
+
+	token := strings.TrimPrefix(request.Link, "https://erp.example.com/public/signatures/")
+	opened, document, err := service.Open(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, request.ID, opened.ID)
+	assert.Equal(t, 7, document.ID)
+	assert.Equal(t, "Acme", document.CustomerName)
+
+	_, err = service.Request(ctx, "quotation", 1, "owner@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestForgedLinksAreNotFound(t *testing.T) {
+	ctx := context.Background()
+	service, _, _ := newTestService()
+	request, err := service.Request(ctx, "invoice", 7, "owner@example.com")
+	require.NoError(t, err)
+	token := service.Token(request)
+
+	other, _, _ := newTestService()
+	other.secret = []byte("another-secret")
+	tampered := request.ExpiresAt.Add(time.Hour)
+	forged := []string{
+		"",
+		"garbage",
+		other.Token(request),
+		service.Token(&models.SignatureRequest{ID: request.ID, ExpiresAt: tampered}),
+		token[:len(token)-1] + "x",
+	}
+	for _, token := range forged {
+		_, _, err := service.Open(ctx, token)
+		assert.ErrorIs(t, err, models.ErrNotFound, token)
+	}
+}
+
+func TestAcceptStoresSignature(t *testing.T) {
+	ctx := context.Background()
+	service, store, files := newTestService()
+	request, err := service.Request(ctx, "invoice", 7, "owner@example.com")
+	require.NoError(t, err)
+	token := service.Token(request)
+
+	accepted, err := service.Accept(ctx, token, Signer{Name: " Jane Doe ", IP: "203.0.113.9", ForwardedFor: "198.51.100.1", Image: pngImage})
+	require.NoError(t, err)
+	assert.Equal(t, models.SignatureAccepted, accepted.Status)
+	assert.Equal(t, "Jane Doe", accepted.SignerName)
+	assert.Equal(t, "203.0.113.9", accepted.SignerIP)
+	assert.NotNil(t, accepted.SignedAt)
+	assert.True(t, strings.HasPrefix(accepted.SignatureKey, "signatures/1/"))
+	assert.True(t, strings.HasSuffix(accepted.SignatureKey, ".png"))
+	assert.Equal(t, "/files/"+accepted.SignatureKey, accepted.SignatureURL)
+	assert.Equal(t, pngImage, files.Files[accepted.SignatureKey])
+	assert.Equal(t, models.SignatureAccepted, store.requests[1].Status)
+
+	_, err = service.Accept(ctx, token, Signer{Name: "Jane Doe", Image: pngImage})
+	assert.ErrorIs(t, err, models.ErrAlreadySigned)
+	assert.Len(t, files.Files, 1, "The image of a failed acceptance is removed")
+}
+
+func TestAcceptRejectsInvalidSigners(t *testing.T) {
+	ctx := context.Background()
+	service, store, files := newTestService()
+	request, err := service.Request(ctx, "invoice", 7, "owner@example.com")
+	require.NoError(t, err)
+	token := service.Token(request)
+
+	_, err = service.Accept(ctx, token, Signer{Name: "  ", Image: pngImage})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = service.Accept(ctx, token, Signer{Name: "Jane Doe", Image: []byte("<svg></svg>")})
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	store.fail = models.ErrSignatureLinkExpired
+	_, err = service.Accept(ctx, token, Signer{Name: "Jane Doe", Image: pngImage})
+	assert.ErrorIs(t, err, models.ErrSignatureLinkExpired)
+	assert.Empty(t, files.Files)
+}
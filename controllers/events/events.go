@@ -0,0 +1,83 @@
+// Package events defines the domain events raised by the ERP and publishes them to a
+// message broker (NATS or Kafka). Events are written to the transactional outbox together
+// with the change that raised them and published by the outbox dispatcher.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"erp/controllers/outbox"
+	"erp/models"
+)
+
+// Domain event types published by the ERP.
+const (
+	InvoiceCreated    = "InvoiceCreated"
+	InvoicePosted     = "InvoicePosted"
+	StockMoved        = "StockMoved"
+	PaymentPosted     = "PaymentPosted"
+	POSSaleRecorded   = "POSSaleRecorded"
+	DocumentAccepted  = "DocumentAccepted"
+	KPIAlertTriggered = "KPIAlertTriggered"
+)
+
+// Enqueue writes a domain event to the outbox using the given transaction.
+//
+// Parameters:
+//   - q: The transaction that also performs the business change.
+//   - eventType: One of the event type constants, e.g. InvoiceCreated.
+//   - aggregateType: The kind of entity the event is about, e.g. "invoice".
+//   - aggregateID: The ID of that entity.
+//   - payload: Any JSON-serializable value describing the event.
+//
+// Returns:
+//   - error: An error if the payload cannot be serialized or the event cannot be stored.
+func Enqueue(ctx context.Context, q outbox.Queryer, eventType, aggregateType string, aggregateID int, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return outbox.Enqueue(ctx, q, outbox.KindEvent, &models.DomainEvent{
+		EventType:     eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       data,
+		OccurredAt:    time.Now(),
+	})
+}
+
+// Subscriber reacts to domain events inside the ERP, e.g. by creating notifications.
+// Subscribers must tolerate receiving the same event more than once, since a failed
+// delivery is retried.
+type Subscriber interface {
+	Handle(ctx context.Context, event *models.DomainEvent) error
+}
+
+// PublishHandler delivers outbox event messages to the configured Publisher and to the
+// in-process subscribers.
+type PublishHandler struct {
+	Publisher   Publisher
+	Subscribers []Subscriber
+}
+
+// Deliver publishes the event stored in the message. The outbox message ID is used as the
+// event ID so consumers can deduplicate redeliveries.
+func (h *PublishHandler) Deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	var event models.DomainEvent
+	if err := json.Unmarshal(msg.Payload, &event); err != nil {
+		return err
+	}
+	event.ID = msg.ID
+	if err := h.Publisher.Publish(&event); err != nil {
+		return err
+	}
+	for _, subscriber := range h.Subscribers {
+		if err := subscriber.Handle(ctx, &event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/models"
+)
+
+// KafkaRESTPublisher publishes events to Kafka through a Kafka REST proxy, keyed by
+// aggregate so all events for one entity land on the same partition in order.
+type KafkaRESTPublisher struct {
+	BaseURL     string       // Base URL of the REST proxy
+	TopicPrefix string       // Prefix prepended to the event type to form the topic
+	Client      *http.Client // Optional HTTP client; a client with a timeout is used if nil
+}
+
+// kafkaRecords is the request body accepted by the REST proxy's produce endpoint.
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string              `json:"key"`
+	Value *models.DomainEvent `json:"value"`
+}
+
+// Publish produces the event to its topic and returns an error unless the proxy accepted it.
+func (p *KafkaRESTPublisher) Publish(event *models.DomainEvent) error {
+	body, err := json.Marshal(kafkaRecords{Records: []kafkaRecord{{
+		Key:   event.AggregateType + ":" + strconv.Itoa(event.AggregateID),
+		Value: event,
+	}}})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/topics/%s", p.BaseURL, topicName(p.TopicPrefix, event))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka REST proxy returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Close is a no-op; the HTTP client holds no dedicated connection.
+func (p *KafkaRESTPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"erp/models"
+)
+
+// NATSPublisher publishes events to a NATS server using the plain-text client protocol.
+// The connection is opened lazily and re-established after a failure.
+type NATSPublisher struct {
+	URL           string // Host and port of the NATS server
+	SubjectPrefix string // Prefix prepended to the event type to form the subject
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Publish sends the event and waits for the server to acknowledge a PING, which
+// guarantees the message was received before the outbox row is marked as published.
+func (p *NATSPublisher) Publish(event *models.DomainEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.connect(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	subject := topicName(p.SubjectPrefix, event)
+	if _, err := fmt.Fprintf(p.conn, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(data), data); err != nil {
+		p.reset()
+		return err
+	}
+	if err := p.expectPong(); err != nil {
+		p.reset()
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the server.
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+	return nil
+}
+
+// connect dials the server and performs the CONNECT handshake if not already connected.
+func (p *NATSPublisher) connect() error {
+	if p.conn != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(p.URL, "nats://"), 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("connect to NATS: %w", err)
+	}
+	p.conn = conn
+	p.reader = bufio.NewReader(conn)
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	line, err := p.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO") {
+		p.reset()
+		return fmt.Errorf("unexpected NATS greeting: %q", line)
+	}
+	if _, err := fmt.Fprint(conn, "CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"erp\"}\r\n"); err != nil {
+		p.reset()
+		return err
+	}
+	return nil
+}
+
+// expectPong reads server lines until a PONG arrives, answering server PINGs on the way.
+func (p *NATSPublisher) expectPong() error {
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			return nil
+		case strings.HasPrefix(line, "PING"):
+			fmt.Fprint(p.conn, "PONG\r\n")
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", strings.TrimSpace(line))
+		}
+	}
+}
+
+// reset drops the current connection so the next publish reconnects.
+func (p *NATSPublisher) reset() {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.conn = nil
+	p.reader = nil
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"fmt"
+	"log"
+
+	"erp/config"
+	"erp/models"
+)
+
+// Publisher delivers domain events to a message broker. Implementations must return an
+// error unless the broker has accepted the event, so the relay can retry it.
+type Publisher interface {
+	Publish(event *models.DomainEvent) error
+	Close() error
+}
+
+// NewPublisher creates the publisher selected by the configuration.
+//
+// Parameters:
+//   - cfg: The events section of the application configuration.
+//
+// Returns:
+//   - Publisher: The configured publisher.
+//   - error: An error if the broker is unknown.
+func NewPublisher(cfg config.EventsConfig) (Publisher, error) {
+	switch cfg.Broker {
+	case "log", "":
+		return &LogPublisher{}, nil
+	case "nats":
+		return &NATSPublisher{URL: cfg.NATSURL, SubjectPrefix: cfg.SubjectPrefix}, nil
+	case "kafka":
+		return &KafkaRESTPublisher{BaseURL: cfg.KafkaRESTURL, TopicPrefix: cfg.SubjectPrefix}, nil
+	default:
+		return nil, fmt.Errorf("unknown event broker %q", cfg.Broker)
+	}
+}
+
+// LogPublisher writes events to the application log. It is used in development when no
+// broker is configured.
+type LogPublisher struct{}
+
+// Publish logs the event.
+func (p *LogPublisher) Publish(event *models.DomainEvent) error {
+	log.Printf("event %s %s/%d: %s", event.EventType, event.AggregateType, event.AggregateID, event.Payload)
+	return nil
+}
+
+// Close is a no-op for the log publisher.
+func (p *LogPublisher) Close() error {
+	return nil
+}
+
+// topicName builds the subject or topic name for an event, e.g. "erp.InvoiceCreated".
+func topicName(prefix string, event *models.DomainEvent) string {
+	if prefix == "" {
+		return event.EventType
+	}
+	return prefix + "." + event.EventType
+}
@@ -0,0 +1,299 @@
+// Package expenses handles employee expense claims and the policies they are checked
+// against. Every category can have a soft limit that flags items for the approver, a hard
+// limit and a receipt threshold that block reimbursement, and a per-diem rate for items
+// claimed by the day. Claims are checked when they are submitted; a claim with hard
+// violations is only reimbursed after a user with an override role has accepted it.
+package expenses
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Service checks, reviews and reimburses expense claims.
+type Service struct {
+	Store         models.ExpenseStore
+	Approvers     []string         // Roles that review and reimburse claims
+	OverrideRoles []string         // Roles that may accept claims with hard violations
+	Now           func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates an expense service.
+func NewService(store models.ExpenseStore, approvers, overrideRoles []string) *Service {
+	return &Service{Store: store, Approvers: approvers, OverrideRoles: overrideRoles, Now: time.Now}
+}
+
+// Policies returns the policy of every expense category.
+func (s *Service) Policies(ctx context.Context) ([]models.ExpensePolicy, error) {
+	return s.Store.GetExpensePolicies(ctx)
+}
+
+// SetPolicy creates or replaces the policy of a category. It applies to claims submitted
+// from now on.
+//
+// Parameters:
+//   - policy: The category with its limits; UpdatedBy and UpdatedAt are set.
+//   - actor: Email of the admin making the change.
+//
+// Returns:
+//   - error: A validation error if the category is missing, an amount is negative or the
+//     soft limit is above the hard limit, or the store's error.
+func (s *Service) SetPolicy(ctx context.Context, policy *models.ExpensePolicy, actor string) error {
+	policy.Category = strings.TrimSpace(policy.Category)
+	switch {
+	case policy.Category == "":
+		return models.Invalid("category is required")
+	case policy.Limit < 0 || policy.HardLimit < 0 || policy.ReceiptThreshold < 0 || policy.PerDiemRate < 0:
+		return models.Invalid("amounts must not be negative")
+	case policy.Limit > 0 && policy.HardLimit > 0 && policy.Limit > policy.HardLimit:
+		return models.Invalid("limit must not be above hard_limit")
+	}
+	policy.UpdatedBy, policy.UpdatedAt = actor, s.Now()
+	return s.Store.SetExpensePolicy(ctx, policy)
+}
+
+// Submit prices the claim's mileage and per-diem items from the rate tables, checks the
+// claim against the policies and saves it for review. Violations do not prevent
+// submission; they are recorded on the claim for the approver.
+//
+// Parameters:
+//   - claim: The claim with its items; its ID, status, total and violations are set.
+//   - employee: Email of the claimant.
+//
+// Returns:
+//   - error: A validation error if the claim has no items, an item is incomplete or has no
+//     rate, or the store's error.
+func (s *Service) Submit(ctx context.Context, claim *models.ExpenseClaim, employee string) error {
+	if len(claim.Items) == 0 {
+		return models.Invalid("a claim needs at least one item")
+	}
+	var rates *rateTables
+	total := 0.0
+	for i := range claim.Items {
+		item := &claim.Items[i]
+		item.Category = strings.TrimSpace(item.Category)
+		if item.Kind != models.ExpenseItemStandard {
+			if rates == nil {
+				var err error
+				if rates, err = s.loadRates(ctx); err != nil {
+					return err
+				}
+			}
+			if err := rates.price(i+1, item); err != nil {
+				return err
+			}
+		} else {
+			item.Rate = 0
+		}
+		switch {
+		case item.Category == "":
+			return models.Invalid("item %d: category is required", i+1)
+		case item.Amount <= 0:
+			return models.Invalid("item %d: amount must be positive", i+1)
+		case item.Date.IsZero():
+			return models.Invalid("item %d: date is required", i+1)
+		case item.Date.After(s.Now()):
+			return models.Invalid("item %d: date is in the future", i+1)
+		case item.Days < 0:
+			return models.Invalid("item %d: days must not be negative", i+1)
+		}
+		total += item.Amount
+	}
+
+	policies, err := s.Store.GetExpensePolicies(ctx)
+	if err != nil {
+		return err
+	}
+	claim.Employee = employee
+	claim.Status = models.ExpenseSubmitted
+	claim.Total = roundCents(total)
+	claim.Violations = Check(policies, claim.Items)
+	claim.SubmittedAt = s.Now()
+	claim.ReviewedBy, claim.ReviewedAt, claim.ReviewNote = nil, nil, ""
+	claim.OverriddenBy, claim.OverriddenAt, claim.OverrideReason = nil, nil, ""
+	claim.ReimbursedBy, claim.ReimbursedAt = nil, nil
+	return s.Store.CreateExpenseClaim(ctx, claim)
+}
+
+// Check returns the policy violations of a claim's items. Items in a category without a
+// policy are flagged for the approver.
+func Check(policies []models.ExpensePolicy, items []models.ExpenseItem) []models.ExpenseViolation {
+	byCategory := map[string]models.ExpensePolicy{}
+	for _, policy := range policies {
+		byCategory[strings.ToLower(policy.Category)] = policy
+	}
+
+	violations := []models.ExpenseViolation{}
+	flag := func(item int, rule, severity, format string, args ...interface{}) {
+		violations = append(violations, models.ExpenseViolation{
+			Item: item, Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...),
+		})
+	}
+	for i, item := range items {
+		policy, ok := byCategory[strings.ToLower(item.Category)]
+		if !ok {
+			flag(i+1, "category", models.ViolationSoft, "no policy covers category %q", item.Category)
+			continue
+		}
+		if policy.HardLimit > 0 && item.Amount > policy.HardLimit {
+			flag(i+1, "hard_limit", models.ViolationHard, "%.2f is above the %s limit of %.2f",
+				item.Amount, policy.Category, policy.HardLimit)
+		} else if policy.Limit > 0 && item.Amount > policy.Limit {
+			flag(i+1, "limit", models.ViolationSoft, "%.2f is above the %s limit of %.2f",
+				item.Amount, policy.Category, policy.Limit)
+		}
+		if policy.ReceiptThreshold > 0 && item.Amount > policy.ReceiptThreshold && strings.TrimSpace(item.Receipt) == "" {
+			flag(i+1, "receipt", models.ViolationHard, "a receipt is required above %.2f", policy.ReceiptThreshold)
+		}
+		if policy.PerDiemRate > 0 && item.Days > 0 {
+			if allowance := policy.PerDiemRate * float64(item.Days); item.Amount > allowance+0.005 {
+				flag(i+1, "per_diem", models.ViolationSoft, "%.2f is above the per-diem allowance of %.2f for %d days",
+					item.Amount, allowance, item.Days)
+			}
+		}
+	}
+	return violations
+}
+
+// Claim returns a claim to its claimant or an approver.
+//
+// Returns:
+//   - *models.ExpenseClaim: The claim.
+//   - error: models.ErrNotFound if it does not exist, or models.ErrPermissionDenied if the
+//     user may not see it.
+func (s *Service) Claim(ctx context.Context, id int, actor, role string) (*models.ExpenseClaim, error) {
+	claim, err := s.Store.GetExpenseClaim(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(claim.Employee, actor) && !hasRole(s.Approvers, role) {
+		return nil, models.PermissionDenied("expense claim %d belongs to another employee", id)
+	}
+	return claim, nil
+}
+
+// Claims lists claims, newest first. An empty employee or status matches every claim.
+func (s *Service) Claims(ctx context.Context, employee, status string) ([]models.ExpenseClaim, error) {
+	switch status {
+	case "", models.ExpenseSubmitted, models.ExpenseApproved, models.ExpenseRejected, models.ExpenseReimbursed:
+	default:
+		return nil, models.Invalid("unknown status %q", status)
+	}
+	return s.Store.GetExpenseClaims(ctx, employee, status)
+}
+
+// Approve approves a submitted claim. Claimants cannot approve their own claims.
+func (s *Service) Approve(ctx context.Context, id int, actor, note string) (*models.ExpenseClaim, error) {
+	return s.review(ctx, id, actor, note, models.ExpenseApproved)
+}
+
+// Reject rejects a submitted claim with the reason given in note.
+func (s *Service) Reject(ctx context.Context, id int, actor, note string) (*models.ExpenseClaim, error) {
+	if strings.TrimSpace(note) == "" {
+		return nil, models.Invalid("a note explaining the rejection is required")
+	}
+	return s.review(ctx, id, actor, note, models.ExpenseRejected)
+}
+
+// review moves a submitted claim to status.
+func (s *Service) review(ctx context.Context, id int, actor, note, status string) (*models.ExpenseClaim, error) {
+	claim, err := s.Store.GetExpenseClaim(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if claim.Status != models.ExpenseSubmitted {
+		return nil, models.Conflict("expense claim %d is %s, not awaiting review", id, claim.Status)
+	}
+	if strings.EqualFold(claim.Employee, actor) {
+		return nil, models.PermissionDenied("claimants cannot review their own expense claims")
+	}
+	now := s.Now()
+	claim.Status, claim.ReviewedBy, claim.ReviewedAt, claim.ReviewNote = status, &actor, &now, strings.TrimSpace(note)
+	if err := s.Store.UpdateExpenseClaim(ctx, claim, models.ExpenseSubmitted); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// Override accepts the hard violations of a claim so it can be reimbursed.
+//
+// Parameters:
+//   - id: The ID of the claim.
+//   - actor: Email of the user accepting the violations.
+//   - role: The user's role, which must be one of the override roles.
+//   - reason: Why the violations are accepted.
+//
+// Returns:
+//   - *models.ExpenseClaim: The claim.
+//   - error: models.ErrPermissionDenied if the role may not override or the user is the
+//     claimant, a validation error if the reason is missing, a conflict if the claim has no
+//     hard violations, is already overridden or has been rejected or reimbursed, or the
+//     store's error.
+func (s *Service) Override(ctx context.Context, id int, actor, role, reason string) (*models.ExpenseClaim, error) {
+	if !hasRole(s.OverrideRoles, role) {
+		return nil, models.PermissionDenied("overriding expense policy violations requires the role %s",
+			strings.Join(s.OverrideRoles, " or "))
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, models.Invalid("a reason for the override is required")
+	}
+	claim, err := s.Store.GetExpenseClaim(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case claim.Status != models.ExpenseSubmitted && claim.Status != models.ExpenseApproved:
+		return nil, models.Conflict("expense claim %d is %s", id, claim.Status)
+	case !claim.HardViolations():
+		return nil, models.Conflict("expense claim %d has no hard violations", id)
+	case claim.OverriddenBy != nil:
+		return nil, models.Conflict("expense claim %d has already been overridden", id)
+	case strings.EqualFold(claim.Employee, actor):
+		return nil, models.PermissionDenied("claimants cannot override violations of their own expense claims")
+	}
+	now := s.Now()
+	claim.OverriddenBy, claim.OverriddenAt, claim.OverrideReason = &actor, &now, strings.TrimSpace(reason)
+	if err := s.Store.UpdateExpenseClaim(ctx, claim, claim.Status); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// Reimburse pays an approved claim: the expense is posted to the ledger against cash.
+//
+// Returns:
+//   - *models.ExpenseClaim: The reimbursed claim.
+//   - error: A conflict if the claim is not approved or has hard violations that have not
+//     been overridden, or the store's error.
+func (s *Service) Reimburse(ctx context.Context, id int, actor string) (*models.ExpenseClaim, error) {
+	claim, err := s.Store.GetExpenseClaim(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if claim.Status != models.ExpenseApproved {
+		return nil, models.Conflict("expense claim %d is %s, not approved", id, claim.Status)
+	}
+	if claim.Blocked() {
+		return nil, models.Conflict("expense claim %d has hard policy violations; an override is required", id)
+	}
+	now := s.Now()
+	claim.Status, claim.ReimbursedBy, claim.ReimbursedAt = models.ExpenseReimbursed, &actor, &now
+	if err := s.Store.ReimburseExpenseClaim(ctx, claim); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// hasRole reports whether role is one of roles.
+func hasRole(roles []string, role string) bool {
+	for _, allowed := range roles {
+		if strings.EqualFold(allowed, role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package expenses
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps policies and claims in memory.
+type fakeStore struct {
+	policies   []models.ExpensePolicy
+	claims     map[int]*models.ExpenseClaim
+	reimbursed []int
+	mileage    []models.MileageRate
+	perDiem    []models.PerDiemRate
+}
+
+func (f *fakeStore) GetExpensePolicies(ctx context.Context) ([]models.ExpensePolicy, error) {
+	return f.policies, nil
+}
+
+func (f *fakeStore) SetExpensePolicy(ctx context.Context, policy *models.ExpensePolicy) error {
+	f.policies = append(f.policies, *policy)
+	return nil
+}
+
+func (f *fakeStore) CreateExpenseClaim(ctx context.Context, claim *models.ExpenseClaim) error {
+	claim.ID = len(f.claims) + 1
+	stored := *claim
+	f.claims[claim.ID] = &stored
+	return nil
+}
+
+func (f *fakeStore) GetExpenseClaim(ctx context.Context, id int) (*models.ExpenseClaim, error) {
+	claim, ok := f.claims[id]
+	if !ok {
+		return nil, models.NotFound("expense claim %d not found", id)
+	}
+	copied := *claim
+	return &copied, nil
+}
+
+func (f *fakeStore) GetExpenseClaims(ctx context.Context, employee, status string) ([]models.ExpenseClaim, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) UpdateExpenseClaim(ctx context.Context, claim *models.ExpenseClaim, from string) error {
+	if f.claims[claim.ID].Status != from {
+		return models.Conflict("expense claim %d has changed", claim.ID)
+	}
+	stored := *claim
+	f.claims[claim.ID] = &stored
+	return nil
+}
+
+func (f *fakeStore) ReimburseExpenseClaim(ctx context.Context, claim *models.ExpenseClaim) error {
+	f.reimbursed = append(f.reimbursed, claim.ID)
+	return f.UpdateExpenseClaim(ctx, claim, models.ExpenseApproved)
+}
+
+var day = time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+
+func newTestService() (*Service, *fakeStore) {
+	store := &fakeStore{
+		policies: []models.ExpensePolicy{
+			{Category: "Meals", Limit: 50, HardLimit: 150, ReceiptThreshold: 25, PerDiemRate: 40},
+			{Category: "Travel", ReceiptThreshold: 100},
+		},
+		claims: map[int]*models.ExpenseClaim{},
+	}
+	service := NewService(store, []string{"Admin", "Accountant"}, []string{"Admin"})
+	service.Now = func() time.Time { return day.AddDate(0, 0, 7) }
+	return service, store
+}
+
+func TestCheck(t *testing.T) {
+	service, _ := newTestService()
+	policies, _ := service.Policies(context.Background())
+
+	violations := Check(policies, []models.ExpenseItem{
+		{Category: "meals", Amount: 20},                        // within limits, no receipt needed
+		{Category: "Meals", Amount: 60, Receipt: "r.pdf"},      // above the soft limit
+		{Category: "Meals", Amount: 200, Receipt: "r.pdf"},     // above the hard limit
+		{Category: "Meals", Amount: 30},                        // no receipt
+		{Category: "Meals", Amount: 90, Days: 2, Receipt: "r"}, // above 2 days of per diem
+		{Category: "Gifts", Amount: 10},
+	})
+
+	rules := []string{}
+	for _, violation := range violations {
+		rules = append(rules, violation.Rule+"/"+violation.Severity)
+	}
+	assert.Equal(t, []string{"limit/soft", "hard_limit/hard", "receipt/hard", "limit/soft", "per_diem/soft",
+		"category/soft"}, rules)
+	assert.Equal(t, 2, violations[0].Item)
+	assert.Equal(t, 6, violations[len(violations)-1].Item)
+}
+
+func TestSubmit(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+
+	claim := &models.ExpenseClaim{Purpose: "Client visit", Items: []models.ExpenseItem{
+		{Category: "Travel", Date: day, Amount: 120.10},
+		{Category: "Meals", Date: day, Amount: 19.95},
+	}}
+	require.NoError(t, service.Submit(ctx, claim, "jane@example.com"))
+	assert.Equal(t, models.ExpenseSubmitted, claim.Status)
+	assert.Equal(t, 140.05, claim.Total)
+	require.Len(t, claim.Violations, 1)
+	assert.Equal(t, "receipt", claim.Violations[0].Rule)
+	assert.True(t, claim.Blocked())
+	assert.Contains(t, store.claims, claim.ID)
+
+	invalid := map[string]models.ExpenseItem{
+		"no category":   {Date: day, Amount: 1},
+		"no amount":     {Category: "Meals", Date: day},
+		"no date":       {Category: "Meals", Amount: 1},
+		"future date":   {Category: "Meals", Date: day.AddDate(0, 1, 0), Amount: 1},
+		"negative days": {Category: "Meals", Date: day, Amount: 1, Days: -1},
+	}
+	for name, item := range invalid {
+		err := service.Submit(ctx, &models.ExpenseClaim{Items: []models.ExpenseItem{item}}, "jane@example.com")
+		assert.ErrorIs(t, err, models.ErrValidation, name)
+	}
+	assert.ErrorIs(t, service.Submit(ctx, &models.ExpenseClaim{}, "jane@example.com"), models.ErrValidation)
+}
+
+func TestHardViolationBlocksReimbursementUntilOverridden(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+	claim := &models.ExpenseClaim{Items: []models.ExpenseItem{{Category: "Meals", Date: day, Amount: 200, Receipt: "r.pdf"}}}
+	require.NoError(t, service.Submit(ctx, claim, "jane@example.com"))
+
+	_, err := service.Approve(ctx, claim.ID, "jane@example.com", "")
+	assert.ErrorIs(t, err, models.ErrPermissionDenied, "claimants cannot approve their own claims")
+	_, err = service.Approve(ctx, claim.ID, "accountant@example.com", "")
+	require.NoError(t, err)
+
+	_, err = service.Reimburse(ctx, claim.ID, "accountant@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.Empty(t, store.reimbursed)
+
+	_, err = service.Override(ctx, claim.ID, "accountant@example.com", "Accountant", "CEO dinner")
+	assert.ErrorIs(t, err, models.ErrPermissionDenied)
+	_, err = service.Override(ctx, claim.ID, "admin@example.com", "Admin", " ")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	overridden, err := service.Override(ctx, claim.ID, "admin@example.com", "Admin", "CEO dinner")
+	require.NoError(t, err)
+	assert.Equal(t, "admin@example.com", *overridden.OverriddenBy)
+	_, err = service.Override(ctx, claim.ID, "admin@example.com", "Admin", "again")
+	assert.ErrorIs(t, err, models.ErrConflict)
+
+	reimbursed, err := service.Reimburse(ctx, claim.ID, "accountant@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.ExpenseReimbursed, reimbursed.Status)
+	assert.Equal(t, []int{claim.ID}, store.reimbursed)
+}
+
+func TestRejectAndReview(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService()
+	claim := &models.ExpenseClaim{Items: []models.ExpenseItem{{Category: "Meals", Date: day, Amount: 10}}}
+	require.NoError(t, service.Submit(ctx, claim, "jane@example.com"))
+
+	_, err := service.Reject(ctx, claim.ID, "accountant@example.com", "")
+	assert.ErrorIs(t, err, models.ErrValidation, "a rejection needs a note")
+	rejected, err := service.Reject(ctx, claim.ID, "accountant@example.com", "Personal expense")
+	require.NoError(t, err)
+	assert.Equal(t, models.ExpenseRejected, rejected.Status)
+
+	_, err = service.Approve(ctx, claim.ID, "accountant@example.com", "")
+	assert.ErrorIs(t, err, models.ErrConflict)
+	_, err = service.Reimburse(ctx, claim.ID, "accountant@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+
+	_, err = service.Claim(ctx, claim.ID, "john@example.com", "Employee")
+	assert.ErrorIs(t, err, models.ErrPermissionDenied)
+	_, err = service.Claim(ctx, claim.ID, "JANE@example.com", "Employee")
+	assert.NoError(t, err)
+	_, err = service.Claim(ctx, claim.ID, "accountant@example.com", "Accountant")
+	assert.NoError(t, err)
+}
+
+func TestSetPolicy(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+	require.NoError(t, service.SetPolicy(ctx, &models.ExpensePolicy{Category: " Lodging ", Limit: 120, HardLimit: 300}, "admin@example.com"))
+	assert.Equal(t, "Lodging", store.policies[2].Category)
+	assert.Equal(t, "admin@example.com", store.policies[2].UpdatedBy)
+
+	assert.ErrorIs(t, service.SetPolicy(ctx, &models.ExpensePolicy{Category: "Lodging", Limit: 400, HardLimit: 300}, "admin@example.com"),
+		models.ErrValidation)
+	assert.ErrorIs(t, service.SetPolicy(ctx, &models.ExpensePolicy{Category: "Lodging", PerDiemRate: -1}, "admin@example.com"),
+		models.ErrValidation)
+	assert.ErrorIs(t, service.SetPolicy(ctx, &models.ExpensePolicy{}, "admin@example.com"), models.ErrValidation)
+}
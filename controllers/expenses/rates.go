@@ -0,0 +1,164 @@
+package expenses
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"erp/models"
+)
+
+// DefaultDestination is the per-diem destination whose rate applies to destinations without
+// a rate of their own.
+const DefaultDestination = "default"
+
+// Categories given to mileage and per-diem items submitted without one, so the category's
+// policy applies to them.
+const (
+	MileageCategory = "Mileage"
+	PerDiemCategory = "Per Diem"
+)
+
+// MileageRates returns the rate of every vehicle type.
+func (s *Service) MileageRates(ctx context.Context) ([]models.MileageRate, error) {
+	return s.Store.GetMileageRates(ctx)
+}
+
+// SetMileageRate creates or replaces the rate of a vehicle type. It applies to claims
+// submitted from now on.
+//
+// Returns:
+//   - error: A validation error if the vehicle type is missing or the rate is not positive,
+//     or the store's error.
+func (s *Service) SetMileageRate(ctx context.Context, rate *models.MileageRate, actor string) error {
+	rate.VehicleType = strings.TrimSpace(rate.VehicleType)
+	switch {
+	case rate.VehicleType == "":
+		return models.Invalid("vehicle_type is required")
+	case rate.Rate <= 0:
+		return models.Invalid("rate must be positive")
+	}
+	rate.UpdatedBy, rate.UpdatedAt = actor, s.Now()
+	return s.Store.SetMileageRate(ctx, rate)
+}
+
+// DeleteMileageRate removes the rate of a vehicle type; mileage in it can no longer be claimed.
+func (s *Service) DeleteMileageRate(ctx context.Context, vehicleType string) error {
+	return s.Store.DeleteMileageRate(ctx, vehicleType)
+}
+
+// PerDiemRates returns the rate of every destination.
+func (s *Service) PerDiemRates(ctx context.Context) ([]models.PerDiemRate, error) {
+	return s.Store.GetPerDiemRates(ctx)
+}
+
+// SetPerDiemRate creates or replaces the rate of a destination. It applies to claims
+// submitted from now on.
+//
+// Returns:
+//   - error: A validation error if the destination is missing, a rate is negative or not
+//     positive, or a long-stay rate is given without the days it starts after, or the
+//     store's error.
+func (s *Service) SetPerDiemRate(ctx context.Context, rate *models.PerDiemRate, actor string) error {
+	rate.Destination = strings.TrimSpace(rate.Destination)
+	switch {
+	case rate.Destination == "":
+		return models.Invalid("destination is required")
+	case rate.DailyRate <= 0:
+		return models.Invalid("daily_rate must be positive")
+	case rate.LongStayAfter < 0 || rate.LongStayRate < 0:
+		return models.Invalid("long_stay_rate and long_stay_after must not be negative")
+	case (rate.LongStayAfter > 0) != (rate.LongStayRate > 0):
+		return models.Invalid("long_stay_rate and long_stay_after are set together")
+	}
+	rate.UpdatedBy, rate.UpdatedAt = actor, s.Now()
+	return s.Store.SetPerDiemRate(ctx, rate)
+}
+
+// DeletePerDiemRate removes the rate of a destination; it falls back to the default rate.
+func (s *Service) DeletePerDiemRate(ctx context.Context, destination string) error {
+	return s.Store.DeletePerDiemRate(ctx, destination)
+}
+
+// rateTables holds the rates items are priced with, loaded when a claim needs them.
+type rateTables struct {
+	mileage map[string]models.MileageRate
+	perDiem map[string]models.PerDiemRate
+}
+
+// loadRates reads the rate tables.
+func (s *Service) loadRates(ctx context.Context) (*rateTables, error) {
+	mileage, err := s.Store.GetMileageRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	perDiem, err := s.Store.GetPerDiemRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables := &rateTables{mileage: map[string]models.MileageRate{}, perDiem: map[string]models.PerDiemRate{}}
+	for _, rate := range mileage {
+		tables.mileage[strings.ToLower(rate.VehicleType)] = rate
+	}
+	for _, rate := range perDiem {
+		tables.perDiem[strings.ToLower(rate.Destination)] = rate
+	}
+	return tables, nil
+}
+
+// price computes the amount of a mileage or per-diem item from the rate tables. The item
+// number is used in error messages.
+func (t *rateTables) price(n int, item *models.ExpenseItem) error {
+	switch item.Kind {
+	case models.ExpenseItemMileage:
+		item.VehicleType = strings.TrimSpace(item.VehicleType)
+		rate, ok := t.mileage[strings.ToLower(item.VehicleType)]
+		switch {
+		case item.Distance <= 0:
+			return models.Invalid("item %d: distance must be positive", n)
+		case !ok:
+			return models.Invalid("item %d: no mileage rate for vehicle type %q", n, item.VehicleType)
+		}
+		if item.Category == "" {
+			item.Category = MileageCategory
+		}
+		item.Rate = rate.Rate
+		item.Amount = roundCents(item.Distance * rate.Rate)
+	case models.ExpenseItemPerDiem:
+		item.Destination = strings.TrimSpace(item.Destination)
+		rate, ok := t.perDiem[strings.ToLower(item.Destination)]
+		if !ok {
+			rate, ok = t.perDiem[DefaultDestination]
+		}
+		switch {
+		case item.Destination == "":
+			return models.Invalid("item %d: destination is required", n)
+		case item.Days <= 0:
+			return models.Invalid("item %d: days must be positive", n)
+		case !ok:
+			return models.Invalid("item %d: no per-diem rate for %q and no default rate", n, item.Destination)
+		}
+		if item.Category == "" {
+			item.Category = PerDiemCategory
+		}
+		item.Rate = rate.DailyRate
+		item.Amount = PerDiem(rate, item.Days)
+	default:
+		return models.Invalid("item %d: unknown kind %q", n, item.Kind)
+	}
+	return nil
+}
+
+// PerDiem returns the allowance for a trip of days at a rate: the daily rate up to the
+// long-stay threshold and the long-stay rate after it.
+func PerDiem(rate models.PerDiemRate, days int) float64 {
+	if rate.LongStayAfter <= 0 || days <= rate.LongStayAfter {
+		return roundCents(float64(days) * rate.DailyRate)
+	}
+	return roundCents(float64(rate.LongStayAfter)*rate.DailyRate + float64(days-rate.LongStayAfter)*rate.LongStayRate)
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,106 @@
+package expenses
+
+import (
+	"context"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *fakeStore) GetMileageRates(ctx context.Context) ([]models.MileageRate, error) {
+	return f.mileage, nil
+}
+
+func (f *fakeStore) SetMileageRate(ctx context.Context, rate *models.MileageRate) error {
+	f.mileage = append(f.mileage, *rate)
+	return nil
+}
+
+func (f *fakeStore) DeleteMileageRate(ctx context.Context, vehicleType string) error { return nil }
+
+func (f *fakeStore) GetPerDiemRates(ctx context.Context) ([]models.PerDiemRate, error) {
+	return f.perDiem, nil
+}
+
+func (f *fakeStore) SetPerDiemRate(ctx context.Context, rate *models.PerDiemRate) error {
+	f.perDiem = append(f.perDiem, *rate)
+	return nil
+}
+
+func (f *fakeStore) DeletePerDiemRate(ctx context.Context, destination string) error { return nil }
+
+func TestPerDiem(t *testing.T) {
+	rate := models.PerDiemRate{DailyRate: 80, LongStayRate: 50, LongStayAfter: 5}
+	assert.Equal(t, 240.0, PerDiem(rate, 3))
+	assert.Equal(t, 400.0, PerDiem(rate, 5))
+	assert.Equal(t, 500.0, PerDiem(rate, 7))
+	assert.Equal(t, 560.0, PerDiem(models.PerDiemRate{DailyRate: 80}, 7))
+}
+
+func TestSubmitPricesMileageAndPerDiem(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+	store.mileage = []models.MileageRate{{VehicleType: "Car", Rate: 0.42}, {VehicleType: "Motorcycle", Rate: 0.2}}
+	store.perDiem = []models.PerDiemRate{
+		{Destination: "London", DailyRate: 90, LongStayRate: 60, LongStayAfter: 3},
+		{Destination: DefaultDestination, DailyRate: 40},
+	}
+
+	claim := &models.ExpenseClaim{Items: []models.ExpenseItem{
+		{Kind: models.ExpenseItemMileage, Date: day, VehicleType: "car", Distance: 123.5, Amount: 999},
+		{Kind: models.ExpenseItemPerDiem, Date: day, Destination: "london", Days: 4},
+		{Kind: models.ExpenseItemPerDiem, Category: "Travel", Date: day, Destination: "Leeds", Days: 2},
+	}}
+	require.NoError(t, service.Submit(ctx, claim, "jane@example.com"))
+
+	assert.Equal(t, MileageCategory, claim.Items[0].Category)
+	assert.Equal(t, 0.42, claim.Items[0].Rate)
+	assert.Equal(t, 51.87, claim.Items[0].Amount, "the claimed amount is replaced by the computed one")
+	assert.Equal(t, PerDiemCategory, claim.Items[1].Category)
+	assert.Equal(t, 330.0, claim.Items[1].Amount)
+	assert.Equal(t, "Travel", claim.Items[2].Category)
+	assert.Equal(t, 80.0, claim.Items[2].Amount, "destinations without a rate get the default")
+	assert.Equal(t, 461.87, claim.Total)
+
+	invalid := map[string]models.ExpenseItem{
+		"unknown vehicle": {Kind: models.ExpenseItemMileage, Date: day, VehicleType: "Bus", Distance: 10},
+		"no distance":     {Kind: models.ExpenseItemMileage, Date: day, VehicleType: "Car"},
+		"no destination":  {Kind: models.ExpenseItemPerDiem, Date: day, Days: 1},
+		"no days":         {Kind: models.ExpenseItemPerDiem, Date: day, Destination: "London"},
+		"unknown kind":    {Kind: "hotel", Date: day, Amount: 10},
+	}
+	for name, item := range invalid {
+		err := service.Submit(ctx, &models.ExpenseClaim{Items: []models.ExpenseItem{item}}, "jane@example.com")
+		assert.ErrorIs(t, err, models.ErrValidation, name)
+	}
+
+	// Without a default rate, unknown destinations cannot be claimed
+	store.perDiem = store.perDiem[:1]
+	err := service.Submit(ctx, &models.ExpenseClaim{Items: []models.ExpenseItem{
+		{Kind: models.ExpenseItemPerDiem, Date: day, Destination: "Leeds", Days: 1},
+	}}, "jane@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestSetRates(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+	require.NoError(t, service.SetMileageRate(ctx, &models.MileageRate{VehicleType: " Car ", Rate: 0.45}, "admin@example.com"))
+	assert.Equal(t, "Car", store.mileage[0].VehicleType)
+	assert.Equal(t, "admin@example.com", store.mileage[0].UpdatedBy)
+	assert.ErrorIs(t, service.SetMileageRate(ctx, &models.MileageRate{VehicleType: "Car"}, "admin@example.com"), models.ErrValidation)
+
+	require.NoError(t, service.SetPerDiemRate(ctx, &models.PerDiemRate{Destination: "Paris", DailyRate: 100}, "admin@example.com"))
+	invalid := []models.PerDiemRate{
+		{Destination: "", DailyRate: 100},
+		{Destination: "Paris"},
+		{Destination: "Paris", DailyRate: 100, LongStayRate: 70},
+		{Destination: "Paris", DailyRate: 100, LongStayAfter: -1},
+	}
+	for _, rate := range invalid {
+		assert.ErrorIs(t, service.SetPerDiemRate(ctx, &rate, "admin@example.com"), models.ErrValidation)
+	}
+}
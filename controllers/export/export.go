@@ -0,0 +1,330 @@
+// Package export writes nightly incremental extracts of the application tables for the data
+// warehouse. Each table has a high-water mark, the newest (updated_at, id) already exported;
+// a run writes the rows changed after it to one CSV file per table and moves the mark on.
+//
+// Extracts are at least once: a run that fails after writing a file leaves its table's mark
+// where it was, so the rows are written again by the next run. Loaders should keep the
+// newest version of each id. Deleted rows are not captured.
+//
+// Rows changed within Lag of the run are left for the next one. updated_at is set when a
+// transaction starts, so without the lag a row committed after the run by a slower
+// transaction could carry a timestamp below the new mark and never be exported.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"erp/controllers/jobs"
+	"erp/controllers/storage"
+	"erp/models"
+	"erp/models/db"
+
+	"github.com/lib/pq"
+)
+
+// KindExport is the job kind of export runs.
+const KindExport = "warehouse_export"
+
+// ErrRunning is returned when an export is started while another is running.
+var ErrRunning = models.Conflict("an export is already running")
+
+// File is the extract of one table written by a run.
+type File struct {
+	Table   string    `json:"table"`
+	Key     string    `json:"key"` // Storage key of the CSV file
+	Rows    int64     `json:"rows"`
+	Through time.Time `json:"through"` // updated_at of the newest row in the file
+}
+
+// Result describes an export run. It is stored as the result of export jobs.
+type Result struct {
+	Cutoff    time.Time `json:"cutoff"` // Rows changed after this are left for the next run
+	Files     []File    `json:"files"`  // Tables without changes have no file
+	Rows      int64     `json:"rows"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Service runs exports as background jobs.
+type Service struct {
+	DB      *sql.DB
+	Storage storage.Storage // Where the extract files are written
+	Jobs    *jobs.Runner
+	Tables  []string         // Tables exported; each needs id and updated_at columns
+	Lag     time.Duration    // How old a change must be to be exported
+	Now     func() time.Time // Clock, replaced in tests
+}
+
+// running is held while an export runs, so the marks are not moved by two runs at once.
+// It is shared by every Service of the process: the scheduler and the API each have one.
+var running sync.Mutex
+
+// NewService creates an export service.
+func NewService(db *sql.DB, store storage.Storage, runner *jobs.Runner, tables []string, lag time.Duration) *Service {
+	return &Service{DB: db, Storage: store, Jobs: runner, Tables: tables, Lag: lag, Now: time.Now}
+}
+
+// Start begins an export in the background.
+//
+// Parameters:
+//   - actor: Email of the admin who requested the export.
+//
+// Returns:
+//   - *Job: The queued export job.
+//   - error: ErrRunning if an export is already running, or an error if the job cannot be
+//     created.
+func (s *Service) Start(ctx context.Context, actor string) (*models.Job, error) {
+	if !running.TryLock() {
+		return nil, ErrRunning
+	}
+	job, err := s.Jobs.Start(ctx, KindExport, actor, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		defer running.Unlock()
+		return s.Export(ctx, p)
+	})
+	if err != nil {
+		running.Unlock()
+	}
+	return job, err
+}
+
+// Nightly exports the changes since the previous run. It is run by the scheduler and does
+// nothing if an export started by hand is still running.
+func (s *Service) Nightly(ctx context.Context) error {
+	if !running.TryLock() {
+		log.Printf("export: skipping the nightly run, an export is already running")
+		return nil
+	}
+	defer running.Unlock()
+	_, err := s.Jobs.Run(ctx, KindExport, "scheduler", func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		return s.Export(ctx, p)
+	})
+	return err
+}
+
+// Export writes the rows of each table changed since its mark and up to the cutoff, then
+// moves the mark to the newest row written. Each table's mark is moved as soon as its file
+// is written, so a failure leaves the tables already exported done.
+//
+// Parameters:
+//   - ctx: Cancels the export. Its statements are not bound by the query timeout.
+//   - p: Receives the number of rows exported.
+//
+// Returns:
+//   - *Result: The files written.
+//   - error: An error if a table cannot be read or its file or mark cannot be written. A
+//     partially written file is not kept.
+func (s *Service) Export(ctx context.Context, p *jobs.Progress) (*Result, error) {
+	ctx = db.WithoutTimeout(ctx)
+	now := s.Now().UTC()
+	result := &Result{Cutoff: now.Add(-s.Lag), Files: []File{}, CreatedAt: now}
+
+	marks, err := s.Watermarks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]models.ExportWatermark, len(marks))
+	for _, mark := range marks {
+		current[mark.Table] = mark
+	}
+
+	for _, table := range s.Tables {
+		key := fmt.Sprintf("%s/%s-%s.csv", table, table, now.Format("20060102T150405Z"))
+		file, mark, err := s.exportTable(ctx, table, key, current[table], result.Cutoff, p)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", table, err)
+		}
+		if file == nil {
+			continue
+		}
+		mark.ExportedAt = now
+		if err := s.saveWatermark(ctx, mark, file.Rows); err != nil {
+			return nil, fmt.Errorf("export %s: %w", table, err)
+		}
+		result.Files = append(result.Files, *file)
+		result.Rows += file.Rows
+	}
+	log.Printf("export: wrote %d rows in %d files", result.Rows, len(result.Files))
+	return result, nil
+}
+
+// exportTable writes the rows of table changed after mark and up to cutoff to key, oldest
+// first. It returns a nil File if there are none.
+func (s *Service) exportTable(ctx context.Context, table, key string, mark models.ExportWatermark,
+	cutoff time.Time, p *jobs.Progress) (*File, models.ExportWatermark, error) {
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(
+		`SELECT * FROM %s t WHERE (t.updated_at, t.id) > ($1, $2) AND t.updated_at <= $3
+		 ORDER BY t.updated_at, t.id`, pq.QuoteIdentifier(table)),
+		mark.LastUpdatedAt, mark.LastID, cutoff,
+	)
+	if err != nil {
+		return nil, mark, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, mark, err
+	}
+	idColumn, updatedColumn := indexOf(columns, "id"), indexOf(columns, "updated_at")
+	if idColumn < 0 || updatedColumn < 0 {
+		return nil, mark, errors.New("the table needs id and updated_at columns")
+	}
+
+	var file io.WriteCloser
+	var out *csv.Writer
+	fail := func(err error) (*File, models.ExportWatermark, error) {
+		if file != nil {
+			file.Close()
+			s.Storage.Delete(key)
+		}
+		return nil, mark, err
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	next := models.ExportWatermark{Table: table, RowsExported: mark.RowsExported}
+	var count int64
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return fail(err)
+		}
+		if file == nil {
+			if file, err = storage.Create(s.Storage, key, "text/csv"); err != nil {
+				return nil, mark, err
+			}
+			out = csv.NewWriter(file)
+			if err := out.Write(columns); err != nil {
+				return fail(err)
+			}
+		}
+		for i, value := range values {
+			record[i] = formatValue(value)
+		}
+		if err := out.Write(record); err != nil {
+			return fail(err)
+		}
+
+		updatedAt, ok := values[updatedColumn].(time.Time)
+		id, idOK := values[idColumn].(int64)
+		if !ok || !idOK {
+			return fail(errors.New("id or updated_at has an unexpected type"))
+		}
+		next.LastUpdatedAt, next.LastID = updatedAt, int(id)
+		count++
+		p.Add(1)
+	}
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+	if file == nil {
+		return nil, mark, nil
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return fail(err)
+	}
+	if err := file.Close(); err != nil {
+		s.Storage.Delete(key)
+		return nil, mark, err
+	}
+	return &File{Table: table, Key: key, Rows: count, Through: next.LastUpdatedAt}, next, nil
+}
+
+// indexOf returns the position of column in columns, or -1.
+func indexOf(columns []string, column string) int {
+	for i, c := range columns {
+		if c == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatValue writes a database value as a CSV field. NULL is an empty field and times are
+// RFC 3339 in UTC.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// saveWatermark stores a table's new mark and adds rows to its running total.
+func (s *Service) saveWatermark(ctx context.Context, mark models.ExportWatermark, rows int64) error {
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO export_watermarks (table_name, last_updated_at, last_id, rows_exported, exported_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (table_name) DO UPDATE SET last_updated_at = $2, last_id = $3,
+		     rows_exported = export_watermarks.rows_exported + $4, exported_at = $5`,
+		mark.Table, mark.LastUpdatedAt, mark.LastID, rows, mark.ExportedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save watermark: %w", err)
+	}
+	return nil
+}
+
+// Watermarks returns the marks of the tables that have been exported, by table name.
+func (s *Service) Watermarks(ctx context.Context) ([]models.ExportWatermark, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT table_name, last_updated_at, last_id, rows_exported, exported_at
+		 FROM export_watermarks ORDER BY table_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watermarks: %w", err)
+	}
+	defer rows.Close()
+
+	marks := []models.ExportWatermark{}
+	for rows.Next() {
+		var mark models.ExportWatermark
+		if err := rows.Scan(&mark.Table, &mark.LastUpdatedAt, &mark.LastID, &mark.RowsExported, &mark.ExportedAt); err != nil {
+			return nil, err
+		}
+		marks = append(marks, mark)
+	}
+	return marks, rows.Err()
+}
+
+// ResetWatermark forgets a table's mark, so the next run exports the whole table again.
+//
+// Returns:
+//   - error: models.ErrNotFound if the table has never been exported.
+func (s *Service) ResetWatermark(ctx context.Context, table string) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM export_watermarks WHERE table_name = $1", table)
+	if err != nil {
+		return fmt.Errorf("failed to reset watermark: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return models.NotFound("table %s has not been exported", table)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/controllers/jobs"
+	"erp/controllers/storage"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	exportTime = time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	markTime   = time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+)
+
+func newTestService(t *testing.T, tables ...string) (*Service, sqlmock.Sqlmock, *storage.MemoryStorage) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	files := storage.NewMemoryStorage()
+	service := NewService(db, files, nil, tables, 5*time.Minute)
+	service.Now = func() time.Time { return exportTime }
+	return service, mock, files
+}
+
+// expectWatermarks returns a mark for customers only.
+func expectWatermarks(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT table_name, last_updated_at, last_id, rows_exported, exported_at FROM export_watermarks").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "last_updated_at", "last_id", "rows_exported", "exported_at"}).
+			AddRow("customers", markTime, 4, 10, markTime))
+}
+
+func TestExportWritesChangedRowsAndMovesMarks(t *testing.T) {
+	service, mock, files := newTestService(t, "customers", "products")
+	cutoff := exportTime.Add(-5 * time.Minute)
+	changed := markTime.Add(time.Hour)
+
+	expectWatermarks(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "customers" t WHERE (t.updated_at, t.id) > ($1, $2) AND t.updated_at <= $3`)).
+		WithArgs(markTime, 4, cutoff).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "contact", "updated_at"}).
+			AddRow(int64(2), "Acme, Inc.", nil, markTime.Add(time.Minute)).
+			AddRow(int64(5), "Globex", []byte("555-0100"), changed))
+	mock.ExpectExec("INSERT INTO export_watermarks").
+		WithArgs("customers", changed, 5, int64(2), exportTime).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" t`)).
+		WithArgs(time.Time{}, 0, cutoff).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "updated_at"}))
+
+	result, err := service.Export(context.Background(), &jobs.Progress{})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, cutoff, result.Cutoff)
+	assert.Equal(t, int64(2), result.Rows)
+	require.Len(t, result.Files, 1, "Tables without changes have no file")
+	assert.Equal(t, File{Table: "customers", Key: "customers/customers-20261016T030000Z.csv", Rows: 2, Through: changed}, result.Files[0])
+	assert.Equal(t, "id,name,contact,updated_at\n"+
+		"2,\"Acme, Inc.\",,2026-10-15T03:01:00Z\n"+
+		"5,Globex,555-0100,2026-10-15T04:00:00Z\n", string(files.Files[result.Files[0].Key]))
+}
+
+func TestExportFailureKeepsEarlierTables(t *testing.T) {
+	service, mock, files := newTestService(t, "customers", "products")
+
+	expectWatermarks(mock)
+	mock.ExpectQuery(`SELECT \* FROM "customers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "updated_at"}).AddRow(int64(6), markTime.Add(time.Hour)))
+	mock.ExpectExec("INSERT INTO export_watermarks").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "updated_at"}).
+			AddRow(int64(1), markTime).
+			AddRow(int64(2), markTime).
+			RowError(1, errors.New("connection reset")))
+
+	_, err := service.Export(context.Background(), &jobs.Progress{})
+	assert.ErrorContains(t, err, "export products: connection reset")
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, files.Files, "customers/customers-20261016T030000Z.csv")
+	assert.Len(t, files.Files, 1, "The partial products file is not kept")
+}
+
+func TestExportRejectsTableWithoutUpdatedAt(t *testing.T) {
+	service, mock, files := newTestService(t, "users")
+
+	expectWatermarks(mock)
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(int64(1), "admin@example.com"))
+
+	_, err := service.Export(context.Background(), &jobs.Progress{})
+	assert.ErrorContains(t, err, "export users: the table needs id and updated_at columns")
+	assert.Empty(t, files.Files)
+}
+
+func TestOneExportAtATime(t *testing.T) {
+	ctx := context.Background()
+	service, mock, _ := newTestService(t, "customers")
+	running.Lock()
+	defer running.Unlock()
+
+	_, err := service.Start(ctx, "admin@example.com")
+	assert.Equal(t, ErrRunning, err)
+	assert.NoError(t, service.Nightly(ctx), "The nightly run is skipped")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetWatermark(t *testing.T) {
+	ctx := context.Background()
+	service, mock, _ := newTestService(t)
+	mock.ExpectExec("DELETE FROM export_watermarks").WithArgs("customers").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM export_watermarks").WithArgs("users").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, service.ResetWatermark(ctx, "customers"))
+	assert.ErrorContains(t, service.ResetWatermark(ctx, "users"), "table users has not been exported")
+}
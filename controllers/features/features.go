@@ -0,0 +1,153 @@
+// Package features evaluates feature flags so new behaviors can be rolled out gradually.
+// Flags are stored in the database, cached, and targeted at roles or individual users.
+package features
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// Known feature flags
+const (
+	// DoubleEntryPosting enables posting invoices and journal entries to the ledger.
+	DoubleEntryPosting = "ledger.double_entry_posting"
+	// ApprovalWorkflows enables approval steps for documents that support them.
+	ApprovalWorkflows = "approvals.workflows"
+	// GraphQLAPI enables the GraphQL endpoint.
+	GraphQLAPI = "api.graphql"
+)
+
+// Defaults describes the known flags and their state while no admin has saved them.
+// New behaviors start disabled until they are switched on.
+var Defaults = map[string]models.FeatureFlag{
+	DoubleEntryPosting: {Key: DoubleEntryPosting, Description: "Post invoices and journal entries to the general ledger"},
+	ApprovalWorkflows:  {Key: ApprovalWorkflows, Description: "Require approval before documents take effect"},
+	GraphQLAPI:         {Key: GraphQLAPI, Description: "Serve read-only GraphQL queries at /graphql"},
+}
+
+// Service evaluates feature flags. Stored flags are cached for TTL; changes made through
+// the service take effect immediately.
+type Service struct {
+	Store models.FeatureFlagStore
+	TTL   time.Duration
+
+	mu       sync.Mutex
+	flags    map[string]models.FeatureFlag
+	loadedAt time.Time
+}
+
+// NewService creates a feature flag service with the given cache lifetime.
+func NewService(store models.FeatureFlagStore, ttl time.Duration) *Service {
+	return &Service{Store: store, TTL: ttl}
+}
+
+// List returns every known or stored flag, sorted by key.
+//
+// Returns:
+//   - []FeatureFlag: The flags, with defaults for known flags that were never saved.
+//   - error: An error if the flags cannot be loaded.
+func (s *Service) List(ctx context.Context) ([]models.FeatureFlag, error) {
+	flags, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]models.FeatureFlag, 0, len(flags))
+	for _, flag := range flags {
+		list = append(list, flag)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list, nil
+}
+
+// Enabled reports whether a flag is on for a user. If the flags cannot be loaded the
+// defaults are used, so an outage keeps new behaviors switched off.
+//
+// Parameters:
+//   - key: The flag key.
+//   - role: The user's role, or "" for anonymous requests.
+//   - email: The user's email, or "" for anonymous requests.
+func (s *Service) Enabled(ctx context.Context, key, role, email string) bool {
+	flags, err := s.load(ctx)
+	if err != nil {
+		log.Printf("features: could not load flags, using defaults: %v", err)
+		flags = Defaults
+	}
+	flag, ok := flags[key]
+	return ok && flag.EnabledFor(role, email)
+}
+
+// EnabledForRequest reports whether a flag is on for the user making the request.
+func (s *Service) EnabledForRequest(r *http.Request, key string) bool {
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	return s.Enabled(r.Context(), key, role, email)
+}
+
+// Require is a middleware that hides a route (404 Not Found) from users the flag is off for.
+// The user is read from a bearer token when one is sent.
+func (s *Service) Require(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return middleware.OptionalJWTAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.EnabledForRequest(r, key) {
+				respond.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// Save stores a flag and refreshes the cache.
+func (s *Service) Save(ctx context.Context, flag *models.FeatureFlag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.Store.SaveFeatureFlag(ctx, flag); err != nil {
+		return err
+	}
+	s.flags = nil
+	return nil
+}
+
+// Reset deletes a stored flag, returning it to its default, and refreshes the cache.
+func (s *Service) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.Store.DeleteFeatureFlag(ctx, key); err != nil {
+		return err
+	}
+	s.flags = nil
+	return nil
+}
+
+// load returns the cached flags merged over the defaults, reloading them once they expire.
+func (s *Service) load(ctx context.Context) (map[string]models.FeatureFlag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.flags != nil && time.Since(s.loadedAt) <= s.TTL {
+		return s.flags, nil
+	}
+
+	stored, err := s.Store.ListFeatureFlags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	flags := make(map[string]models.FeatureFlag, len(Defaults)+len(stored))
+	for key, flag := range Defaults {
+		flags[key] = flag
+	}
+	for _, flag := range stored {
+		flags[flag.Key] = flag
+	}
+	s.flags, s.loadedAt = flags, time.Now()
+	return flags, nil
+}
@@ -0,0 +1,95 @@
+package features
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryFlagStore keeps flags in memory and counts loads.
+type memoryFlagStore struct {
+	flags map[string]models.FeatureFlag
+	loads int
+}
+
+func (m *memoryFlagStore) ListFeatureFlags(ctx context.Context) ([]models.FeatureFlag, error) {
+	m.loads++
+	flags := []models.FeatureFlag{}
+	for _, flag := range m.flags {
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+func (m *memoryFlagStore) SaveFeatureFlag(ctx context.Context, flag *models.FeatureFlag) error {
+	m.flags[flag.Key] = *flag
+	return nil
+}
+
+func (m *memoryFlagStore) DeleteFeatureFlag(ctx context.Context, key string) error {
+	if _, ok := m.flags[key]; !ok {
+		return models.ErrNotFound
+	}
+	delete(m.flags, key)
+	return nil
+}
+
+func TestTargetingAndDefaults(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryFlagStore{flags: map[string]models.FeatureFlag{}}
+	service := NewService(store, time.Hour)
+
+	assert.False(t, service.Enabled(ctx, DoubleEntryPosting, "Admin", "admin@example.com"))
+
+	assert.NoError(t, service.Save(ctx, &models.FeatureFlag{Key: DoubleEntryPosting, Enabled: true, Roles: []string{"Accountant"}, Users: []string{"pilot@example.com"}}))
+	assert.True(t, service.Enabled(ctx, DoubleEntryPosting, "accountant", "a@example.com"))
+	assert.True(t, service.Enabled(ctx, DoubleEntryPosting, "Employee", "Pilot@example.com"))
+	assert.False(t, service.Enabled(ctx, DoubleEntryPosting, "Employee", "b@example.com"))
+	assert.False(t, service.Enabled(ctx, DoubleEntryPosting, "", ""))
+
+	assert.NoError(t, service.Save(ctx, &models.FeatureFlag{Key: DoubleEntryPosting, Enabled: true}))
+	assert.True(t, service.Enabled(ctx, DoubleEntryPosting, "", ""))
+
+	assert.NoError(t, service.Reset(ctx, DoubleEntryPosting))
+	assert.False(t, service.Enabled(ctx, DoubleEntryPosting, "Admin", ""))
+	assert.False(t, service.Enabled(ctx, "unknown.flag", "Admin", ""))
+}
+
+func TestEvaluationIsCached(t *testing.T) {
+	store := &memoryFlagStore{flags: map[string]models.FeatureFlag{
+		ApprovalWorkflows: {Key: ApprovalWorkflows, Enabled: true},
+	}}
+	service := NewService(store, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		assert.True(t, service.Enabled(context.Background(), ApprovalWorkflows, "", ""))
+	}
+	assert.Equal(t, 1, store.loads)
+}
+
+func TestRequireHidesDisabledRoutes(t *testing.T) {
+	store := &memoryFlagStore{flags: map[string]models.FeatureFlag{
+		DoubleEntryPosting: {Key: DoubleEntryPosting, Enabled: true, Roles: []string{"Accountant"}},
+	}}
+	service := NewService(store, time.Hour)
+	handler := service.Require(DoubleEntryPosting)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/invoices/1/post", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	req := httptest.NewRequest("POST", "/invoices/1/post", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserRole, "Accountant"))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,376 @@
+// Package forecasting projects the cash balance week by week. It starts from the ledger
+// balance of the cash account. Open receivables are expected to be collected after the delay
+// their customer usually takes to pay, weighted by amount over recent payments, and so are
+// recurring invoices not issued yet. Supplier bills are paid at the end of their terms,
+// payments waiting for approval on their date, and the last payroll is repeated every
+// month. The optimistic and pessimistic scenarios collect a standard deviation of each
+// customer's delay sooner or later; the pessimistic one also leaves out disputed and
+// doubtful receivables.
+package forecasting
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/config"
+	"erp/models"
+)
+
+// Limits of the forecast horizon, in days.
+const (
+	DefaultHorizon = 90
+	MaxHorizon     = 365
+)
+
+// scenarios are forecast in this order.
+var scenarios = []string{models.CashScenarioExpected, models.CashScenarioOptimistic, models.CashScenarioPessimistic}
+
+// Service forecasts cash and keeps the recurring invoices the forecast collects.
+type Service struct {
+	Store   models.CashForecastStore
+	Account string // Ledger account holding the cash
+	Rules   config.ForecastConfig
+	Now     func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a cash forecasting service for the balance of account.
+func NewService(store models.CashForecastStore, account string, rules config.ForecastConfig) *Service {
+	return &Service{Store: store, Account: account, Rules: rules, Now: time.Now}
+}
+
+// Inputs are the amounts owed to and by the company that a forecast is worked out from.
+type Inputs struct {
+	Receivables []models.OpenReceivable
+	Timings     []models.PaymentTiming
+	Payables    []models.OpenPayable
+	Payroll     *models.PayrollCost // Nil if no payroll was recorded
+	Recurring   []models.RecurringInvoice
+}
+
+// ParseHorizon reads a horizon in days ("90d") or weeks ("12w").
+//
+// Returns:
+//   - int: The number of days; DefaultHorizon if value is empty.
+//   - error: A validation error if the horizon is malformed or longer than MaxHorizon days.
+func ParseHorizon(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return DefaultHorizon, nil
+	}
+	unit := 0
+	switch {
+	case strings.HasSuffix(value, "d"):
+		unit = 1
+	case strings.HasSuffix(value, "w"):
+		unit = 7
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if unit == 0 || err != nil || n <= 0 {
+		return 0, models.Invalid("horizon must be a number of days or weeks, e.g. 90d or 12w")
+	}
+	if days := n * unit; days <= MaxHorizon {
+		return days, nil
+	}
+	return 0, models.Invalid("horizon must be at most %d days", MaxHorizon)
+}
+
+// Cash forecasts the cash balance by week from today.
+//
+// Parameters:
+//   - horizon: How far to forecast, e.g. "90d" or "12w"; 90 days if empty.
+//
+// Returns:
+//   - *models.CashForecast: The opening balance and the weeks of each scenario.
+//   - error: A validation error if the horizon is invalid, or the store's error.
+func (s *Service) Cash(ctx context.Context, horizon string) (*models.CashForecast, error) {
+	days, err := ParseHorizon(horizon)
+	if err != nil {
+		return nil, err
+	}
+	asOf := day(s.Now())
+	opening, err := s.Store.CashBalance(ctx, s.Account, asOf)
+	if err != nil {
+		return nil, err
+	}
+	var in Inputs
+	if in.Receivables, err = s.Store.OpenReceivables(ctx); err != nil {
+		return nil, err
+	}
+	if in.Timings, err = s.Store.PaymentTimings(ctx, asOf.AddDate(0, 0, -s.Rules.LookbackDays)); err != nil {
+		return nil, err
+	}
+	if in.Payables, err = s.Store.OpenPayables(ctx); err != nil {
+		return nil, err
+	}
+	if in.Payroll, err = s.Store.LatestPayroll(ctx); err != nil {
+		return nil, err
+	}
+	if in.Recurring, err = s.Store.ListRecurringInvoices(ctx); err != nil {
+		return nil, err
+	}
+	return s.Forecast(asOf, days, opening, in), nil
+}
+
+// Forecast works out the cash forecast of the days from asOf. Amounts due before asOf are
+// expected in the first week; those due after the horizon are left out.
+func (s *Service) Forecast(asOf time.Time, days int, opening float64, in Inputs) *models.CashForecast {
+	asOf = day(asOf)
+	last := asOf.AddDate(0, 0, days-1)
+	behavior := Behaviors(in.Timings)
+	forecast := &models.CashForecast{AsOf: asOf, HorizonDays: days, Account: s.Account, Opening: round(opening),
+		Payroll: in.Payroll, Scenarios: []models.CashScenario{}, Customers: []models.PaymentBehavior{}}
+
+	for _, scenario := range scenarios {
+		weeks := Weeks(asOf, last)
+		week := func(date time.Time) *models.CashForecastWeek {
+			for i := range weeks {
+				if !date.After(weeks[i].End) {
+					return &weeks[i]
+				}
+			}
+			return nil
+		}
+
+		for _, r := range in.Receivables {
+			b := s.behaviorOf(behavior, r.CustomerID)
+			expected := addDays(r.PostedOn, b.AverageDays)
+			doubtful := asOf.Sub(expected) > time.Duration(s.Rules.DoubtfulDays)*24*time.Hour
+			if scenario == models.CashScenarioPessimistic && (r.Disputed || doubtful) {
+				continue
+			}
+			if w := week(collected(r.PostedOn, b, scenario)); w != nil {
+				w.Receivables += r.Balance
+			}
+		}
+		for _, invoice := range in.Recurring {
+			b := s.behaviorOf(behavior, invoice.CustomerID)
+			for _, issued := range Occurrences(invoice, asOf, last) {
+				if w := week(collected(issued, b, scenario)); w != nil {
+					w.Recurring += invoice.Amount
+				}
+			}
+		}
+		for _, p := range in.Payables {
+			due := p.Date
+			if p.Source == models.PayableSupplierBill {
+				due = due.AddDate(0, 0, s.Rules.PayableTermsDays)
+			}
+			if w := week(due); w != nil {
+				w.Payables += p.Amount
+			}
+		}
+		if in.Payroll != nil {
+			for _, payday := range s.Paydays(asOf, last) {
+				week(payday).Payroll += in.Payroll.Gross + in.Payroll.Employer
+			}
+		}
+
+		balance := opening
+		result := models.CashScenario{Scenario: scenario, Weeks: weeks}
+		for i := range weeks {
+			w := &weeks[i]
+			w.Net = w.Receivables + w.Recurring - w.Payables - w.Payroll
+			balance += w.Net
+			w.Receivables, w.Recurring, w.Payables, w.Payroll = round(w.Receivables), round(w.Recurring), round(w.Payables), round(w.Payroll)
+			w.Net, w.Closing = round(w.Net), round(balance)
+			if i == 0 || w.Closing < result.Lowest {
+				result.Lowest, result.LowestWeek = w.Closing, w.Start
+			}
+		}
+		result.Closing = round(balance)
+		forecast.Scenarios = append(forecast.Scenarios, result)
+	}
+
+	forecast.Customers = forecastCustomers(behavior, in)
+	return forecast
+}
+
+// behaviorOf returns how a customer pays: their own behavior, that of all customers if they
+// have no payments, or the configured terms if nobody has.
+func (s *Service) behaviorOf(behavior map[int]models.PaymentBehavior, customerID int) models.PaymentBehavior {
+	if b, ok := behavior[customerID]; ok {
+		return b
+	}
+	if b, ok := behavior[0]; ok {
+		return b
+	}
+	return models.PaymentBehavior{CustomerID: customerID, AverageDays: float64(s.Rules.TermsDays)}
+}
+
+// collected returns the day an amount invoiced on a day is expected to be paid in a scenario.
+func collected(invoiced time.Time, b models.PaymentBehavior, scenario string) time.Time {
+	switch scenario {
+	case models.CashScenarioOptimistic:
+		return addDays(invoiced, math.Max(0, b.AverageDays-b.StdDevDays))
+	case models.CashScenarioPessimistic:
+		return addDays(invoiced, b.AverageDays+b.StdDevDays)
+	}
+	return addDays(invoiced, b.AverageDays)
+}
+
+// Behaviors learns how long each customer takes to pay from their payments, weighting each
+// payment by its amount. The behavior of every customer together is under customer ID 0.
+// Payments made before the invoice was posted count as paid on the day.
+func Behaviors(timings []models.PaymentTiming) map[int]models.PaymentBehavior {
+	type sums struct {
+		payments              int
+		amount, days, squares float64
+	}
+	totals := map[int]*sums{}
+	for _, t := range timings {
+		if t.Amount <= 0 {
+			continue
+		}
+		days := math.Max(0, float64(t.Days))
+		for _, id := range []int{t.CustomerID, 0} {
+			if totals[id] == nil {
+				totals[id] = &sums{}
+			}
+			total := totals[id]
+			total.payments++
+			total.amount += t.Amount
+			total.days += t.Amount * days
+			total.squares += t.Amount * days * days
+		}
+	}
+	behavior := map[int]models.PaymentBehavior{}
+	for id, total := range totals {
+		mean := total.days / total.amount
+		variance := math.Max(0, total.squares/total.amount-mean*mean)
+		behavior[id] = models.PaymentBehavior{CustomerID: id, Payments: total.payments,
+			AverageDays: math.Round(mean*10) / 10, StdDevDays: math.Round(math.Sqrt(variance)*10) / 10}
+	}
+	return behavior
+}
+
+// forecastCustomers lists the learned behavior of the customers in the forecast, and of all
+// customers together first.
+func forecastCustomers(behavior map[int]models.PaymentBehavior, in Inputs) []models.PaymentBehavior {
+	ids := map[int]bool{0: true}
+	for _, r := range in.Receivables {
+		ids[r.CustomerID] = true
+	}
+	for _, invoice := range in.Recurring {
+		ids[invoice.CustomerID] = true
+	}
+	list := []models.PaymentBehavior{}
+	for id := range ids {
+		if b, ok := behavior[id]; ok {
+			list = append(list, b)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CustomerID < list[j].CustomerID })
+	return list
+}
+
+// Weeks splits the days from first to last into weeks ending on Sunday. The first and last
+// weeks may be shorter.
+func Weeks(first, last time.Time) []models.CashForecastWeek {
+	weeks := []models.CashForecastWeek{}
+	for start := first; !start.After(last); {
+		end := start.AddDate(0, 0, (7-int(start.Weekday()))%7)
+		if end.After(last) {
+			end = last
+		}
+		weeks = append(weeks, models.CashForecastWeek{Start: start, End: end})
+		start = end.AddDate(0, 0, 1)
+	}
+	return weeks
+}
+
+// Occurrences returns the days from first to last that a recurring invoice is issued on.
+// An invoice started on the 31st is issued on the last day of shorter months.
+func Occurrences(invoice models.RecurringInvoice, first, last time.Time) []time.Time {
+	var days []time.Time
+	if invoice.IntervalMonths <= 0 {
+		return days
+	}
+	start := day(invoice.StartsOn)
+	for k := 0; ; k += invoice.IntervalMonths {
+		issued := monthDay(start.Year(), start.Month()+time.Month(k), start.Day())
+		if issued.After(last) || (invoice.EndsOn != nil && issued.After(day(*invoice.EndsOn))) {
+			return days
+		}
+		if !issued.Before(first) {
+			days = append(days, issued)
+		}
+	}
+}
+
+// Paydays returns the days from first to last that payroll is paid on.
+func (s *Service) Paydays(first, last time.Time) []time.Time {
+	var days []time.Time
+	for month := monthDay(first.Year(), first.Month(), 1); !month.After(last); month = month.AddDate(0, 1, 0) {
+		payday := monthDay(month.Year(), month.Month(), s.Rules.PayrollDay)
+		if !payday.Before(first) && !payday.After(last) {
+			days = append(days, payday)
+		}
+	}
+	return days
+}
+
+// CreateRecurringInvoice checks a recurring invoice and records it.
+//
+// Parameters:
+//   - invoice: CustomerID, Description, Amount, IntervalMonths, StartsOn and optionally
+//     EndsOn are read; the ID and creation are set.
+//   - actor: Email of the user recording it.
+//
+// Returns:
+//   - error: A validation error if the invoice is incomplete or the customer does not
+//     exist, or the store's error.
+func (s *Service) CreateRecurringInvoice(ctx context.Context, invoice *models.RecurringInvoice, actor string) error {
+	invoice.Description = strings.TrimSpace(invoice.Description)
+	switch {
+	case invoice.CustomerID <= 0:
+		return models.Invalid("customer_id is required")
+	case invoice.Amount <= 0:
+		return models.Invalid("amount must be positive")
+	case invoice.IntervalMonths < 1 || invoice.IntervalMonths > 12:
+		return models.Invalid("interval_months must be between 1 and 12")
+	case invoice.StartsOn.IsZero():
+		return models.Invalid("starts_on is required")
+	case invoice.EndsOn != nil && invoice.EndsOn.Before(invoice.StartsOn):
+		return models.Invalid("ends_on must not be before starts_on")
+	}
+	invoice.Amount = round(invoice.Amount)
+	invoice.CreatedBy, invoice.CreatedAt = actor, s.Now()
+	return s.Store.CreateRecurringInvoice(ctx, invoice)
+}
+
+// RecurringInvoices returns the recurring invoices.
+func (s *Service) RecurringInvoices(ctx context.Context) ([]models.RecurringInvoice, error) {
+	return s.Store.ListRecurringInvoices(ctx)
+}
+
+// DeleteRecurringInvoice removes a recurring invoice.
+func (s *Service) DeleteRecurringInvoice(ctx context.Context, id int) error {
+	return s.Store.DeleteRecurringInvoice(ctx, id)
+}
+
+// monthDay returns a day of a month, or the last day of the month if it is shorter.
+func monthDay(year int, month time.Month, d int) time.Time {
+	if lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day(); d > lastDay {
+		d = lastDay
+	}
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+// addDays adds a number of days, rounded to the nearest day.
+func addDays(t time.Time, days float64) time.Time {
+	return day(t).AddDate(0, 0, int(math.Round(days)))
+}
+
+// day returns the date of t at midnight UTC.
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// round rounds an amount to cents.
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,107 @@
+package forecasting
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day2025(month time.Month, d int) time.Time {
+	return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseHorizon(t *testing.T) {
+	for value, want := range map[string]int{"": 90, "90d": 90, "12w": 84, " 30D ": 30, "365d": 365} {
+		days, err := ParseHorizon(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, days, value)
+	}
+	for _, value := range []string{"90", "0d", "-3w", "3m", "d", "366d", "53w"} {
+		_, err := ParseHorizon(value)
+		assert.True(t, errors.Is(err, models.ErrValidation), value)
+	}
+}
+
+func TestForecast(t *testing.T) {
+	s := &Service{Account: "cash", Rules: config.ForecastConfig{TermsDays: 30, PayableTermsDays: 30, PayrollDay: 10, DoubtfulDays: 90}}
+	in := Inputs{
+		// Customer 1 pays after 10 and 30 days: 20 days on average, give or take 10
+		Timings: []models.PaymentTiming{{CustomerID: 1, Days: 10, Amount: 100}, {CustomerID: 1, Days: 30, Amount: 100}},
+		Receivables: []models.OpenReceivable{
+			{InvoiceID: 1, CustomerID: 1, PostedOn: day2025(5, 20), Balance: 500},
+			{InvoiceID: 2, CustomerID: 2, PostedOn: day2025(6, 1), Balance: 200, Disputed: true}, // No payments of their own
+			{InvoiceID: 3, CustomerID: 1, PostedOn: day2025(1, 1), Balance: 50},                  // Doubtful
+		},
+		Recurring: []models.RecurringInvoice{{CustomerID: 1, Amount: 300, IntervalMonths: 1, StartsOn: day2025(4, 5)}},
+		Payables: []models.OpenPayable{
+			{Source: models.PayableSupplierBill, ID: 1, Date: day2025(5, 1), Amount: 120}, // Due on May 31
+			{Source: models.PayablePendingPayment, ID: 7, Date: day2025(6, 12), Amount: 80},
+			{Source: models.PayableSupplierBill, ID: 2, Date: day2025(6, 10), Amount: 999}, // Due after the horizon
+		},
+		Payroll: &models.PayrollCost{Period: "2025-05", Employees: 3, Gross: 1000, Employer: 100},
+	}
+
+	// Wednesday 4 June for two weeks
+	forecast := s.Forecast(day2025(6, 4), 14, 2000, in)
+	require.Len(t, forecast.Scenarios, 3)
+	expected, optimistic, pessimistic := forecast.Scenarios[0], forecast.Scenarios[1], forecast.Scenarios[2]
+
+	require.Len(t, expected.Weeks, 3)
+	assert.Equal(t, day2025(6, 8), expected.Weeks[0].End)
+	assert.Equal(t, day2025(6, 9), expected.Weeks[1].Start)
+	assert.Equal(t, day2025(6, 17), expected.Weeks[2].End)
+
+	assert.Equal(t, models.CashForecastWeek{Start: day2025(6, 4), End: day2025(6, 8),
+		Receivables: 50, Payables: 120, Net: -70, Closing: 1930}, expected.Weeks[0])
+	assert.Equal(t, models.CashForecastWeek{Start: day2025(6, 9), End: day2025(6, 15),
+		Receivables: 500, Payables: 80, Payroll: 1100, Net: -680, Closing: 1250}, expected.Weeks[1])
+	assert.Equal(t, 1250.0, expected.Closing)
+	assert.Equal(t, 1250.0, expected.Lowest)
+	assert.Equal(t, day2025(6, 9), expected.LowestWeek)
+
+	assert.Equal(t, models.CashScenarioOptimistic, optimistic.Scenario)
+	assert.Equal(t, 550.0, optimistic.Weeks[0].Receivables)
+	assert.Equal(t, 200.0, optimistic.Weeks[1].Receivables)
+	assert.Equal(t, 300.0, optimistic.Weeks[1].Recurring, "the invoice of 5 June is collected on the 15th")
+	assert.Equal(t, 1750.0, optimistic.Closing)
+
+	assert.Equal(t, 0.0, pessimistic.Weeks[0].Receivables+pessimistic.Weeks[1].Receivables+pessimistic.Weeks[2].Receivables)
+	assert.Equal(t, 700.0, pessimistic.Closing)
+
+	assert.Equal(t, []models.PaymentBehavior{
+		{CustomerID: 0, Payments: 2, AverageDays: 20, StdDevDays: 10},
+		{CustomerID: 1, Payments: 2, AverageDays: 20, StdDevDays: 10},
+	}, forecast.Customers)
+}
+
+func TestForecastWithoutPaymentHistoryUsesTerms(t *testing.T) {
+	s := &Service{Rules: config.ForecastConfig{TermsDays: 30, DoubtfulDays: 90}}
+	in := Inputs{Receivables: []models.OpenReceivable{{InvoiceID: 1, CustomerID: 4, PostedOn: day2025(5, 8), Balance: 75}}}
+
+	forecast := s.Forecast(day2025(6, 2), 7, 0, in)
+	for _, scenario := range forecast.Scenarios {
+		require.Len(t, scenario.Weeks, 1)
+		assert.Equal(t, 75.0, scenario.Weeks[0].Receivables, "due on 7 June in every scenario")
+	}
+	assert.Empty(t, forecast.Customers)
+}
+
+func TestOccurrences(t *testing.T) {
+	ends := day2025(3, 31)
+	invoice := models.RecurringInvoice{IntervalMonths: 1, StartsOn: day2025(1, 31), EndsOn: &ends}
+	assert.Equal(t, []time.Time{day2025(2, 28), day2025(3, 31)}, Occurrences(invoice, day2025(2, 1), day2025(6, 30)))
+
+	invoice = models.RecurringInvoice{IntervalMonths: 3, StartsOn: time.Date(2024, 11, 15, 0, 0, 0, 0, time.UTC)}
+	assert.Equal(t, []time.Time{day2025(2, 15), day2025(5, 15), day2025(8, 15)}, Occurrences(invoice, day2025(1, 1), day2025(8, 15)))
+}
+
+func TestPaydaysOfShortMonths(t *testing.T) {
+	s := &Service{Rules: config.ForecastConfig{PayrollDay: 31}}
+	assert.Equal(t, []time.Time{day2025(2, 28), day2025(3, 31)}, s.Paydays(day2025(2, 10), day2025(4, 29)))
+}
@@ -0,0 +1,145 @@
+// Package giftcards issues gift cards and store credit and applies their balance to invoice
+// payments. The outstanding balance of all cards is carried as a liability in the ledger
+// until it is spent or expires.
+package giftcards
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"erp/config"
+	"erp/models"
+)
+
+// codeAlphabet leaves out letters and digits that are easily confused when a code is read
+// out or typed in (0/O, 1/I/L, 5/S, 8/B).
+const codeAlphabet = "ACDEFGHJKMNPQRTUVWXY234679"
+
+// codeAttempts is how often Issue draws a new code when the first is already taken.
+const codeAttempts = 3
+
+// Service issues, applies and expires gift cards.
+type Service struct {
+	Store models.GiftCardStore
+	Rules config.GiftCardConfig
+}
+
+// NewService creates a gift card service with the configured rules.
+func NewService(store models.GiftCardStore, rules config.GiftCardConfig) *Service {
+	return &Service{Store: store, Rules: rules}
+}
+
+// Issue creates a card with a new code. Cards without an expiry date get the configured
+// default, if any.
+//
+// Parameters:
+//   - card: The card to issue; Kind, InitialAmount and optionally CustomerID and ExpiresAt
+//     are read, the rest is filled in.
+//
+// Returns:
+//   - error: A validation error if the card is invalid, or the store's error.
+func (s *Service) Issue(ctx context.Context, card *models.GiftCard) error {
+	now := time.Now()
+	card.InitialAmount = roundCents(card.InitialAmount)
+	switch {
+	case card.Kind != models.GiftCardSold && card.Kind != models.GiftCardCredit:
+		return models.Invalid("kind must be %q or %q", models.GiftCardSold, models.GiftCardCredit)
+	case card.InitialAmount <= 0:
+		return models.Invalid("amount must be positive")
+	case card.Kind == models.GiftCardCredit && card.CustomerID == nil:
+		return models.Invalid("store credit must be issued to a customer")
+	case card.ExpiresAt != nil && !card.ExpiresAt.After(now):
+		return models.Invalid("expires_at must be in the future")
+	}
+	if card.ExpiresAt == nil && s.Rules.ExpiryDays > 0 {
+		expiresAt := now.AddDate(0, 0, s.Rules.ExpiryDays)
+		card.ExpiresAt = &expiresAt
+	}
+	card.Balance = card.InitialAmount
+	card.IssuedAt = now
+
+	var err error
+	for attempt := 0; attempt < codeAttempts; attempt++ {
+		if card.Code, err = GenerateCode(); err != nil {
+			return err
+		}
+		if err = s.Store.IssueGiftCard(ctx, card); !errors.Is(err, models.ErrGiftCardCodeCollision) {
+			return err
+		}
+	}
+	return err
+}
+
+// Lookup returns a card and its transactions by code.
+func (s *Service) Lookup(ctx context.Context, code string) (*models.GiftCard, error) {
+	return s.Store.GetGiftCardByCode(ctx, NormalizeCode(code))
+}
+
+// CustomerCards returns the cards issued to a customer.
+func (s *Service) CustomerCards(ctx context.Context, customerID int) ([]models.GiftCard, error) {
+	return s.Store.GetGiftCardsByCustomer(ctx, customerID)
+}
+
+// Apply pays part or all of a posted invoice from a card's balance.
+//
+// Returns:
+//   - *models.Payment: The payment recorded against the invoice.
+//   - error: A validation error for a non-positive amount, or the store's error.
+func (s *Service) Apply(ctx context.Context, code string, invoiceID int, amount float64) (*models.Payment, error) {
+	amount = roundCents(amount)
+	if amount <= 0 {
+		return nil, models.Invalid("amount must be positive")
+	}
+	return s.Store.ApplyGiftCard(ctx, NormalizeCode(code), invoiceID, amount, time.Now())
+}
+
+// Expire clears the balance of every card that has expired. It is run daily by the
+// scheduler.
+func (s *Service) Expire(ctx context.Context, now time.Time) error {
+	_, err := s.Store.ExpireGiftCards(ctx, now)
+	return err
+}
+
+// GenerateCode returns a random code of four groups of four characters, e.g.
+// "KX7M-P2QD-W9HT-AC4R".
+func GenerateCode() (string, error) {
+	size := big.NewInt(int64(len(codeAlphabet)))
+	var code strings.Builder
+	for i := 0; i < 16; i++ {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// NormalizeCode returns a code as stored, so codes can be typed in lower case and with or
+// without dashes and spaces.
+func NormalizeCode(code string) string {
+	var plain strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if r != '-' && r != ' ' {
+			plain.WriteRune(r)
+		}
+	}
+	s := plain.String()
+	if len(s) != 16 {
+		return s
+	}
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16]
+}
+
+// roundCents rounds an amount to whole cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
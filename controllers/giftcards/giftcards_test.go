@@ -0,0 +1,126 @@
+package giftcards
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryGiftCardStore keeps issued cards in memory and can pretend the first codes are taken.
+type memoryGiftCardStore struct {
+	cards      map[string]models.GiftCard
+	collisions int
+	applied    []float64
+}
+
+func newMemoryGiftCardStore() *memoryGiftCardStore {
+	return &memoryGiftCardStore{cards: make(map[string]models.GiftCard)}
+}
+
+func (m *memoryGiftCardStore) IssueGiftCard(ctx context.Context, card *models.GiftCard) error {
+	if m.collisions > 0 {
+		m.collisions--
+		return models.ErrGiftCardCodeCollision
+	}
+	card.ID = len(m.cards) + 1
+	m.cards[card.Code] = *card
+	return nil
+}
+
+func (m *memoryGiftCardStore) GetGiftCardByCode(ctx context.Context, code string) (*models.GiftCard, error) {
+	card, ok := m.cards[code]
+	if !ok {
+		return nil, models.NotFound("gift card not found")
+	}
+	return &card, nil
+}
+
+func (m *memoryGiftCardStore) GetGiftCardsByCustomer(ctx context.Context, customerID int) ([]models.GiftCard, error) {
+	return nil, nil
+}
+
+func (m *memoryGiftCardStore) ApplyGiftCard(ctx context.Context, code string, invoiceID int, amount float64, now time.Time) (*models.Payment, error) {
+	m.applied = append(m.applied, amount)
+	return &models.Payment{InvoiceID: invoiceID, Amount: amount, PaymentMethod: models.PaymentMethodGiftCard}, nil
+}
+
+func (m *memoryGiftCardStore) ExpireGiftCards(ctx context.Context, now time.Time) (float64, error) {
+	return 0, nil
+}
+
+func TestIssue(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryGiftCardStore()
+	service := NewService(store, config.GiftCardConfig{ExpiryDays: 30})
+
+	card := models.GiftCard{Kind: models.GiftCardSold, InitialAmount: 25.004}
+	require.NoError(t, service.Issue(ctx, &card))
+	assert.Regexp(t, regexp.MustCompile(`^[A-Z0-9]{4}-[A-Z0-9]{4}-[A-Z0-9]{4}-[A-Z0-9]{4}$`), card.Code)
+	assert.Equal(t, 25.0, card.InitialAmount)
+	assert.Equal(t, 25.0, card.Balance)
+	require.NotNil(t, card.ExpiresAt, "The configured expiry applies")
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 30), *card.ExpiresAt, time.Minute)
+
+	found, err := service.Lookup(ctx, NormalizeCode(card.Code))
+	require.NoError(t, err)
+	assert.Equal(t, card.ID, found.ID)
+}
+
+func TestIssueRetriesTakenCodes(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryGiftCardStore()
+	service := NewService(store, config.GiftCardConfig{})
+
+	store.collisions = codeAttempts - 1
+	card := models.GiftCard{Kind: models.GiftCardSold, InitialAmount: 10}
+	require.NoError(t, service.Issue(ctx, &card))
+	assert.Nil(t, card.ExpiresAt, "Cards never expire without a configured expiry")
+
+	store.collisions = codeAttempts
+	card = models.GiftCard{Kind: models.GiftCardSold, InitialAmount: 10}
+	assert.ErrorIs(t, service.Issue(ctx, &card), models.ErrGiftCardCodeCollision)
+}
+
+func TestIssueRejectsInvalidCards(t *testing.T) {
+	customerID := 3
+	past := time.Now().Add(-time.Hour)
+	tests := map[string]models.GiftCard{
+		"unknown kind":             {Kind: "voucher", InitialAmount: 10},
+		"zero amount":              {Kind: models.GiftCardSold, InitialAmount: 0.001},
+		"store credit without one": {Kind: models.GiftCardCredit, InitialAmount: 10},
+		"expired":                  {Kind: models.GiftCardCredit, InitialAmount: 10, CustomerID: &customerID, ExpiresAt: &past},
+	}
+	service := NewService(newMemoryGiftCardStore(), config.GiftCardConfig{})
+	for name, card := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, service.Issue(context.Background(), &card), models.ErrValidation)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryGiftCardStore()
+	service := NewService(store, config.GiftCardConfig{})
+
+	_, err := service.Apply(ctx, "KX7M-P2QD-W9HT-AC4R", 4, 0)
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	payment, err := service.Apply(ctx, "KX7M-P2QD-W9HT-AC4R", 4, 12.345)
+	require.NoError(t, err)
+	assert.Equal(t, 12.35, payment.Amount)
+	assert.Equal(t, []float64{12.35}, store.applied)
+}
+
+func TestNormalizeCode(t *testing.T) {
+	assert.Equal(t, "KX7M-P2QD-W9HT-AC4R", NormalizeCode("kx7mp2qdw9htac4r"))
+	assert.Equal(t, "KX7M-P2QD-W9HT-AC4R", NormalizeCode(" KX7M P2QD-W9HT AC4R"))
+	assert.Equal(t, "SHORT", NormalizeCode("short"), "Codes of another length are only upper cased")
+}
@@ -0,0 +1,771 @@
+// Package graphql is a small GraphQL engine for read-only queries over the existing stores.
+// It supports the query language clients use day to day (variables, aliases, fragments,
+// @skip and @include) but not mutations, subscriptions, interfaces or introspection; the
+// schema is published as SDL instead.
+//
+// Fields are resolved a level at a time: a field's resolver is called once with every
+// parent object at that level, so nested lists such as customers → invoices → payments
+// take one store call per level instead of one per row. Fields can be limited to
+// permissions, and queries are rejected before they run if they nest too deeply or are too costly.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"erp/models"
+)
+
+// ResolveFunc resolves a field for every parent at one level of a query. It returns one
+// value per parent, in order: a scalar, nil, a parent value for object fields, or a slice
+// of parent values for list fields. Root fields have a single nil parent.
+type ResolveFunc func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error)
+
+// Field is a field of an object type.
+type Field struct {
+	Name        string
+	Description string
+	Type        string // GraphQL type, e.g. "Int", "Customer" or "[Invoice]"
+	Args        []*Arg
+	Permissions []string // Permissions, one of which may query the field; empty allows every caller
+	ListSize    int      // Expected length of a list field without a "limit" argument, for complexity
+	Resolve     ResolveFunc
+}
+
+// Arg is an argument of a field.
+type Arg struct {
+	Name    string
+	Type    string      // e.g. "Int!" for a required argument
+	Default interface{} // Used when the argument is not given
+}
+
+// Object is an object type.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+func (o *Object) field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Scalar types
+var scalars = map[string]bool{"Int": true, "Float": true, "String": true, "Boolean": true, "ID": true}
+
+// defaultListSize is the expected length of lists that declare none.
+const defaultListSize = 10
+
+// Schema is a set of object types with a root query type.
+type Schema struct {
+	Query         *Object
+	MaxDepth      int // Deepest field nesting allowed; 0 is unlimited
+	MaxComplexity int // Highest query cost allowed; 0 is unlimited
+	types         map[string]*Object
+}
+
+// NewSchema creates a schema from its root query type and the object types it returns.
+//
+// Returns:
+//   - *Schema: The schema, without limits.
+//   - error: An error if a field refers to a type that is not known.
+func NewSchema(query *Object, types ...*Object) (*Schema, error) {
+	s := &Schema{Query: query, types: map[string]*Object{query.Name: query}}
+	for _, t := range types {
+		s.types[t.Name] = t
+	}
+	for _, t := range s.types {
+		for _, f := range t.Fields {
+			if name := baseType(f.Type); !scalars[name] && s.types[name] == nil {
+				return nil, fmt.Errorf("field %s.%s has unknown type %s", t.Name, f.Name, name)
+			}
+		}
+	}
+	return s, nil
+}
+
+// SDL returns the schema in the GraphQL schema definition language. Fields limited to
+// permissions carry an @auth directive.
+func (s *Schema) SDL() string {
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		if name != s.Query.Name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("directive @auth(permissions: [String!]!) on FIELD_DEFINITION\n\n")
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n}\n")
+	for _, name := range append([]string{s.Query.Name}, names...) {
+		t := s.types[name]
+		b.WriteString("\n")
+		writeDescription(&b, "", t.Description)
+		b.WriteString("type " + t.Name + " {\n")
+		for _, f := range t.Fields {
+			writeDescription(&b, "  ", f.Description)
+			b.WriteString("  " + f.Name)
+			if len(f.Args) > 0 {
+				args := make([]string, len(f.Args))
+				for i, a := range f.Args {
+					args[i] = a.Name + ": " + a.Type
+					if a.Default != nil {
+						args[i] += fmt.Sprintf(" = %#v", a.Default)
+					}
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.Type)
+			if len(f.Permissions) > 0 {
+				quoted := make([]string, len(f.Permissions))
+				for i, permission := range f.Permissions {
+					quoted[i] = fmt.Sprintf("%q", permission)
+				}
+				b.WriteString(" @auth(permissions: [" + strings.Join(quoted, ", ") + "])")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, indent, description string) {
+	if description != "" {
+		b.WriteString(indent + `"""` + description + `"""` + "\n")
+	}
+}
+
+// Request is a GraphQL request.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	// Allowed reports whether the caller holds one of permissions, for fields with
+	// Permissions. If it is nil, those fields are denied.
+	Allowed func(ctx context.Context, permissions ...string) (bool, error) `json:"-"`
+}
+
+// Response is the result of a request. Data is nil if the request was rejected before it
+// ran; otherwise Errors lists the fields that could not be resolved, which are null.
+type Response struct {
+	Data   *OrderedMap `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is an error in a response.
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+// Location is a position in the query document.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// OrderedMap is a JSON object that keeps its keys in the order of the query.
+type OrderedMap struct {
+	Keys   []string
+	Values map[string]interface{}
+}
+
+func newOrderedMap() *OrderedMap {
+	return &OrderedMap{Values: map[string]interface{}{}}
+}
+
+func (m *OrderedMap) set(key string, value interface{}) {
+	if _, ok := m.Values[key]; !ok {
+		m.Keys = append(m.Keys, key)
+	}
+	m.Values[key] = value
+}
+
+// MarshalJSON writes the keys in order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range m.Keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.Values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// Execute parses, validates and runs a query.
+//
+// Parameters:
+//   - ctx: Passed to the resolvers.
+//   - req: The query, its variables and the caller's permission check.
+//
+// Returns:
+//   - *Response: The data, or the errors that stopped the query from running.
+func (s *Schema) Execute(ctx context.Context, req *Request) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		e := &Error{Message: err.Error()}
+		if syntax, ok := err.(*SyntaxError); ok {
+			e.Message, e.Locations = syntax.Message, []Location{{syntax.Line, syntax.Col}}
+		}
+		return &Response{Errors: []*Error{e}}
+	}
+
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	vars, err := coerceVariables(op, req.Variables)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	p := &planner{schema: s, doc: doc, vars: vars}
+	nodes, err := p.plan(s.Query, op.Selections, 1)
+	if err != nil {
+		return &Response{Errors: []*Error{p.error(err)}}
+	}
+	if cost := complexity(nodes); s.MaxComplexity > 0 && cost > s.MaxComplexity {
+		return &Response{Errors: []*Error{{
+			Message: fmt.Sprintf("query complexity %d exceeds the limit of %d", cost, s.MaxComplexity),
+		}}}
+	}
+
+	e := &executor{schema: s, allowed: req.Allowed}
+	data := e.run(ctx, nodes, []interface{}{nil}, [][]interface{}{nil})
+	return &Response{Data: data[0], Errors: e.errors}
+}
+
+// selectOperation returns the operation named name, or the only operation if name is empty.
+func selectOperation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with several operations")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceVariables checks the given variables against the operation's definitions and
+// applies defaults.
+func coerceVariables(op *Operation, given map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, def := range op.Variables {
+		value, ok := given[def.Name]
+		if !ok && def.Default != nil {
+			value, ok = def.Default.Resolve(nil), true
+		}
+		coerced, err := coerce(def.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.Name, err)
+		}
+		vars[def.Name] = coerced
+	}
+	return vars, nil
+}
+
+// coerce converts a JSON or literal value to the Go type of a GraphQL input type.
+func coerce(typ string, value interface{}) (interface{}, error) {
+	if strings.HasSuffix(typ, "!") {
+		if value == nil {
+			return nil, fmt.Errorf("a value of type %s is required", typ)
+		}
+		typ = strings.TrimSuffix(typ, "!")
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		inner := typ[1 : len(typ)-1]
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerce(inner, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = coerced
+		}
+		return list, nil
+	}
+
+	switch v := value.(type) {
+	case int:
+		switch typ {
+		case "Int":
+			return v, nil
+		case "Float":
+			return float64(v), nil
+		case "ID":
+			return fmt.Sprint(v), nil
+		}
+	case float64:
+		switch typ {
+		case "Int":
+			if v == float64(int(v)) {
+				return int(v), nil
+			}
+		case "Float":
+			return v, nil
+		case "ID":
+			if v == float64(int(v)) {
+				return fmt.Sprint(int(v)), nil
+			}
+		}
+	case string:
+		if typ == "String" || typ == "ID" {
+			return v, nil
+		}
+	case bool:
+		if typ == "Boolean" {
+			return v, nil
+		}
+	}
+	if !scalars[typ] {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	shown, _ := json.Marshal(value)
+	return nil, fmt.Errorf("%s is not a valid %s", shown, typ)
+}
+
+// baseType returns the named type of a type, without list brackets and non-null marks.
+func baseType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
+
+func isList(typ string) bool {
+	return strings.HasPrefix(typ, "[")
+}
+
+// node is a field of a validated query with its arguments resolved.
+type node struct {
+	key       string
+	field     *Field // nil for __typename
+	typeName  string // Object type the field is selected on
+	args      map[string]interface{}
+	children  []*node
+	line, col int
+}
+
+// planError is a validation error at a position in the query.
+type planError struct {
+	message   string
+	line, col int
+}
+
+func (e *planError) Error() string { return e.message }
+
+// planner validates a query against the schema and resolves its fragments, directives and
+// arguments into a tree of nodes.
+type planner struct {
+	schema *Schema
+	doc    *Document
+	vars   map[string]interface{}
+}
+
+func (p *planner) error(err error) *Error {
+	e := &Error{Message: err.Error()}
+	if pe, ok := err.(*planError); ok && pe.line > 0 {
+		e.Locations = []Location{{pe.line, pe.col}}
+	}
+	return e
+}
+
+// group is the selections of one response key; they are merged into one node.
+type group struct {
+	key        string
+	selections []*Selection
+}
+
+// plan turns the selections on an object type into nodes. depth is the nesting level of
+// the selections, 1 for root fields.
+func (p *planner) plan(obj *Object, selections []*Selection, depth int) ([]*node, error) {
+	if p.schema.MaxDepth > 0 && depth > p.schema.MaxDepth {
+		s := selections[0]
+		return nil, &planError{fmt.Sprintf("the query is nested more than %d levels deep", p.schema.MaxDepth), s.Line, s.Col}
+	}
+	var groups []*group
+	if err := p.collect(obj, selections, &groups, map[string]bool{}); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*node, 0, len(groups))
+	for _, g := range groups {
+		first := g.selections[0]
+		n := &node{key: g.key, typeName: obj.Name, line: first.Line, col: first.Col}
+		for _, s := range g.selections[1:] {
+			if s.Name != first.Name {
+				return nil, &planError{fmt.Sprintf("%q selects both %s and %s", g.key, first.Name, s.Name), s.Line, s.Col}
+			}
+		}
+
+		if first.Name == "__typename" {
+			nodes = append(nodes, n)
+			continue
+		}
+		n.field = obj.field(first.Name)
+		if n.field == nil {
+			return nil, &planError{fmt.Sprintf("cannot query field %q on type %s", first.Name, obj.Name), first.Line, first.Col}
+		}
+		args, err := p.arguments(n.field, first)
+		if err != nil {
+			return nil, err
+		}
+		n.args = args
+
+		var children []*Selection
+		for _, s := range g.selections {
+			children = append(children, s.Selections...)
+		}
+		child := p.schema.types[baseType(n.field.Type)]
+		switch {
+		case child == nil && len(children) > 0:
+			return nil, &planError{fmt.Sprintf("field %q of type %s cannot have a selection", first.Name, n.field.Type), first.Line, first.Col}
+		case child != nil && len(children) == 0:
+			return nil, &planError{fmt.Sprintf("field %q of type %s must have a selection", first.Name, n.field.Type), first.Line, first.Col}
+		case child != nil:
+			if n.children, err = p.plan(child, children, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// collect groups the fields selected on obj by response key, expanding fragments and
+// dropping selections excluded by @skip or @include. fragments holds the fragments being
+// expanded, so a fragment that spreads itself is rejected.
+func (p *planner) collect(obj *Object, selections []*Selection, groups *[]*group, fragments map[string]bool) error {
+	for _, s := range selections {
+		included, err := p.included(s)
+		if err != nil {
+			return err
+		}
+		if !included {
+			continue
+		}
+
+		switch {
+		case s.Spread != "":
+			fragment := p.doc.Fragments[s.Spread]
+			if fragment == nil {
+				return &planError{fmt.Sprintf("unknown fragment %q", s.Spread), s.Line, s.Col}
+			}
+			if fragments[s.Spread] || spreads(p.doc, fragment.Selections, s.Spread, map[string]bool{}) {
+				return &planError{fmt.Sprintf("fragment %q spreads itself", s.Spread), s.Line, s.Col}
+			}
+			if fragment.On != obj.Name {
+				return &planError{fmt.Sprintf("fragment %q on %s cannot be spread on %s", s.Spread, fragment.On, obj.Name), s.Line, s.Col}
+			}
+			fragments[s.Spread] = true
+			err = p.collect(obj, fragment.Selections, groups, fragments)
+			delete(fragments, s.Spread)
+		case s.Inline:
+			if s.On != "" && s.On != obj.Name {
+				return &planError{fmt.Sprintf("an inline fragment on %s cannot be spread on %s", s.On, obj.Name), s.Line, s.Col}
+			}
+			err = p.collect(obj, s.Selections, groups, fragments)
+		default:
+			for _, g := range *groups {
+				if g.key == s.Key() {
+					g.selections = append(g.selections, s)
+					s = nil
+					break
+				}
+			}
+			if s != nil {
+				*groups = append(*groups, &group{key: s.Key(), selections: []*Selection{s}})
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spreads reports whether selections spread the fragment name at any depth, directly or
+// through other fragments.
+func spreads(doc *Document, selections []*Selection, name string, seen map[string]bool) bool {
+	for _, s := range selections {
+		if s.Spread == name {
+			return true
+		}
+		if fragment := doc.Fragments[s.Spread]; fragment != nil && !seen[s.Spread] {
+			seen[s.Spread] = true
+			if spreads(doc, fragment.Selections, name, seen) {
+				return true
+			}
+		}
+		if spreads(doc, s.Selections, name, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// included evaluates the @skip and @include directives of a selection.
+func (p *planner) included(s *Selection) (bool, error) {
+	for _, d := range s.Directives {
+		if d.Name != "skip" && d.Name != "include" {
+			return false, &planError{fmt.Sprintf("unknown directive @%s", d.Name), s.Line, s.Col}
+		}
+		if d.Value == nil {
+			return false, &planError{fmt.Sprintf("@%s needs an \"if\" argument", d.Name), s.Line, s.Col}
+		}
+		value, err := p.value(d.Value, s)
+		if err != nil {
+			return false, err
+		}
+		condition, ok := value.(bool)
+		if !ok {
+			return false, &planError{fmt.Sprintf("the \"if\" argument of @%s must be a Boolean", d.Name), s.Line, s.Col}
+		}
+		if condition == (d.Name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// arguments resolves and checks the arguments of a field.
+func (p *planner) arguments(f *Field, s *Selection) (map[string]interface{}, error) {
+	given := map[string]*Value{}
+	for _, arg := range s.Args {
+		var def *Arg
+		for _, a := range f.Args {
+			if a.Name == arg.Name {
+				def = a
+			}
+		}
+		if def == nil {
+			return nil, &planError{fmt.Sprintf("field %q has no argument %q", f.Name, arg.Name), s.Line, s.Col}
+		}
+		given[arg.Name] = arg.Value
+	}
+
+	args := map[string]interface{}{}
+	for _, def := range f.Args {
+		var value interface{}
+		if v, ok := given[def.Name]; ok {
+			var err error
+			if value, err = p.value(v, s); err != nil {
+				return nil, err
+			}
+		}
+		if value == nil {
+			value = def.Default
+		}
+		coerced, err := coerce(def.Type, value)
+		if err != nil {
+			return nil, &planError{fmt.Sprintf("argument %q of field %q: %v", def.Name, f.Name, err), s.Line, s.Col}
+		}
+		if coerced != nil {
+			args[def.Name] = coerced
+		}
+	}
+	return args, nil
+}
+
+// value resolves a literal or variable.
+func (p *planner) value(v *Value, s *Selection) (interface{}, error) {
+	if v.Kind == ValueVariable {
+		if _, ok := p.vars[v.Raw]; !ok {
+			return nil, &planError{fmt.Sprintf("variable $%s is not defined", v.Raw), s.Line, s.Col}
+		}
+	}
+	if v.Kind == ValueEnum {
+		return nil, &planError{fmt.Sprintf("%s is not a valid value", v.Raw), s.Line, s.Col}
+	}
+	return v.Resolve(p.vars), nil
+}
+
+// complexity estimates the cost of running nodes: every field costs one, and the fields
+// below a list are counted once per expected item.
+func complexity(nodes []*node) int {
+	cost := 0
+	for _, n := range nodes {
+		cost++
+		if len(n.children) == 0 {
+			continue
+		}
+		items := 1
+		if isList(n.field.Type) {
+			items = n.field.ListSize
+			if limit, ok := n.args["limit"].(int); ok {
+				items = limit
+			} else if items == 0 {
+				items = defaultListSize
+			}
+		}
+		cost += items * complexity(n.children)
+	}
+	return cost
+}
+
+// executor runs a planned query, collecting field errors.
+type executor struct {
+	schema  *Schema
+	allowed func(ctx context.Context, permissions ...string) (bool, error)
+	errors  []*Error
+}
+
+// run resolves nodes for every parent at one level and returns one object per parent.
+// paths holds the response path of each parent.
+func (e *executor) run(ctx context.Context, nodes []*node, parents []interface{}, paths [][]interface{}) []*OrderedMap {
+	results := make([]*OrderedMap, len(parents))
+	for i := range results {
+		results[i] = newOrderedMap()
+	}
+
+	for _, n := range nodes {
+		if n.field == nil {
+			for _, r := range results {
+				r.set(n.key, n.typeName)
+			}
+			continue
+		}
+		values, err := e.resolve(ctx, n, parents)
+		if err != nil {
+			e.fail(n, appendPath(paths[0], n.key), err)
+			for _, r := range results {
+				r.set(n.key, nil)
+			}
+			continue
+		}
+
+		if len(n.children) == 0 {
+			for i, r := range results {
+				r.set(n.key, values[i])
+			}
+			continue
+		}
+
+		// Gather the objects below every parent to resolve the next level in one batch
+		var children []interface{}
+		var childPaths [][]interface{}
+		counts := make([]int, len(parents))
+		for i, value := range values {
+			if value == nil {
+				counts[i] = -1
+				continue
+			}
+			path := appendPath(paths[i], n.key)
+			if !isList(n.field.Type) {
+				children, childPaths, counts[i] = append(children, value), append(childPaths, path), 1
+				continue
+			}
+			items := reflect.ValueOf(value)
+			if items.Kind() != reflect.Slice {
+				e.fail(n, path, fmt.Errorf("resolver of %s returned %T, not a list", n.field.Name, value))
+				counts[i] = -1
+				continue
+			}
+			for j := 0; j < items.Len(); j++ {
+				children, childPaths = append(children, items.Index(j).Interface()), append(childPaths, appendPath(path, j))
+			}
+			counts[i] = items.Len()
+		}
+
+		var objects []*OrderedMap
+		if len(children) > 0 {
+			objects = e.run(ctx, n.children, children, childPaths)
+		}
+		for i, r := range results {
+			switch {
+			case counts[i] < 0:
+				r.set(n.key, nil)
+			case !isList(n.field.Type):
+				r.set(n.key, objects[0])
+				objects = objects[1:]
+			default:
+				list := make([]*OrderedMap, counts[i])
+				copy(list, objects)
+				objects = objects[counts[i]:]
+				r.set(n.key, list)
+			}
+		}
+	}
+	return results
+}
+
+// resolve checks the caller may query a field and calls its resolver.
+func (e *executor) resolve(ctx context.Context, n *node, parents []interface{}) ([]interface{}, error) {
+	if len(n.field.Permissions) > 0 {
+		allowed := false
+		if e.allowed != nil {
+			var err error
+			if allowed, err = e.allowed(ctx, n.field.Permissions...); err != nil {
+				return nil, err
+			}
+		}
+		if !allowed {
+			return nil, models.PermissionDenied("field %q is limited to %s", n.field.Name, strings.Join(n.field.Permissions, ", "))
+		}
+	}
+	values, err := n.field.Resolve(ctx, parents, n.args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(parents) {
+		return nil, fmt.Errorf("resolver of %s returned %d values for %d parents", n.field.Name, len(values), len(parents))
+	}
+	for i, value := range values {
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+			values[i] = nil
+		}
+	}
+	return values, nil
+}
+
+// fail records a field error. Errors of a batch are reported once, at the path of the
+// first parent. Unexpected errors are logged and reported without their details, so SQL
+// and driver messages are not shown to clients.
+func (e *executor) fail(n *node, path []interface{}, err error) {
+	message := err.Error()
+	if models.Kind(err) == nil {
+		log.Printf("graphql: resolving %s: %v", n.field.Name, err)
+		message = fmt.Sprintf("failed to resolve %s", n.field.Name)
+	}
+	e.errors = append(e.errors, &Error{Message: message, Locations: []Location{{n.line, n.col}}, Path: path})
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+1), path...), elem)
+}
@@ -0,0 +1,245 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// author and book make a small graph: authors have books and books have an author.
+type author struct {
+	ID   int
+	Name string
+}
+
+type book struct {
+	Title    string
+	AuthorID int
+}
+
+var (
+	authors = map[int]*author{1: {1, "Le Guin"}, 2: {2, "Calvino"}}
+	books   = []*book{{"The Dispossessed", 1}, {"Earthsea", 1}, {"Invisible Cities", 2}}
+)
+
+// testSchema returns a schema and the number of resolver calls per field.
+func testSchema(t *testing.T) (*Schema, map[string]int) {
+	calls := map[string]int{}
+	counted := func(name string, fn ResolveFunc) ResolveFunc {
+		return func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			calls[name]++
+			return fn(ctx, parents, args)
+		}
+	}
+
+	authorType := &Object{Name: "Author", Fields: []*Field{
+		{Name: "id", Type: "Int", Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			values := make([]interface{}, len(parents))
+			for i, p := range parents {
+				values[i] = p.(*author).ID
+			}
+			return values, nil
+		}},
+		{Name: "name", Type: "String", Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			values := make([]interface{}, len(parents))
+			for i, p := range parents {
+				values[i] = p.(*author).Name
+			}
+			return values, nil
+		}},
+		{Name: "books", Type: "[Book]", ListSize: 3, Resolve: counted("books", func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			values := make([]interface{}, len(parents))
+			for i, p := range parents {
+				var list []*book
+				for _, b := range books {
+					if b.AuthorID == p.(*author).ID {
+						list = append(list, b)
+					}
+				}
+				values[i] = list
+			}
+			return values, nil
+		})},
+		{Name: "royalties", Type: "Float", Permissions: []string{"finance_permissions"}, Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			return make([]interface{}, len(parents)), nil
+		}},
+	}}
+	bookType := &Object{Name: "Book", Fields: []*Field{
+		{Name: "title", Type: "String", Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			values := make([]interface{}, len(parents))
+			for i, p := range parents {
+				values[i] = p.(*book).Title
+			}
+			return values, nil
+		}},
+		{Name: "author", Type: "Author", Resolve: counted("author", func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			values := make([]interface{}, len(parents))
+			for i, p := range parents {
+				values[i] = authors[p.(*book).AuthorID]
+			}
+			return values, nil
+		})},
+	}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{Name: "author", Type: "Author", Args: []*Arg{{Name: "id", Type: "Int!"}}, Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			return []interface{}{authors[args["id"].(int)]}, nil
+		}},
+		{Name: "authors", Type: "[Author]", Args: []*Arg{{Name: "limit", Type: "Int", Default: 2}}, Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			return []interface{}{[]*author{authors[1], authors[2]}}, nil
+		}},
+		{Name: "broken", Type: "String", Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			return nil, errors.New("pq: connection refused")
+		}},
+	}}
+
+	schema, err := NewSchema(query, authorType, bookType)
+	require.NoError(t, err)
+	return schema, calls
+}
+
+func execute(t *testing.T, schema *Schema, req *Request) (string, *Response) {
+	response := schema.Execute(context.Background(), req)
+	data, err := json.Marshal(response.Data)
+	require.NoError(t, err)
+	return string(data), response
+}
+
+func TestExecuteBatchesEachLevel(t *testing.T) {
+	schema, calls := testSchema(t)
+
+	data, response := execute(t, schema, &Request{Query: `{
+		authors { name books { title author { name } } }
+	}`})
+	assert.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"authors": [
+		{"name": "Le Guin", "books": [
+			{"title": "The Dispossessed", "author": {"name": "Le Guin"}},
+			{"title": "Earthsea", "author": {"name": "Le Guin"}}]},
+		{"name": "Calvino", "books": [{"title": "Invisible Cities", "author": {"name": "Calvino"}}]}
+	]}`, data)
+	assert.Equal(t, map[string]int{"books": 1, "author": 1}, calls, "Each field is resolved once per level")
+}
+
+func TestExecuteKeepsQueryOrder(t *testing.T) {
+	schema, _ := testSchema(t)
+
+	data, _ := execute(t, schema, &Request{Query: `{ author(id: 2) { name id __typename } }`})
+	assert.Equal(t, `{"author":{"name":"Calvino","id":2,"__typename":"Author"}}`, data)
+}
+
+func TestExecuteVariablesAliasesAndFragments(t *testing.T) {
+	schema, _ := testSchema(t)
+
+	data, response := execute(t, schema, &Request{
+		Query: `query Pair($first: Int!, $second: Int = 2, $withBooks: Boolean!) {
+			a: author(id: $first) { ...Names }
+			b: author(id: $second) { ... on Author { id } books @include(if: $withBooks) { title } }
+		}
+		fragment Names on Author { name id @skip(if: true) }`,
+		Variables: map[string]interface{}{"first": float64(1), "withBooks": false},
+	})
+	require.Empty(t, response.Errors)
+	assert.Equal(t, `{"a":{"name":"Le Guin"},"b":{"id":2}}`, data)
+}
+
+func TestExecuteFieldErrors(t *testing.T) {
+	schema, _ := testSchema(t)
+
+	data, response := execute(t, schema, &Request{Query: `{ author(id: 1) { name royalties } broken }`, Allowed: holds("sales_permissions")})
+	assert.Equal(t, `{"author":{"name":"Le Guin","royalties":null},"broken":null}`, data)
+	require.Len(t, response.Errors, 2)
+	assert.Equal(t, `field "royalties" is limited to finance_permissions`, response.Errors[0].Message)
+	assert.Equal(t, []interface{}{"author", "royalties"}, response.Errors[0].Path)
+	assert.Equal(t, "failed to resolve broken", response.Errors[1].Message, "Driver errors are not shown")
+
+	_, response = execute(t, schema, &Request{Query: `{ author(id: 1) { royalties } }`, Allowed: holds("finance_permissions")})
+	assert.Empty(t, response.Errors)
+
+	_, response = execute(t, schema, &Request{Query: `{ author(id: 1) { royalties } }`})
+	require.Len(t, response.Errors, 1, "Fields with permissions are denied without a check")
+}
+
+// holds returns a permission check for a caller granted the given permissions.
+func holds(granted ...string) func(ctx context.Context, permissions ...string) (bool, error) {
+	return func(ctx context.Context, permissions ...string) (bool, error) {
+		for _, permission := range permissions {
+			for _, g := range granted {
+				if g == permission {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+}
+
+func TestExecuteRejectsInvalidQueries(t *testing.T) {
+	tests := map[string]struct {
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		"syntax":             {query: `{ author(id: 1) { name }`, want: "unexpected end of document"},
+		"mutation":           {query: `mutation { author }`, want: "only queries are supported"},
+		"unknown field":      {query: `{ author(id: 1) { email } }`, want: `cannot query field "email" on type Author`},
+		"missing argument":   {query: `{ author { name } }`, want: `argument "id" of field "author": a value of type Int! is required`},
+		"unknown argument":   {query: `{ author(id: 1, name: "x") { name } }`, want: `field "author" has no argument "name"`},
+		"wrong type":         {query: `{ author(id: "one") { name } }`, want: `"one" is not a valid Int`},
+		"undefined variable": {query: `{ author(id: $id) { name } }`, want: "variable $id is not defined"},
+		"bad variable":       {query: `query ($id: Int!) { author(id: $id) { name } }`, vars: map[string]interface{}{"id": 1.5}, want: "variable $id: 1.5 is not a valid Int"},
+		"no selection":       {query: `{ author(id: 1) }`, want: "must have a selection"},
+		"scalar selection":   {query: `{ author(id: 1) { name { first } } }`, want: "cannot have a selection"},
+		"unknown fragment":   {query: `{ author(id: 1) { ...Missing } }`, want: `unknown fragment "Missing"`},
+		"fragment cycle":     {query: `{ author(id: 1) { ...A } } fragment A on Author { books { author { ...A } } }`, want: `fragment "A" spreads itself`},
+		"conflicting alias":  {query: `{ author(id: 1) { x: name x: id } }`, want: `"x" selects both name and id`},
+		"unknown directive":  {query: `{ author(id: 1) { name @cached } }`, want: "unknown directive @cached"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			schema, _ := testSchema(t)
+			response := schema.Execute(context.Background(), &Request{Query: tc.query, Variables: tc.vars})
+			assert.Nil(t, response.Data)
+			require.Len(t, response.Errors, 1)
+			assert.Contains(t, response.Errors[0].Message, tc.want)
+		})
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	schema, calls := testSchema(t)
+	schema.MaxDepth = 3
+
+	response := schema.Execute(context.Background(), &Request{Query: `{ authors { books { author { name } } } }`})
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, "the query is nested more than 3 levels deep", response.Errors[0].Message)
+	assert.Equal(t, []Location{{1, 30}}, response.Errors[0].Locations)
+
+	schema.MaxDepth, schema.MaxComplexity = 0, 20
+	// authors (1) + 2 expected authors × (books (1) + 3 expected books × (title (1)))
+	response = schema.Execute(context.Background(), &Request{Query: `{ authors { books { title } } }`})
+	assert.Empty(t, response.Errors, "A cost of 9 is allowed")
+
+	response = schema.Execute(context.Background(), &Request{Query: `{ authors(limit: 5) { books { title author { name } } } }`})
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, "query complexity 51 exceeds the limit of 20", response.Errors[0].Message)
+	assert.Equal(t, 1, calls["books"], "Rejected queries do not run")
+}
+
+func TestSDL(t *testing.T) {
+	schema, _ := testSchema(t)
+	sdl := schema.SDL()
+	assert.Contains(t, sdl, "type Query {\n  author(id: Int!): Author\n  authors(limit: Int = 2): [Author]\n")
+	assert.Contains(t, sdl, "  royalties: Float @auth(permissions: [\"finance_permissions\"])\n")
+}
+
+func TestParseStrings(t *testing.T) {
+	doc, err := Parse(`{ author(id: 1) { name(format: "a \"quoted\"\né", other: """ block """) } }`)
+	require.NoError(t, err)
+	args := doc.Operations[0].Selections[0].Selections[0].Args
+	assert.Equal(t, "a \"quoted\"\né", args[0].Value.Raw)
+	assert.Equal(t, "block", args[1].Value.Raw)
+}
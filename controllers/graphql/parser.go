@@ -0,0 +1,654 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a parsed GraphQL request document.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query in a document. Mutations and subscriptions are rejected by the
+// parser.
+type Operation struct {
+	Name       string
+	Variables  []*VariableDef
+	Selections []*Selection
+}
+
+// VariableDef declares a variable of an operation.
+type VariableDef struct {
+	Name    string
+	Type    string // e.g. "Int!" or "[String]"
+	Default *Value
+}
+
+// Fragment is a named fragment definition.
+type Fragment struct {
+	Name       string
+	On         string
+	Selections []*Selection
+}
+
+// Selection is a field, a fragment spread (Spread is set) or an inline fragment (Inline is
+// set) in a selection set.
+type Selection struct {
+	Alias      string
+	Name       string
+	Args       []*Argument
+	Directives []*Argument // Directive name and its "if" argument; only @skip and @include are supported
+	Selections []*Selection
+	Spread     string
+	Inline     bool
+	On         string // Type condition of an inline fragment
+	Line, Col  int
+}
+
+// Key returns the name a field has in the response: its alias, or its name.
+func (s *Selection) Key() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// Argument is a named value. Directives are also stored as Arguments, with the value of
+// their "if" argument.
+type Argument struct {
+	Name  string
+	Value *Value
+}
+
+// Value kinds
+const (
+	ValueVariable = iota
+	ValueInt
+	ValueFloat
+	ValueString
+	ValueBoolean
+	ValueNull
+	ValueEnum
+	ValueList
+	ValueObject
+)
+
+// Value is a literal or variable in a document.
+type Value struct {
+	Kind   int
+	Raw    string      // The variable name, or the literal as written (strings unescaped)
+	List   []*Value    // Items of a list
+	Fields []*Argument // Fields of an input object
+}
+
+// Resolve returns the Go value of v: int, float64, string, bool, nil, []interface{} or
+// map[string]interface{}. Variables are looked up in vars.
+func (v *Value) Resolve(vars map[string]interface{}) interface{} {
+	switch v.Kind {
+	case ValueVariable:
+		return vars[v.Raw]
+	case ValueInt:
+		n, _ := strconv.Atoi(v.Raw)
+		return n
+	case ValueFloat:
+		f, _ := strconv.ParseFloat(v.Raw, 64)
+		return f
+	case ValueString, ValueEnum:
+		return v.Raw
+	case ValueBoolean:
+		return v.Raw == "true"
+	case ValueList:
+		list := make([]interface{}, len(v.List))
+		for i, item := range v.List {
+			list[i] = item.Resolve(vars)
+		}
+		return list
+	case ValueObject:
+		object := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			object[field.Name] = field.Value.Resolve(vars)
+		}
+		return object
+	}
+	return nil
+}
+
+// Parse parses a GraphQL document. Only queries are supported.
+//
+// Returns:
+//   - *Document: The operations and fragments of the document.
+//   - error: A *SyntaxError describing where the document is invalid.
+func Parse(src string) (*Document, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	doc := &Document{Fragments: map[string]*Fragment{}}
+	for p.peek().kind != tokenEOF {
+		switch t := p.peek(); {
+		case t.is("{") || t.is("query"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case t.is("fragment"):
+			fragment, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.Fragments[fragment.Name]; dup {
+				return nil, p.errorAt(t, "fragment %q is defined twice", fragment.Name)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		case t.is("mutation") || t.is("subscription"):
+			return nil, p.errorAt(t, "only queries are supported")
+		default:
+			return nil, p.errorAt(t, "unexpected %s", t)
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, &SyntaxError{Message: "the document has no operation", Line: 1, Col: 1}
+	}
+	return doc, nil
+}
+
+// SyntaxError is returned for a document that cannot be parsed.
+type SyntaxError struct {
+	Message   string
+	Line, Col int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Col, e.Message)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorAt(t token, format string, args ...interface{}) error {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Line: t.line, Col: t.col}
+}
+
+// expect consumes the punctuator or keyword s.
+func (p *parser) expect(s string) error {
+	if t := p.next(); !t.is(s) {
+		return p.errorAt(t, "expected %q, found %s", s, t)
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokenName {
+		return "", p.errorAt(t, "expected a name, found %s", t)
+	}
+	return t.value, nil
+}
+
+func (p *parser) operation() (*Operation, error) {
+	op := &Operation{}
+	if p.peek().is("query") {
+		p.next()
+		if p.peek().kind == tokenName {
+			op.Name = p.next().value
+		}
+		if p.peek().is("(") {
+			p.next()
+			for !p.peek().is(")") {
+				def, err := p.variableDef()
+				if err != nil {
+					return nil, err
+				}
+				op.Variables = append(op.Variables, def)
+			}
+			p.next()
+		}
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selections = selections
+	return op, nil
+}
+
+func (p *parser) variableDef() (*VariableDef, error) {
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	typ, err := p.typeRef()
+	if err != nil {
+		return nil, err
+	}
+	def := &VariableDef{Name: name, Type: typ}
+	if p.peek().is("=") {
+		p.next()
+		if def.Default, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+	return def, nil
+}
+
+func (p *parser) typeRef() (string, error) {
+	var typ string
+	if p.peek().is("[") {
+		p.next()
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.peek().is("!") {
+		p.next()
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *parser) fragment() (*Fragment, error) {
+	p.next()
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.errorAt(p.tokens[p.pos-1], "a fragment cannot be named \"on\"")
+	}
+	if err := p.expect("on"); err != nil {
+		return nil, err
+	}
+	on, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &Fragment{Name: name, On: on, Selections: selections}, nil
+}
+
+func (p *parser) selectionSet() ([]*Selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []*Selection
+	for !p.peek().is("}") {
+		if p.peek().kind == tokenEOF {
+			return nil, p.errorAt(p.peek(), "unexpected end of document")
+		}
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	p.next()
+	if len(selections) == 0 {
+		return nil, p.errorAt(p.tokens[p.pos-1], "a selection set cannot be empty")
+	}
+	return selections, nil
+}
+
+func (p *parser) selection() (*Selection, error) {
+	start := p.peek()
+	s := &Selection{Line: start.line, Col: start.col}
+	var err error
+
+	if start.is("...") {
+		p.next()
+		if p.peek().kind == tokenName && !p.peek().is("on") {
+			s.Spread = p.next().value
+			s.Directives, err = p.directives()
+			return s, err
+		}
+		s.Inline = true
+		if p.peek().is("on") {
+			p.next()
+			if s.On, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if s.Directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		s.Selections, err = p.selectionSet()
+		return s, err
+	}
+
+	if s.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.peek().is(":") {
+		p.next()
+		s.Alias = s.Name
+		if s.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().is("(") {
+		if s.Args, err = p.arguments(); err != nil {
+			return nil, err
+		}
+	}
+	if s.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek().is("{") {
+		s.Selections, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *parser) arguments() ([]*Argument, error) {
+	p.next()
+	var args []*Argument
+	for !p.peek().is(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, &Argument{Name: name, Value: value})
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *parser) directives() ([]*Argument, error) {
+	var directives []*Argument
+	for p.peek().is("@") {
+		p.next()
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		directive := &Argument{Name: name}
+		if p.peek().is("(") {
+			args, err := p.arguments()
+			if err != nil {
+				return nil, err
+			}
+			for _, arg := range args {
+				if arg.Name == "if" {
+					directive.Value = arg.Value
+				}
+			}
+		}
+		directives = append(directives, directive)
+	}
+	return directives, nil
+}
+
+// value parses a value; constant values, such as variable defaults, cannot hold variables.
+func (p *parser) value(constant bool) (*Value, error) {
+	t := p.next()
+	switch {
+	case t.is("$") && !constant:
+		name, err := p.name()
+		return &Value{Kind: ValueVariable, Raw: name}, err
+	case t.kind == tokenInt:
+		return &Value{Kind: ValueInt, Raw: t.value}, nil
+	case t.kind == tokenFloat:
+		return &Value{Kind: ValueFloat, Raw: t.value}, nil
+	case t.kind == tokenString:
+		return &Value{Kind: ValueString, Raw: t.value}, nil
+	case t.is("true") || t.is("false"):
+		return &Value{Kind: ValueBoolean, Raw: t.value}, nil
+	case t.is("null"):
+		return &Value{Kind: ValueNull}, nil
+	case t.kind == tokenName:
+		return &Value{Kind: ValueEnum, Raw: t.value}, nil
+	case t.is("["):
+		list := &Value{Kind: ValueList, List: []*Value{}}
+		for !p.peek().is("]") {
+			if p.peek().kind == tokenEOF {
+				return nil, p.errorAt(p.peek(), "unexpected end of document")
+			}
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list.List = append(list.List, item)
+		}
+		p.next()
+		return list, nil
+	case t.is("{"):
+		object := &Value{Kind: ValueObject}
+		for !p.peek().is("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			object.Fields = append(object.Fields, &Argument{Name: name, Value: value})
+		}
+		p.next()
+		return object, nil
+	}
+	return nil, p.errorAt(t, "expected a value, found %s", t)
+}
+
+// Token kinds
+const (
+	tokenEOF = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind      int
+	value     string
+	line, col int
+}
+
+// is reports whether t is the punctuator or name s.
+func (t token) is(s string) bool {
+	return (t.kind == tokenPunctuator || t.kind == tokenName) && t.value == s
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of document"
+	case tokenString:
+		return strconv.Quote(t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// tokenize splits a document into tokens, dropping whitespace, commas and comments.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line, lineStart := 1, 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		col := i - lineStart + 1
+		switch {
+		case c == '\n':
+			line, lineStart = line+1, i+1
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, token{tokenPunctuator, "...", line, col})
+			i += 3
+		case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+			tokens = append(tokens, token{tokenPunctuator, string(c), line, col})
+			i++
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenName, src[start:i], line, col})
+		case c == '-' || isDigit(c):
+			start, kind := i, tokenInt
+			if c == '-' {
+				i++
+			}
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '.' {
+				kind = tokenFloat
+				for i++; i < len(src) && isDigit(src[i]); i++ {
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				kind = tokenFloat
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			raw := src[start:i]
+			if _, err := strconv.ParseFloat(raw, 64); err != nil {
+				return nil, &SyntaxError{Message: fmt.Sprintf("invalid number %q", raw), Line: line, Col: col}
+			}
+			tokens = append(tokens, token{kind, raw, line, col})
+		case c == '"':
+			value, n, err := readString(src[i:])
+			if err != nil {
+				return nil, &SyntaxError{Message: err.Error(), Line: line, Col: col}
+			}
+			tokens = append(tokens, token{tokenString, value, line, col})
+			for _, r := range src[i : i+n] {
+				if r == '\n' {
+					line++
+				}
+			}
+			if nl := strings.LastIndexByte(src[i:i+n], '\n'); nl >= 0 {
+				lineStart = i + nl + 1
+			}
+			i += n
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return nil, &SyntaxError{Message: fmt.Sprintf("unexpected character %q", r), Line: line, Col: col}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, line: line, col: len(src) - lineStart + 1}), nil
+}
+
+// readString reads a quoted or block string at the start of src and returns its value and
+// the number of bytes it spans.
+func readString(src string) (string, int, error) {
+	if strings.HasPrefix(src, `"""`) {
+		end := strings.Index(src[3:], `"""`)
+		if end < 0 {
+			return "", 0, fmt.Errorf("unterminated string")
+		}
+		return strings.TrimSpace(src[3 : 3+end]), end + 6, nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("unterminated string")
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			switch e := src[i]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if i+4 >= len(src) {
+					return "", 0, fmt.Errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
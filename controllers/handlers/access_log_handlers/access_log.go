@@ -0,0 +1,94 @@
+package access_log_handlers
+
+import (
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultDays is how far back the report goes when no start date is given.
+	defaultDays = 30
+	// defaultLimit and maxLimit bound the number of events returned.
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// AccessLogHandler provides the access log report.
+type AccessLogHandler struct {
+	Store models.AccessLogStore
+	Now   func() time.Time
+}
+
+// GetAccessLog lists sign-in attempts, newest first, for security review.
+//
+// HTTP Method: GET
+// URL Path: /reports/access_log?email=&ip=&success=true|false&new=true&from=YYYY-MM-DD&to=YYYY-MM-DD&limit=100
+// (from defaults to 30 days ago and to to today; new=true keeps only sign-ins from a new
+// location or device)
+//
+// Response:
+//   - Status Code: 200 (OK) with the list of events in JSON.
+//   - Status Code: 400 (Bad Request) if a filter is invalid or from is after to.
+//   - Status Code: 500 (Internal Server Error) if the events cannot be read.
+func (h *AccessLogHandler) GetAccessLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r, h.Now())
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.Store.ListAccessEvents(r.Context(), filter)
+	if err != nil {
+		httperr.Write(w, err, "Failed to read access log")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, events)
+}
+
+// parseFilter reads the report's query parameters.
+func parseFilter(r *http.Request, now time.Time) (models.AccessLogFilter, error) {
+	query := r.URL.Query()
+	filter := models.AccessLogFilter{Email: query.Get("email"), IP: query.Get("ip"), Limit: defaultLimit}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to := today.AddDate(0, 0, -defaultDays), today
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			return filter, models.Invalid("invalid from, expected YYYY-MM-DD")
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			return filter, models.Invalid("invalid to, expected YYYY-MM-DD")
+		}
+	}
+	if from.After(to) {
+		return filter, models.Invalid("from must not be after to")
+	}
+	filter.From, filter.To = from, to.AddDate(0, 0, 1)
+
+	if value := query.Get("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, models.Invalid("invalid success, expected true or false")
+		}
+		filter.Success = &success
+	}
+	if value := query.Get("new"); value != "" {
+		if filter.NewOnly, err = strconv.ParseBool(value); err != nil {
+			return filter, models.Invalid("invalid new, expected true or false")
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit <= 0 || filter.Limit > maxLimit {
+			return filter, models.Invalid("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,89 @@
+package access_log_handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockAccessLogStore keeps the last filter it was queried with.
+type mockAccessLogStore struct {
+	filter models.AccessLogFilter
+}
+
+func (m *mockAccessLogStore) RecordAccess(ctx context.Context, event *models.AccessEvent) error {
+	return nil
+}
+
+func (m *mockAccessLogStore) ListAccessEvents(ctx context.Context, filter models.AccessLogFilter) ([]models.AccessEvent, error) {
+	m.filter = filter
+	return []models.AccessEvent{}, nil
+}
+
+func TestGetAccessLog(t *testing.T) {
+	store := &mockAccessLogStore{}
+	handler := &AccessLogHandler{Store: store, Now: func() time.Time { return time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC) }}
+	get := func(query string) int {
+		rr := httptest.NewRecorder()
+		handler.GetAccessLog(rr, httptest.NewRequest("GET", "/reports/access_log"+query, nil))
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get(""))
+	assert.Equal(t, time.Date(2026, 9, 16, 0, 0, 0, 0, time.UTC), store.filter.From)
+	assert.Equal(t, time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), store.filter.To, "to is inclusive")
+	assert.Equal(t, defaultLimit, store.filter.Limit)
+
+	assert.Equal(t, http.StatusOK, get("?email=jane@example.com&success=false&new=true&from=2026-10-01&to=2026-10-02&limit=5"))
+	assert.Equal(t, "jane@example.com", store.filter.Email)
+	require.NotNil(t, store.filter.Success)
+	assert.False(t, *store.filter.Success)
+	assert.True(t, store.filter.NewOnly)
+	assert.Equal(t, time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC), store.filter.To)
+	assert.Equal(t, 5, store.filter.Limit)
+
+	assert.Equal(t, http.StatusBadRequest, get("?success=maybe"))
+	assert.Equal(t, http.StatusBadRequest, get("?from=2026-10-05&to=2026-10-01"))
+	assert.Equal(t, http.StatusBadRequest, get("?limit=5000"))
+}
+
+func TestRecordAccessFlagsNewLocations(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBAccessLogStore{DB: db}
+
+	event := &models.AccessEvent{Email: "jane@example.com", Success: true, IP: "198.51.100.4", Network: "198.51.100.0/24", UserAgent: "Firefox/131.0"}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM access_log WHERE lower(email) = lower($1) AND success")).
+		WithArgs("jane@example.com", "198.51.100.0/24", "Firefox/131.0").
+		WillReturnRows(sqlmock.NewRows([]string{"seen", "network", "device"}).AddRow(true, false, true))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO access_log")).
+		WithArgs("jane@example.com", true, "", "198.51.100.4", "198.51.100.0/24", "", "Firefox/131.0", true, false, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	require.NoError(t, store.RecordAccess(ctx, event))
+	assert.True(t, event.NewLocation)
+	assert.False(t, event.NewDevice)
+
+	// A first sign-in has nothing to compare with and is not flagged
+	event = &models.AccessEvent{Email: "new@example.com", Success: true, Network: "198.51.100.0/24"}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM access_log")).
+		WillReturnRows(sqlmock.NewRows([]string{"seen", "network", "device"}).AddRow(false, false, false))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO access_log")).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	require.NoError(t, store.RecordAccess(ctx, event))
+	assert.False(t, event.NewLocation || event.NewDevice)
+
+	// Failures are stored without comparison
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO access_log")).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	require.NoError(t, store.RecordAccess(ctx, &models.AccessEvent{Email: "x@example.com", Reason: models.AccessUnknownUser}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,87 @@
+// Package access_log_handlers provides SQL-backed storage of sign-in attempts and the
+// access log report for security review.
+package access_log_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/models"
+)
+
+// DBAccessLogStore provides SQL-backed methods for the access_log table.
+type DBAccessLogStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// RecordAccess stores a sign-in attempt. Successful sign-ins are compared with the user's
+// earlier successful sign-ins first, to flag new networks and user agents.
+//
+// Parameters:
+//   - event: The attempt; its ID is populated, and NewLocation and NewDevice for successes.
+//
+// Returns:
+//   - error: An error if the query fails.
+func (store *DBAccessLogStore) RecordAccess(ctx context.Context, event *models.AccessEvent) error {
+	if event.Success {
+		var seen, knownNetwork, knownDevice bool
+		err := store.DB.QueryRowContext(ctx,
+			`SELECT COUNT(*) > 0, COALESCE(BOOL_OR(network = $2), FALSE), COALESCE(BOOL_OR(user_agent = $3), FALSE)
+			 FROM access_log WHERE lower(email) = lower($1) AND success`,
+			event.Email, event.Network, event.UserAgent,
+		).Scan(&seen, &knownNetwork, &knownDevice)
+		if err != nil {
+			return err
+		}
+		event.NewLocation, event.NewDevice = seen && !knownNetwork, seen && !knownDevice
+	}
+
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO access_log (email, success, reason, ip, network, forwarded_for, user_agent, new_location, new_device, occurred_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		event.Email, event.Success, event.Reason, event.IP, event.Network, event.ForwardedFor, event.UserAgent,
+		event.NewLocation, event.NewDevice, event.OccurredAt,
+	).Scan(&event.ID)
+}
+
+// ListAccessEvents returns the sign-in attempts matching the filter.
+//
+// Parameters:
+//   - filter: The criteria; empty fields match every event.
+//
+// Returns:
+//   - []models.AccessEvent: The events, newest first.
+//   - error: An error if the query fails.
+func (store *DBAccessLogStore) ListAccessEvents(ctx context.Context, filter models.AccessLogFilter) ([]models.AccessEvent, error) {
+	var success sql.NullBool
+	if filter.Success != nil {
+		success = sql.NullBool{Bool: *filter.Success, Valid: true}
+	}
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, email, success, reason, ip, network, forwarded_for, user_agent, new_location, new_device, occurred_at
+		 FROM access_log
+		 WHERE ($1 = '' OR lower(email) = lower($1))
+		   AND ($2 = '' OR ip = $2)
+		   AND ($3::boolean IS NULL OR success = $3)
+		   AND (NOT $4 OR new_location OR new_device)
+		   AND occurred_at >= $5 AND occurred_at < $6
+		 ORDER BY occurred_at DESC, id DESC
+		 LIMIT $7`,
+		filter.Email, filter.IP, success, filter.NewOnly, filter.From, filter.To, filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AccessEvent{}
+	for rows.Next() {
+		var e models.AccessEvent
+		err := rows.Scan(&e.ID, &e.Email, &e.Success, &e.Reason, &e.IP, &e.Network, &e.ForwardedFor, &e.UserAgent,
+			&e.NewLocation, &e.NewDevice, &e.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
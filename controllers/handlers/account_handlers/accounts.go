@@ -0,0 +1,206 @@
+// Package account_handlers provides HTTP handlers and the database store for the chart of
+// accounts: hierarchical account codes, account types, and activating and deactivating
+// accounts.
+package account_handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/accounts"
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// AccountHandler provides HTTP handlers for the chart of accounts. The rules live in the
+// accounts service; the handlers only translate HTTP.
+type AccountHandler struct {
+	Service *accounts.Service
+}
+
+// AccountRequest is the request body for adding an account. The parent follows from the
+// code; the account starts active.
+type AccountRequest struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// RenameRequest is the request body for renaming an account.
+type RenameRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     int    `json:"version"` // The version renamed, unless sent in If-Match
+}
+
+// RegisterRoutes maps chart of accounts routes to their respective handler functions.
+func RegisterRoutes(router *mux.Router, service *accounts.Service) {
+	handler := &AccountHandler{Service: service}
+
+	router.HandleFunc("", handler.CreateAccount).Methods("POST")
+	router.HandleFunc("", handler.ListAccounts).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetAccount).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.UpdateAccount).Methods("PUT")
+	router.HandleFunc("/{id:[0-9]+}/activate", handler.ActivateAccount).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/deactivate", handler.DeactivateAccount).Methods("POST")
+}
+
+// CreateAccount adds an account to the chart.
+//
+// HTTP Method: POST
+// URL Path: /accounts
+//
+// Request Body:
+//   - JSON with code (e.g. "1.1.20"; its parent is "1.1"), name, type (asset, liability,
+//     equity, income or expense) and an optional description.
+//
+// Response:
+//   - Status Code: 201 (Created) with the account in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is not JSON.
+//   - Status Code: 409 (Conflict) if the code is taken.
+//   - Status Code: 422 (Unprocessable Entity) if the account is incomplete (the error details list
+//     the invalid fields) or its parent is missing, inactive or of another type.
+//   - Status Code: 500 (Internal Server Error) if the account cannot be added.
+func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	var req AccountRequest
+	if !validation.DecodeJSON(w, r, &req) {
+		return
+	}
+	account := models.Account{Code: req.Code, Name: req.Name, Type: req.Type, Description: req.Description}
+	if err := h.Service.Create(r.Context(), &account); err != nil {
+		httperr.Write(w, err, "Failed to create account")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, account)
+}
+
+// ListAccounts lists the chart of accounts in code order.
+//
+// HTTP Method: GET
+// URL Path: /accounts?type=expense&active=true (both are optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of accounts in JSON.
+//   - Status Code: 400 (Bad Request) if active is not a boolean.
+//   - Status Code: 422 (Unprocessable Entity) if the type is unknown.
+//   - Status Code: 500 (Internal Server Error) if the accounts cannot be loaded.
+func (h *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	var activeOnly bool
+	if value := r.URL.Query().Get("active"); value != "" {
+		var err error
+		if activeOnly, err = strconv.ParseBool(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid active flag")
+			return
+		}
+	}
+	list, err := h.Service.List(r.Context(), r.URL.Query().Get("type"), activeOnly)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load accounts")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// GetAccount returns an account.
+//
+// HTTP Method: GET
+// URL Path: /accounts/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the account in JSON and its version as the ETag.
+//   - Status Code: 404 (Not Found) if the account does not exist.
+//   - Status Code: 500 (Internal Server Error) if the account cannot be loaded.
+func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	account, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load account")
+		return
+	}
+	versioning.SetETag(w, account.Version)
+	respond.JSON(w, http.StatusOK, account)
+}
+
+// UpdateAccount renames an account. Its code and type cannot be changed.
+//
+// HTTP Method: PUT
+// URL Path: /accounts/{id}
+//
+// Request Body:
+//   - JSON with name, description and the version read; the version may be sent as an
+//     If-Match header instead.
+//
+// Response:
+//   - Status Code: 200 (OK) with the account in JSON and its new version as the ETag.
+//   - Status Code: 400 (Bad Request) if the request body is not JSON.
+//   - Status Code: 404 (Not Found) if the account does not exist.
+//   - Status Code: 409 (Conflict) if the account was changed since the version read.
+//   - Status Code: 422 (Unprocessable Entity) if the name is empty.
+//   - Status Code: 428 (Precondition Required) if no version is given.
+//   - Status Code: 500 (Internal Server Error) if the account cannot be updated.
+func (h *AccountHandler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req RenameRequest
+	if !validation.DecodeJSON(w, r, &req) {
+		return
+	}
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
+		return
+	}
+	account, err := h.Service.Rename(r.Context(), id, version, req.Name, req.Description)
+	if err != nil {
+		httperr.Write(w, err, "Failed to update account")
+		return
+	}
+	versioning.SetETag(w, account.Version)
+	respond.JSON(w, http.StatusOK, account)
+}
+
+// ActivateAccount lets an inactive account be used on new records again.
+//
+// HTTP Method: POST
+// URL Path: /accounts/{id}/activate
+//
+// Response:
+//   - Status Code: 200 (OK) with the account in JSON.
+//   - Status Code: 404 (Not Found) if the account does not exist.
+//   - Status Code: 409 (Conflict) if its parent is inactive.
+//   - Status Code: 500 (Internal Server Error) if the account cannot be changed.
+func (h *AccountHandler) ActivateAccount(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Activate, "Failed to activate account")
+}
+
+// DeactivateAccount stops an account being used on new records. Records already posted to
+// it are kept.
+//
+// HTTP Method: POST
+// URL Path: /accounts/{id}/deactivate
+//
+// Response:
+//   - Status Code: 200 (OK) with the account in JSON.
+//   - Status Code: 404 (Not Found) if the account does not exist.
+//   - Status Code: 409 (Conflict) if it has active sub-accounts.
+//   - Status Code: 500 (Internal Server Error) if the account cannot be changed.
+func (h *AccountHandler) DeactivateAccount(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Deactivate, "Failed to deactivate account")
+}
+
+// change applies a change to the account in the URL and writes the result.
+func (h *AccountHandler) change(w http.ResponseWriter, r *http.Request,
+	apply func(ctx context.Context, id int) (*models.Account, error), failure string) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	account, err := apply(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, failure)
+		return
+	}
+	respond.JSON(w, http.StatusOK, account)
+}
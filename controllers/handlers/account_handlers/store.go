@@ -0,0 +1,165 @@
+package account_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"erp/controllers/versioning"
+	"erp/models"
+)
+
+// DBAccountStore implements models.AccountStore using a SQL database.
+type DBAccountStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// accountColumns are the columns of accounts read by scanAccount.
+const accountColumns = `id, code, name, type, parent_id, description, active, created_at, updated_at, version`
+
+// scanAccount reads a row of accountColumns.
+func scanAccount(row interface{ Scan(...interface{}) error }) (models.Account, error) {
+	var a models.Account
+	var parentID sql.NullInt64
+	err := row.Scan(&a.ID, &a.Code, &a.Name, &a.Type, &parentID, &a.Description, &a.Active, &a.CreatedAt, &a.UpdatedAt,
+		&a.Version)
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		a.ParentID = &id
+	}
+	return a, err
+}
+
+// CreateAccount adds an account to the chart.
+//
+// Parameters:
+//   - account: A validated account; its ID and times are set.
+//
+// Returns:
+//   - error: A conflict if the code is taken, or the query error.
+func (s *DBAccountStore) CreateAccount(ctx context.Context, account *models.Account) error {
+	account.CreatedAt = time.Now()
+	account.UpdatedAt = account.CreatedAt
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO accounts (code, name, type, parent_id, description, active, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, version`,
+		account.Code, account.Name, account.Type, account.ParentID, account.Description, account.Active,
+		account.CreatedAt, account.UpdatedAt,
+	).Scan(&account.ID, &account.Version)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("an account with code %q already exists", account.Code)
+	}
+	return err
+}
+
+// GetAccount returns an account by its ID.
+func (s *DBAccountStore) GetAccount(ctx context.Context, id int) (*models.Account, error) {
+	a, err := scanAccount(s.DB.QueryRowContext(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("account %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAccountByCode returns an account by its code.
+func (s *DBAccountStore) GetAccountByCode(ctx context.Context, code string) (*models.Account, error) {
+	a, err := scanAccount(s.DB.QueryRowContext(ctx, `SELECT `+accountColumns+` FROM accounts WHERE code = $1`, code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("account %s not found", code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAccounts returns the accounts of a type, or of every type if accountType is empty, in
+// code order: "1.2" comes before "1.10".
+func (s *DBAccountStore) ListAccounts(ctx context.Context, accountType string, activeOnly bool) ([]models.Account, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+accountColumns+` FROM accounts
+		 WHERE ($1 = '' OR type = $1) AND (NOT $2 OR active)
+		 ORDER BY string_to_array(code, '.')::int[]`, accountType, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accounts := []models.Account{}
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// UpdateAccount changes an account's name and description if it is still at
+// account.Version; its update time and new version are set.
+func (s *DBAccountStore) UpdateAccount(ctx context.Context, account *models.Account) error {
+	account.UpdatedAt = time.Now()
+	err := s.DB.QueryRowContext(ctx,
+		`UPDATE accounts SET name = $1, description = $2, updated_at = $3 WHERE id = $4 AND version = $5 RETURNING version`,
+		account.Name, account.Description, account.UpdatedAt, account.ID, account.Version).Scan(&account.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return versioning.Stale(ctx, s.DB, `SELECT version FROM accounts WHERE id = $1`, "account", account.ID, account.Version)
+	}
+	return err
+}
+
+// SetAccountActive activates or deactivates an account. The account is locked, so a
+// sub-account cannot be activated while its parent is being deactivated.
+//
+// Returns:
+//   - *models.Account: The account after the change.
+//   - error: A not found error, a conflict if the account has active sub-accounts (when
+//     deactivating) or an inactive parent (when activating), or the query error.
+func (s *DBAccountStore) SetAccountActive(ctx context.Context, id int, active bool) (*models.Account, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	a, err := scanAccount(tx.QueryRowContext(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("account %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if active && a.ParentID != nil {
+		var parentActive bool
+		if err := tx.QueryRowContext(ctx, `SELECT active FROM accounts WHERE id = $1 FOR SHARE`, *a.ParentID).Scan(&parentActive); err != nil {
+			return nil, err
+		}
+		if !parentActive {
+			return nil, models.Conflict("account %s is under an inactive account", a.Code)
+		}
+	}
+	if !active {
+		var children int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM accounts WHERE parent_id = $1 AND active`, id).Scan(&children); err != nil {
+			return nil, err
+		}
+		if children > 0 {
+			return nil, models.Conflict("account %s has %d active sub-accounts", a.Code, children)
+		}
+	}
+
+	a.Active, a.UpdatedAt = active, time.Now()
+	err = tx.QueryRowContext(ctx, `UPDATE accounts SET active = $1, updated_at = $2 WHERE id = $3 RETURNING version`, a.Active, a.UpdatedAt, id).
+		Scan(&a.Version)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,54 @@
+package account_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var columns = []string{"id", "code", "name", "type", "parent_id", "description", "active", "created_at", "updated_at", "version"}
+
+func TestDeactivateAccountWithActiveChildren(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBAccountStore{DB: db}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE id = $1 FOR UPDATE")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "1.1", "Cash", models.AccountAsset, 1, "", true, now, now, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM accounts WHERE parent_id = $1 AND active")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	_, err = store.SetAccountActive(context.Background(), 2, false)
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActivateAccountUnderInactiveParent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBAccountStore{DB: db}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM accounts WHERE id = $1 FOR UPDATE")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "1.1", "Cash", models.AccountAsset, 1, "", false, now, now, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT active FROM accounts WHERE id = $1 FOR SHARE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err = store.SetAccountActive(context.Background(), 2, true)
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
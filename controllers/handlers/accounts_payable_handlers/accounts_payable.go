@@ -4,12 +4,16 @@
 package accounts_payable_handlers
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/pagination"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
 	"erp/models"
 
 	"github.com/gorilla/mux"
@@ -19,58 +23,120 @@ import (
 // It interacts with the PaymentStore to manage bills and the FinancialTransactionStore
 // for related financial transactions.
 type AccountsPayableHandler struct {
-	PaymentStore     models.PaymentStore                // PaymentStore manages payable bill records.
+	PaymentStore     models.PaymentStore              // PaymentStore manages payable bill records.
 	TransactionStore models.FinancialTransactionStore // TransactionStore manages associated financial transactions.
+	// ApprovalRequired reports whether bills created by the request wait for approval;
+	// when nil they are approved as soon as they are recorded.
+	ApprovalRequired func(r *http.Request) bool
 }
 
-// RegisterRoutes maps accounts payable routes to their respective handler functions.
-// This function registers the routes to the provided router, associating them with
-// the appropriate handler methods.
+// CreateBillRequest is the request body for creating a bill. The ID and payment date are
+// set by the server.
+type CreateBillRequest struct {
+	InvoiceID     int     `json:"invoice_id"`
+	Amount        float64 `json:"amount"`
+	PaymentMethod string  `json:"payment_method"`
+}
+
+// Payment returns the payment described by the request, paid at the given time.
+func (req CreateBillRequest) Payment(paidAt time.Time) models.Payment {
+	return models.Payment{
+		InvoiceID:     req.InvoiceID,
+		Amount:        req.Amount,
+		PaymentDate:   paidAt,
+		PaymentMethod: req.PaymentMethod,
+	}
+}
+
+// Validate checks that the bill pays a positive amount by a named method.
+func (req CreateBillRequest) Validate() error {
+	var c validation.Checker
+	c.Check(req.InvoiceID >= 0, "invoice_id", "must be the ID of an existing record or 0")
+	c.Positive("amount", req.Amount)
+	c.Required("payment_method", req.PaymentMethod)
+	return c.Err()
+}
+
+// UpdateBillRequest is the request body for updating a bill. The ID is taken from the URL.
+type UpdateBillRequest struct {
+	InvoiceID     int       `json:"invoice_id"`
+	Amount        float64   `json:"amount"`
+	PaymentDate   time.Time `json:"payment_date"`
+	PaymentMethod string    `json:"payment_method"`
+	Version       int       `json:"version"` // The version being updated, unless sent as If-Match
+}
+
+// Payment returns the payment with the given ID as described by the request.
+func (req UpdateBillRequest) Payment(id int) models.Payment {
+	return models.Payment{
+		ID:            id,
+		InvoiceID:     req.InvoiceID,
+		Amount:        req.Amount,
+		PaymentDate:   req.PaymentDate,
+		PaymentMethod: req.PaymentMethod,
+		Version:       req.Version,
+	}
+}
+
+// Validate checks the fields of CreateBillRequest.Validate and that the bill has a
+// payment date.
+func (req UpdateBillRequest) Validate() error {
+	var c validation.Checker
+	c.Check(req.InvoiceID >= 0, "invoice_id", "must be the ID of an existing record or 0")
+	c.Positive("amount", req.Amount)
+	c.Date("payment_date", req.PaymentDate)
+	c.Required("payment_method", req.PaymentMethod)
+	return c.Err()
+}
+
+// RegisterRoutes maps accounts payable routes to the handler's methods. Bills are created
+// with the handler's approval setting. ApproveBill is left to the caller, as approving may
+// need other permissions than recording bills.
 //
 // Parameters:
 //   - router: The HTTP router (from the Gorilla Mux library) to which the routes are registered.
-//   - paymentStore: An implementation of the PaymentStore interface for managing payments.
-//   - transactionStore: An implementation of the FinancialTransactionStore interface for managing transactions.
-func RegisterRoutes(router *mux.Router, paymentStore models.PaymentStore, transactionStore models.FinancialTransactionStore) {
-	handler := &AccountsPayableHandler{PaymentStore: paymentStore, TransactionStore: transactionStore}
-
-	router.HandleFunc("", handler.CreateBill).Methods("POST")
-	router.HandleFunc("/{id}", handler.GetBill).Methods("GET")
-	router.HandleFunc("/{id}", handler.UpdateBill).Methods("PUT")
-	router.HandleFunc("/{id}", handler.DeleteBill).Methods("DELETE")
+func (h *AccountsPayableHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.CreateBill).Methods("POST")
+	router.HandleFunc("", h.ListBills).Methods("GET")
+	router.HandleFunc("/{id}", h.GetBill).Methods("GET")
+	router.HandleFunc("/{id}", h.UpdateBill).Methods("PUT")
+	router.HandleFunc("/{id}", h.DeleteBill).Methods("DELETE")
 }
 
 // CreateBill creates a new payable bill entry in the system. The bill data is extracted
 // from the request body, and the current time is assigned as the payment date before
-// saving it to the database.
+// saving it to the database. The signed-in user is recorded as its creator. While
+// approvals are required the bill is created pending and must be approved with ApproveBill.
 //
 // HTTP Method: POST
 // URL Path: / (root path of accounts payable routes)
 //
 // Request Body:
-//   - JSON representation of a Payment object.
+//   - JSON with invoice_id, amount and payment_method (see CreateBillRequest).
 //
 // Response:
 //   - Status Code: 201 (Created) with the created bill in JSON format.
-//   - Status Code: 400 (Bad Request) if the input data is invalid.
+//   - Status Code: 400 (Bad Request) if the input data is not JSON.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid; the error details list them.
 //   - Status Code: 500 (Internal Server Error) if the bill creation fails.
 func (h *AccountsPayableHandler) CreateBill(w http.ResponseWriter, r *http.Request) {
-	var payment models.Payment
-	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
-		http.Error(w, "Invalid input data", http.StatusBadRequest)
+	var req CreateBillRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	payment.PaymentDate = time.Now() // Set the payment date to the current time.
-	if err := h.PaymentStore.CreatePayment(&payment); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create payment: %v", err), http.StatusInternalServerError)
+	payment := req.Payment(time.Now()) // The payment date is the current time.
+	payment.CreatedBy, _ = middleware.GetUserEmailFromContext(r.Context())
+	payment.Status = models.PaymentApproved
+	if h.ApprovalRequired != nil && h.ApprovalRequired(r) {
+		payment.Status = models.PaymentPending
+	}
+	if err := h.PaymentStore.CreatePayment(r.Context(), &payment); err != nil {
+		httperr.Write(w, err, "Failed to create payment")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(payment); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	respond.JSON(w, http.StatusCreated, payment)
 }
 
 // GetBill retrieves and returns a bill by its ID. The ID is parsed from the URL path,
@@ -86,19 +152,48 @@ func (h *AccountsPayableHandler) CreateBill(w http.ResponseWriter, r *http.Reque
 func (h *AccountsPayableHandler) GetBill(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid bill ID")
 		return
 	}
 
-	bill, err := h.PaymentStore.GetPaymentByID(id)
+	bill, err := h.PaymentStore.GetPaymentByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Bill not found: %v", err), http.StatusNotFound)
+		httperr.Write(w, err, "Failed to load bill")
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(bill); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	versioning.SetETag(w, bill.Version)
+	respond.JSON(w, http.StatusOK, bill)
+}
+
+// ListBills lists bills a page at a time.
+//
+// HTTP Method: GET
+// URL Path: /?status=pending&sort=-payment_date&page=1&limit=50 (root path of accounts payable routes)
+//
+// Query Parameters:
+//   - page, limit: The page number (from 1) and its size (default 50, at most 200).
+//   - sort: id, invoice_id, amount, payment_date, payment_method, status or created_by,
+//     prefixed with "-" for descending order.
+//   - The same fields as filters; text is matched case-insensitively and payment_date
+//     matches a day formatted as YYYY-MM-DD.
+//
+// Response:
+//   - Status Code: 200 (OK) with {"items": [...], "total": n, "page": p, "limit": l} in JSON format.
+//   - Status Code: 422 (Unprocessable Entity) if a parameter is invalid.
+//   - Status Code: 500 (Internal Server Error) if the bills cannot be listed.
+func (h *AccountsPayableHandler) ListBills(w http.ResponseWriter, r *http.Request) {
+	query, err := pagination.Parse(r, PaymentColumns)
+	if err != nil {
+		httperr.Write(w, err, "Invalid list query")
+		return
 	}
+	bills, total, err := h.PaymentStore.ListPayments(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Failed to list bills")
+		return
+	}
+	pagination.Write(w, bills, total, query)
 }
 
 // UpdateBill modifies an existing bill's details. The updated bill data is read from the
@@ -108,35 +203,43 @@ func (h *AccountsPayableHandler) GetBill(w http.ResponseWriter, r *http.Request)
 // URL Path: /{id} (ID of the bill in the path)
 //
 // Request Body:
-//   - JSON representation of a Payment object (excluding the ID, which is taken from the URL).
+//   - JSON with invoice_id, amount, payment_date, payment_method and the version being
+//     updated (see UpdateBillRequest); the version may instead be sent as If-Match.
 //
 // Response:
-//   - Status Code: 200 (OK) with the updated bill in JSON format.
+//   - Status Code: 200 (OK) with the updated bill in JSON format and its new version as ETag.
 //   - Status Code: 400 (Bad Request) if the ID or input data is invalid.
+//   - Status Code: 404 (Not Found) if the bill does not exist.
+//   - Status Code: 409 (Conflict) if the bill has been approved by an approver or changed
+//     since the version being updated.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid; the error details list them.
+//   - Status Code: 428 (Precondition Required) if no version is given.
 //   - Status Code: 500 (Internal Server Error) if the update operation fails.
 func (h *AccountsPayableHandler) UpdateBill(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid bill ID")
 		return
 	}
 
-	var payment models.Payment
-	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
-		http.Error(w, "Invalid input data", http.StatusBadRequest)
+	var req UpdateBillRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	payment.ID = id
-	if err := h.PaymentStore.UpdatePayment(&payment); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update bill: %v", err), http.StatusInternalServerError)
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
 		return
 	}
-
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(payment); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	payment := req.Payment(id)
+	payment.Version = version
+	if err := h.PaymentStore.UpdatePayment(r.Context(), &payment); err != nil {
+		httperr.Write(w, err, "Failed to update bill")
+		return
 	}
+
+	versioning.SetETag(w, payment.Version)
+	respond.JSON(w, http.StatusOK, payment)
 }
 
 // DeleteBill deletes a bill by its ID. The ID is extracted from the URL path, and the
@@ -148,18 +251,49 @@ func (h *AccountsPayableHandler) UpdateBill(w http.ResponseWriter, r *http.Reque
 // Response:
 //   - Status Code: 204 (No Content) if the bill is successfully deleted.
 //   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the bill does not exist.
 //   - Status Code: 500 (Internal Server Error) if the deletion operation fails.
 func (h *AccountsPayableHandler) DeleteBill(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid bill ID")
 		return
 	}
 
-	if err := h.PaymentStore.DeletePayment(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete bill: %v", err), http.StatusInternalServerError)
+	if err := h.PaymentStore.DeletePayment(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete bill")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// ApproveBill approves a pending bill, which posts the payment. Segregation of duties
+// forbids approving a bill you recorded yourself while the policy for payments is enabled.
+//
+// HTTP Method: POST
+// URL Path: /{id}/approve (ID of the bill in the path)
+//
+// Response:
+//   - Status Code: 200 (OK) with the approved bill in JSON format.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 403 (Forbidden) if the approver recorded the bill.
+//   - Status Code: 404 (Not Found) if the bill does not exist.
+//   - Status Code: 409 (Conflict) if the bill is not pending approval.
+//   - Status Code: 500 (Internal Server Error) if the approval fails.
+func (h *AccountsPayableHandler) ApproveBill(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid bill ID")
+		return
+	}
+
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	bill, err := h.PaymentStore.ApprovePayment(r.Context(), id, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to approve bill")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, bill)
+}
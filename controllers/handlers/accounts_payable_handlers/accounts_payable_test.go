@@ -5,13 +5,16 @@ package accounts_payable_handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"erp/controllers/middleware"
+	"erp/controllers/pagination"
 	"erp/models"
 
 	"github.com/gorilla/mux"
@@ -32,9 +35,10 @@ type MockPaymentStore struct {
 //
 // Returns:
 //   - error: Always nil, as this is a simulated operation.
-func (m *MockPaymentStore) CreatePayment(payment *models.Payment) error {
+func (m *MockPaymentStore) CreatePayment(ctx context.Context, payment *models.Payment) error {
 	m.nextID++
 	payment.ID = m.nextID
+	payment.Version = 1
 	m.payments[payment.ID] = payment
 	return nil
 }
@@ -47,10 +51,10 @@ func (m *MockPaymentStore) CreatePayment(payment *models.Payment) error {
 // Returns:
 //   - *Payment: Pointer to the retrieved payment, if found.
 //   - error: "payment not found" if no payment exists with the given ID.
-func (m *MockPaymentStore) GetPaymentByID(id int) (*models.Payment, error) {
+func (m *MockPaymentStore) GetPaymentByID(ctx context.Context, id int) (*models.Payment, error) {
 	payment, exists := m.payments[id]
 	if !exists {
-		return nil, errors.New("payment not found")
+		return nil, models.ErrNotFound
 	}
 	return payment, nil
 }
@@ -61,12 +65,17 @@ func (m *MockPaymentStore) GetPaymentByID(id int) (*models.Payment, error) {
 //   - payment: Pointer to the Payment object with updated details.
 //
 // Returns:
-//   - error: "payment not found" if the payment ID does not exist in the store.
-func (m *MockPaymentStore) UpdatePayment(payment *models.Payment) error {
-	_, exists := m.payments[payment.ID]
+//   - error: "payment not found" if the payment ID does not exist in the store, or a
+//     conflict if it is no longer at payment.Version.
+func (m *MockPaymentStore) UpdatePayment(ctx context.Context, payment *models.Payment) error {
+	existing, exists := m.payments[payment.ID]
 	if !exists {
-		return errors.New("payment not found")
+		return models.ErrNotFound
 	}
+	if existing.Version != payment.Version {
+		return models.Conflict("payment %d was changed by someone else", payment.ID)
+	}
+	payment.Version++
 	m.payments[payment.ID] = payment
 	return nil
 }
@@ -78,15 +87,45 @@ func (m *MockPaymentStore) UpdatePayment(payment *models.Payment) error {
 //
 // Returns:
 //   - error: "payment not found" if no payment exists with the given ID.
-func (m *MockPaymentStore) DeletePayment(id int) error {
+func (m *MockPaymentStore) DeletePayment(ctx context.Context, id int) error {
 	_, exists := m.payments[id]
 	if !exists {
-		return errors.New("payment not found")
+		return models.ErrNotFound
 	}
 	delete(m.payments, id)
 	return nil
 }
 
+// ApprovePayment approves a pending payment in the mock store, refusing approval by the
+// payment's creator as the segregation-of-duties policy does.
+func (m *MockPaymentStore) ApprovePayment(ctx context.Context, id int, actor string) (*models.Payment, error) {
+	payment, exists := m.payments[id]
+	if !exists {
+		return nil, models.ErrNotFound
+	}
+	if payment.Status != models.PaymentPending {
+		return nil, models.Conflict("payment %d is not pending approval", id)
+	}
+	if strings.EqualFold(payment.CreatedBy, actor) {
+		return nil, models.ErrSameCreatorApprover
+	}
+	payment.Status, payment.ApprovedBy = models.PaymentApproved, actor
+	return payment, nil
+}
+
+// ListPayments returns the payments in the mock store sorted by ID; filters and sort orders
+// are not simulated.
+func (m *MockPaymentStore) ListPayments(ctx context.Context, query models.ListQuery) ([]models.Payment, int, error) {
+	payments := []models.Payment{}
+	for id := 1; id <= m.nextID; id++ {
+		if payment, exists := m.payments[id]; exists {
+			payments = append(payments, *payment)
+		}
+	}
+	start, end := pagination.Bounds(len(payments), query)
+	return payments[start:end], len(payments), nil
+}
+
 // TestCreateBill tests the CreateBill handler for adding a new payment.
 //
 // Steps:
@@ -177,6 +216,7 @@ func TestUpdateBill(t *testing.T) {
 		Amount:        100.50,
 		PaymentDate:   time.Now(),
 		PaymentMethod: "credit_card",
+		Version:       1,
 	}
 
 	updatedPayment := models.Payment{
@@ -191,6 +231,7 @@ func TestUpdateBill(t *testing.T) {
 		t.Fatal(err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 
 	rr := httptest.NewRecorder()
 
@@ -199,10 +240,18 @@ func TestUpdateBill(t *testing.T) {
 	router.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"2"`, rr.Header().Get("ETag"))
 
 	var gotPayment models.Payment
 	json.NewDecoder(rr.Body).Decode(&gotPayment)
 	assert.Equal(t, updatedPayment.Amount, gotPayment.Amount)
+
+	// Replaying the same update is based on a version that no longer exists
+	req, _ = http.NewRequest("PUT", "/accounts_payable/1", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"1"`)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
 }
 
 // TestDeleteBill tests the DeleteBill handler for removing a payment by ID.
@@ -239,3 +288,55 @@ func TestDeleteBill(t *testing.T) {
 	_, exists := store.payments[1]
 	assert.False(t, exists)
 }
+
+func TestDeleteMissingBill(t *testing.T) {
+	store := &MockPaymentStore{payments: make(map[int]*models.Payment)}
+	handler := &AccountsPayableHandler{PaymentStore: store}
+
+	req, err := http.NewRequest("DELETE", "/accounts_payable/7", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts_payable/{id}", handler.DeleteBill).Methods("DELETE")
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestApproveBill(t *testing.T) {
+	store := &MockPaymentStore{payments: make(map[int]*models.Payment)}
+	handler := &AccountsPayableHandler{PaymentStore: store, ApprovalRequired: func(r *http.Request) bool { return true }}
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts_payable", handler.CreateBill).Methods("POST")
+	router.HandleFunc("/accounts_payable/{id}/approve", handler.ApproveBill).Methods("POST")
+
+	serve := func(path, body, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmail, user))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("/accounts_payable", `{"invoice_id": 123, "amount": 900, "payment_method": "bank_transfer"}`, "clerk@example.com")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, models.PaymentPending, store.payments[1].Status)
+	assert.Equal(t, "clerk@example.com", store.payments[1].CreatedBy)
+
+	// The clerk who recorded the bill cannot approve it
+	rr = serve("/accounts_payable/1/approve", "", "Clerk@example.com")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = serve("/accounts_payable/1/approve", "", "manager@example.com")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var approved models.Payment
+	json.NewDecoder(rr.Body).Decode(&approved)
+	assert.Equal(t, models.PaymentApproved, approved.Status)
+	assert.Equal(t, "manager@example.com", approved.ApprovedBy)
+
+	rr = serve("/accounts_payable/1/approve", "", "manager@example.com")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
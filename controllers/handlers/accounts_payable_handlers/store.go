@@ -4,31 +4,61 @@
 package accounts_payable_handlers
 
 import (
+	"context"
 	"database/sql"
+	"erp/controllers/approvals"
+	"erp/controllers/events"
+	"erp/controllers/pagination"
+	"erp/controllers/versioning"
 	"erp/models"
-	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 // DBPaymentStore provides SQL-backed methods to manage payments.
 // It implements the CRUD operations required for managing the `payments` table
 // in the database.
 type DBPaymentStore struct {
-	DB *sql.DB // DB represents the database connection.
+	DB        *sql.DB           // DB represents the database connection.
+	Approvals *approvals.Engine // Enforces segregation of duties on approval; nil disables the check
 }
 
 // CreatePayment inserts a new payment into the database.
 // It generates a new ID for the payment and stores it in the provided `Payment` object.
+// Payments without a status are approved. For approved payments the PaymentPosted event
+// is written to the outbox in the same transaction; pending ones post it on approval.
 //
 // Parameters:
 //   - payment: A pointer to the `Payment` object containing the payment details to be stored.
 //
 // Returns:
 //   - error: An error if the query fails or the insertion is unsuccessful.
-func (store *DBPaymentStore) CreatePayment(payment *models.Payment) error {
-	return store.DB.QueryRow(
-		"INSERT INTO payments (invoice_id, amount, payment_date, payment_method) VALUES ($1, $2, $3, $4) RETURNING id",
-		payment.InvoiceID, payment.Amount, payment.PaymentDate, payment.PaymentMethod,
-	).Scan(&payment.ID)
+func (store *DBPaymentStore) CreatePayment(ctx context.Context, payment *models.Payment) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if payment.Status == "" {
+		payment.Status = models.PaymentApproved
+	}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO payments (invoice_id, amount, payment_date, payment_method, status, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, version`,
+		payment.InvoiceID, payment.Amount, payment.PaymentDate, payment.PaymentMethod, payment.Status, nullString(payment.CreatedBy),
+	).Scan(&payment.ID, &payment.Version)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status == models.PaymentApproved {
+		if err := events.Enqueue(ctx, tx, events.PaymentPosted, "payment", payment.ID, payment); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // GetPaymentByID retrieves a payment by its ID from the database.
@@ -38,54 +68,169 @@ func (store *DBPaymentStore) CreatePayment(payment *models.Payment) error {
 //
 // Returns:
 //   - *Payment: A pointer to the `Payment` object containing the retrieved payment details.
-//   - error: An error if the query fails or no payment is found with the provided ID.
-func (store *DBPaymentStore) GetPaymentByID(id int) (*models.Payment, error) {
-	row := store.DB.QueryRow("SELECT id, invoice_id, amount, payment_date, payment_method FROM payments WHERE id = $1", id)
+//   - error: models.ErrNotFound if no payment exists with the provided ID, or the query error.
+func (store *DBPaymentStore) GetPaymentByID(ctx context.Context, id int) (*models.Payment, error) {
+	return getPayment(ctx, store.DB, id, "")
+}
+
+// paymentColumns are the columns of payments read into a models.Payment. Payments drafted
+// for purchase order receipts have no invoice.
+const paymentColumns = `id, COALESCE(invoice_id, 0), COALESCE(purchase_order_id, 0), amount, payment_date,
+	COALESCE(payment_method, ''), status, created_by, approved_by, approved_at, version`
+
+// getPayment reads a payment with the given database or transaction; suffix is appended
+// to the query, e.g. "FOR UPDATE".
+func getPayment(ctx context.Context, q models.Queryer, id int, suffix string) (*models.Payment, error) {
+	row := q.QueryRowContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE id = $1 AND deleted_at IS NULL `+suffix, id,
+	)
 
 	var payment models.Payment
-	err := row.Scan(&payment.ID, &payment.InvoiceID, &payment.Amount, &payment.PaymentDate, &payment.PaymentMethod)
+	err := scanPayment(row, &payment)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("payment %d not found", id)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &payment, nil
 }
 
-// UpdatePayment updates an existing payment in the database.
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPayment reads a row of paymentColumns into payment.
+func scanPayment(row rowScanner, payment *models.Payment) error {
+	var createdBy, approvedBy sql.NullString
+	var approvedAt sql.NullTime
+	if err := row.Scan(&payment.ID, &payment.InvoiceID, &payment.PurchaseOrderID, &payment.Amount, &payment.PaymentDate,
+		&payment.PaymentMethod, &payment.Status, &createdBy, &approvedBy, &approvedAt, &payment.Version); err != nil {
+		return err
+	}
+	payment.CreatedBy, payment.ApprovedBy = createdBy.String, approvedBy.String
+	if approvedAt.Valid {
+		payment.ApprovedAt = &approvedAt.Time
+	}
+	return nil
+}
+
+// PaymentColumns are the fields payments can be listed by.
+var PaymentColumns = pagination.Columns{
+	"id":                {Expr: "id", Type: pagination.Int},
+	"invoice_id":        {Expr: "invoice_id", Type: pagination.Int},
+	"purchase_order_id": {Expr: "purchase_order_id", Type: pagination.Int},
+	"amount":            {Expr: "amount", Type: pagination.Number},
+	"payment_date":      {Expr: "payment_date", Type: pagination.Date},
+	"payment_method":    {Expr: "payment_method", Type: pagination.Text},
+	"status":            {Expr: "status", Type: pagination.Text},
+	"created_by":        {Expr: "created_by", Type: pagination.Text},
+}
+
+// ListPayments retrieves a page of payments from the database.
 //
 // Parameters:
-//   - payment: A pointer to the `Payment` object containing the updated payment details.
+//   - query: The page, sort order and filters (see PaymentColumns).
 //
 // Returns:
-//   - error: An error if the query fails or if no payment exists with the provided ID.
-func (store *DBPaymentStore) UpdatePayment(payment *models.Payment) error {
-	result, err := store.DB.Exec(
-		"UPDATE payments SET invoice_id = $1, amount = $2, payment_date = $3, payment_method = $4 WHERE id = $5",
-		payment.InvoiceID, payment.Amount, payment.PaymentDate, payment.PaymentMethod, payment.ID,
-	)
+//   - []Payment: The page of payments.
+//   - int: The number of payments matching the filters.
+//   - error: A validation error if a filter is invalid, or the query error.
+func (store *DBPaymentStore) ListPayments(ctx context.Context, query models.ListQuery) ([]models.Payment, int, error) {
+	payments := []models.Payment{}
+	total, err := pagination.QueryWhere(ctx, store.DB,
+		paymentColumns, "payments", "deleted_at IS NULL",
+		PaymentColumns, query, func(rows *sql.Rows) error {
+			var payment models.Payment
+			if err := scanPayment(rows, &payment); err != nil {
+				return err
+			}
+			payments = append(payments, payment)
+			return nil
+		})
 	if err != nil {
+		return nil, 0, err
+	}
+	return payments, total, nil
+}
+
+// PaymentsByInvoice returns the payments of the given invoices, read with one query.
+//
+// Parameters:
+//   - invoiceIDs: The invoice IDs.
+//
+// Returns:
+//   - map[int][]models.Payment: The payments of each invoice, oldest first; deleted
+//     payments are left out.
+//   - error: An error if the query fails.
+func (store *DBPaymentStore) PaymentsByInvoice(ctx context.Context, invoiceIDs []int) (map[int][]models.Payment, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments
+		 WHERE invoice_id = ANY($1) AND deleted_at IS NULL ORDER BY payment_date, id`, pq.Array(invoiceIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byInvoice := map[int][]models.Payment{}
+	for rows.Next() {
+		var payment models.Payment
+		if err := scanPayment(rows, &payment); err != nil {
+			return nil, err
+		}
+		byInvoice[payment.InvoiceID] = append(byInvoice[payment.InvoiceID], payment)
+	}
+	return byInvoice, rows.Err()
+}
+
+// UpdatePayment updates an existing payment in the database if it is still at
+// payment.Version, and sets the new version. Payments that went through approval are
+// locked, so the approved amount cannot be changed afterwards.
+//
+// Parameters:
+//   - payment: A pointer to the `Payment` object containing the updated payment details.
+//
+// Returns:
+//   - error: models.ErrNotFound if no payment exists with the provided ID, an ErrConflict
+//     error if it has been approved by an approver or changed since it was read, or the
+//     query error.
+func (store *DBPaymentStore) UpdatePayment(ctx context.Context, payment *models.Payment) error {
+	err := store.DB.QueryRowContext(ctx,
+		`UPDATE payments SET invoice_id = NULLIF($1, 0), amount = $2, payment_date = $3, payment_method = $4
+		 WHERE id = $5 AND version = $6 AND approved_by IS NULL AND deleted_at IS NULL RETURNING version`,
+		payment.InvoiceID, payment.Amount, payment.PaymentDate, payment.PaymentMethod, payment.ID, payment.Version,
+	).Scan(&payment.Version)
+	if err != sql.ErrNoRows {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// The payment does not exist, has been approved or is at another version
+	var approved bool
+	err = store.DB.QueryRowContext(ctx, "SELECT approved_by IS NOT NULL FROM payments WHERE id = $1 AND deleted_at IS NULL", payment.ID).Scan(&approved)
+	if err == sql.ErrNoRows {
+		return models.NotFound("payment %d not found", payment.ID)
+	}
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("payment with ID %d does not exist", payment.ID)
+	if approved {
+		return models.Conflict("payment %d has been approved and can no longer be changed", payment.ID)
 	}
-
-	return nil
+	return versioning.Stale(ctx, store.DB, "SELECT version FROM payments WHERE id = $1 AND deleted_at IS NULL",
+		"payment", payment.ID, payment.Version)
 }
 
-// DeletePayment deletes a payment by its ID from the database.
+// DeletePayment soft deletes a payment by its ID: the payment is hidden from reads until it
+// is restored or purged.
 //
 // Parameters:
 //   - id: The ID of the payment to delete.
 //
 // Returns:
-//   - error: An error if the query fails or if no payment exists with the provided ID.
-func (store *DBPaymentStore) DeletePayment(id int) error {
-	result, err := store.DB.Exec("DELETE FROM payments WHERE id = $1", id)
+//   - error: models.ErrNotFound if no payment exists with the provided ID, or the query error.
+func (store *DBPaymentStore) DeletePayment(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, "UPDATE payments SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		return err
 	}
@@ -95,8 +240,64 @@ func (store *DBPaymentStore) DeletePayment(id int) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("payment with ID %d does not exist", id)
+		return models.NotFound("payment %d not found", id)
 	}
 
 	return nil
 }
+
+// ApprovePayment approves a pending payment and writes the PaymentPosted event to the
+// outbox in the same transaction. The approver may not be the user who recorded the
+// payment while the segregation-of-duties policy for payments is enabled.
+//
+// Parameters:
+//   - id: The ID of the payment to approve.
+//   - actor: The email of the user approving the payment.
+//
+// Returns:
+//   - *Payment: The approved payment.
+//   - error: models.ErrNotFound, models.ErrConflict if the payment is not pending,
+//     models.ErrSameCreatorApprover, or the query error.
+func (store *DBPaymentStore) ApprovePayment(ctx context.Context, id int, actor string) (*models.Payment, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	payment, err := getPayment(ctx, tx, id, "FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != models.PaymentPending {
+		return nil, models.Conflict("payment %d is not pending approval", id)
+	}
+	if store.Approvals != nil {
+		if err := store.Approvals.Authorize(ctx, tx, models.DocumentPayment, id, payment.CreatedBy, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE payments SET status = $1, approved_by = $2, approved_at = $3 WHERE id = $4",
+		models.PaymentApproved, actor, now, id,
+	); err != nil {
+		return nil, err
+	}
+	payment.Status, payment.ApprovedBy, payment.ApprovedAt = models.PaymentApproved, actor, &now
+	payment.Version++ // The row is locked, so approval is the only update since it was read
+
+	if err := events.Enqueue(ctx, tx, events.PaymentPosted, "payment", payment.ID, payment); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// nullString stores empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
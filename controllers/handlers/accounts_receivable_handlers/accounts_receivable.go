@@ -3,12 +3,13 @@
 package accounts_receivable_handlers
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
 	"erp/models"
 
 	"github.com/gorilla/mux"
@@ -22,6 +23,36 @@ type AccountsReceivableHandler struct {
 	TransactionStore models.FinancialTransactionStore // Store for managing related financial transactions.
 }
 
+// ReceivableRequest is the request body for creating or updating a receivable. The ID,
+// status and payment date are managed by the server.
+type ReceivableRequest struct {
+	CustomerName  string    `json:"customer_name"`
+	Amount        float64   `json:"amount"`
+	DueDate       time.Time `json:"due_date"`
+	InvoiceNumber string    `json:"invoice_number"`
+}
+
+// Receivable returns the receivable described by the request.
+func (req ReceivableRequest) Receivable() models.Receivable {
+	return models.Receivable{
+		CustomerName:  req.CustomerName,
+		Amount:        req.Amount,
+		DueDate:       req.DueDate,
+		InvoiceNumber: req.InvoiceNumber,
+	}
+}
+
+// Validate checks that the receivable names its customer and invoice and is owed a positive
+// amount by a due date.
+func (req ReceivableRequest) Validate() error {
+	var c validation.Checker
+	c.Required("customer_name", req.CustomerName)
+	c.Positive("amount", req.Amount)
+	c.Date("due_date", req.DueDate)
+	c.Required("invoice_number", req.InvoiceNumber)
+	return c.Err()
+}
+
 // RegisterRoutes registers HTTP routes for accounts receivable handlers.
 // It maps CRUD operations for payment records to the appropriate HTTP methods.
 //
@@ -44,30 +75,28 @@ func RegisterRoutes(router *mux.Router, receivableStore models.ReceivableStore,
 // URL Path: / (root path of accounts receivable routes)
 //
 // Request Body:
-//   - JSON representation of a Receivable object (excluding the payment date).
+//   - JSON with customer_name, amount, due_date and invoice_number (see ReceivableRequest).
 //
 // Response:
 //   - Status Code: 201 (Created) if the payment is successfully created.
 //   - JSON representation of the created payment on success.
-//   - Status Code: 400 (Bad Request) if the input data is invalid.
+//   - Status Code: 400 (Bad Request) if the input data is not JSON.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid; the error details list them.
 //   - Status Code: 500 (Internal Server Error) if the payment could not be saved.
 func (h *AccountsReceivableHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
-	var receivable models.Receivable
-	if err := json.NewDecoder(r.Body).Decode(&receivable); err != nil {
-		http.Error(w, "Invalid input data", http.StatusBadRequest)
+	var req ReceivableRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
+	receivable := req.Receivable()
 	receivable.PaymentDate = time.Now()
-	if err := h.ReceivableStore.CreateReceivable(&receivable); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create payment: %v", err), http.StatusInternalServerError)
+	if err := h.ReceivableStore.CreateReceivable(r.Context(), &receivable); err != nil {
+		httperr.Write(w, err, "Failed to create payment")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(receivable); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	respond.JSON(w, http.StatusCreated, receivable)
 }
 
 // GetPayment retrieves a payment record by its ID.
@@ -82,19 +111,17 @@ func (h *AccountsReceivableHandler) CreatePayment(w http.ResponseWriter, r *http
 func (h *AccountsReceivableHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid payment ID")
 		return
 	}
 
-	payment, err := h.ReceivableStore.GetReceivableByID(id)
+	payment, err := h.ReceivableStore.GetReceivableByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Payment not found: %v", err), http.StatusNotFound)
+		httperr.Write(w, err, "Failed to load payment")
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(payment); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	respond.JSON(w, http.StatusOK, payment)
 }
 
 // UpdatePayment updates an existing payment record with new data.
@@ -103,35 +130,34 @@ func (h *AccountsReceivableHandler) GetPayment(w http.ResponseWriter, r *http.Re
 // URL Path: /{id} (ID of the payment in the path)
 //
 // Request Body:
-//   - JSON representation of a Receivable object (excluding the ID, which is taken from the URL).
+//   - JSON with customer_name, amount, due_date and invoice_number (see ReceivableRequest).
 //
 // Response:
 //   - Status Code: 200 (OK) with the updated payment data in JSON format if successful.
 //   - Status Code: 400 (Bad Request) if the ID or input data is invalid.
+//   - Status Code: 404 (Not Found) if the payment does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid; the error details list them.
 //   - Status Code: 500 (Internal Server Error) if the update operation fails.
 func (h *AccountsReceivableHandler) UpdatePayment(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid payment ID")
 		return
 	}
 
-	var receivable models.Receivable
-	if err := json.NewDecoder(r.Body).Decode(&receivable); err != nil {
-		http.Error(w, "Invalid input data", http.StatusBadRequest)
+	var req ReceivableRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
+	receivable := req.Receivable()
 	receivable.ID = id
-	if err := h.ReceivableStore.UpdateReceivable(&receivable); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update payment: %v", err), http.StatusInternalServerError)
+	if err := h.ReceivableStore.UpdateReceivable(r.Context(), &receivable); err != nil {
+		httperr.Write(w, err, "Failed to update payment")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(receivable); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	respond.JSON(w, http.StatusOK, receivable)
 }
 
 // DeletePayment deletes a payment record identified by its ID.
@@ -142,16 +168,17 @@ func (h *AccountsReceivableHandler) UpdatePayment(w http.ResponseWriter, r *http
 // Response:
 //   - Status Code: 204 (No Content) if the payment is successfully deleted.
 //   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the payment does not exist.
 //   - Status Code: 500 (Internal Server Error) if the deletion operation fails.
 func (h *AccountsReceivableHandler) DeletePayment(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid payment ID")
 		return
 	}
 
-	if err := h.ReceivableStore.DeleteReceivable(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete payment: %v", err), http.StatusInternalServerError)
+	if err := h.ReceivableStore.DeleteReceivable(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete payment")
 		return
 	}
 
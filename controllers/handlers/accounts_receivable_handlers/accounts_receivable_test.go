@@ -1,6 +1,7 @@
 package accounts_receivable_handlers
 
 import (
+	"context"
 	"erp/models"
 	"testing"
 	"time"
@@ -33,7 +34,7 @@ func TestCreateReceivable(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	// Call the method
-	err = store.CreateReceivable(receivable)
+	err = store.CreateReceivable(context.Background(), receivable)
 
 	// Assert that no error occurred
 	assert.NoError(t, err)
@@ -70,7 +71,7 @@ func TestUpdateReceivable(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Call the method
-	err = store.UpdateReceivable(receivable)
+	err = store.UpdateReceivable(context.Background(), receivable)
 
 	// Assert that no error occurred
 	assert.NoError(t, err)
@@ -92,12 +93,12 @@ func TestDeleteReceivable(t *testing.T) {
 	store := &DBReceivableStore{DB: db}
 
 	// Define expected behavior for mock
-	mock.ExpectExec("DELETE FROM receivables").
+	mock.ExpectExec("UPDATE receivables SET deleted_at").
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Call the method
-	err = store.DeleteReceivable(1)
+	err = store.DeleteReceivable(context.Background(), 1)
 
 	// Assert that no error occurred
 	assert.NoError(t, err)
@@ -3,9 +3,9 @@
 package accounts_receivable_handlers
 
 import (
+	"context"
 	"database/sql"
 	"erp/models"
-	"fmt"
 )
 
 // DBReceivableStore provides SQL-backed methods for managing receivables.
@@ -22,8 +22,8 @@ type DBReceivableStore struct {
 //
 // Returns:
 //   - An error if the operation fails, or nil if the receivable is successfully created.
-func (store *DBReceivableStore) CreateReceivable(receivable *models.Receivable) error {
-	return store.DB.QueryRow(
+func (store *DBReceivableStore) CreateReceivable(ctx context.Context, receivable *models.Receivable) error {
+	return store.DB.QueryRowContext(ctx,
 		"INSERT INTO receivables (customer_name, amount, due_date, invoice_number) VALUES ($1, $2, $3, $4) RETURNING id",
 		receivable.CustomerName, receivable.Amount, receivable.DueDate, receivable.InvoiceNumber,
 	).Scan(&receivable.ID)
@@ -36,12 +36,15 @@ func (store *DBReceivableStore) CreateReceivable(receivable *models.Receivable)
 //
 // Returns:
 //   - A pointer to the Receivable object if found.
-//   - An error if the receivable does not exist or if the operation fails.
-func (store *DBReceivableStore) GetReceivableByID(id int) (*models.Receivable, error) {
-	row := store.DB.QueryRow("SELECT id, customer_name, amount, due_date, invoice_number FROM receivables WHERE id = $1", id)
+//   - models.ErrNotFound if the receivable does not exist, or an error if the operation fails.
+func (store *DBReceivableStore) GetReceivableByID(ctx context.Context, id int) (*models.Receivable, error) {
+	row := store.DB.QueryRowContext(ctx, "SELECT id, customer_name, amount, due_date, invoice_number FROM receivables WHERE id = $1 AND deleted_at IS NULL", id)
 
 	var receivable models.Receivable
 	err := row.Scan(&receivable.ID, &receivable.CustomerName, &receivable.Amount, &receivable.DueDate, &receivable.InvoiceNumber)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("receivable %d not found", id)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -54,10 +57,10 @@ func (store *DBReceivableStore) GetReceivableByID(id int) (*models.Receivable, e
 //   - receivable: A pointer to the Receivable object containing updated details. The ID field must be set.
 //
 // Returns:
-//   - An error if the operation fails, or if no rows are affected (indicating the receivable does not exist).
-func (store *DBReceivableStore) UpdateReceivable(receivable *models.Receivable) error {
-	result, err := store.DB.Exec(
-		"UPDATE receivables SET customer_name = $1, amount = $2, due_date = $3, invoice_number = $4 WHERE id = $5",
+//   - models.ErrNotFound if the receivable does not exist, or an error if the operation fails.
+func (store *DBReceivableStore) UpdateReceivable(ctx context.Context, receivable *models.Receivable) error {
+	result, err := store.DB.ExecContext(ctx,
+		"UPDATE receivables SET customer_name = $1, amount = $2, due_date = $3, invoice_number = $4 WHERE id = $5 AND deleted_at IS NULL",
 		receivable.CustomerName, receivable.Amount, receivable.DueDate, receivable.InvoiceNumber, receivable.ID,
 	)
 	if err != nil {
@@ -69,21 +72,22 @@ func (store *DBReceivableStore) UpdateReceivable(receivable *models.Receivable)
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("receivable with ID %d does not exist", receivable.ID)
+		return models.NotFound("receivable %d not found", receivable.ID)
 	}
 
 	return nil
 }
 
-// DeleteReceivable deletes a receivable record from the database using its ID.
+// DeleteReceivable soft deletes a receivable record using its ID: the record is hidden from
+// reads until it is restored or purged.
 //
 // Parameters:
 //   - id: The unique identifier of the receivable to be deleted.
 //
 // Returns:
-//   - An error if the operation fails, or if no rows are affected (indicating the receivable does not exist).
-func (store *DBReceivableStore) DeleteReceivable(id int) error {
-	result, err := store.DB.Exec("DELETE FROM receivables WHERE id = $1", id)
+//   - models.ErrNotFound if the receivable does not exist, or an error if the operation fails.
+func (store *DBReceivableStore) DeleteReceivable(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, "UPDATE receivables SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		return err
 	}
@@ -93,7 +97,7 @@ func (store *DBReceivableStore) DeleteReceivable(id int) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("receivable with ID %d does not exist", id)
+		return models.NotFound("receivable %d not found", id)
 	}
 
 	return nil
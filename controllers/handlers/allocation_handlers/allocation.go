@@ -0,0 +1,235 @@
+package allocation_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Allocations manages allocation rules and monthly runs. It is satisfied by
+// *allocation.Service.
+type Allocations interface {
+	Rules(ctx context.Context) ([]models.AllocationRule, error)
+	CreateRule(ctx context.Context, rule *models.AllocationRule, actor string) error
+	UpdateRule(ctx context.Context, rule *models.AllocationRule, actor string) error
+	DeleteRule(ctx context.Context, id int) error
+	Preview(ctx context.Context, period string) (*models.AllocationRun, error)
+	Run(ctx context.Context, period, actor string) (*models.AllocationRun, error)
+	Runs(ctx context.Context) ([]models.AllocationRun, error)
+	Trace(ctx context.Context, period string) (*models.AllocationRun, error)
+}
+
+// AllocationHandler provides HTTP handlers for cost center allocation.
+type AllocationHandler struct {
+	Allocations Allocations
+}
+
+// RegisterRoutes maps allocation routes to their respective handler functions. The router is
+// expected to be protected with middleware.JWTAuth and limited to accountants and admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - allocations: The allocation service.
+func RegisterRoutes(router *mux.Router, allocations Allocations) {
+	handler := &AllocationHandler{Allocations: allocations}
+
+	router.HandleFunc("/rules", handler.ListRules).Methods("GET")
+	router.HandleFunc("/rules", handler.CreateRule).Methods("POST")
+	router.HandleFunc("/rules/{id:[0-9]+}", handler.UpdateRule).Methods("PUT")
+	router.HandleFunc("/rules/{id:[0-9]+}", handler.DeleteRule).Methods("DELETE")
+	router.HandleFunc("/runs", handler.ListRuns).Methods("GET")
+	router.HandleFunc("/runs", handler.CreateRun).Methods("POST")
+	router.HandleFunc("/runs/{period}/preview", handler.PreviewRun).Methods("GET")
+	router.HandleFunc("/runs/{period}", handler.GetRun).Methods("GET")
+}
+
+// ListRules returns every allocation rule.
+//
+// HTTP Method: GET
+// URL Path: /allocations/rules
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of AllocationRules in JSON.
+//   - Status Code: 500 (Internal Server Error) if the rules cannot be read.
+func (h *AllocationHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.Allocations.Rules(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load allocation rules")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rules)
+}
+
+// CreateRule adds an allocation rule. It applies from the next month allocated.
+//
+// HTTP Method: POST
+// URL Path: /allocations/rules
+//
+// Request Body:
+//   - JSON object with "name", "source_account", "method" ("percentage" or "headcount"),
+//     "active" and "targets", each with "cost_center", "account" and, for percentage
+//     rules, "percent".
+//
+// Response:
+//   - Status Code: 201 (Created) with the AllocationRule in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if a rule with the same name exists.
+//   - Status Code: 422 (Unprocessable Entity) if the rule is incomplete or its percentages
+//     do not add up to 100.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be saved.
+func (h *AllocationHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.AllocationRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Allocations.CreateRule(r.Context(), &rule, actor); err != nil {
+		httperr.Write(w, err, "Failed to create allocation rule")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, rule)
+}
+
+// UpdateRule replaces an allocation rule. Months already allocated are not changed.
+//
+// HTTP Method: PUT
+// URL Path: /allocations/rules/{id}
+//
+// Request Body:
+//   - JSON object as for CreateRule.
+//
+// Response:
+//   - Status Code: 200 (OK) with the AllocationRule in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the rule does not exist.
+//   - Status Code: 409 (Conflict) if another rule has the same name.
+//   - Status Code: 422 (Unprocessable Entity) if the rule is incomplete or its percentages
+//     do not add up to 100.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be saved.
+func (h *AllocationHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.AllocationRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	rule.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Allocations.UpdateRule(r.Context(), &rule, actor); err != nil {
+		httperr.Write(w, err, "Failed to update allocation rule")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rule)
+}
+
+// DeleteRule removes an allocation rule. Its past allocations stay in the ledger and the trace.
+//
+// HTTP Method: DELETE
+// URL Path: /allocations/rules/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the rule was deleted.
+//   - Status Code: 404 (Not Found) if the rule does not exist.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be deleted.
+func (h *AllocationHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Allocations.DeleteRule(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete allocation rule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRuns lists the allocated months, latest first, without their lines.
+//
+// HTTP Method: GET
+// URL Path: /allocations/runs
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of AllocationRuns in JSON.
+//   - Status Code: 500 (Internal Server Error) if the runs cannot be read.
+func (h *AllocationHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.Allocations.Runs(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load allocation runs")
+		return
+	}
+	respond.JSON(w, http.StatusOK, runs)
+}
+
+// CreateRun allocates a month now rather than waiting for the scheduler.
+//
+// HTTP Method: POST
+// URL Path: /allocations/runs
+//
+// Request Body:
+//   - JSON object with "period", the month as YYYY-MM.
+//
+// Response:
+//   - Status Code: 201 (Created) with the posted AllocationRun in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the month has been allocated already.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid or has not ended.
+//   - Status Code: 500 (Internal Server Error) if the allocation cannot be posted.
+func (h *AllocationHandler) CreateRun(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Period string `json:"period"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	run, err := h.Allocations.Run(r.Context(), req.Period, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to allocate shared expenses")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, run)
+}
+
+// PreviewRun shows what allocating a month would post, without posting anything.
+//
+// HTTP Method: GET
+// URL Path: /allocations/runs/{period}/preview
+//
+// Response:
+//   - Status Code: 200 (OK) with the AllocationRun in JSON, marked as a preview.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid or has not ended.
+//   - Status Code: 500 (Internal Server Error) if the balances cannot be read.
+func (h *AllocationHandler) PreviewRun(w http.ResponseWriter, r *http.Request) {
+	run, err := h.Allocations.Preview(r.Context(), mux.Vars(r)["period"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to preview allocation")
+		return
+	}
+	respond.JSON(w, http.StatusOK, run)
+}
+
+// GetRun returns the allocation trace of a month: every share each rule posted, with the
+// driver it was computed from.
+//
+// HTTP Method: GET
+// URL Path: /allocations/runs/{period}
+//
+// Response:
+//   - Status Code: 200 (OK) with the AllocationRun in JSON.
+//   - Status Code: 404 (Not Found) if the month has not been allocated.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid.
+//   - Status Code: 500 (Internal Server Error) if the run cannot be read.
+func (h *AllocationHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	run, err := h.Allocations.Trace(r.Context(), mux.Vars(r)["period"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to load allocation")
+		return
+	}
+	respond.JSON(w, http.StatusOK, run)
+}
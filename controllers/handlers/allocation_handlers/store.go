@@ -0,0 +1,309 @@
+package allocation_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBAllocationStore provides SQL-backed methods for allocation rules and runs.
+type DBAllocationStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// ruleColumns are the columns scanned by scanRule.
+const ruleColumns = `id, name, source_account, method, targets, active, updated_by, updated_at`
+
+// scanRule reads a row selected with ruleColumns.
+func scanRule(row interface{ Scan(...interface{}) error }) (*models.AllocationRule, error) {
+	var rule models.AllocationRule
+	var targets []byte
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.SourceAccount, &rule.Method, &targets, &rule.Active,
+		&rule.UpdatedBy, &rule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(targets, &rule.Targets); err != nil {
+		return nil, fmt.Errorf("allocation rule %d: %w", rule.ID, err)
+	}
+	return &rule, nil
+}
+
+// duplicateName converts a unique violation on the rule name to a conflict.
+func duplicateName(err error, name string) error {
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("an allocation rule named %q already exists", name)
+	}
+	return err
+}
+
+// CreateAllocationRule saves a new allocation rule.
+//
+// Parameters:
+//   - rule: The validated rule; its ID is set.
+//
+// Returns:
+//   - error: A models.Conflict error if the name is taken, or an error if the insert fails.
+func (store *DBAllocationStore) CreateAllocationRule(ctx context.Context, rule *models.AllocationRule) error {
+	targets, err := json.Marshal(rule.Targets)
+	if err != nil {
+		return err
+	}
+	err = store.DB.QueryRowContext(ctx,
+		`INSERT INTO allocation_rules (name, source_account, method, targets, active, updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		rule.Name, rule.SourceAccount, rule.Method, targets, rule.Active, rule.UpdatedBy, rule.UpdatedAt,
+	).Scan(&rule.ID)
+	return duplicateName(err, rule.Name)
+}
+
+// GetAllocationRules retrieves every allocation rule, ordered by name.
+//
+// Returns:
+//   - []models.AllocationRule: The rules.
+//   - error: An error if the query fails.
+func (store *DBAllocationStore) GetAllocationRules(ctx context.Context) ([]models.AllocationRule, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT `+ruleColumns+` FROM allocation_rules ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.AllocationRule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateAllocationRule replaces an allocation rule.
+//
+// Parameters:
+//   - rule: The validated rule with its ID.
+//
+// Returns:
+//   - error: models.ErrNotFound if the rule does not exist, a models.Conflict error if the
+//     name is taken, or an error if the update fails.
+func (store *DBAllocationStore) UpdateAllocationRule(ctx context.Context, rule *models.AllocationRule) error {
+	targets, err := json.Marshal(rule.Targets)
+	if err != nil {
+		return err
+	}
+	result, err := store.DB.ExecContext(ctx,
+		`UPDATE allocation_rules SET name = $1, source_account = $2, method = $3, targets = $4, active = $5,
+		     updated_by = $6, updated_at = $7
+		 WHERE id = $8`,
+		rule.Name, rule.SourceAccount, rule.Method, targets, rule.Active, rule.UpdatedBy, rule.UpdatedAt, rule.ID,
+	)
+	if err != nil {
+		return duplicateName(err, rule.Name)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("allocation rule %d not found", rule.ID)
+	}
+	return nil
+}
+
+// DeleteAllocationRule removes an allocation rule. The trace of its past allocations keeps
+// the rule's name.
+//
+// Parameters:
+//   - id: The ID of the rule.
+//
+// Returns:
+//   - error: models.ErrNotFound if the rule does not exist, or an error if the delete fails.
+func (store *DBAllocationStore) DeleteAllocationRule(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, `DELETE FROM allocation_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("allocation rule %d not found", id)
+	}
+	return nil
+}
+
+// GetAccountActivity returns the sum of an account's financial transactions dated in
+// [from, to): its debits less its credits.
+func (store *DBAllocationStore) GetAccountActivity(ctx context.Context, account string, from, to time.Time) (float64, error) {
+	var total float64
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM financial_transactions
+		 WHERE account_type = $1 AND transaction_date >= $2 AND transaction_date < $3`,
+		account, from, to,
+	).Scan(&total)
+	return total, err
+}
+
+// GetHeadcount returns the number of active users in each department.
+func (store *DBAllocationStore) GetHeadcount(ctx context.Context) (map[string]int, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT department, COUNT(*) FROM users WHERE active AND department IS NOT NULL GROUP BY department`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	headcount := map[string]int{}
+	for rows.Next() {
+		var department string
+		var count int
+		if err := rows.Scan(&department, &count); err != nil {
+			return nil, err
+		}
+		headcount[department] = count
+	}
+	return headcount, rows.Err()
+}
+
+// SaveAllocationRun posts an allocation run to the ledger and records its trace, in one
+// transaction. Each rule's source account is credited with the sum of its lines and each
+// line's account is debited with its amount.
+//
+// Parameters:
+//   - run: The computed run; its ID and posting time are set.
+//   - postingDate: The date of the ledger lines, the last day of the month.
+//
+// Returns:
+//   - error: A models.Conflict error if the month has been allocated already, or an error
+//     if the run cannot be posted.
+func (store *DBAllocationStore) SaveAllocationRun(ctx context.Context, run *models.AllocationRun, postingDate time.Time) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO allocation_runs (period, total, skipped, actor, posted_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		run.Period, run.Total, pq.StringArray(run.Skipped), run.Actor, now,
+	).Scan(&run.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("%s has been allocated already", run.Period)
+	}
+	if err != nil {
+		return err
+	}
+	run.PostedAt = &now
+
+	credited := map[int]bool{}
+	for _, line := range run.Lines {
+		if !credited[line.RuleID] {
+			credited[line.RuleID] = true
+			err := general_ledger_handlers.InsertTransaction(ctx, tx, &models.FinancialTransaction{
+				AccountType:     line.SourceAccount,
+				Amount:          -line.SourceAmount,
+				TransactionDate: postingDate,
+				Description:     fmt.Sprintf("Allocation %s: %s", run.Period, line.RuleName),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err := general_ledger_handlers.InsertTransaction(ctx, tx, &models.FinancialTransaction{
+			AccountType:     line.Account,
+			Amount:          line.Amount,
+			TransactionDate: postingDate,
+			Description:     fmt.Sprintf("Allocation %s: %s to %s", run.Period, line.RuleName, line.CostCenter),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO allocation_lines (run_id, rule_id, rule_name, source_account, source_amount, cost_center, account, driver, share, amount)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			run.ID, line.RuleID, line.RuleName, line.SourceAccount, line.SourceAmount, line.CostCenter, line.Account,
+			line.Driver, line.Share, line.Amount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAllocationRuns retrieves the allocated months, latest first, without their lines.
+//
+// Returns:
+//   - []models.AllocationRun: The runs.
+//   - error: An error if the query fails.
+func (store *DBAllocationStore) GetAllocationRuns(ctx context.Context) ([]models.AllocationRun, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT id, period, total, skipped, actor, posted_at FROM allocation_runs ORDER BY period DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.AllocationRun{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetAllocationRun retrieves the allocation of a month with the trace of every share.
+//
+// Parameters:
+//   - period: The month, as YYYY-MM.
+//
+// Returns:
+//   - *models.AllocationRun: The run.
+//   - error: models.ErrNotFound if the month has not been allocated, or an error if the query fails.
+func (store *DBAllocationStore) GetAllocationRun(ctx context.Context, period string) (*models.AllocationRun, error) {
+	run, err := scanRun(store.DB.QueryRowContext(ctx,
+		`SELECT id, period, total, skipped, actor, posted_at FROM allocation_runs WHERE period = $1`, period))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("%s has not been allocated", period)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT rule_id, rule_name, source_account, source_amount, cost_center, account, driver, share, amount
+		 FROM allocation_lines WHERE run_id = $1 ORDER BY id`, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line models.AllocationLine
+		if err := rows.Scan(&line.RuleID, &line.RuleName, &line.SourceAccount, &line.SourceAmount, &line.CostCenter,
+			&line.Account, &line.Driver, &line.Share, &line.Amount); err != nil {
+			return nil, err
+		}
+		run.Lines = append(run.Lines, line)
+	}
+	return run, rows.Err()
+}
+
+// scanRun reads an allocation run without its lines.
+func scanRun(row interface{ Scan(...interface{}) error }) (*models.AllocationRun, error) {
+	var run models.AllocationRun
+	var skipped pq.StringArray
+	var postedAt time.Time
+	if err := row.Scan(&run.ID, &run.Period, &run.Total, &skipped, &run.Actor, &postedAt); err != nil {
+		return nil, err
+	}
+	run.Skipped, run.PostedAt, run.Lines = skipped, &postedAt, []models.AllocationLine{}
+	return &run, nil
+}
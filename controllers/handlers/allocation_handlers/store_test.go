@@ -0,0 +1,62 @@
+package allocation_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAllocationRunTwice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBAllocationStore{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO allocation_runs")).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	run := &models.AllocationRun{Period: "2025-06", Total: 100, Lines: []models.AllocationLine{{RuleID: 1, Amount: 100}}}
+	err = store.SaveAllocationRun(context.Background(), run, time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllocationRules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBAllocationStore{DB: db}
+
+	updated := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM allocation_rules ORDER BY name")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "source_account", "method", "targets", "active",
+			"updated_by", "updated_at"}).
+			AddRow(1, "Rent", "Rent", "percentage", []byte(`[{"cost_center":"Sales","account":"Rent - Sales","percent":100}]`),
+				true, "accountant@example.com", updated))
+
+	rules, err := store.GetAllocationRules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.AllocationRule{{ID: 1, Name: "Rent", SourceAccount: "Rent", Method: "percentage",
+		Targets: []models.AllocationTarget{{CostCenter: "Sales", Account: "Rent - Sales", Percent: 100}}, Active: true,
+		UpdatedBy: "accountant@example.com", UpdatedAt: updated}}, rules)
+}
+
+func TestDeleteMissingAllocationRule(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBAllocationStore{DB: db}
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM allocation_rules")).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, store.DeleteAllocationRule(context.Background(), 9), models.ErrNotFound)
+}
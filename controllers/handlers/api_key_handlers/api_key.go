@@ -0,0 +1,161 @@
+package api_key_handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// knownScopes lists the scopes an API key may be granted.
+var knownScopes = map[string]bool{
+	models.ScopeCatalogRead: true,
+	models.ScopeOrdersWrite: true,
+}
+
+// APIKeyHandler provides HTTP handlers for creating, listing and revoking API keys.
+type APIKeyHandler struct {
+	Store models.APIKeyStore
+}
+
+// CreateAPIKeyRequest is the request body for creating an API key.
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit"`
+}
+
+// CreateAPIKeyResponse returns the new key. The plaintext key is only ever shown here.
+type CreateAPIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// RegisterRoutes maps API key routes to their respective handler functions. The routes
+// must be restricted to administrators by the caller.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the APIKeyStore interface.
+func RegisterRoutes(router *mux.Router, store models.APIKeyStore) {
+	handler := &APIKeyHandler{Store: store}
+
+	router.HandleFunc("", handler.CreateAPIKey).Methods("POST")
+	router.HandleFunc("", handler.ListAPIKeys).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.RevokeAPIKey).Methods("DELETE")
+}
+
+// CreateAPIKey issues a new API key.
+//
+// HTTP Method: POST
+// URL Path: /
+//
+// Request Body:
+//   - JSON object with "name", "scopes" and an optional per-minute "rate_limit".
+//
+// Response:
+//   - Status Code: 201 (Created) with the key, including the plaintext "key" shown only once.
+//   - Status Code: 400 (Bad Request) if the name is missing or a scope is unknown.
+//   - Status Code: 500 (Internal Server Error) if the key cannot be stored.
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Scopes) == 0 {
+		respond.Error(w, http.StatusBadRequest, "A name and at least one scope are required")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !knownScopes[scope] {
+			respond.Error(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q", scope))
+			return
+		}
+	}
+	if req.RateLimit < 0 {
+		respond.Error(w, http.StatusBadRequest, "rate_limit cannot be negative")
+		return
+	}
+
+	plaintext, err := generateKey()
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Failed to generate API key")
+		return
+	}
+	key := models.APIKey{
+		Name:      req.Name,
+		Prefix:    plaintext[:12],
+		KeyHash:   middleware.HashAPIKey(plaintext),
+		Scopes:    req.Scopes,
+		RateLimit: req.RateLimit,
+	}
+	if err := h.Store.CreateAPIKey(r.Context(), &key); err != nil {
+		httperr.Write(w, err, "Failed to create API key")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, CreateAPIKeyResponse{APIKey: key, Key: plaintext})
+}
+
+// ListAPIKeys returns all API keys without their secrets.
+//
+// HTTP Method: GET
+// URL Path: /
+//
+// Response:
+//   - Status Code: 200 (OK) with the list of keys.
+//   - Status Code: 500 (Internal Server Error) if the keys cannot be read.
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.Store.ListAPIKeys(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to list API keys")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, keys)
+}
+
+// RevokeAPIKey deactivates an API key.
+//
+// HTTP Method: DELETE
+// URL Path: /{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the key was revoked.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the key does not exist.
+//   - Status Code: 500 (Internal Server Error) if the key could not be revoked.
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.Store.RevokeAPIKey(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to revoke API key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// generateKey returns a new random API key.
+func generateKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "erp_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,88 @@
+package api_key_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockAPIKeyStore keeps API keys in memory.
+type mockAPIKeyStore struct {
+	keys []*models.APIKey
+}
+
+func (m *mockAPIKeyStore) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	key.ID = len(m.keys) + 1
+	key.Active = true
+	m.keys = append(m.keys, key)
+	return nil
+}
+
+func (m *mockAPIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	for _, key := range m.keys {
+		if key.KeyHash == hash {
+			return key, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (m *mockAPIKeyStore) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	for _, key := range m.keys {
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+func (m *mockAPIKeyStore) RevokeAPIKey(ctx context.Context, id int) error {
+	if id < 1 || id > len(m.keys) {
+		return models.ErrNotFound
+	}
+	m.keys[id-1].Active = false
+	return nil
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	store := &mockAPIKeyStore{}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/api_keys").Subrouter(), store)
+
+	body, _ := json.Marshal(CreateAPIKeyRequest{Name: "Web shop", Scopes: []string{models.ScopeCatalogRead}, RateLimit: 300})
+	req := httptest.NewRequest("POST", "/api_keys", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var created CreateAPIKeyResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	assert.NotEmpty(t, created.Key)
+
+	// Only the hash is stored, and it matches the returned plaintext
+	stored, err := store.GetAPIKeyByHash(context.Background(), middleware.HashAPIKey(created.Key))
+	assert.NoError(t, err)
+	assert.Equal(t, "Web shop", stored.Name)
+	assert.NotContains(t, stored.KeyHash, created.Key)
+	assert.Equal(t, created.Key[:12], stored.Prefix)
+}
+
+func TestCreateAPIKeyRejectsUnknownScope(t *testing.T) {
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/api_keys").Subrouter(), &mockAPIKeyStore{})
+
+	body, _ := json.Marshal(CreateAPIKeyRequest{Name: "Web shop", Scopes: []string{"admin:all"}})
+	req := httptest.NewRequest("POST", "/api_keys", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
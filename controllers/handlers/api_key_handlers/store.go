@@ -0,0 +1,115 @@
+// Package api_key_handlers provides SQL-backed methods and HTTP handlers to manage the API
+// keys used by external systems.
+package api_key_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBAPIKeyStore provides SQL-backed methods for the api_keys table.
+type DBAPIKeyStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// CreateAPIKey inserts a new active API key.
+//
+// Parameters:
+//   - key: The key to store; its ID, Active flag and CreatedAt are populated.
+//
+// Returns:
+//   - error: An error if the insert fails.
+func (store *DBAPIKeyStore) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	key.Active = true
+	key.CreatedAt = time.Now()
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO api_keys (name, prefix, key_hash, scopes, rate_limit, active, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.RateLimit, key.Active, key.CreatedAt,
+	).Scan(&key.ID)
+}
+
+// GetAPIKeyByHash looks up a key by the hash of its plaintext and records that it was used.
+//
+// Parameters:
+//   - hash: The hash returned by middleware.HashAPIKey.
+//
+// Returns:
+//   - *APIKey: The matching key.
+//   - error: models.ErrNotFound if no key matches, or another error if the query fails.
+func (store *DBAPIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	var lastUsed sql.NullTime
+	err := store.DB.QueryRowContext(ctx,
+		`UPDATE api_keys SET last_used_at = $2 WHERE key_hash = $1
+		 RETURNING id, name, prefix, key_hash, scopes, rate_limit, active, created_at, last_used_at`,
+		hash, time.Now(),
+	).Scan(&key.ID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes), &key.RateLimit, &key.Active, &key.CreatedAt, &lastUsed)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns all keys, newest first. Hashes are never returned to clients.
+//
+// Returns:
+//   - []APIKey: The keys.
+//   - error: An error if the query fails.
+func (store *DBAPIKeyStore) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, name, prefix, scopes, rate_limit, active, created_at, last_used_at
+		 FROM api_keys ORDER BY id DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.RateLimit, &key.Active, &key.CreatedAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			key.LastUsedAt = &lastUsed.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey deactivates a key so it can no longer be used.
+//
+// Parameters:
+//   - id: The ID of the key to revoke.
+//
+// Returns:
+//   - error: models.ErrNotFound if the key does not exist, or an error if the update fails.
+func (store *DBAPIKeyStore) RevokeAPIKey(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, "UPDATE api_keys SET active = FALSE WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.NotFound("API key %d not found", id)
+	}
+	return nil
+}
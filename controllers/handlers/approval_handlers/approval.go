@@ -0,0 +1,84 @@
+package approval_handlers
+
+import (
+	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// SoDPolicyHandler provides the admin HTTP API for segregation-of-duties policies.
+type SoDPolicyHandler struct {
+	Store models.SoDPolicyStore
+}
+
+// SoDPolicyRequest is the request body for updating a policy.
+type SoDPolicyRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// RegisterRoutes maps segregation-of-duties policy routes to their respective handler
+// functions. The router is expected to be restricted to administrators.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the SoDPolicyStore interface.
+func RegisterRoutes(router *mux.Router, store models.SoDPolicyStore) {
+	handler := &SoDPolicyHandler{Store: store}
+
+	router.HandleFunc("", handler.ListPolicies).Methods("GET")
+	router.HandleFunc("/{document_type}", handler.UpdatePolicy).Methods("PUT")
+}
+
+// ListPolicies returns the policy of every document type.
+//
+// HTTP Method: GET
+// URL Path: /
+//
+// Response:
+//   - Status Code: 200 (OK) with the list of policies in JSON.
+//   - Status Code: 500 (Internal Server Error) if the policies cannot be read.
+func (h *SoDPolicyHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.Store.GetSoDPolicies(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load segregation of duties policies")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, policies)
+}
+
+// UpdatePolicy enables or disables the policy of a document type. Disabling it lets users
+// approve their own documents; such approvals still appear in the violations report.
+//
+// HTTP Method: PUT
+// URL Path: /{document_type} (e.g. payment or journal_entry)
+//
+// Request Body:
+//   - JSON with enabled (see SoDPolicyRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with the updated policy in JSON.
+//   - Status Code: 400 (Bad Request) if enabled is missing.
+//   - Status Code: 404 (Not Found) if the document type has no policy.
+//   - Status Code: 500 (Internal Server Error) if the policy cannot be saved.
+func (h *SoDPolicyHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	var req SoDPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+
+	policy := models.SoDPolicy{DocumentType: mux.Vars(r)["document_type"], Enabled: *req.Enabled, UpdatedBy: actor}
+	if err := h.Store.SaveSoDPolicy(r.Context(), &policy); err != nil {
+		httperr.Write(w, err, "Failed to save segregation of duties policy")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, policy)
+}
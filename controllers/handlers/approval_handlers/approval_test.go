@@ -0,0 +1,59 @@
+package approval_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockPolicyStore keeps policies in memory.
+type mockPolicyStore struct {
+	policies map[string]bool
+}
+
+func (m *mockPolicyStore) GetSoDPolicies(ctx context.Context) ([]models.SoDPolicy, error) {
+	return []models.SoDPolicy{
+		{DocumentType: models.DocumentJournalEntry, Enabled: m.policies[models.DocumentJournalEntry]},
+		{DocumentType: models.DocumentPayment, Enabled: m.policies[models.DocumentPayment]},
+	}, nil
+}
+
+func (m *mockPolicyStore) SaveSoDPolicy(ctx context.Context, policy *models.SoDPolicy) error {
+	if _, ok := m.policies[policy.DocumentType]; !ok {
+		return models.NotFound("no segregation of duties policy for %s", policy.DocumentType)
+	}
+	m.policies[policy.DocumentType] = policy.Enabled
+	return nil
+}
+
+func TestSoDPolicies(t *testing.T) {
+	store := &mockPolicyStore{policies: map[string]bool{models.DocumentPayment: true, models.DocumentJournalEntry: true}}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/sod_policies").Subrouter(), store)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := serve("PUT", "/sod_policies/journal_entry", `{"enabled": false}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, store.policies[models.DocumentJournalEntry])
+
+	rr = serve("GET", "/sod_policies", "")
+	var policies []models.SoDPolicy
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&policies))
+	assert.Equal(t, []models.SoDPolicy{{DocumentType: "journal_entry"}, {DocumentType: "payment", Enabled: true}}, policies)
+
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", "/sod_policies/payment", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve("PUT", "/sod_policies/invoice", `{"enabled": true}`).Code)
+}
@@ -0,0 +1,99 @@
+// Package approval_handlers provides SQL-backed storage and the admin HTTP API for the
+// segregation-of-duties policies enforced when documents are approved.
+package approval_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/controllers/audit"
+	"erp/models"
+	"time"
+)
+
+// DBSoDPolicyStore provides SQL-backed methods for the sod_policies table.
+type DBSoDPolicyStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// GetSoDPolicies returns the policy of every document type.
+//
+// Returns:
+//   - []models.SoDPolicy: The policies ordered by document type.
+//   - error: An error if the query fails.
+func (store *DBSoDPolicyStore) GetSoDPolicies(ctx context.Context) ([]models.SoDPolicy, error) {
+	rows, err := store.DB.QueryContext(ctx, "SELECT document_type, enabled, updated_by, updated_at FROM sod_policies ORDER BY document_type")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []models.SoDPolicy{}
+	for rows.Next() {
+		var policy models.SoDPolicy
+		var updatedBy sql.NullString
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&policy.DocumentType, &policy.Enabled, &updatedBy, &updatedAt); err != nil {
+			return nil, err
+		}
+		policy.UpdatedBy = updatedBy.String
+		if updatedAt.Valid {
+			policy.UpdatedAt = &updatedAt.Time
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// SaveSoDPolicy enables or disables the policy of a document type and records the change
+// in the audit log in the same transaction.
+//
+// Parameters:
+//   - policy: The policy; UpdatedBy must be set to the user making the change. UpdatedAt
+//     is populated.
+//
+// Returns:
+//   - error: models.ErrNotFound if the document type has no policy, or the query error.
+func (store *DBSoDPolicyStore) SaveSoDPolicy(ctx context.Context, policy *models.SoDPolicy) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx,
+		"UPDATE sod_policies SET enabled = $1, updated_by = $2, updated_at = $3 WHERE document_type = $4",
+		policy.Enabled, policy.UpdatedBy, now, policy.DocumentType,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.NotFound("no segregation of duties policy for %s", policy.DocumentType)
+	}
+
+	details, err := json.Marshal(map[string]interface{}{"document_type": policy.DocumentType, "enabled": policy.Enabled})
+	if err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      policy.UpdatedBy,
+		Action:     audit.ActionSoDPolicy,
+		EntityType: "sod_policy",
+		Details:    details,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	policy.UpdatedAt = &now
+	return nil
+}
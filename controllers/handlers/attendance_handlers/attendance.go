@@ -1,9 +1,12 @@
 // Package attendance_handlers provides an HTTP handler for creating attendance records.
-// It includes functions to create and retrieve data for employees.
+// It includes functions to create and retrieve data for employees, and the endpoints
+// employees check in and out with.
 package attendance_handlers
 
 import (
 	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
 	"erp/models"
 	"fmt"
 	"net/http"
@@ -11,6 +14,19 @@ import (
 	"time"
 )
 
+// AttendanceRequest is the request body for creating an attendance record. The ID and the
+// total hours are set by the server.
+type AttendanceRequest struct {
+	UserID   int       `json:"user_id"`
+	CheckIn  time.Time `json:"check_in"`
+	CheckOut time.Time `json:"check_out"`
+}
+
+// Attendance returns the attendance record described by the request.
+func (req AttendanceRequest) Attendance() models.Attendance {
+	return models.Attendance{UserID: req.UserID, CheckIn: req.CheckIn, CheckOut: req.CheckOut}
+}
+
 // CreateAttendanceRecord handles the creation of a new attendance record.
 // It returns an HTTP handler function to process attendance creation requests.
 //
@@ -33,33 +49,33 @@ import (
 //   - http.HandlerFunc: The HTTP handler function for creating attendance records.
 func CreateAttendanceRecord(store models.AttendanceStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var attendance models.Attendance
+		var req AttendanceRequest
 
 		// Decode the JSON body from the request
-		if err := json.NewDecoder(r.Body).Decode(&attendance); err != nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid request payload")
 			return
 		}
+		attendance := req.Attendance()
 
 		// Calculate total hours worked if both check-in and check-out are provided
 		if !attendance.CheckIn.IsZero() && !attendance.CheckOut.IsZero() {
 			duration := attendance.CheckOut.Sub(attendance.CheckIn)
 			if duration < 0 {
-				http.Error(w, "Check-out time cannot be before check-in time", http.StatusBadRequest)
+				respond.Error(w, http.StatusBadRequest, "Check-out time cannot be before check-in time")
 				return
 			}
 			attendance.TotalHours = duration.Hours()
 		}
 
 		// Create the attendance record in the database
-		if err := store.CreateAttendance(&attendance); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create attendance: %v", err), http.StatusInternalServerError)
+		if err := store.CreateAttendance(r.Context(), &attendance); err != nil {
+			httperr.Write(w, err, "Failed to create attendance")
 			return
 		}
 
 		// Respond with the created attendance record
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(attendance)
+		respond.JSON(w, http.StatusCreated, attendance)
 	}
 }
 
@@ -84,27 +100,26 @@ func GetAttendanceByUserID(store models.AttendanceStore) http.HandlerFunc {
 		// Extract the user_id from query parameters
 		userIDStr := r.URL.Query().Get("user_id")
 		if userIDStr == "" {
-			http.Error(w, "Missing user_id query parameter", http.StatusBadRequest)
+			respond.Error(w, http.StatusBadRequest, "Missing user_id query parameter")
 			return
 		}
 
 		// Convert user_id to an integer
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
-			http.Error(w, "Invalid user_id query parameter", http.StatusBadRequest)
+			respond.Error(w, http.StatusBadRequest, "Invalid user_id query parameter")
 			return
 		}
 
 		// Retrieve attendance records from the store
-		attendanceRecords, err := store.GetAttendanceByUserID(userID)
+		attendanceRecords, err := store.GetAttendanceByUserID(r.Context(), userID)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to fetch attendance records: %v", err), http.StatusInternalServerError)
+			httperr.Write(w, err, "Failed to fetch attendance records")
 			return
 		}
 
 		// Respond with the attendance records in JSON format
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(attendanceRecords)
+		respond.JSON(w, http.StatusOK, attendanceRecords)
 	}
 }
 
@@ -2,8 +2,8 @@ package attendance_handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -29,7 +29,7 @@ type MockAttendanceStore struct {
 //
 // Returns:
 //   - error: Always nil as the operation is simulated.
-func (m *MockAttendanceStore) CreateAttendance(attendance *models.Attendance) error {
+func (m *MockAttendanceStore) CreateAttendance(ctx context.Context, attendance *models.Attendance) error {
 	m.nextID++                               // Increment the ID counter.
 	attendance.ID = m.nextID                 // Assign a unique ID.
 	m.attendance[attendance.ID] = attendance // Store the attendance record in memory.
@@ -45,7 +45,7 @@ func (m *MockAttendanceStore) CreateAttendance(attendance *models.Attendance) er
 // Returns:
 //   - []*models.Attendance: A slice of Attendance records for the user.
 //   - error: Always nil as the operation is simulated.
-func (m *MockAttendanceStore) GetAttendanceByUserID(userID int) ([]*models.Attendance, error) {
+func (m *MockAttendanceStore) GetAttendanceByUserID(ctx context.Context, userID int) ([]*models.Attendance, error) {
 	var records []*models.Attendance
 	for _, record := range m.attendance {
 		if record.UserID == userID {
@@ -63,9 +63,9 @@ func (m *MockAttendanceStore) GetAttendanceByUserID(userID int) ([]*models.Atten
 //
 // Returns:
 //   - error: An error if the record is not found, otherwise nil.
-func (m *MockAttendanceStore) UpdateAttendance(attendance *models.Attendance) error {
+func (m *MockAttendanceStore) UpdateAttendance(ctx context.Context, attendance *models.Attendance) error {
 	if _, exists := m.attendance[attendance.ID]; !exists {
-		return errors.New("attendance record not found")
+		return models.ErrNotFound
 	}
 	m.attendance[attendance.ID] = attendance
 	return nil
@@ -79,7 +79,7 @@ func (m *MockAttendanceStore) UpdateAttendance(attendance *models.Attendance) er
 //
 // Returns:
 //   - error: Always nil as the operation is not performed.
-func (m *MockAttendanceStore) DeleteAttendance(id int) error {
+func (m *MockAttendanceStore) DeleteAttendance(ctx context.Context, id int) error {
 	return nil
 }
 
@@ -92,10 +92,11 @@ func TestCreateAttendanceRecord(t *testing.T) {
 	handler := CreateAttendanceRecord(store)
 
 	// Create a sample input attendance record.
+	checkIn := time.Now()
 	input := models.Attendance{
 		UserID:   1,
-		CheckIn:  time.Now(),
-		CheckOut: time.Now().Add(8 * time.Hour), // Simulate an 8-hour workday.
+		CheckIn:  checkIn,
+		CheckOut: checkIn.Add(8 * time.Hour), // Simulate an 8-hour workday.
 	}
 	body, _ := json.Marshal(input)                                          // Convert the input to JSON format.
 	req, _ := http.NewRequest("POST", "/attendance", bytes.NewBuffer(body)) // Create an HTTP POST request with the JSON body.
@@ -122,6 +123,24 @@ func TestCreateAttendanceRecord(t *testing.T) {
 	assert.Equal(t, 8.0, result.TotalHours)      // Check the total hours are calculated correctly.
 }
 
+// TestCreateAttendanceRecordIgnoresServerFields verifies that clients cannot set the ID or
+// the total hours of a new attendance record.
+func TestCreateAttendanceRecordIgnoresServerFields(t *testing.T) {
+	store := &MockAttendanceStore{attendance: make(map[int]*models.Attendance)}
+	handler := CreateAttendanceRecord(store)
+
+	body := `{"id": 99, "user_id": 1, "check_in": "2024-11-16T09:00:00Z", "total_hours": 40}`
+	req, _ := http.NewRequest("POST", "/attendance", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var result models.Attendance
+	json.NewDecoder(rr.Body).Decode(&result)
+	assert.NotEqual(t, 99, result.ID)
+	assert.Zero(t, result.TotalHours)
+}
+
 // TestGetAttendanceByUserID verifies the GetAttendanceByUserID handler.
 // It checks whether the handler retrieves attendance records for a specific user
 // and returns them in the correct format.
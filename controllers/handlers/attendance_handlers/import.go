@@ -0,0 +1,376 @@
+package attendance_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// maxImportSize bounds the size of an imported file.
+const maxImportSize = 10 << 20
+
+// importFields are the fields a column of an imported file can be mapped to.
+var importFields = []string{"user_id", "email", "date", "check_in", "check_out"}
+
+// dateFormats are the accepted date_format values and their layouts.
+var dateFormats = map[string]string{
+	"YYYY-MM-DD": "2006-01-02",
+	"DD/MM/YYYY": "02/01/2006",
+	"MM/DD/YYYY": "01/02/2006",
+	"DD.MM.YYYY": "02.01.2006",
+}
+
+// timeLayouts are the accepted layouts of check-in and check-out times given without a date.
+var timeLayouts = []string{"15:04", "15:04:05", "3:04 PM", "3:04PM", "3:04:05 PM"}
+
+// AttendanceImportHandler provides the endpoints for importing attendance history.
+type AttendanceImportHandler struct {
+	Store models.AttendanceImportStore
+}
+
+// ImportAttendance imports attendance history from a CSV file with a header row. Each row
+// is a day of one employee, identified by user_id or email. check_in and check_out are
+// either full timestamps or times of day on the date column; a check-out earlier than the
+// check-in is taken as the next morning. check_out may be empty.
+//
+// Rows are rejected if the employee does not exist, a value cannot be read, or the employee
+// already has attendance on that day, in the database or earlier in the file. Valid rows are
+// saved together; rejected rows are listed in the response and in a CSV error report.
+//
+// HTTP Method: POST
+// URL Path: /attendance/import?dry_run=true&date_format=DD/MM/YYYY&mapping={"email":"Employee Email"}
+//
+// Request Body:
+//   - The CSV file, either as the body or in the "file" field of a multipart/form-data form.
+//     mapping and date_format may also be sent as form fields.
+//   - mapping is a JSON object from field (user_id, email, date, check_in, check_out) to
+//     column name; fields not mapped are read from the column of the same name.
+//   - date_format is YYYY-MM-DD (the default), DD/MM/YYYY, MM/DD/YYYY or DD.MM.YYYY.
+//
+// Response:
+//   - Status Code: 200 (OK) with the AttendanceImport in JSON. With dry_run nothing is saved
+//     but the error report is still available.
+//   - Status Code: 400 (Bad Request) if the file, mapping or date format cannot be read, or
+//     the file lacks an employee or check_in column.
+//   - Status Code: 500 (Internal Server Error) if the import could not be saved.
+func (h *AttendanceImportHandler) ImportAttendance(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader
+	imp := &models.AttendanceImport{Errors: []models.AttendanceImportError{}}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+1<<20) // Allow for multipart overhead
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "A CSV file is required in the file field")
+			return
+		}
+		defer file.Close()
+		body, imp.FileName = file, path.Base(header.Filename)
+	} else {
+		body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	}
+	imp.DryRun, _ = strconv.ParseBool(r.FormValue("dry_run"))
+	imp.ImportedBy, _ = middleware.GetUserEmailFromContext(r.Context())
+
+	mapping := map[string]string{}
+	if value := r.FormValue("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid mapping, expected a JSON object from field to column name")
+			return
+		}
+	}
+	dateLayout := dateFormats["YYYY-MM-DD"]
+	if value := r.FormValue("date_format"); value != "" {
+		layout, ok := dateFormats[strings.ToUpper(value)]
+		if !ok {
+			respond.Error(w, http.StatusBadRequest, "Invalid date_format, expected YYYY-MM-DD, DD/MM/YYYY, MM/DD/YYYY or DD.MM.YYYY")
+			return
+		}
+		dateLayout = layout
+	}
+
+	users, err := h.Store.GetUserIDsByEmail(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load employees")
+		return
+	}
+	header, rows, err := readAttendanceRows(body, mapping, dateLayout, users, imp)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records, err := h.rejectDuplicates(r.Context(), rows, imp)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load existing attendance")
+		return
+	}
+	imp.Imported, imp.Rejected = len(records), len(imp.Errors)
+	if imp.DryRun {
+		records = nil
+	}
+
+	var report []byte
+	if imp.Rejected > 0 {
+		report = errorReport(header, imp.Errors)
+	}
+	if err := h.Store.SaveAttendanceImport(r.Context(), imp, records, report); err != nil {
+		httperr.Write(w, err, "Failed to import attendance")
+		return
+	}
+	if report != nil {
+		imp.ErrorReportURL = fmt.Sprintf("/attendance/imports/%d/errors", imp.ID)
+	}
+
+	respond.JSON(w, http.StatusOK, imp)
+}
+
+// GetImportErrors downloads the rejected rows of an import as CSV: the columns of the
+// original file followed by the row number and the reason it was rejected.
+//
+// HTTP Method: GET
+// URL Path: /attendance/imports/{id}/errors
+//
+// Response:
+//   - Status Code: 200 (OK) with the CSV error report.
+//   - Status Code: 404 (Not Found) if there is no such import or it had no rejected rows.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be read.
+func (h *AttendanceImportHandler) GetImportErrors(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	report, err := h.Store.GetAttendanceImportErrors(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load error report")
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="attendance-import-%d-errors.csv"`, id))
+	w.Write(report)
+}
+
+// attendanceRow is a row of an imported file that could be read.
+type attendanceRow struct {
+	line       int
+	fields     []string
+	attendance models.Attendance
+	day        string // Date of the check-in, YYYY-MM-DD
+}
+
+// readAttendanceRows reads the rows of an imported file. Rows that cannot be read or name
+// an unknown employee are added to imp.Errors instead.
+//
+// Returns:
+//   - []string: The header row.
+//   - []attendanceRow: The rows read.
+//   - error: An error if the file is not CSV, has no header row or lacks a required column.
+func readAttendanceRows(body io.Reader, mapping map[string]string, dateLayout string, users map[string]int,
+	imp *models.AttendanceImport) ([]string, []attendanceRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("the file has no header row")
+	}
+	positions := map[string]int{}
+	for i, name := range header {
+		positions[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	columns := map[string]int{}
+	for _, field := range importFields {
+		name, mapped := mapping[field]
+		if !mapped {
+			name = field
+		}
+		if i, ok := positions[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[field] = i
+		} else if mapped {
+			return nil, nil, fmt.Errorf("the file has no column %q mapped to %s", name, field)
+		}
+	}
+	_, hasUserID := columns["user_id"]
+	_, hasEmail := columns["email"]
+	if !hasUserID && !hasEmail {
+		return nil, nil, errors.New("the file has no user_id or email column")
+	}
+	if _, ok := columns["check_in"]; !ok {
+		return nil, nil, errors.New("the file has no check_in column")
+	}
+
+	userIDs := make(map[int]bool, len(users))
+	for _, id := range users {
+		userIDs[id] = true
+	}
+	var rows []attendanceRow
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		reject := func(reason string) {
+			imp.Errors = append(imp.Errors, models.AttendanceImportError{Row: line, Reason: reason, Fields: fields})
+		}
+
+		row := attendanceRow{line: line, fields: fields}
+		if email := value("email"); email != "" {
+			id, ok := users[strings.ToLower(email)]
+			if !ok {
+				reject(fmt.Sprintf("no employee with email %s", email))
+				continue
+			}
+			row.attendance.UserID = id
+		} else if id, err := strconv.Atoi(value("user_id")); err == nil && userIDs[id] {
+			row.attendance.UserID = id
+		} else {
+			reject("unknown employee")
+			continue
+		}
+
+		var date *time.Time
+		if text := value("date"); text != "" {
+			parsed, err := time.Parse(dateLayout, text)
+			if err != nil {
+				reject(fmt.Sprintf("invalid date %q", text))
+				continue
+			}
+			date = &parsed
+		}
+		checkIn, _, err := parseMoment(value("check_in"), date, dateLayout)
+		if err != nil {
+			reject("check_in: " + err.Error())
+			continue
+		}
+		row.attendance.CheckIn = checkIn
+		if text := value("check_out"); text != "" {
+			checkOut, timeOnly, err := parseMoment(text, &checkIn, dateLayout)
+			if err != nil {
+				reject("check_out: " + err.Error())
+				continue
+			}
+			if checkOut.Before(checkIn) && timeOnly {
+				checkOut = checkOut.AddDate(0, 0, 1) // A night shift
+			}
+			if checkOut.Before(checkIn) {
+				reject("check_out is before check_in")
+				continue
+			}
+			if checkOut.Sub(checkIn) > 24*time.Hour {
+				reject("the shift is longer than 24 hours")
+				continue
+			}
+			row.attendance.CheckOut = checkOut
+			row.attendance.TotalHours = checkOut.Sub(checkIn).Hours()
+		}
+		row.day = checkIn.Format("2006-01-02")
+		rows = append(rows, row)
+	}
+	return header, rows, nil
+}
+
+// parseMoment reads a check-in or check-out: a full timestamp, or a time of day on date.
+// It reports whether only a time of day was given.
+func parseMoment(value string, date *time.Time, dateLayout string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, errors.New("missing")
+	}
+	for _, layout := range []string{time.RFC3339, dateLayout + " 15:04", dateLayout + " 15:04:05", "2006-01-02T15:04:05"} {
+		if moment, err := time.Parse(layout, value); err == nil {
+			return moment, false, nil
+		}
+	}
+	if date != nil {
+		for _, layout := range timeLayouts {
+			if clock, err := time.Parse(layout, strings.ToUpper(value)); err == nil {
+				day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+				return day.Add(clock.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC))), true, nil
+			}
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("cannot read %q", value)
+}
+
+// rejectDuplicates returns the attendance of the rows for days the employee has no
+// attendance on yet, in the database or earlier in the file. The others are added to
+// imp.Errors.
+func (h *AttendanceImportHandler) rejectDuplicates(ctx context.Context, rows []attendanceRow, imp *models.AttendanceImport) ([]models.Attendance, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	from, to := rows[0].attendance.CheckIn, rows[0].attendance.CheckIn
+	for _, row := range rows {
+		if row.attendance.CheckIn.Before(from) {
+			from = row.attendance.CheckIn
+		}
+		if row.attendance.CheckIn.After(to) {
+			to = row.attendance.CheckIn
+		}
+	}
+	existing, err := h.Store.GetAttendanceDays(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[int]map[string]int{}
+	var records []models.Attendance
+	for _, row := range rows {
+		userID := row.attendance.UserID
+		if existing[userID][row.day] {
+			imp.Errors = append(imp.Errors, models.AttendanceImportError{Row: row.line, Fields: row.fields,
+				Reason: fmt.Sprintf("employee %d already has attendance on %s", userID, row.day)})
+			continue
+		}
+		if first, ok := seen[userID][row.day]; ok {
+			imp.Errors = append(imp.Errors, models.AttendanceImportError{Row: row.line, Fields: row.fields,
+				Reason: fmt.Sprintf("duplicate of row %d", first)})
+			continue
+		}
+		if seen[userID] == nil {
+			seen[userID] = map[string]int{}
+		}
+		seen[userID][row.day] = row.line
+		records = append(records, row.attendance)
+	}
+	sort.SliceStable(imp.Errors, func(i, j int) bool { return imp.Errors[i].Row < imp.Errors[j].Row })
+	return records, nil
+}
+
+// errorReport writes the rejected rows as CSV with the original header followed by the
+// row number and the reason.
+func errorReport(header []string, errs []models.AttendanceImportError) []byte {
+	var buf bytes.Buffer
+	out := csv.NewWriter(&buf)
+	out.Write(append(append([]string{}, header...), "row", "error"))
+	for _, e := range errs {
+		record := make([]string, len(header), len(header)+2)
+		copy(record, e.Fields)
+		out.Write(append(record, strconv.Itoa(e.Row), e.Reason))
+	}
+	out.Flush()
+	return buf.Bytes()
+}
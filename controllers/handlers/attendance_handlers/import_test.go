@@ -0,0 +1,127 @@
+package attendance_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockImportStore is an in-memory implementation of models.AttendanceImportStore.
+type mockImportStore struct {
+	users    map[string]int
+	existing map[int]map[string]bool
+	saved    []models.Attendance
+	reports  map[int][]byte
+}
+
+func (m *mockImportStore) GetUserIDsByEmail(ctx context.Context) (map[string]int, error) {
+	return m.users, nil
+}
+
+func (m *mockImportStore) GetAttendanceDays(ctx context.Context, from, to time.Time) (map[int]map[string]bool, error) {
+	return m.existing, nil
+}
+
+func (m *mockImportStore) SaveAttendanceImport(ctx context.Context, imp *models.AttendanceImport, records []models.Attendance, report []byte) error {
+	imp.ID = len(m.reports) + 1
+	m.saved = append(m.saved, records...)
+	if report != nil {
+		m.reports[imp.ID] = report
+	}
+	return nil
+}
+
+func (m *mockImportStore) GetAttendanceImportErrors(ctx context.Context, id int) ([]byte, error) {
+	report, ok := m.reports[id]
+	if !ok {
+		return nil, models.NotFound("attendance import %d has no error report", id)
+	}
+	return report, nil
+}
+
+func TestImportAttendance(t *testing.T) {
+	store := &mockImportStore{
+		users:    map[string]int{"ana@example.com": 1, "ben@example.com": 2},
+		existing: map[int]map[string]bool{2: {"2023-05-02": true}},
+		reports:  map[int][]byte{},
+	}
+	handler := &AttendanceImportHandler{Store: store}
+	router := mux.NewRouter()
+	router.HandleFunc("/attendance/import", handler.ImportAttendance).Methods("POST")
+	router.HandleFunc("/attendance/imports/{id:[0-9]+}/errors", handler.GetImportErrors).Methods("GET")
+
+	file := "Employee Email,Day,In,Out\n" +
+		"ana@example.com,01/05/2023,09:00,17:30\n" +
+		"ANA@example.com,02/05/2023,22:00,06:00\n" + // A night shift
+		"ana@example.com,02/05/2023,09:00,17:00\n" + // Duplicate in the file
+		"ben@example.com,02/05/2023,09:00,17:00\n" + // Already in the database
+		"carl@example.com,02/05/2023,09:00,17:00\n" +
+		"ben@example.com,31/02/2023,09:00,17:00\n" +
+		"ben@example.com,03/05/2023,9am,17:00\n"
+	query := url.Values{
+		"mapping":     {`{"email": "Employee Email", "date": "day", "check_in": "In", "check_out": "Out"}`},
+		"date_format": {"DD/MM/YYYY"},
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/attendance/import?"+query.Encode(), strings.NewReader(file)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var imp models.AttendanceImport
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&imp))
+	assert.Equal(t, 2, imp.Imported)
+	assert.Equal(t, 5, imp.Rejected)
+	assert.Equal(t, "/attendance/imports/1/errors", imp.ErrorReportURL)
+	assert.Equal(t, []int{4, 5, 6, 7, 8}, []int{imp.Errors[0].Row, imp.Errors[1].Row, imp.Errors[2].Row, imp.Errors[3].Row, imp.Errors[4].Row})
+	assert.Equal(t, "duplicate of row 3", imp.Errors[0].Reason)
+
+	require.Len(t, store.saved, 2)
+	assert.Equal(t, 8.5, store.saved[0].TotalHours)
+	assert.Equal(t, time.Date(2023, 5, 3, 6, 0, 0, 0, time.UTC), store.saved[1].CheckOut)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", imp.ErrorReportURL, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	assert.Equal(t, "Employee Email,Day,In,Out,row,error", lines[0])
+	assert.Equal(t, "carl@example.com,02/05/2023,09:00,17:00,6,no employee with email carl@example.com", lines[3])
+}
+
+func TestImportAttendanceDryRun(t *testing.T) {
+	store := &mockImportStore{users: map[string]int{"ana@example.com": 1}, reports: map[int][]byte{}}
+	handler := &AttendanceImportHandler{Store: store}
+
+	rr := httptest.NewRecorder()
+	handler.ImportAttendance(rr, httptest.NewRequest("POST", "/attendance/import?dry_run=true",
+		strings.NewReader("user_id,check_in,check_out\n1,2023-05-01T09:00:00Z,2023-05-01T17:00:00Z\n")))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var imp models.AttendanceImport
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&imp))
+	assert.Equal(t, 1, imp.Imported)
+	assert.True(t, imp.DryRun)
+	assert.Empty(t, store.saved)
+	assert.Empty(t, imp.ErrorReportURL)
+}
+
+func TestImportAttendanceInvalidFile(t *testing.T) {
+	handler := &AttendanceImportHandler{Store: &mockImportStore{reports: map[int][]byte{}}}
+	for name, target := range map[string]string{
+		"no employee column": "/attendance/import",
+		"unknown mapping":    "/attendance/import?mapping=" + url.QueryEscape(`{"email": "Mail"}`),
+		"bad date format":    "/attendance/import?date_format=YY",
+	} {
+		rr := httptest.NewRecorder()
+		handler.ImportAttendance(rr, httptest.NewRequest("POST", target, strings.NewReader("name,check_in\n")))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, name)
+	}
+}
@@ -0,0 +1,56 @@
+package attendance_handlers
+
+import (
+	"net/http"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// AttendanceSessionHandler provides the endpoints for employees checking in and out.
+type AttendanceSessionHandler struct {
+	Store models.AttendanceSessionStore
+}
+
+// CheckIn opens an attendance record for the signed-in employee at the server's time.
+//
+// HTTP Method: POST
+// URL Path: /attendance/check-in
+//
+// Response:
+//   - Status Code: 201 (Created) with the open Attendance record in JSON.
+//   - Status Code: 409 (Conflict) if the employee is checked in already.
+//   - Status Code: 422 (Unprocessable Entity) if the user has no employee profile.
+//   - Status Code: 500 (Internal Server Error) if the record cannot be saved.
+func (h *AttendanceSessionHandler) CheckIn(w http.ResponseWriter, r *http.Request) {
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	attendance, err := h.Store.CheckIn(r.Context(), email, time.Now())
+	if err != nil {
+		httperr.Write(w, err, "Failed to check in")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, attendance)
+}
+
+// CheckOut closes the signed-in employee's open attendance record at the server's time and
+// works out the hours worked.
+//
+// HTTP Method: POST
+// URL Path: /attendance/check-out
+//
+// Response:
+//   - Status Code: 200 (OK) with the closed Attendance record, including total_hours, in JSON.
+//   - Status Code: 409 (Conflict) if the employee is not checked in.
+//   - Status Code: 500 (Internal Server Error) if the record cannot be saved.
+func (h *AttendanceSessionHandler) CheckOut(w http.ResponseWriter, r *http.Request) {
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	attendance, err := h.Store.CheckOut(r.Context(), email, time.Now())
+	if err != nil {
+		httperr.Write(w, err, "Failed to check out")
+		return
+	}
+	respond.JSON(w, http.StatusOK, attendance)
+}
@@ -0,0 +1,124 @@
+package attendance_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSessionStore keeps the open record of each email.
+type fakeSessionStore struct {
+	open map[string]*models.Attendance
+}
+
+func (f *fakeSessionStore) CheckIn(ctx context.Context, email string, at time.Time) (*models.Attendance, error) {
+	if f.open[email] != nil {
+		return nil, models.Conflict("%s is checked in already", email)
+	}
+	f.open[email] = &models.Attendance{ID: 1, UserID: 3, CheckIn: at.Add(-90 * time.Minute)}
+	return f.open[email], nil
+}
+
+func (f *fakeSessionStore) CheckOut(ctx context.Context, email string, at time.Time) (*models.Attendance, error) {
+	attendance := f.open[email]
+	if attendance == nil {
+		return nil, models.Conflict("%s is not checked in", email)
+	}
+	delete(f.open, email)
+	attendance.CheckOut, attendance.TotalHours = at, at.Sub(attendance.CheckIn).Hours()
+	return attendance, nil
+}
+
+func TestCheckInAndOut(t *testing.T) {
+	handler := &AttendanceSessionHandler{Store: &fakeSessionStore{open: map[string]*models.Attendance{}}}
+	post := func(h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/attendance/check", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmail, "ana@example.com"))
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusConflict, post(handler.CheckOut).Code)
+	assert.Equal(t, http.StatusCreated, post(handler.CheckIn).Code)
+	assert.Equal(t, http.StatusConflict, post(handler.CheckIn).Code)
+
+	rr := post(handler.CheckOut)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var attendance models.Attendance
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&attendance))
+	assert.InDelta(t, 1.5, attendance.TotalHours, 0.01)
+}
+
+func TestDBCheckIn(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBAttendanceStore{DB: db}
+	at := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO attendance (user_id, check_in)")).WithArgs("ana@example.com", at).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "check_in"}).AddRow(5, 3, at))
+	attendance, err := store.CheckIn(ctx, "ana@example.com", at)
+	require.NoError(t, err)
+	assert.Equal(t, 5, attendance.ID)
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO attendance (user_id, check_in)")).
+		WillReturnError(&pq.Error{Code: "23505"})
+	_, err = store.CheckIn(ctx, "ana@example.com", at)
+	assert.True(t, errors.Is(err, models.ErrConflict))
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO attendance (user_id, check_in)")).WillReturnError(sql.ErrNoRows)
+	_, err = store.CheckIn(ctx, "guest@example.com", at)
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDBCheckOut(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBAttendanceStore{DB: db}
+	checkIn := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	at := checkIn.Add(8*time.Hour + 20*time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("AND a.check_out IS NULL FOR UPDATE OF a")).WithArgs("ana@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "check_in"}).AddRow(5, 3, checkIn))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE attendance SET check_out = $1, total_hours = $2 WHERE id = $3")).
+		WithArgs(at, 8.33, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	attendance, err := store.CheckOut(ctx, "ana@example.com", at)
+	require.NoError(t, err)
+	assert.Equal(t, 8.33, attendance.TotalHours)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("AND a.check_out IS NULL FOR UPDATE OF a")).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = store.CheckOut(ctx, "ana@example.com", at)
+	assert.True(t, errors.Is(err, models.ErrConflict))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("AND a.check_out IS NULL FOR UPDATE OF a")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "check_in"}).AddRow(5, 3, at.Add(time.Hour)))
+	mock.ExpectRollback()
+	_, err = store.CheckOut(ctx, "ana@example.com", at)
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
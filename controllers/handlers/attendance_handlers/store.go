@@ -3,8 +3,12 @@
 package attendance_handlers
 
 import (
+	"context"
 	"database/sql"
 	"erp/models"
+	"errors"
+	"math"
+	"time"
 )
 
 // DBAttendanceStore implements the AttendanceStore interface for SQL database operations.
@@ -23,14 +27,24 @@ type DBAttendanceStore struct {
 //   - TotalHours: Calculated hours based on CheckIn and CheckOut.
 //
 // Returns:
-//   - error: An error if the operation fails, otherwise nil.
+//   - error: A validation error if the user has no employee profile, or an error if the
+//     operation fails, otherwise nil.
 //
 // Details:
 //   - This method executes an SQL `INSERT` query to add the attendance record to the `attendance` table.
-func (store *DBAttendanceStore) CreateAttendance(attendance *models.Attendance) error {
-	query := "INSERT INTO attendance (user_id, check_in, check_out, total_hours) VALUES ($1, $2, $3, $4)"
-	_, err := store.DB.Exec(query, attendance.UserID, attendance.CheckIn, attendance.CheckOut, attendance.TotalHours)
-	return err
+//   - The row is only inserted if the user has a row in `employees`, like a foreign key would require.
+func (store *DBAttendanceStore) CreateAttendance(ctx context.Context, attendance *models.Attendance) error {
+	query := `INSERT INTO attendance (user_id, check_in, check_out, total_hours)
+		SELECT $1::int, $2::timestamp, $3::timestamp, $4::decimal
+		WHERE EXISTS (SELECT 1 FROM employees WHERE user_id = $1)`
+	result, err := store.DB.ExecContext(ctx, query, attendance.UserID, attendance.CheckIn, attendance.CheckOut, attendance.TotalHours)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.Invalid("user %d has no employee profile", attendance.UserID)
+	}
+	return nil
 }
 
 // GetAttendanceByUserID retrieves all attendance records for a specific user from the database.
@@ -46,12 +60,12 @@ func (store *DBAttendanceStore) CreateAttendance(attendance *models.Attendance)
 //   - This method executes an SQL `SELECT` query to fetch attendance records from the `attendance` table
 //     where the `user_id` matches the provided ID.
 //   - The records are returned in the order they are found in the database.
-func (store *DBAttendanceStore) GetAttendanceByUserID(userID int) ([]*models.Attendance, error) {
+func (store *DBAttendanceStore) GetAttendanceByUserID(ctx context.Context, userID int) ([]*models.Attendance, error) {
 	// Prepare the query to fetch attendance records for the given user ID
-	query := "SELECT id, user_id, check_in, check_out, total_hours FROM attendance WHERE user_id = $1"
+	query := "SELECT id, user_id, check_in, check_out, COALESCE(total_hours, 0) FROM attendance WHERE user_id = $1"
 
 	// Execute the query
-	rows, err := store.DB.Query(query, userID)
+	rows, err := store.DB.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -61,12 +75,211 @@ func (store *DBAttendanceStore) GetAttendanceByUserID(userID int) ([]*models.Att
 	var attendanceRecords []*models.Attendance
 	for rows.Next() {
 		var attendance models.Attendance
-		if err := rows.Scan(&attendance.ID, &attendance.UserID, &attendance.CheckIn, &attendance.CheckOut, &attendance.TotalHours); err != nil {
+		var checkOut sql.NullTime // Not set while the employee is checked in
+		if err := rows.Scan(&attendance.ID, &attendance.UserID, &attendance.CheckIn, &checkOut, &attendance.TotalHours); err != nil {
 			return nil, err
 		}
+		attendance.CheckOut = checkOut.Time
 		attendanceRecords = append(attendanceRecords, &attendance)
 	}
 
 	// Return the slice of attendance records
 	return attendanceRecords, nil
 }
+
+// CheckIn opens an attendance record for the user with an email, without a check-out.
+//
+// Parameters:
+//   - email: The email of the user checking in, matched case-insensitively.
+//   - at: The check-in time.
+//
+// Returns:
+//   - *models.Attendance: The open record.
+//   - error: A validation error if the user has no employee profile, a conflict if they
+//     are checked in already, or an error if the operation fails.
+//
+// Details:
+//   - A partial unique index on `attendance` allows one record without a check-out per
+//     user, so two check-ins at once cannot both open one.
+func (store *DBAttendanceStore) CheckIn(ctx context.Context, email string, at time.Time) (*models.Attendance, error) {
+	var attendance models.Attendance
+	err := store.DB.QueryRowContext(ctx,
+		`INSERT INTO attendance (user_id, check_in)
+		 SELECT u.id, $2 FROM users u JOIN employees e ON e.user_id = u.id WHERE LOWER(u.email) = LOWER($1)
+		 RETURNING id, user_id, check_in`, email, at,
+	).Scan(&attendance.ID, &attendance.UserID, &attendance.CheckIn)
+	if models.IsUniqueViolation(err) {
+		return nil, models.Conflict("%s is checked in already", email)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.Invalid("%s has no employee profile", email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attendance, nil
+}
+
+// CheckOut closes the open attendance record of the user with an email and sets its total
+// hours, rounded to two decimals as the column holds them.
+//
+// Parameters:
+//   - email: The email of the user checking out, matched case-insensitively.
+//   - at: The check-out time.
+//
+// Returns:
+//   - *models.Attendance: The closed record.
+//   - error: A conflict if the user is not checked in, a validation error if at is before
+//     the check-in, or an error if the operation fails.
+func (store *DBAttendanceStore) CheckOut(ctx context.Context, email string, at time.Time) (*models.Attendance, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var attendance models.Attendance
+	err = tx.QueryRowContext(ctx,
+		`SELECT a.id, a.user_id, a.check_in FROM attendance a JOIN users u ON u.id = a.user_id
+		 WHERE LOWER(u.email) = LOWER($1) AND a.check_out IS NULL FOR UPDATE OF a`, email,
+	).Scan(&attendance.ID, &attendance.UserID, &attendance.CheckIn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.Conflict("%s is not checked in", email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	hours, err := CalculateWorkingHours(attendance.CheckIn, at)
+	if err != nil {
+		return nil, models.Invalid("check-out cannot be before the check-in at %s", attendance.CheckIn.Format(time.RFC3339))
+	}
+	attendance.CheckOut, attendance.TotalHours = at, math.Round(hours*100)/100
+	if _, err := tx.ExecContext(ctx, `UPDATE attendance SET check_out = $1, total_hours = $2 WHERE id = $3`,
+		attendance.CheckOut, attendance.TotalHours, attendance.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &attendance, nil
+}
+
+// DBAttendanceImportStore implements the AttendanceImportStore interface for SQL database
+// operations.
+type DBAttendanceImportStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// GetUserIDsByEmail returns the IDs of the users with an employee profile keyed by
+// lower-case email, so that rows for anyone else are rejected as unknown employees.
+//
+// Returns:
+//   - map[string]int: The user IDs.
+//   - error: An error if the query fails.
+func (store *DBAttendanceImportStore) GetUserIDsByEmail(ctx context.Context) (map[string]int, error) {
+	rows, err := store.DB.QueryContext(ctx, "SELECT u.id, LOWER(u.email) FROM users u JOIN employees e ON e.user_id = u.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := map[string]int{}
+	for rows.Next() {
+		var id int
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			return nil, err
+		}
+		users[email] = id
+	}
+	return users, rows.Err()
+}
+
+// GetAttendanceDays returns the days users already have attendance on, by check-in date.
+//
+// Parameters:
+//   - from, to: The first and last check-in to look at; only their dates are used.
+//
+// Returns:
+//   - map[int]map[string]bool: The days (YYYY-MM-DD) keyed by user ID.
+//   - error: An error if the query fails.
+func (store *DBAttendanceImportStore) GetAttendanceDays(ctx context.Context, from, to time.Time) (map[int]map[string]bool, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT DISTINCT user_id, check_in::date FROM attendance
+		 WHERE check_in::date BETWEEN $1::date AND $2::date`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := map[int]map[string]bool{}
+	for rows.Next() {
+		var userID int
+		var day time.Time
+		if err := rows.Scan(&userID, &day); err != nil {
+			return nil, err
+		}
+		if days[userID] == nil {
+			days[userID] = map[string]bool{}
+		}
+		days[userID][day.Format("2006-01-02")] = true
+	}
+	return days, rows.Err()
+}
+
+// SaveAttendanceImport saves the imported attendance and records the import with its error
+// report in one transaction.
+//
+// Parameters:
+//   - imp: The import; its ID and CreatedAt are set.
+//   - records: The attendance to save; empty for dry runs.
+//   - errorReport: The CSV of the rejected rows, or nil if none were rejected.
+//
+// Returns:
+//   - error: An error if anything fails; nothing is saved then.
+func (store *DBAttendanceImportStore) SaveAttendanceImport(ctx context.Context, imp *models.AttendanceImport, records []models.Attendance, errorReport []byte) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO attendance (user_id, check_in, check_out, total_hours) VALUES ($1, $2, $3, $4)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, record := range records {
+		if _, err := stmt.ExecContext(ctx, record.UserID, record.CheckIn, record.CheckOut, record.TotalHours); err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO attendance_imports (file_name, dry_run, imported, rejected, error_report, imported_by, created_at)
+		 VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, NOW()) RETURNING id, created_at`,
+		imp.FileName, imp.DryRun, imp.Imported, imp.Rejected, errorReport, imp.ImportedBy,
+	).Scan(&imp.ID, &imp.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetAttendanceImportErrors returns the CSV error report of an import.
+//
+// Parameters:
+//   - id: The ID of the import.
+//
+// Returns:
+//   - []byte: The error report.
+//   - error: models.ErrNotFound if there is no such import or it rejected no rows, or the
+//     query error.
+func (store *DBAttendanceImportStore) GetAttendanceImportErrors(ctx context.Context, id int) ([]byte, error) {
+	var report []byte
+	err := store.DB.QueryRowContext(ctx, "SELECT error_report FROM attendance_imports WHERE id = $1", id).Scan(&report)
+	if err == sql.ErrNoRows || (err == nil && report == nil) {
+		return nil, models.NotFound("attendance import %d has no error report", id)
+	}
+	return report, err
+}
@@ -0,0 +1,116 @@
+package audit_handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// defaultDays is how far back the trail goes when no start date is given.
+	defaultDays = 30
+	// defaultLimit and maxLimit bound the number of changes returned.
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// AuditHandler provides the audit trail of API changes.
+type AuditHandler struct {
+	Store models.RequestAuditStore
+	Now   func() time.Time
+}
+
+// RegisterRoutes registers the audit trail routes.
+func (h *AuditHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.ListAudits).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", h.GetAudit).Methods("GET")
+}
+
+// ListAudits lists the changes made through the API, newest first.
+//
+// HTTP Method: GET
+// URL Path: /audit?user=&entity_type=&entity_id=&from=YYYY-MM-DD&to=YYYY-MM-DD&limit=100
+// (user is the email of the user who made the change; from defaults to 30 days ago and to
+// to today)
+//
+// Response:
+//   - Status Code: 200 (OK) with the list of RequestAudits in JSON.
+//   - Status Code: 400 (Bad Request) if a filter is invalid or from is after to.
+//   - Status Code: 500 (Internal Server Error) if the audit trail cannot be read.
+func (h *AuditHandler) ListAudits(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r, h.Now())
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	audits, err := h.Store.ListRequestAudits(r.Context(), filter)
+	if err != nil {
+		httperr.Write(w, err, "Failed to read audit trail")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, audits)
+}
+
+// GetAudit returns a change made through the API.
+//
+// HTTP Method: GET
+// URL Path: /audit/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the RequestAudit in JSON.
+//   - Status Code: 404 (Not Found) if there is no such entry.
+//   - Status Code: 500 (Internal Server Error) if the entry cannot be read.
+func (h *AuditHandler) GetAudit(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	audit, err := h.Store.GetRequestAudit(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to read audit log entry")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, audit)
+}
+
+// parseFilter reads the query parameters of the audit trail.
+func parseFilter(r *http.Request, now time.Time) (models.RequestAuditFilter, error) {
+	query := r.URL.Query()
+	filter := models.RequestAuditFilter{
+		Actor:      query.Get("user"),
+		EntityType: query.Get("entity_type"),
+		EntityID:   query.Get("entity_id"),
+		Limit:      defaultLimit,
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to := today.AddDate(0, 0, -defaultDays), today
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			return filter, models.Invalid("invalid from, expected YYYY-MM-DD")
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			return filter, models.Invalid("invalid to, expected YYYY-MM-DD")
+		}
+	}
+	if from.After(to) {
+		return filter, models.Invalid("from must not be after to")
+	}
+	filter.From, filter.To = from, to.AddDate(0, 0, 1)
+
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit <= 0 || filter.Limit > maxLimit {
+			return filter, models.Invalid("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,116 @@
+package audit_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockAuditStore keeps the last filter it was queried with.
+type mockAuditStore struct {
+	filter models.RequestAuditFilter
+}
+
+func (m *mockAuditStore) RecordRequestAudit(ctx context.Context, audit *models.RequestAudit) error {
+	return nil
+}
+
+func (m *mockAuditStore) ListRequestAudits(ctx context.Context, filter models.RequestAuditFilter) ([]models.RequestAudit, error) {
+	m.filter = filter
+	return []models.RequestAudit{}, nil
+}
+
+func (m *mockAuditStore) GetRequestAudit(ctx context.Context, id int) (*models.RequestAudit, error) {
+	if id != 4 {
+		return nil, models.NotFound("audit log entry %d not found", id)
+	}
+	return &models.RequestAudit{ID: 4, Method: "PUT", Path: "/products/9"}, nil
+}
+
+func TestListAudits(t *testing.T) {
+	store := &mockAuditStore{}
+	handler := &AuditHandler{Store: store, Now: func() time.Time { return time.Date(2026, 10, 17, 15, 0, 0, 0, time.UTC) }}
+	get := func(query string) int {
+		rr := httptest.NewRecorder()
+		handler.ListAudits(rr, httptest.NewRequest("GET", "/audit"+query, nil))
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get(""))
+	assert.Equal(t, time.Date(2026, 9, 17, 0, 0, 0, 0, time.UTC), store.filter.From)
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), store.filter.To, "to is inclusive")
+	assert.Equal(t, defaultLimit, store.filter.Limit)
+
+	assert.Equal(t, http.StatusOK, get("?user=jane@example.com&entity_type=products&entity_id=9&from=2026-10-01&to=2026-10-02&limit=5"))
+	assert.Equal(t, models.RequestAuditFilter{Actor: "jane@example.com", EntityType: "products", EntityID: "9",
+		From: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC), Limit: 5}, store.filter)
+
+	assert.Equal(t, http.StatusBadRequest, get("?from=2026-10-05&to=2026-10-01"))
+	assert.Equal(t, http.StatusBadRequest, get("?to=yesterday"))
+	assert.Equal(t, http.StatusBadRequest, get("?limit=5000"))
+}
+
+func TestGetAudit(t *testing.T) {
+	router := mux.NewRouter()
+	(&AuditHandler{Store: &mockAuditStore{}, Now: time.Now}).RegisterRoutes(router.PathPrefix("/audit").Subrouter())
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/audit/4", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var audit models.RequestAudit
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&audit))
+	assert.Equal(t, "/products/9", audit.Path)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/audit/5", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestRecordRequestAudit(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBRequestAuditStore{DB: db}
+	at := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+
+	audit := &models.RequestAudit{Actor: "jane@example.com", Method: "PUT", Path: "/products/9", EntityType: "products",
+		EntityID: "9", Status: 200, Changes: json.RawMessage(`{"price":{"from":10,"to":12}}`), CreatedAt: at}
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO audit_logs")).
+		WithArgs("jane@example.com", "PUT", "/products/9", "products", "9", 200, []byte(`{"price":{"from":10,"to":12}}`), at).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	require.NoError(t, store.RecordRequestAudit(ctx, audit))
+	assert.Equal(t, 3, audit.ID)
+
+	// Requests without changes store NULL
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO audit_logs")).
+		WithArgs("", "POST", "/auth/logout", "auth/logout", "", 204, nil, at).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	require.NoError(t, store.RecordRequestAudit(ctx, &models.RequestAudit{Method: "POST", Path: "/auth/logout",
+		EntityType: "auth/logout", Status: 204, CreatedAt: at}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRequestAuditNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBRequestAuditStore{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM audit_logs WHERE id = $1")).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = store.GetRequestAudit(context.Background(), 7)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
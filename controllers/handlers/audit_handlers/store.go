@@ -0,0 +1,105 @@
+// Package audit_handlers provides SQL-backed storage of the audit trail of API changes and
+// the endpoints admins review it with.
+package audit_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"erp/models"
+)
+
+// DBRequestAuditStore provides SQL-backed methods for the audit_logs table.
+type DBRequestAuditStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// RecordRequestAudit stores a change made through the API.
+//
+// Parameters:
+//   - audit: The change; its ID is populated.
+//
+// Returns:
+//   - error: An error if the query fails.
+func (store *DBRequestAuditStore) RecordRequestAudit(ctx context.Context, audit *models.RequestAudit) error {
+	var changes interface{}
+	if len(audit.Changes) > 0 {
+		changes = []byte(audit.Changes)
+	}
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO audit_logs (actor, method, path, entity_type, entity_id, status, changes, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		audit.Actor, audit.Method, audit.Path, audit.EntityType, audit.EntityID, audit.Status, changes, audit.CreatedAt,
+	).Scan(&audit.ID)
+}
+
+// ListRequestAudits returns the changes matching the filter.
+//
+// Parameters:
+//   - filter: The criteria; empty fields match every change.
+//
+// Returns:
+//   - []models.RequestAudit: The changes, newest first.
+//   - error: An error if the query fails.
+func (store *DBRequestAuditStore) ListRequestAudits(ctx context.Context, filter models.RequestAuditFilter) ([]models.RequestAudit, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT `+columns+` FROM audit_logs
+		 WHERE ($1 = '' OR lower(actor) = lower($1))
+		   AND ($2 = '' OR entity_type = $2)
+		   AND ($3 = '' OR entity_id = $3)
+		   AND created_at >= $4 AND created_at < $5
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT $6`,
+		filter.Actor, filter.EntityType, filter.EntityID, filter.From, filter.To, filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	audits := []models.RequestAudit{}
+	for rows.Next() {
+		audit, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		audits = append(audits, *audit)
+	}
+	return audits, rows.Err()
+}
+
+// GetRequestAudit returns a change made through the API.
+//
+// Returns:
+//   - error: A not found error if there is no such change, or the query error.
+func (store *DBRequestAuditStore) GetRequestAudit(ctx context.Context, id int) (*models.RequestAudit, error) {
+	audit, err := scan(store.DB.QueryRowContext(ctx, `SELECT `+columns+` FROM audit_logs WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("audit log entry %d not found", id)
+	}
+	return audit, err
+}
+
+// columns are the columns read by scan.
+const columns = `id, actor, method, path, entity_type, entity_id, status, changes, created_at`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan reads a change selected with columns.
+func scan(row rowScanner) (*models.RequestAudit, error) {
+	var audit models.RequestAudit
+	var changes []byte
+	err := row.Scan(&audit.ID, &audit.Actor, &audit.Method, &audit.Path, &audit.EntityType, &audit.EntityID,
+		&audit.Status, &changes, &audit.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		audit.Changes = changes
+	}
+	return &audit, nil
+}
@@ -2,22 +2,57 @@
 package auth_handlers
 
 import (
+	"context"
 	"encoding/json"
-	"erp/models"
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
 	"erp/controllers/utils"
+	"erp/models"
 	"errors"
 	"fmt"
+	"io"
 
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// PasswordResetTemplate is the key of the email template with the password reset link. Its
+// variables are name, link and expires.
+const PasswordResetTemplate = "password_reset"
+
+// DefaultSignUpRole is the role of users who sign up when no other is configured.
+const DefaultSignUpRole = "Employee"
+
+// AccessRecorder records sign-in attempts. It is satisfied by *accesslog.Service.
+type AccessRecorder interface {
+	Record(r *http.Request, email string, success bool, reason string)
+}
+
 // AuthHandlers struct contains the user store dependency
 type AuthHandlers struct {
 	UserStore models.UserStore
+	AccessLog AccessRecorder // Records sign-in attempts; nil disables the access log
+
+	// SignUpRole is the role of users who sign up; DefaultSignUpRole if empty. Other roles
+	// are granted by administrators, never by the sign-up request.
+	SignUpRole string
+
+	Tokens     models.TokenStore // Refresh tokens and revoked access tokens; nil disables refresh tokens
+	RefreshTTL time.Duration     // How long a refresh token can be used
+
+	Resets    models.PasswordResetStore // Password reset tokens; nil disables password reset
+	Templates *mailtemplates.Service    // Renders the password reset email
+	Mailer    mailer.Mailer             // Sends the password reset email, e.g. through the outbox
+	ResetURL  string                    // Page of the frontend that takes the reset token
+	ResetTTL  time.Duration             // How long a reset token can be used
 }
 
 // RegisterRoutes registers all the authentication routes
@@ -26,6 +61,10 @@ func (h *AuthHandlers) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/check-user", h.CheckUser).Methods("POST")
 	router.HandleFunc("/set-new-password", h.SetNewPassword).Methods("POST")
 	router.HandleFunc("/login", h.Login).Methods("POST")
+	router.HandleFunc("/refresh", h.Refresh).Methods("POST")
+	router.Handle("/logout", middleware.JWTAuth(http.HandlerFunc(h.Logout))).Methods("POST")
+	router.HandleFunc("/forgot-password", h.ForgotPassword).Methods("POST")
+	router.HandleFunc("/reset-password", h.ResetPassword).Methods("POST")
 }
 
 // SignUp handles the user registration process
@@ -33,56 +72,58 @@ func (h *AuthHandlers) SignUp(w http.ResponseWriter, r *http.Request) {
 	var req models.SignUpRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
 	// Check if the user already exists
-	_, err = h.UserStore.GetUserByEmail(req.Email)
+	_, err = h.UserStore.GetUserByEmail(r.Context(), req.Email)
 	if err == nil {
-		http.Error(w, "User already exists", http.StatusConflict)
+		respond.Error(w, http.StatusConflict, "User already exists")
 		return
 	} else if !errors.Is(err, ErrUserNotFound) {
 		// Log the unexpected error and respond with "Server error"
 		fmt.Println("Error:", err)
-		http.Error(w, "Server error", http.StatusInternalServerError)
+		respond.Error(w, http.StatusInternalServerError, "Server error")
 		return
 	}
 
-	// Insert the new user (with name, email, role, and department)
-	err = h.UserStore.CreateUser(req.Name, req.Email, req.Role, req.Department)
+	// Insert the new user (with name, email, the sign-up role, and department)
+	role := h.SignUpRole
+	if role == "" {
+		role = DefaultSignUpRole
+	}
+	err = h.UserStore.CreateUser(r.Context(), req.Name, req.Email, role, req.Department)
 	if err != nil {
-		http.Error(w, "Could not create user", http.StatusInternalServerError)
+		respond.Error(w, http.StatusInternalServerError, "Could not create user")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("User created successfully"))
+	respond.JSON(w, http.StatusCreated, models.MessageResponse{Message: "User created successfully"})
 }
 
 // CheckUser verifies if a user needs to set a new password
 func (h *AuthHandlers) CheckUser(w http.ResponseWriter, r *http.Request) {
-	var req models.User
+	var req models.CheckUserRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid request")
 		return
 	}
 
 	// Check if the user exists
-	existingUser, err := h.UserStore.GetUserByEmail(req.Email)
+	existingUser, err := h.UserStore.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
 		if errors.Is(err, ErrUserNotFound) {
-			http.Error(w, "User not found", http.StatusNotFound)
+			respond.Error(w, http.StatusNotFound, "User not found")
 			return
 		}
-		http.Error(w, "Server error", http.StatusInternalServerError)
+		respond.Error(w, http.StatusInternalServerError, "Server error")
 		return
 	}
 
 	// Respond with whether the user needs to set a new password
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"needsNewPass": existingUser.NeedsNewPass})
+	respond.JSON(w, http.StatusOK, models.CheckUserResponse{NeedsNewPass: existingUser.NeedsNewPass})
 }
 
 // SetNewPassword handles setting a new password for first-time login
@@ -90,20 +131,20 @@ func (h *AuthHandlers) SetNewPassword(w http.ResponseWriter, r *http.Request) {
 	var req models.SetNewPasswordRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
 	// Check if the user exists and needs a new password
-	existingUser, err := h.UserStore.GetUserByEmail(req.Email)
+	existingUser, err := h.UserStore.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		respond.Error(w, http.StatusNotFound, "User not found")
 		log.Println("User not found:", req.Email)
 		return
 	}
 
 	if !existingUser.NeedsNewPass {
-		http.Error(w, "Password already set. Use login instead.", http.StatusConflict)
+		respond.Error(w, http.StatusConflict, "Password already set. Use login instead.")
 		log.Println("User already has a password:", req.Email)
 		return
 	}
@@ -111,21 +152,20 @@ func (h *AuthHandlers) SetNewPassword(w http.ResponseWriter, r *http.Request) {
 	// Hash the new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Error setting password", http.StatusInternalServerError)
+		respond.Error(w, http.StatusInternalServerError, "Error setting password")
 		log.Println("Error hashing password:", err)
 		return
 	}
 
 	// Update the user's password in the database
-	err = h.UserStore.UpdatePassword(req.Email, string(hashedPassword))
+	err = h.UserStore.UpdatePassword(r.Context(), req.Email, string(hashedPassword))
 	if err != nil {
-		http.Error(w, "Error updating password", http.StatusInternalServerError)
+		respond.Error(w, http.StatusInternalServerError, "Error updating password")
 		log.Println("Error updating password in database:", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Password set successfully"))
+	respond.JSON(w, http.StatusOK, models.MessageResponse{Message: "Password set successfully"})
 	log.Println("Password set successfully for user:", req.Email)
 }
 
@@ -134,44 +174,306 @@ func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 	var credentials models.LoginCredentials
 	err := json.NewDecoder(r.Body).Decode(&credentials)
 	if err != nil || credentials.Password == "" {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
 	// Retrieve the user's hashed password and check if the user exists
-	existingUser, err := h.UserStore.GetUserByEmail(credentials.Email)
+	existingUser, err := h.UserStore.GetUserByEmail(r.Context(), credentials.Email)
 	if err != nil {
 		if errors.Is(err, ErrUserNotFound) {
-			http.Error(w, "User not found", http.StatusNotFound)
+			h.recordAccess(r, credentials.Email, models.AccessUnknownUser)
+			respond.Error(w, http.StatusNotFound, "User not found")
 			return
 		}
-		http.Error(w, "Server error", http.StatusInternalServerError)
+		respond.Error(w, http.StatusInternalServerError, "Server error")
+		return
+	}
+	if !existingUser.Active {
+		h.recordAccess(r, credentials.Email, models.AccessDeactivated)
+		respond.Error(w, http.StatusForbidden, "User is deactivated")
 		return
 	}
 	if existingUser.NeedsNewPass {
-		http.Error(w, "User needs to set a new password", http.StatusUnauthorized)
+		h.recordAccess(r, credentials.Email, models.AccessPasswordNotSet)
+		respond.Error(w, http.StatusUnauthorized, "User needs to set a new password")
 		return
 	}
 
 	// Compare the provided password with the stored hashed password
 	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(credentials.Password))
 	if err != nil {
-		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		h.recordAccess(r, credentials.Email, models.AccessInvalidPassword)
+		respond.Error(w, http.StatusUnauthorized, "Invalid password")
 		return
 	}
 
 	// Generate JWT token
 	tokenString, err := utils.GenerateJWT(existingUser.Email, existingUser.Role.RoleName)
 	if err != nil {
-		http.Error(w, "Could not generate token", http.StatusInternalServerError)
+		respond.Error(w, http.StatusInternalServerError, "Could not generate token")
 		return
 	}
 
-	// Return the generated token along with the user's name and role
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"token": tokenString,
 		"name":  existingUser.Name,
 		"role":  existingUser.Role.RoleName,
+	}
+
+	// Start a new family of refresh tokens for this sign-in
+	if h.Tokens != nil {
+		refreshToken, hash, err := newToken()
+		family, idErr := utils.NewTokenID()
+		if err != nil || idErr != nil {
+			respond.Error(w, http.StatusInternalServerError, "Could not generate token")
+			return
+		}
+		now := time.Now()
+		err = h.Tokens.CreateRefreshToken(r.Context(), &models.RefreshToken{
+			Email: existingUser.Email, Family: family, TokenHash: hash, ExpiresAt: now.Add(h.RefreshTTL), CreatedAt: now,
+		})
+		if err != nil {
+			log.Println("Error storing refresh token:", err)
+			respond.Error(w, http.StatusInternalServerError, "Could not generate token")
+			return
+		}
+		response["refresh_token"] = refreshToken
+		response["expires_in"] = int(utils.AccessTokenTTL.Seconds())
+	}
+
+	h.recordAccess(r, existingUser.Email, "")
+
+	// Return the generated token along with the user's name and role
+	respond.JSON(w, http.StatusOK, response)
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token. The refresh
+// token is spent: presenting it again revokes every token issued from the same sign-in.
+//
+// HTTP Method: POST
+// URL Path: /auth/refresh
+// Request Body: {"refresh_token": "..."}
+// Response:
+//   - Status Code: 200 (OK) with the new "token", "refresh_token" and "expires_in", and the
+//     user's "name" and "role".
+//   - Status Code: 400 (Bad Request) if no refresh token is given.
+//   - Status Code: 401 (Unauthorized) if the refresh token is unknown, expired, revoked or
+//     already used.
+//   - Status Code: 403 (Forbidden) if the user is deactivated.
+func (h *AuthHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if h.Tokens == nil {
+		respond.Error(w, http.StatusNotFound, "Refresh tokens are not enabled")
+		return
+	}
+
+	refreshToken, hash, err := newToken()
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+	now := time.Now()
+	next := &models.RefreshToken{TokenHash: hash, ExpiresAt: now.Add(h.RefreshTTL), CreatedAt: now}
+	spent, err := h.Tokens.RotateRefreshToken(r.Context(), hashToken(req.RefreshToken), next, now)
+	if errors.Is(err, ErrRefreshTokenReused) {
+		log.Println("Refresh token reused; its family has been revoked")
+	}
+	if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+		respond.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		log.Println("Error rotating refresh token:", err)
+		respond.Error(w, http.StatusInternalServerError, "Server error")
+		return
+	}
+
+	// The role is read again, so a changed role takes effect at the next refresh
+	user, err := h.UserStore.GetUserByEmail(r.Context(), spent.Email)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Server error")
+		return
+	}
+	if !user.Active {
+		if err := h.Tokens.RevokeRefreshTokens(r.Context(), user.Email, "", now); err != nil {
+			log.Println("Error revoking refresh tokens:", err)
+		}
+		respond.Error(w, http.StatusForbidden, "User is deactivated")
+		return
+	}
+	tokenString, err := utils.GenerateJWT(user.Email, user.Role.RoleName)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"token":         tokenString,
+		"refresh_token": refreshToken,
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"name":          user.Name,
+		"role":          user.Role.RoleName,
+	})
+}
+
+// Logout revokes the access token the request is made with, so it is refused until it
+// expires. The refresh tokens issued with the given one, or all of the user's refresh
+// tokens, are revoked as well.
+//
+// HTTP Method: POST
+// URL Path: /auth/logout
+// Request Body (optional): {"refresh_token": "...", "all_sessions": false}
+// Response:
+//   - Status Code: 204 (No Content) if the tokens are revoked.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 401 (Unauthorized) if the access token is missing, invalid or revoked.
+func (h *AuthHandlers) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if h.Tokens == nil {
+		respond.Error(w, http.StatusNotFound, "Refresh tokens are not enabled")
+		return
+	}
+	email, err := middleware.GetUserEmailFromContext(r.Context())
+	if err != nil {
+		respond.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	now := time.Now()
+	if id, err := middleware.GetTokenIDFromContext(r.Context()); err == nil {
+		// The token expires no later than one lifetime from now
+		if err := h.Tokens.RevokeAccessToken(r.Context(), id, now.Add(utils.AccessTokenTTL)); err != nil {
+			log.Println("Error revoking access token:", err)
+			respond.Error(w, http.StatusInternalServerError, "Could not revoke token")
+			return
+		}
+	}
+	if req.AllSessions || req.RefreshToken != "" {
+		hash := ""
+		if !req.AllSessions {
+			hash = hashToken(req.RefreshToken)
+		}
+		if err := h.Tokens.RevokeRefreshTokens(r.Context(), email, hash, now); err != nil {
+			log.Println("Error revoking refresh tokens:", err)
+			respond.Error(w, http.StatusInternalServerError, "Could not revoke token")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPassword emails a one-time link to reset the password. The answer is the same
+// whether or not the email belongs to an account, so it cannot be used to find accounts.
+//
+// HTTP Method: POST
+// URL Path: /auth/forgot-password
+// Request Body: {"email": "..."}
+// Response:
+//   - Status Code: 202 (Accepted) whether or not a link was sent.
+//   - Status Code: 400 (Bad Request) if no email is given.
+func (h *AuthHandlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if h.Resets == nil {
+		respond.Error(w, http.StatusNotFound, "Password reset is not enabled")
+		return
+	}
+
+	if err := h.sendResetLink(r.Context(), strings.TrimSpace(req.Email)); err != nil {
+		log.Println("Error sending password reset link:", err)
+		respond.Error(w, http.StatusInternalServerError, "Server error")
+		return
+	}
+	respond.JSON(w, http.StatusAccepted, models.MessageResponse{Message: "If the email belongs to an account, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password with the token from a reset link. The token works once,
+// and every session of the user has to sign in again.
+//
+// HTTP Method: POST
+// URL Path: /auth/reset-password
+// Request Body: {"token": "...", "new_password": "..."}
+// Response:
+//   - Status Code: 200 (OK) if the password is set.
+//   - Status Code: 400 (Bad Request) if the token or password is missing, or the token is
+//     unknown, expired or used.
+func (h *AuthHandlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.NewPassword == "" {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if h.Resets == nil {
+		respond.Error(w, http.StatusNotFound, "Password reset is not enabled")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Error setting password")
+		log.Println("Error hashing password:", err)
+		return
+	}
+	email, err := h.Resets.ResetPassword(r.Context(), hashToken(req.Token), string(hashedPassword), time.Now())
+	if errors.Is(err, ErrInvalidResetToken) {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Error updating password")
+		log.Println("Error resetting password:", err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, models.MessageResponse{Message: "Password reset successfully"})
+	log.Println("Password reset for user:", email)
+}
+
+// sendResetLink emails a reset link to the user with the email, if there is an active one
+func (h *AuthHandlers) sendResetLink(ctx context.Context, email string) error {
+	user, err := h.UserStore.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !user.Active {
+		return nil
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	reset := &models.PasswordReset{Email: user.Email, TokenHash: hash, ExpiresAt: now.Add(h.ResetTTL), CreatedAt: now}
+	if err := h.Resets.CreatePasswordReset(ctx, reset); err != nil {
+		return err
+	}
+	msg, err := h.Templates.Email(ctx, PasswordResetTemplate, "", []string{user.Email}, map[string]string{
+		"name":    user.Name,
+		"link":    h.ResetURL + "?token=" + url.QueryEscape(token),
+		"expires": fmt.Sprintf("%d minutes", int(h.ResetTTL.Minutes())),
 	})
+	if err != nil {
+		return err
+	}
+	return h.Mailer.Send(ctx, msg)
+}
+
+// recordAccess records a sign-in attempt in the access log; an empty reason means success.
+func (h *AuthHandlers) recordAccess(r *http.Request, email, reason string) {
+	if h.AccessLog != nil {
+		h.AccessLog.Record(r, email, reason == "", reason)
+	}
 }
@@ -1 +1,179 @@
 package auth_handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"erp/controllers/handlers/auth_handlers"
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeUserStore struct {
+	users   map[string]*models.User
+	created map[string]string // Role of each created user by email
+}
+
+func (s *fakeUserStore) CreateUser(ctx context.Context, name, email, role, department string) error {
+	if s.created == nil {
+		s.created = map[string]string{}
+	}
+	s.created[email] = role
+	return nil
+}
+
+func (s *fakeUserStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	if user, ok := s.users[email]; ok {
+		return user, nil
+	}
+	return nil, auth_handlers.ErrUserNotFound
+}
+
+func (s *fakeUserStore) UpdatePassword(ctx context.Context, email, hashedPassword string) error {
+	return nil
+}
+
+type fakeResetStore struct {
+	resets    []models.PasswordReset
+	passwords map[string]string
+}
+
+func (s *fakeResetStore) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	s.resets = append(s.resets, *reset)
+	return nil
+}
+
+func (s *fakeResetStore) ResetPassword(ctx context.Context, hash, hashedPassword string, now time.Time) (string, error) {
+	for i, reset := range s.resets {
+		if reset.TokenHash == hash && reset.UsedAt == nil && reset.ExpiresAt.After(now) {
+			s.resets[i].UsedAt = &now
+			s.passwords[reset.Email] = hashedPassword
+			return reset.Email, nil
+		}
+	}
+	return "", auth_handlers.ErrInvalidResetToken
+}
+
+type fakeTemplateStore struct{}
+
+func (fakeTemplateStore) ListCurrentTemplates(ctx context.Context) ([]models.EmailTemplate, error) {
+	return nil, nil
+}
+
+func (fakeTemplateStore) GetTemplate(ctx context.Context, key, locale string, version int) (*models.EmailTemplate, error) {
+	return &models.EmailTemplate{Key: key, Locale: locale, Subject: "Reset your password",
+		Body: "Hello {{name}}, go to {{link}} within {{expires}}.", Variables: []string{"name", "link", "expires"}}, nil
+}
+
+func (fakeTemplateStore) ListTemplateVersions(ctx context.Context, key, locale string) ([]models.EmailTemplate, error) {
+	return nil, nil
+}
+
+func (fakeTemplateStore) AddTemplateVersion(ctx context.Context, template *models.EmailTemplate) error {
+	return nil
+}
+
+// mockMailer keeps the emails it is asked to send.
+type mockMailer struct {
+	sent []mailer.Message
+}
+
+func (m *mockMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func newResetHandlers() (*auth_handlers.AuthHandlers, *fakeResetStore, *mockMailer) {
+	resets := &fakeResetStore{passwords: map[string]string{}}
+	mail := &mockMailer{}
+	h := &auth_handlers.AuthHandlers{
+		UserStore: &fakeUserStore{users: map[string]*models.User{
+			"ana@example.com":  {Name: "Ana", Email: "ana@example.com", Active: true},
+			"gone@example.com": {Name: "Gone", Email: "gone@example.com", Active: false},
+		}},
+		Resets:    resets,
+		Templates: mailtemplates.NewService(fakeTemplateStore{}, "en"),
+		Mailer:    mail,
+		ResetURL:  "https://erp.example.com/reset-password.html",
+		ResetTTL:  time.Hour,
+	}
+	return h, resets, mail
+}
+
+func post(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	return rec
+}
+
+func TestSignUpIgnoresRequestedRole(t *testing.T) {
+	users := &fakeUserStore{}
+	signUp := func(h *auth_handlers.AuthHandlers, email string) {
+		rr := httptest.NewRecorder()
+		h.SignUp(rr, httptest.NewRequest(http.MethodPost, "/auth/signup",
+			strings.NewReader(`{"name": "New", "email": "`+email+`", "role": "Admin", "department": "Sales"}`)))
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	signUp(&auth_handlers.AuthHandlers{UserStore: users}, "ana@example.com")
+	signUp(&auth_handlers.AuthHandlers{UserStore: users, SignUpRole: "Sales Group"}, "ben@example.com")
+	assert.Equal(t, auth_handlers.DefaultSignUpRole, users.created["ana@example.com"])
+	assert.Equal(t, "Sales Group", users.created["ben@example.com"])
+}
+
+func TestForgotAndResetPassword(t *testing.T) {
+	h, resets, mail := newResetHandlers()
+
+	rec := post(h.ForgotPassword, `{"email": "ana@example.com"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Len(t, mail.sent, 1)
+	assert.Equal(t, []string{"ana@example.com"}, mail.sent[0].To)
+	assert.Contains(t, mail.sent[0].Body, "within 60 minutes")
+
+	// The emailed link carries the token; only its hash is stored
+	start := strings.Index(mail.sent[0].Body, "https://")
+	end := strings.Index(mail.sent[0].Body[start:], " ")
+	link, err := url.Parse(mail.sent[0].Body[start : start+end])
+	require.NoError(t, err)
+	token := link.Query().Get("token")
+	require.NotEmpty(t, token)
+	require.Len(t, resets.resets, 1)
+	assert.NotEqual(t, token, resets.resets[0].TokenHash)
+
+	rec = post(h.ResetPassword, `{"token": "`+token+`", "new_password": "n3w-secret"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(resets.passwords["ana@example.com"]), []byte("n3w-secret")))
+
+	// The token works once
+	rec = post(h.ResetPassword, `{"token": "`+token+`", "new_password": "again"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	h, resets, mail := newResetHandlers()
+
+	for _, email := range []string{"nobody@example.com", "gone@example.com"} {
+		rec := post(h.ForgotPassword, `{"email": "`+email+`"}`)
+		assert.Equal(t, http.StatusAccepted, rec.Code, email)
+	}
+	assert.Empty(t, mail.sent)
+	assert.Empty(t, resets.resets)
+}
+
+func TestResetPasswordRequiresTokenAndPassword(t *testing.T) {
+	h, _, _ := newResetHandlers()
+
+	assert.Equal(t, http.StatusBadRequest, post(h.ResetPassword, `{"token": "abc"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(h.ResetPassword, `{"new_password": "secret"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(h.ResetPassword, `{"token": "unknown", "new_password": "secret"}`).Code)
+}
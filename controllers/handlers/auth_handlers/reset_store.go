@@ -0,0 +1,76 @@
+package auth_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/models"
+	"time"
+)
+
+// ErrInvalidResetToken is returned when a password reset token does not exist, has expired
+// or was used already.
+var ErrInvalidResetToken = models.Invalid("reset token is invalid or expired")
+
+// DBPasswordResetStore implements PasswordResetStore using a SQL database
+type DBPasswordResetStore struct {
+	DB *sql.DB
+}
+
+// CreatePasswordReset stores a reset token and discards the user's earlier unused ones
+func (s *DBPasswordResetStore) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO password_resets (user_id, token_hash, expires_at, created_at)
+		SELECT id, $2, $3, $4 FROM users WHERE email = $1 RETURNING id, user_id`,
+		reset.Email, reset.TokenHash, reset.ExpiresAt, reset.CreatedAt).Scan(&reset.ID, &reset.UserID)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = $1 AND id <> $2 AND used_at IS NULL", reset.UserID, reset.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResetPassword spends a reset token, sets the password and revokes the user's refresh tokens
+func (s *DBPasswordResetStore) ResetPassword(ctx context.Context, hash, hashedPassword string, now time.Time) (string, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var id, userID int
+	var email string
+	var expiresAt time.Time
+	var usedAt *time.Time
+	err = tx.QueryRowContext(ctx, `SELECT r.id, r.user_id, u.email, r.expires_at, r.used_at
+		FROM password_resets r JOIN users u ON u.id = r.user_id
+		WHERE r.token_hash = $1 FOR UPDATE OF r`, hash).Scan(&id, &userID, &email, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidResetToken
+	} else if err != nil {
+		return "", err
+	}
+	if usedAt != nil || !expiresAt.After(now) {
+		return "", ErrInvalidResetToken
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE password_resets SET used_at = $2 WHERE id = $1", id, now); err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = $2 WHERE id = $1", userID, hashedPassword); err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL", userID, now); err != nil {
+		return "", err
+	}
+	return email, tx.Commit()
+}
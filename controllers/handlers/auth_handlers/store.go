@@ -1,45 +1,56 @@
 package auth_handlers
 
 import (
+	"context"
 	"database/sql"
 	"erp/models"
-	"errors"
+	"erp/models/db"
 	"fmt"
 )
 
-// ErrUserNotFound is returned when a user cannot be found in the database
-var ErrUserNotFound = errors.New("user not found")
+// ErrUserNotFound is returned when a user cannot be found in the database. It wraps
+// models.ErrNotFound.
+var ErrUserNotFound = models.NotFound("user not found")
 
 // DBUserStore implements UserStore using a SQL database
 type DBUserStore struct {
 	DB        *sql.DB
 	RoleStore models.RoleStore // RoleStore dependency to fetch roles
+
+	stmts db.Statements // Hot queries, prepared on first use
 }
 
+// selectUserByEmailSQL looks up a user at every login, so it is prepared once per store
+const selectUserByEmailSQL = "SELECT id, name, email, password, role_id, department, needs_new_pass, active FROM users WHERE email = $1"
+
 // CreateUser inserts a new user into the database with the specified name, role, and department
-func (s *DBUserStore) CreateUser(name, email, roleName, department string) error {
+func (s *DBUserStore) CreateUser(ctx context.Context, name, email, roleName, department string) error {
     // Retrieve the role ID based on the role name
-    role, err := s.RoleStore.GetRoleByName(roleName)
+    role, err := s.RoleStore.GetRoleByName(ctx, roleName)
     if err != nil {
         return err // Role not found or other error
     }
 
     // Insert the new user with the retrieved role ID and specified name
-    _, err = s.DB.Exec("INSERT INTO users (name, email, role_id, department) VALUES ($1, $2, $3, $4)", name, email, role.ID, department)
+    _, err = s.DB.ExecContext(ctx, "INSERT INTO users (name, email, role_id, department) VALUES ($1, $2, $3, $4)", name, email, role.ID, department)
 	fmt.Println("Eror in CreateUser", err)
     return err
 }
 
 
 // GetUserByEmail fetches a user by email along with their role information
-func (s *DBUserStore) GetUserByEmail(email string) (*models.User, error) {
+func (s *DBUserStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
     var user models.User
     var roleID int
     var existingPassword sql.NullString
 
     // Retrieve the user's information, including the name
-    err := s.DB.QueryRow("SELECT id, name, email, password, role_id, department, needs_new_pass FROM users WHERE email = $1", email).Scan(
-        &user.ID, &user.Name, &user.Email, &existingPassword, &roleID, &user.Department, &user.NeedsNewPass)
+    query, err := s.stmts.Prepare(ctx, s.DB, selectUserByEmailSQL)
+    if err != nil {
+        return nil, err
+    }
+    err = query.QueryRowContext(ctx, email).Scan(
+        &user.ID, &user.Name, &user.Email, &existingPassword, &roleID, &user.Department, &user.NeedsNewPass, &user.Active)
     
     if err == sql.ErrNoRows {
         return nil, ErrUserNotFound // Custom error for "user not found"
@@ -51,7 +62,7 @@ func (s *DBUserStore) GetUserByEmail(email string) (*models.User, error) {
     user.NeedsNewPass = !existingPassword.Valid || existingPassword.String == ""
 
     // Retrieve the role by ID and assign it to the user
-    role, err := s.RoleStore.GetRoleByID(roleID)
+    role, err := s.RoleStore.GetRoleByID(ctx, roleID)
     if err != nil {
         return nil, err
     }
@@ -60,23 +71,32 @@ func (s *DBUserStore) GetUserByEmail(email string) (*models.User, error) {
 }
 
 // UpdatePassword updates the user's password in the database
-func (s *DBUserStore) UpdatePassword(email, hashedPassword string) error {
-	_, err := s.DB.Exec("UPDATE users SET password=$1 WHERE email=$2", hashedPassword, email)
+func (s *DBUserStore) UpdatePassword(ctx context.Context, email, hashedPassword string) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE users SET password=$1 WHERE email=$2", hashedPassword, email)
 	return err
 }
 
 // DBRoleStore implements RoleStore using a SQL database
 type DBRoleStore struct {
 	DB *sql.DB
+
+	stmts db.Statements // Hot queries, prepared on first use
 }
 
+// selectRoleByIDSQL runs with every user lookup, so it is prepared once per store
+const selectRoleByIDSQL = "SELECT id, role_name, permissions FROM roles WHERE id=$1"
+
 // GetRoleByID retrieves a role by its ID
-func (s *DBRoleStore) GetRoleByID(id int) (*models.Role, error) {
+func (s *DBRoleStore) GetRoleByID(ctx context.Context, id int) (*models.Role, error) {
 	var role models.Role
-	err := s.DB.QueryRow("SELECT id, role_name, permissions FROM roles WHERE id=$1", id).Scan(
+	query, err := s.stmts.Prepare(ctx, s.DB, selectRoleByIDSQL)
+	if err != nil {
+		return nil, err
+	}
+	err = query.QueryRowContext(ctx, id).Scan(
 		&role.ID, &role.RoleName, &role.Permissions)
 	if err == sql.ErrNoRows {
-		return nil, errors.New("role not found")
+		return nil, models.NotFound("role %d not found", id)
 	} else if err != nil {
 		return nil, err
 	}
@@ -84,12 +104,12 @@ func (s *DBRoleStore) GetRoleByID(id int) (*models.Role, error) {
 }
 
 // GetRoleByName retrieves a role by its name
-func (s *DBRoleStore) GetRoleByName(roleName string) (*models.Role, error) {
+func (s *DBRoleStore) GetRoleByName(ctx context.Context, roleName string) (*models.Role, error) {
 	var role models.Role
-	err := s.DB.QueryRow("SELECT id, role_name, permissions FROM roles WHERE role_name=$1", roleName).Scan(
+	err := s.DB.QueryRowContext(ctx, "SELECT id, role_name, permissions FROM roles WHERE role_name=$1", roleName).Scan(
 		&role.ID, &role.RoleName, &role.Permissions)
 	if err == sql.ErrNoRows {
-		return nil, errors.New("role not found")
+		return nil, models.NotFound("role %q not found", roleName)
 	} else if err != nil {
 		return nil, err
 	}
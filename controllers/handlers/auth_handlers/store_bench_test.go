@@ -0,0 +1,58 @@
+package auth_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/models/db/dbtest"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// BenchmarkGetUserByEmail compares the user lookup done at every login with the SQL text
+// sent on every call ("adhoc", as the store did before) against the store's prepared
+// lookups. It needs ERP_BENCH_DATABASE_URL; see the dbtest package.
+func BenchmarkGetUserByEmail(b *testing.B) {
+	ctx := context.Background()
+	db := dbtest.Open(ctx, b)
+
+	email := fmt.Sprintf("bench-%d@example.com", time.Now().UnixNano())
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO users (name, email, role_id, department) SELECT 'Benchmark', $1, id, 'IT' FROM roles WHERE role_name = 'Admin'",
+		email,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.ExecContext(ctx, "DELETE FROM users WHERE email = $1", email) })
+
+	b.Run("adhoc", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var id, roleID int
+			var name, userEmail, department string
+			var password sql.NullString
+			var needsNewPass, active bool
+			err := db.QueryRowContext(ctx, selectUserByEmailSQL, email).Scan(&id, &name, &userEmail, &password, &roleID, &department, &needsNewPass, &active)
+			if err == nil {
+				var roleName string
+				var permissions sql.NullString
+				err = db.QueryRowContext(ctx, selectRoleByIDSQL, roleID).Scan(&roleID, &roleName, &permissions)
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		roles := &DBRoleStore{DB: db}
+		users := &DBUserStore{DB: db, RoleStore: roles}
+		defer roles.stmts.Close()
+		defer users.stmts.Close()
+		for i := 0; i < b.N; i++ {
+			if _, err := users.GetUserByEmail(ctx, email); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package auth_handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"erp/models"
+	"erp/models/db"
+	"time"
+)
+
+// Errors returned when a refresh token cannot be exchanged. Both are answered with 401, so
+// the client signs in again.
+var (
+	ErrInvalidRefreshToken = models.PermissionDenied("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = models.PermissionDenied("refresh token was already used; sign in again")
+)
+
+// DBTokenStore implements TokenStore using a SQL database
+type DBTokenStore struct {
+	DB *sql.DB
+
+	stmts db.Statements // Hot queries, prepared on first use
+}
+
+// isTokenRevokedSQL runs with every authenticated request, so it is prepared once per store
+const isTokenRevokedSQL = "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE token_id = $1)"
+
+// CreateRefreshToken stores the first refresh token of a family
+func (s *DBTokenStore) CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	err := s.DB.QueryRowContext(ctx, `INSERT INTO refresh_tokens (user_id, family, token_hash, expires_at, created_at)
+		SELECT id, $2, $3, $4, $5 FROM users WHERE email = $1 RETURNING id, user_id`,
+		t.Email, t.Family, t.TokenHash, t.ExpiresAt, t.CreatedAt).Scan(&t.ID, &t.UserID)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// RotateRefreshToken spends a refresh token and stores the next one of its family. A token
+// that was spent already is being replayed, so the family is revoked and
+// ErrRefreshTokenReused returned; unknown, revoked and expired tokens give
+// ErrInvalidRefreshToken.
+func (s *DBTokenStore) RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken, now time.Time) (*models.RefreshToken, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	t := models.RefreshToken{TokenHash: hash}
+	err = tx.QueryRowContext(ctx, `SELECT t.id, t.user_id, u.email, t.family, t.expires_at, t.created_at, t.used_at, t.revoked_at
+		FROM refresh_tokens t JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 FOR UPDATE OF t`, hash).Scan(
+		&t.ID, &t.UserID, &t.Email, &t.Family, &t.ExpiresAt, &t.CreatedAt, &t.UsedAt, &t.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t.RevokedAt != nil, !t.ExpiresAt.After(now):
+		return nil, ErrInvalidRefreshToken
+	case t.UsedAt != nil:
+		if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $2 WHERE family = $1 AND revoked_at IS NULL", t.Family, now); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = $2 WHERE id = $1", t.ID, now); err != nil {
+		return nil, err
+	}
+	next.UserID, next.Email, next.Family = t.UserID, t.Email, t.Family
+	err = tx.QueryRowContext(ctx, `INSERT INTO refresh_tokens (user_id, family, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		next.UserID, next.Family, next.TokenHash, next.ExpiresAt, next.CreatedAt).Scan(&next.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	t.UsedAt = &now
+	return &t, nil
+}
+
+// RevokeRefreshTokens revokes a family of the user's refresh tokens, or all of them when
+// hash is empty. Tokens that do not exist or belong to someone else are ignored.
+func (s *DBTokenStore) RevokeRefreshTokens(ctx context.Context, email, hash string, now time.Time) error {
+	if hash == "" {
+		_, err := s.DB.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = $2
+			WHERE revoked_at IS NULL AND user_id = (SELECT id FROM users WHERE email = $1)`, email, now)
+		return err
+	}
+	_, err := s.DB.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = $3
+		WHERE revoked_at IS NULL AND family = (
+			SELECT t.family FROM refresh_tokens t JOIN users u ON u.id = t.user_id
+			WHERE t.token_hash = $2 AND u.email = $1)`, email, hash, now)
+	return err
+}
+
+// RevokeAccessToken puts an access token on the revocation list. Tokens that have expired
+// since they were revoked are removed from the list at the same time.
+func (s *DBTokenStore) RevokeAccessToken(ctx context.Context, id string, expiresAt time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO revoked_tokens (token_id, expires_at) VALUES ($1, $2)
+		ON CONFLICT (token_id) DO NOTHING`, id, expiresAt)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < NOW()")
+	return err
+}
+
+// IsTokenRevoked reports whether an access token is on the revocation list
+func (s *DBTokenStore) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	query, err := s.stmts.Prepare(ctx, s.DB, isTokenRevokedSQL)
+	if err != nil {
+		return false, err
+	}
+	var revoked bool
+	err = query.QueryRowContext(ctx, id).Scan(&revoked)
+	return revoked, err
+}
+
+// newToken returns a random refresh or password reset token and the hash that is stored
+// for it
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hash a token is stored and looked up by
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
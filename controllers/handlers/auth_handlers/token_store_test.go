@@ -0,0 +1,90 @@
+package auth_handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tokenColumns = []string{"id", "user_id", "email", "family", "expires_at", "created_at", "used_at", "revoked_at"}
+
+func TestRotateRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBTokenStore{DB: db}
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	next := &models.RefreshToken{TokenHash: "next", ExpiresAt: now.AddDate(0, 1, 0), CreatedAt: now}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM refresh_tokens t JOIN users u")).WithArgs("spent").
+		WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow(4, 7, "ana@example.com", "fam", now.Add(time.Hour), now.Add(-time.Hour), nil, nil))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE refresh_tokens SET used_at = $2 WHERE id = $1")).WithArgs(4, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO refresh_tokens")).WithArgs(7, "fam", "next", next.ExpiresAt, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectCommit()
+
+	spent, err := store.RotateRefreshToken(context.Background(), "spent", next, now)
+	require.NoError(t, err)
+	assert.Equal(t, "ana@example.com", spent.Email)
+	assert.Equal(t, 5, next.ID)
+	assert.Equal(t, "fam", next.Family)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshTokenRevokesFamilyOnReuse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBTokenStore{DB: db}
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	used := now.Add(-time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM refresh_tokens t JOIN users u")).WithArgs("spent").
+		WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow(4, 7, "ana@example.com", "fam", now.Add(time.Hour), now.Add(-time.Hour), used, nil))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE refresh_tokens SET revoked_at = $2 WHERE family = $1")).WithArgs("fam", now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	_, err = store.RotateRefreshToken(context.Background(), "spent", &models.RefreshToken{TokenHash: "next"}, now)
+	assert.True(t, errors.Is(err, ErrRefreshTokenReused), "%v", err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshTokenRefusesExpiredTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBTokenStore{DB: db}
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM refresh_tokens t JOIN users u")).WithArgs("old").
+		WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow(4, 7, "ana@example.com", "fam", now.Add(-time.Second), now.AddDate(0, -1, 0), nil, nil))
+	mock.ExpectRollback()
+
+	_, err = store.RotateRefreshToken(context.Background(), "old", &models.RefreshToken{TokenHash: "next"}, now)
+	assert.True(t, errors.Is(err, ErrInvalidRefreshToken), "%v", err)
+	assert.True(t, errors.Is(err, models.ErrPermissionDenied))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewTokenIsStoredByHash(t *testing.T) {
+	token, hash, err := newToken()
+	require.NoError(t, err)
+	other, _, err := newToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+	assert.Equal(t, hashToken(token), hash)
+	assert.NotContains(t, hash, token)
+}
@@ -0,0 +1,151 @@
+// Package backup_handlers provides the admin API for taking backups of the application
+// data and verifying that a backup can be read back.
+package backup_handlers
+
+import (
+	"context"
+	"erp/controllers/backup"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// listLimit is the number of recent backups listed.
+const listLimit = 50
+
+// Backuper starts backup and verify jobs. It is satisfied by *backup.Service.
+type Backuper interface {
+	Start(ctx context.Context, actor string) (*models.Job, error)
+	StartVerify(ctx context.Context, backupJob *models.Job, actor string) (*models.Job, error)
+}
+
+// BackupHandler provides HTTP handlers for backups.
+type BackupHandler struct {
+	Backups Backuper
+	Jobs    models.JobStore
+}
+
+// RegisterRoutes maps backup routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth and limited to admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - backups: Starts backup and verify jobs.
+//   - jobs: An implementation of the JobStore interface.
+func RegisterRoutes(router *mux.Router, backups Backuper, jobs models.JobStore) {
+	handler := &BackupHandler{Backups: backups, Jobs: jobs}
+
+	router.HandleFunc("", handler.CreateBackup).Methods("POST")
+	router.HandleFunc("", handler.ListBackups).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetBackup).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/verify", handler.VerifyBackup).Methods("POST")
+}
+
+// CreateBackup starts a backup of every application table in the background.
+//
+// HTTP Method: POST
+// URL Path: /
+//
+// Response:
+//   - Status Code: 202 (Accepted) with the queued Job in JSON and its URL in the Location
+//     header. Poll it until the status is "succeeded" or "failed".
+//   - Status Code: 500 (Internal Server Error) if the job cannot be created.
+func (h *BackupHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	job, err := h.Backups.Start(r.Context(), actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to start backup")
+		return
+	}
+	writeAccepted(w, fmt.Sprintf("/backups/%d", job.ID), job)
+}
+
+// ListBackups returns the most recent backup jobs, newest first. The result of a
+// successful backup holds its file, checksum and row counts.
+//
+// HTTP Method: GET
+// URL Path: /
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of Jobs in JSON.
+func (h *BackupHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.Jobs.ListJobs(r.Context(), backup.KindBackup, listLimit)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load backups")
+		return
+	}
+	respond.JSON(w, http.StatusOK, jobs)
+}
+
+// GetBackup returns a backup job.
+//
+// HTTP Method: GET
+// URL Path: /{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the Job in JSON.
+//   - Status Code: 404 (Not Found) if no backup has the ID.
+func (h *BackupHandler) GetBackup(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.loadBackup(w, r)
+	if !ok {
+		return
+	}
+	respond.JSON(w, http.StatusOK, job)
+}
+
+// VerifyBackup starts reading a backup back in full to check that it is intact and could
+// be restored: the checksum matches and every table's rows are present.
+//
+// HTTP Method: POST
+// URL Path: /{id}/verify
+//
+// Response:
+//   - Status Code: 202 (Accepted) with the queued verify Job in JSON and its URL in the
+//     Location header.
+//   - Status Code: 404 (Not Found) if no backup has the ID.
+//   - Status Code: 409 (Conflict) if the backup has not succeeded.
+func (h *BackupHandler) VerifyBackup(w http.ResponseWriter, r *http.Request) {
+	backupJob, ok := h.loadBackup(w, r)
+	if !ok {
+		return
+	}
+
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	job, err := h.Backups.StartVerify(r.Context(), backupJob, actor)
+	if err == backup.ErrNotBackup {
+		respond.Error(w, http.StatusConflict, "Only a succeeded backup can be verified")
+		return
+	} else if err != nil {
+		httperr.Write(w, err, "Failed to start verification")
+		return
+	}
+	writeAccepted(w, fmt.Sprintf("/jobs/%d", job.ID), job)
+}
+
+// loadBackup reads the backup job named in the URL, writing an error response if there
+// is none.
+func (h *BackupHandler) loadBackup(w http.ResponseWriter, r *http.Request) (*models.Job, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	job, err := h.Jobs.GetJob(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) || (err == nil && job.Kind != backup.KindBackup) {
+		respond.Error(w, http.StatusNotFound, "Backup not found")
+		return nil, false
+	} else if err != nil {
+		httperr.Write(w, err, "Failed to load backup")
+		return nil, false
+	}
+	return job, true
+}
+
+// writeAccepted writes a 202 response for a job that runs in the background.
+func writeAccepted(w http.ResponseWriter, location string, job *models.Job) {
+	w.Header().Set("Location", location)
+	respond.JSON(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,115 @@
+package backup_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erp/controllers/backup"
+	"erp/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackuper records the jobs it is asked to start.
+type fakeBackuper struct {
+	verified *models.Job
+}
+
+func (f *fakeBackuper) Start(ctx context.Context, actor string) (*models.Job, error) {
+	return &models.Job{ID: 7, Kind: backup.KindBackup, Status: models.JobQueued, CreatedBy: actor}, nil
+}
+
+func (f *fakeBackuper) StartVerify(ctx context.Context, backupJob *models.Job, actor string) (*models.Job, error) {
+	if backupJob.Status != models.JobSucceeded {
+		return nil, backup.ErrNotBackup
+	}
+	f.verified = backupJob
+	return &models.Job{ID: 8, Kind: backup.KindVerify, Status: models.JobQueued, CreatedBy: actor}, nil
+}
+
+// fakeJobStore serves a fixed set of jobs.
+type fakeJobStore struct {
+	models.JobStore
+	jobs map[int]*models.Job
+}
+
+func (f *fakeJobStore) GetJob(ctx context.Context, id int) (*models.Job, error) {
+	job, ok := f.jobs[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return job, nil
+}
+
+func (f *fakeJobStore) ListJobs(ctx context.Context, kind string, limit int) ([]models.Job, error) {
+	list := []models.Job{}
+	for _, job := range f.jobs {
+		if job.Kind == kind {
+			list = append(list, *job)
+		}
+	}
+	return list, nil
+}
+
+func setupRouter() (*mux.Router, *fakeBackuper) {
+	backups := &fakeBackuper{}
+	store := &fakeJobStore{jobs: map[int]*models.Job{
+		1: {ID: 1, Kind: backup.KindBackup, Status: models.JobSucceeded},
+		2: {ID: 2, Kind: backup.KindBackup, Status: models.JobFailed},
+		3: {ID: 3, Kind: backup.KindVerify, Status: models.JobSucceeded},
+	}}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/backups").Subrouter(), backups, store)
+	return router, backups
+}
+
+func serve(router *mux.Router, method, path string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+	return rr
+}
+
+func TestCreateBackup(t *testing.T) {
+	router, _ := setupRouter()
+
+	rr := serve(router, "POST", "/backups")
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "/backups/7", rr.Header().Get("Location"))
+
+	var job models.Job
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&job))
+	assert.Equal(t, models.JobQueued, job.Status)
+}
+
+func TestListBackupsOnlyListsBackups(t *testing.T) {
+	router, _ := setupRouter()
+
+	rr := serve(router, "GET", "/backups")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var jobs []models.Job
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&jobs))
+	assert.Len(t, jobs, 2)
+}
+
+func TestGetBackup(t *testing.T) {
+	router, _ := setupRouter()
+
+	assert.Equal(t, http.StatusOK, serve(router, "GET", "/backups/1").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/backups/3").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/backups/99").Code)
+}
+
+func TestVerifyBackup(t *testing.T) {
+	router, backups := setupRouter()
+
+	rr := serve(router, "POST", "/backups/1/verify")
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "/jobs/8", rr.Header().Get("Location"))
+	assert.Equal(t, 1, backups.verified.ID)
+
+	assert.Equal(t, http.StatusConflict, serve(router, "POST", "/backups/2/verify").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "POST", "/backups/3/verify").Code)
+}
@@ -0,0 +1,189 @@
+// Package bank_handlers provides HTTP handlers and the database store for importing bank
+// statement lines and working the queue of suspense items left by those that match no
+// invoice.
+package bank_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/banking"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// BankHandler provides HTTP handlers for bank statements and suspense items.
+type BankHandler struct {
+	Service *banking.Service
+}
+
+// ImportRequest is the request body for importing bank statement lines.
+type ImportRequest struct {
+	Lines []models.BankStatementLine `json:"lines"`
+}
+
+// ImportResponse reports the outcome of an import.
+type ImportResponse struct {
+	Lines      []models.BankStatementLine `json:"lines"`
+	Matched    int                        `json:"matched"`
+	Suspense   int                        `json:"suspense"`
+	Duplicates int                        `json:"duplicates"`
+}
+
+// RegisterRoutes maps bank routes to their handler functions. The router is expected to be
+// protected with middleware.JWTAuth and limited to finance.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The bank handler.
+func RegisterRoutes(router *mux.Router, handler *BankHandler) {
+	router.HandleFunc("/statement_lines", handler.ImportLines).Methods("POST")
+	router.HandleFunc("/suspense", handler.ListSuspenseItems).Methods("GET")
+	router.HandleFunc("/suspense/aging", handler.GetSuspenseAging).Methods("GET")
+	router.HandleFunc("/suspense/{id:[0-9]+}", handler.GetSuspenseItem).Methods("GET")
+	router.HandleFunc("/suspense/{id:[0-9]+}/resolve", handler.ResolveSuspenseItem).Methods("POST")
+}
+
+// ImportLines imports bank statement lines. Receipts naming a posted invoice ("INV-42",
+// "Invoice #42") are applied to it; the other lines are posted to the suspense account.
+//
+// HTTP Method: POST
+// URL Path: /bank/statement_lines
+//
+// Request Body:
+//   - JSON with lines, each with date, amount (negative for payments), description,
+//     reference and an optional external_id from the bank.
+//
+// Response:
+//   - Status Code: 201 (Created) with the lines and the count of each outcome in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if a line has no date, amount or description.
+//   - Status Code: 500 (Internal Server Error) if the lines cannot be imported.
+func (h *BankHandler) ImportLines(w http.ResponseWriter, r *http.Request) {
+	var req ImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	lines, err := h.Service.Import(r.Context(), req.Lines, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to import bank statement lines")
+		return
+	}
+	response := ImportResponse{Lines: lines}
+	for _, line := range lines {
+		switch line.Status {
+		case models.BankLineMatched:
+			response.Matched++
+		case models.BankLineSuspense:
+			response.Suspense++
+		case models.BankLineDuplicate:
+			response.Duplicates++
+		}
+	}
+	respond.JSON(w, http.StatusCreated, response)
+}
+
+// ListSuspenseItems returns the work queue of suspense items, oldest first.
+//
+// HTTP Method: GET
+// URL Path: /bank/suspense?status=open (optional; open or resolved)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of suspense items and their age in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the items cannot be loaded.
+func (h *BankHandler) ListSuspenseItems(w http.ResponseWriter, r *http.Request) {
+	items, err := h.Service.Queue(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load suspense items")
+		return
+	}
+	respond.JSON(w, http.StatusOK, items)
+}
+
+// GetSuspenseItem returns a suspense item.
+//
+// HTTP Method: GET
+// URL Path: /bank/suspense/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the suspense item in JSON.
+//   - Status Code: 404 (Not Found) if the item does not exist.
+//   - Status Code: 500 (Internal Server Error) if the item cannot be loaded.
+func (h *BankHandler) GetSuspenseItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	item, err := h.Service.Item(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load suspense item")
+		return
+	}
+	respond.JSON(w, http.StatusOK, item)
+}
+
+// ResolveSuspenseItem reclassifies a suspense item to the invoice it pays or to the account
+// it belongs to.
+//
+// HTTP Method: POST
+// URL Path: /bank/suspense/{id}/resolve
+//
+// Request Body:
+//   - JSON with either invoice_id or account, and a note.
+//
+// Response:
+//   - Status Code: 200 (OK) with the resolved item in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the item or invoice does not exist.
+//   - Status Code: 409 (Conflict) if the item is resolved already.
+//   - Status Code: 422 (Unprocessable Entity) if the resolution is incomplete or the invoice
+//     cannot take the payment.
+//   - Status Code: 500 (Internal Server Error) if the item cannot be resolved.
+func (h *BankHandler) ResolveSuspenseItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var res models.SuspenseResolution
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	item, err := h.Service.Resolve(r.Context(), id, res, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to resolve suspense item")
+		return
+	}
+	respond.JSON(w, http.StatusOK, item)
+}
+
+// GetSuspenseAging returns the open suspense items by age: 0-30, 31-60, 61-90 and over 90
+// days since the bank line.
+//
+// HTTP Method: GET
+// URL Path: /bank/suspense/aging?as_of=2025-06-30 (optional; today by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with the aging in JSON.
+//   - Status Code: 400 (Bad Request) if as_of is not a date.
+//   - Status Code: 500 (Internal Server Error) if the items cannot be loaded.
+func (h *BankHandler) GetSuspenseAging(w http.ResponseWriter, r *http.Request) {
+	var asOf time.Time
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		var err error
+		if asOf, err = time.Parse("2006-01-02", value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "as_of must be formatted as YYYY-MM-DD")
+			return
+		}
+	}
+	aging, err := h.Service.Aging(r.Context(), asOf)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load suspense aging")
+		return
+	}
+	respond.JSON(w, http.StatusOK, aging)
+}
@@ -0,0 +1,278 @@
+package bank_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/config"
+	"erp/controllers/audit"
+	"erp/controllers/events"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+)
+
+// receivableAccount is credited when a bank receipt pays an invoice.
+const receivableAccount = "accounts_receivable"
+
+// DBBankStore implements models.BankStore using a SQL database.
+type DBBankStore struct {
+	DB       *sql.DB
+	Accounts config.BankConfig // Ledger accounts of the bank and of suspense
+}
+
+// suspenseColumns are the columns read by scanSuspenseItem.
+const suspenseColumns = `s.id, s.line_id, l.line_date, s.amount, l.description, l.reference, s.account, s.status,
+	s.resolved_account, s.invoice_id, s.payment_id, s.note, s.resolved_by, s.resolved_at, s.created_at
+	FROM suspense_items s JOIN bank_statement_lines l ON l.id = s.line_id`
+
+// scanSuspenseItem reads a row of suspenseColumns.
+func scanSuspenseItem(row interface{ Scan(...interface{}) error }) (*models.SuspenseItem, error) {
+	var item models.SuspenseItem
+	var resolvedAccount, resolvedBy sql.NullString
+	err := row.Scan(&item.ID, &item.LineID, &item.Date, &item.Amount, &item.Description, &item.Reference,
+		&item.Account, &item.Status, &resolvedAccount, &item.InvoiceID, &item.PaymentID, &item.Note, &resolvedBy,
+		&item.ResolvedAt, &item.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	item.ResolvedAccount, item.ResolvedBy = resolvedAccount.String, resolvedBy.String
+	return &item, nil
+}
+
+// ImportBankLine records a statement line and its ledger postings in one transaction. A
+// matched receipt debits the bank and credits the invoice's receivable through a payment;
+// any other line is posted between the bank and the suspense account and opens a suspense
+// item.
+//
+// Returns:
+//   - error: A conflict if a line with the external ID was imported before, or the query error.
+func (s *DBBankStore) ImportBankLine(ctx context.Context, line *models.BankStatementLine, invoiceID *int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	line.Status = models.BankLineSuspense
+	if invoiceID != nil {
+		err := payableInvoice(ctx, tx, *invoiceID, line.Amount)
+		if err == nil {
+			line.Status = models.BankLineMatched
+		} else if k := models.Kind(err); k != models.ErrNotFound && k != models.ErrValidation {
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO bank_statement_lines (external_id, line_date, amount, description, reference, status, imported_by, imported_at)
+		 VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		line.ExternalID, line.Date, line.Amount, line.Description, line.Reference, line.Status, line.ImportedBy, line.ImportedAt,
+	).Scan(&line.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("bank line %s was imported before", line.ExternalID)
+	} else if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Bank line %d: %s", line.ID, label(line.Description, line.Reference))
+	if line.Status == models.BankLineMatched {
+		paymentID, err := insertPayment(ctx, tx, *invoiceID, line.Amount, line.Date)
+		if err != nil {
+			return err
+		}
+		line.InvoiceID, line.PaymentID = invoiceID, &paymentID
+		if _, err := tx.ExecContext(ctx, `UPDATE bank_statement_lines SET invoice_id = $1, payment_id = $2 WHERE id = $3`,
+			invoiceID, paymentID, line.ID); err != nil {
+			return err
+		}
+		if err := post(ctx, tx, line.Date, description, invoiceID, s.Accounts.BankAccount, receivableAccount, line.Amount); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	var itemID int
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO suspense_items (line_id, amount, account, status, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		line.ID, line.Amount, s.Accounts.SuspenseAccount, models.SuspenseOpen, line.ImportedAt,
+	).Scan(&itemID)
+	if err != nil {
+		return err
+	}
+	line.SuspenseItemID = &itemID
+	if err := post(ctx, tx, line.Date, description, nil, s.Accounts.BankAccount, s.Accounts.SuspenseAccount, line.Amount); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSuspenseItem returns a suspense item.
+func (s *DBBankStore) GetSuspenseItem(ctx context.Context, id int) (*models.SuspenseItem, error) {
+	item, err := scanSuspenseItem(s.DB.QueryRowContext(ctx, `SELECT `+suspenseColumns+` WHERE s.id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("suspense item %d not found", id)
+	}
+	return item, err
+}
+
+// ListSuspenseItems returns the suspense items with the status, or all of them if it is
+// empty, oldest bank line first.
+func (s *DBBankStore) ListSuspenseItems(ctx context.Context, status string) ([]models.SuspenseItem, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+suspenseColumns+` WHERE ($1 = '' OR s.status = $1)
+		ORDER BY l.line_date, s.id`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.SuspenseItem{}
+	for rows.Next() {
+		item, err := scanSuspenseItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// ResolveSuspenseItem clears an open item from the suspense account in one transaction: to
+// the receivable of the invoice it pays, with a payment, or to another account. The
+// resolution is recorded in the audit log.
+//
+// Returns:
+//   - *models.SuspenseItem: The resolved item.
+//   - error: A not found error, a conflict if the item is resolved already, a validation
+//     error if the invoice cannot take the payment, or the query error.
+func (s *DBBankStore) ResolveSuspenseItem(ctx context.Context, id int, res models.SuspenseResolution, actor string, now time.Time) (*models.SuspenseItem, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	item, err := scanSuspenseItem(tx.QueryRowContext(ctx, `SELECT `+suspenseColumns+` WHERE s.id = $1 FOR UPDATE OF s`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("suspense item %d not found", id)
+	} else if err != nil {
+		return nil, err
+	}
+	if item.Status != models.SuspenseOpen {
+		return nil, models.Conflict("suspense item %d is %s", id, item.Status)
+	}
+
+	account := res.Account
+	var paymentID *int
+	if res.InvoiceID != nil {
+		if err := payableInvoice(ctx, tx, *res.InvoiceID, item.Amount); err != nil {
+			return nil, err
+		}
+		payment, err := insertPayment(ctx, tx, *res.InvoiceID, item.Amount, now)
+		if err != nil {
+			return nil, err
+		}
+		account, paymentID = receivableAccount, &payment
+	}
+	description := fmt.Sprintf("Suspense item %d reclassified: %s", id, label(item.Description, item.Reference))
+	if err := post(ctx, tx, now, description, res.InvoiceID, item.Account, account, item.Amount); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE suspense_items SET status = $1, resolved_account = $2, invoice_id = $3, payment_id = $4, note = $5,
+		     resolved_by = $6, resolved_at = $7
+		 WHERE id = $8`,
+		models.SuspenseResolved, account, res.InvoiceID, paymentID, res.Note, actor, now, id)
+	if err != nil {
+		return nil, err
+	}
+	details, _ := json.Marshal(map[string]interface{}{
+		"amount": item.Amount, "from_account": item.Account, "to_account": account, "invoice_id": res.InvoiceID,
+	})
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		Action:     audit.ActionSuspenseResolve,
+		EntityType: "suspense_item",
+		EntityID:   id,
+		Reason:     res.Note,
+		Details:    details,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	item.Status, item.ResolvedAccount, item.InvoiceID, item.PaymentID = models.SuspenseResolved, account, res.InvoiceID, paymentID
+	item.Note, item.ResolvedBy, item.ResolvedAt = res.Note, actor, &now
+	return item, nil
+}
+
+// payableInvoice locks an invoice and checks that it is posted with at least amount
+// outstanding.
+func payableInvoice(ctx context.Context, tx *sql.Tx, invoiceID int, amount float64) error {
+	var total, paid float64
+	var status sql.NullString
+	err := tx.QueryRowContext(ctx, `SELECT amount, status FROM invoices WHERE id = $1 FOR UPDATE`, invoiceID).Scan(&total, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotFound("invoice %d not found", invoiceID)
+	} else if err != nil {
+		return err
+	}
+	if status.String != models.InvoiceStatusPosted {
+		return models.Invalid("invoice %d is not posted", invoiceID)
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = $1 AND deleted_at IS NULL`, invoiceID).Scan(&paid); err != nil {
+		return err
+	}
+	// Compare in cents to avoid floating point noise
+	if outstanding := total - paid; math.Round(amount*100) > math.Round(outstanding*100) {
+		return models.Invalid("a payment of %.2f exceeds the %.2f outstanding on invoice %d", amount, outstanding, invoiceID)
+	}
+	return nil
+}
+
+// insertPayment records a bank transfer paying an invoice and its PaymentPosted event.
+func insertPayment(ctx context.Context, tx *sql.Tx, invoiceID int, amount float64, date time.Time) (int, error) {
+	payment := &models.Payment{InvoiceID: invoiceID, Amount: amount, PaymentDate: date, PaymentMethod: models.PaymentMethodBankTransfer}
+	err := tx.QueryRowContext(ctx,
+		"INSERT INTO payments (invoice_id, amount, payment_date, payment_method) VALUES ($1, $2, $3, $4) RETURNING id",
+		payment.InvoiceID, payment.Amount, payment.PaymentDate, payment.PaymentMethod,
+	).Scan(&payment.ID)
+	if err != nil {
+		return 0, err
+	}
+	return payment.ID, events.Enqueue(ctx, tx, events.PaymentPosted, "payment", payment.ID, payment)
+}
+
+// post records a balanced pair of ledger lines debiting one account and crediting another
+// with amount; a negative amount reverses them.
+func post(ctx context.Context, tx *sql.Tx, date time.Time, description string, invoiceID *int, debit, credit string, amount float64) error {
+	lines := []models.FinancialTransaction{
+		{AccountType: debit, Amount: amount},
+		{AccountType: credit, Amount: -amount},
+	}
+	for i := range lines {
+		lines[i].TransactionDate = date
+		lines[i].Description = description
+		lines[i].InvoiceID = invoiceID
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &lines[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// label names a bank line in the ledger by its description, or its reference if it has none.
+func label(description, reference string) string {
+	if description == "" {
+		return reference
+	}
+	return description
+}
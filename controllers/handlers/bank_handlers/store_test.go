@@ -0,0 +1,109 @@
+package bank_handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var accounts = config.BankConfig{BankAccount: "cash", SuspenseAccount: "suspense"}
+
+// expectPosting expects a ledger line of account with amount.
+func expectPosting(mock sqlmock.Sqlmock, account string, amount float64) {
+	mock.ExpectQuery("INSERT INTO financial_transactions").
+		WithArgs(account, amount, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO account_period_balances").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestImportBankLineWithoutMatchGoesToSuspense(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBBankStore{DB: db, Accounts: accounts}
+	at := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	invoice := 42
+	line := &models.BankStatementLine{Date: at, Amount: 150, Description: "ACME LTD", Reference: "INV-42",
+		ImportedBy: "clerk@example.com", ImportedAt: at}
+
+	mock.ExpectBegin()
+	// Invoice 42 has only 100 outstanding
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT amount, status FROM invoices WHERE id = $1 FOR UPDATE")).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"amount", "status"}).AddRow(300, models.InvoiceStatusPosted))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = $1 AND deleted_at IS NULL")).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(200))
+	mock.ExpectQuery("INSERT INTO bank_statement_lines").
+		WithArgs("", at, 150.0, "ACME LTD", "INV-42", models.BankLineSuspense, "clerk@example.com", at).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("INSERT INTO suspense_items").WithArgs(7, 150.0, "suspense", models.SuspenseOpen, at).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	expectPosting(mock, "cash", 150)
+	expectPosting(mock, "suspense", -150)
+	mock.ExpectCommit()
+
+	require.NoError(t, store.ImportBankLine(context.Background(), line, &invoice))
+	assert.Equal(t, models.BankLineSuspense, line.Status)
+	assert.Equal(t, 3, *line.SuspenseItemID)
+	assert.Nil(t, line.InvoiceID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveSuspenseItemToAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBBankStore{DB: db, Accounts: accounts}
+	at := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	now := at.AddDate(0, 0, 10)
+
+	// A bank fee of 25.50 is moved from suspense to the expense account
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE s.id = $1 FOR UPDATE OF s")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "line_id", "line_date", "amount", "description", "reference", "account",
+			"status", "resolved_account", "invoice_id", "payment_id", "note", "resolved_by", "resolved_at", "created_at"}).
+			AddRow(3, 7, at, -25.5, "Bank fee", "", "suspense", models.SuspenseOpen, nil, nil, nil, "", nil, nil, at))
+	expectPosting(mock, "suspense", -25.5)
+	expectPosting(mock, "expense", 25.5)
+	mock.ExpectExec("UPDATE suspense_items SET status").
+		WithArgs(models.SuspenseResolved, "expense", nil, nil, "Monthly fee", "a@example.com", now, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("a@example.com", "suspense_resolve", "suspense_item", 3, "Monthly fee", sqlmock.AnyArg(), now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	item, err := store.ResolveSuspenseItem(context.Background(), 3, models.SuspenseResolution{Account: "expense", Note: "Monthly fee"}, "a@example.com", now)
+	require.NoError(t, err)
+	assert.Equal(t, models.SuspenseResolved, item.Status)
+	assert.Equal(t, "expense", item.ResolvedAccount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveSuspenseItemRefusesResolvedItems(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBBankStore{DB: db, Accounts: accounts}
+	at := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE s.id = $1 FOR UPDATE OF s")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "line_id", "line_date", "amount", "description", "reference", "account",
+			"status", "resolved_account", "invoice_id", "payment_id", "note", "resolved_by", "resolved_at", "created_at"}).
+			AddRow(3, 7, at, 10, "Unknown", "", "suspense", models.SuspenseResolved, "revenue", nil, nil, "x", "b@example.com", at, at))
+	mock.ExpectRollback()
+
+	_, err = store.ResolveSuspenseItem(context.Background(), 3, models.SuspenseResolution{Account: "revenue", Note: "again"}, "a@example.com", at)
+	assert.True(t, errors.Is(err, models.ErrConflict), "%v", err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,330 @@
+// Package benefit_handlers provides HTTP handlers and the database store for benefit plans,
+// enrollment windows and employees' benefit elections.
+package benefit_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/benefits"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/rbac"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// PermissionChecker reports whether a role grants one of the required permissions,
+// typically the rbac service.
+type PermissionChecker interface {
+	Allowed(ctx context.Context, role string, required ...string) (bool, error)
+}
+
+// BenefitHandler provides HTTP handlers for benefits.
+type BenefitHandler struct {
+	Service *benefits.Service
+	Access  PermissionChecker // Decides who may elect benefits for other employees
+}
+
+// PlanRequest is the request body for creating or updating a benefit plan. The ID is
+// assigned by the database or taken from the URL.
+type PlanRequest struct {
+	Code   string               `json:"code"`
+	Name   string               `json:"name"`
+	Kind   string               `json:"kind"` // "health_insurance" or "allowance"
+	Tiers  []models.BenefitTier `json:"tiers"`
+	Active *bool                `json:"active"` // Defaults to true
+}
+
+// Plan returns the benefit plan described by the request.
+func (req PlanRequest) Plan() models.BenefitPlan {
+	active := req.Active == nil || *req.Active
+	return models.BenefitPlan{Code: req.Code, Name: req.Name, Kind: req.Kind, Tiers: req.Tiers, Active: active}
+}
+
+// WindowRequest is the request body for opening an enrollment window.
+type WindowRequest struct {
+	Name          string `json:"name"`
+	OpensOn       string `json:"opens_on"`       // YYYY-MM-DD
+	ClosesOn      string `json:"closes_on"`      // YYYY-MM-DD
+	CoverageStart string `json:"coverage_start"` // YYYY-MM-DD; moved to the first of its month
+}
+
+// Window returns the enrollment window described by the request.
+func (req WindowRequest) Window() (models.EnrollmentWindow, error) {
+	window := models.EnrollmentWindow{Name: req.Name}
+	for _, date := range []struct {
+		field string
+		value string
+		dest  *time.Time
+	}{
+		{"opens_on", req.OpensOn, &window.OpensOn},
+		{"closes_on", req.ClosesOn, &window.ClosesOn},
+		{"coverage_start", req.CoverageStart, &window.CoverageStart},
+	} {
+		t, err := time.Parse("2006-01-02", date.value)
+		if err != nil {
+			return window, models.Invalid("%s must be a date formatted as YYYY-MM-DD", date.field)
+		}
+		*date.dest = t
+	}
+	return window, nil
+}
+
+// ElectionRequest is the request body for electing a benefit.
+type ElectionRequest struct {
+	PlanID int    `json:"plan_id"`
+	Tier   string `json:"tier"`              // Empty to waive the plan
+	UserID int    `json:"user_id,omitempty"` // HR only; the signed-in employee by default
+}
+
+// HRPermissions are the permissions that allow managing plans and windows and electing
+// benefits for other employees.
+var HRPermissions = []string{rbac.HR}
+
+// ListPlans returns every benefit plan.
+//
+// HTTP Method: GET
+// URL Path: /benefits/plans
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of BenefitPlans in JSON.
+//   - Status Code: 500 (Internal Server Error) if the plans cannot be read.
+func (h *BenefitHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.Service.Plans(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load benefit plans")
+		return
+	}
+	respond.JSON(w, http.StatusOK, plans)
+}
+
+// CreatePlan adds a benefit plan.
+//
+// HTTP Method: POST
+// URL Path: /benefits/plans
+//
+// Request Body:
+//   - JSON object with "code", "name", "kind" ("health_insurance" or "allowance"), "tiers"
+//     of "name", "employee_cost" and "employer_cost" per month, and optionally "active".
+//
+// Response:
+//   - Status Code: 201 (Created) with the BenefitPlan in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the code is taken.
+//   - Status Code: 422 (Unprocessable Entity) if the plan is incomplete.
+//   - Status Code: 500 (Internal Server Error) if the plan cannot be saved.
+func (h *BenefitHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	plan := req.Plan()
+	if err := h.Service.CreatePlan(r.Context(), &plan, actor); err != nil {
+		httperr.Write(w, err, "Failed to create benefit plan")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, plan)
+}
+
+// UpdatePlan replaces a benefit plan. Elections already made keep the costs of their tier.
+//
+// HTTP Method: PUT
+// URL Path: /benefits/plans/{id}
+//
+// Request Body:
+//   - JSON object with the fields of a PlanRequest.
+//
+// Response:
+//   - Status Code: 200 (OK) with the BenefitPlan in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the plan does not exist.
+//   - Status Code: 409 (Conflict) if the code is taken.
+//   - Status Code: 422 (Unprocessable Entity) if the plan is incomplete.
+//   - Status Code: 500 (Internal Server Error) if the plan cannot be saved.
+func (h *BenefitHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	plan := req.Plan()
+	plan.ID = id
+	if err := h.Service.UpdatePlan(r.Context(), &plan, actor); err != nil {
+		httperr.Write(w, err, "Failed to update benefit plan")
+		return
+	}
+	respond.JSON(w, http.StatusOK, plan)
+}
+
+// ListWindows returns every enrollment window, the latest to open first.
+//
+// HTTP Method: GET
+// URL Path: /benefits/windows
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of EnrollmentWindows in JSON.
+//   - Status Code: 500 (Internal Server Error) if the windows cannot be read.
+func (h *BenefitHandler) ListWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := h.Service.Windows(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load enrollment windows")
+		return
+	}
+	respond.JSON(w, http.StatusOK, windows)
+}
+
+// CreateWindow opens an enrollment window.
+//
+// HTTP Method: POST
+// URL Path: /benefits/windows
+//
+// Request Body:
+//   - JSON object with "name", "opens_on", "closes_on" and "coverage_start" (YYYY-MM-DD).
+//
+// Response:
+//   - Status Code: 201 (Created) with the EnrollmentWindow in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if a date is invalid or out of order.
+//   - Status Code: 500 (Internal Server Error) if the window cannot be saved.
+func (h *BenefitHandler) CreateWindow(w http.ResponseWriter, r *http.Request) {
+	var req WindowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	window, err := req.Window()
+	if err != nil {
+		httperr.Write(w, err, "Invalid enrollment window")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.CreateWindow(r.Context(), &window, actor); err != nil {
+		httperr.Write(w, err, "Failed to create enrollment window")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, window)
+}
+
+// Elect records the signed-in employee's election in an open enrollment window, or another
+// employee's when HR passes their user_id. Electing again in the same window replaces the
+// election.
+//
+// HTTP Method: POST
+// URL Path: /benefits/windows/{id}/elections
+//
+// Request Body:
+//   - JSON object with "plan_id", "tier" (empty to waive the plan) and, for HR, "user_id".
+//
+// Response:
+//   - Status Code: 201 (Created) with the BenefitElection in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 403 (Forbidden) if the election is for another employee and the user is
+//     not HR.
+//   - Status Code: 404 (Not Found) if the window, plan or employee does not exist.
+//   - Status Code: 409 (Conflict) if the window is not open.
+//   - Status Code: 422 (Unprocessable Entity) if the plan is inactive or has no such tier.
+//   - Status Code: 500 (Internal Server Error) if the election cannot be saved.
+func (h *BenefitHandler) Elect(w http.ResponseWriter, r *http.Request) {
+	windowID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req ElectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	election := models.BenefitElection{Email: email, PlanID: req.PlanID, Tier: req.Tier}
+	if req.UserID != 0 {
+		role, _ := middleware.GetUserRoleFromContext(r.Context())
+		allowed := false
+		if role != "" {
+			var err error
+			if allowed, err = h.Access.Allowed(r.Context(), role, HRPermissions...); err != nil {
+				httperr.Write(w, err, "Failed to check permissions")
+				return
+			}
+		}
+		if !allowed {
+			respond.Error(w, http.StatusForbidden, "Only HR may elect benefits for other employees")
+			return
+		}
+		election = models.BenefitElection{UserID: req.UserID, PlanID: req.PlanID, Tier: req.Tier}
+	}
+	if err := h.Service.Elect(r.Context(), windowID, &election, email); err != nil {
+		httperr.Write(w, err, "Failed to record benefit election")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, election)
+}
+
+// ListMyElections lists the signed-in employee's elections, the latest to take effect first.
+//
+// HTTP Method: GET
+// URL Path: /benefits/elections/mine
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of BenefitElections in JSON.
+//   - Status Code: 500 (Internal Server Error) if the elections cannot be read.
+func (h *BenefitHandler) ListMyElections(w http.ResponseWriter, r *http.Request) {
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	elections, err := h.Service.Mine(r.Context(), email)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load benefit elections")
+		return
+	}
+	respond.JSON(w, http.StatusOK, elections)
+}
+
+// ListElections lists every employee's elections, or one employee's.
+//
+// HTTP Method: GET
+// URL Path: /benefits/elections?user_id=1 (user_id is optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of BenefitElections in JSON.
+//   - Status Code: 400 (Bad Request) if user_id is not a number.
+//   - Status Code: 500 (Internal Server Error) if the elections cannot be read.
+func (h *BenefitHandler) ListElections(w http.ResponseWriter, r *http.Request) {
+	var userID int
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		var err error
+		if userID, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+	}
+	elections, err := h.Service.Elections(r.Context(), userID, "")
+	if err != nil {
+		httperr.Write(w, err, "Failed to load benefit elections")
+		return
+	}
+	respond.JSON(w, http.StatusOK, elections)
+}
+
+// CostReport totals the monthly cost of the elections in force in a period by department:
+// what employees pay through payroll and what the employer pays.
+//
+// HTTP Method: GET
+// URL Path: /benefits/reports/cost?period=2024-05
+//
+// Response:
+//   - Status Code: 200 (OK) with the BenefitCostReport in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid.
+//   - Status Code: 500 (Internal Server Error) if the elections cannot be read.
+func (h *BenefitHandler) CostReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.CostReport(r.Context(), r.URL.Query().Get("period"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to build benefits cost report")
+		return
+	}
+	respond.JSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,295 @@
+package benefit_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"erp/models"
+)
+
+// DBBenefitStore provides SQL-backed methods for benefit plans, enrollment windows and
+// elections.
+type DBBenefitStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// planColumns are the columns scanned by scanPlan.
+const planColumns = `id, code, name, kind, tiers, active, updated_by, updated_at`
+
+// scanPlan reads a row selected with planColumns.
+func scanPlan(row interface{ Scan(...interface{}) error }) (*models.BenefitPlan, error) {
+	var plan models.BenefitPlan
+	var tiers []byte
+	if err := row.Scan(&plan.ID, &plan.Code, &plan.Name, &plan.Kind, &tiers, &plan.Active, &plan.UpdatedBy,
+		&plan.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(tiers, &plan.Tiers); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// duplicateCode converts a unique violation on the plan code to a conflict.
+func duplicateCode(err error, plan *models.BenefitPlan) error {
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("a benefit plan with code %q already exists", plan.Code)
+	}
+	return err
+}
+
+// CreateBenefitPlan saves a new benefit plan.
+//
+// Parameters:
+//   - plan: The validated plan; its ID is set.
+//
+// Returns:
+//   - error: A models.Conflict error if the code is taken, or an error if the insert fails.
+func (store *DBBenefitStore) CreateBenefitPlan(ctx context.Context, plan *models.BenefitPlan) error {
+	tiers, err := json.Marshal(plan.Tiers)
+	if err != nil {
+		return err
+	}
+	err = store.DB.QueryRowContext(ctx,
+		`INSERT INTO benefit_plans (code, name, kind, tiers, active, updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		plan.Code, plan.Name, plan.Kind, tiers, plan.Active, plan.UpdatedBy, plan.UpdatedAt,
+	).Scan(&plan.ID)
+	return duplicateCode(err, plan)
+}
+
+// GetBenefitPlan retrieves a benefit plan by its ID.
+//
+// Returns:
+//   - *models.BenefitPlan: The plan.
+//   - error: models.ErrNotFound if it does not exist, or an error if the query fails.
+func (store *DBBenefitStore) GetBenefitPlan(ctx context.Context, id int) (*models.BenefitPlan, error) {
+	plan, err := scanPlan(store.DB.QueryRowContext(ctx, `SELECT `+planColumns+` FROM benefit_plans WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("benefit plan %d not found", id)
+	}
+	return plan, err
+}
+
+// GetBenefitPlans retrieves every benefit plan, ordered by code.
+//
+// Returns:
+//   - []models.BenefitPlan: The plans.
+//   - error: An error if the query fails.
+func (store *DBBenefitStore) GetBenefitPlans(ctx context.Context) ([]models.BenefitPlan, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT `+planColumns+` FROM benefit_plans ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []models.BenefitPlan{}
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *plan)
+	}
+	return plans, rows.Err()
+}
+
+// UpdateBenefitPlan replaces a benefit plan. Elections already made keep their costs.
+//
+// Parameters:
+//   - plan: The validated plan with its ID.
+//
+// Returns:
+//   - error: models.ErrNotFound if the plan does not exist, a models.Conflict error if the
+//     code is taken, or an error if the update fails.
+func (store *DBBenefitStore) UpdateBenefitPlan(ctx context.Context, plan *models.BenefitPlan) error {
+	tiers, err := json.Marshal(plan.Tiers)
+	if err != nil {
+		return err
+	}
+	result, err := store.DB.ExecContext(ctx,
+		`UPDATE benefit_plans SET code = $1, name = $2, kind = $3, tiers = $4, active = $5, updated_by = $6,
+		     updated_at = $7
+		 WHERE id = $8`,
+		plan.Code, plan.Name, plan.Kind, tiers, plan.Active, plan.UpdatedBy, plan.UpdatedAt, plan.ID,
+	)
+	if err != nil {
+		return duplicateCode(err, plan)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("benefit plan %d not found", plan.ID)
+	}
+	return nil
+}
+
+// windowColumns are the columns scanned by scanWindow.
+const windowColumns = `id, name, opens_on, closes_on, coverage_start, created_by, created_at`
+
+// scanWindow reads a row selected with windowColumns.
+func scanWindow(row interface{ Scan(...interface{}) error }) (*models.EnrollmentWindow, error) {
+	var window models.EnrollmentWindow
+	if err := row.Scan(&window.ID, &window.Name, &window.OpensOn, &window.ClosesOn, &window.CoverageStart,
+		&window.CreatedBy, &window.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
+
+// CreateEnrollmentWindow saves a new enrollment window.
+//
+// Parameters:
+//   - window: The validated window; its ID is set.
+//
+// Returns:
+//   - error: An error if the insert fails.
+func (store *DBBenefitStore) CreateEnrollmentWindow(ctx context.Context, window *models.EnrollmentWindow) error {
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO enrollment_windows (name, opens_on, closes_on, coverage_start, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		window.Name, window.OpensOn, window.ClosesOn, window.CoverageStart, window.CreatedBy, window.CreatedAt,
+	).Scan(&window.ID)
+}
+
+// GetEnrollmentWindow retrieves an enrollment window by its ID.
+//
+// Returns:
+//   - *models.EnrollmentWindow: The window.
+//   - error: models.ErrNotFound if it does not exist, or an error if the query fails.
+func (store *DBBenefitStore) GetEnrollmentWindow(ctx context.Context, id int) (*models.EnrollmentWindow, error) {
+	window, err := scanWindow(store.DB.QueryRowContext(ctx, `SELECT `+windowColumns+` FROM enrollment_windows WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("enrollment window %d not found", id)
+	}
+	return window, err
+}
+
+// GetEnrollmentWindows retrieves every enrollment window, the latest to open first.
+//
+// Returns:
+//   - []models.EnrollmentWindow: The windows.
+//   - error: An error if the query fails.
+func (store *DBBenefitStore) GetEnrollmentWindows(ctx context.Context) ([]models.EnrollmentWindow, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT `+windowColumns+` FROM enrollment_windows ORDER BY opens_on DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []models.EnrollmentWindow{}
+	for rows.Next() {
+		window, err := scanWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, *window)
+	}
+	return windows, rows.Err()
+}
+
+// SaveBenefitElection records an election, replacing the employee's election in the same
+// plan and window. When the election has no user ID the user is looked up by email.
+//
+// Parameters:
+//   - election: The election; its ID, user ID and the employee's details are set.
+//
+// Returns:
+//   - error: models.ErrNotFound if the user does not exist, or an error if the election
+//     cannot be saved.
+func (store *DBBenefitStore) SaveBenefitElection(ctx context.Context, election *models.BenefitElection) error {
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT id, name, email, COALESCE(department, '') FROM users
+		 WHERE ($1 <> 0 AND id = $1) OR ($1 = 0 AND LOWER(email) = LOWER($2))`,
+		election.UserID, election.Email,
+	).Scan(&election.UserID, &election.Employee, &election.Email, &election.Department)
+	if err == sql.ErrNoRows {
+		if election.UserID != 0 {
+			return models.NotFound("user %d not found", election.UserID)
+		}
+		return models.NotFound("user %q not found", election.Email)
+	}
+	if err != nil {
+		return err
+	}
+
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO benefit_elections (user_id, window_id, plan_id, tier, employee_cost, employer_cost, effective_from,
+		     elected_by, elected_at)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9)
+		 ON CONFLICT (user_id, plan_id, window_id) DO UPDATE SET tier = EXCLUDED.tier,
+		     employee_cost = EXCLUDED.employee_cost, employer_cost = EXCLUDED.employer_cost,
+		     effective_from = EXCLUDED.effective_from, elected_by = EXCLUDED.elected_by, elected_at = EXCLUDED.elected_at
+		 RETURNING id`,
+		election.UserID, election.WindowID, election.PlanID, election.Tier, election.EmployeeCost, election.EmployerCost,
+		election.EffectiveFrom, election.ElectedBy, election.ElectedAt,
+	).Scan(&election.ID)
+}
+
+// electionColumns are the columns scanned by scanElection, from benefit_elections e joined
+// with users u and benefit_plans p.
+const electionColumns = `e.id, e.user_id, u.name, u.email, COALESCE(u.department, ''), e.window_id, e.plan_id, p.code,
+	p.name, COALESCE(e.tier, ''), e.employee_cost, e.employer_cost, e.effective_from, e.elected_by, e.elected_at`
+
+// electionJoins joins an election with its employee and plan.
+const electionJoins = `benefit_elections e JOIN users u ON u.id = e.user_id JOIN benefit_plans p ON p.id = e.plan_id`
+
+// scanElections reads the rows of a query selecting electionColumns.
+func scanElections(rows *sql.Rows) ([]models.BenefitElection, error) {
+	defer rows.Close()
+	elections := []models.BenefitElection{}
+	for rows.Next() {
+		var election models.BenefitElection
+		if err := rows.Scan(&election.ID, &election.UserID, &election.Employee, &election.Email, &election.Department,
+			&election.WindowID, &election.PlanID, &election.PlanCode, &election.PlanName, &election.Tier,
+			&election.EmployeeCost, &election.EmployerCost, &election.EffectiveFrom, &election.ElectedBy,
+			&election.ElectedAt); err != nil {
+			return nil, err
+		}
+		elections = append(elections, election)
+	}
+	return elections, rows.Err()
+}
+
+// GetBenefitElections lists elections, the latest to take effect first.
+//
+// Parameters:
+//   - userID: The employee, or 0 for every employee.
+//   - email: The employee's email, or "" for every employee.
+//
+// Returns:
+//   - []models.BenefitElection: The elections.
+//   - error: An error if the query fails.
+func (store *DBBenefitStore) GetBenefitElections(ctx context.Context, userID int, email string) ([]models.BenefitElection, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT `+electionColumns+` FROM `+electionJoins+`
+		 WHERE ($1 = 0 OR e.user_id = $1) AND ($2 = '' OR LOWER(u.email) = LOWER($2))
+		 ORDER BY e.effective_from DESC, u.name, p.code`, userID, email)
+	if err != nil {
+		return nil, err
+	}
+	return scanElections(rows)
+}
+
+// GetElectionsInForce lists, for each employee and plan, the election that took effect last
+// on or before a day. Waivers are included, as they end the cover elected before.
+//
+// Parameters:
+//   - userID: The employee, or 0 for every employee.
+//   - on: The day.
+//
+// Returns:
+//   - []models.BenefitElection: The elections, by employee and plan.
+//   - error: An error if the query fails.
+func (store *DBBenefitStore) GetElectionsInForce(ctx context.Context, userID int, on time.Time) ([]models.BenefitElection, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT DISTINCT ON (e.user_id, e.plan_id) `+electionColumns+` FROM `+electionJoins+`
+		 WHERE ($1 = 0 OR e.user_id = $1) AND e.effective_from <= $2
+		 ORDER BY e.user_id, e.plan_id, e.effective_from DESC, e.elected_at DESC`, userID, on)
+	if err != nil {
+		return nil, err
+	}
+	return scanElections(rows)
+}
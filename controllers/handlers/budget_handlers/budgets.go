@@ -0,0 +1,293 @@
+// Package budget_handlers provides HTTP handlers and the database store for budget
+// scenarios, their comparison against the ledger and the variances of the active plan.
+package budget_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"erp/controllers/budgeting"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// BudgetHandler provides HTTP handlers for budgeting.
+type BudgetHandler struct {
+	Service *budgeting.Service
+}
+
+// RegisterRoutes maps budgeting routes to their handler functions. The router is expected to
+// be protected with middleware.JWTAuth and limited to finance.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The budget handler.
+func RegisterRoutes(router *mux.Router, handler *BudgetHandler) {
+	router.HandleFunc("/scenarios", handler.ListScenarios).Methods("GET")
+	router.HandleFunc("/scenarios", handler.CreateScenario).Methods("POST")
+	router.HandleFunc("/scenarios/{id:[0-9]+}", handler.GetScenario).Methods("GET")
+	router.HandleFunc("/scenarios/{id:[0-9]+}", handler.DeleteScenario).Methods("DELETE")
+	router.HandleFunc("/scenarios/{id:[0-9]+}/lines", handler.SetLines).Methods("PUT")
+	router.HandleFunc("/scenarios/{id:[0-9]+}/clone", handler.CloneScenario).Methods("POST")
+	router.HandleFunc("/scenarios/{id:[0-9]+}/adjust", handler.AdjustScenario).Methods("POST")
+	router.HandleFunc("/scenarios/{id:[0-9]+}/activate", handler.ActivateScenario).Methods("POST")
+	router.HandleFunc("/comparison", handler.GetComparison).Methods("GET")
+	router.HandleFunc("/variance", handler.GetVariance).Methods("GET")
+}
+
+// ListScenarios lists the budget scenarios.
+//
+// HTTP Method: GET
+// URL Path: /budgets/scenarios?fiscal_year=2025 (optional; every year by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of BudgetScenarios, without their lines, in JSON.
+//   - Status Code: 500 (Internal Server Error) if the scenarios cannot be loaded.
+func (h *BudgetHandler) ListScenarios(w http.ResponseWriter, r *http.Request) {
+	year, _ := strconv.Atoi(r.URL.Query().Get("fiscal_year"))
+	list, err := h.Service.Scenarios(r.Context(), year)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load budget scenarios")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// CreateScenario records a budget scenario.
+//
+// HTTP Method: POST
+// URL Path: /budgets/scenarios
+//
+// Request Body:
+//   - JSON with name, fiscal_year, kind (base, optimistic or pessimistic; base by default) and
+//     lines, each with account, type (income or expense), period (YYYY-MM) and amount.
+//
+// Response:
+//   - Status Code: 201 (Created) with the scenario in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the year already has a scenario of the name.
+//   - Status Code: 422 (Unprocessable Entity) if a field or line is invalid.
+//   - Status Code: 500 (Internal Server Error) if the scenario cannot be recorded.
+func (h *BudgetHandler) CreateScenario(w http.ResponseWriter, r *http.Request) {
+	var scenario models.BudgetScenario
+	if err := json.NewDecoder(r.Body).Decode(&scenario); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Create(r.Context(), &scenario, actor); err != nil {
+		httperr.Write(w, err, "Failed to create budget scenario")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, scenario)
+}
+
+// GetScenario returns a budget scenario with its lines.
+//
+// HTTP Method: GET
+// URL Path: /budgets/scenarios/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the scenario in JSON.
+//   - Status Code: 404 (Not Found) if the scenario does not exist.
+//   - Status Code: 500 (Internal Server Error) if the scenario cannot be loaded.
+func (h *BudgetHandler) GetScenario(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	scenario, err := h.Service.Scenario(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load budget scenario")
+		return
+	}
+	respond.JSON(w, http.StatusOK, scenario)
+}
+
+// DeleteScenario removes a budget scenario.
+//
+// HTTP Method: DELETE
+// URL Path: /budgets/scenarios/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the scenario is removed.
+//   - Status Code: 404 (Not Found) if the scenario does not exist.
+//   - Status Code: 409 (Conflict) if the scenario is the active plan.
+//   - Status Code: 500 (Internal Server Error) if the scenario cannot be removed.
+func (h *BudgetHandler) DeleteScenario(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete budget scenario")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetLines replaces the lines of a budget scenario.
+//
+// HTTP Method: PUT
+// URL Path: /budgets/scenarios/{id}/lines
+//
+// Request Body:
+//   - JSON list of lines, each with account, type, period and amount.
+//
+// Response:
+//   - Status Code: 200 (OK) with the scenario in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the scenario does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if a line is invalid.
+//   - Status Code: 500 (Internal Server Error) if the lines cannot be saved.
+func (h *BudgetHandler) SetLines(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var lines []models.BudgetLine
+	if err := json.NewDecoder(r.Body).Decode(&lines); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	scenario, err := h.Service.SetLines(r.Context(), id, lines)
+	if err != nil {
+		httperr.Write(w, err, "Failed to save budget lines")
+		return
+	}
+	respond.JSON(w, http.StatusOK, scenario)
+}
+
+// CloneScenario records a copy of a budget scenario adjusted by percentage rules.
+//
+// HTTP Method: POST
+// URL Path: /budgets/scenarios/{id}/clone
+//
+// Request Body:
+//   - JSON with name, optionally kind, and rules, each with percent and optionally account,
+//     type, from and to (YYYY-MM) restricting the lines it changes.
+//
+// Response:
+//   - Status Code: 201 (Created) with the new scenario in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the scenario does not exist.
+//   - Status Code: 409 (Conflict) if the year already has a scenario of the name.
+//   - Status Code: 422 (Unprocessable Entity) if the name, kind or a rule is invalid.
+//   - Status Code: 500 (Internal Server Error) if the scenario cannot be cloned.
+func (h *BudgetHandler) CloneScenario(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var clone models.BudgetClone
+	if err := json.NewDecoder(r.Body).Decode(&clone); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	scenario, err := h.Service.Clone(r.Context(), id, clone, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to clone budget scenario")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, scenario)
+}
+
+// AdjustScenario changes the lines of a budget scenario by percentage rules.
+//
+// HTTP Method: POST
+// URL Path: /budgets/scenarios/{id}/adjust
+//
+// Request Body:
+//   - JSON with rules, as for cloning.
+//
+// Response:
+//   - Status Code: 200 (OK) with the scenario in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the scenario does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if there is no rule or a rule is invalid.
+//   - Status Code: 500 (Internal Server Error) if the scenario cannot be adjusted.
+func (h *BudgetHandler) AdjustScenario(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req struct {
+		Rules []models.BudgetRule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	scenario, err := h.Service.Adjust(r.Context(), id, req.Rules)
+	if err != nil {
+		httperr.Write(w, err, "Failed to adjust budget scenario")
+		return
+	}
+	respond.JSON(w, http.StatusOK, scenario)
+}
+
+// ActivateScenario makes a budget scenario the active plan of its fiscal year.
+//
+// HTTP Method: POST
+// URL Path: /budgets/scenarios/{id}/activate
+//
+// Response:
+//   - Status Code: 200 (OK) with the scenario in JSON.
+//   - Status Code: 404 (Not Found) if the scenario does not exist.
+//   - Status Code: 500 (Internal Server Error) if the scenario cannot be activated.
+func (h *BudgetHandler) ActivateScenario(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	scenario, err := h.Service.Activate(r.Context(), id, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to activate budget scenario")
+		return
+	}
+	respond.JSON(w, http.StatusOK, scenario)
+}
+
+// GetComparison sets budget scenarios side by side against the actuals of their year.
+//
+// HTTP Method: GET
+// URL Path: /budgets/comparison?fiscal_year=2025&scenarios=1,2 (scenarios optional; every
+// scenario of the year by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with the BudgetComparison in JSON.
+//   - Status Code: 400 (Bad Request) if scenarios is not a list of IDs.
+//   - Status Code: 404 (Not Found) if a scenario does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the year is missing or a scenario budgets
+//     another year.
+//   - Status Code: 500 (Internal Server Error) if the comparison cannot be worked out.
+func (h *BudgetHandler) GetComparison(w http.ResponseWriter, r *http.Request) {
+	year, _ := strconv.Atoi(r.URL.Query().Get("fiscal_year"))
+	var ids []int
+	for _, part := range strings.Split(r.URL.Query().Get("scenarios"), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "scenarios must be a comma-separated list of IDs")
+			return
+		}
+		ids = append(ids, id)
+	}
+	comparison, err := h.Service.Compare(r.Context(), year, ids)
+	if err != nil {
+		httperr.Write(w, err, "Failed to compare budget scenarios")
+		return
+	}
+	respond.JSON(w, http.StatusOK, comparison)
+}
+
+// GetVariance reports the actuals of a month against the active plan of its year.
+//
+// HTTP Method: GET
+// URL Path: /budgets/variance?period=2025-06 (optional; the current month by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with the BudgetVariance in JSON.
+//   - Status Code: 404 (Not Found) if the year has no active plan.
+//   - Status Code: 422 (Unprocessable Entity) if the period is malformed.
+//   - Status Code: 500 (Internal Server Error) if the variances cannot be worked out.
+func (h *BudgetHandler) GetVariance(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.Variance(r.Context(), r.URL.Query().Get("period"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to report budget variance")
+		return
+	}
+	respond.JSON(w, http.StatusOK, report)
+}
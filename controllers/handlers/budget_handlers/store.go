@@ -0,0 +1,225 @@
+package budget_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"erp/models"
+)
+
+// DBBudgetStore implements models.BudgetStore using a SQL database.
+type DBBudgetStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// scenarioColumns are the columns scanned by scanScenario, with the totals of the lines.
+const scenarioColumns = `s.id, s.name, s.fiscal_year, s.kind, s.based_on_id, s.active,
+	(SELECT COALESCE(SUM(amount), 0) FROM budget_lines WHERE scenario_id = s.id AND type = 'income'),
+	(SELECT COALESCE(SUM(amount), 0) FROM budget_lines WHERE scenario_id = s.id AND type = 'expense'),
+	s.created_by, s.created_at, COALESCE(s.activated_by, ''), s.activated_at`
+
+// scanScenario reads a row selected with scenarioColumns.
+func scanScenario(row interface{ Scan(...interface{}) error }) (*models.BudgetScenario, error) {
+	var s models.BudgetScenario
+	if err := row.Scan(&s.ID, &s.Name, &s.FiscalYear, &s.Kind, &s.BasedOnID, &s.Active, &s.Income, &s.Expense,
+		&s.CreatedBy, &s.CreatedAt, &s.ActivatedBy, &s.ActivatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// insertLines adds lines to a scenario.
+func insertLines(ctx context.Context, tx *sql.Tx, id int, lines []models.BudgetLine) error {
+	for _, line := range lines {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO budget_lines (scenario_id, account, type, period, amount) VALUES ($1, $2, $3, $4, $5)`,
+			id, line.Account, line.Type, line.Period, line.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateBudgetScenario records a scenario with its lines.
+//
+// Returns:
+//   - error: A conflict if the year already has a scenario of the name, or the query error.
+func (s *DBBudgetStore) CreateBudgetScenario(ctx context.Context, scenario *models.BudgetScenario) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO budget_scenarios (name, fiscal_year, kind, based_on_id, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		scenario.Name, scenario.FiscalYear, scenario.Kind, scenario.BasedOnID, scenario.CreatedBy, scenario.CreatedAt,
+	).Scan(&scenario.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("%d already has a budget named %q", scenario.FiscalYear, scenario.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if err := insertLines(ctx, tx, scenario.ID, scenario.Lines); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetBudgetScenario returns a scenario with its lines, ordered by account and month.
+func (s *DBBudgetStore) GetBudgetScenario(ctx context.Context, id int) (*models.BudgetScenario, error) {
+	scenario, err := scanScenario(s.DB.QueryRowContext(ctx, `SELECT `+scenarioColumns+` FROM budget_scenarios s WHERE s.id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("budget scenario %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT account, type, period, amount FROM budget_lines WHERE scenario_id = $1 ORDER BY type DESC, account, period`,
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scenario.Lines = []models.BudgetLine{}
+	for rows.Next() {
+		var line models.BudgetLine
+		if err := rows.Scan(&line.Account, &line.Type, &line.Period, &line.Amount); err != nil {
+			return nil, err
+		}
+		scenario.Lines = append(scenario.Lines, line)
+	}
+	return scenario, rows.Err()
+}
+
+// ListBudgetScenarios returns the scenarios of a year, or of every year if it is 0, latest
+// year first.
+func (s *DBBudgetStore) ListBudgetScenarios(ctx context.Context, fiscalYear int) ([]models.BudgetScenario, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+scenarioColumns+` FROM budget_scenarios s
+		 WHERE $1 = 0 OR s.fiscal_year = $1
+		 ORDER BY s.fiscal_year DESC, s.id`,
+		fiscalYear)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.BudgetScenario{}
+	for rows.Next() {
+		scenario, err := scanScenario(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *scenario)
+	}
+	return list, rows.Err()
+}
+
+// ReplaceBudgetLines replaces the lines of a scenario.
+func (s *DBBudgetStore) ReplaceBudgetLines(ctx context.Context, id int, lines []models.BudgetLine) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM budget_scenarios WHERE id = $1 FOR UPDATE`, id).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotFound("budget scenario %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM budget_lines WHERE scenario_id = $1`, id); err != nil {
+		return err
+	}
+	if err := insertLines(ctx, tx, id, lines); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ActivateBudgetScenario makes a scenario the active plan of its year, and the year's other
+// scenarios inactive.
+func (s *DBBudgetStore) ActivateBudgetScenario(ctx context.Context, id int, actor string, now time.Time) (*models.BudgetScenario, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var year int
+	err = tx.QueryRowContext(ctx, `SELECT fiscal_year FROM budget_scenarios WHERE id = $1 FOR UPDATE`, id).Scan(&year)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("budget scenario %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE budget_scenarios SET active = FALSE WHERE fiscal_year = $1 AND active AND id <> $2`, year, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE budget_scenarios SET active = TRUE, activated_by = $2, activated_at = $3 WHERE id = $1`,
+		id, actor, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetBudgetScenario(ctx, id)
+}
+
+// DeleteBudgetScenario removes a scenario and its lines.
+//
+// Returns:
+//   - error: A not found error if the scenario does not exist, a conflict if it is the active
+//     plan, or the query error.
+func (s *DBBudgetStore) DeleteBudgetScenario(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM budget_scenarios WHERE id = $1 AND NOT active`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	var active bool
+	err = s.DB.QueryRowContext(ctx, `SELECT active FROM budget_scenarios WHERE id = $1`, id).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotFound("budget scenario %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	return models.Conflict("budget scenario %d is the active plan", id)
+}
+
+// AccountMovements returns the debits and credits of each ledger account by month from the
+// trial balance summary.
+func (s *DBBudgetStore) AccountMovements(ctx context.Context, first, last string) ([]models.AccountMovement, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT account_type, TO_CHAR(period, 'YYYY-MM'), debit_total, credit_total FROM account_period_balances
+		 WHERE period BETWEEN TO_DATE($1, 'YYYY-MM') AND TO_DATE($2, 'YYYY-MM')
+		 ORDER BY account_type, period`,
+		first, last)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.AccountMovement{}
+	for rows.Next() {
+		var m models.AccountMovement
+		if err := rows.Scan(&m.Account, &m.Period, &m.Debit, &m.Credit); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
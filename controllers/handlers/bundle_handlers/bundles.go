@@ -0,0 +1,117 @@
+// Package bundle_handlers provides HTTP handlers and the database store for bundle products:
+// kits of other products sold as one line.
+package bundle_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/bundles"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// BundleHandler provides HTTP handlers for bundles. The rules
+// live in the bundles service; the handlers only translate HTTP.
+type BundleHandler struct {
+	Service *bundles.Service
+}
+
+// BundleRequest is the request body for defining a bundle.
+type BundleRequest struct {
+	Pricing         string                   `json:"pricing"`
+	DiscountPercent float64                  `json:"discount_percent"`
+	Components      []models.BundleComponent `json:"components"` // product_id and quantity; unit_price is ignored
+}
+
+// RegisterRoutes registers the bundle routes. They hang off the product paths, so the
+// router is expected to match full paths.
+//
+// URL Paths:
+//   - GET /products/{id}/bundle: Read a bundle with its price and availability
+//   - PUT /products/{id}/bundle: Make a product a bundle or replace its components
+//   - DELETE /products/{id}/bundle: Make a bundle a plain product again
+func (h *BundleHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/bundle", h.GetBundle).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/bundle", h.SaveBundle).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/bundle", h.DeleteBundle).Methods("DELETE")
+}
+
+// GetBundle returns a bundle with its components, price and the number that can be made
+// from free stock.
+//
+// HTTP Method: GET
+// URL Path: /products/{id}/bundle
+//
+// Response:
+//   - Status Code: 200 (OK) with the Bundle in JSON.
+//   - Status Code: 404 (Not Found) if the product is not a bundle.
+//   - Status Code: 500 (Internal Server Error) if the bundle cannot be loaded.
+func (h *BundleHandler) GetBundle(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	bundle, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load bundle")
+		return
+	}
+	respond.JSON(w, http.StatusOK, bundle)
+}
+
+// SaveBundle makes a product a bundle of other products, or replaces its definition.
+//
+// HTTP Method: PUT
+// URL Path: /products/{id}/bundle
+//
+// Request Body:
+//   - JSON with pricing (fixed or components), discount_percent for components pricing
+//     and components of product_id and quantity (see BundleRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with the Bundle in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the product does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the definition is invalid, a component
+//     does not exist or bundles would be nested.
+//   - Status Code: 500 (Internal Server Error) if the bundle cannot be saved.
+func (h *BundleHandler) SaveBundle(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req BundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	components := make([]models.BundleComponent, len(req.Components))
+	for i, component := range req.Components {
+		components[i] = models.BundleComponent{ProductID: component.ProductID, Quantity: component.Quantity}
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	bundle := &models.Bundle{ProductID: id, Pricing: req.Pricing, DiscountPercent: req.DiscountPercent, Components: components}
+	if err := h.Service.Save(r.Context(), bundle, actor); err != nil {
+		httperr.Write(w, err, "Failed to save bundle")
+		return
+	}
+	respond.JSON(w, http.StatusOK, bundle)
+}
+
+// DeleteBundle makes a bundle a plain product again.
+//
+// HTTP Method: DELETE
+// URL Path: /products/{id}/bundle
+//
+// Response:
+//   - Status Code: 204 (No Content) if the bundle was removed.
+//   - Status Code: 404 (Not Found) if the product is not a bundle.
+//   - Status Code: 500 (Internal Server Error) if the bundle cannot be removed.
+func (h *BundleHandler) DeleteBundle(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete bundle")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
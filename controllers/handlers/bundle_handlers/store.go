@@ -0,0 +1,302 @@
+package bundle_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"erp/controllers/bundles"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBBundleStore implements models.BundleStore using a SQL database.
+type DBBundleStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// Queryer is satisfied by both *sql.DB and *sql.Tx.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// SaveBundle makes a product a bundle or replaces its components, in one transaction. The
+// bundle product is locked so concurrent saves do not mix their components.
+//
+// Parameters:
+//   - bundle: The bundle checked by the service; component prices, the price and the
+//     availability are set.
+//
+// Returns:
+//   - error: models.ErrNotFound if the product does not exist, a validation error if a
+//     component does not exist or is a bundle, or the product is a component of another
+//     bundle, or the query error.
+func (s *DBBundleStore) SaveBundle(ctx context.Context, bundle *models.Bundle) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ownPrice float64
+	err = tx.QueryRowContext(ctx, `SELECT price FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, bundle.ProductID).
+		Scan(&ownPrice)
+	if err == sql.ErrNoRows {
+		return models.NotFound("product %d not found", bundle.ProductID)
+	}
+	if err != nil {
+		return err
+	}
+	var parentID int
+	err = tx.QueryRowContext(ctx, `SELECT bundle_id FROM bundle_components WHERE component_id = $1 ORDER BY bundle_id LIMIT 1`,
+		bundle.ProductID).Scan(&parentID)
+	if err == nil {
+		return models.Invalid("product %d is a component of bundle %d; bundles cannot be nested", bundle.ProductID, parentID)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	ids := make(pq.Int64Array, len(bundle.Components))
+	quantities := make(pq.Int64Array, len(bundle.Components))
+	for i, component := range bundle.Components {
+		ids[i], quantities[i] = int64(component.ProductID), int64(component.Quantity)
+	}
+	rows, err := tx.QueryContext(ctx,
+		`SELECT p.id, p.price, b.product_id IS NOT NULL FROM products p LEFT JOIN bundles b ON b.product_id = p.id
+		 WHERE p.id = ANY($1) AND p.deleted_at IS NULL`, ids)
+	if err != nil {
+		return err
+	}
+	prices := map[int]float64{}
+	for rows.Next() {
+		var productID int
+		var price float64
+		var isBundle bool
+		if err := rows.Scan(&productID, &price, &isBundle); err != nil {
+			rows.Close()
+			return err
+		}
+		if isBundle {
+			rows.Close()
+			return models.Invalid("product %d is a bundle; bundles cannot be nested", productID)
+		}
+		prices[productID] = price
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range bundle.Components {
+		price, ok := prices[bundle.Components[i].ProductID]
+		if !ok {
+			return models.Invalid("product %d does not exist", bundle.Components[i].ProductID)
+		}
+		bundle.Components[i].UnitPrice = price
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO bundles (product_id, pricing, discount_percent, updated_by, updated_at) VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (product_id) DO UPDATE
+		 SET pricing = EXCLUDED.pricing, discount_percent = EXCLUDED.discount_percent,
+		     updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		bundle.ProductID, bundle.Pricing, bundle.DiscountPercent, bundle.UpdatedBy, bundle.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save bundle: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM bundle_components WHERE bundle_id = $1`, bundle.ProductID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO bundle_components (bundle_id, component_id, quantity)
+		 SELECT $1, c.component_id, c.quantity FROM unnest($2::int[], $3::int[]) AS c(component_id, quantity)`,
+		bundle.ProductID, ids, quantities)
+	if err != nil {
+		return fmt.Errorf("failed to save bundle components: %w", err)
+	}
+
+	free, err := FreeStock(ctx, tx, componentIDs(bundle))
+	if err != nil {
+		return err
+	}
+	bundle.Price = bundles.Price(bundle, ownPrice)
+	bundle.Available = bundles.Available(bundle, free)
+	return tx.Commit()
+}
+
+// GetBundle retrieves a bundle with its components, price and availability.
+//
+// Returns:
+//   - error: models.ErrNotFound if the product is not a bundle, or the query error.
+func (s *DBBundleStore) GetBundle(ctx context.Context, productID int) (*models.Bundle, error) {
+	found, err := LoadBundles(ctx, s.DB, []int{productID})
+	if err != nil {
+		return nil, err
+	}
+	bundle, ok := found[productID]
+	if !ok {
+		return nil, models.NotFound("product %d is not a bundle", productID)
+	}
+	free, err := FreeStock(ctx, s.DB, componentIDs(bundle))
+	if err != nil {
+		return nil, err
+	}
+	bundle.Available = bundles.Available(bundle, free)
+	return bundle, nil
+}
+
+// DeleteBundle removes a bundle's definition, making it a plain product again. Orders
+// already confirmed keep the reservations of its components.
+//
+// Returns:
+//   - error: models.ErrNotFound if the product is not a bundle, or the query error.
+func (s *DBBundleStore) DeleteBundle(ctx context.Context, productID int) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM bundles WHERE product_id = $1`, productID)
+	if err != nil {
+		return fmt.Errorf("failed to delete bundle: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("product %d is not a bundle", productID)
+	}
+	return nil
+}
+
+// Bundles retrieves the bundles among products, keyed by product ID.
+func (s *DBBundleStore) Bundles(ctx context.Context, productIDs []int) (map[int]*models.Bundle, error) {
+	return LoadBundles(ctx, s.DB, productIDs)
+}
+
+// LoadBundles reads the bundles among products with their components and prices, keyed by
+// product ID; products that are not bundles are left out. Stores that sell or ship products
+// call it in their own transaction to expand bundles into their components.
+//
+// Parameters:
+//   - q: The transaction (or database) to read with.
+//   - productIDs: The products to look up.
+//
+// Returns:
+//   - map[int]*models.Bundle: The bundles found.
+//   - error: The query error.
+func LoadBundles(ctx context.Context, q Queryer, productIDs []int) (map[int]*models.Bundle, error) {
+	found := map[int]*models.Bundle{}
+	if len(productIDs) == 0 {
+		return found, nil
+	}
+	rows, err := q.QueryContext(ctx,
+		`SELECT b.product_id, b.pricing, b.discount_percent, p.price, b.updated_by, b.updated_at
+		 FROM bundles b JOIN products p ON p.id = b.product_id WHERE b.product_id = ANY($1)`, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	ownPrices := map[int]float64{}
+	for rows.Next() {
+		var b models.Bundle
+		var ownPrice float64
+		if err := rows.Scan(&b.ProductID, &b.Pricing, &b.DiscountPercent, &ownPrice, &b.UpdatedBy, &b.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		b.Components = []models.BundleComponent{}
+		found[b.ProductID], ownPrices[b.ProductID] = &b, ownPrice
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(found) == 0 {
+		return found, err
+	}
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT c.bundle_id, c.component_id, c.quantity, p.price
+		 FROM bundle_components c JOIN products p ON p.id = c.component_id
+		 WHERE c.bundle_id = ANY($1) ORDER BY c.bundle_id, c.component_id`, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bundleID int
+		var component models.BundleComponent
+		if err := rows.Scan(&bundleID, &component.ProductID, &component.Quantity, &component.UnitPrice); err != nil {
+			return nil, err
+		}
+		if b, ok := found[bundleID]; ok {
+			b.Components = append(b.Components, component)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for id, b := range found {
+		b.Price = bundles.Price(b, ownPrices[id])
+	}
+	return found, nil
+}
+
+// FreeStock reads the stock of products across all warehouses less the quantity reserved
+// for open orders, keyed by product. Products that do not exist are left out.
+func FreeStock(ctx context.Context, q Queryer, productIDs []int) (map[int]int, error) {
+	free := map[int]int{}
+	if len(productIDs) == 0 {
+		return free, nil
+	}
+	rows, err := q.QueryContext(ctx,
+		`SELECT p.id,
+		        (SELECT COALESCE(SUM(quantity), 0) FROM stock WHERE product_id = p.id)
+		      - (SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE product_id = p.id AND status = 'active')
+		 FROM products p WHERE p.id = ANY($1)`, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var productID, quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		free[productID] = quantity
+	}
+	return free, rows.Err()
+}
+
+// Availability reads how many of each product can be sold from free stock, keyed by
+// product: a plain product's free stock, or how many of a bundle its components' free
+// stock makes. Products that do not exist are left out.
+func Availability(ctx context.Context, q Queryer, productIDs []int) (map[int]int, error) {
+	found, err := LoadBundles(ctx, q, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	stocked := make([]int, 0, len(productIDs))
+	for _, id := range productIDs {
+		if b, ok := found[id]; ok {
+			stocked = append(stocked, componentIDs(b)...)
+		} else {
+			stocked = append(stocked, id)
+		}
+	}
+	free, err := FreeStock(ctx, q, stocked)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[int]int, len(productIDs))
+	for _, id := range productIDs {
+		if b, ok := found[id]; ok {
+			available[id] = bundles.Available(b, free)
+		} else if quantity, ok := free[id]; ok {
+			available[id] = quantity
+		}
+	}
+	return available, nil
+}
+
+// componentIDs returns the products a bundle is made of.
+func componentIDs(bundle *models.Bundle) []int {
+	ids := make([]int, len(bundle.Components))
+	for i, component := range bundle.Components {
+		ids[i] = component.ProductID
+	}
+	return ids
+}
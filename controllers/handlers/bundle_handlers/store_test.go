@@ -0,0 +1,59 @@
+package bundle_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveBundleRejectsNesting(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBBundleStore{DB: db}
+	bundle := &models.Bundle{ProductID: 9, Pricing: models.BundlePriceFixed,
+		Components: []models.BundleComponent{{ProductID: 3, Quantity: 2}, {ProductID: 8, Quantity: 1}}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT price FROM products WHERE id = $1")).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(25.0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT bundle_id FROM bundle_components WHERE component_id = $1")).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"bundle_id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM products p LEFT JOIN bundles b")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "price", "is_bundle"}).AddRow(3, 4.99, false).AddRow(8, 30.0, true))
+	mock.ExpectRollback()
+
+	err = store.SaveBundle(context.Background(), bundle)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Contains(t, err.Error(), "product 8 is a bundle")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAvailabilityDerivesBundlesFromComponents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	updated := time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)
+
+	// Product 9 is a bundle of two of product 3 and one of product 5; product 4 is plain
+	mock.ExpectQuery(regexp.QuoteMeta("FROM bundles b JOIN products p")).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "pricing", "discount_percent", "price", "updated_by", "updated_at"}).
+			AddRow(9, models.BundlePriceComponents, 10.0, 0.0, "sales@example.com", updated))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM bundle_components c JOIN products p")).
+		WillReturnRows(sqlmock.NewRows([]string{"bundle_id", "component_id", "quantity", "price"}).
+			AddRow(9, 3, 2, 4.99).AddRow(9, 5, 1, 12.0))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_reservations")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "free"}).AddRow(3, 7).AddRow(5, 10).AddRow(4, 6))
+
+	available, err := Availability(context.Background(), db, []int{4, 9, 99})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{4: 6, 9: 3}, available)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
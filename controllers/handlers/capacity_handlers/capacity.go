@@ -0,0 +1,119 @@
+// Package capacity_handlers provides HTTP handlers and the database store for capacity
+// planning: the hours employees are planned to work on projects, and the report comparing
+// them with the hours available.
+package capacity_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/capacity"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// CapacityHandler provides HTTP handlers for capacity planning.
+type CapacityHandler struct {
+	Service *capacity.Service
+}
+
+// RegisterRoutes maps capacity planning routes to their handler functions. The router is
+// expected to be protected with middleware.JWTAuth and limited to the planners.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The capacity handler.
+func RegisterRoutes(router *mux.Router, handler *CapacityHandler) {
+	router.HandleFunc("/capacity", handler.GetCapacity).Methods("GET")
+	router.HandleFunc("/project_allocations", handler.ListAllocations).Methods("GET")
+	router.HandleFunc("/project_allocations", handler.CreateAllocation).Methods("POST")
+	router.HandleFunc("/project_allocations/{id:[0-9]+}", handler.DeleteAllocation).Methods("DELETE")
+}
+
+// GetCapacity compares the hours available in a month with the hours planned on projects,
+// by department and employee.
+//
+// HTTP Method: GET
+// URL Path: /planning/capacity?period=2025-06 (optional; the current month by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with the CapacityReport in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be worked out.
+func (h *CapacityHandler) GetCapacity(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.Report(r.Context(), r.URL.Query().Get("period"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load capacity")
+		return
+	}
+	respond.JSON(w, http.StatusOK, report)
+}
+
+// ListAllocations lists the project allocations of a month.
+//
+// HTTP Method: GET
+// URL Path: /planning/project_allocations?period=2025-06
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of ProjectAllocations in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the period is missing or invalid.
+//   - Status Code: 500 (Internal Server Error) if the allocations cannot be loaded.
+func (h *CapacityHandler) ListAllocations(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Service.Allocations(r.Context(), r.URL.Query().Get("period"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load project allocations")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// CreateAllocation plans hours of an employee on a project in a month.
+//
+// HTTP Method: POST
+// URL Path: /planning/project_allocations
+//
+// Request Body:
+//   - JSON with user_id, project, period (YYYY-MM) and hours.
+//
+// Response:
+//   - Status Code: 201 (Created) with the allocation in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the employee already has hours on the project that month.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid or the user does not exist.
+//   - Status Code: 500 (Internal Server Error) if the allocation cannot be recorded.
+func (h *CapacityHandler) CreateAllocation(w http.ResponseWriter, r *http.Request) {
+	var allocation models.ProjectAllocation
+	if err := json.NewDecoder(r.Body).Decode(&allocation); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Allocate(r.Context(), &allocation, actor); err != nil {
+		httperr.Write(w, err, "Failed to create project allocation")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, allocation)
+}
+
+// DeleteAllocation removes a project allocation.
+//
+// HTTP Method: DELETE
+// URL Path: /planning/project_allocations/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the allocation is removed.
+//   - Status Code: 404 (Not Found) if the allocation does not exist.
+//   - Status Code: 500 (Internal Server Error) if the allocation cannot be removed.
+func (h *CapacityHandler) DeleteAllocation(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.Deallocate(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete project allocation")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
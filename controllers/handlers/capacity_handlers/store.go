@@ -0,0 +1,111 @@
+package capacity_handlers
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"erp/models"
+)
+
+// statusApproved is the status of approved leave.
+const statusApproved = "Approved"
+
+// DBCapacityStore implements models.CapacityStore using a SQL database.
+type DBCapacityStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// CreateProjectAllocation records the hours an employee is planned to work on a project in
+// a month.
+//
+// Returns:
+//   - error: A validation error if the user does not exist, a conflict if they already
+//     have hours on the project in the month, or the query error.
+func (s *DBCapacityStore) CreateProjectAllocation(ctx context.Context, a *models.ProjectAllocation) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO project_allocations (user_id, project, period, hours, created_by, created_at)
+		 VALUES ($1, $2, to_date($3, 'YYYY-MM'), $4, $5, $6) RETURNING id`,
+		a.UserID, a.Project, a.Period, a.Hours, a.CreatedBy, a.CreatedAt,
+	).Scan(&a.ID)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("user %d does not exist", a.UserID)
+	}
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("user %d already has hours on %s in %s", a.UserID, a.Project, a.Period)
+	}
+	return err
+}
+
+// ListProjectAllocations returns the allocations of a month by employee and project.
+func (s *DBCapacityStore) ListProjectAllocations(ctx context.Context, period string) ([]models.ProjectAllocation, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT a.id, a.user_id, u.name, COALESCE(u.department, ''), a.project, to_char(a.period, 'YYYY-MM'), a.hours,
+		        a.created_by, a.created_at
+		 FROM project_allocations a JOIN users u ON u.id = a.user_id
+		 WHERE a.period = to_date($1, 'YYYY-MM') ORDER BY u.name, a.project, a.id`, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.ProjectAllocation{}
+	for rows.Next() {
+		var a models.ProjectAllocation
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Employee, &a.Department, &a.Project, &a.Period, &a.Hours,
+			&a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// DeleteProjectAllocation removes an allocation.
+func (s *DBCapacityStore) DeleteProjectAllocation(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM project_allocations WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("project allocation %d not found", id)
+	}
+	return nil
+}
+
+// CapacityEmployees returns the active users.
+func (s *DBCapacityStore) CapacityEmployees(ctx context.Context) ([]models.CapacityEmployee, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, COALESCE(department, '') FROM users WHERE active`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var employees []models.CapacityEmployee
+	for rows.Next() {
+		var e models.CapacityEmployee
+		if err := rows.Scan(&e.UserID, &e.Name, &e.Department); err != nil {
+			return nil, err
+		}
+		employees = append(employees, e)
+	}
+	return employees, rows.Err()
+}
+
+// ApprovedLeave returns the approved leave overlapping the days from first to last.
+func (s *DBCapacityStore) ApprovedLeave(ctx context.Context, first, last time.Time) ([]models.Leave, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, user_id, leave_type, start_date, end_date, status FROM leave
+		 WHERE status = $1 AND start_date <= $3 AND end_date >= $2`, statusApproved, first, last)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.Leave
+	for rows.Next() {
+		var l models.Leave
+		if err := rows.Scan(&l.ID, &l.UserID, &l.LeaveType, &l.StartDate, &l.EndDate, &l.Status); err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
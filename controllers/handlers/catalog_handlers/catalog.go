@@ -0,0 +1,166 @@
+package catalog_handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageSize    = 50
+	maxPageSize        = 200
+	maxAvailabilityIDs = 100
+)
+
+// CatalogHandler provides the read-only catalog endpoints.
+type CatalogHandler struct {
+	Store              models.CatalogStore
+	ProductsMaxAge     time.Duration // Cache lifetime of product responses
+	AvailabilityMaxAge time.Duration // Cache lifetime of availability responses
+}
+
+// RegisterRoutes maps catalog routes to their respective handler functions. The router is
+// expected to be protected with middleware.APIKeyAuth.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The configured catalog handler.
+func RegisterRoutes(router *mux.Router, handler *CatalogHandler) {
+	router.HandleFunc("/products", handler.ListProducts).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}", handler.GetProduct).Methods("GET")
+	router.HandleFunc("/availability", handler.GetAvailability).Methods("GET")
+}
+
+// ListProducts returns a page of catalog products.
+//
+// HTTP Method: GET
+// URL Path: /products?limit=50&offset=0
+//
+// Response:
+//   - Status Code: 200 (OK) with the products, or 304 (Not Modified) if the client's copy is current.
+//   - Status Code: 400 (Bad Request) if limit or offset is invalid.
+//   - Status Code: 500 (Internal Server Error) if the products cannot be read.
+func (h *CatalogHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultPageSize)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		respond.Error(w, http.StatusBadRequest, "offset must be a non-negative integer")
+		return
+	}
+
+	products, err := h.Store.ListCatalogProducts(r.Context(), limit, offset)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Failed to load products")
+		return
+	}
+	writeCached(w, r, h.ProductsMaxAge, products)
+}
+
+// GetProduct returns one catalog product.
+//
+// HTTP Method: GET
+// URL Path: /products/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the product, or 304 (Not Modified) if the client's copy is current.
+//   - Status Code: 404 (Not Found) if the product does not exist.
+//   - Status Code: 500 (Internal Server Error) if the product cannot be read.
+func (h *CatalogHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	product, err := h.Store.GetCatalogProduct(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Failed to load product")
+		return
+	}
+	writeCached(w, r, h.ProductsMaxAge, product)
+}
+
+// GetAvailability returns the available quantity of the requested products.
+//
+// HTTP Method: GET
+// URL Path: /availability?product_ids=1,2,3
+//
+// Response:
+//   - Status Code: 200 (OK) with one entry per existing product, or 304 (Not Modified).
+//   - Status Code: 400 (Bad Request) if product_ids is missing, invalid or too long.
+//   - Status Code: 500 (Internal Server Error) if availability cannot be read.
+func (h *CatalogHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	var ids []int
+	for _, part := range strings.Split(r.URL.Query().Get("product_ids"), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "product_ids must be a comma-separated list of IDs")
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 || len(ids) > maxAvailabilityIDs {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("Between 1 and %d product_ids are required", maxAvailabilityIDs))
+		return
+	}
+
+	availability, err := h.Store.GetAvailability(r.Context(), ids)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Failed to load availability")
+		return
+	}
+	writeCached(w, r, h.AvailabilityMaxAge, availability)
+}
+
+// writeCached writes value as JSON with Cache-Control and ETag headers, answering
+// 304 Not Modified when the client's If-None-Match matches.
+func writeCached(w http.ResponseWriter, r *http.Request, maxAge time.Duration, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// queryInt reads an integer query parameter, returning fallback when it is absent.
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
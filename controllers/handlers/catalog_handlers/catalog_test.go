@@ -0,0 +1,159 @@
+package catalog_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockCatalogStore serves a fixed set of products and stock levels.
+type mockCatalogStore struct {
+	products []models.CatalogProduct
+	stock    map[int]int
+}
+
+func (m *mockCatalogStore) ListCatalogProducts(ctx context.Context, limit, offset int) ([]models.CatalogProduct, error) {
+	if offset >= len(m.products) {
+		return []models.CatalogProduct{}, nil
+	}
+	end := offset + limit
+	if end > len(m.products) {
+		end = len(m.products)
+	}
+	return m.products[offset:end], nil
+}
+
+func (m *mockCatalogStore) GetCatalogProduct(ctx context.Context, id int) (*models.CatalogProduct, error) {
+	for _, p := range m.products {
+		if p.ID == id {
+			return &p, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (m *mockCatalogStore) GetAvailability(ctx context.Context, productIDs []int) ([]models.ProductAvailability, error) {
+	result := []models.ProductAvailability{}
+	for _, id := range productIDs {
+		if _, err := m.GetCatalogProduct(ctx, id); err == nil {
+			result = append(result, models.ProductAvailability{ProductID: id, Quantity: m.stock[id], InStock: m.stock[id] > 0})
+		}
+	}
+	return result, nil
+}
+
+// mockAPIKeyStore keeps API keys in memory, keyed by hash.
+type mockAPIKeyStore struct {
+	keys map[string]*models.APIKey
+}
+
+func (m *mockAPIKeyStore) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	key.ID = len(m.keys) + 1
+	key.Active = true
+	m.keys[key.KeyHash] = key
+	return nil
+}
+
+func (m *mockAPIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	key, ok := m.keys[hash]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return key, nil
+}
+
+func (m *mockAPIKeyStore) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) { return nil, nil }
+
+func (m *mockAPIKeyStore) RevokeAPIKey(ctx context.Context, id int) error { return nil }
+
+func setupCatalogRouter(t *testing.T) *mux.Router {
+	ctx := context.Background()
+	keys := &mockAPIKeyStore{keys: make(map[string]*models.APIKey)}
+	keys.CreateAPIKey(ctx, &models.APIKey{Name: "shop", KeyHash: middleware.HashAPIKey("shop-key"), Scopes: []string{models.ScopeCatalogRead}, RateLimit: 3})
+	keys.CreateAPIKey(ctx, &models.APIKey{Name: "other", KeyHash: middleware.HashAPIKey("other-key"), Scopes: []string{"orders:write"}})
+
+	store := &mockCatalogStore{
+		products: []models.CatalogProduct{{ID: 1, Name: "Jacket", Price: 40}, {ID: 2, Name: "Scarf", Price: 12}},
+		stock:    map[int]int{1: 5},
+	}
+
+	router := mux.NewRouter()
+	catalogRouter := router.PathPrefix("/catalog").Subrouter()
+	catalogRouter.Use(middleware.APIKeyAuth(keys, middleware.NewRateLimiter(), 60, models.ScopeCatalogRead))
+	RegisterRoutes(catalogRouter, &CatalogHandler{Store: store, ProductsMaxAge: 5 * time.Minute, AvailabilityMaxAge: 30 * time.Second})
+	return router
+}
+
+func catalogRequest(router *mux.Router, path, key, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if key != "" {
+		req.Header.Set(middleware.APIKeyHeader, key)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCatalogRequiresScopedAPIKey(t *testing.T) {
+	router := setupCatalogRouter(t)
+
+	assert.Equal(t, http.StatusUnauthorized, catalogRequest(router, "/catalog/products", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, catalogRequest(router, "/catalog/products", "wrong", "").Code)
+	assert.Equal(t, http.StatusForbidden, catalogRequest(router, "/catalog/products", "other-key", "").Code)
+}
+
+func TestCatalogProductsCaching(t *testing.T) {
+	router := setupCatalogRouter(t)
+
+	rr := catalogRequest(router, "/catalog/products", "shop-key", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "public, max-age=300", rr.Header().Get("Cache-Control"))
+	var products []models.CatalogProduct
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&products))
+	assert.Len(t, products, 2)
+
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	rr = catalogRequest(router, "/catalog/products", "shop-key", etag)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+}
+
+func TestCatalogAvailability(t *testing.T) {
+	router := setupCatalogRouter(t)
+
+	rr := catalogRequest(router, "/catalog/availability?product_ids=1,2,9", "shop-key", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var availability []models.ProductAvailability
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&availability))
+	assert.Equal(t, []models.ProductAvailability{
+		{ProductID: 1, Quantity: 5, InStock: true},
+		{ProductID: 2, Quantity: 0, InStock: false},
+	}, availability)
+
+	rr = catalogRequest(router, "/catalog/availability", "shop-key", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCatalogRateLimit(t *testing.T) {
+	router := setupCatalogRouter(t)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, catalogRequest(router, "/catalog/products/1", "shop-key", "").Code)
+	}
+	rr := catalogRequest(router, "/catalog/products/1", "shop-key", "")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+}
@@ -0,0 +1,94 @@
+// Package catalog_handlers provides the read-only public product catalog used by the
+// e-commerce site. Clients authenticate with API keys rather than user JWTs.
+package catalog_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/controllers/handlers/bundle_handlers"
+	"erp/models"
+	"sort"
+)
+
+// DBCatalogStore provides SQL-backed read-only catalog queries. Queries select the public
+// columns explicitly so internal fields never leave the database layer.
+type DBCatalogStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// ListCatalogProducts returns a page of products ordered by ID.
+//
+// Parameters:
+//   - limit: The maximum number of products to return.
+//   - offset: The number of products to skip.
+//
+// Returns:
+//   - []CatalogProduct: The page of products.
+//   - error: An error if the query fails.
+func (store *DBCatalogStore) ListCatalogProducts(ctx context.Context, limit, offset int) ([]models.CatalogProduct, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT id, name, COALESCE(brand, ''), COALESCE(season, ''), price FROM products WHERE deleted_at IS NULL ORDER BY id LIMIT $1 OFFSET $2",
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []models.CatalogProduct{}
+	for rows.Next() {
+		var p models.CatalogProduct
+		if err := rows.Scan(&p.ID, &p.Name, &p.Brand, &p.Season, &p.Price); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// GetCatalogProduct returns one product.
+//
+// Parameters:
+//   - id: The ID of the product.
+//
+// Returns:
+//   - *CatalogProduct: The product.
+//   - error: models.ErrNotFound if it does not exist, or another error if the query fails.
+func (store *DBCatalogStore) GetCatalogProduct(ctx context.Context, id int) (*models.CatalogProduct, error) {
+	var p models.CatalogProduct
+	err := store.DB.QueryRowContext(ctx,
+		"SELECT id, name, COALESCE(brand, ''), COALESCE(season, ''), price FROM products WHERE id = $1 AND deleted_at IS NULL", id,
+	).Scan(&p.ID, &p.Name, &p.Brand, &p.Season, &p.Price)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetAvailability sums the stock of each requested product across all warehouses, less the
+// quantity reserved for open orders. Products without stock records are reported with a
+// quantity of zero. A bundle is reported as the number its components' free stock makes.
+//
+// Parameters:
+//   - productIDs: The products to report on.
+//
+// Returns:
+//   - []ProductAvailability: One entry per existing product, ordered by product ID.
+//   - error: An error if the query fails.
+func (store *DBCatalogStore) GetAvailability(ctx context.Context, productIDs []int) ([]models.ProductAvailability, error) {
+	quantities, err := bundle_handlers.Availability(ctx, store.DB, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	availability := []models.ProductAvailability{}
+	for productID, quantity := range quantities {
+		a := models.ProductAvailability{ProductID: productID, Quantity: max(quantity, 0)}
+		a.InStock = a.Quantity > 0
+		availability = append(availability, a)
+	}
+	sort.Slice(availability, func(i, j int) bool { return availability[i].ProductID < availability[j].ProductID })
+	return availability, nil
+}
@@ -3,8 +3,13 @@
 package customer_data_management_handlers
 
 import (
-	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/pagination"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
 	"erp/models"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -17,35 +22,85 @@ type CustomerHandlers struct {
 	Store models.CustomerStore // Interface for interacting with the customer data store
 }
 
+// CustomerRequest is the request body for creating or updating a customer. The ID is
+// assigned by the store or taken from the URL.
+type CustomerRequest struct {
+	Name         string `json:"name"`
+	Contact      string `json:"contact"`
+	OrderHistory string `json:"order_history"`
+	Version      int    `json:"version"` // The version updated, unless sent in If-Match
+}
+
+// Customer returns the customer described by the request.
+func (req CustomerRequest) Customer() models.Customer {
+	return models.Customer{
+		Name:         req.Name,
+		Contact:      req.Contact,
+		OrderHistory: req.OrderHistory,
+	}
+}
+
+// Validate checks that the customer has a name and a way to contact them.
+func (req CustomerRequest) Validate() error {
+	var c validation.Checker
+	c.Required("name", req.Name)
+	c.Required("contact", req.Contact)
+	return c.Err()
+}
+
 // CreateCustomerHandler handles HTTP POST requests for creating a new customer.
 //
 // Request Body:
-//   - JSON object representing a customer.
+//   - JSON object with name, contact and order_history (see CustomerRequest).
 //
 // Response:
 //   - 201 Created: If the customer is successfully created, returns the customer object as JSON.
-//   - 400 Bad Request: If the request payload is invalid.
+//   - 400 Bad Request: If the request payload is not JSON.
+//   - 422 Unprocessable Entity: If a field is invalid; the error details list them.
 //   - 500 Internal Server Error: If an error occurs while creating the customer.
 func (h *CustomerHandlers) CreateCustomerHandler(w http.ResponseWriter, r *http.Request) {
-	var customer models.Customer
+	var req CustomerRequest
 
-	// Decode JSON body into the customer struct
-	err := json.NewDecoder(r.Body).Decode(&customer)
-	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	// Decode JSON body into the request struct
+	if !validation.Decode(w, r, &req) {
 		return
 	}
+	customer := req.Customer()
 
 	// Create the customer in the database
-	err = h.Store.CreateCustomer(&customer)
+	err := h.Store.CreateCustomer(r.Context(), &customer)
 	if err != nil {
-		http.Error(w, "Failed to create customer", http.StatusInternalServerError)
+		httperr.Write(w, err, "Failed to create customer")
 		return
 	}
 
 	// Respond with the created customer object
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(customer)
+	respond.JSON(w, http.StatusCreated, customer)
+}
+
+// ListCustomersHandler handles HTTP GET requests to list customers a page at a time.
+//
+// Query Parameters:
+//   - page, limit: The page number (from 1) and its size (default 50, at most 200).
+//   - sort: id, name or contact, prefixed with "-" for descending order.
+//   - name, contact, id: Filters; text is matched case-insensitively.
+//
+// Response:
+//   - 200 OK: Returns {"items": [...], "total": n, "page": p, "limit": l} as JSON.
+//   - 422 Unprocessable Entity: If a parameter is invalid.
+//   - 500 Internal Server Error: If an error occurs while listing the customers.
+func (h *CustomerHandlers) ListCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := pagination.Parse(r, CustomerColumns)
+	if err != nil {
+		httperr.Write(w, err, "Invalid list query")
+		return
+	}
+	customers, total, err := h.Store.ListCustomers(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Failed to list customers")
+		return
+	}
+	pagination.Write(w, customers, total, query)
 }
 
 // GetCustomerByIDHandler handles HTTP GET requests to fetch a customer by their ID.
@@ -54,7 +109,7 @@ func (h *CustomerHandlers) CreateCustomerHandler(w http.ResponseWriter, r *http.
 //   - id: Customer ID (integer).
 //
 // Response:
-//   - 200 OK: Returns the customer object as JSON.
+//   - 200 OK: Returns the customer object as JSON, with its version as the ETag.
 //   - 400 Bad Request: If the provided ID is invalid.
 //   - 404 Not Found: If no customer with the given ID exists.
 func (h *CustomerHandlers) GetCustomerByIDHandler(w http.ResponseWriter, r *http.Request) {
@@ -62,20 +117,24 @@ func (h *CustomerHandlers) GetCustomerByIDHandler(w http.ResponseWriter, r *http
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid customer ID")
 		return
 	}
 
 	// Fetch the customer by ID
-	customer, err := h.Store.GetCustomerByID(id)
+	customer, err := h.Store.GetCustomerByID(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Customer not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Customer not found", http.StatusNotFound)
+		httperr.Write(w, err, "Failed to load customer")
 		return
 	}
 
 	// Respond with the customer object
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(customer)
+	versioning.SetETag(w, customer.Version)
+	respond.JSON(w, http.StatusOK, customer)
 }
 
 // UpdateCustomerHandler handles HTTP PUT requests to update an existing customer's data.
@@ -84,42 +143,55 @@ func (h *CustomerHandlers) GetCustomerByIDHandler(w http.ResponseWriter, r *http
 //   - id: Customer ID (integer).
 //
 // Request Body:
-//   - JSON object representing the updated customer data.
+//   - JSON object with name, contact, order_history and the version read (see
+//     CustomerRequest); the version may be sent as an If-Match header instead.
 //
 // Response:
 //   - 200 OK: If the update is successful, returns the updated customer object as JSON.
 //   - 400 Bad Request: If the ID is invalid or the request payload is malformed.
+//   - 404 Not Found: If no customer exists with the given ID.
+//   - 409 Conflict: If the customer was updated by someone else since the version read.
+//   - 422 Unprocessable Entity: If a field is invalid; the error details list them.
+//   - 428 Precondition Required: If no version is given.
 //   - 500 Internal Server Error: If an error occurs while updating the customer.
 func (h *CustomerHandlers) UpdateCustomerHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the "id" variable from the URL
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid customer ID")
 		return
 	}
 
-	var customer models.Customer
-	// Decode JSON body into the customer struct
-	err = json.NewDecoder(r.Body).Decode(&customer)
-	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	var req CustomerRequest
+	// Decode JSON body into the request struct
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
 		return
 	}
 
 	// Ensure the customer ID matches the URL parameter
-	customer.ID = id
+	customer := req.Customer()
+	customer.ID, customer.Version = id, version
 
 	// Update the customer data in the store
-	err = h.Store.UpdateCustomer(&customer)
+	err = h.Store.UpdateCustomer(r.Context(), &customer)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Customer not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to update customer", http.StatusInternalServerError)
+		httperr.Write(w, err, "Failed to update customer")
 		return
 	}
 
 	// Respond with the updated customer object
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(customer)
+	versioning.SetETag(w, customer.Version)
+	respond.JSON(w, http.StatusOK, customer)
 }
 
 // DeleteCustomerHandler handles HTTP DELETE requests to remove a customer by their ID.
@@ -130,20 +202,25 @@ func (h *CustomerHandlers) UpdateCustomerHandler(w http.ResponseWriter, r *http.
 // Response:
 //   - 204 No Content: If the deletion is successful.
 //   - 400 Bad Request: If the provided ID is invalid.
+//   - 404 Not Found: If no customer exists with the given ID.
 //   - 500 Internal Server Error: If an error occurs while deleting the customer.
 func (h *CustomerHandlers) DeleteCustomerHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the "id" variable from the URL
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid customer ID")
 		return
 	}
 
 	// Delete the customer by ID
-	err = h.Store.DeleteCustomer(id)
+	err = h.Store.DeleteCustomer(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Customer not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to delete customer", http.StatusInternalServerError)
+		httperr.Write(w, err, "Failed to delete customer")
 		return
 	}
 
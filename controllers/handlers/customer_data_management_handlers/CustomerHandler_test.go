@@ -5,12 +5,17 @@
 package customer_data_management_handlers_test
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"erp/models"
 	"erp/controllers/handlers/customer_data_management_handlers"
+	"erp/controllers/pagination"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -44,9 +49,10 @@ func NewMockCustomerStore() *MockCustomerStore {
 //
 // Returns:
 //   - Always returns nil as it assumes no errors in a mock setup.
-func (m *MockCustomerStore) CreateCustomer(customer *models.Customer) error {
-	customer.ID = m.nextID
-	m.customers[m.nextID] = customer
+func (m *MockCustomerStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	customer.ID, customer.Version = m.nextID, 1
+	stored := *customer
+	m.customers[m.nextID] = &stored
 	m.nextID++
 	return nil
 }
@@ -59,12 +65,13 @@ func (m *MockCustomerStore) CreateCustomer(customer *models.Customer) error {
 // Returns:
 //   - The customer object if found.
 //   - models.ErrNotFound if no customer exists with the given ID.
-func (m *MockCustomerStore) GetCustomerByID(id int) (*models.Customer, error) {
+func (m *MockCustomerStore) GetCustomerByID(ctx context.Context, id int) (*models.Customer, error) {
 	customer, exists := m.customers[id]
 	if !exists {
 		return nil, models.ErrNotFound
 	}
-	return customer, nil
+	found := *customer
+	return &found, nil
 }
 
 // UpdateCustomer simulates updating an existing customer's data.
@@ -75,12 +82,18 @@ func (m *MockCustomerStore) GetCustomerByID(id int) (*models.Customer, error) {
 // Returns:
 //   - nil if the update is successful.
 //   - models.ErrNotFound if no customer exists with the given ID.
-func (m *MockCustomerStore) UpdateCustomer(customer *models.Customer) error {
-	_, exists := m.customers[customer.ID]
+//   - A conflict if the customer is no longer at customer.Version.
+func (m *MockCustomerStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	stored, exists := m.customers[customer.ID]
 	if !exists {
 		return models.ErrNotFound
 	}
-	m.customers[customer.ID] = customer
+	if stored.Version != customer.Version {
+		return models.Conflict("customer %d is at version %d", customer.ID, stored.Version)
+	}
+	customer.Version++
+	updated := *customer
+	m.customers[customer.ID] = &updated
 	return nil
 }
 
@@ -92,7 +105,7 @@ func (m *MockCustomerStore) UpdateCustomer(customer *models.Customer) error {
 // Returns:
 //   - nil if the deletion is successful.
 //   - models.ErrNotFound if no customer exists with the given ID.
-func (m *MockCustomerStore) DeleteCustomer(id int) error {
+func (m *MockCustomerStore) DeleteCustomer(ctx context.Context, id int) error {
 	_, exists := m.customers[id]
 	if !exists {
 		return models.ErrNotFound
@@ -101,6 +114,30 @@ func (m *MockCustomerStore) DeleteCustomer(id int) error {
 	return nil
 }
 
+// ListCustomers simulates listing customers, sorted by ID and filtered by ID, name and contact.
+//
+// Parameters:
+//   - query: The page and filters.
+//
+// Returns:
+//   - The page of customers and the number matching the filters.
+func (m *MockCustomerStore) ListCustomers(ctx context.Context, query models.ListQuery) ([]models.Customer, int, error) {
+	matches := []models.Customer{}
+	for _, customer := range m.customers {
+		fields := map[string]string{"id": strconv.Itoa(customer.ID), "name": customer.Name, "contact": customer.Contact}
+		match := true
+		for key, value := range query.Filters {
+			match = match && strings.EqualFold(fields[key], value)
+		}
+		if match {
+			matches = append(matches, *customer)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+	start, end := pagination.Bounds(len(matches), query)
+	return matches[start:end], len(matches), nil
+}
+
 // TestCreateCustomerHandler validates the CreateCustomerHandler functionality.
 //
 // Steps:
@@ -130,6 +167,38 @@ func TestCreateCustomerHandler(t *testing.T) {
 	assert.Equal(t, newCustomer.OrderHistory, createdCustomer.OrderHistory, "Customer order history mismatch")
 }
 
+// TestCreateCustomerHandlerValidation validates that malformed and invalid customers are
+// answered with problem documents and not saved.
+//
+// Steps:
+//   - Simulate HTTP POST requests with a body that is not JSON and one without a name or contact.
+//   - Verify 400 Bad Request and 422 Unprocessable Entity listing both fields.
+func TestCreateCustomerHandlerValidation(t *testing.T) {
+	store := NewMockCustomerStore()
+	handler := customer_data_management_handlers.CustomerHandlers{Store: store}
+
+	req, _ := http.NewRequest(http.MethodPost, "/customers", strings.NewReader(`{"name": `))
+	rec := httptest.NewRecorder()
+	handler.CreateCustomerHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	req, _ = http.NewRequest(http.MethodPost, "/customers", strings.NewReader(`{"name": " ", "order_history": "Order 1"}`))
+	rec = httptest.NewRecorder()
+	handler.CreateCustomerHandler(rec, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var body struct {
+		Error struct {
+			Code    string              `json:"code"`
+			Details []models.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "validation_failed", body.Error.Code)
+	assert.Equal(t, []models.FieldError{{Field: "name", Message: "is required"}, {Field: "contact", Message: "is required"}}, body.Error.Details)
+	assert.Empty(t, store.customers, "Invalid customers are not saved")
+}
+
 // TestGetCustomerByIDHandler validates the GetCustomerByIDHandler functionality.
 //
 // Steps:
@@ -141,7 +210,7 @@ func TestGetCustomerByIDHandler(t *testing.T) {
 	handler := customer_data_management_handlers.CustomerHandlers{Store: store}
 
 	// Add a customer to the mock store
-	store.CreateCustomer(&models.Customer{Name: "Existing Customer", Contact: "9876543210", OrderHistory: "Order 3"})
+	store.CreateCustomer(context.Background(), &models.Customer{Name: "Existing Customer", Contact: "9876543210", OrderHistory: "Order 3"})
 
 	// Simulate the HTTP GET request
 	req, _ := http.NewRequest(http.MethodGet, "/customers/1", nil)
@@ -171,10 +240,10 @@ func TestUpdateCustomerHandler(t *testing.T) {
 	handler := customer_data_management_handlers.CustomerHandlers{Store: store}
 
 	// Add a customer to the mock store
-	store.CreateCustomer(&models.Customer{Name: "Old Name", Contact: "0000000000", OrderHistory: "Order A"})
+	store.CreateCustomer(context.Background(), &models.Customer{Name: "Old Name", Contact: "0000000000", OrderHistory: "Order A"})
 
 	// Updated customer data
-	updatedCustomer := &models.Customer{ID: 1, Name: "Updated Name", Contact: "9999999999", OrderHistory: "Order B"}
+	updatedCustomer := &models.Customer{ID: 1, Name: "Updated Name", Contact: "9999999999", OrderHistory: "Order B", Version: 1}
 	payload, _ := json.Marshal(updatedCustomer)
 
 	// Simulate the HTTP PUT request
@@ -192,6 +261,40 @@ func TestUpdateCustomerHandler(t *testing.T) {
 	assert.Equal(t, updatedCustomer.Name, updatedResult.Name, "Customer name mismatch")
 	assert.Equal(t, updatedCustomer.Contact, updatedResult.Contact, "Customer contact mismatch")
 	assert.Equal(t, updatedCustomer.OrderHistory, updatedResult.OrderHistory, "Customer order history mismatch")
+	assert.Equal(t, 2, updatedResult.Version, "Customer version not incremented")
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+}
+
+// TestUpdateCustomerHandlerRejectsStaleVersion validates that an update based on a version
+// someone else has replaced is refused instead of overwriting their change.
+//
+// Steps:
+//   - Add a customer to the mock store and update it once.
+//   - Update it again with If-Match naming the first version, and without any version.
+//   - Verify 409 Conflict and 428 Precondition Required, and that the first update stands.
+func TestUpdateCustomerHandlerRejectsStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockCustomerStore()
+	handler := customer_data_management_handlers.CustomerHandlers{Store: store}
+	store.CreateCustomer(ctx, &models.Customer{Name: "Old Name", Contact: "0000000000"})
+	store.UpdateCustomer(ctx, &models.Customer{ID: 1, Name: "First Writer", Contact: "0000000000", Version: 1})
+
+	req, _ := http.NewRequest(http.MethodPut, "/customers/1", strings.NewReader(`{"name": "Second Writer", "contact": "0000000000"}`))
+	req.Header.Set("If-Match", `"1"`)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rec := httptest.NewRecorder()
+	handler.UpdateCustomerHandler(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	req, _ = http.NewRequest(http.MethodPut, "/customers/1", strings.NewReader(`{"name": "Second Writer", "contact": "0000000000"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rec = httptest.NewRecorder()
+	handler.UpdateCustomerHandler(rec, req)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+
+	customer, _ := store.GetCustomerByID(ctx, 1)
+	assert.Equal(t, "First Writer", customer.Name)
+	assert.Equal(t, 2, customer.Version)
 }
 
 // TestDeleteCustomerHandler validates the DeleteCustomerHandler functionality.
@@ -201,11 +304,12 @@ func TestUpdateCustomerHandler(t *testing.T) {
 //   - Simulate an HTTP DELETE request to remove the customer by ID.
 //   - Verify the response status and ensure the customer is deleted.
 func TestDeleteCustomerHandler(t *testing.T) {
+	ctx := context.Background()
 	store := NewMockCustomerStore()
 	handler := customer_data_management_handlers.CustomerHandlers{Store: store}
 
 	// Add a customer to the mock store
-	store.CreateCustomer(&models.Customer{Name: "To Be Deleted", Contact: "1111111111", OrderHistory: "Order X"})
+	store.CreateCustomer(ctx, &models.Customer{Name: "To Be Deleted", Contact: "1111111111", OrderHistory: "Order X"})
 
 	// Simulate the HTTP DELETE request
 	req, _ := http.NewRequest(http.MethodDelete, "/customers/1", nil)
@@ -217,6 +321,44 @@ func TestDeleteCustomerHandler(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusNoContent, rec.Code, "Expected status code 204 No Content")
-	_, err := store.GetCustomerByID(1)
+	_, err := store.GetCustomerByID(ctx, 1)
 	assert.Equal(t, models.ErrNotFound, err, "Expected the customer to be deleted")
 }
+
+// TestListCustomersHandler validates the ListCustomersHandler functionality.
+//
+// Steps:
+//   - Add customers to the mock store.
+//   - Simulate HTTP GET requests for a filtered page and with an unknown filter.
+//   - Verify the page, the total and the rejection of the unknown filter.
+func TestListCustomersHandler(t *testing.T) {
+	store := NewMockCustomerStore()
+	handler := customer_data_management_handlers.CustomerHandlers{Store: store}
+	for _, name := range []string{"Acme", "Globex", "acme"} {
+		store.CreateCustomer(context.Background(), &models.Customer{Name: name})
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/customers?name=ACME&limit=1&page=2", nil)
+	rec := httptest.NewRecorder()
+	handler.ListCustomersHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code, "Expected status code 200 OK")
+	var page struct {
+		Items []models.Customer `json:"items"`
+		Total int               `json:"total"`
+		Page  int               `json:"page"`
+		Limit int               `json:"limit"`
+	}
+	json.NewDecoder(rec.Body).Decode(&page)
+	assert.Equal(t, 2, page.Total, "Names are matched case-insensitively")
+	assert.Equal(t, 2, page.Page)
+	assert.Equal(t, 1, page.Limit)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, 3, page.Items[0].ID, "Pages are sorted by ID")
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/customers?email=a@example.com", nil)
+	rec = httptest.NewRecorder()
+	handler.ListCustomersHandler(rec, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "Expected unknown filters to be rejected")
+}
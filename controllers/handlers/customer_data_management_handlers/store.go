@@ -1,9 +1,13 @@
 package customer_data_management_handlers
 
 import (
+	"context"
     "database/sql"
-    "errors"
+    "erp/controllers/pagination"
+    "erp/controllers/versioning"
     "erp/models" // Adjust the import path if necessary
+
+	"github.com/lib/pq"
 )
 
 // DBStore is a struct to hold the database connection.
@@ -12,9 +16,9 @@ type DBStore struct {
 }
 
 // CreateCustomer inserts a new customer into the database.
-func (store *DBStore) CreateCustomer(customer *models.Customer) error {
-    query := `INSERT INTO customers (name, contact, order_history) VALUES ($1, $2, $3) RETURNING id`
-    err := store.DB.QueryRow(query, customer.Name, customer.Contact, customer.OrderHistory).Scan(&customer.ID)
+func (store *DBStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+    query := `INSERT INTO customers (name, contact, order_history) VALUES ($1, $2, $3) RETURNING id, version`
+    err := store.DB.QueryRowContext(ctx, query, customer.Name, customer.Contact, customer.OrderHistory).Scan(&customer.ID, &customer.Version)
     if err != nil {
         return err
     }
@@ -22,35 +26,129 @@ func (store *DBStore) CreateCustomer(customer *models.Customer) error {
 }
 
 // GetCustomerByID retrieves a customer by their ID from the database.
-func (store *DBStore) GetCustomerByID(id int) (*models.Customer, error) {
-    query := `SELECT id, name, contact, order_history FROM customers WHERE id = $1`
+func (store *DBStore) GetCustomerByID(ctx context.Context, id int) (*models.Customer, error) {
+    query := `SELECT id, name, contact, order_history, version FROM customers WHERE id = $1 AND deleted_at IS NULL`
     customer := &models.Customer{}
-    err := store.DB.QueryRow(query, id).Scan(&customer.ID, &customer.Name, &customer.Contact, &customer.OrderHistory)
+    err := store.DB.QueryRowContext(ctx, query, id).Scan(&customer.ID, &customer.Name, &customer.Contact, &customer.OrderHistory, &customer.Version)
     if err == sql.ErrNoRows {
-        return nil, errors.New("customer not found")
+        return nil, models.ErrNotFound
     } else if err != nil {
         return nil, err
     }
     return customer, nil
 }
 
-// UpdateCustomer updates an existing customer's details in the database.
-func (store *DBStore) UpdateCustomer(customer *models.Customer) error {
-	query := `UPDATE customers SET name = $1, contact = $2, order_history = $3 WHERE id = $4`
-	_, err := store.DB.Exec(query, customer.Name, customer.Contact, customer.OrderHistory, customer.ID)
+// UpdateCustomer updates an existing customer's details in the database if it is still at
+// customer.Version, and sets the new version. It returns models.ErrNotFound if the customer
+// does not exist and a conflict if someone else updated it since.
+func (store *DBStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	query := `UPDATE customers SET name = $1, contact = $2, order_history = $3
+		WHERE id = $4 AND version = $5 AND deleted_at IS NULL RETURNING version`
+	err := store.DB.QueryRowContext(ctx, query, customer.Name, customer.Contact, customer.OrderHistory, customer.ID, customer.Version).
+		Scan(&customer.Version)
+	if err == sql.ErrNoRows {
+		return versioning.Stale(ctx, store.DB, `SELECT version FROM customers WHERE id = $1 AND deleted_at IS NULL`, "customer",
+			customer.ID, customer.Version)
+	}
+	return err
+}
+
+// DeleteCustomer soft deletes a customer by their ID: the customer is hidden from reads
+// until it is restored or purged.
+func (store *DBStore) DeleteCustomer(ctx context.Context, id int) error {
+	query := `UPDATE customers SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`
+	result, err := store.DB.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkAffected(result)
+}
+
+// CustomerColumns are the fields customers can be listed by.
+var CustomerColumns = pagination.Columns{
+	"id":      {Expr: "id", Type: pagination.Int},
+	"name":    {Expr: "name", Type: pagination.Text},
+	"contact": {Expr: "contact", Type: pagination.Text},
+}
+
+// ListCustomers returns a page of customers and the number matching the filters.
+func (store *DBStore) ListCustomers(ctx context.Context, query models.ListQuery) ([]models.Customer, int, error) {
+	customers := []models.Customer{}
+	total, err := pagination.QueryWhere(ctx, store.DB, "id, name, COALESCE(contact, ''), COALESCE(order_history, ''), version", "customers",
+		"deleted_at IS NULL", CustomerColumns, query, func(rows *sql.Rows) error {
+			var customer models.Customer
+			if err := rows.Scan(&customer.ID, &customer.Name, &customer.Contact, &customer.OrderHistory, &customer.Version); err != nil {
+				return err
+			}
+			customers = append(customers, customer)
+			return nil
+		})
+	if err != nil {
+		return nil, 0, err
+	}
+	return customers, total, nil
+}
+
+// CustomersByID returns the customers with the given IDs, read with one query.
+//
+// Parameters:
+//   - ids: The customer IDs.
+//
+// Returns:
+//   - map[int]*models.Customer: The customers found, by ID; deleted customers are left out.
+//   - error: An error if the query fails.
+func (store *DBStore) CustomersByID(ctx context.Context, ids []int) (map[int]*models.Customer, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, name, COALESCE(contact, ''), COALESCE(order_history, ''), version
+		 FROM customers WHERE id = ANY($1) AND deleted_at IS NULL`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	customers, err := scanCustomers(rows)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*models.Customer, len(customers))
+	for i := range customers {
+		byID[customers[i].ID] = &customers[i]
+	}
+	return byID, nil
 }
 
-// DeleteCustomer deletes a customer from the database by their ID.
-func (store *DBStore) DeleteCustomer(id int) error {
-	query := `DELETE FROM customers WHERE id = $1`
-	_, err := store.DB.Exec(query, id)
+// CustomersPage returns limit customers ordered by ID, after skipping offset. Deleted
+// customers are left out.
+func (store *DBStore) CustomersPage(ctx context.Context, limit, offset int) ([]models.Customer, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, name, COALESCE(contact, ''), COALESCE(order_history, ''), version
+		 FROM customers WHERE deleted_at IS NULL ORDER BY id LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanCustomers(rows)
+}
+
+func scanCustomers(rows *sql.Rows) ([]models.Customer, error) {
+	defer rows.Close()
+	customers := []models.Customer{}
+	for rows.Next() {
+		var customer models.Customer
+		if err := rows.Scan(&customer.ID, &customer.Name, &customer.Contact, &customer.OrderHistory, &customer.Version); err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	return customers, rows.Err()
+}
+
+// checkAffected returns models.ErrNotFound when a statement changed no customer.
+func checkAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rowsAffected == 0 {
+		return models.ErrNotFound
+	}
 	return nil
 }
 
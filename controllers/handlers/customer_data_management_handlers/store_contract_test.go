@@ -0,0 +1,23 @@
+package customer_data_management_handlers_test
+
+import (
+	"context"
+	"testing"
+
+	"erp/controllers/handlers/customer_data_management_handlers"
+	"erp/models/db/dbtest"
+	"erp/models/storetest"
+)
+
+// TestMockCustomerStoreContract keeps the handler tests' mock in line with the database store.
+func TestMockCustomerStoreContract(t *testing.T) {
+	storetest.TestCustomerStore(context.Background(), t, NewMockCustomerStore())
+}
+
+// TestDBCustomerStoreContract runs the customer store contract against PostgreSQL. It
+// needs ERP_BENCH_DATABASE_URL; see the dbtest package.
+func TestDBCustomerStoreContract(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(ctx, t)
+	storetest.TestCustomerStore(ctx, t, &customer_data_management_handlers.DBStore{DB: db})
+}
@@ -0,0 +1,502 @@
+// Package delivery_route_handlers provides HTTP handlers and the database store for
+// delivery routes: the day's local shipments grouped per vehicle and driver, and the proof
+// of delivery the driver app records at each stop.
+package delivery_route_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/deliveryroutes"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/rbac"
+	"erp/controllers/respond"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// PermissionChecker reports whether a role grants one of the required permissions,
+// typically the rbac service.
+type PermissionChecker interface {
+	Allowed(ctx context.Context, role string, required ...string) (bool, error)
+}
+
+// DeliveryRouteHandler provides HTTP handlers for delivery routes. Dispatchers plan and
+// send out routes; drivers read their own and record what happened at each stop.
+type DeliveryRouteHandler struct {
+	Service       *deliveryroutes.Service
+	Access        PermissionChecker // Decides who may read and record every driver's routes
+	MaxUploadSize int64             // Largest signature or photo accepted, in bytes
+}
+
+// DispatchPermissions are the permissions that allow planning routes and reading and
+// recording every driver's routes.
+var DispatchPermissions = []string{rbac.Sales}
+
+// RouteRequest is the request body for planning a route.
+type RouteRequest struct {
+	DeliveryDate   string        `json:"delivery_date"` // YYYY-MM-DD
+	Vehicle        string        `json:"vehicle"`
+	DriverEmail    string        `json:"driver_email"`
+	DepotLatitude  *float64      `json:"depot_latitude"` // Where the route starts, optional
+	DepotLongitude *float64      `json:"depot_longitude"`
+	Stops          []StopRequest `json:"stops"`
+}
+
+// DeliveryRoute returns the route described by the request.
+func (req RouteRequest) DeliveryRoute() (*models.DeliveryRoute, error) {
+	route := &models.DeliveryRoute{Vehicle: req.Vehicle, DriverEmail: req.DriverEmail,
+		DepotLatitude: req.DepotLatitude, DepotLongitude: req.DepotLongitude}
+	date, err := time.Parse("2006-01-02", req.DeliveryDate)
+	if err != nil {
+		return route, models.Invalid("delivery_date must be a date formatted as YYYY-MM-DD")
+	}
+	route.DeliveryDate = date
+	for _, stop := range req.Stops {
+		route.Stops = append(route.Stops, stop.DeliveryStop(0))
+	}
+	return route, nil
+}
+
+// StopRequest is a shipment to deliver on a route, with the coordinates of its address if
+// they are known.
+type StopRequest struct {
+	ShipmentID int      `json:"shipment_id"`
+	Latitude   *float64 `json:"latitude"`
+	Longitude  *float64 `json:"longitude"`
+}
+
+// DeliveryStop returns the stop described by the request, on the given route.
+func (req StopRequest) DeliveryStop(routeID int) models.DeliveryStop {
+	return models.DeliveryStop{RouteID: routeID, ShipmentID: req.ShipmentID, Latitude: req.Latitude,
+		Longitude: req.Longitude}
+}
+
+// SequenceRequest is the request body for putting a route's stops in order.
+type SequenceRequest struct {
+	StopIDs []int `json:"stop_ids"` // Every stop of the route, in delivery order
+}
+
+// FailureRequest is the request body for recording a failed delivery.
+type FailureRequest struct {
+	Reason   string `json:"reason"`
+	FailedAt string `json:"failed_at"` // RFC 3339, on the device; now by default
+}
+
+// PlanRoute records a planned route for a vehicle and driver, with its stops in delivery
+// order: nearest first from the depot for stops with coordinates, then the others by
+// postal code.
+//
+// HTTP Method: POST
+// URL Path: /delivery_routes
+//
+// Request Body:
+//   - JSON with delivery_date, vehicle, driver_email, an optional depot_latitude and
+//     depot_longitude, and stops of shipment_id with an optional latitude and longitude
+//     (see RouteRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the DeliveryRoute in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if a shipment is already on a route.
+//   - Status Code: 422 (Unprocessable Entity) if the route is incomplete or a shipment does
+//     not exist, is sent by carrier or is not waiting for delivery.
+//   - Status Code: 500 (Internal Server Error) if the route cannot be recorded.
+func (h *DeliveryRouteHandler) PlanRoute(w http.ResponseWriter, r *http.Request) {
+	var req RouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	route, err := req.DeliveryRoute()
+	if err != nil {
+		httperr.Write(w, err, "Invalid delivery route")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Plan(r.Context(), route, actor); err != nil {
+		httperr.Write(w, err, "Failed to plan delivery route")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, route)
+}
+
+// ListRoutes lists routes with their stops.
+//
+// HTTP Method: GET
+// URL Path: /delivery_routes?date=2026-10-18&driver_email=driver@example.com (both optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of DeliveryRoutes in JSON.
+//   - Status Code: 400 (Bad Request) if date is not a date.
+//   - Status Code: 500 (Internal Server Error) if the routes cannot be loaded.
+func (h *DeliveryRouteHandler) ListRoutes(w http.ResponseWriter, r *http.Request) {
+	date, ok := dateParam(w, r)
+	if !ok {
+		return
+	}
+	routes, err := h.Service.List(r.Context(), date, r.URL.Query().Get("driver_email"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load delivery routes")
+		return
+	}
+	respond.JSON(w, http.StatusOK, routes)
+}
+
+// ListMyRoutes lists the routes the user drives, for the driver app.
+//
+// HTTP Method: GET
+// URL Path: /delivery_routes/mine?date=2026-10-18 (date is optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of DeliveryRoutes in JSON.
+//   - Status Code: 400 (Bad Request) if date is not a date.
+//   - Status Code: 500 (Internal Server Error) if the routes cannot be loaded.
+func (h *DeliveryRouteHandler) ListMyRoutes(w http.ResponseWriter, r *http.Request) {
+	date, ok := dateParam(w, r)
+	if !ok {
+		return
+	}
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	if email == "" {
+		respond.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	routes, err := h.Service.List(r.Context(), date, email)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load delivery routes")
+		return
+	}
+	respond.JSON(w, http.StatusOK, routes)
+}
+
+// ListUnrouted lists the local shipments waiting for a route: pending shipments without a
+// carrier, and those whose delivery failed.
+//
+// HTTP Method: GET
+// URL Path: /delivery_routes/unrouted
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of UnroutedShipments in JSON.
+//   - Status Code: 500 (Internal Server Error) if the shipments cannot be loaded.
+func (h *DeliveryRouteHandler) ListUnrouted(w http.ResponseWriter, r *http.Request) {
+	shipments, err := h.Service.Unrouted(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load unrouted shipments")
+		return
+	}
+	respond.JSON(w, http.StatusOK, shipments)
+}
+
+// GetRoute returns a route with its stops in sequence. Drivers may read their own routes;
+// dispatchers every route.
+//
+// HTTP Method: GET
+// URL Path: /delivery_routes/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the DeliveryRoute in JSON.
+//   - Status Code: 403 (Forbidden) if the route is another driver's.
+//   - Status Code: 404 (Not Found) if the route does not exist.
+//   - Status Code: 500 (Internal Server Error) if the route cannot be loaded.
+func (h *DeliveryRouteHandler) GetRoute(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	email, privileged, err := h.caller(r)
+	if err != nil {
+		httperr.Write(w, err, "Failed to check permissions")
+		return
+	}
+	route, err := h.Service.Route(r.Context(), id, email, privileged)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load delivery route")
+		return
+	}
+	respond.JSON(w, http.StatusOK, route)
+}
+
+// DeleteRoute removes a planned route; its shipments wait for another route.
+//
+// HTTP Method: DELETE
+// URL Path: /delivery_routes/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the route was removed.
+//   - Status Code: 404 (Not Found) if the route does not exist.
+//   - Status Code: 409 (Conflict) if the route has been dispatched.
+//   - Status Code: 500 (Internal Server Error) if the route cannot be removed.
+func (h *DeliveryRouteHandler) DeleteRoute(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete delivery route")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddStop adds a shipment at the end of a planned route.
+//
+// HTTP Method: POST
+// URL Path: /delivery_routes/{id}/stops
+//
+// Request Body:
+//   - JSON with shipment_id and an optional latitude and longitude (see StopRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the DeliveryRoute in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the route does not exist.
+//   - Status Code: 409 (Conflict) if the route has been dispatched or the shipment is on a
+//     route.
+//   - Status Code: 422 (Unprocessable Entity) if the shipment cannot be delivered locally.
+//   - Status Code: 500 (Internal Server Error) if the stop cannot be added.
+func (h *DeliveryRouteHandler) AddStop(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req StopRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	stop := req.DeliveryStop(id)
+	route, err := h.Service.AddStop(r.Context(), &stop)
+	if err != nil {
+		httperr.Write(w, err, "Failed to add delivery stop")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, route)
+}
+
+// RemoveStop takes a shipment off a planned route.
+//
+// HTTP Method: DELETE
+// URL Path: /delivery_routes/{id}/stops/{stop_id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the DeliveryRoute in JSON.
+//   - Status Code: 404 (Not Found) if the route or stop does not exist.
+//   - Status Code: 409 (Conflict) if the route has been dispatched.
+//   - Status Code: 500 (Internal Server Error) if the stop cannot be removed.
+func (h *DeliveryRouteHandler) RemoveStop(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	stopID, _ := strconv.Atoi(mux.Vars(r)["stop_id"])
+	route, err := h.Service.RemoveStop(r.Context(), id, stopID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to remove delivery stop")
+		return
+	}
+	respond.JSON(w, http.StatusOK, route)
+}
+
+// OptimizeRoute puts a planned route's stops back in the computed order.
+//
+// HTTP Method: POST
+// URL Path: /delivery_routes/{id}/optimize
+//
+// Response:
+//   - Status Code: 200 (OK) with the DeliveryRoute in JSON.
+//   - Status Code: 404 (Not Found) if the route does not exist.
+//   - Status Code: 409 (Conflict) if the route has been dispatched.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be saved.
+func (h *DeliveryRouteHandler) OptimizeRoute(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	route, err := h.Service.Optimize(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to sequence delivery route")
+		return
+	}
+	respond.JSON(w, http.StatusOK, route)
+}
+
+// SequenceRoute puts a planned route's stops in the dispatcher's order.
+//
+// HTTP Method: PUT
+// URL Path: /delivery_routes/{id}/sequence
+//
+// Request Body:
+//   - JSON with stop_ids, every stop of the route in delivery order (see SequenceRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with the DeliveryRoute in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the route does not exist.
+//   - Status Code: 409 (Conflict) if the route has been dispatched.
+//   - Status Code: 422 (Unprocessable Entity) if stop_ids does not list each stop once.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be saved.
+func (h *DeliveryRouteHandler) SequenceRoute(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req SequenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	route, err := h.Service.Reorder(r.Context(), id, req.StopIDs)
+	if err != nil {
+		httperr.Write(w, err, "Failed to sequence delivery route")
+		return
+	}
+	respond.JSON(w, http.StatusOK, route)
+}
+
+// DispatchRoute sends a planned route out: its shipments are out for delivery.
+//
+// HTTP Method: POST
+// URL Path: /delivery_routes/{id}/dispatch
+//
+// Response:
+//   - Status Code: 200 (OK) with the DeliveryRoute in JSON.
+//   - Status Code: 404 (Not Found) if the route does not exist.
+//   - Status Code: 409 (Conflict) if the route was already dispatched.
+//   - Status Code: 422 (Unprocessable Entity) if the route has no stops.
+//   - Status Code: 500 (Internal Server Error) if the route cannot be dispatched.
+func (h *DeliveryRouteHandler) DispatchRoute(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	route, err := h.Service.Dispatch(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to dispatch delivery route")
+		return
+	}
+	respond.JSON(w, http.StatusOK, route)
+}
+
+// RecordDelivery records the proof of delivery at a stop of a dispatched route and marks
+// its shipment delivered. Drivers record stops of their own routes; dispatchers any.
+//
+// HTTP Method: POST
+// URL Path: /delivery_routes/{id}/stops/{stop_id}/proof
+//
+// Request Body:
+//   - multipart/form-data with "recipient_name", a PNG or JPEG image in "signature",
+//     "photo" or both, an optional "note" and an optional "delivered_at" (RFC 3339, when
+//     the app recorded it; now by default).
+//
+// Response:
+//   - Status Code: 200 (OK) with the DeliveryStop in JSON.
+//   - Status Code: 400 (Bad Request) if the form is invalid or an image is too large.
+//   - Status Code: 403 (Forbidden) if the route is another driver's.
+//   - Status Code: 404 (Not Found) if the route or stop does not exist.
+//   - Status Code: 409 (Conflict) if the route is not dispatched or the stop was recorded.
+//   - Status Code: 422 (Unprocessable Entity) if the name or images are missing, an image is
+//     not PNG or JPEG, or delivered_at is in the future.
+//   - Status Code: 500 (Internal Server Error) if the proof cannot be stored.
+func (h *DeliveryRouteHandler) RecordDelivery(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	stopID, _ := strconv.Atoi(mux.Vars(r)["stop_id"])
+	email, privileged, err := h.caller(r)
+	if err != nil {
+		httperr.Write(w, err, "Failed to check permissions")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 2*h.MaxUploadSize+1<<20) // Two images and the multipart overhead
+	if err := r.ParseMultipartForm(h.MaxUploadSize); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid multipart form")
+		return
+	}
+	proof := deliveryroutes.Proof{RecipientName: r.FormValue("recipient_name"), Note: r.FormValue("note")}
+	if value := r.FormValue("delivered_at"); value != "" {
+		if proof.DeliveredAt, err = time.Parse(time.RFC3339, value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "delivered_at must be an RFC 3339 time")
+			return
+		}
+	}
+	for field, image := range map[string]*[]byte{"signature": &proof.Signature, "photo": &proof.Photo} {
+		file, _, err := r.FormFile(field)
+		if err == http.ErrMissingFile {
+			continue
+		} else if err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid \""+field+"\" upload")
+			return
+		}
+		*image, err = storage.ReadAll(file, h.MaxUploadSize)
+		file.Close()
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	stop, err := h.Service.Deliver(r.Context(), id, stopID, proof, email, privileged)
+	if err != nil {
+		httperr.Write(w, err, "Failed to record delivery")
+		return
+	}
+	respond.JSON(w, http.StatusOK, stop)
+}
+
+// RecordFailure records that a stop of a dispatched route could not be delivered. Its
+// shipment becomes an exception and waits for another route.
+//
+// HTTP Method: POST
+// URL Path: /delivery_routes/{id}/stops/{stop_id}/failure
+//
+// Request Body:
+//   - JSON with the reason and an optional failed_at (see FailureRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with the DeliveryStop in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 403 (Forbidden) if the route is another driver's.
+//   - Status Code: 404 (Not Found) if the route or stop does not exist.
+//   - Status Code: 409 (Conflict) if the route is not dispatched or the stop was recorded.
+//   - Status Code: 422 (Unprocessable Entity) if the reason is missing or failed_at is in
+//     the future.
+//   - Status Code: 500 (Internal Server Error) if the failure cannot be recorded.
+func (h *DeliveryRouteHandler) RecordFailure(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	stopID, _ := strconv.Atoi(mux.Vars(r)["stop_id"])
+	var req FailureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	var failedAt time.Time
+	if req.FailedAt != "" {
+		var err error
+		if failedAt, err = time.Parse(time.RFC3339, req.FailedAt); err != nil {
+			respond.Error(w, http.StatusBadRequest, "failed_at must be an RFC 3339 time")
+			return
+		}
+	}
+	email, privileged, err := h.caller(r)
+	if err != nil {
+		httperr.Write(w, err, "Failed to check permissions")
+		return
+	}
+	stop, err := h.Service.Fail(r.Context(), id, stopID, req.Reason, failedAt, email, privileged)
+	if err != nil {
+		httperr.Write(w, err, "Failed to record failed delivery")
+		return
+	}
+	respond.JSON(w, http.StatusOK, stop)
+}
+
+// caller returns the user's email and whether their role lets them work with every
+// driver's routes.
+func (h *DeliveryRouteHandler) caller(r *http.Request) (string, bool, error) {
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	if role == "" {
+		return email, false, nil
+	}
+	privileged, err := h.Access.Allowed(r.Context(), role, DispatchPermissions...)
+	return email, privileged, err
+}
+
+// dateParam reads the optional date query parameter, answering 400 if it is not a date.
+func dateParam(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	value := r.URL.Query().Get("date")
+	if value == "" {
+		return time.Time{}, true
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid date")
+		return time.Time{}, false
+	}
+	return date, true
+}
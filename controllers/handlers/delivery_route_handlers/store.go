@@ -0,0 +1,482 @@
+package delivery_route_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBDeliveryRouteStore implements models.DeliveryRouteStore using a SQL database. A stop's
+// address is its shipment's destination; a shipment can be pending on one stop at a time,
+// which a unique index on delivery_stops enforces.
+type DBDeliveryRouteStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// routeColumns are the columns of delivery_routes read by query.
+const routeColumns = `id, delivery_date, vehicle, driver_email, status, depot_latitude, depot_longitude,
+	created_by, created_at, dispatched_at, completed_at`
+
+// CreateDeliveryRoute records a planned route with its stops.
+//
+// Parameters:
+//   - route: A validated route; its ID and the IDs of its stops are set.
+//
+// Returns:
+//   - error: A validation error if a shipment does not exist or is not a local shipment
+//     waiting for delivery, a conflict if one is on another route, or the query error.
+func (s *DBDeliveryRouteStore) CreateDeliveryRoute(ctx context.Context, route *models.DeliveryRoute) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO delivery_routes (delivery_date, vehicle, driver_email, status, depot_latitude, depot_longitude,
+		 created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		route.DeliveryDate, route.Vehicle, route.DriverEmail, route.Status, route.DepotLatitude, route.DepotLongitude,
+		route.CreatedBy, route.CreatedAt,
+	).Scan(&route.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery route: %w", err)
+	}
+	for i := range route.Stops {
+		route.Stops[i].RouteID, route.Stops[i].Sequence = route.ID, i+1
+		if err := insertStop(ctx, tx, &route.Stops[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDeliveryRoute retrieves a route with its stops in sequence.
+//
+// Returns:
+//   - *models.DeliveryRoute: The route.
+//   - error: models.ErrNotFound if it does not exist, or the query error.
+func (s *DBDeliveryRouteStore) GetDeliveryRoute(ctx context.Context, id int) (*models.DeliveryRoute, error) {
+	routes, err := s.query(ctx, `SELECT `+routeColumns+` FROM delivery_routes WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, models.NotFound("delivery route %d not found", id)
+	}
+	return &routes[0], nil
+}
+
+// ListDeliveryRoutes retrieves the routes of a day, of one driver, or both, with their
+// stops. A zero date or an empty email matches every route.
+func (s *DBDeliveryRouteStore) ListDeliveryRoutes(ctx context.Context, date time.Time, driverEmail string) ([]models.DeliveryRoute, error) {
+	var day *time.Time
+	if !date.IsZero() {
+		day = &date
+	}
+	return s.query(ctx, `SELECT `+routeColumns+` FROM delivery_routes
+		WHERE ($1::date IS NULL OR delivery_date = $1) AND ($2 = '' OR lower(driver_email) = lower($2))
+		ORDER BY delivery_date, vehicle, id`, day, driverEmail)
+}
+
+// DeleteDeliveryRoute removes a planned route and its stops, so its shipments can be put on
+// another route.
+//
+// Returns:
+//   - error: models.ErrNotFound if the route does not exist, a conflict if it has been
+//     dispatched, or the query error.
+func (s *DBDeliveryRouteStore) DeleteDeliveryRoute(ctx context.Context, id int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockPlanned(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM delivery_routes WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddDeliveryStop adds a stop at the end of a planned route.
+//
+// Parameters:
+//   - stop: The stop, with RouteID, ShipmentID and the coordinates; its ID and sequence are set.
+//
+// Returns:
+//   - error: models.ErrNotFound if the route does not exist, a conflict if it has been
+//     dispatched or the shipment is on another route, a validation error as for
+//     CreateDeliveryRoute, or the query error.
+func (s *DBDeliveryRouteStore) AddDeliveryStop(ctx context.Context, stop *models.DeliveryStop) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockPlanned(ctx, tx, stop.RouteID); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(sequence), 0) + 1 FROM delivery_stops WHERE route_id = $1`,
+		stop.RouteID).Scan(&stop.Sequence)
+	if err != nil {
+		return err
+	}
+	if err := insertStop(ctx, tx, stop); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveDeliveryStop takes a stop off a planned route; the stops after it move up.
+//
+// Returns:
+//   - error: models.ErrNotFound if the route or the stop does not exist, a conflict if the
+//     route has been dispatched, or the query error.
+func (s *DBDeliveryRouteStore) RemoveDeliveryStop(ctx context.Context, routeID, stopID int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockPlanned(ctx, tx, routeID); err != nil {
+		return err
+	}
+	var sequence int
+	err = tx.QueryRowContext(ctx, `DELETE FROM delivery_stops WHERE id = $1 AND route_id = $2 RETURNING sequence`,
+		stopID, routeID).Scan(&sequence)
+	if err == sql.ErrNoRows {
+		return models.NotFound("stop %d is not on route %d", stopID, routeID)
+	} else if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE delivery_stops SET sequence = sequence - 1 WHERE route_id = $1 AND sequence > $2`,
+		routeID, sequence)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SequenceDeliveryStops numbers a planned route's stops in the order given, from 1.
+//
+// Returns:
+//   - error: models.ErrNotFound if the route does not exist or a stop is not on it, a
+//     conflict if the route has been dispatched, or the query error.
+func (s *DBDeliveryRouteStore) SequenceDeliveryStops(ctx context.Context, routeID int, stopIDs []int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockPlanned(ctx, tx, routeID); err != nil {
+		return err
+	}
+	ids := make(pq.Int64Array, len(stopIDs))
+	for i, id := range stopIDs {
+		ids[i] = int64(id)
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE delivery_stops s SET sequence = o.position
+		 FROM unnest($2::int[]) WITH ORDINALITY AS o(id, position)
+		 WHERE s.id = o.id AND s.route_id = $1`,
+		routeID, ids)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected != int64(len(stopIDs)) {
+		return models.NotFound("a stop is not on route %d", routeID)
+	}
+	return tx.Commit()
+}
+
+// DispatchDeliveryRoute marks a planned route dispatched and its shipments out for
+// delivery, recording a tracking update for each.
+//
+// Returns:
+//   - *models.DeliveryRoute: The dispatched route.
+//   - error: models.ErrNotFound if the route does not exist, a conflict if it was already
+//     dispatched, or the query error.
+func (s *DBDeliveryRouteStore) DispatchDeliveryRoute(ctx context.Context, id int, at time.Time) (*models.DeliveryRoute, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockPlanned(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE delivery_routes SET status = $2, dispatched_at = $3 WHERE id = $1`,
+		id, models.RouteDispatched, at)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE shipments SET status = $2, updated_at = $3
+		 WHERE id IN (SELECT shipment_id FROM delivery_stops WHERE route_id = $1)`,
+		id, models.ShipmentOutForDelivery, at)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO shipment_tracking_events (shipment_id, tracking_number, status, description, location, occurred_at)
+		 SELECT sh.id, COALESCE(sh.tracking_number, ''), $2, $3, '', $4
+		 FROM delivery_stops s JOIN shipments sh ON sh.id = s.shipment_id WHERE s.route_id = $1
+		 ON CONFLICT (shipment_id, status, occurred_at) DO NOTHING`,
+		id, models.ShipmentOutForDelivery, "Out for delivery with the company's driver", at)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetDeliveryRoute(ctx, id)
+}
+
+// CompleteDeliveryStop records a pending stop as delivered or failed. Its shipment becomes
+// delivered or an exception, with a tracking update, and the route is completed once no
+// stop is pending.
+//
+// Parameters:
+//   - stop: The stop, with ID, RouteID, ShipmentID, Status and the proof or reason.
+//
+// Returns:
+//   - error: A conflict if the route is not dispatched or the stop is no longer pending, or
+//     the query error.
+func (s *DBDeliveryRouteStore) CompleteDeliveryStop(ctx context.Context, stop *models.DeliveryStop) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM delivery_routes WHERE id = $1 FOR UPDATE`, stop.RouteID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return models.NotFound("delivery route %d not found", stop.RouteID)
+	} else if err != nil {
+		return err
+	}
+	if status != models.RouteDispatched {
+		return models.Conflict("route %d is %s", stop.RouteID, status)
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE delivery_stops SET status = $3, recipient_name = $4, signature_key = $5, photo_key = $6, note = $7,
+		 completed_at = $8, recorded_by = $9
+		 WHERE id = $1 AND route_id = $2 AND status = $10`,
+		stop.ID, stop.RouteID, stop.Status, stop.RecipientName, stop.SignatureKey, stop.PhotoKey, stop.Note,
+		stop.CompletedAt, stop.RecordedBy, models.StopPending)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return models.Conflict("stop %d is no longer pending", stop.ID)
+	}
+
+	shipmentStatus, description := models.ShipmentDelivered, "Delivered to "+stop.RecipientName
+	if stop.Status == models.StopFailed {
+		shipmentStatus, description = models.ShipmentException, "Delivery failed: "+stop.Note
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE shipments SET status = $2, updated_at = $3 WHERE id = $1`,
+		stop.ShipmentID, shipmentStatus, stop.CompletedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO shipment_tracking_events (shipment_id, tracking_number, status, description, location, occurred_at)
+		 SELECT id, COALESCE(tracking_number, ''), $2, $3, '', $4 FROM shipments WHERE id = $1
+		 ON CONFLICT (shipment_id, status, occurred_at) DO NOTHING`,
+		stop.ShipmentID, shipmentStatus, description, stop.CompletedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE delivery_routes SET status = $2, completed_at = $3
+		 WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM delivery_stops WHERE route_id = $1 AND status = $4)`,
+		stop.RouteID, models.RouteCompleted, stop.CompletedAt, models.StopPending)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UnroutedShipments retrieves the shipments without a carrier that are waiting for
+// delivery, or whose delivery failed, and are not pending on a route.
+func (s *DBDeliveryRouteStore) UnroutedShipments(ctx context.Context) ([]models.UnroutedShipment, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT sh.id, sh.sales_order_id, sh.status, sh.to_address, sh.weight_kg FROM shipments sh
+		 WHERE sh.carrier IS NULL AND sh.status IN ($1, $2)
+		   AND NOT EXISTS (SELECT 1 FROM delivery_stops s WHERE s.shipment_id = sh.id AND s.status = $3)
+		 ORDER BY sh.id`,
+		models.ShipmentPending, models.ShipmentException, models.StopPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shipments := []models.UnroutedShipment{}
+	for rows.Next() {
+		var shipment models.UnroutedShipment
+		var salesOrderID sql.NullInt64
+		var address []byte
+		if err := rows.Scan(&shipment.ShipmentID, &salesOrderID, &shipment.Status, &address, &shipment.WeightKg); err != nil {
+			return nil, err
+		}
+		shipment.SalesOrderID = intPtr(salesOrderID)
+		if err := json.Unmarshal(address, &shipment.Address); err != nil {
+			return nil, err
+		}
+		shipments = append(shipments, shipment)
+	}
+	return shipments, rows.Err()
+}
+
+// query reads routes and then their stops, with each stop's address from its shipment.
+func (s *DBDeliveryRouteStore) query(ctx context.Context, query string, args ...interface{}) ([]models.DeliveryRoute, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	routes := []models.DeliveryRoute{}
+	index := map[int]int{}
+	for rows.Next() {
+		var r models.DeliveryRoute
+		var depotLatitude, depotLongitude sql.NullFloat64
+		var dispatchedAt, completedAt sql.NullTime
+		err := rows.Scan(&r.ID, &r.DeliveryDate, &r.Vehicle, &r.DriverEmail, &r.Status, &depotLatitude,
+			&depotLongitude, &r.CreatedBy, &r.CreatedAt, &dispatchedAt, &completedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.DepotLatitude, r.DepotLongitude = floatPtr(depotLatitude), floatPtr(depotLongitude)
+		r.DispatchedAt, r.CompletedAt = timePtr(dispatchedAt), timePtr(completedAt)
+		r.Stops = []models.DeliveryStop{}
+		index[r.ID] = len(routes)
+		routes = append(routes, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return routes, nil
+	}
+
+	ids := make(pq.Int64Array, 0, len(routes))
+	for _, route := range routes {
+		ids = append(ids, int64(route.ID))
+	}
+	rows, err = s.DB.QueryContext(ctx,
+		`SELECT s.id, s.route_id, s.shipment_id, sh.sales_order_id, s.sequence, sh.to_address, s.latitude, s.longitude,
+		 s.status, s.recipient_name, s.signature_key, s.photo_key, s.note, s.completed_at, s.recorded_by
+		 FROM delivery_stops s JOIN shipments sh ON sh.id = s.shipment_id
+		 WHERE s.route_id = ANY($1) ORDER BY s.route_id, s.sequence, s.id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stop models.DeliveryStop
+		var salesOrderID sql.NullInt64
+		var address []byte
+		var latitude, longitude sql.NullFloat64
+		var completedAt sql.NullTime
+		err := rows.Scan(&stop.ID, &stop.RouteID, &stop.ShipmentID, &salesOrderID, &stop.Sequence, &address,
+			&latitude, &longitude, &stop.Status, &stop.RecipientName, &stop.SignatureKey, &stop.PhotoKey, &stop.Note,
+			&completedAt, &stop.RecordedBy)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(address, &stop.Address); err != nil {
+			return nil, err
+		}
+		stop.SalesOrderID, stop.CompletedAt = intPtr(salesOrderID), timePtr(completedAt)
+		stop.Latitude, stop.Longitude = floatPtr(latitude), floatPtr(longitude)
+		route := &routes[index[stop.RouteID]]
+		route.Stops = append(route.Stops, stop)
+	}
+	return routes, rows.Err()
+}
+
+// lockPlanned locks a route for a change to its stops, which is only allowed while it is
+// planned.
+func lockPlanned(ctx context.Context, tx *sql.Tx, id int) error {
+	var status string
+	err := tx.QueryRowContext(ctx, `SELECT status FROM delivery_routes WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return models.NotFound("delivery route %d not found", id)
+	} else if err != nil {
+		return err
+	}
+	if status != models.RoutePlanned {
+		return models.Conflict("route %d is %s", id, status)
+	}
+	return nil
+}
+
+// insertStop checks that a stop's shipment can be delivered locally and records the stop.
+func insertStop(ctx context.Context, tx *sql.Tx, stop *models.DeliveryStop) error {
+	var status string
+	var local bool
+	err := tx.QueryRowContext(ctx, `SELECT status, carrier IS NULL FROM shipments WHERE id = $1 FOR UPDATE`,
+		stop.ShipmentID).Scan(&status, &local)
+	if err == sql.ErrNoRows {
+		return models.Invalid("shipment %d does not exist", stop.ShipmentID)
+	} else if err != nil {
+		return err
+	}
+	if !local {
+		return models.Invalid("shipment %d is sent by carrier", stop.ShipmentID)
+	}
+	if status != models.ShipmentPending && status != models.ShipmentException {
+		return models.Invalid("shipment %d is %s", stop.ShipmentID, status)
+	}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO delivery_stops (route_id, shipment_id, sequence, latitude, longitude, status)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		stop.RouteID, stop.ShipmentID, stop.Sequence, stop.Latitude, stop.Longitude, stop.Status,
+	).Scan(&stop.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("shipment %d is already on a route", stop.ShipmentID)
+	} else if err != nil {
+		return fmt.Errorf("failed to record delivery stop: %w", err)
+	}
+	return nil
+}
+
+// intPtr returns the integer, or nil if it is NULL.
+func intPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	value := int(n.Int64)
+	return &value
+}
+
+// floatPtr returns the number, or nil if it is NULL.
+func floatPtr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+// timePtr returns the time, or nil if it is NULL.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
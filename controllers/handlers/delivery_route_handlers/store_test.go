@@ -0,0 +1,129 @@
+package delivery_route_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddDeliveryStopRefusesCarrierAndRoutedShipments(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBDeliveryRouteStore{DB: db}
+
+	// A shipment with a label is delivered by its carrier
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status FROM delivery_routes WHERE id = $1 FOR UPDATE")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.RoutePlanned))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(sequence), 0) + 1")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"sequence"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status, carrier IS NULL FROM shipments")).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "local"}).AddRow(models.ShipmentLabelPurchased, false))
+	mock.ExpectRollback()
+	err = store.AddDeliveryStop(ctx, &models.DeliveryStop{RouteID: 2, ShipmentID: 7, Status: models.StopPending})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Contains(t, err.Error(), "sent by carrier")
+
+	// A shipment pending on another route trips the unique index
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status FROM delivery_routes WHERE id = $1 FOR UPDATE")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.RoutePlanned))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(sequence), 0) + 1")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"sequence"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status, carrier IS NULL FROM shipments")).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "local"}).AddRow(models.ShipmentPending, true))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO delivery_stops")).WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+	err = store.AddDeliveryStop(ctx, &models.DeliveryStop{RouteID: 2, ShipmentID: 8, Status: models.StopPending})
+	assert.ErrorIs(t, err, models.ErrConflict)
+
+	// Stops cannot be added once the driver has left
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status FROM delivery_routes WHERE id = $1 FOR UPDATE")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.RouteDispatched))
+	mock.ExpectRollback()
+	err = store.AddDeliveryStop(ctx, &models.DeliveryStop{RouteID: 2, ShipmentID: 8, Status: models.StopPending})
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompleteDeliveryStopUpdatesShipmentAndRoute(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBDeliveryRouteStore{DB: db}
+	deliveredAt := time.Date(2026, 10, 17, 13, 40, 0, 0, time.UTC)
+	stop := &models.DeliveryStop{ID: 10, RouteID: 2, ShipmentID: 5, Status: models.StopDelivered,
+		RecipientName: "Rahim", SignatureKey: "deliveries/2/10-signature-ab.png", CompletedAt: &deliveredAt,
+		RecordedBy: "driver@example.com"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status FROM delivery_routes WHERE id = $1 FOR UPDATE")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.RouteDispatched))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE delivery_stops SET status = $3")).
+		WithArgs(10, 2, models.StopDelivered, "Rahim", stop.SignatureKey, "", "", stop.CompletedAt, "driver@example.com",
+			models.StopPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE shipments SET status = $2")).WithArgs(5, models.ShipmentDelivered, stop.CompletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO shipment_tracking_events")).
+		WithArgs(5, models.ShipmentDelivered, "Delivered to Rahim", stop.CompletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE delivery_routes SET status = $2, completed_at = $3")).
+		WithArgs(2, models.RouteCompleted, stop.CompletedAt, models.StopPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, store.CompleteDeliveryStop(ctx, stop))
+
+	// A stop recorded twice, as when the app retries, is a conflict
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status FROM delivery_routes WHERE id = $1 FOR UPDATE")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.RouteDispatched))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE delivery_stops SET status = $3")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.CompleteDeliveryStop(ctx, stop), models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDeliveryRouteReadsStopsWithAddresses(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBDeliveryRouteStore{DB: db}
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM delivery_routes WHERE id = $1")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "delivery_date", "vehicle", "driver_email", "status",
+			"depot_latitude", "depot_longitude", "created_by", "created_at", "dispatched_at", "completed_at"}).
+			AddRow(2, day, "Van 2", "driver@example.com", models.RoutePlanned, 23.73, 90.38, "dispatch@example.com",
+				day, nil, nil))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM delivery_stops s JOIN shipments sh")).WithArgs(pq.Int64Array{2}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "route_id", "shipment_id", "sales_order_id", "sequence",
+			"to_address", "latitude", "longitude", "status", "recipient_name", "signature_key", "photo_key", "note",
+			"completed_at", "recorded_by"}).
+			AddRow(10, 2, 5, 31, 1, []byte(`{"line1":"Road 2","postal_code":"1205"}`), 23.75, 90.39,
+				models.StopPending, "", "", "", "", nil, "").
+			AddRow(11, 2, 6, nil, 2, []byte(`{"line1":"Road 9","postal_code":"1212"}`), nil, nil,
+				models.StopPending, "", "", "", "", nil, ""))
+
+	route, err := store.GetDeliveryRoute(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, 23.73, *route.DepotLatitude)
+	assert.Nil(t, route.DispatchedAt)
+	require.Len(t, route.Stops, 2)
+	assert.Equal(t, 31, *route.Stops[0].SalesOrderID)
+	assert.Equal(t, "1205", route.Stops[0].Address.PostalCode)
+	assert.Nil(t, route.Stops[1].Latitude)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
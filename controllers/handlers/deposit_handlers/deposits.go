@@ -0,0 +1,143 @@
+// Package deposit_handlers provides HTTP handlers and the database store for deposits
+// customers pay on sales orders before they are invoiced.
+package deposit_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/deposits"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// DepositHandler provides HTTP handlers for customer deposits.
+type DepositHandler struct {
+	Service *deposits.Service
+}
+
+// RegisterRoutes maps customer deposit routes to their respective handler functions. The
+// router is expected to be protected with middleware.JWTAuth and limited to the roles
+// handling customer payments.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The customer deposit service.
+func RegisterRoutes(router *mux.Router, service *deposits.Service) {
+	handler := &DepositHandler{Service: service}
+
+	router.HandleFunc("", handler.ListDeposits).Methods("GET")
+	router.HandleFunc("", handler.ReceiveDeposit).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetDeposit).Methods("GET")
+	// Refunds pay cash out, so they are limited to finance
+	refund := middleware.RequireRole("Admin", "Accountant")(http.HandlerFunc(handler.RefundOrder))
+	router.Handle("/orders/{sales_order_id:[0-9]+}/refund", refund).Methods("POST")
+}
+
+// ReceiveDeposit records a deposit a customer paid against a sales order. It is held as a
+// liability until the order's invoice is posted.
+//
+// HTTP Method: POST
+// URL Path: /deposits
+//
+// Request Body:
+//   - JSON object with "sales_order_id", "amount", and optionally "received_on", "method"
+//     and "reference".
+//
+// Response:
+//   - Status Code: 201 (Created) with the CustomerDeposit in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the sales order does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the sales order or amount is missing.
+//   - Status Code: 500 (Internal Server Error) if the deposit cannot be recorded.
+func (h *DepositHandler) ReceiveDeposit(w http.ResponseWriter, r *http.Request) {
+	var deposit models.CustomerDeposit
+	if err := json.NewDecoder(r.Body).Decode(&deposit); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Receive(r.Context(), &deposit, actor); err != nil {
+		httperr.Write(w, err, "Failed to record deposit")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, deposit)
+}
+
+// ListDeposits lists deposits, oldest first.
+//
+// HTTP Method: GET
+// URL Path: /deposits?sales_order_id=12&unapplied=true
+// (both are optional; unapplied leaves out deposits fully applied or refunded)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of CustomerDeposits in JSON.
+//   - Status Code: 500 (Internal Server Error) if the deposits cannot be read.
+func (h *DepositHandler) ListDeposits(w http.ResponseWriter, r *http.Request) {
+	salesOrderID, _ := strconv.Atoi(r.URL.Query().Get("sales_order_id"))
+	unapplied, _ := strconv.ParseBool(r.URL.Query().Get("unapplied"))
+	list, err := h.Service.Deposits(r.Context(), salesOrderID, unapplied)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load deposits")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// GetDeposit returns a deposit.
+//
+// HTTP Method: GET
+// URL Path: /deposits/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the CustomerDeposit in JSON.
+//   - Status Code: 404 (Not Found) if the deposit does not exist.
+//   - Status Code: 500 (Internal Server Error) if the deposit cannot be read.
+func (h *DepositHandler) GetDeposit(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	deposit, err := h.Service.Deposit(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load deposit")
+		return
+	}
+	respond.JSON(w, http.StatusOK, deposit)
+}
+
+// RefundOrder refunds what is left of a cancelled sales order's deposits. The refunds are
+// recorded in the audit log with the reason.
+//
+// HTTP Method: POST
+// URL Path: /deposits/orders/{sales_order_id}/refund (admins and accountants)
+//
+// Request Body:
+//   - JSON object with "reason".
+//
+// Response:
+//   - Status Code: 200 (OK) with the list of refunded CustomerDeposits in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 403 (Forbidden) if the user is not an admin or accountant.
+//   - Status Code: 409 (Conflict) if the order has no deposit left to refund.
+//   - Status Code: 422 (Unprocessable Entity) if the reason is missing.
+//   - Status Code: 500 (Internal Server Error) if the refund cannot be recorded.
+func (h *DepositHandler) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	salesOrderID, _ := strconv.Atoi(mux.Vars(r)["sales_order_id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	refunded, err := h.Service.RefundOrder(r.Context(), salesOrderID, body.Reason, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to refund deposits")
+		return
+	}
+	respond.JSON(w, http.StatusOK, refunded)
+}
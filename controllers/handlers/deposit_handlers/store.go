@@ -0,0 +1,262 @@
+package deposit_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/controllers/audit"
+	"erp/controllers/deposits"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+)
+
+// DBCustomerDepositStore provides SQL-backed methods for customer deposits.
+type DBCustomerDepositStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// depositColumns are the columns read by queryDeposits, in scan order.
+const depositColumns = `id, sales_order_id, customer_id, amount, applied, refunded, received_on, method, reference,
+	refund_reason, refunded_at, created_by, created_at`
+
+// CreateCustomerDeposit records a deposit in one transaction: the customer is taken from
+// the sales order, cash is debited and the deposit liability credited.
+//
+// Parameters:
+//   - deposit: The validated deposit; its ID and customer are set.
+//
+// Returns:
+//   - error: models.ErrNotFound if the sales order does not exist, or an error if the
+//     deposit cannot be recorded or posted.
+func (store *DBCustomerDepositStore) CreateCustomerDeposit(ctx context.Context, deposit *models.CustomerDeposit) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var customerID sql.NullInt64
+	err = tx.QueryRowContext(ctx, `SELECT customer_id FROM sales_orders WHERE id = $1`, deposit.SalesOrderID).Scan(&customerID)
+	if err == sql.ErrNoRows {
+		return models.NotFound("sales order %d not found", deposit.SalesOrderID)
+	}
+	if err != nil {
+		return err
+	}
+	deposit.CustomerID = int(customerID.Int64)
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO customer_deposits (sales_order_id, customer_id, amount, received_on, method, reference, created_by, created_at)
+		 VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8) RETURNING id`,
+		deposit.SalesOrderID, deposit.CustomerID, deposit.Amount, deposit.ReceivedOn, deposit.Method, deposit.Reference,
+		deposit.CreatedBy, deposit.CreatedAt,
+	).Scan(&deposit.ID)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Deposit %d on sales order %d", deposit.ID, deposit.SalesOrderID)
+	err = post(ctx, tx, deposit.ReceivedOn, description, deposit.Amount, deposits.CashAccount, deposits.DepositAccount, nil)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCustomerDeposit retrieves a deposit by its ID.
+//
+// Returns:
+//   - *models.CustomerDeposit: The deposit.
+//   - error: models.ErrNotFound if the deposit does not exist, or an error if the query fails.
+func (store *DBCustomerDepositStore) GetCustomerDeposit(ctx context.Context, id int) (*models.CustomerDeposit, error) {
+	list, err := queryDeposits(ctx, store.DB, `WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, models.NotFound("deposit %d not found", id)
+	}
+	return &list[0], nil
+}
+
+// GetCustomerDeposits lists deposits in the order they are applied: oldest receipt first.
+//
+// Parameters:
+//   - salesOrderID: The sales order, or 0 for every order.
+//   - unappliedOnly: Whether to leave out deposits fully applied or refunded.
+//
+// Returns:
+//   - []models.CustomerDeposit: The deposits.
+//   - error: An error if the query fails.
+func (store *DBCustomerDepositStore) GetCustomerDeposits(ctx context.Context, salesOrderID int, unappliedOnly bool) ([]models.CustomerDeposit, error) {
+	return queryDeposits(ctx, store.DB,
+		`WHERE ($1 = 0 OR sales_order_id = $1) AND (NOT $2 OR applied + refunded < amount) ORDER BY received_on, id`,
+		salesOrderID, unappliedOnly)
+}
+
+// RefundCustomerDeposits refunds the unapplied part of a sales order's deposits in one
+// transaction. Each refund debits the deposit liability, credits cash and is recorded in
+// the audit log with the reason.
+//
+// Parameters:
+//   - salesOrderID: The cancelled sales order.
+//   - reason: Why the deposits are refunded.
+//   - actor: Email of the user refunding.
+//   - at: When the refund is made.
+//
+// Returns:
+//   - []models.CustomerDeposit: The deposits refunded.
+//   - error: A models.Conflict error if the order has no unapplied deposits, or an error if
+//     the refunds cannot be recorded or posted.
+func (store *DBCustomerDepositStore) RefundCustomerDeposits(ctx context.Context, salesOrderID int, reason, actor string, at time.Time) ([]models.CustomerDeposit, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	list, err := queryDeposits(ctx, tx,
+		`WHERE sales_order_id = $1 AND applied + refunded < amount ORDER BY received_on, id FOR UPDATE`, salesOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, models.Conflict("sales order %d has no deposit left to refund", salesOrderID)
+	}
+
+	for i := range list {
+		deposit := &list[i]
+		refund := roundCents(deposit.Unapplied())
+		deposit.Refunded = roundCents(deposit.Refunded + refund)
+		deposit.RefundReason, deposit.RefundedAt = reason, &at
+		_, err := tx.ExecContext(ctx,
+			`UPDATE customer_deposits SET refunded = $1, refund_reason = $2, refunded_at = $3 WHERE id = $4`,
+			deposit.Refunded, reason, at, deposit.ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		description := fmt.Sprintf("Refund of deposit %d on sales order %d", deposit.ID, salesOrderID)
+		if err := post(ctx, tx, at, description, refund, deposits.DepositAccount, deposits.CashAccount, nil); err != nil {
+			return nil, err
+		}
+		details, _ := json.Marshal(map[string]interface{}{"sales_order_id": salesOrderID, "amount": refund})
+		entry := &models.AuditEntry{
+			Actor:      actor,
+			Action:     audit.ActionDepositRefund,
+			EntityType: "customer_deposit",
+			EntityID:   deposit.ID,
+			Reason:     reason,
+			Details:    details,
+			CreatedAt:  at,
+		}
+		if err := audit.Record(ctx, tx, entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ApplyDeposits applies the unapplied deposits of an invoice's sales order to the invoice,
+// oldest first, within the caller's transaction. The amount applied is moved from the
+// deposit liability to the receivable. It is called when the invoice is posted.
+//
+// Parameters:
+//   - tx: The transaction posting the invoice.
+//   - invoice: The invoice being posted; its DepositApplied is set.
+//   - date: The posting date.
+//
+// Returns:
+//   - error: An error if the deposits cannot be read, updated or posted.
+func ApplyDeposits(ctx context.Context, tx *sql.Tx, invoice *models.Invoice, date time.Time) error {
+	invoice.DepositApplied = 0
+	if invoice.SalesOrderID == 0 {
+		return nil
+	}
+	list, err := queryDeposits(ctx, tx,
+		`WHERE sales_order_id = $1 AND applied + refunded < amount ORDER BY received_on, id FOR UPDATE`,
+		invoice.SalesOrderID)
+	if err != nil {
+		return err
+	}
+	applied, total := deposits.Apply(list, invoice.Amount)
+	for i, amount := range applied {
+		if amount <= 0 {
+			continue
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO customer_deposit_applications (deposit_id, invoice_id, amount, applied_at) VALUES ($1, $2, $3, $4)`,
+			list[i].ID, invoice.ID, amount, date,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE customer_deposits SET applied = applied + $1 WHERE id = $2`, amount, list[i].ID)
+		if err != nil {
+			return err
+		}
+	}
+	if total > 0 {
+		description := fmt.Sprintf("Deposits applied to invoice #%d", invoice.ID)
+		err := post(ctx, tx, date, description, total, deposits.DepositAccount, deposits.ReceivableAccount, &invoice.ID)
+		if err != nil {
+			return err
+		}
+	}
+	invoice.DepositApplied = total
+	return nil
+}
+
+// queryDeposits reads the deposits matching a WHERE clause and its arguments.
+func queryDeposits(ctx context.Context, q models.Queryer, where string, args ...interface{}) ([]models.CustomerDeposit, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+depositColumns+` FROM customer_deposits `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.CustomerDeposit{}
+	for rows.Next() {
+		var deposit models.CustomerDeposit
+		var customerID sql.NullInt64
+		var refundedAt sql.NullTime
+		if err := rows.Scan(&deposit.ID, &deposit.SalesOrderID, &customerID, &deposit.Amount, &deposit.Applied,
+			&deposit.Refunded, &deposit.ReceivedOn, &deposit.Method, &deposit.Reference, &deposit.RefundReason,
+			&refundedAt, &deposit.CreatedBy, &deposit.CreatedAt); err != nil {
+			return nil, err
+		}
+		deposit.CustomerID = int(customerID.Int64)
+		if refundedAt.Valid {
+			deposit.RefundedAt = &refundedAt.Time
+		}
+		list = append(list, deposit)
+	}
+	return list, rows.Err()
+}
+
+// post debits one account and credits another with amount, linking the lines to an
+// invoice when one is given.
+func post(ctx context.Context, tx *sql.Tx, date time.Time, description string, amount float64, debit, credit string, invoiceID *int) error {
+	for _, transaction := range []models.FinancialTransaction{
+		{AccountType: debit, Amount: amount, TransactionDate: date, Description: description, InvoiceID: invoiceID},
+		{AccountType: credit, Amount: -amount, TransactionDate: date, Description: description, InvoiceID: invoiceID},
+	} {
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
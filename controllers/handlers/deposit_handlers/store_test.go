@@ -0,0 +1,74 @@
+package deposit_handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var depositRow = []string{"id", "sales_order_id", "customer_id", "amount", "applied", "refunded", "received_on", "method",
+	"reference", "refund_reason", "refunded_at", "created_by", "created_at"}
+
+// TestApplyDeposits verifies that the deposits of the order are applied to the invoice and
+// the amount moved from the deposit liability to the receivable.
+func TestApplyDeposits(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM customer_deposits WHERE sales_order_id = \\$1 .* FOR UPDATE").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(depositRow).
+			AddRow(1, 4, 9, 100.0, 0.0, 0.0, day, "", "", "", nil, "sales@example.com", day).
+			AddRow(2, 4, 9, 300.0, 0.0, 0.0, day, "", "", "", nil, "sales@example.com", day))
+	for _, deposit := range []struct {
+		id     int
+		amount float64
+	}{{1, 100}, {2, 150}} {
+		mock.ExpectExec("INSERT INTO customer_deposit_applications").WithArgs(deposit.id, 7, deposit.amount, day).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE customer_deposits SET applied").WithArgs(deposit.amount, deposit.id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	for _, line := range []struct {
+		account string
+		amount  float64
+	}{{"customer_deposits", 250}, {"accounts_receivable", -250}} {
+		mock.ExpectQuery("INSERT INTO financial_transactions").
+			WithArgs(line.account, line.amount, day, "Deposits applied to invoice #7", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO account_period_balances").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	invoice := &models.Invoice{ID: 7, SalesOrderID: 4, Amount: 250}
+	require.NoError(t, ApplyDeposits(ctx, tx, invoice, day))
+	assert.Equal(t, 250.0, invoice.DepositApplied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestRefundWithoutDeposits verifies that an order with nothing left to refund is a conflict.
+func TestRefundWithoutDeposits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBCustomerDepositStore{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM customer_deposits").WithArgs(4).WillReturnRows(sqlmock.NewRows(depositRow))
+	mock.ExpectRollback()
+
+	_, err = store.RefundCustomerDeposits(context.Background(), 4, "Order cancelled", "finance@example.com", time.Now())
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
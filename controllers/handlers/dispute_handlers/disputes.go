@@ -0,0 +1,204 @@
+// Package dispute_handlers provides HTTP handlers and the database store for customers'
+// disputes of invoice charges and the credit notes issued to resolve them.
+package dispute_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/disputes"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// DisputeHandler provides HTTP handlers for invoice disputes.
+type DisputeHandler struct {
+	Service *disputes.Service
+}
+
+// ResolutionRequest is the request body for resolving a dispute.
+type ResolutionRequest struct {
+	Resolution string  `json:"resolution"`
+	Amount     float64 `json:"amount"` // Amount allowed; the whole disputed amount if 0
+	Note       string  `json:"note"`
+}
+
+// RegisterRoutes maps dispute routes to their respective handler functions. The router is
+// expected to be protected with middleware.JWTAuth and limited to accountants and admins.
+// Disputes are raised on the invoice routes with RaiseDispute and ListInvoiceDisputes.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The invoice dispute service.
+func RegisterRoutes(router *mux.Router, service *disputes.Service) {
+	handler := &DisputeHandler{Service: service}
+
+	router.HandleFunc("", handler.ListDisputes).Methods("GET")
+	router.HandleFunc("/aging", handler.Aging).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetDispute).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/resolution", handler.ResolveDispute).Methods("POST")
+	router.HandleFunc("/credit_notes/{id:[0-9]+}", handler.GetCreditNote).Methods("GET")
+}
+
+// RaiseDispute opens a dispute on a posted invoice. Late fees and installment reminders for
+// the invoice are suspended until it is resolved.
+//
+// HTTP Method: POST
+// URL Path: /invoices/{id}/disputes
+//
+// Request Body:
+//   - JSON object with "reason" and "amount", the amount disputed.
+//
+// Response:
+//   - Status Code: 201 (Created) with the InvoiceDispute in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the invoice does not exist.
+//   - Status Code: 409 (Conflict) if the invoice is not posted or already disputed.
+//   - Status Code: 422 (Unprocessable Entity) if the reason is missing or the amount is
+//     not positive or exceeds what is owed.
+//   - Status Code: 500 (Internal Server Error) if the dispute cannot be saved.
+func (h *DisputeHandler) RaiseDispute(w http.ResponseWriter, r *http.Request) {
+	var dispute models.InvoiceDispute
+	if err := json.NewDecoder(r.Body).Decode(&dispute); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	dispute.InvoiceID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Raise(r.Context(), &dispute, actor); err != nil {
+		httperr.Write(w, err, "Failed to raise dispute")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, dispute)
+}
+
+// ListInvoiceDisputes lists the disputes of an invoice, newest first.
+//
+// HTTP Method: GET
+// URL Path: /invoices/{id}/disputes
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of InvoiceDisputes in JSON.
+//   - Status Code: 500 (Internal Server Error) if the disputes cannot be read.
+func (h *DisputeHandler) ListInvoiceDisputes(w http.ResponseWriter, r *http.Request) {
+	invoiceID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	list, err := h.Service.Disputes(r.Context(), invoiceID, "")
+	if err != nil {
+		httperr.Write(w, err, "Failed to load disputes")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// ListDisputes lists disputes, newest first.
+//
+// HTTP Method: GET
+// URL Path: /disputes?status=open&invoice_id=7 (both are optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of InvoiceDisputes in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the disputes cannot be read.
+func (h *DisputeHandler) ListDisputes(w http.ResponseWriter, r *http.Request) {
+	invoiceID, _ := strconv.Atoi(r.URL.Query().Get("invoice_id"))
+	list, err := h.Service.Disputes(r.Context(), invoiceID, r.URL.Query().Get("status"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load disputes")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// GetDispute returns a dispute.
+//
+// HTTP Method: GET
+// URL Path: /disputes/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the InvoiceDispute in JSON.
+//   - Status Code: 404 (Not Found) if the dispute does not exist.
+//   - Status Code: 500 (Internal Server Error) if the dispute cannot be read.
+func (h *DisputeHandler) GetDispute(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	dispute, err := h.Service.Dispute(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load dispute")
+		return
+	}
+	respond.JSON(w, http.StatusOK, dispute)
+}
+
+// ResolveDispute closes an open dispute by issuing a credit note, adjusting the receivable
+// or upholding the charge. Dunning of the invoice resumes for what is still owed.
+//
+// HTTP Method: POST
+// URL Path: /disputes/{id}/resolution
+//
+// Request Body:
+//   - JSON object with "resolution" ("credit_note", "adjustment" or "uphold"), "note" and,
+//     for credit notes and adjustments, optionally "amount" (the whole disputed amount by
+//     default).
+//
+// Response:
+//   - Status Code: 200 (OK) with the resolved InvoiceDispute in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the dispute does not exist.
+//   - Status Code: 409 (Conflict) if the dispute is resolved already.
+//   - Status Code: 422 (Unprocessable Entity) if the resolution, amount or note is invalid.
+//   - Status Code: 500 (Internal Server Error) if the resolution cannot be recorded.
+func (h *DisputeHandler) ResolveDispute(w http.ResponseWriter, r *http.Request) {
+	var req ResolutionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	dispute, err := h.Service.Resolve(r.Context(), id, req.Resolution, req.Amount, req.Note, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to resolve dispute")
+		return
+	}
+	respond.JSON(w, http.StatusOK, dispute)
+}
+
+// Aging reports the open disputes by the days since they were raised.
+//
+// HTTP Method: GET
+// URL Path: /disputes/aging
+//
+// Response:
+//   - Status Code: 200 (OK) with the DisputeAging report in JSON.
+//   - Status Code: 500 (Internal Server Error) if the disputes cannot be read.
+func (h *DisputeHandler) Aging(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.Aging(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load dispute aging")
+		return
+	}
+	respond.JSON(w, http.StatusOK, report)
+}
+
+// GetCreditNote returns a credit note issued to resolve a dispute.
+//
+// HTTP Method: GET
+// URL Path: /disputes/credit_notes/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the CreditNote in JSON.
+//   - Status Code: 404 (Not Found) if the credit note does not exist.
+//   - Status Code: 500 (Internal Server Error) if the credit note cannot be read.
+func (h *DisputeHandler) GetCreditNote(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	note, err := h.Service.CreditNote(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load credit note")
+		return
+	}
+	respond.JSON(w, http.StatusOK, note)
+}
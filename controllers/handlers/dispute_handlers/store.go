@@ -0,0 +1,243 @@
+package dispute_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"erp/controllers/audit"
+	"erp/controllers/disputes"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+)
+
+// DBDisputeStore provides SQL-backed methods for invoice disputes and credit notes.
+type DBDisputeStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// disputeColumns are the columns read by queryDisputes, in scan order.
+const disputeColumns = `id, invoice_id, COALESCE(customer_id, 0), reason, amount, status, COALESCE(resolution, ''),
+	resolved_amount, COALESCE(resolution_note, ''), credit_note_id, raised_by, raised_at, COALESCE(resolved_by, ''),
+	resolved_at`
+
+// GetInvoiceBalance reads what has been invoiced and settled on an invoice: its payments,
+// the deposits applied to it and the amounts allowed on its disputes.
+//
+// Returns:
+//   - *models.InvoiceBalance: The balance.
+//   - error: models.ErrNotFound if the invoice does not exist, or an error if the query fails.
+func (store *DBDisputeStore) GetInvoiceBalance(ctx context.Context, invoiceID int) (*models.InvoiceBalance, error) {
+	balance := models.InvoiceBalance{InvoiceID: invoiceID}
+	var customerID sql.NullInt64
+	var status sql.NullString
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT i.customer_id, i.status, i.amount,
+		     (SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = i.id AND deleted_at IS NULL) +
+		     (SELECT COALESCE(SUM(amount), 0) FROM customer_deposit_applications WHERE invoice_id = i.id) +
+		     (SELECT COALESCE(SUM(resolved_amount), 0) FROM invoice_disputes WHERE invoice_id = i.id)
+		 FROM invoices i WHERE i.id = $1`, invoiceID,
+	).Scan(&customerID, &status, &balance.Amount, &balance.Settled)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("invoice %d not found", invoiceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	balance.CustomerID, balance.Status = int(customerID.Int64), status.String
+	return &balance, nil
+}
+
+// CreateDispute records an open dispute.
+//
+// Parameters:
+//   - dispute: The validated dispute; its ID is set.
+//
+// Returns:
+//   - error: A models.Conflict error if the invoice already has an open dispute, or an error
+//     if the insert fails.
+func (store *DBDisputeStore) CreateDispute(ctx context.Context, dispute *models.InvoiceDispute) error {
+	err := store.DB.QueryRowContext(ctx,
+		`INSERT INTO invoice_disputes (invoice_id, customer_id, reason, amount, status, raised_by, raised_at)
+		 VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7) RETURNING id`,
+		dispute.InvoiceID, dispute.CustomerID, dispute.Reason, dispute.Amount, dispute.Status, dispute.RaisedBy,
+		dispute.RaisedAt,
+	).Scan(&dispute.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("invoice %d already has an open dispute", dispute.InvoiceID)
+	}
+	return err
+}
+
+// GetDispute retrieves a dispute by its ID.
+//
+// Returns:
+//   - *models.InvoiceDispute: The dispute.
+//   - error: models.ErrNotFound if the dispute does not exist, or an error if the query fails.
+func (store *DBDisputeStore) GetDispute(ctx context.Context, id int) (*models.InvoiceDispute, error) {
+	list, err := queryDisputes(ctx, store.DB, `WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, models.NotFound("dispute %d not found", id)
+	}
+	return &list[0], nil
+}
+
+// GetDisputes lists disputes, newest first.
+//
+// Parameters:
+//   - invoiceID: The invoice disputed, or 0 for every invoice.
+//   - status: models.DisputeOpen or models.DisputeResolved, or "" for both.
+//
+// Returns:
+//   - []models.InvoiceDispute: The disputes.
+//   - error: An error if the query fails.
+func (store *DBDisputeStore) GetDisputes(ctx context.Context, invoiceID int, status string) ([]models.InvoiceDispute, error) {
+	return queryDisputes(ctx, store.DB,
+		`WHERE ($1 = 0 OR invoice_id = $1) AND ($2 = '' OR status = $2) ORDER BY raised_at DESC, id DESC`,
+		invoiceID, status)
+}
+
+// ResolveDispute records the resolution of a dispute in one transaction. For a credit note,
+// the note is issued and sales allowances debited; for an adjustment, receivable
+// adjustments are debited; either way the receivable is credited with the amount allowed.
+// The resolution is recorded in the audit log with its note.
+//
+// Parameters:
+//   - dispute: The dispute with its resolution; its credit note is set if one is issued.
+//
+// Returns:
+//   - error: models.ErrNotFound if the dispute does not exist, a models.Conflict error if it
+//     is not open, or an error if the resolution cannot be recorded or posted.
+func (store *DBDisputeStore) ResolveDispute(ctx context.Context, dispute *models.InvoiceDispute) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM invoice_disputes WHERE id = $1 FOR UPDATE`, dispute.ID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return models.NotFound("dispute %d not found", dispute.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.DisputeOpen {
+		return models.Conflict("dispute %d is resolved already", dispute.ID)
+	}
+
+	account := disputes.AdjustmentAccount
+	description := fmt.Sprintf("Adjustment for dispute %d on invoice #%d", dispute.ID, dispute.InvoiceID)
+	if dispute.Resolution == models.ResolutionCreditNote {
+		var id int
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO credit_notes (invoice_id, customer_id, dispute_id, amount, reason, issued_by, issued_at)
+			 VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7) RETURNING id`,
+			dispute.InvoiceID, dispute.CustomerID, dispute.ID, dispute.ResolvedAmount, dispute.ResolutionNote,
+			dispute.ResolvedBy, *dispute.ResolvedAt,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		dispute.CreditNoteID = &id
+		account = disputes.CreditNoteAccount
+		description = fmt.Sprintf("Credit note %d on invoice #%d", id, dispute.InvoiceID)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE invoice_disputes SET status = $1, resolution = $2, resolved_amount = $3, resolution_note = $4,
+		     credit_note_id = $5, resolved_by = $6, resolved_at = $7
+		 WHERE id = $8`,
+		dispute.Status, dispute.Resolution, dispute.ResolvedAmount, dispute.ResolutionNote, dispute.CreditNoteID,
+		dispute.ResolvedBy, *dispute.ResolvedAt, dispute.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if dispute.ResolvedAmount > 0 {
+		for _, transaction := range []models.FinancialTransaction{
+			{AccountType: account, Amount: dispute.ResolvedAmount},
+			{AccountType: disputes.ReceivableAccount, Amount: -dispute.ResolvedAmount},
+		} {
+			transaction.TransactionDate, transaction.Description = *dispute.ResolvedAt, description
+			transaction.InvoiceID = &dispute.InvoiceID
+			if err := general_ledger_handlers.InsertTransaction(ctx, tx, &transaction); err != nil {
+				return err
+			}
+		}
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"invoice_id": dispute.InvoiceID, "resolution": dispute.Resolution, "amount": dispute.ResolvedAmount,
+	})
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      dispute.ResolvedBy,
+		Action:     audit.ActionDisputeResolve,
+		EntityType: "invoice_dispute",
+		EntityID:   dispute.ID,
+		Reason:     dispute.ResolutionNote,
+		Details:    details,
+		CreatedAt:  *dispute.ResolvedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCreditNote retrieves a credit note by its ID.
+//
+// Returns:
+//   - *models.CreditNote: The credit note.
+//   - error: models.ErrNotFound if the credit note does not exist, or an error if the query fails.
+func (store *DBDisputeStore) GetCreditNote(ctx context.Context, id int) (*models.CreditNote, error) {
+	var note models.CreditNote
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT id, invoice_id, COALESCE(customer_id, 0), dispute_id, amount, reason, issued_by, issued_at
+		 FROM credit_notes WHERE id = $1`, id,
+	).Scan(&note.ID, &note.InvoiceID, &note.CustomerID, &note.DisputeID, &note.Amount, &note.Reason, &note.IssuedBy,
+		&note.IssuedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("credit note %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// queryDisputes reads the disputes matching a WHERE clause and its arguments.
+func queryDisputes(ctx context.Context, db *sql.DB, where string, args ...interface{}) ([]models.InvoiceDispute, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+disputeColumns+` FROM invoice_disputes `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.InvoiceDispute{}
+	for rows.Next() {
+		var dispute models.InvoiceDispute
+		var creditNoteID sql.NullInt64
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&dispute.ID, &dispute.InvoiceID, &dispute.CustomerID, &dispute.Reason, &dispute.Amount,
+			&dispute.Status, &dispute.Resolution, &dispute.ResolvedAmount, &dispute.ResolutionNote, &creditNoteID,
+			&dispute.RaisedBy, &dispute.RaisedAt, &dispute.ResolvedBy, &resolvedAt); err != nil {
+			return nil, err
+		}
+		if creditNoteID.Valid {
+			id := int(creditNoteID.Int64)
+			dispute.CreditNoteID = &id
+		}
+		if resolvedAt.Valid {
+			dispute.ResolvedAt = &resolvedAt.Time
+		}
+		list = append(list, dispute)
+	}
+	return list, rows.Err()
+}
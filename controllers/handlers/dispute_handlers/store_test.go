@@ -0,0 +1,71 @@
+package dispute_handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolveWithCreditNote verifies that a credit note is issued, the dispute closed, the
+// allowance posted against the receivable and the resolution audited.
+func TestResolveWithCreditNote(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBDisputeStore{DB: db}
+
+	at := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM invoice_disputes WHERE id = \\$1 FOR UPDATE").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.DisputeOpen))
+	mock.ExpectQuery("INSERT INTO credit_notes").WithArgs(7, 9, 3, 40.0, "Damaged goods", "finance@example.com", at).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectExec("UPDATE invoice_disputes SET status").
+		WithArgs(models.DisputeResolved, models.ResolutionCreditNote, 40.0, "Damaged goods", sqlmock.AnyArg(),
+			"finance@example.com", at, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, line := range []struct {
+		account string
+		amount  float64
+	}{{"sales_allowances", 40}, {"accounts_receivable", -40}} {
+		mock.ExpectQuery("INSERT INTO financial_transactions").
+			WithArgs(line.account, line.amount, at, "Credit note 11 on invoice #7", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO account_period_balances").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	dispute := &models.InvoiceDispute{ID: 3, InvoiceID: 7, CustomerID: 9, Amount: 50, Status: models.DisputeResolved,
+		Resolution: models.ResolutionCreditNote, ResolvedAmount: 40, ResolutionNote: "Damaged goods",
+		ResolvedBy: "finance@example.com", ResolvedAt: &at}
+	require.NoError(t, store.ResolveDispute(context.Background(), dispute))
+	require.NotNil(t, dispute.CreditNoteID)
+	assert.Equal(t, 11, *dispute.CreditNoteID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestResolveResolvedDispute verifies that a dispute cannot be resolved twice.
+func TestResolveResolvedDispute(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBDisputeStore{DB: db}
+
+	at := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM invoice_disputes").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.DisputeResolved))
+	mock.ExpectRollback()
+
+	err = store.ResolveDispute(context.Background(), &models.InvoiceDispute{ID: 3, Resolution: models.ResolutionUphold, ResolvedAt: &at})
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,115 @@
+package ecommerce_handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// maxOrderLines limits the number of lines accepted on one external order.
+const maxOrderLines = 200
+
+// EcommerceHandler receives orders from the e-commerce site.
+type EcommerceHandler struct {
+	Store models.EcommerceOrderStore
+}
+
+// RegisterRoutes maps e-commerce integration routes to their respective handler functions.
+// The router is expected to be protected with middleware.APIKeyAuth.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the EcommerceOrderStore interface.
+func RegisterRoutes(router *mux.Router, store models.EcommerceOrderStore) {
+	handler := &EcommerceHandler{Store: store}
+
+	router.HandleFunc("/orders", handler.IngestOrder).Methods("POST")
+}
+
+// IngestOrder accepts an order from the e-commerce site. Sending the same external order ID
+// again is safe: the original mapping is returned and nothing new is created.
+//
+// HTTP Method: POST
+// URL Path: /orders
+//
+// Request Body:
+//   - JSON representation of an ExternalOrder.
+//
+// Response:
+//   - Status Code: 201 (Created) with the IngestedOrder mapping for a new order.
+//   - Status Code: 200 (OK) with the original mapping if the order was already ingested.
+//   - Status Code: 400 (Bad Request) if the order is invalid.
+//   - Status Code: 401 (Unauthorized) if the API key is missing from the context.
+//   - Status Code: 422 (Unprocessable Entity) if a line refers to an unknown product.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be stored.
+func (h *EcommerceHandler) IngestOrder(w http.ResponseWriter, r *http.Request) {
+	key, err := middleware.GetAPIKeyFromContext(r.Context())
+	if err != nil {
+		respond.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var order models.ExternalOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	if err := validateOrder(&order); err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, created, err := h.Store.IngestOrder(r.Context(), key.ID, &order)
+	if err != nil {
+		httperr.Write(w, err, "Failed to ingest order")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	respond.JSON(w, status, result)
+}
+
+// validateOrder checks the required fields of an external order and normalizes it.
+func validateOrder(order *models.ExternalOrder) error {
+	order.ExternalOrderID = strings.TrimSpace(order.ExternalOrderID)
+	if order.ExternalOrderID == "" {
+		return errors.New("external_order_id is required")
+	}
+	if strings.TrimSpace(order.Customer.Name) == "" {
+		return errors.New("customer.name is required")
+	}
+	if _, err := mail.ParseAddress(order.Customer.Email); err != nil {
+		return errors.New("customer.email must be a valid email address")
+	}
+	if len(order.Lines) == 0 || len(order.Lines) > maxOrderLines {
+		return fmt.Errorf("between 1 and %d lines are required", maxOrderLines)
+	}
+	for i, line := range order.Lines {
+		if line.ProductID <= 0 || line.Quantity <= 0 {
+			return fmt.Errorf("line %d needs a product_id and a positive quantity", i+1)
+		}
+	}
+
+	order.PaymentStatus = strings.ToLower(strings.TrimSpace(order.PaymentStatus))
+	switch order.PaymentStatus {
+	case "":
+		order.PaymentStatus = models.ExternalPaymentPending
+	case models.ExternalPaymentPending, models.ExternalPaymentPaid, models.ExternalPaymentRefunded:
+	default:
+		return fmt.Errorf("unknown payment_status %q", order.PaymentStatus)
+	}
+	return nil
+}
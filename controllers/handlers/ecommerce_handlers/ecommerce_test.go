@@ -0,0 +1,129 @@
+package ecommerce_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockEcommerceOrderStore remembers ingested orders per source, like the unique
+// (source_id, external_order_id) constraint does.
+type mockEcommerceOrderStore struct {
+	orders   map[string]*models.IngestedOrder
+	products map[int]bool
+	calls    int
+}
+
+func (m *mockEcommerceOrderStore) IngestOrder(ctx context.Context, sourceID int, order *models.ExternalOrder) (*models.IngestedOrder, bool, error) {
+	m.calls++
+	key := fmt.Sprintf("%d/%s", sourceID, order.ExternalOrderID)
+	if existing, ok := m.orders[key]; ok {
+		return existing, false, nil
+	}
+	result := &models.IngestedOrder{
+		ExternalOrderID: order.ExternalOrderID,
+		CustomerID:      1,
+		CustomerCreated: true,
+		PaymentStatus:   order.PaymentStatus,
+	}
+	for i, line := range order.Lines {
+		if !m.products[line.ProductID] {
+			return nil, false, fmt.Errorf("%w: product with ID %d does not exist", ErrUnknownProduct, line.ProductID)
+		}
+		result.Lines = append(result.Lines, models.IngestedLine{
+			ProductID:    line.ProductID,
+			SalesOrderID: i + 1,
+			Reserved:     line.Quantity,
+		})
+	}
+	m.orders[key] = result
+	return result, true, nil
+}
+
+func setupRouter(store models.EcommerceOrderStore) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := &models.APIKey{ID: 7, Active: true, Scopes: []string{models.ScopeOrdersWrite}}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.APIKeyContext, key)))
+		})
+	})
+	RegisterRoutes(router, store)
+	return router
+}
+
+func postOrder(router *mux.Router, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/orders", bytes.NewReader(payload))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func validOrder() models.ExternalOrder {
+	return models.ExternalOrder{
+		ExternalOrderID: "WEB-1001",
+		Customer:        models.ExternalCustomer{Name: "Jane Doe", Email: "jane@example.com"},
+		Lines:           []models.ExternalOrderLine{{ProductID: 1, Quantity: 2}},
+		PaymentStatus:   "PAID",
+	}
+}
+
+func TestIngestOrderIsIdempotent(t *testing.T) {
+	store := &mockEcommerceOrderStore{orders: map[string]*models.IngestedOrder{}, products: map[int]bool{1: true}}
+	router := setupRouter(store)
+
+	rr := postOrder(router, validOrder())
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var first models.IngestedOrder
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&first))
+	assert.Equal(t, models.ExternalPaymentPaid, first.PaymentStatus)
+	assert.Len(t, first.Lines, 1)
+
+	rr = postOrder(router, validOrder())
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var second models.IngestedOrder
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&second))
+	assert.Equal(t, first, second)
+	assert.Len(t, store.orders, 1)
+}
+
+func TestIngestOrderValidation(t *testing.T) {
+	store := &mockEcommerceOrderStore{orders: map[string]*models.IngestedOrder{}, products: map[int]bool{1: true}}
+	router := setupRouter(store)
+
+	missingID := validOrder()
+	missingID.ExternalOrderID = " "
+	badEmail := validOrder()
+	badEmail.Customer.Email = "not-an-email"
+	noLines := validOrder()
+	noLines.Lines = nil
+	badQuantity := validOrder()
+	badQuantity.Lines[0].Quantity = 0
+	badStatus := validOrder()
+	badStatus.PaymentStatus = "chargeback"
+
+	for _, order := range []models.ExternalOrder{missingID, badEmail, noLines, badQuantity, badStatus} {
+		rr := postOrder(router, order)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, order)
+	}
+	assert.Equal(t, 0, store.calls)
+}
+
+func TestIngestOrderUnknownProduct(t *testing.T) {
+	store := &mockEcommerceOrderStore{orders: map[string]*models.IngestedOrder{}, products: map[int]bool{}}
+	router := setupRouter(store)
+
+	rr := postOrder(router, validOrder())
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
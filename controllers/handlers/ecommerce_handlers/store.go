@@ -0,0 +1,227 @@
+// Package ecommerce_handlers ingests orders placed on the e-commerce site. Each order is
+// mapped to a customer, one sales order per line and stock reservations, exactly once per
+// external order ID.
+package ecommerce_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/controllers/bundles"
+	"erp/controllers/handlers/bundle_handlers"
+	"erp/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrUnknownProduct is returned when an order line refers to a product that does not exist.
+// It wraps models.ErrValidation.
+var ErrUnknownProduct = models.Invalid("unknown product")
+
+// DBEcommerceOrderStore provides SQL-backed order ingestion.
+type DBEcommerceOrderStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// IngestOrder records an external order in one transaction: the customer is matched by
+// email or created, a sales order is created per line, and stock is reserved up to the
+// quantity available. The order is claimed first by inserting its external ID, so a
+// concurrent or repeated delivery waits for the first one and then returns its mapping.
+//
+// Parameters:
+//   - sourceID: The API key the order arrived with; external IDs are unique per source.
+//   - order: The validated external order.
+//
+// Returns:
+//   - *IngestedOrder: The mapping from the external order to the created records.
+//   - bool: True if the order was ingested now, false if it had been ingested before.
+//   - error: An error if a product does not exist or the ingestion fails.
+func (store *DBEcommerceOrderStore) IngestOrder(ctx context.Context, sourceID int, order *models.ExternalOrder) (*models.IngestedOrder, bool, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var mappingID int
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO ecommerce_orders (source_id, external_order_id, payment_status, created_at)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (source_id, external_order_id) DO NOTHING
+		 RETURNING id`,
+		sourceID, order.ExternalOrderID, order.PaymentStatus, time.Now(),
+	).Scan(&mappingID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		existing, err := store.getIngestedOrder(ctx, sourceID, order.ExternalOrderID)
+		return existing, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := &models.IngestedOrder{
+		ExternalOrderID: order.ExternalOrderID,
+		PaymentStatus:   order.PaymentStatus,
+	}
+	result.CustomerID, result.CustomerCreated, err = matchOrCreateCustomer(ctx, tx, order.Customer)
+	if err != nil {
+		return nil, false, err
+	}
+
+	orderDate := order.OrderedAt
+	if orderDate.IsZero() {
+		orderDate = time.Now()
+	}
+	for _, line := range order.Lines {
+		ingested, err := createLine(ctx, tx, result.CustomerID, orderDate, line)
+		if err != nil {
+			return nil, false, err
+		}
+		result.Lines = append(result.Lines, *ingested)
+	}
+
+	mapping, err := json.Marshal(result)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE ecommerce_orders SET customer_id = $1, mapping = $2 WHERE id = $3",
+		result.CustomerID, mapping, mappingID,
+	); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return result, true, nil
+}
+
+// getIngestedOrder returns the stored mapping of a previously ingested order.
+func (store *DBEcommerceOrderStore) getIngestedOrder(ctx context.Context, sourceID int, externalOrderID string) (*models.IngestedOrder, error) {
+	var mapping []byte
+	err := store.DB.QueryRowContext(ctx,
+		"SELECT mapping FROM ecommerce_orders WHERE source_id = $1 AND external_order_id = $2",
+		sourceID, externalOrderID,
+	).Scan(&mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var result models.IngestedOrder
+	if err := json.Unmarshal(mapping, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// matchOrCreateCustomer finds a customer by email (stored as the contact) or creates one.
+func matchOrCreateCustomer(ctx context.Context, tx *sql.Tx, customer models.ExternalCustomer) (int, bool, error) {
+	email := strings.ToLower(strings.TrimSpace(customer.Email))
+
+	var id int
+	err := tx.QueryRowContext(ctx,
+		"SELECT id FROM customers WHERE LOWER(contact) = $1 AND deleted_at IS NULL ORDER BY id LIMIT 1", email,
+	).Scan(&id)
+	if err == nil {
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO customers (name, contact) VALUES ($1, $2) RETURNING id",
+		customer.Name, email,
+	).Scan(&id)
+	return id, true, err
+}
+
+// createLine creates the sales order for one line and reserves as much of its quantity as
+// is available. The product row is locked so concurrent orders cannot over-reserve. A
+// bundle is available as far as its components are, which are locked and reserved instead.
+func createLine(ctx context.Context, tx *sql.Tx, customerID int, orderDate time.Time, line models.ExternalOrderLine) (*models.IngestedLine, error) {
+	var available int
+	err := tx.QueryRowContext(ctx,
+		`SELECT (SELECT COALESCE(SUM(quantity), 0) FROM stock WHERE product_id = p.id)
+		      - (SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE product_id = p.id AND status = 'active')
+		 FROM products p WHERE p.id = $1 AND p.deleted_at IS NULL FOR UPDATE`,
+		line.ProductID,
+	).Scan(&available)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: product with ID %d does not exist", ErrUnknownProduct, line.ProductID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	found, err := bundle_handlers.LoadBundles(ctx, tx, []int{line.ProductID})
+	if err != nil {
+		return nil, err
+	}
+	bundle, isBundle := found[line.ProductID]
+	if isBundle {
+		if available, err = bundleAvailability(ctx, tx, bundle); err != nil {
+			return nil, err
+		}
+	}
+
+	ingested := &models.IngestedLine{ProductID: line.ProductID}
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO sales_orders (customer_id, product_id, order_date, quantity) VALUES ($1, $2, $3, $4) RETURNING id",
+		customerID, line.ProductID, orderDate, line.Quantity,
+	).Scan(&ingested.SalesOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	ingested.Reserved = line.Quantity
+	if available < ingested.Reserved {
+		ingested.Reserved = max(available, 0)
+	}
+	ingested.Backordered = line.Quantity - ingested.Reserved
+	if ingested.Reserved > 0 && isBundle {
+		demand := bundles.Expand(map[int]int{line.ProductID: ingested.Reserved}, found)
+		for _, productID := range bundles.ProductIDs(demand) {
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO stock_reservations (product_id, sales_order_id, quantity, status, created_at)
+				 VALUES ($1, $2, $3, 'active', $4)`,
+				productID, ingested.SalesOrderID, demand[productID], time.Now(),
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if ingested.Reserved > 0 {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO stock_reservations (product_id, sales_order_id, quantity, status, created_at)
+			 VALUES ($1, $2, $3, 'active', $4)`,
+			line.ProductID, ingested.SalesOrderID, ingested.Reserved, time.Now(),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ingested, nil
+}
+
+// bundleAvailability locks a bundle's components, in product order, and returns how many
+// bundles their free stock makes.
+func bundleAvailability(ctx context.Context, tx *sql.Tx, bundle *models.Bundle) (int, error) {
+	ids := make(pq.Int64Array, len(bundle.Components))
+	components := make([]int, len(bundle.Components))
+	for i, component := range bundle.Components {
+		ids[i], components[i] = int64(component.ProductID), component.ProductID
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT id FROM products WHERE id = ANY($1) ORDER BY id FOR UPDATE", ids); err != nil {
+		return 0, err
+	}
+	free, err := bundle_handlers.FreeStock(ctx, tx, components)
+	if err != nil {
+		return 0, err
+	}
+	return bundles.Available(bundle, free), nil
+}
@@ -0,0 +1,229 @@
+// Package email_template_handlers provides the API for editing, versioning and previewing
+// the templates notification emails are rendered from.
+package email_template_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// EmailTemplateHandler provides HTTP handlers for email templates.
+type EmailTemplateHandler struct {
+	Templates *mailtemplates.Service
+	Send      func(ctx context.Context, msg mailer.Message) error // Queues an email, e.g. in the outbox
+}
+
+// SaveTemplateRequest is the request body for adding a template version.
+type SaveTemplateRequest struct {
+	Subject   string   `json:"subject"`
+	Body      string   `json:"body"`
+	HTML      bool     `json:"html"`
+	Variables []string `json:"variables"` // Omit to keep those of the current version
+	Note      string   `json:"note"`
+}
+
+// PreviewRequest is the request body for previewing a template. Without a subject and
+// body, the stored template is rendered.
+type PreviewRequest struct {
+	SaveTemplateRequest
+	Version  int               `json:"version"` // Stored version to render; 0 for the current one
+	Values   map[string]string `json:"values"`
+	SendTest bool              `json:"send_test"` // Also email the result to the caller
+}
+
+// RegisterRoutes maps email template routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth and limited to the roles
+// that may edit templates.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The email template handler.
+func RegisterRoutes(router *mux.Router, handler *EmailTemplateHandler) {
+	router.HandleFunc("", handler.ListTemplates).Methods("GET")
+	router.HandleFunc("/{key}/{locale}", handler.GetTemplate).Methods("GET")
+	router.HandleFunc("/{key}/{locale}", handler.SaveTemplate).Methods("PUT")
+	router.HandleFunc("/{key}/{locale}/versions", handler.ListVersions).Methods("GET")
+	router.HandleFunc("/{key}/{locale}/versions/{version:[0-9]+}/restore", handler.RestoreVersion).Methods("POST")
+	router.HandleFunc("/{key}/{locale}/preview", handler.PreviewTemplate).Methods("POST")
+}
+
+// ListTemplates returns the current version of every template and locale.
+//
+// HTTP Method: GET
+// URL Path: /email_templates
+//
+// Response:
+//   - Status Code: 200 (OK) with the templates in JSON format.
+//   - Status Code: 500 (Internal Server Error) if the templates could not be loaded.
+func (h *EmailTemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.Templates.Store.ListCurrentTemplates(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to list email templates")
+		return
+	}
+	respond.JSON(w, http.StatusOK, templates)
+}
+
+// GetTemplate returns the current version of a template, or an earlier one.
+//
+// HTTP Method: GET
+// URL Path: /email_templates/{key}/{locale}?version=2
+//
+// Response:
+//   - Status Code: 200 (OK) with the template in JSON format.
+//   - Status Code: 404 (Not Found) if the template or version does not exist.
+func (h *EmailTemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	version, _ := strconv.Atoi(r.URL.Query().Get("version"))
+	template, err := h.Templates.Store.GetTemplate(r.Context(), vars["key"], vars["locale"], version)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load email template")
+		return
+	}
+	respond.JSON(w, http.StatusOK, template)
+}
+
+// SaveTemplate adds a version of a template, creating the template if it does not exist.
+// The new version is used for the emails sent from then on.
+//
+// HTTP Method: PUT
+// URL Path: /email_templates/{key}/{locale}
+//
+// Request Body:
+//   - JSON representation of a SaveTemplateRequest. Variables are written as {{name}}.
+//
+// Response:
+//   - Status Code: 201 (Created) with the new version in JSON format.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the template uses an undeclared variable
+//     or is otherwise invalid.
+func (h *EmailTemplateHandler) SaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var req SaveTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	vars := mux.Vars(r)
+	template := newTemplate(vars["key"], vars["locale"], &req)
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Templates.Save(r.Context(), template, actor); err != nil {
+		httperr.Write(w, err, "Failed to save email template")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, template)
+}
+
+// ListVersions returns every version of a template, newest first.
+//
+// HTTP Method: GET
+// URL Path: /email_templates/{key}/{locale}/versions
+//
+// Response:
+//   - Status Code: 200 (OK) with the versions in JSON format.
+//   - Status Code: 500 (Internal Server Error) if the versions could not be loaded.
+func (h *EmailTemplateHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	versions, err := h.Templates.Store.ListTemplateVersions(r.Context(), vars["key"], vars["locale"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to list email template versions")
+		return
+	}
+	respond.JSON(w, http.StatusOK, versions)
+}
+
+// RestoreVersion makes an earlier version current again by copying it to a new version.
+//
+// HTTP Method: POST
+// URL Path: /email_templates/{key}/{locale}/versions/{version}/restore
+//
+// Response:
+//   - Status Code: 201 (Created) with the new version in JSON format.
+//   - Status Code: 404 (Not Found) if the version does not exist.
+func (h *EmailTemplateHandler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	version, _ := strconv.Atoi(vars["version"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	template, err := h.Templates.Restore(r.Context(), vars["key"], vars["locale"], version, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to restore email template")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, template)
+}
+
+// PreviewTemplate renders a draft or a stored version of a template with sample values,
+// and optionally emails the result to the caller, so wording can be checked before it is
+// saved. Nothing is stored.
+//
+// HTTP Method: POST
+// URL Path: /email_templates/{key}/{locale}/preview
+//
+// Request Body:
+//   - JSON representation of a PreviewRequest.
+//
+// Response:
+//   - Status Code: 200 (OK) with the rendered subject, body and html flag in JSON format.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if no draft is given and the template does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the draft is invalid or a variable has no value.
+func (h *EmailTemplateHandler) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	var req PreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	vars := mux.Vars(r)
+
+	var template *models.EmailTemplate
+	var err error
+	if req.Subject != "" || req.Body != "" {
+		template = newTemplate(vars["key"], vars["locale"], &req.SaveTemplateRequest)
+		err = h.Templates.Check(r.Context(), template)
+	} else {
+		template, err = h.Templates.Store.GetTemplate(r.Context(), vars["key"], vars["locale"], req.Version)
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to preview email template")
+		return
+	}
+	msg, err := mailtemplates.Render(template, req.Values)
+	if err != nil {
+		httperr.Write(w, err, "Failed to preview email template")
+		return
+	}
+
+	if req.SendTest {
+		actor, _ := middleware.GetUserEmailFromContext(r.Context())
+		test := msg
+		test.To = []string{actor}
+		test.Subject = "[Test] " + msg.Subject
+		if err := h.Send(r.Context(), test); err != nil {
+			httperr.Write(w, err, "Failed to send test email")
+			return
+		}
+	}
+	respond.JSON(w, http.StatusOK, msg)
+}
+
+func newTemplate(key, locale string, req *SaveTemplateRequest) *models.EmailTemplate {
+	return &models.EmailTemplate{
+		Key:       key,
+		Locale:    locale,
+		Subject:   req.Subject,
+		Body:      req.Body,
+		HTML:      req.HTML,
+		Variables: req.Variables,
+		Note:      req.Note,
+	}
+}
@@ -0,0 +1,109 @@
+package email_template_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRouter serves the handlers over a sqlmock database and records the emails sent.
+func setupRouter(t *testing.T) (*mux.Router, sqlmock.Sqlmock, *[]mailer.Message) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	var sent []mailer.Message
+	handler := &EmailTemplateHandler{
+		Templates: mailtemplates.NewService(&DBEmailTemplateStore{DB: db}, "en"),
+		Send: func(ctx context.Context, msg mailer.Message) error {
+			sent = append(sent, msg)
+			return nil
+		},
+	}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/email_templates").Subrouter(), handler)
+	return router, mock, &sent
+}
+
+func serve(router *mux.Router, method, url, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmail, "finance@example.com"))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+var templateRows = []string{"id", "key", "locale", "version", "subject", "body", "html", "variables", "note", "created_by", "created_at"}
+
+func TestSaveTemplate(t *testing.T) {
+	router, mock, _ := setupRouter(t)
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO email_templates")).
+		WithArgs("payment_reminder", "en", "Overdue: {{invoice}}", "Please pay {{invoice}}.", false,
+			pq.Array([]string{"invoice"}), "Firmer wording", "finance@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version", "created_at"}).AddRow(5, 2, now))
+
+	rr := serve(router, "PUT", "/email_templates/payment_reminder/en",
+		`{"subject": "Overdue: {{invoice}}", "body": "Please pay {{invoice}}.", "variables": ["invoice"], "note": "Firmer wording"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var saved models.EmailTemplate
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&saved))
+	assert.Equal(t, 2, saved.Version)
+	assert.Equal(t, "finance@example.com", saved.CreatedBy)
+
+	rr = serve(router, "PUT", "/email_templates/payment_reminder/en",
+		`{"subject": "Overdue: {{invoice}}", "body": "Pay by {{due}}", "variables": ["invoice"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	var failure respond.Envelope
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&failure))
+	assert.Equal(t, `variable "due" is not declared`, failure.Error.Message)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	router, mock, sent := setupRouter(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM email_templates")).
+		WithArgs("payment_reminder", "en", 0).
+		WillReturnRows(sqlmock.NewRows(templateRows).AddRow(
+			1, "payment_reminder", "en", 1, "Invoice {{invoice}} is due", "Dear {{customer}}", false,
+			"{invoice,customer}", "", "system", time.Now()))
+
+	rr := serve(router, "POST", "/email_templates/payment_reminder/en/preview",
+		`{"values": {"invoice": "INV-7", "customer": "Acme"}, "send_test": true}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"to": null, "subject": "Invoice INV-7 is due", "body": "Dear Acme"}`, rr.Body.String())
+	require.Len(t, *sent, 1)
+	assert.Equal(t, []string{"finance@example.com"}, (*sent)[0].To)
+	assert.Equal(t, "[Test] Invoice INV-7 is due", (*sent)[0].Subject)
+
+	// A draft is rendered without being stored
+	rr = serve(router, "POST", "/email_templates/welcome/en/preview",
+		`{"subject": "Welcome {{name}}", "body": "<b>{{name}}</b>", "html": true, "variables": ["name"], "values": {"name": "<Ana>"}}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var draft mailer.Message
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&draft))
+	assert.Equal(t, "<b>&lt;Ana&gt;</b>", draft.Body)
+
+	rr = serve(router, "POST", "/email_templates/welcome/en/preview", `{"subject": "Hi {{name}}", "body": "x", "variables": ["name"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,116 @@
+package email_template_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBEmailTemplateStore implements models.EmailTemplateStore using a SQL database.
+type DBEmailTemplateStore struct {
+	DB *sql.DB
+}
+
+const templateColumns = `id, key, locale, version, subject, body, html, variables, COALESCE(note, ''),
+	COALESCE(created_by, ''), created_at`
+
+// ListCurrentTemplates returns the newest version of every template and locale.
+//
+// Returns:
+//   - []models.EmailTemplate: The templates, ordered by key and locale.
+//   - error: An error if the query fails.
+func (s *DBEmailTemplateStore) ListCurrentTemplates(ctx context.Context) ([]models.EmailTemplate, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT DISTINCT ON (key, locale) "+templateColumns+`
+		 FROM email_templates ORDER BY key, locale, version DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email templates: %w", err)
+	}
+	return scanTemplates(rows)
+}
+
+// GetTemplate returns a version of a template.
+//
+// Parameters:
+//   - key: The template key.
+//   - locale: The locale.
+//   - version: The version, or 0 for the newest.
+//
+// Returns:
+//   - *models.EmailTemplate: The template.
+//   - error: A models.NotFound error if it does not exist, or an error if the query fails.
+func (s *DBEmailTemplateStore) GetTemplate(ctx context.Context, key, locale string, version int) (*models.EmailTemplate, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT "+templateColumns+` FROM email_templates
+		 WHERE key = $1 AND locale = $2 AND ($3 = 0 OR version = $3)
+		 ORDER BY version DESC LIMIT 1`, key, locale, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load email template: %w", err)
+	}
+	templates, err := scanTemplates(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		if version != 0 {
+			return nil, models.NotFound("version %d of email template %s (%s) not found", version, key, locale)
+		}
+		return nil, models.NotFound("email template %s (%s) not found", key, locale)
+	}
+	return &templates[0], nil
+}
+
+// ListTemplateVersions returns every version of a template, newest first.
+func (s *DBEmailTemplateStore) ListTemplateVersions(ctx context.Context, key, locale string) ([]models.EmailTemplate, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT "+templateColumns+" FROM email_templates WHERE key = $1 AND locale = $2 ORDER BY version DESC",
+		key, locale)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email template versions: %w", err)
+	}
+	return scanTemplates(rows)
+}
+
+// AddTemplateVersion stores a template as the next version of its key and locale.
+//
+// Parameters:
+//   - t: The template; its ID, Version and CreatedAt are set.
+//
+// Returns:
+//   - error: A models.Conflict error if another version was added at the same time, or an
+//     error if the insert fails.
+func (s *DBEmailTemplateStore) AddTemplateVersion(ctx context.Context, t *models.EmailTemplate) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO email_templates (key, locale, version, subject, body, html, variables, note, created_by)
+		 SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3, $4, $5, $6, NULLIF($7, ''), $8
+		 FROM email_templates WHERE key = $1 AND locale = $2
+		 RETURNING id, version, created_at`,
+		t.Key, t.Locale, t.Subject, t.Body, t.HTML, pq.Array(t.Variables), t.Note, t.CreatedBy,
+	).Scan(&t.ID, &t.Version, &t.CreatedAt)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("email template %s (%s) was changed at the same time; try again", t.Key, t.Locale)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save email template: %w", err)
+	}
+	return nil
+}
+
+func scanTemplates(rows *sql.Rows) ([]models.EmailTemplate, error) {
+	defer rows.Close()
+	templates := []models.EmailTemplate{}
+	for rows.Next() {
+		var t models.EmailTemplate
+		err := rows.Scan(&t.ID, &t.Key, &t.Locale, &t.Version, &t.Subject, &t.Body, &t.HTML,
+			pq.Array(&t.Variables), &t.Note, &t.CreatedBy, &t.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
@@ -0,0 +1,227 @@
+// Package employee_handlers provides HTTP handlers and the database store for employee
+// profiles: CRUD, filtered lists and a text search for pickers.
+package employee_handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/employees"
+	"erp/controllers/httperr"
+	"erp/controllers/pagination"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// EmployeeHandler provides HTTP handlers for employee profiles.
+type EmployeeHandler struct {
+	Service *employees.Service
+}
+
+// RegisterRoutes maps employee routes to their handler functions. The router is expected to
+// be protected with middleware.JWTAuth and limited to HR.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The employee handler.
+func RegisterRoutes(router *mux.Router, handler *EmployeeHandler) {
+	router.HandleFunc("", handler.ListEmployees).Methods("GET")
+	router.HandleFunc("", handler.CreateEmployee).Methods("POST")
+	router.HandleFunc("/search", handler.SearchEmployees).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetEmployee).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.UpdateEmployee).Methods("PUT")
+	router.HandleFunc("/{id:[0-9]+}", handler.DeleteEmployee).Methods("DELETE")
+}
+
+// EmployeeRequest is the request body for creating or changing an employee profile. The ID
+// and timestamps are set by the server.
+type EmployeeRequest struct {
+	UserID      *int   `json:"user_id"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	Designation string `json:"designation"`
+	SalaryGrade string `json:"salary_grade"`
+	Department  string `json:"department"`
+	ManagerID   *int   `json:"manager_id"`
+	HireDate    string `json:"hire_date"` // YYYY-MM-DD
+	Version     int    `json:"version"`   // The version changed, unless sent in If-Match; ignored on create
+}
+
+// Validate checks that hire_date is a date. The rules of the profile itself are applied by
+// the employee service, which reports the fields that break them the same way.
+func (req EmployeeRequest) Validate() error {
+	var c validation.Checker
+	if req.HireDate != "" {
+		_, err := time.Parse("2006-01-02", req.HireDate)
+		c.Check(err == nil, "hire_date", "must be a date formatted as YYYY-MM-DD")
+	}
+	return c.Err()
+}
+
+// Employee returns the profile described by a validated request.
+func (req EmployeeRequest) Employee() models.Employee {
+	e := models.Employee{
+		UserID:      req.UserID,
+		Name:        req.Name,
+		Email:       req.Email,
+		Designation: req.Designation,
+		SalaryGrade: req.SalaryGrade,
+		Department:  req.Department,
+		ManagerID:   req.ManagerID,
+	}
+	e.HireDate, _ = time.Parse("2006-01-02", req.HireDate)
+	return e
+}
+
+// ListEmployees lists employee profiles a page at a time.
+//
+// HTTP Method: GET
+// URL Path: /employees?department=Sales&designation=&salary_grade=&manager_id=&user_id=&hire_date=&sort=-hire_date&page=1&limit=50
+//
+// Response:
+//   - Status Code: 200 (OK) with a Page of Employees in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if a filter, the sort or the page is invalid.
+//   - Status Code: 500 (Internal Server Error) if the employees cannot be loaded.
+func (h *EmployeeHandler) ListEmployees(w http.ResponseWriter, r *http.Request) {
+	query, err := pagination.Parse(r, EmployeeColumns)
+	if err != nil {
+		httperr.Write(w, err, "Invalid list query")
+		return
+	}
+	list, total, err := h.Service.List(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load employees")
+		return
+	}
+	pagination.Write(w, list, total, query)
+}
+
+// SearchEmployees finds employees by part of their name, email or designation.
+//
+// HTTP Method: GET
+// URL Path: /employees/search?q=ali
+//
+// Response:
+//   - Status Code: 200 (OK) with up to 20 Employees in JSON, ordered by name.
+//   - Status Code: 422 (Unprocessable Entity) if q has fewer than 2 characters.
+//   - Status Code: 500 (Internal Server Error) if the search fails.
+func (h *EmployeeHandler) SearchEmployees(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Service.Search(r.Context(), r.URL.Query().Get("q"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to search employees")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// CreateEmployee records an employee profile.
+//
+// HTTP Method: POST
+// URL Path: /employees
+//
+// Request Body:
+//   - JSON with name, email, designation, hire_date (YYYY-MM-DD) and optionally user_id (the
+//     sign-in account), salary_grade, department and manager_id (see EmployeeRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the Employee in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is not JSON.
+//   - Status Code: 409 (Conflict) if the user or email already has a profile.
+//   - Status Code: 422 (Unprocessable Entity) if a field is missing or invalid, or the user or manager does not exist.
+//     Invalid fields are listed in the error details.
+//   - Status Code: 500 (Internal Server Error) if the profile cannot be saved.
+func (h *EmployeeHandler) CreateEmployee(w http.ResponseWriter, r *http.Request) {
+	var req EmployeeRequest
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+	e := req.Employee()
+	if err := h.Service.Create(r.Context(), &e); err != nil {
+		httperr.Write(w, err, "Failed to create employee")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, e)
+}
+
+// GetEmployee returns an employee profile.
+//
+// HTTP Method: GET
+// URL Path: /employees/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the Employee in JSON and its version as the ETag.
+//   - Status Code: 404 (Not Found) if the employee does not exist.
+//   - Status Code: 500 (Internal Server Error) if the profile cannot be loaded.
+func (h *EmployeeHandler) GetEmployee(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	e, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load employee")
+		return
+	}
+	versioning.SetETag(w, e.Version)
+	respond.JSON(w, http.StatusOK, e)
+}
+
+// UpdateEmployee changes an employee profile. Every field is replaced.
+//
+// HTTP Method: PUT
+// URL Path: /employees/{id}
+//
+// Request Body:
+//   - JSON as for CreateEmployee, with the version read; the version may be sent as an
+//     If-Match header instead.
+//
+// Response:
+//   - Status Code: 200 (OK) with the Employee in JSON and its new version as the ETag.
+//   - Status Code: 400 (Bad Request) if the request body is not JSON.
+//   - Status Code: 404 (Not Found) if the employee does not exist.
+//   - Status Code: 409 (Conflict) if the user or email already has another profile, or the
+//     profile was changed since the version read.
+//   - Status Code: 428 (Precondition Required) if no version is given.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid, the user or manager does not exist, or the
+//     manager reports to the employee. Invalid fields are listed in the error details.
+//   - Status Code: 500 (Internal Server Error) if the profile cannot be saved.
+func (h *EmployeeHandler) UpdateEmployee(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req EmployeeRequest
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
+		return
+	}
+	e := req.Employee()
+	e.ID, e.Version = id, version
+	if err := h.Service.Update(r.Context(), &e); err != nil {
+		httperr.Write(w, err, "Failed to update employee")
+		return
+	}
+	versioning.SetETag(w, e.Version)
+	respond.JSON(w, http.StatusOK, e)
+}
+
+// DeleteEmployee removes an employee profile. The user account with its attendance and leave
+// is kept, but no more attendance or leave is accepted for it.
+//
+// HTTP Method: DELETE
+// URL Path: /employees/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the profile is removed.
+//   - Status Code: 404 (Not Found) if the employee does not exist.
+//   - Status Code: 500 (Internal Server Error) if the profile cannot be removed.
+func (h *EmployeeHandler) DeleteEmployee(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete employee")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package employee_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"erp/controllers/pagination"
+	"erp/controllers/versioning"
+	"erp/models"
+)
+
+// DBEmployeeStore implements models.EmployeeStore using a SQL database.
+type DBEmployeeStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// EmployeeColumns are the fields employees can be listed by.
+var EmployeeColumns = pagination.Columns{
+	"id":           {Expr: "id", Type: pagination.Int},
+	"user_id":      {Expr: "user_id", Type: pagination.Int},
+	"name":         {Expr: "name", Type: pagination.Text},
+	"email":        {Expr: "email", Type: pagination.Text},
+	"designation":  {Expr: "designation", Type: pagination.Text},
+	"salary_grade": {Expr: "salary_grade", Type: pagination.Text},
+	"department":   {Expr: "department", Type: pagination.Text},
+	"manager_id":   {Expr: "manager_id", Type: pagination.Int},
+	"hire_date":    {Expr: "hire_date", Type: pagination.Date},
+}
+
+// employeeFields are the columns scanned by scanEmployee.
+const employeeFields = `id, user_id, name, email, designation, COALESCE(salary_grade, ''), COALESCE(department, ''),
+	manager_id, hire_date, created_at, updated_at, version`
+
+// scanEmployee reads a row selected with employeeFields.
+func scanEmployee(row interface{ Scan(...interface{}) error }) (*models.Employee, error) {
+	var e models.Employee
+	if err := row.Scan(&e.ID, &e.UserID, &e.Name, &e.Email, &e.Designation, &e.SalaryGrade, &e.Department,
+		&e.ManagerID, &e.HireDate, &e.CreatedAt, &e.UpdatedAt, &e.Version); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// employeeError converts the constraint errors of a profile: a user or email that already
+// has one to a conflict, and an unknown user or manager to a validation error.
+func employeeError(err error, e *models.Employee) error {
+	switch {
+	case models.IsUniqueViolation(err, "employees_user_id_key"):
+		return models.Conflict("user %d already has an employee profile", *e.UserID)
+	case models.IsUniqueViolation(err):
+		return models.Conflict("an employee with email %s already exists", e.Email)
+	case models.IsForeignKeyViolation(err, "employees_manager_id_fkey"):
+		return models.Invalid("manager %d does not exist", *e.ManagerID)
+	case models.IsForeignKeyViolation(err):
+		return models.Invalid("user %d does not exist", *e.UserID)
+	}
+	return err
+}
+
+// CreateEmployee records a profile and sets its ID.
+//
+// Returns:
+//   - error: A conflict if the user or email already has a profile, a validation error if the
+//     user or manager does not exist, or the query error.
+func (s *DBEmployeeStore) CreateEmployee(ctx context.Context, e *models.Employee) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO employees (user_id, name, email, designation, salary_grade, department, manager_id, hire_date, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10) RETURNING id, version`,
+		e.UserID, e.Name, e.Email, e.Designation, e.SalaryGrade, e.Department, e.ManagerID, e.HireDate,
+		e.CreatedAt, e.UpdatedAt,
+	).Scan(&e.ID, &e.Version)
+	return employeeError(err, e)
+}
+
+// GetEmployee returns a profile.
+//
+// Returns:
+//   - error: A not found error if there is no such employee, or the query error.
+func (s *DBEmployeeStore) GetEmployee(ctx context.Context, id int) (*models.Employee, error) {
+	e, err := scanEmployee(s.DB.QueryRowContext(ctx, `SELECT `+employeeFields+` FROM employees WHERE id = $1 AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("employee %d not found", id)
+	}
+	return e, err
+}
+
+// UpdateEmployee changes a profile if it is still at e.Version, and sets the new version.
+//
+// Returns:
+//   - error: A not found error if there is no such employee, a conflict if it was changed
+//     since e.Version, the errors of CreateEmployee, or the query error.
+func (s *DBEmployeeStore) UpdateEmployee(ctx context.Context, e *models.Employee) error {
+	err := s.DB.QueryRowContext(ctx,
+		`UPDATE employees SET user_id = $1, name = $2, email = $3, designation = $4, salary_grade = NULLIF($5, ''),
+		        department = NULLIF($6, ''), manager_id = $7, hire_date = $8, updated_at = $9
+		 WHERE id = $10 AND version = $11 AND deleted_at IS NULL RETURNING version`,
+		e.UserID, e.Name, e.Email, e.Designation, e.SalaryGrade, e.Department, e.ManagerID, e.HireDate,
+		e.UpdatedAt, e.ID, e.Version).Scan(&e.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return versioning.Stale(ctx, s.DB, `SELECT version FROM employees WHERE id = $1 AND deleted_at IS NULL`, "employee",
+			e.ID, e.Version)
+	}
+	return employeeError(err, e)
+}
+
+// DeleteEmployee soft deletes a profile, hiding it until it is restored or purged.
+//
+// Returns:
+//   - error: A not found error if there is no such employee, or the query error.
+func (s *DBEmployeeStore) DeleteEmployee(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, `UPDATE employees SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("employee %d not found", id)
+	}
+	return nil
+}
+
+// ListEmployees returns a page of the employees matching the query's filters.
+func (s *DBEmployeeStore) ListEmployees(ctx context.Context, query models.ListQuery) ([]models.Employee, int, error) {
+	list := []models.Employee{}
+	total, err := pagination.QueryWhere(ctx, s.DB, employeeFields, "employees", "deleted_at IS NULL", EmployeeColumns, query, func(rows *sql.Rows) error {
+		e, err := scanEmployee(rows)
+		if err != nil {
+			return err
+		}
+		list = append(list, *e)
+		return nil
+	})
+	return list, total, err
+}
+
+// SearchEmployees returns up to limit employees whose name, email or designation contains
+// text, ignoring case, ordered by name.
+func (s *DBEmployeeStore) SearchEmployees(ctx context.Context, text string, limit int) ([]models.Employee, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+employeeFields+` FROM employees
+		 WHERE (name ILIKE $1 OR email ILIKE $1 OR designation ILIKE $1) AND deleted_at IS NULL
+		 ORDER BY name, id LIMIT $2`,
+		pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Employee{}
+	for rows.Next() {
+		e, err := scanEmployee(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
+	}
+	return list, rows.Err()
+}
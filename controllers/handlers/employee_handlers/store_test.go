@@ -0,0 +1,58 @@
+package employee_handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateEmployeeMapsConstraintErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBEmployeeStore{DB: db}
+
+	userID, managerID := 3, 8
+	e := &models.Employee{UserID: &userID, ManagerID: &managerID, Name: "Alice", Email: "alice@example.com",
+		Designation: "Clerk", HireDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}
+	for _, c := range []struct {
+		err     *pq.Error
+		kind    error
+		message string
+	}{
+		{&pq.Error{Code: "23505", Constraint: "employees_user_id_key"}, models.ErrConflict, "user 3 already has an employee profile"},
+		{&pq.Error{Code: "23505", Constraint: "employees_email_key"}, models.ErrConflict, "an employee with email alice@example.com already exists"},
+		{&pq.Error{Code: "23503", Constraint: "employees_manager_id_fkey"}, models.ErrValidation, "manager 8 does not exist"},
+		{&pq.Error{Code: "23503", Constraint: "employees_user_id_fkey"}, models.ErrValidation, "user 3 does not exist"},
+	} {
+		mock.ExpectQuery("INSERT INTO employees").WillReturnError(c.err)
+		err := store.CreateEmployee(context.Background(), e)
+		assert.True(t, errors.Is(err, c.kind), c.message)
+		assert.EqualError(t, err, c.message)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchEmployeesEscapesPattern(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBEmployeeStore{DB: db}
+
+	mock.ExpectQuery("WHERE \\(name ILIKE \\$1 .*\\) AND deleted_at IS NULL").WithArgs(`%50\%%`, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "email", "designation", "salary_grade",
+			"department", "manager_id", "hire_date", "created_at", "updated_at", "version"}))
+
+	list, err := store.SearchEmployees(context.Background(), "50%", 20)
+	require.NoError(t, err)
+	assert.NotNil(t, list)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
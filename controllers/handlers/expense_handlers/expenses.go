@@ -0,0 +1,272 @@
+package expense_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/expenses"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// ExpenseHandlers provides the endpoints under /expenses. The policy checks and the claim
+// workflow live in the expenses service; the handlers only translate HTTP.
+type ExpenseHandlers struct {
+	Service *expenses.Service
+}
+
+// reviewRequest is the request body of reviews and overrides.
+type reviewRequest struct {
+	Note   string `json:"note"`
+	Reason string `json:"reason"`
+}
+
+// ListPolicies returns the policy of every expense category.
+//
+// HTTP Method: GET
+// URL Path: /expenses/policies
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of ExpensePolicies in JSON.
+//   - Status Code: 500 (Internal Server Error) if the policies cannot be read.
+func (h *ExpenseHandlers) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.Service.Policies(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load expense policies")
+		return
+	}
+	respond.JSON(w, http.StatusOK, policies)
+}
+
+// UpdatePolicy sets the limits of an expense category. Zero disables a check.
+//
+// HTTP Method: PUT
+// URL Path: /expenses/policies/{category}
+//
+// Request Body:
+//   - JSON object with "limit", "hard_limit", "receipt_threshold" and "per_diem_rate".
+//
+// Response:
+//   - Status Code: 200 (OK) with the ExpensePolicy in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if an amount is negative or the limit is
+//     above the hard limit.
+//   - Status Code: 500 (Internal Server Error) if the policy cannot be saved.
+func (h *ExpenseHandlers) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	var policy models.ExpensePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	policy.Category = mux.Vars(r)["category"]
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.SetPolicy(r.Context(), &policy, actor); err != nil {
+		httperr.Write(w, err, "Failed to update expense policy")
+		return
+	}
+	respond.JSON(w, http.StatusOK, policy)
+}
+
+// SubmitClaim submits an expense claim for the authenticated user. The claim is checked
+// against the expense policies and any violations are recorded on it for the approver.
+//
+// HTTP Method: POST
+// URL Path: /expenses
+//
+// Request Body:
+//   - JSON object with "purpose" and "items", each with "category", "description", "date",
+//     "amount", and optionally "receipt". Items of "kind" "mileage" give "vehicle_type" and
+//     "distance", and items of "kind" "per_diem" give "destination" and "days", instead of
+//     an amount; their amount is computed from the rate tables.
+//
+// Response:
+//   - Status Code: 201 (Created) with the ExpenseClaim in JSON, including its violations.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the claim has no items, an item is
+//     incomplete or there is no rate for its vehicle type or destination.
+//   - Status Code: 500 (Internal Server Error) if the claim cannot be saved.
+func (h *ExpenseHandlers) SubmitClaim(w http.ResponseWriter, r *http.Request) {
+	var claim models.ExpenseClaim
+	if err := json.NewDecoder(r.Body).Decode(&claim); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	employee, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Submit(r.Context(), &claim, employee); err != nil {
+		httperr.Write(w, err, "Failed to submit expense claim")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, claim)
+}
+
+// ListMyClaims lists the authenticated user's claims, newest first.
+//
+// HTTP Method: GET
+// URL Path: /expenses/mine?status=submitted
+// (status is optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of ExpenseClaims in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the claims cannot be read.
+func (h *ExpenseHandlers) ListMyClaims(w http.ResponseWriter, r *http.Request) {
+	employee, _ := middleware.GetUserEmailFromContext(r.Context())
+	h.list(r.Context(), w, employee, r.URL.Query().Get("status"))
+}
+
+// ListClaims lists the claims of every employee for approvers, newest first. Each claim
+// carries its policy violations.
+//
+// HTTP Method: GET
+// URL Path: /expenses?status=submitted&employee=jane@example.com
+// (both parameters are optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of ExpenseClaims in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the claims cannot be read.
+func (h *ExpenseHandlers) ListClaims(w http.ResponseWriter, r *http.Request) {
+	h.list(r.Context(), w, r.URL.Query().Get("employee"), r.URL.Query().Get("status"))
+}
+
+// list writes the claims matching employee and status.
+func (h *ExpenseHandlers) list(ctx context.Context, w http.ResponseWriter, employee, status string) {
+	claims, err := h.Service.Claims(ctx, employee, status)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load expense claims")
+		return
+	}
+	respond.JSON(w, http.StatusOK, claims)
+}
+
+// GetClaim returns a claim to its claimant or an approver.
+//
+// HTTP Method: GET
+// URL Path: /expenses/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the ExpenseClaim in JSON.
+//   - Status Code: 403 (Forbidden) if the claim belongs to someone else.
+//   - Status Code: 404 (Not Found) if the claim does not exist.
+//   - Status Code: 500 (Internal Server Error) if the claim cannot be read.
+func (h *ExpenseHandlers) GetClaim(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	claim, err := h.Service.Claim(r.Context(), id, actor, role)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load expense claim")
+		return
+	}
+	respond.JSON(w, http.StatusOK, claim)
+}
+
+// ApproveClaim approves a submitted claim. Approving does not accept hard violations;
+// those still need an override before the claim is reimbursed.
+//
+// HTTP Method: POST
+// URL Path: /expenses/{id}/approve
+//
+// Request Body:
+//   - Optional JSON object with a "note".
+//
+// Response:
+//   - Status Code: 200 (OK) with the ExpenseClaim in JSON.
+//   - Status Code: 403 (Forbidden) if the approver submitted the claim.
+//   - Status Code: 404 (Not Found) if the claim does not exist.
+//   - Status Code: 409 (Conflict) if the claim is not awaiting review.
+//   - Status Code: 500 (Internal Server Error) if the claim cannot be saved.
+func (h *ExpenseHandlers) ApproveClaim(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, func(id int, actor, role string, req reviewRequest) (*models.ExpenseClaim, error) {
+		return h.Service.Approve(r.Context(), id, actor, req.Note)
+	}, "Failed to approve expense claim")
+}
+
+// RejectClaim rejects a submitted claim.
+//
+// HTTP Method: POST
+// URL Path: /expenses/{id}/reject
+//
+// Request Body:
+//   - JSON object with a "note" explaining the rejection.
+//
+// Response:
+//   - Status Code: 200 (OK) with the ExpenseClaim in JSON.
+//   - Status Code: 403 (Forbidden) if the approver submitted the claim.
+//   - Status Code: 404 (Not Found) if the claim does not exist.
+//   - Status Code: 409 (Conflict) if the claim is not awaiting review.
+//   - Status Code: 422 (Unprocessable Entity) if the note is missing.
+//   - Status Code: 500 (Internal Server Error) if the claim cannot be saved.
+func (h *ExpenseHandlers) RejectClaim(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, func(id int, actor, role string, req reviewRequest) (*models.ExpenseClaim, error) {
+		return h.Service.Reject(r.Context(), id, actor, req.Note)
+	}, "Failed to reject expense claim")
+}
+
+// OverrideClaim accepts the hard policy violations of a claim so it can be reimbursed.
+// Only users with an override role may do so.
+//
+// HTTP Method: POST
+// URL Path: /expenses/{id}/override
+//
+// Request Body:
+//   - JSON object with a "reason".
+//
+// Response:
+//   - Status Code: 200 (OK) with the ExpenseClaim in JSON.
+//   - Status Code: 403 (Forbidden) if the user's role may not override or the user submitted the claim.
+//   - Status Code: 404 (Not Found) if the claim does not exist.
+//   - Status Code: 409 (Conflict) if the claim has no hard violations, is already
+//     overridden, or has been rejected or reimbursed.
+//   - Status Code: 422 (Unprocessable Entity) if the reason is missing.
+//   - Status Code: 500 (Internal Server Error) if the claim cannot be saved.
+func (h *ExpenseHandlers) OverrideClaim(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, func(id int, actor, role string, req reviewRequest) (*models.ExpenseClaim, error) {
+		return h.Service.Override(r.Context(), id, actor, role, req.Reason)
+	}, "Failed to override expense claim")
+}
+
+// ReimburseClaim reimburses an approved claim and posts it to the ledger.
+//
+// HTTP Method: POST
+// URL Path: /expenses/{id}/reimburse
+//
+// Response:
+//   - Status Code: 200 (OK) with the ExpenseClaim in JSON.
+//   - Status Code: 404 (Not Found) if the claim does not exist.
+//   - Status Code: 409 (Conflict) if the claim is not approved or has hard violations
+//     that have not been overridden.
+//   - Status Code: 500 (Internal Server Error) if the reimbursement cannot be posted.
+func (h *ExpenseHandlers) ReimburseClaim(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, func(id int, actor, role string, req reviewRequest) (*models.ExpenseClaim, error) {
+		return h.Service.Reimburse(r.Context(), id, actor)
+	}, "Failed to reimburse expense claim")
+}
+
+// review reads the claim ID and the optional request body, applies a change to the claim
+// and writes the result.
+func (h *ExpenseHandlers) review(w http.ResponseWriter, r *http.Request,
+	apply func(id int, actor, role string, req reviewRequest) (*models.ExpenseClaim, error), failure string) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req reviewRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	claim, err := apply(id, actor, role, req)
+	if err != nil {
+		httperr.Write(w, err, failure)
+		return
+	}
+	respond.JSON(w, http.StatusOK, claim)
+}
@@ -0,0 +1,140 @@
+package expense_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// ListMileageRates returns the mileage rate of every vehicle type.
+//
+// HTTP Method: GET
+// URL Path: /expenses/rates/mileage
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of MileageRates in JSON.
+//   - Status Code: 500 (Internal Server Error) if the rates cannot be read.
+func (h *ExpenseHandlers) ListMileageRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.Service.MileageRates(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load mileage rates")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rates)
+}
+
+// UpdateMileageRate sets the amount reimbursed per kilometre for a vehicle type.
+//
+// HTTP Method: PUT
+// URL Path: /expenses/rates/mileage/{vehicle_type}
+//
+// Request Body:
+//   - JSON object with "rate".
+//
+// Response:
+//   - Status Code: 200 (OK) with the MileageRate in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the rate is not positive.
+//   - Status Code: 500 (Internal Server Error) if the rate cannot be saved.
+func (h *ExpenseHandlers) UpdateMileageRate(w http.ResponseWriter, r *http.Request) {
+	var rate models.MileageRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	rate.VehicleType = mux.Vars(r)["vehicle_type"]
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.SetMileageRate(r.Context(), &rate, actor); err != nil {
+		httperr.Write(w, err, "Failed to update mileage rate")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rate)
+}
+
+// DeleteMileageRate removes the rate of a vehicle type. Claims already submitted keep the
+// rate they were priced with.
+//
+// HTTP Method: DELETE
+// URL Path: /expenses/rates/mileage/{vehicle_type}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the rate was deleted.
+//   - Status Code: 404 (Not Found) if the vehicle type has no rate.
+//   - Status Code: 500 (Internal Server Error) if the rate cannot be deleted.
+func (h *ExpenseHandlers) DeleteMileageRate(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.DeleteMileageRate(r.Context(), mux.Vars(r)["vehicle_type"]); err != nil {
+		httperr.Write(w, err, "Failed to delete mileage rate")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPerDiemRates returns the per-diem rate of every destination.
+//
+// HTTP Method: GET
+// URL Path: /expenses/rates/per_diem
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of PerDiemRates in JSON.
+//   - Status Code: 500 (Internal Server Error) if the rates cannot be read.
+func (h *ExpenseHandlers) ListPerDiemRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.Service.PerDiemRates(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load per-diem rates")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rates)
+}
+
+// UpdatePerDiemRate sets the daily allowance for a destination. The destination "default"
+// applies to destinations without a rate of their own.
+//
+// HTTP Method: PUT
+// URL Path: /expenses/rates/per_diem/{destination}
+//
+// Request Body:
+//   - JSON object with "daily_rate", and optionally "long_stay_rate" with "long_stay_after",
+//     the number of days after which it applies.
+//
+// Response:
+//   - Status Code: 200 (OK) with the PerDiemRate in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if a rate is invalid.
+//   - Status Code: 500 (Internal Server Error) if the rate cannot be saved.
+func (h *ExpenseHandlers) UpdatePerDiemRate(w http.ResponseWriter, r *http.Request) {
+	var rate models.PerDiemRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	rate.Destination = mux.Vars(r)["destination"]
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.SetPerDiemRate(r.Context(), &rate, actor); err != nil {
+		httperr.Write(w, err, "Failed to update per-diem rate")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rate)
+}
+
+// DeletePerDiemRate removes the rate of a destination, which then gets the default rate.
+//
+// HTTP Method: DELETE
+// URL Path: /expenses/rates/per_diem/{destination}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the rate was deleted.
+//   - Status Code: 404 (Not Found) if the destination has no rate.
+//   - Status Code: 500 (Internal Server Error) if the rate cannot be deleted.
+func (h *ExpenseHandlers) DeletePerDiemRate(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.DeletePerDiemRate(r.Context(), mux.Vars(r)["destination"]); err != nil {
+		httperr.Write(w, err, "Failed to delete per-diem rate")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
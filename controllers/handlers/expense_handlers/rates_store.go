@@ -0,0 +1,155 @@
+package expense_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"erp/controllers/audit"
+	"erp/models"
+)
+
+// GetMileageRates retrieves the rate of every vehicle type, ordered by vehicle type.
+//
+// Returns:
+//   - []models.MileageRate: The rates.
+//   - error: An error if the query fails.
+func (store *DBExpenseStore) GetMileageRates(ctx context.Context) ([]models.MileageRate, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT vehicle_type, rate, updated_by, updated_at FROM expense_mileage_rates ORDER BY vehicle_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.MileageRate{}
+	for rows.Next() {
+		var rate models.MileageRate
+		if err := rows.Scan(&rate.VehicleType, &rate.Rate, &rate.UpdatedBy, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// SetMileageRate creates or replaces the rate of a vehicle type and records the change in
+// the audit log.
+//
+// Parameters:
+//   - rate: The validated rate with UpdatedBy and UpdatedAt set.
+//
+// Returns:
+//   - error: An error if the rate or its audit entry cannot be saved.
+func (store *DBExpenseStore) SetMileageRate(ctx context.Context, rate *models.MileageRate) error {
+	return store.setRate(ctx, "expense_mileage_rate", rate, rate.UpdatedBy, rate.UpdatedAt,
+		`INSERT INTO expense_mileage_rates (vehicle_type, rate, updated_by, updated_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (vehicle_type) DO UPDATE SET rate = EXCLUDED.rate, updated_by = EXCLUDED.updated_by,
+		     updated_at = EXCLUDED.updated_at`,
+		rate.VehicleType, rate.Rate, rate.UpdatedBy, rate.UpdatedAt)
+}
+
+// DeleteMileageRate removes the rate of a vehicle type.
+//
+// Returns:
+//   - error: models.ErrNotFound if the vehicle type has no rate, or an error if the delete fails.
+func (store *DBExpenseStore) DeleteMileageRate(ctx context.Context, vehicleType string) error {
+	result, err := store.DB.ExecContext(ctx, `DELETE FROM expense_mileage_rates WHERE LOWER(vehicle_type) = LOWER($1)`, vehicleType)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("no mileage rate for vehicle type %q", vehicleType)
+	}
+	return nil
+}
+
+// GetPerDiemRates retrieves the rate of every destination, ordered by destination.
+//
+// Returns:
+//   - []models.PerDiemRate: The rates.
+//   - error: An error if the query fails.
+func (store *DBExpenseStore) GetPerDiemRates(ctx context.Context) ([]models.PerDiemRate, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT destination, daily_rate, long_stay_rate, long_stay_after, updated_by, updated_at
+		 FROM expense_per_diem_rates ORDER BY destination`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.PerDiemRate{}
+	for rows.Next() {
+		var rate models.PerDiemRate
+		if err := rows.Scan(&rate.Destination, &rate.DailyRate, &rate.LongStayRate, &rate.LongStayAfter,
+			&rate.UpdatedBy, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// SetPerDiemRate creates or replaces the rate of a destination and records the change in
+// the audit log.
+//
+// Parameters:
+//   - rate: The validated rate with UpdatedBy and UpdatedAt set.
+//
+// Returns:
+//   - error: An error if the rate or its audit entry cannot be saved.
+func (store *DBExpenseStore) SetPerDiemRate(ctx context.Context, rate *models.PerDiemRate) error {
+	return store.setRate(ctx, "expense_per_diem_rate", rate, rate.UpdatedBy, rate.UpdatedAt,
+		`INSERT INTO expense_per_diem_rates (destination, daily_rate, long_stay_rate, long_stay_after, updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (destination) DO UPDATE SET daily_rate = EXCLUDED.daily_rate, long_stay_rate = EXCLUDED.long_stay_rate,
+		     long_stay_after = EXCLUDED.long_stay_after, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		rate.Destination, rate.DailyRate, rate.LongStayRate, rate.LongStayAfter, rate.UpdatedBy, rate.UpdatedAt)
+}
+
+// DeletePerDiemRate removes the rate of a destination.
+//
+// Returns:
+//   - error: models.ErrNotFound if the destination has no rate, or an error if the delete fails.
+func (store *DBExpenseStore) DeletePerDiemRate(ctx context.Context, destination string) error {
+	result, err := store.DB.ExecContext(ctx, `DELETE FROM expense_per_diem_rates WHERE LOWER(destination) = LOWER($1)`, destination)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("no per-diem rate for %q", destination)
+	}
+	return nil
+}
+
+// setRate runs the upsert of a rate and records the rate in the audit log, in one transaction.
+func (store *DBExpenseStore) setRate(ctx context.Context, entityType string, rate interface{}, actor string, at time.Time,
+	query string, args ...interface{}) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	details, err := json.Marshal(rate)
+	if err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		Action:     audit.ActionExpenseRate,
+		EntityType: entityType,
+		Details:    details,
+		CreatedAt:  at,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
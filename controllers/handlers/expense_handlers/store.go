@@ -0,0 +1,272 @@
+package expense_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"erp/controllers/audit"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+)
+
+// DBExpenseStore provides SQL-backed methods for expense policies and claims.
+type DBExpenseStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// GetExpensePolicies retrieves the policy of every expense category, ordered by category.
+//
+// Returns:
+//   - []models.ExpensePolicy: The policies.
+//   - error: An error if the query fails.
+func (store *DBExpenseStore) GetExpensePolicies(ctx context.Context) ([]models.ExpensePolicy, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT category, spending_limit, hard_limit, receipt_threshold, per_diem_rate, updated_by, updated_at
+		 FROM expense_policies ORDER BY category`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []models.ExpensePolicy{}
+	for rows.Next() {
+		var policy models.ExpensePolicy
+		if err := rows.Scan(&policy.Category, &policy.Limit, &policy.HardLimit, &policy.ReceiptThreshold,
+			&policy.PerDiemRate, &policy.UpdatedBy, &policy.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// SetExpensePolicy creates or replaces the policy of a category and records the change in
+// the audit log, in one transaction.
+//
+// Parameters:
+//   - policy: The validated policy with UpdatedBy and UpdatedAt set.
+//
+// Returns:
+//   - error: An error if the policy or its audit entry cannot be saved.
+func (store *DBExpenseStore) SetExpensePolicy(ctx context.Context, policy *models.ExpensePolicy) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO expense_policies (category, spending_limit, hard_limit, receipt_threshold, per_diem_rate, updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (category) DO UPDATE SET spending_limit = EXCLUDED.spending_limit, hard_limit = EXCLUDED.hard_limit,
+		     receipt_threshold = EXCLUDED.receipt_threshold, per_diem_rate = EXCLUDED.per_diem_rate,
+		     updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		policy.Category, policy.Limit, policy.HardLimit, policy.ReceiptThreshold, policy.PerDiemRate,
+		policy.UpdatedBy, policy.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	details, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      policy.UpdatedBy,
+		Action:     audit.ActionExpensePolicy,
+		EntityType: "expense_policy",
+		Details:    details,
+		CreatedAt:  policy.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// claimColumns are the columns scanned by scanClaim.
+const claimColumns = `id, employee, purpose, status, items, total, violations, submitted_at, reviewed_by, reviewed_at,
+	review_note, overridden_by, overridden_at, override_reason, reimbursed_by, reimbursed_at`
+
+// scanClaim reads a row selected with claimColumns.
+func scanClaim(row interface{ Scan(...interface{}) error }) (*models.ExpenseClaim, error) {
+	var claim models.ExpenseClaim
+	var items, violations []byte
+	var reviewedBy, overriddenBy, reimbursedBy sql.NullString
+	var reviewedAt, overriddenAt, reimbursedAt sql.NullTime
+	if err := row.Scan(&claim.ID, &claim.Employee, &claim.Purpose, &claim.Status, &items, &claim.Total, &violations,
+		&claim.SubmittedAt, &reviewedBy, &reviewedAt, &claim.ReviewNote, &overriddenBy, &overriddenAt,
+		&claim.OverrideReason, &reimbursedBy, &reimbursedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(items, &claim.Items); err != nil {
+		return nil, fmt.Errorf("expense claim %d: %w", claim.ID, err)
+	}
+	if err := json.Unmarshal(violations, &claim.Violations); err != nil {
+		return nil, fmt.Errorf("expense claim %d: %w", claim.ID, err)
+	}
+	claim.ReviewedBy, claim.ReviewedAt = nullString(reviewedBy), nullTime(reviewedAt)
+	claim.OverriddenBy, claim.OverriddenAt = nullString(overriddenBy), nullTime(overriddenAt)
+	claim.ReimbursedBy, claim.ReimbursedAt = nullString(reimbursedBy), nullTime(reimbursedAt)
+	return &claim, nil
+}
+
+// nullString returns the string of a nullable column, or nil.
+func nullString(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+// nullTime returns the time of a nullable column, or nil.
+func nullTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
+
+// CreateExpenseClaim saves a submitted claim with its items and violations.
+//
+// Parameters:
+//   - claim: The checked claim; its ID is set.
+//
+// Returns:
+//   - error: An error if the insert fails.
+func (store *DBExpenseStore) CreateExpenseClaim(ctx context.Context, claim *models.ExpenseClaim) error {
+	items, err := json.Marshal(claim.Items)
+	if err != nil {
+		return err
+	}
+	violations, err := json.Marshal(claim.Violations)
+	if err != nil {
+		return err
+	}
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO expense_claims (employee, purpose, status, items, total, violations, submitted_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		claim.Employee, claim.Purpose, claim.Status, items, claim.Total, violations, claim.SubmittedAt,
+	).Scan(&claim.ID)
+}
+
+// GetExpenseClaim retrieves a claim by its ID.
+//
+// Parameters:
+//   - id: The ID of the claim.
+//
+// Returns:
+//   - *models.ExpenseClaim: The claim.
+//   - error: models.ErrNotFound if the claim does not exist, or an error if the query fails.
+func (store *DBExpenseStore) GetExpenseClaim(ctx context.Context, id int) (*models.ExpenseClaim, error) {
+	claim, err := scanClaim(store.DB.QueryRowContext(ctx, `SELECT `+claimColumns+` FROM expense_claims WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("expense claim %d not found", id)
+	}
+	return claim, err
+}
+
+// GetExpenseClaims lists claims, newest first.
+//
+// Parameters:
+//   - employee: Email of the claimant, or "" for every claimant.
+//   - status: The status of the claims, or "" for every status.
+//
+// Returns:
+//   - []models.ExpenseClaim: The claims.
+//   - error: An error if the query fails.
+func (store *DBExpenseStore) GetExpenseClaims(ctx context.Context, employee, status string) ([]models.ExpenseClaim, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT `+claimColumns+` FROM expense_claims
+		 WHERE ($1 = '' OR LOWER(employee) = LOWER($1)) AND ($2 = '' OR status = $2)
+		 ORDER BY submitted_at DESC, id DESC`,
+		employee, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims := []models.ExpenseClaim{}
+	for rows.Next() {
+		claim, err := scanClaim(rows)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, *claim)
+	}
+	return claims, rows.Err()
+}
+
+// UpdateExpenseClaim saves the status, review and override of a claim, provided it is still
+// in status from.
+//
+// Parameters:
+//   - claim: The reviewed or overridden claim.
+//   - from: The status the claim was read in.
+//
+// Returns:
+//   - error: A models.Conflict error if the claim has changed since it was read, or an
+//     error if the update fails.
+func (store *DBExpenseStore) UpdateExpenseClaim(ctx context.Context, claim *models.ExpenseClaim, from string) error {
+	result, err := store.DB.ExecContext(ctx,
+		`UPDATE expense_claims SET status = $1, reviewed_by = $2, reviewed_at = $3, review_note = $4,
+		     overridden_by = $5, overridden_at = $6, override_reason = $7
+		 WHERE id = $8 AND status = $9`,
+		claim.Status, claim.ReviewedBy, claim.ReviewedAt, claim.ReviewNote,
+		claim.OverriddenBy, claim.OverriddenAt, claim.OverrideReason, claim.ID, from,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.Conflict("expense claim %d has changed; reload it and try again", claim.ID)
+	}
+	return nil
+}
+
+// ReimburseExpenseClaim marks an approved claim reimbursed and posts it to the ledger in one
+// transaction: its total is debited to expense and credited to cash.
+//
+// Parameters:
+//   - claim: The claim with its status, ReimbursedBy and ReimbursedAt set.
+//
+// Returns:
+//   - error: A models.Conflict error if the claim is no longer approved, or an error if it
+//     cannot be posted.
+func (store *DBExpenseStore) ReimburseExpenseClaim(ctx context.Context, claim *models.ExpenseClaim) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE expense_claims SET status = $1, reimbursed_by = $2, reimbursed_at = $3 WHERE id = $4 AND status = $5`,
+		claim.Status, claim.ReimbursedBy, claim.ReimbursedAt, claim.ID, models.ExpenseApproved,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.Conflict("expense claim %d is no longer approved", claim.ID)
+	}
+
+	description := fmt.Sprintf("Expense claim %d reimbursed to %s", claim.ID, claim.Employee)
+	for _, transaction := range []models.FinancialTransaction{
+		{AccountType: "expense", Amount: claim.Total, TransactionDate: *claim.ReimbursedAt, Description: description},
+		{AccountType: "cash", Amount: -claim.Total, TransactionDate: *claim.ReimbursedAt, Description: description},
+	} {
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &transaction); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
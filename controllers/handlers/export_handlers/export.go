@@ -0,0 +1,143 @@
+// Package export_handlers provides the admin API for the data warehouse export: starting a
+// run by hand, following runs and inspecting or resetting the per-table high-water marks.
+package export_handlers
+
+import (
+	"context"
+	"erp/controllers/export"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// listLimit is the number of recent runs listed.
+const listLimit = 50
+
+// Exporter starts export runs and manages their marks. It is satisfied by *export.Service.
+type Exporter interface {
+	Start(ctx context.Context, actor string) (*models.Job, error)
+	Watermarks(ctx context.Context) ([]models.ExportWatermark, error)
+	ResetWatermark(ctx context.Context, table string) error
+}
+
+// ExportHandler provides HTTP handlers for the data warehouse export.
+type ExportHandler struct {
+	Exports Exporter
+	Jobs    models.JobStore
+}
+
+// RegisterRoutes maps export routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth and limited to admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - exports: Starts export runs and manages their marks.
+//   - jobs: An implementation of the JobStore interface.
+func RegisterRoutes(router *mux.Router, exports Exporter, jobs models.JobStore) {
+	handler := &ExportHandler{Exports: exports, Jobs: jobs}
+
+	router.HandleFunc("", handler.CreateExport).Methods("POST")
+	router.HandleFunc("", handler.ListExports).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetExport).Methods("GET")
+	router.HandleFunc("/watermarks", handler.ListWatermarks).Methods("GET")
+	router.HandleFunc("/watermarks/{table}", handler.ResetWatermark).Methods("DELETE")
+}
+
+// CreateExport starts an export of the rows changed since the previous run.
+//
+// HTTP Method: POST
+// URL Path: /
+//
+// Response:
+//   - Status Code: 202 (Accepted) with the queued Job in JSON and its URL in the Location
+//     header. Poll it until the status is "succeeded" or "failed".
+//   - Status Code: 409 (Conflict) if an export is already running.
+//   - Status Code: 500 (Internal Server Error) if the job cannot be created.
+func (h *ExportHandler) CreateExport(w http.ResponseWriter, r *http.Request) {
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	job, err := h.Exports.Start(r.Context(), actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to start export")
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/exports/%d", job.ID))
+	respond.JSON(w, http.StatusAccepted, job)
+}
+
+// ListExports returns the most recent export runs, newest first. The result of a
+// successful run lists the files it wrote.
+//
+// HTTP Method: GET
+// URL Path: /
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of Jobs in JSON.
+func (h *ExportHandler) ListExports(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.Jobs.ListJobs(r.Context(), export.KindExport, listLimit)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load exports")
+		return
+	}
+	respond.JSON(w, http.StatusOK, jobs)
+}
+
+// GetExport returns an export run.
+//
+// HTTP Method: GET
+// URL Path: /{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the Job in JSON.
+//   - Status Code: 404 (Not Found) if no export run has the ID.
+func (h *ExportHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	job, err := h.Jobs.GetJob(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) || (err == nil && job.Kind != export.KindExport) {
+		respond.Error(w, http.StatusNotFound, "Export not found")
+		return
+	} else if err != nil {
+		httperr.Write(w, err, "Failed to load export")
+		return
+	}
+	respond.JSON(w, http.StatusOK, job)
+}
+
+// ListWatermarks returns the high-water mark of every table that has been exported.
+//
+// HTTP Method: GET
+// URL Path: /watermarks
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of ExportWatermarks in JSON.
+func (h *ExportHandler) ListWatermarks(w http.ResponseWriter, r *http.Request) {
+	marks, err := h.Exports.Watermarks(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load watermarks")
+		return
+	}
+	respond.JSON(w, http.StatusOK, marks)
+}
+
+// ResetWatermark forgets a table's high-water mark so the next run exports the whole table,
+// for example to rebuild it in the warehouse.
+//
+// HTTP Method: DELETE
+// URL Path: /watermarks/{table}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the mark was reset.
+//   - Status Code: 404 (Not Found) if the table has never been exported.
+func (h *ExportHandler) ResetWatermark(w http.ResponseWriter, r *http.Request) {
+	if err := h.Exports.ResetWatermark(r.Context(), mux.Vars(r)["table"]); err != nil {
+		httperr.Write(w, err, "Failed to reset watermark")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,125 @@
+package export_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erp/controllers/export"
+	"erp/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeExporter starts jobs unless busy and keeps marks in memory.
+type fakeExporter struct {
+	busy  bool
+	marks map[string]models.ExportWatermark
+}
+
+func (f *fakeExporter) Start(ctx context.Context, actor string) (*models.Job, error) {
+	if f.busy {
+		return nil, export.ErrRunning
+	}
+	return &models.Job{ID: 7, Kind: export.KindExport, Status: models.JobQueued, CreatedBy: actor}, nil
+}
+
+func (f *fakeExporter) Watermarks(ctx context.Context) ([]models.ExportWatermark, error) {
+	marks := []models.ExportWatermark{}
+	for _, mark := range f.marks {
+		marks = append(marks, mark)
+	}
+	return marks, nil
+}
+
+func (f *fakeExporter) ResetWatermark(ctx context.Context, table string) error {
+	if _, ok := f.marks[table]; !ok {
+		return models.NotFound("table %s has not been exported", table)
+	}
+	delete(f.marks, table)
+	return nil
+}
+
+// fakeJobStore serves a fixed set of jobs.
+type fakeJobStore struct {
+	models.JobStore
+	jobs map[int]*models.Job
+}
+
+func (f *fakeJobStore) GetJob(ctx context.Context, id int) (*models.Job, error) {
+	job, ok := f.jobs[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return job, nil
+}
+
+func (f *fakeJobStore) ListJobs(ctx context.Context, kind string, limit int) ([]models.Job, error) {
+	list := []models.Job{}
+	for _, job := range f.jobs {
+		if job.Kind == kind {
+			list = append(list, *job)
+		}
+	}
+	return list, nil
+}
+
+func setupRouter() (*mux.Router, *fakeExporter) {
+	exports := &fakeExporter{marks: map[string]models.ExportWatermark{
+		"customers": {Table: "customers", LastID: 5, RowsExported: 12},
+	}}
+	store := &fakeJobStore{jobs: map[int]*models.Job{
+		1: {ID: 1, Kind: export.KindExport, Status: models.JobSucceeded},
+		2: {ID: 2, Kind: "backup", Status: models.JobSucceeded},
+	}}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/exports").Subrouter(), exports, store)
+	return router, exports
+}
+
+func serve(router *mux.Router, method, path string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+	return rr
+}
+
+func TestCreateExport(t *testing.T) {
+	router, exports := setupRouter()
+
+	rr := serve(router, "POST", "/exports")
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "/exports/7", rr.Header().Get("Location"))
+
+	exports.busy = true
+	assert.Equal(t, http.StatusConflict, serve(router, "POST", "/exports").Code)
+}
+
+func TestListAndGetExports(t *testing.T) {
+	router, _ := setupRouter()
+
+	rr := serve(router, "GET", "/exports")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var jobs []models.Job
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&jobs))
+	assert.Len(t, jobs, 1)
+
+	assert.Equal(t, http.StatusOK, serve(router, "GET", "/exports/1").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/exports/2").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/exports/99").Code)
+}
+
+func TestWatermarks(t *testing.T) {
+	router, exports := setupRouter()
+
+	rr := serve(router, "GET", "/exports/watermarks")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var marks []models.ExportWatermark
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&marks))
+	assert.Len(t, marks, 1)
+
+	assert.Equal(t, http.StatusNoContent, serve(router, "DELETE", "/exports/watermarks/customers").Code)
+	assert.Empty(t, exports.marks)
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/exports/watermarks/customers").Code)
+}
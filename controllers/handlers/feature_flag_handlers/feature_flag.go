@@ -0,0 +1,178 @@
+package feature_flag_handlers
+
+import (
+	"encoding/json"
+	"erp/controllers/features"
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// flagKey restricts flag keys to dotted lowercase names such as "ledger.double_entry_posting".
+var flagKey = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)*$`)
+
+// FeatureFlagHandler provides HTTP handlers to manage and evaluate feature flags.
+type FeatureFlagHandler struct {
+	Flags *features.Service
+}
+
+// SaveFlagRequest is the request body for saving a feature flag. The key is taken from the
+// URL and the update time is set by the store.
+type SaveFlagRequest struct {
+	Description string   `json:"description"`
+	Enabled     bool     `json:"enabled"`
+	Roles       []string `json:"roles"`
+	Users       []string `json:"users"`
+}
+
+// Flag returns the flag with the given key as described by the request.
+func (req SaveFlagRequest) Flag(key string) models.FeatureFlag {
+	return models.FeatureFlag{
+		Key:         key,
+		Description: req.Description,
+		Enabled:     req.Enabled,
+		Roles:       trimAll(req.Roles),
+		Users:       trimAll(req.Users),
+	}
+}
+
+// RegisterRoutes maps the admin feature flag routes to their handler functions.
+// The router is expected to be restricted to administrators.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - flags: The feature flag service shared with the rest of the application.
+func RegisterRoutes(router *mux.Router, flags *features.Service) {
+	handler := &FeatureFlagHandler{Flags: flags}
+
+	router.HandleFunc("", handler.ListFlags).Methods("GET")
+	router.HandleFunc("/{key}", handler.SaveFlag).Methods("PUT")
+	router.HandleFunc("/{key}", handler.ResetFlag).Methods("DELETE")
+}
+
+// RegisterUserRoutes maps the route that tells the signed-in user which flags are on.
+// The router is expected to be protected with middleware.JWTAuth.
+func RegisterUserRoutes(router *mux.Router, flags *features.Service) {
+	handler := &FeatureFlagHandler{Flags: flags}
+
+	router.HandleFunc("/features", handler.GetUserFeatures).Methods("GET")
+}
+
+// ListFlags returns every flag with its targeting.
+//
+// HTTP Method: GET
+// URL Path: /
+//
+// Response:
+//   - Status Code: 200 (OK) with the flags in JSON.
+//   - Status Code: 500 (Internal Server Error) if the flags cannot be loaded.
+func (h *FeatureFlagHandler) ListFlags(w http.ResponseWriter, r *http.Request) {
+	flags, err := h.Flags.List(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load feature flags")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, flags)
+}
+
+// SaveFlag creates or replaces a flag.
+//
+// HTTP Method: PUT
+// URL Path: /{key}
+//
+// Request Body:
+//   - JSON with enabled, and optionally description, roles and users.
+//
+// Response:
+//   - Status Code: 200 (OK) with the saved flag in JSON.
+//   - Status Code: 400 (Bad Request) if the key or body is invalid.
+//   - Status Code: 500 (Internal Server Error) if the flag cannot be saved.
+func (h *FeatureFlagHandler) SaveFlag(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	if !flagKey.MatchString(key) {
+		respond.Error(w, http.StatusBadRequest, "Invalid flag key")
+		return
+	}
+
+	var req SaveFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	flag := req.Flag(key)
+	if flag.Description == "" {
+		flag.Description = features.Defaults[key].Description
+	}
+
+	if err := h.Flags.Save(r.Context(), &flag); err != nil {
+		httperr.Write(w, err, "Failed to save feature flag")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, flag)
+}
+
+// ResetFlag deletes a stored flag; known flags fall back to their default.
+//
+// HTTP Method: DELETE
+// URL Path: /{key}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the flag was deleted.
+//   - Status Code: 404 (Not Found) if the flag is not stored.
+func (h *FeatureFlagHandler) ResetFlag(w http.ResponseWriter, r *http.Request) {
+	err := h.Flags.Reset(r.Context(), mux.Vars(r)["key"])
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Feature flag not found")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to delete feature flag")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserFeatures returns the keys of the flags that are on for the signed-in user, so the
+// frontend can show or hide features.
+//
+// HTTP Method: GET
+// URL Path: /features
+//
+// Response:
+//   - Status Code: 200 (OK) with a JSON list of flag keys.
+//   - Status Code: 500 (Internal Server Error) if the flags cannot be loaded.
+func (h *FeatureFlagHandler) GetUserFeatures(w http.ResponseWriter, r *http.Request) {
+	flags, err := h.Flags.List(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load feature flags")
+		return
+	}
+
+	enabled := []string{}
+	for _, flag := range flags {
+		if h.Flags.EnabledForRequest(r, flag.Key) {
+			enabled = append(enabled, flag.Key)
+		}
+	}
+
+	respond.JSON(w, http.StatusOK, enabled)
+}
+
+// trimAll trims the values and drops empty ones.
+func trimAll(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+// Package feature_flag_handlers provides SQL-backed storage and HTTP handlers for feature flags.
+package feature_flag_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBFeatureFlagStore provides SQL-backed methods for the feature_flags table.
+type DBFeatureFlagStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// ListFeatureFlags returns every stored flag.
+//
+// Returns:
+//   - []FeatureFlag: The stored flags.
+//   - error: An error if the query fails.
+func (store *DBFeatureFlagStore) ListFeatureFlags(ctx context.Context) ([]models.FeatureFlag, error) {
+	rows, err := store.DB.QueryContext(ctx, "SELECT key, description, enabled, roles, users, updated_at FROM feature_flags ORDER BY key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []models.FeatureFlag{}
+	for rows.Next() {
+		var flag models.FeatureFlag
+		if err := rows.Scan(&flag.Key, &flag.Description, &flag.Enabled, pq.Array(&flag.Roles), pq.Array(&flag.Users), &flag.UpdatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// SaveFeatureFlag creates or replaces a flag.
+//
+// Parameters:
+//   - flag: The flag to store; UpdatedAt is set to the current time.
+//
+// Returns:
+//   - error: An error if the flag cannot be stored.
+func (store *DBFeatureFlagStore) SaveFeatureFlag(ctx context.Context, flag *models.FeatureFlag) error {
+	flag.UpdatedAt = time.Now()
+	_, err := store.DB.ExecContext(ctx,
+		`INSERT INTO feature_flags (key, description, enabled, roles, users, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (key) DO UPDATE SET description = EXCLUDED.description, enabled = EXCLUDED.enabled,
+		     roles = EXCLUDED.roles, users = EXCLUDED.users, updated_at = EXCLUDED.updated_at`,
+		flag.Key, flag.Description, flag.Enabled, pq.Array(flag.Roles), pq.Array(flag.Users), flag.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteFeatureFlag removes a stored flag.
+//
+// Parameters:
+//   - key: The flag key.
+//
+// Returns:
+//   - error: models.ErrNotFound if the flag is not stored.
+func (store *DBFeatureFlagStore) DeleteFeatureFlag(ctx context.Context, key string) error {
+	result, err := store.DB.ExecContext(ctx, "DELETE FROM feature_flags WHERE key = $1", key)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
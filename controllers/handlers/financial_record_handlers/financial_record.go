@@ -3,11 +3,15 @@
 package financial_record_handlers
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"erp/controllers/httperr"
+	"erp/controllers/pagination"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
 	"erp/models"
 
 	"github.com/gorilla/mux"
@@ -19,6 +23,41 @@ type FinancialRecordHandler struct {
 	RecordStore models.FinancialRecordStore // RecordStore is the interface for managing financial records in the database.
 }
 
+// RecordRequest is the request body for creating or updating a financial record. The ID is
+// assigned by the database or taken from the URL.
+type RecordRequest struct {
+	TransactionID   int       `json:"transaction_id"`
+	AccountID       int       `json:"account_id"`
+	Amount          float64   `json:"amount"`
+	TransactionDate time.Time `json:"transaction_date"`
+	TransactionType string    `json:"transaction_type"`
+	Description     string    `json:"description"`
+	Version         int       `json:"version"` // The version updated, unless sent in If-Match; ignored on create
+}
+
+// Record returns the financial record described by the request.
+func (req RecordRequest) Record() models.FinancialRecord {
+	return models.FinancialRecord{
+		TransactionID:   req.TransactionID,
+		AccountID:       req.AccountID,
+		Amount:          req.Amount,
+		TransactionDate: req.TransactionDate,
+		TransactionType: req.TransactionType,
+		Description:     req.Description,
+	}
+}
+
+// Validate checks that the record charges a positive amount to an account on a date.
+func (req RecordRequest) Validate() error {
+	var c validation.Checker
+	c.Check(req.TransactionID >= 0, "transaction_id", "must be the ID of an existing record or 0")
+	c.ID("account_id", req.AccountID)
+	c.Positive("amount", req.Amount)
+	c.Date("transaction_date", req.TransactionDate)
+	c.Required("transaction_type", req.TransactionType)
+	return c.Err()
+}
+
 // RegisterRoutes registers the HTTP routes for managing financial records.
 //
 // Parameters:
@@ -29,6 +68,7 @@ func RegisterRoutes(router *mux.Router, recordStore models.FinancialRecordStore)
 
 	// Register routes for financial record management
 	router.HandleFunc("/records", handler.CreateRecord).Methods("POST")
+	router.HandleFunc("/records", handler.ListRecords).Methods("GET")
 	router.HandleFunc("/records/{id:[0-9]+}", handler.GetRecord).Methods("GET")
 	router.HandleFunc("/records/{id:[0-9]+}", handler.UpdateRecord).Methods("PUT")
 	router.HandleFunc("/records/{id:[0-9]+}", handler.DeleteRecord).Methods("DELETE")
@@ -40,30 +80,58 @@ func RegisterRoutes(router *mux.Router, recordStore models.FinancialRecordStore)
 // URL Path: /records
 //
 // Request Body:
-//   - JSON representation of a FinancialRecord object (excluding the ID).
+//   - JSON with transaction_id, account_id, amount, transaction_date, transaction_type and description (see RecordRequest).
 //
 // Response:
 //   - Status Code: 201 (Created) if the record is successfully created.
 //   - JSON representation of the created record on success.
-//   - Status Code: 400 (Bad Request) if the input data is invalid.
+//   - Status Code: 400 (Bad Request) if the input data is not JSON.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid (the error details list them),
+//     or the account is not in the chart of accounts or is inactive.
 //   - Status Code: 500 (Internal Server Error) if the record creation fails.
 func (h *FinancialRecordHandler) CreateRecord(w http.ResponseWriter, r *http.Request) {
-	var record models.FinancialRecord
-	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
-		http.Error(w, "Invalid input data", http.StatusBadRequest)
+	var req RecordRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	if err := h.RecordStore.CreateFinancialRecord(&record); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create financial record: %v", err), http.StatusInternalServerError)
+	record := req.Record()
+	if err := h.RecordStore.CreateFinancialRecord(r.Context(), &record); err != nil {
+		httperr.Write(w, err, "Failed to create financial record")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(record); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	respond.JSON(w, http.StatusCreated, record)
+}
+
+// ListRecords handles HTTP GET requests to list financial records a page at a time.
+//
+// HTTP Method: GET
+// URL Path: /records?account_id=4&transaction_date=2024-03-31&sort=-amount
+//
+// Query Parameters:
+//   - page, limit: The page number (from 1) and its size (default 50, at most 200).
+//   - sort: id, transaction_id, account_id, amount, transaction_date or transaction_type,
+//     prefixed with "-" for descending order.
+//   - The same fields as filters; transaction_type is matched case-insensitively and
+//     transaction_date matches a day formatted as YYYY-MM-DD.
+//
+// Response:
+//   - Status Code: 200 (OK) with {"items": [...], "total": n, "page": p, "limit": l} in JSON format.
+//   - Status Code: 422 (Unprocessable Entity) if a parameter is invalid.
+//   - Status Code: 500 (Internal Server Error) if the records cannot be listed.
+func (h *FinancialRecordHandler) ListRecords(w http.ResponseWriter, r *http.Request) {
+	query, err := pagination.Parse(r, RecordColumns)
+	if err != nil {
+		httperr.Write(w, err, "Invalid list query")
+		return
 	}
+	records, total, err := h.RecordStore.ListFinancialRecords(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Failed to list records")
+		return
+	}
+	pagination.Write(w, records, total, query)
 }
 
 // GetRecord handles HTTP GET requests to retrieve a financial record by its ID.
@@ -72,26 +140,25 @@ func (h *FinancialRecordHandler) CreateRecord(w http.ResponseWriter, r *http.Req
 // URL Path: /records/{id}
 //
 // Response:
-//   - Status Code: 200 (OK) with the record data in JSON format if found.
+//   - Status Code: 200 (OK) with the record data in JSON format if found, and its version as
+//     the ETag.
 //   - Status Code: 400 (Bad Request) if the ID is invalid.
 //   - Status Code: 404 (Not Found) if the record with the specified ID does not exist.
 func (h *FinancialRecordHandler) GetRecord(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid record ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid record ID")
 		return
 	}
 
-	record, err := h.RecordStore.GetFinancialRecordByID(id)
+	record, err := h.RecordStore.GetFinancialRecordByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Record not found: %v", err), http.StatusNotFound)
+		httperr.Write(w, err, "Failed to load record")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(record); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	versioning.SetETag(w, record.Version)
+	respond.JSON(w, http.StatusOK, record)
 }
 
 // UpdateRecord handles HTTP PUT requests to update an existing financial record.
@@ -100,36 +167,46 @@ func (h *FinancialRecordHandler) GetRecord(w http.ResponseWriter, r *http.Reques
 // URL Path: /records/{id}
 //
 // Request Body:
-//   - JSON representation of a FinancialRecord object (excluding the ID, which is taken from the URL).
+//   - JSON with the fields of a RecordRequest, including the version read; the ID is taken
+//     from the URL, and the version may be sent as an If-Match header instead.
 //
 // Response:
-//   - Status Code: 200 (OK) with the updated record data in JSON format if successful.
+//   - Status Code: 200 (OK) with the updated record data in JSON format if successful, and
+//     its new version as the ETag.
 //   - Status Code: 400 (Bad Request) if the ID or input data is invalid.
+//   - Status Code: 404 (Not Found) if the record does not exist.
+//   - Status Code: 409 (Conflict) if the record was changed since the version read.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid (the error details list them),
+//     or the account is not in the chart of accounts, or is inactive and not the record's
+//     current account.
+//   - Status Code: 428 (Precondition Required) if no version is given.
 //   - Status Code: 500 (Internal Server Error) if the update operation fails.
 func (h *FinancialRecordHandler) UpdateRecord(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid record ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid record ID")
 		return
 	}
 
-	var record models.FinancialRecord
-	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
-		http.Error(w, "Invalid input data", http.StatusBadRequest)
+	var req RecordRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	record.ID = id
-	if err := h.RecordStore.UpdateFinancialRecord(&record); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update record: %v", err), http.StatusInternalServerError)
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(record); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	record := req.Record()
+	record.ID, record.Version = id, version
+	if err := h.RecordStore.UpdateFinancialRecord(r.Context(), &record); err != nil {
+		httperr.Write(w, err, "Failed to update record")
+		return
 	}
+
+	versioning.SetETag(w, record.Version)
+	respond.JSON(w, http.StatusOK, record)
 }
 
 // DeleteRecord handles HTTP DELETE requests to delete a financial record by its ID.
@@ -140,16 +217,17 @@ func (h *FinancialRecordHandler) UpdateRecord(w http.ResponseWriter, r *http.Req
 // Response:
 //   - Status Code: 204 (No Content) if the record is successfully deleted.
 //   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the record does not exist.
 //   - Status Code: 500 (Internal Server Error) if the deletion operation fails.
 func (h *FinancialRecordHandler) DeleteRecord(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid record ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid record ID")
 		return
 	}
 
-	if err := h.RecordStore.DeleteFinancialRecord(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete record: %v", err), http.StatusInternalServerError)
+	if err := h.RecordStore.DeleteFinancialRecord(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete record")
 		return
 	}
 
@@ -8,6 +8,7 @@
 package financial_record_handlers
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -175,6 +176,7 @@ func TestUpdateRecord(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
+	req.Header.Set("If-Match", `"1"`)
 
 	// Create mock HTTP recorder
 	rr := httptest.NewRecorder()
@@ -228,6 +230,47 @@ func TestDeleteRecord(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, rr.Code)
 }
 
+// TestListRecords tests listing financial records a page at a time.
+// It sends a GET request with a filter, a sort order and a page, and asserts that the
+// parsed query reaches the store and the page is returned with the total count.
+func TestListRecords(t *testing.T) {
+	var received models.ListQuery
+	mockStore := &MockFinancialRecordStore{
+		ListFinancialRecordsFn: func(query models.ListQuery) ([]models.FinancialRecord, int, error) {
+			received = query
+			return []models.FinancialRecord{{ID: 7, AccountID: 456, Amount: 250, TransactionType: "Debit"}}, 11, nil
+		},
+	}
+
+	req, err := http.NewRequest("GET", "/records?account_id=456&sort=-amount&page=2&limit=5", nil)
+	if err != nil {
+		t.Fatalf("Could not create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	r := mux.NewRouter()
+	RegisterRoutes(r, mockStore)
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.ListQuery{Page: 2, Limit: 5, Sort: "amount", Desc: true, Filters: map[string]string{"account_id": "456"}}, received)
+	var page struct {
+		Items []models.FinancialRecord `json:"items"`
+		Total int                      `json:"total"`
+		Page  int                      `json:"page"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&page)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, page.Total)
+	assert.Equal(t, 2, page.Page)
+	assert.Len(t, page.Items, 1)
+
+	// Dates that are not formatted as YYYY-MM-DD are rejected
+	req, _ = http.NewRequest("GET", "/records?transaction_date=17/11/2024", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
 // MockFinancialRecordStore is a mock implementation of the FinancialRecordStore interface.
 // It is used to simulate interactions with a data store during testing.
 type MockFinancialRecordStore struct {
@@ -236,29 +279,30 @@ type MockFinancialRecordStore struct {
 	UpdateFinancialRecordFn    func(record *models.FinancialRecord) error
 	DeleteFinancialRecordFn    func(id int) error
 	GetAllFinancialRecordsFn   func() ([]models.FinancialRecord, error) // Added this function
+	ListFinancialRecordsFn     func(query models.ListQuery) ([]models.FinancialRecord, int, error)
 }
 
 // CreateFinancialRecord simulates the creation of a financial record in the store.
 // It invokes the mock function CreateFinancialRecordFn.
-func (m *MockFinancialRecordStore) CreateFinancialRecord(record *models.FinancialRecord) error {
+func (m *MockFinancialRecordStore) CreateFinancialRecord(ctx context.Context, record *models.FinancialRecord) error {
 	return m.CreateFinancialRecordFn(record)
 }
 
 // GetFinancialRecordByID retrieves a financial record by its ID from the mock store.
 // It invokes the mock function GetFinancialRecordByIDFn.
-func (m *MockFinancialRecordStore) GetFinancialRecordByID(id int) (*models.FinancialRecord, error) {
+func (m *MockFinancialRecordStore) GetFinancialRecordByID(ctx context.Context, id int) (*models.FinancialRecord, error) {
 	return m.GetFinancialRecordByIDFn(id)
 }
 
 // UpdateFinancialRecord simulates the updating of a financial record in the store.
 // It invokes the mock function UpdateFinancialRecordFn.
-func (m *MockFinancialRecordStore) UpdateFinancialRecord(record *models.FinancialRecord) error {
+func (m *MockFinancialRecordStore) UpdateFinancialRecord(ctx context.Context, record *models.FinancialRecord) error {
 	return m.UpdateFinancialRecordFn(record)
 }
 
 // DeleteFinancialRecord simulates the deletion of a financial record in the store.
 // It invokes the mock function DeleteFinancialRecordFn.
-func (m *MockFinancialRecordStore) DeleteFinancialRecord(id int) error {
+func (m *MockFinancialRecordStore) DeleteFinancialRecord(ctx context.Context, id int) error {
 	return m.DeleteFinancialRecordFn(id)
 }
 
@@ -267,3 +311,9 @@ func (m *MockFinancialRecordStore) DeleteFinancialRecord(id int) error {
 func (m *MockFinancialRecordStore) GetAllFinancialRecords() ([]models.FinancialRecord, error) {
 	return m.GetAllFinancialRecordsFn()
 }
+
+// ListFinancialRecords retrieves a page of financial records from the mock store.
+// It invokes the mock function ListFinancialRecordsFn.
+func (m *MockFinancialRecordStore) ListFinancialRecords(ctx context.Context, query models.ListQuery) ([]models.FinancialRecord, int, error) {
+	return m.ListFinancialRecordsFn(query)
+}
@@ -4,9 +4,11 @@
 package financial_record_handlers
 
 import (
+	"context"
 	"database/sql"
+	"erp/controllers/pagination"
+	"erp/controllers/versioning"
 	"erp/models"
-	"fmt"
 )
 
 // DBFinancialRecordStore provides SQL-backed methods to manage financial records.
@@ -24,12 +26,16 @@ type DBFinancialRecordStore struct {
 //   - financialRecord: A pointer to the FinancialRecord object containing the details of the record to be created.
 //
 // Returns:
-//   - An error if the operation fails, or nil if the record is successfully created.
-func (store *DBFinancialRecordStore) CreateFinancialRecord(financialRecord *models.FinancialRecord) error {
-	return store.DB.QueryRow(
-		"INSERT INTO financial_records (transaction_id, account_id, amount, transaction_date, transaction_type, description) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+//   - A validation error if the account does not exist or is inactive, an error if the
+//     operation fails, or nil if the record is successfully created.
+func (store *DBFinancialRecordStore) CreateFinancialRecord(ctx context.Context, financialRecord *models.FinancialRecord) error {
+	if err := store.checkAccount(ctx, financialRecord.AccountID, 0); err != nil {
+		return err
+	}
+	return store.DB.QueryRowContext(ctx,
+		"INSERT INTO financial_records (transaction_id, account_id, amount, transaction_date, transaction_type, description) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, version",
 		financialRecord.TransactionID, financialRecord.AccountID, financialRecord.Amount, financialRecord.TransactionDate, financialRecord.TransactionType, financialRecord.Description,
-	).Scan(&financialRecord.ID)
+	).Scan(&financialRecord.ID, &financialRecord.Version)
 }
 
 // GetFinancialRecordByID retrieves a financial record from the database by its ID.
@@ -39,54 +45,94 @@ func (store *DBFinancialRecordStore) CreateFinancialRecord(financialRecord *mode
 //
 // Returns:
 //   - A pointer to the FinancialRecord object if the record is found.
-//   - An error if the record does not exist or if the operation fails.
-func (store *DBFinancialRecordStore) GetFinancialRecordByID(id int) (*models.FinancialRecord, error) {
-	row := store.DB.QueryRow("SELECT id, transaction_id, account_id, amount, transaction_date, transaction_type, description FROM financial_records WHERE id = $1", id)
+//   - models.ErrNotFound if the record does not exist, or an error if the operation fails.
+func (store *DBFinancialRecordStore) GetFinancialRecordByID(ctx context.Context, id int) (*models.FinancialRecord, error) {
+	row := store.DB.QueryRowContext(ctx, "SELECT id, transaction_id, account_id, amount, transaction_date, transaction_type, description, version FROM financial_records WHERE id = $1 AND deleted_at IS NULL", id)
 
 	var financialRecord models.FinancialRecord
-	err := row.Scan(&financialRecord.ID, &financialRecord.TransactionID, &financialRecord.AccountID, &financialRecord.Amount, &financialRecord.TransactionDate, &financialRecord.TransactionType, &financialRecord.Description)
+	err := row.Scan(&financialRecord.ID, &financialRecord.TransactionID, &financialRecord.AccountID, &financialRecord.Amount, &financialRecord.TransactionDate, &financialRecord.TransactionType, &financialRecord.Description, &financialRecord.Version)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("financial record %d not found", id)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &financialRecord, nil
 }
 
-// UpdateFinancialRecord updates an existing financial record in the database.
+// RecordColumns are the fields financial records can be listed by.
+var RecordColumns = pagination.Columns{
+	"id":               {Expr: "id", Type: pagination.Int},
+	"transaction_id":   {Expr: "transaction_id", Type: pagination.Int},
+	"account_id":       {Expr: "account_id", Type: pagination.Int},
+	"amount":           {Expr: "amount", Type: pagination.Number},
+	"transaction_date": {Expr: "transaction_date", Type: pagination.Date},
+	"transaction_type": {Expr: "transaction_type", Type: pagination.Text},
+}
+
+// ListFinancialRecords retrieves a page of financial records from the database.
 //
 // Parameters:
-//   - financialRecord: A pointer to the FinancialRecord object containing updated details. The ID field must be set.
+//   - query: The page, sort order and filters (see RecordColumns).
 //
 // Returns:
-//   - An error if the operation fails, or if no rows are affected (indicating the record does not exist).
-func (store *DBFinancialRecordStore) UpdateFinancialRecord(financialRecord *models.FinancialRecord) error {
-	result, err := store.DB.Exec(
-		"UPDATE financial_records SET transaction_id = $1, account_id = $2, amount = $3, transaction_date = $4, transaction_type = $5, description = $6 WHERE id = $7",
-		financialRecord.TransactionID, financialRecord.AccountID, financialRecord.Amount, financialRecord.TransactionDate, financialRecord.TransactionType, financialRecord.Description, financialRecord.ID,
-	)
+//   - The page of records and the number of records matching the filters.
+//   - A validation error if a filter is invalid, or an error if the operation fails.
+func (store *DBFinancialRecordStore) ListFinancialRecords(ctx context.Context, query models.ListQuery) ([]models.FinancialRecord, int, error) {
+	records := []models.FinancialRecord{}
+	total, err := pagination.QueryWhere(ctx, store.DB,
+		"id, transaction_id, account_id, amount, transaction_date, transaction_type, description, version", "financial_records",
+		"deleted_at IS NULL", RecordColumns, query, func(rows *sql.Rows) error {
+			var record models.FinancialRecord
+			if err := rows.Scan(&record.ID, &record.TransactionID, &record.AccountID, &record.Amount, &record.TransactionDate,
+				&record.TransactionType, &record.Description, &record.Version); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
+	return records, total, nil
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+// UpdateFinancialRecord updates an existing financial record in the database if it is still
+// at financialRecord.Version, and sets the new version.
+//
+// Parameters:
+//   - financialRecord: A pointer to the FinancialRecord object containing updated details. The ID and Version fields must be set.
+//
+// Returns:
+//   - A validation error if the account does not exist, or is inactive and not the one the
+//     record already had.
+//   - models.ErrNotFound if the record does not exist, a conflict if it was changed since
+//     financialRecord.Version, or an error if the operation fails.
+func (store *DBFinancialRecordStore) UpdateFinancialRecord(ctx context.Context, financialRecord *models.FinancialRecord) error {
+	if err := store.checkAccount(ctx, financialRecord.AccountID, financialRecord.ID); err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("financial record with ID %d does not exist", financialRecord.ID)
+	err := store.DB.QueryRowContext(ctx,
+		"UPDATE financial_records SET transaction_id = $1, account_id = $2, amount = $3, transaction_date = $4, transaction_type = $5, description = $6 WHERE id = $7 AND version = $8 AND deleted_at IS NULL RETURNING version",
+		financialRecord.TransactionID, financialRecord.AccountID, financialRecord.Amount, financialRecord.TransactionDate, financialRecord.TransactionType, financialRecord.Description, financialRecord.ID, financialRecord.Version,
+	).Scan(&financialRecord.Version)
+	if err == sql.ErrNoRows {
+		return versioning.Stale(ctx, store.DB, "SELECT version FROM financial_records WHERE id = $1 AND deleted_at IS NULL",
+			"financial record", financialRecord.ID, financialRecord.Version)
 	}
-
-	return nil
+	return err
 }
 
-// DeleteFinancialRecord deletes a financial record from the database by its ID.
+// DeleteFinancialRecord soft deletes a financial record by its ID: the record is hidden from
+// reads until it is restored or purged.
 //
 // Parameters:
 //   - id: The unique identifier of the financial record to be deleted.
 //
 // Returns:
-//   - An error if the operation fails, or if no rows are affected (indicating the record does not exist).
-func (store *DBFinancialRecordStore) DeleteFinancialRecord(id int) error {
-	result, err := store.DB.Exec("DELETE FROM financial_records WHERE id = $1", id)
+//   - models.ErrNotFound if the record does not exist, or an error if the operation fails.
+func (store *DBFinancialRecordStore) DeleteFinancialRecord(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, "UPDATE financial_records SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
 		return err
 	}
@@ -96,8 +142,36 @@ func (store *DBFinancialRecordStore) DeleteFinancialRecord(id int) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("financial record with ID %d does not exist", id)
+		return models.NotFound("financial record %d not found", id)
 	}
 
 	return nil
 }
+
+// checkAccount makes sure a record references an account of the chart of accounts. New
+// records need an active account; a record may keep the account it already has after the
+// account is deactivated.
+//
+// Parameters:
+//   - accountID: The account the record references.
+//   - recordID: The ID of the record being updated, or 0 for a new record.
+//
+// Returns:
+//   - A validation error if the account does not exist or cannot be used, or the query error.
+func (store *DBFinancialRecordStore) checkAccount(ctx context.Context, accountID, recordID int) error {
+	var usable bool
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT a.active OR EXISTS (SELECT 1 FROM financial_records r WHERE r.id = $2 AND r.account_id = a.id)
+		 FROM accounts a WHERE a.id = $1`, accountID, recordID,
+	).Scan(&usable)
+	if err == sql.ErrNoRows {
+		return models.Invalid("account %d does not exist", accountID)
+	}
+	if err != nil {
+		return err
+	}
+	if !usable {
+		return models.Invalid("account %d is inactive", accountID)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package forecast_handlers provides HTTP handlers and the database store for the cash flow
+// forecast and the recurring invoices it expects to collect.
+package forecast_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/forecasting"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// ForecastHandler provides HTTP handlers for cash forecasting.
+type ForecastHandler struct {
+	Service *forecasting.Service
+}
+
+// RegisterRoutes maps forecasting routes to their handler functions. The router is expected
+// to be protected with middleware.JWTAuth and limited to finance.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The forecast handler.
+func RegisterRoutes(router *mux.Router, handler *ForecastHandler) {
+	router.HandleFunc("/cash", handler.GetCashForecast).Methods("GET")
+	router.HandleFunc("/recurring_invoices", handler.ListRecurringInvoices).Methods("GET")
+	router.HandleFunc("/recurring_invoices", handler.CreateRecurringInvoice).Methods("POST")
+	router.HandleFunc("/recurring_invoices/{id:[0-9]+}", handler.DeleteRecurringInvoice).Methods("DELETE")
+}
+
+// GetCashForecast projects the cash balance by week from today, in the expected, optimistic
+// and pessimistic scenarios.
+//
+// HTTP Method: GET
+// URL Path: /forecasts/cash?horizon=90d (optional; days or weeks such as 12w, 90 days by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with the CashForecast in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the horizon is invalid or over 365 days.
+//   - Status Code: 500 (Internal Server Error) if the forecast cannot be worked out.
+func (h *ForecastHandler) GetCashForecast(w http.ResponseWriter, r *http.Request) {
+	forecast, err := h.Service.Cash(r.Context(), r.URL.Query().Get("horizon"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to forecast cash")
+		return
+	}
+	respond.JSON(w, http.StatusOK, forecast)
+}
+
+// ListRecurringInvoices lists the recurring invoices.
+//
+// HTTP Method: GET
+// URL Path: /forecasts/recurring_invoices
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of RecurringInvoices in JSON.
+//   - Status Code: 500 (Internal Server Error) if the invoices cannot be loaded.
+func (h *ForecastHandler) ListRecurringInvoices(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Service.RecurringInvoices(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load recurring invoices")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// CreateRecurringInvoice records an amount a customer is invoiced every few months.
+//
+// HTTP Method: POST
+// URL Path: /forecasts/recurring_invoices
+//
+// Request Body:
+//   - JSON with customer_id, description, amount, interval_months (1 to 12), starts_on and
+//     optionally ends_on.
+//
+// Response:
+//   - Status Code: 201 (Created) with the recurring invoice in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if a field is invalid or the customer does not exist.
+//   - Status Code: 500 (Internal Server Error) if the invoice cannot be recorded.
+func (h *ForecastHandler) CreateRecurringInvoice(w http.ResponseWriter, r *http.Request) {
+	var invoice models.RecurringInvoice
+	if err := json.NewDecoder(r.Body).Decode(&invoice); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.CreateRecurringInvoice(r.Context(), &invoice, actor); err != nil {
+		httperr.Write(w, err, "Failed to create recurring invoice")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, invoice)
+}
+
+// DeleteRecurringInvoice removes a recurring invoice.
+//
+// HTTP Method: DELETE
+// URL Path: /forecasts/recurring_invoices/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the invoice is removed.
+//   - Status Code: 404 (Not Found) if the invoice does not exist.
+//   - Status Code: 500 (Internal Server Error) if the invoice cannot be removed.
+func (h *ForecastHandler) DeleteRecurringInvoice(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.DeleteRecurringInvoice(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete recurring invoice")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
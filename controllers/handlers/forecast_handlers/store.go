@@ -0,0 +1,177 @@
+package forecast_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"erp/models"
+)
+
+// DBForecastStore implements models.CashForecastStore using a SQL database.
+type DBForecastStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// postedOn is the day an invoice was posted to the receivable account.
+const postedOn = `(SELECT MIN(transaction_date) FROM financial_transactions
+	WHERE invoice_id = i.id AND account_type = 'accounts_receivable')`
+
+// CashBalance returns the ledger balance of an account at the end of a day.
+func (s *DBForecastStore) CashBalance(ctx context.Context, account string, asOf time.Time) (float64, error) {
+	var balance float64
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM financial_transactions WHERE account_type = $1 AND transaction_date <= $2`,
+		account, asOf).Scan(&balance)
+	return balance, err
+}
+
+// OpenReceivables returns the posted invoices with a balance, oldest first. The balance is
+// net of payments, applied deposits and amounts allowed on resolved disputes.
+func (s *DBForecastStore) OpenReceivables(ctx context.Context) ([]models.OpenReceivable, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, customer_id, posted_on, balance, disputed FROM (
+		     SELECT i.id, COALESCE(i.customer_id, 0) AS customer_id, `+postedOn+` AS posted_on,
+		         i.amount - (SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = i.id AND deleted_at IS NULL)
+		             - (SELECT COALESCE(SUM(amount), 0) FROM customer_deposit_applications WHERE invoice_id = i.id)
+		             - (SELECT COALESCE(SUM(resolved_amount), 0) FROM invoice_disputes WHERE invoice_id = i.id) AS balance,
+		         EXISTS (SELECT 1 FROM invoice_disputes WHERE invoice_id = i.id AND status = $2) AS disputed
+		     FROM invoices i WHERE i.status = $1 AND i.deleted_at IS NULL
+		 ) open_invoices
+		 WHERE posted_on IS NOT NULL AND balance > 0
+		 ORDER BY posted_on, id`,
+		models.InvoiceStatusPosted, models.DisputeOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.OpenReceivable{}
+	for rows.Next() {
+		var r models.OpenReceivable
+		if err := rows.Scan(&r.InvoiceID, &r.CustomerID, &r.PostedOn, &r.Balance, &r.Disputed); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// PaymentTimings returns the customers' payments on posted invoices made since a day, with
+// the days from posting to payment.
+func (s *DBForecastStore) PaymentTimings(ctx context.Context, since time.Time) ([]models.PaymentTiming, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT customer_id, payment_date - posted_on, amount FROM (
+		     SELECT i.customer_id, p.payment_date, p.amount, `+postedOn+` AS posted_on
+		     FROM payments p JOIN invoices i ON i.id = p.invoice_id
+		     WHERE i.customer_id IS NOT NULL AND p.payment_date >= $1 AND p.status = $2 AND p.deleted_at IS NULL
+		 ) paid
+		 WHERE posted_on IS NOT NULL`,
+		since, models.PaymentApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.PaymentTiming{}
+	for rows.Next() {
+		var t models.PaymentTiming
+		if err := rows.Scan(&t.CustomerID, &t.Days, &t.Amount); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// OpenPayables returns the parts of supplier bills not settled by advances and the payments
+// waiting for approval in accounts payable, oldest first.
+func (s *DBForecastStore) OpenPayables(ctx context.Context) ([]models.OpenPayable, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT $1, id, bill_date, amount - advance_applied FROM supplier_bills WHERE amount > advance_applied
+		 UNION ALL
+		 SELECT $2, id, payment_date, amount FROM payments WHERE status = $3 AND deleted_at IS NULL
+		 ORDER BY 3, 2`,
+		models.PayableSupplierBill, models.PayablePendingPayment, models.PaymentPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.OpenPayable{}
+	for rows.Next() {
+		var p models.OpenPayable
+		if err := rows.Scan(&p.Source, &p.ID, &p.Date, &p.Amount); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// LatestPayroll returns the gross pay and employer contributions of the last period with
+// statutory records, or nil if there is none.
+func (s *DBForecastStore) LatestPayroll(ctx context.Context) (*models.PayrollCost, error) {
+	var cost models.PayrollCost
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT period, COUNT(*), SUM(gross), SUM(total_employer) FROM statutory_records
+		 WHERE period = (SELECT MAX(period) FROM statutory_records) GROUP BY period`,
+	).Scan(&cost.Period, &cost.Employees, &cost.Gross, &cost.Employer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cost, nil
+}
+
+// CreateRecurringInvoice records a recurring invoice.
+//
+// Returns:
+//   - error: A validation error if the customer does not exist, or the query error.
+func (s *DBForecastStore) CreateRecurringInvoice(ctx context.Context, invoice *models.RecurringInvoice) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO recurring_invoices (customer_id, description, amount, interval_months, starts_on, ends_on, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		invoice.CustomerID, invoice.Description, invoice.Amount, invoice.IntervalMonths, invoice.StartsOn, invoice.EndsOn,
+		invoice.CreatedBy, invoice.CreatedAt,
+	).Scan(&invoice.ID)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("customer %d does not exist", invoice.CustomerID)
+	}
+	return err
+}
+
+// ListRecurringInvoices returns the recurring invoices with their customers' names.
+func (s *DBForecastStore) ListRecurringInvoices(ctx context.Context) ([]models.RecurringInvoice, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT r.id, r.customer_id, c.name, r.description, r.amount, r.interval_months, r.starts_on, r.ends_on,
+		        r.created_by, r.created_at
+		 FROM recurring_invoices r JOIN customers c ON c.id = r.customer_id
+		 ORDER BY r.starts_on, r.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.RecurringInvoice{}
+	for rows.Next() {
+		var r models.RecurringInvoice
+		if err := rows.Scan(&r.ID, &r.CustomerID, &r.CustomerName, &r.Description, &r.Amount, &r.IntervalMonths,
+			&r.StartsOn, &r.EndsOn, &r.CreatedBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// DeleteRecurringInvoice removes a recurring invoice.
+func (s *DBForecastStore) DeleteRecurringInvoice(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM recurring_invoices WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("recurring invoice %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package general_ledger_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/jobs"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limits of a batch posting request
+const (
+	MaxBatchLines     = 100000   // Transactions accepted in one request
+	maxBatchBodyBytes = 64 << 20 // Size of the request body
+	maxBatchErrors    = 100      // Invalid lines reported before giving up
+	maxAccountLength  = 50       // Length of financial_transactions.account_type
+	maxAmount         = 1e8      // Amounts must fit DECIMAL(10, 2)
+)
+
+// KindLedgerBatch is the job kind of batch postings.
+const KindLedgerBatch = "ledger_batch"
+
+// BatchHandler provides the HTTP handler for posting many ledger transactions at once.
+type BatchHandler struct {
+	Store models.LedgerBatchStore
+	Jobs  *jobs.Runner
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// BatchRequest is the body of a batch posting request.
+type BatchRequest struct {
+	Transactions []TransactionRequest `json:"transactions"`
+}
+
+// BatchResult is stored as the result of a batch posting job.
+type BatchResult struct {
+	Posted int     `json:"posted"`
+	Debit  float64 `json:"debit_total"`
+	Credit float64 `json:"credit_total"`
+}
+
+// PostBatch validates a month-end import of ledger transactions and posts it in the
+// background. Either every transaction is posted or, if the job fails, none is.
+//
+// HTTP Method: POST
+// URL Path: /batch
+//
+// Request Body:
+//   - JSON object with "transactions", a list of up to 100000 TransactionRequests.
+//     Each needs an account_type and a non-zero amount (positive for debits, negative for
+//     credits); a missing transaction_date defaults to today.
+//
+// Response:
+//   - Status Code: 202 (Accepted) with the queued Job in JSON and its URL in the Location
+//     header. The job reports progress as transactions are written.
+//   - Status Code: 400 (Bad Request) with the rejected lines (numbered from 1) in the error
+//     details if any transaction is invalid; nothing is posted.
+//   - Status Code: 413 (Request Entity Too Large) if the body is too large.
+//   - Status Code: 500 (Internal Server Error) if the job cannot be created.
+func (h *BatchHandler) PostBatch(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respond.Error(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+		return
+	} else if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	if len(req.Transactions) == 0 || len(req.Transactions) > MaxBatchLines {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("Between 1 and %d transactions are required", MaxBatchLines))
+		return
+	}
+
+	transactions := make([]models.FinancialTransaction, len(req.Transactions))
+	for i, line := range req.Transactions {
+		transactions[i] = line.Transaction()
+	}
+	result, rejected := h.prepare(transactions)
+	if rejected != nil {
+		respond.ErrorDetails(w, http.StatusBadRequest, "The batch was rejected", rejected.Items)
+		return
+	}
+
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	job, err := h.Jobs.Start(r.Context(), KindLedgerBatch, actor, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		p.SetTotal(int64(len(transactions)))
+		if err := h.Store.PostBatch(ctx, transactions, func(written int) { p.Add(int64(written)) }); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+	if err != nil {
+		httperr.Write(w, err, "Failed to start batch")
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	respond.JSON(w, http.StatusAccepted, job)
+}
+
+// prepare validates and normalizes the transactions in place and totals them. It returns
+// the rejected lines, numbered from 1, if any transaction is invalid.
+func (h *BatchHandler) prepare(transactions []models.FinancialTransaction) (*BatchResult, *models.BatchRejectedError) {
+	now := h.Now()
+	result := &BatchResult{Posted: len(transactions)}
+	rejected := &models.BatchRejectedError{}
+	for i := range transactions {
+		t := &transactions[i]
+		t.AccountType = strings.TrimSpace(t.AccountType)
+		if t.TransactionDate.IsZero() {
+			t.TransactionDate = now
+		}
+
+		var reason string
+		switch {
+		case t.AccountType == "":
+			reason = "account_type is required"
+		case len(t.AccountType) > maxAccountLength:
+			reason = fmt.Sprintf("account_type is limited to %d characters", maxAccountLength)
+		case t.Amount == 0 || math.IsNaN(t.Amount) || math.Abs(t.Amount) >= maxAmount:
+			reason = "amount must be non-zero and less than 100000000 in absolute value"
+		}
+		if reason != "" {
+			rejected.Items = append(rejected.Items, models.BatchItemError{ID: i + 1, Error: reason})
+			if len(rejected.Items) == maxBatchErrors {
+				break
+			}
+			continue
+		}
+
+		if t.Amount > 0 {
+			result.Debit += t.Amount
+		} else {
+			result.Credit -= t.Amount
+		}
+	}
+	if len(rejected.Items) > 0 {
+		return nil, rejected
+	}
+	result.Debit, result.Credit = math.Round(result.Debit*100)/100, math.Round(result.Credit*100)/100
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package general_ledger_handlers
+
+import (
+	"context"
+	"erp/controllers/handlers/report_handlers"
+	"erp/models"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BatchChunkSize is the number of transactions copied to the database between progress
+// reports.
+const BatchChunkSize = 5000
+
+// periodKey identifies an account's balance row for one month.
+type periodKey struct {
+	accountType string
+	period      time.Time
+}
+
+// PostBatch records many financial transactions in a single database transaction, so a
+// failed import leaves nothing behind and can simply be submitted again. Rows are streamed
+// with COPY in chunks of BatchChunkSize, and the account balances are updated once per
+// account and month instead of once per transaction.
+//
+// Parameters:
+//   - transactions: The transactions to record, already validated.
+//   - progress: Called with the size of each chunk once it is written; may be nil.
+//
+// Returns:
+//   - error: An error object if any transaction fails to be recorded, otherwise nil.
+func (store *DBFinancialTransactionStore) PostBatch(ctx context.Context, transactions []models.FinancialTransaction, progress func(written int)) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	totals := map[periodKey][2]float64{}
+	for start := 0; start < len(transactions); start += BatchChunkSize {
+		end := start + BatchChunkSize
+		if end > len(transactions) {
+			end = len(transactions)
+		}
+
+		stmt, err := tx.PrepareContext(ctx, pq.CopyIn("financial_transactions", "account_type", "amount", "transaction_date", "description"))
+		if err != nil {
+			return err
+		}
+		for i, t := range transactions[start:end] {
+			if _, err := stmt.ExecContext(ctx, t.AccountType, t.Amount, t.TransactionDate, t.Description); err != nil {
+				stmt.Close()
+				return fmt.Errorf("line %d: %w", start+i+1, err)
+			}
+
+			key := periodKey{t.AccountType, time.Date(t.TransactionDate.Year(), t.TransactionDate.Month(), 1, 0, 0, 0, 0, time.UTC)}
+			sums := totals[key]
+			if t.Amount >= 0 {
+				sums[0] += t.Amount
+			} else {
+				sums[1] -= t.Amount
+			}
+			totals[key] = sums
+		}
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			stmt.Close()
+			return err
+		}
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+
+		if progress != nil {
+			progress(end - start)
+		}
+	}
+
+	for key, sums := range totals {
+		if err := report_handlers.ApplyLedgerTotals(ctx, tx, key.accountType, key.period, sums[0], sums[1]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,258 @@
+package general_ledger_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"erp/controllers/jobs"
+	"erp/models"
+	"erp/models/db/dbtest"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var batchNow = time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC)
+
+func TestPostBatchStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBFinancialTransactionStore{DB: db}
+
+	date := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	transactions := []models.FinancialTransaction{
+		{AccountType: "expense", Amount: 100, TransactionDate: date, Description: "Rent"},
+		{AccountType: "expense", Amount: -40, TransactionDate: date, Description: "Refund"},
+	}
+
+	mock.ExpectBegin()
+	copyIn := mock.ExpectPrepare(`COPY "financial_transactions"`)
+	copyIn.ExpectExec().WithArgs("expense", 100.0, date, "Rent").WillReturnResult(sqlmock.NewResult(0, 1))
+	copyIn.ExpectExec().WithArgs("expense", -40.0, date, "Refund").WillReturnResult(sqlmock.NewResult(0, 1))
+	copyIn.ExpectExec().WithArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO account_period_balances").
+		WithArgs("expense", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), 100.0, 40.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var written []int
+	err = store.PostBatch(context.Background(), transactions, func(n int) { written = append(written, n) })
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, written)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostBatchStoreRollsBackOnError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBFinancialTransactionStore{DB: db}
+
+	mock.ExpectBegin()
+	copyIn := mock.ExpectPrepare(`COPY "financial_transactions"`)
+	copyIn.ExpectExec().WillReturnError(errors.New("value too long"))
+	mock.ExpectRollback()
+
+	err = store.PostBatch(context.Background(), []models.FinancialTransaction{{AccountType: "expense", Amount: 1, TransactionDate: batchNow}}, nil)
+	assert.ErrorContains(t, err, "line 1: value too long")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// mockBatchStore records the transactions it is asked to post.
+type mockBatchStore struct {
+	mu     sync.Mutex
+	posted []models.FinancialTransaction
+	err    error
+}
+
+func (m *mockBatchStore) PostBatch(ctx context.Context, transactions []models.FinancialTransaction, progress func(written int)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.posted = append(m.posted, transactions...)
+	progress(len(transactions))
+	return nil
+}
+
+// memoryJobStore keeps jobs in memory.
+type memoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[int]*models.Job
+}
+
+func (m *memoryJobStore) CreateJob(ctx context.Context, job *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.ID = len(m.jobs) + 1
+	copy := *job
+	m.jobs[job.ID] = &copy
+	return nil
+}
+
+func (m *memoryJobStore) StartJob(ctx context.Context, id int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].Status = models.JobRunning
+	return nil
+}
+
+func (m *memoryJobStore) UpdateJobProgress(ctx context.Context, id int, processed, total int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].Processed, m.jobs[id].Total = processed, total
+	return nil
+}
+
+func (m *memoryJobStore) FinishJob(ctx context.Context, id int, result json.RawMessage, errMsg string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	job.Status, job.Result, job.Error = models.JobSucceeded, result, errMsg
+	if errMsg != "" {
+		job.Status = models.JobFailed
+	}
+	return nil
+}
+
+func (m *memoryJobStore) GetJob(ctx context.Context, id int) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	copy := *job
+	return &copy, nil
+}
+
+func (m *memoryJobStore) ListJobs(ctx context.Context, kind string, limit int) ([]models.Job, error) {
+	return nil, nil
+}
+
+func setupBatchRouter(store models.LedgerBatchStore) (*mux.Router, *jobs.Runner, *memoryJobStore) {
+	jobStore := &memoryJobStore{jobs: map[int]*models.Job{}}
+	runner := jobs.NewRunner(jobStore)
+	handler := &BatchHandler{Store: store, Jobs: runner, Now: func() time.Time { return batchNow }}
+	router := mux.NewRouter()
+	router.HandleFunc("/general_ledger/batch", handler.PostBatch).Methods("POST")
+	return router, runner, jobStore
+}
+
+func serveBatch(router *mux.Router, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/general_ledger/batch", bytes.NewReader(data)))
+	return rr
+}
+
+func TestPostBatch(t *testing.T) {
+	store := &mockBatchStore{}
+	router, runner, jobStore := setupBatchRouter(store)
+
+	rr := serveBatch(router, map[string]interface{}{"transactions": []map[string]interface{}{
+		{"account_type": " expense ", "amount": 100.5},
+		{"account_type": "cash", "amount": -100.5, "transaction_date": "2026-10-30T00:00:00Z"},
+	}})
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	assert.Equal(t, "/jobs/1", rr.Header().Get("Location"))
+	runner.Wait()
+
+	job, err := jobStore.GetJob(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobSucceeded, job.Status)
+	assert.Equal(t, int64(2), job.Processed)
+	assert.Equal(t, int64(2), job.Total)
+	assert.JSONEq(t, `{"posted":2,"debit_total":100.5,"credit_total":100.5}`, string(job.Result))
+
+	require.Len(t, store.posted, 2)
+	assert.Equal(t, "expense", store.posted[0].AccountType)
+	assert.Equal(t, batchNow, store.posted[0].TransactionDate)
+}
+
+func TestPostBatchRejectsInvalidLines(t *testing.T) {
+	store := &mockBatchStore{}
+	router, _, _ := setupBatchRouter(store)
+
+	rr := serveBatch(router, map[string]interface{}{"transactions": []map[string]interface{}{
+		{"account_type": "expense", "amount": 10},
+		{"account_type": "", "amount": 10},
+		{"account_type": "cash", "amount": 0},
+	}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var body struct {
+		Error struct {
+			Details []models.BatchItemError `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	require.Len(t, body.Error.Details, 2)
+	assert.Equal(t, 2, body.Error.Details[0].ID)
+	assert.Equal(t, 3, body.Error.Details[1].ID)
+	assert.Empty(t, store.posted)
+
+	assert.Equal(t, http.StatusBadRequest, serveBatch(router, map[string]interface{}{"transactions": []interface{}{}}).Code)
+}
+
+func TestPostBatchFailedJob(t *testing.T) {
+	router, runner, jobStore := setupBatchRouter(&mockBatchStore{err: errors.New("connection reset")})
+
+	rr := serveBatch(router, map[string]interface{}{"transactions": []map[string]interface{}{{"account_type": "expense", "amount": 1}}})
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	runner.Wait()
+
+	job, err := jobStore.GetJob(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobFailed, job.Status)
+	assert.Equal(t, "connection reset", job.Error)
+}
+
+// BenchmarkPostBatch measures bulk posting against a real PostgreSQL database with the
+// application schema. Set ERP_BENCH_DATABASE_URL to a disposable database to run it:
+//
+//	ERP_BENCH_DATABASE_URL=postgres://... go test -run '^$' -bench PostBatch ./controllers/handlers/general_ledger_handlers/
+//
+// The rows/s metric is the number to compare against the 10k rows/second target.
+func BenchmarkPostBatch(b *testing.B) {
+	ctx := context.Background()
+	db := dbtest.Open(ctx, b)
+	store := &DBFinancialTransactionStore{DB: db}
+
+	for _, size := range []int{1000, 10000, 50000} {
+		transactions := make([]models.FinancialTransaction, size)
+		for i := range transactions {
+			transactions[i] = models.FinancialTransaction{
+				AccountType:     fmt.Sprintf("bench_%d", i%20),
+				Amount:          float64(i%1000+1) * 1.25,
+				TransactionDate: batchNow.AddDate(0, 0, -(i % 60)),
+				Description:     "Benchmark import",
+			}
+		}
+
+		b.Run(fmt.Sprintf("rows=%d", size), func(b *testing.B) {
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				if err := store.PostBatch(ctx, transactions, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(size*b.N)/time.Since(start).Seconds(), "rows/s")
+		})
+	}
+
+	db.ExecContext(ctx, "DELETE FROM financial_transactions WHERE account_type LIKE 'bench\\_%'")
+	db.ExecContext(ctx, "DELETE FROM account_period_balances WHERE account_type LIKE 'bench\\_%'")
+}
@@ -10,6 +10,9 @@ import (
 	"strconv"
 	"time"
 
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/controllers/versioning"
 	"erp/models"
 
 	"github.com/gorilla/mux"
@@ -22,6 +25,27 @@ type GeneralLedgerHandler struct {
 	Store models.FinancialTransactionStore // Store defines the interface for managing transactions in the database.
 }
 
+// TransactionRequest is the request body for creating or updating a ledger transaction, and
+// one line of a batch. The ID and the invoice or journal entry a transaction was posted from
+// are set by the server.
+type TransactionRequest struct {
+	AccountType     string    `json:"account_type"`
+	Amount          float64   `json:"amount"`
+	TransactionDate time.Time `json:"transaction_date"`
+	Description     string    `json:"description"`
+	Version         int       `json:"version"` // The version updated, unless sent in If-Match; ignored on create
+}
+
+// Transaction returns the ledger transaction described by the request.
+func (req TransactionRequest) Transaction() models.FinancialTransaction {
+	return models.FinancialTransaction{
+		AccountType:     req.AccountType,
+		Amount:          req.Amount,
+		TransactionDate: req.TransactionDate,
+		Description:     req.Description,
+	}
+}
+
 // RegisterRoutes maps general ledger routes to their respective handler functions.
 // It keeps route definitions modular, enabling easy modifications and scalability.
 //
@@ -45,7 +69,7 @@ func RegisterRoutes(router *mux.Router, store models.FinancialTransactionStore)
 // URL Path: / (root path of general ledger routes)
 //
 // Request Body:
-//   - JSON representation of a FinancialTransaction object (excluding the transaction date).
+//   - JSON with account_type, amount and description (see TransactionRequest).
 //
 // Response:
 //   - Status Code: 201 (Created) if the transaction is successfully created.
@@ -53,22 +77,20 @@ func RegisterRoutes(router *mux.Router, store models.FinancialTransactionStore)
 //   - Status Code: 400 (Bad Request) if the input data is invalid.
 //   - Status Code: 500 (Internal Server Error) if the transaction could not be saved.
 func (h *GeneralLedgerHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
-	var transaction models.FinancialTransaction
-	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		http.Error(w, "Invalid input data", http.StatusBadRequest)
+	var req TransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
 		return
 	}
 
+	transaction := req.Transaction()
 	transaction.TransactionDate = time.Now()
-	if err := h.Store.CreateTransaction(&transaction); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create transaction: %v", err), http.StatusInternalServerError)
+	if err := h.Store.CreateTransaction(r.Context(), &transaction); err != nil {
+		httperr.Write(w, err, "Failed to create transaction")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(transaction); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	respond.JSON(w, http.StatusCreated, transaction)
 }
 
 // GetTransaction retrieves and returns a financial transaction by its ID.
@@ -79,25 +101,25 @@ func (h *GeneralLedgerHandler) CreateTransaction(w http.ResponseWriter, r *http.
 // URL Path: /{id} (ID of the transaction in the path)
 //
 // Response:
-//   - Status Code: 200 (OK) with the transaction data in JSON format if found.
+//   - Status Code: 200 (OK) with the transaction data in JSON format and its version as the
+//     ETag if found.
 //   - Status Code: 400 (Bad Request) if the ID is invalid.
 //   - Status Code: 404 (Not Found) if the transaction with the specified ID does not exist.
 func (h *GeneralLedgerHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid transaction ID")
 		return
 	}
 
-	transaction, err := h.Store.GetTransactionByID(id)
+	transaction, err := h.Store.GetTransactionByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Transaction not found: %v", err), http.StatusNotFound)
+		httperr.Write(w, err, "Failed to load transaction")
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(transaction); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	versioning.SetETag(w, transaction.Version)
+	respond.JSON(w, http.StatusOK, transaction)
 }
 
 // UpdateTransaction modifies an existing financial transaction based on the given ID.
@@ -108,36 +130,45 @@ func (h *GeneralLedgerHandler) GetTransaction(w http.ResponseWriter, r *http.Req
 // URL Path: /{id} (ID of the transaction in the path)
 //
 // Request Body:
-//   - JSON representation of a FinancialTransaction object (excluding the ID, which is taken from the URL).
+//   - JSON with account_type, amount, transaction_date, description and the version read
+//     (see TransactionRequest); the version may be sent as an If-Match header instead.
 //
 // Response:
-//   - Status Code: 200 (OK) with the updated transaction data in JSON format if successful.
+//   - Status Code: 200 (OK) with the updated transaction data in JSON format and its new
+//     version as the ETag if successful.
 //   - Status Code: 400 (Bad Request) if the ID or input data is invalid.
+//   - Status Code: 404 (Not Found) if the transaction does not exist.
+//   - Status Code: 409 (Conflict) if the transaction was changed since the version read.
+//   - Status Code: 428 (Precondition Required) if no version is given.
 //   - Status Code: 500 (Internal Server Error) if the update operation fails.
 func (h *GeneralLedgerHandler) UpdateTransaction(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid transaction ID")
 		return
 	}
 	fmt.Println("ID: ", id)
 
-	var transaction models.FinancialTransaction
-	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		http.Error(w, "Invalid input data", http.StatusBadRequest)
+	var req TransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
 		return
 	}
 
-	transaction.ID = id
-	if err := h.Store.UpdateTransaction(&transaction); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update transaction: %v", err), http.StatusInternalServerError)
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(transaction); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	transaction := req.Transaction()
+	transaction.ID, transaction.Version = id, version
+	if err := h.Store.UpdateTransaction(r.Context(), &transaction); err != nil {
+		httperr.Write(w, err, "Failed to update transaction")
+		return
 	}
+
+	versioning.SetETag(w, transaction.Version)
+	respond.JSON(w, http.StatusOK, transaction)
 }
 
 // DeleteTransaction removes a financial transaction identified by its ID.
@@ -149,16 +180,17 @@ func (h *GeneralLedgerHandler) UpdateTransaction(w http.ResponseWriter, r *http.
 // Response:
 //   - Status Code: 204 (No Content) if the transaction is successfully deleted.
 //   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the transaction does not exist.
 //   - Status Code: 500 (Internal Server Error) if the deletion operation fails.
 func (h *GeneralLedgerHandler) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid transaction ID")
 		return
 	}
 
-	if err := h.Store.DeleteTransaction(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete transaction: %v", err), http.StatusInternalServerError)
+	if err := h.Store.DeleteTransaction(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete transaction")
 		return
 	}
 
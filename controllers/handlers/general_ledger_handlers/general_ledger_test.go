@@ -1,7 +1,12 @@
 package general_ledger_handlers
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
+
+	"erp/models"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -88,12 +93,20 @@ func TestDeleteTransaction(t *testing.T) {
 	store := &DBFinancialTransactionStore{DB: db}
 
 	// Define expected behavior for mock
-	mock.ExpectExec("DELETE FROM financial_transactions").
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM financial_transactions").
 		WithArgs(1).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"account_type", "amount", "transaction_date"}).
+			AddRow("expense", 150.0, time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)))
+	mock.ExpectExec("INSERT INTO account_period_balances").
+		WithArgs("expense", sqlmock.AnyArg(), -150.0, 0.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO report_refreshes").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
 	// Call the method
-	err = store.DeleteTransaction(1)
+	err = store.DeleteTransaction(context.Background(), 1)
 
 	// Assert that no error occurred
 	assert.NoError(t, err)
@@ -103,3 +116,33 @@ func TestDeleteTransaction(t *testing.T) {
 		t.Errorf("there were unmet expectations: %v", err)
 	}
 }
+
+func TestUpdateTransactionRejectsStaleVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("could not create mock db: %v", err)
+	}
+	defer db.Close()
+
+	store := &DBFinancialTransactionStore{DB: db}
+	date := time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	// Someone else changed the transaction after version 2 was read: nothing is saved
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT account_type, amount, transaction_date FROM financial_transactions").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"account_type", "amount", "transaction_date"}).AddRow("expense", 150.0, date))
+	mock.ExpectQuery("UPDATE financial_transactions").
+		WithArgs("expense", 200.0, date, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery("SELECT version FROM financial_transactions WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectRollback()
+
+	transaction := &models.FinancialTransaction{ID: 1, AccountType: "expense", Amount: 200, TransactionDate: date, Version: 2}
+	err = store.UpdateTransaction(context.Background(), transaction)
+	assert.True(t, errors.Is(err, models.ErrConflict), "got %v", err)
+	assert.Contains(t, err.Error(), "version 3")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,310 @@
+package general_ledger_handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"erp/controllers/antivirus"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/storage"
+	"erp/controllers/versioning"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// JournalEntryHandler provides HTTP handlers for manual journal entries. Entries are
+// created as drafts, can be edited freely and only reach the ledger once posted.
+type JournalEntryHandler struct {
+	Store         models.JournalEntryStore // Store defines the interface for managing journal entries in the database.
+	Storage       storage.Storage          // Attachment backend holding supporting documents
+	MaxUploadSize int64                    // Maximum accepted attachment size in bytes
+	Antivirus     *antivirus.Service       // Scans attachments before they are stored; nil disables scanning
+}
+
+// JournalEntryRequest is the request body for creating or updating a draft journal entry.
+// The ID, status, attachment and posting details are managed by the server.
+type JournalEntryRequest struct {
+	EntryDate     time.Time            `json:"entry_date"`
+	Description   string               `json:"description"`
+	Justification string               `json:"justification"` // Required before the entry can be posted
+	Lines         []models.JournalLine `json:"lines"`
+	Version       int                  `json:"version"` // The version updated, unless sent in If-Match; ignored on create
+}
+
+// JournalEntry returns the draft described by the request. A missing entry date defaults
+// to now.
+func (req JournalEntryRequest) JournalEntry(now time.Time) models.JournalEntry {
+	entry := models.JournalEntry{
+		EntryDate:     req.EntryDate,
+		Description:   req.Description,
+		Justification: req.Justification,
+		Lines:         req.Lines,
+	}
+	if entry.EntryDate.IsZero() {
+		entry.EntryDate = now
+	}
+	return entry
+}
+
+// RegisterRoutes maps journal entry routes to the handler's methods. PostJournalEntry is
+// left to the caller, which may gate posting behind a feature flag.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+func (h *JournalEntryHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.CreateJournalEntry).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}", h.GetJournalEntry).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", h.UpdateJournalEntry).Methods("PUT")
+}
+
+// CreateJournalEntry creates a new draft journal entry. The entry date defaults to today,
+// and the signed-in user is recorded as its creator.
+//
+// HTTP Method: POST
+// URL Path: / (root path of journal entry routes)
+//
+// Request Body:
+//   - JSON with entry_date, description, justification and lines (see JournalEntryRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the created draft in JSON format.
+//   - Status Code: 400 (Bad Request) if the input data is invalid.
+//   - Status Code: 500 (Internal Server Error) if the entry could not be saved.
+func (h *JournalEntryHandler) CreateJournalEntry(w http.ResponseWriter, r *http.Request) {
+	var req JournalEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+
+	entry := req.JournalEntry(time.Now())
+	entry.CreatedBy, _ = middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Store.CreateJournalEntry(r.Context(), &entry); err != nil {
+		httperr.Write(w, err, "Failed to create journal entry")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, entry)
+}
+
+// GetJournalEntry returns a journal entry and its lines by ID.
+//
+// HTTP Method: GET
+// URL Path: /{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the journal entry in JSON format and its version as the ETag.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the entry does not exist.
+//   - Status Code: 500 (Internal Server Error) if the entry could not be read.
+func (h *JournalEntryHandler) GetJournalEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid journal entry ID")
+		return
+	}
+
+	entry, err := h.Store.GetJournalEntryByID(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Journal entry not found")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to get journal entry")
+		return
+	}
+
+	versioning.SetETag(w, entry.Version)
+	respond.JSON(w, http.StatusOK, entry)
+}
+
+// UpdateJournalEntry replaces the date, description, justification and lines of a draft
+// journal entry.
+//
+// HTTP Method: PUT
+// URL Path: /{id}
+//
+// Request Body:
+//   - JSON with entry_date, description, justification, lines and the version read (see
+//     JournalEntryRequest); the version may be sent as an If-Match header instead.
+//
+// Response:
+//   - Status Code: 200 (OK) with the updated draft in JSON format and its new version as the ETag.
+//   - Status Code: 400 (Bad Request) if the ID or input data is invalid.
+//   - Status Code: 404 (Not Found) if the entry does not exist.
+//   - Status Code: 409 (Conflict) if the entry has been posted, or was changed since the
+//     version read.
+//   - Status Code: 428 (Precondition Required) if no version is given.
+//   - Status Code: 500 (Internal Server Error) if the update fails.
+func (h *JournalEntryHandler) UpdateJournalEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid journal entry ID")
+		return
+	}
+
+	var req JournalEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
+		return
+	}
+	entry := req.JournalEntry(time.Now())
+	entry.ID, entry.Version = id, version
+
+	err = h.Store.UpdateJournalEntry(r.Context(), &entry)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Journal entry not found")
+		return
+	}
+	if errors.Is(err, models.ErrDocumentLocked) {
+		respond.Error(w, http.StatusConflict, "Only draft journal entries can be changed")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to update journal entry")
+		return
+	}
+
+	versioning.SetETag(w, entry.Version)
+	respond.JSON(w, http.StatusOK, entry)
+}
+
+// PostJournalEntry posts a draft journal entry. The entry must be justified and balance;
+// posting records one ledger transaction per line, locks the entry and writes the
+// justification to the audit log.
+//
+// HTTP Method: POST
+// URL Path: /{id}/post
+//
+// Response:
+//   - Status Code: 200 (OK) with the posted entry in JSON format.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 403 (Forbidden) if segregation of duties forbids the creator to post it.
+//   - Status Code: 404 (Not Found) if the entry does not exist.
+//   - Status Code: 409 (Conflict) if the entry has already been posted.
+//   - Status Code: 422 (Unprocessable Entity) if the entry fails validation, e.g. has no
+//     justification or does not balance.
+//   - Status Code: 500 (Internal Server Error) if posting fails.
+func (h *JournalEntryHandler) PostJournalEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid journal entry ID")
+		return
+	}
+
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	entry, err := h.Store.PostJournalEntry(r.Context(), id, actor)
+	var postingErr *models.PostingError
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		respond.Error(w, http.StatusNotFound, "Journal entry not found")
+		return
+	case errors.Is(err, models.ErrDocumentLocked):
+		respond.Error(w, http.StatusConflict, "Only draft journal entries can be posted")
+		return
+	case errors.As(err, &postingErr):
+		respond.Error(w, http.StatusUnprocessableEntity, postingErr.Error())
+		return
+	case err != nil:
+		httperr.Write(w, err, "Failed to post journal entry")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, entry)
+}
+
+// UploadAttachment stores a supporting document, such as an approval email or a calculation,
+// and links it to a draft journal entry. A new upload replaces the link to the previous one.
+//
+// HTTP Method: POST
+// URL Path: /{id}/attachment
+//
+// Request Body:
+//   - multipart/form-data with the document in the "file" field.
+//
+// Response:
+//   - Status Code: 200 (OK) with the updated entry in JSON format.
+//   - Status Code: 400 (Bad Request) if the ID is invalid or the upload is missing or too large.
+//   - Status Code: 404 (Not Found) if the entry does not exist.
+//   - Status Code: 409 (Conflict) if the entry has been posted.
+//   - Status Code: 422 (Unprocessable Entity) if the virus scanner flagged the document. It
+//     is quarantined and the response names the malware found.
+//   - Status Code: 500 (Internal Server Error) if the document cannot be stored.
+//   - Status Code: 503 (Service Unavailable) if the document cannot be scanned.
+func (h *JournalEntryHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid journal entry ID")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadSize+1<<20) // Allow for multipart overhead
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "A document is required in the \"file\" field")
+		return
+	}
+	defer file.Close()
+	data, err := storage.ReadAll(file, h.MaxUploadSize)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	if h.Antivirus != nil {
+		actor, _ := middleware.GetUserEmailFromContext(r.Context())
+		err := h.Antivirus.Check(r.Context(), data, models.QuarantinedFile{
+			Source:      models.UploadJournalAttachment,
+			EntityID:    id,
+			FileName:    path.Base(header.Filename),
+			ContentType: contentType,
+			UploadedBy:  actor,
+		})
+		if errors.Is(err, antivirus.ErrUnavailable) {
+			respond.Error(w, http.StatusServiceUnavailable, "The document could not be scanned for viruses, try again later")
+			return
+		}
+		if err != nil {
+			httperr.Write(w, err, "Failed to scan attachment")
+			return
+		}
+	}
+
+	key := fmt.Sprintf("journal_entries/%d/%d-%s", id, time.Now().UnixNano(), path.Base(header.Filename))
+	if err := h.Storage.Put(key, data, contentType); err != nil {
+		httperr.Write(w, err, "Failed to store attachment")
+		return
+	}
+	err = h.Store.SetJournalAttachment(r.Context(), id, h.Storage.URL(key))
+	if err != nil {
+		h.Storage.Delete(key)
+	}
+	if errors.Is(err, models.ErrDocumentLocked) {
+		respond.Error(w, http.StatusConflict, "Only draft journal entries can be changed")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to attach document")
+		return
+	}
+
+	entry, err := h.Store.GetJournalEntryByID(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to get journal entry")
+		return
+	}
+	respond.JSON(w, http.StatusOK, entry)
+}
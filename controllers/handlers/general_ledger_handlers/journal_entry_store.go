@@ -0,0 +1,286 @@
+package general_ledger_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/controllers/approvals"
+	"erp/controllers/audit"
+	"erp/controllers/versioning"
+	"erp/models"
+	"fmt"
+	"time"
+)
+
+// DBJournalEntryStore provides SQL-backed methods to manage journal entries.
+// It acts as a store for the journal_entries and journal_entry_lines tables.
+type DBJournalEntryStore struct {
+	DB        *sql.DB           // DB represents the database connection.
+	Approvals *approvals.Engine // Enforces segregation of duties on posting; nil disables the check
+}
+
+// CreateJournalEntry inserts a new draft journal entry and its lines in one transaction.
+//
+// Parameters:
+//   - entry: A pointer to the JournalEntry to create; its ID, status and version are populated.
+//
+// Returns:
+//   - error: An error object if the entry fails to be created, otherwise nil.
+func (store *DBJournalEntryStore) CreateJournalEntry(ctx context.Context, entry *models.JournalEntry) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	entry.Status = models.StatusDraft
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO journal_entries (entry_date, description, justification, status, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id, version",
+		entry.EntryDate, entry.Description, entry.Justification, entry.Status, sql.NullString{String: entry.CreatedBy, Valid: entry.CreatedBy != ""},
+	).Scan(&entry.ID, &entry.Version)
+	if err != nil {
+		return err
+	}
+
+	if err := insertJournalLines(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetJournalEntryByID retrieves a journal entry and its lines by ID.
+//
+// Parameters:
+//   - id: The ID of the journal entry to retrieve.
+//
+// Returns:
+//   - *JournalEntry: A pointer to the retrieved journal entry.
+//   - error: models.ErrNotFound if the entry does not exist, or another error if the query fails.
+func (store *DBJournalEntryStore) GetJournalEntryByID(ctx context.Context, id int) (*models.JournalEntry, error) {
+	return getJournalEntry(ctx, store.DB, id)
+}
+
+// getJournalEntry reads a journal entry and its lines with the given database or transaction.
+func getJournalEntry(ctx context.Context, q models.Queryer, id int) (*models.JournalEntry, error) {
+	var entry models.JournalEntry
+	var postedAt sql.NullTime
+	var attachmentURL, createdBy, postedBy sql.NullString
+	err := q.QueryRowContext(ctx,
+		`SELECT id, entry_date, description, justification, attachment_url, status, created_by, posted_at, posted_by, version
+		 FROM journal_entries WHERE id = $1`, id,
+	).Scan(&entry.ID, &entry.EntryDate, &entry.Description, &entry.Justification, &attachmentURL, &entry.Status, &createdBy, &postedAt, &postedBy,
+		&entry.Version)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if postedAt.Valid {
+		entry.PostedAt = &postedAt.Time
+	}
+	entry.AttachmentURL, entry.CreatedBy, entry.PostedBy = attachmentURL.String, createdBy.String, postedBy.String
+
+	rows, err := q.QueryContext(ctx,
+		"SELECT account_type, debit, credit FROM journal_entry_lines WHERE journal_entry_id = $1 ORDER BY line_no", id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entry.Lines = []models.JournalLine{}
+	for rows.Next() {
+		var line models.JournalLine
+		if err := rows.Scan(&line.AccountType, &line.Debit, &line.Credit); err != nil {
+			return nil, err
+		}
+		entry.Lines = append(entry.Lines, line)
+	}
+	return &entry, rows.Err()
+}
+
+// UpdateJournalEntry replaces the header and lines of a draft journal entry if it is still
+// at entry.Version, and sets the new version.
+//
+// Parameters:
+//   - entry: A pointer to the JournalEntry containing the updated details.
+//
+// Returns:
+//   - error: models.ErrNotFound if the entry does not exist, models.ErrDocumentLocked if it
+//     has been posted, a models.Conflict error if it was changed since entry.Version, or
+//     another error if the update fails.
+func (store *DBJournalEntryStore) UpdateJournalEntry(ctx context.Context, entry *models.JournalEntry) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockJournalEntry(ctx, tx, entry.ID); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"UPDATE journal_entries SET entry_date = $1, description = $2, justification = $3 WHERE id = $4 AND version = $5 RETURNING version",
+		entry.EntryDate, entry.Description, entry.Justification, entry.ID, entry.Version,
+	).Scan(&entry.Version)
+	if err == sql.ErrNoRows {
+		return versioning.Stale(ctx, tx, "SELECT version FROM journal_entries WHERE id = $1", "journal entry", entry.ID, entry.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM journal_entry_lines WHERE journal_entry_id = $1", entry.ID); err != nil {
+		return err
+	}
+	if err := insertJournalLines(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	entry.Status = models.StatusDraft
+	return tx.Commit()
+}
+
+// SetJournalAttachment links a supporting document to a draft journal entry.
+//
+// Parameters:
+//   - id: The ID of the journal entry.
+//   - url: The URL of the stored document.
+//
+// Returns:
+//   - error: models.ErrNotFound if the entry does not exist, models.ErrDocumentLocked if it
+//     has been posted, or another error if the update fails.
+func (store *DBJournalEntryStore) SetJournalAttachment(ctx context.Context, id int, url string) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockJournalEntry(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE journal_entries SET attachment_url = $1 WHERE id = $2", url, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PostJournalEntry validates a draft journal entry and posts it: one financial transaction
+// is recorded per line, the entry is locked and the posting is written to the audit log
+// with the entry's justification, all in one transaction. Posting approves the entry, so
+// the poster may not be its creator while the segregation-of-duties policy is enabled.
+//
+// Parameters:
+//   - id: The ID of the journal entry to post.
+//   - actor: The email of the user posting the entry.
+//
+// Returns:
+//   - *JournalEntry: The posted journal entry.
+//   - error: models.ErrNotFound, models.ErrDocumentLocked, a *models.PostingError if the
+//     entry is invalid, models.ErrSameCreatorApprover, or another error if posting fails.
+func (store *DBJournalEntryStore) PostJournalEntry(ctx context.Context, id int, actor string) (*models.JournalEntry, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the entry before reading its lines so concurrent edits or posts cannot interleave
+	if _, err := lockJournalEntry(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	entry, err := getJournalEntry(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := entry.ValidateForPosting(); err != nil {
+		return nil, err
+	}
+	if store.Approvals != nil {
+		if err := store.Approvals.Authorize(ctx, tx, models.DocumentJournalEntry, id, entry.CreatedBy, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE journal_entries SET status = $1, posted_at = $2, posted_by = $3 WHERE id = $4", models.StatusPosted, now, actor, id,
+	); err != nil {
+		return nil, err
+	}
+	details, err := json.Marshal(map[string]interface{}{"total": entry.Total(), "attachment_url": entry.AttachmentURL})
+	if err != nil {
+		return nil, err
+	}
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		Action:     audit.ActionJournalPost,
+		EntityType: "journal_entry",
+		EntityID:   id,
+		Reason:     entry.Justification,
+		Details:    details,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	description := entry.Description
+	if description == "" {
+		description = fmt.Sprintf("Journal entry #%d", id)
+	}
+	for _, line := range entry.Lines {
+		transaction := &models.FinancialTransaction{
+			AccountType:     line.AccountType,
+			Amount:          line.Amount(),
+			TransactionDate: entry.EntryDate,
+			Description:     description,
+			JournalEntryID:  &entry.ID,
+		}
+		if err := InsertTransaction(ctx, tx, transaction); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// The entry is locked, so posting is the only update since it was read
+	entry.Status, entry.Version = models.StatusPosted, entry.Version+1
+	entry.PostedAt = &now
+	entry.PostedBy = actor
+	return entry, nil
+}
+
+// lockJournalEntry locks a journal entry row for the rest of the transaction and returns
+// its status. It returns models.ErrDocumentLocked if the entry is not a draft.
+func lockJournalEntry(ctx context.Context, tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status FROM journal_entries WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", models.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if status != models.StatusDraft {
+		return status, models.ErrDocumentLocked
+	}
+	return status, nil
+}
+
+// insertJournalLines writes the lines of a journal entry in order.
+func insertJournalLines(ctx context.Context, tx *sql.Tx, entry *models.JournalEntry) error {
+	for i, line := range entry.Lines {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO journal_entry_lines (journal_entry_id, line_no, account_type, debit, credit) VALUES ($1, $2, $3, $4, $5)",
+			entry.ID, i+1, line.AccountType, line.Debit, line.Credit,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package general_ledger_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"erp/controllers/antivirus"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockJournalEntryStore is an in-memory implementation of models.JournalEntryStore.
+type mockJournalEntryStore struct {
+	entries map[int]*models.JournalEntry
+	nextID  int
+	posted  []models.FinancialTransaction // Ledger lines recorded by posting
+}
+
+func newMockJournalEntryStore() *mockJournalEntryStore {
+	return &mockJournalEntryStore{entries: make(map[int]*models.JournalEntry), nextID: 1}
+}
+
+func (m *mockJournalEntryStore) CreateJournalEntry(ctx context.Context, entry *models.JournalEntry) error {
+	entry.ID = m.nextID
+	entry.Status, entry.Version = models.StatusDraft, 1
+	m.entries[entry.ID] = entry
+	m.nextID++
+	return nil
+}
+
+func (m *mockJournalEntryStore) GetJournalEntryByID(ctx context.Context, id int) (*models.JournalEntry, error) {
+	entry, exists := m.entries[id]
+	if !exists {
+		return nil, models.ErrNotFound
+	}
+	return entry, nil
+}
+
+func (m *mockJournalEntryStore) UpdateJournalEntry(ctx context.Context, entry *models.JournalEntry) error {
+	existing, exists := m.entries[entry.ID]
+	if !exists {
+		return models.ErrNotFound
+	}
+	if existing.Status != models.StatusDraft {
+		return models.ErrDocumentLocked
+	}
+	if entry.Version != existing.Version {
+		return models.Conflict("journal entry %d is at version %d", entry.ID, existing.Version)
+	}
+	entry.Status, entry.Version = models.StatusDraft, entry.Version+1
+	m.entries[entry.ID] = entry
+	return nil
+}
+
+func (m *mockJournalEntryStore) SetJournalAttachment(ctx context.Context, id int, url string) error {
+	entry, exists := m.entries[id]
+	if !exists {
+		return models.ErrNotFound
+	}
+	if entry.Status != models.StatusDraft {
+		return models.ErrDocumentLocked
+	}
+	entry.AttachmentURL = url
+	return nil
+}
+
+func (m *mockJournalEntryStore) PostJournalEntry(ctx context.Context, id int, actor string) (*models.JournalEntry, error) {
+	entry, exists := m.entries[id]
+	if !exists {
+		return nil, models.ErrNotFound
+	}
+	if err := entry.ValidateForPosting(); err != nil {
+		return nil, err
+	}
+	for _, line := range entry.Lines {
+		m.posted = append(m.posted, models.FinancialTransaction{AccountType: line.AccountType, Amount: line.Amount()})
+	}
+	entry.Status, entry.Version = models.StatusPosted, entry.Version+1
+	entry.PostedBy = actor
+	return entry, nil
+}
+
+func setupJournalRouter(store models.JournalEntryStore) *mux.Router {
+	router := mux.NewRouter()
+	handler := &JournalEntryHandler{Store: store}
+	entries := router.PathPrefix("/general_ledger/journal_entries").Subrouter()
+	handler.RegisterRoutes(entries)
+	entries.HandleFunc("/{id:[0-9]+}/post", handler.PostJournalEntry).Methods("POST")
+	return router
+}
+
+func serveJournal(router *mux.Router, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(payload))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestJournalEntryDraftToPosted(t *testing.T) {
+	store := newMockJournalEntryStore()
+	router := setupJournalRouter(store)
+
+	draft := models.JournalEntry{
+		Description: "Accrue rent",
+		Lines: []models.JournalLine{
+			{AccountType: "rent_expense", Debit: 1200},
+			{AccountType: "accrued_liabilities", Credit: 1000},
+		},
+	}
+	rr := serveJournal(router, "POST", "/general_ledger/journal_entries", draft)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Unbalanced drafts cannot be posted and leave no ledger effect
+	rr = serveJournal(router, "POST", "/general_ledger/journal_entries/1/post", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Empty(t, store.posted)
+
+	// Drafts are editable, naming the version they change
+	draft.Lines[1].Credit = 1200
+	rr = serveJournal(router, "PUT", "/general_ledger/journal_entries/1", draft)
+	assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+	draft.Version = 1
+	rr = serveJournal(router, "PUT", "/general_ledger/journal_entries/1", draft)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"2"`, rr.Header().Get("ETag"))
+
+	// An edit based on the replaced version is refused
+	rr = serveJournal(router, "PUT", "/general_ledger/journal_entries/1", draft)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Manual adjustments must say why they are made
+	rr = serveJournal(router, "POST", "/general_ledger/journal_entries/1/post", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "a justification is required")
+	draft.Justification, draft.Version = "Rent for March is billed in April", 2
+	rr = serveJournal(router, "PUT", "/general_ledger/journal_entries/1", draft)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = serveJournal(router, "POST", "/general_ledger/journal_entries/1/post", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var posted models.JournalEntry
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&posted))
+	assert.Equal(t, models.StatusPosted, posted.Status)
+	assert.Len(t, store.posted, 2)
+	assert.Equal(t, -1200.0, store.posted[1].Amount)
+
+	// Posted entries are locked
+	rr = serveJournal(router, "PUT", "/general_ledger/journal_entries/1", draft)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = serveJournal(router, "POST", "/general_ledger/journal_entries/1/post", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestPostJournalEntryNotFound(t *testing.T) {
+	router := setupJournalRouter(newMockJournalEntryStore())
+
+	rr := serveJournal(router, "POST", "/general_ledger/journal_entries/9/post", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestUploadJournalAttachment(t *testing.T) {
+	store := newMockJournalEntryStore()
+	files := storage.NewMemoryStorage()
+	handler := &JournalEntryHandler{Store: store, Storage: files, MaxUploadSize: 1 << 10}
+	router := mux.NewRouter()
+	router.HandleFunc("/general_ledger/journal_entries/{id:[0-9]+}/attachment", handler.UploadAttachment).Methods("POST")
+	store.CreateJournalEntry(context.Background(), &models.JournalEntry{Justification: "Write off stale balance"})
+
+	upload := func(id int, content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "approval.txt")
+		part.Write([]byte(content))
+		form.Close()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/general_ledger/journal_entries/%d/attachment", id), &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := upload(1, "Approved by the CFO")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var entry models.JournalEntry
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&entry))
+	assert.Contains(t, entry.AttachmentURL, "journal_entries/1/")
+	assert.Contains(t, entry.AttachmentURL, "approval.txt")
+
+	assert.Equal(t, http.StatusBadRequest, upload(1, strings.Repeat("x", 2<<10)).Code)
+	assert.Equal(t, http.StatusNotFound, upload(9, "x").Code)
+
+	store.entries[1].Status = models.StatusPosted
+	assert.Equal(t, http.StatusConflict, upload(1, "Late evidence").Code)
+}
+
+// quarantineStore records quarantined files in memory.
+type quarantineStore struct {
+	models.QuarantineStore
+	files []models.QuarantinedFile
+}
+
+func (q *quarantineStore) CreateQuarantinedFile(ctx context.Context, file *models.QuarantinedFile) error {
+	q.files = append(q.files, *file)
+	return nil
+}
+
+func TestUploadJournalAttachmentRejectsMalware(t *testing.T) {
+	store := newMockJournalEntryStore()
+	files := storage.NewMemoryStorage()
+	quarantine := &quarantineStore{}
+	scanner := antivirus.ScannerFunc(func(ctx context.Context, data []byte) (*antivirus.Verdict, error) {
+		if strings.Contains(string(data), "EICAR") {
+			return &antivirus.Verdict{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+		}
+		return &antivirus.Verdict{}, nil
+	})
+	handler := &JournalEntryHandler{Store: store, Storage: files, MaxUploadSize: 1 << 10,
+		Antivirus: &antivirus.Service{Scanner: scanner, Quarantine: storage.NewMemoryStorage(), Store: quarantine}}
+	store.CreateJournalEntry(context.Background(), &models.JournalEntry{Justification: "Write off stale balance"})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "receipt.pdf")
+	part.Write([]byte("EICAR test file"))
+	form.Close()
+	req := httptest.NewRequest("POST", "/general_ledger/journal_entries/1/attachment", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+	handler.UploadAttachment(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Eicar-Test-Signature")
+	assert.Empty(t, files.Files)
+	assert.Empty(t, store.entries[1].AttachmentURL)
+	if assert.Len(t, quarantine.files, 1) {
+		assert.Equal(t, models.UploadJournalAttachment, quarantine.files[0].Source)
+		assert.Equal(t, 1, quarantine.files[0].EntityID)
+	}
+}
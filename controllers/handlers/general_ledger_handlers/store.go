@@ -1,32 +1,86 @@
 package general_ledger_handlers
 
 import (
+	"context"
 	"database/sql"
+	"erp/controllers/handlers/report_handlers"
+	"erp/controllers/versioning"
 	"erp/models"
-	"fmt"
+	"erp/models/db"
+	"erp/models/db/queries"
 )
 
 // DBFinancialTransactionStore provides SQL-backed methods to manage financial transactions.
 // It acts as a store for interacting with the financial_transactions table in the database.
 type DBFinancialTransactionStore struct {
 	DB *sql.DB // DB represents the database connection.
+
+	stmts db.Statements // Prepared statements of the typed queries
+}
+
+// queries returns the typed queries of the store, prepared on first use.
+func (store *DBFinancialTransactionStore) queries() *queries.Queries {
+	return queries.Prepared(store.DB, &store.stmts)
 }
 
 // CreateTransaction inserts a new financial transaction into the database.
 // It populates the ID of the transaction with the auto-generated ID from the database.
+// The per-period account balance is updated in the same database transaction.
 //
 // Parameters:
 //   - transaction: A pointer to the FinancialTransaction object containing transaction details.
 //
 // Returns:
 //   - error: An error object if the transaction fails to be created, otherwise nil.
-func (store *DBFinancialTransactionStore) CreateTransaction(transaction *models.FinancialTransaction) error {
-	err := store.DB.QueryRow(
-		"INSERT INTO financial_transactions (account_type, amount, transaction_date) VALUES ($1, $2, $3) RETURNING id",
-		transaction.AccountType, transaction.Amount, transaction.TransactionDate,
-	).Scan(&transaction.ID) // Scan the generated ID into the transaction.ID field
+func (store *DBFinancialTransactionStore) CreateTransaction(ctx context.Context, transaction *models.FinancialTransaction) error {
+	q := store.queries()
+	if err := q.Prepare(ctx, queries.InsertFinancialTransactionSQL); err != nil {
+		return err
+	}
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	return err
+	if err := insertTransaction(ctx, q.WithTx(tx), tx, transaction); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertTransaction records a financial transaction and its effect on the account balances
+// using the caller's database transaction. Documents that post to the ledger, such as
+// invoices and journal entries, use it so the ledger lines commit with the document.
+//
+// Parameters:
+//   - tx: The database transaction to write with.
+//   - transaction: The financial transaction to insert; its ID is populated.
+//
+// Returns:
+//   - error: An error object if the transaction fails to be recorded, otherwise nil.
+func InsertTransaction(ctx context.Context, tx *sql.Tx, transaction *models.FinancialTransaction) error {
+	return insertTransaction(ctx, queries.New(tx), tx, transaction)
+}
+
+// insertTransaction is InsertTransaction with the queries to insert with, which may reuse
+// a prepared statement.
+func insertTransaction(ctx context.Context, q *queries.Queries, tx *sql.Tx, transaction *models.FinancialTransaction) error {
+	id, err := q.InsertFinancialTransaction(ctx, queries.InsertFinancialTransactionParams{
+		AccountType:     transaction.AccountType,
+		Amount:          transaction.Amount,
+		TransactionDate: transaction.TransactionDate,
+		Description:     transaction.Description,
+		InvoiceID:       transaction.InvoiceID,
+		JournalEntryID:  transaction.JournalEntryID,
+	})
+	if err != nil {
+		return err
+	}
+	// Rows start at version 1; only updates increment it
+	transaction.ID, transaction.Version = id, 1
+
+	return report_handlers.ApplyLedgerEntry(ctx, tx, transaction)
 }
 
 // GetTransactionByID retrieves a financial transaction from the database by its ID.
@@ -36,65 +90,110 @@ func (store *DBFinancialTransactionStore) CreateTransaction(transaction *models.
 //
 // Returns:
 //   - *FinancialTransaction: A pointer to the retrieved transaction object.
-//   - error: An error object if the retrieval fails or if the transaction does not exist.
-func (store *DBFinancialTransactionStore) GetTransactionByID(id int) (*models.FinancialTransaction, error) {
-	row := store.DB.QueryRow("SELECT id, account_type, amount, transaction_date FROM financial_transactions WHERE id = $1", id)
-
-	var transaction models.FinancialTransaction
-	err := row.Scan(&transaction.ID, &transaction.AccountType, &transaction.Amount, &transaction.TransactionDate)
+//   - error: models.ErrNotFound if the transaction does not exist, or an error object if the retrieval fails.
+func (store *DBFinancialTransactionStore) GetTransactionByID(ctx context.Context, id int) (*models.FinancialTransaction, error) {
+	row, err := store.queries().GetFinancialTransaction(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("transaction %d not found", id)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &transaction, nil
+	return &models.FinancialTransaction{
+		ID:              row.ID,
+		AccountType:     row.AccountType,
+		Amount:          row.Amount,
+		TransactionDate: row.TransactionDate,
+		Version:         row.Version,
+	}, nil
 }
 
-// UpdateTransaction updates an existing financial transaction in the database.
+// UpdateTransaction updates an existing financial transaction in the database if it is
+// still at transaction.Version, and sets the new version. The old amount is reversed out of
+// the account balances and the new one applied.
 //
 // Parameters:
 //   - transaction: A pointer to the FinancialTransaction object containing updated transaction details.
 //
 // Returns:
-//   - error: An error object if the update fails, or if the transaction ID does not exist.
-func (store *DBFinancialTransactionStore) UpdateTransaction(transaction *models.FinancialTransaction) error {
-	result, err := store.DB.Exec(
-		"UPDATE financial_transactions SET account_type = $1, amount = $2, transaction_date = $3 WHERE id = $4",
-		transaction.AccountType, transaction.Amount, transaction.TransactionDate, transaction.ID,
-	)
+//   - error: models.ErrNotFound if the transaction does not exist, a models.Conflict error if
+//     it was changed since transaction.Version, or an error object if the update fails.
+func (store *DBFinancialTransactionStore) UpdateTransaction(ctx context.Context, transaction *models.FinancialTransaction) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	q := store.queries().WithTx(tx)
+	locked, err := q.LockFinancialTransaction(ctx, transaction.ID)
+	if err == sql.ErrNoRows {
+		return models.NotFound("transaction %d not found", transaction.ID)
+	}
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("transaction with ID %d does not exist", transaction.ID)
+	previous := models.FinancialTransaction{
+		AccountType:     locked.AccountType,
+		Amount:          locked.Amount,
+		TransactionDate: locked.TransactionDate,
+	}
+
+	version, err := q.UpdateFinancialTransaction(ctx, queries.UpdateFinancialTransactionParams{
+		AccountType:     transaction.AccountType,
+		Amount:          transaction.Amount,
+		TransactionDate: transaction.TransactionDate,
+		ID:              transaction.ID,
+		Version:         transaction.Version,
+	})
+	if err == sql.ErrNoRows {
+		return versioning.Stale(ctx, tx, "SELECT version FROM financial_transactions WHERE id = $1", "transaction",
+			transaction.ID, transaction.Version)
+	}
+	if err != nil {
+		return err
 	}
+	transaction.Version = version
 
-	return nil
+	if err := report_handlers.ReverseLedgerEntry(ctx, tx, &previous); err != nil {
+		return err
+	}
+	if err := report_handlers.ApplyLedgerEntry(ctx, tx, transaction); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // DeleteTransaction deletes a financial transaction from the database by its ID.
+// Its amount is reversed out of the account balances.
 //
 // Parameters:
 //   - id: The ID of the transaction to delete.
 //
 // Returns:
-//   - error: An error object if the deletion fails, or if the transaction ID does not exist.
-func (store *DBFinancialTransactionStore) DeleteTransaction(id int) error {
-	result, err := store.DB.Exec("DELETE FROM financial_transactions WHERE id = $1", id)
+//   - error: models.ErrNotFound if the transaction does not exist, or an error object if the deletion fails.
+func (store *DBFinancialTransactionStore) DeleteTransaction(ctx context.Context, id int) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	row, err := store.queries().WithTx(tx).DeleteFinancialTransaction(ctx, id)
+	if err == sql.ErrNoRows {
+		return models.NotFound("transaction %d not found", id)
+	}
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("transaction with ID %d does not exist", id)
+	deleted := models.FinancialTransaction{
+		AccountType:     row.AccountType,
+		Amount:          row.Amount,
+		TransactionDate: row.TransactionDate,
 	}
 
-	return nil
+	if err := report_handlers.ReverseLedgerEntry(ctx, tx, &deleted); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
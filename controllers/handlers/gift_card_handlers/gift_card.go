@@ -0,0 +1,164 @@
+// Package gift_card_handlers provides HTTP handlers for gift cards and store credit:
+// issuing cards, looking up their balance and paying invoices with them.
+package gift_card_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/giftcards"
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GiftCardHandlers provides the gift card endpoints.
+type GiftCardHandlers struct {
+	Service *giftcards.Service
+}
+
+// IssueGiftCardRequest is the request body for issuing a gift card. The code and balance
+// are set by the server.
+type IssueGiftCardRequest struct {
+	Kind       string     `json:"kind"`
+	Amount     float64    `json:"amount"`
+	CustomerID *int       `json:"customer_id"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// GiftCard returns the card described by the request.
+func (req IssueGiftCardRequest) GiftCard() models.GiftCard {
+	return models.GiftCard{
+		Kind:          req.Kind,
+		InitialAmount: req.Amount,
+		CustomerID:    req.CustomerID,
+		ExpiresAt:     req.ExpiresAt,
+	}
+}
+
+// GiftCardPaymentRequest is the request body for paying an invoice with a gift card.
+type GiftCardPaymentRequest struct {
+	Code   string  `json:"code"`
+	Amount float64 `json:"amount"`
+}
+
+// IssueGiftCard issues a gift card or store credit with a new code. The balance is posted
+// to the gift card liability.
+//
+// HTTP Method: POST
+// URL Path: /gift_cards
+//
+// Request Body:
+//   - JSON with kind ("gift_card" or "store_credit"), amount and optionally customer_id and
+//     expires_at (see IssueGiftCardRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the card, including its code, in JSON format.
+//   - Status Code: 400 (Bad Request) if the payload is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the kind, amount, customer or expiry is invalid.
+//   - Status Code: 500 (Internal Server Error) if the card could not be issued.
+func (h *GiftCardHandlers) IssueGiftCard(w http.ResponseWriter, r *http.Request) {
+	var req IssueGiftCardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	card := req.GiftCard()
+	if err := h.Service.Issue(r.Context(), &card); err != nil {
+		httperr.Write(w, err, "Failed to issue gift card")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, card)
+}
+
+// GetGiftCard returns a card's balance, expiry and transactions. The code may be given
+// in lower case and without dashes.
+//
+// HTTP Method: GET
+// URL Path: /gift_cards/{code}
+//
+// Response:
+//   - Status Code: 200 (OK) with the card in JSON format.
+//   - Status Code: 404 (Not Found) if no card has the code.
+//   - Status Code: 500 (Internal Server Error) if the card could not be loaded.
+func (h *GiftCardHandlers) GetGiftCard(w http.ResponseWriter, r *http.Request) {
+	card, err := h.Service.Lookup(r.Context(), mux.Vars(r)["code"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to load gift card")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, card)
+}
+
+// GetCustomerGiftCards returns the gift cards and store credit issued to a customer.
+//
+// HTTP Method: GET
+// URL Path: /customers/{id}/gift_cards
+//
+// Response:
+//   - Status Code: 200 (OK) with a JSON array of cards.
+//   - Status Code: 400 (Bad Request) if the customer ID is invalid.
+//   - Status Code: 404 (Not Found) if the customer does not exist.
+//   - Status Code: 500 (Internal Server Error) if the cards could not be loaded.
+func (h *GiftCardHandlers) GetCustomerGiftCards(w http.ResponseWriter, r *http.Request) {
+	customerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	cards, err := h.Service.CustomerCards(r.Context(), customerID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load gift cards")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, cards)
+}
+
+// PayInvoiceWithGiftCard pays part or all of a posted invoice from a gift card. A payment
+// with the method "gift_card" is recorded and the amount is moved from the gift card
+// liability to the receivable.
+//
+// HTTP Method: POST
+// URL Path: /invoices/{id}/gift_card_payments
+//
+// Request Body:
+//   - JSON with the card's code and the amount to pay (see GiftCardPaymentRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the payment in JSON format.
+//   - Status Code: 400 (Bad Request) if the invoice ID or payload is invalid.
+//   - Status Code: 404 (Not Found) if the card or invoice does not exist.
+//   - Status Code: 409 (Conflict) if the card has expired or its balance is too low.
+//   - Status Code: 422 (Unprocessable Entity) if the amount is not positive or exceeds what is
+//     outstanding, the invoice is not posted or the store credit is another customer's.
+//   - Status Code: 500 (Internal Server Error) if the payment could not be recorded.
+func (h *GiftCardHandlers) PayInvoiceWithGiftCard(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	var req GiftCardPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	payment, err := h.Service.Apply(r.Context(), req.Code, invoiceID, req.Amount)
+	if err != nil {
+		httperr.Write(w, err, "Failed to pay invoice with gift card")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, payment)
+}
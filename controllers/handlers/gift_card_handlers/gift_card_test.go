@@ -0,0 +1,210 @@
+package gift_card_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/controllers/giftcards"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var giftCardColumns = []string{"id", "code", "kind", "customer_id", "initial_amount", "balance", "expires_at", "issued_at"}
+
+// newTestRouter serves the gift card routes from a DBGiftCardStore on a mock database.
+func newTestRouter(t *testing.T) (*mux.Router, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	handlers := &GiftCardHandlers{Service: giftcards.NewService(&DBGiftCardStore{DB: db}, config.GiftCardConfig{})}
+	router := mux.NewRouter()
+	router.HandleFunc("/gift_cards", handlers.IssueGiftCard).Methods("POST")
+	router.HandleFunc("/gift_cards/{code}", handlers.GetGiftCard).Methods("GET")
+	router.HandleFunc("/invoices/{id:[0-9]+}/gift_card_payments", handlers.PayInvoiceWithGiftCard).Methods("POST")
+	return router, mock
+}
+
+// expectLedgerLines expects a debit and a credit of amount with their period balance updates.
+func expectLedgerLines(mock sqlmock.Sqlmock, debit, credit string, amount float64) {
+	for _, line := range []struct {
+		account string
+		amount  float64
+	}{{debit, amount}, {credit, -amount}} {
+		mock.ExpectQuery("INSERT INTO financial_transactions").
+			WithArgs(line.account, line.amount, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO account_period_balances").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+// TestIssueStoreCredit verifies that issuing store credit records the card and its issue
+// transaction and credits the liability against sales returns.
+func TestIssueStoreCredit(t *testing.T) {
+	router, mock := newTestRouter(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("INSERT INTO gift_cards").
+		WithArgs(sqlmock.AnyArg(), models.GiftCardCredit, 3, 40.0, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("INSERT INTO gift_card_transactions").
+		WithArgs(7, models.GiftCardIssue, 40.0, nil, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectLedgerLines(mock, "sales_returns", "gift_card_liability", 40)
+	mock.ExpectCommit()
+
+	body := `{"kind": "store_credit", "amount": 40, "customer_id": 3}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/gift_cards", bytes.NewBufferString(body)))
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var card models.GiftCard
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &card))
+	assert.Equal(t, 7, card.ID)
+	assert.Len(t, card.Code, 19)
+	assert.Equal(t, 40.0, card.Balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetGiftCard(t *testing.T) {
+	router, mock := newTestRouter(t)
+	issuedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id, code, kind, customer_id").WithArgs("KX7M-P2QD-W9HT-AC4R").
+		WillReturnRows(sqlmock.NewRows(giftCardColumns).AddRow(7, "KX7M-P2QD-W9HT-AC4R", models.GiftCardSold, nil, 50.0, 20.0, nil, issuedAt))
+	mock.ExpectQuery("SELECT id, gift_card_id, kind").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "gift_card_id", "kind", "amount", "invoice_id", "payment_id", "created_at"}).
+			AddRow(2, 7, models.GiftCardRedeem, -30.0, 4, 11, issuedAt.Add(time.Hour)).
+			AddRow(1, 7, models.GiftCardIssue, 50.0, nil, nil, issuedAt))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/gift_cards/kx7mp2qdw9htac4r", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var card models.GiftCard
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &card))
+	assert.Equal(t, 20.0, card.Balance)
+	assert.Nil(t, card.CustomerID)
+	require.Len(t, card.Transactions, 2)
+	assert.Equal(t, 11, *card.Transactions[0].PaymentID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery("SELECT id, code, kind, customer_id").WillReturnRows(sqlmock.NewRows(giftCardColumns))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/gift_cards/NOPE", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// TestPayInvoiceWithGiftCard verifies that a payment is recorded, the card is charged and
+// the amount is moved from the liability to the receivable in one transaction.
+func TestPayInvoiceWithGiftCard(t *testing.T) {
+	router, mock := newTestRouter(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, code, kind, customer_id.* FOR UPDATE").WithArgs("KX7M-P2QD-W9HT-AC4R").
+		WillReturnRows(sqlmock.NewRows(giftCardColumns).AddRow(7, "KX7M-P2QD-W9HT-AC4R", models.GiftCardSold, nil, 50.0, 50.0, nil, time.Now()))
+	mock.ExpectQuery("SELECT customer_id, amount, status FROM invoices").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "amount", "status"}).AddRow(3, 100.0, models.InvoiceStatusPosted))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM payments WHERE invoice_id = \\$1 AND deleted_at IS NULL").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(60.0))
+	mock.ExpectQuery("INSERT INTO payments").
+		WithArgs(4, 30.0, sqlmock.AnyArg(), models.PaymentMethodGiftCard).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE gift_cards SET balance = balance").WithArgs(30.0, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO gift_card_transactions").
+		WithArgs(7, models.GiftCardRedeem, -30.0, 4, 11, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	expectLedgerLines(mock, "gift_card_liability", "accounts_receivable", 30)
+	mock.ExpectCommit()
+
+	body := `{"code": "KX7M-P2QD-W9HT-AC4R", "amount": 30}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/invoices/4/gift_card_payments", bytes.NewBufferString(body)))
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var payment models.Payment
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &payment))
+	assert.Equal(t, 11, payment.ID)
+	assert.Equal(t, models.PaymentMethodGiftCard, payment.PaymentMethod)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPayInvoiceWithGiftCardRejections(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name        string
+		expiresAt   *time.Time
+		balance     float64
+		cardOwner   interface{}
+		status      string
+		outstanding float64
+		want        int
+	}{
+		{"expired card", &past, 50, nil, "", 0, http.StatusConflict},
+		{"balance too low", nil, 20, nil, "", 0, http.StatusConflict},
+		{"draft invoice", nil, 50, nil, models.InvoiceStatusDraft, 0, http.StatusUnprocessableEntity},
+		{"another customer's credit", nil, 50, 8, models.InvoiceStatusPosted, 0, http.StatusUnprocessableEntity},
+		{"more than outstanding", nil, 50, nil, models.InvoiceStatusPosted, 29.99, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mock := newTestRouter(t)
+			var expiresAt interface{}
+			if tt.expiresAt != nil {
+				expiresAt = *tt.expiresAt
+			}
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT id, code, kind, customer_id").
+				WillReturnRows(sqlmock.NewRows(giftCardColumns).AddRow(7, "KX7M-P2QD-W9HT-AC4R", models.GiftCardCredit, tt.cardOwner, 50.0, tt.balance, expiresAt, time.Now()))
+			mock.ExpectQuery("SELECT customer_id, amount, status FROM invoices").
+				WillReturnRows(sqlmock.NewRows([]string{"customer_id", "amount", "status"}).AddRow(3, 100.0, tt.status))
+			mock.ExpectQuery("SELECT COALESCE").
+				WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100 - tt.outstanding))
+			mock.ExpectRollback()
+
+			body := `{"code": "KX7M-P2QD-W9HT-AC4R", "amount": 30}`
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("POST", "/invoices/4/gift_card_payments", bytes.NewBufferString(body)))
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+// TestExpireGiftCards verifies that expired balances are cleared and moved to breakage.
+func TestExpireGiftCards(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBGiftCardStore{DB: db}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, code, balance FROM gift_cards").WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "balance"}).AddRow(7, "KX7M-P2QD-W9HT-AC4R", 12.5).AddRow(9, "QQQQ-QQQQ-QQQQ-RRRR", 5.0))
+	for _, card := range []struct {
+		id      int
+		balance float64
+	}{{7, 12.5}, {9, 5}} {
+		mock.ExpectExec("UPDATE gift_cards SET balance = 0").WithArgs(card.id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO gift_card_transactions").
+			WithArgs(card.id, models.GiftCardExpiry, -card.balance, nil, nil, now).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		expectLedgerLines(mock, "gift_card_liability", "gift_card_breakage", card.balance)
+	}
+	mock.ExpectCommit()
+
+	expired, err := store.ExpireGiftCards(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 17.5, expired)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,381 @@
+package gift_card_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/controllers/events"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+)
+
+// Ledger accounts of gift cards. The liability is credited when a card is issued and
+// debited as the card is spent or expires.
+const (
+	liabilityAccount = "gift_card_liability"
+	breakageAccount  = "gift_card_breakage" // Income from balances that expire unspent
+)
+
+// issueAccounts are the accounts debited when a card of each kind is issued.
+var issueAccounts = map[string]string{
+	models.GiftCardSold:   "cash",
+	models.GiftCardCredit: "sales_returns",
+}
+
+// DBGiftCardStore implements models.GiftCardStore using a SQL database. Every change to a
+// balance is recorded in gift_card_transactions and posted to the ledger in the same
+// transaction.
+type DBGiftCardStore struct {
+	DB *sql.DB
+}
+
+// IssueGiftCard stores a new card with its issue transaction and credits the liability.
+//
+// Parameters:
+//   - card: The card to store; its ID is populated.
+//
+// Returns:
+//   - error: models.ErrGiftCardCodeCollision if the code is taken, a validation error if
+//     the customer does not exist, or the query error.
+func (s *DBGiftCardStore) IssueGiftCard(ctx context.Context, card *models.GiftCard) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if card.CustomerID != nil {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1 AND deleted_at IS NULL)", *card.CustomerID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return models.Invalid("customer %d does not exist", *card.CustomerID)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO gift_cards (code, kind, customer_id, initial_amount, balance, expires_at, issued_at)
+		 VALUES ($1, $2, $3, $4, $4, $5, $6)
+		 ON CONFLICT (code) DO NOTHING RETURNING id`,
+		card.Code, card.Kind, card.CustomerID, card.InitialAmount, card.ExpiresAt, card.IssuedAt,
+	).Scan(&card.ID)
+	if err == sql.ErrNoRows {
+		return models.ErrGiftCardCodeCollision
+	} else if err != nil {
+		return fmt.Errorf("failed to issue gift card: %w", err)
+	}
+
+	if _, err := insertGiftCardTransaction(ctx, tx, card.ID, models.GiftCardIssue, card.InitialAmount, nil, nil, card.IssuedAt); err != nil {
+		return err
+	}
+	description := fmt.Sprintf("Gift card ending %s issued", lastFour(card.Code))
+	err = postLedgerLines(ctx, tx, card.IssuedAt, description, nil, issueAccounts[card.Kind], liabilityAccount, card.InitialAmount)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetGiftCardByCode returns a card with its transactions, newest first.
+//
+// Returns:
+//   - *models.GiftCard: The card.
+//   - error: models.ErrNotFound if no card has the code, or the query error.
+func (s *DBGiftCardStore) GetGiftCardByCode(ctx context.Context, code string) (*models.GiftCard, error) {
+	card, err := scanGiftCard(s.DB.QueryRowContext(ctx,
+		`SELECT id, code, kind, customer_id, initial_amount, balance, expires_at, issued_at
+		 FROM gift_cards WHERE code = $1`, code,
+	))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("gift card not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, gift_card_id, kind, amount, invoice_id, payment_id, created_at
+		 FROM gift_card_transactions WHERE gift_card_id = $1
+		 ORDER BY created_at DESC, id DESC`, card.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gift card transactions: %w", err)
+	}
+	defer rows.Close()
+	card.Transactions = []models.GiftCardTransaction{}
+	for rows.Next() {
+		var t models.GiftCardTransaction
+		var invoiceID, paymentID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.GiftCardID, &t.Kind, &t.Amount, &invoiceID, &paymentID, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.InvoiceID = nullableID(invoiceID)
+		t.PaymentID = nullableID(paymentID)
+		card.Transactions = append(card.Transactions, t)
+	}
+	return card, rows.Err()
+}
+
+// GetGiftCardsByCustomer returns the cards issued to a customer, newest first, without
+// their transactions.
+//
+// Returns:
+//   - []models.GiftCard: The cards; empty if the customer has none.
+//   - error: models.ErrNotFound if the customer does not exist, or the query error.
+func (s *DBGiftCardStore) GetGiftCardsByCustomer(ctx context.Context, customerID int) ([]models.GiftCard, error) {
+	var exists bool
+	if err := s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1 AND deleted_at IS NULL)", customerID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to load customer: %w", err)
+	}
+	if !exists {
+		return nil, models.NotFound("customer %d not found", customerID)
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, code, kind, customer_id, initial_amount, balance, expires_at, issued_at
+		 FROM gift_cards WHERE customer_id = $1
+		 ORDER BY issued_at DESC, id DESC`, customerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gift cards: %w", err)
+	}
+	defer rows.Close()
+	cards := []models.GiftCard{}
+	for rows.Next() {
+		card, err := scanGiftCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, *card)
+	}
+	return cards, rows.Err()
+}
+
+// ApplyGiftCard pays amount of a posted invoice from a card's balance. The payment and its
+// PaymentPosted event, the redemption and the ledger lines moving the amount from the
+// liability to the receivable are recorded in one transaction.
+//
+// Returns:
+//   - *models.Payment: The payment.
+//   - error: models.ErrNotFound if the card or invoice does not exist,
+//     models.ErrGiftCardExpired or models.ErrInsufficientGiftCard if the card cannot pay,
+//     a validation error if the invoice is not posted, belongs to another customer than
+//     the store credit or has less outstanding, or the query error.
+func (s *DBGiftCardStore) ApplyGiftCard(ctx context.Context, code string, invoiceID int, amount float64, now time.Time) (*models.Payment, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	card, err := scanGiftCard(tx.QueryRowContext(ctx,
+		`SELECT id, code, kind, customer_id, initial_amount, balance, expires_at, issued_at
+		 FROM gift_cards WHERE code = $1 FOR UPDATE`, code,
+	))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("gift card not found")
+	} else if err != nil {
+		return nil, err
+	}
+	if card.ExpiresAt != nil && !card.ExpiresAt.After(now) {
+		return nil, models.ErrGiftCardExpired
+	}
+	if card.Balance < amount {
+		return nil, models.ErrInsufficientGiftCard
+	}
+
+	var invoiceCustomer sql.NullInt64
+	var invoiceAmount, paid float64
+	var status sql.NullString
+	err = tx.QueryRowContext(ctx,
+		"SELECT customer_id, amount, status FROM invoices WHERE id = $1 FOR UPDATE", invoiceID,
+	).Scan(&invoiceCustomer, &invoiceAmount, &status)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("invoice %d not found", invoiceID)
+	} else if err != nil {
+		return nil, err
+	}
+	if status.String != models.InvoiceStatusPosted {
+		return nil, models.Invalid("invoice %d must be posted before it is paid", invoiceID)
+	}
+	if card.CustomerID != nil && int64(*card.CustomerID) != invoiceCustomer.Int64 {
+		return nil, models.Invalid("the store credit belongs to another customer than invoice %d", invoiceID)
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = $1 AND deleted_at IS NULL", invoiceID).Scan(&paid); err != nil {
+		return nil, err
+	}
+	// Compare in cents to avoid floating point noise
+	if outstanding := invoiceAmount - paid; roundCents(amount) > roundCents(outstanding) {
+		return nil, models.Invalid("a payment of %.2f exceeds the %.2f outstanding on invoice %d", amount, outstanding, invoiceID)
+	}
+
+	payment := &models.Payment{
+		InvoiceID:     invoiceID,
+		Amount:        amount,
+		PaymentDate:   now,
+		PaymentMethod: models.PaymentMethodGiftCard,
+	}
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO payments (invoice_id, amount, payment_date, payment_method) VALUES ($1, $2, $3, $4) RETURNING id",
+		payment.InvoiceID, payment.Amount, payment.PaymentDate, payment.PaymentMethod,
+	).Scan(&payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := events.Enqueue(ctx, tx, events.PaymentPosted, "payment", payment.ID, payment); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE gift_cards SET balance = balance - $1 WHERE id = $2", amount, card.ID); err != nil {
+		return nil, err
+	}
+	if _, err := insertGiftCardTransaction(ctx, tx, card.ID, models.GiftCardRedeem, -amount, &invoiceID, &payment.ID, now); err != nil {
+		return nil, err
+	}
+	description := fmt.Sprintf("Invoice #%d paid with gift card ending %s", invoiceID, lastFour(card.Code))
+	if err := postLedgerLines(ctx, tx, now, description, &invoiceID, liabilityAccount, "accounts_receivable", amount); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// ExpireGiftCards clears the balance of every card that expired at or before now. Each
+// balance is recorded as an expiry and moved from the liability to breakage income.
+//
+// Returns:
+//   - float64: The total balance expired.
+//   - error: An error if the expiries cannot be recorded; then none are.
+func (s *DBGiftCardStore) ExpireGiftCards(ctx context.Context, now time.Time) (float64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, code, balance FROM gift_cards
+		 WHERE balance > 0 AND expires_at <= $1
+		 ORDER BY id
+		 FOR UPDATE`, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired gift cards: %w", err)
+	}
+	type due struct {
+		id      int
+		code    string
+		balance float64
+	}
+	var cards []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.id, &d.code, &d.balance); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		cards = append(cards, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, card := range cards {
+		if _, err := tx.ExecContext(ctx, "UPDATE gift_cards SET balance = 0 WHERE id = $1", card.id); err != nil {
+			return 0, err
+		}
+		if _, err := insertGiftCardTransaction(ctx, tx, card.id, models.GiftCardExpiry, -card.balance, nil, nil, now); err != nil {
+			return 0, err
+		}
+		description := fmt.Sprintf("Gift card ending %s expired", lastFour(card.code))
+		if err := postLedgerLines(ctx, tx, now, description, nil, liabilityAccount, breakageAccount, card.balance); err != nil {
+			return 0, err
+		}
+		total += card.balance
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return roundCents(total), nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGiftCard reads the gift card columns in the order the queries above select them.
+func scanGiftCard(row rowScanner) (*models.GiftCard, error) {
+	var card models.GiftCard
+	var customerID sql.NullInt64
+	var expiresAt sql.NullTime
+	err := row.Scan(&card.ID, &card.Code, &card.Kind, &customerID, &card.InitialAmount, &card.Balance, &expiresAt, &card.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	card.CustomerID = nullableID(customerID)
+	if expiresAt.Valid {
+		card.ExpiresAt = &expiresAt.Time
+	}
+	return &card, nil
+}
+
+// insertGiftCardTransaction records a change to a card's balance and returns its ID.
+func insertGiftCardTransaction(ctx context.Context, tx *sql.Tx, cardID int, kind string, amount float64, invoiceID, paymentID *int, at time.Time) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO gift_card_transactions (gift_card_id, kind, amount, invoice_id, payment_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		cardID, kind, amount, invoiceID, paymentID, at,
+	).Scan(&id)
+	return id, err
+}
+
+// postLedgerLines posts a balanced pair of ledger lines debiting one account and crediting
+// another by amount.
+func postLedgerLines(ctx context.Context, tx *sql.Tx, at time.Time, description string, invoiceID *int, debit, credit string, amount float64) error {
+	lines := []models.FinancialTransaction{
+		{AccountType: debit, Amount: amount},
+		{AccountType: credit, Amount: -amount},
+	}
+	for i := range lines {
+		lines[i].TransactionDate = at
+		lines[i].Description = description
+		lines[i].InvoiceID = invoiceID
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &lines[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullableID returns a pointer to a nullable ID column, or nil if it is NULL.
+func nullableID(id sql.NullInt64) *int {
+	if !id.Valid {
+		return nil
+	}
+	v := int(id.Int64)
+	return &v
+}
+
+// lastFour returns the last four characters of a code, which is all the ledger shows of it.
+func lastFour(code string) string {
+	if len(code) <= 4 {
+		return code
+	}
+	return code[len(code)-4:]
+}
+
+// roundCents rounds an amount to whole cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,91 @@
+// Package graphql_handlers serves the GraphQL endpoint, which lets the dashboard read
+// nested data such as a customer with their invoices and payments in one request.
+package graphql_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"erp/controllers/graphql"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBodySize bounds the size of a GraphQL request.
+const maxBodySize = 1 << 20
+
+// PermissionChecker reports whether a role grants one of the required permissions,
+// typically the rbac service.
+type PermissionChecker interface {
+	Allowed(ctx context.Context, role string, required ...string) (bool, error)
+}
+
+// GraphQLHandler serves queries against a schema.
+type GraphQLHandler struct {
+	Schema *graphql.Schema
+	Access PermissionChecker // Decides which fields the caller's role may query
+}
+
+// RegisterRoutes maps the GraphQL routes to their handler functions.
+// The router is expected to be protected with middleware.JWTAuth; the permissions of the
+// caller's role limit the fields they can query.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - schema: The schema queries run against.
+//   - access: Checks the permissions of the caller's role.
+func RegisterRoutes(router *mux.Router, schema *graphql.Schema, access PermissionChecker) {
+	handler := &GraphQLHandler{Schema: schema, Access: access}
+
+	router.HandleFunc("", handler.Query).Methods("POST")
+	router.HandleFunc("", handler.GetSchema).Methods("GET")
+}
+
+// Query runs a GraphQL query.
+//
+// HTTP Method: POST
+// URL Path: /graphql
+//
+// Request Body:
+//   - JSON object with the "query", and optionally "variables" and "operationName".
+//
+// Response:
+//   - Status Code: 200 (OK) with "data" and, if some fields could not be resolved, "errors"
+//     in JSON. Fields the caller's permissions do not cover are null with a "permission
+//     denied" error.
+//   - Status Code: 400 (Bad Request) with "errors" in JSON if the query is invalid, nests
+//     too deeply or is too costly to run.
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	req.Allowed = func(ctx context.Context, permissions ...string) (bool, error) {
+		return h.Access.Allowed(ctx, role, permissions...)
+	}
+
+	response := h.Schema.Execute(r.Context(), &req)
+	status := http.StatusOK
+	if response.Data == nil {
+		status = http.StatusBadRequest
+	}
+	respond.JSON(w, status, response)
+}
+
+// GetSchema returns the schema in the GraphQL schema definition language. Fields limited
+// to permissions are marked with @auth.
+//
+// HTTP Method: GET
+// URL Path: /graphql
+//
+// Response:
+//   - Status Code: 200 (OK) with the schema as plain text.
+func (h *GraphQLHandler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(h.Schema.SDL()))
+}
@@ -0,0 +1,230 @@
+package graphql_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/controllers/handlers/accounts_payable_handlers"
+	"erp/controllers/handlers/customer_data_management_handlers"
+	"erp/controllers/handlers/invoice_handlers"
+	"erp/controllers/middleware"
+	"erp/controllers/rbac"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGraphStore serves fixed records and counts its calls.
+type fakeGraphStore struct {
+	calls map[string]int
+}
+
+var (
+	testCustomers = []models.Customer{{ID: 1, Name: "Acme"}, {ID: 2, Name: "Globex"}}
+	testInvoices  = []models.Invoice{
+		{ID: 10, CustomerID: 1, Amount: 100, Status: "posted"},
+		{ID: 11, CustomerID: 1, Amount: 50, Status: "draft"},
+		{ID: 12, CustomerID: 2, Amount: 75, Status: "posted"},
+	}
+	testPayments = []models.Payment{
+		{ID: 20, InvoiceID: 10, Amount: 60, PaymentDate: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), PaymentMethod: "card"},
+		{ID: 21, InvoiceID: 12, Amount: 75, PaymentDate: time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), PaymentMethod: "cash"},
+	}
+)
+
+func (f *fakeGraphStore) CustomersByID(ctx context.Context, ids []int) (map[int]*models.Customer, error) {
+	f.calls["CustomersByID"]++
+	byID := map[int]*models.Customer{}
+	for i, c := range testCustomers {
+		for _, id := range ids {
+			if c.ID == id {
+				byID[id] = &testCustomers[i]
+			}
+		}
+	}
+	return byID, nil
+}
+
+func (f *fakeGraphStore) CustomersPage(ctx context.Context, limit, offset int) ([]models.Customer, error) {
+	f.calls["CustomersPage"]++
+	return testCustomers, nil
+}
+
+func (f *fakeGraphStore) InvoicesByID(ctx context.Context, ids []int) (map[int]*models.Invoice, error) {
+	f.calls["InvoicesByID"]++
+	byID := map[int]*models.Invoice{}
+	for i, invoice := range testInvoices {
+		for _, id := range ids {
+			if invoice.ID == id {
+				byID[id] = &testInvoices[i]
+			}
+		}
+	}
+	return byID, nil
+}
+
+func (f *fakeGraphStore) InvoicesByCustomer(ctx context.Context, customerIDs []int, status string) (map[int][]models.Invoice, error) {
+	f.calls["InvoicesByCustomer"]++
+	byCustomer := map[int][]models.Invoice{}
+	for _, invoice := range testInvoices {
+		if status == "" || invoice.Status == status {
+			byCustomer[invoice.CustomerID] = append(byCustomer[invoice.CustomerID], invoice)
+		}
+	}
+	return byCustomer, nil
+}
+
+func (f *fakeGraphStore) PaymentsByInvoice(ctx context.Context, invoiceIDs []int) (map[int][]models.Payment, error) {
+	f.calls["PaymentsByInvoice"]++
+	byInvoice := map[int][]models.Payment{}
+	for _, payment := range testPayments {
+		byInvoice[payment.InvoiceID] = append(byInvoice[payment.InvoiceID], payment)
+	}
+	return byInvoice, nil
+}
+
+// fakeAccess grants each test role fixed permissions.
+type fakeAccess map[string][]string
+
+func (f fakeAccess) Allowed(ctx context.Context, role string, required ...string) (bool, error) {
+	return rbac.Grants(f[role], required...), nil
+}
+
+var testAccess = fakeAccess{
+	"Admin":       {rbac.All},
+	"Accountant":  {rbac.Finance},
+	"Sales Group": {rbac.Sales},
+	"Employee":    {rbac.Basic},
+}
+
+func setupRouter() (*mux.Router, *fakeGraphStore) {
+	store := &fakeGraphStore{calls: map[string]int{}}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/graphql").Subrouter(), NewSchema(store, 6, 5000), testAccess)
+	return router, store
+}
+
+func query(router *mux.Router, role, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserRole, role))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestQueryNestedDataInOneStoreCallPerLevel(t *testing.T) {
+	router, store := setupRouter()
+
+	rr := query(router, "Accountant", `{"query": "{ customers { name invoices(status: \"posted\") { id amount payments { amount payment_date } } } }"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"data": {"customers": [
+		{"name": "Acme", "invoices": [{"id": 10, "amount": 100, "payments": [{"amount": 60, "payment_date": "2026-10-01"}]}]},
+		{"name": "Globex", "invoices": [{"id": 12, "amount": 75, "payments": [{"amount": 75, "payment_date": "2026-10-02"}]}]}
+	]}}`, rr.Body.String())
+	assert.Equal(t, map[string]int{"CustomersPage": 1, "InvoicesByCustomer": 1, "PaymentsByInvoice": 1}, store.calls)
+}
+
+func TestQueryLimitsFieldsByPermission(t *testing.T) {
+	router, _ := setupRouter()
+
+	rr := query(router, "Sales Group", `{"query": "query ($id: Int!) { invoice(id: $id) { status customer { name } payments { amount } } }", "variables": {"id": 11}}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Data   map[string]interface{}
+		Errors []struct {
+			Message string
+			Path    []interface{}
+		}
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, map[string]interface{}{"status": "draft", "customer": map[string]interface{}{"name": "Acme"}, "payments": nil}, response.Data["invoice"])
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, []interface{}{"invoice", "payments"}, response.Errors[0].Path)
+
+	rr = query(router, "Employee", `{"query": "{ customer(id: 2) { name invoices { id } } }"}`)
+	assert.Contains(t, rr.Body.String(), `"customer":null`)
+	assert.Contains(t, rr.Body.String(), `field \"customer\" is limited to sales_permissions, finance_permissions`)
+}
+
+func TestQueryRejectsInvalidRequests(t *testing.T) {
+	router, store := setupRouter()
+
+	assert.Equal(t, http.StatusBadRequest, query(router, "Admin", `not json`).Code)
+
+	rr := query(router, "Admin", `{"query": "{ customers(limit: 50) { invoices { payments { invoice { payments { id } } } } } }"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "exceeds the limit of 5000")
+	assert.Empty(t, store.calls)
+
+	rr = query(router, "Admin", `{"query": "{ customers(limit: 500) { name } }"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "limit must be between 1 and 100")
+}
+
+func TestGetSchema(t *testing.T) {
+	router, _ := setupRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/graphql", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `payments: [Payment] @auth(permissions: ["finance_permissions"])`)
+}
+
+func TestInvoicesByCustomer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM invoices WHERE customer_id = ANY($1) AND ($2 = '' OR status = $2) AND deleted_at IS NULL ORDER BY id")).
+		WithArgs("{1,2}", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sales_order_id", "customer_id", "amount", "status", "version"}).
+			AddRow(10, 0, 1, 100.0, "posted", 2).
+			AddRow(12, 3, 2, 75.0, "draft", 1))
+
+	byCustomer, err := (&invoice_handlers.DBInvoiceStore{DB: db}).InvoicesByCustomer(context.Background(), []int{1, 2}, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[int][]models.Invoice{
+		1: {{ID: 10, CustomerID: 1, Amount: 100, Status: "posted", Version: 2}},
+		2: {{ID: 12, SalesOrderID: 3, CustomerID: 2, Amount: 75, Status: "draft", Version: 1}},
+	}, byCustomer)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestStoresLeaveOutDeletedRecords verifies that every read of the graph skips soft-deleted
+// customers, invoices and payments, as the REST endpoints do.
+func TestStoresLeaveOutDeletedRecords(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := Stores{
+		CustomerGraphStore: &customer_data_management_handlers.DBStore{DB: db},
+		InvoiceGraphStore:  &invoice_handlers.DBInvoiceStore{DB: db},
+		PaymentGraphStore:  &accounts_payable_handlers.DBPaymentStore{DB: db},
+	}
+	ctx := context.Background()
+
+	for _, table := range []string{"customers", "customers", "invoices", "invoices", "payments"} {
+		mock.ExpectQuery("FROM " + table + " WHERE .*deleted_at IS NULL").WillReturnRows(sqlmock.NewRows(nil))
+	}
+	_, err = store.CustomersByID(ctx, []int{1})
+	require.NoError(t, err)
+	_, err = store.CustomersPage(ctx, 20, 0)
+	require.NoError(t, err)
+	_, err = store.InvoicesByID(ctx, []int{10})
+	require.NoError(t, err)
+	_, err = store.InvoicesByCustomer(ctx, []int{1}, "")
+	require.NoError(t, err)
+	_, err = store.PaymentsByInvoice(ctx, []int{10})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,246 @@
+package graphql_handlers
+
+import (
+	"context"
+
+	"erp/controllers/graphql"
+	"erp/controllers/rbac"
+	"erp/models"
+)
+
+// maxPageSize is the largest page of customers a query can ask for.
+const maxPageSize = 100
+
+// Permissions that may read each type through the graph, as on its REST endpoints.
+// Administrators hold them all.
+var (
+	CustomerPermissions = []string{rbac.Sales, rbac.Finance}
+	InvoicePermissions  = []string{rbac.Finance, rbac.Sales}
+	PaymentPermissions  = []string{rbac.Finance}
+)
+
+// NewSchema builds the ERP graph over a store: customers, their invoices and the payments
+// of those invoices, in both directions.
+//
+// Parameters:
+//   - store: Loads the records of each level in one batch.
+//   - maxDepth: Deepest field nesting allowed.
+//   - maxComplexity: Highest query cost allowed.
+//
+// Returns:
+//   - *graphql.Schema: The schema.
+func NewSchema(store models.GraphStore, maxDepth, maxComplexity int) *graphql.Schema {
+	customer := &graphql.Object{Name: "Customer", Fields: []*graphql.Field{
+		{Name: "id", Type: "Int", Resolve: customerProperty(func(c *models.Customer) interface{} { return c.ID })},
+		{Name: "name", Type: "String", Resolve: customerProperty(func(c *models.Customer) interface{} { return c.Name })},
+		{Name: "contact", Type: "String", Resolve: customerProperty(func(c *models.Customer) interface{} { return c.Contact })},
+		{Name: "order_history", Type: "String", Resolve: customerProperty(func(c *models.Customer) interface{} { return c.OrderHistory })},
+		{
+			Name: "invoices", Type: "[Invoice]", Description: "The customer's invoices, optionally only those with a status.",
+			Args:        []*graphql.Arg{{Name: "status", Type: "String"}},
+			Permissions: InvoicePermissions, ListSize: 10,
+			Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				ids := make([]int, len(parents))
+				for i, p := range parents {
+					ids[i] = p.(*models.Customer).ID
+				}
+				status, _ := args["status"].(string)
+				byCustomer, err := store.InvoicesByCustomer(ctx, unique(ids), status)
+				if err != nil {
+					return nil, err
+				}
+				values := make([]interface{}, len(parents))
+				for i, id := range ids {
+					values[i] = invoicePointers(byCustomer[id])
+				}
+				return values, nil
+			},
+		},
+	}}
+
+	invoice := &graphql.Object{Name: "Invoice", Fields: []*graphql.Field{
+		{Name: "id", Type: "Int", Resolve: invoiceProperty(func(i *models.Invoice) interface{} { return i.ID })},
+		{Name: "sales_order_id", Type: "Int", Resolve: invoiceProperty(func(i *models.Invoice) interface{} { return nullableID(i.SalesOrderID) })},
+		{Name: "customer_id", Type: "Int", Resolve: invoiceProperty(func(i *models.Invoice) interface{} { return nullableID(i.CustomerID) })},
+		{Name: "amount", Type: "Float", Resolve: invoiceProperty(func(i *models.Invoice) interface{} { return i.Amount })},
+		{Name: "status", Type: "String", Resolve: invoiceProperty(func(i *models.Invoice) interface{} { return i.Status })},
+		{
+			Name: "customer", Type: "Customer", Permissions: CustomerPermissions,
+			Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				ids := make([]int, len(parents))
+				for i, p := range parents {
+					ids[i] = p.(*models.Invoice).CustomerID
+				}
+				return loadCustomers(ctx, store, ids)
+			},
+		},
+		{
+			Name: "payments", Type: "[Payment]", Permissions: PaymentPermissions, ListSize: 3,
+			Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				ids := make([]int, len(parents))
+				for i, p := range parents {
+					ids[i] = p.(*models.Invoice).ID
+				}
+				byInvoice, err := store.PaymentsByInvoice(ctx, unique(ids))
+				if err != nil {
+					return nil, err
+				}
+				values := make([]interface{}, len(parents))
+				for i, id := range ids {
+					payments := make([]*models.Payment, len(byInvoice[id]))
+					for j := range byInvoice[id] {
+						payments[j] = &byInvoice[id][j]
+					}
+					values[i] = payments
+				}
+				return values, nil
+			},
+		},
+	}}
+
+	payment := &graphql.Object{Name: "Payment", Fields: []*graphql.Field{
+		{Name: "id", Type: "Int", Resolve: paymentProperty(func(p *models.Payment) interface{} { return p.ID })},
+		{Name: "invoice_id", Type: "Int", Resolve: paymentProperty(func(p *models.Payment) interface{} { return p.InvoiceID })},
+		{Name: "amount", Type: "Float", Resolve: paymentProperty(func(p *models.Payment) interface{} { return p.Amount })},
+		{Name: "payment_date", Type: "String", Description: "The payment date, as YYYY-MM-DD.",
+			Resolve: paymentProperty(func(p *models.Payment) interface{} { return p.PaymentDate.Format("2006-01-02") })},
+		{Name: "payment_method", Type: "String", Resolve: paymentProperty(func(p *models.Payment) interface{} { return p.PaymentMethod })},
+		{
+			Name: "invoice", Type: "Invoice", Permissions: InvoicePermissions,
+			Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				ids := make([]int, len(parents))
+				for i, p := range parents {
+					ids[i] = p.(*models.Payment).InvoiceID
+				}
+				return loadInvoices(ctx, store, ids)
+			},
+		},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{
+			Name: "customer", Type: "Customer", Args: []*graphql.Arg{{Name: "id", Type: "Int!"}}, Permissions: CustomerPermissions,
+			Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				return loadCustomers(ctx, store, []int{args["id"].(int)})
+			},
+		},
+		{
+			Name: "customers", Type: "[Customer]", Description: "A page of customers ordered by ID; limit is at most 100.",
+			Args:        []*graphql.Arg{{Name: "limit", Type: "Int", Default: 20}, {Name: "offset", Type: "Int", Default: 0}},
+			Permissions: CustomerPermissions,
+			Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				limit, offset := args["limit"].(int), args["offset"].(int)
+				if limit < 1 || limit > maxPageSize || offset < 0 {
+					return nil, models.Invalid("limit must be between 1 and %d and offset must not be negative", maxPageSize)
+				}
+				customers, err := store.CustomersPage(ctx, limit, offset)
+				if err != nil {
+					return nil, err
+				}
+				list := make([]*models.Customer, len(customers))
+				for i := range customers {
+					list[i] = &customers[i]
+				}
+				return []interface{}{list}, nil
+			},
+		},
+		{
+			Name: "invoice", Type: "Invoice", Args: []*graphql.Arg{{Name: "id", Type: "Int!"}}, Permissions: InvoicePermissions,
+			Resolve: func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				return loadInvoices(ctx, store, []int{args["id"].(int)})
+			},
+		},
+	}}
+
+	schema, err := graphql.NewSchema(query, customer, invoice, payment)
+	if err != nil {
+		panic(err)
+	}
+	schema.MaxDepth, schema.MaxComplexity = maxDepth, maxComplexity
+	return schema
+}
+
+// loadCustomers returns the customer of each ID, or nil if there is none.
+func loadCustomers(ctx context.Context, store models.GraphStore, ids []int) ([]interface{}, error) {
+	byID, err := store.CustomersByID(ctx, unique(ids))
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = byID[id]
+	}
+	return values, nil
+}
+
+// loadInvoices returns the invoice of each ID, or nil if there is none.
+func loadInvoices(ctx context.Context, store models.GraphStore, ids []int) ([]interface{}, error) {
+	byID, err := store.InvoicesByID(ctx, unique(ids))
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = byID[id]
+	}
+	return values, nil
+}
+
+func customerProperty(get func(*models.Customer) interface{}) graphql.ResolveFunc {
+	return func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		values := make([]interface{}, len(parents))
+		for i, p := range parents {
+			values[i] = get(p.(*models.Customer))
+		}
+		return values, nil
+	}
+}
+
+func invoiceProperty(get func(*models.Invoice) interface{}) graphql.ResolveFunc {
+	return func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		values := make([]interface{}, len(parents))
+		for i, p := range parents {
+			values[i] = get(p.(*models.Invoice))
+		}
+		return values, nil
+	}
+}
+
+func paymentProperty(get func(*models.Payment) interface{}) graphql.ResolveFunc {
+	return func(ctx context.Context, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		values := make([]interface{}, len(parents))
+		for i, p := range parents {
+			values[i] = get(p.(*models.Payment))
+		}
+		return values, nil
+	}
+}
+
+func invoicePointers(invoices []models.Invoice) []*models.Invoice {
+	list := make([]*models.Invoice, len(invoices))
+	for i := range invoices {
+		list[i] = &invoices[i]
+	}
+	return list
+}
+
+// nullableID returns nil for a missing reference, which the store reads as 0.
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// unique returns ids without duplicates or missing references, in their first order.
+func unique(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	list := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			list = append(list, id)
+		}
+	}
+	return list
+}
@@ -0,0 +1,11 @@
+package graphql_handlers
+
+import "erp/models"
+
+// Stores implements models.GraphStore with the customer, invoice and payment stores, so
+// the graph reads the same records as the REST endpoints.
+type Stores struct {
+	models.CustomerGraphStore
+	models.InvoiceGraphStore
+	models.PaymentGraphStore
+}
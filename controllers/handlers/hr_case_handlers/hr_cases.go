@@ -0,0 +1,373 @@
+// Package hr_case_handlers provides HTTP handlers and the database store for confidential
+// disciplinary and grievance cases: opening them, adding parties, notes and documents,
+// moving them through their statuses, and anonymous statistics.
+package hr_case_handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"erp/controllers/antivirus"
+	"erp/controllers/hrcases"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// HRCaseHandler provides HTTP handlers for HR cases. The rules live in the hrcases service;
+// the handlers only translate HTTP and keep the documents.
+type HRCaseHandler struct {
+	Service       *hrcases.Service
+	Files         storage.Storage    // Private storage of the case documents; never publicly served
+	MaxUploadSize int64              // Maximum accepted document size in bytes
+	Antivirus     *antivirus.Service // Scans documents before they are stored; nil disables scanning
+}
+
+// CaseRequest is the request body for opening a case.
+type CaseRequest struct {
+	Type    string               `json:"type"` // disciplinary or grievance
+	Summary string               `json:"summary"`
+	Parties []models.HRCaseParty `json:"parties"` // user_id and role of each party
+}
+
+// NoteRequest is the request body for adding a note to a case.
+type NoteRequest struct {
+	Body string `json:"body"`
+}
+
+// StatusRequest is the request body for moving a case to another status.
+type StatusRequest struct {
+	Status  string `json:"status"`
+	Outcome string `json:"outcome"` // Required when resolving
+}
+
+// RegisterRoutes maps HR case routes to their respective handler functions. The router
+// must only let HR through (see rbac.Service.RequireExplicit).
+func RegisterRoutes(router *mux.Router, handler *HRCaseHandler) {
+	router.HandleFunc("", handler.OpenCase).Methods("POST")
+	router.HandleFunc("", handler.ListCases).Methods("GET")
+	router.HandleFunc("/statistics", handler.Statistics).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetCase).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/parties", handler.AddParty).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/notes", handler.AddNote).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/attachments", handler.UploadAttachment).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/attachments/{attachment:[0-9]+}", handler.DownloadAttachment).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/status", handler.ChangeStatus).Methods("POST")
+}
+
+// OpenCase opens a disciplinary or grievance case.
+//
+// HTTP Method: POST
+// URL Path: /hr_cases
+//
+// Request Body:
+//   - JSON with type, summary and parties of user_id and role (subject, complainant,
+//     witness or representative). A disciplinary case needs a subject, a grievance a
+//     complainant.
+//
+// Response:
+//   - Status Code: 201 (Created) with the open case in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the case is incomplete or a party's user
+//     does not exist.
+//   - Status Code: 500 (Internal Server Error) if the case cannot be recorded.
+func (h *HRCaseHandler) OpenCase(w http.ResponseWriter, r *http.Request) {
+	var req CaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	c := models.HRCase{Type: req.Type, Summary: req.Summary, Parties: req.Parties}
+	if err := h.Service.Open(r.Context(), &c, actor); err != nil {
+		httperr.Write(w, err, "Failed to open HR case")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, c)
+}
+
+// ListCases lists cases with their parties, newest first. Cases the user is a party to are
+// left out.
+//
+// HTTP Method: GET
+// URL Path: /hr_cases?status=open&type=grievance (both are optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of cases in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the status or type is unknown.
+//   - Status Code: 500 (Internal Server Error) if the cases cannot be loaded.
+func (h *HRCaseHandler) ListCases(w http.ResponseWriter, r *http.Request) {
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	cases, err := h.Service.List(r.Context(), r.URL.Query().Get("status"), r.URL.Query().Get("type"), actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load HR cases")
+		return
+	}
+	respond.JSON(w, http.StatusOK, cases)
+}
+
+// GetCase returns a case with its parties, notes, documents and history.
+//
+// HTTP Method: GET
+// URL Path: /hr_cases/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the case in JSON.
+//   - Status Code: 403 (Forbidden) if the user is a party to the case.
+//   - Status Code: 404 (Not Found) if the case does not exist.
+//   - Status Code: 500 (Internal Server Error) if the case cannot be loaded.
+func (h *HRCaseHandler) GetCase(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	c, err := h.Service.Get(r.Context(), id, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load HR case")
+		return
+	}
+	respond.JSON(w, http.StatusOK, c)
+}
+
+// AddParty adds a party, such as a witness, to a case.
+//
+// HTTP Method: POST
+// URL Path: /hr_cases/{id}/parties
+//
+// Request Body:
+//   - JSON with user_id and role.
+//
+// Response:
+//   - Status Code: 200 (OK) with the case in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 403 (Forbidden) if the user is a party to the case.
+//   - Status Code: 404 (Not Found) if the case does not exist.
+//   - Status Code: 409 (Conflict) if the case is closed or the user is already a party.
+//   - Status Code: 422 (Unprocessable Entity) if the role is unknown or the user does not exist.
+//   - Status Code: 500 (Internal Server Error) if the party cannot be added.
+func (h *HRCaseHandler) AddParty(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var party models.HRCaseParty
+	if err := json.NewDecoder(r.Body).Decode(&party); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	c, err := h.Service.AddParty(r.Context(), id, &party, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to add party")
+		return
+	}
+	respond.JSON(w, http.StatusOK, c)
+}
+
+// AddNote adds a note to a case. Notes cannot be changed or deleted.
+//
+// HTTP Method: POST
+// URL Path: /hr_cases/{id}/notes
+//
+// Request Body:
+//   - JSON with the note's body.
+//
+// Response:
+//   - Status Code: 201 (Created) with the note in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 403 (Forbidden) if the user is a party to the case.
+//   - Status Code: 404 (Not Found) if the case does not exist.
+//   - Status Code: 409 (Conflict) if the case is closed.
+//   - Status Code: 422 (Unprocessable Entity) if the note is empty.
+//   - Status Code: 500 (Internal Server Error) if the note cannot be recorded.
+func (h *HRCaseHandler) AddNote(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req NoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	note, err := h.Service.AddNote(r.Context(), id, req.Body, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to add note")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, note)
+}
+
+// UploadAttachment files a document, such as a signed statement, with a case. It is kept in
+// private storage and can only be downloaded through the case.
+//
+// HTTP Method: POST
+// URL Path: /hr_cases/{id}/attachments
+//
+// Request Body:
+//   - multipart/form-data with the document in the "file" field.
+//
+// Response:
+//   - Status Code: 201 (Created) with the attachment in JSON.
+//   - Status Code: 400 (Bad Request) if the upload is missing or too large.
+//   - Status Code: 403 (Forbidden) if the user is a party to the case.
+//   - Status Code: 404 (Not Found) if the case does not exist.
+//   - Status Code: 409 (Conflict) if the case is closed.
+//   - Status Code: 422 (Unprocessable Entity) if the virus scanner flagged the document. It
+//     is quarantined and the response names the malware found.
+//   - Status Code: 500 (Internal Server Error) if the document cannot be stored.
+//   - Status Code: 503 (Service Unavailable) if the document cannot be scanned.
+func (h *HRCaseHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if _, err := h.Service.Get(r.Context(), id, actor); err != nil {
+		httperr.Write(w, err, "Failed to load HR case")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadSize+1<<20) // Allow for multipart overhead
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "A document is required in the \"file\" field")
+		return
+	}
+	defer file.Close()
+	data, err := storage.ReadAll(file, h.MaxUploadSize)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attachment := &models.HRCaseAttachment{
+		FileName:    path.Base(header.Filename),
+		ContentType: http.DetectContentType(data),
+		SizeBytes:   len(data),
+		UploadedBy:  actor,
+	}
+	if h.Antivirus != nil {
+		err := h.Antivirus.Check(r.Context(), data, models.QuarantinedFile{
+			Source:      models.UploadHRCaseAttachment,
+			EntityID:    id,
+			FileName:    attachment.FileName,
+			ContentType: attachment.ContentType,
+			UploadedBy:  actor,
+		})
+		if errors.Is(err, antivirus.ErrUnavailable) {
+			respond.Error(w, http.StatusServiceUnavailable, "The document could not be scanned for viruses, try again later")
+			return
+		}
+		if err != nil {
+			httperr.Write(w, err, "Failed to scan attachment")
+			return
+		}
+	}
+
+	attachment.StorageKey = fmt.Sprintf("hr_cases/%d/%d-%s", id, time.Now().UnixNano(), attachment.FileName)
+	if err := h.Files.Put(attachment.StorageKey, data, attachment.ContentType); err != nil {
+		httperr.Write(w, err, "Failed to store attachment")
+		return
+	}
+	if err := h.Service.Attach(r.Context(), id, attachment); err != nil {
+		h.Files.Delete(attachment.StorageKey)
+		httperr.Write(w, err, "Failed to attach document")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, attachment)
+}
+
+// DownloadAttachment returns a document of a case.
+//
+// HTTP Method: GET
+// URL Path: /hr_cases/{id}/attachments/{attachment}
+//
+// Response:
+//   - Status Code: 200 (OK) with the document as an attachment.
+//   - Status Code: 403 (Forbidden) if the user is a party to the case.
+//   - Status Code: 404 (Not Found) if the case or document does not exist.
+//   - Status Code: 500 (Internal Server Error) if the document cannot be read.
+func (h *HRCaseHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	attachmentID, _ := strconv.Atoi(mux.Vars(r)["attachment"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	attachment, err := h.Service.Attachment(r.Context(), id, attachmentID, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load attachment")
+		return
+	}
+	data, err := h.Files.Get(attachment.StorageKey)
+	if err != nil {
+		httperr.Write(w, err, "Failed to read attachment")
+		return
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.FileName))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// ChangeStatus moves a case to another status: open cases go to investigating or resolved,
+// investigations are resolved, resolved cases are closed or reopened for investigation.
+//
+// HTTP Method: POST
+// URL Path: /hr_cases/{id}/status
+//
+// Request Body:
+//   - JSON with the new status and, when resolving, the outcome.
+//
+// Response:
+//   - Status Code: 200 (OK) with the case in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 403 (Forbidden) if the user is a party to the case.
+//   - Status Code: 404 (Not Found) if the case does not exist.
+//   - Status Code: 409 (Conflict) if the case cannot move to the status.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown or the outcome missing.
+//   - Status Code: 500 (Internal Server Error) if the case cannot be changed.
+func (h *HRCaseHandler) ChangeStatus(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req StatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	c, err := h.Service.Move(r.Context(), id, req.Status, req.Outcome, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to change HR case status")
+		return
+	}
+	respond.JSON(w, http.StatusOK, c)
+}
+
+// Statistics counts the cases opened in a period by type, status and month, with the
+// average days to resolve them. It names no one and no case.
+//
+// HTTP Method: GET
+// URL Path: /hr_cases/statistics?from=2025-01-01&to=2026-01-01
+// (both are optional and default to the start of the year and now; to is exclusive)
+//
+// Response:
+//   - Status Code: 200 (OK) with the statistics in JSON.
+//   - Status Code: 400 (Bad Request) if a date is not formatted as YYYY-MM-DD.
+//   - Status Code: 422 (Unprocessable Entity) if from is not before to.
+//   - Status Code: 500 (Internal Server Error) if the cases cannot be loaded.
+func (h *HRCaseHandler) Statistics(w http.ResponseWriter, r *http.Request) {
+	var dates [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		if value := r.URL.Query().Get(name); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				respond.Error(w, http.StatusBadRequest, "Invalid "+name+" date, expected YYYY-MM-DD")
+				return
+			}
+			dates[i] = date
+		}
+	}
+	stats, err := h.Service.Statistics(r.Context(), dates[0], dates[1])
+	if err != nil {
+		httperr.Write(w, err, "Failed to compute HR case statistics")
+		return
+	}
+	respond.JSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,382 @@
+package hr_case_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp/models"
+)
+
+// DBHRCaseStore implements models.HRCaseStore using a SQL database. Each change locks the
+// case and appends to its history in the same transaction; the database refuses to update
+// or delete parties, notes, attachments and history entries.
+type DBHRCaseStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// caseColumns are the columns of hr_cases read by scanCase.
+const caseColumns = `id, type, summary, status, outcome, opened_by, opened_at, resolved_at, closed_at`
+
+// caseQueryer is satisfied by both *sql.DB and *sql.Tx.
+type caseQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanCase reads a row of caseColumns.
+func scanCase(row interface{ Scan(...interface{}) error }) (models.HRCase, error) {
+	var c models.HRCase
+	var resolvedAt, closedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.Type, &c.Summary, &c.Status, &c.Outcome, &c.OpenedBy, &c.OpenedAt, &resolvedAt, &closedAt)
+	if resolvedAt.Valid {
+		c.ResolvedAt = &resolvedAt.Time
+	}
+	if closedAt.Valid {
+		c.ClosedAt = &closedAt.Time
+	}
+	return c, err
+}
+
+// CreateHRCase records an open case with its parties and the first entry of its history.
+//
+// Parameters:
+//   - c: A validated case; its ID, its parties' names and emails and its opening time are set.
+//
+// Returns:
+//   - error: A validation error if a party's user does not exist, or the query error.
+func (s *DBHRCaseStore) CreateHRCase(ctx context.Context, c *models.HRCase) error {
+	c.OpenedAt = time.Now()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO hr_cases (type, summary, status, outcome, opened_by, opened_at)
+		 VALUES ($1, $2, $3, '', $4, $5) RETURNING id`,
+		c.Type, c.Summary, c.Status, c.OpenedBy, c.OpenedAt,
+	).Scan(&c.ID)
+	if err != nil {
+		return err
+	}
+	if err := addEvent(ctx, tx, c.ID, models.HRCaseOpened, c.Type, c.OpenedBy, c.OpenedAt); err != nil {
+		return err
+	}
+	for i := range c.Parties {
+		if err := addParty(ctx, tx, c.ID, &c.Parties[i], c.OpenedBy, c.OpenedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetHRCase returns a case with its parties, notes, attachments and history.
+func (s *DBHRCaseStore) GetHRCase(ctx context.Context, id int) (*models.HRCase, error) {
+	c, err := scanCase(s.DB.QueryRowContext(ctx, `SELECT `+caseColumns+` FROM hr_cases WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("HR case %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Parties, err = parties(ctx, s.DB, id); err != nil {
+		return nil, err
+	}
+	if c.Notes, err = s.notes(ctx, id); err != nil {
+		return nil, err
+	}
+	if c.Attachments, err = s.attachments(ctx, id); err != nil {
+		return nil, err
+	}
+	if c.History, err = s.history(ctx, id); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListHRCases returns cases in a status and of a type with their parties, newest first.
+// Empty filters match every case.
+func (s *DBHRCaseStore) ListHRCases(ctx context.Context, status, caseType string) ([]models.HRCase, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+caseColumns+` FROM hr_cases
+		 WHERE ($1 = '' OR status = $1) AND ($2 = '' OR type = $2)
+		 ORDER BY opened_at DESC, id DESC`, status, caseType)
+	if err != nil {
+		return nil, err
+	}
+	cases := []models.HRCase{}
+	for rows.Next() {
+		c, err := scanCase(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range cases {
+		if cases[i].Parties, err = parties(ctx, s.DB, cases[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return cases, nil
+}
+
+// ListHRCasesOpened returns the cases opened in [from, to), without parties.
+func (s *DBHRCaseStore) ListHRCasesOpened(ctx context.Context, from, to time.Time) ([]models.HRCase, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+caseColumns+` FROM hr_cases WHERE opened_at >= $1 AND opened_at < $2 ORDER BY opened_at`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cases := []models.HRCase{}
+	for rows.Next() {
+		c, err := scanCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, rows.Err()
+}
+
+// AddHRCaseParty adds a party to a case that is not closed; the party's name and email are
+// set.
+func (s *DBHRCaseStore) AddHRCaseParty(ctx context.Context, caseID int, party *models.HRCaseParty, actor string) error {
+	return s.change(ctx, caseID, func(tx *sql.Tx, at time.Time) error {
+		return addParty(ctx, tx, caseID, party, actor, at)
+	})
+}
+
+// AddHRCaseNote adds a note to a case that is not closed; the note's ID and time are set.
+func (s *DBHRCaseStore) AddHRCaseNote(ctx context.Context, caseID int, note *models.HRCaseNote) error {
+	return s.change(ctx, caseID, func(tx *sql.Tx, at time.Time) error {
+		note.CreatedAt = at
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO hr_case_notes (case_id, body, author, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+			caseID, note.Body, note.Author, note.CreatedAt,
+		).Scan(&note.ID)
+		if err != nil {
+			return err
+		}
+		return addEvent(ctx, tx, caseID, models.HRCaseNoteAdded, fmt.Sprintf("note %d", note.ID), note.Author, at)
+	})
+}
+
+// AddHRCaseAttachment records a stored document against a case that is not closed; the
+// attachment's ID and time are set.
+func (s *DBHRCaseStore) AddHRCaseAttachment(ctx context.Context, caseID int, a *models.HRCaseAttachment) error {
+	return s.change(ctx, caseID, func(tx *sql.Tx, at time.Time) error {
+		a.UploadedAt = at
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO hr_case_attachments (case_id, file_name, content_type, size_bytes, storage_key, uploaded_by, uploaded_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			caseID, a.FileName, a.ContentType, a.SizeBytes, a.StorageKey, a.UploadedBy, a.UploadedAt,
+		).Scan(&a.ID)
+		if err != nil {
+			return err
+		}
+		return addEvent(ctx, tx, caseID, models.HRCaseAttachmentAdded, a.FileName, a.UploadedBy, at)
+	})
+}
+
+// GetHRCaseAttachment returns a document of a case.
+func (s *DBHRCaseStore) GetHRCaseAttachment(ctx context.Context, caseID, attachmentID int) (*models.HRCaseAttachment, error) {
+	var a models.HRCaseAttachment
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT id, file_name, content_type, size_bytes, storage_key, uploaded_by, uploaded_at
+		 FROM hr_case_attachments WHERE case_id = $1 AND id = $2`, caseID, attachmentID,
+	).Scan(&a.ID, &a.FileName, &a.ContentType, &a.SizeBytes, &a.StorageKey, &a.UploadedBy, &a.UploadedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("attachment %d of HR case %d not found", attachmentID, caseID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ChangeHRCaseStatus moves a case from one status to another and records the change.
+// Resolving sets the outcome and the resolution time; reopening a resolved case clears them.
+//
+// Returns:
+//   - *models.HRCase: The case after the change.
+//   - error: A not found error, a conflict if the case is no longer in status from, or the
+//     query error.
+func (s *DBHRCaseStore) ChangeHRCaseStatus(ctx context.Context, id int, from, to, outcome, actor string) (*models.HRCase, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status, err := lockCase(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != from {
+		return nil, models.Conflict("HR case %d is %s, not %s", id, status, from)
+	}
+	at := time.Now()
+	switch to {
+	case models.HRCaseResolved:
+		_, err = tx.ExecContext(ctx, `UPDATE hr_cases SET status = $1, outcome = $2, resolved_at = $3 WHERE id = $4`, to, outcome, at, id)
+	case models.HRCaseClosed:
+		_, err = tx.ExecContext(ctx, `UPDATE hr_cases SET status = $1, closed_at = $2 WHERE id = $3`, to, at, id)
+	default:
+		_, err = tx.ExecContext(ctx, `UPDATE hr_cases SET status = $1, outcome = '', resolved_at = NULL WHERE id = $2`, to, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	detail := from + " to " + to
+	if outcome != "" {
+		detail += ": " + outcome
+	}
+	if err := addEvent(ctx, tx, id, models.HRCaseStatusChanged, detail, actor, at); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetHRCase(ctx, id)
+}
+
+// change locks a case that is not closed and applies a change to it in a transaction.
+func (s *DBHRCaseStore) change(ctx context.Context, id int, apply func(tx *sql.Tx, at time.Time) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockCase(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if status == models.HRCaseClosed {
+		return models.Conflict("HR case %d is closed", id)
+	}
+	if err := apply(tx, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockCase locks a case and returns its status.
+func lockCase(ctx context.Context, tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, `SELECT status FROM hr_cases WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", models.NotFound("HR case %d not found", id)
+	}
+	return status, err
+}
+
+// addParty looks up a party's user, adds the party to a case and records it in the history.
+func addParty(ctx context.Context, tx *sql.Tx, caseID int, party *models.HRCaseParty, actor string, at time.Time) error {
+	err := tx.QueryRowContext(ctx, `SELECT name, email FROM users WHERE id = $1`, party.UserID).Scan(&party.Name, &party.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Invalid("user %d does not exist", party.UserID)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO hr_case_parties (case_id, user_id, role, added_by, added_at) VALUES ($1, $2, $3, $4, $5)`,
+		caseID, party.UserID, party.Role, actor, at)
+	if err != nil {
+		return err
+	}
+	return addEvent(ctx, tx, caseID, models.HRCasePartyAdded, fmt.Sprintf("%s as %s", party.Name, party.Role), actor, at)
+}
+
+// addEvent appends an entry to a case's history.
+func addEvent(ctx context.Context, tx *sql.Tx, caseID int, action, detail, actor string, at time.Time) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO hr_case_events (case_id, action, detail, actor, at) VALUES ($1, $2, $3, $4, $5)`,
+		caseID, action, detail, actor, at)
+	return err
+}
+
+// parties returns the parties to a case in the order they were added.
+func parties(ctx context.Context, q caseQueryer, caseID int) ([]models.HRCaseParty, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT p.user_id, u.name, u.email, p.role FROM hr_case_parties p JOIN users u ON u.id = p.user_id
+		 WHERE p.case_id = $1 ORDER BY p.id`, caseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.HRCaseParty{}
+	for rows.Next() {
+		var p models.HRCaseParty
+		if err := rows.Scan(&p.UserID, &p.Name, &p.Email, &p.Role); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// notes returns the notes on a case, oldest first.
+func (s *DBHRCaseStore) notes(ctx context.Context, caseID int) ([]models.HRCaseNote, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, body, author, created_at FROM hr_case_notes WHERE case_id = $1 ORDER BY id`, caseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.HRCaseNote{}
+	for rows.Next() {
+		var n models.HRCaseNote
+		if err := rows.Scan(&n.ID, &n.Body, &n.Author, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+// attachments returns the documents of a case, oldest first.
+func (s *DBHRCaseStore) attachments(ctx context.Context, caseID int) ([]models.HRCaseAttachment, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, file_name, content_type, size_bytes, storage_key, uploaded_by, uploaded_at
+		 FROM hr_case_attachments WHERE case_id = $1 ORDER BY id`, caseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.HRCaseAttachment{}
+	for rows.Next() {
+		var a models.HRCaseAttachment
+		if err := rows.Scan(&a.ID, &a.FileName, &a.ContentType, &a.SizeBytes, &a.StorageKey, &a.UploadedBy, &a.UploadedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// history returns the history of a case, oldest first.
+func (s *DBHRCaseStore) history(ctx context.Context, caseID int) ([]models.HRCaseEvent, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, action, detail, actor, at FROM hr_case_events WHERE case_id = $1 ORDER BY id`, caseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.HRCaseEvent{}
+	for rows.Next() {
+		var e models.HRCaseEvent
+		if err := rows.Scan(&e.ID, &e.Action, &e.Detail, &e.Actor, &e.At); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
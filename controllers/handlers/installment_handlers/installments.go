@@ -0,0 +1,112 @@
+package installment_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/installments"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+
+	"github.com/gorilla/mux"
+)
+
+// InstallmentHandlers provides HTTP handlers for invoice installment plans.
+type InstallmentHandlers struct {
+	Service *installments.Service
+}
+
+// CreatePlan splits what is owed on a posted invoice into installments.
+//
+// HTTP Method: POST
+// URL Path: /invoices/{id}/installments
+//
+// Request Body:
+//   - JSON object with "installments", a list of objects with "due_date" and "amount"
+//     adding up to the amount owed, or with "count", "first_due_date" and optionally
+//     "interval_months" (1 by default) to split it into equal installments.
+//
+// Response:
+//   - Status Code: 201 (Created) with the InstallmentPlan in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the invoice does not exist.
+//   - Status Code: 409 (Conflict) if the invoice already has a plan.
+//   - Status Code: 422 (Unprocessable Entity) if the invoice is not posted, nothing is owed
+//     or the schedule is invalid.
+//   - Status Code: 500 (Internal Server Error) if the plan cannot be saved.
+func (h *InstallmentHandlers) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var schedule installments.Schedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	plan, err := h.Service.CreatePlan(r.Context(), invoiceID(r), schedule, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to create installment plan")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, plan)
+}
+
+// GetPlan returns the installment plan of an invoice with what has been paid of each
+// installment and its status.
+//
+// HTTP Method: GET
+// URL Path: /invoices/{id}/installments
+//
+// Response:
+//   - Status Code: 200 (OK) with the InstallmentPlan in JSON.
+//   - Status Code: 404 (Not Found) if the invoice has no plan.
+//   - Status Code: 500 (Internal Server Error) if the plan cannot be read.
+func (h *InstallmentHandlers) GetPlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := h.Service.Plan(r.Context(), invoiceID(r))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load installment plan")
+		return
+	}
+	respond.JSON(w, http.StatusOK, plan)
+}
+
+// DeletePlan removes the installment plan of an invoice, e.g. to agree a new one.
+//
+// HTTP Method: DELETE
+// URL Path: /invoices/{id}/installments
+//
+// Response:
+//   - Status Code: 204 (No Content) if the plan was removed.
+//   - Status Code: 404 (Not Found) if the invoice has no plan.
+//   - Status Code: 500 (Internal Server Error) if the plan cannot be removed.
+func (h *InstallmentHandlers) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.DeletePlan(r.Context(), invoiceID(r)); err != nil {
+		httperr.Write(w, err, "Failed to delete installment plan")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Forecast reports the cash expected from open installment plans by month.
+//
+// HTTP Method: GET
+// URL Path: /installments/forecast
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of CashInflows in JSON; installments already past
+//     due are on the first line, with month "overdue".
+//   - Status Code: 500 (Internal Server Error) if the plans cannot be read.
+func (h *InstallmentHandlers) Forecast(w http.ResponseWriter, r *http.Request) {
+	forecast, err := h.Service.Forecast(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to forecast installments")
+		return
+	}
+	respond.JSON(w, http.StatusOK, forecast)
+}
+
+// invoiceID returns the invoice ID in the URL.
+func invoiceID(r *http.Request) int {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	return id
+}
@@ -0,0 +1,217 @@
+package installment_handlers
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBInstallmentStore provides SQL-backed methods for invoice installment plans.
+type DBInstallmentStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// settledSQL is what has been settled on invoice i: its payments, the deposits applied to
+// it and the amounts allowed on its disputes.
+const settledSQL = `(SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = i.id AND deleted_at IS NULL) +
+	(SELECT COALESCE(SUM(amount), 0) FROM customer_deposit_applications WHERE invoice_id = i.id) +
+	(SELECT COALESCE(SUM(resolved_amount), 0) FROM invoice_disputes WHERE invoice_id = i.id)`
+
+// GetInvoiceBalance reads what has been invoiced and settled on an invoice.
+//
+// Returns:
+//   - *models.InvoiceBalance: The invoice's amount, status and settled amount.
+//   - error: models.ErrNotFound if the invoice does not exist, or an error if the query fails.
+func (store *DBInstallmentStore) GetInvoiceBalance(ctx context.Context, invoiceID int) (*models.InvoiceBalance, error) {
+	balance := models.InvoiceBalance{InvoiceID: invoiceID}
+	var customerID sql.NullInt64
+	var status sql.NullString
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT i.customer_id, i.status, i.amount, `+settledSQL+` FROM invoices i WHERE i.id = $1`, invoiceID,
+	).Scan(&customerID, &status, &balance.Amount, &balance.Settled)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("invoice %d not found", invoiceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	balance.CustomerID, balance.Status = int(customerID.Int64), status.String
+	return &balance, nil
+}
+
+// CreateInstallmentPlan inserts a plan and its installments in one transaction.
+//
+// Parameters:
+//   - plan: The validated plan; its ID and the IDs of its installments are set.
+//
+// Returns:
+//   - error: A models.Conflict error if the invoice already has a plan, or an error if the
+//     plan cannot be saved.
+func (store *DBInstallmentStore) CreateInstallmentPlan(ctx context.Context, plan *models.InstallmentPlan) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO installment_plans (invoice_id, customer_id, total, settled_before, created_by, created_at)
+		 VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6) RETURNING id`,
+		plan.InvoiceID, plan.CustomerID, plan.Total, plan.SettledBefore, plan.CreatedBy, plan.CreatedAt,
+	).Scan(&plan.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("invoice %d already has an installment plan", plan.InvoiceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range plan.Installments {
+		installment := &plan.Installments[i]
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO installments (plan_id, number, due_date, amount) VALUES ($1, $2, $3, $4) RETURNING id`,
+			plan.ID, installment.Number, installment.DueDate, installment.Amount,
+		).Scan(&installment.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetInstallmentPlan retrieves the plan of an invoice with its installments and the amount
+// settled on the invoice now.
+//
+// Returns:
+//   - *models.InstallmentPlan: The plan.
+//   - error: models.ErrNotFound if the invoice has no plan, or an error if the query fails.
+func (store *DBInstallmentStore) GetInstallmentPlan(ctx context.Context, invoiceID int) (*models.InstallmentPlan, error) {
+	plans, err := store.queryPlans(ctx, `WHERE p.invoice_id = $1`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return nil, models.NotFound("invoice %d has no installment plan", invoiceID)
+	}
+	return &plans[0], nil
+}
+
+// DeleteInstallmentPlan removes the plan of an invoice; its installments are removed with it.
+//
+// Returns:
+//   - error: models.ErrNotFound if the invoice has no plan, or an error if the delete fails.
+func (store *DBInstallmentStore) DeleteInstallmentPlan(ctx context.Context, invoiceID int) error {
+	result, err := store.DB.ExecContext(ctx, `DELETE FROM installment_plans WHERE invoice_id = $1`, invoiceID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return models.NotFound("invoice %d has no installment plan", invoiceID)
+	}
+	return nil
+}
+
+// GetOpenInstallmentPlans retrieves the plans whose invoices have not been settled in full
+// since the plan was made, with their customers and installments.
+//
+// Returns:
+//   - []models.InstallmentPlan: The plans, oldest first.
+//   - error: An error if the query fails.
+func (store *DBInstallmentStore) GetOpenInstallmentPlans(ctx context.Context) ([]models.InstallmentPlan, error) {
+	return store.queryPlans(ctx, `WHERE `+settledSQL+` - p.settled_before < p.total`)
+}
+
+// queryPlans reads the plans matching a WHERE clause and its arguments, then their
+// installments.
+func (store *DBInstallmentStore) queryPlans(ctx context.Context, where string, args ...interface{}) ([]models.InstallmentPlan, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT p.id, p.invoice_id, COALESCE(p.customer_id, 0), COALESCE(c.name, ''), COALESCE(c.contact, ''),
+		     p.total, p.settled_before, `+settledSQL+`, p.created_by, p.created_at,
+		     EXISTS (SELECT 1 FROM invoice_disputes WHERE invoice_id = i.id AND status = '`+models.DisputeOpen+`')
+		 FROM installment_plans p
+		 JOIN invoices i ON i.id = p.invoice_id
+		 LEFT JOIN customers c ON c.id = p.customer_id `+where+` ORDER BY p.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []models.InstallmentPlan{}
+	index := map[int]int{}
+	var ids pq.Int64Array
+	for rows.Next() {
+		var plan models.InstallmentPlan
+		if err := rows.Scan(&plan.ID, &plan.InvoiceID, &plan.CustomerID, &plan.CustomerName, &plan.Contact,
+			&plan.Total, &plan.SettledBefore, &plan.Settled, &plan.CreatedBy, &plan.CreatedAt, &plan.Disputed); err != nil {
+			return nil, err
+		}
+		plan.Installments = []models.Installment{}
+		index[plan.ID] = len(plans)
+		ids = append(ids, int64(plan.ID))
+		plans = append(plans, plan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return plans, nil
+	}
+
+	installments, err := store.DB.QueryContext(ctx,
+		`SELECT id, plan_id, number, due_date, amount, reminder_stage, reminded_at
+		 FROM installments WHERE plan_id = ANY($1) ORDER BY plan_id, number`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer installments.Close()
+	for installments.Next() {
+		var installment models.Installment
+		var planID int
+		var stage sql.NullInt64
+		var remindedAt sql.NullTime
+		if err := installments.Scan(&installment.ID, &planID, &installment.Number, &installment.DueDate,
+			&installment.Amount, &stage, &remindedAt); err != nil {
+			return nil, err
+		}
+		if stage.Valid {
+			value := int(stage.Int64)
+			installment.ReminderStage = &value
+		}
+		if remindedAt.Valid {
+			installment.RemindedAt = &remindedAt.Time
+		}
+		i := index[planID]
+		plans[i].Installments = append(plans[i].Installments, installment)
+	}
+	return plans, installments.Err()
+}
+
+// MarkInstallmentReminded records the reminder stage sent for an installment, unless the
+// same or a later stage was recorded already, e.g. by another instance of the application.
+//
+// Parameters:
+//   - installmentID: The installment.
+//   - stage: Days from the due date of the reminder.
+//   - at: When the reminder is sent.
+//
+// Returns:
+//   - bool: Whether the stage was recorded, so the reminder should be sent.
+//   - error: An error if the update fails.
+func (store *DBInstallmentStore) MarkInstallmentReminded(ctx context.Context, installmentID, stage int, at time.Time) (bool, error) {
+	result, err := store.DB.ExecContext(ctx,
+		`UPDATE installments SET reminder_stage = $1, reminded_at = $2
+		 WHERE id = $3 AND (reminder_stage IS NULL OR reminder_stage < $1)`,
+		stage, at, installmentID,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
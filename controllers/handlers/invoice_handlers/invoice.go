@@ -0,0 +1,361 @@
+// Package invoice_handlers provides HTTP handlers for managing invoice data.
+// It includes functionality for creating, retrieving, updating, and deleting invoices.
+package invoice_handlers
+
+import (
+	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/invoicing"
+	"erp/controllers/middleware"
+	"erp/controllers/pagination"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
+	"erp/models"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// InvoiceHandlers is a struct that provides methods to handle invoice-related HTTP requests.
+// The invoice rules live in the invoicing service; the handlers only translate HTTP.
+type InvoiceHandlers struct {
+	Service *invoicing.Service // Applies the invoice rules on top of the invoice store
+}
+
+// NewInvoiceHandlers creates invoice handlers backed by store.
+func NewInvoiceHandlers(store models.InvoiceStore) *InvoiceHandlers {
+	return &InvoiceHandlers{Service: invoicing.NewService(store)}
+}
+
+// InvoiceRequest is the request body for creating or updating an invoice. The ID and status
+// are managed by the server; invoices change status only through posting and voiding.
+type InvoiceRequest struct {
+	SalesOrderID int     `json:"sales_order_id"`
+	CustomerID   int     `json:"customer_id"`
+	Amount       float64 `json:"amount"`
+	Version      int     `json:"version"` // The version updated, unless sent in If-Match; ignored on create
+}
+
+// Invoice returns the invoice described by the request.
+func (req InvoiceRequest) Invoice() models.Invoice {
+	return models.Invoice{
+		SalesOrderID: req.SalesOrderID,
+		CustomerID:   req.CustomerID,
+		Amount:       req.Amount,
+	}
+}
+
+// Validate checks the fields of a draft. Drafts may still lack a customer or an amount;
+// posting requires them (see models.Invoice.ValidateForPosting).
+func (req InvoiceRequest) Validate() error {
+	var c validation.Checker
+	c.Check(req.SalesOrderID >= 0, "sales_order_id", "must be the ID of an existing record or 0")
+	c.Check(req.CustomerID >= 0, "customer_id", "must be the ID of an existing record or 0")
+	c.NotNegative("amount", req.Amount)
+	return c.Err()
+}
+
+// CreateInvoiceHandler handles HTTP POST requests for creating a new invoice.
+// Invoices are always created as drafts and have no ledger effect until posted.
+//
+// Request Body:
+//   - JSON object with sales_order_id, customer_id and amount (see InvoiceRequest).
+//
+// Response:
+//   - 201 Created: If the invoice is successfully created, returns the invoice object as JSON.
+//   - 400 Bad Request: If the request payload is not JSON.
+//   - 422 Unprocessable Entity: If a field is invalid; the error details list them.
+//   - 500 Internal Server Error: If an error occurs while creating the invoice.
+func (h *InvoiceHandlers) CreateInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	var req InvoiceRequest
+
+	// Decode JSON body into the request struct
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	// Create the invoice in the database
+	invoice := req.Invoice()
+	err := h.Service.Create(r.Context(), &invoice)
+	if err != nil {
+		httperr.Write(w, err, "Failed to create invoice")
+		return
+	}
+
+	// Respond with the created invoice object
+	respond.JSON(w, http.StatusCreated, invoice)
+}
+
+// ListInvoicesHandler handles HTTP GET requests to list invoices a page at a time.
+//
+// Query Parameters:
+//   - page, limit: The page number (from 1) and its size (default 50, at most 200).
+//   - sort: id, sales_order_id, customer_id, amount or status, prefixed with "-" for
+//     descending order.
+//   - id, sales_order_id, customer_id, amount, status: Filters, e.g. ?status=posted&customer_id=5.
+//
+// Response:
+//   - 200 OK: Returns {"items": [...], "total": n, "page": p, "limit": l} as JSON.
+//   - 422 Unprocessable Entity: If a parameter is invalid.
+//   - 500 Internal Server Error: If an error occurs while listing the invoices.
+func (h *InvoiceHandlers) ListInvoicesHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := pagination.Parse(r, InvoiceColumns)
+	if err != nil {
+		httperr.Write(w, err, "Invalid list query")
+		return
+	}
+	invoices, total, err := h.Service.List(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Failed to list invoices")
+		return
+	}
+	pagination.Write(w, invoices, total, query)
+}
+
+// GetInvoiceByIDHandler handles HTTP GET requests to fetch an invoice by its ID.
+//
+// URL Parameters:
+//   - id: Invoice ID (integer).
+//
+// Response:
+//   - 200 OK: Returns the invoice object as JSON, with its version as the ETag.
+//   - 400 Bad Request: If the provided ID is invalid.
+//   - 404 Not Found: If no invoice with the given ID exists.
+func (h *InvoiceHandlers) GetInvoiceByIDHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract the "id" variable from the URL
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	// Fetch the invoice by ID
+	invoice, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load invoice")
+		return
+	}
+
+	// Respond with the invoice object
+	versioning.SetETag(w, invoice.Version)
+	respond.JSON(w, http.StatusOK, invoice)
+}
+
+// UpdateInvoiceHandler handles HTTP PUT requests to update an existing draft invoice.
+// The status cannot be changed here; use PostInvoiceHandler to post a draft.
+//
+// URL Parameters:
+//   - id: Invoice ID (integer).
+//
+// Request Body:
+//   - JSON object with sales_order_id, customer_id, amount and the version read (see
+//     InvoiceRequest); the version may be sent as an If-Match header instead.
+//
+// Response:
+//   - 200 OK: If the update is successful, returns the updated invoice object as JSON.
+//   - 400 Bad Request: If the ID is invalid or the request payload is malformed.
+//   - 404 Not Found: If no invoice with the given ID exists.
+//   - 409 Conflict: If the invoice is no longer a draft, or was changed since the version read.
+//   - 422 Unprocessable Entity: If a field is invalid; the error details list them.
+//   - 428 Precondition Required: If no version is given.
+//   - 500 Internal Server Error: If an error occurs while updating the invoice.
+func (h *InvoiceHandlers) UpdateInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract the "id" variable from the URL
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	var req InvoiceRequest
+	// Decode JSON body into the request struct
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
+		return
+	}
+
+	// Ensure the invoice ID matches the URL parameter
+	invoice := req.Invoice()
+	invoice.ID, invoice.Version = id, version
+
+	// Update the invoice data in the store
+	err = h.Service.Update(r.Context(), &invoice)
+	if err != nil {
+		httperr.Write(w, err, "Failed to update invoice")
+		return
+	}
+
+	// Respond with the updated invoice object
+	versioning.SetETag(w, invoice.Version)
+	respond.JSON(w, http.StatusOK, invoice)
+}
+
+// DeleteInvoiceHandler handles HTTP DELETE requests to remove an invoice by its ID.
+//
+// URL Parameters:
+//   - id: Invoice ID (integer).
+//
+// Response:
+//   - 204 No Content: If the deletion is successful.
+//   - 400 Bad Request: If the provided ID is invalid.
+//   - 404 Not Found: If no invoice with the given ID exists.
+//   - 409 Conflict: If the invoice is no longer a draft.
+//   - 500 Internal Server Error: If an error occurs while deleting the invoice.
+func (h *InvoiceHandlers) DeleteInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	// Extract the "id" variable from the URL
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	// Delete the invoice by ID
+	err = h.Service.Delete(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to delete invoice")
+		return
+	}
+
+	// Respond with no content
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostInvoiceHandler handles HTTP POST requests to post a draft invoice. Posting validates
+// the invoice, records its ledger transactions and locks it against further changes.
+//
+// URL Parameters:
+//   - id: Invoice ID (integer).
+//
+// Response:
+//   - 200 OK: Returns the posted invoice as JSON.
+//   - 400 Bad Request: If the provided ID is invalid.
+//   - 404 Not Found: If no invoice with the given ID exists.
+//   - 409 Conflict: If the invoice is not a draft.
+//   - 422 Unprocessable Entity: If the invoice fails validation.
+//   - 500 Internal Server Error: If an error occurs while posting the invoice.
+func (h *InvoiceHandlers) PostInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	invoice, err := h.Service.Post(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to post invoice")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, invoice)
+}
+
+// CloneInvoiceRequest holds optional overrides applied to a cloned invoice.
+// Fields left out of the request body are copied from the source invoice.
+type CloneInvoiceRequest struct {
+	SalesOrderID *int     `json:"sales_order_id"`
+	CustomerID   *int     `json:"customer_id"`
+	Amount       *float64 `json:"amount"`
+}
+
+// Overrides returns the clone overrides described by the request.
+func (req CloneInvoiceRequest) Overrides() invoicing.Overrides {
+	return invoicing.Overrides{SalesOrderID: req.SalesOrderID, CustomerID: req.CustomerID, Amount: req.Amount}
+}
+
+// CloneInvoiceHandler handles HTTP POST requests to duplicate an invoice. The copy gets a new
+// ID and starts as a draft regardless of the source invoice's status.
+//
+// URL Parameters:
+//   - id: ID of the invoice to copy (integer).
+//
+// Request Body (optional):
+//   - JSON object with any of "sales_order_id", "customer_id" and "amount" to override.
+//
+// Response:
+//   - 201 Created: Returns the new draft invoice as JSON.
+//   - 400 Bad Request: If the ID or request payload is invalid.
+//   - 404 Not Found: If the source invoice does not exist.
+//   - 500 Internal Server Error: If an error occurs while creating the copy.
+func (h *InvoiceHandlers) CloneInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	var req CloneInvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	clone, err := h.Service.Clone(r.Context(), id, req.Overrides())
+	if err != nil {
+		httperr.Write(w, err, "Failed to clone invoice")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, clone)
+}
+
+// VoidInvoicesRequest is the request body for voiding a batch of invoices.
+type VoidInvoicesRequest struct {
+	IDs    []int  `json:"ids"`
+	Reason string `json:"reason"`
+}
+
+// VoidInvoicesHandler handles HTTP POST requests to void a batch of draft invoices.
+// The route must be protected with JWTAuth and RequireRole; the user's email is recorded
+// in the audit log together with the reason.
+//
+// Request Body:
+//   - JSON object with the invoice "ids" and a mandatory "reason".
+//
+// Response:
+//   - 200 OK: All invoices were voided; returns the voided IDs.
+//   - 400 Bad Request: If the payload is invalid, the reason is empty or the batch is empty or too large.
+//   - 401 Unauthorized: If the user cannot be identified.
+//   - 409 Conflict: If any invoice is missing or not voidable; the error details list the rejected IDs and nothing is voided.
+//   - 500 Internal Server Error: If an error occurs while voiding the invoices.
+func (h *InvoiceHandlers) VoidInvoicesHandler(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUserEmailFromContext(r.Context())
+	if err != nil {
+		respond.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req VoidInvoicesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	result, err := h.Service.Void(r.Context(), req.IDs, req.Reason, actor)
+	var rejected *models.BatchRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		respond.ErrorDetails(w, http.StatusConflict, "The batch was rejected", rejected.Items)
+		return
+	case errors.Is(err, models.ErrValidation):
+		// A missing reason or a bad batch size is a malformed request, not a rule violation
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		httperr.Write(w, err, "Failed to void invoices")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, result)
+}
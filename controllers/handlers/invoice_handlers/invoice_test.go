@@ -0,0 +1,539 @@
+// Package invoice_handlers_test provides comprehensive tests for the invoice data management HTTP handlers.
+// These tests ensure correctness by validating invoice creation, retrieval, updating, and deletion.
+// A mock data store is used to simulate a database in an isolated environment.
+
+package invoice_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"erp/controllers/middleware"
+	"erp/controllers/pagination"
+	"erp/controllers/utils"
+	"erp/models"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockInvoiceStore provides a mock implementation of the InvoiceStore interface.
+//
+// This mock simulates database operations, allowing unit tests to focus
+// on the handler logic without depending on a real database connection.
+type MockInvoiceStore struct {
+	invoices map[int]*models.Invoice // Stores mock invoice data
+	nextID   int                     // Tracks the next available invoice ID
+}
+
+// NewMockInvoiceStore initializes a new instance of the MockInvoiceStore.
+//
+// Returns:
+//   - A pointer to a MockInvoiceStore with an empty invoice map and ID counter set to 1.
+func NewMockInvoiceStore() *MockInvoiceStore {
+	return &MockInvoiceStore{
+		invoices: make(map[int]*models.Invoice),
+		nextID:   1,
+	}
+}
+
+// CreateInvoice simulates adding a new draft invoice to the mock store.
+//
+// Parameters:
+//   - invoice: Pointer to the Invoice object to be added.
+//
+// Returns:
+//   - Always returns nil as it assumes no errors in a mock setup.
+func (m *MockInvoiceStore) CreateInvoice(ctx context.Context, invoice *models.Invoice) error {
+	invoice.Status = models.InvoiceStatusDraft
+	invoice.Version = 1
+	m.seed(*invoice)
+	invoice.ID = m.nextID - 1
+	return nil
+}
+
+// seed adds an invoice in any status, for tests that need posted or paid invoices.
+func (m *MockInvoiceStore) seed(invoice models.Invoice) {
+	invoice.ID = m.nextID
+	if invoice.Version == 0 {
+		invoice.Version = 1
+	}
+	m.invoices[m.nextID] = &invoice
+	m.nextID++
+}
+
+// GetInvoiceByID simulates fetching an invoice by its ID.
+//
+// Parameters:
+//   - id: The unique identifier of the invoice.
+//
+// Returns:
+//   - The invoice object if found.
+//   - models.ErrNotFound if no invoice exists with the given ID.
+func (m *MockInvoiceStore) GetInvoiceByID(ctx context.Context, id int) (*models.Invoice, error) {
+	invoice, exists := m.invoices[id]
+	if !exists {
+		return nil, models.ErrNotFound
+	}
+	found := *invoice
+	return &found, nil
+}
+
+// UpdateInvoice simulates updating an existing invoice's data.
+//
+// Parameters:
+//   - invoice: Pointer to the updated Invoice object.
+//
+// Returns:
+//   - nil if the update is successful.
+//   - models.ErrNotFound if no invoice exists with the given ID.
+//   - models.ErrDocumentLocked if the invoice is not a draft.
+//   - A conflict if the invoice is no longer at invoice.Version.
+func (m *MockInvoiceStore) UpdateInvoice(ctx context.Context, invoice *models.Invoice) error {
+	existing, exists := m.invoices[invoice.ID]
+	if !exists {
+		return models.ErrNotFound
+	}
+	if existing.Status != models.InvoiceStatusDraft {
+		return models.ErrDocumentLocked
+	}
+	if existing.Version != invoice.Version {
+		return models.Conflict("invoice %d was changed by someone else", invoice.ID)
+	}
+	invoice.Version++
+	updated := *invoice
+	updated.Status = existing.Status
+	m.invoices[invoice.ID] = &updated
+	return nil
+}
+
+// PostInvoice simulates posting a draft invoice.
+//
+// Returns:
+//   - The posted invoice on success.
+//   - models.ErrNotFound, models.ErrDocumentLocked or a *models.PostingError otherwise.
+func (m *MockInvoiceStore) PostInvoice(ctx context.Context, id int) (*models.Invoice, error) {
+	invoice, exists := m.invoices[id]
+	if !exists {
+		return nil, models.ErrNotFound
+	}
+	if err := invoice.ValidateForPosting(); err != nil {
+		return nil, err
+	}
+	invoice.Status = models.InvoiceStatusPosted
+	invoice.Version++
+	posted := *invoice
+	return &posted, nil
+}
+
+// DeleteInvoice simulates deleting an invoice by its ID.
+//
+// Parameters:
+//   - id: The unique identifier of the invoice to be deleted.
+//
+// Returns:
+//   - nil if the deletion is successful.
+//   - models.ErrNotFound if no invoice exists with the given ID.
+//   - models.ErrDocumentLocked if the invoice is not a draft.
+func (m *MockInvoiceStore) DeleteInvoice(ctx context.Context, id int) error {
+	invoice, exists := m.invoices[id]
+	if !exists {
+		return models.ErrNotFound
+	}
+	if invoice.Status != models.InvoiceStatusDraft {
+		return models.ErrDocumentLocked
+	}
+	delete(m.invoices, id)
+	return nil
+}
+
+// VoidInvoices simulates voiding a batch of invoices. Like the database store it changes
+// nothing when any invoice is missing or not voidable.
+//
+// Returns:
+//   - *models.BatchRejectedError if any invoice cannot be voided.
+func (m *MockInvoiceStore) VoidInvoices(ctx context.Context, ids []int, reason, actor string) error {
+	rejected := &models.BatchRejectedError{}
+	for _, id := range ids {
+		invoice, exists := m.invoices[id]
+		if !exists {
+			rejected.Items = append(rejected.Items, models.BatchItemError{ID: id, Error: "invoice not found"})
+		} else if !models.IsInvoiceVoidable(invoice.Status) {
+			rejected.Items = append(rejected.Items, models.BatchItemError{ID: id, Error: "not voidable"})
+		}
+	}
+	if len(rejected.Items) > 0 {
+		return rejected
+	}
+	for _, id := range ids {
+		m.invoices[id].Status = models.InvoiceStatusVoid
+		m.invoices[id].Version++
+	}
+	return nil
+}
+
+// ListInvoices simulates listing invoices filtered by customer and status, sorted by ID or
+// amount.
+//
+// Returns:
+//   - The page of invoices and the number matching the filters.
+func (m *MockInvoiceStore) ListInvoices(ctx context.Context, query models.ListQuery) ([]models.Invoice, int, error) {
+	matches := []models.Invoice{}
+	for _, invoice := range m.invoices {
+		if customerID, ok := query.Filters["customer_id"]; ok && strconv.Itoa(invoice.CustomerID) != customerID {
+			continue
+		}
+		if status, ok := query.Filters["status"]; ok && !strings.EqualFold(invoice.Status, status) {
+			continue
+		}
+		matches = append(matches, *invoice)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if query.Sort == "amount" && a.Amount != b.Amount {
+			return (a.Amount < b.Amount) != query.Desc
+		}
+		return a.ID < b.ID
+	})
+	start, end := pagination.Bounds(len(matches), query)
+	return matches[start:end], len(matches), nil
+}
+
+// TestCreateInvoiceHandler validates the CreateInvoiceHandler functionality.
+//
+// Steps:
+//   - Simulate an HTTP POST request to create a new invoice.
+//   - Verify the response status and ensure the invoice data is correctly returned.
+func TestCreateInvoiceHandler(t *testing.T) {
+	store := NewMockInvoiceStore()
+	handler := NewInvoiceHandlers(store)
+
+	// Input data for a new invoice
+	newInvoice := &models.Invoice{SalesOrderID: 1, CustomerID: 123, Amount: 250.75, Status: "Pending"}
+	payload, _ := json.Marshal(newInvoice)
+
+	// Simulate the HTTP POST request
+	req, _ := http.NewRequest(http.MethodPost, "/invoices", bytes.NewBuffer(payload))
+	rec := httptest.NewRecorder()
+
+	// Invoke the handler
+	handler.CreateInvoiceHandler(rec, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusCreated, rec.Code, "Expected status code 201 Created")
+	var createdInvoice models.Invoice
+	json.NewDecoder(rec.Body).Decode(&createdInvoice)
+	assert.Equal(t, newInvoice.SalesOrderID, createdInvoice.SalesOrderID, "SalesOrderID mismatch")
+	assert.Equal(t, newInvoice.CustomerID, createdInvoice.CustomerID, "CustomerID mismatch")
+	assert.Equal(t, newInvoice.Amount, createdInvoice.Amount, "Amount mismatch")
+	assert.Equal(t, models.InvoiceStatusDraft, createdInvoice.Status, "New invoices must be drafts")
+}
+
+// TestGetInvoiceByIDHandler validates the GetInvoiceByIDHandler functionality.
+//
+// Steps:
+//   - Add an invoice to the mock store.
+//   - Simulate an HTTP GET request to fetch the invoice by ID.
+//   - Verify the response status and invoice data.
+func TestGetInvoiceByIDHandler(t *testing.T) {
+	store := NewMockInvoiceStore()
+	handler := NewInvoiceHandlers(store)
+
+	// Add an invoice to the mock store
+	store.seed(models.Invoice{SalesOrderID: 2, CustomerID: 456, Amount: 500.00, Status: "Paid"})
+
+	// Simulate the HTTP GET request
+	req, _ := http.NewRequest(http.MethodGet, "/invoices/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rec := httptest.NewRecorder()
+
+	// Invoke the handler
+	handler.GetInvoiceByIDHandler(rec, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, rec.Code, "Expected status code 200 OK")
+	var retrievedInvoice models.Invoice
+	json.NewDecoder(rec.Body).Decode(&retrievedInvoice)
+	assert.Equal(t, 2, retrievedInvoice.SalesOrderID, "SalesOrderID mismatch")
+	assert.Equal(t, 456, retrievedInvoice.CustomerID, "CustomerID mismatch")
+	assert.Equal(t, 500.00, retrievedInvoice.Amount, "Amount mismatch")
+	assert.Equal(t, "Paid", retrievedInvoice.Status, "Status mismatch")
+}
+
+// TestUpdateInvoiceHandler validates the UpdateInvoiceHandler functionality.
+//
+// Steps:
+//   - Add an invoice to the mock store.
+//   - Simulate an HTTP PUT request to update the invoice data.
+//   - Verify the response status and updated data.
+func TestUpdateInvoiceHandler(t *testing.T) {
+	store := NewMockInvoiceStore()
+	handler := NewInvoiceHandlers(store)
+
+	// Add an invoice to the mock store
+	store.CreateInvoice(context.Background(), &models.Invoice{SalesOrderID: 3, CustomerID: 789, Amount: 150.00, Status: "Pending"})
+
+	// Updated invoice data
+	updatedInvoice := &models.Invoice{ID: 1, SalesOrderID: 4, CustomerID: 890, Amount: 300.00, Status: "Paid", Version: 1}
+	payload, _ := json.Marshal(updatedInvoice)
+
+	// Simulate the HTTP PUT request
+	req, _ := http.NewRequest(http.MethodPut, "/invoices/1", bytes.NewBuffer(payload))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rec := httptest.NewRecorder()
+
+	// Invoke the handler
+	handler.UpdateInvoiceHandler(rec, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, rec.Code, "Expected status code 200 OK")
+	var updatedResult models.Invoice
+	json.NewDecoder(rec.Body).Decode(&updatedResult)
+	assert.Equal(t, updatedInvoice.SalesOrderID, updatedResult.SalesOrderID, "SalesOrderID mismatch")
+	assert.Equal(t, updatedInvoice.CustomerID, updatedResult.CustomerID, "CustomerID mismatch")
+	assert.Equal(t, updatedInvoice.Amount, updatedResult.Amount, "Amount mismatch")
+	assert.Equal(t, models.InvoiceStatusDraft, updatedResult.Status, "Status cannot be changed by an update")
+}
+
+// TestDeleteInvoiceHandler validates the DeleteInvoiceHandler functionality.
+//
+// Steps:
+//   - Add an invoice to the mock store.
+//   - Simulate an HTTP DELETE request to remove the invoice by ID.
+//   - Verify the response status and ensure the invoice is deleted.
+func TestDeleteInvoiceHandler(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockInvoiceStore()
+	handler := NewInvoiceHandlers(store)
+
+	// Add an invoice to the mock store
+	store.CreateInvoice(ctx, &models.Invoice{SalesOrderID: 5, CustomerID: 123, Amount: 700.00, Status: "Unpaid"})
+
+	// Simulate the HTTP DELETE request
+	req, _ := http.NewRequest(http.MethodDelete, "/invoices/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rec := httptest.NewRecorder()
+
+	// Invoke the handler
+	handler.DeleteInvoiceHandler(rec, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNoContent, rec.Code, "Expected status code 204 No Content")
+	_, err := store.GetInvoiceByID(ctx, 1)
+	assert.Equal(t, models.ErrNotFound, err, "Expected the invoice to be deleted")
+}
+
+// TestCreateInvoiceWritesEventInSameTransaction verifies that the InvoiceCreated event is
+// written to the outbox in the invoice's transaction, and that the invoice is rolled back
+// when the event cannot be stored.
+func TestCreateInvoiceWritesEventInSameTransaction(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	store := &DBInvoiceStore{DB: db}
+
+	// The insert is prepared on first use and reused by the second call
+	insert := mock.ExpectPrepare("INSERT INTO invoices")
+	mock.ExpectBegin()
+	insert.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(1, 1))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	assert.NoError(t, store.CreateInvoice(ctx, &models.Invoice{CustomerID: 1, Amount: 10, Status: "Pending"}))
+
+	mock.ExpectBegin()
+	insert.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(2, 1))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+	assert.Error(t, store.CreateInvoice(ctx, &models.Invoice{CustomerID: 1, Amount: 10, Status: "Pending"}))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// voidRequest builds an authenticated void request for a user with the given role and
+// serves it through the same middleware chain used in production.
+func voidRequest(t *testing.T, store *MockInvoiceStore, role string, body VoidInvoicesRequest) *httptest.ResponseRecorder {
+	handler := NewInvoiceHandlers(store)
+	router := mux.NewRouter()
+	router.Handle("/invoices/void", middleware.JWTAuth(middleware.RequireRole("Admin", "Accountant")(http.HandlerFunc(handler.VoidInvoicesHandler)))).Methods("POST")
+
+	token, err := utils.GenerateJWT("finance@example.com", role)
+	assert.NoError(t, err)
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/invoices/void", bytes.NewBuffer(payload))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestVoidInvoicesHandler verifies that draft invoices are voided by an accountant and that
+// a reason and an elevated role are required.
+func TestVoidInvoicesHandler(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockInvoiceStore()
+	store.CreateInvoice(ctx, &models.Invoice{CustomerID: 1, Amount: 10, Status: models.InvoiceStatusDraft})
+	store.CreateInvoice(ctx, &models.Invoice{CustomerID: 1, Amount: 20, Status: models.InvoiceStatusDraft})
+
+	rec := voidRequest(t, store, "Employee", VoidInvoicesRequest{IDs: []int{1, 2}, Reason: "Duplicate import"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = voidRequest(t, store, "Accountant", VoidInvoicesRequest{IDs: []int{1, 2}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = voidRequest(t, store, "Accountant", VoidInvoicesRequest{IDs: []int{1, 2, 2}, Reason: "Duplicate import"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.InvoiceStatusVoid, store.invoices[1].Status)
+	assert.Equal(t, models.InvoiceStatusVoid, store.invoices[2].Status)
+}
+
+// TestVoidInvoicesHandlerRejectsBatch verifies that nothing is voided when the batch
+// contains an invoice that is not in a voidable state.
+func TestVoidInvoicesHandlerRejectsBatch(t *testing.T) {
+	store := NewMockInvoiceStore()
+	store.CreateInvoice(context.Background(), &models.Invoice{CustomerID: 1, Amount: 10, Status: models.InvoiceStatusDraft})
+	store.seed(models.Invoice{CustomerID: 1, Amount: 20, Status: "Paid"})
+
+	rec := voidRequest(t, store, "Admin", VoidInvoicesRequest{IDs: []int{1, 2, 3}, Reason: "Wrong customer"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	var body struct {
+		Error struct {
+			Details []models.BatchItemError `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Len(t, body.Error.Details, 2)
+	assert.Equal(t, models.InvoiceStatusDraft, store.invoices[1].Status)
+}
+
+// TestVoidInvoicesWritesAuditLog verifies that the database store updates the invoices and
+// writes one audit entry per invoice in a single transaction.
+func TestVoidInvoicesWritesAuditLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	store := &DBInvoiceStore{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, status FROM invoices").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(1, "draft").AddRow(2, "draft"))
+	mock.ExpectExec("UPDATE invoices SET status").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("finance@example.com", "void", "invoice", 1, "Duplicate import", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("finance@example.com", "void", "invoice", 2, "Duplicate import", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, store.VoidInvoices(context.Background(), []int{1, 2}, "Duplicate import", "finance@example.com"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostInvoiceHandler verifies the Draft→Posted transition: a valid draft is posted,
+// posted invoices cannot be posted again or edited, and incomplete drafts are rejected.
+func TestPostInvoiceHandler(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockInvoiceStore()
+	handler := NewInvoiceHandlers(store)
+	store.CreateInvoice(ctx, &models.Invoice{CustomerID: 1, Amount: 100, Status: models.InvoiceStatusDraft})
+	store.CreateInvoice(ctx, &models.Invoice{CustomerID: 1, Amount: 0, Status: models.InvoiceStatusDraft})
+
+	post := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/invoices/"+id+"/post", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		handler.PostInvoiceHandler(rec, req)
+		return rec
+	}
+
+	rec := post("1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.InvoiceStatusPosted, store.invoices[1].Status)
+
+	assert.Equal(t, http.StatusConflict, post("1").Code, "Posted invoices cannot be posted again")
+	assert.Equal(t, http.StatusUnprocessableEntity, post("2").Code, "Zero-amount invoices cannot be posted")
+	assert.Equal(t, http.StatusNotFound, post("3").Code)
+
+	payload, _ := json.Marshal(&models.Invoice{CustomerID: 2, Amount: 50, Version: 2})
+	req := httptest.NewRequest(http.MethodPut, "/invoices/1", bytes.NewBuffer(payload))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rec = httptest.NewRecorder()
+	handler.UpdateInvoiceHandler(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code, "Posted invoices are locked")
+}
+
+// TestPostInvoiceCreatesLedgerEffects verifies that posting locks the invoice and records
+// the receivable and revenue lines in the same transaction.
+func TestPostInvoiceCreatesLedgerEffects(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	store := &DBInvoiceStore{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, sales_order_id, customer_id, amount, status, version FROM invoices").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sales_order_id", "customer_id", "amount", "status", "version"}).AddRow(7, 1, 3, 250.0, "draft", 1))
+	mock.ExpectExec("UPDATE invoices SET status").WithArgs("posted", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM revenue_schedules").WithArgs(7).WillReturnRows(sqlmock.NewRows(nil))
+	for _, line := range []struct {
+		account string
+		amount  float64
+	}{{"accounts_receivable", 250}, {"revenue", -250}} {
+		mock.ExpectQuery("INSERT INTO financial_transactions").
+			WithArgs(line.account, line.amount, sqlmock.AnyArg(), "Invoice #7", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO account_period_balances").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery("FROM customer_deposits").WithArgs(1).WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	invoice, err := store.PostInvoice(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, models.InvoiceStatusPosted, invoice.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCloneInvoiceHandler verifies that a posted invoice is copied into a new draft and that
+// overrides from the request body are applied.
+func TestCloneInvoiceHandler(t *testing.T) {
+	store := NewMockInvoiceStore()
+	handler := NewInvoiceHandlers(store)
+	store.seed(models.Invoice{SalesOrderID: 4, CustomerID: 9, Amount: 80, Status: models.InvoiceStatusPosted})
+
+	req := httptest.NewRequest(http.MethodPost, "/invoices/1/clone", bytes.NewBufferString(`{"amount": 95.5}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rec := httptest.NewRecorder()
+	handler.CloneInvoiceHandler(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var clone models.Invoice
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&clone))
+	assert.Equal(t, 2, clone.ID)
+	assert.Equal(t, 4, clone.SalesOrderID)
+	assert.Equal(t, 9, clone.CustomerID)
+	assert.Equal(t, 95.5, clone.Amount)
+	assert.Equal(t, models.InvoiceStatusDraft, clone.Status)
+	assert.Equal(t, models.InvoiceStatusPosted, store.invoices[1].Status, "Source invoice is unchanged")
+
+	// An empty body copies the invoice as is
+	req = httptest.NewRequest(http.MethodPost, "/invoices/1/clone", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rec = httptest.NewRecorder()
+	handler.CloneInvoiceHandler(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 80.0, store.invoices[3].Amount)
+}
@@ -0,0 +1,365 @@
+// Package invoice_handlers provides the database implementation for invoice-related operations.
+// It includes functionality for creating, retrieving, updating, and deleting invoices in the database.
+package invoice_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/controllers/audit"
+	"erp/controllers/events"
+	"erp/controllers/handlers/deposit_handlers"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/controllers/handlers/recognition_handlers"
+	"erp/controllers/pagination"
+	"erp/controllers/versioning"
+	"erp/models"
+	"erp/models/db"
+	"erp/models/db/queries"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBInvoiceStore is a struct to hold the database connection for invoice operations.
+type DBInvoiceStore struct {
+	DB *sql.DB
+
+	stmts db.Statements // Prepared statements of the typed queries
+}
+
+// queries returns the typed queries of the store, prepared on first use.
+func (store *DBInvoiceStore) queries() *queries.Queries {
+	return queries.Prepared(store.DB, &store.stmts)
+}
+
+// CreateInvoice inserts a new draft invoice into the database. The InvoiceCreated event is
+// written to the outbox in the same transaction.
+func (store *DBInvoiceStore) CreateInvoice(ctx context.Context, invoice *models.Invoice) error {
+	invoice.Status = models.InvoiceStatusDraft
+
+	q := store.queries()
+	if err := q.Prepare(ctx, queries.InsertInvoiceSQL); err != nil {
+		return err
+	}
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	inserted, err := q.WithTx(tx).InsertInvoice(ctx, queries.InsertInvoiceParams{
+		SalesOrderID: invoice.SalesOrderID,
+		CustomerID:   invoice.CustomerID,
+		Amount:       invoice.Amount,
+		Status:       invoice.Status,
+	})
+	if err != nil {
+		return err
+	}
+	invoice.ID, invoice.Version = inserted.ID, inserted.Version
+
+	if err := events.Enqueue(ctx, tx, events.InvoiceCreated, "invoice", invoice.ID, invoice); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetInvoiceByID retrieves an invoice by its ID from the database.
+func (store *DBInvoiceStore) GetInvoiceByID(ctx context.Context, id int) (*models.Invoice, error) {
+	row, err := store.queries().GetInvoice(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &models.Invoice{
+		ID:           row.ID,
+		SalesOrderID: row.SalesOrderID,
+		CustomerID:   row.CustomerID,
+		Amount:       row.Amount,
+		Status:       row.Status.String,
+		Version:      row.Version,
+	}, nil
+}
+
+// InvoiceColumns are the fields invoices can be listed by.
+var InvoiceColumns = pagination.Columns{
+	"id":             {Expr: "id", Type: pagination.Int},
+	"sales_order_id": {Expr: "sales_order_id", Type: pagination.Int},
+	"customer_id":    {Expr: "customer_id", Type: pagination.Int},
+	"amount":         {Expr: "amount", Type: pagination.Number},
+	"status":         {Expr: "status", Type: pagination.Text},
+}
+
+// ListInvoices returns a page of invoices and the number matching the filters.
+func (store *DBInvoiceStore) ListInvoices(ctx context.Context, query models.ListQuery) ([]models.Invoice, int, error) {
+	invoices := []models.Invoice{}
+	total, err := pagination.Query(ctx, store.DB,
+		"id, COALESCE(sales_order_id, 0), COALESCE(customer_id, 0), amount, COALESCE(status, ''), version", "invoices",
+		InvoiceColumns, query, func(rows *sql.Rows) error {
+			var invoice models.Invoice
+			if err := rows.Scan(&invoice.ID, &invoice.SalesOrderID, &invoice.CustomerID, &invoice.Amount, &invoice.Status,
+				&invoice.Version); err != nil {
+				return err
+			}
+			invoices = append(invoices, invoice)
+			return nil
+		})
+	if err != nil {
+		return nil, 0, err
+	}
+	return invoices, total, nil
+}
+
+// invoiceColumns are the columns of invoices read into a models.Invoice; the optional
+// references are read as 0.
+const invoiceColumns = "id, COALESCE(sales_order_id, 0), COALESCE(customer_id, 0), amount, COALESCE(status, ''), version"
+
+// InvoicesByID returns the invoices with the given IDs, read with one query.
+//
+// Parameters:
+//   - ids: The invoice IDs.
+//
+// Returns:
+//   - map[int]*models.Invoice: The invoices found, by ID; deleted invoices are left out.
+//   - error: An error if the query fails.
+func (store *DBInvoiceStore) InvoicesByID(ctx context.Context, ids []int) (map[int]*models.Invoice, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT "+invoiceColumns+" FROM invoices WHERE id = ANY($1) AND deleted_at IS NULL", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	invoices, err := scanInvoices(rows)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*models.Invoice, len(invoices))
+	for i := range invoices {
+		byID[invoices[i].ID] = &invoices[i]
+	}
+	return byID, nil
+}
+
+// InvoicesByCustomer returns the invoices of the given customers, read with one query.
+//
+// Parameters:
+//   - customerIDs: The customer IDs.
+//   - status: An invoice status, or "" for all.
+//
+// Returns:
+//   - map[int][]models.Invoice: The invoices of each customer, ordered by ID; deleted
+//     invoices are left out.
+//   - error: An error if the query fails.
+func (store *DBInvoiceStore) InvoicesByCustomer(ctx context.Context, customerIDs []int, status string) (map[int][]models.Invoice, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT "+invoiceColumns+` FROM invoices
+		 WHERE customer_id = ANY($1) AND ($2 = '' OR status = $2) AND deleted_at IS NULL ORDER BY id`,
+		pq.Array(customerIDs), status)
+	if err != nil {
+		return nil, err
+	}
+	invoices, err := scanInvoices(rows)
+	if err != nil {
+		return nil, err
+	}
+	byCustomer := map[int][]models.Invoice{}
+	for _, invoice := range invoices {
+		byCustomer[invoice.CustomerID] = append(byCustomer[invoice.CustomerID], invoice)
+	}
+	return byCustomer, nil
+}
+
+func scanInvoices(rows *sql.Rows) ([]models.Invoice, error) {
+	defer rows.Close()
+	invoices := []models.Invoice{}
+	for rows.Next() {
+		var invoice models.Invoice
+		if err := rows.Scan(&invoice.ID, &invoice.SalesOrderID, &invoice.CustomerID, &invoice.Amount, &invoice.Status,
+			&invoice.Version); err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, rows.Err()
+}
+
+// UpdateInvoice updates an existing draft invoice's details in the database if it is still
+// at invoice.Version, and sets the new version. The status is not changed; posted and voided
+// invoices are locked and return models.ErrDocumentLocked, and drafts changed since
+// invoice.Version return a conflict.
+func (store *DBInvoiceStore) UpdateInvoice(ctx context.Context, invoice *models.Invoice) error {
+	version, err := store.queries().UpdateDraftInvoice(ctx, queries.UpdateDraftInvoiceParams{
+		SalesOrderID: invoice.SalesOrderID,
+		CustomerID:   invoice.CustomerID,
+		Amount:       invoice.Amount,
+		ID:           invoice.ID,
+		DraftStatus:  models.InvoiceStatusDraft,
+		Version:      invoice.Version,
+	})
+	if err == sql.ErrNoRows {
+		current, err := store.GetInvoiceByID(ctx, invoice.ID)
+		if err != nil {
+			return err
+		}
+		if current.Status != models.InvoiceStatusDraft {
+			return models.ErrDocumentLocked
+		}
+		return versioning.Stale(ctx, store.DB, "SELECT version FROM invoices WHERE id = $1 AND deleted_at IS NULL", "invoice",
+			invoice.ID, invoice.Version)
+	}
+	if err != nil {
+		return err
+	}
+	invoice.Version = version
+	return nil
+}
+
+// DeleteInvoice soft deletes a draft invoice by its ID. Posted and voided
+// invoices are locked and return models.ErrDocumentLocked.
+func (store *DBInvoiceStore) DeleteInvoice(ctx context.Context, id int) error {
+	rowsAffected, err := store.queries().DeleteDraftInvoice(ctx, id, models.InvoiceStatusDraft)
+	if err != nil {
+		return err
+	}
+	return store.checkDraftChange(ctx, rowsAffected, id)
+}
+
+// checkDraftChange explains why a change restricted to draft invoices affected no rows.
+func (store *DBInvoiceStore) checkDraftChange(ctx context.Context, rowsAffected int64, id int) error {
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	exists, err := store.queries().InvoiceExists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrNotFound
+	}
+	return models.ErrDocumentLocked
+}
+
+// PostInvoice validates a draft invoice and posts it: the receivable is debited and revenue
+// credited in the ledger (deferred revenue if the invoice has a revenue schedule), deposits
+// paid on the sales order are applied to the receivable, the invoice is locked and the
+// InvoicePosted event is written to the outbox, all in one transaction.
+func (store *DBInvoiceStore) PostInvoice(ctx context.Context, id int) (*models.Invoice, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	q := store.queries().WithTx(tx)
+	row, err := q.LockInvoice(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	invoice := &models.Invoice{
+		ID:           row.ID,
+		SalesOrderID: row.SalesOrderID,
+		CustomerID:   row.CustomerID,
+		Amount:       row.Amount,
+		Status:       row.Status.String,
+		Version:      row.Version,
+	}
+
+	if err := invoice.ValidateForPosting(); err != nil {
+		return nil, err
+	}
+
+	if err := q.SetInvoiceStatus(ctx, models.InvoiceStatusPosted, id); err != nil {
+		return nil, err
+	}
+	// The invoice is locked, so posting is the only update since it was read
+	invoice.Status, invoice.Version = models.InvoiceStatusPosted, invoice.Version+1
+
+	now := time.Now()
+	revenueAccount, err := recognition_handlers.ScheduleRevenue(ctx, tx, invoice, now)
+	if err != nil {
+		return nil, err
+	}
+	description := fmt.Sprintf("Invoice #%d", id)
+	lines := []models.FinancialTransaction{
+		{AccountType: "accounts_receivable", Amount: invoice.Amount},
+		{AccountType: revenueAccount, Amount: -invoice.Amount},
+	}
+	for i := range lines {
+		lines[i].TransactionDate = now
+		lines[i].Description = description
+		lines[i].InvoiceID = &invoice.ID
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &lines[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := deposit_handlers.ApplyDeposits(ctx, tx, invoice, now); err != nil {
+		return nil, err
+	}
+
+	if err := events.Enqueue(ctx, tx, events.InvoicePosted, "invoice", invoice.ID, invoice); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// VoidInvoices marks a batch of invoices as void. The invoices are locked, checked for a
+// voidable status, updated and audited in one transaction; if any is missing or not voidable
+// the whole batch is rejected.
+func (store *DBInvoiceStore) VoidInvoices(ctx context.Context, ids []int, reason, actor string) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := store.queries().WithTx(tx)
+	idArray := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		idArray[i] = int64(id)
+	}
+	rows, err := q.LockInvoiceStatuses(ctx, idArray)
+	if err != nil {
+		return err
+	}
+	statuses := make(map[int]string)
+	for _, row := range rows {
+		statuses[row.ID] = row.Status.String
+	}
+
+	rejected := &models.BatchRejectedError{}
+	for _, id := range ids {
+		status, found := statuses[id]
+		if !found {
+			rejected.Items = append(rejected.Items, models.BatchItemError{ID: id, Error: "invoice not found"})
+		} else if !models.IsInvoiceVoidable(status) {
+			rejected.Items = append(rejected.Items, models.BatchItemError{ID: id, Error: fmt.Sprintf("invoice in status %q cannot be voided", status)})
+		}
+	}
+	if len(rejected.Items) > 0 {
+		return rejected
+	}
+
+	if err := q.SetInvoiceStatuses(ctx, models.InvoiceStatusVoid, idArray); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		entry := &models.AuditEntry{
+			Actor:      actor,
+			Action:     audit.ActionVoid,
+			EntityType: "invoice",
+			EntityID:   id,
+			Reason:     reason,
+		}
+		if err := audit.Record(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,117 @@
+package invoice_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/controllers/events"
+	"erp/models"
+	"erp/models/db/dbtest"
+	"erp/models/db/queries"
+	"testing"
+)
+
+// BenchmarkCreateInvoice compares creating invoices with the SQL text sent on every call
+// ("adhoc", as the store did before) against the store's prepared insert. It needs
+// ERP_BENCH_DATABASE_URL; see the dbtest package.
+func BenchmarkCreateInvoice(b *testing.B) {
+	ctx := context.Background()
+	db := dbtest.Open(ctx, b)
+	invoice := benchInvoice(b, db)
+
+	b.Run("adhoc", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			created := *invoice
+			var inserted queries.InsertInvoiceRow
+			inserted, err = queries.New(tx).InsertInvoice(ctx, queries.InsertInvoiceParams{
+				SalesOrderID: created.SalesOrderID,
+				CustomerID:   created.CustomerID,
+				Amount:       created.Amount,
+				Status:       models.InvoiceStatusDraft,
+			})
+			created.ID = inserted.ID
+			if err == nil {
+				err = events.Enqueue(ctx, tx, events.InvoiceCreated, "invoice", created.ID, &created)
+			}
+			if err == nil {
+				err = tx.Commit()
+			}
+			if err != nil {
+				tx.Rollback()
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		store := &DBInvoiceStore{DB: db}
+		defer store.stmts.Close()
+		for i := 0; i < b.N; i++ {
+			created := *invoice
+			if err := store.CreateInvoice(ctx, &created); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkGetInvoiceByID compares ad hoc and prepared invoice lookups.
+func BenchmarkGetInvoiceByID(b *testing.B) {
+	ctx := context.Background()
+	db := dbtest.Open(ctx, b)
+	store := &DBInvoiceStore{DB: db}
+	defer store.stmts.Close()
+	invoice := benchInvoice(b, db)
+	if err := store.CreateInvoice(ctx, invoice); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("adhoc", func(b *testing.B) {
+		adhoc := queries.New(db)
+		for i := 0; i < b.N; i++ {
+			if _, err := adhoc.GetInvoice(ctx, invoice.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetInvoiceByID(ctx, invoice.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// benchInvoice creates a customer and sales order to invoice, and removes them, their
+// invoices and the outbox messages written meanwhile when the benchmark ends.
+func benchInvoice(b *testing.B, db *sql.DB) *models.Invoice {
+	ctx := context.Background()
+	b.Helper()
+	var lastMessage int
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM outbox_messages").Scan(&lastMessage); err != nil {
+		b.Fatal(err)
+	}
+
+	invoice := &models.Invoice{Amount: 125.50}
+	err := db.QueryRowContext(ctx, "INSERT INTO customers (name) VALUES ('Benchmark customer') RETURNING id").Scan(&invoice.CustomerID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		db.ExecContext(ctx, "DELETE FROM customers WHERE id = $1", invoice.CustomerID)
+		db.ExecContext(ctx, "DELETE FROM outbox_messages WHERE id > $1", lastMessage)
+	})
+	err = db.QueryRowContext(ctx,
+		"INSERT INTO sales_orders (customer_id, order_date, quantity) VALUES ($1, CURRENT_DATE, 1) RETURNING id",
+		invoice.CustomerID,
+	).Scan(&invoice.SalesOrderID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return invoice
+}
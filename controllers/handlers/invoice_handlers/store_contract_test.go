@@ -0,0 +1,40 @@
+package invoice_handlers
+
+import (
+	"context"
+	"testing"
+
+	"erp/models"
+	"erp/models/db/dbtest"
+	"erp/models/storetest"
+)
+
+// TestMockInvoiceStoreContract keeps the handler tests' mock in line with the database store.
+func TestMockInvoiceStoreContract(t *testing.T) {
+	storetest.TestInvoiceStore(context.Background(), t, NewMockInvoiceStore(), func(t *testing.T) models.Invoice {
+		return models.Invoice{SalesOrderID: 1, CustomerID: 1, Amount: 125.50}
+	})
+}
+
+// TestDBInvoiceStoreContract runs the invoice store contract against PostgreSQL. It needs
+// ERP_BENCH_DATABASE_URL; see the dbtest package.
+func TestDBInvoiceStoreContract(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(ctx, t)
+	storetest.TestInvoiceStore(ctx, t, &DBInvoiceStore{DB: db}, func(t *testing.T) models.Invoice {
+		invoice := models.Invoice{Amount: 125.50}
+		err := db.QueryRowContext(ctx, "INSERT INTO customers (name) VALUES ('Contract customer') RETURNING id").Scan(&invoice.CustomerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.ExecContext(ctx, "DELETE FROM customers WHERE id = $1", invoice.CustomerID) })
+		err = db.QueryRowContext(ctx,
+			"INSERT INTO sales_orders (customer_id, order_date, quantity) VALUES ($1, CURRENT_DATE, 1) RETURNING id",
+			invoice.CustomerID,
+		).Scan(&invoice.SalesOrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return invoice
+	})
+}
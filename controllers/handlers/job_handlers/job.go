@@ -0,0 +1,60 @@
+package job_handlers
+
+import (
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// JobHandler provides the HTTP handler for polling background jobs.
+type JobHandler struct {
+	Store models.JobStore
+}
+
+// RegisterRoutes maps job routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the JobStore interface.
+func RegisterRoutes(router *mux.Router, store models.JobStore) {
+	handler := &JobHandler{Store: store}
+
+	router.HandleFunc("/{id:[0-9]+}", handler.GetJob).Methods("GET")
+}
+
+// GetJob returns the status, progress and result of a job. Users only see the jobs they
+// started; admins see every job.
+//
+// HTTP Method: GET
+// URL Path: /{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the Job in JSON.
+//   - Status Code: 404 (Not Found) if the job does not exist or belongs to another user.
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	job, err := h.Store.GetJob(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Job not found")
+		return
+	} else if err != nil {
+		httperr.Write(w, err, "Failed to load job")
+		return
+	}
+
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	if job.CreatedBy != email && role != "Admin" {
+		respond.Error(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,155 @@
+// Package job_handlers provides SQL-backed methods for the background jobs table and the
+// HTTP handler clients use to poll a job's status and progress.
+package job_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/models"
+	"time"
+)
+
+// DBJobStore provides SQL-backed methods for the jobs table.
+type DBJobStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+const jobColumns = `id, kind, status, processed, total, result, error, created_by, created_at, started_at, finished_at`
+
+// CreateJob inserts a new job.
+//
+// Parameters:
+//   - job: The job to store. Its ID is set on success.
+//
+// Returns:
+//   - error: An error if the insert fails.
+func (store *DBJobStore) CreateJob(ctx context.Context, job *models.Job) error {
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO jobs (kind, status, created_by, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		job.Kind, job.Status, job.CreatedBy, job.CreatedAt,
+	).Scan(&job.ID)
+}
+
+// StartJob marks a job as running.
+//
+// Parameters:
+//   - id: The ID of the job.
+//   - at: The time the job started.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (store *DBJobStore) StartJob(ctx context.Context, id int, at time.Time) error {
+	_, err := store.DB.ExecContext(ctx, `UPDATE jobs SET status = $2, started_at = $3 WHERE id = $1`, id, models.JobRunning, at)
+	return err
+}
+
+// UpdateJobProgress records how much of a job is done.
+//
+// Parameters:
+//   - id: The ID of the job.
+//   - processed: Units of work done so far.
+//   - total: Expected units of work, or 0 if unknown.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (store *DBJobStore) UpdateJobProgress(ctx context.Context, id int, processed, total int64) error {
+	_, err := store.DB.ExecContext(ctx, `UPDATE jobs SET processed = $2, total = $3 WHERE id = $1`, id, processed, total)
+	return err
+}
+
+// FinishJob stores the outcome of a job.
+//
+// Parameters:
+//   - id: The ID of the job.
+//   - result: The JSON result, or nil.
+//   - errMsg: The reason the job failed, or "" if it succeeded.
+//   - at: The time the job finished.
+//
+// Returns:
+//   - error: An error if the update fails.
+func (store *DBJobStore) FinishJob(ctx context.Context, id int, result json.RawMessage, errMsg string, at time.Time) error {
+	status := models.JobSucceeded
+	if errMsg != "" {
+		status = models.JobFailed
+	}
+	var resultValue interface{}
+	if result != nil {
+		resultValue = []byte(result)
+	}
+	_, err := store.DB.ExecContext(ctx,
+		`UPDATE jobs SET status = $2, result = $3, error = $4, finished_at = $5 WHERE id = $1`,
+		id, status, resultValue, errMsg, at,
+	)
+	return err
+}
+
+// GetJob retrieves a job by ID.
+//
+// Parameters:
+//   - id: The ID of the job.
+//
+// Returns:
+//   - *Job: The job.
+//   - error: models.ErrNotFound if no job has the ID.
+func (store *DBJobStore) GetJob(ctx context.Context, id int) (*models.Job, error) {
+	job, err := scanJob(store.DB.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return job, err
+}
+
+// ListJobs returns the most recent jobs of a kind, newest first.
+//
+// Parameters:
+//   - kind: The kind of job.
+//   - limit: The maximum number of jobs to return.
+//
+// Returns:
+//   - []Job: The jobs.
+//   - error: An error if the query fails.
+func (store *DBJobStore) ListJobs(ctx context.Context, kind string, limit int) ([]models.Job, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE kind = $1 ORDER BY created_at DESC, id DESC LIMIT $2`, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// scanner is satisfied by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob reads a job selected with jobColumns.
+func scanJob(row scanner) (*models.Job, error) {
+	job := &models.Job{}
+	var result []byte
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Kind, &job.Status, &job.Processed, &job.Total, &result, &job.Error,
+		&job.CreatedBy, &job.CreatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		job.Result = result
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
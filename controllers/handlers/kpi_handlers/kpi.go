@@ -0,0 +1,264 @@
+// Package kpi_handlers provides HTTP handlers for KPI alert rules and their alert history,
+// and the SQL store that measures the metrics the rules watch.
+package kpi_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/kpi"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// KPIHandlers provides the KPI rule and alert endpoints.
+type KPIHandlers struct {
+	Service *kpi.Service
+}
+
+// RuleRequest is the request body for creating or updating a KPI rule. Rules are active
+// unless "active" is false.
+type RuleRequest struct {
+	Name      string  `json:"name"`
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	Active    *bool   `json:"active"`
+}
+
+// Rule returns the rule described by the request.
+func (req RuleRequest) Rule() models.KPIRule {
+	return models.KPIRule{
+		Name:      req.Name,
+		Metric:    req.Metric,
+		Operator:  req.Operator,
+		Threshold: req.Threshold,
+		Active:    req.Active == nil || *req.Active,
+	}
+}
+
+// RegisterRoutes maps the KPI rule routes to their handler functions. The router is
+// expected to be restricted to administrators.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The KPI service shared with the scheduler.
+func RegisterRoutes(router *mux.Router, service *kpi.Service) {
+	handler := &KPIHandlers{Service: service}
+
+	router.HandleFunc("", handler.ListRules).Methods("GET")
+	router.HandleFunc("", handler.CreateRule).Methods("POST")
+	router.HandleFunc("/evaluate", handler.Evaluate).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetRule).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.UpdateRule).Methods("PUT")
+	router.HandleFunc("/{id:[0-9]+}", handler.DeleteRule).Methods("DELETE")
+}
+
+// RegisterAlertRoutes maps the alert history routes to their handler functions.
+func RegisterAlertRoutes(router *mux.Router, service *kpi.Service) {
+	handler := &KPIHandlers{Service: service}
+
+	router.HandleFunc("", handler.ListAlerts).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetAlert).Methods("GET")
+}
+
+// ListRules returns every KPI rule with its last value and breach state.
+//
+// HTTP Method: GET
+// URL Path: /kpi_rules
+//
+// Response:
+//   - Status Code: 200 (OK) with a JSON array of rules.
+//   - Status Code: 500 (Internal Server Error) if the rules cannot be loaded.
+func (h *KPIHandlers) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.Service.List(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load KPI rules")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, rules)
+}
+
+// CreateRule creates a KPI rule, e.g. {"name": "High receivables", "metric": "receivables",
+// "operator": ">", "threshold": 50000}.
+//
+// HTTP Method: POST
+// URL Path: /kpi_rules
+//
+// Request Body:
+//   - JSON with name, metric, operator, threshold and optionally active.
+//
+// Response:
+//   - Status Code: 201 (Created) with the rule in JSON format.
+//   - Status Code: 400 (Bad Request) if the payload is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the name is missing or the metric or operator is unknown.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be created.
+func (h *KPIHandlers) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req RuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	rule := req.Rule()
+	rule.CreatedBy, _ = middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Create(r.Context(), &rule); err != nil {
+		httperr.Write(w, err, "Failed to create KPI rule")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, rule)
+}
+
+// GetRule returns a KPI rule by ID.
+//
+// HTTP Method: GET
+// URL Path: /kpi_rules/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the rule in JSON format.
+//   - Status Code: 404 (Not Found) if the rule does not exist.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be loaded.
+func (h *KPIHandlers) GetRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	rule, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load KPI rule")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, rule)
+}
+
+// UpdateRule replaces a KPI rule's definition. Its breach state is cleared, so the rule
+// alerts again the next time it is breached.
+//
+// HTTP Method: PUT
+// URL Path: /kpi_rules/{id}
+//
+// Request Body:
+//   - JSON with name, metric, operator, threshold and optionally active.
+//
+// Response:
+//   - Status Code: 200 (OK) with the rule in JSON format.
+//   - Status Code: 400 (Bad Request) if the payload is invalid.
+//   - Status Code: 404 (Not Found) if the rule does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the name is missing or the metric or operator is unknown.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be updated.
+func (h *KPIHandlers) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req RuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	rule := req.Rule()
+	rule.ID = id
+	if err := h.Service.Update(r.Context(), &rule); err != nil {
+		httperr.Write(w, err, "Failed to update KPI rule")
+		return
+	}
+	updated, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load KPI rule")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, updated)
+}
+
+// DeleteRule deletes a KPI rule. Its alerts stay in the history.
+//
+// HTTP Method: DELETE
+// URL Path: /kpi_rules/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the rule was deleted.
+//   - Status Code: 404 (Not Found) if the rule does not exist.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be deleted.
+func (h *KPIHandlers) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete KPI rule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Evaluate evaluates the active rules now instead of waiting for the scheduler.
+//
+// HTTP Method: POST
+// URL Path: /kpi_rules/evaluate
+//
+// Response:
+//   - Status Code: 200 (OK) with a JSON array of the alerts raised.
+//   - Status Code: 500 (Internal Server Error) if a rule cannot be evaluated.
+func (h *KPIHandlers) Evaluate(w http.ResponseWriter, r *http.Request) {
+	alerts, err := h.Service.Evaluate(r.Context(), time.Now())
+	if err != nil {
+		httperr.Write(w, err, "Failed to evaluate KPI rules")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, alerts)
+}
+
+// ListAlerts returns the alert history, newest first.
+//
+// HTTP Method: GET
+// URL Path: /kpi_alerts?rule_id={id}&limit={n} (both optional; limit defaults to 50)
+//
+// Response:
+//   - Status Code: 200 (OK) with a JSON array of alerts.
+//   - Status Code: 400 (Bad Request) if rule_id or limit is not a number.
+//   - Status Code: 422 (Unprocessable Entity) if limit is out of range.
+//   - Status Code: 500 (Internal Server Error) if the alerts cannot be loaded.
+func (h *KPIHandlers) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	var ruleID, limit int
+	for name, value := range map[string]*int{"rule_id": &ruleID, "limit": &limit} {
+		if raw := r.URL.Query().Get(name); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil {
+				respond.Error(w, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*value = parsed
+		}
+	}
+
+	alerts, err := h.Service.Alerts(r.Context(), ruleID, limit)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load KPI alerts")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, alerts)
+}
+
+// GetAlert returns a KPI alert by ID.
+//
+// HTTP Method: GET
+// URL Path: /kpi_alerts/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the alert in JSON format.
+//   - Status Code: 404 (Not Found) if the alert does not exist.
+//   - Status Code: 500 (Internal Server Error) if the alert cannot be loaded.
+func (h *KPIHandlers) GetAlert(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	alert, err := h.Service.Alert(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load KPI alert")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, alert)
+}
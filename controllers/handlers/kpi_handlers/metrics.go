@@ -0,0 +1,93 @@
+package kpi_handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/controllers/handlers/report_handlers"
+	"erp/models"
+)
+
+// MeasureKPI computes a metric at now from the same sources as the financial reports.
+//
+// Parameters:
+//   - metric: One of the models.KPI* metrics.
+//   - now: The time to measure at; monthly metrics use the last complete month before it.
+//
+// Returns:
+//   - float64: The metric's value, rounded to cents.
+//   - bool: false if the metric has no value, e.g. a turnover without stock on hand or a
+//     revenue drop from a month without revenue.
+//   - error: A validation error for an unknown metric, or the query error.
+func (s *DBKPIStore) MeasureKPI(ctx context.Context, metric string, now time.Time) (float64, bool, error) {
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+
+	switch metric {
+	case models.KPIReceivables:
+		var outstanding float64
+		err := s.DB.QueryRowContext(ctx,
+			`SELECT COALESCE(SUM(amount), 0) FROM receivables
+			 WHERE COALESCE(LOWER(status), 'pending') <> 'paid'`,
+		).Scan(&outstanding)
+		return roundCents(outstanding), err == nil, err
+
+	case models.KPIMonthlyRevenue:
+		revenue, err := s.revenue(ctx, lastMonth, thisMonth)
+		return roundCents(revenue), err == nil, err
+
+	case models.KPIRevenueDropPct:
+		previous, err := s.revenue(ctx, lastMonth.AddDate(0, -1, 0), lastMonth)
+		if err != nil || previous <= 0 {
+			return 0, false, err
+		}
+		last, err := s.revenue(ctx, lastMonth, thisMonth)
+		if err != nil {
+			return 0, false, err
+		}
+		return roundCents((previous - last) / previous * 100), true, nil
+
+	case models.KPIStockTurnover:
+		// The profitability report's sales give the cost of goods sold over the last year
+		reports := &report_handlers.DBReportStore{DB: s.DB}
+		lines, _, err := reports.GetProfitability(ctx, models.ProfitabilityByProduct, now.AddDate(-1, 0, 0), now)
+		if err != nil {
+			return 0, false, err
+		}
+		cogs := 0.0
+		for _, line := range lines {
+			cogs += line.COGS
+		}
+		var stockValue float64
+		err = s.DB.QueryRowContext(ctx,
+			`SELECT COALESCE(SUM(s.quantity * p.unit_cost), 0)
+			 FROM stock s JOIN products p ON p.id = s.product_id`,
+		).Scan(&stockValue)
+		if err != nil || stockValue <= 0 {
+			return 0, false, err
+		}
+		return roundCents(cogs / stockValue), true, nil
+	}
+	return 0, false, models.Invalid("unknown metric %q", metric)
+}
+
+// revenue returns the revenue booked to the ledger from start up to but excluding end.
+func (s *DBKPIStore) revenue(ctx context.Context, start, end time.Time) (float64, error) {
+	var revenue float64
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(-SUM(amount), 0) FROM financial_transactions
+		 WHERE account_type = 'revenue' AND transaction_date >= $1 AND transaction_date < $2`,
+		start, end,
+	).Scan(&revenue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load revenue: %w", err)
+	}
+	return revenue, nil
+}
+
+// roundCents rounds an amount to two decimal places.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,255 @@
+package kpi_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/controllers/events"
+	"erp/models"
+)
+
+// kpiRuleColumns are the kpi_rules columns read by scanKPIRule.
+const kpiRuleColumns = `id, name, metric, operator, threshold, active, breached, last_value, last_evaluated_at, created_by, created_at`
+
+// kpiAlertColumns are the kpi_alerts columns read by scanKPIAlert.
+const kpiAlertColumns = `id, rule_id, rule_name, metric, operator, threshold, value, message, triggered_at`
+
+// DBKPIStore implements models.KPIStore using a SQL database.
+type DBKPIStore struct {
+	DB *sql.DB
+}
+
+// CreateKPIRule inserts a new KPI rule.
+//
+// Parameters:
+//   - rule: The rule to insert; its ID is populated.
+//
+// Returns:
+//   - error: An error if the insertion fails.
+func (s *DBKPIStore) CreateKPIRule(ctx context.Context, rule *models.KPIRule) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO kpi_rules (name, metric, operator, threshold, active, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		rule.Name, rule.Metric, rule.Operator, rule.Threshold, rule.Active, rule.CreatedBy, rule.CreatedAt,
+	).Scan(&rule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create KPI rule: %w", err)
+	}
+	return nil
+}
+
+// GetKPIRule returns a KPI rule by ID.
+//
+// Returns:
+//   - *models.KPIRule: The rule.
+//   - error: models.ErrNotFound if it does not exist, or the query error.
+func (s *DBKPIStore) GetKPIRule(ctx context.Context, id int) (*models.KPIRule, error) {
+	rule, err := scanKPIRule(s.DB.QueryRowContext(ctx, "SELECT "+kpiRuleColumns+" FROM kpi_rules WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("KPI rule %d not found", id)
+	}
+	return rule, err
+}
+
+// ListKPIRules returns the KPI rules ordered by ID.
+//
+// Parameters:
+//   - activeOnly: Whether to leave out inactive rules.
+//
+// Returns:
+//   - []models.KPIRule: The rules; empty if there are none.
+//   - error: An error if the query fails.
+func (s *DBKPIStore) ListKPIRules(ctx context.Context, activeOnly bool) ([]models.KPIRule, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+kpiRuleColumns+" FROM kpi_rules WHERE active OR NOT $1 ORDER BY id", activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list KPI rules: %w", err)
+	}
+	defer rows.Close()
+	rules := []models.KPIRule{}
+	for rows.Next() {
+		rule, err := scanKPIRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateKPIRule changes a rule's definition and clears its breach state and last value.
+//
+// Returns:
+//   - error: models.ErrNotFound if the rule does not exist, or the query error.
+func (s *DBKPIStore) UpdateKPIRule(ctx context.Context, rule *models.KPIRule) error {
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE kpi_rules
+		 SET name = $1, metric = $2, operator = $3, threshold = $4, active = $5,
+		     breached = FALSE, last_value = NULL, last_evaluated_at = NULL
+		 WHERE id = $6`,
+		rule.Name, rule.Metric, rule.Operator, rule.Threshold, rule.Active, rule.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update KPI rule: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("KPI rule %d not found", rule.ID)
+	}
+	rule.Breached = false
+	rule.LastValue = nil
+	rule.LastEvaluatedAt = nil
+	return nil
+}
+
+// DeleteKPIRule removes a KPI rule. Its alerts are kept without the rule reference.
+//
+// Returns:
+//   - error: models.ErrNotFound if the rule does not exist, or the query error.
+func (s *DBKPIStore) DeleteKPIRule(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM kpi_rules WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete KPI rule: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("KPI rule %d not found", id)
+	}
+	return nil
+}
+
+// RecordKPIEvaluation stores a rule's latest value and breach state. The rule is locked
+// so that concurrent evaluations raise one alert when it enters breach.
+//
+// Parameters:
+//   - rule: The evaluated rule; its breach state and last value are updated.
+//   - value: The measured value of its metric.
+//   - now: The time of the evaluation.
+//
+// Returns:
+//   - *models.KPIAlert: The alert if the rule entered breach, otherwise nil.
+//   - error: models.ErrNotFound if the rule was deleted, or the query error.
+func (s *DBKPIStore) RecordKPIEvaluation(ctx context.Context, rule *models.KPIRule, value float64, now time.Time) (*models.KPIAlert, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var wasBreached bool
+	err = tx.QueryRowContext(ctx, "SELECT breached FROM kpi_rules WHERE id = $1 FOR UPDATE", rule.ID).Scan(&wasBreached)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("KPI rule %d not found", rule.ID)
+	} else if err != nil {
+		return nil, err
+	}
+	breached := rule.Breaches(value)
+	_, err = tx.ExecContext(ctx,
+		"UPDATE kpi_rules SET breached = $1, last_value = $2, last_evaluated_at = $3 WHERE id = $4",
+		breached, value, now, rule.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rule.Breached = breached
+	rule.LastValue = &value
+	rule.LastEvaluatedAt = &now
+
+	var alert *models.KPIAlert
+	if breached && !wasBreached {
+		alert = rule.Alert(value, now)
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO kpi_alerts (rule_id, rule_name, metric, operator, threshold, value, message, triggered_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			alert.RuleID, alert.RuleName, alert.Metric, alert.Operator, alert.Threshold, alert.Value, alert.Message, alert.TriggeredAt,
+		).Scan(&alert.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := events.Enqueue(ctx, tx, events.KPIAlertTriggered, "kpi_alert", alert.ID, alert); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// GetKPIAlert returns a KPI alert by ID.
+//
+// Returns:
+//   - *models.KPIAlert: The alert.
+//   - error: models.ErrNotFound if it does not exist, or the query error.
+func (s *DBKPIStore) GetKPIAlert(ctx context.Context, id int) (*models.KPIAlert, error) {
+	alert, err := scanKPIAlert(s.DB.QueryRowContext(ctx, "SELECT "+kpiAlertColumns+" FROM kpi_alerts WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("KPI alert %d not found", id)
+	}
+	return alert, err
+}
+
+// ListKPIAlerts returns the newest alerts of a rule, or of all rules when ruleID is 0.
+//
+// Returns:
+//   - []models.KPIAlert: Up to limit alerts, newest first; empty if there are none.
+//   - error: An error if the query fails.
+func (s *DBKPIStore) ListKPIAlerts(ctx context.Context, ruleID, limit int) ([]models.KPIAlert, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT "+kpiAlertColumns+" FROM kpi_alerts WHERE $1 = 0 OR rule_id = $1 ORDER BY triggered_at DESC, id DESC LIMIT $2",
+		ruleID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list KPI alerts: %w", err)
+	}
+	defer rows.Close()
+	alerts := []models.KPIAlert{}
+	for rows.Next() {
+		alert, err := scanKPIAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *alert)
+	}
+	return alerts, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanKPIRule reads the kpiRuleColumns of a rule.
+func scanKPIRule(row rowScanner) (*models.KPIRule, error) {
+	var rule models.KPIRule
+	var lastValue sql.NullFloat64
+	var lastEvaluatedAt sql.NullTime
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Metric, &rule.Operator, &rule.Threshold, &rule.Active, &rule.Breached,
+		&lastValue, &lastEvaluatedAt, &rule.CreatedBy, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastValue.Valid {
+		rule.LastValue = &lastValue.Float64
+	}
+	if lastEvaluatedAt.Valid {
+		rule.LastEvaluatedAt = &lastEvaluatedAt.Time
+	}
+	return &rule, nil
+}
+
+// scanKPIAlert reads the kpiAlertColumns of an alert.
+func scanKPIAlert(row rowScanner) (*models.KPIAlert, error) {
+	var alert models.KPIAlert
+	var ruleID sql.NullInt64
+	err := row.Scan(&alert.ID, &ruleID, &alert.RuleName, &alert.Metric, &alert.Operator, &alert.Threshold,
+		&alert.Value, &alert.Message, &alert.TriggeredAt)
+	if err != nil {
+		return nil, err
+	}
+	alert.RuleID = int(ruleID.Int64)
+	return &alert, nil
+}
@@ -0,0 +1,84 @@
+package kpi_handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockStore(t *testing.T) (*DBKPIStore, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return &DBKPIStore{DB: db}, mock
+}
+
+func TestRecordKPIEvaluationAlertsOnBreach(t *testing.T) {
+	store, mock := newMockStore(t)
+	rule := &models.KPIRule{ID: 4, Name: "High receivables", Metric: models.KPIReceivables, Operator: ">", Threshold: 50000}
+	now := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT breached FROM kpi_rules WHERE id = \\$1 FOR UPDATE").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"breached"}).AddRow(false))
+	mock.ExpectExec("UPDATE kpi_rules SET breached").WithArgs(true, 62000.0, now, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO kpi_alerts").
+		WithArgs(4, "High receivables", models.KPIReceivables, ">", 50000.0, 62000.0, sqlmock.AnyArg(), now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	alert, err := store.RecordKPIEvaluation(context.Background(), rule, 62000, now)
+	require.NoError(t, err)
+	require.NotNil(t, alert)
+	assert.Equal(t, 9, alert.ID)
+	assert.True(t, rule.Breached)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordKPIEvaluationStaysQuietInBreach(t *testing.T) {
+	store, mock := newMockStore(t)
+	rule := &models.KPIRule{ID: 4, Metric: models.KPIReceivables, Operator: ">", Threshold: 50000}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT breached FROM kpi_rules").WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"breached"}).AddRow(true))
+	mock.ExpectExec("UPDATE kpi_rules SET breached").WithArgs(true, 70000.0, now, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	alert, err := store.RecordKPIEvaluation(context.Background(), rule, 70000, now)
+	require.NoError(t, err)
+	assert.Nil(t, alert)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMeasureRevenueDrop(t *testing.T) {
+	ctx := context.Background()
+	store, mock := newMockStore(t)
+	now := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	april := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("FROM financial_transactions").WithArgs(april.AddDate(0, -1, 0), april).
+		WillReturnRows(sqlmock.NewRows([]string{"revenue"}).AddRow(8000.0))
+	mock.ExpectQuery("FROM financial_transactions").WithArgs(april, may).
+		WillReturnRows(sqlmock.NewRows([]string{"revenue"}).AddRow(6800.0))
+
+	value, ok, err := store.MeasureKPI(ctx, models.KPIRevenueDropPct, now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 15.0, value)
+
+	mock.ExpectQuery("FROM financial_transactions").WillReturnRows(sqlmock.NewRows([]string{"revenue"}).AddRow(0.0))
+	_, ok, err = store.MeasureKPI(ctx, models.KPIRevenueDropPct, now)
+	require.NoError(t, err)
+	assert.False(t, ok, "A drop from a month without revenue has no value")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
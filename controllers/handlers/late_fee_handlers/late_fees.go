@@ -0,0 +1,221 @@
+package late_fee_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/latefees"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// LateFeeHandler provides HTTP handlers for late fee rules and charges.
+type LateFeeHandler struct {
+	Service *latefees.Service
+}
+
+// RegisterRoutes maps late fee routes to their respective handler functions. The router is
+// expected to be protected with middleware.JWTAuth and limited to accountants and admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The late fee service.
+func RegisterRoutes(router *mux.Router, service *latefees.Service) {
+	handler := &LateFeeHandler{Service: service}
+
+	router.HandleFunc("/rules", handler.ListRules).Methods("GET")
+	router.HandleFunc("/rules", handler.CreateRule).Methods("POST")
+	router.HandleFunc("/rules/{id:[0-9]+}", handler.UpdateRule).Methods("PUT")
+	router.HandleFunc("/rules/{id:[0-9]+}", handler.DeleteRule).Methods("DELETE")
+	router.HandleFunc("/preview", handler.Preview).Methods("GET")
+	router.HandleFunc("/runs", handler.Run).Methods("POST")
+	router.HandleFunc("/charges", handler.ListCharges).Methods("GET")
+}
+
+// ListRules returns every late fee rule.
+//
+// HTTP Method: GET
+// URL Path: /late_fees/rules
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of LateFeeRules in JSON.
+//   - Status Code: 500 (Internal Server Error) if the rules cannot be read.
+func (h *LateFeeHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.Service.Rules(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load late fee rules")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rules)
+}
+
+// CreateRule adds a late fee rule, for one customer or for all customers without a rule of
+// their own.
+//
+// HTTP Method: POST
+// URL Path: /late_fees/rules
+//
+// Request Body:
+//   - JSON object with "name", optionally "customer_id", "method" ("interest" or "flat"),
+//     "payment_terms_days", "grace_days", "annual_rate" (percent, for interest) or
+//     "flat_fee", "minimum_balance" and "active".
+//
+// Response:
+//   - Status Code: 201 (Created) with the LateFeeRule in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the name is taken or the customer already has a rule.
+//   - Status Code: 422 (Unprocessable Entity) if the rule is incomplete.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be saved.
+func (h *LateFeeHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.LateFeeRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.CreateRule(r.Context(), &rule, actor); err != nil {
+		httperr.Write(w, err, "Failed to create late fee rule")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, rule)
+}
+
+// UpdateRule replaces a late fee rule. Fees already charged are not changed.
+//
+// HTTP Method: PUT
+// URL Path: /late_fees/rules/{id}
+//
+// Request Body:
+//   - JSON object as for CreateRule.
+//
+// Response:
+//   - Status Code: 200 (OK) with the LateFeeRule in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the rule does not exist.
+//   - Status Code: 409 (Conflict) if the name is taken or the customer already has a rule.
+//   - Status Code: 422 (Unprocessable Entity) if the rule is incomplete.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be saved.
+func (h *LateFeeHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.LateFeeRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	rule.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.UpdateRule(r.Context(), &rule, actor); err != nil {
+		httperr.Write(w, err, "Failed to update late fee rule")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rule)
+}
+
+// DeleteRule removes a late fee rule. Fees already charged are not changed.
+//
+// HTTP Method: DELETE
+// URL Path: /late_fees/rules/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the rule was removed.
+//   - Status Code: 404 (Not Found) if the rule does not exist.
+//   - Status Code: 500 (Internal Server Error) if the rule cannot be removed.
+func (h *LateFeeHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.DeleteRule(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete late fee rule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Preview shows the late fees a run would charge now, without charging them.
+//
+// HTTP Method: GET
+// URL Path: /late_fees/preview
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of LateFeeCharges in JSON.
+//   - Status Code: 500 (Internal Server Error) if the invoices cannot be read.
+func (h *LateFeeHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	charges, err := h.Service.Preview(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to preview late fees")
+		return
+	}
+	respond.JSON(w, http.StatusOK, charges)
+}
+
+// Run charges the late fees due now, each on a posted penalty invoice. It does what the
+// daily run does, for use after changing rules.
+//
+// HTTP Method: POST
+// URL Path: /late_fees/runs
+//
+// Response:
+//   - Status Code: 200 (OK) with the list of LateFeeCharges made in JSON.
+//   - Status Code: 500 (Internal Server Error) if any fee cannot be charged; the others are.
+func (h *LateFeeHandler) Run(w http.ResponseWriter, r *http.Request) {
+	charges, err := h.Service.Run(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to charge late fees")
+		return
+	}
+	respond.JSON(w, http.StatusOK, charges)
+}
+
+// ListCharges lists the late fees charged, newest first.
+//
+// HTTP Method: GET
+// URL Path: /late_fees/charges?invoice_id=7 (invoice_id is optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of LateFeeCharges in JSON.
+//   - Status Code: 500 (Internal Server Error) if the charges cannot be read.
+func (h *LateFeeHandler) ListCharges(w http.ResponseWriter, r *http.Request) {
+	invoiceID, _ := strconv.Atoi(r.URL.Query().Get("invoice_id"))
+	charges, err := h.Service.Charges(r.Context(), invoiceID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load late fees")
+		return
+	}
+	respond.JSON(w, http.StatusOK, charges)
+}
+
+// Statement returns a customer's statement: posted invoices, late fees, payments and
+// applied deposits in a period, with the opening, running and closing balances.
+//
+// HTTP Method: GET
+// URL Path: /customers/{id}/statement?from=2024-05-01&to=2024-05-31 (both optional; the
+// current month to date by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with the CustomerStatement in JSON.
+//   - Status Code: 404 (Not Found) if the customer does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if a date is invalid or from is after to.
+//   - Status Code: 500 (Internal Server Error) if the statement cannot be read.
+func (h *LateFeeHandler) Statement(w http.ResponseWriter, r *http.Request) {
+	customerID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var from, to time.Time
+	for name, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := r.URL.Query().Get(name); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				httperr.Write(w, models.Invalid("invalid %s, expected YYYY-MM-DD", name), "Failed to load statement")
+				return
+			}
+			*date = parsed
+		}
+	}
+	statement, err := h.Service.Statement(r.Context(), customerID, from, to)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load statement")
+		return
+	}
+	respond.JSON(w, http.StatusOK, statement)
+}
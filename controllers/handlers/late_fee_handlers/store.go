@@ -0,0 +1,344 @@
+package late_fee_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/controllers/latefees"
+	"erp/models"
+)
+
+// DBLateFeeStore provides SQL-backed methods for late fee rules and charges.
+type DBLateFeeStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// ruleColumns are the columns scanned by scanRule.
+const ruleColumns = `id, name, customer_id, method, payment_terms_days, grace_days, annual_rate, flat_fee,
+	minimum_balance, active, updated_by, updated_at`
+
+// scanRule reads a row selected with ruleColumns.
+func scanRule(row interface{ Scan(...interface{}) error }) (*models.LateFeeRule, error) {
+	var rule models.LateFeeRule
+	var customerID sql.NullInt64
+	if err := row.Scan(&rule.ID, &rule.Name, &customerID, &rule.Method, &rule.PaymentTermsDays, &rule.GraceDays,
+		&rule.AnnualRate, &rule.FlatFee, &rule.MinimumBalance, &rule.Active, &rule.UpdatedBy, &rule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if customerID.Valid {
+		id := int(customerID.Int64)
+		rule.CustomerID = &id
+	}
+	return &rule, nil
+}
+
+// duplicateRule converts a unique violation on the rule name or customer to a conflict.
+func duplicateRule(err error, rule *models.LateFeeRule) error {
+	if !models.IsUniqueViolation(err) {
+		return err
+	}
+	if models.IsUniqueViolation(err, "idx_late_fee_rules_customer") {
+		if rule.CustomerID == nil {
+			return models.Conflict("a late fee rule for all customers already exists")
+		}
+		return models.Conflict("customer %d already has a late fee rule", *rule.CustomerID)
+	}
+	return models.Conflict("a late fee rule named %q already exists", rule.Name)
+}
+
+// CreateLateFeeRule saves a new late fee rule.
+//
+// Parameters:
+//   - rule: The validated rule; its ID is set.
+//
+// Returns:
+//   - error: A models.Conflict error if the name is taken or the customer already has a
+//     rule, or an error if the insert fails.
+func (store *DBLateFeeStore) CreateLateFeeRule(ctx context.Context, rule *models.LateFeeRule) error {
+	err := store.DB.QueryRowContext(ctx,
+		`INSERT INTO late_fee_rules (name, customer_id, method, payment_terms_days, grace_days, annual_rate, flat_fee,
+		     minimum_balance, active, updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+		rule.Name, rule.CustomerID, rule.Method, rule.PaymentTermsDays, rule.GraceDays, rule.AnnualRate, rule.FlatFee,
+		rule.MinimumBalance, rule.Active, rule.UpdatedBy, rule.UpdatedAt,
+	).Scan(&rule.ID)
+	return duplicateRule(err, rule)
+}
+
+// GetLateFeeRules retrieves every late fee rule, ordered by name.
+//
+// Returns:
+//   - []models.LateFeeRule: The rules.
+//   - error: An error if the query fails.
+func (store *DBLateFeeStore) GetLateFeeRules(ctx context.Context) ([]models.LateFeeRule, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT `+ruleColumns+` FROM late_fee_rules ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.LateFeeRule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateLateFeeRule replaces a late fee rule.
+//
+// Parameters:
+//   - rule: The validated rule with its ID.
+//
+// Returns:
+//   - error: models.ErrNotFound if the rule does not exist, a models.Conflict error if the
+//     name is taken or the customer already has a rule, or an error if the update fails.
+func (store *DBLateFeeStore) UpdateLateFeeRule(ctx context.Context, rule *models.LateFeeRule) error {
+	result, err := store.DB.ExecContext(ctx,
+		`UPDATE late_fee_rules SET name = $1, customer_id = $2, method = $3, payment_terms_days = $4, grace_days = $5,
+		     annual_rate = $6, flat_fee = $7, minimum_balance = $8, active = $9, updated_by = $10, updated_at = $11
+		 WHERE id = $12`,
+		rule.Name, rule.CustomerID, rule.Method, rule.PaymentTermsDays, rule.GraceDays, rule.AnnualRate, rule.FlatFee,
+		rule.MinimumBalance, rule.Active, rule.UpdatedBy, rule.UpdatedAt, rule.ID,
+	)
+	if err != nil {
+		return duplicateRule(err, rule)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("late fee rule %d not found", rule.ID)
+	}
+	return nil
+}
+
+// DeleteLateFeeRule removes a late fee rule. The fees it charged are kept.
+//
+// Parameters:
+//   - id: The ID of the rule.
+//
+// Returns:
+//   - error: models.ErrNotFound if the rule does not exist, or an error if the delete fails.
+func (store *DBLateFeeStore) DeleteLateFeeRule(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, `DELETE FROM late_fee_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("late fee rule %d not found", id)
+	}
+	return nil
+}
+
+// GetOverdueInvoices retrieves the posted invoices that still have a balance, with the date
+// they were posted and the late fees charged for them so far. Penalty invoices are left
+// out, so late fees are not charged on late fees, and so are invoices with an open dispute.
+// Amounts allowed on resolved disputes reduce the balance.
+//
+// Returns:
+//   - []models.OverdueInvoice: The invoices, oldest first.
+//   - error: An error if the query fails.
+func (store *DBLateFeeStore) GetOverdueInvoices(ctx context.Context) ([]models.OverdueInvoice, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`WITH open_invoices AS (
+		     SELECT i.id, COALESCE(i.customer_id, 0) AS customer_id,
+		         (SELECT MIN(transaction_date) FROM financial_transactions
+		          WHERE invoice_id = i.id AND account_type = 'accounts_receivable') AS posted_on,
+		         i.amount - (SELECT COALESCE(SUM(amount), 0) FROM payments WHERE invoice_id = i.id AND deleted_at IS NULL)
+		             - (SELECT COALESCE(SUM(amount), 0) FROM customer_deposit_applications WHERE invoice_id = i.id)
+		             - (SELECT COALESCE(SUM(resolved_amount), 0) FROM invoice_disputes WHERE invoice_id = i.id) AS balance
+		     FROM invoices i
+		     WHERE i.status = $1 AND i.deleted_at IS NULL
+		         AND NOT EXISTS (SELECT 1 FROM late_fee_charges WHERE penalty_invoice_id = i.id)
+		         AND NOT EXISTS (SELECT 1 FROM invoice_disputes WHERE invoice_id = i.id AND status = $4)
+		 )
+		 SELECT o.id, o.customer_id, o.posted_on, o.balance,
+		     EXISTS (SELECT 1 FROM late_fee_charges WHERE invoice_id = o.id AND method = $2),
+		     (SELECT MAX(period_to) FROM late_fee_charges WHERE invoice_id = o.id AND method = $3)
+		 FROM open_invoices o
+		 WHERE o.posted_on IS NOT NULL AND o.balance > 0
+		 ORDER BY o.posted_on, o.id`,
+		models.InvoiceStatusPosted, models.LateFeeFlat, models.LateFeeInterest, models.DisputeOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invoices := []models.OverdueInvoice{}
+	for rows.Next() {
+		var invoice models.OverdueInvoice
+		var through sql.NullTime
+		if err := rows.Scan(&invoice.InvoiceID, &invoice.CustomerID, &invoice.PostedOn, &invoice.Balance,
+			&invoice.FlatCharged, &through); err != nil {
+			return nil, err
+		}
+		if through.Valid {
+			invoice.InterestThrough = &through.Time
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, rows.Err()
+}
+
+// ChargeLateFee bills a late fee in one transaction: a penalty invoice is issued to the
+// customer already posted, the receivable is debited and late fee income credited, and the
+// charge is recorded.
+//
+// Parameters:
+//   - charge: The fee; its ID and penalty invoice are set.
+//
+// Returns:
+//   - error: A models.Conflict error if the fee was charged already, or an error if it
+//     cannot be charged.
+func (store *DBLateFeeStore) ChargeLateFee(ctx context.Context, charge *models.LateFeeCharge) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO invoices (sales_order_id, customer_id, amount, status) VALUES (NULL, NULLIF($1, 0), $2, $3) RETURNING id`,
+		charge.CustomerID, charge.Amount, models.InvoiceStatusPosted,
+	).Scan(&charge.PenaltyInvoiceID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO late_fee_charges (invoice_id, customer_id, penalty_invoice_id, rule_id, method, balance, period_from,
+		     period_to, amount, charged_at)
+		 VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		charge.InvoiceID, charge.CustomerID, charge.PenaltyInvoiceID, charge.RuleID, charge.Method, charge.Balance,
+		charge.PeriodFrom, charge.PeriodTo, charge.Amount, charge.ChargedAt,
+	).Scan(&charge.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("the late fee for invoice %d has been charged already", charge.InvoiceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Late fee on invoice #%d", charge.InvoiceID)
+	for _, transaction := range []models.FinancialTransaction{
+		{AccountType: "accounts_receivable", Amount: charge.Amount},
+		{AccountType: latefees.IncomeAccount, Amount: -charge.Amount},
+	} {
+		transaction.TransactionDate, transaction.Description = charge.ChargedAt, description
+		transaction.InvoiceID = &charge.PenaltyInvoiceID
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &transaction); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetLateFeeCharges lists late fees charged, newest first.
+//
+// Parameters:
+//   - invoiceID: The invoice charged for, or 0 for every invoice.
+//
+// Returns:
+//   - []models.LateFeeCharge: The charges.
+//   - error: An error if the query fails.
+func (store *DBLateFeeStore) GetLateFeeCharges(ctx context.Context, invoiceID int) ([]models.LateFeeCharge, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, invoice_id, COALESCE(customer_id, 0), penalty_invoice_id, rule_id, method, balance, period_from,
+		     period_to, amount, charged_at
+		 FROM late_fee_charges WHERE $1 = 0 OR invoice_id = $1 ORDER BY charged_at DESC, id DESC`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	charges := []models.LateFeeCharge{}
+	for rows.Next() {
+		var charge models.LateFeeCharge
+		var from, to sql.NullTime
+		if err := rows.Scan(&charge.ID, &charge.InvoiceID, &charge.CustomerID, &charge.PenaltyInvoiceID, &charge.RuleID,
+			&charge.Method, &charge.Balance, &from, &to, &charge.Amount, &charge.ChargedAt); err != nil {
+			return nil, err
+		}
+		if from.Valid {
+			charge.PeriodFrom = &from.Time
+		}
+		if to.Valid {
+			charge.PeriodTo = &to.Time
+		}
+		charges = append(charges, charge)
+	}
+	return charges, rows.Err()
+}
+
+// GetCustomerStatement retrieves a customer's posted invoices, payments, applied deposits
+// and the credit notes and adjustments resolving disputes, up to and including a day. An invoice is dated the day it was posted; penalty invoices
+// are listed as late fees.
+//
+// Parameters:
+//   - customerID: The ID of the customer.
+//   - to: The last day of the statement.
+//
+// Returns:
+//   - *models.CustomerStatement: The customer and the lines, oldest first.
+//   - error: models.ErrNotFound if the customer does not exist, or an error if a query fails.
+func (store *DBLateFeeStore) GetCustomerStatement(ctx context.Context, customerID int, to time.Time) (*models.CustomerStatement, error) {
+	statement := &models.CustomerStatement{CustomerID: customerID, Lines: []models.StatementLine{}}
+	err := store.DB.QueryRowContext(ctx, `SELECT name FROM customers WHERE id = $1`, customerID).Scan(&statement.CustomerName)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("customer %d not found", customerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT day, type, invoice_id, description, debit, credit FROM (
+		     SELECT (SELECT MIN(transaction_date) FROM financial_transactions
+		             WHERE invoice_id = i.id AND account_type = 'accounts_receivable') AS day,
+		         CASE WHEN c.id IS NULL THEN $3 ELSE $4 END AS type, i.id AS invoice_id,
+		         CASE WHEN c.id IS NULL THEN 'Invoice #' || i.id
+		              ELSE 'Late fee on invoice #' || c.invoice_id END AS description,
+		         i.amount AS debit, 0 AS credit
+		     FROM invoices i LEFT JOIN late_fee_charges c ON c.penalty_invoice_id = i.id
+		     WHERE i.customer_id = $1 AND i.status = $7 AND i.deleted_at IS NULL
+		     UNION ALL
+		     SELECT p.payment_date, $5, i.id, 'Payment on invoice #' || i.id, 0, p.amount
+		     FROM payments p JOIN invoices i ON i.id = p.invoice_id
+		     WHERE i.customer_id = $1 AND p.deleted_at IS NULL
+		     UNION ALL
+		     SELECT a.applied_at::date, $6, i.id, 'Deposit applied to invoice #' || i.id, 0, a.amount
+		     FROM customer_deposit_applications a JOIN invoices i ON i.id = a.invoice_id
+		     WHERE i.customer_id = $1
+		     UNION ALL
+		     SELECT d.resolved_at::date, d.resolution, i.id,
+		         CASE WHEN d.credit_note_id IS NULL THEN 'Adjustment on invoice #' || i.id
+		              ELSE 'Credit note ' || d.credit_note_id || ' on invoice #' || i.id END, 0, d.resolved_amount
+		     FROM invoice_disputes d JOIN invoices i ON i.id = d.invoice_id
+		     WHERE i.customer_id = $1 AND d.resolved_amount > 0
+		 ) lines
+		 WHERE day IS NOT NULL AND day <= $2
+		 ORDER BY day, credit, invoice_id`,
+		customerID, to, models.StatementInvoice, models.StatementLateFee, models.StatementPayment,
+		models.StatementDeposit, models.InvoiceStatusPosted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.StatementLine
+		if err := rows.Scan(&line.Date, &line.Type, &line.InvoiceID, &line.Description, &line.Debit, &line.Credit); err != nil {
+			return nil, err
+		}
+		statement.Lines = append(statement.Lines, line)
+	}
+	return statement, rows.Err()
+}
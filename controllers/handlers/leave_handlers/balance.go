@@ -0,0 +1,63 @@
+package leave_handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/rbac"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// Entitlements reads leave balances. It is satisfied by *leave.Service.
+type Entitlements interface {
+	Balance(ctx context.Context, userID int, email string, privileged bool) ([]models.LeaveBalance, error)
+}
+
+// PermissionChecker reports whether a role grants one of the required permissions,
+// typically the rbac service.
+type PermissionChecker interface {
+	Allowed(ctx context.Context, role string, required ...string) (bool, error)
+}
+
+// EntitlementHandler provides the HTTP handler for reading leave balances.
+type EntitlementHandler struct {
+	Entitlements Entitlements
+	Access       PermissionChecker // Decides who may read every employee's balance
+}
+
+// GetBalance returns an employee's balance of every leave type for the current year: the
+// entitlement accrued so far, adjustments, approved and pending leave, and the days still
+// available. Employees may read their own balance; HR everyone's.
+//
+// HTTP Method: GET
+// URL Path: /leaves/balance?user_id=1
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of LeaveBalances in JSON.
+//   - Status Code: 403 (Forbidden) if the balance is another employee's.
+//   - Status Code: 404 (Not Found) if the user does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if user_id is missing.
+//   - Status Code: 500 (Internal Server Error) if the balance cannot be read.
+func (h *EntitlementHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(r.URL.Query().Get("user_id"))
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	privileged := false
+	if role != "" {
+		var err error
+		if privileged, err = h.Access.Allowed(r.Context(), role, rbac.HR); err != nil {
+			httperr.Write(w, err, "Failed to check permissions")
+			return
+		}
+	}
+	balances, err := h.Entitlements.Balance(r.Context(), userID, email, privileged)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load leave balance")
+		return
+	}
+	respond.JSON(w, http.StatusOK, balances)
+}
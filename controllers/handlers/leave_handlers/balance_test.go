@@ -0,0 +1,53 @@
+package leave_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEntitlements lets user 1, ana@example.com, read their own balance.
+type fakeEntitlements struct{}
+
+func (fakeEntitlements) Balance(ctx context.Context, userID int, email string, privileged bool) ([]models.LeaveBalance, error) {
+	if !privileged && !(userID == 1 && email == "ana@example.com") {
+		return nil, models.PermissionDenied("the leave balance of user %d belongs to another employee", userID)
+	}
+	return []models.LeaveBalance{{UserID: userID, LeaveType: "Vacation", Year: 2025, Accrued: 20, Available: 20}}, nil
+}
+
+// hrOnly grants the HR permission to the HR role only.
+type hrOnly struct{}
+
+func (hrOnly) Allowed(ctx context.Context, role string, required ...string) (bool, error) {
+	return role == "HR", nil
+}
+
+func TestGetBalance(t *testing.T) {
+	handler := &EntitlementHandler{Entitlements: fakeEntitlements{}, Access: hrOnly{}}
+	get := func(userID, email, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/leaves/balance?user_id="+userID, nil)
+		ctx := context.WithValue(req.Context(), middleware.UserEmail, email)
+		ctx = context.WithValue(ctx, middleware.UserRole, role)
+		rr := httptest.NewRecorder()
+		handler.GetBalance(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	rr := get("1", "ana@example.com", "Employee")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var balances []models.LeaveBalance
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&balances))
+	assert.Equal(t, 20.0, balances[0].Available)
+
+	assert.Equal(t, http.StatusForbidden, get("2", "ana@example.com", "Employee").Code)
+	assert.Equal(t, http.StatusOK, get("2", "hr@example.com", "HR").Code)
+}
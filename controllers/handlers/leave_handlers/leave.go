@@ -3,10 +3,17 @@
 package leave_handlers
 
 import (
-	"encoding/json"
+	"context"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/rbac"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
 	"erp/models"
-	"fmt"
+	"errors"
 	"net/http"
+	"time"
 )
 
 // LeaveStore defines the interface for database operations related to leave requests.
@@ -17,15 +24,77 @@ type LeaveStore interface {
 	//   - leave: A pointer to the Leave object containing the details of the leave request.
 	// Returns:
 	//   - error: An error if the insertion fails, otherwise nil.
-	CreateLeave(leave *models.Leave) error
+	CreateLeave(ctx context.Context, leave *models.Leave) error
 
-	// UpdateLeaveStatus updates the status of an existing leave request.
+	// UpdateLeaveStatus updates the status of an existing leave request if it is still at the
+	// given version.
 	// Parameters:
 	//   - id: The unique identifier of the leave request.
 	//   - status: A string representing the new status (e.g., "Approved", "Rejected").
+	//   - version: The version of the request the decision is based on.
 	// Returns:
-	//   - error: An error if the update fails, otherwise nil.
-	UpdateLeaveStatus(id int, status string) error
+	//   - int: The new version of the request.
+	//   - error: A models.Conflict error if the request was changed since the version, or an
+	//     error if the update fails, otherwise nil.
+	UpdateLeaveStatus(ctx context.Context, id int, status string, version int) (int, error)
+
+	// GetUserIDByEmail returns the ID of the user with the given email.
+	// Parameters:
+	//   - email: The email of the user, typically the signed-in one.
+	// Returns:
+	//   - error: models.ErrNotFound if no user has the email.
+	GetUserIDByEmail(ctx context.Context, email string) (int, error)
+}
+
+// CreateLeaveRequest is the request body for requesting leave. The ID and status are set by
+// the server.
+type CreateLeaveRequest struct {
+	UserID    int       `json:"user_id"`
+	LeaveType string    `json:"leave_type"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+}
+
+// Leave returns the leave request described by the request body.
+func (req CreateLeaveRequest) Leave() models.Leave {
+	return models.Leave{
+		UserID:    req.UserID,
+		LeaveType: req.LeaveType,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+	}
+}
+
+// Validate checks that the request names the leave type, and the employee if any, and that
+// the leave does not end before it starts.
+func (req CreateLeaveRequest) Validate() error {
+	var c validation.Checker
+	if req.UserID != 0 {
+		c.ID("user_id", req.UserID)
+	}
+	c.Required("leave_type", req.LeaveType)
+	c.Date("start_date", req.StartDate)
+	c.Date("end_date", req.EndDate)
+	c.Ordered("start_date", req.StartDate, "end_date", req.EndDate)
+	return c.Err()
+}
+
+// LeaveStatuses are the statuses a leave request can be given.
+var LeaveStatuses = []string{"Pending", statusApproved, "Rejected"}
+
+// UpdateLeaveStatusRequest is the request body for deciding a leave request.
+type UpdateLeaveStatusRequest struct {
+	ID      int    `json:"id"`
+	Status  string `json:"status"`
+	Version int    `json:"version"` // The version decided, unless sent in If-Match
+}
+
+// Validate checks that the request names a leave request and one of LeaveStatuses.
+func (req UpdateLeaveStatusRequest) Validate() error {
+	var c validation.Checker
+	c.ID("id", req.ID)
+	c.OneOf("status", req.Status, LeaveStatuses)
+	return c.Err()
 }
 
 // CreateLeaveHandler creates a new leave request in the system.
@@ -42,21 +111,32 @@ type LeaveStore interface {
 //
 // Details:
 //   - The status of the new leave request is automatically set to "Pending".
+//   - Without a user_id, the leave is requested for the signed-in user. Only HR may request
+//     leave for another employee; others are rejected with HTTP 403 (Forbidden).
+//   - A body that is not JSON is rejected with HTTP 400 (Bad Request), and one without the
+//     leave type or dates, or ending before it starts, with HTTP 422 listing the fields.
+//   - Requests for a leave type with a policy are rejected with HTTP 422 (Unprocessable
+//     Entity) when the employee's balance, less their other pending requests, does not cover them.
 //   - On success, it responds with HTTP 201 (Created) and the leave request details in JSON format.
 //   - On failure, it responds with an appropriate HTTP error status.
 //
 // Parameters:
 //   - store: An implementation of the LeaveStore interface to handle database operations.
+//   - access: Decides who may request leave for other employees, typically the rbac service.
 //
 // Returns:
 //   - http.HandlerFunc: The HTTP handler function for creating leave requests.
-func CreateLeaveHandler(store LeaveStore) http.HandlerFunc {
+func CreateLeaveHandler(store LeaveStore, access PermissionChecker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var leave models.Leave
+		var req CreateLeaveRequest
 
-		// Parse the JSON body from the request
-		if err := json.NewDecoder(r.Body).Decode(&leave); err != nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		// Parse and check the JSON body from the request
+		if !validation.Decode(w, r, &req) {
+			return
+		}
+		leave := req.Leave()
+		if err := requestFor(r, store, access, &leave); err != nil {
+			httperr.Write(w, err, "Failed to create leave")
 			return
 		}
 
@@ -64,15 +144,44 @@ func CreateLeaveHandler(store LeaveStore) http.HandlerFunc {
 		leave.Status = "Pending"
 
 		// Attempt to create the leave in the database
-		if err := store.CreateLeave(&leave); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create leave: %v", err), http.StatusInternalServerError)
+		if err := store.CreateLeave(r.Context(), &leave); err != nil {
+			httperr.Write(w, err, "Failed to create leave")
 			return
 		}
 
 		// Respond with the created leave request and a 201 status code.
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(leave)
+		respond.JSON(w, http.StatusCreated, leave)
+	}
+}
+
+// requestFor sets the employee a leave request is for: the signed-in user unless the request
+// names another employee, which only HR may do.
+func requestFor(r *http.Request, store LeaveStore, access PermissionChecker, leave *models.Leave) error {
+	caller := 0
+	if email, _ := middleware.GetUserEmailFromContext(r.Context()); email != "" {
+		var err error
+		if caller, err = store.GetUserIDByEmail(r.Context(), email); err != nil && !errors.Is(err, models.ErrNotFound) {
+			return err
+		}
 	}
+	if leave.UserID == 0 {
+		if caller == 0 {
+			return models.Invalid("user_id is required")
+		}
+		leave.UserID = caller
+	}
+	if leave.UserID == caller {
+		return nil
+	}
+
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	if role != "" {
+		allowed, err := access.Allowed(r.Context(), role, rbac.HR)
+		if err != nil || allowed {
+			return err
+		}
+	}
+	return models.PermissionDenied("only HR may request leave for user %d", leave.UserID)
 }
 
 // UpdateLeaveStatusHandler updates the status of an existing leave request.
@@ -82,11 +191,19 @@ func CreateLeaveHandler(store LeaveStore) http.HandlerFunc {
 //
 //	{
 //	  "id": 1,
-//	  "status": "Approved"
+//	  "status": "Approved",
+//	  "version": 1
 //	}
 //
 // Details:
-//   - On success, it responds with HTTP 200 (OK) and a success message.
+//   - The status must be one of LeaveStatuses; other values are rejected with HTTP 422.
+//   - The version of the request the decision is based on may be sent as an If-Match header
+//     instead. Without one the decision is rejected with HTTP 428 (Precondition Required),
+//     and if the request was changed since, with HTTP 409 (Conflict).
+//   - Approving a request deducts its days from the employee's balance; it is rejected with
+//     HTTP 422 (Unprocessable Entity) if the balance no longer covers them.
+//   - On success, it responds with HTTP 200 (OK) and the leave ID, new status and new version
+//     in JSON format, with the version as the ETag.
 //   - On failure, it responds with an appropriate HTTP error status.
 //
 // Parameters:
@@ -97,25 +214,26 @@ func CreateLeaveHandler(store LeaveStore) http.HandlerFunc {
 func UpdateLeaveStatusHandler(store LeaveStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract the leave ID and status from the request.
-		var requestData struct {
-			ID     int    `json:"id"`
-			Status string `json:"status"`
+		var requestData UpdateLeaveStatusRequest
+		if !validation.Decode(w, r, &requestData) {
+			return
 		}
 
-		// Parse the JSON body from the request.
-		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		version, ok := versioning.Expected(w, r, requestData.Version)
+		if !ok {
 			return
 		}
 
 		// Attempt to update the leave status in the database.
-		if err := store.UpdateLeaveStatus(requestData.ID, requestData.Status); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to update leave status: %v", err), http.StatusInternalServerError)
+		version, err := store.UpdateLeaveStatus(r.Context(), requestData.ID, requestData.Status, version)
+		if err != nil {
+			httperr.Write(w, err, "Failed to update leave status")
 			return
 		}
 
-		// Respond with a success message.
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Leave status updated successfully")
+		// Respond with the updated status.
+		requestData.Version = version
+		versioning.SetETag(w, version)
+		respond.JSON(w, http.StatusOK, requestData)
 	}
 }
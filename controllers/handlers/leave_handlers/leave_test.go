@@ -2,13 +2,14 @@ package leave_handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"erp/controllers/middleware"
 	"erp/models"
 
 	"github.com/stretchr/testify/assert"
@@ -21,6 +22,23 @@ type MockLeaveStore struct {
 	nextID int                   // Counter to assign unique IDs to leave requests.
 }
 
+// GetUserIDByEmail knows ana@example.com as user 1 and hr@example.com as user 9.
+func (m *MockLeaveStore) GetUserIDByEmail(ctx context.Context, email string) (int, error) {
+	switch email {
+	case "ana@example.com":
+		return 1, nil
+	case "hr@example.com":
+		return 9, nil
+	}
+	return 0, models.ErrNotFound
+}
+
+// signedIn returns req as sent by the user with the email and role.
+func signedIn(req *http.Request, email, role string) *http.Request {
+	ctx := context.WithValue(req.Context(), middleware.UserEmail, email)
+	return req.WithContext(context.WithValue(ctx, middleware.UserRole, role))
+}
+
 // CreateLeave adds a new leave request to the mock store.
 // Assigns a unique ID to the leave request.
 //
@@ -29,9 +47,9 @@ type MockLeaveStore struct {
 //
 // Returns:
 //   - error: Always nil as the operation is simulated.
-func (m *MockLeaveStore) CreateLeave(leave *models.Leave) error {
+func (m *MockLeaveStore) CreateLeave(ctx context.Context, leave *models.Leave) error {
 	m.nextID++
-	leave.ID = m.nextID
+	leave.ID, leave.Version = m.nextID, 1
 	m.leaves[leave.ID] = leave
 	return nil
 }
@@ -41,16 +59,22 @@ func (m *MockLeaveStore) CreateLeave(leave *models.Leave) error {
 // Parameters:
 //   - id: The ID of the leave request to update.
 //   - status: The new status of the leave request (e.g., "Approved", "Rejected").
+//   - version: The version the update is based on.
 //
 // Returns:
-//   - error: "leave not found" if the leave ID does not exist.
-func (m *MockLeaveStore) UpdateLeaveStatus(id int, status string) error {
+//   - int: The new version.
+//   - error: models.ErrNotFound if the leave ID does not exist, or a conflict if it is at
+//     another version.
+func (m *MockLeaveStore) UpdateLeaveStatus(ctx context.Context, id int, status string, version int) (int, error) {
 	leave, exists := m.leaves[id]
 	if !exists {
-		return errors.New("leave not found")
+		return 0, models.ErrNotFound
 	}
-	leave.Status = status
-	return nil
+	if leave.Version != version {
+		return 0, models.Conflict("leave request %d is at version %d", id, leave.Version)
+	}
+	leave.Status, leave.Version = status, leave.Version+1
+	return leave.Version, nil
 }
 
 // TestCreateLeaveHandler verifies the CreateLeaveHandler for creating a new leave request.
@@ -62,7 +86,7 @@ func TestCreateLeaveHandler(t *testing.T) {
 
 	// Initialize the mock store and handler.
 	store := &MockLeaveStore{leaves: make(map[int]*models.Leave)}
-	handler := CreateLeaveHandler(store)
+	handler := CreateLeaveHandler(store, hrOnly{})
 
 	// Create a sample leave request.
 	leave := models.Leave{
@@ -79,7 +103,7 @@ func TestCreateLeaveHandler(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler.
-	handler(rr, req)
+	handler(rr, signedIn(req, "ana@example.com", "Employee"))
 
 	// Validate the response status code.
 	assert.Equal(t, http.StatusCreated, rr.Code)
@@ -93,6 +117,63 @@ func TestCreateLeaveHandler(t *testing.T) {
 	assert.Equal(t, leave.LeaveType, createdLeave.LeaveType) // Verify the LeaveType matches the input.
 }
 
+// TestCreateLeaveHandlerRejectsInvalidRequests verifies that leave ending before it starts,
+// or without a leave type, is answered with an error listing the fields and not stored.
+func TestCreateLeaveHandlerRejectsInvalidRequests(t *testing.T) {
+	store := &MockLeaveStore{leaves: make(map[int]*models.Leave)}
+	handler := CreateLeaveHandler(store, hrOnly{})
+
+	body := `{"user_id": 1, "start_date": "2024-11-25T00:00:00Z", "end_date": "2024-11-20T00:00:00Z"}`
+	req, _ := http.NewRequest("POST", "/leaves", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	var response struct {
+		Error struct {
+			Details []models.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(rr.Body).Decode(&response)
+	assert.Equal(t, []models.FieldError{
+		{Field: "leave_type", Message: "is required"},
+		{Field: "end_date", Message: "must not be before start_date"},
+	}, response.Error.Details)
+	assert.Empty(t, store.leaves)
+
+	// Dates must be RFC 3339 times
+	req, _ = http.NewRequest("POST", "/leaves", bytes.NewBufferString(`{"user_id": 1, "start_date": "2024-11-20"}`))
+	rr = httptest.NewRecorder()
+	handler(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+// TestCreateLeaveHandlerForAnotherEmployee verifies that leave is requested for the signed-in
+// user by default, and that only HR may request it for someone else.
+func TestCreateLeaveHandlerForAnotherEmployee(t *testing.T) {
+	store := &MockLeaveStore{leaves: make(map[int]*models.Leave)}
+	handler := CreateLeaveHandler(store, hrOnly{})
+	create := func(body, email, role string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/leaves", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		handler(rr, signedIn(req, email, role))
+		return rr
+	}
+	const dates = `"leave_type": "Vacation", "start_date": "2024-11-20T00:00:00Z", "end_date": "2024-11-22T00:00:00Z"`
+
+	rr := create(`{`+dates+`}`, "ana@example.com", "Employee")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, 1, store.leaves[1].UserID)
+
+	rr = create(`{"user_id": 2, `+dates+`}`, "ana@example.com", "Employee")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Len(t, store.leaves, 1)
+
+	rr = create(`{"user_id": 2, `+dates+`}`, "hr@example.com", "HR")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, 2, store.leaves[2].UserID)
+}
+
 // TestUpdateLeaveStatusHandler verifies the UpdateLeaveStatusHandler for updating the status of a leave request.
 // It checks whether the handler correctly updates the status and responds with 200.
 func TestUpdateLeaveStatusHandler(t *testing.T) {
@@ -112,28 +193,34 @@ func TestUpdateLeaveStatusHandler(t *testing.T) {
 		StartDate: startDate,
 		EndDate:   endDate,
 		Status:    "Pending",
+		Version:   1,
 	}
 
 	// Create a request to update the status of the leave request.
-	updateRequest := struct {
-		ID     int    `json:"id"`
-		Status string `json:"status"`
-	}{
-		ID:     1,
-		Status: "Approved",
+	decide := func(version string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", "/leaves/status", bytes.NewBufferString(`{"id": 1, "status": "Approved"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if version != "" {
+			req.Header.Set("If-Match", version)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
 	}
-	body, _ := json.Marshal(updateRequest)
-	req, _ := http.NewRequest("PUT", "/leaves/status", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-
-	// Record the response.
-	rr := httptest.NewRecorder()
 
-	// Call the handler.
-	handler(rr, req)
+	// A decision must name the version it is based on
+	rr := decide("")
+	assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
 
 	// Validate the response status code.
+	rr = decide(`"1"`)
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"id": 1, "status": "Approved", "version": 2}`, rr.Body.String())
+	assert.Equal(t, `"2"`, rr.Header().Get("ETag"))
+
+	// A second decision based on the same version is refused
+	rr = decide(`"1"`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
 
 	// Validate the updated status in the mock store.
 	assert.Equal(t, "Approved", store.leaves[1].Status) // Check if the status was updated correctly.
@@ -3,7 +3,12 @@
 package leave_handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+
+	"erp/controllers/leave"
+	"erp/controllers/versioning"
 	"erp/models"
 )
 
@@ -13,10 +18,20 @@ type DBLeaveStore struct {
 	DB *sql.DB // DB represents the database connection.
 }
 
+// GetUserIDByEmail returns the ID of the user with the given email, or models.ErrNotFound.
+func (store *DBLeaveStore) GetUserIDByEmail(ctx context.Context, email string) (int, error) {
+	var id int
+	err := store.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", email).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, models.ErrNotFound
+	}
+	return id, err
+}
+
 // CreateLeave inserts a new leave request into the database.
 //
 // Parameters:
-//   - leave: A pointer to the Leave object containing the details of the leave request, including:
+//   - request: A pointer to the Leave object containing the details of the leave request, including:
 //   - UserID: The ID of the user requesting leave.
 //   - LeaveType: The type of leave (e.g., "Vacation", "Sick Leave").
 //   - StartDate: The starting date of the leave.
@@ -24,31 +39,102 @@ type DBLeaveStore struct {
 //   - Status: The status of the leave request (e.g., "Pending").
 //
 // Returns:
-//   - error: An error if the operation fails, otherwise nil.
+//   - error: A validation error if the user has no employee profile or their balance of the
+//     leave type does not cover the request, or an error if the operation fails, otherwise nil.
 //
 // Details:
-//   - This method executes an SQL `INSERT` query to add the leave request to the `leave` table in the database.
-//   - The leave request's details must be valid and conform to the database schema for successful insertion.
-func (store *DBLeaveStore) CreateLeave(leave *models.Leave) error {
-	query := "INSERT INTO leave (user_id, leave_type, start_date, end_date, status) VALUES ($1, $2, $3, $4, $5)"
-	_, err := store.DB.Exec(query, leave.UserID, leave.LeaveType, leave.StartDate, leave.EndDate, leave.Status)
-	return err
+//   - The employee is locked while the balance is checked, so two requests cannot both
+//     spend the same days; see leave.Check.
+//   - The request's ID and version are set once it is inserted into the `leave` table.
+func (store *DBLeaveStore) CreateLeave(ctx context.Context, request *models.Leave) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockEmployee(ctx, tx, request.UserID); err != nil {
+		return err
+	}
+	if err := leave.Check(ctx, tx, request, 0); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO leave (user_id, leave_type, start_date, end_date, status) VALUES ($1, $2, $3, $4, $5) RETURNING id, version`,
+		request.UserID, request.LeaveType, request.StartDate, request.EndDate, request.Status,
+	).Scan(&request.ID, &request.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// UpdateLeaveStatus updates the status of an existing leave request in the database.
+// UpdateLeaveStatus updates the status of an existing leave request in the database if it is
+// still at the given version.
 //
 // Parameters:
 //   - id: The unique identifier of the leave request to be updated.
 //   - status: A string representing the new status of the leave request (e.g., "Approved", "Rejected").
+//   - version: The version of the leave request the decision is based on.
 //
 // Returns:
-//   - error: An error if the operation fails, otherwise nil.
+//   - int: The new version of the leave request.
+//   - error: models.ErrNotFound if the leave request does not exist, a validation error if
+//     it is being approved and the balance no longer covers it, a models.Conflict error if it
+//     was changed since the version, or an error if the operation fails.
 //
 // Details:
-//   - This method executes an SQL `UPDATE` query to modify the `status` column of the specified leave request in the `leave` table.
-//   - The leave request must exist in the database for the update to succeed.
-func (store *DBLeaveStore) UpdateLeaveStatus(id int, status string) error {
-	query := "UPDATE leave SET status = $1 WHERE id = $2"
-	_, err := store.DB.Exec(query, status, id)
+//   - Approving a request deducts its days from the employee's balance: they stop being held
+//     as pending and count as taken. The balance is checked again first, as policies and
+//     adjustments may have changed since the request was made.
+func (store *DBLeaveStore) UpdateLeaveStatus(ctx context.Context, id int, status string, version int) (int, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var request models.Leave
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, user_id, leave_type, start_date, end_date, COALESCE(status, '') FROM leave WHERE id = $1 FOR UPDATE`, id,
+	).Scan(&request.ID, &request.UserID, &request.LeaveType, &request.StartDate, &request.EndDate, &request.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, models.NotFound("leave request %d not found", id)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if status == statusApproved && request.Status != statusApproved {
+		if err := lockEmployee(ctx, tx, request.UserID); err != nil {
+			return 0, err
+		}
+		if err := leave.Check(ctx, tx, &request, id); err != nil {
+			return 0, err
+		}
+	}
+	err = tx.QueryRowContext(ctx, "UPDATE leave SET status = $1 WHERE id = $2 AND version = $3 RETURNING version", status, id, version).
+		Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, versioning.Stale(ctx, tx, "SELECT version FROM leave WHERE id = $1", "leave request", id, version)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// statusApproved is the status of approved leave requests.
+const statusApproved = "Approved"
+
+// lockEmployee locks the employee profile of a user until the transaction ends.
+func lockEmployee(ctx context.Context, tx *sql.Tx, userID int) error {
+	var employeeID int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM employees WHERE user_id = $1 FOR UPDATE`, userID).Scan(&employeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Invalid("user %d has no employee profile", userID)
+	}
 	return err
 }
@@ -0,0 +1,142 @@
+package leave_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateLeaveRejectsUnknownEmployees(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBLeaveStore{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM employees WHERE user_id = $1 FOR UPDATE")).
+		WithArgs(9).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	day := time.Date(2024, 11, 20, 0, 0, 0, 0, time.UTC)
+	err = store.CreateLeave(context.Background(), &models.Leave{UserID: 9, LeaveType: "Vacation", StartDate: day, EndDate: day, Status: "Pending"})
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	assert.EqualError(t, err, "user 9 has no employee profile")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectBalance expects the employee lock and the balance queries of 2025 for user 1, who
+// has 20 days of vacation, 12 taken and the given days pending.
+func expectBalance(mock sqlmock.Sqlmock, excludeID int, pending float64) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM employees WHERE user_id = $1 FOR UPDATE")).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM leave_policies")).
+		WillReturnRows(sqlmock.NewRows([]string{"leave_type", "annual_days", "accrual", "max_carry_forward", "updated_by", "updated_at"}).
+			AddRow("Vacation", 20, "annual", 5, "admin@example.com", time.Now()))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM leave_adjustments")).WithArgs(2025, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "leave_type", "sum"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM leave WHERE status = $3")).WithArgs(
+		time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), "Approved", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "leave_type", "sum"}).AddRow(1, "Vacation", 12))
+	mock.ExpectQuery(regexp.QuoteMeta("AND id <> $5")).WithArgs(
+		time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), "Pending", 1, excludeID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "leave_type", "sum"}).AddRow(1, "Vacation", pending))
+}
+
+func TestCreateLeaveChecksBalance(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBLeaveStore{DB: db}
+	request := models.Leave{UserID: 1, LeaveType: "Vacation", Status: "Pending",
+		StartDate: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2025, 6, 6, 0, 0, 0, 0, time.UTC)}
+
+	// 8 days left, 4 of them held by a pending request
+	mock.ExpectBegin()
+	expectBalance(mock, 0, 4)
+	mock.ExpectRollback()
+	err = store.CreateLeave(ctx, &request)
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	assert.EqualError(t, err, "insufficient Vacation balance for 2025: 5 days requested, 4 available")
+
+	mock.ExpectBegin()
+	expectBalance(mock, 0, 3)
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO leave")).
+		WithArgs(1, "Vacation", request.StartDate, request.EndDate, "Pending").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(7, 1))
+	mock.ExpectCommit()
+	require.NoError(t, store.CreateLeave(ctx, &request))
+	assert.Equal(t, 7, request.ID)
+	assert.Equal(t, 1, request.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApprovingLeaveChecksBalance(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBLeaveStore{DB: db}
+
+	expectRequest := func(status string) {
+		mock.ExpectQuery(regexp.QuoteMeta("FROM leave WHERE id = $1 FOR UPDATE")).WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "leave_type", "start_date", "end_date", "status"}).
+				AddRow(7, 1, "Vacation", time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 6, 0, 0, 0, 0, time.UTC), status))
+	}
+
+	// Other pending requests leave only 3 days
+	mock.ExpectBegin()
+	expectRequest("Pending")
+	expectBalance(mock, 7, 5)
+	mock.ExpectRollback()
+	_, err = store.UpdateLeaveStatus(ctx, 7, "Approved", 1)
+	assert.True(t, errors.Is(err, models.ErrValidation))
+
+	mock.ExpectBegin()
+	expectRequest("Pending")
+	expectBalance(mock, 7, 0)
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE leave SET status = $1 WHERE id = $2 AND version = $3 RETURNING version")).
+		WithArgs("Approved", 7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectCommit()
+	version, err := store.UpdateLeaveStatus(ctx, 7, "Approved", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	// Rejecting is not checked
+	mock.ExpectBegin()
+	expectRequest("Approved")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE leave SET status = $1 WHERE id = $2 AND version = $3 RETURNING version")).
+		WithArgs("Rejected", 7, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectCommit()
+	_, err = store.UpdateLeaveStatus(ctx, 7, "Rejected", 2)
+	require.NoError(t, err)
+
+	// A decision based on a version someone else has replaced changes nothing
+	mock.ExpectBegin()
+	expectRequest("Rejected")
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE leave SET status = $1")).WithArgs("Pending", 7, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM leave WHERE id = $1")).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectRollback()
+	_, err = store.UpdateLeaveStatus(ctx, 7, "Pending", 2)
+	assert.True(t, errors.Is(err, models.ErrConflict))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM leave WHERE id = $1 FOR UPDATE")).WithArgs(8).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = store.UpdateLeaveStatus(ctx, 8, "Approved", 1)
+	assert.True(t, errors.Is(err, models.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
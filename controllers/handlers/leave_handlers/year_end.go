@@ -0,0 +1,243 @@
+package leave_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Balances manages leave policies, adjustments and year-end runs. It is satisfied by
+// *leave.Service.
+type Balances interface {
+	Policies(ctx context.Context) ([]models.LeavePolicy, error)
+	SetPolicy(ctx context.Context, policy models.LeavePolicy, actor string) error
+	Adjust(ctx context.Context, adjustments []models.LeaveAdjustment, actor string) ([]models.LeaveAdjustment, error)
+	Adjustments(ctx context.Context, year, userID int) ([]models.LeaveAdjustment, error)
+	Preview(ctx context.Context, year int) (*models.LeaveYearEndRun, error)
+	Start(ctx context.Context, year int, actor string) (*models.Job, error)
+	Runs(ctx context.Context) ([]models.LeaveYearEndRun, error)
+	Run(ctx context.Context, year int) (*models.LeaveYearEndRun, error)
+}
+
+// BalanceHandler provides HTTP handlers for leave policies, adjustments and year-end processing.
+type BalanceHandler struct {
+	Balances Balances
+}
+
+// RegisterBalanceRoutes maps leave balance routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth and limited to admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - balances: The leave service.
+func RegisterBalanceRoutes(router *mux.Router, balances Balances) {
+	handler := &BalanceHandler{Balances: balances}
+
+	router.HandleFunc("/policies", handler.ListPolicies).Methods("GET")
+	router.HandleFunc("/policies/{type}", handler.UpdatePolicy).Methods("PUT")
+	router.HandleFunc("/adjustments", handler.CreateAdjustments).Methods("POST")
+	router.HandleFunc("/adjustments", handler.ListAdjustments).Methods("GET")
+	router.HandleFunc("/year_end", handler.ListRuns).Methods("GET")
+	router.HandleFunc("/year_end/{year:[0-9]+}/preview", handler.PreviewYearEnd).Methods("GET")
+	router.HandleFunc("/year_end/{year:[0-9]+}", handler.StartYearEnd).Methods("POST")
+	router.HandleFunc("/year_end/{year:[0-9]+}", handler.GetRun).Methods("GET")
+}
+
+// ListPolicies returns the policy of every leave type.
+//
+// HTTP Method: GET
+// URL Path: /leave/policies
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of LeavePolicies in JSON.
+//   - Status Code: 500 (Internal Server Error) if the policies cannot be read.
+func (h *BalanceHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.Balances.Policies(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load leave policies")
+		return
+	}
+	respond.JSON(w, http.StatusOK, policies)
+}
+
+// UpdatePolicy sets the yearly entitlement of a leave type and how much of it can be
+// carried forward.
+//
+// HTTP Method: PUT
+// URL Path: /leave/policies/{type}
+//
+// Request Body:
+//   - JSON object with "annual_days" and "max_carry_forward".
+//
+// Response:
+//   - Status Code: 200 (OK) with the updated list of LeavePolicies in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if a number is negative.
+//   - Status Code: 500 (Internal Server Error) if the policy cannot be saved.
+func (h *BalanceHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	var policy models.LeavePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	policy.LeaveType = mux.Vars(r)["type"]
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Balances.SetPolicy(r.Context(), policy, actor); err != nil {
+		httperr.Write(w, err, "Failed to update leave policy")
+		return
+	}
+	h.ListPolicies(w, r)
+}
+
+// CreateAdjustments adjusts the leave balances of several employees at once. Either all
+// adjustments are saved or none is.
+//
+// HTTP Method: POST
+// URL Path: /leave/adjustments
+//
+// Request Body:
+//   - JSON list of adjustments, each with "user_id", "leave_type", "year", "days"
+//     (negative to remove leave) and "reason".
+//
+// Response:
+//   - Status Code: 201 (Created) with the saved LeaveAdjustments in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if an adjustment is incomplete, or names an
+//     unknown user or a leave type without a policy.
+//   - Status Code: 500 (Internal Server Error) if the adjustments cannot be saved.
+func (h *BalanceHandler) CreateAdjustments(w http.ResponseWriter, r *http.Request) {
+	var adjustments []models.LeaveAdjustment
+	if err := json.NewDecoder(r.Body).Decode(&adjustments); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	saved, err := h.Balances.Adjust(r.Context(), adjustments, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to save leave adjustments")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, saved)
+}
+
+// ListAdjustments lists the adjustments made to the balances of a year, including those
+// made by year-end runs.
+//
+// HTTP Method: GET
+// URL Path: /leave/adjustments?year=2025&user_id=1
+// (user_id is optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of LeaveAdjustments in JSON.
+//   - Status Code: 400 (Bad Request) if the year is missing or a parameter is not a number.
+//   - Status Code: 500 (Internal Server Error) if the adjustments cannot be read.
+func (h *BalanceHandler) ListAdjustments(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(r.URL.Query().Get("year"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid year")
+		return
+	}
+	userID := 0
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		if userID, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+	}
+	adjustments, err := h.Balances.Adjustments(r.Context(), year, userID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load leave adjustments")
+		return
+	}
+	respond.JSON(w, http.StatusOK, adjustments)
+}
+
+// PreviewYearEnd shows what closing a year would carry forward and forfeit for every
+// employee, without saving anything.
+//
+// HTTP Method: GET
+// URL Path: /leave/year_end/{year}/preview
+//
+// Response:
+//   - Status Code: 200 (OK) with the LeaveYearEndRun in JSON, marked as a preview.
+//   - Status Code: 409 (Conflict) if the year is already closed.
+//   - Status Code: 422 (Unprocessable Entity) if the year has not ended.
+//   - Status Code: 500 (Internal Server Error) if the balances cannot be read.
+func (h *BalanceHandler) PreviewYearEnd(w http.ResponseWriter, r *http.Request) {
+	year, _ := strconv.Atoi(mux.Vars(r)["year"])
+	run, err := h.Balances.Preview(r.Context(), year)
+	if err != nil {
+		httperr.Write(w, err, "Failed to preview leave year end")
+		return
+	}
+	respond.JSON(w, http.StatusOK, run)
+}
+
+// StartYearEnd closes a year in the background, as the scheduler does in January.
+//
+// HTTP Method: POST
+// URL Path: /leave/year_end/{year}
+//
+// Response:
+//   - Status Code: 202 (Accepted) with the queued Job in JSON and its URL in the Location
+//     header. The job's result is the LeaveYearEndRun.
+//   - Status Code: 409 (Conflict) if the year is already closed.
+//   - Status Code: 422 (Unprocessable Entity) if the year has not ended.
+//   - Status Code: 500 (Internal Server Error) if the job cannot be created.
+func (h *BalanceHandler) StartYearEnd(w http.ResponseWriter, r *http.Request) {
+	year, _ := strconv.Atoi(mux.Vars(r)["year"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	job, err := h.Balances.Start(r.Context(), year, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to start leave year end")
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	respond.JSON(w, http.StatusAccepted, job)
+}
+
+// ListRuns lists the closed years with their totals, latest first.
+//
+// HTTP Method: GET
+// URL Path: /leave/year_end
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of LeaveYearEndRuns, without lines, in JSON.
+//   - Status Code: 500 (Internal Server Error) if the runs cannot be read.
+func (h *BalanceHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.Balances.Runs(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load leave year-end runs")
+		return
+	}
+	respond.JSON(w, http.StatusOK, runs)
+}
+
+// GetRun returns the summary report of a closed year: its totals and what was carried
+// forward and forfeited for every employee and leave type.
+//
+// HTTP Method: GET
+// URL Path: /leave/year_end/{year}
+//
+// Response:
+//   - Status Code: 200 (OK) with the LeaveYearEndRun in JSON.
+//   - Status Code: 404 (Not Found) if the year has not been closed.
+//   - Status Code: 500 (Internal Server Error) if the run cannot be read.
+func (h *BalanceHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	year, _ := strconv.Atoi(mux.Vars(r)["year"])
+	run, err := h.Balances.Run(r.Context(), year)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load leave year-end run")
+		return
+	}
+	respond.JSON(w, http.StatusOK, run)
+}
@@ -0,0 +1,118 @@
+package leave_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBalances closes 2025 on request and keeps the adjustments it is given.
+type fakeBalances struct {
+	closed      map[int]bool
+	adjustments []models.LeaveAdjustment
+}
+
+func (f *fakeBalances) Policies(ctx context.Context) ([]models.LeavePolicy, error) {
+	return []models.LeavePolicy{{LeaveType: "Vacation", AnnualDays: 20, MaxCarryForward: 5}}, nil
+}
+
+func (f *fakeBalances) SetPolicy(ctx context.Context, policy models.LeavePolicy, actor string) error {
+	return nil
+}
+
+func (f *fakeBalances) Adjust(ctx context.Context, adjustments []models.LeaveAdjustment, actor string) ([]models.LeaveAdjustment, error) {
+	for i := range adjustments {
+		if adjustments[i].Reason == "" {
+			return nil, models.Invalid("adjustment %d: reason is required", i+1)
+		}
+		adjustments[i].ID, adjustments[i].CreatedBy = len(f.adjustments)+1, actor
+		f.adjustments = append(f.adjustments, adjustments[i])
+	}
+	return adjustments, nil
+}
+
+func (f *fakeBalances) Adjustments(ctx context.Context, year, userID int) ([]models.LeaveAdjustment, error) {
+	return f.adjustments, nil
+}
+
+func (f *fakeBalances) Preview(ctx context.Context, year int) (*models.LeaveYearEndRun, error) {
+	if f.closed[year] {
+		return nil, models.Conflict("leave year %d is already closed", year)
+	}
+	return &models.LeaveYearEndRun{Year: year, Preview: true, Lines: []models.LeaveYearEndLine{}}, nil
+}
+
+func (f *fakeBalances) Start(ctx context.Context, year int, actor string) (*models.Job, error) {
+	if f.closed[year] {
+		return nil, models.Conflict("leave year %d is already closed", year)
+	}
+	f.closed[year] = true
+	return &models.Job{ID: 3, Kind: "leave_year_end", CreatedBy: actor}, nil
+}
+
+func (f *fakeBalances) Runs(ctx context.Context) ([]models.LeaveYearEndRun, error) {
+	return []models.LeaveYearEndRun{}, nil
+}
+
+func (f *fakeBalances) Run(ctx context.Context, year int) (*models.LeaveYearEndRun, error) {
+	if !f.closed[year] {
+		return nil, models.NotFound("leave year %d has not been closed", year)
+	}
+	return &models.LeaveYearEndRun{Year: year}, nil
+}
+
+func TestYearEndRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	RegisterBalanceRoutes(router.PathPrefix("/leave").Subrouter(), &fakeBalances{closed: map[int]bool{}})
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+
+	rr := serve("GET", "/leave/year_end/2025/preview")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var run models.LeaveYearEndRun
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&run))
+	assert.True(t, run.Preview)
+
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/leave/year_end/2025").Code)
+
+	rr = serve("POST", "/leave/year_end/2025")
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "/jobs/3", rr.Header().Get("Location"))
+
+	assert.Equal(t, http.StatusConflict, serve("POST", "/leave/year_end/2025").Code)
+	assert.Equal(t, http.StatusConflict, serve("GET", "/leave/year_end/2025/preview").Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/leave/year_end/2025").Code)
+}
+
+func TestCreateAdjustments(t *testing.T) {
+	balances := &fakeBalances{closed: map[int]bool{}}
+	handler := &BalanceHandler{Balances: balances}
+
+	rr := httptest.NewRecorder()
+	handler.CreateAdjustments(rr, httptest.NewRequest("POST", "/leave/adjustments", strings.NewReader(
+		`[{"user_id": 1, "leave_type": "Vacation", "year": 2026, "days": 2, "reason": "Overtime"},
+		  {"user_id": 2, "leave_type": "Vacation", "year": 2026, "days": -1.5, "reason": "Unrecorded absence"}]`)))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Len(t, balances.adjustments, 2)
+
+	rr = httptest.NewRecorder()
+	handler.CreateAdjustments(rr, httptest.NewRequest("POST", "/leave/adjustments", strings.NewReader(
+		`[{"user_id": 1, "leave_type": "Vacation", "year": 2026, "days": 2}]`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ListAdjustments(rr, httptest.NewRequest("GET", "/leave/adjustments", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
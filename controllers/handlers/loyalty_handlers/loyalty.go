@@ -0,0 +1,92 @@
+// Package loyalty_handlers provides HTTP handlers for customers' loyalty points: reading a
+// customer's balance and history and redeeming points against a draft invoice.
+package loyalty_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/loyalty"
+	"erp/controllers/respond"
+
+	"github.com/gorilla/mux"
+)
+
+// LoyaltyHandlers provides the loyalty endpoints under /customers/{id}/loyalty.
+type LoyaltyHandlers struct {
+	Service *loyalty.Service
+}
+
+// RedeemRequest is the request body for redeeming loyalty points.
+type RedeemRequest struct {
+	InvoiceID int `json:"invoice_id"`
+	Points    int `json:"points"`
+}
+
+// GetLoyaltyAccount returns a customer's loyalty balance, what it is worth, when the oldest
+// points expire and the customer's recent loyalty transactions.
+//
+// HTTP Method: GET
+// URL Path: /customers/{id}/loyalty
+//
+// Response:
+//   - Status Code: 200 (OK) with the loyalty account in JSON format.
+//   - Status Code: 400 (Bad Request) if the customer ID is invalid.
+//   - Status Code: 404 (Not Found) if the customer does not exist.
+//   - Status Code: 500 (Internal Server Error) if the account could not be loaded.
+func (h *LoyaltyHandlers) GetLoyaltyAccount(w http.ResponseWriter, r *http.Request) {
+	customerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	account, err := h.Service.Account(r.Context(), customerID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load loyalty account")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, account)
+}
+
+// RedeemPoints spends a customer's points as a discount on one of their draft invoices.
+// The invoice amount is reduced by the value of the points.
+//
+// HTTP Method: POST
+// URL Path: /customers/{id}/loyalty/redemptions
+//
+// Request Body:
+//   - JSON object with "invoice_id" and the number of "points" to redeem.
+//
+// Response:
+//   - Status Code: 201 (Created) with the redemption in JSON format.
+//   - Status Code: 400 (Bad Request) if the customer ID or payload is invalid.
+//   - Status Code: 404 (Not Found) if the invoice does not exist.
+//   - Status Code: 409 (Conflict) if the invoice is not a draft or the customer holds too few points.
+//   - Status Code: 422 (Unprocessable Entity) if the points are not positive, the invoice belongs
+//     to another customer or the discount would not leave a positive amount.
+//   - Status Code: 500 (Internal Server Error) if the points could not be redeemed.
+func (h *LoyaltyHandlers) RedeemPoints(w http.ResponseWriter, r *http.Request) {
+	customerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	var req RedeemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	redemption, err := h.Service.Redeem(r.Context(), customerID, req.InvoiceID, req.Points)
+	if err != nil {
+		httperr.Write(w, err, "Failed to redeem loyalty points")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, redemption)
+}
@@ -0,0 +1,130 @@
+package loyalty_handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/controllers/loyalty"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRouter serves the loyalty routes from a DBLoyaltyStore on a mock database.
+func newTestRouter(t *testing.T) (*mux.Router, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	rules := config.LoyaltyConfig{PointsPerUnit: 1, PointValue: 0.1, ExpiryDays: 365}
+	handlers := &LoyaltyHandlers{Service: loyalty.NewService(&DBLoyaltyStore{DB: db}, rules)}
+	router := mux.NewRouter()
+	router.HandleFunc("/customers/{id:[0-9]+}/loyalty", handlers.GetLoyaltyAccount).Methods("GET")
+	router.HandleFunc("/customers/{id:[0-9]+}/loyalty/redemptions", handlers.RedeemPoints).Methods("POST")
+	return router, mock
+}
+
+func TestGetLoyaltyAccount(t *testing.T) {
+	router, mock := newTestRouter(t)
+	expiry := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(remaining\\), 0\\)").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(150))
+	mock.ExpectQuery("SELECT expires_at, SUM\\(remaining\\)").WillReturnRows(sqlmock.NewRows([]string{"expires_at", "sum"}).AddRow(expiry, 100))
+	mock.ExpectQuery("SELECT id, customer_id, kind").WithArgs(5, loyalty.HistoryLimit).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "customer_id", "kind", "points", "invoice_id", "discount", "expires_at", "created_at"}).
+			AddRow(2, 5, models.LoyaltyAccrual, 150, 9, 0, expiry, expiry.AddDate(-1, 0, 0)))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/customers/5/loyalty", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var account models.LoyaltyAccount
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &account))
+	assert.Equal(t, 150, account.Balance)
+	assert.Equal(t, 15.0, account.Value)
+	assert.Equal(t, 100, account.ExpiringPoints)
+	assert.True(t, expiry.Equal(*account.NextExpiry))
+	require.Len(t, account.Transactions, 1)
+	assert.Equal(t, 9, *account.Transactions[0].InvoiceID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetLoyaltyAccountOfMissingCustomer(t *testing.T) {
+	router, mock := newTestRouter(t)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/customers/5/loyalty", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// TestRedeemPoints verifies that points are spent from the accrual that expires first and
+// the invoice amount is reduced by their value.
+func TestRedeemPoints(t *testing.T) {
+	router, mock := newTestRouter(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT customer_id, amount, status FROM invoices").WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "amount", "status"}).AddRow(5, 100.0, models.InvoiceStatusDraft))
+	mock.ExpectQuery("SELECT id, remaining FROM loyalty_transactions").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "remaining"}).AddRow(1, 30).AddRow(2, 50))
+	mock.ExpectExec("UPDATE loyalty_transactions SET remaining").WithArgs(30, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE loyalty_transactions SET remaining").WithArgs(10, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE invoices SET amount").WithArgs(4.0, 9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO loyalty_transactions").
+		WithArgs(5, models.LoyaltyRedemption, -40, 9, 4.0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(RedeemRequest{InvoiceID: 9, Points: 40})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/customers/5/loyalty/redemptions", bytes.NewReader(body)))
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var redemption models.LoyaltyTransaction
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &redemption))
+	assert.Equal(t, 3, redemption.ID)
+	assert.Equal(t, -40, redemption.Points)
+	assert.Equal(t, 4.0, redemption.Discount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedeemPointsRejections(t *testing.T) {
+	tests := []struct {
+		name     string
+		customer int
+		amount   float64
+		status   string
+		points   int
+		held     int
+		want     int
+	}{
+		{"too few points", 5, 100, models.InvoiceStatusDraft, 40, 39, http.StatusConflict},
+		{"posted invoice", 5, 100, models.InvoiceStatusPosted, 40, 100, http.StatusConflict},
+		{"other customer's invoice", 6, 100, models.InvoiceStatusDraft, 40, 100, http.StatusUnprocessableEntity},
+		{"discount covers the invoice", 5, 4, models.InvoiceStatusDraft, 40, 100, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mock := newTestRouter(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT customer_id, amount, status FROM invoices").
+				WillReturnRows(sqlmock.NewRows([]string{"customer_id", "amount", "status"}).AddRow(tt.customer, tt.amount, tt.status))
+			mock.ExpectQuery("SELECT id, remaining FROM loyalty_transactions").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "remaining"}).AddRow(1, tt.held))
+			mock.ExpectRollback()
+
+			body, _ := json.Marshal(RedeemRequest{InvoiceID: 9, Points: tt.points})
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("POST", "/customers/5/loyalty/redemptions", bytes.NewReader(body)))
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package loyalty_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/models"
+)
+
+// DBLoyaltyStore implements models.LoyaltyStore using a SQL database. Accruals keep their
+// unspent points in the remaining column; redemptions and expiries reduce it, oldest
+// accrual first.
+type DBLoyaltyStore struct {
+	DB *sql.DB
+}
+
+// Accrue records points earned by a posted invoice.
+//
+// Parameters:
+//   - customerID: The customer who earned the points.
+//   - invoiceID: The posted invoice.
+//   - points: The number of points earned.
+//   - expiresAt: When the points expire if unspent.
+//
+// Returns:
+//   - bool: false if the invoice has already accrued points.
+//   - error: An error if the accrual cannot be stored.
+func (s *DBLoyaltyStore) Accrue(ctx context.Context, customerID, invoiceID, points int, expiresAt time.Time) (bool, error) {
+	result, err := s.DB.ExecContext(ctx,
+		`INSERT INTO loyalty_transactions (customer_id, kind, points, remaining, invoice_id, expires_at, created_at)
+		 VALUES ($1, $2, $3, $3, $4, $5, $6)
+		 ON CONFLICT (invoice_id) WHERE kind = 'accrual' DO NOTHING`,
+		customerID, models.LoyaltyAccrual, points, invoiceID, expiresAt, time.Now(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to accrue loyalty points: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
+
+// Redeem spends points of the customer's oldest unexpired accruals and takes discount off
+// the amount of a draft invoice of that customer, in one transaction.
+//
+// Returns:
+//   - *models.LoyaltyTransaction: The redemption.
+//   - error: models.ErrNotFound if the invoice does not exist, a validation error if it
+//     belongs to another customer or the discount is not below its amount,
+//     models.ErrDocumentLocked if it is not a draft and models.ErrInsufficientPoints if
+//     the customer holds fewer points.
+func (s *DBLoyaltyStore) Redeem(ctx context.Context, customerID, invoiceID, points int, discount float64, now time.Time) (*models.LoyaltyTransaction, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var invoiceCustomer sql.NullInt64
+	var amount float64
+	var status sql.NullString
+	err = tx.QueryRowContext(ctx,
+		"SELECT customer_id, amount, status FROM invoices WHERE id = $1 FOR UPDATE",
+		invoiceID,
+	).Scan(&invoiceCustomer, &amount, &status)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("invoice %d not found", invoiceID)
+	} else if err != nil {
+		return nil, err
+	}
+	if int(invoiceCustomer.Int64) != customerID {
+		return nil, models.Invalid("invoice %d does not belong to customer %d", invoiceID, customerID)
+	}
+	if status.String != models.InvoiceStatusDraft {
+		return nil, models.ErrDocumentLocked
+	}
+	if discount >= amount {
+		return nil, models.Invalid("a discount of %.2f must be less than the invoice amount of %.2f", discount, amount)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, remaining FROM loyalty_transactions
+		 WHERE customer_id = $1 AND kind = $2 AND remaining > 0 AND expires_at > $3
+		 ORDER BY expires_at, id
+		 FOR UPDATE`,
+		customerID, models.LoyaltyAccrual, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type accrual struct{ id, remaining int }
+	var accruals []accrual
+	available := 0
+	for rows.Next() {
+		var a accrual
+		if err := rows.Scan(&a.id, &a.remaining); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		accruals = append(accruals, a)
+		available += a.remaining
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if available < points {
+		return nil, models.ErrInsufficientPoints
+	}
+
+	// Spend the points of the accruals that expire first
+	left := points
+	for _, a := range accruals {
+		if left == 0 {
+			break
+		}
+		spend := a.remaining
+		if spend > left {
+			spend = left
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE loyalty_transactions SET remaining = remaining - $1 WHERE id = $2", spend, a.id); err != nil {
+			return nil, err
+		}
+		left -= spend
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE invoices SET amount = amount - $1 WHERE id = $2", discount, invoiceID); err != nil {
+		return nil, err
+	}
+
+	redemption := &models.LoyaltyTransaction{
+		CustomerID: customerID,
+		Kind:       models.LoyaltyRedemption,
+		Points:     -points,
+		InvoiceID:  &invoiceID,
+		Discount:   discount,
+		CreatedAt:  now,
+	}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO loyalty_transactions (customer_id, kind, points, invoice_id, discount, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		customerID, redemption.Kind, redemption.Points, invoiceID, discount, now,
+	).Scan(&redemption.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return redemption, nil
+}
+
+// GetLoyaltyAccount returns the customer's spendable balance, the next expiry and their
+// newest transactions.
+//
+// Returns:
+//   - *models.LoyaltyAccount: The account; Value is not filled in.
+//   - error: models.ErrNotFound if the customer does not exist, or the query error.
+func (s *DBLoyaltyStore) GetLoyaltyAccount(ctx context.Context, customerID int, limit int, now time.Time) (*models.LoyaltyAccount, error) {
+	var exists bool
+	if err := s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1 AND deleted_at IS NULL)", customerID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to load customer: %w", err)
+	}
+	if !exists {
+		return nil, models.NotFound("customer %d not found", customerID)
+	}
+
+	account := &models.LoyaltyAccount{CustomerID: customerID, Transactions: []models.LoyaltyTransaction{}}
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(remaining), 0) FROM loyalty_transactions
+		 WHERE customer_id = $1 AND kind = $2 AND remaining > 0 AND expires_at > $3`,
+		customerID, models.LoyaltyAccrual, now,
+	).Scan(&account.Balance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load loyalty balance: %w", err)
+	}
+
+	var nextExpiry time.Time
+	err = s.DB.QueryRowContext(ctx,
+		`SELECT expires_at, SUM(remaining) FROM loyalty_transactions
+		 WHERE customer_id = $1 AND kind = $2 AND remaining > 0 AND expires_at > $3
+		 GROUP BY expires_at ORDER BY expires_at LIMIT 1`,
+		customerID, models.LoyaltyAccrual, now,
+	).Scan(&nextExpiry, &account.ExpiringPoints)
+	if err == nil {
+		account.NextExpiry = &nextExpiry
+	} else if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load loyalty expiry: %w", err)
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, customer_id, kind, points, invoice_id, discount, expires_at, created_at
+		 FROM loyalty_transactions WHERE customer_id = $1
+		 ORDER BY created_at DESC, id DESC LIMIT $2`,
+		customerID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load loyalty transactions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.LoyaltyTransaction
+		var invoiceID sql.NullInt64
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.CustomerID, &t.Kind, &t.Points, &invoiceID, &t.Discount, &expiresAt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		if invoiceID.Valid {
+			id := int(invoiceID.Int64)
+			t.InvoiceID = &id
+		}
+		if expiresAt.Valid {
+			t.ExpiresAt = &expiresAt.Time
+		}
+		account.Transactions = append(account.Transactions, t)
+	}
+	return account, rows.Err()
+}
+
+// ExpirePoints records an expiry for the unspent points of every accrual that expired at
+// or before now. The accruals are cleared and the expiries recorded in one statement.
+//
+// Returns:
+//   - int: The number of points expired.
+//   - error: An error if the expiries cannot be recorded.
+func (s *DBLoyaltyStore) ExpirePoints(ctx context.Context, now time.Time) (int, error) {
+	var expired int
+	err := s.DB.QueryRowContext(ctx,
+		`WITH due AS (
+		     SELECT id, customer_id, remaining, invoice_id FROM loyalty_transactions
+		     WHERE kind = $1 AND remaining > 0 AND expires_at <= $2
+		     FOR UPDATE
+		 ), cleared AS (
+		     UPDATE loyalty_transactions SET remaining = 0 FROM due WHERE loyalty_transactions.id = due.id
+		 ), recorded AS (
+		     INSERT INTO loyalty_transactions (customer_id, kind, points, invoice_id, created_at)
+		     SELECT customer_id, $3, -remaining, invoice_id, $2 FROM due
+		     RETURNING points
+		 )
+		 SELECT COALESCE(-SUM(points), 0) FROM recorded`,
+		models.LoyaltyAccrual, now, models.LoyaltyExpiry,
+	).Scan(&expired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire loyalty points: %w", err)
+	}
+	return expired, nil
+}
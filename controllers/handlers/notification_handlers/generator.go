@@ -0,0 +1,82 @@
+package notification_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erp/controllers/events"
+	"erp/models"
+	"fmt"
+)
+
+// rule describes who is notified about an event type and how the notification reads.
+type rule struct {
+	roles  []string
+	title  string // Format string taking the aggregate ID
+	link   string // Format string taking the aggregate ID
+	amount bool   // Whether the payload's "amount" is included in the body
+	owner  bool   // Whether the user in the payload's "owner" is notified instead of roles
+	signer bool   // Whether the payload's "signer_name" is included in the body
+	detail bool   // Whether the payload's "message" is the body
+}
+
+// rules lists the domain events that generate notifications. Events without a rule,
+// such as individual stock moves, are not shown in the notification center.
+var rules = map[string]rule{
+	events.InvoiceCreated:    {roles: []string{"Accountant"}, title: "Invoice #%d was created", link: "/invoices/%d", amount: true},
+	events.InvoicePosted:     {roles: []string{"Accountant", "Sales Group"}, title: "Invoice #%d was posted", link: "/invoices/%d", amount: true},
+	events.PaymentPosted:     {roles: []string{"Accountant", "Admin"}, title: "Payment #%d was received", link: "/accounts_receivable/%d", amount: true},
+	events.DocumentAccepted:  {title: "Signature request #%d was accepted", link: "/signature_requests/%d", owner: true, signer: true},
+	events.KPIAlertTriggered: {roles: []string{"Admin", "Accountant"}, title: "KPI alert #%d was triggered", link: "/kpi_alerts/%d", detail: true},
+}
+
+// Generator creates notifications from domain events. It is registered as a subscriber of
+// the event publisher.
+type Generator struct {
+	Store models.NotificationStore
+}
+
+// Handle notifies the roles interested in the event, or the user who owns the record. The
+// event ID is stored with each notification so a redelivered event does not notify anyone
+// twice.
+func (g *Generator) Handle(ctx context.Context, event *models.DomainEvent) error {
+	r, ok := rules[event.EventType]
+	if !ok {
+		return nil
+	}
+
+	notification := &models.Notification{
+		Type:  event.EventType,
+		Title: fmt.Sprintf(r.title, event.AggregateID),
+		Link:  fmt.Sprintf(r.link, event.AggregateID),
+	}
+	if event.ID != 0 {
+		id := event.ID
+		notification.EventID = &id
+	}
+	var payload struct {
+		Amount     *float64 `json:"amount"`
+		Owner      string   `json:"owner"`
+		SignerName string   `json:"signer_name"`
+		Message    string   `json:"message"`
+	}
+	decoded := json.Unmarshal(event.Payload, &payload) == nil
+	if r.amount && decoded && payload.Amount != nil {
+		notification.Body = fmt.Sprintf("Amount: %.2f", *payload.Amount)
+	}
+	if r.signer && decoded && payload.SignerName != "" {
+		notification.Body = "Signed by " + payload.SignerName
+	}
+	if r.detail && decoded {
+		notification.Body = payload.Message
+	}
+
+	if r.owner {
+		if payload.Owner == "" {
+			return nil
+		}
+		_, err := g.Store.NotifyUsers(ctx, []string{payload.Owner}, notification)
+		return err
+	}
+	_, err := g.Store.NotifyRoles(ctx, r.roles, notification)
+	return err
+}
@@ -0,0 +1,184 @@
+package notification_handlers
+
+import (
+	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// NotificationHandler provides HTTP handlers for the signed-in user's notifications.
+type NotificationHandler struct {
+	Store models.NotificationStore
+}
+
+// RegisterRoutes maps notification routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the NotificationStore interface.
+func RegisterRoutes(router *mux.Router, store models.NotificationStore) {
+	handler := &NotificationHandler{Store: store}
+
+	router.HandleFunc("", handler.ListNotifications).Methods("GET")
+	router.HandleFunc("/read", handler.MarkAllRead).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/read", handler.MarkRead).Methods("POST")
+}
+
+// ListNotifications returns the user's notifications, newest first, with the unread count
+// for the bell icon.
+//
+// HTTP Method: GET
+// URL Path: /
+//
+// Query Parameters:
+//   - unread: "true" to return only unread notifications.
+//   - limit: Maximum number of notifications (default 50, at most 200).
+//
+// Response:
+//   - Status Code: 200 (OK) with unread_count and notifications in JSON.
+//   - Status Code: 400 (Bad Request) if a query parameter is invalid.
+//   - Status Code: 401 (Unauthorized) if the user is unknown.
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	unreadOnly := false
+	if v := r.URL.Query().Get("unread"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid unread parameter")
+			return
+		}
+		unreadOnly = parsed
+	}
+	limit := defaultListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxListLimit {
+			respond.Error(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	notifications, err := h.Store.ListNotifications(r.Context(), userID, unreadOnly, limit)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load notifications")
+		return
+	}
+	unread, err := h.Store.CountUnread(r.Context(), userID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to count notifications")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"unread_count":  unread,
+		"notifications": notifications,
+	})
+}
+
+// MarkRead marks one notification as read.
+//
+// HTTP Method: POST
+// URL Path: /{id}/read
+//
+// Response:
+//   - Status Code: 204 (No Content) if the notification is marked as read.
+//   - Status Code: 401 (Unauthorized) if the user is unknown.
+//   - Status Code: 404 (Not Found) if the user has no such notification.
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	err = h.Store.MarkRead(r.Context(), userID, id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to mark notification as read")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead marks several notifications as read.
+//
+// HTTP Method: POST
+// URL Path: /read
+//
+// Request Body:
+//   - Optional JSON with "ids", the notifications to mark. Without ids all unread
+//     notifications are marked.
+//
+// Response:
+//   - Status Code: 200 (OK) with the number of notifications marked in JSON.
+//   - Status Code: 400 (Bad Request) if the body is invalid.
+//   - Status Code: 401 (Unauthorized) if the user is unknown.
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid input data")
+			return
+		}
+	}
+
+	marked, err := h.Store.MarkAllRead(r.Context(), userID, req.IDs)
+	if err != nil {
+		httperr.Write(w, err, "Failed to mark notifications as read")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]int{"marked": marked})
+}
+
+// currentUser resolves the authenticated user, writing an error response if it fails.
+func (h *NotificationHandler) currentUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	email, err := middleware.GetUserEmailFromContext(r.Context())
+	if err != nil {
+		respond.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return 0, false
+	}
+	userID, err := h.Store.GetUserIDByEmail(r.Context(), email)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return 0, false
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to load user")
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,256 @@
+package notification_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/controllers/events"
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockNotificationStore keeps notifications in memory for users identified by email.
+type mockNotificationStore struct {
+	users         map[string]int // email to user ID
+	roles         map[int]string // user ID to role
+	notifications []*models.Notification
+}
+
+func newMockStore() *mockNotificationStore {
+	return &mockNotificationStore{
+		users: map[string]int{"alice@example.com": 1, "bob@example.com": 2},
+		roles: map[int]string{1: "Accountant", 2: "Employee"},
+	}
+}
+
+func (m *mockNotificationStore) GetUserIDByEmail(ctx context.Context, email string) (int, error) {
+	id, ok := m.users[email]
+	if !ok {
+		return 0, models.ErrNotFound
+	}
+	return id, nil
+}
+
+func (m *mockNotificationStore) ListNotifications(ctx context.Context, userID int, unreadOnly bool, limit int) ([]models.Notification, error) {
+	result := []models.Notification{}
+	for i := len(m.notifications) - 1; i >= 0 && len(result) < limit; i-- {
+		n := m.notifications[i]
+		if n.UserID == userID && !(unreadOnly && n.Read) {
+			result = append(result, *n)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockNotificationStore) CountUnread(ctx context.Context, userID int) (int, error) {
+	count := 0
+	for _, n := range m.notifications {
+		if n.UserID == userID && !n.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockNotificationStore) MarkRead(ctx context.Context, userID, id int) error {
+	for _, n := range m.notifications {
+		if n.ID == id && n.UserID == userID {
+			n.Read = true
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (m *mockNotificationStore) MarkAllRead(ctx context.Context, userID int, ids []int) (int, error) {
+	marked := 0
+	for _, n := range m.notifications {
+		if n.UserID != userID || n.Read {
+			continue
+		}
+		if len(ids) > 0 && !containsID(ids, n.ID) {
+			continue
+		}
+		n.Read = true
+		marked++
+	}
+	return marked, nil
+}
+
+func (m *mockNotificationStore) NotifyRoles(ctx context.Context, roles []string, notification *models.Notification) (int, error) {
+	created := 0
+	for userID, role := range m.roles {
+		if !containsRole(roles, role) || m.notified(userID, notification.EventID) {
+			continue
+		}
+		n := *notification
+		n.ID = len(m.notifications) + 1
+		n.UserID = userID
+		n.CreatedAt = time.Now()
+		m.notifications = append(m.notifications, &n)
+		created++
+	}
+	return created, nil
+}
+
+func (m *mockNotificationStore) NotifyUsers(ctx context.Context, emails []string, notification *models.Notification) (int, error) {
+	created := 0
+	for _, email := range emails {
+		userID, ok := m.users[email]
+		if !ok || m.notified(userID, notification.EventID) {
+			continue
+		}
+		n := *notification
+		n.ID = len(m.notifications) + 1
+		n.UserID = userID
+		n.CreatedAt = time.Now()
+		m.notifications = append(m.notifications, &n)
+		created++
+	}
+	return created, nil
+}
+
+func (m *mockNotificationStore) notified(userID int, eventID *int) bool {
+	for _, n := range m.notifications {
+		if eventID != nil && n.EventID != nil && n.UserID == userID && *n.EventID == *eventID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func setupRouter(store models.NotificationStore) *mux.Router {
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/notifications").Subrouter(), store)
+	return router
+}
+
+func doRequest(router *mux.Router, method, path, email string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmail, email))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+type listResponse struct {
+	UnreadCount   int                   `json:"unread_count"`
+	Notifications []models.Notification `json:"notifications"`
+}
+
+func TestGeneratorNotifiesRolesOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	generator := &Generator{Store: store}
+	event := &models.DomainEvent{ID: 10, EventType: events.InvoicePosted, AggregateType: "invoice", AggregateID: 7, Payload: []byte(`{"amount":150}`)}
+
+	assert.NoError(t, generator.Handle(ctx, event))
+	assert.NoError(t, generator.Handle(ctx, event))
+	assert.NoError(t, generator.Handle(ctx, &models.DomainEvent{ID: 11, EventType: events.StockMoved, AggregateID: 3}))
+
+	assert.Len(t, store.notifications, 1)
+	n := store.notifications[0]
+	assert.Equal(t, 1, n.UserID)
+	assert.Equal(t, "Invoice #7 was posted", n.Title)
+	assert.Equal(t, "Amount: 150.00", n.Body)
+	assert.Equal(t, "/invoices/7", n.Link)
+}
+
+func TestGeneratorNotifiesDocumentOwner(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	generator := &Generator{Store: store}
+	event := &models.DomainEvent{ID: 12, EventType: events.DocumentAccepted, AggregateType: "signature_request", AggregateID: 4,
+		Payload: []byte(`{"owner":"bob@example.com","signer_name":"Jane Doe"}`)}
+
+	assert.NoError(t, generator.Handle(ctx, event))
+	assert.NoError(t, generator.Handle(ctx, event))
+
+	assert.Len(t, store.notifications, 1)
+	n := store.notifications[0]
+	assert.Equal(t, 2, n.UserID)
+	assert.Equal(t, "Signature request #4 was accepted", n.Title)
+	assert.Equal(t, "Signed by Jane Doe", n.Body)
+	assert.Equal(t, "/signature_requests/4", n.Link)
+}
+
+func TestGeneratorNotifiesKPIAlerts(t *testing.T) {
+	store := newMockStore()
+	generator := &Generator{Store: store}
+	event := &models.DomainEvent{ID: 13, EventType: events.KPIAlertTriggered, AggregateType: "kpi_alert", AggregateID: 5,
+		Payload: []byte(`{"message":"High receivables: receivables is 62000.00 (> 50000.00)"}`)}
+
+	assert.NoError(t, generator.Handle(context.Background(), event))
+
+	assert.Len(t, store.notifications, 1, "Only the accountant holds a notified role")
+	n := store.notifications[0]
+	assert.Equal(t, "KPI alert #5 was triggered", n.Title)
+	assert.Equal(t, "High receivables: receivables is 62000.00 (> 50000.00)", n.Body)
+	assert.Equal(t, "/kpi_alerts/5", n.Link)
+}
+
+func TestListAndMarkRead(t *testing.T) {
+	store := newMockStore()
+	generator := &Generator{Store: store}
+	for id := 1; id <= 3; id++ {
+		assert.NoError(t, generator.Handle(context.Background(), &models.DomainEvent{ID: id, EventType: events.InvoiceCreated, AggregateID: id, Payload: []byte(`{}`)}))
+	}
+	router := setupRouter(store)
+
+	rr := doRequest(router, "GET", "/notifications?unread=true", "alice@example.com", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var list listResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	assert.Equal(t, 3, list.UnreadCount)
+	assert.Len(t, list.Notifications, 3)
+	assert.Equal(t, 3, list.Notifications[0].ID)
+
+	assert.Equal(t, http.StatusNoContent, doRequest(router, "POST", "/notifications/1/read", "alice@example.com", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(router, "POST", "/notifications/1/read", "bob@example.com", nil).Code)
+
+	rr = doRequest(router, "POST", "/notifications/read", "alice@example.com", map[string][]int{"ids": {1, 2}})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"marked":1}`, rr.Body.String())
+
+	rr = doRequest(router, "POST", "/notifications/read", "alice@example.com", nil)
+	assert.JSONEq(t, `{"marked":1}`, rr.Body.String())
+
+	rr = doRequest(router, "GET", "/notifications?unread=true", "alice@example.com", nil)
+	list = listResponse{}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	assert.Equal(t, 0, list.UnreadCount)
+	assert.Empty(t, list.Notifications)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(router, "GET", "/notifications?unread=maybe", "alice@example.com", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(router, "GET", "/notifications", "mallory@example.com", nil).Code)
+}
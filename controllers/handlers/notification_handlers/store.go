@@ -0,0 +1,201 @@
+// Package notification_handlers provides SQL-backed methods and HTTP handlers for the
+// in-app notification center, and generates notifications from domain events.
+package notification_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBNotificationStore provides SQL-backed methods for the notifications table.
+type DBNotificationStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// GetUserIDByEmail looks up a user's ID by email.
+//
+// Parameters:
+//   - email: The email of the user.
+//
+// Returns:
+//   - int: The user's ID.
+//   - error: models.ErrNotFound if no user has the email.
+func (store *DBNotificationStore) GetUserIDByEmail(ctx context.Context, email string) (int, error) {
+	var id int
+	err := store.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", email).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, models.ErrNotFound
+	}
+	return id, err
+}
+
+// ListNotifications returns a user's most recent notifications, newest first.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - unreadOnly: Whether to return only unread notifications.
+//   - limit: The maximum number of notifications to return.
+//
+// Returns:
+//   - []Notification: The notifications.
+//   - error: An error if the query fails.
+func (store *DBNotificationStore) ListNotifications(ctx context.Context, userID int, unreadOnly bool, limit int) ([]models.Notification, error) {
+	query := `SELECT id, user_id, event_id, type, title, body, link, read_at, created_at
+		 FROM notifications WHERE user_id = $1`
+	if unreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT $2"
+
+	rows, err := store.DB.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var eventID sql.NullInt64
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.UserID, &eventID, &n.Type, &n.Title, &n.Body, &n.Link, &readAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		if eventID.Valid {
+			id := int(eventID.Int64)
+			n.EventID = &id
+		}
+		if readAt.Valid {
+			n.Read = true
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnread returns the number of unread notifications of a user.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - int: The number of unread notifications.
+//   - error: An error if the query fails.
+func (store *DBNotificationStore) CountUnread(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := store.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks one notification as read. Marking an already read notification again
+// keeps its original read time.
+//
+// Parameters:
+//   - userID: The ID of the user owning the notification.
+//   - id: The ID of the notification.
+//
+// Returns:
+//   - error: models.ErrNotFound if the user has no such notification.
+func (store *DBNotificationStore) MarkRead(ctx context.Context, userID, id int) error {
+	result, err := store.DB.ExecContext(ctx,
+		"UPDATE notifications SET read_at = COALESCE(read_at, $1) WHERE id = $2 AND user_id = $3",
+		time.Now(), id, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks the given unread notifications of a user as read, or all of them if
+// ids is empty. IDs belonging to other users are ignored.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - ids: The notifications to mark, or nil for all.
+//
+// Returns:
+//   - int: The number of notifications marked as read.
+//   - error: An error if the update fails.
+func (store *DBNotificationStore) MarkAllRead(ctx context.Context, userID int, ids []int) (int, error) {
+	query := "UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL"
+	args := []interface{}{time.Now(), userID}
+	if len(ids) > 0 {
+		query += " AND id = ANY($3)"
+		args = append(args, pq.Array(ids))
+	}
+
+	result, err := store.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// NotifyRoles creates the notification for every user whose role is one of roles. When the
+// notification comes from an event, users who were already notified about it are skipped.
+//
+// Parameters:
+//   - roles: The role names to notify.
+//   - notification: The notification to copy to each user; UserID is ignored.
+//
+// Returns:
+//   - int: The number of notifications created.
+//   - error: An error if the insertion fails.
+func (store *DBNotificationStore) NotifyRoles(ctx context.Context, roles []string, notification *models.Notification) (int, error) {
+	result, err := store.DB.ExecContext(ctx,
+		`INSERT INTO notifications (user_id, event_id, type, title, body, link, created_at)
+		 SELECT u.id, $2, $3, $4, $5, $6, $7
+		 FROM users u JOIN roles r ON r.id = u.role_id
+		 WHERE r.role_name = ANY($1)
+		 ON CONFLICT (user_id, event_id) DO NOTHING`,
+		pq.Array(roles), notification.EventID, notification.Type, notification.Title,
+		notification.Body, notification.Link, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// NotifyUsers creates a copy of the notification for each user with one of the given
+// emails. Emails without a user are skipped, and a redelivered event creates no duplicates.
+//
+// Parameters:
+//   - emails: The emails of the users to notify.
+//   - notification: The notification to copy to each user; UserID is ignored.
+//
+// Returns:
+//   - int: The number of notifications created.
+//   - error: An error if the insertion fails.
+func (store *DBNotificationStore) NotifyUsers(ctx context.Context, emails []string, notification *models.Notification) (int, error) {
+	result, err := store.DB.ExecContext(ctx,
+		`INSERT INTO notifications (user_id, event_id, type, title, body, link, created_at)
+		 SELECT u.id, $2, $3, $4, $5, $6, $7
+		 FROM users u
+		 WHERE u.email = ANY($1)
+		 ON CONFLICT (user_id, event_id) DO NOTHING`,
+		pq.Array(emails), notification.EventID, notification.Type, notification.Title,
+		notification.Body, notification.Link, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
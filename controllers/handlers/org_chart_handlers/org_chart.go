@@ -0,0 +1,92 @@
+// Package org_chart_handlers provides HTTP handlers and the database store for the
+// organization chart and for setting who each employee reports to.
+package org_chart_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/orgchart"
+	"erp/controllers/respond"
+
+	"github.com/gorilla/mux"
+)
+
+// OrgChartHandler provides HTTP handlers for the org chart.
+type OrgChartHandler struct {
+	Service *orgchart.Service
+}
+
+// ManagerRequest is the request body for setting an employee's manager.
+type ManagerRequest struct {
+	ManagerID *int `json:"manager_id"` // null to clear
+}
+
+// GetOrgChart returns the reporting hierarchy of the active employees, grouped by
+// department.
+//
+// HTTP Method: GET
+// URL Path: /org-chart?root=12&department=Sales&depth=2
+// (all optional: root starts the chart at an employee, department keeps only its members,
+// depth limits the levels returned; direct_reports still counts reports cut off by it)
+//
+// Response:
+//   - Status Code: 200 (OK) with the OrgChart in JSON.
+//   - Status Code: 400 (Bad Request) if root or depth is not a number.
+//   - Status Code: 404 (Not Found) if the root is not an active employee of the department.
+//   - Status Code: 422 (Unprocessable Entity) if the depth is negative.
+//   - Status Code: 500 (Internal Server Error) if the chart cannot be loaded.
+func (h *OrgChartHandler) GetOrgChart(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := orgchart.Options{Department: query.Get("department")}
+	var err error
+	if value := query.Get("root"); value != "" {
+		if opts.Root, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid root")
+			return
+		}
+	}
+	if value := query.Get("depth"); value != "" {
+		if opts.Depth, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid depth")
+			return
+		}
+	}
+	chart, err := h.Service.Chart(r.Context(), opts)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load org chart")
+		return
+	}
+	respond.JSON(w, http.StatusOK, chart)
+}
+
+// SetManager sets who an employee reports to.
+//
+// HTTP Method: PUT
+// URL Path: /users/{id}/manager
+//
+// Request Body:
+//   - JSON with manager_id, or null for an employee who reports to no one.
+//
+// Response:
+//   - Status Code: 204 (No Content) if the manager is set.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the user does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the manager is not an active user or
+//     reports to the employee, directly or through others.
+//   - Status Code: 500 (Internal Server Error) if the manager cannot be set.
+func (h *OrgChartHandler) SetManager(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req ManagerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.Service.SetManager(r.Context(), id, req.ManagerID); err != nil {
+		httperr.Write(w, err, "Failed to set manager")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
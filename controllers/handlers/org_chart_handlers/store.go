@@ -0,0 +1,91 @@
+package org_chart_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"erp/models"
+)
+
+// DBOrgChartStore implements models.OrgChartStore using a SQL database. The hierarchy is
+// the manager_id of each user.
+type DBOrgChartStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// ListOrgEmployees returns the active users with their departments and managers.
+func (s *DBOrgChartStore) ListOrgEmployees(ctx context.Context) ([]models.OrgEmployee, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, email, COALESCE(department, ''), manager_id FROM users WHERE active`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var employees []models.OrgEmployee
+	for rows.Next() {
+		var e models.OrgEmployee
+		var managerID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.Name, &e.Email, &e.Department, &managerID); err != nil {
+			return nil, err
+		}
+		if managerID.Valid {
+			id := int(managerID.Int64)
+			e.ManagerID = &id
+		}
+		employees = append(employees, e)
+	}
+	return employees, rows.Err()
+}
+
+// SetManager sets who a user reports to, or clears it when managerID is nil. The managers
+// above the new one are locked as they are walked, so that two changes made at the same
+// time cannot close a loop.
+//
+// Returns:
+//   - error: A not found error if the user does not exist, a validation error if the
+//     manager is not an active user or reports to the user, or the query error.
+func (s *DBOrgChartStore) SetManager(ctx context.Context, userID int, managerID *int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT true FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotFound("user %d not found", userID)
+	}
+	if err != nil {
+		return err
+	}
+	if managerID != nil {
+		var active bool
+		err := tx.QueryRowContext(ctx, `SELECT active FROM users WHERE id = $1 FOR UPDATE`, *managerID).Scan(&active)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !active) {
+			return models.Invalid("manager %d is not an active user", *managerID)
+		}
+		if err != nil {
+			return err
+		}
+		seen := map[int]bool{}
+		for above := *managerID; !seen[above]; {
+			seen[above] = true
+			var next sql.NullInt64
+			if err := tx.QueryRowContext(ctx, `SELECT manager_id FROM users WHERE id = $1 FOR UPDATE`, above).Scan(&next); err != nil {
+				return err
+			}
+			if !next.Valid {
+				break
+			}
+			if int(next.Int64) == userID {
+				return models.Invalid("user %d reports to user %d", *managerID, userID)
+			}
+			above = int(next.Int64)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET manager_id = $1 WHERE id = $2`, managerID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
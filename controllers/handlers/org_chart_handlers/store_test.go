@@ -0,0 +1,62 @@
+package org_chart_handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetManager(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBOrgChartStore{DB: db}
+	manager := 2
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT true FROM users WHERE id = $1 FOR UPDATE")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT active FROM users")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT manager_id FROM users")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"manager_id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT manager_id FROM users")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"manager_id"}).AddRow(nil))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET manager_id = $1 WHERE id = $2")).WithArgs(&manager, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, store.SetManager(context.Background(), 3, &manager))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetManagerRefusesLoops(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBOrgChartStore{DB: db}
+	manager := 3
+
+	// Making 1 report to 3 would close the loop 1 → 3 → 2 → 1
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT true FROM users WHERE id = $1 FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT active FROM users")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT manager_id FROM users")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"manager_id"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT manager_id FROM users")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"manager_id"}).AddRow(1))
+	mock.ExpectRollback()
+
+	err = store.SetManager(context.Background(), 1, &manager)
+	assert.True(t, errors.Is(err, models.ErrValidation), "%v", err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
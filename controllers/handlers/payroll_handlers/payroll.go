@@ -0,0 +1,153 @@
+// Package payroll_handlers serves the monthly payroll: pay rates per salary grade, payroll
+// runs and each employee's pay for a month.
+package payroll_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Payroll manages pay rates and runs the monthly payroll. It is satisfied by
+// *payroll.Service.
+type Payroll interface {
+	Rates(ctx context.Context) ([]models.PayRate, error)
+	SaveRate(ctx context.Context, rate *models.PayRate, actor string) error
+	DeleteRate(ctx context.Context, grade string) error
+	Run(ctx context.Context, period, actor string) (*models.PayrollRun, error)
+	Record(ctx context.Context, employeeID int, period string) (*models.PayrollRecord, error)
+}
+
+// PayrollHandler provides HTTP handlers for the payroll. Its routes are registered with their
+// permissions in the routes package: finance runs the payroll and sets pay rates, and HR and
+// finance read employees' pay.
+type PayrollHandler struct {
+	Payroll Payroll
+}
+
+// ListRates returns the pay rate of every salary grade.
+//
+// HTTP Method: GET
+// URL Path: /payroll/rates
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of PayRates in JSON.
+//   - Status Code: 500 (Internal Server Error) if the rates cannot be read.
+func (h *PayrollHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.Payroll.Rates(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load pay rates")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rates)
+}
+
+// SaveRate sets the pay rate of a salary grade. Months already run are not changed.
+//
+// HTTP Method: PUT
+// URL Path: /payroll/rates/{grade}
+//
+// Request Body:
+//   - JSON object with "hourly_rate" and, optionally, "overtime_multiplier"; the configured
+//     multiplier applies if it is left out.
+//
+// Response:
+//   - Status Code: 200 (OK) with the PayRate in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the rate is not positive or the multiplier
+//     is below 1.
+//   - Status Code: 500 (Internal Server Error) if the rate cannot be saved.
+func (h *PayrollHandler) SaveRate(w http.ResponseWriter, r *http.Request) {
+	var rate models.PayRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	rate.Grade = mux.Vars(r)["grade"]
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Payroll.SaveRate(r.Context(), &rate, actor); err != nil {
+		httperr.Write(w, err, "Failed to save pay rate")
+		return
+	}
+	respond.JSON(w, http.StatusOK, rate)
+}
+
+// DeleteRate removes the pay rate of a salary grade. Its employees are skipped by later runs.
+//
+// HTTP Method: DELETE
+// URL Path: /payroll/rates/{grade}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the rate was deleted.
+//   - Status Code: 404 (Not Found) if the grade has no rate.
+//   - Status Code: 500 (Internal Server Error) if the rate cannot be deleted.
+func (h *PayrollHandler) DeleteRate(w http.ResponseWriter, r *http.Request) {
+	if err := h.Payroll.DeleteRate(r.Context(), mux.Vars(r)["grade"]); err != nil {
+		httperr.Write(w, err, "Failed to delete pay rate")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunPayroll works out every employee's pay for a month from their attendance and approved
+// leave, and posts it to the ledger.
+//
+// HTTP Method: POST
+// URL Path: /payroll/run
+//
+// Request Body:
+//   - JSON object with "period", the month as YYYY-MM.
+//
+// Response:
+//   - Status Code: 201 (Created) with the posted PayrollRun in JSON, including the
+//     employees skipped and why.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the month has been run already.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid or has not ended.
+//   - Status Code: 500 (Internal Server Error) if the payroll cannot be posted.
+func (h *PayrollHandler) RunPayroll(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Period string `json:"period"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	run, err := h.Payroll.Run(r.Context(), req.Period, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to run payroll")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, run)
+}
+
+// GetPayrollRecord returns an employee's pay for a month: the hours, leave and pay it was
+// worked out from.
+//
+// HTTP Method: GET
+// URL Path: /payroll/{employee_id}/{period}
+//
+// Response:
+//   - Status Code: 200 (OK) with the PayrollRecord in JSON.
+//   - Status Code: 404 (Not Found) if the employee was not paid for the month.
+//   - Status Code: 422 (Unprocessable Entity) if the period is not formatted as YYYY-MM.
+//   - Status Code: 500 (Internal Server Error) if the record cannot be read.
+func (h *PayrollHandler) GetPayrollRecord(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	employeeID, _ := strconv.Atoi(vars["employee_id"])
+	record, err := h.Payroll.Record(r.Context(), employeeID, vars["period"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to load payroll record")
+		return
+	}
+	respond.JSON(w, http.StatusOK, record)
+}
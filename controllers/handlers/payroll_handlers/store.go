@@ -0,0 +1,211 @@
+package payroll_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// Ledger accounts payroll runs are posted to.
+const (
+	salaryAccount  = "salaries"         // Expense
+	payableAccount = "salaries_payable" // Owed to employees until paid out
+)
+
+// DBPayrollStore implements models.PayrollStore using a SQL database.
+type DBPayrollStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// ListPayRates returns the pay rate of every grade, by grade.
+func (s *DBPayrollStore) ListPayRates(ctx context.Context) ([]models.PayRate, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT grade, hourly_rate, overtime_multiplier, updated_by, updated_at FROM pay_rates ORDER BY grade`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pay rates: %w", err)
+	}
+	defer rows.Close()
+	rates := []models.PayRate{}
+	for rows.Next() {
+		var rate models.PayRate
+		if err := rows.Scan(&rate.Grade, &rate.HourlyRate, &rate.OvertimeMultiplier, &rate.UpdatedBy, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// SavePayRate creates or replaces the rate of a grade.
+func (s *DBPayrollStore) SavePayRate(ctx context.Context, rate *models.PayRate) error {
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO pay_rates (grade, hourly_rate, overtime_multiplier, updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (grade) DO UPDATE
+		 SET hourly_rate = EXCLUDED.hourly_rate, overtime_multiplier = EXCLUDED.overtime_multiplier,
+		     updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		rate.Grade, rate.HourlyRate, rate.OvertimeMultiplier, rate.UpdatedBy, rate.UpdatedAt)
+	return err
+}
+
+// DeletePayRate removes the rate of a grade.
+//
+// Returns:
+//   - error: A not found error if the grade has no rate, or the query error.
+func (s *DBPayrollStore) DeletePayRate(ctx context.Context, grade string) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM pay_rates WHERE grade = $1`, grade)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("grade %s has no pay rate", grade)
+	}
+	return nil
+}
+
+// ListPayrollEmployees returns the employees with a user account.
+func (s *DBPayrollStore) ListPayrollEmployees(ctx context.Context) ([]models.Employee, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, user_id, name, email, COALESCE(salary_grade, ''), hire_date
+		 FROM employees WHERE user_id IS NOT NULL ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list employees: %w", err)
+	}
+	defer rows.Close()
+	employees := []models.Employee{}
+	for rows.Next() {
+		var e models.Employee
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Name, &e.Email, &e.SalaryGrade, &e.HireDate); err != nil {
+			return nil, err
+		}
+		employees = append(employees, e)
+	}
+	return employees, rows.Err()
+}
+
+// ListAttendance returns the attendance checked in from from to before to. Records without
+// hours count as none.
+func (s *DBPayrollStore) ListAttendance(ctx context.Context, from, to time.Time) ([]models.Attendance, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, user_id, check_in, COALESCE(total_hours, 0) FROM attendance
+		 WHERE check_in >= $1 AND check_in < $2 ORDER BY check_in`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attendance: %w", err)
+	}
+	defer rows.Close()
+	list := []models.Attendance{}
+	for rows.Next() {
+		var a models.Attendance
+		if err := rows.Scan(&a.ID, &a.UserID, &a.CheckIn, &a.TotalHours); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// ListApprovedLeave returns the approved leave overlapping the days from from to before to.
+func (s *DBPayrollStore) ListApprovedLeave(ctx context.Context, from, to time.Time) ([]models.Leave, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, user_id, leave_type, start_date, end_date, status FROM leave
+		 WHERE status = 'Approved' AND start_date < $2 AND end_date >= $1 ORDER BY start_date`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leave: %w", err)
+	}
+	defer rows.Close()
+	list := []models.Leave{}
+	for rows.Next() {
+		var l models.Leave
+		if err := rows.Scan(&l.ID, &l.UserID, &l.LeaveType, &l.StartDate, &l.EndDate, &l.Status); err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
+
+// SavePayrollRun records a payroll run and posts it to the ledger, in one transaction. Each
+// employee's gross pay is debited to salary expense and credited to salaries payable.
+//
+// Parameters:
+//   - run: The computed run; its ID, posting time and record IDs are set.
+//   - postingDate: The date of the ledger lines, the last day of the month.
+//
+// Returns:
+//   - error: A models.Conflict error if the month has been run already, or an error if the
+//     run cannot be posted.
+func (s *DBPayrollStore) SavePayrollRun(ctx context.Context, run *models.PayrollRun, postingDate time.Time) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO payroll_runs (period, total, skipped, actor, posted_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		run.Period, run.Total, pq.StringArray(run.Skipped), run.Actor, now,
+	).Scan(&run.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("payroll for %s has been run already", run.Period)
+	}
+	if err != nil {
+		return err
+	}
+	run.PostedAt = &now
+
+	for i := range run.Records {
+		record := &run.Records[i]
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO payroll_records (run_id, employee_id, user_id, employee, email, period, grade, hourly_rate,
+			   regular_hours, overtime_hours, leave_days, unpaid_leave_days, regular_pay, overtime_pay, leave_pay, gross)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id`,
+			run.ID, record.EmployeeID, record.UserID, record.Employee, record.Email, record.Period, record.Grade,
+			record.HourlyRate, record.RegularHours, record.OvertimeHours, record.LeaveDays, record.UnpaidLeaveDays,
+			record.RegularPay, record.OvertimePay, record.LeavePay, record.Gross,
+		).Scan(&record.ID)
+		if err != nil {
+			return err
+		}
+		description := fmt.Sprintf("Payroll %s: %s", run.Period, record.Employee)
+		for _, line := range []models.FinancialTransaction{
+			{AccountType: salaryAccount, Amount: record.Gross},
+			{AccountType: payableAccount, Amount: -record.Gross},
+		} {
+			line.TransactionDate, line.Description = postingDate, description
+			if err := general_ledger_handlers.InsertTransaction(ctx, tx, &line); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// GetPayrollRecord returns an employee's pay for a month.
+//
+// Returns:
+//   - error: A not found error if the employee was not paid for the month, or the query error.
+func (s *DBPayrollStore) GetPayrollRecord(ctx context.Context, employeeID int, period string) (*models.PayrollRecord, error) {
+	var record models.PayrollRecord
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT id, employee_id, user_id, employee, email, period, grade, hourly_rate, regular_hours, overtime_hours,
+		        leave_days, unpaid_leave_days, regular_pay, overtime_pay, leave_pay, gross
+		 FROM payroll_records WHERE employee_id = $1 AND period = $2`, employeeID, period,
+	).Scan(&record.ID, &record.EmployeeID, &record.UserID, &record.Employee, &record.Email, &record.Period,
+		&record.Grade, &record.HourlyRate, &record.RegularHours, &record.OvertimeHours, &record.LeaveDays,
+		&record.UnpaidLeaveDays, &record.RegularPay, &record.OvertimePay, &record.LeavePay, &record.Gross)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("employee %d was not paid for %s", employeeID, period)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,55 @@
+package payroll_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavePayrollRunTwice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPayrollStore{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO payroll_runs")).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	run := &models.PayrollRun{Period: "2025-06", Total: 100, Records: []models.PayrollRecord{{EmployeeID: 1, Gross: 100}}}
+	err = store.SavePayrollRun(context.Background(), run, time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMissingPayrollRecord(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPayrollStore{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM payroll_records WHERE employee_id = $1 AND period = $2")).
+		WithArgs(7, "2025-06").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = store.GetPayrollRecord(context.Background(), 7, "2025-06")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestDeleteMissingPayRate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPayrollStore{DB: db}
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM pay_rates")).WithArgs("G9").WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, store.DeletePayRate(context.Background(), "G9"), models.ErrNotFound)
+}
@@ -0,0 +1,186 @@
+// Package payslip_handlers provides HTTP handlers and the database store for employees'
+// payslips.
+package payslip_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/documents"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/payslips"
+	"erp/controllers/rbac"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// PermissionChecker reports whether a role grants one of the required permissions,
+// typically the rbac service.
+type PermissionChecker interface {
+	Allowed(ctx context.Context, role string, required ...string) (bool, error)
+}
+
+// CompanyProvider supplies the company profile, typically the settings service.
+type CompanyProvider interface {
+	Company(ctx context.Context) (*models.CompanyProfile, error)
+}
+
+// PayslipHandler provides HTTP handlers for payslips.
+type PayslipHandler struct {
+	Service *payslips.Service
+	Access  PermissionChecker // Decides who may read every employee's payslips
+	Company CompanyProvider   // Optional source of the company header
+}
+
+// EmailRequest is the request body for emailing the payslips of a period.
+type EmailRequest struct {
+	Period string `json:"period"` // e.g. "2024-05"
+}
+
+// PayrollPermissions are the permissions that allow reading and emailing every employee's
+// payslips.
+var PayrollPermissions = []string{rbac.HR, rbac.Finance}
+
+// GetPayslipPDF renders a payslip: the gross pay, the statutory deductions, the net pay and
+// the year-to-date totals. Employees may download their own payslips; HR and payroll staff
+// everyone's.
+//
+// HTTP Method: GET
+// URL Path: /payroll/payslips/{id}/pdf (format=html renders it as HTML)
+//
+// Response:
+//   - Status Code: 200 (OK) with the payslip as PDF, or as HTML with format=html.
+//   - Status Code: 403 (Forbidden) if the payslip is another employee's.
+//   - Status Code: 404 (Not Found) if the payslip does not exist.
+//   - Status Code: 500 (Internal Server Error) if the payslip cannot be generated.
+func (h *PayslipHandler) GetPayslipPDF(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	privileged := false
+	if role != "" {
+		var err error
+		if privileged, err = h.Access.Allowed(r.Context(), role, PayrollPermissions...); err != nil {
+			httperr.Write(w, err, "Failed to check permissions")
+			return
+		}
+	}
+	payslip, err := h.Service.Payslip(r.Context(), id, email, privileged)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load payslip")
+		return
+	}
+	var company *models.CompanyProfile
+	if h.Company != nil {
+		if company, err = h.Company.Company(r.Context()); err != nil {
+			httperr.Write(w, err, "Failed to load company profile")
+			return
+		}
+	}
+
+	if err := documents.Write(w, r, payslips.Document(payslip, company)); err != nil {
+		httperr.Write(w, err, "Failed to render payslip")
+	}
+}
+
+// GetPayrollSummary returns an employee's gross pay, tax withheld, benefits and net pay for
+// a year by month, with the year's totals, for annual tax statements. Employees may read
+// their own; HR and payroll staff everyone's.
+//
+// HTTP Method: GET
+// URL Path: /employees/{id}/payroll_summary?year=2024 (the current year by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with the PayrollSummary in JSON.
+//   - Status Code: 403 (Forbidden) if the summary is another employee's.
+//   - Status Code: 404 (Not Found) if the employee does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the year is invalid.
+//   - Status Code: 500 (Internal Server Error) if the summary cannot be read.
+func (h *PayslipHandler) GetPayrollSummary(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	role, _ := middleware.GetUserRoleFromContext(r.Context())
+	privileged := false
+	if role != "" {
+		var err error
+		if privileged, err = h.Access.Allowed(r.Context(), role, PayrollPermissions...); err != nil {
+			httperr.Write(w, err, "Failed to check permissions")
+			return
+		}
+	}
+	summary, err := h.Service.Summary(r.Context(), id, r.URL.Query().Get("year"), email, privileged)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load payroll summary")
+		return
+	}
+	respond.JSON(w, http.StatusOK, summary)
+}
+
+// ListMyPayslips lists the signed-in employee's payslips, newest first.
+//
+// HTTP Method: GET
+// URL Path: /payroll/payslips/mine
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of Payslips in JSON.
+//   - Status Code: 500 (Internal Server Error) if the payslips cannot be read.
+func (h *PayslipHandler) ListMyPayslips(w http.ResponseWriter, r *http.Request) {
+	email, _ := middleware.GetUserEmailFromContext(r.Context())
+	list, err := h.Service.Mine(r.Context(), email)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load payslips")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// ListPayslips lists every employee's payslips for a period.
+//
+// HTTP Method: GET
+// URL Path: /payroll/payslips?period=2024-05
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of Payslips in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid.
+//   - Status Code: 500 (Internal Server Error) if the payslips cannot be read.
+func (h *PayslipHandler) ListPayslips(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Service.Payslips(r.Context(), r.URL.Query().Get("period"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load payslips")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// EmailPayslips emails every employee with a payslip for a period a link to download it.
+// It is sent after the period's payroll is recorded.
+//
+// HTTP Method: POST
+// URL Path: /payroll/payslips/emails
+//
+// Request Body:
+//   - JSON object with "period" (YYYY-MM).
+//
+// Response:
+//   - Status Code: 200 (OK) with {"sent": n}.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid.
+//   - Status Code: 500 (Internal Server Error) if the emails cannot be queued.
+func (h *PayslipHandler) EmailPayslips(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	sent, err := h.Service.Email(r.Context(), req.Period)
+	if err != nil {
+		httperr.Write(w, err, "Failed to email payslips")
+		return
+	}
+	respond.JSON(w, http.StatusOK, map[string]int{"sent": sent})
+}
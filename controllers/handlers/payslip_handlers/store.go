@@ -0,0 +1,153 @@
+package payslip_handlers
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"erp/models"
+)
+
+// DBPayslipStore provides SQL-backed methods for reading payslips from statutory records.
+type DBPayslipStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// payslipColumns are the columns scanned by scanPayslip.
+const payslipColumns = `r.id, r.user_id, u.name, u.email, COALESCE(u.department, ''), r.period, r.gross,
+	r.total_employee, r.net, r.total_employer, r.recorded_at`
+
+// scanPayslip reads a row selected with payslipColumns.
+func scanPayslip(row interface{ Scan(...interface{}) error }) (*models.Payslip, error) {
+	var payslip models.Payslip
+	if err := row.Scan(&payslip.ID, &payslip.UserID, &payslip.Employee, &payslip.Email, &payslip.Department,
+		&payslip.Period, &payslip.Gross, &payslip.TotalDeductions, &payslip.Net, &payslip.Employer,
+		&payslip.RecordedAt); err != nil {
+		return nil, err
+	}
+	return &payslip, nil
+}
+
+// GetPayslip retrieves a payslip with its deductions and the employee's totals for the
+// year up to and including its period.
+//
+// Parameters:
+//   - id: The ID of the statutory record.
+//
+// Returns:
+//   - *models.Payslip: The payslip.
+//   - error: models.ErrNotFound if it does not exist, or an error if a query fails.
+func (store *DBPayslipStore) GetPayslip(ctx context.Context, id int) (*models.Payslip, error) {
+	payslip, err := scanPayslip(store.DB.QueryRowContext(ctx,
+		`SELECT `+payslipColumns+` FROM statutory_records r JOIN users u ON u.id = r.user_id WHERE r.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("payslip %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	payslip.YearToDate = &models.PayslipTotals{}
+	err = store.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(gross), 0), COALESCE(SUM(total_employee), 0), COALESCE(SUM(net), 0),
+		     COALESCE(SUM(total_employer), 0)
+		 FROM statutory_records
+		 WHERE user_id = $1 AND LEFT(period, 4) = LEFT($2, 4) AND period <= $2`,
+		payslip.UserID, payslip.Period,
+	).Scan(&payslip.YearToDate.Gross, &payslip.YearToDate.Deductions, &payslip.YearToDate.Net,
+		&payslip.YearToDate.Employer)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT code, name, kind, base, employee, employer FROM statutory_record_lines WHERE record_id = $1 ORDER BY code`,
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	payslip.Deductions = []models.StatutoryLine{}
+	for rows.Next() {
+		var line models.StatutoryLine
+		if err := rows.Scan(&line.Code, &line.Name, &line.Kind, &line.Base, &line.Employee, &line.Employer); err != nil {
+			return nil, err
+		}
+		payslip.Deductions = append(payslip.Deductions, line)
+	}
+	return payslip, rows.Err()
+}
+
+// GetPayslips lists payslips without their deductions, newest period first.
+//
+// Parameters:
+//   - period: The pay period, or "" for every period.
+//   - email: The employee's email, or "" for every employee.
+//
+// Returns:
+//   - []models.Payslip: The payslips.
+//   - error: An error if the query fails.
+func (store *DBPayslipStore) GetPayslips(ctx context.Context, period, email string) ([]models.Payslip, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT `+payslipColumns+` FROM statutory_records r JOIN users u ON u.id = r.user_id
+		 WHERE ($1 = '' OR r.period = $1) AND ($2 = '' OR LOWER(u.email) = LOWER($2))
+		 ORDER BY r.period DESC, u.name`, period, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payslips := []models.Payslip{}
+	for rows.Next() {
+		payslip, err := scanPayslip(rows)
+		if err != nil {
+			return nil, err
+		}
+		payslips = append(payslips, *payslip)
+	}
+	return payslips, rows.Err()
+}
+
+// GetPayrollSummary retrieves an employee's pay for a year by month. Income tax lines are
+// the tax withheld; the other statutory lines withheld from the employee are benefits.
+//
+// Parameters:
+//   - userID: The employee.
+//   - year: The year.
+//
+// Returns:
+//   - *models.PayrollSummary: The employee and the months they were paid, without totals.
+//   - error: models.ErrNotFound if the user does not exist, or an error if a query fails.
+func (store *DBPayslipStore) GetPayrollSummary(ctx context.Context, userID, year int) (*models.PayrollSummary, error) {
+	summary := models.PayrollSummary{UserID: userID, Year: year, Months: []models.PayrollMonth{}}
+	err := store.DB.QueryRowContext(ctx, `SELECT name, email, COALESCE(department, '') FROM users WHERE id = $1`, userID).
+		Scan(&summary.Employee, &summary.Email, &summary.Department)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("user %d not found", userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT r.period, r.gross,
+		     COALESCE(SUM(l.employee) FILTER (WHERE l.kind = $3), 0),
+		     COALESCE(SUM(l.employee) FILTER (WHERE l.kind <> $3), 0),
+		     r.net, r.total_employer
+		 FROM statutory_records r LEFT JOIN statutory_record_lines l ON l.record_id = r.id
+		 WHERE r.user_id = $1 AND LEFT(r.period, 4) = $2
+		 GROUP BY r.id ORDER BY r.period`,
+		userID, strconv.Itoa(year), models.StatutoryIncomeTax)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var month models.PayrollMonth
+		if err := rows.Scan(&month.Period, &month.Gross, &month.Tax, &month.Benefits, &month.Net, &month.Employer); err != nil {
+			return nil, err
+		}
+		summary.Months = append(summary.Months, month)
+	}
+	return &summary, rows.Err()
+}
@@ -0,0 +1,84 @@
+// Package pos_handlers provides the point of sale endpoint used by retail counters.
+package pos_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"erp/controllers/httperr"
+	"erp/controllers/pos"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// POSHandlers provides the endpoints under /pos.
+type POSHandlers struct {
+	Service *pos.Service
+}
+
+// SaleRequest is the request body for recording a POS sale. Prices and totals are set by
+// the server.
+type SaleRequest struct {
+	Register      string            `json:"register"`
+	WarehouseID   int               `json:"warehouse_id"`
+	CustomerID    *int              `json:"customer_id"`
+	Lines         []SaleLineRequest `json:"lines"`
+	PaymentMethod string            `json:"payment_method"`
+	Tendered      float64           `json:"tendered"`
+}
+
+// SaleLineRequest is one product of a SaleRequest.
+type SaleLineRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// Sale returns the sale described by the request.
+func (req SaleRequest) Sale() models.POSSale {
+	lines := make([]models.POSSaleLine, len(req.Lines))
+	for i, line := range req.Lines {
+		lines[i] = models.POSSaleLine{ProductID: line.ProductID, Quantity: line.Quantity}
+	}
+	return models.POSSale{
+		Register:      req.Register,
+		WarehouseID:   req.WarehouseID,
+		CustomerID:    req.CustomerID,
+		Lines:         lines,
+		PaymentMethod: req.PaymentMethod,
+		Tendered:      req.Tendered,
+	}
+}
+
+// RecordSale records a paid sale in one call: the stock is taken from the register's
+// warehouse, the payment is recorded and the total is posted to the ledger.
+//
+// HTTP Method: POST
+// URL Path: /pos/sales
+//
+// Request Body:
+//   - JSON with register, warehouse_id, optional customer_id, lines of product_id and
+//     quantity, payment_method ("cash" or "card") and, for cash, the amount tendered
+//     (see SaleRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the priced sale, including the change due, in JSON format.
+//   - Status Code: 400 (Bad Request) if the payload is invalid.
+//   - Status Code: 409 (Conflict) if a product is not in stock in the quantity sold.
+//   - Status Code: 422 (Unprocessable Entity) if the sale is incomplete, too little cash is
+//     tendered or the customer or warehouse does not exist.
+//   - Status Code: 500 (Internal Server Error) if the sale could not be recorded.
+func (h *POSHandlers) RecordSale(w http.ResponseWriter, r *http.Request) {
+	var req SaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	sale := req.Sale()
+	if err := h.Service.RecordSale(r.Context(), &sale); err != nil {
+		httperr.Write(w, err, "Failed to record sale")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, sale)
+}
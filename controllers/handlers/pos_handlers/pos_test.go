@@ -0,0 +1,118 @@
+package pos_handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/controllers/pos"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestHandlers returns POS handlers backed by a DBPOSStore on a mock database.
+func newTestHandlers(t *testing.T) (*POSHandlers, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return &POSHandlers{Service: pos.NewService(&DBPOSStore{DB: db})}, mock
+}
+
+func postSale(h *POSHandlers, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.RecordSale(rec, httptest.NewRequest(http.MethodPost, "/pos/sales", bytes.NewBufferString(body)))
+	return rec
+}
+
+// TestRecordSale verifies that a sale takes stock for all lines in one statement, records
+// the sale with its lines in another and posts one summarized pair of ledger lines.
+func TestRecordSale(t *testing.T) {
+	h, mock := newTestHandlers(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE stock SET quantity").
+		WithArgs(pq.Int64Array{5, 8}, pq.Int64Array{3, 1}, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "price"}).AddRow(8, 10.0).AddRow(5, 2.5))
+	mock.ExpectQuery("INSERT INTO pos_sales").
+		WithArgs("front-1", 2, nil, 17.5, models.POSPaymentCash, 20.0, 2.5, sqlmock.AnyArg(),
+			pq.Int64Array{5, 8}, pq.Int64Array{3, 1}, pq.Float64Array{2.5, 10}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id"}).AddRow(41, 6))
+	for _, line := range []struct {
+		account string
+		amount  float64
+	}{{"cash", 17.5}, {"revenue", -17.5}} {
+		mock.ExpectQuery("INSERT INTO financial_transactions").
+			WithArgs(line.account, line.amount, sqlmock.AnyArg(), "POS sale #41 at register front-1", nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO account_period_balances").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	rec := postSale(h, `{"register": "front-1", "warehouse_id": 2, "payment_method": "cash", "tendered": 20,
+		"lines": [{"product_id": 5, "quantity": 2}, {"product_id": 8, "quantity": 1}, {"product_id": 5, "quantity": 1}]}`)
+
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var sale models.POSSale
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sale))
+	assert.Equal(t, 41, sale.ID)
+	assert.Equal(t, 6, *sale.SessionID)
+	assert.Equal(t, 17.5, sale.Total)
+	assert.Equal(t, 2.5, sale.Change)
+	assert.Equal(t, []models.POSSaleLine{
+		{ProductID: 5, Quantity: 3, UnitPrice: 2.5, Amount: 7.5},
+		{ProductID: 8, Quantity: 1, UnitPrice: 10, Amount: 10},
+	}, sale.Lines)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordSaleOutOfStock(t *testing.T) {
+	h, mock := newTestHandlers(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE stock SET quantity").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "price"}).AddRow(5, 2.5))
+	mock.ExpectRollback()
+
+	rec := postSale(h, `{"register": "front-1", "warehouse_id": 2, "payment_method": "card",
+		"lines": [{"product_id": 5, "quantity": 1}, {"product_id": 8, "quantity": 4}]}`)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "product 8")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Nothing is recorded and the stock update is rolled back")
+}
+
+func TestRecordSaleWithTooLittleCash(t *testing.T) {
+	h, mock := newTestHandlers(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE stock SET quantity").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "price"}).AddRow(5, 12.0))
+	mock.ExpectRollback()
+
+	rec := postSale(h, `{"register": "front-1", "warehouse_id": 2, "payment_method": "cash", "tendered": 10,
+		"lines": [{"product_id": 5, "quantity": 1}]}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordSaleForUnknownCustomer(t *testing.T) {
+	h, mock := newTestHandlers(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE stock SET quantity").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "price"}).AddRow(5, 12.0))
+	mock.ExpectQuery("INSERT INTO pos_sales").
+		WillReturnError(&pq.Error{Code: "23503", Constraint: "pos_sales_customer_id_fkey"})
+	mock.ExpectRollback()
+
+	rec := postSale(h, `{"register": "front-1", "warehouse_id": 2, "customer_id": 99, "payment_method": "card",
+		"lines": [{"product_id": 5, "quantity": 1}]}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "customer 99")
+}
@@ -0,0 +1,308 @@
+package pos_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/config"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+)
+
+// DBRegisterStore implements models.RegisterStore using a SQL database. Variances found at
+// closing are posted to the configured over and short accounts.
+type DBRegisterStore struct {
+	DB       *sql.DB
+	Accounts config.RegisterConfig
+}
+
+// OpenSession starts a session at a register.
+//
+// Parameters:
+//   - session: The session to start; its ID is populated.
+//
+// Returns:
+//   - error: models.ErrRegisterOpen if the register already has an open session, or the
+//     query error.
+func (s *DBRegisterStore) OpenSession(ctx context.Context, session *models.RegisterSession) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO register_sessions (register, opening_float, opened_by, opened_at)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (register) WHERE closed_at IS NULL DO NOTHING
+		 RETURNING id`,
+		session.Register, session.OpeningFloat, session.OpenedBy, session.OpenedAt,
+	).Scan(&session.ID)
+	if err == sql.ErrNoRows {
+		return models.ErrRegisterOpen
+	} else if err != nil {
+		return fmt.Errorf("failed to open register: %w", err)
+	}
+	return nil
+}
+
+// GetOpenSession returns the open session of a register with its movements, the cash
+// taken by sales so far and the cash expected in the drawer.
+//
+// Returns:
+//   - *models.RegisterSession: The open session.
+//   - error: models.ErrRegisterClosed if the register has no open session, or the query error.
+func (s *DBRegisterStore) GetOpenSession(ctx context.Context, register string) (*models.RegisterSession, error) {
+	session, err := scanSession(s.DB.QueryRowContext(ctx,
+		"SELECT "+sessionColumns+" FROM register_sessions WHERE register = $1 AND closed_at IS NULL", register,
+	))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrRegisterClosed
+	} else if err != nil {
+		return nil, err
+	}
+	if err := loadSessionCash(ctx, s.DB, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// AddCashMovement records a pay-in or pay-out in the register's open session.
+//
+// Parameters:
+//   - register: The register's name.
+//   - movement: The movement to record; its ID and session ID are populated.
+//
+// Returns:
+//   - error: models.ErrRegisterClosed if the register has no open session, or the query error.
+func (s *DBRegisterStore) AddCashMovement(ctx context.Context, register string, movement *models.CashMovement) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO register_cash_movements (session_id, kind, amount, reason, created_by, created_at)
+		 SELECT id, $2, $3, $4, $5, $6 FROM register_sessions
+		 WHERE register = $1 AND closed_at IS NULL
+		 RETURNING id, session_id`,
+		register, movement.Kind, movement.Amount, movement.Reason, movement.CreatedBy, movement.CreatedAt,
+	).Scan(&movement.ID, &movement.SessionID)
+	if err == sql.ErrNoRows {
+		return models.ErrRegisterClosed
+	} else if err != nil {
+		return fmt.Errorf("failed to record cash movement: %w", err)
+	}
+	return nil
+}
+
+// CloseSession closes the register's open session with the cash counted in the drawer.
+// The expected cash and the variance are stored with the session, and a variance is
+// posted against cash: an overage to the over account, a shortage to the short account.
+// The session is locked first, so sales recorded at the register meanwhile are either
+// counted or recorded without a session.
+//
+// Returns:
+//   - *models.RegisterSession: The closed session.
+//   - error: models.ErrRegisterClosed if the register has no open session, or the query error.
+func (s *DBRegisterStore) CloseSession(ctx context.Context, register string, countedCash float64, closedBy string, closedAt time.Time) (*models.RegisterSession, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	session, err := scanSession(tx.QueryRowContext(ctx,
+		"SELECT "+sessionColumns+" FROM register_sessions WHERE register = $1 AND closed_at IS NULL FOR UPDATE", register,
+	))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrRegisterClosed
+	} else if err != nil {
+		return nil, err
+	}
+	if err := loadSessionCash(ctx, tx, session); err != nil {
+		return nil, err
+	}
+
+	variance := roundCents(countedCash - session.ExpectedCash)
+	session.CountedCash = &countedCash
+	session.Variance = &variance
+	session.ClosedBy = closedBy
+	session.ClosedAt = &closedAt
+	_, err = tx.ExecContext(ctx,
+		`UPDATE register_sessions
+		 SET expected_cash = $1, counted_cash = $2, variance = $3, closed_by = $4, closed_at = $5
+		 WHERE id = $6`,
+		session.ExpectedCash, countedCash, variance, closedBy, closedAt, session.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if variance != 0 {
+		debit, credit, amount := "cash", s.Accounts.OverAccount, variance
+		if variance < 0 {
+			debit, credit, amount = s.Accounts.ShortAccount, "cash", -variance
+		}
+		description := fmt.Sprintf("Register %s session #%d over/short", register, session.ID)
+		lines := []models.FinancialTransaction{
+			{AccountType: debit, Amount: amount},
+			{AccountType: credit, Amount: -amount},
+		}
+		for i := range lines {
+			lines[i].TransactionDate = closedAt
+			lines[i].Description = description
+			if err := general_ledger_handlers.InsertTransaction(ctx, tx, &lines[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetZReport summarizes a register's day: its sales by payment method and the sessions
+// opened that day. Closed sessions report the figures stored at closing; open sessions
+// report the cash expected so far.
+//
+// Parameters:
+//   - register: The register's name.
+//   - date: Any time on the day to report, in the location that defines the day.
+//
+// Returns:
+//   - *models.ZReport: The report; a register without activity has an empty report.
+//   - error: An error if a query fails.
+func (s *DBRegisterStore) GetZReport(ctx context.Context, register string, date time.Time) (*models.ZReport, error) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	to := from.AddDate(0, 0, 1)
+	report := &models.ZReport{
+		Register: register,
+		Date:     from.Format("2006-01-02"),
+		Sales:    map[string]float64{},
+		Sessions: []models.RegisterSession{},
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT payment_method, COUNT(*), COALESCE(SUM(total), 0) FROM pos_sales
+		 WHERE register = $1 AND created_at >= $2 AND created_at < $3
+		 GROUP BY payment_method`,
+		register, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load register sales: %w", err)
+	}
+	for rows.Next() {
+		var method string
+		var count int
+		var total float64
+		if err := rows.Scan(&method, &count, &total); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Sales[method] = total
+		report.SalesCount += count
+		report.Total += total
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	report.Total = roundCents(report.Total)
+
+	rows, err = s.DB.QueryContext(ctx,
+		"SELECT "+sessionColumns+" FROM register_sessions WHERE register = $1 AND opened_at >= $2 AND opened_at < $3 ORDER BY opened_at, id",
+		register, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load register sessions: %w", err)
+	}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Sessions = append(report.Sessions, *session)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range report.Sessions {
+		session := &report.Sessions[i]
+		if err := loadSessionCash(ctx, s.DB, session); err != nil {
+			return nil, err
+		}
+		if session.Variance != nil {
+			report.Variance += *session.Variance
+		}
+	}
+	report.Variance = roundCents(report.Variance)
+	return report, nil
+}
+
+// sessionColumns are the register_sessions columns read by scanSession.
+const sessionColumns = "id, register, opening_float, opened_by, opened_at, counted_cash, variance, closed_by, closed_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSession reads the sessionColumns of a register session.
+func scanSession(row rowScanner) (*models.RegisterSession, error) {
+	var session models.RegisterSession
+	var counted, variance sql.NullFloat64
+	var closedBy sql.NullString
+	var closedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.Register, &session.OpeningFloat, &session.OpenedBy, &session.OpenedAt,
+		&counted, &variance, &closedBy, &closedAt)
+	if err != nil {
+		return nil, err
+	}
+	if counted.Valid {
+		session.CountedCash = &counted.Float64
+	}
+	if variance.Valid {
+		session.Variance = &variance.Float64
+	}
+	session.ClosedBy = closedBy.String
+	if closedAt.Valid {
+		session.ClosedAt = &closedAt.Time
+	}
+	return &session, nil
+}
+
+// loadSessionCash fills in a session's movements, the cash taken by its sales and the
+// cash expected in its drawer.
+func loadSessionCash(ctx context.Context, q models.Queryer, session *models.RegisterSession) error {
+	err := q.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(total), 0) FROM pos_sales WHERE session_id = $1 AND payment_method = $2",
+		session.ID, models.POSPaymentCash,
+	).Scan(&session.CashSales)
+	if err != nil {
+		return fmt.Errorf("failed to load register cash sales: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, session_id, kind, amount, reason, created_by, created_at
+		 FROM register_cash_movements WHERE session_id = $1 ORDER BY created_at, id`,
+		session.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load register cash movements: %w", err)
+	}
+	defer rows.Close()
+
+	expected := session.OpeningFloat + session.CashSales
+	session.Movements = []models.CashMovement{}
+	for rows.Next() {
+		var m models.CashMovement
+		if err := rows.Scan(&m.ID, &m.SessionID, &m.Kind, &m.Amount, &m.Reason, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return err
+		}
+		if m.Kind == models.CashPayOut {
+			expected -= m.Amount
+		} else {
+			expected += m.Amount
+		}
+		session.Movements = append(session.Movements, m)
+	}
+	session.ExpectedCash = roundCents(expected)
+	return rows.Err()
+}
@@ -0,0 +1,176 @@
+package pos_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/pos"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterHandlers provides the cash register endpoints under /pos/registers/{register}.
+type RegisterHandlers struct {
+	Service *pos.RegisterService
+}
+
+// OpenSessionRequest is the request body for opening a register.
+type OpenSessionRequest struct {
+	OpeningFloat float64 `json:"opening_float"`
+}
+
+// CashMovementRequest is the request body for a pay-in or pay-out.
+type CashMovementRequest struct {
+	Kind   string  `json:"kind"`
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+// Movement returns the movement described by the request, made by actor.
+func (req CashMovementRequest) Movement(actor string) models.CashMovement {
+	return models.CashMovement{Kind: req.Kind, Amount: req.Amount, Reason: req.Reason, CreatedBy: actor}
+}
+
+// CloseSessionRequest is the request body for closing a register.
+type CloseSessionRequest struct {
+	CountedCash *float64 `json:"counted_cash"`
+}
+
+// OpenSession opens a register with a float of cash in the drawer.
+//
+// HTTP Method: POST
+// URL Path: /pos/registers/{register}/sessions
+//
+// Request Body:
+//   - JSON with the "opening_float".
+//
+// Response:
+//   - Status Code: 201 (Created) with the open session in JSON format.
+//   - Status Code: 400 (Bad Request) if the payload is invalid.
+//   - Status Code: 409 (Conflict) if the register is already open.
+//   - Status Code: 422 (Unprocessable Entity) if the float is negative.
+//   - Status Code: 500 (Internal Server Error) if the register could not be opened.
+func (h *RegisterHandlers) OpenSession(w http.ResponseWriter, r *http.Request) {
+	var req OpenSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	session, err := h.Service.Open(r.Context(), mux.Vars(r)["register"], req.OpeningFloat, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to open register")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, session)
+}
+
+// GetOpenSession returns a register's open session with its cash movements and the cash
+// expected in the drawer.
+//
+// HTTP Method: GET
+// URL Path: /pos/registers/{register}/session
+//
+// Response:
+//   - Status Code: 200 (OK) with the session in JSON format.
+//   - Status Code: 404 (Not Found) if the register is not open.
+//   - Status Code: 500 (Internal Server Error) if the session could not be loaded.
+func (h *RegisterHandlers) GetOpenSession(w http.ResponseWriter, r *http.Request) {
+	session, err := h.Service.Current(r.Context(), mux.Vars(r)["register"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to load register session")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, session)
+}
+
+// AddCashMovement records cash put into ("pay_in") or taken out of ("pay_out") an open
+// register outside of sales.
+//
+// HTTP Method: POST
+// URL Path: /pos/registers/{register}/session/movements
+//
+// Request Body:
+//   - JSON with kind, amount and reason (see CashMovementRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the movement in JSON format.
+//   - Status Code: 400 (Bad Request) if the payload is invalid.
+//   - Status Code: 404 (Not Found) if the register is not open.
+//   - Status Code: 422 (Unprocessable Entity) if the kind, amount or reason is invalid.
+//   - Status Code: 500 (Internal Server Error) if the movement could not be recorded.
+func (h *RegisterHandlers) AddCashMovement(w http.ResponseWriter, r *http.Request) {
+	var req CashMovementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	movement := req.Movement(actor)
+	if err := h.Service.Move(r.Context(), mux.Vars(r)["register"], &movement); err != nil {
+		httperr.Write(w, err, "Failed to record cash movement")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, movement)
+}
+
+// CloseSession closes a register with the cash counted in the drawer. The variance from
+// the expected cash is returned and posted to the over or short account.
+//
+// HTTP Method: POST
+// URL Path: /pos/registers/{register}/session/close
+//
+// Request Body:
+//   - JSON with the "counted_cash".
+//
+// Response:
+//   - Status Code: 200 (OK) with the closed session, including its variance, in JSON format.
+//   - Status Code: 400 (Bad Request) if the payload is invalid or has no counted_cash.
+//   - Status Code: 404 (Not Found) if the register is not open.
+//   - Status Code: 422 (Unprocessable Entity) if the counted cash is negative.
+//   - Status Code: 500 (Internal Server Error) if the register could not be closed.
+func (h *RegisterHandlers) CloseSession(w http.ResponseWriter, r *http.Request) {
+	var req CloseSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CountedCash == nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	session, err := h.Service.Close(r.Context(), mux.Vars(r)["register"], *req.CountedCash, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to close register")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, session)
+}
+
+// GetZReport returns a register's end-of-day report: sales by payment method and the
+// sessions opened that day with their expected and counted cash and variances.
+//
+// HTTP Method: GET
+// URL Path: /pos/registers/{register}/z_report?date=YYYY-MM-DD (today if omitted)
+//
+// Response:
+//   - Status Code: 200 (OK) with the report in JSON format.
+//   - Status Code: 422 (Unprocessable Entity) if the date is malformed.
+//   - Status Code: 500 (Internal Server Error) if the report could not be built.
+func (h *RegisterHandlers) GetZReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.ZReport(r.Context(), mux.Vars(r)["register"], r.URL.Query().Get("date"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to build Z-report")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, report)
+}
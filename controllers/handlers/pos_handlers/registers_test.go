@@ -0,0 +1,169 @@
+package pos_handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/controllers/pos"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sessionRows = []string{"id", "register", "opening_float", "opened_by", "opened_at", "counted_cash", "variance", "closed_by", "closed_at"}
+
+// newRegisterRouter serves the register routes from a DBRegisterStore on a mock database.
+func newRegisterRouter(t *testing.T) (*mux.Router, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	store := &DBRegisterStore{DB: db, Accounts: config.RegisterConfig{OverAccount: "cash_over", ShortAccount: "cash_short"}}
+	h := &RegisterHandlers{Service: pos.NewRegisterService(store)}
+	router := mux.NewRouter()
+	sub := router.PathPrefix("/pos/registers/{register}").Subrouter()
+	sub.HandleFunc("/sessions", h.OpenSession).Methods("POST")
+	sub.HandleFunc("/session", h.GetOpenSession).Methods("GET")
+	sub.HandleFunc("/session/movements", h.AddCashMovement).Methods("POST")
+	sub.HandleFunc("/session/close", h.CloseSession).Methods("POST")
+	sub.HandleFunc("/z_report", h.GetZReport).Methods("GET")
+	return router, mock
+}
+
+func serve(router *mux.Router, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+	return rec
+}
+
+// expectSessionCash expects the queries of loadSessionCash for a session with the given
+// cash sales and one pay-in and one pay-out.
+func expectSessionCash(mock sqlmock.Sqlmock, sessionID int, cashSales, payIn, payOut float64) {
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(total\\), 0\\) FROM pos_sales WHERE session_id").
+		WithArgs(sessionID, models.POSPaymentCash).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(cashSales))
+	at := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id, session_id, kind, amount, reason").WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "kind", "amount", "reason", "created_by", "created_at"}).
+			AddRow(1, sessionID, models.CashPayIn, payIn, "Change from the bank", "a@example.com", at).
+			AddRow(2, sessionID, models.CashPayOut, payOut, "Drop to the safe", "a@example.com", at))
+}
+
+func TestOpenSession(t *testing.T) {
+	router, mock := newRegisterRouter(t)
+	mock.ExpectQuery("INSERT INTO register_sessions").
+		WithArgs("front-1", 100.0, "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	rec := serve(router, "POST", "/pos/registers/front-1/sessions", `{"opening_float": 100}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var session models.RegisterSession
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	assert.Equal(t, 3, session.ID)
+	assert.Equal(t, 100.0, session.ExpectedCash)
+
+	mock.ExpectQuery("INSERT INTO register_sessions").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	rec = serve(router, "POST", "/pos/registers/front-1/sessions", `{"opening_float": 100}`)
+	assert.Equal(t, http.StatusConflict, rec.Code, "A register has one open session at a time")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddCashMovementToClosedRegister(t *testing.T) {
+	router, mock := newRegisterRouter(t)
+	mock.ExpectQuery("INSERT INTO register_cash_movements").WillReturnRows(sqlmock.NewRows([]string{"id", "session_id"}))
+
+	rec := serve(router, "POST", "/pos/registers/front-1/session/movements", `{"kind": "pay_out", "amount": 50, "reason": "Drop"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = serve(router, "POST", "/pos/registers/front-1/session/movements", `{"kind": "pay_out", "amount": 50}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "A reason is required")
+}
+
+// TestCloseSessionPostsShortage verifies that the expected cash is the float plus cash
+// sales and pay-ins less pay-outs, and that a shortage is posted from cash to the short
+// account.
+func TestCloseSessionPostsShortage(t *testing.T) {
+	router, mock := newRegisterRouter(t)
+	openedAt := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, register, .* FOR UPDATE").WithArgs("front-1").
+		WillReturnRows(sqlmock.NewRows(sessionRows).AddRow(3, "front-1", 100.0, "a@example.com", openedAt, nil, nil, nil, nil))
+	expectSessionCash(mock, 3, 240.5, 20, 150)
+	mock.ExpectExec("UPDATE register_sessions").
+		WithArgs(210.5, 208.0, -2.5, "", sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, line := range []struct {
+		account string
+		amount  float64
+	}{{"cash_short", 2.5}, {"cash", -2.5}} {
+		mock.ExpectQuery("INSERT INTO financial_transactions").
+			WithArgs(line.account, line.amount, sqlmock.AnyArg(), "Register front-1 session #3 over/short", nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO account_period_balances").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	rec := serve(router, "POST", "/pos/registers/front-1/session/close", `{"counted_cash": 208}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var session models.RegisterSession
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	assert.Equal(t, 210.5, session.ExpectedCash)
+	assert.Equal(t, -2.5, *session.Variance)
+	assert.NotNil(t, session.ClosedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloseSessionThatBalances(t *testing.T) {
+	router, mock := newRegisterRouter(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, register, .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows(sessionRows).AddRow(3, "front-1", 100.0, "a@example.com", time.Now(), nil, nil, nil, nil))
+	expectSessionCash(mock, 3, 50, 10, 10)
+	mock.ExpectExec("UPDATE register_sessions").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rec := serve(router, "POST", "/pos/registers/front-1/session/close", `{"counted_cash": 150}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet(), "Nothing is posted without a variance")
+
+	rec = serve(router, "POST", "/pos/registers/front-1/session/close", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "The counted cash is required")
+}
+
+func TestGetZReport(t *testing.T) {
+	router, mock := newRegisterRouter(t)
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.Local)
+	mock.ExpectQuery("SELECT payment_method, COUNT").WithArgs("front-1", day, day.AddDate(0, 0, 1)).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_method", "count", "sum"}).
+			AddRow(models.POSPaymentCash, 12, 240.5).
+			AddRow(models.POSPaymentCard, 8, 410.25))
+	mock.ExpectQuery("SELECT id, register, .* FROM register_sessions WHERE register").
+		WillReturnRows(sqlmock.NewRows(sessionRows).
+			AddRow(3, "front-1", 100.0, "a@example.com", day.Add(9*time.Hour), 208.0, -2.5, "b@example.com", day.Add(17*time.Hour)))
+	expectSessionCash(mock, 3, 240.5, 20, 150)
+
+	rec := serve(router, "GET", "/pos/registers/front-1/z_report?date=2024-05-06", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report models.ZReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, "2024-05-06", report.Date)
+	assert.Equal(t, 20, report.SalesCount)
+	assert.Equal(t, 650.75, report.Total)
+	assert.Equal(t, 410.25, report.Sales[models.POSPaymentCard])
+	require.Len(t, report.Sessions, 1)
+	assert.Equal(t, 210.5, report.Sessions[0].ExpectedCash)
+	assert.Equal(t, -2.5, report.Variance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	rec = serve(router, "GET", "/pos/registers/front-1/z_report?date=06/05/2024", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
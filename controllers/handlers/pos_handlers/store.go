@@ -0,0 +1,167 @@
+package pos_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/controllers/events"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// paymentAccounts are the ledger accounts debited for each POS payment method. Card
+// payments wait in a clearing account until the card processor settles them.
+var paymentAccounts = map[string]string{
+	models.POSPaymentCash: "cash",
+	models.POSPaymentCard: "card_clearing",
+}
+
+// DBPOSStore implements models.POSStore using a SQL database. A sale costs one statement
+// for the stock of all its lines, one for the sale and its lines, the ledger lines and its
+// outbox event, so its latency does not grow with the number of lines.
+type DBPOSStore struct {
+	DB *sql.DB
+}
+
+// RecordSale takes the stock of every line from the sale's warehouse, prices the lines at
+// the products' prices, records the sale and posts the total from the payment account to
+// revenue, in one transaction. A POSSaleRecorded event is written to the outbox.
+//
+// Parameters:
+//   - sale: A validated sale with one line per product; its ID, prices, total, change and
+//     time are filled in.
+//
+// Returns:
+//   - error: A conflict if a product is not in stock at the warehouse in the quantity sold,
+//     a validation error if too little cash is tendered or the customer does not exist,
+//     or the query error.
+func (s *DBPOSStore) RecordSale(ctx context.Context, sale *models.POSSale) error {
+	productIDs := make(pq.Int64Array, len(sale.Lines))
+	quantities := make(pq.Int64Array, len(sale.Lines))
+	for i, line := range sale.Lines {
+		productIDs[i] = int64(line.ProductID)
+		quantities[i] = int64(line.Quantity)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Take the stock of all lines at once. A line whose product is unknown or short at the
+	// warehouse updates no row; the outer quantity check is repeated after waiting for a
+	// concurrent sale of the same stock.
+	rows, err := tx.QueryContext(ctx,
+		`UPDATE stock SET quantity = stock.quantity - l.quantity
+		 FROM unnest($1::int[], $2::int[]) AS l(product_id, quantity)
+		 JOIN products p ON p.id = l.product_id
+		 WHERE stock.id = (
+		     SELECT s.id FROM stock s
+		     WHERE s.product_id = l.product_id AND s.warehouse_id = $3 AND s.quantity >= l.quantity
+		     ORDER BY s.quantity DESC, s.id LIMIT 1
+		 ) AND stock.quantity >= l.quantity
+		 RETURNING stock.product_id, p.price`,
+		productIDs, quantities, sale.WarehouseID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to take stock: %w", err)
+	}
+	prices := make(map[int]float64, len(sale.Lines))
+	for rows.Next() {
+		var productID int
+		var price float64
+		if err := rows.Scan(&productID, &price); err != nil {
+			rows.Close()
+			return err
+		}
+		prices[productID] = price
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sale.Total = 0
+	unitPrices := make(pq.Float64Array, len(sale.Lines))
+	for i := range sale.Lines {
+		line := &sale.Lines[i]
+		price, ok := prices[line.ProductID]
+		if !ok {
+			return models.Conflict("product %d is not in stock at warehouse %d in a quantity of %d", line.ProductID, sale.WarehouseID, line.Quantity)
+		}
+		line.UnitPrice = price
+		line.Amount = roundCents(price * float64(line.Quantity))
+		unitPrices[i] = price
+		sale.Total += line.Amount
+	}
+	sale.Total = roundCents(sale.Total)
+
+	switch {
+	case sale.PaymentMethod == models.POSPaymentCard || sale.Tendered == 0:
+		sale.Tendered = sale.Total
+	case sale.Tendered < sale.Total:
+		return models.Invalid("%.2f tendered does not cover the total of %.2f", sale.Tendered, sale.Total)
+	}
+	sale.Change = roundCents(sale.Tendered - sale.Total)
+	sale.CreatedAt = time.Now()
+
+	// The sale is counted against the register's open session, if any. The session row is
+	// share-locked so a concurrent close waits for the sale and includes it.
+	var sessionID sql.NullInt64
+	err = tx.QueryRowContext(ctx,
+		`WITH sale AS (
+		     INSERT INTO pos_sales (register, warehouse_id, customer_id, total, payment_method, tendered, change_due, created_at, session_id)
+		     VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+		             (SELECT id FROM register_sessions WHERE register = $1 AND closed_at IS NULL FOR SHARE))
+		     RETURNING id, session_id
+		 ), lines AS (
+		     INSERT INTO pos_sale_lines (sale_id, product_id, quantity, unit_price)
+		     SELECT sale.id, l.product_id, l.quantity, l.unit_price
+		     FROM sale, unnest($9::int[], $10::int[], $11::numeric[]) AS l(product_id, quantity, unit_price)
+		 )
+		 SELECT id, session_id FROM sale`,
+		sale.Register, sale.WarehouseID, sale.CustomerID, sale.Total, sale.PaymentMethod, sale.Tendered, sale.Change, sale.CreatedAt,
+		productIDs, quantities, unitPrices,
+	).Scan(&sale.ID, &sessionID)
+	if models.IsForeignKeyViolation(err, "pos_sales_customer_id_fkey") {
+		return models.Invalid("customer %d does not exist", *sale.CustomerID)
+	} else if models.IsForeignKeyViolation(err, "pos_sales_warehouse_id_fkey") {
+		return models.Invalid("warehouse %d does not exist", sale.WarehouseID)
+	} else if err != nil {
+		return fmt.Errorf("failed to record sale: %w", err)
+	}
+	if sessionID.Valid {
+		id := int(sessionID.Int64)
+		sale.SessionID = &id
+	}
+
+	// One summarized pair of ledger lines per sale rather than one per product
+	description := fmt.Sprintf("POS sale #%d at register %s", sale.ID, sale.Register)
+	lines := []models.FinancialTransaction{
+		{AccountType: paymentAccounts[sale.PaymentMethod], Amount: sale.Total},
+		{AccountType: "revenue", Amount: -sale.Total},
+	}
+	for i := range lines {
+		lines[i].TransactionDate = sale.CreatedAt
+		lines[i].Description = description
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &lines[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := events.Enqueue(ctx, tx, events.POSSaleRecorded, "pos_sale", sale.ID, sale); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// roundCents rounds an amount to whole cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,127 @@
+package preference_handlers
+
+import (
+	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// PreferenceHandler provides HTTP handlers for the signed-in user's preferences.
+type PreferenceHandler struct {
+	Store models.PreferenceStore
+}
+
+// RegisterRoutes maps preference routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the PreferenceStore interface.
+func RegisterRoutes(router *mux.Router, store models.PreferenceStore) {
+	handler := &PreferenceHandler{Store: store}
+
+	router.HandleFunc("/preferences", handler.GetPreferences).Methods("GET")
+	router.HandleFunc("/preferences", handler.UpdatePreferences).Methods("PUT")
+}
+
+// GetPreferences returns the user's preferences, or the defaults if none are saved.
+//
+// HTTP Method: GET
+// URL Path: /preferences
+//
+// Response:
+//   - Status Code: 200 (OK) with the preferences in JSON.
+//   - Status Code: 401 (Unauthorized) if the user is unknown.
+func (h *PreferenceHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	preferences, err := h.Store.GetPreferences(r.Context(), userID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load preferences")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, preferences)
+}
+
+// UpdatePreferences replaces the user's preferences. Fields left out of the document are
+// reset to their defaults.
+//
+// HTTP Method: PUT
+// URL Path: /preferences
+//
+// Request Body:
+//   - JSON with default_warehouse_id, date_format, landing_page and notification_channels.
+//
+// Response:
+//   - Status Code: 200 (OK) with the saved preferences in JSON.
+//   - Status Code: 400 (Bad Request) if the document has unknown fields or unsupported values.
+//   - Status Code: 401 (Unauthorized) if the user is unknown.
+func (h *PreferenceHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	preferences := models.DefaultPreferences()
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(preferences); err != nil {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("Invalid preferences: %v", err))
+		return
+	}
+	if preferences.NotificationChannels == nil {
+		preferences.NotificationChannels = []string{}
+	}
+	if err := preferences.Validate(); err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if preferences.DefaultWarehouseID != nil {
+		exists, err := h.Store.WarehouseExists(r.Context(), *preferences.DefaultWarehouseID)
+		if err != nil {
+			httperr.Write(w, err, "Failed to check warehouse")
+			return
+		}
+		if !exists {
+			respond.Error(w, http.StatusBadRequest, "default_warehouse_id does not refer to an existing warehouse")
+			return
+		}
+	}
+
+	if err := h.Store.SavePreferences(r.Context(), userID, preferences); err != nil {
+		httperr.Write(w, err, "Failed to save preferences")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, preferences)
+}
+
+// currentUser resolves the authenticated user, writing an error response if it fails.
+func (h *PreferenceHandler) currentUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	email, err := middleware.GetUserEmailFromContext(r.Context())
+	if err != nil {
+		respond.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return 0, false
+	}
+	userID, err := h.Store.GetUserIDByEmail(r.Context(), email)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return 0, false
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to load user")
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,88 @@
+package preference_handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockPreferenceStore keeps preferences in memory.
+type mockPreferenceStore struct {
+	saved map[int]*models.UserPreferences
+}
+
+func (m *mockPreferenceStore) GetUserIDByEmail(ctx context.Context, email string) (int, error) {
+	if email != "alice@example.com" {
+		return 0, models.ErrNotFound
+	}
+	return 1, nil
+}
+
+func (m *mockPreferenceStore) GetPreferences(ctx context.Context, userID int) (*models.UserPreferences, error) {
+	if p, ok := m.saved[userID]; ok {
+		return p, nil
+	}
+	return models.DefaultPreferences(), nil
+}
+
+func (m *mockPreferenceStore) SavePreferences(ctx context.Context, userID int, preferences *models.UserPreferences) error {
+	m.saved[userID] = preferences
+	return nil
+}
+
+func (m *mockPreferenceStore) WarehouseExists(ctx context.Context, id int) (bool, error) {
+	return id == 3, nil
+}
+
+func doRequest(router *mux.Router, method, email, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/me/preferences", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmail, email))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPreferences(t *testing.T) {
+	store := &mockPreferenceStore{saved: map[int]*models.UserPreferences{}}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/me").Subrouter(), store)
+
+	rr := doRequest(router, "GET", "alice@example.com", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"default_warehouse_id":null,"date_format":"YYYY-MM-DD","landing_page":"dashboard","notification_channels":["in_app"]}`, rr.Body.String())
+
+	rr = doRequest(router, "PUT", "alice@example.com", `{"default_warehouse_id":3,"date_format":"DD/MM/YYYY","notification_channels":["in_app","email"]}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = doRequest(router, "GET", "alice@example.com", "")
+	assert.JSONEq(t, `{"default_warehouse_id":3,"date_format":"DD/MM/YYYY","landing_page":"dashboard","notification_channels":["in_app","email"]}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, doRequest(router, "GET", "mallory@example.com", "").Code)
+}
+
+func TestUpdatePreferencesValidation(t *testing.T) {
+	store := &mockPreferenceStore{saved: map[int]*models.UserPreferences{}}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/me").Subrouter(), store)
+
+	for _, body := range []string{
+		`{"theme":"dark"}`,
+		`{"date_format":"yesterday"}`,
+		`{"landing_page":"/admin"}`,
+		`{"notification_channels":["sms"]}`,
+		`{"notification_channels":["email","email"]}`,
+		`{"default_warehouse_id":99}`,
+	} {
+		rr := doRequest(router, "PUT", "alice@example.com", body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+	assert.Empty(t, store.saved)
+}
@@ -0,0 +1,95 @@
+// Package preference_handlers provides SQL-backed methods and HTTP handlers for the
+// signed-in user's preferences.
+package preference_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/models"
+	"time"
+)
+
+// DBPreferenceStore provides SQL-backed methods for the user_preferences table.
+type DBPreferenceStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// GetUserIDByEmail looks up a user's ID by email.
+//
+// Parameters:
+//   - email: The email of the user.
+//
+// Returns:
+//   - int: The user's ID.
+//   - error: models.ErrNotFound if no user has the email.
+func (store *DBPreferenceStore) GetUserIDByEmail(ctx context.Context, email string) (int, error) {
+	var id int
+	err := store.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", email).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, models.ErrNotFound
+	}
+	return id, err
+}
+
+// GetPreferences loads a user's preferences. Fields missing from the stored document,
+// for example ones added after it was saved, keep their default values.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - *UserPreferences: The saved preferences merged over the defaults.
+//   - error: An error if the query fails or the stored document is invalid.
+func (store *DBPreferenceStore) GetPreferences(ctx context.Context, userID int) (*models.UserPreferences, error) {
+	preferences := models.DefaultPreferences()
+
+	var document []byte
+	err := store.DB.QueryRowContext(ctx, "SELECT preferences FROM user_preferences WHERE user_id = $1", userID).Scan(&document)
+	if err == sql.ErrNoRows {
+		return preferences, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(document, preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// SavePreferences stores a user's preferences, replacing any saved before.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - preferences: The validated preferences.
+//
+// Returns:
+//   - error: An error if the preferences cannot be stored.
+func (store *DBPreferenceStore) SavePreferences(ctx context.Context, userID int, preferences *models.UserPreferences) error {
+	document, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.DB.ExecContext(ctx,
+		`INSERT INTO user_preferences (user_id, preferences, updated_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id) DO UPDATE SET preferences = EXCLUDED.preferences, updated_at = EXCLUDED.updated_at`,
+		userID, document, time.Now(),
+	)
+	return err
+}
+
+// WarehouseExists reports whether a warehouse with the given ID exists.
+//
+// Parameters:
+//   - id: The ID of the warehouse.
+//
+// Returns:
+//   - bool: true if the warehouse exists.
+//   - error: An error if the query fails.
+func (store *DBPreferenceStore) WarehouseExists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	err := store.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM warehouses WHERE id = $1)", id).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,143 @@
+package product_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/models"
+	"fmt"
+	"time"
+)
+
+// DBProductImageStore implements the ProductImageStore interface for database operations.
+type DBProductImageStore struct {
+	DB *sql.DB
+}
+
+// CreateProductImage inserts a product image record. The files themselves are stored in the
+// attachment backend before this is called.
+//
+// Parameters:
+// - image: A pointer to the ProductImage to insert; its ID and CreatedAt are populated.
+//
+// Returns:
+// - An error if the insertion fails, otherwise nil.
+func (s *DBProductImageStore) CreateProductImage(ctx context.Context, image *models.ProductImage) error {
+	thumbnails, err := json.Marshal(image.ThumbKeys)
+	if err != nil {
+		return err
+	}
+	image.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO product_images (product_id, filename, content_type, size_bytes, width, height, storage_key, thumbnails, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+	err = s.DB.QueryRowContext(ctx, query,
+		image.ProductID, image.Filename, image.ContentType, image.SizeBytes, image.Width, image.Height,
+		image.StorageKey, thumbnails, image.CreatedAt,
+	).Scan(&image.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert product image: %w", err)
+	}
+	return nil
+}
+
+// GetProductImages retrieves all images of a product, oldest first.
+//
+// Parameters:
+// - productID: The ID of the product.
+//
+// Returns:
+// - A slice of images, empty if the product has none.
+// - An error if the query fails.
+func (s *DBProductImageStore) GetProductImages(ctx context.Context, productID int) ([]models.ProductImage, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, product_id, filename, content_type, size_bytes, width, height, storage_key, thumbnails, created_at
+		FROM product_images
+		WHERE product_id = $1
+		ORDER BY id
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list product images: %w", err)
+	}
+	defer rows.Close()
+
+	images := []models.ProductImage{}
+	for rows.Next() {
+		image, err := scanProductImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, *image)
+	}
+	return images, rows.Err()
+}
+
+// GetProductImage retrieves one image of a product.
+//
+// Parameters:
+// - productID: The ID of the product.
+// - imageID: The ID of the image.
+//
+// Returns:
+// - A pointer to the image if found.
+// - models.ErrNotFound if the product has no such image, or another error if the query fails.
+func (s *DBProductImageStore) GetProductImage(ctx context.Context, productID, imageID int) (*models.ProductImage, error) {
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT id, product_id, filename, content_type, size_bytes, width, height, storage_key, thumbnails, created_at
+		FROM product_images
+		WHERE product_id = $1 AND id = $2
+	`, productID, imageID)
+	image, err := scanProductImage(row)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	return image, err
+}
+
+// DeleteProductImage removes an image record.
+//
+// Parameters:
+// - productID: The ID of the product.
+// - imageID: The ID of the image.
+//
+// Returns:
+// - models.ErrNotFound if the product has no such image, or another error if the deletion fails.
+func (s *DBProductImageStore) DeleteProductImage(ctx context.Context, productID, imageID int) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM product_images WHERE product_id = $1 AND id = $2", productID, imageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete product image: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProductImage reads one product_images row.
+func scanProductImage(row rowScanner) (*models.ProductImage, error) {
+	var image models.ProductImage
+	var thumbnails []byte
+	err := row.Scan(&image.ID, &image.ProductID, &image.Filename, &image.ContentType, &image.SizeBytes,
+		&image.Width, &image.Height, &image.StorageKey, &thumbnails, &image.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(thumbnails) > 0 {
+		if err := json.Unmarshal(thumbnails, &image.ThumbKeys); err != nil {
+			return nil, fmt.Errorf("failed to read thumbnails of image %d: %w", image.ID, err)
+		}
+	}
+	return &image, nil
+}
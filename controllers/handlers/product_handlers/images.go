@@ -0,0 +1,257 @@
+package product_handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"erp/controllers/antivirus"
+	"erp/controllers/httperr"
+	"erp/controllers/imaging"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/storage"
+	"erp/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ProductImageHandlers handles uploading and listing product images.
+type ProductImageHandlers struct {
+	ImageStore    models.ProductImageStore
+	ProductStore  models.ProductStore
+	Storage       storage.Storage    // Attachment backend holding the image files
+	MaxUploadSize int64              // Maximum accepted upload size in bytes
+	Antivirus     *antivirus.Service // Scans uploads before they are stored; nil disables scanning
+}
+
+// RegisterRoutes registers the product image routes.
+//
+// URL Paths:
+// - POST /products/{id}/images: Upload an image (multipart field "image")
+// - GET /products/{id}/images: List a product's images with thumbnail URLs
+// - DELETE /products/{id}/images/{imageID}: Delete an image and its thumbnails
+func (h *ProductImageHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/{id:[0-9]+}/images", h.UploadImage).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/images", h.GetImages).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/images/{imageID:[0-9]+}", h.DeleteImage).Methods("DELETE")
+}
+
+// UploadImage handles a multipart image upload for a product.
+//
+// The original is stored in the attachment backend together with a thumbnail in each of
+// the standard sizes, then the image record is saved.
+//
+// HTTP Method: POST
+// URL Path: /products/{id}/images
+//
+// Request Body:
+// - multipart/form-data with the image file in the "image" field (JPEG, PNG or GIF).
+//
+// Response:
+// - Status Code: 201 (Created) and the image with its URLs in JSON.
+// - Status Code: 400 (Bad Request) if the upload is missing, too large or not a supported image.
+// - Status Code: 404 (Not Found) if the product does not exist.
+// - Status Code: 422 (Unprocessable Entity) if the virus scanner flagged and quarantined the file.
+// - Status Code: 500 (Internal Server Error) if the image cannot be stored.
+// - Status Code: 503 (Service Unavailable) if the file cannot be scanned.
+func (h *ProductImageHandlers) UploadImage(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	_, err = h.ProductStore.GetProductByID(r.Context(), productID)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Could not load product")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadSize+1<<20) // Allow for multipart overhead
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "An image file is required in the \"image\" field")
+		return
+	}
+	defer file.Close()
+
+	data, err := storage.ReadAll(file, h.MaxUploadSize)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if h.Antivirus != nil {
+		actor, _ := middleware.GetUserEmailFromContext(r.Context())
+		err := h.Antivirus.Check(r.Context(), data, models.QuarantinedFile{
+			Source:      models.UploadProductImage,
+			EntityID:    productID,
+			FileName:    path.Base(header.Filename),
+			ContentType: contentType,
+			UploadedBy:  actor,
+		})
+		if errors.Is(err, antivirus.ErrUnavailable) {
+			respond.Error(w, http.StatusServiceUnavailable, "The file could not be scanned for viruses, try again later")
+			return
+		}
+		if err != nil {
+			httperr.Write(w, err, "Failed to scan image")
+			return
+		}
+	}
+	ext, ok := imaging.SupportedContentTypes[contentType]
+	if !ok {
+		respond.Error(w, http.StatusBadRequest, "Only JPEG, PNG and GIF images are supported")
+		return
+	}
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	thumbnails, err := imaging.Thumbnails(img, format, imaging.StandardSizes)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Could not generate thumbnails")
+		return
+	}
+
+	prefix := fmt.Sprintf("products/%d/%s", productID, randomKey())
+	image := &models.ProductImage{
+		ProductID:   productID,
+		Filename:    path.Base(header.Filename),
+		ContentType: contentType,
+		SizeBytes:   len(data),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		StorageKey:  prefix + "/original." + ext,
+		ThumbKeys:   make(map[string]string, len(thumbnails)),
+	}
+
+	if err := h.Storage.Put(image.StorageKey, data, contentType); err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Could not store image")
+		return
+	}
+	for _, thumb := range thumbnails {
+		key := prefix + "/" + thumb.Size.Name + "." + imaging.SupportedContentTypes[thumb.ContentType]
+		if err := h.Storage.Put(key, thumb.Data, thumb.ContentType); err != nil {
+			h.deleteFiles(image)
+			respond.Error(w, http.StatusInternalServerError, "Could not store thumbnail")
+			return
+		}
+		image.ThumbKeys[thumb.Size.Name] = key
+	}
+
+	if err := h.ImageStore.CreateProductImage(r.Context(), image); err != nil {
+		h.deleteFiles(image)
+		respond.Error(w, http.StatusInternalServerError, "Could not save image")
+		return
+	}
+
+	h.setURLs(image)
+	respond.JSON(w, http.StatusCreated, image)
+}
+
+// GetImages lists a product's images with the URLs of the originals and thumbnails.
+//
+// HTTP Method: GET
+// URL Path: /products/{id}/images
+//
+// Response:
+// - Status Code: 200 (OK) and the list of images in JSON.
+// - Status Code: 400 (Bad Request) if the ID is invalid.
+// - Status Code: 500 (Internal Server Error) if the images cannot be read.
+func (h *ProductImageHandlers) GetImages(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	images, err := h.ImageStore.GetProductImages(r.Context(), productID)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Could not load images")
+		return
+	}
+	for i := range images {
+		h.setURLs(&images[i])
+	}
+
+	respond.JSON(w, http.StatusOK, images)
+}
+
+// DeleteImage removes an image record and its files.
+//
+// HTTP Method: DELETE
+// URL Path: /products/{id}/images/{imageID}
+//
+// Response:
+// - Status Code: 204 (No Content) if the image was deleted.
+// - Status Code: 400 (Bad Request) if an ID is invalid.
+// - Status Code: 404 (Not Found) if the product has no such image.
+// - Status Code: 500 (Internal Server Error) if the deletion fails.
+func (h *ProductImageHandlers) DeleteImage(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	imageID, err := strconv.Atoi(mux.Vars(r)["imageID"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid image ID")
+		return
+	}
+
+	image, err := h.ImageStore.GetProductImage(r.Context(), productID, imageID)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Image not found")
+		return
+	}
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Could not load image")
+		return
+	}
+	if err := h.ImageStore.DeleteProductImage(r.Context(), productID, imageID); err != nil {
+		httperr.Write(w, err, "Could not delete image")
+		return
+	}
+	h.deleteFiles(image)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setURLs fills in the public URLs of an image and its thumbnails.
+func (h *ProductImageHandlers) setURLs(image *models.ProductImage) {
+	image.URL = h.Storage.URL(image.StorageKey)
+	image.Thumbnails = make(map[string]string, len(image.ThumbKeys))
+	for name, key := range image.ThumbKeys {
+		image.Thumbnails[name] = h.Storage.URL(key)
+	}
+}
+
+// deleteFiles removes an image's original and thumbnails from storage, logging failures.
+func (h *ProductImageHandlers) deleteFiles(image *models.ProductImage) {
+	keys := []string{image.StorageKey}
+	for _, key := range image.ThumbKeys {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if err := h.Storage.Delete(key); err != nil {
+			log.Printf("failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
+
+// randomKey returns a random hex string used to make storage keys unguessable.
+func randomKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,160 @@
+package product_handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"erp/controllers/handlers/product_handlers"
+	"erp/controllers/storage"
+	"erp/models"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockProductImageStore keeps product images in memory.
+type mockProductImageStore struct {
+	images map[int]*models.ProductImage
+	nextID int
+}
+
+func (m *mockProductImageStore) CreateProductImage(ctx context.Context, image *models.ProductImage) error {
+	m.nextID++
+	image.ID = m.nextID
+	m.images[image.ID] = image
+	return nil
+}
+
+func (m *mockProductImageStore) GetProductImages(ctx context.Context, productID int) ([]models.ProductImage, error) {
+	images := []models.ProductImage{}
+	for id := 1; id <= m.nextID; id++ {
+		if image, ok := m.images[id]; ok && image.ProductID == productID {
+			images = append(images, *image)
+		}
+	}
+	return images, nil
+}
+
+func (m *mockProductImageStore) GetProductImage(ctx context.Context, productID, imageID int) (*models.ProductImage, error) {
+	image, ok := m.images[imageID]
+	if !ok || image.ProductID != productID {
+		return nil, models.ErrNotFound
+	}
+	return image, nil
+}
+
+func (m *mockProductImageStore) DeleteProductImage(ctx context.Context, productID, imageID int) error {
+	if _, err := m.GetProductImage(ctx, productID, imageID); err != nil {
+		return err
+	}
+	delete(m.images, imageID)
+	return nil
+}
+
+// multipartImage builds a multipart body holding a width×height JPEG in the "image" field.
+func multipartImage(t *testing.T, width, height int) (*bytes.Buffer, string) {
+	var img bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, width, height)), nil))
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", "jacket.jpg")
+	assert.NoError(t, err)
+	part.Write(img.Bytes())
+	writer.Close()
+	return &body, writer.FormDataContentType()
+}
+
+// TestUploadAndListProductImages verifies that an upload stores the original and one
+// thumbnail per standard size, and that the listing returns their URLs.
+func TestUploadAndListProductImages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	files := storage.NewMemoryStorage()
+	imageStore := &mockProductImageStore{images: make(map[int]*models.ProductImage)}
+	handler := &product_handlers.ProductImageHandlers{
+		ImageStore:    imageStore,
+		ProductStore:  product_handlers.NewDBProductStore(db),
+		Storage:       files,
+		MaxUploadSize: 1 << 20,
+	}
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	mock.ExpectQuery("SELECT id, name, brand, season, price, unit_cost").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "season", "price", "unit_cost", "version"}).AddRow(1, "Jacket", "Acme", "Winter", 40.0, 22.5, 1))
+
+	body, contentType := multipartImage(t, 1200, 600)
+	req := httptest.NewRequest(http.MethodPost, "/products/1/images", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var uploaded models.ProductImage
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&uploaded))
+	assert.Equal(t, "image/jpeg", uploaded.ContentType)
+	assert.Equal(t, 1200, uploaded.Width)
+	assert.Len(t, uploaded.Thumbnails, 3)
+	assert.Len(t, files.Files, 4) // Original and three thumbnails
+
+	req = httptest.NewRequest(http.MethodGet, "/products/1/images", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var images []models.ProductImage
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&images))
+	assert.Len(t, images, 1)
+	assert.Equal(t, uploaded.URL, images[0].URL)
+	assert.Contains(t, images[0].Thumbnails["small"], "/small.jpg")
+
+	req = httptest.NewRequest(http.MethodDelete, "/products/1/images/1", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, files.Files)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUploadRejectsNonImages verifies that files other than JPEG, PNG and GIF are refused.
+func TestUploadRejectsNonImages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	handler := &product_handlers.ProductImageHandlers{
+		ImageStore:    &mockProductImageStore{images: make(map[int]*models.ProductImage)},
+		ProductStore:  product_handlers.NewDBProductStore(db),
+		Storage:       storage.NewMemoryStorage(),
+		MaxUploadSize: 1 << 20,
+	}
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	mock.ExpectQuery("SELECT id, name, brand, season, price, unit_cost").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "season", "price", "unit_cost", "version"}).AddRow(1, "Jacket", "Acme", "Winter", 40.0, 22.5, 1))
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "notes.txt")
+	part.Write([]byte("plain text"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/products/1/images", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,170 @@
+package product_handlers
+
+import (
+	"encoding/json"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PriceUpdateHandlers handles bulk price updates for products.
+type PriceUpdateHandlers struct {
+	Store models.PriceListStore
+}
+
+// PriceUpdate previews or applies a set of price rules to all matching products.
+//
+// With dry_run set, the proposed changes are returned and nothing is stored. Otherwise the
+// changes are applied in one transaction and audited, or, when effective_date is in the
+// future, added to the price list and applied by the scheduler on that date. The route must
+// be protected with JWTAuth; the user's email is recorded in the audit log.
+//
+// HTTP Method: POST
+// URL Path: /products/price-update
+//
+// Request Body:
+// - JSON representation of a PriceUpdateRequest.
+//
+// Response:
+// - Status Code: 200 (OK) and a PriceUpdateResult with the proposed or applied changes.
+// - Status Code: 400 (Bad Request) if the rules are invalid or a reason is missing.
+// - Status Code: 409 (Conflict) if a price changed while the update was applied.
+// - Status Code: 500 (Internal Server Error) if the update fails.
+func (h *PriceUpdateHandlers) PriceUpdate(w http.ResponseWriter, r *http.Request) {
+	var req models.PriceUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if err := validatePriceRules(req.Rules); err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if !req.DryRun && req.Reason == "" {
+		respond.Error(w, http.StatusBadRequest, "A reason is required to change prices")
+		return
+	}
+
+	products, err := h.Store.GetAllProducts(r.Context())
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "Could not load products")
+		return
+	}
+
+	result := models.PriceUpdateResult{
+		DryRun:        req.DryRun,
+		EffectiveDate: req.EffectiveDate,
+		Changes:       ProposePriceChanges(products, req.Rules),
+	}
+
+	if !req.DryRun && len(result.Changes) > 0 {
+		actor, _ := middleware.GetUserEmailFromContext(r.Context())
+		today := time.Now().Truncate(24 * time.Hour)
+		if req.EffectiveDate != nil && req.EffectiveDate.After(today) {
+			err = h.Store.SchedulePriceChanges(r.Context(), result.Changes, *req.EffectiveDate, req.Reason, actor)
+			result.Scheduled = true
+		} else {
+			err = h.Store.ApplyPriceChanges(r.Context(), result.Changes, req.Reason, actor)
+		}
+		if errors.Is(err, models.ErrStalePrice) {
+			respond.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			respond.Error(w, http.StatusInternalServerError, "Could not update prices")
+			return
+		}
+	}
+
+	respond.JSON(w, http.StatusOK, result)
+}
+
+// validatePriceRules checks that there is at least one rule and that every rule changes something.
+func validatePriceRules(rules []models.PriceRule) error {
+	if len(rules) == 0 {
+		return errors.New("at least one price rule is required")
+	}
+	for _, rule := range rules {
+		if rule.Percent == 0 && rule.Amount == 0 && rule.RoundTo == 0 {
+			return errors.New("each price rule needs a percent, amount or round_to")
+		}
+		if rule.RoundTo < 0 || rule.RoundTo >= 1 {
+			return errors.New("round_to must be a price ending between 0 and 1, e.g. 0.99")
+		}
+		if rule.Percent <= -100 {
+			return errors.New("percent must be greater than -100")
+		}
+	}
+	return nil
+}
+
+// ProposePriceChanges applies the rules in order to each product and returns the products
+// whose price would change.
+//
+// Parameters:
+// - products: The products to evaluate.
+// - rules: The price rules; a product may match several rules.
+//
+// Returns:
+// - The proposed changes, in product order.
+func ProposePriceChanges(products []models.Product, rules []models.PriceRule) []models.PriceChange {
+	changes := []models.PriceChange{}
+	for _, product := range products {
+		price := product.Price
+		for _, rule := range rules {
+			if ruleMatches(rule, product) {
+				price = applyPriceRule(rule, price)
+			}
+		}
+		if price < 0 {
+			price = 0
+		}
+		if price != product.Price {
+			changes = append(changes, models.PriceChange{
+				ProductID: product.ID,
+				Name:      product.Name,
+				OldPrice:  product.Price,
+				NewPrice:  price,
+			})
+		}
+	}
+	return changes
+}
+
+// ruleMatches reports whether a product satisfies all filters of a rule.
+func ruleMatches(rule models.PriceRule, product models.Product) bool {
+	if rule.Brand != "" && !strings.EqualFold(rule.Brand, product.Brand) {
+		return false
+	}
+	if rule.Season != "" && !strings.EqualFold(rule.Season, product.Season) {
+		return false
+	}
+	if len(rule.ProductIDs) > 0 {
+		for _, id := range rule.ProductIDs {
+			if id == product.ID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// applyPriceRule adjusts a price by the rule's percentage and amount, then rounds it up to
+// the rule's price ending (e.g. 10.20 becomes 10.99), or to whole cents otherwise.
+func applyPriceRule(rule models.PriceRule, price float64) float64 {
+	price = price * (1 + rule.Percent/100)
+	price += rule.Amount
+	price = math.Round(price*100) / 100
+	if rule.RoundTo > 0 {
+		price = math.Ceil(price-rule.RoundTo) + rule.RoundTo
+		price = math.Round(price*100) / 100
+	}
+	return price
+}
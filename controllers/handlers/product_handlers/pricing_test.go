@@ -0,0 +1,103 @@
+package product_handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"erp/controllers/handlers/product_handlers"
+	"erp/controllers/middleware"
+	"erp/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestProposePriceChanges verifies that rules are filtered by brand, applied in order and
+// rounded to the requested price ending.
+func TestProposePriceChanges(t *testing.T) {
+	products := []models.Product{
+		{ID: 1, Name: "Jacket", Brand: "Acme", Price: 40.00},
+		{ID: 2, Name: "Scarf", Brand: "Other", Price: 12.00},
+		{ID: 3, Name: "Boots", Brand: "acme", Price: 94.99},
+	}
+	rules := []models.PriceRule{
+		{Brand: "Acme", Percent: 5},
+		{Brand: "Acme", RoundTo: 0.99},
+	}
+
+	changes := product_handlers.ProposePriceChanges(products, rules)
+
+	assert.Len(t, changes, 2)
+	assert.Equal(t, 1, changes[0].ProductID)
+	assert.Equal(t, 42.99, changes[0].NewPrice) // 42.00 rounded up to .99
+	assert.Equal(t, 3, changes[1].ProductID)
+	assert.Equal(t, 99.99, changes[1].NewPrice) // 99.74 rounded up to .99
+}
+
+// priceUpdateRequest sends an authenticated price update request to the handler.
+func priceUpdateRequest(handler *product_handlers.PriceUpdateHandlers, body models.PriceUpdateRequest) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/products/price-update", bytes.NewReader(payload))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmail, "pricing@example.com"))
+	rec := httptest.NewRecorder()
+	handler.PriceUpdate(rec, req)
+	return rec
+}
+
+// TestPriceUpdateDryRun verifies that a dry run returns the proposed changes without
+// writing to the database.
+func TestPriceUpdateDryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	handler := &product_handlers.PriceUpdateHandlers{Store: product_handlers.NewDBProductStore(db)}
+
+	mock.ExpectQuery("SELECT id, name, brand, season, price, unit_cost, version FROM products").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "season", "price", "unit_cost", "version"}).
+			AddRow(1, "Jacket", "Acme", "Winter", 40.0, 22.5, 1))
+
+	rec := priceUpdateRequest(handler, models.PriceUpdateRequest{
+		Rules:  []models.PriceRule{{Percent: 10}},
+		DryRun: true,
+	})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var result models.PriceUpdateResult
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 44.0, result.Changes[0].NewPrice)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPriceUpdateApply verifies that changes are applied and audited in one transaction
+// and that a reason is required.
+func TestPriceUpdateApply(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	handler := &product_handlers.PriceUpdateHandlers{Store: product_handlers.NewDBProductStore(db)}
+
+	rec := priceUpdateRequest(handler, models.PriceUpdateRequest{Rules: []models.PriceRule{{Amount: 1}}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mock.ExpectQuery("SELECT id, name, brand, season, price, unit_cost, version FROM products").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "season", "price", "unit_cost", "version"}).
+			AddRow(1, "Jacket", "Acme", "Winter", 40.0, 22.5, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products SET price").WithArgs(41.0, 1, 40.0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("pricing@example.com", "price_update", "product", 1, "Supplier increase", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	rec = priceUpdateRequest(handler, models.PriceUpdateRequest{
+		Rules:  []models.PriceRule{{Amount: 1}},
+		Reason: "Supplier increase",
+	})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,8 +2,13 @@
 package product_handlers
 
 import (
-	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/pagination"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
 	"erp/models"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -15,6 +20,31 @@ type ProductHandlers struct {
 	ProductStore models.ProductStore
 }
 
+// ProductRequest is the request body for creating or updating a product. The ID is assigned by
+// the database or taken from the URL.
+type ProductRequest struct {
+	Name     string  `json:"name"`
+	Brand    string  `json:"brand"`
+	Season   string  `json:"season"`
+	Price    float64 `json:"price"`
+	UnitCost float64 `json:"unit_cost"`
+	Version  int     `json:"version"` // The version updated, unless sent in If-Match; ignored on create
+}
+
+// Product returns the product described by the request.
+func (req ProductRequest) Product() models.Product {
+	return models.Product{Name: req.Name, Brand: req.Brand, Season: req.Season, Price: req.Price, UnitCost: req.UnitCost}
+}
+
+// Validate checks that the product has a name and that its price and cost are not negative.
+func (req ProductRequest) Validate() error {
+	var c validation.Checker
+	c.Required("name", req.Name)
+	c.NotNegative("price", req.Price)
+	c.NotNegative("unit_cost", req.UnitCost)
+	return c.Err()
+}
+
 // RegisterRoutes registers all the product-related routes for the HTTP server.
 //
 // This method sets up routes for creating, retrieving, updating, and deleting products.
@@ -22,11 +52,13 @@ type ProductHandlers struct {
 //
 // URL Paths:
 // - POST /products: Create a new product
+// - GET /products: List products a page at a time
 // - GET /products/{id}: Retrieve a product by ID
 // - PUT /products/{id}: Update an existing product by ID
 // - DELETE /products/{id}: Delete a product by ID
 func (h *ProductHandlers) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/products", h.CreateProduct).Methods("POST")
+	router.HandleFunc("/products", h.ListProducts).Methods("GET")
 	router.HandleFunc("/products/{id:[0-9]+}", h.GetProductByID).Methods("GET")
 	router.HandleFunc("/products/{id:[0-9]+}", h.UpdateProduct).Methods("PUT")
 	router.HandleFunc("/products/{id:[0-9]+}", h.DeleteProduct).Methods("DELETE")
@@ -34,7 +66,7 @@ func (h *ProductHandlers) RegisterRoutes(router *mux.Router) {
 
 // CreateProduct handles the creation of a new product.
 //
-// This handler reads the incoming request body, decodes it into a Product struct,
+// This handler reads the incoming request body, decodes it into a ProductRequest,
 // and attempts to store it in the database. On successful creation, it returns
 // a status code 201 Created. If an error occurs, it responds with an appropriate
 // status code and error message.
@@ -43,28 +75,55 @@ func (h *ProductHandlers) RegisterRoutes(router *mux.Router) {
 // URL Path: /products
 //
 // Request Body:
-// - JSON representation of a Product object.
+// - JSON with name, brand, season and price (see ProductRequest).
 //
 // Response:
-// - Status Code: 201 (Created) if the product is successfully created.
-// - Status Code: 400 (Bad Request) if the request body is invalid.
+// - Status Code: 201 (Created) with the created product in JSON.
+// - Status Code: 400 (Bad Request) if the request body is not JSON.
+// - Status Code: 422 (Unprocessable Entity) if a field is invalid; the error details list them.
 // - Status Code: 500 (Internal Server Error) if the creation fails.
 func (h *ProductHandlers) CreateProduct(w http.ResponseWriter, r *http.Request) {
-	var req models.Product
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	var req ProductRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	err = h.ProductStore.CreateProduct(&req)
+	product := req.Product()
+	err := h.ProductStore.CreateProduct(r.Context(), &product)
 	if err != nil {
-		http.Error(w, "Could not create product", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not create product")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("Product created successfully"))
+	respond.JSON(w, http.StatusCreated, product)
+}
+
+// ListProducts handles listing products a page at a time.
+//
+// HTTP Method: GET
+// URL Path: /products?brand=Acme&sort=-price&page=1&limit=50
+//
+// Query Parameters:
+// - page, limit: The page number (from 1) and its size (default 50, at most 200).
+// - sort: id, name, brand, season or price, prefixed with "-" for descending order.
+// - id, name, brand, season, price: Filters; text is matched case-insensitively.
+//
+// Response:
+// - Status Code: 200 (OK) with {"items": [...], "total": n, "page": p, "limit": l} in JSON.
+// - Status Code: 422 (Unprocessable Entity) if a parameter is invalid.
+// - Status Code: 500 (Internal Server Error) if the products cannot be listed.
+func (h *ProductHandlers) ListProducts(w http.ResponseWriter, r *http.Request) {
+	query, err := pagination.Parse(r, ProductColumns)
+	if err != nil {
+		httperr.Write(w, err, "Invalid list query")
+		return
+	}
+	products, total, err := h.ProductStore.ListProducts(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Failed to list products")
+		return
+	}
+	pagination.Write(w, products, total, query)
 }
 
 // GetProductByID handles retrieving a product by its ID.
@@ -78,31 +137,37 @@ func (h *ProductHandlers) CreateProduct(w http.ResponseWriter, r *http.Request)
 // URL Path: /products/{id}
 //
 // Response:
-// - Status Code: 200 (OK) and the product details in JSON if the product is found.
+// - Status Code: 200 (OK) and the product details in JSON if the product is found, with its
+// version as the ETag.
 // - Status Code: 400 (Bad Request) if the ID is invalid.
 // - Status Code: 404 (Not Found) if the product is not found.
+// - Status Code: 500 (Internal Server Error) if the product cannot be read.
 func (h *ProductHandlers) GetProductByID(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, err := strconv.Atoi(params["id"])
 	if err != nil {
-		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	product, err := h.ProductStore.GetProductByID(productID)
+	product, err := h.ProductStore.GetProductByID(r.Context(), productID)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Product not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Product not found", http.StatusNotFound)
+		httperr.Write(w, err, "Could not load product")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(product)
+	versioning.SetETag(w, product.Version)
+	respond.JSON(w, http.StatusOK, product)
 }
 
 // UpdateProduct handles updating an existing product by ID.
 //
 // This handler extracts the product ID from the URL path, decodes the request body
-// into a Product struct, updates the product in the database, and returns a success
+// into a ProductRequest, updates the product in the database, and returns a success
 // response. If an error occurs, it responds with an appropriate status code and error
 // message.
 //
@@ -110,65 +175,75 @@ func (h *ProductHandlers) GetProductByID(w http.ResponseWriter, r *http.Request)
 // URL Path: /products/{id}
 //
 // Request Body:
-// - JSON representation of a Product object to update.
+// - JSON with name, brand, season, price and the version read (see ProductRequest); the
+// version may be sent as an If-Match header instead.
 //
 // Response:
-// - Status Code: 200 (OK) if the product is successfully updated.
+// - Status Code: 200 (OK) with the updated product in JSON, and the new version as the
+// ETag.
 // - Status Code: 400 (Bad Request) if the request body or ID is invalid.
+// - Status Code: 404 (Not Found) if the product does not exist.
+// - Status Code: 409 (Conflict) if the product was changed since the version read.
+// - Status Code: 422 (Unprocessable Entity) if a field is invalid; the error details list them.
+// - Status Code: 428 (Precondition Required) if no version is given.
 // - Status Code: 500 (Internal Server Error) if the update fails.
 func (h *ProductHandlers) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, err := strconv.Atoi(params["id"])
 	if err != nil {
-		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	var req models.Product
-	err = json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	var req ProductRequest
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
 		return
 	}
 
-	req.ID = productID
-	err = h.ProductStore.UpdateProduct(&req)
+	product := req.Product()
+	product.ID, product.Version = productID, version
+	err = h.ProductStore.UpdateProduct(r.Context(), &product)
 	if err != nil {
-		http.Error(w, "Could not update product", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not update product")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Product updated successfully"))
+	versioning.SetETag(w, product.Version)
+	respond.JSON(w, http.StatusOK, product)
 }
 
 // DeleteProduct handles deleting a product by its ID.
 //
 // This handler extracts the product ID from the URL path, deletes the product
-// from the database, and returns a success message. If any error occurs, it
+// from the database, and returns no content. If any error occurs, it
 // responds with an appropriate status code and error message.
 //
 // HTTP Method: DELETE
 // URL Path: /products/{id}
 //
 // Response:
-// - Status Code: 200 (OK) if the product is successfully deleted.
+// - Status Code: 204 (No Content) if the product is successfully deleted.
 // - Status Code: 400 (Bad Request) if the ID is invalid.
+// - Status Code: 404 (Not Found) if the product does not exist.
 // - Status Code: 500 (Internal Server Error) if the deletion fails.
 func (h *ProductHandlers) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, err := strconv.Atoi(params["id"])
 	if err != nil {
-		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	err = h.ProductStore.DeleteProduct(productID)
+	err = h.ProductStore.DeleteProduct(r.Context(), productID)
 	if err != nil {
-		http.Error(w, "Could not delete product", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not delete product")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Product deleted successfully"))
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -30,16 +30,17 @@ func TestCreateProduct(t *testing.T) {
 
 	// Sample product data
 	product := &models.Product{
-		Name:   "Test Product",
-		Brand:  "Test Brand",
-		Season: "Summer",
-		Price:  100.50,
+		Name:     "Test Product",
+		Brand:    "Test Brand",
+		Season:   "Summer",
+		Price:    100.50,
+		UnitCost: 61.25,
 	}
 
 	// Mock database behavior
-	mock.ExpectQuery(`INSERT INTO products \(name, brand, season, price\)`).
-		WithArgs(product.Name, product.Brand, product.Season, product.Price).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO products \(name, brand, season, price, unit_cost\)`).
+		WithArgs(product.Name, product.Brand, product.Season, product.Price, product.UnitCost).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(1, 1))
 
 	// Create HTTP request and recorder
 	body, _ := json.Marshal(product)
@@ -52,7 +53,10 @@ func TestCreateProduct(t *testing.T) {
 
 	// Verify response
 	assert.Equal(t, http.StatusCreated, rec.Code)
-	assert.Equal(t, "Product created successfully", rec.Body.String())
+	var created models.Product
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, 1, created.ID)
+	assert.Equal(t, product.Name, created.Name)
 
 	// Verify expectations
 	assert.NoError(t, mock.ExpectationsWereMet(), "unmet mock database expectations")
@@ -73,18 +77,20 @@ func TestGetProductByID(t *testing.T) {
 
 	// Sample product data
 	product := &models.Product{
-		ID:     1,
-		Name:   "Test Product",
-		Brand:  "Test Brand",
-		Season: "Summer",
-		Price:  100.50,
+		ID:       1,
+		Name:     "Test Product",
+		Brand:    "Test Brand",
+		Season:   "Summer",
+		Price:    100.50,
+		UnitCost: 61.25,
+		Version:  3,
 	}
 
 	// Mock database behavior
-	mock.ExpectQuery(`SELECT id, name, brand, season, price FROM products WHERE id = \$1`).
+	mock.ExpectQuery(`SELECT id, name, brand, season, price, unit_cost, version FROM products WHERE id = \$1`).
 		WithArgs(product.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "season", "price"}).
-			AddRow(product.ID, product.Name, product.Brand, product.Season, product.Price))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "season", "price", "unit_cost", "version"}).
+			AddRow(product.ID, product.Name, product.Brand, product.Season, product.Price, product.UnitCost, product.Version))
 
 	// Create HTTP request and recorder
 	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
@@ -96,6 +102,7 @@ func TestGetProductByID(t *testing.T) {
 
 	// Verify response
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
 	expectedBody, _ := json.Marshal(product)
 	assert.JSONEq(t, string(expectedBody), rec.Body.String())
 
@@ -118,27 +125,31 @@ func TestUpdateProduct(t *testing.T) {
 
 	// Sample product data
 	product := &models.Product{
-		ID:     1,
-		Name:   "Updated Product",
-		Brand:  "Updated Brand",
-		Season: "Winter",
-		Price:  120.75,
+		ID:       1,
+		Name:     "Updated Product",
+		Brand:    "Updated Brand",
+		Season:   "Winter",
+		Price:    120.75,
+		UnitCost: 70,
+		Version:  2,
 	}
 
 	// Mock database behavior
-	mock.ExpectExec(`UPDATE products SET name = \$1, brand = \$2, season = \$3, price = \$4 WHERE id = \$5`).
-		WithArgs(product.Name, product.Brand, product.Season, product.Price, product.ID).
-		WillReturnResult(sqlmock.NewResult(0, 1)) // Simulate one row affected
+	mock.ExpectQuery(`UPDATE products SET name = \$1, brand = \$2, season = \$3, price = \$4, unit_cost = \$5 WHERE id = \$6 AND version = \$7`).
+		WithArgs(product.Name, product.Brand, product.Season, product.Price, product.UnitCost, product.ID, product.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3)) // The trigger increments the version
 
 	// Create HTTP request and recorder
 	body, _ := json.Marshal(models.Product{
-		Name:   product.Name,
-		Brand:  product.Brand,
-		Season: product.Season,
-		Price:  product.Price,
+		Name:     product.Name,
+		Brand:    product.Brand,
+		Season:   product.Season,
+		Price:    product.Price,
+		UnitCost: product.UnitCost,
 	})
 	req := httptest.NewRequest(http.MethodPut, "/products/1", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"2"`)
 	rec := httptest.NewRecorder()
 	req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
@@ -147,12 +158,42 @@ func TestUpdateProduct(t *testing.T) {
 
 	// Verify response
 	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.Equal(t, "Product updated successfully", rec.Body.String())
+	var updated models.Product
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.Equal(t, 3, updated.Version)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
 
 	// Verify mock expectations
 	assert.NoError(t, mock.ExpectationsWereMet(), "unmet mock database expectations")
 }
 
+// TestUpdateProductRejectsStaleVersion verifies that an update based on a version that was
+// replaced in the meantime changes nothing and is answered with 409 Conflict.
+func TestUpdateProductRejectsStaleVersion(t *testing.T) {
+	// Set up mock database
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err, "failed to create mock database")
+	defer db.Close()
+
+	handler := &product_handlers.ProductHandlers{ProductStore: product_handlers.NewDBProductStore(db)}
+
+	// A price list moved the product to version 3 after the client read version 2
+	mock.ExpectQuery(`UPDATE products SET .* WHERE id = \$6 AND version = \$7`).
+		WithArgs("Renamed", "", "", 10.0, 0.0, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery(`SELECT version FROM products WHERE id = \$1`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+
+	req := httptest.NewRequest(http.MethodPut, "/products/1", bytes.NewReader([]byte(`{"name": "Renamed", "price": 10, "version": 2}`)))
+	rec := httptest.NewRecorder()
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	handler.UpdateProduct(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "at version 3 now")
+	assert.NoError(t, mock.ExpectationsWereMet(), "unmet mock database expectations")
+}
+
 // TestDeleteProduct verifies the behavior of the DeleteProduct handler.
 //
 // This test sets up a mock database, simulates a DELETE request for a product by ID,
@@ -167,7 +208,7 @@ func TestDeleteProduct(t *testing.T) {
 	handler := &product_handlers.ProductHandlers{ProductStore: store}
 
 	// Mock database behavior
-	mock.ExpectExec(`DELETE FROM products WHERE id = \$1`).
+	mock.ExpectExec(`UPDATE products SET deleted_at = now\(\) WHERE id = \$1 AND deleted_at IS NULL`).
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(0, 1)) // Simulate one row affected
 
@@ -180,8 +221,8 @@ func TestDeleteProduct(t *testing.T) {
 	handler.DeleteProduct(rec, req)
 
 	// Verify response
-	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.Equal(t, "Product deleted successfully", rec.Body.String())
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
 
 	// Verify mock expectations
 	assert.NoError(t, mock.ExpectationsWereMet(), "unmet mock database expectations")
@@ -2,9 +2,15 @@
 package product_handlers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"erp/controllers/audit"
+	"erp/controllers/pagination"
+	"erp/controllers/versioning"
 	"erp/models"
 	"fmt"
+	"time"
 )
 
 // DBProductStore implements the ProductStore interface for database operations.
@@ -30,13 +36,14 @@ func NewDBProductStore(db *sql.DB) *DBProductStore {
 //
 // Returns:
 // - An error if the insertion fails, otherwise nil.
-func (s *DBProductStore) CreateProduct(product *models.Product) error {
+func (s *DBProductStore) CreateProduct(ctx context.Context, product *models.Product) error {
 	query := `
-		INSERT INTO products (name, brand, season, price)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
+		INSERT INTO products (name, brand, season, price, unit_cost)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, version
 	`
-	err := s.DB.QueryRow(query, product.Name, product.Brand, product.Season, product.Price).Scan(&product.ID)
+	err := s.DB.QueryRowContext(ctx, query, product.Name, product.Brand, product.Season, product.Price, product.UnitCost).
+		Scan(&product.ID, &product.Version)
 	if err != nil {
 		return fmt.Errorf("failed to insert product: %w", err)
 	}
@@ -50,20 +57,20 @@ func (s *DBProductStore) CreateProduct(product *models.Product) error {
 //
 // Returns:
 // - A pointer to the Product struct if found.
-// - An error if no record is found or if the query fails.
-func (s *DBProductStore) GetProductByID(id int) (*models.Product, error) {
+// - models.ErrNotFound if no record is found, or an error if the query fails.
+func (s *DBProductStore) GetProductByID(ctx context.Context, id int) (*models.Product, error) {
 	query := `
-		SELECT id, name, brand, season, price
+		SELECT id, name, brand, season, price, unit_cost, version
 		FROM products
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
-	row := s.DB.QueryRow(query, id)
+	row := s.DB.QueryRowContext(ctx, query, id)
 
 	var product models.Product
-	err := row.Scan(&product.ID, &product.Name, &product.Brand, &product.Season, &product.Price)
+	err := row.Scan(&product.ID, &product.Name, &product.Brand, &product.Season, &product.Price, &product.UnitCost, &product.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no product found with ID %d", id)
+			return nil, models.NotFound("no product found with ID %d", id)
 		}
 		return nil, fmt.Errorf("failed to retrieve product: %w", err)
 	}
@@ -71,48 +78,84 @@ func (s *DBProductStore) GetProductByID(id int) (*models.Product, error) {
 	return &product, nil
 }
 
-// UpdateProduct updates an existing product record in the database.
+// ProductColumns are the fields products can be listed by.
+var ProductColumns = pagination.Columns{
+	"id":     {Expr: "id", Type: pagination.Int},
+	"name":   {Expr: "name", Type: pagination.Text},
+	"brand":  {Expr: "brand", Type: pagination.Text},
+	"season": {Expr: "season", Type: pagination.Text},
+	"price":  {Expr: "price", Type: pagination.Number},
+}
+
+// ListProducts retrieves a page of product records from the database.
+//
+// Parameters:
+// - query: The page, sort order and filters (see ProductColumns).
+//
+// Returns:
+// - The page of products and the number of products matching the filters.
+// - An error if a filter is invalid or the query fails.
+func (s *DBProductStore) ListProducts(ctx context.Context, query models.ListQuery) ([]models.Product, int, error) {
+	products := []models.Product{}
+	total, err := pagination.QueryWhere(ctx, s.DB, "id, name, COALESCE(brand, ''), COALESCE(season, ''), price, unit_cost, version", "products",
+		"deleted_at IS NULL", ProductColumns, query, func(rows *sql.Rows) error {
+			var product models.Product
+			if err := rows.Scan(&product.ID, &product.Name, &product.Brand, &product.Season, &product.Price, &product.UnitCost,
+				&product.Version); err != nil {
+				return err
+			}
+			products = append(products, product)
+			return nil
+		})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list products: %w", err)
+	}
+	return products, total, nil
+}
+
+// UpdateProduct updates an existing product record in the database if it is still at
+// product.Version, and sets the new version.
 //
 // Parameters:
 // - product: A pointer to the Product struct containing the updated product details.
 //
 // Returns:
-// - An error if the update fails, otherwise nil.
-func (s *DBProductStore) UpdateProduct(product *models.Product) error {
+// - models.ErrNotFound if the product does not exist, a conflict if it was changed since
+// product.Version, or another error if the update fails; otherwise nil.
+func (s *DBProductStore) UpdateProduct(ctx context.Context, product *models.Product) error {
 	query := `
 		UPDATE products
-		SET name = $1, brand = $2, season = $3, price = $4
-		WHERE id = $5
+		SET name = $1, brand = $2, season = $3, price = $4, unit_cost = $5
+		WHERE id = $6 AND version = $7 AND deleted_at IS NULL
+		RETURNING version
 	`
-	result, err := s.DB.Exec(query, product.Name, product.Brand, product.Season, product.Price, product.ID)
-	if err != nil {
-		return fmt.Errorf("failed to update product: %w", err)
+	err := s.DB.QueryRowContext(ctx, query, product.Name, product.Brand, product.Season, product.Price, product.UnitCost,
+		product.ID, product.Version).Scan(&product.Version)
+	if err == sql.ErrNoRows {
+		return versioning.Stale(ctx, s.DB, "SELECT version FROM products WHERE id = $1 AND deleted_at IS NULL", "product",
+			product.ID, product.Version)
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("no product found with ID %d", product.ID)
+		return fmt.Errorf("failed to update product: %w", err)
 	}
-
 	return nil
 }
 
-// DeleteProduct removes a product record from the database by ID.
+// DeleteProduct soft deletes a product record by ID: the product is hidden from reads until
+// it is restored or purged.
 //
 // Parameters:
 // - id: An integer representing the product ID to delete.
 //
 // Returns:
 // - An error if the deletion fails, otherwise nil.
-func (s *DBProductStore) DeleteProduct(id int) error {
+func (s *DBProductStore) DeleteProduct(ctx context.Context, id int) error {
 	query := `
-		DELETE FROM products
-		WHERE id = $1
+		UPDATE products
+		SET deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
-	result, err := s.DB.Exec(query, id)
+	result, err := s.DB.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete product with ID %d: %w", id, err)
 	}
@@ -122,8 +165,167 @@ func (s *DBProductStore) DeleteProduct(id int) error {
 		return fmt.Errorf("failed to check rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("no product found with ID %d", id)
+		return models.NotFound("no product found with ID %d", id)
 	}
 
 	return nil
 }
+
+// GetAllProducts retrieves every product record from the database.
+//
+// Returns:
+// - A slice of products ordered by ID.
+// - An error if the query fails.
+func (s *DBProductStore) GetAllProducts(ctx context.Context) ([]models.Product, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, name, brand, season, price, unit_cost, version FROM products WHERE deleted_at IS NULL ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var product models.Product
+		if err := rows.Scan(&product.ID, &product.Name, &product.Brand, &product.Season, &product.Price, &product.UnitCost,
+			&product.Version); err != nil {
+			return nil, fmt.Errorf("failed to read product: %w", err)
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+// ApplyPriceChanges updates product prices and records each change in the audit log in one
+// transaction. A product whose price no longer matches the previewed old price aborts the
+// whole update with models.ErrStalePrice.
+//
+// Parameters:
+// - changes: The price changes to apply.
+// - reason: Why the prices are changed.
+// - actor: Email of the user applying the change.
+//
+// Returns:
+// - An error if any change fails, otherwise nil.
+func (s *DBProductStore) ApplyPriceChanges(ctx context.Context, changes []models.PriceChange, reason, actor string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, change := range changes {
+		result, err := tx.ExecContext(ctx,
+			"UPDATE products SET price = $1 WHERE id = $2 AND price = $3 AND deleted_at IS NULL",
+			change.NewPrice, change.ProductID, change.OldPrice,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update price of product %d: %w", change.ProductID, err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to check rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("product %d: %w", change.ProductID, models.ErrStalePrice)
+		}
+		if err := recordPriceChange(ctx, tx, change, reason, actor, nil); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SchedulePriceChanges adds price list entries that take effect on effectiveDate.
+//
+// Parameters:
+// - changes: The price changes to schedule.
+// - effectiveDate: The date from which the new prices apply.
+// - reason: Why the prices are changed.
+// - actor: Email of the user scheduling the change.
+//
+// Returns:
+// - An error if any entry cannot be stored, otherwise nil.
+func (s *DBProductStore) SchedulePriceChanges(ctx context.Context, changes []models.PriceChange, effectiveDate time.Time, reason, actor string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, change := range changes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO price_list_entries (product_id, price, effective_date, reason, created_by) VALUES ($1, $2, $3, $4, $5)",
+			change.ProductID, change.NewPrice, effectiveDate, reason, actor,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to schedule price of product %d: %w", change.ProductID, err)
+		}
+		if err := recordPriceChange(ctx, tx, change, reason, actor, &effectiveDate); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ApplyDuePriceChanges sets product prices from price list entries whose effective date has
+// been reached and marks the entries as applied. When several entries for a product are due,
+// the one with the latest effective date wins.
+//
+// Parameters:
+// - now: The current time.
+//
+// Returns:
+// - The number of products updated.
+// - An error if the update fails.
+func (s *DBProductStore) ApplyDuePriceChanges(ctx context.Context, now time.Time) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE products p
+		SET price = due.price
+		FROM (
+			SELECT DISTINCT ON (product_id) product_id, price
+			FROM price_list_entries
+			WHERE applied_at IS NULL AND effective_date <= $1
+			ORDER BY product_id, effective_date DESC, id DESC
+		) due
+		WHERE p.id = due.product_id
+	`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply price list: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE price_list_entries SET applied_at = $1 WHERE applied_at IS NULL AND effective_date <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark price list entries applied: %w", err)
+	}
+	return int(updated), tx.Commit()
+}
+
+// recordPriceChange writes the audit entry for one price change.
+func recordPriceChange(ctx context.Context, tx *sql.Tx, change models.PriceChange, reason, actor string, effectiveDate *time.Time) error {
+	details, err := json.Marshal(map[string]interface{}{
+		"old_price":      change.OldPrice,
+		"new_price":      change.NewPrice,
+		"effective_date": effectiveDate,
+	})
+	if err != nil {
+		return err
+	}
+	return audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		Action:     audit.ActionPriceUpdate,
+		EntityType: "product",
+		EntityID:   change.ProductID,
+		Reason:     reason,
+		Details:    details,
+	})
+}
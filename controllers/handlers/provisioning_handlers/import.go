@@ -0,0 +1,153 @@
+// Package provisioning_handlers lets IT keep the ERP users in sync with the HR system, by
+// importing a CSV export or through a minimal SCIM 2.0 API for identity providers.
+package provisioning_handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"erp/controllers/httperr"
+	"erp/controllers/provisioning"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// maxImportSize bounds the size of an imported file.
+const maxImportSize = 10 << 20
+
+// ProvisioningHandlers provides the user import and SCIM endpoints.
+type ProvisioningHandlers struct {
+	Service *provisioning.Service
+}
+
+// ImportUsers creates, updates and deactivates users from a CSV file with a header row.
+// The columns are email (required), external_id, name, title, department, role and active
+// ("true" or "false", true when empty); their order does not matter and unknown columns are
+// ignored. Users are matched by external ID, then email. Roles come from the role column or
+// the configured mapping rules.
+//
+// HTTP Method: POST
+// URL Path: /users/import?dry_run=true
+//
+// Request Body:
+//   - The CSV file, either as the body or in the "file" field of a multipart/form-data form.
+//
+// Response:
+//   - Status Code: 200 (OK) with a ProvisionReport in JSON: the action taken for each row,
+//     with the fields changed or, for conflicts and invalid rows, the reason it was skipped.
+//     With dry_run nothing is saved.
+//   - Status Code: 400 (Bad Request) if the file cannot be read or has no email column.
+//   - Status Code: 409 (Conflict) if another change took an email or external ID meanwhile.
+//   - Status Code: 500 (Internal Server Error) if the users could not be saved.
+func (h *ProvisioningHandlers) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	var body io.Reader
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+1<<20) // Allow for multipart overhead
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "A CSV file is required in the file field")
+			return
+		}
+		defer file.Close()
+		body = file
+	} else {
+		body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	}
+
+	records, rows, rejected, err := readRecords(body)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := h.Service.Apply(r.Context(), records, dryRun)
+	if err != nil {
+		httperr.Write(w, err, "Failed to import users")
+		return
+	}
+	for i := range report.Results {
+		report.Results[i].Row = rows[i]
+	}
+	for _, result := range rejected {
+		report.Counts[result.Action]++
+		report.Results = append(report.Results, result)
+	}
+	sort.SliceStable(report.Results, func(i, j int) bool { return report.Results[i].Row < report.Results[j].Row })
+
+	respond.JSON(w, http.StatusOK, report)
+}
+
+// readRecords reads the records of a CSV file. Rows that cannot be read are returned as
+// invalid results instead.
+//
+// Returns:
+//   - []models.ProvisionRecord: The records read.
+//   - []int: The line of each record.
+//   - []models.ProvisionResult: The rows that could not be read.
+//   - error: An error if the file has no header row with an email column, or is not CSV.
+func readRecords(body io.Reader) ([]models.ProvisionRecord, []int, []models.ProvisionResult, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, nil, errors.New("the file has no header row")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, nil, nil, errors.New("the file has no email column")
+	}
+
+	var records []models.ProvisionRecord
+	var rows []int
+	var rejected []models.ProvisionResult
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+
+		record := models.ProvisionRecord{
+			ExternalID: value("external_id"),
+			Name:       value("name"),
+			Email:      value("email"),
+			Title:      value("title"),
+			Department: value("department"),
+			Role:       value("role"),
+			Active:     true,
+		}
+		if active := value("active"); active != "" {
+			if record.Active, err = strconv.ParseBool(active); err != nil {
+				rejected = append(rejected, models.ProvisionResult{
+					Row: line, ExternalID: record.ExternalID, Email: record.Email,
+					Action: models.ProvisionInvalid, Detail: "active must be true or false",
+				})
+				continue
+			}
+		}
+		records = append(records, record)
+		rows = append(rows, line)
+	}
+	return records, rows, rejected, nil
+}
@@ -0,0 +1,195 @@
+package provisioning_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"erp/config"
+	"erp/controllers/provisioning"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps the directory in memory and saves changes to it.
+type fakeStore struct {
+	users []models.DirectoryUser
+	saves int
+}
+
+func (f *fakeStore) ListDirectoryUsers(ctx context.Context) ([]models.DirectoryUser, error) {
+	return append([]models.DirectoryUser(nil), f.users...), nil
+}
+
+func (f *fakeStore) GetDirectoryUser(ctx context.Context, id int) (*models.DirectoryUser, error) {
+	for _, u := range f.users {
+		if u.ID == id {
+			return &u, nil
+		}
+	}
+	return nil, models.NotFound("user %d not found", id)
+}
+
+func (f *fakeStore) RoleNames(ctx context.Context) ([]string, error) {
+	return []string{"Accountant", "Admin", "Employee"}, nil
+}
+
+func (f *fakeStore) SaveDirectoryUsers(ctx context.Context, users []*models.DirectoryUser) error {
+	f.saves++
+	for _, u := range users {
+		if u.ID == 0 {
+			u.ID = len(f.users) + 1
+			f.users = append(f.users, *u)
+			continue
+		}
+		for i := range f.users {
+			if f.users[i].ID == u.ID {
+				f.users[i] = *u
+			}
+		}
+	}
+	return nil
+}
+
+func setupRouter(t *testing.T) (*mux.Router, *fakeStore) {
+	store := &fakeStore{users: []models.DirectoryUser{
+		{ID: 1, ExternalID: "E1", Name: "Ana", Email: "ana@example.com", Role: "Accountant", Department: "Finance", Active: true},
+	}}
+	service, err := provisioning.NewService(store, config.ProvisioningConfig{DefaultRole: "Employee", RoleRules: []string{"department=Finance:Accountant"}})
+	require.NoError(t, err)
+
+	handler := &ProvisioningHandlers{Service: service}
+	router := mux.NewRouter()
+	router.HandleFunc("/users/import", handler.ImportUsers).Methods("POST")
+	RegisterSCIMRoutes(router.PathPrefix("/scim/v2").Subrouter(), handler)
+	return router, store
+}
+
+func serve(router *mux.Router, method, url, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+const importFile = `Email,External_ID,Name,Department,Active
+ana@example.com,E1,Ana,Finance,false
+bob@example.com,E2,Bob,Warehouse,
+ana@example.com,E3,Ann,Finance,true
+cat@example.com,E4,Cat,Finance,maybe
+`
+
+func TestImportUsers(t *testing.T) {
+	router, store := setupRouter(t)
+
+	rr := serve(router, "POST", "/users/import?dry_run=true", "text/csv", importFile)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var report models.ProvisionReport
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, map[models.ProvisionAction]int{"deactivated": 1, "created": 1, "conflict": 1, "invalid": 1}, report.Counts)
+	require.Len(t, report.Results, 4)
+	for i, result := range report.Results {
+		assert.Equal(t, i+2, result.Row, "Results are in file order")
+	}
+	assert.Equal(t, "active must be true or false", report.Results[3].Detail)
+	assert.Zero(t, store.saves)
+
+	// The same file in a multipart form, this time applied
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "staff.csv")
+	file.Write([]byte(importFile))
+	form.Close()
+	rr = serve(router, "POST", "/users/import", form.FormDataContentType(), body.String())
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, store.saves)
+	assert.False(t, store.users[0].Active)
+	assert.Equal(t, models.DirectoryUser{ID: 2, ExternalID: "E2", Name: "Bob", Email: "bob@example.com", Role: "Employee", Department: "Warehouse", Active: true}, store.users[1])
+}
+
+func TestImportUsersRequiresEmailColumn(t *testing.T) {
+	router, _ := setupRouter(t)
+
+	rr := serve(router, "POST", "/users/import", "text/csv", "name\nAna\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "no email column")
+}
+
+func TestSCIMUserLifecycle(t *testing.T) {
+	router, store := setupRouter(t)
+
+	rr := serve(router, "POST", "/scim/v2/Users", "application/scim+json", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"externalId": "E2", "userName": "bob@example.com", "displayName": "Bob",
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Finance"}
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, "/scim/v2/Users/2", rr.Header().Get("Location"))
+	var created scimUser
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	assert.Equal(t, "2", created.ID)
+	assert.Equal(t, []scimValue{{Value: "Accountant"}}, created.Roles, "Mapped from the department")
+
+	rr = serve(router, "POST", "/scim/v2/Users", "", `{"externalId": "E2", "userName": "bob@example.com", "displayName": "Bob"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code, "A create does not update an existing user")
+	assert.Contains(t, rr.Body.String(), `"scimType":"uniqueness"`)
+
+	rr = serve(router, "PATCH", "/scim/v2/Users/2", "", `{"Operations": [{"op": "replace", "path": "displayName", "value": "Robert"}]}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "Robert", store.users[1].Name)
+	assert.Equal(t, "Accountant", store.users[1].Role, "The role is kept")
+
+	rr = serve(router, "DELETE", "/scim/v2/Users/2?dry_run=true", "", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"action":"deactivated"`)
+	assert.True(t, store.users[1].Active)
+
+	rr = serve(router, "DELETE", "/scim/v2/Users/2", "", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.False(t, store.users[1].Active)
+
+	rr = serve(router, "GET", `/scim/v2/Users?filter=userName+eq+"bob@example.com"`, "", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"totalResults":1`)
+	assert.Contains(t, rr.Body.String(), `"active":false`)
+
+	rr = serve(router, "GET", `/scim/v2/Users?filter=name.familyName+co+"x"`, "", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = serve(router, "GET", "/scim/v2/Users/9", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestSaveDirectoryUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users (external_id, name, email, role_id, department, active)")).
+		WithArgs("E2", "Bob", "bob@example.com", "Employee", "IT", true).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET external_id = NULLIF($2, '')")).
+		WithArgs(1, "E1", "Ana", "ana@example.com", "Accountant", "Finance", false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	created := &models.DirectoryUser{ExternalID: "E2", Name: "Bob", Email: "bob@example.com", Role: "Employee", Department: "IT", Active: true}
+	updated := &models.DirectoryUser{ID: 1, ExternalID: "E1", Name: "Ana", Email: "ana@example.com", Role: "Accountant", Department: "Finance"}
+	require.NoError(t, (&DBProvisioningStore{DB: db}).SaveDirectoryUsers(context.Background(), []*models.DirectoryUser{created, updated}))
+	assert.Equal(t, 7, created.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
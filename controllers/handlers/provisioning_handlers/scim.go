@@ -0,0 +1,411 @@
+package provisioning_handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SCIM schema URNs (RFC 7643 and 7644).
+const (
+	userSchema       = "urn:ietf:params:scim:schemas:core:2.0:User"
+	enterpriseSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	listSchema       = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	errorSchema      = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// scimUser is a user as SCIM describes it. The user name is the email.
+type scimUser struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	ExternalID  string          `json:"externalId,omitempty"`
+	UserName    string          `json:"userName"`
+	DisplayName string          `json:"displayName,omitempty"`
+	Title       string          `json:"title,omitempty"`
+	Active      *bool           `json:"active,omitempty"`
+	Roles       []scimValue     `json:"roles,omitempty"`
+	Enterprise  *scimEnterprise `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta        *scimMeta       `json:"meta,omitempty"`
+}
+
+type scimValue struct {
+	Value string `json:"value"`
+}
+
+type scimEnterprise struct {
+	Department string `json:"department,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+// scimPatch is a SCIM PATCH request body.
+type scimPatch struct {
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+// RegisterSCIMRoutes maps the SCIM user routes to their handler functions.
+// The router is expected to be protected with middleware.JWTAuth and limited to admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The provisioning handlers.
+func RegisterSCIMRoutes(router *mux.Router, handler *ProvisioningHandlers) {
+	router.HandleFunc("/Users", handler.ListSCIMUsers).Methods("GET")
+	router.HandleFunc("/Users", handler.CreateSCIMUser).Methods("POST")
+	router.HandleFunc("/Users/{id:[0-9]+}", handler.GetSCIMUser).Methods("GET")
+	router.HandleFunc("/Users/{id:[0-9]+}", handler.ReplaceSCIMUser).Methods("PUT")
+	router.HandleFunc("/Users/{id:[0-9]+}", handler.PatchSCIMUser).Methods("PATCH")
+	router.HandleFunc("/Users/{id:[0-9]+}", handler.DeleteSCIMUser).Methods("DELETE")
+}
+
+// filterPattern matches the filters identity providers send to look up a user.
+var filterPattern = regexp.MustCompile(`^(?i)(userName|externalId)\s+eq\s+"([^"]*)"$`)
+
+// ListSCIMUsers returns a page of users, optionally only the one with a user name or
+// external ID.
+//
+// HTTP Method: GET
+// URL Path: /scim/v2/Users?filter=userName eq "a@example.com"&startIndex=1&count=100
+//
+// Response:
+//   - Status Code: 200 (OK) with a SCIM ListResponse.
+//   - Status Code: 400 (Bad Request) if the filter is not supported.
+//   - Status Code: 500 (Internal Server Error) if the users could not be loaded.
+func (h *ProvisioningHandlers) ListSCIMUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var attribute, value string
+	if filter := strings.TrimSpace(query.Get("filter")); filter != "" {
+		match := filterPattern.FindStringSubmatch(filter)
+		if match == nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", `only userName eq "..." and externalId eq "..." filters are supported`)
+			return
+		}
+		attribute, value = strings.ToLower(match[1]), match[2]
+	}
+	start, err := strconv.Atoi(query.Get("startIndex"))
+	if err != nil || start < 1 {
+		start = 1
+	}
+	count, err := strconv.Atoi(query.Get("count"))
+	if err != nil || count < 0 {
+		count = 100
+	}
+
+	users, err := h.Service.Users(r.Context())
+	if err != nil {
+		writeSCIMFailure(w, err, "Failed to list users")
+		return
+	}
+	resources := []scimUser{}
+	for i := range users {
+		if (attribute == "username" && !strings.EqualFold(users[i].Email, value)) ||
+			(attribute == "externalid" && users[i].ExternalID != value) {
+			continue
+		}
+		resources = append(resources, toSCIM(&users[i]))
+	}
+	total := len(resources)
+	resources = resources[min(start-1, total):min(start-1+count, total)]
+
+	writeSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas":      []string{listSchema},
+		"totalResults": total,
+		"startIndex":   start,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+// GetSCIMUser returns one user.
+//
+// HTTP Method: GET
+// URL Path: /scim/v2/Users/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the SCIM user.
+//   - Status Code: 404 (Not Found) if the user does not exist.
+func (h *ProvisioningHandlers) GetSCIMUser(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	user, err := h.Service.User(r.Context(), id)
+	if err != nil {
+		writeSCIMFailure(w, err, "Failed to load user")
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIM(user))
+}
+
+// CreateSCIMUser creates a user. The role comes from the first of the user's roles or
+// the configured mapping rules, and the department from the enterprise extension.
+//
+// HTTP Method: POST
+// URL Path: /scim/v2/Users?dry_run=true
+//
+// Request Body:
+//   - A SCIM user; userName is the email.
+//
+// Response:
+//   - Status Code: 201 (Created) with the SCIM user, or 200 (OK) with the ProvisionResult
+//     in a dry run.
+//   - Status Code: 400 (Bad Request) if the user is invalid or maps to an unknown role.
+//   - Status Code: 409 (Conflict) if the email or external ID belongs to another user.
+func (h *ProvisioningHandlers) CreateSCIMUser(w http.ResponseWriter, r *http.Request) {
+	var body scimUser
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request payload")
+		return
+	}
+	record := fromSCIM(&body, models.ProvisionRecord{Active: true})
+
+	// A create must not update an existing user, so the outcome is checked first
+	check, err := h.Service.Apply(r.Context(), []models.ProvisionRecord{record}, true)
+	if err != nil {
+		writeSCIMFailure(w, err, "Failed to create user")
+		return
+	}
+	if result := check.Results[0]; result.UserID != 0 {
+		writeSCIMError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("user %d already has this email or external ID", result.UserID))
+		return
+	}
+	h.apply(w, r, record, http.StatusCreated)
+}
+
+// ReplaceSCIMUser replaces a user's attributes. A user without a title, department or
+// roles is given the default role.
+//
+// HTTP Method: PUT
+// URL Path: /scim/v2/Users/{id}?dry_run=true
+//
+// Request Body:
+//   - A SCIM user; "active": false deactivates the user.
+//
+// Response:
+//   - Status Code: 200 (OK) with the SCIM user, or the ProvisionResult in a dry run.
+//   - Status Code: 400 (Bad Request) if the user is invalid or maps to an unknown role.
+//   - Status Code: 404 (Not Found) if the user does not exist.
+//   - Status Code: 409 (Conflict) if the email or external ID belongs to another user.
+func (h *ProvisioningHandlers) ReplaceSCIMUser(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var body scimUser
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request payload")
+		return
+	}
+	if _, err := h.Service.User(r.Context(), id); err != nil {
+		writeSCIMFailure(w, err, "Failed to update user")
+		return
+	}
+	h.apply(w, r, fromSCIM(&body, models.ProvisionRecord{ID: id, Active: true}), http.StatusOK)
+}
+
+// PatchSCIMUser changes some of a user's attributes with "add" or "replace" operations on
+// active, userName, displayName, externalId, title, roles and department. The user keeps
+// their role unless the title, department or roles change.
+//
+// HTTP Method: PATCH
+// URL Path: /scim/v2/Users/{id}?dry_run=true
+//
+// Request Body:
+//   - A SCIM PatchOp, e.g. {"Operations": [{"op": "replace", "path": "active", "value": false}]}.
+//
+// Response:
+//   - Status Code: 200 (OK) with the SCIM user, or the ProvisionResult in a dry run.
+//   - Status Code: 400 (Bad Request) if an operation is not supported or the user is invalid.
+//   - Status Code: 404 (Not Found) if the user does not exist.
+//   - Status Code: 409 (Conflict) if the email or external ID belongs to another user.
+func (h *ProvisioningHandlers) PatchSCIMUser(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var patch scimPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || len(patch.Operations) == 0 {
+		writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request payload")
+		return
+	}
+	user, err := h.Service.User(r.Context(), id)
+	if err != nil {
+		writeSCIMFailure(w, err, "Failed to update user")
+		return
+	}
+
+	// The patched attributes are applied to the user as SCIM describes them
+	current := toSCIM(user)
+	current.Roles = []scimValue{{Value: user.Role}}
+	for _, op := range patch.Operations {
+		if kind := strings.ToLower(op.Op); kind != "add" && kind != "replace" {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("operation %q is not supported", op.Op))
+			return
+		}
+		value := op.Value
+		if op.Path != "" {
+			// A path is the same as a value object with that one attribute
+			value = json.RawMessage(fmt.Sprintf(`{%q: %s}`, patchAttribute(op.Path), op.Value))
+		}
+		var changes scimUser
+		if err := json.Unmarshal(value, &changes); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", fmt.Sprintf("invalid value for %q", op.Path))
+			return
+		}
+		if changes.Title != "" || changes.Roles != nil || changes.Enterprise != nil {
+			current.Roles = nil // Map the role again
+		}
+		mergeSCIM(&current, &changes)
+	}
+	h.apply(w, r, fromSCIM(&current, models.ProvisionRecord{ID: id, Active: true}), http.StatusOK)
+}
+
+// DeleteSCIMUser deactivates a user. Users are not deleted, as their records refer to them.
+//
+// HTTP Method: DELETE
+// URL Path: /scim/v2/Users/{id}?dry_run=true
+//
+// Response:
+//   - Status Code: 204 (No Content), or 200 (OK) with the ProvisionResult in a dry run.
+//   - Status Code: 404 (Not Found) if the user does not exist.
+func (h *ProvisioningHandlers) DeleteSCIMUser(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	user, err := h.Service.User(r.Context(), id)
+	if err != nil {
+		writeSCIMFailure(w, err, "Failed to deactivate user")
+		return
+	}
+	record := models.ProvisionRecord{ID: id, Email: user.Email, Role: user.Role, Department: user.Department}
+	h.apply(w, r, record, http.StatusNoContent)
+}
+
+// apply provisions one record and answers with the resulting user, or with the result
+// itself in a dry run.
+func (h *ProvisioningHandlers) apply(w http.ResponseWriter, r *http.Request, record models.ProvisionRecord, status int) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	report, err := h.Service.Apply(r.Context(), []models.ProvisionRecord{record}, dryRun)
+	if err != nil {
+		writeSCIMFailure(w, err, "Failed to save user")
+		return
+	}
+
+	result := report.Results[0]
+	switch {
+	case result.Action == models.ProvisionConflict:
+		writeSCIMError(w, http.StatusConflict, "uniqueness", result.Detail)
+	case result.Action == models.ProvisionInvalid || result.User == nil:
+		writeSCIMError(w, http.StatusBadRequest, "invalidValue", result.Detail)
+	case dryRun:
+		respond.JSON(w, http.StatusOK, result)
+	case status == http.StatusNoContent:
+		w.WriteHeader(status)
+	default:
+		user := toSCIM(result.User)
+		w.Header().Set("Location", user.Meta.Location)
+		writeSCIM(w, status, user)
+	}
+}
+
+// patchAttribute returns the attribute of a PATCH path, e.g. "title" for "title" and the
+// enterprise extension for its "department".
+func patchAttribute(path string) string {
+	if strings.EqualFold(strings.TrimPrefix(path, enterpriseSchema+":"), "department") {
+		return enterpriseSchema
+	}
+	return path
+}
+
+// mergeSCIM copies the attributes set in changes to user.
+func mergeSCIM(user, changes *scimUser) {
+	if changes.ExternalID != "" {
+		user.ExternalID = changes.ExternalID
+	}
+	if changes.UserName != "" {
+		user.UserName = changes.UserName
+	}
+	if changes.DisplayName != "" {
+		user.DisplayName = changes.DisplayName
+	}
+	if changes.Title != "" {
+		user.Title = changes.Title
+	}
+	if changes.Active != nil {
+		user.Active = changes.Active
+	}
+	if changes.Roles != nil {
+		user.Roles = changes.Roles
+	}
+	if changes.Enterprise != nil {
+		user.Enterprise = changes.Enterprise
+	}
+}
+
+// fromSCIM returns the record of a SCIM user, starting from base.
+func fromSCIM(user *scimUser, base models.ProvisionRecord) models.ProvisionRecord {
+	record := base
+	record.ExternalID = user.ExternalID
+	record.Name = user.DisplayName
+	record.Email = user.UserName
+	record.Title = user.Title
+	if user.Active != nil {
+		record.Active = *user.Active
+	}
+	if len(user.Roles) > 0 {
+		record.Role = user.Roles[0].Value
+	}
+	if user.Enterprise != nil {
+		record.Department = user.Enterprise.Department
+	}
+	return record
+}
+
+// toSCIM returns the SCIM representation of a user.
+func toSCIM(user *models.DirectoryUser) scimUser {
+	active := user.Active
+	return scimUser{
+		Schemas:     []string{userSchema, enterpriseSchema},
+		ID:          strconv.Itoa(user.ID),
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		DisplayName: user.Name,
+		Active:      &active,
+		Roles:       []scimValue{{Value: user.Role}},
+		Enterprise:  &scimEnterprise{Department: user.Department},
+		Meta:        &scimMeta{ResourceType: "User", Location: fmt.Sprintf("/scim/v2/Users/%d", user.ID)},
+	}
+}
+
+func writeSCIM(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeSCIMError answers with a SCIM error.
+func writeSCIMError(w http.ResponseWriter, status int, scimType, detail string) {
+	body := map[string]interface{}{"schemas": []string{errorSchema}, "status": strconv.Itoa(status), "detail": detail}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	writeSCIM(w, status, body)
+}
+
+// writeSCIMFailure answers a request that failed with err, as httperr.Write does, with a
+// SCIM error.
+func writeSCIMFailure(w http.ResponseWriter, err error, fallback string) {
+	status := httperr.Status(err)
+	detail := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("%s: %v", fallback, err)
+		detail = fallback
+	}
+	writeSCIMError(w, status, "", detail)
+}
@@ -0,0 +1,125 @@
+package provisioning_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"erp/models"
+)
+
+// DBProvisioningStore implements models.ProvisioningStore using a SQL database.
+type DBProvisioningStore struct {
+	DB *sql.DB
+}
+
+// directoryUserColumns reads a user with the name of their role.
+const directoryUserColumns = `SELECT u.id, COALESCE(u.external_id, ''), u.name, u.email, COALESCE(r.role_name, ''),
+	COALESCE(u.department, ''), u.active
+	FROM users u LEFT JOIN roles r ON r.id = u.role_id`
+
+// ListDirectoryUsers returns every user, ordered by ID.
+//
+// Returns:
+//   - []models.DirectoryUser: The users.
+//   - error: An error if the query fails.
+func (s *DBProvisioningStore) ListDirectoryUsers(ctx context.Context) ([]models.DirectoryUser, error) {
+	rows, err := s.DB.QueryContext(ctx, directoryUserColumns+" ORDER BY u.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.DirectoryUser{}
+	for rows.Next() {
+		var u models.DirectoryUser
+		if err := rows.Scan(&u.ID, &u.ExternalID, &u.Name, &u.Email, &u.Role, &u.Department, &u.Active); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetDirectoryUser returns one user.
+//
+// Parameters:
+//   - id: The user ID.
+//
+// Returns:
+//   - *models.DirectoryUser: The user.
+//   - error: A models.NotFound error if there is no such user, or an error if the query fails.
+func (s *DBProvisioningStore) GetDirectoryUser(ctx context.Context, id int) (*models.DirectoryUser, error) {
+	var u models.DirectoryUser
+	err := s.DB.QueryRowContext(ctx, directoryUserColumns+" WHERE u.id = $1", id).
+		Scan(&u.ID, &u.ExternalID, &u.Name, &u.Email, &u.Role, &u.Department, &u.Active)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("user %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	return &u, nil
+}
+
+// RoleNames returns the names of all roles.
+func (s *DBProvisioningStore) RoleNames(ctx context.Context) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT role_name FROM roles ORDER BY role_name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SaveDirectoryUsers creates the users without an ID and updates the others in one
+// transaction. New users have no password, so they set one before their first login.
+//
+// Parameters:
+//   - users: The users to save; created users get their ID.
+//
+// Returns:
+//   - error: A models.Conflict error if an email or external ID is taken, or an error if
+//     the transaction fails. Nothing is saved on error.
+func (s *DBProvisioningStore) SaveDirectoryUsers(ctx context.Context, users []*models.DirectoryUser) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, u := range users {
+		if u.ID == 0 {
+			err = tx.QueryRowContext(ctx,
+				`INSERT INTO users (external_id, name, email, role_id, department, active)
+				 SELECT NULLIF($1, ''), $2, $3, id, $5, $6 FROM roles WHERE role_name = $4
+				 RETURNING id`,
+				u.ExternalID, u.Name, u.Email, u.Role, u.Department, u.Active).Scan(&u.ID)
+		} else {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE users SET external_id = NULLIF($2, ''), name = $3, email = $4,
+				 role_id = (SELECT id FROM roles WHERE role_name = $5), department = $6, active = $7
+				 WHERE id = $1`,
+				u.ID, u.ExternalID, u.Name, u.Email, u.Role, u.Department, u.Active)
+		}
+		if err == sql.ErrNoRows {
+			return models.Invalid("role %q does not exist", u.Role)
+		}
+		if models.IsUniqueViolation(err) {
+			return models.Conflict("the email or external ID of %s is already taken", u.Email)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save user %s: %w", u.Email, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,138 @@
+package purchase_order_handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/documents"
+	"erp/controllers/httperr"
+	"erp/controllers/purchasing"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// PurchaseOrderSeries is the numbering series of printed purchase orders.
+const PurchaseOrderSeries = "purchase_order"
+
+// CompanyProvider supplies the company profile, typically the settings service.
+type CompanyProvider interface {
+	Company(ctx context.Context) (*models.CompanyProfile, error)
+}
+
+// DocumentHandler generates the purchase orders sent to suppliers.
+type DocumentHandler struct {
+	Service    *purchasing.Service
+	Suppliers  models.SupplierStore
+	Warehouses models.WarehouseStore
+	Products   models.ProductStore
+	Numbers    documents.Numberer
+	Company    CompanyProvider // Optional source of the company header
+}
+
+// GetPurchaseOrderPDF renders a purchase order for its supplier. The document is numbered
+// the first time it is generated and keeps its number when printed again.
+//
+// HTTP Method: GET
+// URL Path: /purchase_orders/{id}/pdf (format=html renders it as HTML)
+//
+// Response:
+//   - Status Code: 200 (OK) with the order as PDF, or as HTML with format=html.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 500 (Internal Server Error) if the document cannot be generated.
+func (h *DocumentHandler) GetPurchaseOrderPDF(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	order, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load purchase order")
+		return
+	}
+	supplier, err := h.Suppliers.GetSupplier(r.Context(), order.SupplierID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load supplier")
+		return
+	}
+	warehouse, err := h.Warehouses.GetWarehouseByID(r.Context(), order.WarehouseID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load warehouse")
+		return
+	}
+	names := make(map[int]string, len(order.Lines))
+	for _, line := range order.Lines {
+		product, err := h.Products.GetProductByID(r.Context(), line.ProductID)
+		if err == nil {
+			names[line.ProductID] = product.Name
+		} else if !errors.Is(err, models.ErrNotFound) {
+			httperr.Write(w, err, "Failed to load products")
+			return
+		}
+	}
+	number, err := h.Numbers.Number(r.Context(), PurchaseOrderSeries, order.ID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to number purchase order")
+		return
+	}
+	var company *models.CompanyProfile
+	if h.Company != nil {
+		if company, err = h.Company.Company(r.Context()); err != nil {
+			httperr.Write(w, err, "Failed to load company profile")
+			return
+		}
+	}
+
+	if err := documents.Write(w, r, purchaseOrderDocument(order, supplier, warehouse, names, number, company)); err != nil {
+		httperr.Write(w, err, "Failed to render purchase order")
+	}
+}
+
+// purchaseOrderDocument lays out a purchase order. Products missing from names are printed
+// by ID.
+func purchaseOrderDocument(order *models.PurchaseOrder, supplier *models.Supplier, warehouse *models.Warehouse,
+	names map[int]string, number string, company *models.CompanyProfile) *models.PrintedDocument {
+	doc := &models.PrintedDocument{
+		Title:   "Purchase Order",
+		Number:  number,
+		Date:    order.CreatedAt,
+		Company: company,
+		Parties: []models.DocumentParty{
+			{Label: "Supplier", Lines: nonEmpty(supplier.Name, supplier.Email, supplier.Phone)},
+			{Label: "Deliver to", Lines: nonEmpty(warehouse.Name, warehouse.Location)},
+		},
+		Columns: []string{"Product", "Quantity", "Unit cost", "Amount"},
+		Lines:   [][]string{},
+		Notes:   order.Note,
+	}
+	if order.ExpectedOn != nil {
+		doc.Details = append(doc.Details, models.DocumentField{Label: "Expected on", Value: order.ExpectedOn.Format("2006-01-02")})
+	}
+
+	for _, line := range order.Lines {
+		name := names[line.ProductID]
+		if name == "" {
+			name = fmt.Sprintf("Product #%d", line.ProductID)
+		}
+		doc.Lines = append(doc.Lines, []string{name, strconv.Itoa(line.Quantity), money(line.UnitCost),
+			money(float64(line.Quantity) * line.UnitCost)})
+	}
+	doc.Totals = []models.DocumentField{{Label: "Total", Value: money(order.Total)}}
+	return doc
+}
+
+// money formats an amount with two decimals.
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// nonEmpty returns the values that are not empty.
+func nonEmpty(values ...string) []string {
+	var lines []string
+	for _, value := range values {
+		if value != "" {
+			lines = append(lines, value)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,36 @@
+package purchase_order_handlers
+
+import (
+	"testing"
+	"time"
+
+	"erp/controllers/documents"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurchaseOrderDocument(t *testing.T) {
+	expected := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	order := &models.PurchaseOrder{
+		ID: 4, SupplierID: 3, WarehouseID: 2, ExpectedOn: &expected, Note: "Deliver to the back door",
+		CreatedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+		Lines:     []models.PurchaseOrderLine{{ProductID: 7, Quantity: 10, UnitCost: 4.5}, {ProductID: 8, Quantity: 3, UnitCost: 20}},
+		Total:     105,
+	}
+	doc := purchaseOrderDocument(order, &models.Supplier{Name: "Acme Supplies", Email: "orders@acme.example"},
+		&models.Warehouse{Name: "Main", Location: "Dhaka"}, map[int]string{7: "Desk lamp"}, "PO-00001", nil)
+
+	body, err := documents.HTML(doc)
+	require.NoError(t, err)
+	html := string(body)
+	assert.Contains(t, html, "<h1>Purchase Order</h1>No. PO-00001<br>Date: 2026-03-01")
+	assert.Contains(t, html, "<strong>Supplier</strong><br>Acme Supplies<br>orders@acme.example<br>")
+	assert.Contains(t, html, "<strong>Deliver to</strong><br>Main<br>Dhaka<br>")
+	assert.Contains(t, html, "<dt>Expected on</dt><dd>2026-03-10</dd>")
+	assert.Contains(t, html, "<td>Desk lamp</td><td>10</td><td>4.50</td><td>45.00</td>")
+	assert.Contains(t, html, "<td>Product #8</td><td>3</td><td>20.00</td><td>60.00</td>")
+	assert.Contains(t, html, "<dt>Total</dt><dd>105.00</dd>")
+	assert.Contains(t, html, "Deliver to the back door")
+}
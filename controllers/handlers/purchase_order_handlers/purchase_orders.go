@@ -0,0 +1,291 @@
+// Package purchase_order_handlers provides HTTP handlers and the database store for
+// purchase orders: creation, approval, receiving into stock with a payment drafted in
+// accounts payable, and closing.
+package purchase_order_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/purchasing"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// PurchaseOrderHandler provides HTTP handlers for purchase orders. The rules live in the
+// purchasing service; the handlers only translate HTTP.
+type PurchaseOrderHandler struct {
+	Service *purchasing.Service
+}
+
+// PurchaseOrderRequest is the request body for raising a purchase order. The status, the
+// received quantities and who did what when are set by the server.
+type PurchaseOrderRequest struct {
+	SupplierID         int                        `json:"supplier_id"`
+	WarehouseID        int                        `json:"warehouse_id"`
+	ExpectedOn         string                     `json:"expected_on"` // YYYY-MM-DD; optional
+	Note               string                     `json:"note"`
+	InspectionRequired bool                       `json:"inspection_required"` // Quarantine receipts until inspected
+	Lines              []PurchaseOrderLineRequest `json:"lines"`
+}
+
+// PurchaseOrderLineRequest is one product of a PurchaseOrderRequest.
+type PurchaseOrderLineRequest struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitCost  float64 `json:"unit_cost"`
+}
+
+// PurchaseOrder returns the purchase order described by the request.
+func (req PurchaseOrderRequest) PurchaseOrder() (models.PurchaseOrder, error) {
+	order := models.PurchaseOrder{SupplierID: req.SupplierID, WarehouseID: req.WarehouseID, Note: req.Note,
+		InspectionRequired: req.InspectionRequired}
+	if req.ExpectedOn != "" {
+		date, err := time.Parse("2006-01-02", req.ExpectedOn)
+		if err != nil {
+			return order, models.Invalid("expected_on must be a date formatted as YYYY-MM-DD")
+		}
+		order.ExpectedOn = &date
+	}
+	order.Lines = make([]models.PurchaseOrderLine, len(req.Lines))
+	for i, line := range req.Lines {
+		order.Lines[i] = models.PurchaseOrderLine{ProductID: line.ProductID, Quantity: line.Quantity, UnitCost: line.UnitCost}
+	}
+	return order, nil
+}
+
+// ReceiptRequest is the request body for receiving goods. An empty body receives everything
+// outstanding.
+type ReceiptRequest struct {
+	Lines []models.PurchaseOrderReceiptLine `json:"lines"`
+}
+
+// CloneRequest holds optional overrides applied to a cloned purchase order. Fields left out
+// of the request body are copied from the source order, except expected_on.
+type CloneRequest struct {
+	SupplierID  *int    `json:"supplier_id"`
+	WarehouseID *int    `json:"warehouse_id"`
+	ExpectedOn  *string `json:"expected_on"` // YYYY-MM-DD
+	Note        *string `json:"note"`
+}
+
+// Overrides returns the clone overrides described by the request.
+func (req CloneRequest) Overrides() (purchasing.Overrides, error) {
+	overrides := purchasing.Overrides{SupplierID: req.SupplierID, WarehouseID: req.WarehouseID, Note: req.Note}
+	if req.ExpectedOn != nil {
+		date, err := time.Parse("2006-01-02", *req.ExpectedOn)
+		if err != nil {
+			return overrides, models.Invalid("expected_on must be a date formatted as YYYY-MM-DD")
+		}
+		overrides.ExpectedOn = &date
+	}
+	return overrides, nil
+}
+
+// CreatePurchaseOrder raises a draft purchase order.
+//
+// HTTP Method: POST
+// URL Path: /purchase_orders
+//
+// Request Body:
+//   - JSON with supplier_id, warehouse_id, an optional expected_on, note and
+//     inspection_required, and lines of product_id, quantity and unit_cost (see
+//     PurchaseOrderRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the draft order in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the order is incomplete or names a supplier,
+//     warehouse or product that does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be recorded.
+func (h *PurchaseOrderHandler) CreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	var req PurchaseOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	order, err := req.PurchaseOrder()
+	if err != nil {
+		httperr.Write(w, err, "Invalid purchase order")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Create(r.Context(), &order, actor); err != nil {
+		httperr.Write(w, err, "Failed to create purchase order")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, order)
+}
+
+// ListPurchaseOrders lists purchase orders, newest first.
+//
+// HTTP Method: GET
+// URL Path: /purchase_orders?status=approved&supplier_id=3
+// (both are optional; status is draft, approved, partially_received, received or closed)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of purchase orders in JSON.
+//   - Status Code: 400 (Bad Request) if supplier_id is not a number.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the orders cannot be loaded.
+func (h *PurchaseOrderHandler) ListPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	var supplierID int
+	if value := r.URL.Query().Get("supplier_id"); value != "" {
+		var err error
+		if supplierID, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid supplier_id")
+			return
+		}
+	}
+	orders, err := h.Service.List(r.Context(), r.URL.Query().Get("status"), supplierID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load purchase orders")
+		return
+	}
+	respond.JSON(w, http.StatusOK, orders)
+}
+
+// GetPurchaseOrder returns a purchase order with its lines and receipts.
+//
+// HTTP Method: GET
+// URL Path: /purchase_orders/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the order in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be loaded.
+func (h *PurchaseOrderHandler) GetPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	order, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load purchase order")
+		return
+	}
+	respond.JSON(w, http.StatusOK, order)
+}
+
+// ApprovePurchaseOrder approves a draft purchase order so its goods can be received.
+//
+// HTTP Method: POST
+// URL Path: /purchase_orders/{id}/approve
+//
+// Response:
+//   - Status Code: 200 (OK) with the approved order in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 409 (Conflict) if the order is not a draft.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be approved.
+func (h *PurchaseOrderHandler) ApprovePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Approve, "Failed to approve purchase order")
+}
+
+// ReceivePurchaseOrder records goods received against an approved order. They are added to
+// the order's warehouse, or quarantined in quality holds if the order requires inspection,
+// and a pending payment for their cost is drafted in accounts payable, to be approved there.
+//
+// HTTP Method: POST
+// URL Path: /purchase_orders/{id}/receive
+//
+// Request Body:
+//   - JSON with lines of product_id and quantity received. An empty body receives
+//     everything outstanding.
+//
+// Response:
+//   - Status Code: 200 (OK) with the order in JSON, including the receipt and its payment_id.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 409 (Conflict) if the order is not approved or partially received, or
+//     nothing is left to receive.
+//   - Status Code: 422 (Unprocessable Entity) if a product is not on the order or more is
+//     received than is outstanding.
+//   - Status Code: 500 (Internal Server Error) if the receipt cannot be recorded.
+func (h *PurchaseOrderHandler) ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req ReceiptRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	order, err := h.Service.Receive(r.Context(), id, req.Lines, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to receive purchase order")
+		return
+	}
+	respond.JSON(w, http.StatusOK, order)
+}
+
+// ClosePurchaseOrder closes an approved purchase order; nothing more can be received.
+//
+// HTTP Method: POST
+// URL Path: /purchase_orders/{id}/close
+//
+// Response:
+//   - Status Code: 200 (OK) with the closed order in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 409 (Conflict) if the order is a draft or already closed.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be closed.
+func (h *PurchaseOrderHandler) ClosePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Close, "Failed to close purchase order")
+}
+
+// ClonePurchaseOrder copies a purchase order as a new draft raised by the signed-in user. The
+// copy orders the same products at the same costs, whatever the source order's status.
+//
+// HTTP Method: POST
+// URL Path: /purchase_orders/{id}/clone
+//
+// Request Body (optional):
+//   - JSON with any of supplier_id, warehouse_id, expected_on and note to override (see
+//     CloneRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the new draft order in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the source order does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if an override is invalid or names a supplier
+//     or warehouse that does not exist.
+//   - Status Code: 500 (Internal Server Error) if the copy cannot be recorded.
+func (h *PurchaseOrderHandler) ClonePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req CloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	overrides, err := req.Overrides()
+	if err != nil {
+		httperr.Write(w, err, "Invalid purchase order")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	clone, err := h.Service.Clone(r.Context(), id, overrides, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to clone purchase order")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, clone)
+}
+
+// change applies a status change to the order in the URL and writes the result.
+func (h *PurchaseOrderHandler) change(w http.ResponseWriter, r *http.Request,
+	apply func(ctx context.Context, id int, actor string) (*models.PurchaseOrder, error), failure string) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	order, err := apply(r.Context(), id, actor)
+	if err != nil {
+		httperr.Write(w, err, failure)
+		return
+	}
+	respond.JSON(w, http.StatusOK, order)
+}
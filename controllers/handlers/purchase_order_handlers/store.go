@@ -0,0 +1,421 @@
+package purchase_order_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"erp/controllers/events"
+	"erp/controllers/purchasing"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBPurchaseOrderStore implements models.PurchaseOrderStore using a SQL database. Every
+// status change locks the order first, so two users cannot receive the same goods twice.
+type DBPurchaseOrderStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// orderColumns are the columns of purchase_orders read by scanOrder.
+const orderColumns = `id, supplier_id, warehouse_id, status, expected_on, note, created_by, created_at, approved_by,
+	approved_at, closed_by, closed_at, inspection_required`
+
+// orderQueryer is satisfied by both *sql.DB and *sql.Tx.
+type orderQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// CreatePurchaseOrder records a draft order with its lines.
+//
+// Parameters:
+//   - order: A validated order with one line per product; its ID and creation time are set.
+//
+// Returns:
+//   - error: A validation error if the supplier, warehouse or a product does not exist, or
+//     the query error.
+func (s *DBPurchaseOrderStore) CreatePurchaseOrder(ctx context.Context, order *models.PurchaseOrder) error {
+	productIDs := make(pq.Int64Array, len(order.Lines))
+	quantities := make(pq.Int64Array, len(order.Lines))
+	costs := make(pq.Float64Array, len(order.Lines))
+	for i, line := range order.Lines {
+		productIDs[i], quantities[i], costs[i] = int64(line.ProductID), int64(line.Quantity), line.UnitCost
+	}
+	order.CreatedAt = time.Now()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO purchase_orders (supplier_id, warehouse_id, status, expected_on, note, created_by, created_at,
+		 inspection_required)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		order.SupplierID, order.WarehouseID, order.Status, order.ExpectedOn, order.Note, order.CreatedBy, order.CreatedAt,
+		order.InspectionRequired,
+	).Scan(&order.ID)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("supplier %d or warehouse %d does not exist", order.SupplierID, order.WarehouseID)
+	} else if err != nil {
+		return fmt.Errorf("failed to record purchase order: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO purchase_order_lines (purchase_order_id, product_id, quantity, unit_cost)
+		 SELECT $1, l.product_id, l.quantity, l.unit_cost
+		 FROM unnest($2::int[], $3::int[], $4::numeric[]) AS l(product_id, quantity, unit_cost)`,
+		order.ID, productIDs, quantities, costs,
+	)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("a product on the purchase order does not exist")
+	} else if err != nil {
+		return fmt.Errorf("failed to record purchase order lines: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetPurchaseOrder retrieves an order with its lines and receipts.
+//
+// Parameters:
+//   - id: The ID of the order.
+//
+// Returns:
+//   - *models.PurchaseOrder: The order.
+//   - error: models.ErrNotFound if the order does not exist, or the query error.
+func (s *DBPurchaseOrderStore) GetPurchaseOrder(ctx context.Context, id int) (*models.PurchaseOrder, error) {
+	return getOrder(ctx, s.DB, id, "")
+}
+
+// ListPurchaseOrders retrieves the orders in a status, or all of them if status is empty,
+// newest first. Receipts are left out; they are read with GetPurchaseOrder.
+//
+// Parameters:
+//   - status: One of the models.PurchaseOrder* statuses, or empty.
+//   - supplierID: The supplier, or 0 for every supplier.
+//
+// Returns:
+//   - []models.PurchaseOrder: The orders with their lines.
+//   - error: The query error.
+func (s *DBPurchaseOrderStore) ListPurchaseOrders(ctx context.Context, status string, supplierID int) ([]models.PurchaseOrder, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+orderColumns+` FROM purchase_orders
+		 WHERE ($1 = '' OR status = $1) AND ($2 = 0 OR supplier_id = $2) ORDER BY id DESC`, status, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.PurchaseOrder{}
+	index := map[int]int{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[order.ID] = len(orders)
+		orders = append(orders, *order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	ids := make(pq.Int64Array, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, int64(order.ID))
+	}
+	rows, err = s.DB.QueryContext(ctx,
+		`SELECT purchase_order_id, product_id, quantity, unit_cost, received FROM purchase_order_lines
+		 WHERE purchase_order_id = ANY($1) ORDER BY id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orderID int
+		var line models.PurchaseOrderLine
+		if err := rows.Scan(&orderID, &line.ProductID, &line.Quantity, &line.UnitCost, &line.Received); err != nil {
+			return nil, err
+		}
+		order := &orders[index[orderID]]
+		order.Lines = append(order.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		purchasing.Total(&orders[i])
+	}
+	return orders, nil
+}
+
+// ApprovePurchaseOrder approves a draft order so it can be received.
+//
+// Returns:
+//   - *models.PurchaseOrder: The approved order.
+//   - error: models.ErrNotFound, a conflict if the order is not a draft, or the query error.
+func (s *DBPurchaseOrderStore) ApprovePurchaseOrder(ctx context.Context, id int, actor string) (*models.PurchaseOrder, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, order *models.PurchaseOrder) error {
+		now := time.Now()
+		order.Status, order.ApprovedBy, order.ApprovedAt = models.PurchaseOrderApproved, actor, &now
+		_, err := tx.ExecContext(ctx, `UPDATE purchase_orders SET status = $1, approved_by = $2, approved_at = $3 WHERE id = $4`,
+			order.Status, actor, now, id)
+		return err
+	}, models.PurchaseOrderDraft)
+}
+
+// ReceivePurchaseOrder adds the received quantities to the order's warehouse, to its first
+// stock entry of each product or to a new one, and drafts a pending payment for their cost
+// in accounts payable. The receipt, the stock and the payment are recorded in one
+// transaction, and a StockMoved event is written to the outbox. If the order requires
+// inspection, each product received is quarantined in a quality hold instead, and no stock
+// moves until the hold is inspected.
+//
+// Parameters:
+//   - id: The ID of the order.
+//   - lines: The quantities received, checked against the order by the purchasing service.
+//   - actor: Email of the user receiving the goods; the payment is recorded as theirs.
+//
+// Returns:
+//   - *models.PurchaseOrder: The order with the new receipt.
+//   - error: models.ErrNotFound, a conflict if the order is not approved or partially
+//     received or more is received than is outstanding, or the query error.
+func (s *DBPurchaseOrderStore) ReceivePurchaseOrder(ctx context.Context, id int, lines []models.PurchaseOrderReceiptLine, actor string) (*models.PurchaseOrder, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, order *models.PurchaseOrder) error {
+		received := make(map[int]int, len(lines))
+		for _, line := range lines {
+			received[line.ProductID] = line.Quantity
+		}
+		for i := range order.Lines {
+			line := &order.Lines[i]
+			quantity := received[line.ProductID]
+			if quantity == 0 {
+				continue
+			}
+			if quantity > line.Outstanding() {
+				return models.Conflict("product %d: %d received but only %d outstanding", line.ProductID, quantity, line.Outstanding())
+			}
+			line.Received += quantity
+			if _, err := tx.ExecContext(ctx, `UPDATE purchase_order_lines SET received = $1 WHERE purchase_order_id = $2 AND product_id = $3`,
+				line.Received, id, line.ProductID); err != nil {
+				return err
+			}
+			if order.InspectionRequired {
+				continue
+			}
+			if err := addStock(ctx, tx, line.ProductID, quantity, order.WarehouseID); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		receipt := models.PurchaseOrderReceipt{Lines: lines, Amount: purchasing.ReceiptAmount(order, lines),
+			ReceivedBy: actor, ReceivedAt: now}
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO payments (invoice_id, purchase_order_id, amount, payment_date, payment_method, status, created_by)
+			 VALUES (NULL, $1, $2, $3, '', $4, $5) RETURNING id`,
+			id, receipt.Amount, now, models.PaymentPending, actor,
+		).Scan(&receipt.PaymentID)
+		if err != nil {
+			return fmt.Errorf("failed to draft payment: %w", err)
+		}
+		data, err := json.Marshal(lines)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO purchase_order_receipts (purchase_order_id, lines, amount, payment_id, received_by, received_at)
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			id, data, receipt.Amount, receipt.PaymentID, actor, now,
+		).Scan(&receipt.ID)
+		if err != nil {
+			return fmt.Errorf("failed to record receipt: %w", err)
+		}
+		order.Receipts = append(order.Receipts, receipt)
+
+		order.Status = purchasing.Status(order)
+		if _, err := tx.ExecContext(ctx, `UPDATE purchase_orders SET status = $1 WHERE id = $2`, order.Status, id); err != nil {
+			return err
+		}
+		if order.InspectionRequired {
+			return holdReceipt(ctx, tx, order, receipt)
+		}
+		return events.Enqueue(ctx, tx, events.StockMoved, "purchase_order", id, receipt)
+	}, models.PurchaseOrderApproved, models.PurchaseOrderPartiallyReceived)
+}
+
+// ClosePurchaseOrder closes an approved order, whether or not everything has been received.
+//
+// Returns:
+//   - *models.PurchaseOrder: The closed order.
+//   - error: models.ErrNotFound, a conflict if the order is a draft or already closed, or
+//     the query error.
+func (s *DBPurchaseOrderStore) ClosePurchaseOrder(ctx context.Context, id int, actor string) (*models.PurchaseOrder, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, order *models.PurchaseOrder) error {
+		now := time.Now()
+		order.Status, order.ClosedBy, order.ClosedAt = models.PurchaseOrderClosed, actor, &now
+		_, err := tx.ExecContext(ctx, `UPDATE purchase_orders SET status = $1, closed_by = $2, closed_at = $3 WHERE id = $4`,
+			order.Status, actor, now, id)
+		return err
+	}, models.PurchaseOrderApproved, models.PurchaseOrderPartiallyReceived, models.PurchaseOrderReceived)
+}
+
+// addStock adds a quantity of a product to a warehouse's first stock entry of it, or to a
+// new entry.
+func addStock(ctx context.Context, tx *sql.Tx, productID, quantity, warehouseID int) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE stock SET quantity = quantity + $1
+		 WHERE id = (SELECT id FROM stock WHERE product_id = $2 AND warehouse_id = $3 ORDER BY id LIMIT 1)`,
+		quantity, productID, warehouseID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add stock: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		_, err := tx.ExecContext(ctx, `INSERT INTO stock (product_id, quantity, warehouse_id, location) VALUES ($1, $2, $3, '')`,
+			productID, quantity, warehouseID)
+		if err != nil {
+			return fmt.Errorf("failed to add stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// holdReceipt quarantines each product of a receipt in a quality hold at the order's
+// warehouse, to be inspected.
+func holdReceipt(ctx context.Context, tx *sql.Tx, order *models.PurchaseOrder, receipt models.PurchaseOrderReceipt) error {
+	for _, line := range receipt.Lines {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO quality_holds (purchase_order_id, receipt_id, product_id, warehouse_id, quantity, status, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			order.ID, receipt.ID, line.ProductID, order.WarehouseID, line.Quantity, models.HoldQuarantined, receipt.ReceivedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to hold goods for inspection: %w", err)
+		}
+	}
+	return nil
+}
+
+// transition locks an order, checks that it is in one of the statuses in from and applies
+// change in the same transaction.
+func (s *DBPurchaseOrderStore) transition(ctx context.Context, id int, change func(tx *sql.Tx, order *models.PurchaseOrder) error, from ...string) (*models.PurchaseOrder, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := getOrder(ctx, tx, id, " FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || order.Status == status
+	}
+	if !allowed {
+		return nil, models.Conflict("purchase order %d is %s", id, order.Status)
+	}
+	if err := change(tx, order); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// getOrder reads an order with its lines and receipts, adding lock to the query of the order.
+func getOrder(ctx context.Context, q orderQueryer, id int, lock string) (*models.PurchaseOrder, error) {
+	order, err := scanOrder(q.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM purchase_orders WHERE id = $1`+lock, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("purchase order %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT product_id, quantity, unit_cost, received FROM purchase_order_lines WHERE purchase_order_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var line models.PurchaseOrderLine
+		if err := rows.Scan(&line.ProductID, &line.Quantity, &line.UnitCost, &line.Received); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		order.Lines = append(order.Lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT id, lines, amount, COALESCE(payment_id, 0), received_by, received_at FROM purchase_order_receipts
+		 WHERE purchase_order_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var receipt models.PurchaseOrderReceipt
+		var lines []byte
+		if err := rows.Scan(&receipt.ID, &lines, &receipt.Amount, &receipt.PaymentID, &receipt.ReceivedBy,
+			&receipt.ReceivedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(lines, &receipt.Lines); err != nil {
+			return nil, err
+		}
+		order.Receipts = append(order.Receipts, receipt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	purchasing.Total(order)
+	return order, nil
+}
+
+// orderScanner is satisfied by both *sql.Row and *sql.Rows.
+type orderScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanOrder reads a row of orderColumns.
+func scanOrder(row orderScanner) (*models.PurchaseOrder, error) {
+	var o models.PurchaseOrder
+	var approvedBy, closedBy sql.NullString
+	var expectedOn, approvedAt, closedAt sql.NullTime
+	err := row.Scan(&o.ID, &o.SupplierID, &o.WarehouseID, &o.Status, &expectedOn, &o.Note, &o.CreatedBy, &o.CreatedAt,
+		&approvedBy, &approvedAt, &closedBy, &closedAt, &o.InspectionRequired)
+	if err != nil {
+		return nil, err
+	}
+	o.ExpectedOn = timePtr(expectedOn)
+	o.ApprovedBy, o.ApprovedAt = approvedBy.String, timePtr(approvedAt)
+	o.ClosedBy, o.ClosedAt = closedBy.String, timePtr(closedAt)
+	o.Lines = []models.PurchaseOrderLine{}
+	o.Receipts = []models.PurchaseOrderReceipt{}
+	return &o, nil
+}
+
+// timePtr returns the time, or nil if it is NULL.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,126 @@
+package purchase_order_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectLockedOrder expects a purchase order for warehouse 2 in status to be locked and read
+// with its lines of product 7 (10 ordered at 4.50, 4 received) and product 8 (3 ordered at
+// 20, none received), and no receipts.
+func expectLockedOrder(mock sqlmock.Sqlmock, status string) {
+	expectLockedOrderInspected(mock, status, false)
+}
+
+// expectLockedOrderInspected is expectLockedOrder for an order that may require inspection.
+func expectLockedOrderInspected(mock sqlmock.Sqlmock, status string, inspection bool) {
+	created := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM purchase_orders WHERE id = $1 FOR UPDATE")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "warehouse_id", "status", "expected_on", "note",
+			"created_by", "created_at", "approved_by", "approved_at", "closed_by", "closed_at", "inspection_required"}).
+			AddRow(4, 3, 2, status, nil, "", "buyer@example.com", created, "finance@example.com", created, nil, nil, inspection))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM purchase_order_lines")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity", "unit_cost", "received"}).
+			AddRow(7, 10, 4.5, 4).AddRow(8, 3, 20.0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM purchase_order_receipts")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "lines", "amount", "payment_id", "received_by", "received_at"}))
+}
+
+func TestReceivePurchaseOrderDraftsPayment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPurchaseOrderStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedOrder(mock, models.PurchaseOrderPartiallyReceived)
+	// The rest of product 7 is added to the warehouse's stock; product 8 is still to come
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE purchase_order_lines SET received")).WithArgs(10, 4, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity + $1")).WithArgs(6, 7, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO payments")).
+		WithArgs(4, 27.0, sqlmock.AnyArg(), models.PaymentPending, "clerk@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO purchase_order_receipts")).
+		WithArgs(4, []byte(`[{"product_id":7,"quantity":6}]`), 27.0, 31, "clerk@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE purchase_orders SET status")).
+		WithArgs(models.PurchaseOrderPartiallyReceived, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	order, err := store.ReceivePurchaseOrder(context.Background(), 4, []models.PurchaseOrderReceiptLine{{ProductID: 7, Quantity: 6}}, "clerk@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.PurchaseOrderPartiallyReceived, order.Status)
+	assert.Equal(t, 10, order.Lines[0].Received)
+	require.Len(t, order.Receipts, 1)
+	assert.Equal(t, 31, order.Receipts[0].PaymentID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceivePurchaseOrderForInspection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPurchaseOrderStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedOrderInspected(mock, models.PurchaseOrderApproved, true)
+	// The goods are received and paid for, but held for inspection instead of being stocked
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE purchase_order_lines SET received")).WithArgs(3, 4, 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO payments")).
+		WithArgs(4, 60.0, sqlmock.AnyArg(), models.PaymentPending, "clerk@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO purchase_order_receipts")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE purchase_orders SET status")).
+		WithArgs(models.PurchaseOrderPartiallyReceived, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO quality_holds")).
+		WithArgs(4, 2, 8, 2, 3, models.HoldQuarantined, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectCommit()
+
+	order, err := store.ReceivePurchaseOrder(context.Background(), 4, []models.PurchaseOrderReceiptLine{{ProductID: 8, Quantity: 3}}, "clerk@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 3, order.Lines[1].Received)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceivePurchaseOrderBeyondOutstanding(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPurchaseOrderStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedOrder(mock, models.PurchaseOrderApproved)
+	mock.ExpectRollback()
+
+	_, err = store.ReceivePurchaseOrder(context.Background(), 4, []models.PurchaseOrderReceiptLine{{ProductID: 7, Quantity: 7}}, "clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceiveDraftPurchaseOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPurchaseOrderStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedOrder(mock, models.PurchaseOrderDraft)
+	mock.ExpectRollback()
+
+	_, err = store.ReceivePurchaseOrder(context.Background(), 4, []models.PurchaseOrderReceiptLine{{ProductID: 8, Quantity: 1}}, "clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
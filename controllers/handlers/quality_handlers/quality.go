@@ -0,0 +1,127 @@
+// Package quality_handlers provides HTTP handlers and the database store for quality control
+// holds: goods received for inspection and the inspections that release or reject them.
+package quality_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/quality"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// QualityHandler provides HTTP handlers for quality holds. The rules
+// live in the quality service; the handlers only translate HTTP.
+type QualityHandler struct {
+	Service *quality.Service
+}
+
+// InspectionRequest is the request body for inspecting a hold.
+type InspectionRequest struct {
+	SampleSize       int      `json:"sample_size"`
+	Result           string   `json:"result"`
+	RejectedQuantity int      `json:"rejected_quantity"`
+	Notes            string   `json:"notes"`
+	Photos           []string `json:"photos"`
+}
+
+// ListHolds lists quality holds, oldest first.
+//
+// HTTP Method: GET
+// URL Path: /quality/holds?status=quarantined&purchase_order_id=4
+// (both are optional; status is quarantined, released, rejected or partially_rejected)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of QualityHolds in JSON.
+//   - Status Code: 400 (Bad Request) if purchase_order_id is not a number.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the holds cannot be loaded.
+func (h *QualityHandler) ListHolds(w http.ResponseWriter, r *http.Request) {
+	orderID, ok := queryID(w, r, "purchase_order_id")
+	if !ok {
+		return
+	}
+	holds, err := h.Service.Holds(r.Context(), r.URL.Query().Get("status"), orderID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load quality holds")
+		return
+	}
+	respond.JSON(w, http.StatusOK, holds)
+}
+
+// GetHold returns a quality hold with its inspection.
+//
+// HTTP Method: GET
+// URL Path: /quality/holds/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the QualityHold in JSON.
+//   - Status Code: 404 (Not Found) if the hold does not exist.
+//   - Status Code: 500 (Internal Server Error) if the hold cannot be loaded.
+func (h *QualityHandler) GetHold(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	hold, err := h.Service.Hold(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load quality hold")
+		return
+	}
+	respond.JSON(w, http.StatusOK, hold)
+}
+
+// InspectHold records the inspection of quarantined goods. A pass adds them to the
+// warehouse's stock; a fail rejects the rejected quantity (all of it by default), adds the
+// rest to stock and raises a supplier return for the rejected goods.
+//
+// HTTP Method: POST
+// URL Path: /quality/holds/{id}/inspection
+//
+// Request Body:
+//   - JSON with sample_size, result (pass or fail), an optional rejected_quantity, notes
+//     (required for a fail) and photos (see InspectionRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with the QualityHold in JSON, including the inspection and the
+//     supplier_return_id of a fail.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the hold does not exist.
+//   - Status Code: 409 (Conflict) if the hold was inspected already.
+//   - Status Code: 422 (Unprocessable Entity) if the inspection is incomplete or does not
+//     fit the quantity held.
+//   - Status Code: 500 (Internal Server Error) if the inspection cannot be recorded.
+func (h *QualityHandler) InspectHold(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req InspectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	inspection := &models.Inspection{SampleSize: req.SampleSize, Result: req.Result,
+		RejectedQuantity: req.RejectedQuantity, Notes: req.Notes, Photos: req.Photos}
+	hold, err := h.Service.Inspect(r.Context(), id, inspection, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to record inspection")
+		return
+	}
+	respond.JSON(w, http.StatusOK, hold)
+}
+
+// queryID reads an optional numeric query parameter, writing 400 if it is not a number.
+func queryID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,241 @@
+package quality_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"erp/controllers/events"
+	"erp/controllers/quality"
+	"erp/models"
+)
+
+// DBQualityStore implements models.QualityStore using a SQL database. An inspection locks
+// its hold first, so the same goods cannot be released twice.
+type DBQualityStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// holdColumns are the columns of a hold and its inspection read by scanHold.
+const holdColumns = `h.id, h.purchase_order_id, h.receipt_id, o.supplier_id, h.product_id, h.warehouse_id, h.quantity,
+	h.status, h.released, h.rejected, h.created_at, i.sample_size, i.result, i.rejected_quantity, i.notes, i.photos,
+	i.supplier_return_id, i.inspected_by, i.inspected_at`
+
+// holdTables are the tables holdColumns are read from.
+const holdTables = `quality_holds h JOIN purchase_orders o ON o.id = h.purchase_order_id
+	LEFT JOIN quality_inspections i ON i.hold_id = h.id`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// ListQualityHolds retrieves the holds in a status, or all of them if status is empty,
+// oldest first.
+//
+// Parameters:
+//   - status: One of the models.Hold* statuses, or empty.
+//   - purchaseOrderID: The purchase order, or 0 for every order.
+//
+// Returns:
+//   - []models.QualityHold: The holds with their inspections.
+//   - error: The query error.
+func (s *DBQualityStore) ListQualityHolds(ctx context.Context, status string, purchaseOrderID int) ([]models.QualityHold, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+holdColumns+` FROM `+holdTables+`
+		 WHERE ($1 = '' OR h.status = $1) AND ($2 = 0 OR h.purchase_order_id = $2) ORDER BY h.id`,
+		status, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	holds := []models.QualityHold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *hold)
+	}
+	return holds, rows.Err()
+}
+
+// GetQualityHold retrieves a hold with its inspection.
+//
+// Returns:
+//   - error: models.ErrNotFound if the hold does not exist, or the query error.
+func (s *DBQualityStore) GetQualityHold(ctx context.Context, id int) (*models.QualityHold, error) {
+	return getHold(ctx, s.DB, id, "")
+}
+
+// InspectQualityHold records the inspection of a quarantined hold. The released quantity is
+// added to the warehouse's first stock entry of the product, or to a new one, and a
+// StockMoved event is written to the outbox. The rejected quantity is put on a supplier
+// return at the order's unit cost.
+//
+// Parameters:
+//   - id: The ID of the hold.
+//   - inspection: The inspection, checked by the quality service; its supplier return ID is set.
+//
+// Returns:
+//   - *models.QualityHold: The hold with its inspection.
+//   - error: models.ErrNotFound, a conflict if the hold is not quarantined, or the query error.
+func (s *DBQualityStore) InspectQualityHold(ctx context.Context, id int, inspection *models.Inspection) (*models.QualityHold, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	hold, err := getHold(ctx, tx, id, " FOR UPDATE OF h")
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != models.HoldQuarantined {
+		return nil, models.Conflict("quality hold %d is %s", id, hold.Status)
+	}
+	if inspection.RejectedQuantity > hold.Quantity {
+		return nil, models.Conflict("quality hold %d: %d rejected but only %d held", id, inspection.RejectedQuantity, hold.Quantity)
+	}
+	hold.Rejected = inspection.RejectedQuantity
+	hold.Released = hold.Quantity - hold.Rejected
+	hold.Status = quality.Status(hold.Quantity, hold.Rejected)
+
+	if hold.Released > 0 {
+		if err := addStock(ctx, tx, hold.ProductID, hold.Released, hold.WarehouseID); err != nil {
+			return nil, err
+		}
+	}
+	if hold.Rejected > 0 {
+		if inspection.SupplierReturnID, err = raiseReturn(ctx, tx, hold, inspection); err != nil {
+			return nil, err
+		}
+	}
+	photos, err := json.Marshal(inspection.Photos)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO quality_inspections (hold_id, sample_size, result, rejected_quantity, notes, photos, supplier_return_id,
+		 inspected_by, inspected_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		id, inspection.SampleSize, inspection.Result, inspection.RejectedQuantity, inspection.Notes, photos,
+		nullID(inspection.SupplierReturnID), inspection.InspectedBy, inspection.InspectedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record inspection: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE quality_holds SET status = $1, released = $2, rejected = $3 WHERE id = $4`,
+		hold.Status, hold.Released, hold.Rejected, id); err != nil {
+		return nil, err
+	}
+	hold.Inspection = inspection
+	if hold.Released > 0 {
+		if err := events.Enqueue(ctx, tx, events.StockMoved, "quality_hold", id, hold); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// raiseReturn records a supplier return of the rejected goods of a hold, at the unit cost on
+// the purchase order, and returns its ID.
+func raiseReturn(ctx context.Context, tx *sql.Tx, hold *models.QualityHold, inspection *models.Inspection) (int, error) {
+	var unitCost float64
+	err := tx.QueryRowContext(ctx, `SELECT unit_cost FROM purchase_order_lines WHERE purchase_order_id = $1 AND product_id = $2`,
+		hold.PurchaseOrderID, hold.ProductID).Scan(&unitCost)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read unit cost: %w", err)
+	}
+	var id int
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO supplier_returns (supplier_id, purchase_order_id, receipt_id, warehouse_id, hold_id, reason, status,
+		 amount, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		hold.SupplierID, hold.PurchaseOrderID, hold.ReceiptID, hold.WarehouseID, hold.ID,
+		"Rejected by quality inspection: "+inspection.Notes, models.SupplierReturnOpen,
+		math.Round(float64(hold.Rejected)*unitCost*100)/100, inspection.InspectedBy, inspection.InspectedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to raise supplier return: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO supplier_return_lines (supplier_return_id, product_id, quantity, unit_cost) VALUES ($1, $2, $3, $4)`,
+		id, hold.ProductID, hold.Rejected, unitCost)
+	if err != nil {
+		return 0, fmt.Errorf("failed to raise supplier return: %w", err)
+	}
+	return id, nil
+}
+
+// addStock adds a quantity of a product to a warehouse's first stock entry of it, or to a
+// new entry.
+func addStock(ctx context.Context, tx *sql.Tx, productID, quantity, warehouseID int) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE stock SET quantity = quantity + $1
+		 WHERE id = (SELECT id FROM stock WHERE product_id = $2 AND warehouse_id = $3 ORDER BY id LIMIT 1)`,
+		quantity, productID, warehouseID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add stock: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		_, err := tx.ExecContext(ctx, `INSERT INTO stock (product_id, quantity, warehouse_id, location) VALUES ($1, $2, $3, '')`,
+			productID, quantity, warehouseID)
+		if err != nil {
+			return fmt.Errorf("failed to add stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// getHold reads a hold with its inspection, adding lock to the query.
+func getHold(ctx context.Context, q models.Queryer, id int, lock string) (*models.QualityHold, error) {
+	hold, err := scanHold(q.QueryRowContext(ctx, `SELECT `+holdColumns+` FROM `+holdTables+` WHERE h.id = $1`+lock, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("quality hold %d not found", id)
+	}
+	return hold, err
+}
+
+// scanHold reads a row of holdColumns.
+func scanHold(row rowScanner) (*models.QualityHold, error) {
+	var h models.QualityHold
+	var sampleSize, rejected, returnID sql.NullInt64
+	var result, notes, inspectedBy sql.NullString
+	var inspectedAt sql.NullTime
+	var photos []byte
+	err := row.Scan(&h.ID, &h.PurchaseOrderID, &h.ReceiptID, &h.SupplierID, &h.ProductID, &h.WarehouseID, &h.Quantity,
+		&h.Status, &h.Released, &h.Rejected, &h.CreatedAt, &sampleSize, &result, &rejected, &notes, &photos,
+		&returnID, &inspectedBy, &inspectedAt)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Valid {
+		return &h, nil
+	}
+	h.Inspection = &models.Inspection{SampleSize: int(sampleSize.Int64), Result: result.String,
+		RejectedQuantity: int(rejected.Int64), Notes: notes.String, Photos: []string{},
+		SupplierReturnID: int(returnID.Int64), InspectedBy: inspectedBy.String, InspectedAt: inspectedAt.Time}
+	if len(photos) > 0 {
+		if err := json.Unmarshal(photos, &h.Inspection.Photos); err != nil {
+			return nil, err
+		}
+	}
+	return &h, nil
+}
+
+// nullID returns nil for a zero ID, so it is stored as NULL.
+func nullID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,80 @@
+package quality_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// holdRows are the columns read by scanHold.
+var holdRows = []string{"id", "purchase_order_id", "receipt_id", "supplier_id", "product_id", "warehouse_id", "quantity",
+	"status", "released", "rejected", "created_at", "sample_size", "result", "rejected_quantity", "notes", "photos",
+	"supplier_return_id", "inspected_by", "inspected_at"}
+
+// expectLockedHold expects hold 5 of 10 units of product 7, received on order 4 from
+// supplier 3 into warehouse 2, to be locked and read.
+func expectLockedHold(mock sqlmock.Sqlmock, status string) {
+	created := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE h.id = $1 FOR UPDATE OF h")).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(holdRows).
+			AddRow(5, 4, 2, 3, 7, 2, 10, status, 0, 0, created, nil, nil, nil, nil, nil, nil, nil, nil))
+}
+
+func TestInspectQualityHoldRaisesSupplierReturn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBQualityStore{DB: db}
+	at := time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectLockedHold(mock, models.HoldQuarantined)
+	// 6 units go into stock and 4 back to the supplier at the order's unit cost
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity + $1")).WithArgs(6, 7, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT unit_cost FROM purchase_order_lines")).WithArgs(4, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"unit_cost"}).AddRow(4.5))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO supplier_returns")).
+		WithArgs(3, 4, 2, 2, 5, "Rejected by quality inspection: Cracked casing", models.SupplierReturnOpen, 18.0,
+			"qc@example.com", at).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO supplier_return_lines")).WithArgs(8, 7, 4, 4.5).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO quality_inspections")).
+		WithArgs(5, 3, models.InspectionFail, 4, "Cracked casing", []byte(`["photo.jpg"]`), 8, "qc@example.com", at).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE quality_holds SET status = $1, released = $2, rejected = $3")).
+		WithArgs(models.HoldPartiallyRejected, 6, 4, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	hold, err := store.InspectQualityHold(context.Background(), 5, &models.Inspection{SampleSize: 3, Result: models.InspectionFail,
+		RejectedQuantity: 4, Notes: "Cracked casing", Photos: []string{"photo.jpg"}, InspectedBy: "qc@example.com", InspectedAt: at})
+	require.NoError(t, err)
+	assert.Equal(t, models.HoldPartiallyRejected, hold.Status)
+	assert.Equal(t, 6, hold.Released)
+	assert.Equal(t, 8, hold.Inspection.SupplierReturnID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInspectQualityHoldTwice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBQualityStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedHold(mock, models.HoldReleased)
+	mock.ExpectRollback()
+
+	_, err = store.InspectQualityHold(context.Background(), 5, &models.Inspection{SampleSize: 3, Result: models.InspectionPass})
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,103 @@
+// Package quarantine_handlers provides the admin API for reviewing and deleting uploads the
+// virus scanner flagged.
+package quarantine_handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// listLimit is the number of quarantined files listed.
+const listLimit = 100
+
+// QuarantineHandler provides HTTP handlers for quarantined files.
+type QuarantineHandler struct {
+	Store models.QuarantineStore
+	Files storage.Storage // The private quarantine storage
+}
+
+// RegisterRoutes maps quarantine routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth and limited to admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the QuarantineStore interface.
+//   - files: The storage the quarantined files are kept in.
+func RegisterRoutes(router *mux.Router, store models.QuarantineStore, files storage.Storage) {
+	handler := &QuarantineHandler{Store: store, Files: files}
+
+	router.HandleFunc("", handler.ListQuarantinedFiles).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetQuarantinedFile).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.DeleteQuarantinedFile).Methods("DELETE")
+}
+
+// ListQuarantinedFiles returns the most recently quarantined uploads, newest first.
+//
+// HTTP Method: GET
+// URL Path: /quarantine
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of QuarantinedFiles in JSON.
+//   - Status Code: 500 (Internal Server Error) if the list cannot be read.
+func (h *QuarantineHandler) ListQuarantinedFiles(w http.ResponseWriter, r *http.Request) {
+	files, err := h.Store.ListQuarantinedFiles(r.Context(), listLimit)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load quarantined files")
+		return
+	}
+	respond.JSON(w, http.StatusOK, files)
+}
+
+// GetQuarantinedFile returns the details of a quarantined upload. The file itself is never
+// served.
+//
+// HTTP Method: GET
+// URL Path: /quarantine/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the QuarantinedFile in JSON.
+//   - Status Code: 404 (Not Found) if there is no such file.
+//   - Status Code: 500 (Internal Server Error) if the file cannot be read.
+func (h *QuarantineHandler) GetQuarantinedFile(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	file, err := h.Store.GetQuarantinedFile(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load quarantined file")
+		return
+	}
+	respond.JSON(w, http.StatusOK, file)
+}
+
+// DeleteQuarantinedFile deletes a quarantined upload and its record.
+//
+// HTTP Method: DELETE
+// URL Path: /quarantine/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the file was deleted.
+//   - Status Code: 404 (Not Found) if there is no such file.
+//   - Status Code: 500 (Internal Server Error) if the file cannot be deleted.
+func (h *QuarantineHandler) DeleteQuarantinedFile(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	file, err := h.Store.GetQuarantinedFile(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load quarantined file")
+		return
+	}
+	if err := h.Files.Delete(file.StorageKey); err != nil {
+		httperr.Write(w, err, "Failed to delete quarantined file")
+		return
+	}
+	if err := h.Store.DeleteQuarantinedFile(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete quarantined file")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
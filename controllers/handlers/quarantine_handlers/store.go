@@ -0,0 +1,106 @@
+package quarantine_handlers
+
+import (
+	"context"
+	"database/sql"
+
+	"erp/models"
+)
+
+// DBQuarantineStore provides SQL-backed methods for the record of quarantined files.
+type DBQuarantineStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// quarantineColumns are the columns scanned by scanQuarantinedFile.
+const quarantineColumns = `id, source, entity_id, file_name, content_type, size_bytes, signature, storage_key, uploaded_by, detected_at`
+
+// scanQuarantinedFile reads a row selected with quarantineColumns.
+func scanQuarantinedFile(row interface{ Scan(...interface{}) error }) (*models.QuarantinedFile, error) {
+	var f models.QuarantinedFile
+	err := row.Scan(&f.ID, &f.Source, &f.EntityID, &f.FileName, &f.ContentType, &f.SizeBytes, &f.Signature,
+		&f.StorageKey, &f.UploadedBy, &f.DetectedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// CreateQuarantinedFile records a file moved to quarantine and sets its ID.
+//
+// Parameters:
+//   - file: The quarantined file.
+//
+// Returns:
+//   - error: An error if the insert fails.
+func (store *DBQuarantineStore) CreateQuarantinedFile(ctx context.Context, file *models.QuarantinedFile) error {
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO quarantined_files (source, entity_id, file_name, content_type, size_bytes, signature, storage_key, uploaded_by, detected_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		file.Source, file.EntityID, file.FileName, file.ContentType, file.SizeBytes, file.Signature,
+		file.StorageKey, file.UploadedBy, file.DetectedAt,
+	).Scan(&file.ID)
+}
+
+// ListQuarantinedFiles returns the most recently quarantined files, newest first.
+//
+// Parameters:
+//   - limit: The maximum number of files returned.
+//
+// Returns:
+//   - []models.QuarantinedFile: The files.
+//   - error: An error if the query fails.
+func (store *DBQuarantineStore) ListQuarantinedFiles(ctx context.Context, limit int) ([]models.QuarantinedFile, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT `+quarantineColumns+` FROM quarantined_files ORDER BY detected_at DESC, id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []models.QuarantinedFile{}
+	for rows.Next() {
+		f, err := scanQuarantinedFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *f)
+	}
+	return files, rows.Err()
+}
+
+// GetQuarantinedFile returns a quarantined file.
+//
+// Parameters:
+//   - id: The ID of the file.
+//
+// Returns:
+//   - *models.QuarantinedFile: The file.
+//   - error: models.ErrNotFound if there is no such file, or the query error.
+func (store *DBQuarantineStore) GetQuarantinedFile(ctx context.Context, id int) (*models.QuarantinedFile, error) {
+	f, err := scanQuarantinedFile(store.DB.QueryRowContext(ctx, `SELECT `+quarantineColumns+` FROM quarantined_files WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("quarantined file %d not found", id)
+	}
+	return f, err
+}
+
+// DeleteQuarantinedFile removes the record of a quarantined file.
+//
+// Parameters:
+//   - id: The ID of the file.
+//
+// Returns:
+//   - error: models.ErrNotFound if there is no such file, or the query error.
+func (store *DBQuarantineStore) DeleteQuarantinedFile(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, `DELETE FROM quarantined_files WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("quarantined file %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package recognition_handlers provides HTTP handlers and the database store for revenue
+// recognition schedules of service invoices.
+package recognition_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/recognition"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// RecognitionHandler provides HTTP handlers for revenue recognition.
+type RecognitionHandler struct {
+	Service *recognition.Service
+}
+
+// RunRequest is the request body for recognizing the revenue of a month.
+type RunRequest struct {
+	Period string `json:"period"` // e.g. "2024-05"
+}
+
+// RegisterRoutes maps revenue recognition routes to their respective handler functions. The
+// router is expected to be protected with middleware.JWTAuth and limited to accountants and
+// admins. Schedules are set on the invoice routes with SetSchedule, GetSchedule and
+// DeleteSchedule.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The revenue recognition service.
+func RegisterRoutes(router *mux.Router, service *recognition.Service) {
+	handler := &RecognitionHandler{Service: service}
+
+	router.HandleFunc("/runs", handler.Run).Methods("POST")
+	router.HandleFunc("/deferred", handler.Deferred).Methods("GET")
+}
+
+// SetSchedule sets how a draft invoice's revenue is recognized: straight-line over a number
+// of months once it is posted.
+//
+// HTTP Method: PUT
+// URL Path: /invoices/{id}/revenue_schedule
+//
+// Request Body:
+//   - JSON object with "months" and optionally "start_month" (the posting month by default).
+//
+// Response:
+//   - Status Code: 200 (OK) with the RevenueSchedule in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the invoice does not exist.
+//   - Status Code: 409 (Conflict) if the invoice is not a draft.
+//   - Status Code: 422 (Unprocessable Entity) if the number of months is out of range.
+//   - Status Code: 500 (Internal Server Error) if the schedule cannot be saved.
+func (h *RecognitionHandler) SetSchedule(w http.ResponseWriter, r *http.Request) {
+	var schedule models.RevenueSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	schedule.InvoiceID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.SetSchedule(r.Context(), &schedule, actor); err != nil {
+		httperr.Write(w, err, "Failed to set revenue schedule")
+		return
+	}
+	respond.JSON(w, http.StatusOK, schedule)
+}
+
+// GetSchedule returns the revenue schedule of an invoice with its monthly lines and what
+// has been recognized.
+//
+// HTTP Method: GET
+// URL Path: /invoices/{id}/revenue_schedule
+//
+// Response:
+//   - Status Code: 200 (OK) with the RevenueSchedule in JSON.
+//   - Status Code: 404 (Not Found) if the invoice has no schedule.
+//   - Status Code: 500 (Internal Server Error) if the schedule cannot be read.
+func (h *RecognitionHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	invoiceID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	schedule, err := h.Service.Schedule(r.Context(), invoiceID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load revenue schedule")
+		return
+	}
+	respond.JSON(w, http.StatusOK, schedule)
+}
+
+// DeleteSchedule removes the revenue schedule of a draft invoice.
+//
+// HTTP Method: DELETE
+// URL Path: /invoices/{id}/revenue_schedule
+//
+// Response:
+//   - Status Code: 204 (No Content) if the schedule was removed.
+//   - Status Code: 404 (Not Found) if the invoice has no schedule.
+//   - Status Code: 409 (Conflict) if the invoice is not a draft.
+//   - Status Code: 500 (Internal Server Error) if the schedule cannot be removed.
+func (h *RecognitionHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	invoiceID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.DeleteSchedule(r.Context(), invoiceID); err != nil {
+		httperr.Write(w, err, "Failed to delete revenue schedule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Run recognizes the revenue of a month that has ended, with earlier months not yet
+// recognized. It does what the monthly run does, e.g. to catch up after an outage.
+//
+// HTTP Method: POST
+// URL Path: /revenue_recognition/runs
+//
+// Request Body:
+//   - JSON object with "period", the month as YYYY-MM.
+//
+// Response:
+//   - Status Code: 200 (OK) with the RecognitionRun in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid or has not ended.
+//   - Status Code: 500 (Internal Server Error) if the revenue cannot be recognized.
+func (h *RecognitionHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	run, err := h.Service.Run(r.Context(), req.Period)
+	if err != nil {
+		httperr.Write(w, err, "Failed to recognize revenue")
+		return
+	}
+	respond.JSON(w, http.StatusOK, run)
+}
+
+// Deferred reports the revenue still deferred at the end of a day, by invoice, and
+// reconciles the total with the deferred revenue account in the ledger.
+//
+// HTTP Method: GET
+// URL Path: /revenue_recognition/deferred?as_of=2024-05-31 (today by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with the DeferredRevenueReport in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if as_of is not a date.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be built.
+func (h *RecognitionHandler) Deferred(w http.ResponseWriter, r *http.Request) {
+	var asOf time.Time
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		var err error
+		if asOf, err = time.Parse("2006-01-02", value); err != nil {
+			httperr.Write(w, models.Invalid("invalid as_of, expected YYYY-MM-DD"), "Failed to load deferred revenue")
+			return
+		}
+	}
+	report, err := h.Service.Deferred(r.Context(), asOf)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load deferred revenue")
+		return
+	}
+	respond.JSON(w, http.StatusOK, report)
+}
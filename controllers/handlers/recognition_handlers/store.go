@@ -0,0 +1,315 @@
+package recognition_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/controllers/recognition"
+	"erp/models"
+)
+
+// DBRevenueScheduleStore provides SQL-backed methods for revenue recognition schedules.
+type DBRevenueScheduleStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// lockDraft locks an invoice and checks that it is still a draft.
+func lockDraft(ctx context.Context, tx *sql.Tx, invoiceID int) error {
+	var status sql.NullString
+	err := tx.QueryRowContext(ctx, `SELECT status FROM invoices WHERE id = $1 FOR UPDATE`, invoiceID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return models.NotFound("invoice %d not found", invoiceID)
+	}
+	if err != nil {
+		return err
+	}
+	if status.String != models.InvoiceStatusDraft {
+		return models.ErrDocumentLocked
+	}
+	return nil
+}
+
+// SetRevenueSchedule sets or replaces the revenue schedule of a draft invoice.
+//
+// Parameters:
+//   - schedule: The validated schedule.
+//
+// Returns:
+//   - error: models.ErrNotFound if the invoice does not exist, models.ErrDocumentLocked if
+//     it is not a draft, or an error if the schedule cannot be saved.
+func (store *DBRevenueScheduleStore) SetRevenueSchedule(ctx context.Context, schedule *models.RevenueSchedule) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockDraft(ctx, tx, schedule.InvoiceID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO revenue_schedules (invoice_id, months, start_month, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (invoice_id) DO UPDATE SET months = EXCLUDED.months, start_month = EXCLUDED.start_month,
+		     created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at`,
+		schedule.InvoiceID, schedule.Months, schedule.StartMonth, schedule.CreatedBy, schedule.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRevenueSchedule removes the revenue schedule of a draft invoice.
+//
+// Parameters:
+//   - invoiceID: The invoice.
+//
+// Returns:
+//   - error: models.ErrNotFound if the invoice or its schedule does not exist,
+//     models.ErrDocumentLocked if it is not a draft, or an error if the delete fails.
+func (store *DBRevenueScheduleStore) DeleteRevenueSchedule(ctx context.Context, invoiceID int) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockDraft(ctx, tx, invoiceID); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM revenue_schedules WHERE invoice_id = $1`, invoiceID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("invoice %d has no revenue schedule", invoiceID)
+	}
+	return tx.Commit()
+}
+
+// GetRevenueSchedule retrieves the revenue schedule of an invoice with its monthly lines.
+//
+// Returns:
+//   - *models.RevenueSchedule: The schedule; it has no lines until the invoice is posted.
+//   - error: models.ErrNotFound if the invoice has no schedule, or an error if a query fails.
+func (store *DBRevenueScheduleStore) GetRevenueSchedule(ctx context.Context, invoiceID int) (*models.RevenueSchedule, error) {
+	schedule := models.RevenueSchedule{Lines: []models.RevenueLine{}}
+	var start, postedOn sql.NullTime
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT s.invoice_id, COALESCE(i.customer_id, 0), s.months, s.start_month, s.amount, s.posted_on, s.created_by,
+		     s.created_at
+		 FROM revenue_schedules s JOIN invoices i ON i.id = s.invoice_id
+		 WHERE s.invoice_id = $1`, invoiceID,
+	).Scan(&schedule.InvoiceID, &schedule.CustomerID, &schedule.Months, &start, &schedule.Amount, &postedOn,
+		&schedule.CreatedBy, &schedule.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("invoice %d has no revenue schedule", invoiceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if start.Valid {
+		schedule.StartMonth = &start.Time
+	}
+	if postedOn.Valid {
+		schedule.PostedOn = &postedOn.Time
+	}
+
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, month, amount, recognized_on FROM revenue_schedule_lines WHERE invoice_id = $1 ORDER BY month`,
+		invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line models.RevenueLine
+		var recognizedOn sql.NullTime
+		if err := rows.Scan(&line.ID, &line.Month, &line.Amount, &recognizedOn); err != nil {
+			return nil, err
+		}
+		if recognizedOn.Valid {
+			line.RecognizedOn = &recognizedOn.Time
+			schedule.Recognized = roundCents(schedule.Recognized + line.Amount)
+		}
+		schedule.Lines = append(schedule.Lines, line)
+	}
+	return &schedule, rows.Err()
+}
+
+// RecognizeRevenue recognizes, in one transaction, every line not yet recognized for the
+// months up to and including through: deferred revenue is debited and revenue credited on
+// the last day of the line's month.
+//
+// Parameters:
+//   - through: The first day of the last month recognized.
+//
+// Returns:
+//   - *models.RecognitionRun: The number of invoices and lines recognized and the amount.
+//   - error: An error if the lines cannot be read, posted or updated.
+func (store *DBRevenueScheduleStore) RecognizeRevenue(ctx context.Context, through time.Time) (*models.RecognitionRun, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, invoice_id, month, amount FROM revenue_schedule_lines
+		 WHERE recognized_on IS NULL AND month <= $1 ORDER BY month, invoice_id FOR UPDATE`, through)
+	if err != nil {
+		return nil, err
+	}
+	type line struct {
+		id, invoiceID int
+		month         time.Time
+		amount        float64
+	}
+	var lines []line
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&l.id, &l.invoiceID, &l.month, &l.amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	run := &models.RecognitionRun{}
+	invoices := map[int]bool{}
+	for _, l := range lines {
+		date := recognition.MonthEnd(l.month)
+		description := fmt.Sprintf("Revenue of invoice #%d for %s", l.invoiceID, l.month.Format("2006-01"))
+		for _, transaction := range []models.FinancialTransaction{
+			{AccountType: recognition.DeferredAccount, Amount: l.amount},
+			{AccountType: recognition.RevenueAccount, Amount: -l.amount},
+		} {
+			transaction.TransactionDate, transaction.Description = date, description
+			transaction.InvoiceID = &l.invoiceID
+			if err := general_ledger_handlers.InsertTransaction(ctx, tx, &transaction); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE revenue_schedule_lines SET recognized_on = $1 WHERE id = $2`, date, l.id); err != nil {
+			return nil, err
+		}
+		invoices[l.invoiceID] = true
+		run.Lines++
+		run.Amount = roundCents(run.Amount + l.amount)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	run.Invoices = len(invoices)
+	return run, nil
+}
+
+// GetDeferredRevenue retrieves the revenue still deferred at the end of a day for each
+// invoice posted by then, and the credit balance of the deferred revenue account that day.
+//
+// Parameters:
+//   - asOf: The day.
+//
+// Returns:
+//   - []models.DeferredRevenue: The invoices with revenue deferred, by invoice.
+//   - float64: The ledger balance of the deferred revenue account.
+//   - error: An error if a query fails.
+func (store *DBRevenueScheduleStore) GetDeferredRevenue(ctx context.Context, asOf time.Time) ([]models.DeferredRevenue, float64, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT s.invoice_id, COALESCE(i.customer_id, 0), s.amount,
+		     COALESCE(SUM(l.amount) FILTER (WHERE l.recognized_on <= $1), 0) AS recognized
+		 FROM revenue_schedules s
+		 JOIN invoices i ON i.id = s.invoice_id
+		 LEFT JOIN revenue_schedule_lines l ON l.invoice_id = s.invoice_id
+		 WHERE s.posted_on <= $1
+		 GROUP BY s.invoice_id, i.customer_id, s.amount
+		 HAVING s.amount > COALESCE(SUM(l.amount) FILTER (WHERE l.recognized_on <= $1), 0)
+		 ORDER BY s.invoice_id`, asOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	invoices := []models.DeferredRevenue{}
+	for rows.Next() {
+		var invoice models.DeferredRevenue
+		if err := rows.Scan(&invoice.InvoiceID, &invoice.CustomerID, &invoice.Amount, &invoice.Recognized); err != nil {
+			return nil, 0, err
+		}
+		invoice.Deferred = roundCents(invoice.Amount - invoice.Recognized)
+		invoices = append(invoices, invoice)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var ledger float64
+	err = store.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(-SUM(amount), 0) FROM financial_transactions WHERE account_type = $1 AND transaction_date <= $2`,
+		recognition.DeferredAccount, asOf,
+	).Scan(&ledger)
+	if err != nil {
+		return nil, 0, err
+	}
+	return invoices, ledger, nil
+}
+
+// ScheduleRevenue defers the revenue of an invoice with a revenue schedule, within the
+// caller's transaction: the monthly lines are generated from the invoice amount and the
+// account to credit is deferred revenue. Invoices without a schedule are credited to
+// revenue. It is called when the invoice is posted.
+//
+// Parameters:
+//   - tx: The transaction posting the invoice.
+//   - invoice: The invoice being posted.
+//   - date: The posting date; the first month recognized unless the schedule sets one.
+//
+// Returns:
+//   - string: The account the invoice's revenue is credited to.
+//   - error: An error if the schedule cannot be read or its lines saved.
+func ScheduleRevenue(ctx context.Context, tx *sql.Tx, invoice *models.Invoice, date time.Time) (string, error) {
+	var months int
+	var start sql.NullTime
+	err := tx.QueryRowContext(ctx, `SELECT months, start_month FROM revenue_schedules WHERE invoice_id = $1 FOR UPDATE`, invoice.ID).
+		Scan(&months, &start)
+	if err == sql.ErrNoRows {
+		return recognition.RevenueAccount, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	first := date
+	if start.Valid {
+		first = start.Time
+	}
+	for _, line := range recognition.Split(invoice.Amount, months, first) {
+		_, err := tx.ExecContext(ctx, `INSERT INTO revenue_schedule_lines (invoice_id, month, amount) VALUES ($1, $2, $3)`,
+			invoice.ID, line.Month, line.Amount)
+		if err != nil {
+			return "", err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE revenue_schedules SET amount = $1, posted_on = $2 WHERE invoice_id = $3`,
+		invoice.Amount, date, invoice.ID)
+	if err != nil {
+		return "", err
+	}
+	return recognition.DeferredAccount, nil
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,59 @@
+package recognition_handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScheduleRevenue verifies that posting an invoice with a schedule generates its monthly
+// lines and defers its revenue.
+func TestScheduleRevenue(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT months, start_month FROM revenue_schedules WHERE invoice_id = \\$1 FOR UPDATE").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"months", "start_month"}).AddRow(3, nil))
+	for i, amount := range []float64{100, 100, 100.01} {
+		mock.ExpectExec("INSERT INTO revenue_schedule_lines").
+			WithArgs(7, time.Date(2024, time.Month(5+i), 1, 0, 0, 0, 0, time.UTC), amount).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectExec("UPDATE revenue_schedules SET amount").WithArgs(300.01, day, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	account, err := ScheduleRevenue(ctx, tx, &models.Invoice{ID: 7, Amount: 300.01}, day)
+	require.NoError(t, err)
+	assert.Equal(t, "deferred_revenue", account)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestScheduleRevenueWithoutSchedule verifies that invoices without a schedule are credited
+// to revenue.
+func TestScheduleRevenueWithoutSchedule(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM revenue_schedules").WithArgs(7).WillReturnRows(sqlmock.NewRows(nil))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	account, err := ScheduleRevenue(ctx, tx, &models.Invoice{ID: 7, Amount: 300}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "revenue", account)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
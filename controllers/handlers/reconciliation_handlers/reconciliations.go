@@ -0,0 +1,278 @@
+// Package reconciliation_handlers provides HTTP handlers and the database store for the
+// month-end reconciliation worksheets of control accounts.
+package reconciliation_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/reconciliation"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// ReconciliationHandler provides HTTP handlers for reconciliation worksheets.
+type ReconciliationHandler struct {
+	Service *reconciliation.Service
+}
+
+// StatementRequest is the request body for correcting a bank statement balance.
+type StatementRequest struct {
+	StatementBalance float64 `json:"statement_balance"`
+}
+
+// SignOffRequest is the request body for signing off a worksheet.
+type SignOffRequest struct {
+	Note string `json:"note"`
+}
+
+// RegisterRoutes maps reconciliation routes to their handler functions. The router is
+// expected to be protected with middleware.JWTAuth and limited to finance.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - handler: The reconciliation handler.
+func RegisterRoutes(router *mux.Router, handler *ReconciliationHandler) {
+	router.HandleFunc("", handler.PrepareReconciliation).Methods("POST")
+	router.HandleFunc("", handler.ListReconciliations).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetReconciliation).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/statement", handler.SetStatementBalance).Methods("PUT")
+	router.HandleFunc("/{id:[0-9]+}/ledger", handler.GetLedgerLines).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/subledger", handler.GetSubledgerLines).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/items", handler.AddItem).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/items/{item:[0-9]+}", handler.DeleteItem).Methods("DELETE")
+	router.HandleFunc("/{id:[0-9]+}/sign_off", handler.SignOff).Methods("POST")
+}
+
+// PrepareReconciliation opens the worksheet of a control account for a month.
+//
+// HTTP Method: POST
+// URL Path: /reconciliations
+//
+// Request Body:
+//   - JSON with account (accounts_receivable, accounts_payable or cash), period (YYYY-MM)
+//     and, for cash, statement_balance: the closing balance of the bank statement.
+//
+// Response:
+//   - Status Code: 201 (Created) with the worksheet in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the account already has a worksheet for the month.
+//   - Status Code: 422 (Unprocessable Entity) if the account, period or statement balance is invalid.
+//   - Status Code: 500 (Internal Server Error) if the worksheet cannot be prepared.
+func (h *ReconciliationHandler) PrepareReconciliation(w http.ResponseWriter, r *http.Request) {
+	var worksheet models.Reconciliation
+	if err := json.NewDecoder(r.Body).Decode(&worksheet); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Prepare(r.Context(), &worksheet, actor); err != nil {
+		httperr.Write(w, err, "Failed to prepare reconciliation")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, worksheet)
+}
+
+// ListReconciliations lists worksheets, latest month first.
+//
+// HTTP Method: GET
+// URL Path: /reconciliations?account=cash&period=2025-06&status=open
+// (all optional; status is open or signed_off)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of worksheets in JSON; unexplained shows what is
+//     left to reconcile.
+//   - Status Code: 422 (Unprocessable Entity) if the period or status is invalid.
+//   - Status Code: 500 (Internal Server Error) if the worksheets cannot be loaded.
+func (h *ReconciliationHandler) ListReconciliations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	list, err := h.Service.List(r.Context(), query.Get("account"), query.Get("period"), query.Get("status"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load reconciliations")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// GetReconciliation returns a worksheet: the general ledger and subledger balances, the
+// difference, the reconciling items and what is left unexplained.
+//
+// HTTP Method: GET
+// URL Path: /reconciliations/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the worksheet in JSON.
+//   - Status Code: 404 (Not Found) if the worksheet does not exist.
+//   - Status Code: 500 (Internal Server Error) if the worksheet cannot be loaded.
+func (h *ReconciliationHandler) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	worksheet, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load reconciliation")
+		return
+	}
+	respond.JSON(w, http.StatusOK, worksheet)
+}
+
+// SetStatementBalance corrects the statement balance of an open bank worksheet.
+//
+// HTTP Method: PUT
+// URL Path: /reconciliations/{id}/statement
+//
+// Request Body:
+//   - JSON with statement_balance.
+//
+// Response:
+//   - Status Code: 200 (OK) with the worksheet in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the worksheet does not exist.
+//   - Status Code: 409 (Conflict) if the worksheet is signed off.
+//   - Status Code: 422 (Unprocessable Entity) if the worksheet is not for the bank.
+//   - Status Code: 500 (Internal Server Error) if the balance cannot be saved.
+func (h *ReconciliationHandler) SetStatementBalance(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req StatementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	worksheet, err := h.Service.SetStatementBalance(r.Context(), id, req.StatementBalance)
+	if err != nil {
+		httperr.Write(w, err, "Failed to set statement balance")
+		return
+	}
+	respond.JSON(w, http.StatusOK, worksheet)
+}
+
+// GetLedgerLines drills down into the postings to a worksheet's account during its month.
+//
+// HTTP Method: GET
+// URL Path: /reconciliations/{id}/ledger
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of FinancialTransactions in JSON, oldest first.
+//   - Status Code: 404 (Not Found) if the worksheet does not exist.
+//   - Status Code: 500 (Internal Server Error) if the postings cannot be loaded.
+func (h *ReconciliationHandler) GetLedgerLines(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	lines, err := h.Service.LedgerLines(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load ledger postings")
+		return
+	}
+	respond.JSON(w, http.StatusOK, lines)
+}
+
+// GetSubledgerLines drills down into the open documents making up a worksheet's subledger
+// balance: customer invoices for receivables and supplier bills for payables. Bank
+// worksheets have none.
+//
+// HTTP Method: GET
+// URL Path: /reconciliations/{id}/subledger
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of SubledgerLines in JSON.
+//   - Status Code: 404 (Not Found) if the worksheet does not exist.
+//   - Status Code: 500 (Internal Server Error) if the subledger cannot be loaded.
+func (h *ReconciliationHandler) GetSubledgerLines(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	lines, err := h.Service.SubledgerLines(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load subledger")
+		return
+	}
+	respond.JSON(w, http.StatusOK, lines)
+}
+
+// AddItem records a reconciling item explaining part of a worksheet's difference.
+//
+// HTTP Method: POST
+// URL Path: /reconciliations/{id}/items
+//
+// Request Body:
+//   - JSON with description and amount; the amount is signed like the difference
+//     (subledger less general ledger).
+//
+// Response:
+//   - Status Code: 201 (Created) with the worksheet in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the worksheet does not exist.
+//   - Status Code: 409 (Conflict) if the worksheet is signed off.
+//   - Status Code: 422 (Unprocessable Entity) if the description or amount is missing.
+//   - Status Code: 500 (Internal Server Error) if the item cannot be recorded.
+func (h *ReconciliationHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var item models.ReconcilingItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	worksheet, err := h.Service.AddItem(r.Context(), id, &item, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to add reconciling item")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, worksheet)
+}
+
+// DeleteItem removes a reconciling item from an open worksheet.
+//
+// HTTP Method: DELETE
+// URL Path: /reconciliations/{id}/items/{item}
+//
+// Response:
+//   - Status Code: 200 (OK) with the worksheet in JSON.
+//   - Status Code: 404 (Not Found) if the worksheet or item does not exist.
+//   - Status Code: 409 (Conflict) if the worksheet is signed off.
+//   - Status Code: 500 (Internal Server Error) if the item cannot be removed.
+func (h *ReconciliationHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	itemID, _ := strconv.Atoi(vars["item"])
+	worksheet, err := h.Service.DeleteItem(r.Context(), id, itemID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to delete reconciling item")
+		return
+	}
+	respond.JSON(w, http.StatusOK, worksheet)
+}
+
+// SignOff signs off a worksheet whose difference is fully explained, freezing its figures.
+// The sign-off is recorded in the audit log.
+//
+// HTTP Method: POST
+// URL Path: /reconciliations/{id}/sign_off
+//
+// Request Body:
+//   - JSON with an optional note.
+//
+// Response:
+//   - Status Code: 200 (OK) with the signed-off worksheet in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 403 (Forbidden) if the user prepared the worksheet.
+//   - Status Code: 404 (Not Found) if the worksheet does not exist.
+//   - Status Code: 409 (Conflict) if it is signed off already or a difference is unexplained.
+//   - Status Code: 500 (Internal Server Error) if the worksheet cannot be signed off.
+func (h *ReconciliationHandler) SignOff(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req SignOffRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	worksheet, err := h.Service.SignOff(r.Context(), id, actor, req.Note)
+	if err != nil {
+		httperr.Write(w, err, "Failed to sign off reconciliation")
+		return
+	}
+	respond.JSON(w, http.StatusOK, worksheet)
+}
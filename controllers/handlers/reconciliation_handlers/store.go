@@ -0,0 +1,324 @@
+package reconciliation_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/controllers/audit"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBReconciliationStore implements models.ReconciliationStore using a SQL database.
+type DBReconciliationStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// reconciliationColumns are the columns read by scanReconciliation.
+const reconciliationColumns = `id, account, to_char(period, 'YYYY-MM'), statement_balance, COALESCE(gl_balance, 0),
+	COALESCE(subledger_balance, 0), status, prepared_by, prepared_at, signed_off_by, signed_off_at, sign_off_note`
+
+// scanReconciliation reads a row of reconciliationColumns. The difference of a signed-off
+// worksheet is worked out from its stored balances.
+func scanReconciliation(row interface{ Scan(...interface{}) error }) (*models.Reconciliation, error) {
+	var r models.Reconciliation
+	var statement sql.NullFloat64
+	var signedOffBy, note sql.NullString
+	var signedOffAt sql.NullTime
+	err := row.Scan(&r.ID, &r.Account, &r.Period, &statement, &r.GLBalance, &r.SubledgerBalance, &r.Status,
+		&r.PreparedBy, &r.PreparedAt, &signedOffBy, &signedOffAt, &note)
+	if err != nil {
+		return nil, err
+	}
+	if statement.Valid {
+		r.StatementBalance = &statement.Float64
+	}
+	if signedOffAt.Valid {
+		r.SignedOffAt = &signedOffAt.Time
+	}
+	r.SignedOffBy, r.SignOffNote = signedOffBy.String, note.String
+	r.Difference = math.Round((r.SubledgerBalance-r.GLBalance)*100) / 100
+	r.Items = []models.ReconcilingItem{}
+	return &r, nil
+}
+
+// CreateReconciliation records an open worksheet.
+//
+// Returns:
+//   - error: A conflict if the account has a worksheet for the period, or the query error.
+func (s *DBReconciliationStore) CreateReconciliation(ctx context.Context, r *models.Reconciliation) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO reconciliations (account, period, statement_balance, status, prepared_by, prepared_at)
+		 VALUES ($1, to_date($2, 'YYYY-MM'), $3, $4, $5, $6) RETURNING id`,
+		r.Account, r.Period, r.StatementBalance, r.Status, r.PreparedBy, r.PreparedAt,
+	).Scan(&r.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("%s already has a reconciliation for %s", r.Account, r.Period)
+	}
+	return err
+}
+
+// GetReconciliation returns a worksheet with its reconciling items.
+func (s *DBReconciliationStore) GetReconciliation(ctx context.Context, id int) (*models.Reconciliation, error) {
+	r, err := scanReconciliation(s.DB.QueryRowContext(ctx, `SELECT `+reconciliationColumns+` FROM reconciliations WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("reconciliation %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.items(ctx, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	r.Items = append(r.Items, items[id]...)
+	return r, nil
+}
+
+// ListReconciliations returns the worksheets matching the filters that are not empty, latest
+// period first, with their reconciling items.
+func (s *DBReconciliationStore) ListReconciliations(ctx context.Context, account, period, status string) ([]models.Reconciliation, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+reconciliationColumns+` FROM reconciliations
+		WHERE ($1 = '' OR account = $1) AND ($2 = '' OR period = to_date(NULLIF($2, ''), 'YYYY-MM'))
+		  AND ($3 = '' OR status = $3)
+		ORDER BY period DESC, account`, account, period, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Reconciliation{}
+	var ids []int
+	for rows.Next() {
+		r, err := scanReconciliation(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *r)
+		ids = append(ids, r.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	items, err := s.items(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Items = append(list[i].Items, items[list[i].ID]...)
+	}
+	return list, nil
+}
+
+// items returns the reconciling items of worksheets by worksheet, oldest first.
+func (s *DBReconciliationStore) items(ctx context.Context, ids []int) (map[int][]models.ReconcilingItem, error) {
+	items := map[int][]models.ReconcilingItem{}
+	if len(ids) == 0 {
+		return items, nil
+	}
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT reconciliation_id, id, description, amount, created_by, created_at FROM reconciling_items
+		 WHERE reconciliation_id = ANY($1) ORDER BY id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var item models.ReconcilingItem
+		if err := rows.Scan(&id, &item.ID, &item.Description, &item.Amount, &item.CreatedBy, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items[id] = append(items[id], item)
+	}
+	return items, rows.Err()
+}
+
+// SetStatementBalance replaces the statement balance of an open worksheet.
+func (s *DBReconciliationStore) SetStatementBalance(ctx context.Context, id int, balance float64) error {
+	return s.change(ctx, id, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE reconciliations SET statement_balance = $1 WHERE id = $2`, balance, id)
+		return err
+	})
+}
+
+// AddReconcilingItem records a reconciling item on an open worksheet.
+func (s *DBReconciliationStore) AddReconcilingItem(ctx context.Context, reconciliationID int, item *models.ReconcilingItem) error {
+	return s.change(ctx, reconciliationID, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx,
+			`INSERT INTO reconciling_items (reconciliation_id, description, amount, created_by, created_at)
+			 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			reconciliationID, item.Description, item.Amount, item.CreatedBy, item.CreatedAt,
+		).Scan(&item.ID)
+	})
+}
+
+// DeleteReconcilingItem removes a reconciling item from an open worksheet.
+func (s *DBReconciliationStore) DeleteReconcilingItem(ctx context.Context, reconciliationID, itemID int) error {
+	return s.change(ctx, reconciliationID, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM reconciling_items WHERE id = $1 AND reconciliation_id = $2`,
+			itemID, reconciliationID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return models.NotFound("reconciling item %d not found", itemID)
+		}
+		return nil
+	})
+}
+
+// SignOffReconciliation stores a worksheet's balances, marks it signed off and records the
+// sign-off in the audit log, in one transaction.
+//
+// Returns:
+//   - error: A not found error, a conflict if the worksheet is not open or its reconciling
+//     items changed since r was worked out, or the query error.
+func (s *DBReconciliationStore) SignOffReconciliation(ctx context.Context, r *models.Reconciliation, actor, note string) error {
+	return s.change(ctx, r.ID, func(tx *sql.Tx) error {
+		var explained float64
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount), 0) FROM reconciling_items WHERE reconciliation_id = $1`, r.ID).
+			Scan(&explained)
+		if err != nil {
+			return err
+		}
+		if math.Round(explained*100) != math.Round(r.Explained*100) {
+			return models.Conflict("the reconciling items of reconciliation %d have changed", r.ID)
+		}
+		now := time.Now()
+		_, err = tx.ExecContext(ctx,
+			`UPDATE reconciliations SET gl_balance = $1, subledger_balance = $2, status = $3, signed_off_by = $4,
+			     signed_off_at = $5, sign_off_note = $6
+			 WHERE id = $7`,
+			r.GLBalance, r.SubledgerBalance, models.ReconciliationSignedOff, actor, now, note, r.ID)
+		if err != nil {
+			return err
+		}
+		details, _ := json.Marshal(map[string]interface{}{
+			"account": r.Account, "period": r.Period, "gl_balance": r.GLBalance,
+			"subledger_balance": r.SubledgerBalance, "difference": r.Difference, "explained": r.Explained,
+		})
+		return audit.Record(ctx, tx, &models.AuditEntry{
+			Actor:      actor,
+			Action:     audit.ActionReconciliationSignOff,
+			EntityType: "reconciliation",
+			EntityID:   r.ID,
+			Reason:     note,
+			Details:    details,
+			CreatedAt:  now,
+		})
+	})
+}
+
+// change locks an open worksheet and applies a change to it in a transaction.
+func (s *DBReconciliationStore) change(ctx context.Context, id int, apply func(tx *sql.Tx) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM reconciliations WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotFound("reconciliation %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.ReconciliationOpen {
+		return models.Conflict("reconciliation %d is %s", id, status)
+	}
+	if err := apply(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LedgerBalance returns the debits less credits of an account up to the end of the month
+// starting on period, from the per-period account balances.
+func (s *DBReconciliationStore) LedgerBalance(ctx context.Context, account string, period time.Time) (float64, error) {
+	var balance float64
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(debit_total - credit_total), 0) FROM account_period_balances
+		 WHERE account_type = $1 AND period <= $2`, account, period,
+	).Scan(&balance)
+	return balance, err
+}
+
+// LedgerLines returns the postings to an account from first to last, oldest first.
+func (s *DBReconciliationStore) LedgerLines(ctx context.Context, account string, first, last time.Time) ([]models.FinancialTransaction, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, account_type, amount, transaction_date, COALESCE(description, ''), invoice_id, journal_entry_id
+		 FROM financial_transactions WHERE account_type = $1 AND transaction_date BETWEEN $2 AND $3
+		 ORDER BY transaction_date, id`, account, first, last)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.FinancialTransaction{}
+	for rows.Next() {
+		var t models.FinancialTransaction
+		var invoiceID, journalEntryID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.AccountType, &t.Amount, &t.TransactionDate, &t.Description, &invoiceID,
+			&journalEntryID); err != nil {
+			return nil, err
+		}
+		if invoiceID.Valid {
+			id := int(invoiceID.Int64)
+			t.InvoiceID = &id
+		}
+		if journalEntryID.Valid {
+			id := int(journalEntryID.Int64)
+			t.JournalEntryID = &id
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// ReceivableLines returns the customer invoices with a balance on the receivable account at
+// the end of the day, by invoice.
+func (s *DBReconciliationStore) ReceivableLines(ctx context.Context, account string, end time.Time) ([]models.SubledgerLine, error) {
+	return s.lines(ctx,
+		`SELECT i.id, COALESCE(c.name, ''), '', MIN(t.transaction_date), SUM(t.amount)
+		 FROM financial_transactions t
+		 JOIN invoices i ON i.id = t.invoice_id
+		 LEFT JOIN customers c ON c.id = i.customer_id
+		 WHERE t.account_type = $1 AND t.transaction_date <= $2
+		 GROUP BY i.id, c.name HAVING SUM(t.amount) <> 0
+		 ORDER BY i.id`, "invoice", account, end)
+}
+
+// PayableLines returns the supplier bills dated up to end with the part not settled by
+// advances.
+func (s *DBReconciliationStore) PayableLines(ctx context.Context, end time.Time) ([]models.SubledgerLine, error) {
+	return s.lines(ctx,
+		`SELECT b.id, su.name, b.number, b.bill_date, b.amount - b.advance_applied
+		 FROM supplier_bills b JOIN suppliers su ON su.id = b.supplier_id
+		 WHERE b.bill_date <= $1 AND b.amount > b.advance_applied
+		 ORDER BY b.bill_date, b.id`, "supplier_bill", end)
+}
+
+// lines reads subledger lines of a document type.
+func (s *DBReconciliationStore) lines(ctx context.Context, query, document string, args ...interface{}) ([]models.SubledgerLine, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the %s subledger: %w", document, err)
+	}
+	defer rows.Close()
+	list := []models.SubledgerLine{}
+	for rows.Next() {
+		line := models.SubledgerLine{Document: document}
+		if err := rows.Scan(&line.ID, &line.Party, &line.Reference, &line.Date, &line.Amount); err != nil {
+			return nil, err
+		}
+		list = append(list, line)
+	}
+	return list, rows.Err()
+}
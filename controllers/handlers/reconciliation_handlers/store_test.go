@@ -0,0 +1,57 @@
+package reconciliation_handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignOffReconciliation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBReconciliationStore{DB: db}
+	worksheet := &models.Reconciliation{ID: 5, Account: "cash", Period: "2025-06", GLBalance: 1000,
+		SubledgerBalance: 980, Difference: -20, Explained: -20}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status FROM reconciliations WHERE id = $1 FOR UPDATE")).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.ReconciliationOpen))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(amount), 0) FROM reconciling_items")).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(-20.0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE reconciliations SET gl_balance = $1")).
+		WithArgs(1000.0, 980.0, models.ReconciliationSignedOff, "bo@example.com", sqlmock.AnyArg(), "Bank charges", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("bo@example.com", "reconciliation_sign_off", "reconciliation", 5, "Bank charges", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, store.SignOffReconciliation(context.Background(), worksheet, "bo@example.com", "Bank charges"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSignOffAfterItemsChanged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBReconciliationStore{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.ReconciliationOpen))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM reconciling_items")).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(-35.0))
+	mock.ExpectRollback()
+
+	err = store.SignOffReconciliation(context.Background(), &models.Reconciliation{ID: 5, Explained: -20}, "bo@example.com", "")
+	assert.True(t, errors.Is(err, models.ErrConflict))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
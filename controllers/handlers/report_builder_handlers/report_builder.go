@@ -0,0 +1,270 @@
+// Package report_builder_handlers provides the API of the custom report builder: the
+// whitelist of entities and fields, saved report definitions and running reports as JSON
+// or CSV.
+package report_builder_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/reportbuilder"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Runner validates and runs report queries. It is satisfied by *reportbuilder.Service.
+type Runner interface {
+	Validate(q models.ReportQuery) error
+	Run(ctx context.Context, q models.ReportQuery) (*models.ReportResult, error)
+}
+
+// ReportBuilderHandler provides HTTP handlers for custom reports.
+type ReportBuilderHandler struct {
+	Store  models.ReportDefinitionStore
+	Runner Runner
+}
+
+// RegisterRoutes maps report builder routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the ReportDefinitionStore interface.
+//   - runner: Validates and runs report queries.
+func RegisterRoutes(router *mux.Router, store models.ReportDefinitionStore, runner Runner) {
+	handler := &ReportBuilderHandler{Store: store, Runner: runner}
+
+	router.HandleFunc("/entities", handler.ListEntities).Methods("GET")
+	router.HandleFunc("/run", handler.RunQuery).Methods("POST")
+	router.HandleFunc("/definitions", handler.ListDefinitions).Methods("GET")
+	router.HandleFunc("/definitions", handler.CreateDefinition).Methods("POST")
+	router.HandleFunc("/definitions/{id:[0-9]+}", handler.GetDefinition).Methods("GET")
+	router.HandleFunc("/definitions/{id:[0-9]+}", handler.UpdateDefinition).Methods("PUT")
+	router.HandleFunc("/definitions/{id:[0-9]+}", handler.DeleteDefinition).Methods("DELETE")
+	router.HandleFunc("/definitions/{id:[0-9]+}/run", handler.RunDefinition).Methods("GET")
+}
+
+// ListEntities returns the entities reports can be built on with their fields and types.
+//
+// HTTP Method: GET
+// URL Path: /reports/builder/entities
+//
+// Response:
+//   - Status Code: 200 (OK) with the entities in JSON, keyed by name.
+func (h *ReportBuilderHandler) ListEntities(w http.ResponseWriter, r *http.Request) {
+	respond.JSON(w, http.StatusOK, reportbuilder.Entities)
+}
+
+// RunQuery runs a report without saving it.
+//
+// HTTP Method: POST
+// URL Path: /reports/builder/run?format=json|csv
+//
+// Request Body:
+//   - JSON object with the ReportQuery: "entity", "columns", "filters", "group_by",
+//     "aggregates", "order_by" and "limit".
+//
+// Response:
+//   - Status Code: 200 (OK) with the ReportResult in JSON, or as CSV with format=csv.
+//   - Status Code: 400 (Bad Request) if the request body or format is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the query uses anything outside the whitelist.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be run.
+func (h *ReportBuilderHandler) RunQuery(w http.ResponseWriter, r *http.Request) {
+	var query models.ReportQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	h.run(w, r, query, "report")
+}
+
+// ListDefinitions returns the saved reports by name.
+//
+// HTTP Method: GET
+// URL Path: /reports/builder/definitions
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of ReportDefinitions in JSON.
+//   - Status Code: 500 (Internal Server Error) if the reports cannot be read.
+func (h *ReportBuilderHandler) ListDefinitions(w http.ResponseWriter, r *http.Request) {
+	definitions, err := h.Store.ListReportDefinitions(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load reports")
+		return
+	}
+	respond.JSON(w, http.StatusOK, definitions)
+}
+
+// CreateDefinition validates and saves a report.
+//
+// HTTP Method: POST
+// URL Path: /reports/builder/definitions
+//
+// Request Body:
+//   - JSON object with "name", an optional "description" and the "query".
+//
+// Response:
+//   - Status Code: 201 (Created) with the saved ReportDefinition in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if a report with the name exists.
+//   - Status Code: 422 (Unprocessable Entity) if the name is missing or the query uses
+//     anything outside the whitelist.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be saved.
+func (h *ReportBuilderHandler) CreateDefinition(w http.ResponseWriter, r *http.Request) {
+	definition, ok := h.decodeDefinition(w, r)
+	if !ok {
+		return
+	}
+	definition.CreatedBy, _ = middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Store.CreateReportDefinition(r.Context(), definition); err != nil {
+		httperr.Write(w, err, "Failed to save report")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, definition)
+}
+
+// GetDefinition returns a saved report.
+//
+// HTTP Method: GET
+// URL Path: /reports/builder/definitions/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the ReportDefinition in JSON.
+//   - Status Code: 404 (Not Found) if there is no such report.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be read.
+func (h *ReportBuilderHandler) GetDefinition(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	definition, err := h.Store.GetReportDefinition(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load report")
+		return
+	}
+	respond.JSON(w, http.StatusOK, definition)
+}
+
+// UpdateDefinition validates and replaces a saved report.
+//
+// HTTP Method: PUT
+// URL Path: /reports/builder/definitions/{id}
+//
+// Request Body:
+//   - JSON object with "name", an optional "description" and the "query".
+//
+// Response:
+//   - Status Code: 200 (OK) with the updated ReportDefinition in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if there is no such report.
+//   - Status Code: 409 (Conflict) if another report has the name.
+//   - Status Code: 422 (Unprocessable Entity) if the name is missing or the query uses
+//     anything outside the whitelist.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be saved.
+func (h *ReportBuilderHandler) UpdateDefinition(w http.ResponseWriter, r *http.Request) {
+	definition, ok := h.decodeDefinition(w, r)
+	if !ok {
+		return
+	}
+	definition.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Store.UpdateReportDefinition(r.Context(), definition); err != nil {
+		httperr.Write(w, err, "Failed to save report")
+		return
+	}
+	respond.JSON(w, http.StatusOK, definition)
+}
+
+// DeleteDefinition deletes a saved report.
+//
+// HTTP Method: DELETE
+// URL Path: /reports/builder/definitions/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the report was deleted.
+//   - Status Code: 404 (Not Found) if there is no such report.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be deleted.
+func (h *ReportBuilderHandler) DeleteDefinition(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Store.DeleteReportDefinition(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete report")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunDefinition runs a saved report.
+//
+// HTTP Method: GET
+// URL Path: /reports/builder/definitions/{id}/run?format=json|csv
+//
+// Response:
+//   - Status Code: 200 (OK) with the ReportResult in JSON, or as CSV with format=csv.
+//   - Status Code: 400 (Bad Request) if the format is invalid.
+//   - Status Code: 404 (Not Found) if there is no such report.
+//   - Status Code: 422 (Unprocessable Entity) if the saved query is no longer allowed.
+//   - Status Code: 500 (Internal Server Error) if the report cannot be run.
+func (h *ReportBuilderHandler) RunDefinition(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	definition, err := h.Store.GetReportDefinition(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load report")
+		return
+	}
+	h.run(w, r, definition.Query, definition.Name)
+}
+
+// decodeDefinition reads and validates a report definition from the request body. It
+// writes the error response and returns false if the definition is not valid.
+func (h *ReportBuilderHandler) decodeDefinition(w http.ResponseWriter, r *http.Request) (*models.ReportDefinition, bool) {
+	var definition models.ReportDefinition
+	if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+	definition.Name = strings.TrimSpace(definition.Name)
+	if definition.Name == "" || len(definition.Name) > 100 {
+		respond.Error(w, http.StatusUnprocessableEntity, "A name of at most 100 characters is required")
+		return nil, false
+	}
+	if err := h.Runner.Validate(definition.Query); err != nil {
+		httperr.Write(w, err, "Failed to validate report")
+		return nil, false
+	}
+	return &definition, true
+}
+
+// run runs a report query and writes the result in the requested format. CSV downloads are
+// named after the report.
+func (h *ReportBuilderHandler) run(w http.ResponseWriter, r *http.Request, query models.ReportQuery, name string) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		respond.Error(w, http.StatusBadRequest, "Invalid format, expected json or csv")
+		return
+	}
+	result, err := h.Runner.Run(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Failed to run report")
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+fileName(name)+`.csv"`)
+		reportbuilder.WriteCSV(w, result)
+		return
+	}
+	respond.JSON(w, http.StatusOK, result)
+}
+
+// fileName turns a report name into a safe file name.
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,115 @@
+package report_builder_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps report definitions in memory.
+type fakeStore struct {
+	definitions map[int]*models.ReportDefinition
+}
+
+func (f *fakeStore) CreateReportDefinition(ctx context.Context, d *models.ReportDefinition) error {
+	for _, existing := range f.definitions {
+		if existing.Name == d.Name {
+			return models.Conflict("a report named %q already exists", d.Name)
+		}
+	}
+	d.ID = len(f.definitions) + 1
+	f.definitions[d.ID] = d
+	return nil
+}
+
+func (f *fakeStore) ListReportDefinitions(ctx context.Context) ([]models.ReportDefinition, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) GetReportDefinition(ctx context.Context, id int) (*models.ReportDefinition, error) {
+	d, ok := f.definitions[id]
+	if !ok {
+		return nil, models.NotFound("report %d not found", id)
+	}
+	return d, nil
+}
+
+func (f *fakeStore) UpdateReportDefinition(ctx context.Context, d *models.ReportDefinition) error {
+	if _, ok := f.definitions[d.ID]; !ok {
+		return models.NotFound("report %d not found", d.ID)
+	}
+	f.definitions[d.ID] = d
+	return nil
+}
+
+func (f *fakeStore) DeleteReportDefinition(ctx context.Context, id int) error {
+	delete(f.definitions, id)
+	return nil
+}
+
+// fakeRunner accepts queries on invoices and returns a fixed result.
+type fakeRunner struct{}
+
+func (fakeRunner) Validate(q models.ReportQuery) error {
+	if q.Entity != "invoices" {
+		return models.Invalid("unknown entity %q", q.Entity)
+	}
+	return nil
+}
+
+func (r fakeRunner) Run(ctx context.Context, q models.ReportQuery) (*models.ReportResult, error) {
+	if err := r.Validate(q); err != nil {
+		return nil, err
+	}
+	return &models.ReportResult{Columns: []string{"status", "count"}, Rows: [][]interface{}{{"posted", 3.0}}}, nil
+}
+
+func setupRouter() (*mux.Router, *fakeStore) {
+	store := &fakeStore{definitions: map[int]*models.ReportDefinition{}}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/reports/builder").Subrouter(), store, fakeRunner{})
+	return router, store
+}
+
+func TestCreateAndRunDefinition(t *testing.T) {
+	router, store := setupRouter()
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/reports/builder/definitions", strings.NewReader(body)))
+		return rr
+	}
+
+	rr := post(`{"name": "Invoices by status", "query": {"entity": "invoices"}}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "invoices", store.definitions[1].Query.Entity)
+
+	assert.Equal(t, http.StatusConflict, post(`{"name": "Invoices by status", "query": {"entity": "invoices"}}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, post(`{"name": "Users", "query": {"entity": "users"}}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, post(`{"name": " ", "query": {"entity": "invoices"}}`).Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/reports/builder/definitions/1/run", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var result models.ReportResult
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&result))
+	assert.Equal(t, []string{"status", "count"}, result.Columns)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/reports/builder/definitions/1/run?format=csv", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename="Invoices_by_status.csv"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "status,count\nposted,3\n", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/reports/builder/definitions/9/run", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
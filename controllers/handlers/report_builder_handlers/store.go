@@ -0,0 +1,142 @@
+package report_builder_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"erp/models"
+)
+
+// DBReportDefinitionStore provides SQL-backed methods for saved custom reports.
+type DBReportDefinitionStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// definitionColumns are the columns scanned by scanDefinition.
+const definitionColumns = `id, name, COALESCE(description, ''), query, created_by, created_at, updated_at`
+
+// scanDefinition reads a row selected with definitionColumns.
+func scanDefinition(row interface{ Scan(...interface{}) error }) (*models.ReportDefinition, error) {
+	var d models.ReportDefinition
+	var query []byte
+	if err := row.Scan(&d.ID, &d.Name, &d.Description, &query, &d.CreatedBy, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(query, &d.Query); err != nil {
+		return nil, fmt.Errorf("report definition %d: %w", d.ID, err)
+	}
+	return &d, nil
+}
+
+// duplicateName converts a unique violation on the report name to a conflict.
+func duplicateName(err error, name string) error {
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("a report named %q already exists", name)
+	}
+	return err
+}
+
+// CreateReportDefinition saves a new custom report.
+//
+// Parameters:
+//   - d: The report; its ID, CreatedAt and UpdatedAt are set.
+//
+// Returns:
+//   - error: A models.Conflict error if the name is taken, or an error if the insert fails.
+func (store *DBReportDefinitionStore) CreateReportDefinition(ctx context.Context, d *models.ReportDefinition) error {
+	query, err := json.Marshal(d.Query)
+	if err != nil {
+		return err
+	}
+	err = store.DB.QueryRowContext(ctx,
+		`INSERT INTO report_definitions (name, description, query, created_by, created_at, updated_at)
+		 VALUES ($1, NULLIF($2, ''), $3, $4, NOW(), NOW()) RETURNING id, created_at, updated_at`,
+		d.Name, d.Description, query, d.CreatedBy,
+	).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+	return duplicateName(err, d.Name)
+}
+
+// ListReportDefinitions returns the saved custom reports by name.
+//
+// Returns:
+//   - []models.ReportDefinition: The reports.
+//   - error: An error if the query fails.
+func (store *DBReportDefinitionStore) ListReportDefinitions(ctx context.Context) ([]models.ReportDefinition, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT `+definitionColumns+` FROM report_definitions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	definitions := []models.ReportDefinition{}
+	for rows.Next() {
+		d, err := scanDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, *d)
+	}
+	return definitions, rows.Err()
+}
+
+// GetReportDefinition returns a saved custom report.
+//
+// Parameters:
+//   - id: The ID of the report.
+//
+// Returns:
+//   - *models.ReportDefinition: The report.
+//   - error: models.ErrNotFound if there is no such report, or the query error.
+func (store *DBReportDefinitionStore) GetReportDefinition(ctx context.Context, id int) (*models.ReportDefinition, error) {
+	d, err := scanDefinition(store.DB.QueryRowContext(ctx, `SELECT `+definitionColumns+` FROM report_definitions WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("report %d not found", id)
+	}
+	return d, err
+}
+
+// UpdateReportDefinition replaces the name, description and query of a saved report.
+//
+// Parameters:
+//   - d: The report with its ID set; UpdatedAt is set.
+//
+// Returns:
+//   - error: models.ErrNotFound if there is no such report, a models.Conflict error if the
+//     name is taken, or an error if the update fails.
+func (store *DBReportDefinitionStore) UpdateReportDefinition(ctx context.Context, d *models.ReportDefinition) error {
+	query, err := json.Marshal(d.Query)
+	if err != nil {
+		return err
+	}
+	err = store.DB.QueryRowContext(ctx,
+		`UPDATE report_definitions SET name = $1, description = NULLIF($2, ''), query = $3, updated_at = NOW()
+		 WHERE id = $4 RETURNING created_by, created_at, updated_at`,
+		d.Name, d.Description, query, d.ID,
+	).Scan(&d.CreatedBy, &d.CreatedAt, &d.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return models.NotFound("report %d not found", d.ID)
+	}
+	return duplicateName(err, d.Name)
+}
+
+// DeleteReportDefinition deletes a saved custom report.
+//
+// Parameters:
+//   - id: The ID of the report.
+//
+// Returns:
+//   - error: models.ErrNotFound if there is no such report, or the query error.
+func (store *DBReportDefinitionStore) DeleteReportDefinition(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, `DELETE FROM report_definitions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("report %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package report_handlers
+
+import (
+	"net/http"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// GetJournalAdjustments lists the manual journal entries posted over a date range with
+// the justification, supporting document and poster of each, for auditors. It is read
+// from the journal entries, so it is always current.
+//
+// HTTP Method: GET
+// URL Path: /journal_adjustments?from=YYYY-MM-DD&to=YYYY-MM-DD
+// (from defaults to the first of the month and to to today)
+//
+// Response:
+//   - Status Code: 200 (OK) with the JournalAdjustmentReport as JSON.
+//   - Status Code: 400 (Bad Request) if a date is invalid or from is after to.
+//   - Status Code: 500 (Internal Server Error) if the entries cannot be read.
+func (h *ReportHandler) GetJournalAdjustments(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRange(r.URL.Query(), time.Now())
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	adjustments, err := h.Store.GetJournalAdjustments(r.Context(), from, to)
+	if err != nil {
+		httperr.Write(w, err, "Failed to get journal adjustments")
+		return
+	}
+
+	report := models.JournalAdjustmentReport{
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		Adjustments: adjustments,
+		Company:     h.company(r.Context()),
+	}
+	for _, adjustment := range adjustments {
+		report.Total += adjustment.Total
+	}
+	report.Total = roundCents(report.Total)
+
+	respond.JSON(w, http.StatusOK, report)
+}
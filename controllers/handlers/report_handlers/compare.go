@@ -0,0 +1,102 @@
+package report_handlers
+
+import (
+	"fmt"
+	"time"
+
+	"erp/models"
+)
+
+// comparisonRange returns the period a date range is compared with.
+//
+// Parameters:
+//   - compare: models.ComparePreviousPeriod or models.ComparePreviousYear.
+//   - from, to: The first and last day of the range.
+//
+// Returns:
+//   - time.Time, time.Time: The first and last day of the comparison period.
+//   - error: An error for an unknown comparison mode.
+func comparisonRange(compare string, from, to time.Time) (time.Time, time.Time, error) {
+	switch compare {
+	case models.ComparePreviousPeriod:
+		days := int(to.Sub(from).Hours()/24) + 1
+		return from.AddDate(0, 0, -days), from.AddDate(0, 0, -1), nil
+	case models.ComparePreviousYear:
+		return yearEarlier(from), yearEarlier(to), nil
+	default:
+		return from, to, fmt.Errorf("Invalid compare, expected %s or %s", models.ComparePreviousPeriod, models.ComparePreviousYear)
+	}
+}
+
+// yearEarlier returns the same date a year earlier; February 29 becomes February 28.
+func yearEarlier(date time.Time) time.Time {
+	earlier := date.AddDate(-1, 0, 0)
+	if earlier.Month() != date.Month() {
+		earlier = earlier.AddDate(0, 0, -earlier.Day())
+	}
+	return earlier
+}
+
+// delta returns the change from previous to current. The percentage is left out when
+// previous is zero.
+func delta(previous, current float64) models.Delta {
+	d := models.Delta{Previous: roundCents(previous), Change: roundCents(current - previous)}
+	if previous != 0 {
+		percent := roundCents((current - previous) / abs(previous) * 100)
+		d.Percent = &percent
+	}
+	return d
+}
+
+// abs returns the absolute value of x, so a loss shrinking shows as an increase.
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// CompareProfitability adds the changes from previous to each line of current and to its
+// total. Lines that only had sales in the comparison period are added to current with
+// zero amounts, so every change is listed.
+func CompareProfitability(current *models.ProfitabilityReport, previous models.ProfitabilityReport, compare string) {
+	type key struct {
+		id   int
+		name string
+	}
+	earlier := make(map[key]models.ProfitabilityLine, len(previous.Lines))
+	for _, line := range previous.Lines {
+		earlier[key{line.ID, line.Name}] = line
+	}
+	for i := range current.Lines {
+		k := key{current.Lines[i].ID, current.Lines[i].Name}
+		current.Lines[i].Deltas = profitabilityDeltas(earlier[k], current.Lines[i])
+		delete(earlier, k)
+	}
+	for _, line := range previous.Lines {
+		if _, ok := earlier[key{line.ID, line.Name}]; ok {
+			gone := models.ProfitabilityLine{ID: line.ID, Name: line.Name}
+			gone.Deltas = profitabilityDeltas(line, gone)
+			current.Lines = append(current.Lines, gone)
+		}
+	}
+	current.Total.Deltas = profitabilityDeltas(previous.Total, current.Total)
+	expenses := delta(previous.Expenses, current.Expenses)
+	current.ExpensesDelta = &expenses
+	current.Compare = compare
+	current.Previous = &previous
+}
+
+// profitabilityDeltas returns the changes of every amount of a profitability line.
+func profitabilityDeltas(previous, current models.ProfitabilityLine) *models.ProfitabilityDeltas {
+	return &models.ProfitabilityDeltas{
+		Quantity:          delta(float64(previous.Quantity), float64(current.Quantity)),
+		GrossRevenue:      delta(previous.GrossRevenue, current.GrossRevenue),
+		Discounts:         delta(previous.Discounts, current.Discounts),
+		NetRevenue:        delta(previous.NetRevenue, current.NetRevenue),
+		COGS:              delta(previous.COGS, current.COGS),
+		GrossMargin:       delta(previous.GrossMargin, current.GrossMargin),
+		AllocatedExpenses: delta(previous.AllocatedExpenses, current.AllocatedExpenses),
+		NetMargin:         delta(previous.NetMargin, current.NetMargin),
+	}
+}
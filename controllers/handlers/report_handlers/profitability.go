@@ -0,0 +1,168 @@
+package report_handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// GetProfitability returns the gross and net margin per product or per customer over a
+// date range. Unlike the summary reports it is computed from the source tables, so it is
+// always current. With compare, the report for the comparison period is included and every
+// line and the total carry the absolute and percentage change of each amount.
+//
+// HTTP Method: GET
+// URL Path: /profitability?group_by=product|customer&from=YYYY-MM-DD&to=YYYY-MM-DD&compare=previous_period|previous_year
+// (group_by defaults to product, from to the first of the month and to to today; without
+// compare there is no comparison)
+//
+// Response:
+//   - Status Code: 200 (OK) with the ProfitabilityReport as JSON.
+//   - Status Code: 400 (Bad Request) if group_by, a date or compare is invalid, or from is after to.
+//   - Status Code: 500 (Internal Server Error) if the sales cannot be read.
+func (h *ReportHandler) GetProfitability(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	groupBy := query.Get("group_by")
+	if groupBy == "" {
+		groupBy = models.ProfitabilityByProduct
+	}
+	if groupBy != models.ProfitabilityByProduct && groupBy != models.ProfitabilityByCustomer {
+		respond.Error(w, http.StatusBadRequest, "Invalid group_by, expected product or customer")
+		return
+	}
+
+	from, to, err := dateRange(query, time.Now())
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	compare := query.Get("compare")
+	var previousFrom, previousTo time.Time
+	if compare != "" {
+		if previousFrom, previousTo, err = comparisonRange(compare, from, to); err != nil {
+			respond.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	report, err := h.profitability(r.Context(), groupBy, from, to)
+	if err != nil {
+		httperr.Write(w, err, "Failed to get profitability")
+		return
+	}
+	if compare != "" {
+		previous, err := h.profitability(r.Context(), groupBy, previousFrom, previousTo)
+		if err != nil {
+			httperr.Write(w, err, "Failed to get profitability")
+			return
+		}
+		CompareProfitability(report, *previous, compare)
+	}
+	report.Company = h.company(r.Context())
+
+	respond.JSON(w, http.StatusOK, report)
+}
+
+// profitability builds the profitability report for one date range.
+func (h *ReportHandler) profitability(ctx context.Context, groupBy string, from, to time.Time) (*models.ProfitabilityReport, error) {
+	lines, expenses, err := h.Store.GetProfitability(ctx, groupBy, from, to)
+	if err != nil {
+		return nil, err
+	}
+	report := BuildProfitability(lines, expenses)
+	report.GroupBy = groupBy
+	report.From = from.Format("2006-01-02")
+	report.To = to.Format("2006-01-02")
+	return &report, nil
+}
+
+// dateRange reads the from and to query parameters (YYYY-MM-DD, inclusive). They default
+// to the first of the current month and today.
+func dateRange(query url.Values, now time.Time) (time.Time, time.Time, error) {
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for name, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return from, to, fmt.Errorf("Invalid %s, expected YYYY-MM-DD", name)
+			}
+			*date = parsed
+		}
+	}
+	if from.After(to) {
+		return from, to, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// BuildProfitability computes the margins of lines that hold quantities, revenue,
+// discounts and COGS. Expenses are allocated to the lines with positive net revenue in
+// proportion to it; the last such line takes the rounding difference so the allocations
+// add up to expenses. With no positive revenue the expenses stay unallocated.
+func BuildProfitability(lines []models.ProfitabilityLine, expenses float64) models.ProfitabilityReport {
+	report := models.ProfitabilityReport{Lines: []models.ProfitabilityLine{}, Expenses: roundCents(expenses)}
+
+	revenue := 0.0
+	last := -1
+	for i := range lines {
+		lines[i].NetRevenue = roundCents(lines[i].GrossRevenue - lines[i].Discounts)
+		if lines[i].NetRevenue > 0 {
+			revenue += lines[i].NetRevenue
+			last = i
+		}
+	}
+
+	allocated := 0.0
+	for i := range lines {
+		line := &lines[i]
+		if line.NetRevenue > 0 {
+			if i == last {
+				line.AllocatedExpenses = roundCents(report.Expenses - allocated)
+			} else {
+				line.AllocatedExpenses = roundCents(report.Expenses * line.NetRevenue / revenue)
+			}
+			allocated += line.AllocatedExpenses
+		}
+		setMargins(line)
+
+		report.Total.Quantity += line.Quantity
+		report.Total.GrossRevenue += line.GrossRevenue
+		report.Total.Discounts += line.Discounts
+		report.Total.COGS += line.COGS
+		report.Total.AllocatedExpenses += line.AllocatedExpenses
+		report.Lines = append(report.Lines, *line)
+	}
+
+	report.Total.GrossRevenue = roundCents(report.Total.GrossRevenue)
+	report.Total.Discounts = roundCents(report.Total.Discounts)
+	report.Total.NetRevenue = roundCents(report.Total.GrossRevenue - report.Total.Discounts)
+	report.Total.COGS = roundCents(report.Total.COGS)
+	report.Total.AllocatedExpenses = roundCents(report.Total.AllocatedExpenses)
+	setMargins(&report.Total)
+	return report
+}
+
+// setMargins fills in the margins of a line from its net revenue, COGS and expenses.
+func setMargins(line *models.ProfitabilityLine) {
+	line.GrossMargin = roundCents(line.NetRevenue - line.COGS)
+	line.NetMargin = roundCents(line.GrossMargin - line.AllocatedExpenses)
+	line.GrossMarginPct = 0
+	if line.NetRevenue != 0 {
+		line.GrossMarginPct = roundCents(line.GrossMargin / line.NetRevenue * 100)
+	}
+}
+
+// roundCents rounds an amount to two decimal places.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,186 @@
+package report_handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// ReportHandler provides HTTP handlers for the trial balance, AR aging and profitability
+// reports. The first two are read from summary tables, so each response carries a
+// freshness indicator.
+type ReportHandler struct {
+	Store         models.ReportStore // Store reads and refreshes the summary tables.
+	MaxStaleness  time.Duration      // Age after which a summary is reported as stale.
+	Company       CompanyProvider    // Optional source of the company header printed on reports.
+	DeadStockDays int                // Days without movement after which stock is reported as dead.
+}
+
+// CompanyProvider supplies the company profile, typically the settings service.
+type CompanyProvider interface {
+	Company(ctx context.Context) (*models.CompanyProfile, error)
+}
+
+// RegisterRoutes maps report routes to their respective handler functions.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the ReportStore interface.
+//   - maxStaleness: Age after which a summary is flagged as stale.
+//   - company: Source of the company header on reports, or nil to omit it.
+//   - deadStockDays: Days without movement after which the turnover report flags stock as dead.
+func RegisterRoutes(router *mux.Router, store models.ReportStore, maxStaleness time.Duration, company CompanyProvider, deadStockDays int) {
+	handler := &ReportHandler{Store: store, MaxStaleness: maxStaleness, Company: company, DeadStockDays: deadStockDays}
+
+	router.HandleFunc("/trial_balance", handler.GetTrialBalance).Methods("GET")
+	router.HandleFunc("/ar_aging", handler.GetARAging).Methods("GET")
+	router.HandleFunc("/profitability", handler.GetProfitability).Methods("GET")
+	router.HandleFunc("/journal_adjustments", handler.GetJournalAdjustments).Methods("GET")
+	router.HandleFunc("/sod_violations", handler.GetSoDViolations).Methods("GET")
+	router.HandleFunc("/inventory_turnover", handler.GetInventoryTurnover).Methods("GET")
+	router.HandleFunc("/refresh", handler.Refresh).Methods("POST")
+}
+
+// GetTrialBalance returns the balance of every account up to and including a month.
+//
+// HTTP Method: GET
+// URL Path: /trial_balance?as_of=YYYY-MM (as_of defaults to the current month)
+//
+// Response:
+//   - Status Code: 200 (OK) with the TrialBalance as JSON.
+//   - Status Code: 400 (Bad Request) if as_of is not in YYYY-MM format.
+//   - Status Code: 500 (Internal Server Error) if the summary cannot be read.
+func (h *ReportHandler) GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	asOf := time.Now()
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		parsed, err := time.Parse("2006-01", value)
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid as_of, expected YYYY-MM")
+			return
+		}
+		asOf = parsed
+	}
+	period := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	lines, refreshedAt, err := h.Store.GetTrialBalance(r.Context(), period)
+	if err != nil {
+		httperr.Write(w, err, "Failed to get trial balance")
+		return
+	}
+
+	report := models.TrialBalance{
+		AsOf:      period.Format("2006-01"),
+		Lines:     lines,
+		Freshness: h.freshness(refreshedAt),
+		Company:   h.company(r.Context()),
+	}
+	if report.Lines == nil {
+		report.Lines = []models.TrialBalanceLine{}
+	}
+	for _, line := range lines {
+		report.TotalDebit += line.Debit
+		report.TotalCredit += line.Credit
+	}
+
+	respond.JSON(w, http.StatusOK, report)
+}
+
+// GetARAging returns outstanding receivables per customer bucketed by days overdue.
+//
+// HTTP Method: GET
+// URL Path: /ar_aging
+//
+// Response:
+//   - Status Code: 200 (OK) with the ARAgingReport as JSON.
+//   - Status Code: 500 (Internal Server Error) if the summary cannot be read.
+func (h *ReportHandler) GetARAging(w http.ResponseWriter, r *http.Request) {
+	customers, refreshedAt, err := h.Store.GetARAging(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to get AR aging")
+		return
+	}
+
+	report := models.ARAgingReport{
+		Customers: customers,
+		Freshness: h.freshness(refreshedAt),
+		Company:   h.company(r.Context()),
+	}
+	if report.Customers == nil {
+		report.Customers = []models.CustomerAging{}
+	}
+	for _, customer := range customers {
+		report.Total += customer.Total
+	}
+
+	respond.JSON(w, http.StatusOK, report)
+}
+
+// Refresh rebuilds both summary tables immediately instead of waiting for the scheduler.
+//
+// HTTP Method: POST
+// URL Path: /refresh
+//
+// Response:
+//   - Status Code: 204 (No Content) when both summaries were rebuilt.
+//   - Status Code: 500 (Internal Server Error) if a rebuild fails.
+func (h *ReportHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if err := Refresh(r.Context(), h.Store, time.Now()); err != nil {
+		httperr.Write(w, err, "Failed to refresh reports")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// company returns the company header for a report. Reports are still served without the
+// header if the settings cannot be loaded.
+func (h *ReportHandler) company(ctx context.Context) *models.CompanyProfile {
+	if h.Company == nil {
+		return nil
+	}
+	profile, err := h.Company.Company(ctx)
+	if err != nil {
+		log.Printf("reports: could not load company profile: %v", err)
+		return nil
+	}
+	return profile
+}
+
+// Refresh rebuilds the account balance and customer outstanding summaries.
+// It is shared by the refresh endpoint and the background scheduler.
+//
+// Parameters:
+//   - store: The report store to rebuild.
+//   - now: The date receivables are aged against.
+//
+// Returns:
+//   - error: The first rebuild error, if any.
+func Refresh(ctx context.Context, store models.ReportStore, now time.Time) error {
+	if err := store.RefreshAccountBalances(ctx); err != nil {
+		return fmt.Errorf("account balances: %w", err)
+	}
+	if err := store.RefreshCustomerOutstanding(ctx, now); err != nil {
+		return fmt.Errorf("customer outstanding: %w", err)
+	}
+	return nil
+}
+
+// freshness describes how old a summary is. A summary that was never built is always stale.
+func (h *ReportHandler) freshness(refreshedAt time.Time) models.Freshness {
+	if refreshedAt.IsZero() {
+		return models.Freshness{Stale: true}
+	}
+	age := time.Since(refreshedAt)
+	return models.Freshness{
+		RefreshedAt: refreshedAt,
+		AgeSeconds:  int64(age.Seconds()),
+		Stale:       h.MaxStaleness > 0 && age > h.MaxStaleness,
+	}
+}
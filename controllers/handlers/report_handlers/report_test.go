@@ -0,0 +1,375 @@
+package report_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockReportStore is an in-memory implementation of models.ReportStore.
+type mockReportStore struct {
+	lines       []models.TrialBalanceLine
+	customers   []models.CustomerAging
+	refreshedAt time.Time
+	asOf        time.Time
+	refreshes   int
+
+	profitability []models.ProfitabilityLine
+	expenses      float64
+	earlier       map[time.Time][]models.ProfitabilityLine // Lines of comparison periods by start date
+	groupBy       string
+	from, to      time.Time
+
+	adjustments []models.JournalAdjustment
+	violations  []models.SoDViolation
+	turnover    []models.InventoryTurnoverLine
+}
+
+func (m *mockReportStore) GetTrialBalance(ctx context.Context, asOf time.Time) ([]models.TrialBalanceLine, time.Time, error) {
+	m.asOf = asOf
+	return m.lines, m.refreshedAt, nil
+}
+
+func (m *mockReportStore) GetARAging(ctx context.Context) ([]models.CustomerAging, time.Time, error) {
+	return m.customers, m.refreshedAt, nil
+}
+
+func (m *mockReportStore) RefreshAccountBalances(ctx context.Context) error {
+	m.refreshes++
+	return nil
+}
+
+func (m *mockReportStore) RefreshCustomerOutstanding(ctx context.Context, asOf time.Time) error {
+	m.refreshes++
+	m.refreshedAt = time.Now()
+	return nil
+}
+
+func (m *mockReportStore) GetProfitability(ctx context.Context, groupBy string, from, to time.Time) ([]models.ProfitabilityLine, float64, error) {
+	if lines, ok := m.earlier[from]; ok {
+		return lines, 0, nil
+	}
+	m.groupBy, m.from, m.to = groupBy, from, to
+	return m.profitability, m.expenses, nil
+}
+
+func (m *mockReportStore) GetJournalAdjustments(ctx context.Context, from, to time.Time) ([]models.JournalAdjustment, error) {
+	m.from, m.to = from, to
+	return m.adjustments, nil
+}
+
+func (m *mockReportStore) GetSoDViolations(ctx context.Context, from, to time.Time) ([]models.SoDViolation, error) {
+	m.from, m.to = from, to
+	return m.violations, nil
+}
+
+func (m *mockReportStore) GetInventoryTurnover(ctx context.Context, from, to time.Time) ([]models.InventoryTurnoverLine, error) {
+	m.from, m.to = from, to
+	return m.turnover, nil
+}
+
+func setupRouter(store *mockReportStore) *mux.Router {
+	router := mux.NewRouter()
+	RegisterRoutes(router, store, time.Hour, nil, 90)
+	return router
+}
+
+func TestGetTrialBalance(t *testing.T) {
+	store := &mockReportStore{
+		lines: []models.TrialBalanceLine{
+			{AccountType: "cash", Debit: 500, Credit: 200, Balance: 300},
+			{AccountType: "revenue", Debit: 0, Credit: 300, Balance: -300},
+		},
+		refreshedAt: time.Now().Add(-time.Minute),
+	}
+	router := setupRouter(store)
+
+	req := httptest.NewRequest("GET", "/trial_balance?as_of=2024-03", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var report models.TrialBalance
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, "2024-03", report.AsOf)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), store.asOf)
+	assert.Equal(t, 500.0, report.TotalDebit)
+	assert.Equal(t, 500.0, report.TotalCredit)
+	assert.False(t, report.Freshness.Stale)
+}
+
+func TestGetTrialBalanceInvalidPeriod(t *testing.T) {
+	router := setupRouter(&mockReportStore{})
+
+	req := httptest.NewRequest("GET", "/trial_balance?as_of=March", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetARAgingStale(t *testing.T) {
+	store := &mockReportStore{
+		customers: []models.CustomerAging{
+			{CustomerName: "Acme", Current: 100, Over90: 50, Total: 150},
+		},
+		refreshedAt: time.Now().Add(-2 * time.Hour),
+	}
+	router := setupRouter(store)
+
+	req := httptest.NewRequest("GET", "/ar_aging", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var report models.ARAgingReport
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, 150.0, report.Total)
+	assert.True(t, report.Freshness.Stale)
+}
+
+func TestRefresh(t *testing.T) {
+	store := &mockReportStore{}
+	router := setupRouter(store)
+
+	req := httptest.NewRequest("POST", "/refresh", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, 2, store.refreshes)
+	assert.False(t, store.refreshedAt.IsZero())
+}
+
+func TestGetProfitability(t *testing.T) {
+	store := &mockReportStore{
+		profitability: []models.ProfitabilityLine{
+			{ID: 1, Name: "Acme", Quantity: 10, GrossRevenue: 700, Discounts: 100, COGS: 360},
+			{ID: 2, Name: "Globex", Quantity: 4, GrossRevenue: 300, COGS: 120},
+			{ID: 0, Name: "", Quantity: 1, GrossRevenue: 5, Discounts: 5},
+		},
+		expenses: 100,
+	}
+	router := setupRouter(store)
+
+	req := httptest.NewRequest("GET", "/profitability?group_by=customer&from=2024-01-01&to=2024-03-31", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.ProfitabilityByCustomer, store.groupBy)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), store.from)
+	assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), store.to)
+
+	var report models.ProfitabilityReport
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, "2024-01-01", report.From)
+	assert.Len(t, report.Lines, 3)
+
+	acme := report.Lines[0]
+	assert.Equal(t, 600.0, acme.NetRevenue)
+	assert.Equal(t, 240.0, acme.GrossMargin)
+	assert.Equal(t, 40.0, acme.GrossMarginPct)
+	assert.Equal(t, 66.67, acme.AllocatedExpenses)
+	assert.Equal(t, 173.33, acme.NetMargin)
+	assert.Equal(t, 33.33, report.Lines[1].AllocatedExpenses, "The last line takes the rounding difference")
+	assert.Equal(t, 0.0, report.Lines[2].AllocatedExpenses, "Lines without net revenue carry no expenses")
+
+	assert.Equal(t, 15, report.Total.Quantity)
+	assert.Equal(t, 900.0, report.Total.NetRevenue)
+	assert.Equal(t, 480.0, report.Total.COGS)
+	assert.Equal(t, 100.0, report.Total.AllocatedExpenses)
+	assert.Equal(t, 320.0, report.Total.NetMargin)
+}
+
+func TestGetProfitabilityInvalidQuery(t *testing.T) {
+	router := setupRouter(&mockReportStore{})
+	for _, query := range []string{"group_by=region", "from=2024-13-01", "from=2024-03-01&to=2024-02-01"} {
+		req := httptest.NewRequest("GET", "/profitability?"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestGetProfitabilityComparison(t *testing.T) {
+	store := &mockReportStore{
+		profitability: []models.ProfitabilityLine{
+			{ID: 1, Name: "Acme", Quantity: 12, GrossRevenue: 900},
+			{ID: 3, Name: "Initech", Quantity: 1, GrossRevenue: 50},
+		},
+		earlier: map[time.Time][]models.ProfitabilityLine{
+			// The 31 days before March 2024 start on January 30
+			time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC): {
+				{ID: 1, Name: "Acme", Quantity: 10, GrossRevenue: 600},
+				{ID: 2, Name: "Globex", Quantity: 4, GrossRevenue: 300},
+			},
+			time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC): {},
+		},
+	}
+	router := setupRouter(store)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/profitability?group_by=customer&from=2024-03-01&to=2024-03-31&compare=previous_period", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var report models.ProfitabilityReport
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, models.ComparePreviousPeriod, report.Compare)
+	assert.Equal(t, "2024-01-30", report.Previous.From)
+	assert.Equal(t, "2024-02-29", report.Previous.To)
+	assert.Len(t, report.Lines, 3, "Globex only sold in the previous period")
+
+	acme := report.Lines[0].Deltas.NetRevenue
+	assert.Equal(t, 600.0, acme.Previous)
+	assert.Equal(t, 300.0, acme.Change)
+	assert.Equal(t, 50.0, *acme.Percent)
+	assert.Nil(t, report.Lines[1].Deltas.NetRevenue.Percent, "Initech is new")
+	assert.Equal(t, "Globex", report.Lines[2].Name)
+	assert.Equal(t, -100.0, *report.Lines[2].Deltas.NetRevenue.Percent)
+	assert.Equal(t, 50.0, report.Total.Deltas.NetRevenue.Change)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/profitability?from=2024-03-01&to=2024-03-31&compare=previous_year", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	report = models.ProfitabilityReport{}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, "2023-03-31", report.Previous.To)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/profitability?compare=last_week", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestYearEarlier(t *testing.T) {
+	assert.Equal(t, time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC), yearEarlier(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), yearEarlier(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestBuildProfitabilityWithoutRevenue(t *testing.T) {
+	report := BuildProfitability(nil, 50)
+	assert.Empty(t, report.Lines)
+	assert.Equal(t, 50.0, report.Expenses)
+	assert.Equal(t, 0.0, report.Total.AllocatedExpenses)
+	assert.Equal(t, 0.0, report.Total.GrossMarginPct)
+}
+
+func TestGetJournalAdjustments(t *testing.T) {
+	store := &mockReportStore{adjustments: []models.JournalAdjustment{
+		{ID: 1, EntryDate: "2024-03-05", Justification: "Accrue March rent per lease", PostedBy: "cfo@example.com", Total: 1200.10},
+		{ID: 4, EntryDate: "2024-03-20", Justification: "Reclassify misposted fee", Total: 35.05},
+	}}
+	router := setupRouter(store)
+
+	req := httptest.NewRequest("GET", "/journal_adjustments?from=2024-03-01&to=2024-03-31", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var report models.JournalAdjustmentReport
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), store.to)
+	assert.Len(t, report.Adjustments, 2)
+	assert.Equal(t, "Accrue March rent per lease", report.Adjustments[0].Justification)
+	assert.Equal(t, 1235.15, report.Total)
+}
+
+func TestGetSoDViolations(t *testing.T) {
+	store := &mockReportStore{violations: []models.SoDViolation{
+		{ID: 1, DocumentType: models.DocumentPayment, DocumentID: 7, User: "clerk@example.com", Blocked: true},
+		{ID: 2, DocumentType: models.DocumentJournalEntry, DocumentID: 3, User: "cfo@example.com"},
+		{ID: 3, DocumentType: models.DocumentPayment, DocumentID: 9, User: "clerk@example.com", Blocked: true},
+	}}
+	router := setupRouter(store)
+
+	req := httptest.NewRequest("GET", "/sod_violations?from=2024-03-01&to=2024-03-31", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var report models.SoDViolationReport
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), store.from)
+	assert.Len(t, report.Violations, 3)
+	assert.Equal(t, 2, report.Blocked)
+	assert.Equal(t, 1, report.Allowed)
+
+	req = httptest.NewRequest("GET", "/sod_violations?from=2024-04-01&to=2024-03-31", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestBuildInventoryTurnover(t *testing.T) {
+	now := time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+	lines := []models.InventoryTurnoverLine{
+		{ID: 1, Name: "Jacket", Brand: "Acme", UnitCost: 20, UnitsIssued: 60, OnHand: 10, AverageOnHand: 15, LastMovement: daysAgo(2)},
+		{ID: 2, Name: "Scarf", Brand: "Acme", UnitCost: 5, UnitsIssued: 0, OnHand: 40, AverageOnHand: 40, LastMovement: daysAgo(120)},
+		{ID: 3, Name: "Boots", Brand: "Trek", UnitCost: 50, UnitsIssued: 4, OnHand: 2, AverageOnHand: 2, LastMovement: daysAgo(10)},
+		{ID: 4, Name: "Gloves", Brand: "Trek", UnitCost: 8, OnHand: 5, AverageOnHand: 5},
+		{ID: 5, Name: "Hat", Brand: "Trek", UnitCost: 9, LastMovement: daysAgo(400)},
+	}
+
+	report := BuildInventoryTurnover(lines, models.TurnoverByProduct, now, 90)
+	assert.Equal(t, []int{1, 3, 2, 4, 5}, []int{report.Lines[0].ID, report.Lines[1].ID, report.Lines[2].ID,
+		report.Lines[3].ID, report.Lines[4].ID}, "fastest first")
+	assert.Equal(t, 1200.0, report.Lines[0].COGS)
+	assert.Equal(t, 300.0, report.Lines[0].AverageValue)
+	assert.Equal(t, 4.0, report.Lines[0].Turnover)
+	assert.Equal(t, 2.0, report.Lines[1].Turnover)
+	require.Len(t, report.DeadStock, 2, "stock idle for 90 days or never moved; nothing on hand is not dead")
+	assert.Equal(t, "Scarf", report.DeadStock[0].Name, "most valuable first")
+	assert.Equal(t, 120, report.DeadStock[0].IdleDays)
+	assert.Equal(t, "Gloves", report.DeadStock[1].Name)
+	assert.Equal(t, 240.0, report.DeadStockValue)
+	assert.Equal(t, 1400.0, report.COGS)
+	assert.Equal(t, 640.0, report.AverageValue)
+	assert.Equal(t, 2.19, report.Turnover)
+
+	report = BuildInventoryTurnover(lines, models.TurnoverByBrand, now, 90)
+	require.Len(t, report.Lines, 2)
+	assert.Equal(t, "Acme", report.Lines[0].Name)
+	assert.Equal(t, 2.4, report.Lines[0].Turnover)
+	assert.Equal(t, "Trek", report.Lines[1].Name)
+	assert.Equal(t, 1.43, report.Lines[1].Turnover)
+	assert.Equal(t, 7, report.Lines[1].OnHand)
+	assert.Equal(t, 10, report.Lines[1].IdleDays, "the brand's latest movement")
+	assert.False(t, report.Lines[1].Dead)
+	require.Len(t, report.DeadStock, 2, "dead stock is listed by product")
+}
+
+func TestGetInventoryTurnover(t *testing.T) {
+	store := &mockReportStore{turnover: []models.InventoryTurnoverLine{{ID: 1, Name: "Jacket", UnitCost: 20, UnitsIssued: 6, AverageOnHand: 3}}}
+	router := setupRouter(store)
+
+	req := httptest.NewRequest("GET", "/inventory_turnover?period=2024-02", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var report models.InventoryTurnoverReport
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), store.from)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), store.to)
+	assert.Equal(t, "2024-02-29", report.To)
+	assert.Equal(t, 90, report.DeadStockDays)
+	assert.Equal(t, 2.0, report.Turnover)
+
+	for _, query := range []string{"period=2024-13", "period=2999", "group_by=season", "dead_days=0"} {
+		req = httptest.NewRequest("GET", "/inventory_turnover?"+query, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
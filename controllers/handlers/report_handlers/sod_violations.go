@@ -0,0 +1,52 @@
+package report_handlers
+
+import (
+	"net/http"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// GetSoDViolations lists, for compliance review, the users who approved or tried to
+// approve a payment or journal entry they created themselves over a date range. Refused
+// attempts are counted as blocked; approvals made while the policy was disabled as allowed.
+//
+// HTTP Method: GET
+// URL Path: /sod_violations?from=YYYY-MM-DD&to=YYYY-MM-DD
+// (from defaults to the first of the month and to to today)
+//
+// Response:
+//   - Status Code: 200 (OK) with the SoDViolationReport as JSON.
+//   - Status Code: 400 (Bad Request) if a date is invalid or from is after to.
+//   - Status Code: 500 (Internal Server Error) if the violations cannot be read.
+func (h *ReportHandler) GetSoDViolations(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRange(r.URL.Query(), time.Now())
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	violations, err := h.Store.GetSoDViolations(r.Context(), from, to)
+	if err != nil {
+		httperr.Write(w, err, "Failed to get segregation of duties violations")
+		return
+	}
+
+	report := models.SoDViolationReport{
+		From:       from.Format("2006-01-02"),
+		To:         to.Format("2006-01-02"),
+		Violations: violations,
+		Company:    h.company(r.Context()),
+	}
+	for _, violation := range violations {
+		if violation.Blocked {
+			report.Blocked++
+		} else {
+			report.Allowed++
+		}
+	}
+
+	respond.JSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,483 @@
+// Package report_handlers provides SQL-backed methods for the report summary tables.
+// The summaries avoid scanning the full ledger and receivables tables on every request.
+package report_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/models"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBReportStore provides SQL-backed methods for the account_period_balances and
+// customer_outstanding summary tables and for the reports read from the source tables.
+type DBReportStore struct {
+	DB              *sql.DB  // DB represents the database connection.
+	ExpenseAccounts []string // Ledger accounts summed as expenses by GetProfitability.
+}
+
+// profitabilityGroups are the key and name columns of each profitability grouping, read
+// from the sales CTE joined to products (p) and customers (c).
+var profitabilityGroups = map[string]string{
+	models.ProfitabilityByProduct:  "COALESCE(sales.product_id, 0), COALESCE(p.name, '')",
+	models.ProfitabilityByCustomer: "COALESCE(sales.customer_id, 0), COALESCE(c.name, '')",
+}
+
+// GetTrialBalance sums the per-period account balances up to and including asOf.
+//
+// Parameters:
+//   - asOf: The first day of the last month to include.
+//
+// Returns:
+//   - []TrialBalanceLine: One line per account.
+//   - time.Time: When the summary was last updated.
+//   - error: An error if the query fails.
+func (store *DBReportStore) GetTrialBalance(ctx context.Context, asOf time.Time) ([]models.TrialBalanceLine, time.Time, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT account_type, SUM(debit_total), SUM(credit_total)
+		 FROM account_period_balances
+		 WHERE period <= $1
+		 GROUP BY account_type
+		 ORDER BY account_type`, asOf,
+	)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var lines []models.TrialBalanceLine
+	for rows.Next() {
+		var line models.TrialBalanceLine
+		if err := rows.Scan(&line.AccountType, &line.Debit, &line.Credit); err != nil {
+			return nil, time.Time{}, err
+		}
+		line.Balance = line.Debit - line.Credit
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	refreshedAt, err := store.refreshedAt(ctx, models.ReportTrialBalance)
+	return lines, refreshedAt, err
+}
+
+// GetARAging returns the precomputed outstanding amounts per customer.
+//
+// Returns:
+//   - []CustomerAging: One row per customer with an outstanding balance.
+//   - time.Time: When the summary was last refreshed.
+//   - error: An error if the query fails.
+func (store *DBReportStore) GetARAging(ctx context.Context) ([]models.CustomerAging, time.Time, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT customer_name, current_amount, days_1_30, days_31_60, days_61_90, over_90, total
+		 FROM customer_outstanding
+		 ORDER BY total DESC`,
+	)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var customers []models.CustomerAging
+	for rows.Next() {
+		var c models.CustomerAging
+		if err := rows.Scan(&c.CustomerName, &c.Current, &c.Days1To30, &c.Days31To60, &c.Days61To90, &c.Over90, &c.Total); err != nil {
+			return nil, time.Time{}, err
+		}
+		customers = append(customers, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	refreshedAt, err := store.refreshedAt(ctx, models.ReportARAging)
+	return customers, refreshedAt, err
+}
+
+// RefreshAccountBalances rebuilds account_period_balances from the ledger. Positive amounts
+// are debits and negative amounts are credits. Readers see either the old or the new summary.
+//
+// Returns:
+//   - error: An error if the rebuild fails.
+func (store *DBReportStore) RefreshAccountBalances(ctx context.Context) error {
+	return store.rebuild(ctx, models.ReportTrialBalance,
+		`DELETE FROM account_period_balances`,
+		`INSERT INTO account_period_balances (account_type, period, debit_total, credit_total)
+		 SELECT account_type, date_trunc('month', transaction_date)::date,
+		        SUM(GREATEST(amount, 0)), SUM(GREATEST(-amount, 0))
+		 FROM financial_transactions
+		 GROUP BY account_type, date_trunc('month', transaction_date)`,
+	)
+}
+
+// RefreshCustomerOutstanding rebuilds customer_outstanding from unpaid receivables, bucketing
+// each amount by how many days it is overdue at asOf.
+//
+// Parameters:
+//   - asOf: The date the ageing is computed for.
+//
+// Returns:
+//   - error: An error if the rebuild fails.
+func (store *DBReportStore) RefreshCustomerOutstanding(ctx context.Context, asOf time.Time) error {
+	return store.rebuild(ctx, models.ReportARAging,
+		`DELETE FROM customer_outstanding`,
+		`INSERT INTO customer_outstanding (customer_name, current_amount, days_1_30, days_31_60, days_61_90, over_90, total)
+		 SELECT customer_name,
+		        SUM(CASE WHEN $1::date - due_date <= 0 THEN amount ELSE 0 END),
+		        SUM(CASE WHEN $1::date - due_date BETWEEN 1 AND 30 THEN amount ELSE 0 END),
+		        SUM(CASE WHEN $1::date - due_date BETWEEN 31 AND 60 THEN amount ELSE 0 END),
+		        SUM(CASE WHEN $1::date - due_date BETWEEN 61 AND 90 THEN amount ELSE 0 END),
+		        SUM(CASE WHEN $1::date - due_date > 90 THEN amount ELSE 0 END),
+		        SUM(amount)
+		 FROM receivables
+		 WHERE COALESCE(LOWER(status), 'pending') <> 'paid'
+		 GROUP BY customer_name`,
+		asOf,
+	)
+}
+
+// GetProfitability sums the sales between from and to per product or customer. Sales are
+// posted invoices, dated by their revenue posting and attributed to their sales order's
+// product, and POS sale lines. Loyalty redemptions are the invoices' discounts, and COGS is
+// the quantity sold at the product's unit cost.
+//
+// Parameters:
+//   - groupBy: models.ProfitabilityByProduct or models.ProfitabilityByCustomer.
+//   - from, to: The first and last day of the range.
+//
+// Returns:
+//   - []models.ProfitabilityLine: One line per group with the sums filled in, by net revenue.
+//   - float64: The net debits to the expense accounts over the range.
+//   - error: A validation error for an unknown grouping, or the query error.
+func (store *DBReportStore) GetProfitability(ctx context.Context, groupBy string, from, to time.Time) ([]models.ProfitabilityLine, float64, error) {
+	group, ok := profitabilityGroups[groupBy]
+	if !ok {
+		return nil, 0, models.Invalid("cannot group profitability by %q", groupBy)
+	}
+	rows, err := store.DB.QueryContext(ctx,
+		`WITH posted AS (
+		     SELECT invoice_id, MIN(transaction_date) AS posted_on FROM financial_transactions
+		     WHERE account_type = 'revenue' AND invoice_id IS NOT NULL
+		     GROUP BY invoice_id
+		 ), discounts AS (
+		     SELECT invoice_id, SUM(discount) AS discount FROM loyalty_transactions
+		     WHERE kind = $3
+		     GROUP BY invoice_id
+		 ), sales AS (
+		     SELECT so.product_id, i.customer_id, COALESCE(so.quantity, 0) AS quantity,
+		            i.amount + COALESCE(d.discount, 0) AS gross, COALESCE(d.discount, 0) AS discount
+		     FROM invoices i
+		     JOIN posted ON posted.invoice_id = i.id
+		     LEFT JOIN sales_orders so ON so.id = i.sales_order_id
+		     LEFT JOIN discounts d ON d.invoice_id = i.id
+		     WHERE i.status = $4 AND posted.posted_on BETWEEN $1 AND $2
+		     UNION ALL
+		     SELECT l.product_id, s.customer_id, l.quantity, l.quantity * l.unit_price, 0
+		     FROM pos_sale_lines l JOIN pos_sales s ON s.id = l.sale_id
+		     WHERE s.created_at::date BETWEEN $1 AND $2
+		 )
+		 SELECT `+group+`, SUM(sales.quantity), SUM(sales.gross), SUM(sales.discount),
+		        SUM(sales.quantity * COALESCE(p.unit_cost, 0))
+		 FROM sales
+		 LEFT JOIN products p ON p.id = sales.product_id
+		 LEFT JOIN customers c ON c.id = sales.customer_id
+		 GROUP BY 1, 2
+		 ORDER BY SUM(sales.gross) - SUM(sales.discount) DESC, 1`,
+		from, to, models.LoyaltyRedemption, models.InvoiceStatusPosted,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load sales: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []models.ProfitabilityLine
+	for rows.Next() {
+		var line models.ProfitabilityLine
+		if err := rows.Scan(&line.ID, &line.Name, &line.Quantity, &line.GrossRevenue, &line.Discounts, &line.COGS); err != nil {
+			return nil, 0, err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var expenses float64
+	err = store.DB.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM financial_transactions
+		 WHERE account_type = ANY($1) AND transaction_date BETWEEN $2 AND $3`,
+		pq.Array(store.ExpenseAccounts), from, to,
+	).Scan(&expenses)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load expenses: %w", err)
+	}
+	return lines, expenses, nil
+}
+
+// GetJournalAdjustments lists the posted manual journal entries dated between from and to
+// with their justification and total.
+//
+// Parameters:
+//   - from, to: The first and last entry date to include.
+//
+// Returns:
+//   - []models.JournalAdjustment: The adjustments, oldest first.
+//   - error: An error if the query fails.
+func (store *DBReportStore) GetJournalAdjustments(ctx context.Context, from, to time.Time) ([]models.JournalAdjustment, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT je.id, je.entry_date, COALESCE(je.description, ''), je.justification,
+		        COALESCE(je.attachment_url, ''), COALESCE(je.posted_by, ''), je.posted_at,
+		        COALESCE(SUM(l.debit), 0)
+		 FROM journal_entries je
+		 LEFT JOIN journal_entry_lines l ON l.journal_entry_id = je.id
+		 WHERE je.status = $1 AND je.entry_date BETWEEN $2 AND $3
+		 GROUP BY je.id
+		 ORDER BY je.entry_date, je.id`,
+		models.StatusPosted, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := []models.JournalAdjustment{}
+	for rows.Next() {
+		var a models.JournalAdjustment
+		var entryDate time.Time
+		if err := rows.Scan(&a.ID, &entryDate, &a.Description, &a.Justification, &a.AttachmentURL, &a.PostedBy, &a.PostedAt, &a.Total); err != nil {
+			return nil, err
+		}
+		a.EntryDate = entryDate.Format("2006-01-02")
+		adjustments = append(adjustments, a)
+	}
+	return adjustments, rows.Err()
+}
+
+// GetSoDViolations lists the segregation-of-duties violations that occurred between from
+// and to.
+//
+// Parameters:
+//   - from, to: The first and last day to include.
+//
+// Returns:
+//   - []models.SoDViolation: The violations, oldest first.
+//   - error: An error if the query fails.
+func (store *DBReportStore) GetSoDViolations(ctx context.Context, from, to time.Time) ([]models.SoDViolation, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, document_type, document_id, user_email, blocked, occurred_at
+		 FROM sod_violations
+		 WHERE occurred_at >= $1 AND occurred_at < $2
+		 ORDER BY occurred_at, id`,
+		from, to.AddDate(0, 0, 1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	violations := []models.SoDViolation{}
+	for rows.Next() {
+		var v models.SoDViolation
+		if err := rows.Scan(&v.ID, &v.DocumentType, &v.DocumentID, &v.User, &v.Blocked, &v.OccurredAt); err != nil {
+			return nil, err
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
+
+// GetInventoryTurnover returns every product's stock activity between from and to: the
+// units fulfilled on sales orders and sold at the POS, the units on hand now, the average
+// units on hand over the stock snapshots taken in the range, and the last time its stock
+// was transferred, sold or received.
+//
+// Parameters:
+//   - from, to: The start of the range and the end, exclusive.
+//
+// Returns:
+//   - []models.InventoryTurnoverLine: The products by ID, without values or ratios.
+//   - error: An error if the query fails.
+func (store *DBReportStore) GetInventoryTurnover(ctx context.Context, from, to time.Time) ([]models.InventoryTurnoverLine, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`WITH issued AS (
+		     SELECT l.product_id, l.quantity FROM sales_order_lines l
+		     JOIN sales_orders so ON so.id = l.sales_order_id
+		     WHERE so.fulfilled_at >= $1 AND so.fulfilled_at < $2
+		     UNION ALL
+		     SELECT l.product_id, l.quantity FROM pos_sale_lines l
+		     JOIN pos_sales s ON s.id = l.sale_id
+		     WHERE s.created_at >= $1 AND s.created_at < $2
+		 ), snapshots AS (
+		     SELECT id FROM stock_snapshots WHERE taken_at >= $1 AND taken_at < $2
+		 ), average AS (
+		     SELECT l.product_id, SUM(l.quantity)::float8 / (SELECT COUNT(*) FROM snapshots) AS quantity
+		     FROM stock_snapshot_lines l
+		     WHERE l.snapshot_id IN (SELECT id FROM snapshots)
+		     GROUP BY l.product_id
+		 ), moved AS (
+		     SELECT product_id, moved_at AS at FROM stock_movements
+		     UNION ALL
+		     SELECT l.product_id, so.fulfilled_at FROM sales_order_lines l
+		     JOIN sales_orders so ON so.id = l.sales_order_id
+		     WHERE so.fulfilled_at IS NOT NULL
+		     UNION ALL
+		     SELECT l.product_id, s.created_at FROM pos_sale_lines l JOIN pos_sales s ON s.id = l.sale_id
+		     UNION ALL
+		     SELECT (line->>'product_id')::int, r.received_at
+		     FROM purchase_order_receipts r, jsonb_array_elements(r.lines) line
+		 ), on_hand AS (
+		     SELECT product_id, SUM(quantity) AS quantity FROM stock GROUP BY product_id
+		 )
+		 SELECT p.id, p.name, COALESCE(p.brand, ''), p.unit_cost,
+		        COALESCE((SELECT SUM(quantity) FROM issued WHERE issued.product_id = p.id), 0),
+		        COALESCE(oh.quantity, 0),
+		        CASE WHEN EXISTS (SELECT 1 FROM snapshots) THEN COALESCE(a.quantity, 0)
+		             ELSE COALESCE(oh.quantity, 0) END,
+		        (SELECT MAX(at) FROM moved WHERE moved.product_id = p.id)
+		 FROM products p
+		 LEFT JOIN on_hand oh ON oh.product_id = p.id
+		 LEFT JOIN average a ON a.product_id = p.id
+		 ORDER BY p.id`,
+		from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stock activity: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []models.InventoryTurnoverLine{}
+	for rows.Next() {
+		var line models.InventoryTurnoverLine
+		var lastMovement sql.NullTime
+		if err := rows.Scan(&line.ID, &line.Name, &line.Brand, &line.UnitCost, &line.UnitsIssued, &line.OnHand,
+			&line.AverageOnHand, &lastMovement); err != nil {
+			return nil, err
+		}
+		if lastMovement.Valid {
+			line.LastMovement = &lastMovement.Time
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// rebuild replaces a summary table in one transaction and records the refresh time.
+func (store *DBReportStore) rebuild(ctx context.Context, report, clear, fill string, args ...interface{}) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, clear); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, fill, args...); err != nil {
+		return err
+	}
+	if err := MarkRefreshed(ctx, tx, report); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// refreshedAt returns when a summary was last updated, or the zero time if never.
+func (store *DBReportStore) refreshedAt(ctx context.Context, report string) (time.Time, error) {
+	var refreshedAt time.Time
+	err := store.DB.QueryRowContext(ctx, "SELECT refreshed_at FROM report_refreshes WHERE report_name = $1", report).Scan(&refreshedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return refreshedAt, err
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// MarkRefreshed records that a summary table was updated just now. Stores that maintain a
+// summary incrementally call it in the same transaction as their change.
+//
+// Parameters:
+//   - e: The transaction (or database) to write with.
+//   - report: One of the models.Report* names.
+//
+// Returns:
+//   - error: An error if the upsert fails.
+func MarkRefreshed(ctx context.Context, e Execer, report string) error {
+	_, err := e.ExecContext(ctx,
+		`INSERT INTO report_refreshes (report_name, refreshed_at) VALUES ($1, $2)
+		 ON CONFLICT (report_name) DO UPDATE SET refreshed_at = EXCLUDED.refreshed_at`,
+		report, time.Now(),
+	)
+	return err
+}
+
+// ApplyLedgerEntry adds a financial transaction to the per-period account balances.
+// Positive amounts are debits and negative amounts are credits.
+//
+// Parameters:
+//   - e: The transaction the ledger change is written in.
+//   - transaction: The financial transaction being recorded.
+//
+// Returns:
+//   - error: An error if the upsert fails.
+func ApplyLedgerEntry(ctx context.Context, e Execer, transaction *models.FinancialTransaction) error {
+	return adjustAccountBalance(ctx, e, transaction, 1)
+}
+
+// ReverseLedgerEntry removes a previously applied financial transaction from the
+// per-period account balances.
+//
+// Parameters:
+//   - e: The transaction the ledger change is written in.
+//   - transaction: The financial transaction as it was recorded.
+//
+// Returns:
+//   - error: An error if the upsert fails.
+func ReverseLedgerEntry(ctx context.Context, e Execer, transaction *models.FinancialTransaction) error {
+	return adjustAccountBalance(ctx, e, transaction, -1)
+}
+
+// adjustAccountBalance adds sign times the transaction's debit or credit to its period row.
+func adjustAccountBalance(ctx context.Context, e Execer, transaction *models.FinancialTransaction, sign float64) error {
+	debit, credit := 0.0, 0.0
+	if transaction.Amount >= 0 {
+		debit = transaction.Amount
+	} else {
+		credit = -transaction.Amount
+	}
+	return ApplyLedgerTotals(ctx, e, transaction.AccountType, transaction.TransactionDate, sign*debit, sign*credit)
+}
+
+// ApplyLedgerTotals adds debit and credit totals to an account's balance for the period
+// containing date. Bulk imports use it to apply many transactions with one upsert per
+// account and month.
+//
+// Parameters:
+//   - e: The transaction the ledger change is written in.
+//   - accountType: The account the totals belong to.
+//   - date: Any date in the period.
+//   - debit, credit: The amounts to add; both are positive.
+//
+// Returns:
+//   - error: An error if the upsert fails.
+func ApplyLedgerTotals(ctx context.Context, e Execer, accountType string, date time.Time, debit, credit float64) error {
+	_, err := e.ExecContext(ctx,
+		`INSERT INTO account_period_balances (account_type, period, debit_total, credit_total)
+		 VALUES ($1, date_trunc('month', $2::date)::date, $3, $4)
+		 ON CONFLICT (account_type, period) DO UPDATE
+		 SET debit_total = account_period_balances.debit_total + EXCLUDED.debit_total,
+		     credit_total = account_period_balances.credit_total + EXCLUDED.credit_total`,
+		accountType, date, debit, credit,
+	)
+	if err != nil {
+		return err
+	}
+	return MarkRefreshed(ctx, e, models.ReportTrialBalance)
+}
@@ -0,0 +1,166 @@
+package report_handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// GetInventoryTurnover returns how many times the stock of each product or brand was sold
+// through over a period, and lists the products with stock that has not moved for the dead
+// stock days, with its value, so purchasing can clear it. Like profitability it is computed
+// from the source tables.
+//
+// HTTP Method: GET
+// URL Path: /inventory_turnover?period=YYYY|YYYY-MM&group_by=product|brand&dead_days=90
+// (period defaults to the current year and group_by to product; dead_days defaults to
+// REPORT_DEAD_STOCK_DAYS)
+//
+// Response:
+//   - Status Code: 200 (OK) with the InventoryTurnoverReport as JSON.
+//   - Status Code: 400 (Bad Request) if the period, group_by or dead_days is invalid, or the
+//     period has not started.
+//   - Status Code: 500 (Internal Server Error) if the stock activity cannot be read.
+func (h *ReportHandler) GetInventoryTurnover(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	groupBy := query.Get("group_by")
+	if groupBy == "" {
+		groupBy = models.TurnoverByProduct
+	}
+	if groupBy != models.TurnoverByProduct && groupBy != models.TurnoverByBrand {
+		respond.Error(w, http.StatusBadRequest, "Invalid group_by, expected product or brand")
+		return
+	}
+	deadDays := h.DeadStockDays
+	if value := query.Get("dead_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			respond.Error(w, http.StatusBadRequest, "Invalid dead_days, expected a positive number of days")
+			return
+		}
+		deadDays = days
+	}
+
+	now := time.Now()
+	period, from, to, err := turnoverPeriod(query, now)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	lines, err := h.Store.GetInventoryTurnover(r.Context(), from, to)
+	if err != nil {
+		httperr.Write(w, err, "Failed to get inventory turnover")
+		return
+	}
+	report := BuildInventoryTurnover(lines, groupBy, now, deadDays)
+	report.Period = period
+	report.From, report.To = from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02")
+	report.Company = h.company(r.Context())
+
+	respond.JSON(w, http.StatusOK, report)
+}
+
+// turnoverPeriod parses the period of the turnover report, a year or a month, and returns it
+// with its start and exclusive end. A period that is under way ends today.
+func turnoverPeriod(query url.Values, now time.Time) (string, time.Time, time.Time, error) {
+	period := query.Get("period")
+	if period == "" {
+		period = strconv.Itoa(now.Year())
+	}
+	var from, to time.Time
+	if month, err := time.Parse("2006-01", period); err == nil {
+		from, to = month, month.AddDate(0, 1, 0)
+	} else if year, err := time.Parse("2006", period); err == nil {
+		from, to = year, year.AddDate(1, 0, 0)
+	} else {
+		return period, from, to, errors.New("Invalid period, expected YYYY or YYYY-MM")
+	}
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	if !from.Before(tomorrow) {
+		return period, from, to, errors.New("period has not started")
+	}
+	if to.After(tomorrow) {
+		to = tomorrow
+	}
+	return period, from, to, nil
+}
+
+// BuildInventoryTurnover values the stock activity of each product at its unit cost, works
+// out its turnover and flags it as dead if it has stock that has not moved in deadDays,
+// then groups the lines by brand if asked. The turnover is the cost of the units issued
+// over the average value of the stock held.
+func BuildInventoryTurnover(lines []models.InventoryTurnoverLine, groupBy string, now time.Time, deadDays int) models.InventoryTurnoverReport {
+	report := models.InventoryTurnoverReport{GroupBy: groupBy, DeadStockDays: deadDays,
+		Lines: []models.InventoryTurnoverLine{}, DeadStock: []models.InventoryTurnoverLine{}}
+	brands := map[string]*models.InventoryTurnoverLine{}
+	var brandOrder []string
+	for _, line := range lines {
+		line.COGS = roundCents(float64(line.UnitsIssued) * line.UnitCost)
+		line.Value = roundCents(float64(line.OnHand) * line.UnitCost)
+		line.AverageOnHand = math.Round(line.AverageOnHand*100) / 100
+		line.AverageValue = roundCents(line.AverageOnHand * line.UnitCost)
+		line.Turnover = turnover(line.COGS, line.AverageValue)
+		if line.LastMovement != nil {
+			line.IdleDays = int(now.Sub(*line.LastMovement).Hours() / 24)
+		}
+		line.Dead = line.OnHand > 0 && (line.LastMovement == nil || line.IdleDays >= deadDays)
+		if line.Dead {
+			report.DeadStock = append(report.DeadStock, line)
+			report.DeadStockValue += line.Value
+		}
+		report.COGS += line.COGS
+		report.AverageValue += line.AverageValue
+
+		if groupBy != models.TurnoverByBrand {
+			report.Lines = append(report.Lines, line)
+			continue
+		}
+		brand := brands[line.Brand]
+		if brand == nil {
+			brand = &models.InventoryTurnoverLine{Name: line.Brand}
+			brands[line.Brand] = brand
+			brandOrder = append(brandOrder, line.Brand)
+		}
+		brand.UnitsIssued += line.UnitsIssued
+		brand.COGS += line.COGS
+		brand.OnHand += line.OnHand
+		brand.Value += line.Value
+		brand.AverageOnHand += line.AverageOnHand
+		brand.AverageValue += line.AverageValue
+		if line.LastMovement != nil && (brand.LastMovement == nil || line.LastMovement.After(*brand.LastMovement)) {
+			brand.LastMovement = line.LastMovement
+			brand.IdleDays = line.IdleDays
+		}
+		brand.Dead = brand.OnHand > 0 && (brand.LastMovement == nil || brand.IdleDays >= deadDays)
+	}
+	for _, name := range brandOrder {
+		brand := brands[name]
+		brand.COGS, brand.Value, brand.AverageValue = roundCents(brand.COGS), roundCents(brand.Value), roundCents(brand.AverageValue)
+		brand.Turnover = turnover(brand.COGS, brand.AverageValue)
+		report.Lines = append(report.Lines, *brand)
+	}
+
+	sort.SliceStable(report.Lines, func(i, j int) bool { return report.Lines[i].Turnover > report.Lines[j].Turnover })
+	sort.SliceStable(report.DeadStock, func(i, j int) bool { return report.DeadStock[i].Value > report.DeadStock[j].Value })
+	report.COGS, report.AverageValue = roundCents(report.COGS), roundCents(report.AverageValue)
+	report.Turnover = turnover(report.COGS, report.AverageValue)
+	report.DeadStockValue = roundCents(report.DeadStockValue)
+	return report
+}
+
+// turnover is cogs over the average value of stock held, to two decimal places, or 0 if no
+// stock was held.
+func turnover(cogs, averageValue float64) float64 {
+	if averageValue <= 0 {
+		return 0
+	}
+	return math.Round(cogs/averageValue*100) / 100
+}
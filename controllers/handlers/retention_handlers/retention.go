@@ -0,0 +1,152 @@
+// Package retention_handlers provides the admin API for data retention: the policy of each
+// data class, starting a purge and the report of what was purged and when.
+package retention_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Retention manages policies and purges. It is satisfied by *retention.Service.
+type Retention interface {
+	Policies(ctx context.Context) ([]models.RetentionPolicy, error)
+	SetPolicy(ctx context.Context, dataClass string, days *int, actor string) error
+	Start(ctx context.Context, actor string) (*models.Job, error)
+	Purges(ctx context.Context, from, to time.Time) ([]models.RetentionPurge, error)
+}
+
+// RetentionHandler provides HTTP handlers for data retention.
+type RetentionHandler struct {
+	Retention Retention
+	Now       func() time.Time
+}
+
+// RegisterRoutes maps retention routes to their respective handler functions.
+// The router is expected to be protected with middleware.JWTAuth and limited to admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - retention: The retention service.
+func RegisterRoutes(router *mux.Router, retention Retention) {
+	handler := &RetentionHandler{Retention: retention, Now: time.Now}
+
+	router.HandleFunc("/policies", handler.ListPolicies).Methods("GET")
+	router.HandleFunc("/policies/{class}", handler.UpdatePolicy).Methods("PUT")
+	router.HandleFunc("/purges", handler.StartPurge).Methods("POST")
+	router.HandleFunc("/purges", handler.ListPurges).Methods("GET")
+}
+
+// ListPolicies returns the retention policy of every data class.
+//
+// HTTP Method: GET
+// URL Path: /retention/policies
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of RetentionPolicies in JSON. A null retention_days
+//     keeps the records forever.
+//   - Status Code: 500 (Internal Server Error) if the policies cannot be read.
+func (h *RetentionHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.Retention.Policies(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load retention policies")
+		return
+	}
+	respond.JSON(w, http.StatusOK, policies)
+}
+
+// UpdatePolicy changes how long a data class is kept.
+//
+// HTTP Method: PUT
+// URL Path: /retention/policies/{class}
+//
+// Request Body:
+//   - JSON object with "retention_days": a number of days, or null to keep the records forever.
+//
+// Response:
+//   - Status Code: 200 (OK) with the updated list of RetentionPolicies in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the data class is unknown.
+//   - Status Code: 422 (Unprocessable Entity) if the period is not positive, or is below
+//     the legal minimum for financial data.
+//   - Status Code: 500 (Internal Server Error) if the policy cannot be saved.
+func (h *RetentionHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RetentionDays *int `json:"retention_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Retention.SetPolicy(r.Context(), mux.Vars(r)["class"], body.RetentionDays, actor); err != nil {
+		httperr.Write(w, err, "Failed to update retention policy")
+		return
+	}
+	h.ListPolicies(w, r)
+}
+
+// StartPurge starts purging expired records in the background, as the nightly run does.
+//
+// HTTP Method: POST
+// URL Path: /retention/purges
+//
+// Response:
+//   - Status Code: 202 (Accepted) with the queued Job in JSON and its URL in the Location
+//     header. The job's result lists what was purged from each class.
+//   - Status Code: 500 (Internal Server Error) if the job cannot be created.
+func (h *RetentionHandler) StartPurge(w http.ResponseWriter, r *http.Request) {
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	job, err := h.Retention.Start(r.Context(), actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to start purge")
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	respond.JSON(w, http.StatusAccepted, job)
+}
+
+// ListPurges reports what was purged from each data class over a date range, newest first.
+//
+// HTTP Method: GET
+// URL Path: /retention/purges?from=YYYY-MM-DD&to=YYYY-MM-DD
+// (from defaults to 30 days ago and to to today)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of RetentionPurges in JSON.
+//   - Status Code: 400 (Bad Request) if a date is invalid or from is after to.
+//   - Status Code: 500 (Internal Server Error) if the purges cannot be read.
+func (h *RetentionHandler) ListPurges(w http.ResponseWriter, r *http.Request) {
+	today := h.Now().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -30), today
+	for name, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := r.URL.Query().Get(name); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				respond.Error(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s date", name))
+				return
+			}
+			*date = parsed
+		}
+	}
+	if from.After(to) {
+		respond.Error(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	purges, err := h.Retention.Purges(r.Context(), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load purges")
+		return
+	}
+	respond.JSON(w, http.StatusOK, purges)
+}
@@ -0,0 +1,90 @@
+package retention_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRetention keeps policies in memory and records the purge range asked for.
+type fakeRetention struct {
+	days     map[string]*int
+	from, to time.Time
+}
+
+func (f *fakeRetention) Policies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	return []models.RetentionPolicy{{DataClass: "notifications", RetentionDays: f.days["notifications"]}}, nil
+}
+
+func (f *fakeRetention) SetPolicy(ctx context.Context, dataClass string, days *int, actor string) error {
+	if dataClass != "notifications" {
+		return models.NotFound("unknown data class %s", dataClass)
+	}
+	f.days[dataClass] = days
+	return nil
+}
+
+func (f *fakeRetention) Start(ctx context.Context, actor string) (*models.Job, error) {
+	return &models.Job{ID: 9, Kind: "retention_purge", Status: models.JobQueued, CreatedBy: actor}, nil
+}
+
+func (f *fakeRetention) Purges(ctx context.Context, from, to time.Time) ([]models.RetentionPurge, error) {
+	f.from, f.to = from, to
+	return []models.RetentionPurge{}, nil
+}
+
+func setupRouter() (*mux.Router, *fakeRetention) {
+	retention := &fakeRetention{days: map[string]*int{}}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/retention").Subrouter(), retention)
+	return router, retention
+}
+
+func TestUpdatePolicy(t *testing.T) {
+	router, retention := setupRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("PUT", "/retention/policies/notifications", strings.NewReader(`{"retention_days": 60}`)))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var policies []models.RetentionPolicy
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&policies))
+	assert.Equal(t, 60, *policies[0].RetentionDays)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("PUT", "/retention/policies/notifications", strings.NewReader(`{"retention_days": null}`)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, retention.days["notifications"])
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("PUT", "/retention/policies/invoices", strings.NewReader(`{"retention_days": 60}`)))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPurges(t *testing.T) {
+	router, retention := setupRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/retention/purges", nil))
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "/jobs/9", rr.Header().Get("Location"))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/retention/purges?from=2026-02-01&to=2026-02-28", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), retention.from)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), retention.to)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/retention/purges?from=2026-03-01&to=2026-02-01", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
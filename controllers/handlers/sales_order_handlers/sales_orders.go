@@ -0,0 +1,213 @@
+// Package sales_order_handlers provides HTTP handlers and the database store for sales
+// orders and their draft, confirmed, fulfilled and cancelled workflow.
+package sales_order_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/salesorders"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SalesOrderHandler provides HTTP handlers for sales orders. The workflow rules live in the
+// salesorders service; the handlers only translate HTTP.
+type SalesOrderHandler struct {
+	Service *salesorders.Service
+}
+
+// SalesOrderRequest is the request body for creating a sales order. The status, totals and
+// who did what when are set by the server.
+type SalesOrderRequest struct {
+	CustomerID int                     `json:"customer_id"`
+	OrderDate  string                  `json:"order_date"` // YYYY-MM-DD; today by default
+	Note       string                  `json:"note"`
+	Lines      []SalesOrderLineRequest `json:"lines"`
+}
+
+// SalesOrderLineRequest is one product of a SalesOrderRequest.
+type SalesOrderLineRequest struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"` // The product's price if omitted
+}
+
+// SalesOrder returns the sales order described by the request.
+func (req SalesOrderRequest) SalesOrder() (models.SalesOrder, error) {
+	order := models.SalesOrder{CustomerID: req.CustomerID, Note: req.Note}
+	if req.OrderDate != "" {
+		date, err := time.Parse("2006-01-02", req.OrderDate)
+		if err != nil {
+			return order, models.Invalid("order_date must be a date formatted as YYYY-MM-DD")
+		}
+		order.OrderDate = date
+	}
+	order.Lines = make([]models.SalesOrderLine, len(req.Lines))
+	for i, line := range req.Lines {
+		order.Lines[i] = models.SalesOrderLine{ProductID: line.ProductID, Quantity: line.Quantity, UnitPrice: line.UnitPrice}
+	}
+	return order, nil
+}
+
+// RegisterRoutes maps sales order routes to their respective handler functions. The router
+// is expected to be protected with middleware.JWTAuth and limited to sales staff and admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The sales order service.
+func RegisterRoutes(router *mux.Router, service *salesorders.Service) {
+	handler := &SalesOrderHandler{Service: service}
+
+	router.HandleFunc("", handler.CreateSalesOrder).Methods("POST")
+	router.HandleFunc("", handler.ListSalesOrders).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetSalesOrder).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/confirm", handler.ConfirmSalesOrder).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/fulfill", handler.FulfillSalesOrder).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/cancel", handler.CancelSalesOrder).Methods("POST")
+}
+
+// CreateSalesOrder records a draft sales order. No stock is reserved until it is confirmed.
+//
+// HTTP Method: POST
+// URL Path: /sales_orders
+//
+// Request Body:
+//   - JSON with customer_id, an optional order_date and note, and lines of product_id,
+//     quantity and an optional unit_price (see SalesOrderRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the draft order in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the order is incomplete or names a customer
+//     or product that does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be recorded.
+func (h *SalesOrderHandler) CreateSalesOrder(w http.ResponseWriter, r *http.Request) {
+	var req SalesOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	order, err := req.SalesOrder()
+	if err != nil {
+		httperr.Write(w, err, "Invalid sales order")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Create(r.Context(), &order, actor); err != nil {
+		httperr.Write(w, err, "Failed to create sales order")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, order)
+}
+
+// ListSalesOrders lists sales orders, newest first.
+//
+// HTTP Method: GET
+// URL Path: /sales_orders?status=confirmed&customer_id=5
+// (both are optional; status is draft, confirmed, fulfilled or cancelled)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of sales orders in JSON.
+//   - Status Code: 400 (Bad Request) if customer_id is not a number.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the orders cannot be loaded.
+func (h *SalesOrderHandler) ListSalesOrders(w http.ResponseWriter, r *http.Request) {
+	var customerID int
+	if value := r.URL.Query().Get("customer_id"); value != "" {
+		var err error
+		if customerID, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid customer_id")
+			return
+		}
+	}
+	orders, err := h.Service.List(r.Context(), r.URL.Query().Get("status"), customerID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load sales orders")
+		return
+	}
+	respond.JSON(w, http.StatusOK, orders)
+}
+
+// GetSalesOrder returns a sales order with its lines and total.
+//
+// HTTP Method: GET
+// URL Path: /sales_orders/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the order in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be loaded.
+func (h *SalesOrderHandler) GetSalesOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	order, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load sales order")
+		return
+	}
+	respond.JSON(w, http.StatusOK, order)
+}
+
+// ConfirmSalesOrder confirms a draft order once every product is in stock in the quantity
+// ordered, and reserves the stock.
+//
+// HTTP Method: POST
+// URL Path: /sales_orders/{id}/confirm
+//
+// Response:
+//   - Status Code: 200 (OK) with the confirmed order in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 409 (Conflict) if the order is not a draft or a product is short of stock.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be confirmed.
+func (h *SalesOrderHandler) ConfirmSalesOrder(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Confirm, "Failed to confirm sales order")
+}
+
+// FulfillSalesOrder ships a confirmed order: its stock is taken from the warehouses.
+//
+// HTTP Method: POST
+// URL Path: /sales_orders/{id}/fulfill
+//
+// Response:
+//   - Status Code: 200 (OK) with the fulfilled order in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 409 (Conflict) if the order is not confirmed or a product is no longer in
+//     stock in the quantity ordered.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be fulfilled.
+func (h *SalesOrderHandler) FulfillSalesOrder(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Fulfill, "Failed to fulfill sales order")
+}
+
+// CancelSalesOrder cancels an order that has not been fulfilled and releases its stock.
+//
+// HTTP Method: POST
+// URL Path: /sales_orders/{id}/cancel
+//
+// Response:
+//   - Status Code: 200 (OK) with the cancelled order in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 409 (Conflict) if the order is fulfilled or already cancelled.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be cancelled.
+func (h *SalesOrderHandler) CancelSalesOrder(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Cancel, "Failed to cancel sales order")
+}
+
+// change applies a status change to the order in the URL and writes the result.
+func (h *SalesOrderHandler) change(w http.ResponseWriter, r *http.Request,
+	apply func(ctx context.Context, id int, actor string) (*models.SalesOrder, error), failure string) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	order, err := apply(r.Context(), id, actor)
+	if err != nil {
+		httperr.Write(w, err, failure)
+		return
+	}
+	respond.JSON(w, http.StatusOK, order)
+}
@@ -0,0 +1,442 @@
+package sales_order_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/controllers/bundles"
+	"erp/controllers/events"
+	"erp/controllers/handlers/bundle_handlers"
+	"erp/controllers/salesorders"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBSalesOrderStore implements models.SalesOrderStore using a SQL database. Every status
+// change locks the order first, so two users cannot confirm or fulfill it twice. Bundle
+// lines reserve and take the stock of their components.
+type DBSalesOrderStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// orderColumns are the columns of sales_orders read by scanOrder.
+const orderColumns = `id, COALESCE(customer_id, 0), order_date, status, note, created_by, created_at, confirmed_by,
+	confirmed_at, fulfilled_by, fulfilled_at, cancelled_by, cancelled_at, standing_order_id, delivery_date`
+
+// orderLines selects the lines of every order. Orders taken before sales orders had lines
+// (such as e-commerce orders) hold one product on the order itself, priced at the
+// product's current price.
+const orderLines = `SELECT sales_order_id, product_id, quantity, unit_price FROM sales_order_lines
+	UNION ALL
+	SELECT so.id, so.product_id, so.quantity, p.price FROM sales_orders so JOIN products p ON p.id = so.product_id
+	WHERE so.quantity IS NOT NULL`
+
+// orderQueryer is satisfied by both *sql.DB and *sql.Tx.
+type orderQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// CreateSalesOrder records a draft order with its lines. Lines without a unit price take
+// the product's price, or the bundle's price for a bundle.
+//
+// Parameters:
+//   - order: A validated order with one line per product; its ID and line prices are set.
+//
+// Returns:
+//   - error: A validation error if the customer or a product does not exist, a conflict if
+//     the order is a standing order's delivery that already has one, or the query error.
+func (s *DBSalesOrderStore) CreateSalesOrder(ctx context.Context, order *models.SalesOrder) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int, len(order.Lines))
+	for i, line := range order.Lines {
+		ids[i] = line.ProductID
+	}
+	found, err := bundle_handlers.LoadBundles(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	productIDs := make(pq.Int64Array, len(order.Lines))
+	quantities := make(pq.Int64Array, len(order.Lines))
+	prices := make(pq.Float64Array, len(order.Lines))
+	for i, line := range order.Lines {
+		productIDs[i], quantities[i], prices[i] = int64(line.ProductID), int64(line.Quantity), line.UnitPrice
+		if bundle, ok := found[line.ProductID]; ok && line.UnitPrice <= 0 {
+			prices[i] = bundle.Price
+		}
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO sales_orders (customer_id, order_date, status, note, created_by, created_at, standing_order_id, delivery_date)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		order.CustomerID, order.OrderDate, order.Status, order.Note, order.CreatedBy, order.CreatedAt,
+		order.StandingOrderID, order.DeliveryDate,
+	).Scan(&order.ID)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("customer %d does not exist", order.CustomerID)
+	} else if models.IsUniqueViolation(err) {
+		return models.Conflict("the delivery of standing order %d on %s already has a sales order",
+			*order.StandingOrderID, order.DeliveryDate.Format("2006-01-02"))
+	} else if err != nil {
+		return fmt.Errorf("failed to record sales order: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`INSERT INTO sales_order_lines (sales_order_id, product_id, quantity, unit_price)
+		 SELECT $1, l.product_id, l.quantity, CASE WHEN l.unit_price > 0 THEN l.unit_price ELSE p.price END
+		 FROM unnest($2::int[], $3::int[], $4::numeric[]) AS l(product_id, quantity, unit_price)
+		 JOIN products p ON p.id = l.product_id
+		 RETURNING product_id, unit_price`,
+		order.ID, productIDs, quantities, prices,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record sales order lines: %w", err)
+	}
+	priced := map[int]float64{}
+	for rows.Next() {
+		var productID int
+		var price float64
+		if err := rows.Scan(&productID, &price); err != nil {
+			rows.Close()
+			return err
+		}
+		priced[productID] = price
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range order.Lines {
+		price, ok := priced[order.Lines[i].ProductID]
+		if !ok {
+			return models.Invalid("product %d does not exist", order.Lines[i].ProductID)
+		}
+		order.Lines[i].UnitPrice = price
+	}
+	return tx.Commit()
+}
+
+// GetSalesOrder retrieves an order with its lines.
+//
+// Parameters:
+//   - id: The ID of the order.
+//
+// Returns:
+//   - *models.SalesOrder: The order.
+//   - error: models.ErrNotFound if the order does not exist, or the query error.
+func (s *DBSalesOrderStore) GetSalesOrder(ctx context.Context, id int) (*models.SalesOrder, error) {
+	return getOrder(ctx, s.DB, id, "")
+}
+
+// ListSalesOrders retrieves the orders in a status, or all of them if status is empty,
+// newest first.
+//
+// Parameters:
+//   - status: One of the models.SalesOrder* statuses, or empty.
+//   - customerID: The customer, or 0 for every customer.
+//
+// Returns:
+//   - []models.SalesOrder: The orders with their lines.
+//   - error: The query error.
+func (s *DBSalesOrderStore) ListSalesOrders(ctx context.Context, status string, customerID int) ([]models.SalesOrder, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+orderColumns+` FROM sales_orders
+		 WHERE ($1 = '' OR status = $1) AND ($2 = 0 OR customer_id = $2) ORDER BY id DESC`, status, customerID)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.SalesOrder{}
+	index := map[int]int{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[order.ID] = len(orders)
+		orders = append(orders, *order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	ids := make(pq.Int64Array, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, int64(order.ID))
+	}
+	rows, err = s.DB.QueryContext(ctx,
+		`SELECT l.sales_order_id, l.product_id, l.quantity, l.unit_price FROM (`+orderLines+`) l
+		 WHERE l.sales_order_id = ANY($1) ORDER BY l.sales_order_id, l.product_id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orderID int
+		var line models.SalesOrderLine
+		if err := rows.Scan(&orderID, &line.ProductID, &line.Quantity, &line.UnitPrice); err != nil {
+			return nil, err
+		}
+		order := &orders[index[orderID]]
+		order.Lines = append(order.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		salesorders.Total(&orders[i])
+	}
+	return orders, nil
+}
+
+// ConfirmSalesOrder confirms a draft order and reserves the stock of every line, or of the
+// components of a bundle line, so it is no longer offered to other orders.
+//
+// Returns:
+//   - *models.SalesOrder: The confirmed order.
+//   - error: models.ErrNotFound, a conflict if the order is not a draft, or the query error.
+func (s *DBSalesOrderStore) ConfirmSalesOrder(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, order *models.SalesOrder) error {
+		demand, err := stockDemand(ctx, tx, order)
+		if err != nil {
+			return err
+		}
+		productIDs := make(pq.Int64Array, 0, len(demand))
+		quantities := make(pq.Int64Array, 0, len(demand))
+		for _, productID := range bundles.ProductIDs(demand) {
+			productIDs, quantities = append(productIDs, int64(productID)), append(quantities, int64(demand[productID]))
+		}
+		now := time.Now()
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO stock_reservations (product_id, sales_order_id, quantity, status, created_at)
+			 SELECT r.product_id, $1, r.quantity, 'active', $2 FROM unnest($3::int[], $4::int[]) AS r(product_id, quantity)`,
+			id, now, productIDs, quantities)
+		if err != nil {
+			return fmt.Errorf("failed to reserve stock: %w", err)
+		}
+		order.Status, order.ConfirmedBy, order.ConfirmedAt = models.SalesOrderConfirmed, actor, &now
+		_, err = tx.ExecContext(ctx, `UPDATE sales_orders SET status = $1, confirmed_by = $2, confirmed_at = $3 WHERE id = $4`,
+			order.Status, actor, now, id)
+		return err
+	}, models.SalesOrderDraft)
+}
+
+// FulfillSalesOrder takes the stock of every line, or of the components of a bundle line,
+// from the warehouses holding the most first, and marks the order's reservations fulfilled. A StockMoved event is written to the
+// outbox.
+//
+// Returns:
+//   - *models.SalesOrder: The fulfilled order.
+//   - error: models.ErrNotFound, a conflict if the order is not confirmed or a product is
+//     no longer in stock in the quantity ordered, or the query error.
+func (s *DBSalesOrderStore) FulfillSalesOrder(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, order *models.SalesOrder) error {
+		demand, err := stockDemand(ctx, tx, order)
+		if err != nil {
+			return err
+		}
+		for _, productID := range bundles.ProductIDs(demand) {
+			if err := takeStock(ctx, tx, productID, demand[productID]); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE stock_reservations SET status = 'fulfilled' WHERE sales_order_id = $1 AND status = 'active'`,
+			id); err != nil {
+			return err
+		}
+		now := time.Now()
+		order.Status, order.FulfilledBy, order.FulfilledAt = models.SalesOrderFulfilled, actor, &now
+		_, err = tx.ExecContext(ctx, `UPDATE sales_orders SET status = $1, fulfilled_by = $2, fulfilled_at = $3 WHERE id = $4`,
+			order.Status, actor, now, id)
+		if err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, tx, events.StockMoved, "sales_order", id, order)
+	}, models.SalesOrderConfirmed)
+}
+
+// CancelSalesOrder cancels an order that has not been fulfilled and releases its
+// reservations.
+//
+// Returns:
+//   - *models.SalesOrder: The cancelled order.
+//   - error: models.ErrNotFound, a conflict if the order is fulfilled or already cancelled,
+//     or the query error.
+func (s *DBSalesOrderStore) CancelSalesOrder(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, order *models.SalesOrder) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE stock_reservations SET status = 'released' WHERE sales_order_id = $1 AND status = 'active'`,
+			id); err != nil {
+			return err
+		}
+		now := time.Now()
+		order.Status, order.CancelledBy, order.CancelledAt = models.SalesOrderCancelled, actor, &now
+		_, err := tx.ExecContext(ctx, `UPDATE sales_orders SET status = $1, cancelled_by = $2, cancelled_at = $3 WHERE id = $4`,
+			order.Status, actor, now, id)
+		return err
+	}, models.SalesOrderDraft, models.SalesOrderConfirmed)
+}
+
+// stockDemand returns the quantities of the products holding stock that an order's lines
+// need, keyed by product: bundle lines are expanded into their components.
+func stockDemand(ctx context.Context, tx *sql.Tx, order *models.SalesOrder) (map[int]int, error) {
+	ordered := make(map[int]int, len(order.Lines))
+	for _, line := range order.Lines {
+		ordered[line.ProductID] += line.Quantity
+	}
+	found, err := bundle_handlers.LoadBundles(ctx, tx, bundles.ProductIDs(ordered))
+	if err != nil {
+		return nil, err
+	}
+	return bundles.Expand(ordered, found), nil
+}
+
+// takeStock takes a quantity of a product from its stock entries, the largest first. The
+// entries are locked so concurrent fulfilments cannot take the same stock.
+func takeStock(ctx context.Context, tx *sql.Tx, productID, quantity int) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, quantity FROM stock WHERE product_id = $1 AND quantity > 0 ORDER BY quantity DESC, id FOR UPDATE`,
+		productID)
+	if err != nil {
+		return fmt.Errorf("failed to take stock: %w", err)
+	}
+	type entry struct{ id, quantity int }
+	var entries []entry
+	available := 0
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+		available += e.quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if available < quantity {
+		return models.Conflict("product %d has %d in stock but %d are ordered", productID, available, quantity)
+	}
+
+	for _, e := range entries {
+		if quantity == 0 {
+			break
+		}
+		taken := min(e.quantity, quantity)
+		if _, err := tx.ExecContext(ctx, `UPDATE stock SET quantity = quantity - $1 WHERE id = $2`, taken, e.id); err != nil {
+			return fmt.Errorf("failed to take stock: %w", err)
+		}
+		quantity -= taken
+	}
+	return nil
+}
+
+// transition locks an order, checks that it is in one of the statuses in from and applies
+// change in the same transaction.
+func (s *DBSalesOrderStore) transition(ctx context.Context, id int, change func(tx *sql.Tx, order *models.SalesOrder) error, from ...string) (*models.SalesOrder, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := getOrder(ctx, tx, id, " FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || order.Status == status
+	}
+	if !allowed {
+		return nil, models.Conflict("sales order %d is %s", id, order.Status)
+	}
+	if err := change(tx, order); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// getOrder reads an order and its lines, adding lock to the query of the order.
+func getOrder(ctx context.Context, q orderQueryer, id int, lock string) (*models.SalesOrder, error) {
+	order, err := scanOrder(q.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM sales_orders WHERE id = $1`+lock, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("sales order %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT l.product_id, l.quantity, l.unit_price FROM (`+orderLines+`) l
+		 WHERE l.sales_order_id = $1 ORDER BY l.product_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line models.SalesOrderLine
+		if err := rows.Scan(&line.ProductID, &line.Quantity, &line.UnitPrice); err != nil {
+			return nil, err
+		}
+		order.Lines = append(order.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	salesorders.Total(order)
+	return order, nil
+}
+
+// orderScanner is satisfied by both *sql.Row and *sql.Rows.
+type orderScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanOrder reads a row of orderColumns.
+func scanOrder(row orderScanner) (*models.SalesOrder, error) {
+	var o models.SalesOrder
+	var createdBy, confirmedBy, fulfilledBy, cancelledBy sql.NullString
+	var createdAt, confirmedAt, fulfilledAt, cancelledAt, deliveryDate sql.NullTime
+	var standingOrderID sql.NullInt64
+	err := row.Scan(&o.ID, &o.CustomerID, &o.OrderDate, &o.Status, &o.Note, &createdBy, &createdAt, &confirmedBy,
+		&confirmedAt, &fulfilledBy, &fulfilledAt, &cancelledBy, &cancelledAt, &standingOrderID, &deliveryDate)
+	if err != nil {
+		return nil, err
+	}
+	if standingOrderID.Valid {
+		id := int(standingOrderID.Int64)
+		o.StandingOrderID = &id
+	}
+	o.DeliveryDate = timePtr(deliveryDate)
+	o.CreatedBy, o.CreatedAt = createdBy.String, timePtr(createdAt)
+	o.ConfirmedBy, o.ConfirmedAt = confirmedBy.String, timePtr(confirmedAt)
+	o.FulfilledBy, o.FulfilledAt = fulfilledBy.String, timePtr(fulfilledAt)
+	o.CancelledBy, o.CancelledAt = cancelledBy.String, timePtr(cancelledAt)
+	o.Lines = []models.SalesOrderLine{}
+	return &o, nil
+}
+
+// timePtr returns the time, or nil if it is NULL.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,135 @@
+// Package sandbox_handlers provides the admin API of sandbox deployments: saving the seed
+// snapshot, resetting the data to it and reading the captured emails and webhooks.
+package sandbox_handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/backup"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/sandbox"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// captureLimit is the default number of captured messages listed.
+const captureLimit = 100
+
+// Sandbox saves seeds, resets data and lists captures. It is satisfied by *sandbox.Service.
+type Sandbox interface {
+	Status(ctx context.Context) (*sandbox.Status, error)
+	SaveSeed(ctx context.Context, actor string) (*backup.Result, error)
+	Reset(ctx context.Context, actor string) (map[string]int64, error)
+	Captures(ctx context.Context, limit int) ([]models.SandboxCapture, error)
+}
+
+// SandboxHandler provides HTTP handlers for the sandbox.
+type SandboxHandler struct {
+	Sandbox Sandbox
+}
+
+// RegisterRoutes maps sandbox routes to their respective handler functions. They are only
+// registered on sandbox deployments; the router is expected to be protected with
+// middleware.JWTAuth and limited to admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - sandbox: The sandbox service.
+func RegisterRoutes(router *mux.Router, sandbox Sandbox) {
+	handler := &SandboxHandler{Sandbox: sandbox}
+
+	router.HandleFunc("", handler.GetStatus).Methods("GET")
+	router.HandleFunc("/seed", handler.SaveSeed).Methods("POST")
+	router.HandleFunc("/reset", handler.Reset).Methods("POST")
+	router.HandleFunc("/captures", handler.ListCaptures).Methods("GET")
+}
+
+// GetStatus returns the sandbox's name and the seed snapshot resets restore.
+//
+// HTTP Method: GET
+// URL Path: /admin/sandbox
+//
+// Response:
+//   - Status Code: 200 (OK) with the status in JSON. The environment is empty if the
+//     database is not marked as a sandbox, and the seed is missing until one is saved.
+//   - Status Code: 500 (Internal Server Error) if the status cannot be read.
+func (h *SandboxHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.Sandbox.Status(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to read sandbox status")
+		return
+	}
+	respond.JSON(w, http.StatusOK, status)
+}
+
+// SaveSeed snapshots the current data as the state resets return to.
+//
+// HTTP Method: POST
+// URL Path: /admin/sandbox/seed
+//
+// Response:
+//   - Status Code: 201 (Created) with the snapshot's file and row counts in JSON.
+//   - Status Code: 409 (Conflict) if the database is not marked as a sandbox.
+//   - Status Code: 500 (Internal Server Error) if the snapshot cannot be taken.
+func (h *SandboxHandler) SaveSeed(w http.ResponseWriter, r *http.Request) {
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	result, err := h.Sandbox.SaveSeed(r.Context(), actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to save seed snapshot")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, result)
+}
+
+// Reset replaces all data, including users, with the seed snapshot.
+//
+// HTTP Method: POST
+// URL Path: /admin/sandbox/reset
+//
+// Response:
+//   - Status Code: 200 (OK) with the rows restored per table in JSON.
+//   - Status Code: 404 (Not Found) if no seed snapshot has been saved.
+//   - Status Code: 409 (Conflict) if the database is not marked as a sandbox.
+//   - Status Code: 500 (Internal Server Error) if the snapshot is damaged or cannot be
+//     restored; the data is left unchanged.
+func (h *SandboxHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	counts, err := h.Sandbox.Reset(r.Context(), actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to reset sandbox")
+		return
+	}
+	respond.JSON(w, http.StatusOK, map[string]interface{}{"tables": counts})
+}
+
+// ListCaptures returns the emails and webhooks captured instead of sent, newest first.
+//
+// HTTP Method: GET
+// URL Path: /admin/sandbox/captures?limit=100
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of captures in JSON.
+//   - Status Code: 400 (Bad Request) if the limit is not a positive number.
+//   - Status Code: 500 (Internal Server Error) if the captures cannot be read.
+func (h *SandboxHandler) ListCaptures(w http.ResponseWriter, r *http.Request) {
+	limit := captureLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			respond.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+	captures, err := h.Sandbox.Captures(r.Context(), limit)
+	if err != nil {
+		httperr.Write(w, err, "Failed to list captures")
+		return
+	}
+	respond.JSON(w, http.StatusOK, captures)
+}
@@ -0,0 +1,62 @@
+package sandbox_handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/controllers/backup"
+	"erp/controllers/sandbox"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSandbox is an unmarked database with no seed.
+type mockSandbox struct {
+	limit int
+}
+
+func (m *mockSandbox) Status(ctx context.Context) (*sandbox.Status, error) {
+	return &sandbox.Status{}, nil
+}
+
+func (m *mockSandbox) SaveSeed(ctx context.Context, actor string) (*backup.Result, error) {
+	return nil, sandbox.ErrNotSandbox
+}
+
+func (m *mockSandbox) Reset(ctx context.Context, actor string) (map[string]int64, error) {
+	return nil, sandbox.ErrNoSeed
+}
+
+func (m *mockSandbox) Captures(ctx context.Context, limit int) ([]models.SandboxCapture, error) {
+	m.limit = limit
+	return []models.SandboxCapture{}, nil
+}
+
+func TestSandboxRoutes(t *testing.T) {
+	mock := &mockSandbox{}
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/admin/sandbox").Subrouter(), mock)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+
+	rr := do("GET", "/admin/sandbox")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusConflict, do("POST", "/admin/sandbox/seed").Code)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/admin/sandbox/reset").Code)
+
+	assert.Equal(t, http.StatusOK, do("GET", "/admin/sandbox/captures").Code)
+	assert.Equal(t, captureLimit, mock.limit)
+	assert.Equal(t, http.StatusOK, do("GET", "/admin/sandbox/captures?limit=5").Code)
+	assert.Equal(t, 5, mock.limit)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/admin/sandbox/captures?limit=0").Code)
+}
@@ -0,0 +1,107 @@
+package settings_handlers
+
+import (
+	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/settings"
+	"erp/models"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// SettingsHandler provides the admin HTTP API for organization-wide settings.
+type SettingsHandler struct {
+	Settings *settings.Service
+}
+
+// RegisterRoutes maps settings routes to their respective handler functions.
+// The router is expected to be restricted to administrators.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The settings service shared with the rest of the application.
+func RegisterRoutes(router *mux.Router, service *settings.Service) {
+	handler := &SettingsHandler{Settings: service}
+
+	router.HandleFunc("", handler.GetSettings).Methods("GET")
+	router.HandleFunc("", handler.UpdateSettings).Methods("PUT")
+	router.HandleFunc("/definitions", handler.GetDefinitions).Methods("GET")
+}
+
+// GetSettings returns every setting, with defaults for settings that were never saved.
+//
+// HTTP Method: GET
+// URL Path: /
+//
+// Response:
+//   - Status Code: 200 (OK) with the values keyed by setting key in JSON.
+//   - Status Code: 500 (Internal Server Error) if the settings cannot be loaded.
+func (h *SettingsHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	values, err := h.Settings.All(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load settings")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, values)
+}
+
+// UpdateSettings saves the settings in the request body. Settings not in the body are left
+// unchanged; if any value is invalid nothing is saved.
+//
+// HTTP Method: PUT
+// URL Path: /
+//
+// Request Body:
+//   - JSON object of values keyed by setting key, e.g. {"company.name": "Acme Ltd"}.
+//
+// Response:
+//   - Status Code: 200 (OK) with all settings in JSON.
+//   - Status Code: 400 (Bad Request) if the body is empty or invalid.
+//   - Status Code: 422 (Unprocessable Entity) with the reason for each invalid key in the
+//     error details if a value is rejected.
+//   - Status Code: 500 (Internal Server Error) if the settings cannot be saved.
+func (h *SettingsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var values map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&values); err != nil || len(values) == 0 {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+
+	err := h.Settings.Update(r.Context(), values, actor)
+	var invalid *models.InvalidSettingsError
+	if errors.As(err, &invalid) {
+		respond.ErrorDetails(w, http.StatusUnprocessableEntity, "Some settings are invalid", invalid.Fields)
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to save settings")
+		return
+	}
+
+	h.GetSettings(w, r)
+}
+
+// GetDefinitions lists the known settings with their types and defaults, so the admin
+// screen can render a form.
+//
+// HTTP Method: GET
+// URL Path: /definitions
+//
+// Response:
+//   - Status Code: 200 (OK) with the setting definitions in JSON, sorted by key.
+func (h *SettingsHandler) GetDefinitions(w http.ResponseWriter, r *http.Request) {
+	definitions := make([]models.SettingDefinition, 0, len(models.SettingDefinitions))
+	for _, definition := range models.SettingDefinitions {
+		definitions = append(definitions, definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Key < definitions[j].Key })
+
+	respond.JSON(w, http.StatusOK, definitions)
+}
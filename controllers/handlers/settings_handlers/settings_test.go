@@ -0,0 +1,54 @@
+package settings_handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/controllers/settings"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateSettings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/settings").Subrouter(), settings.NewService(&DBSettingStore{DB: db}, time.Minute))
+
+	// An invalid value is rejected before anything is written.
+	body, _ := json.Marshal(map[string]string{models.SettingDefaultCurrency: "US"})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("PUT", "/settings", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), models.SettingDefaultCurrency)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO settings").
+		WithArgs(models.SettingInvoiceFooter, []byte(`"Thank you for your business"`), sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT key, value FROM settings").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "value"}).
+			AddRow(models.SettingInvoiceFooter, []byte(`"Thank you for your business"`)))
+
+	body, _ = json.Marshal(map[string]string{models.SettingInvoiceFooter: "  Thank you for your business "})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("PUT", "/settings", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var values map[string]json.RawMessage
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&values))
+	assert.JSONEq(t, `"Thank you for your business"`, string(values[models.SettingInvoiceFooter]))
+	assert.JSONEq(t, `"My Company"`, string(values[models.SettingCompanyName]))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
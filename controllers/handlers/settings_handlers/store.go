@@ -0,0 +1,85 @@
+// Package settings_handlers provides SQL-backed storage and the admin HTTP API for the
+// organization-wide settings.
+package settings_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/controllers/audit"
+	"erp/models"
+	"time"
+)
+
+// DBSettingStore provides SQL-backed methods for the settings table.
+type DBSettingStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// GetSettings returns every stored setting value.
+//
+// Returns:
+//   - map[string]json.RawMessage: The values keyed by setting key.
+//   - error: An error if the query fails.
+func (store *DBSettingStore) GetSettings(ctx context.Context) (map[string]json.RawMessage, error) {
+	rows, err := store.DB.QueryContext(ctx, "SELECT key, value FROM settings")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := map[string]json.RawMessage{}
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+// SaveSettings upserts the values in one transaction and records the change in the audit log.
+//
+// Parameters:
+//   - values: Validated JSON values keyed by setting key.
+//   - actor: Email of the user making the change.
+//
+// Returns:
+//   - error: An error if any value cannot be stored; nothing is saved in that case.
+func (store *DBSettingStore) SaveSettings(ctx context.Context, values map[string]json.RawMessage, actor string) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for key, value := range values {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO settings (key, value, updated_at, updated_by) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at, updated_by = EXCLUDED.updated_by`,
+			key, []byte(value), now, actor,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	details, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		Action:     audit.ActionSettingsUpdate,
+		EntityType: "settings",
+		Details:    details,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,234 @@
+// Package settlement_handlers provides HTTP handlers and the database store for the final
+// settlement of employees who leave: the settlement statement, the exit interview, and
+// approval, which posts the payable to the ledger.
+package settlement_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/settlements"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SettlementHandler provides HTTP handlers for final settlements. The rules live in the
+// settlements service; the handlers only translate HTTP.
+type SettlementHandler struct {
+	Service *settlements.Service
+}
+
+// SettlementRequest is the request body for drafting or recalculating a settlement. The
+// amounts are worked out by the server.
+type SettlementRequest struct {
+	UserID         int                         `json:"user_id"`          // Ignored when recalculating
+	LastWorkingDay string                      `json:"last_working_day"` // YYYY-MM-DD
+	PaidThrough    string                      `json:"paid_through"`     // YYYY-MM-DD
+	MonthlySalary  float64                     `json:"monthly_salary"`
+	Recoveries     []models.SettlementRecovery `json:"recoveries"`
+	Assets         []models.SettlementAsset    `json:"assets"`
+}
+
+// Settlement returns the settlement inputs described by the request.
+func (req SettlementRequest) Settlement() (models.FinalSettlement, error) {
+	settlement := models.FinalSettlement{UserID: req.UserID, MonthlySalary: req.MonthlySalary,
+		Recoveries: req.Recoveries, Assets: req.Assets}
+	var err error
+	if settlement.LastWorkingDay, err = time.Parse("2006-01-02", req.LastWorkingDay); err != nil {
+		return settlement, models.Invalid("last_working_day must be a date formatted as YYYY-MM-DD")
+	}
+	if settlement.PaidThrough, err = time.Parse("2006-01-02", req.PaidThrough); err != nil {
+		return settlement, models.Invalid("paid_through must be a date formatted as YYYY-MM-DD")
+	}
+	return settlement, nil
+}
+
+// CreateSettlement drafts the final settlement of an employee who is leaving.
+//
+// HTTP Method: POST
+// URL Path: /final_settlements
+//
+// Request Body:
+//   - JSON with user_id, last_working_day, paid_through (the last day salary has been paid
+//     for), monthly_salary, recoveries of description and amount (outstanding loans and
+//     advances) and assets of description, value and returned.
+//
+// Response:
+//   - Status Code: 201 (Created) with the settlement statement in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the employee already has a settlement.
+//   - Status Code: 422 (Unprocessable Entity) if an input is invalid or the user does not exist.
+//   - Status Code: 500 (Internal Server Error) if the settlement cannot be recorded.
+func (h *SettlementHandler) CreateSettlement(w http.ResponseWriter, r *http.Request) {
+	var req SettlementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	settlement, err := req.Settlement()
+	if err != nil {
+		httperr.Write(w, err, "Invalid settlement")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Create(r.Context(), &settlement, actor); err != nil {
+		httperr.Write(w, err, "Failed to create settlement")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, settlement)
+}
+
+// ListSettlements lists final settlements, newest first.
+//
+// HTTP Method: GET
+// URL Path: /final_settlements?status=draft (optional; draft, approved or cancelled)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of settlements in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the settlements cannot be loaded.
+func (h *SettlementHandler) ListSettlements(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Service.List(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load settlements")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// GetSettlement returns a settlement statement: the salary due, leave encashed, recoveries,
+// assets and the net amount.
+//
+// HTTP Method: GET
+// URL Path: /final_settlements/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the settlement in JSON.
+//   - Status Code: 404 (Not Found) if the settlement does not exist.
+//   - Status Code: 500 (Internal Server Error) if the settlement cannot be loaded.
+func (h *SettlementHandler) GetSettlement(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	settlement, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load settlement")
+		return
+	}
+	respond.JSON(w, http.StatusOK, settlement)
+}
+
+// RecalculateSettlement replaces the inputs of a draft settlement, for example once assets
+// are returned, and works it out again.
+//
+// HTTP Method: PUT
+// URL Path: /final_settlements/{id}
+//
+// Request Body:
+//   - JSON like CreateSettlement's; user_id is ignored.
+//
+// Response:
+//   - Status Code: 200 (OK) with the settlement in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the settlement does not exist.
+//   - Status Code: 409 (Conflict) if the settlement is not a draft.
+//   - Status Code: 422 (Unprocessable Entity) if an input is invalid.
+//   - Status Code: 500 (Internal Server Error) if the settlement cannot be saved.
+func (h *SettlementHandler) RecalculateSettlement(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req SettlementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	input, err := req.Settlement()
+	if err != nil {
+		httperr.Write(w, err, "Invalid settlement")
+		return
+	}
+	settlement, err := h.Service.Recalculate(r.Context(), id, input)
+	if err != nil {
+		httperr.Write(w, err, "Failed to recalculate settlement")
+		return
+	}
+	respond.JSON(w, http.StatusOK, settlement)
+}
+
+// RecordExitInterview records the exit interview of the employee a settlement is for.
+//
+// HTTP Method: PUT
+// URL Path: /final_settlements/{id}/exit_interview
+//
+// Request Body:
+//   - JSON with reason, feedback and would_rejoin.
+//
+// Response:
+//   - Status Code: 200 (OK) with the settlement in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the settlement does not exist.
+//   - Status Code: 409 (Conflict) if the settlement is cancelled.
+//   - Status Code: 422 (Unprocessable Entity) if the reason is missing.
+//   - Status Code: 500 (Internal Server Error) if the interview cannot be recorded.
+func (h *SettlementHandler) RecordExitInterview(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var interview models.ExitInterview
+	if err := json.NewDecoder(r.Body).Decode(&interview); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Interview(r.Context(), id, &interview, actor); err != nil {
+		httperr.Write(w, err, "Failed to record exit interview")
+		return
+	}
+	h.GetSettlement(w, r)
+}
+
+// ApproveSettlement approves a draft settlement and posts it to the ledger: the gross to
+// salaries, recoveries to employee advances and asset recoveries, and the net to salaries
+// payable.
+//
+// HTTP Method: POST
+// URL Path: /final_settlements/{id}/approve
+//
+// Response:
+//   - Status Code: 200 (OK) with the approved settlement in JSON.
+//   - Status Code: 403 (Forbidden) if the user drafted the settlement.
+//   - Status Code: 404 (Not Found) if the settlement does not exist.
+//   - Status Code: 409 (Conflict) if the settlement is not a draft.
+//   - Status Code: 500 (Internal Server Error) if the settlement cannot be approved.
+func (h *SettlementHandler) ApproveSettlement(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Approve, "Failed to approve settlement")
+}
+
+// CancelSettlement cancels a draft settlement.
+//
+// HTTP Method: POST
+// URL Path: /final_settlements/{id}/cancel
+//
+// Response:
+//   - Status Code: 200 (OK) with the cancelled settlement in JSON.
+//   - Status Code: 404 (Not Found) if the settlement does not exist.
+//   - Status Code: 409 (Conflict) if the settlement is not a draft.
+//   - Status Code: 500 (Internal Server Error) if the settlement cannot be cancelled.
+func (h *SettlementHandler) CancelSettlement(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Cancel, "Failed to cancel settlement")
+}
+
+// change applies a status change to the settlement in the URL and writes the result.
+func (h *SettlementHandler) change(w http.ResponseWriter, r *http.Request,
+	apply func(ctx context.Context, id int, actor string) (*models.FinalSettlement, error), failure string) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	settlement, err := apply(r.Context(), id, actor)
+	if err != nil {
+		httperr.Write(w, err, failure)
+		return
+	}
+	respond.JSON(w, http.StatusOK, settlement)
+}
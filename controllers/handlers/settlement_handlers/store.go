@@ -0,0 +1,243 @@
+package settlement_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/controllers/settlements"
+	"erp/models"
+)
+
+// DBFinalSettlementStore implements models.FinalSettlementStore using a SQL database. The
+// leave, recoveries, assets and exit interview of a settlement are kept as JSON.
+type DBFinalSettlementStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// settlementColumns are the columns read by scanSettlement, from final_settlements s
+// joined with users u.
+const settlementColumns = `s.id, s.user_id, u.name, u.email, s.last_working_day, s.paid_through, s.monthly_salary,
+	s.daily_rate, s.unpaid_days, s.salary_due, s.leave, s.leave_encashment, s.recoveries, s.assets, s.asset_recovery,
+	s.gross, s.deductions, s.net, s.status, s.exit_interview, s.created_by, s.created_at, s.approved_by, s.approved_at`
+
+// settlementFrom is the FROM clause settlementColumns are read from.
+const settlementFrom = ` FROM final_settlements s JOIN users u ON u.id = s.user_id`
+
+// scanSettlement reads a row of settlementColumns.
+func scanSettlement(row interface{ Scan(...interface{}) error }) (*models.FinalSettlement, error) {
+	var st models.FinalSettlement
+	var leave, recoveries, assets, interview []byte
+	var approvedBy sql.NullString
+	var approvedAt sql.NullTime
+	err := row.Scan(&st.ID, &st.UserID, &st.Employee, &st.Email, &st.LastWorkingDay, &st.PaidThrough, &st.MonthlySalary,
+		&st.DailyRate, &st.UnpaidDays, &st.SalaryDue, &leave, &st.LeaveEncashment, &recoveries, &assets, &st.AssetRecovery,
+		&st.Gross, &st.Deductions, &st.Net, &st.Status, &interview, &st.CreatedBy, &st.CreatedAt, &approvedBy, &approvedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range []struct {
+		data   []byte
+		target interface{}
+	}{{leave, &st.Leave}, {recoveries, &st.Recoveries}, {assets, &st.Assets}} {
+		if err := json.Unmarshal(field.data, field.target); err != nil {
+			return nil, err
+		}
+	}
+	if interview != nil {
+		st.Interview = &models.ExitInterview{}
+		if err := json.Unmarshal(interview, st.Interview); err != nil {
+			return nil, err
+		}
+	}
+	st.ApprovedBy = approvedBy.String
+	if approvedAt.Valid {
+		st.ApprovedAt = &approvedAt.Time
+	}
+	return &st, nil
+}
+
+// encodeLines returns the leave, recoveries and assets of a settlement as JSON.
+func encodeLines(st *models.FinalSettlement) (leave, recoveries, assets []byte, err error) {
+	if leave, err = json.Marshal(st.Leave); err != nil {
+		return
+	}
+	if recoveries, err = json.Marshal(st.Recoveries); err != nil {
+		return
+	}
+	assets, err = json.Marshal(st.Assets)
+	return
+}
+
+// CreateFinalSettlement records a draft settlement.
+//
+// Parameters:
+//   - st: A calculated settlement; its ID, creation time, and the employee's name and email
+//     are set.
+//
+// Returns:
+//   - error: A validation error if the user does not exist, a conflict if they already have
+//     a settlement that is not cancelled, or the query error.
+func (s *DBFinalSettlementStore) CreateFinalSettlement(ctx context.Context, st *models.FinalSettlement) error {
+	leave, recoveries, assets, err := encodeLines(st)
+	if err != nil {
+		return err
+	}
+	st.CreatedAt = time.Now()
+	err = s.DB.QueryRowContext(ctx,
+		`INSERT INTO final_settlements (user_id, last_working_day, paid_through, monthly_salary, daily_rate, unpaid_days,
+		     salary_due, leave, leave_encashment, recoveries, assets, asset_recovery, gross, deductions, net, status,
+		     created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		 RETURNING id, (SELECT name FROM users WHERE id = $1), (SELECT email FROM users WHERE id = $1)`,
+		st.UserID, st.LastWorkingDay, st.PaidThrough, st.MonthlySalary, st.DailyRate, st.UnpaidDays, st.SalaryDue,
+		leave, st.LeaveEncashment, recoveries, assets, st.AssetRecovery, st.Gross, st.Deductions, st.Net, st.Status,
+		st.CreatedBy, st.CreatedAt,
+	).Scan(&st.ID, &st.Employee, &st.Email)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("user %d does not exist", st.UserID)
+	}
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("user %d already has a final settlement", st.UserID)
+	}
+	return err
+}
+
+// GetFinalSettlement returns a settlement with the employee's name and email.
+func (s *DBFinalSettlementStore) GetFinalSettlement(ctx context.Context, id int) (*models.FinalSettlement, error) {
+	st, err := scanSettlement(s.DB.QueryRowContext(ctx, `SELECT `+settlementColumns+settlementFrom+` WHERE s.id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("final settlement %d not found", id)
+	}
+	return st, err
+}
+
+// ListFinalSettlements returns the settlements in a status, or all of them if status is
+// empty, newest first.
+func (s *DBFinalSettlementStore) ListFinalSettlements(ctx context.Context, status string) ([]models.FinalSettlement, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+settlementColumns+settlementFrom+`
+		WHERE ($1 = '' OR s.status = $1) ORDER BY s.created_at DESC, s.id DESC`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.FinalSettlement{}
+	for rows.Next() {
+		st, err := scanSettlement(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *st)
+	}
+	return list, rows.Err()
+}
+
+// UpdateFinalSettlement saves the inputs and amounts of a recalculated draft.
+//
+// Returns:
+//   - error: A not found error, a conflict if the settlement is no longer a draft, or the
+//     query error.
+func (s *DBFinalSettlementStore) UpdateFinalSettlement(ctx context.Context, st *models.FinalSettlement) error {
+	leave, recoveries, assets, err := encodeLines(st)
+	if err != nil {
+		return err
+	}
+	return s.change(ctx, st.ID, []string{models.SettlementDraft}, func(tx *sql.Tx, current *models.FinalSettlement) error {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE final_settlements SET last_working_day = $1, paid_through = $2, monthly_salary = $3, daily_rate = $4,
+			     unpaid_days = $5, salary_due = $6, leave = $7, leave_encashment = $8, recoveries = $9, assets = $10,
+			     asset_recovery = $11, gross = $12, deductions = $13, net = $14
+			 WHERE id = $15`,
+			st.LastWorkingDay, st.PaidThrough, st.MonthlySalary, st.DailyRate, st.UnpaidDays, st.SalaryDue, leave,
+			st.LeaveEncashment, recoveries, assets, st.AssetRecovery, st.Gross, st.Deductions, st.Net, st.ID)
+		return err
+	})
+}
+
+// SaveExitInterview records the exit interview of a settlement that is not cancelled,
+// replacing any recorded before.
+func (s *DBFinalSettlementStore) SaveExitInterview(ctx context.Context, id int, interview *models.ExitInterview) error {
+	data, err := json.Marshal(interview)
+	if err != nil {
+		return err
+	}
+	return s.change(ctx, id, []string{models.SettlementDraft, models.SettlementApproved}, func(tx *sql.Tx, current *models.FinalSettlement) error {
+		_, err := tx.ExecContext(ctx, `UPDATE final_settlements SET exit_interview = $1 WHERE id = $2`, data, id)
+		return err
+	})
+}
+
+// ApproveFinalSettlement approves a draft and posts it to the ledger in the same
+// transaction (see settlements.Postings).
+//
+// Returns:
+//   - *models.FinalSettlement: The approved settlement.
+//   - error: A not found error, a conflict if it is not a draft, a permission error if the
+//     actor drafted it, or the query error.
+func (s *DBFinalSettlementStore) ApproveFinalSettlement(ctx context.Context, id int, actor string) (*models.FinalSettlement, error) {
+	err := s.change(ctx, id, []string{models.SettlementDraft}, func(tx *sql.Tx, current *models.FinalSettlement) error {
+		if current.CreatedBy == actor {
+			return models.PermissionDenied("settlement %d must be approved by someone other than who drafted it", id)
+		}
+		now := time.Now()
+		if _, err := tx.ExecContext(ctx, `UPDATE final_settlements SET status = $1, approved_by = $2, approved_at = $3 WHERE id = $4`,
+			models.SettlementApproved, actor, now, id); err != nil {
+			return err
+		}
+		for _, line := range settlements.Postings(current, now) {
+			if err := general_ledger_handlers.InsertTransaction(ctx, tx, &line); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetFinalSettlement(ctx, id)
+}
+
+// CancelFinalSettlement cancels a draft. The employee may then be given a new settlement.
+func (s *DBFinalSettlementStore) CancelFinalSettlement(ctx context.Context, id int, actor string) (*models.FinalSettlement, error) {
+	err := s.change(ctx, id, []string{models.SettlementDraft}, func(tx *sql.Tx, current *models.FinalSettlement) error {
+		_, err := tx.ExecContext(ctx, `UPDATE final_settlements SET status = $1, cancelled_by = $2, cancelled_at = $3 WHERE id = $4`,
+			models.SettlementCancelled, actor, time.Now(), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetFinalSettlement(ctx, id)
+}
+
+// change locks a settlement, checks it is in one of the given statuses and applies a
+// change to it in a transaction.
+func (s *DBFinalSettlementStore) change(ctx context.Context, id int, from []string, apply func(tx *sql.Tx, current *models.FinalSettlement) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := scanSettlement(tx.QueryRowContext(ctx, `SELECT `+settlementColumns+settlementFrom+` WHERE s.id = $1 FOR UPDATE OF s`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotFound("final settlement %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || current.Status == status
+	}
+	if !allowed {
+		return models.Conflict("final settlement %d is %s", id, current.Status)
+	}
+	if err := apply(tx, current); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,71 @@
+package settlement_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectLockedSettlement expects a settlement drafted by hr@example.com to be locked and
+// read: 2400 salary due and 1080 leave encashed, less a 500 advance and a 300 phone.
+func expectLockedSettlement(mock sqlmock.Sqlmock, status string) {
+	day := time.Date(2025, 6, 20, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE s.id = $1 FOR UPDATE OF s")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "email", "last_working_day", "paid_through",
+			"monthly_salary", "daily_rate", "unpaid_days", "salary_due", "leave", "leave_encashment", "recoveries", "assets",
+			"asset_recovery", "gross", "deductions", "net", "status", "exit_interview", "created_by", "created_at",
+			"approved_by", "approved_at"}).
+			AddRow(3, 4, "Dana", "dana@example.com", day, day.AddDate(0, 0, -20), 3650.0, 120.0, 20, 2400.0,
+				[]byte(`[{"leave_type":"Vacation","days":9,"amount":1080}]`), 1080.0,
+				[]byte(`[{"description":"Salary advance","amount":500}]`),
+				[]byte(`[{"description":"Phone","value":300,"returned":false}]`), 300.0, 3480.0, 800.0, 2680.0,
+				status, nil, "hr@example.com", day, nil, nil))
+}
+
+func TestApproveFinalSettlementPostsThePayable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBFinalSettlementStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedSettlement(mock, models.SettlementDraft)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE final_settlements SET status = $1, approved_by = $2")).
+		WithArgs(models.SettlementApproved, "finance@example.com", sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, amount := range []float64{3480, -500, -300, -2680} {
+		mock.ExpectQuery("INSERT INTO financial_transactions").
+			WithArgs(sqlmock.AnyArg(), amount, sqlmock.AnyArg(), "Final settlement of dana@example.com", nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO account_period_balances").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO report_refreshes").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE s.id = $1")).WithArgs(3).WillReturnError(assert.AnError)
+
+	_, err = store.ApproveFinalSettlement(context.Background(), 3, "finance@example.com")
+	assert.ErrorIs(t, err, assert.AnError, "the settlement is read back after the commit")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproveOwnFinalSettlement(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBFinalSettlementStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedSettlement(mock, models.SettlementDraft)
+	mock.ExpectRollback()
+
+	_, err = store.ApproveFinalSettlement(context.Background(), 3, "hr@example.com")
+	assert.ErrorIs(t, err, models.ErrPermissionDenied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
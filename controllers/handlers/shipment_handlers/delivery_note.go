@@ -0,0 +1,123 @@
+package shipment_handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/documents"
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// DeliveryNoteSeries is the numbering series of delivery notes.
+const DeliveryNoteSeries = "delivery_note"
+
+// CompanyProvider supplies the company profile, typically the settings service.
+type CompanyProvider interface {
+	Company(ctx context.Context) (*models.CompanyProfile, error)
+}
+
+// DeliveryNoteHandler generates the delivery notes (packing slips) included with shipments.
+type DeliveryNoteHandler struct {
+	Store   models.ShipmentStore
+	Items   models.PackingListStore
+	Numbers documents.Numberer
+	Company CompanyProvider // Optional source of the company header
+}
+
+// RegisterDeliveryNoteRoutes maps the delivery note route to its handler function.
+//
+// Parameters:
+//   - router: The shipments router where the route is registered.
+//   - handler: The delivery note handler.
+func RegisterDeliveryNoteRoutes(router *mux.Router, handler *DeliveryNoteHandler) {
+	router.HandleFunc("/{id:[0-9]+}/delivery_note", handler.GetDeliveryNote).Methods("GET")
+}
+
+// GetDeliveryNote renders the delivery note of a shipment. The note is numbered the first
+// time it is generated and keeps its number when printed again.
+//
+// HTTP Method: GET
+// URL Path: /{id}/delivery_note?format=html
+//
+// Response:
+//   - Status Code: 200 (OK) with the note as PDF, or as HTML with format=html.
+//   - Status Code: 404 (Not Found) if the shipment does not exist.
+//   - Status Code: 500 (Internal Server Error) if the note cannot be generated.
+func (h *DeliveryNoteHandler) GetDeliveryNote(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	shipment, err := h.Store.GetShipmentByID(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Shipment not found")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to load shipment")
+		return
+	}
+	items, err := h.Items.GetPackingList(r.Context(), shipment.ID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load packing list")
+		return
+	}
+	number, err := h.Numbers.Number(r.Context(), DeliveryNoteSeries, shipment.ID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to number delivery note")
+		return
+	}
+	var company *models.CompanyProfile
+	if h.Company != nil {
+		if company, err = h.Company.Company(r.Context()); err != nil {
+			httperr.Write(w, err, "Failed to load company profile")
+			return
+		}
+	}
+
+	if err := documents.Write(w, r, deliveryNote(shipment, items, number, company)); err != nil {
+		httperr.Write(w, err, "Failed to render delivery note")
+	}
+}
+
+// deliveryNote lays out the delivery note of a shipment.
+func deliveryNote(shipment *models.Shipment, items []models.PackingItem, number string, company *models.CompanyProfile) *models.PrintedDocument {
+	doc := &models.PrintedDocument{
+		Title:   "Delivery Note",
+		Number:  number,
+		Date:    shipment.CreatedAt,
+		Company: company,
+		Parties: []models.DocumentParty{
+			{Label: "Ship from", Lines: shipment.FromAddress.Lines()},
+			{Label: "Ship to", Lines: shipment.ToAddress.Lines()},
+		},
+		Columns: []string{"Product", "Quantity"},
+		Lines:   [][]string{},
+	}
+	if shipment.SalesOrderID != nil {
+		doc.Details = append(doc.Details, models.DocumentField{Label: "Sales order", Value: strconv.Itoa(*shipment.SalesOrderID)})
+	}
+	if shipment.Carrier != "" {
+		doc.Details = append(doc.Details, models.DocumentField{Label: "Carrier", Value: shipment.Carrier + " " + shipment.Service})
+	}
+	if shipment.TrackingNumber != "" {
+		doc.Details = append(doc.Details, models.DocumentField{Label: "Tracking number", Value: shipment.TrackingNumber})
+	}
+	doc.Details = append(doc.Details, models.DocumentField{Label: "Weight", Value: fmt.Sprintf("%.2f kg", shipment.WeightKg)})
+
+	total := 0
+	for _, item := range items {
+		name := item.Name
+		if name == "" && item.ProductID != nil {
+			name = fmt.Sprintf("Product #%d", *item.ProductID)
+		}
+		doc.Lines = append(doc.Lines, []string{name, strconv.Itoa(item.Quantity)})
+		total += item.Quantity
+	}
+	doc.Totals = []models.DocumentField{{Label: "Total quantity", Value: strconv.Itoa(total)}}
+	return doc
+}
@@ -0,0 +1,57 @@
+package shipment_handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"erp/controllers/shipping"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockPackingList returns the same items for every shipment.
+type mockPackingList []models.PackingItem
+
+func (m mockPackingList) GetPackingList(ctx context.Context, shipmentID int) ([]models.PackingItem, error) {
+	return m, nil
+}
+
+// mockNumbers numbers documents by entity ID.
+type mockNumbers struct{}
+
+func (mockNumbers) Number(ctx context.Context, series string, entityID int) (string, error) {
+	return fmt.Sprintf("DN-%05d", entityID), nil
+}
+
+func TestGetDeliveryNote(t *testing.T) {
+	store := newMockShipmentStore()
+	router := setupRouter(store, shipping.Carriers{})
+	productID := 3
+	RegisterDeliveryNoteRoutes(router.PathPrefix("/shipments").Subrouter(), &DeliveryNoteHandler{
+		Store:   store,
+		Items:   mockPackingList{{ProductID: &productID, Name: "Desk lamp", Quantity: 2}, {ProductID: &productID, Quantity: 1}},
+		Numbers: mockNumbers{},
+	})
+
+	rr := doRequest(router, "GET", "/shipments/1/delivery_note", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	shipment := testShipment()
+	assert.NoError(t, store.CreateShipment(context.Background(), &shipment))
+
+	rr = doRequest(router, "GET", "/shipments/1/delivery_note?format=html", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `inline; filename="DN-00001.html"`, rr.Header().Get("Content-Disposition"))
+	body := rr.Body.String()
+	assert.Contains(t, body, "<strong>Ship to</strong><br>Jane Doe<br>1 Main St<br>Chittagong<br>BD<br>")
+	assert.Contains(t, body, "<td>Desk lamp</td><td>2</td>")
+	assert.Contains(t, body, "<td>Product #3</td><td>1</td>")
+	assert.Contains(t, body, "<dt>Total quantity</dt><dd>3</dd>")
+
+	rr = doRequest(router, "GET", "/shipments/1/delivery_note", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+}
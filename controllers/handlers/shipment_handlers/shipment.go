@@ -0,0 +1,336 @@
+package shipment_handlers
+
+import (
+	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/controllers/shipping"
+	"erp/controllers/storage"
+	"erp/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ShipmentHandler provides HTTP handlers for shipments, rates, labels and tracking.
+type ShipmentHandler struct {
+	Store    models.ShipmentStore
+	Carriers shipping.Carriers // Carriers available for quotes and labels
+	Storage  storage.Storage   // Attachment backend holding the purchased labels
+}
+
+// CreateShipmentRequest is the request body for creating a shipment. The status, carrier,
+// label and tracking details are set as the shipment progresses.
+type CreateShipmentRequest struct {
+	SalesOrderID *int           `json:"sales_order_id,omitempty"`
+	FromAddress  models.Address `json:"from_address"`
+	ToAddress    models.Address `json:"to_address"`
+	WeightKg     float64        `json:"weight_kg"`
+}
+
+// Shipment returns the pending shipment described by the request.
+func (req CreateShipmentRequest) Shipment() models.Shipment {
+	return models.Shipment{
+		SalesOrderID: req.SalesOrderID,
+		FromAddress:  req.FromAddress,
+		ToAddress:    req.ToAddress,
+		WeightKg:     req.WeightKg,
+		Status:       models.ShipmentPending,
+	}
+}
+
+// RegisterRoutes maps shipment routes to their respective handler functions.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the ShipmentStore interface.
+//   - carriers: The configured shipping carriers.
+//   - files: The storage backend where labels are kept.
+func RegisterRoutes(router *mux.Router, store models.ShipmentStore, carriers shipping.Carriers, files storage.Storage) {
+	handler := &ShipmentHandler{Store: store, Carriers: carriers, Storage: files}
+
+	router.HandleFunc("", handler.CreateShipment).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetShipment).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/rates", handler.GetRates).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/label", handler.PurchaseLabel).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/tracking", handler.GetTracking).Methods("GET")
+}
+
+// CreateShipment creates a pending shipment.
+//
+// HTTP Method: POST
+// URL Path: /
+//
+// Request Body:
+//   - JSON with from_address, to_address, weight_kg and an optional sales_order_id.
+//
+// Response:
+//   - Status Code: 201 (Created) with the shipment in JSON.
+//   - Status Code: 400 (Bad Request) if an address is incomplete or the weight is not positive.
+//   - Status Code: 500 (Internal Server Error) if the shipment cannot be stored.
+func (h *ShipmentHandler) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	var req CreateShipmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	shipment := req.Shipment()
+	if err := validateShipment(&shipment); err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.Store.CreateShipment(r.Context(), &shipment); err != nil {
+		httperr.Write(w, err, "Failed to create shipment")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, shipment)
+}
+
+// GetShipment retrieves a shipment by ID.
+//
+// HTTP Method: GET
+// URL Path: /{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the shipment in JSON.
+//   - Status Code: 404 (Not Found) if the shipment does not exist.
+func (h *ShipmentHandler) GetShipment(w http.ResponseWriter, r *http.Request) {
+	shipment, ok := h.loadShipment(w, r)
+	if !ok {
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, shipment)
+}
+
+// GetRates quotes the shipment with every configured carrier, cheapest first. A carrier
+// that fails to quote is skipped so one outage does not hide the other rates.
+//
+// HTTP Method: GET
+// URL Path: /{id}/rates
+//
+// Query Parameters:
+//   - carrier: Optional carrier name to quote with only that carrier.
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of rates in JSON.
+//   - Status Code: 400 (Bad Request) if the carrier is unknown.
+//   - Status Code: 404 (Not Found) if the shipment does not exist.
+//   - Status Code: 502 (Bad Gateway) if no carrier could quote the shipment.
+func (h *ShipmentHandler) GetRates(w http.ResponseWriter, r *http.Request) {
+	shipment, ok := h.loadShipment(w, r)
+	if !ok {
+		return
+	}
+
+	carriers := h.Carriers
+	if name := r.URL.Query().Get("carrier"); name != "" {
+		carrier, ok := h.Carriers[name]
+		if !ok {
+			respond.Error(w, http.StatusBadRequest, "Unknown carrier")
+			return
+		}
+		carriers = shipping.Carriers{name: carrier}
+	}
+
+	rates := []models.ShippingRate{}
+	var lastErr error
+	for _, carrier := range carriers {
+		quoted, err := carrier.Rates(shipment)
+		if err != nil {
+			log.Printf("shipping: %s could not quote shipment %d: %v", carrier.Name(), shipment.ID, err)
+			lastErr = err
+			continue
+		}
+		rates = append(rates, quoted...)
+	}
+	if len(rates) == 0 && lastErr != nil {
+		respond.Error(w, http.StatusBadGateway, fmt.Sprintf("Failed to fetch rates: %v", lastErr))
+		return
+	}
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Amount < rates[j].Amount })
+
+	respond.JSON(w, http.StatusOK, rates)
+}
+
+// PurchaseLabel buys a label from a carrier, stores it in the attachment backend and
+// records the tracking number on the shipment.
+//
+// HTTP Method: POST
+// URL Path: /{id}/label
+//
+// Request Body:
+//   - JSON with the carrier and service to use, as returned by the rates endpoint.
+//
+// Response:
+//   - Status Code: 200 (OK) with the updated shipment in JSON.
+//   - Status Code: 400 (Bad Request) if the carrier or service is unknown.
+//   - Status Code: 404 (Not Found) if the shipment does not exist.
+//   - Status Code: 409 (Conflict) if a label was already purchased.
+//   - Status Code: 502 (Bad Gateway) if the carrier rejects the request.
+//   - Status Code: 500 (Internal Server Error) if the label cannot be stored.
+func (h *ShipmentHandler) PurchaseLabel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Carrier string `json:"carrier"`
+		Service string `json:"service"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	carrier, ok := h.Carriers[req.Carrier]
+	if !ok {
+		respond.Error(w, http.StatusBadRequest, "Unknown carrier")
+		return
+	}
+
+	shipment, ok := h.loadShipment(w, r)
+	if !ok {
+		return
+	}
+	if shipment.Status != models.ShipmentPending {
+		respond.Error(w, http.StatusConflict, models.ErrLabelPurchased.Error())
+		return
+	}
+
+	label, err := carrier.CreateShipment(shipment, req.Service)
+	if errors.Is(err, shipping.ErrUnknownService) {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respond.Error(w, http.StatusBadGateway, fmt.Sprintf("Carrier rejected the shipment: %v", err))
+		return
+	}
+
+	key := fmt.Sprintf("labels/%d/%s%s", shipment.ID, label.TrackingNumber, labelExtension(label.ContentType))
+	if err := h.Storage.Put(key, label.Data, label.ContentType); err != nil {
+		httperr.Write(w, err, "Failed to store label")
+		return
+	}
+
+	shipment.Carrier = carrier.Name()
+	shipment.Service = req.Service
+	shipment.TrackingNumber = label.TrackingNumber
+	shipment.LabelURL = h.Storage.URL(key)
+	shipment.Cost = label.Cost
+	shipment.Currency = label.Currency
+	if err := h.Store.SaveLabel(r.Context(), shipment); err != nil {
+		// The label was bought but cannot be recorded; log it so it can be voided with the carrier.
+		log.Printf("shipping: label %s from %s for shipment %d not recorded: %v", label.TrackingNumber, carrier.Name(), shipment.ID, err)
+		if errors.Is(err, models.ErrLabelPurchased) {
+			h.Storage.Delete(key)
+			respond.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		httperr.Write(w, err, "Failed to save label")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, shipment)
+}
+
+// GetTracking returns the tracking history of a shipment. Carriers with a tracking API are
+// asked for new updates first; others rely on the updates received through webhooks.
+//
+// HTTP Method: GET
+// URL Path: /{id}/tracking
+//
+// Response:
+//   - Status Code: 200 (OK) with the shipment status and tracking events in JSON.
+//   - Status Code: 404 (Not Found) if the shipment does not exist.
+//   - Status Code: 500 (Internal Server Error) if the events cannot be read.
+func (h *ShipmentHandler) GetTracking(w http.ResponseWriter, r *http.Request) {
+	shipment, ok := h.loadShipment(w, r)
+	if !ok {
+		return
+	}
+
+	if carrier, ok := h.Carriers[shipment.Carrier]; ok && shipment.TrackingNumber != "" {
+		updates, err := carrier.Track(shipment.TrackingNumber)
+		if err != nil && !errors.Is(err, shipping.ErrTrackingUnavailable) {
+			log.Printf("shipping: tracking lookup for shipment %d failed: %v", shipment.ID, err)
+		}
+		for _, update := range updates {
+			update.ShipmentID = shipment.ID
+			if _, err := h.Store.AddTrackingEvent(r.Context(), &update); err != nil {
+				httperr.Write(w, err, "Failed to record tracking")
+				return
+			}
+		}
+		if len(updates) > 0 {
+			if shipment, ok = h.loadShipment(w, r); !ok {
+				return
+			}
+		}
+	}
+
+	events, err := h.Store.GetTrackingEvents(r.Context(), shipment.ID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load tracking")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"shipment_id":     shipment.ID,
+		"status":          shipment.Status,
+		"tracking_number": shipment.TrackingNumber,
+		"events":          events,
+	})
+}
+
+// loadShipment reads the shipment named in the URL, writing an error response if it
+// cannot be loaded.
+func (h *ShipmentHandler) loadShipment(w http.ResponseWriter, r *http.Request) (*models.Shipment, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid shipment ID")
+		return nil, false
+	}
+
+	shipment, err := h.Store.GetShipmentByID(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Shipment not found")
+		return nil, false
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to load shipment")
+		return nil, false
+	}
+	return shipment, true
+}
+
+// validateShipment checks the fields required to quote and ship a parcel.
+func validateShipment(shipment *models.Shipment) error {
+	if shipment.WeightKg <= 0 {
+		return errors.New("weight_kg must be positive")
+	}
+	for name, a := range map[string]models.Address{"from_address": shipment.FromAddress, "to_address": shipment.ToAddress} {
+		if strings.TrimSpace(a.Name) == "" || strings.TrimSpace(a.Line1) == "" ||
+			strings.TrimSpace(a.City) == "" || len(strings.TrimSpace(a.Country)) != 2 {
+			return fmt.Errorf("%s needs a name, line1, city and two-letter country code", name)
+		}
+	}
+	return nil
+}
+
+// labelExtension returns the file extension for a label's content type.
+func labelExtension(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "application/pdf"):
+		return ".pdf"
+	case strings.HasPrefix(contentType, "image/png"):
+		return ".png"
+	case strings.HasPrefix(contentType, "text/plain"):
+		return ".txt"
+	}
+	return ""
+}
@@ -0,0 +1,214 @@
+package shipment_handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/controllers/shipping"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockShipmentStore keeps shipments and tracking events in memory.
+type mockShipmentStore struct {
+	shipments map[int]*models.Shipment
+	events    []models.TrackingEvent
+}
+
+func newMockShipmentStore() *mockShipmentStore {
+	return &mockShipmentStore{shipments: map[int]*models.Shipment{}}
+}
+
+func (m *mockShipmentStore) CreateShipment(ctx context.Context, shipment *models.Shipment) error {
+	shipment.ID = len(m.shipments) + 1
+	shipment.Status = models.ShipmentPending
+	stored := *shipment
+	m.shipments[shipment.ID] = &stored
+	return nil
+}
+
+func (m *mockShipmentStore) GetShipmentByID(ctx context.Context, id int) (*models.Shipment, error) {
+	s, ok := m.shipments[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	copy := *s
+	return &copy, nil
+}
+
+func (m *mockShipmentStore) GetShipmentByTracking(ctx context.Context, carrier, trackingNumber string) (*models.Shipment, error) {
+	for _, s := range m.shipments {
+		if s.Carrier == carrier && s.TrackingNumber == trackingNumber {
+			copy := *s
+			return &copy, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (m *mockShipmentStore) SaveLabel(ctx context.Context, shipment *models.Shipment) error {
+	stored, ok := m.shipments[shipment.ID]
+	if !ok {
+		return models.ErrNotFound
+	}
+	if stored.Status != models.ShipmentPending {
+		return models.ErrLabelPurchased
+	}
+	shipment.Status = models.ShipmentLabelPurchased
+	*stored = *shipment
+	return nil
+}
+
+func (m *mockShipmentStore) AddTrackingEvent(ctx context.Context, event *models.TrackingEvent) (bool, error) {
+	latest := true
+	for _, e := range m.events {
+		if e.ShipmentID != event.ShipmentID {
+			continue
+		}
+		if e.Status == event.Status && e.OccurredAt.Equal(event.OccurredAt) {
+			return false, nil
+		}
+		if e.OccurredAt.After(event.OccurredAt) {
+			latest = false
+		}
+	}
+	m.events = append(m.events, *event)
+	if latest {
+		m.shipments[event.ShipmentID].Status = event.Status
+	}
+	return true, nil
+}
+
+func (m *mockShipmentStore) GetTrackingEvents(ctx context.Context, shipmentID int) ([]models.TrackingEvent, error) {
+	events := []models.TrackingEvent{}
+	for _, e := range m.events {
+		if e.ShipmentID == shipmentID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func setupRouter(store models.ShipmentStore, carriers shipping.Carriers) *mux.Router {
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("/shipments").Subrouter(), store, carriers, storage.NewMemoryStorage())
+	return router
+}
+
+func doRequest(router *mux.Router, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func testShipment() models.Shipment {
+	return models.Shipment{
+		FromAddress: models.Address{Name: "Warehouse", Line1: "2 Depot Rd", City: "Dhaka", Country: "BD"},
+		ToAddress:   models.Address{Name: "Jane Doe", Line1: "1 Main St", City: "Chittagong", Country: "BD"},
+		WeightKg:    1.5,
+	}
+}
+
+func TestCreateShipmentValidation(t *testing.T) {
+	router := setupRouter(newMockShipmentStore(), shipping.Carriers{})
+
+	invalid := testShipment()
+	invalid.ToAddress.Country = ""
+	assert.Equal(t, http.StatusBadRequest, doRequest(router, "POST", "/shipments", invalid).Code)
+
+	invalid = testShipment()
+	invalid.WeightKg = 0
+	assert.Equal(t, http.StatusBadRequest, doRequest(router, "POST", "/shipments", invalid).Code)
+
+	assert.Equal(t, http.StatusCreated, doRequest(router, "POST", "/shipments", testShipment()).Code)
+}
+
+func TestCreateShipmentIgnoresLabelFields(t *testing.T) {
+	store := newMockShipmentStore()
+	router := setupRouter(store, shipping.Carriers{})
+
+	shipment := testShipment()
+	shipment.Carrier, shipment.TrackingNumber, shipment.LabelURL = "local_courier", "TRK1", "/files/forged.pdf"
+	rr := doRequest(router, "POST", "/shipments", shipment)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	stored := store.shipments[1]
+	assert.Empty(t, stored.Carrier)
+	assert.Empty(t, stored.TrackingNumber)
+	assert.Empty(t, stored.LabelURL)
+}
+
+func TestRatesLabelAndTracking(t *testing.T) {
+	ctx := context.Background()
+	store := newMockShipmentStore()
+	carriers := shipping.Carriers{}
+	carriers.Add(shipping.NewFlatRateCarrier("courier", "USD", 5, 1.5))
+	router := setupRouter(store, carriers)
+
+	rr := doRequest(router, "POST", "/shipments", testShipment())
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = doRequest(router, "GET", "/shipments/1/rates", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var rates []models.ShippingRate
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&rates))
+	assert.Len(t, rates, 2)
+	assert.Equal(t, "standard", rates[0].Service)
+	assert.Equal(t, 8.0, rates[0].Amount)
+
+	rr = doRequest(router, "POST", "/shipments/1/label", map[string]string{"carrier": "courier", "service": "overnight"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doRequest(router, "POST", "/shipments/1/label", map[string]string{"carrier": "courier", "service": "standard"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var shipment models.Shipment
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&shipment))
+	assert.Equal(t, models.ShipmentLabelPurchased, shipment.Status)
+	assert.NotEmpty(t, shipment.TrackingNumber)
+	assert.NotEmpty(t, shipment.LabelURL)
+
+	rr = doRequest(router, "POST", "/shipments/1/label", map[string]string{"carrier": "courier", "service": "standard"})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Tracking updates arrive through the webhook processor, possibly out of order.
+	processor := &TrackingProcessor{Store: store, Carriers: carriers}
+	integration := &models.WebhookIntegration{Name: "courier", Module: "shipping"}
+	delivered, _ := json.Marshal(map[string]interface{}{"tracking_number": shipment.TrackingNumber, "status": "delivered", "occurred_at": time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)})
+	inTransit, _ := json.Marshal(map[string]interface{}{"tracking_number": shipment.TrackingNumber, "status": "in_transit", "occurred_at": time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)})
+	assert.NoError(t, processor.Process(ctx, integration, &models.WebhookEvent{Payload: string(delivered)}))
+	assert.NoError(t, processor.Process(ctx, integration, &models.WebhookEvent{Payload: string(inTransit)}))
+	assert.NoError(t, processor.Process(ctx, integration, &models.WebhookEvent{Payload: string(delivered)}))
+
+	rr = doRequest(router, "GET", "/shipments/1/tracking", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var tracking struct {
+		Status string                 `json:"status"`
+		Events []models.TrackingEvent `json:"events"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&tracking))
+	assert.Equal(t, models.ShipmentDelivered, tracking.Status)
+	assert.Len(t, tracking.Events, 2)
+}
+
+func TestTrackingProcessorUnknownTrackingNumber(t *testing.T) {
+	carriers := shipping.Carriers{}
+	carriers.Add(shipping.NewFlatRateCarrier("courier", "USD", 5, 1.5))
+	processor := &TrackingProcessor{Store: newMockShipmentStore(), Carriers: carriers}
+
+	err := processor.Process(context.Background(), &models.WebhookIntegration{Name: "courier"}, &models.WebhookEvent{Payload: `{"tracking_number":"COU404","status":"in_transit"}`})
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
@@ -0,0 +1,253 @@
+// Package shipment_handlers provides SQL-backed methods and HTTP handlers to manage
+// shipments, buy labels from carriers and record tracking updates.
+package shipment_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/models"
+	"time"
+)
+
+// DBShipmentStore provides SQL-backed methods for the shipments and
+// shipment_tracking_events tables.
+type DBShipmentStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// shipmentColumns lists the columns read by scanShipment, in order.
+const shipmentColumns = `id, sales_order_id, from_address, to_address, weight_kg, status, carrier, service,
+	tracking_number, label_url, cost, currency, created_at, updated_at`
+
+// CreateShipment inserts a new pending shipment and assigns its generated ID.
+//
+// Parameters:
+//   - shipment: A pointer to the Shipment to store.
+//
+// Returns:
+//   - error: An error if the insertion fails, otherwise nil.
+func (store *DBShipmentStore) CreateShipment(ctx context.Context, shipment *models.Shipment) error {
+	from, err := json.Marshal(shipment.FromAddress)
+	if err != nil {
+		return err
+	}
+	to, err := json.Marshal(shipment.ToAddress)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	shipment.Status = models.ShipmentPending
+	shipment.CreatedAt, shipment.UpdatedAt = now, now
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO shipments (sales_order_id, from_address, to_address, weight_kg, status, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING id`,
+		shipment.SalesOrderID, from, to, shipment.WeightKg, shipment.Status, now,
+	).Scan(&shipment.ID)
+}
+
+// GetShipmentByID retrieves a shipment by its ID.
+//
+// Parameters:
+//   - id: The ID of the shipment.
+//
+// Returns:
+//   - *Shipment: The shipment if found.
+//   - error: models.ErrNotFound if no shipment exists with the given ID.
+func (store *DBShipmentStore) GetShipmentByID(ctx context.Context, id int) (*models.Shipment, error) {
+	return scanShipment(store.DB.QueryRowContext(ctx, `SELECT `+shipmentColumns+` FROM shipments WHERE id = $1`, id))
+}
+
+// GetShipmentByTracking retrieves a shipment by the carrier's tracking number.
+//
+// Parameters:
+//   - carrier: The name of the carrier.
+//   - trackingNumber: The tracking number issued by the carrier.
+//
+// Returns:
+//   - *Shipment: The shipment if found.
+//   - error: models.ErrNotFound if no shipment has the tracking number.
+func (store *DBShipmentStore) GetShipmentByTracking(ctx context.Context, carrier, trackingNumber string) (*models.Shipment, error) {
+	return scanShipment(store.DB.QueryRowContext(ctx,
+		`SELECT `+shipmentColumns+` FROM shipments WHERE carrier = $1 AND tracking_number = $2`,
+		carrier, trackingNumber,
+	))
+}
+
+// SaveLabel records the carrier, service, tracking number, label and cost of a purchased
+// label and marks the shipment as label_purchased. Only pending shipments are updated.
+//
+// Parameters:
+//   - shipment: The shipment with its label fields set.
+//
+// Returns:
+//   - error: models.ErrNotFound if the shipment does not exist, models.ErrLabelPurchased
+//     if it is no longer pending, or an error if the update fails.
+func (store *DBShipmentStore) SaveLabel(ctx context.Context, shipment *models.Shipment) error {
+	shipment.UpdatedAt = time.Now()
+	result, err := store.DB.ExecContext(ctx,
+		`UPDATE shipments
+		 SET carrier = $1, service = $2, tracking_number = $3, label_url = $4, cost = $5, currency = $6,
+		     status = $7, updated_at = $8
+		 WHERE id = $9 AND status = $10`,
+		shipment.Carrier, shipment.Service, shipment.TrackingNumber, shipment.LabelURL, shipment.Cost,
+		shipment.Currency, models.ShipmentLabelPurchased, shipment.UpdatedAt, shipment.ID, models.ShipmentPending,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		if _, err := store.GetShipmentByID(ctx, shipment.ID); err != nil {
+			return err
+		}
+		return models.ErrLabelPurchased
+	}
+	shipment.Status = models.ShipmentLabelPurchased
+	return nil
+}
+
+// AddTrackingEvent stores a tracking update for a shipment. The shipment's status follows
+// the most recent update, so updates delivered out of order do not move it backwards.
+//
+// Parameters:
+//   - event: The tracking event; ShipmentID must be set.
+//
+// Returns:
+//   - bool: true if the event was new, false if the same update was already recorded.
+//   - error: An error if the database operations fail.
+func (store *DBShipmentStore) AddTrackingEvent(ctx context.Context, event *models.TrackingEvent) (bool, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO shipment_tracking_events (shipment_id, tracking_number, status, description, location, occurred_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (shipment_id, status, occurred_at) DO NOTHING
+		 RETURNING id`,
+		event.ShipmentID, event.TrackingNumber, event.Status, event.Description, event.Location, event.OccurredAt,
+	).Scan(&event.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE shipments SET status = $1, updated_at = $2
+		 WHERE id = $3 AND NOT EXISTS (
+		     SELECT 1 FROM shipment_tracking_events WHERE shipment_id = $3 AND occurred_at > $4
+		 )`,
+		event.Status, time.Now(), event.ShipmentID, event.OccurredAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetTrackingEvents lists the tracking updates of a shipment, oldest first.
+//
+// Parameters:
+//   - shipmentID: The ID of the shipment.
+//
+// Returns:
+//   - []TrackingEvent: The recorded updates.
+//   - error: An error if the query fails.
+func (store *DBShipmentStore) GetTrackingEvents(ctx context.Context, shipmentID int) ([]models.TrackingEvent, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, shipment_id, tracking_number, status, description, location, occurred_at
+		 FROM shipment_tracking_events WHERE shipment_id = $1 ORDER BY occurred_at, id`,
+		shipmentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.TrackingEvent{}
+	for rows.Next() {
+		var e models.TrackingEvent
+		if err := rows.Scan(&e.ID, &e.ShipmentID, &e.TrackingNumber, &e.Status, &e.Description, &e.Location, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// scanShipment reads a shipment row selected with shipmentColumns.
+func scanShipment(row *sql.Row) (*models.Shipment, error) {
+	var s models.Shipment
+	var salesOrderID sql.NullInt64
+	var from, to []byte
+	var carrier, service, trackingNumber, labelURL, currency sql.NullString
+	var cost sql.NullFloat64
+	err := row.Scan(&s.ID, &salesOrderID, &from, &to, &s.WeightKg, &s.Status, &carrier, &service,
+		&trackingNumber, &labelURL, &cost, &currency, &s.CreatedAt, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if salesOrderID.Valid {
+		id := int(salesOrderID.Int64)
+		s.SalesOrderID = &id
+	}
+	if err := json.Unmarshal(from, &s.FromAddress); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &s.ToAddress); err != nil {
+		return nil, err
+	}
+	s.Carrier, s.Service, s.TrackingNumber = carrier.String, service.String, trackingNumber.String
+	s.LabelURL, s.Cost, s.Currency = labelURL.String, cost.Float64, currency.String
+	return &s, nil
+}
+
+// GetPackingList returns the items packed in a shipment, read from its sales order.
+//
+// Parameters:
+//   - shipmentID: The ID of the shipment.
+//
+// Returns:
+//   - []PackingItem: The packed items; empty if the shipment has no sales order.
+//   - error: An error if the query fails.
+func (store *DBShipmentStore) GetPackingList(ctx context.Context, shipmentID int) ([]models.PackingItem, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT so.product_id, COALESCE(p.name, ''), so.quantity
+		 FROM shipments s
+		 JOIN sales_orders so ON so.id = s.sales_order_id
+		 LEFT JOIN products p ON p.id = so.product_id
+		 WHERE s.id = $1`,
+		shipmentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.PackingItem{}
+	for rows.Next() {
+		var item models.PackingItem
+		var productID sql.NullInt64
+		if err := rows.Scan(&productID, &item.Name, &item.Quantity); err != nil {
+			return nil, err
+		}
+		if productID.Valid {
+			id := int(productID.Int64)
+			item.ProductID = &id
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
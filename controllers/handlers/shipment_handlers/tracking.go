@@ -0,0 +1,41 @@
+package shipment_handlers
+
+import (
+	"context"
+	"erp/controllers/shipping"
+	"erp/models"
+	"fmt"
+)
+
+// TrackingProcessor applies carrier tracking webhooks to shipments. It is registered as the
+// webhook processor for the "shipping" module; the integration's name selects the carrier
+// that parses the payload.
+type TrackingProcessor struct {
+	Store    models.ShipmentStore
+	Carriers shipping.Carriers
+}
+
+// Process records every tracking update in the event. Updates that were already recorded
+// are skipped, so redelivered webhooks are harmless.
+func (p *TrackingProcessor) Process(ctx context.Context, integration *models.WebhookIntegration, event *models.WebhookEvent) error {
+	carrier, ok := p.Carriers[integration.Name]
+	if !ok {
+		return fmt.Errorf("no carrier configured for integration %q", integration.Name)
+	}
+
+	updates, err := carrier.ParseWebhook([]byte(event.Payload))
+	if err != nil {
+		return err
+	}
+	for _, update := range updates {
+		shipment, err := p.Store.GetShipmentByTracking(ctx, carrier.Name(), update.TrackingNumber)
+		if err != nil {
+			return fmt.Errorf("shipment with tracking number %s: %w", update.TrackingNumber, err)
+		}
+		update.ShipmentID = shipment.ID
+		if _, err := p.Store.AddTrackingEvent(ctx, &update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
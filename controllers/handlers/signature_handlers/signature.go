@@ -0,0 +1,201 @@
+// Package signature_handlers provides HTTP handlers for sending documents to customers for
+// acceptance and for the public signing page the customers' links point to.
+package signature_handlers
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/esign"
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SignatureHandlers provides the signature request endpoints.
+type SignatureHandlers struct {
+	Service *esign.Service
+}
+
+// PublicSignature is what the public signing page shows about a request. The owner and
+// the signer's addresses are left out.
+type PublicSignature struct {
+	Status     string                   `json:"status"`
+	Expired    bool                     `json:"expired"`
+	ExpiresAt  time.Time                `json:"expires_at"`
+	Document   *models.SignableDocument `json:"document,omitempty"`
+	SignerName string                   `json:"signer_name,omitempty"`
+	SignedAt   *time.Time               `json:"signed_at,omitempty"`
+}
+
+// newPublicSignature returns the public view of a request.
+func newPublicSignature(request *models.SignatureRequest, document *models.SignableDocument) PublicSignature {
+	return PublicSignature{
+		Status:     request.Status,
+		Expired:    request.Status == models.SignaturePending && !request.ExpiresAt.After(time.Now()),
+		ExpiresAt:  request.ExpiresAt,
+		Document:   document,
+		SignerName: request.SignerName,
+		SignedAt:   request.SignedAt,
+	}
+}
+
+// CreateSignatureRequest returns a handler that sends a document of the given type for
+// acceptance. The response contains the signed link to give to the customer.
+//
+// HTTP Method: POST
+// URL Path: /invoices/{id}/signature_requests
+//
+// Response:
+//   - Status Code: 201 (Created) with the request, including its link, in JSON format.
+//   - Status Code: 400 (Bad Request) if the document ID is invalid.
+//   - Status Code: 404 (Not Found) if the document does not exist.
+//   - Status Code: 500 (Internal Server Error) if the request could not be created.
+func (h *SignatureHandlers) CreateSignatureRequest(documentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		documentID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid document ID")
+			return
+		}
+
+		owner, _ := middleware.GetUserEmailFromContext(r.Context())
+		request, err := h.Service.Request(r.Context(), documentType, documentID, owner)
+		if err != nil {
+			httperr.Write(w, err, "Failed to create signature request")
+			return
+		}
+
+		respond.JSON(w, http.StatusCreated, request)
+	}
+}
+
+// ListSignatureRequests returns a handler that lists the signature requests of a document
+// of the given type, with who signed them, when and from where.
+//
+// HTTP Method: GET
+// URL Path: /invoices/{id}/signature_requests
+//
+// Response:
+//   - Status Code: 200 (OK) with a JSON array of requests, newest first.
+//   - Status Code: 400 (Bad Request) if the document ID is invalid.
+//   - Status Code: 500 (Internal Server Error) if the requests could not be loaded.
+func (h *SignatureHandlers) ListSignatureRequests(documentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		documentID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid document ID")
+			return
+		}
+
+		requests, err := h.Service.List(r.Context(), documentType, documentID)
+		if err != nil {
+			httperr.Write(w, err, "Failed to load signature requests")
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, requests)
+	}
+}
+
+// GetSignatureRequest returns a signature request by ID.
+//
+// HTTP Method: GET
+// URL Path: /signature_requests/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the request in JSON format.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the request does not exist.
+//   - Status Code: 500 (Internal Server Error) if the request could not be loaded.
+func (h *SignatureHandlers) GetSignatureRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid signature request ID")
+		return
+	}
+
+	request, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load signature request")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, request)
+}
+
+// GetPublicSignature returns the document summary and status behind a signing link. It is
+// public: the signed token in the URL is the customer's authorization.
+//
+// HTTP Method: GET
+// URL Path: /public/signatures/{token}
+//
+// Response:
+//   - Status Code: 200 (OK) with the document summary and request status in JSON format.
+//   - Status Code: 404 (Not Found) if the link is not valid.
+//   - Status Code: 500 (Internal Server Error) if the document could not be loaded.
+func (h *SignatureHandlers) GetPublicSignature(w http.ResponseWriter, r *http.Request) {
+	request, document, err := h.Service.Open(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to load document")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, newPublicSignature(request, document))
+}
+
+// AcceptPublicSignature accepts the document behind a signing link. The signer's name,
+// the time, the client address and the signature image are recorded, and the user who
+// sent the request is notified.
+//
+// HTTP Method: POST
+// URL Path: /public/signatures/{token}
+//
+// Request Body:
+//   - multipart/form-data with "signer_name" and a PNG or JPEG image in "signature".
+//
+// Response:
+//   - Status Code: 200 (OK) with the accepted request's public view in JSON format.
+//   - Status Code: 400 (Bad Request) if the form or image is missing or too large.
+//   - Status Code: 404 (Not Found) if the link is not valid.
+//   - Status Code: 409 (Conflict) if the link has expired or the document was already accepted.
+//   - Status Code: 422 (Unprocessable Entity) if the name is missing or the image is not PNG or JPEG.
+//   - Status Code: 500 (Internal Server Error) if the acceptance could not be recorded.
+func (h *SignatureHandlers) AcceptPublicSignature(w http.ResponseWriter, r *http.Request) {
+	maxSize := h.Service.Config.MaxImageSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20) // Allow for multipart overhead
+	file, _, err := r.FormFile("signature")
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "A signature image is required in the \"signature\" field")
+		return
+	}
+	defer file.Close()
+	image, err := storage.ReadAll(file, maxSize)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	request, err := h.Service.Accept(r.Context(), mux.Vars(r)["token"], esign.Signer{
+		Name:         r.FormValue("signer_name"),
+		IP:           ip,
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		Image:        image,
+	})
+	if err != nil {
+		httperr.Write(w, err, "Failed to accept document")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, newPublicSignature(request, nil))
+}
@@ -0,0 +1,148 @@
+package signature_handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/controllers/esign"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var requestRows = []string{"id", "document_type", "document_id", "owner", "status", "expires_at", "created_at",
+	"signer_name", "signer_ip", "forwarded_for", "signature_key", "signed_at"}
+
+// newSignatureRouter serves the public signing routes from a DBSignatureStore on a mock database.
+func newSignatureRouter(t *testing.T) (*mux.Router, *esign.Service, *storage.MemoryStorage, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	files := storage.NewMemoryStorage()
+	service := esign.NewService(&DBSignatureStore{DB: db}, files, config.SignatureConfig{
+		Secret: "test-secret", BaseURL: "http://localhost:8080", LinkTTL: time.Hour, MaxImageSize: 1 << 10,
+	})
+	h := &SignatureHandlers{Service: service}
+	router := mux.NewRouter()
+	router.HandleFunc("/public/signatures/{token}", h.GetPublicSignature).Methods("GET")
+	router.HandleFunc("/public/signatures/{token}", h.AcceptPublicSignature).Methods("POST")
+	return router, service, files, mock
+}
+
+// signatureForm returns a multipart body with a signer name and a PNG signature image.
+func signatureForm(t *testing.T, name string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	require.NoError(t, form.WriteField("signer_name", name))
+	part, err := form.CreateFormFile("signature", "signature.png")
+	require.NoError(t, err)
+	part.Write([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	require.NoError(t, form.Close())
+	return body, form.FormDataContentType()
+}
+
+func TestAcceptPublicSignature(t *testing.T) {
+	router, service, files, mock := newSignatureRouter(t)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := service.Token(&models.SignatureRequest{ID: 3, ExpiresAt: expiresAt})
+	pending := sqlmock.NewRows(requestRows).
+		AddRow(3, "invoice", 7, "owner@example.com", models.SignaturePending, expiresAt, time.Now(), nil, nil, nil, nil, nil)
+
+	mock.ExpectQuery("SELECT id, document_type, document_id, owner, status").WithArgs(3).WillReturnRows(pending)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, expires_at FROM signature_requests WHERE id = \\$1 FOR UPDATE").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "expires_at"}).AddRow(models.SignaturePending, expiresAt))
+	mock.ExpectExec("UPDATE signature_requests").
+		WithArgs(models.SignatureAccepted, "Jane Doe", "203.0.113.9", "198.51.100.1", sqlmock.AnyArg(), sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	body, contentType := signatureForm(t, "Jane Doe")
+	req := httptest.NewRequest("POST", "/public/signatures/"+token, body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.RemoteAddr = "203.0.113.9:51234"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var view PublicSignature
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&view))
+	assert.Equal(t, models.SignatureAccepted, view.Status)
+	assert.Equal(t, "Jane Doe", view.SignerName)
+	assert.NotNil(t, view.SignedAt)
+	assert.NotContains(t, rec.Body.String(), "owner@example.com")
+	assert.Len(t, files.Files, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcceptPublicSignatureRejections(t *testing.T) {
+	router, service, files, mock := newSignatureRouter(t)
+	expiresAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	token := service.Token(&models.SignatureRequest{ID: 3, ExpiresAt: expiresAt})
+
+	// An expired link is refused by the store and its image is removed again
+	mock.ExpectQuery("SELECT id, document_type, document_id, owner, status").WithArgs(3).WillReturnRows(
+		sqlmock.NewRows(requestRows).AddRow(3, "invoice", 7, "owner@example.com", models.SignaturePending, expiresAt, time.Now(), nil, nil, nil, nil, nil))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, expires_at FROM signature_requests").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "expires_at"}).AddRow(models.SignaturePending, expiresAt))
+	mock.ExpectRollback()
+
+	body, contentType := signatureForm(t, "Jane Doe")
+	req := httptest.NewRequest("POST", "/public/signatures/"+token, body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Empty(t, files.Files)
+
+	// A forged link never reaches the database
+	body, contentType = signatureForm(t, "Jane Doe")
+	req = httptest.NewRequest("POST", "/public/signatures/3.1700000000.forged", body)
+	req.Header.Set("Content-Type", contentType)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// A request without an image is malformed
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/public/signatures/"+token, nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPublicSignature(t *testing.T) {
+	router, service, _, mock := newSignatureRouter(t)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := service.Token(&models.SignatureRequest{ID: 3, ExpiresAt: expiresAt})
+
+	mock.ExpectQuery("SELECT id, document_type, document_id, owner, status").WithArgs(3).WillReturnRows(
+		sqlmock.NewRows(requestRows).AddRow(3, "invoice", 7, "owner@example.com", models.SignaturePending, expiresAt, time.Now(), nil, nil, nil, nil, nil))
+	mock.ExpectQuery("FROM invoices i LEFT JOIN customers c").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "amount", "status"}).AddRow(7, "Acme", 120.5, "posted"))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/public/signatures/"+token, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var view PublicSignature
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&view))
+	assert.Equal(t, models.SignaturePending, view.Status)
+	assert.False(t, view.Expired)
+	require.NotNil(t, view.Document)
+	assert.Equal(t, "Acme", view.Document.CustomerName)
+	assert.Equal(t, 120.5, view.Document.Amount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
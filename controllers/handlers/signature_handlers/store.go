@@ -0,0 +1,186 @@
+package signature_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/controllers/events"
+	"erp/models"
+)
+
+// documentQueries are the queries reading each signable document type's summary. They
+// take the document ID and select the columns of a models.SignableDocument after Type.
+var documentQueries = map[string]string{
+	"invoice": `SELECT i.id, COALESCE(c.name, ''), i.amount, COALESCE(i.status, '')
+	            FROM invoices i LEFT JOIN customers c ON c.id = i.customer_id
+	            WHERE i.id = $1`,
+}
+
+// signatureColumns are the signature_requests columns read by scanSignatureRequest.
+const signatureColumns = `id, document_type, document_id, owner, status, expires_at, created_at,
+	signer_name, signer_ip, forwarded_for, signature_key, signed_at`
+
+// DBSignatureStore implements models.SignatureStore using a SQL database.
+type DBSignatureStore struct {
+	DB *sql.DB
+}
+
+// CreateSignatureRequest stores a pending signature request for an existing document.
+//
+// Parameters:
+//   - request: The request to store; its ID is populated.
+//
+// Returns:
+//   - error: models.ErrNotFound if the document does not exist, a validation error for an
+//     unsupported document type, or the query error.
+func (s *DBSignatureStore) CreateSignatureRequest(ctx context.Context, request *models.SignatureRequest) error {
+	if _, err := s.GetSignableDocument(ctx, request.DocumentType, request.DocumentID); err != nil {
+		return err
+	}
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO signature_requests (document_type, document_id, owner, status, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		request.DocumentType, request.DocumentID, request.Owner, request.Status, request.ExpiresAt, request.CreatedAt,
+	).Scan(&request.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create signature request: %w", err)
+	}
+	return nil
+}
+
+// GetSignatureRequest returns a signature request by ID.
+//
+// Returns:
+//   - *models.SignatureRequest: The request.
+//   - error: models.ErrNotFound if it does not exist, or the query error.
+func (s *DBSignatureStore) GetSignatureRequest(ctx context.Context, id int) (*models.SignatureRequest, error) {
+	request, err := scanSignatureRequest(s.DB.QueryRowContext(ctx, "SELECT "+signatureColumns+" FROM signature_requests WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("signature request %d not found", id)
+	}
+	return request, err
+}
+
+// ListSignatureRequests returns the signature requests for a document, newest first.
+//
+// Returns:
+//   - []models.SignatureRequest: The requests; empty if there are none.
+//   - error: An error if the query fails.
+func (s *DBSignatureStore) ListSignatureRequests(ctx context.Context, documentType string, documentID int) ([]models.SignatureRequest, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT "+signatureColumns+" FROM signature_requests WHERE document_type = $1 AND document_id = $2 ORDER BY created_at DESC, id DESC",
+		documentType, documentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signature requests: %w", err)
+	}
+	defer rows.Close()
+	requests := []models.SignatureRequest{}
+	for rows.Next() {
+		request, err := scanSignatureRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *request)
+	}
+	return requests, rows.Err()
+}
+
+// GetSignableDocument returns the summary of a document shown to the signer.
+//
+// Returns:
+//   - *models.SignableDocument: The summary.
+//   - error: models.ErrNotFound if the document does not exist, a validation error for an
+//     unsupported document type, or the query error.
+func (s *DBSignatureStore) GetSignableDocument(ctx context.Context, documentType string, documentID int) (*models.SignableDocument, error) {
+	query, ok := documentQueries[documentType]
+	if !ok {
+		return nil, models.Invalid("documents of type %q cannot be signed", documentType)
+	}
+	document := &models.SignableDocument{Type: documentType}
+	err := s.DB.QueryRowContext(ctx, query, documentID).Scan(&document.ID, &document.CustomerName, &document.Amount, &document.Status)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("%s %d not found", documentType, documentID)
+	} else if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// AcceptSignatureRequest records the signer of a pending request and marks it accepted.
+// The request is locked while it is checked, so two acceptances cannot both succeed, and
+// the DocumentAccepted event is written to the outbox in the same transaction.
+//
+// Parameters:
+//   - request: The request with the signer's name, addresses and signature key set; its
+//     status and signing time are updated.
+//   - now: The time of acceptance.
+//
+// Returns:
+//   - error: models.ErrAlreadySigned, models.ErrSignatureLinkExpired, or the query error.
+func (s *DBSignatureStore) AcceptSignatureRequest(ctx context.Context, request *models.SignatureRequest, now time.Time) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var expiresAt time.Time
+	err = tx.QueryRowContext(ctx, "SELECT status, expires_at FROM signature_requests WHERE id = $1 FOR UPDATE", request.ID).Scan(&status, &expiresAt)
+	if err == sql.ErrNoRows {
+		return models.NotFound("signature request %d not found", request.ID)
+	} else if err != nil {
+		return err
+	}
+	if status != models.SignaturePending {
+		return models.ErrAlreadySigned
+	}
+	if !expiresAt.After(now) {
+		return models.ErrSignatureLinkExpired
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE signature_requests
+		 SET status = $1, signer_name = $2, signer_ip = $3, forwarded_for = $4, signature_key = $5, signed_at = $6
+		 WHERE id = $7`,
+		models.SignatureAccepted, request.SignerName, request.SignerIP, request.ForwardedFor, request.SignatureKey, now, request.ID,
+	)
+	if err != nil {
+		return err
+	}
+	request.Status = models.SignatureAccepted
+	request.SignedAt = &now
+
+	if err := events.Enqueue(ctx, tx, events.DocumentAccepted, "signature_request", request.ID, request); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSignatureRequest reads the signatureColumns of a signature request.
+func scanSignatureRequest(row rowScanner) (*models.SignatureRequest, error) {
+	var request models.SignatureRequest
+	var signerName, signerIP, forwardedFor, signatureKey sql.NullString
+	var signedAt sql.NullTime
+	err := row.Scan(&request.ID, &request.DocumentType, &request.DocumentID, &request.Owner, &request.Status,
+		&request.ExpiresAt, &request.CreatedAt, &signerName, &signerIP, &forwardedFor, &signatureKey, &signedAt)
+	if err != nil {
+		return nil, err
+	}
+	request.SignerName = signerName.String
+	request.SignerIP = signerIP.String
+	request.ForwardedFor = forwardedFor.String
+	request.SignatureKey = signatureKey.String
+	if signedAt.Valid {
+		request.SignedAt = &signedAt.Time
+	}
+	return &request, nil
+}
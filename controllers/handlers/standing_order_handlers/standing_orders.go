@@ -0,0 +1,394 @@
+// Package standing_order_handlers provides HTTP handlers and the database store for
+// standing orders: customers' repeating orders, delivered on a schedule, that generate
+// draft sales orders.
+package standing_order_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/standingorders"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// StandingOrderHandler provides HTTP handlers for standing orders. The schedule rules live
+// in the standingorders service; the handlers only translate HTTP.
+type StandingOrderHandler struct {
+	Service *standingorders.Service
+}
+
+// StandingOrderRequest is the request body for creating or replacing a standing order.
+type StandingOrderRequest struct {
+	CustomerID  int                        `json:"customer_id"`
+	Recurrence  string                     `json:"recurrence"`   // weekly, biweekly or monthly
+	DeliveryDay int                        `json:"delivery_day"` // Weekday 0 (Sunday) to 6, or day of the month 1 to 28
+	LeadDays    int                        `json:"lead_days"`
+	StartDate   string                     `json:"start_date"` // YYYY-MM-DD; today, or unchanged, by default
+	EndDate     string                     `json:"end_date"`   // YYYY-MM-DD, optional
+	Note        string                     `json:"note"`
+	Lines       []models.StandingOrderLine `json:"lines"`
+}
+
+// StandingOrder returns the standing order described by the request.
+func (req StandingOrderRequest) StandingOrder() (models.StandingOrder, error) {
+	order := models.StandingOrder{CustomerID: req.CustomerID, Recurrence: req.Recurrence, DeliveryDay: req.DeliveryDay,
+		LeadDays: req.LeadDays, Note: req.Note, Lines: req.Lines}
+	if req.StartDate != "" {
+		date, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			return order, models.Invalid("start_date must be a date formatted as YYYY-MM-DD")
+		}
+		order.StartDate = date
+	}
+	if req.EndDate != "" {
+		date, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			return order, models.Invalid("end_date must be a date formatted as YYYY-MM-DD")
+		}
+		order.EndDate = &date
+	}
+	return order, nil
+}
+
+// RegisterRoutes maps standing order routes to their respective handler functions. The
+// router is expected to be protected with middleware.JWTAuth and limited to sales staff and
+// admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The standing order service.
+func RegisterRoutes(router *mux.Router, service *standingorders.Service) {
+	handler := &StandingOrderHandler{Service: service}
+
+	router.HandleFunc("", handler.CreateStandingOrder).Methods("POST")
+	router.HandleFunc("", handler.ListStandingOrders).Methods("GET")
+	router.HandleFunc("/forecast", handler.Forecast).Methods("GET")
+	router.HandleFunc("/runs", handler.Run).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetStandingOrder).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.UpdateStandingOrder).Methods("PUT")
+	router.HandleFunc("/{id:[0-9]+}", handler.DeleteStandingOrder).Methods("DELETE")
+	router.HandleFunc("/{id:[0-9]+}/pause", handler.PauseStandingOrder).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/resume", handler.ResumeStandingOrder).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/skips", handler.SkipDelivery).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/skips/{date}", handler.UnskipDelivery).Methods("DELETE")
+	router.HandleFunc("/{id:[0-9]+}/forecast", handler.Forecast).Methods("GET")
+}
+
+// CreateStandingOrder records a standing order. Its first sales order is generated when
+// the lead time before its first delivery begins.
+//
+// HTTP Method: POST
+// URL Path: /standing_orders
+//
+// Request Body:
+//   - JSON with customer_id, recurrence (weekly, biweekly or monthly), delivery_day,
+//     lead_days, an optional start_date, end_date and note, and lines of product_id,
+//     quantity and an optional unit_price (see StandingOrderRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the StandingOrder in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the order is incomplete or names a customer
+//     or product that does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be recorded.
+func (h *StandingOrderHandler) CreateStandingOrder(w http.ResponseWriter, r *http.Request) {
+	var req StandingOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	order, err := req.StandingOrder()
+	if err != nil {
+		httperr.Write(w, err, "Invalid standing order")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Create(r.Context(), &order, actor); err != nil {
+		httperr.Write(w, err, "Failed to create standing order")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, order)
+}
+
+// ListStandingOrders lists standing orders with their lines.
+//
+// HTTP Method: GET
+// URL Path: /standing_orders?customer_id=5 (customer_id is optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of StandingOrders in JSON.
+//   - Status Code: 400 (Bad Request) if customer_id is not a number.
+//   - Status Code: 500 (Internal Server Error) if the orders cannot be loaded.
+func (h *StandingOrderHandler) ListStandingOrders(w http.ResponseWriter, r *http.Request) {
+	var customerID int
+	if value := r.URL.Query().Get("customer_id"); value != "" {
+		var err error
+		if customerID, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid customer_id")
+			return
+		}
+	}
+	orders, err := h.Service.List(r.Context(), customerID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load standing orders")
+		return
+	}
+	respond.JSON(w, http.StatusOK, orders)
+}
+
+// GetStandingOrder returns a standing order with its lines.
+//
+// HTTP Method: GET
+// URL Path: /standing_orders/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the StandingOrder in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be loaded.
+func (h *StandingOrderHandler) GetStandingOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	order, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load standing order")
+		return
+	}
+	respond.JSON(w, http.StatusOK, order)
+}
+
+// UpdateStandingOrder replaces a standing order's schedule and lines. Sales orders already
+// generated are not changed; later deliveries follow the new definition.
+//
+// HTTP Method: PUT
+// URL Path: /standing_orders/{id}
+//
+// Request Body:
+//   - JSON as for CreateStandingOrder; without start_date the current one is kept.
+//
+// Response:
+//   - Status Code: 200 (OK) with the StandingOrder in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the order is incomplete or names a customer
+//     or product that does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be saved.
+func (h *StandingOrderHandler) UpdateStandingOrder(w http.ResponseWriter, r *http.Request) {
+	var req StandingOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	order, err := req.StandingOrder()
+	if err != nil {
+		httperr.Write(w, err, "Invalid standing order")
+		return
+	}
+	order.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	updated, err := h.Service.Update(r.Context(), &order)
+	if err != nil {
+		httperr.Write(w, err, "Failed to update standing order")
+		return
+	}
+	respond.JSON(w, http.StatusOK, updated)
+}
+
+// DeleteStandingOrder removes a standing order. The sales orders it generated are kept.
+//
+// HTTP Method: DELETE
+// URL Path: /standing_orders/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the order was removed.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be removed.
+func (h *StandingOrderHandler) DeleteStandingOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete standing order")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PauseStandingOrder stops a standing order's deliveries until it is resumed, or until a
+// given day.
+//
+// HTTP Method: POST
+// URL Path: /standing_orders/{id}/pause
+//
+// Request Body:
+//   - Optional JSON object with "until": the day, YYYY-MM-DD, deliveries start again.
+//
+// Response:
+//   - Status Code: 200 (OK) with the StandingOrder in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if until is not a date after today.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be paused.
+func (h *StandingOrderHandler) PauseStandingOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Until string `json:"until"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	var until *time.Time
+	if req.Until != "" {
+		date, err := time.Parse("2006-01-02", req.Until)
+		if err != nil {
+			respond.Error(w, http.StatusUnprocessableEntity, "until must be a date formatted as YYYY-MM-DD")
+			return
+		}
+		until = &date
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	order, err := h.Service.Pause(r.Context(), id, until)
+	if err != nil {
+		httperr.Write(w, err, "Failed to pause standing order")
+		return
+	}
+	respond.JSON(w, http.StatusOK, order)
+}
+
+// ResumeStandingOrder makes a paused standing order's deliveries again.
+//
+// HTTP Method: POST
+// URL Path: /standing_orders/{id}/resume
+//
+// Response:
+//   - Status Code: 200 (OK) with the StandingOrder in JSON.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 500 (Internal Server Error) if the order cannot be resumed.
+func (h *StandingOrderHandler) ResumeStandingOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	order, err := h.Service.Resume(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to resume standing order")
+		return
+	}
+	respond.JSON(w, http.StatusOK, order)
+}
+
+// SkipDelivery skips a single upcoming delivery of a standing order.
+//
+// HTTP Method: POST
+// URL Path: /standing_orders/{id}/skips
+//
+// Request Body:
+//   - JSON object with "delivery_date" (YYYY-MM-DD) and an optional "reason".
+//
+// Response:
+//   - Status Code: 201 (Created) with the StandingOrderSkip in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the order does not exist.
+//   - Status Code: 409 (Conflict) if the delivery is already skipped or its sales order
+//     has been generated.
+//   - Status Code: 422 (Unprocessable Entity) if no delivery falls on the day or it is past.
+//   - Status Code: 500 (Internal Server Error) if the skip cannot be recorded.
+func (h *StandingOrderHandler) SkipDelivery(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DeliveryDate string `json:"delivery_date"`
+		Reason       string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	date, err := time.Parse("2006-01-02", req.DeliveryDate)
+	if err != nil {
+		respond.Error(w, http.StatusUnprocessableEntity, "delivery_date must be a date formatted as YYYY-MM-DD")
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	skip, err := h.Service.Skip(r.Context(), id, date, req.Reason, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to skip delivery")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, skip)
+}
+
+// UnskipDelivery restores a skipped delivery.
+//
+// HTTP Method: DELETE
+// URL Path: /standing_orders/{id}/skips/{date} (date formatted as YYYY-MM-DD)
+//
+// Response:
+//   - Status Code: 204 (No Content) if the delivery is made again.
+//   - Status Code: 404 (Not Found) if the delivery was not skipped.
+//   - Status Code: 422 (Unprocessable Entity) if the date is invalid.
+//   - Status Code: 500 (Internal Server Error) if the skip cannot be removed.
+func (h *StandingOrderHandler) UnskipDelivery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	date, err := time.Parse("2006-01-02", vars["date"])
+	if err != nil {
+		respond.Error(w, http.StatusUnprocessableEntity, "The date must be formatted as YYYY-MM-DD")
+		return
+	}
+	id, _ := strconv.Atoi(vars["id"])
+	if err := h.Service.Unskip(r.Context(), id, date); err != nil {
+		httperr.Write(w, err, "Failed to restore delivery")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Forecast lists the deliveries of the coming days, of every standing order or of one,
+// with whether each is scheduled, skipped, paused or already generated.
+//
+// HTTP Method: GET
+// URL Path: /standing_orders/forecast?days=28 or /standing_orders/{id}/forecast?days=28
+// (days is optional; 28 by default, at most 366)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of StandingDeliveries in JSON, by delivery date.
+//   - Status Code: 400 (Bad Request) if days is not a number.
+//   - Status Code: 404 (Not Found) if the standing order does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if days is out of range.
+//   - Status Code: 500 (Internal Server Error) if the forecast cannot be made.
+func (h *StandingOrderHandler) Forecast(w http.ResponseWriter, r *http.Request) {
+	var days int
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid days")
+			return
+		}
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	deliveries, err := h.Service.Forecast(r.Context(), id, days)
+	if err != nil {
+		httperr.Write(w, err, "Failed to forecast deliveries")
+		return
+	}
+	respond.JSON(w, http.StatusOK, deliveries)
+}
+
+// Run generates the sales orders of deliveries whose lead time has begun. It does what the
+// daily run does, for use after adding or changing standing orders.
+//
+// HTTP Method: POST
+// URL Path: /standing_orders/runs
+//
+// Response:
+//   - Status Code: 200 (OK) with the list of draft SalesOrders generated in JSON.
+//   - Status Code: 500 (Internal Server Error) if any order cannot be generated; the others are.
+func (h *StandingOrderHandler) Run(w http.ResponseWriter, r *http.Request) {
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	orders, err := h.Service.Generate(r.Context(), actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to generate standing orders")
+		return
+	}
+	respond.JSON(w, http.StatusOK, orders)
+}
@@ -0,0 +1,300 @@
+package standing_order_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBStandingOrderStore implements models.StandingOrderStore using a SQL database. The sales
+// orders a standing order generated are found by their standing_order_id and delivery_date.
+type DBStandingOrderStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// standingOrderColumns are the columns of standing_orders read by query.
+const standingOrderColumns = `id, customer_id, recurrence, delivery_day, lead_days, start_date, end_date, status,
+	paused_until, note, created_by, created_at, updated_at`
+
+// CreateStandingOrder records a standing order with its lines.
+//
+// Parameters:
+//   - order: A validated order; its ID is set.
+//
+// Returns:
+//   - error: A validation error if the customer or a product does not exist, or the query error.
+func (s *DBStandingOrderStore) CreateStandingOrder(ctx context.Context, order *models.StandingOrder) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO standing_orders (customer_id, recurrence, delivery_day, lead_days, start_date, end_date, status,
+		 note, created_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+		order.CustomerID, order.Recurrence, order.DeliveryDay, order.LeadDays, order.StartDate, order.EndDate,
+		order.Status, order.Note, order.CreatedBy, order.CreatedAt, order.UpdatedAt,
+	).Scan(&order.ID)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("customer %d does not exist", order.CustomerID)
+	} else if err != nil {
+		return fmt.Errorf("failed to record standing order: %w", err)
+	}
+	if err := insertLines(ctx, tx, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetStandingOrder retrieves a standing order with its lines.
+//
+// Parameters:
+//   - id: The ID of the standing order.
+//
+// Returns:
+//   - *models.StandingOrder: The standing order.
+//   - error: models.ErrNotFound if it does not exist, or the query error.
+func (s *DBStandingOrderStore) GetStandingOrder(ctx context.Context, id int) (*models.StandingOrder, error) {
+	orders, err := s.query(ctx, `SELECT `+standingOrderColumns+` FROM standing_orders WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, models.NotFound("standing order %d not found", id)
+	}
+	return &orders[0], nil
+}
+
+// ListStandingOrders retrieves the standing orders of a customer, or of every customer if
+// customerID is 0, with their lines.
+func (s *DBStandingOrderStore) ListStandingOrders(ctx context.Context, customerID int) ([]models.StandingOrder, error) {
+	return s.query(ctx, `SELECT `+standingOrderColumns+` FROM standing_orders
+		WHERE $1 = 0 OR customer_id = $1 ORDER BY id`, customerID)
+}
+
+// UpdateStandingOrder replaces a standing order's schedule, note and lines.
+//
+// Returns:
+//   - error: models.ErrNotFound if the order does not exist, a validation error if the
+//     customer or a product does not exist, or the query error.
+func (s *DBStandingOrderStore) UpdateStandingOrder(ctx context.Context, order *models.StandingOrder) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE standing_orders SET customer_id = $2, recurrence = $3, delivery_day = $4, lead_days = $5,
+		 start_date = $6, end_date = $7, note = $8, updated_at = $9 WHERE id = $1`,
+		order.ID, order.CustomerID, order.Recurrence, order.DeliveryDay, order.LeadDays, order.StartDate,
+		order.EndDate, order.Note, order.UpdatedAt,
+	)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("customer %d does not exist", order.CustomerID)
+	} else if err != nil {
+		return fmt.Errorf("failed to update standing order: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return models.NotFound("standing order %d not found", order.ID)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM standing_order_lines WHERE standing_order_id = $1`, order.ID); err != nil {
+		return err
+	}
+	if err := insertLines(ctx, tx, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteStandingOrder removes a standing order with its lines and skips. The sales orders
+// it generated are kept.
+func (s *DBStandingOrderStore) DeleteStandingOrder(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM standing_orders WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return models.NotFound("standing order %d not found", id)
+	}
+	return nil
+}
+
+// SetStandingOrderStatus pauses or resumes a standing order.
+//
+// Parameters:
+//   - id: The ID of the standing order.
+//   - status: models.StandingOrderActive or models.StandingOrderPaused.
+//   - pausedUntil: The day a paused order delivers again, or nil.
+//
+// Returns:
+//   - *models.StandingOrder: The standing order.
+//   - error: models.ErrNotFound if it does not exist, or the query error.
+func (s *DBStandingOrderStore) SetStandingOrderStatus(ctx context.Context, id int, status string, pausedUntil *time.Time) (*models.StandingOrder, error) {
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE standing_orders SET status = $2, paused_until = $3, updated_at = now() WHERE id = $1`,
+		id, status, pausedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, models.NotFound("standing order %d not found", id)
+	}
+	return s.GetStandingOrder(ctx, id)
+}
+
+// SkipDelivery records a skipped delivery.
+//
+// Returns:
+//   - error: A conflict if the delivery is already skipped, or the query error.
+func (s *DBStandingOrderStore) SkipDelivery(ctx context.Context, skip *models.StandingOrderSkip) error {
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO standing_order_skips (standing_order_id, delivery_date, reason, skipped_by, skipped_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		skip.StandingOrderID, skip.DeliveryDate, skip.Reason, skip.SkippedBy, skip.SkippedAt)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("the delivery on %s is already skipped", skip.DeliveryDate.Format("2006-01-02"))
+	}
+	return err
+}
+
+// UnskipDelivery removes a skip.
+//
+// Returns:
+//   - error: models.ErrNotFound if the delivery was not skipped, or the query error.
+func (s *DBStandingOrderStore) UnskipDelivery(ctx context.Context, standingOrderID int, deliveryDate time.Time) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM standing_order_skips WHERE standing_order_id = $1 AND delivery_date = $2`,
+		standingOrderID, deliveryDate)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return models.NotFound("the delivery on %s is not skipped", deliveryDate.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// RecordedDeliveries retrieves the skipped deliveries and the sales orders generated for
+// deliveries from from to to, inclusive.
+//
+// Parameters:
+//   - standingOrderID: The standing order, or 0 for every standing order.
+//   - from: The first delivery day.
+//   - to: The last delivery day.
+//
+// Returns:
+//   - []models.StandingDelivery: The deliveries, with their status and sales order or
+//     reason; lines are not read.
+//   - error: The query error.
+func (s *DBStandingOrderStore) RecordedDeliveries(ctx context.Context, standingOrderID int, from, to time.Time) ([]models.StandingDelivery, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT standing_order_id, delivery_date, 'skipped', NULL::int, reason FROM standing_order_skips
+		 WHERE ($1 = 0 OR standing_order_id = $1) AND delivery_date BETWEEN $2 AND $3
+		 UNION ALL
+		 SELECT standing_order_id, delivery_date, 'generated', id, '' FROM sales_orders
+		 WHERE standing_order_id IS NOT NULL AND ($1 = 0 OR standing_order_id = $1) AND delivery_date BETWEEN $2 AND $3`,
+		standingOrderID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []models.StandingDelivery{}
+	for rows.Next() {
+		var delivery models.StandingDelivery
+		var salesOrderID sql.NullInt64
+		if err := rows.Scan(&delivery.StandingOrderID, &delivery.DeliveryDate, &delivery.Status, &salesOrderID,
+			&delivery.Reason); err != nil {
+			return nil, err
+		}
+		if salesOrderID.Valid {
+			id := int(salesOrderID.Int64)
+			delivery.SalesOrderID = &id
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// query reads standing orders and then their lines.
+func (s *DBStandingOrderStore) query(ctx context.Context, query string, args ...interface{}) ([]models.StandingOrder, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.StandingOrder{}
+	index := map[int]int{}
+	for rows.Next() {
+		var o models.StandingOrder
+		var endDate, pausedUntil sql.NullTime
+		var createdBy sql.NullString
+		err := rows.Scan(&o.ID, &o.CustomerID, &o.Recurrence, &o.DeliveryDay, &o.LeadDays, &o.StartDate, &endDate,
+			&o.Status, &pausedUntil, &o.Note, &createdBy, &o.CreatedAt, &o.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		o.EndDate, o.PausedUntil, o.CreatedBy = timePtr(endDate), timePtr(pausedUntil), createdBy.String
+		o.Lines = []models.StandingOrderLine{}
+		index[o.ID] = len(orders)
+		orders = append(orders, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	ids := make(pq.Int64Array, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, int64(order.ID))
+	}
+	rows, err = s.DB.QueryContext(ctx,
+		`SELECT standing_order_id, product_id, quantity, unit_price FROM standing_order_lines
+		 WHERE standing_order_id = ANY($1) ORDER BY standing_order_id, product_id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orderID int
+		var line models.StandingOrderLine
+		if err := rows.Scan(&orderID, &line.ProductID, &line.Quantity, &line.UnitPrice); err != nil {
+			return nil, err
+		}
+		order := &orders[index[orderID]]
+		order.Lines = append(order.Lines, line)
+	}
+	return orders, rows.Err()
+}
+
+// insertLines records the lines of a standing order.
+func insertLines(ctx context.Context, tx *sql.Tx, order *models.StandingOrder) error {
+	for _, line := range order.Lines {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO standing_order_lines (standing_order_id, product_id, quantity, unit_price) VALUES ($1, $2, $3, $4)`,
+			order.ID, line.ProductID, line.Quantity, line.UnitPrice)
+		if models.IsForeignKeyViolation(err) {
+			return models.Invalid("product %d does not exist", line.ProductID)
+		} else if err != nil {
+			return fmt.Errorf("failed to record standing order lines: %w", err)
+		}
+	}
+	return nil
+}
+
+// timePtr returns the time, or nil if it is NULL.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,54 @@
+package standing_order_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordedDeliveriesReadsSkipsAndSalesOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStandingOrderStore{DB: db}
+	from := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 27)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM standing_order_skips")).WithArgs(3, from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"standing_order_id", "delivery_date", "status", "id", "reason"}).
+			AddRow(3, from.AddDate(0, 0, 2), models.DeliveryGenerated, 41, "").
+			AddRow(3, from.AddDate(0, 0, 9), models.DeliverySkipped, nil, "Closed"))
+
+	deliveries, err := store.RecordedDeliveries(context.Background(), 3, from, to)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, 41, *deliveries[0].SalesOrderID)
+	assert.Nil(t, deliveries[1].SalesOrderID)
+	assert.Equal(t, "Closed", deliveries[1].Reason)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSkipDeliveryTwiceConflicts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStandingOrderStore{DB: db}
+	skip := &models.StandingOrderSkip{StandingOrderID: 3, DeliveryDate: time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC),
+		SkippedBy: "sales@example.com", SkippedAt: time.Now()}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO standing_order_skips")).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	err = store.SkipDelivery(context.Background(), skip)
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.Contains(t, err.Error(), "2026-10-26 is already skipped")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,253 @@
+// Package statutory_handlers provides HTTP handlers and the database store for statutory
+// payroll deductions, the deductions recorded for each employee and period, and remittance
+// reports.
+package statutory_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/statutory"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// StatutoryHandler provides HTTP handlers for statutory deductions.
+type StatutoryHandler struct {
+	Service *statutory.Service
+}
+
+// RecordRequest is the request body for recording an employee's deductions for a period.
+type RecordRequest struct {
+	UserID int     `json:"user_id"`
+	Period string  `json:"period"` // e.g. "2024-05"
+	Gross  float64 `json:"gross"`
+}
+
+// PreviewRequest is the request body for working out deductions without recording them.
+type PreviewRequest struct {
+	Gross float64 `json:"gross"`
+}
+
+// RegisterRoutes maps statutory deduction routes to their respective handler functions. The
+// router is expected to be protected with middleware.JWTAuth and limited to accountants and
+// admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The statutory deduction service.
+func RegisterRoutes(router *mux.Router, service *statutory.Service) {
+	handler := &StatutoryHandler{Service: service}
+
+	router.HandleFunc("/deductions", handler.ListDeductions).Methods("GET")
+	router.HandleFunc("/deductions", handler.CreateDeduction).Methods("POST")
+	router.HandleFunc("/deductions/{id:[0-9]+}", handler.UpdateDeduction).Methods("PUT")
+	router.HandleFunc("/deductions/{id:[0-9]+}", handler.DeleteDeduction).Methods("DELETE")
+	router.HandleFunc("/preview", handler.Preview).Methods("POST")
+	router.HandleFunc("/records", handler.ListRecords).Methods("GET")
+	router.HandleFunc("/records", handler.Record).Methods("POST")
+	router.HandleFunc("/remittances/{period}", handler.Remittance).Methods("GET")
+}
+
+// ListDeductions returns every statutory deduction.
+//
+// HTTP Method: GET
+// URL Path: /payroll/statutory/deductions
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of StatutoryDeductions in JSON.
+//   - Status Code: 500 (Internal Server Error) if the deductions cannot be read.
+func (h *StatutoryHandler) ListDeductions(w http.ResponseWriter, r *http.Request) {
+	deductions, err := h.Service.Deductions(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load statutory deductions")
+		return
+	}
+	respond.JSON(w, http.StatusOK, deductions)
+}
+
+// CreateDeduction adds a statutory deduction.
+//
+// HTTP Method: POST
+// URL Path: /payroll/statutory/deductions
+//
+// Request Body:
+//   - JSON object with "code", "name", "kind" ("income_tax", "provident_fund" or
+//     "insurance"), "slabs" (income tax: a list of "up_to" and "rate", the last with up_to
+//     0), "employee_rate", "employer_rate", "ceiling" and "active".
+//
+// Response:
+//   - Status Code: 201 (Created) with the StatutoryDeduction in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the code is taken.
+//   - Status Code: 422 (Unprocessable Entity) if the deduction is incomplete.
+//   - Status Code: 500 (Internal Server Error) if the deduction cannot be saved.
+func (h *StatutoryHandler) CreateDeduction(w http.ResponseWriter, r *http.Request) {
+	var deduction models.StatutoryDeduction
+	if err := json.NewDecoder(r.Body).Decode(&deduction); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.CreateDeduction(r.Context(), &deduction, actor); err != nil {
+		httperr.Write(w, err, "Failed to create statutory deduction")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, deduction)
+}
+
+// UpdateDeduction replaces a statutory deduction. Periods already recorded are not changed.
+//
+// HTTP Method: PUT
+// URL Path: /payroll/statutory/deductions/{id}
+//
+// Request Body:
+//   - JSON object as for CreateDeduction.
+//
+// Response:
+//   - Status Code: 200 (OK) with the StatutoryDeduction in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the deduction does not exist.
+//   - Status Code: 409 (Conflict) if the code is taken.
+//   - Status Code: 422 (Unprocessable Entity) if the deduction is incomplete.
+//   - Status Code: 500 (Internal Server Error) if the deduction cannot be saved.
+func (h *StatutoryHandler) UpdateDeduction(w http.ResponseWriter, r *http.Request) {
+	var deduction models.StatutoryDeduction
+	if err := json.NewDecoder(r.Body).Decode(&deduction); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	deduction.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.UpdateDeduction(r.Context(), &deduction, actor); err != nil {
+		httperr.Write(w, err, "Failed to update statutory deduction")
+		return
+	}
+	respond.JSON(w, http.StatusOK, deduction)
+}
+
+// DeleteDeduction removes a statutory deduction. Periods already recorded are not changed.
+//
+// HTTP Method: DELETE
+// URL Path: /payroll/statutory/deductions/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the deduction was removed.
+//   - Status Code: 404 (Not Found) if the deduction does not exist.
+//   - Status Code: 500 (Internal Server Error) if the deduction cannot be removed.
+func (h *StatutoryHandler) DeleteDeduction(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.Service.DeleteDeduction(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete statutory deduction")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Preview works out the active deductions from a monthly gross wage without recording them.
+//
+// HTTP Method: POST
+// URL Path: /payroll/statutory/preview
+//
+// Request Body:
+//   - JSON object with "gross".
+//
+// Response:
+//   - Status Code: 200 (OK) with the StatutoryRecord in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if gross is negative.
+//   - Status Code: 500 (Internal Server Error) if the deductions cannot be read.
+func (h *StatutoryHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var req PreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	record, err := h.Service.Preview(r.Context(), req.Gross)
+	if err != nil {
+		httperr.Write(w, err, "Failed to preview statutory deductions")
+		return
+	}
+	respond.JSON(w, http.StatusOK, record)
+}
+
+// Record works out an employee's deductions for a pay period with the active deductions
+// and records them, replacing any recorded before for the period.
+//
+// HTTP Method: POST
+// URL Path: /payroll/statutory/records
+//
+// Request Body:
+//   - JSON object with "user_id", "period" (YYYY-MM) and "gross".
+//
+// Response:
+//   - Status Code: 201 (Created) with the StatutoryRecord in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the user does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the period or gross is invalid.
+//   - Status Code: 500 (Internal Server Error) if the record cannot be saved.
+func (h *StatutoryHandler) Record(w http.ResponseWriter, r *http.Request) {
+	var req RecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	record, err := h.Service.Record(r.Context(), req.UserID, req.Period, req.Gross, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to record statutory deductions")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, record)
+}
+
+// ListRecords lists the deductions recorded for a period.
+//
+// HTTP Method: GET
+// URL Path: /payroll/statutory/records?period=2024-05&user_id=1 (user_id is optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of StatutoryRecords in JSON.
+//   - Status Code: 400 (Bad Request) if user_id is not a number.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid.
+//   - Status Code: 500 (Internal Server Error) if the records cannot be read.
+func (h *StatutoryHandler) ListRecords(w http.ResponseWriter, r *http.Request) {
+	var userID int
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		var err error
+		if userID, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+	}
+	records, err := h.Service.Records(r.Context(), r.URL.Query().Get("period"), userID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load statutory records")
+		return
+	}
+	respond.JSON(w, http.StatusOK, records)
+}
+
+// Remittance totals a period's recorded deductions by deduction: what was withheld from
+// employees and what the employer contributes, to be paid to the authorities.
+//
+// HTTP Method: GET
+// URL Path: /payroll/statutory/remittances/{period}
+//
+// Response:
+//   - Status Code: 200 (OK) with the RemittanceReport in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the period is invalid.
+//   - Status Code: 500 (Internal Server Error) if the records cannot be read.
+func (h *StatutoryHandler) Remittance(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.Remittance(r.Context(), mux.Vars(r)["period"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to build remittance report")
+		return
+	}
+	respond.JSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,246 @@
+package statutory_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"erp/models"
+)
+
+// DBStatutoryStore provides SQL-backed methods for statutory deductions and their records.
+type DBStatutoryStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// deductionColumns are the columns scanned by scanDeduction.
+const deductionColumns = `id, code, name, kind, slabs, employee_rate, employer_rate, ceiling, active, updated_by,
+	updated_at`
+
+// scanDeduction reads a row selected with deductionColumns.
+func scanDeduction(row interface{ Scan(...interface{}) error }) (*models.StatutoryDeduction, error) {
+	var deduction models.StatutoryDeduction
+	var slabs []byte
+	if err := row.Scan(&deduction.ID, &deduction.Code, &deduction.Name, &deduction.Kind, &slabs,
+		&deduction.EmployeeRate, &deduction.EmployerRate, &deduction.Ceiling, &deduction.Active, &deduction.UpdatedBy,
+		&deduction.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if len(slabs) > 0 {
+		if err := json.Unmarshal(slabs, &deduction.Slabs); err != nil {
+			return nil, err
+		}
+	}
+	return &deduction, nil
+}
+
+// duplicateCode converts a unique violation on the deduction code to a conflict.
+func duplicateCode(err error, deduction *models.StatutoryDeduction) error {
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("a statutory deduction with code %q already exists", deduction.Code)
+	}
+	return err
+}
+
+// marshalSlabs encodes the tax slabs of a deduction, or NULL if it has none.
+func marshalSlabs(deduction *models.StatutoryDeduction) (interface{}, error) {
+	if len(deduction.Slabs) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(deduction.Slabs)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// CreateStatutoryDeduction saves a new statutory deduction.
+//
+// Parameters:
+//   - deduction: The validated deduction; its ID is set.
+//
+// Returns:
+//   - error: A models.Conflict error if the code is taken, or an error if the insert fails.
+func (store *DBStatutoryStore) CreateStatutoryDeduction(ctx context.Context, deduction *models.StatutoryDeduction) error {
+	slabs, err := marshalSlabs(deduction)
+	if err != nil {
+		return err
+	}
+	err = store.DB.QueryRowContext(ctx,
+		`INSERT INTO statutory_deductions (code, name, kind, slabs, employee_rate, employer_rate, ceiling, active,
+		     updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		deduction.Code, deduction.Name, deduction.Kind, slabs, deduction.EmployeeRate, deduction.EmployerRate,
+		deduction.Ceiling, deduction.Active, deduction.UpdatedBy, deduction.UpdatedAt,
+	).Scan(&deduction.ID)
+	return duplicateCode(err, deduction)
+}
+
+// GetStatutoryDeductions retrieves every statutory deduction, ordered by code.
+//
+// Returns:
+//   - []models.StatutoryDeduction: The deductions.
+//   - error: An error if the query fails.
+func (store *DBStatutoryStore) GetStatutoryDeductions(ctx context.Context) ([]models.StatutoryDeduction, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT `+deductionColumns+` FROM statutory_deductions ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deductions := []models.StatutoryDeduction{}
+	for rows.Next() {
+		deduction, err := scanDeduction(rows)
+		if err != nil {
+			return nil, err
+		}
+		deductions = append(deductions, *deduction)
+	}
+	return deductions, rows.Err()
+}
+
+// UpdateStatutoryDeduction replaces a statutory deduction.
+//
+// Parameters:
+//   - deduction: The validated deduction with its ID.
+//
+// Returns:
+//   - error: models.ErrNotFound if the deduction does not exist, a models.Conflict error if
+//     the code is taken, or an error if the update fails.
+func (store *DBStatutoryStore) UpdateStatutoryDeduction(ctx context.Context, deduction *models.StatutoryDeduction) error {
+	slabs, err := marshalSlabs(deduction)
+	if err != nil {
+		return err
+	}
+	result, err := store.DB.ExecContext(ctx,
+		`UPDATE statutory_deductions SET code = $1, name = $2, kind = $3, slabs = $4, employee_rate = $5,
+		     employer_rate = $6, ceiling = $7, active = $8, updated_by = $9, updated_at = $10
+		 WHERE id = $11`,
+		deduction.Code, deduction.Name, deduction.Kind, slabs, deduction.EmployeeRate, deduction.EmployerRate,
+		deduction.Ceiling, deduction.Active, deduction.UpdatedBy, deduction.UpdatedAt, deduction.ID,
+	)
+	if err != nil {
+		return duplicateCode(err, deduction)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("statutory deduction %d not found", deduction.ID)
+	}
+	return nil
+}
+
+// DeleteStatutoryDeduction removes a statutory deduction. Records already made keep their
+// lines.
+//
+// Parameters:
+//   - id: The ID of the deduction.
+//
+// Returns:
+//   - error: models.ErrNotFound if the deduction does not exist, or an error if the delete fails.
+func (store *DBStatutoryStore) DeleteStatutoryDeduction(ctx context.Context, id int) error {
+	result, err := store.DB.ExecContext(ctx, `DELETE FROM statutory_deductions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.NotFound("statutory deduction %d not found", id)
+	}
+	return nil
+}
+
+// SaveStatutoryRecord records an employee's deductions for a period in one transaction,
+// replacing the record and lines saved before for the period.
+//
+// Parameters:
+//   - record: The computed record; its ID is set.
+//
+// Returns:
+//   - error: models.ErrNotFound if the user does not exist, or an error if the record
+//     cannot be saved.
+func (store *DBStatutoryStore) SaveStatutoryRecord(ctx context.Context, record *models.StatutoryRecord) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO statutory_records (user_id, period, gross, total_employee, total_employer, net, recorded_by,
+		     recorded_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (user_id, period) DO UPDATE SET gross = EXCLUDED.gross, total_employee = EXCLUDED.total_employee,
+		     total_employer = EXCLUDED.total_employer, net = EXCLUDED.net, recorded_by = EXCLUDED.recorded_by,
+		     recorded_at = EXCLUDED.recorded_at
+		 RETURNING id`,
+		record.UserID, record.Period, record.Gross, record.TotalEmployee, record.TotalEmployer, record.Net,
+		record.RecordedBy, record.RecordedAt,
+	).Scan(&record.ID)
+	if models.IsForeignKeyViolation(err) {
+		return models.NotFound("user %d not found", record.UserID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM statutory_record_lines WHERE record_id = $1`, record.ID); err != nil {
+		return err
+	}
+	for _, line := range record.Lines {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO statutory_record_lines (record_id, code, name, kind, base, employee, employer)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			record.ID, line.Code, line.Name, line.Kind, line.Base, line.Employee, line.Employer)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetStatutoryRecords retrieves the records of a period with their lines.
+//
+// Parameters:
+//   - period: The pay period, formatted as YYYY-MM.
+//   - userID: The employee, or 0 for every employee.
+//
+// Returns:
+//   - []models.StatutoryRecord: The records, by user.
+//   - error: An error if a query fails.
+func (store *DBStatutoryStore) GetStatutoryRecords(ctx context.Context, period string, userID int) ([]models.StatutoryRecord, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT r.id, r.user_id, r.period, r.gross, r.total_employee, r.total_employer, r.net, r.recorded_by,
+		     r.recorded_at, l.code, l.name, l.kind, l.base, l.employee, l.employer
+		 FROM statutory_records r
+		 LEFT JOIN statutory_record_lines l ON l.record_id = r.id
+		 WHERE r.period = $1 AND ($2 = 0 OR r.user_id = $2)
+		 ORDER BY r.user_id, l.code`, period, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []models.StatutoryRecord{}
+	for rows.Next() {
+		var record models.StatutoryRecord
+		var code, name, kind sql.NullString
+		var base, employee, employer sql.NullFloat64
+		if err := rows.Scan(&record.ID, &record.UserID, &record.Period, &record.Gross, &record.TotalEmployee,
+			&record.TotalEmployer, &record.Net, &record.RecordedBy, &record.RecordedAt, &code, &name, &kind, &base,
+			&employee, &employer); err != nil {
+			return nil, err
+		}
+		if n := len(records); n == 0 || records[n-1].ID != record.ID {
+			record.Lines = []models.StatutoryLine{}
+			records = append(records, record)
+		}
+		if code.Valid {
+			last := &records[len(records)-1]
+			last.Lines = append(last.Lines, models.StatutoryLine{Code: code.String, Name: name.String,
+				Kind: kind.String, Base: base.Float64, Employee: employee.Float64, Employer: employer.Float64})
+		}
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,123 @@
+package stock_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"erp/models"
+)
+
+// DBStockAlertStore implements models.StockAlertStore using a SQL database.
+type DBStockAlertStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// scanStockAlerts reads rows of stock ID, policy ID, product ID and name, warehouse ID and
+// name, location, quantity, reorder level and maximum level.
+func scanStockAlerts(rows *sql.Rows) ([]models.StockAlert, error) {
+	defer rows.Close()
+	alerts := []models.StockAlert{}
+	for rows.Next() {
+		var a models.StockAlert
+		if err := rows.Scan(&a.StockID, &a.PolicyID, &a.ProductID, &a.ProductName, &a.WarehouseID, &a.WarehouseName,
+			&a.Location, &a.Quantity, &a.ReorderLevel, &a.MaxLevel); err != nil {
+			return nil, err
+		}
+		a.Shortfall = a.ReorderLevel - a.Quantity
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// unpoliced matches stock records s of a product without a policy in their warehouse.
+const unpoliced = `NOT EXISTS (SELECT 1 FROM stock_policies sp WHERE sp.product_id = s.product_id AND sp.warehouse_id = s.warehouse_id)`
+
+// policyStock totals the stock of each policy's product in its warehouse.
+const policyStock = `SELECT sp.id, COALESCE(SUM(s.quantity), 0) AS quantity
+	FROM stock_policies sp
+	LEFT JOIN stock s ON s.product_id = sp.product_id AND s.warehouse_id = sp.warehouse_id
+	GROUP BY sp.id`
+
+// ListStockAlerts returns the stock records below their reorder level and the policies below
+// their minimum in a warehouse, or in every warehouse if warehouseID is 0, largest shortfall
+// first.
+func (s *DBStockAlertStore) ListStockAlerts(ctx context.Context, warehouseID int) ([]models.StockAlert, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT a.stock_id, a.policy_id, COALESCE(a.product_id, 0), COALESCE(p.name, ''), COALESCE(a.warehouse_id, 0),
+		        COALESCE(w.name, ''), a.location, a.quantity, a.reorder_level, a.max_level
+		 FROM (
+		     SELECT s.id AS stock_id, 0 AS policy_id, s.product_id, s.warehouse_id, COALESCE(s.location, '') AS location,
+		            s.quantity, s.reorder_level, 0 AS max_level
+		     FROM stock s
+		     WHERE s.quantity < s.reorder_level AND `+unpoliced+`
+		     UNION ALL
+		     SELECT 0, sp.id, sp.product_id, sp.warehouse_id, '', t.quantity, sp.min_level, sp.max_level
+		     FROM stock_policies sp JOIN (`+policyStock+`) t ON t.id = sp.id
+		     WHERE t.quantity < sp.min_level
+		 ) a
+		 LEFT JOIN products p ON p.id = a.product_id
+		 LEFT JOIN warehouses w ON w.id = a.warehouse_id
+		 WHERE $1 = 0 OR a.warehouse_id = $1
+		 ORDER BY a.reorder_level - a.quantity DESC, a.stock_id, a.policy_id`,
+		warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stock alerts: %w", err)
+	}
+	return scanStockAlerts(rows)
+}
+
+// MarkStockAlerts flips the below_reorder flag of the stock records and the below_min flag
+// of the policies that crossed their level since the last call. Each flag is flipped in the
+// statement that reads it, so that concurrent checks notify a crossing once.
+//
+// Returns:
+//   - []models.StockAlert: The records and policies that fell below their level, largest
+//     shortfall first. Those restocked to their level are marked but not returned.
+//   - error: An error if the update fails.
+func (s *DBStockAlertStore) MarkStockAlerts(ctx context.Context) ([]models.StockAlert, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`WITH changed AS (
+		     UPDATE stock s SET below_reorder = NOT below_reorder
+		     WHERE below_reorder <> (quantity < reorder_level AND `+unpoliced+`)
+		     RETURNING id, product_id, warehouse_id, location, quantity, reorder_level, below_reorder
+		 )
+		 SELECT c.id, 0, COALESCE(c.product_id, 0), COALESCE(p.name, ''), COALESCE(c.warehouse_id, 0), COALESCE(w.name, ''),
+		        COALESCE(c.location, ''), c.quantity, c.reorder_level, 0
+		 FROM changed c
+		 LEFT JOIN products p ON p.id = c.product_id
+		 LEFT JOIN warehouses w ON w.id = c.warehouse_id
+		 WHERE c.below_reorder`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark stock alerts: %w", err)
+	}
+	alerts, err := scanStockAlerts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = s.DB.QueryContext(ctx,
+		`WITH changed AS (
+		     UPDATE stock_policies sp SET below_min = NOT sp.below_min
+		     FROM (`+policyStock+`) t
+		     WHERE t.id = sp.id AND sp.below_min <> (t.quantity < sp.min_level)
+		     RETURNING sp.id, sp.product_id, sp.warehouse_id, t.quantity, sp.min_level, sp.max_level, sp.below_min
+		 )
+		 SELECT 0, c.id, c.product_id, COALESCE(p.name, ''), c.warehouse_id, COALESCE(w.name, ''), '',
+		        c.quantity, c.min_level, c.max_level
+		 FROM changed c
+		 LEFT JOIN products p ON p.id = c.product_id
+		 LEFT JOIN warehouses w ON w.id = c.warehouse_id
+		 WHERE c.below_min`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark stock policy alerts: %w", err)
+	}
+	low, err := scanStockAlerts(rows)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, low...)
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Shortfall > alerts[j].Shortfall })
+	return alerts, nil
+}
@@ -0,0 +1,53 @@
+package stock_handlers
+
+import (
+	"context"
+	"testing"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var stockAlertColumns = []string{"id", "policy_id", "product_id", "name", "warehouse_id", "name", "location", "quantity",
+	"reorder_level", "max_level"}
+
+func TestMarkStockAlertsReturnsCrossings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockAlertStore{DB: db}
+
+	mock.ExpectQuery("UPDATE stock s SET below_reorder = NOT below_reorder").
+		WillReturnRows(sqlmock.NewRows(stockAlertColumns).AddRow(3, 0, 7, "Widget", 1, "Main", "A1", 2, 10, 0))
+	mock.ExpectQuery("UPDATE stock_policies sp SET below_min = NOT sp.below_min").
+		WillReturnRows(sqlmock.NewRows(stockAlertColumns).AddRow(0, 4, 8, "Gadget", 2, "North", "", 5, 20, 50))
+
+	alerts, err := store.MarkStockAlerts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.StockAlert{
+		{PolicyID: 4, ProductID: 8, ProductName: "Gadget", WarehouseID: 2, WarehouseName: "North", Quantity: 5,
+			ReorderLevel: 20, MaxLevel: 50, Shortfall: 15},
+		{StockID: 3, ProductID: 7, ProductName: "Widget", WarehouseID: 1, WarehouseName: "Main", Location: "A1",
+			Quantity: 2, ReorderLevel: 10, Shortfall: 8},
+	}, alerts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListStockAlertsByWarehouse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockAlertStore{DB: db}
+
+	mock.ExpectQuery("WHERE s.quantity < s.reorder_level AND NOT EXISTS .* WHERE t.quantity < sp.min_level").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(stockAlertColumns))
+
+	alerts, err := store.ListStockAlerts(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+	assert.NotNil(t, alerts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
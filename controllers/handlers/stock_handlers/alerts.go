@@ -0,0 +1,33 @@
+package stock_handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/inventory"
+	"erp/controllers/respond"
+)
+
+// AlertHandlers provides the low-stock endpoint.
+type AlertHandlers struct {
+	Service *inventory.AlertService
+}
+
+// ListStockAlerts lists the stock below its reorder level.
+//
+// HTTP Method: GET
+// URL Path: /stock/alerts?warehouse_id=2 (optional; every warehouse by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of StockAlerts in JSON, largest shortfall first.
+//   - Status Code: 500 (Internal Server Error) if the alerts cannot be loaded.
+func (h *AlertHandlers) ListStockAlerts(w http.ResponseWriter, r *http.Request) {
+	warehouseID, _ := strconv.Atoi(r.URL.Query().Get("warehouse_id"))
+	alerts, err := h.Service.Alerts(r.Context(), warehouseID)
+	if err != nil {
+		httperr.Write(w, err, "Could not load stock alerts")
+		return
+	}
+	respond.JSON(w, http.StatusOK, alerts)
+}
@@ -0,0 +1,148 @@
+package stock_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/models"
+)
+
+// DBPutawayStore implements models.PutawayStore using a SQL database.
+type DBPutawayStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// binError converts a duplicate bin code to a conflict and an unknown warehouse to a
+// validation error.
+func binError(err error, bin *models.Bin) error {
+	switch {
+	case models.IsUniqueViolation(err):
+		return models.Conflict("warehouse %d already has a bin %q", bin.WarehouseID, bin.Code)
+	case models.IsForeignKeyViolation(err):
+		return models.Invalid("warehouse %d does not exist", bin.WarehouseID)
+	}
+	return err
+}
+
+// CreateBin records a bin.
+//
+// Returns:
+//   - error: A conflict if the warehouse has a bin of the code, a validation error if the
+//     warehouse does not exist, or the query error.
+func (s *DBPutawayStore) CreateBin(ctx context.Context, bin *models.Bin) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO warehouse_bins (warehouse_id, code, zone, aisle, position, capacity, distance)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		bin.WarehouseID, bin.Code, bin.Zone, bin.Aisle, bin.Position, bin.Capacity, bin.Distance,
+	).Scan(&bin.ID)
+	return binError(err, bin)
+}
+
+// UpdateBin changes a bin.
+//
+// Returns:
+//   - error: A not found error if the bin does not exist, the errors of CreateBin, or the
+//     query error.
+func (s *DBPutawayStore) UpdateBin(ctx context.Context, bin *models.Bin) error {
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE warehouse_bins SET warehouse_id = $1, code = $2, zone = $3, aisle = $4, position = $5, capacity = $6,
+		        distance = $7
+		 WHERE id = $8`,
+		bin.WarehouseID, bin.Code, bin.Zone, bin.Aisle, bin.Position, bin.Capacity, bin.Distance, bin.ID)
+	if err != nil {
+		return binError(err, bin)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("bin %d not found", bin.ID)
+	}
+	return nil
+}
+
+// DeleteBin removes a bin.
+func (s *DBPutawayStore) DeleteBin(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM warehouse_bins WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("bin %d not found", id)
+	}
+	return nil
+}
+
+// ListBins returns the bins of a warehouse, or of every warehouse if warehouseID is 0,
+// nearest first, with the units of stock whose location is their code.
+func (s *DBPutawayStore) ListBins(ctx context.Context, warehouseID int) ([]models.Bin, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT b.id, b.warehouse_id, b.code, b.zone, b.aisle, b.position, b.capacity, b.distance,
+		        COALESCE((SELECT SUM(quantity) FROM stock WHERE warehouse_id = b.warehouse_id AND location = b.code), 0)
+		 FROM warehouse_bins b
+		 WHERE $1 = 0 OR b.warehouse_id = $1
+		 ORDER BY b.warehouse_id, b.distance, b.code`,
+		warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bins: %w", err)
+	}
+	defer rows.Close()
+	bins := []models.Bin{}
+	for rows.Next() {
+		var bin models.Bin
+		if err := rows.Scan(&bin.ID, &bin.WarehouseID, &bin.Code, &bin.Zone, &bin.Aisle, &bin.Position, &bin.Capacity,
+			&bin.Distance, &bin.Used); err != nil {
+			return nil, err
+		}
+		bins = append(bins, bin)
+	}
+	return bins, rows.Err()
+}
+
+// ListBinStock returns the quantity of each product in each bin of a warehouse.
+func (s *DBPutawayStore) ListBinStock(ctx context.Context, warehouseID int) ([]models.BinStock, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT b.code, s.product_id, SUM(s.quantity)
+		 FROM warehouse_bins b JOIN stock s ON s.warehouse_id = b.warehouse_id AND s.location = b.code
+		 WHERE b.warehouse_id = $1 AND s.product_id IS NOT NULL
+		 GROUP BY b.code, s.product_id
+		 ORDER BY b.code, s.product_id`,
+		warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bin stock: %w", err)
+	}
+	defer rows.Close()
+	list := []models.BinStock{}
+	for rows.Next() {
+		var entry models.BinStock
+		if err := rows.Scan(&entry.Code, &entry.ProductID, &entry.Quantity); err != nil {
+			return nil, err
+		}
+		list = append(list, entry)
+	}
+	return list, rows.Err()
+}
+
+// ListProductPicks counts the order lines of each product on the sales orders fulfilled
+// since a time, most picked first.
+func (s *DBPutawayStore) ListProductPicks(ctx context.Context, since time.Time) ([]models.ProductPicks, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT l.product_id, COUNT(*)
+		 FROM sales_order_lines l JOIN sales_orders o ON o.id = l.sales_order_id
+		 WHERE o.status = $1 AND o.fulfilled_at >= $2
+		 GROUP BY l.product_id
+		 ORDER BY COUNT(*) DESC, l.product_id`,
+		models.SalesOrderFulfilled, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count picks: %w", err)
+	}
+	defer rows.Close()
+	list := []models.ProductPicks{}
+	for rows.Next() {
+		var p models.ProductPicks
+		if err := rows.Scan(&p.ProductID, &p.Picks); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
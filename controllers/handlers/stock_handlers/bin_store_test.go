@@ -0,0 +1,46 @@
+package stock_handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateBinMapsConstraintErrors(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPutawayStore{DB: db}
+
+	mock.ExpectQuery("INSERT INTO warehouse_bins").WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectQuery("INSERT INTO warehouse_bins").WillReturnError(&pq.Error{Code: "23503"})
+
+	bin := &models.Bin{WarehouseID: 1, Code: "A1", Capacity: 10}
+	assert.True(t, errors.Is(store.CreateBin(ctx, bin), models.ErrConflict))
+	assert.True(t, errors.Is(store.CreateBin(ctx, bin), models.ErrValidation))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListProductPicksCountsFulfilledOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBPutawayStore{DB: db}
+
+	mock.ExpectQuery("FROM sales_order_lines").WithArgs(models.SalesOrderFulfilled, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "count"}).AddRow(7, 12).AddRow(3, 2))
+
+	picks, err := store.ListProductPicks(context.Background(), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, []models.ProductPicks{{ProductID: 7, Picks: 12}, {ProductID: 3, Picks: 2}}, picks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
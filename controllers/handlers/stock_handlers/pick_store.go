@@ -0,0 +1,89 @@
+package stock_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBPickStore implements models.PickStore using a SQL database.
+type DBPickStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// GetPickLayout returns the saved layout of a warehouse.
+//
+// Returns:
+//   - error: A not found error if the warehouse has no saved layout, or the query error.
+func (s *DBPickStore) GetPickLayout(ctx context.Context, warehouseID int) (*models.PickLayout, error) {
+	layout := models.PickLayout{WarehouseID: warehouseID}
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT zones, traversal, updated_by, updated_at FROM warehouse_pick_layouts WHERE warehouse_id = $1`,
+		warehouseID,
+	).Scan(pq.Array(&layout.Zones), &layout.Traversal, &layout.UpdatedBy, &layout.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("warehouse %d has no pick layout", warehouseID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if layout.Zones == nil {
+		layout.Zones = []string{}
+	}
+	return &layout, nil
+}
+
+// SavePickLayout creates or replaces the layout of a warehouse.
+//
+// Returns:
+//   - error: A validation error if the warehouse does not exist, or the query error.
+func (s *DBPickStore) SavePickLayout(ctx context.Context, layout *models.PickLayout) error {
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO warehouse_pick_layouts (warehouse_id, zones, traversal, updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (warehouse_id) DO UPDATE
+		 SET zones = EXCLUDED.zones, traversal = EXCLUDED.traversal, updated_by = EXCLUDED.updated_by,
+		     updated_at = EXCLUDED.updated_at`,
+		layout.WarehouseID, pq.Array(layout.Zones), layout.Traversal, layout.UpdatedBy, layout.UpdatedAt)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("warehouse %d does not exist", layout.WarehouseID)
+	}
+	return err
+}
+
+// ListPickLocations returns the units of the products in each bin of a warehouse that
+// holds any.
+func (s *DBPickStore) ListPickLocations(ctx context.Context, warehouseID int, productIDs []int) ([]models.PickLocation, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT b.id, b.warehouse_id, b.code, b.zone, b.aisle, b.position, b.capacity, b.distance,
+		        s.product_id, SUM(s.quantity)
+		 FROM warehouse_bins b JOIN stock s ON s.warehouse_id = b.warehouse_id AND s.location = b.code
+		 WHERE b.warehouse_id = $1 AND s.product_id = ANY($2)
+		 GROUP BY b.id, s.product_id
+		 HAVING SUM(s.quantity) > 0
+		 ORDER BY b.id, s.product_id`,
+		warehouseID, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pick locations: %w", err)
+	}
+	defer rows.Close()
+	list := []models.PickLocation{}
+	for rows.Next() {
+		var l models.PickLocation
+		if err := rows.Scan(&l.ID, &l.WarehouseID, &l.Code, &l.Zone, &l.Aisle, &l.Position, &l.Capacity, &l.Distance,
+			&l.ProductID, &l.Quantity); err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
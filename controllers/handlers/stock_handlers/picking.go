@@ -0,0 +1,104 @@
+package stock_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/inventory"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// PickHandlers provides the pick layout and pick route endpoints.
+type PickHandlers struct {
+	Service *inventory.PickService
+}
+
+// RegisterRoutes registers the pick layout and pick route routes.
+func (h *PickHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/stock/pick-layouts/{warehouse_id:[0-9]+}", h.GetPickLayout).Methods("GET")
+	router.HandleFunc("/stock/pick-layouts/{warehouse_id:[0-9]+}", h.SavePickLayout).Methods("PUT")
+	router.HandleFunc("/stock/pick-route", h.PlanPickRoute).Methods("POST")
+}
+
+// GetPickLayout returns how pickers walk a warehouse.
+//
+// HTTP Method: GET
+// URL Path: /stock/pick-layouts/{warehouse_id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the PickLayout in JSON; the default serpentine layout if
+//     none was saved.
+//   - Status Code: 500 (Internal Server Error) if the layout cannot be loaded.
+func (h *PickHandlers) GetPickLayout(w http.ResponseWriter, r *http.Request) {
+	warehouseID, _ := strconv.Atoi(mux.Vars(r)["warehouse_id"])
+	layout, err := h.Service.Layout(r.Context(), warehouseID)
+	if err != nil {
+		httperr.Write(w, err, "Could not load pick layout")
+		return
+	}
+	respond.JSON(w, http.StatusOK, layout)
+}
+
+// SavePickLayout sets how pickers walk a warehouse.
+//
+// HTTP Method: PUT
+// URL Path: /stock/pick-layouts/{warehouse_id}
+//
+// Request Body:
+//   - JSON with zones, the order zones are visited in, and traversal (serpentine or return;
+//     serpentine by default).
+//
+// Response:
+//   - Status Code: 200 (OK) with the PickLayout in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the traversal is unknown, a zone is blank or repeated, or the
+//     warehouse does not exist.
+//   - Status Code: 500 (Internal Server Error) if the layout cannot be saved.
+func (h *PickHandlers) SavePickLayout(w http.ResponseWriter, r *http.Request) {
+	var layout models.PickLayout
+	if err := json.NewDecoder(r.Body).Decode(&layout); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	layout.WarehouseID, _ = strconv.Atoi(mux.Vars(r)["warehouse_id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.SaveLayout(r.Context(), &layout, actor); err != nil {
+		httperr.Write(w, err, "Could not save pick layout")
+		return
+	}
+	respond.JSON(w, http.StatusOK, layout)
+}
+
+// PlanPickRoute orders the stops of a pick list to walk the warehouse as little as possible.
+//
+// HTTP Method: POST
+// URL Path: /stock/pick-route
+//
+// Request Body:
+//   - JSON with warehouse_id and lines, each with product_id and quantity (see PickRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with the PickRoute in JSON: the tasks in sequence, the bins and
+//     aisles visited, and the units no bin holds as short.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the warehouse or lines are missing or a quantity is not positive.
+//   - Status Code: 500 (Internal Server Error) if the route cannot be worked out.
+func (h *PickHandlers) PlanPickRoute(w http.ResponseWriter, r *http.Request) {
+	var req models.PickRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	route, err := h.Service.Route(r.Context(), req)
+	if err != nil {
+		httperr.Write(w, err, "Could not plan pick route")
+		return
+	}
+	respond.JSON(w, http.StatusOK, route)
+}
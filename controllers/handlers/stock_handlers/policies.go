@@ -0,0 +1,177 @@
+package stock_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/inventory"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// PolicyHandlers provides the stock policy and replenishment endpoints.
+type PolicyHandlers struct {
+	Service *inventory.PolicyService
+}
+
+// RegisterRoutes registers the stock policy and replenishment routes.
+func (h *PolicyHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/stock/policies", h.ListPolicies).Methods("GET")
+	router.HandleFunc("/stock/policies", h.CreatePolicy).Methods("POST")
+	router.HandleFunc("/stock/policies/{id:[0-9]+}", h.GetPolicy).Methods("GET")
+	router.HandleFunc("/stock/policies/{id:[0-9]+}", h.UpdatePolicy).Methods("PUT")
+	router.HandleFunc("/stock/policies/{id:[0-9]+}", h.DeletePolicy).Methods("DELETE")
+	router.HandleFunc("/stock/replenishment", h.ListSuggestions).Methods("GET")
+}
+
+// ListPolicies lists the stock policies.
+//
+// HTTP Method: GET
+// URL Path: /stock/policies?warehouse_id=2&product_id=7 (both optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of StockPolicies in JSON, by warehouse and product.
+//   - Status Code: 500 (Internal Server Error) if the policies cannot be loaded.
+func (h *PolicyHandlers) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	warehouseID, _ := strconv.Atoi(r.URL.Query().Get("warehouse_id"))
+	productID, _ := strconv.Atoi(r.URL.Query().Get("product_id"))
+	policies, err := h.Service.Policies(r.Context(), warehouseID, productID)
+	if err != nil {
+		httperr.Write(w, err, "Could not load stock policies")
+		return
+	}
+	respond.JSON(w, http.StatusOK, policies)
+}
+
+// CreatePolicy sets the minimum and maximum stock of a product in a warehouse. They replace
+// the reorder levels of the product's stock records there.
+//
+// HTTP Method: POST
+// URL Path: /stock/policies
+//
+// Request Body:
+//   - JSON with product_id, warehouse_id, min_level and max_level.
+//
+// Response:
+//   - Status Code: 201 (Created) with the StockPolicy in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the product already has a policy in the warehouse.
+//   - Status Code: 422 (Unprocessable Entity) if a field is missing or out of range, or the product or warehouse does not exist.
+//   - Status Code: 500 (Internal Server Error) if the policy cannot be saved.
+func (h *PolicyHandlers) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	var policy models.StockPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if err := h.Service.CreatePolicy(r.Context(), &policy); err != nil {
+		httperr.Write(w, err, "Could not create stock policy")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, policy)
+}
+
+// GetPolicy returns a stock policy.
+//
+// HTTP Method: GET
+// URL Path: /stock/policies/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the StockPolicy in JSON.
+//   - Status Code: 400 (Bad Request) if the policy ID is invalid.
+//   - Status Code: 404 (Not Found) if the policy does not exist.
+//   - Status Code: 500 (Internal Server Error) if the policy cannot be loaded.
+func (h *PolicyHandlers) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid stock policy ID")
+		return
+	}
+	policy, err := h.Service.Policy(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Could not load stock policy")
+		return
+	}
+	respond.JSON(w, http.StatusOK, policy)
+}
+
+// UpdatePolicy changes a stock policy.
+//
+// HTTP Method: PUT
+// URL Path: /stock/policies/{id}
+//
+// Request Body:
+//   - JSON as for CreatePolicy.
+//
+// Response:
+//   - Status Code: 200 (OK) with the StockPolicy in JSON.
+//   - Status Code: 400 (Bad Request) if the request body or policy ID is invalid.
+//   - Status Code: 404 (Not Found) if the policy does not exist.
+//   - Status Code: 409 (Conflict) if the product already has another policy in the warehouse.
+//   - Status Code: 422 (Unprocessable Entity) if a field is missing or out of range, or the product or warehouse does not exist.
+//   - Status Code: 500 (Internal Server Error) if the policy cannot be saved.
+func (h *PolicyHandlers) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid stock policy ID")
+		return
+	}
+	var policy models.StockPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	policy.ID = id
+	if err := h.Service.UpdatePolicy(r.Context(), &policy); err != nil {
+		httperr.Write(w, err, "Could not update stock policy")
+		return
+	}
+	respond.JSON(w, http.StatusOK, policy)
+}
+
+// DeletePolicy removes a stock policy. The reorder levels of the product's stock records in
+// the warehouse apply again.
+//
+// HTTP Method: DELETE
+// URL Path: /stock/policies/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the policy is deleted.
+//   - Status Code: 400 (Bad Request) if the policy ID is invalid.
+//   - Status Code: 404 (Not Found) if the policy does not exist.
+//   - Status Code: 500 (Internal Server Error) if the deletion fails.
+func (h *PolicyHandlers) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid stock policy ID")
+		return
+	}
+	if err := h.Service.DeletePolicy(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Could not delete stock policy")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSuggestions suggests what to order for the products whose stock on hand and on order
+// in a warehouse is below their policy's minimum: enough to bring it up to the maximum.
+//
+// HTTP Method: GET
+// URL Path: /stock/replenishment?warehouse_id=2 (optional; every warehouse by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of Replenishments in JSON, by warehouse and product.
+//   - Status Code: 500 (Internal Server Error) if the stock positions cannot be loaded.
+func (h *PolicyHandlers) ListSuggestions(w http.ResponseWriter, r *http.Request) {
+	warehouseID, _ := strconv.Atoi(r.URL.Query().Get("warehouse_id"))
+	suggestions, err := h.Service.Suggestions(r.Context(), warehouseID)
+	if err != nil {
+		httperr.Write(w, err, "Could not load replenishment suggestions")
+		return
+	}
+	respond.JSON(w, http.StatusOK, suggestions)
+}
@@ -0,0 +1,148 @@
+package stock_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"erp/models"
+)
+
+// DBStockPolicyStore implements models.StockPolicyStore using a SQL database.
+type DBStockPolicyStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// policyError converts a second policy for a product in a warehouse to a conflict and an
+// unknown product or warehouse to a validation error.
+func policyError(err error, policy *models.StockPolicy) error {
+	switch {
+	case models.IsUniqueViolation(err):
+		return models.Conflict("product %d already has a stock policy in warehouse %d", policy.ProductID, policy.WarehouseID)
+	case models.IsForeignKeyViolation(err):
+		return models.Invalid("product %d or warehouse %d does not exist", policy.ProductID, policy.WarehouseID)
+	}
+	return err
+}
+
+// CreateStockPolicy records a policy.
+//
+// Returns:
+//   - error: A conflict if the product has a policy in the warehouse, a validation error if
+//     the product or warehouse does not exist, or the query error.
+func (s *DBStockPolicyStore) CreateStockPolicy(ctx context.Context, policy *models.StockPolicy) error {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO stock_policies (product_id, warehouse_id, min_level, max_level) VALUES ($1, $2, $3, $4) RETURNING id`,
+		policy.ProductID, policy.WarehouseID, policy.MinLevel, policy.MaxLevel,
+	).Scan(&policy.ID)
+	return policyError(err, policy)
+}
+
+// UpdateStockPolicy changes a policy.
+//
+// Returns:
+//   - error: A not found error if the policy does not exist, the errors of
+//     CreateStockPolicy, or the query error.
+func (s *DBStockPolicyStore) UpdateStockPolicy(ctx context.Context, policy *models.StockPolicy) error {
+	result, err := s.DB.ExecContext(ctx,
+		`UPDATE stock_policies SET product_id = $1, warehouse_id = $2, min_level = $3, max_level = $4 WHERE id = $5`,
+		policy.ProductID, policy.WarehouseID, policy.MinLevel, policy.MaxLevel, policy.ID)
+	if err != nil {
+		return policyError(err, policy)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("stock policy %d not found", policy.ID)
+	}
+	return nil
+}
+
+// DeleteStockPolicy removes a policy. The reorder levels of the product's stock records in
+// the warehouse apply again.
+func (s *DBStockPolicyStore) DeleteStockPolicy(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM stock_policies WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("stock policy %d not found", id)
+	}
+	return nil
+}
+
+// GetStockPolicy returns a policy.
+func (s *DBStockPolicyStore) GetStockPolicy(ctx context.Context, id int) (*models.StockPolicy, error) {
+	var policy models.StockPolicy
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT id, product_id, warehouse_id, min_level, max_level FROM stock_policies WHERE id = $1`, id,
+	).Scan(&policy.ID, &policy.ProductID, &policy.WarehouseID, &policy.MinLevel, &policy.MaxLevel)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("stock policy %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ListStockPolicies returns the policies of a warehouse and product, by warehouse and
+// product; 0 matches any.
+func (s *DBStockPolicyStore) ListStockPolicies(ctx context.Context, warehouseID, productID int) ([]models.StockPolicy, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, product_id, warehouse_id, min_level, max_level FROM stock_policies
+		 WHERE ($1 = 0 OR warehouse_id = $1) AND ($2 = 0 OR product_id = $2)
+		 ORDER BY warehouse_id, product_id`,
+		warehouseID, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stock policies: %w", err)
+	}
+	defer rows.Close()
+	policies := []models.StockPolicy{}
+	for rows.Next() {
+		var policy models.StockPolicy
+		if err := rows.Scan(&policy.ID, &policy.ProductID, &policy.WarehouseID, &policy.MinLevel, &policy.MaxLevel); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// ListStockPositions returns the stock on hand and on order of every product with a policy
+// in a warehouse, or in every warehouse if warehouseID is 0. On order is what open purchase
+// orders for the warehouse have not delivered yet, plus deliveries quarantined for
+// inspection, which are not stock until released.
+func (s *DBStockPolicyStore) ListStockPositions(ctx context.Context, warehouseID int) ([]models.Replenishment, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT sp.id, sp.product_id, COALESCE(p.name, ''), sp.warehouse_id, COALESCE(w.name, ''),
+		        COALESCE((SELECT SUM(s.quantity) FROM stock s
+		                  WHERE s.product_id = sp.product_id AND s.warehouse_id = sp.warehouse_id), 0),
+		        COALESCE((SELECT SUM(l.quantity - l.received)
+		                  FROM purchase_order_lines l JOIN purchase_orders o ON o.id = l.purchase_order_id
+		                  WHERE l.product_id = sp.product_id AND o.warehouse_id = sp.warehouse_id
+		                    AND o.status IN ($2, $3, $4)), 0)
+		        + COALESCE((SELECT SUM(h.quantity) FROM quality_holds h
+		                    WHERE h.product_id = sp.product_id AND h.warehouse_id = sp.warehouse_id AND h.status = $5), 0),
+		        sp.min_level, sp.max_level
+		 FROM stock_policies sp
+		 LEFT JOIN products p ON p.id = sp.product_id
+		 LEFT JOIN warehouses w ON w.id = sp.warehouse_id
+		 WHERE $1 = 0 OR sp.warehouse_id = $1
+		 ORDER BY sp.warehouse_id, sp.product_id`,
+		warehouseID, models.PurchaseOrderDraft, models.PurchaseOrderApproved, models.PurchaseOrderPartiallyReceived,
+		models.HoldQuarantined)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stock positions: %w", err)
+	}
+	defer rows.Close()
+	positions := []models.Replenishment{}
+	for rows.Next() {
+		var r models.Replenishment
+		if err := rows.Scan(&r.PolicyID, &r.ProductID, &r.ProductName, &r.WarehouseID, &r.WarehouseName, &r.OnHand,
+			&r.OnOrder, &r.MinLevel, &r.MaxLevel); err != nil {
+			return nil, err
+		}
+		positions = append(positions, r)
+	}
+	return positions, rows.Err()
+}
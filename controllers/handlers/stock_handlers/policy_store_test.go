@@ -0,0 +1,46 @@
+package stock_handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateStockPolicyConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockPolicyStore{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO stock_policies")).WithArgs(7, 1, 10, 50).
+		WillReturnError(&pq.Error{Code: "23505"})
+	err = store.CreateStockPolicy(context.Background(), &models.StockPolicy{ProductID: 7, WarehouseID: 1, MinLevel: 10, MaxLevel: 50})
+	assert.True(t, errors.Is(err, models.ErrConflict))
+	assert.EqualError(t, err, "product 7 already has a stock policy in warehouse 1")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListStockPositionsCountsOpenPurchaseOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockPolicyStore{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta("o.status IN ($2, $3, $4)")).
+		WithArgs(1, "draft", "approved", "partially_received", "quarantined").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "name", "warehouse_id", "name", "on_hand", "on_order",
+			"min_level", "max_level"}).AddRow(4, 7, "Widget", 1, "Main", 3, 6, 10, 50))
+	positions, err := store.ListStockPositions(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Replenishment{{PolicyID: 4, ProductID: 7, ProductName: "Widget", WarehouseID: 1,
+		WarehouseName: "Main", OnHand: 3, OnOrder: 6, MinLevel: 10, MaxLevel: 50}}, positions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
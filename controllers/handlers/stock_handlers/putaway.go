@@ -0,0 +1,161 @@
+package stock_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/inventory"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// PutawayHandlers provides the bin and putaway suggestion endpoints.
+type PutawayHandlers struct {
+	Service *inventory.PutawayService
+}
+
+// RegisterRoutes registers the bin and putaway routes.
+func (h *PutawayHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/stock/bins", h.ListBins).Methods("GET")
+	router.HandleFunc("/stock/bins", h.CreateBin).Methods("POST")
+	router.HandleFunc("/stock/bins/{id:[0-9]+}", h.UpdateBin).Methods("PUT")
+	router.HandleFunc("/stock/bins/{id:[0-9]+}", h.DeleteBin).Methods("DELETE")
+	router.HandleFunc("/stock/putaway", h.SuggestPutaway).Methods("POST")
+}
+
+// ListBins lists the bins of a warehouse with the units they hold.
+//
+// HTTP Method: GET
+// URL Path: /stock/bins?warehouse_id=2 (optional; every warehouse by default)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of Bins in JSON, nearest dispatch first.
+//   - Status Code: 500 (Internal Server Error) if the bins cannot be loaded.
+func (h *PutawayHandlers) ListBins(w http.ResponseWriter, r *http.Request) {
+	warehouseID, _ := strconv.Atoi(r.URL.Query().Get("warehouse_id"))
+	bins, err := h.Service.Bins(r.Context(), warehouseID)
+	if err != nil {
+		httperr.Write(w, err, "Could not load bins")
+		return
+	}
+	respond.JSON(w, http.StatusOK, bins)
+}
+
+// CreateBin adds a bin to a warehouse. Stock is in the bin when its location is the code.
+//
+// HTTP Method: POST
+// URL Path: /stock/bins
+//
+// Request Body:
+//   - JSON with warehouse_id, code, capacity, distance (walking distance from dispatch) and
+//     optionally zone, aisle and position along the aisle, which pick routes are walked by.
+//
+// Response:
+//   - Status Code: 201 (Created) with the Bin in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the warehouse already has a bin of the code.
+//   - Status Code: 422 (Unprocessable Entity) if a field is missing or out of range, or the warehouse does not exist.
+//   - Status Code: 500 (Internal Server Error) if the bin cannot be saved.
+func (h *PutawayHandlers) CreateBin(w http.ResponseWriter, r *http.Request) {
+	var bin models.Bin
+	if err := json.NewDecoder(r.Body).Decode(&bin); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if err := h.Service.CreateBin(r.Context(), &bin); err != nil {
+		httperr.Write(w, err, "Could not create bin")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, bin)
+}
+
+// UpdateBin changes a bin.
+//
+// HTTP Method: PUT
+// URL Path: /stock/bins/{id}
+//
+// Request Body:
+//   - JSON as for CreateBin.
+//
+// Response:
+//   - Status Code: 200 (OK) with the Bin in JSON.
+//   - Status Code: 400 (Bad Request) if the request body or bin ID is invalid.
+//   - Status Code: 404 (Not Found) if the bin does not exist.
+//   - Status Code: 409 (Conflict) if the warehouse already has another bin of the code.
+//   - Status Code: 422 (Unprocessable Entity) if a field is missing or out of range, or the warehouse does not exist.
+//   - Status Code: 500 (Internal Server Error) if the bin cannot be saved.
+func (h *PutawayHandlers) UpdateBin(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid bin ID")
+		return
+	}
+	var bin models.Bin
+	if err := json.NewDecoder(r.Body).Decode(&bin); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	bin.ID = id
+	if err := h.Service.UpdateBin(r.Context(), &bin); err != nil {
+		httperr.Write(w, err, "Could not update bin")
+		return
+	}
+	respond.JSON(w, http.StatusOK, bin)
+}
+
+// DeleteBin removes a bin. The stock in it keeps its location.
+//
+// HTTP Method: DELETE
+// URL Path: /stock/bins/{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the bin is deleted.
+//   - Status Code: 400 (Bad Request) if the bin ID is invalid.
+//   - Status Code: 404 (Not Found) if the bin does not exist.
+//   - Status Code: 500 (Internal Server Error) if the deletion fails.
+func (h *PutawayHandlers) DeleteBin(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid bin ID")
+		return
+	}
+	if err := h.Service.DeleteBin(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Could not delete bin")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SuggestPutaway suggests the bins to store received goods in. Nothing is moved; the
+// suggestions are applied by setting the location of the stock to the bin codes.
+//
+// HTTP Method: POST
+// URL Path: /stock/putaway
+//
+// Request Body:
+//   - JSON with warehouse_id and lines, each with product_id and quantity (see PutawayRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of PutawayPlans in JSON, most picked product first.
+//     Units no bin has room for are reported as unplaced.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the warehouse has no bins.
+//   - Status Code: 422 (Unprocessable Entity) if the warehouse or lines are missing or a quantity is not positive.
+//   - Status Code: 500 (Internal Server Error) if the suggestions cannot be worked out.
+func (h *PutawayHandlers) SuggestPutaway(w http.ResponseWriter, r *http.Request) {
+	var req models.PutawayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	plans, err := h.Service.Suggest(r.Context(), req)
+	if err != nil {
+		httperr.Write(w, err, "Could not suggest putaway")
+		return
+	}
+	respond.JSON(w, http.StatusOK, plans)
+}
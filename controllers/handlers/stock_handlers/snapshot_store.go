@@ -0,0 +1,113 @@
+package stock_handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp/models"
+)
+
+// DBStockSnapshotStore implements models.StockSnapshotStore using a SQL database.
+type DBStockSnapshotStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// TakeStockSnapshot copies the quantity of every product in every warehouse, summed over
+// locations, in one transaction.
+//
+// Returns:
+//   - error: A conflict if a snapshot was taken on the day already, or the query error.
+func (s *DBStockSnapshotStore) TakeStockSnapshot(ctx context.Context, snapshot *models.StockSnapshot) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO stock_snapshots (taken_on, taken_at) VALUES ($1, $2) RETURNING id`,
+		snapshot.TakenOn, snapshot.TakenAt).Scan(&snapshot.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("a stock snapshot was taken on %s already", snapshot.TakenOn)
+	}
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO stock_snapshot_lines (snapshot_id, product_id, warehouse_id, quantity)
+		 SELECT $1, product_id, warehouse_id, SUM(quantity) FROM stock
+		 WHERE product_id IS NOT NULL AND warehouse_id IS NOT NULL
+		 GROUP BY product_id, warehouse_id`, snapshot.ID)
+	if err != nil {
+		return fmt.Errorf("failed to copy stock: %w", err)
+	}
+	lines, _ := result.RowsAffected()
+	snapshot.Lines = int(lines)
+	if _, err := tx.ExecContext(ctx, `UPDATE stock_snapshots SET lines = $1 WHERE id = $2`, snapshot.Lines, snapshot.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetStockSnapshotBefore returns the last snapshot taken before a time and a product's
+// balances in it, by warehouse.
+//
+// Returns:
+//   - error: A not found error if no snapshot was taken by then, or the query error.
+func (s *DBStockSnapshotStore) GetStockSnapshotBefore(ctx context.Context, productID int, before time.Time) (*models.StockSnapshot, []models.WarehouseBalance, error) {
+	var snapshot models.StockSnapshot
+	var takenOn time.Time
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT id, taken_on, taken_at, lines FROM stock_snapshots WHERE taken_at < $1 ORDER BY taken_at DESC LIMIT 1`,
+		before,
+	).Scan(&snapshot.ID, &takenOn, &snapshot.TakenAt, &snapshot.Lines)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, models.NotFound("no stock snapshot was taken before %s", before.Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot.TakenOn = takenOn.Format("2006-01-02")
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT warehouse_id, quantity FROM stock_snapshot_lines WHERE snapshot_id = $1 AND product_id = $2
+		 ORDER BY warehouse_id`, snapshot.ID, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	balances := []models.WarehouseBalance{}
+	for rows.Next() {
+		var b models.WarehouseBalance
+		if err := rows.Scan(&b.WarehouseID, &b.Quantity); err != nil {
+			return nil, nil, err
+		}
+		balances = append(balances, b)
+	}
+	return &snapshot, balances, rows.Err()
+}
+
+// ListProductMovements returns a product's movements after from and before to, oldest first.
+func (s *DBStockSnapshotStore) ListProductMovements(ctx context.Context, productID int, from, to time.Time) ([]models.StockMovement, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, product_id, warehouse_id, quantity, kind, COALESCE(counterpart_warehouse_id, 0), note, moved_by, moved_at
+		 FROM stock_movements
+		 WHERE product_id = $1 AND moved_at > $2 AND moved_at < $3
+		 ORDER BY moved_at, id`, productID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stock movements: %w", err)
+	}
+	defer rows.Close()
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var m models.StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.WarehouseID, &m.Quantity, &m.Kind, &m.CounterpartWarehouseID,
+			&m.Note, &m.MovedBy, &m.MovedAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
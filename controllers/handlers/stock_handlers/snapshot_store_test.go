@@ -0,0 +1,56 @@
+package stock_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeStockSnapshot(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockSnapshotStore{DB: db}
+	takenAt := time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO stock_snapshots")).WithArgs("2025-03-01", takenAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO stock_snapshot_lines")).WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock_snapshots SET lines")).WithArgs(12, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	snapshot := models.StockSnapshot{TakenOn: "2025-03-01", TakenAt: takenAt}
+	require.NoError(t, store.TakeStockSnapshot(ctx, &snapshot))
+	assert.Equal(t, 4, snapshot.ID)
+	assert.Equal(t, 12, snapshot.Lines)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO stock_snapshots")).WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.TakeStockSnapshot(ctx, &snapshot), models.ErrConflict)
+}
+
+func TestGetStockSnapshotBeforeFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockSnapshotStore{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_snapshots WHERE taken_at < $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "taken_on", "taken_at", "lines"}))
+	_, _, err = store.GetStockSnapshotBefore(context.Background(), 7, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
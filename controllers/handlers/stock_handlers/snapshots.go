@@ -0,0 +1,44 @@
+package stock_handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/inventory"
+	"erp/controllers/respond"
+
+	"github.com/gorilla/mux"
+)
+
+// SnapshotHandlers provides the point-in-time stock balance endpoint.
+type SnapshotHandlers struct {
+	Service *inventory.SnapshotService
+}
+
+// RegisterRoutes registers the stock snapshot routes.
+func (h *SnapshotHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/stock/as-of", h.GetStockAsOf).Methods("GET")
+}
+
+// GetStockAsOf returns a product's stock at the end of a past day, for audits and claims.
+//
+// HTTP Method: GET
+// URL Path: /stock/as-of?date=2025-03-31&product_id=7
+//
+// Response:
+//   - Status Code: 200 (OK) with the StockBalance in JSON: the quantity in each warehouse,
+//     and the snapshot and number of movements it was worked out from.
+//   - Status Code: 404 (Not Found) if no snapshot was taken by the end of the day.
+//   - Status Code: 422 (Unprocessable Entity) if the product is missing or the date is
+//     invalid or in the future.
+//   - Status Code: 500 (Internal Server Error) if the balance cannot be worked out.
+func (h *SnapshotHandlers) GetStockAsOf(w http.ResponseWriter, r *http.Request) {
+	productID, _ := strconv.Atoi(r.URL.Query().Get("product_id"))
+	balance, err := h.Service.AsOf(r.Context(), productID, r.URL.Query().Get("date"))
+	if err != nil {
+		httperr.Write(w, err, "Could not work out stock balance")
+		return
+	}
+	respond.JSON(w, http.StatusOK, balance)
+}
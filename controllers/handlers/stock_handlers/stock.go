@@ -2,7 +2,12 @@
 package stock_handlers
 
 import (
-	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/inventory"
+	"erp/controllers/pagination"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
 	"erp/models"
 	"net/http"
 	"strconv"
@@ -10,9 +15,38 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// StockHandlers contains dependencies for handling stock-related requests.
+// StockHandlers contains dependencies for handling stock-related requests. The stock rules
+// live in the inventory service; the handlers only translate HTTP.
 type StockHandlers struct {
-	StockStore models.StockStore
+	Service *inventory.Service
+}
+
+// NewStockHandlers creates stock handlers backed by store.
+func NewStockHandlers(store models.StockStore) *StockHandlers {
+	return &StockHandlers{Service: inventory.NewService(store)}
+}
+
+// StockRequest is the request body for creating or updating a stock record. The ID is
+// assigned by the database or taken from the URL.
+type StockRequest struct {
+	ProductID    int    `json:"product_id"`
+	Quantity     int    `json:"quantity"`
+	WarehouseID  int    `json:"warehouse_id"`
+	Location     string `json:"location"`
+	ReorderLevel int    `json:"reorder_level"`
+	Version      int    `json:"version"` // The version updated, unless sent in If-Match; ignored on create
+}
+
+// Stock returns the stock record described by the request.
+func (req StockRequest) Stock() models.Stock {
+	return models.Stock{ProductID: req.ProductID, Quantity: req.Quantity, WarehouseID: req.WarehouseID, Location: req.Location,
+		ReorderLevel: req.ReorderLevel}
+}
+
+// Validate applies the stock rules of inventory.Validate to the request.
+func (req StockRequest) Validate() error {
+	stock := req.Stock()
+	return inventory.Validate(&stock)
 }
 
 // RegisterRoutes registers all the stock-related routes for the HTTP server.
@@ -22,11 +56,13 @@ type StockHandlers struct {
 //
 // URL Paths:
 // - POST /stock: Create a new stock entry
+// - GET /stock: List stock entries a page at a time
 // - GET /stock/product/{product_id}: Retrieve stock by product ID
 // - PUT /stock/{id}: Update an existing stock entry by ID
 // - DELETE /stock/{id}: Delete a stock entry by ID
 func (h *StockHandlers) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/stock", h.CreateStock).Methods("POST")
+	router.HandleFunc("/stock", h.ListStock).Methods("GET")
 	router.HandleFunc("/stock/product/{product_id:[0-9]+}", h.GetStockByProductID).Methods("GET")
 	router.HandleFunc("/stock/{id:[0-9]+}", h.UpdateStock).Methods("PUT")
 	router.HandleFunc("/stock/{id:[0-9]+}", h.DeleteStock).Methods("DELETE")
@@ -34,7 +70,7 @@ func (h *StockHandlers) RegisterRoutes(router *mux.Router) {
 
 // CreateStock handles the creation of a new stock entry.
 //
-// This handler reads the incoming request body, decodes it into a Stock struct,
+// This handler reads the incoming request body, decodes it into a StockRequest,
 // and attempts to store it in the database. On successful creation, it returns
 // a status code 201 Created. If an error occurs, it responds with an appropriate
 // status code and error message.
@@ -43,28 +79,55 @@ func (h *StockHandlers) RegisterRoutes(router *mux.Router) {
 // URL Path: /stock
 //
 // Request Body:
-// - JSON representation of a Stock object.
+// - JSON with product_id, quantity, warehouse_id, location and optionally reorder_level (see StockRequest).
 //
 // Response:
-// - Status Code: 201 (Created) if the stock is successfully created.
-// - Status Code: 400 (Bad Request) if the request body is invalid.
+// - Status Code: 201 (Created) with the created stock in JSON.
+// - Status Code: 400 (Bad Request) if the request body is not JSON.
+// - Status Code: 422 (Unprocessable Entity) if the product or warehouse is missing or the quantity or reorder level is negative; the error details list the fields.
 // - Status Code: 500 (Internal Server Error) if the creation fails.
 func (h *StockHandlers) CreateStock(w http.ResponseWriter, r *http.Request) {
-	var req models.Stock
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	var req StockRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	err = h.StockStore.CreateStock(&req)
+	stock := req.Stock()
+	err := h.Service.Create(r.Context(), &stock)
 	if err != nil {
-		http.Error(w, "Could not create stock", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not create stock")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("Stock created successfully"))
+	respond.JSON(w, http.StatusCreated, stock)
+}
+
+// ListStock handles listing stock entries a page at a time.
+//
+// HTTP Method: GET
+// URL Path: /stock?warehouse_id=2&sort=-quantity&page=1&limit=50
+//
+// Query Parameters:
+// - page, limit: The page number (from 1) and its size (default 50, at most 200).
+// - sort: id, product_id, warehouse_id, quantity, location or reorder_level, prefixed with "-" for descending order.
+// - id, product_id, warehouse_id, quantity, location, reorder_level: Filters; text is matched case-insensitively.
+//
+// Response:
+// - Status Code: 200 (OK) with {"items": [...], "total": n, "page": p, "limit": l} in JSON.
+// - Status Code: 422 (Unprocessable Entity) if a parameter is invalid.
+// - Status Code: 500 (Internal Server Error) if the stock cannot be listed.
+func (h *StockHandlers) ListStock(w http.ResponseWriter, r *http.Request) {
+	query, err := pagination.Parse(r, StockColumns)
+	if err != nil {
+		httperr.Write(w, err, "Invalid list query")
+		return
+	}
+	stock, total, err := h.Service.List(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Could not list stock")
+		return
+	}
+	pagination.Write(w, stock, total, query)
 }
 
 // GetStockByProductID handles retrieving stock information by product ID.
@@ -78,31 +141,32 @@ func (h *StockHandlers) CreateStock(w http.ResponseWriter, r *http.Request) {
 // URL Path: /stock/product/{product_id}
 //
 // Response:
-// - Status Code: 200 (OK) and the stock details in JSON if found.
+// - Status Code: 200 (OK) and the stock details in JSON if found, with its version as the ETag.
 // - Status Code: 400 (Bad Request) if the product ID is invalid.
 // - Status Code: 404 (Not Found) if the stock is not found.
+// - Status Code: 500 (Internal Server Error) if the stock cannot be loaded.
 func (h *StockHandlers) GetStockByProductID(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, err := strconv.Atoi(params["product_id"])
 	if err != nil {
-		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	stock, err := h.StockStore.GetStockByProductID(productID)
+	stock, err := h.Service.GetByProduct(r.Context(), productID)
 	if err != nil {
-		http.Error(w, "Stock not found for the given product ID", http.StatusNotFound)
+		httperr.Write(w, err, "Could not load stock")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stock)
+	versioning.SetETag(w, stock.Version)
+	respond.JSON(w, http.StatusOK, stock)
 }
 
 // UpdateStock handles updating an existing stock entry by ID.
 //
 // This handler extracts the stock ID from the URL path, decodes the request body
-// into a Stock struct, updates the stock in the database, and returns a success
+// into a StockRequest, updates the stock in the database, and returns a success
 // response. If an error occurs, it responds with an appropriate status code and
 // error message.
 //
@@ -110,65 +174,74 @@ func (h *StockHandlers) GetStockByProductID(w http.ResponseWriter, r *http.Reque
 // URL Path: /stock/{id}
 //
 // Request Body:
-// - JSON representation of a Stock object to update.
+// - JSON with product_id, quantity, warehouse_id, location, optionally reorder_level and the
+// version read (see StockRequest); the version may be sent as an If-Match header instead.
 //
 // Response:
-// - Status Code: 200 (OK) if the stock is successfully updated.
+// - Status Code: 200 (OK) with the updated stock in JSON, and the new version as the ETag.
 // - Status Code: 400 (Bad Request) if the request body or stock ID is invalid.
+// - Status Code: 404 (Not Found) if the stock entry does not exist.
+// - Status Code: 409 (Conflict) if the stock entry was changed since the version read.
+// - Status Code: 422 (Unprocessable Entity) if the product or warehouse is missing or the quantity or reorder level is negative; the error details list the fields.
+// - Status Code: 428 (Precondition Required) if no version is given.
 // - Status Code: 500 (Internal Server Error) if the update fails.
 func (h *StockHandlers) UpdateStock(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	stockID, err := strconv.Atoi(params["id"])
 	if err != nil {
-		http.Error(w, "Invalid stock ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid stock ID")
 		return
 	}
 
-	var req models.Stock
-	err = json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	var req StockRequest
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
 		return
 	}
 
-	req.ID = stockID
-	err = h.StockStore.UpdateStock(&req)
+	stock := req.Stock()
+	stock.ID, stock.Version = stockID, version
+	err = h.Service.Update(r.Context(), &stock)
 	if err != nil {
-		http.Error(w, "Could not update stock", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not update stock")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Stock updated successfully"))
+	versioning.SetETag(w, stock.Version)
+	respond.JSON(w, http.StatusOK, stock)
 }
 
 // DeleteStock handles deleting a stock entry by ID.
 //
 // This handler extracts the stock ID from the URL path, deletes the stock
-// from the database, and returns a success message. If an error occurs, it
+// from the database, and returns no content. If an error occurs, it
 // responds with an appropriate status code and error message.
 //
 // HTTP Method: DELETE
 // URL Path: /stock/{id}
 //
 // Response:
-// - Status Code: 200 (OK) if the stock is successfully deleted.
+// - Status Code: 204 (No Content) if the stock is successfully deleted.
 // - Status Code: 400 (Bad Request) if the stock ID is invalid.
+// - Status Code: 404 (Not Found) if the stock entry does not exist.
 // - Status Code: 500 (Internal Server Error) if the deletion fails.
 func (h *StockHandlers) DeleteStock(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	stockID, err := strconv.Atoi(params["id"])
 	if err != nil {
-		http.Error(w, "Invalid stock ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid stock ID")
 		return
 	}
 
-	err = h.StockStore.DeleteStock(stockID)
+	err = h.Service.Delete(r.Context(), stockID)
 	if err != nil {
-		http.Error(w, "Could not delete stock", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not delete stock")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Stock deleted successfully"))
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -3,9 +3,11 @@ package stock_handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"erp/controllers/handlers/stock_handlers"
 	"erp/models"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -21,30 +23,35 @@ type MockStockStore struct {
 	mock.Mock
 }
 
-func (m *MockStockStore) CreateStock(stock *models.Stock) error {
+func (m *MockStockStore) CreateStock(ctx context.Context, stock *models.Stock) error {
 	args := m.Called(stock)
 	return args.Error(0)
 }
 
-func (m *MockStockStore) GetStockByProductID(productID int) (*models.Stock, error) {
+func (m *MockStockStore) GetStockByProductID(ctx context.Context, productID int) (*models.Stock, error) {
 	args := m.Called(productID)
 	return args.Get(0).(*models.Stock), args.Error(1)
 }
 
-func (m *MockStockStore) UpdateStock(stock *models.Stock) error {
+func (m *MockStockStore) UpdateStock(ctx context.Context, stock *models.Stock) error {
 	args := m.Called(stock)
 	return args.Error(0)
 }
 
-func (m *MockStockStore) DeleteStock(id int) error {
+func (m *MockStockStore) DeleteStock(ctx context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
+func (m *MockStockStore) ListStock(ctx context.Context, query models.ListQuery) ([]models.Stock, int, error) {
+	args := m.Called(query)
+	return args.Get(0).([]models.Stock), args.Int(1), args.Error(2)
+}
+
 // TestStockHandlers tests the stock-related HTTP handlers.
 func TestStockHandlers(t *testing.T) {
 	mockStore := new(MockStockStore)
-	handler := &stock_handlers.StockHandlers{StockStore: mockStore}
+	handler := stock_handlers.NewStockHandlers(mockStore)
 	router := mux.NewRouter()
 	handler.RegisterRoutes(router)
 
@@ -59,7 +66,9 @@ func TestStockHandlers(t *testing.T) {
 		router.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusCreated, rec.Code)
-		assert.Equal(t, "Stock created successfully", rec.Body.String())
+		var created models.Stock
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		assert.Equal(t, *stock, created)
 		mockStore.AssertCalled(t, "CreateStock", stock)
 	})
 
@@ -83,7 +92,7 @@ func TestStockHandlers(t *testing.T) {
 
 	t.Run("UpdateStock", func(t *testing.T) {
 		stockID := 1
-		stock := &models.Stock{ID: stockID, ProductID: 2, Quantity: 15, WarehouseID: 3, Location: "C3"}
+		stock := &models.Stock{ID: stockID, ProductID: 2, Quantity: 15, WarehouseID: 3, Location: "C3", Version: 4}
 		mockStore.On("UpdateStock", stock).Return(nil)
 
 		body, _ := json.Marshal(stock)
@@ -93,7 +102,9 @@ func TestStockHandlers(t *testing.T) {
 		router.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.Equal(t, "Stock updated successfully", rec.Body.String())
+		var updated models.Stock
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, *stock, updated)
 		mockStore.AssertCalled(t, "UpdateStock", stock)
 	})
 
@@ -106,8 +117,50 @@ func TestStockHandlers(t *testing.T) {
 
 		router.ServeHTTP(rec, req)
 
-		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.Equal(t, "Stock deleted successfully", rec.Body.String())
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Body.String())
 		mockStore.AssertCalled(t, "DeleteStock", stockID)
 	})
+
+	t.Run("ListStock", func(t *testing.T) {
+		query := models.ListQuery{Page: 1, Limit: 50, Sort: "quantity", Desc: true, Filters: map[string]string{"warehouse_id": "2"}}
+		stock := []models.Stock{{ID: 1, ProductID: 1, Quantity: 20, WarehouseID: 2, Location: "B2"}}
+		mockStore.On("ListStock", query).Return(stock, 1, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/stock?warehouse_id=2&sort=-quantity", nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var page struct {
+			Items []models.Stock `json:"items"`
+			Total int            `json:"total"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		assert.Equal(t, stock, page.Items)
+		assert.Equal(t, 1, page.Total)
+		mockStore.AssertCalled(t, "ListStock", query)
+	})
+
+	t.Run("ListStockByUnknownField", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/stock?sku=A1", nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("DeleteMissingStock", func(t *testing.T) {
+		stockID := 99
+		mockStore.On("DeleteStock", stockID).Return(fmt.Errorf("stock with ID %d: %w", stockID, models.ErrNotFound))
+
+		req := httptest.NewRequest(http.MethodDelete, "/stock/"+strconv.Itoa(stockID), nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
 }
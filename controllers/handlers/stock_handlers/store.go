@@ -2,14 +2,27 @@
 package stock_handlers
 
 import (
+	"context"
 	"database/sql"
+	"erp/controllers/events"
+	"erp/controllers/pagination"
+	"erp/controllers/versioning"
 	"erp/models"
+	"erp/models/db"
+	"erp/models/db/queries"
 	"fmt"
 )
 
 // DBStockStore implements the StockStore interface for database operations.
 type DBStockStore struct {
 	DB *sql.DB
+
+	stmts db.Statements // Prepared statements of the typed queries
+}
+
+// queries returns the typed queries of the store, prepared on first use.
+func (s *DBStockStore) queries() *queries.Queries {
+	return queries.Prepared(s.DB, &s.stmts)
 }
 
 // NewDBStockStore initializes a new DBStockStore instance.
@@ -23,23 +36,41 @@ func NewDBStockStore(db *sql.DB) *DBStockStore {
 	return &DBStockStore{DB: db}
 }
 
-// CreateStock inserts a new stock record into the database.
+// CreateStock inserts a new stock record into the database. A StockMoved event is
+// written to the outbox in the same transaction.
 //
 // Parameters:
 // - stock: A pointer to the Stock struct containing the stock details to insert.
 //
 // Returns:
 // - An error if the insertion fails, otherwise nil.
-func (s *DBStockStore) CreateStock(stock *models.Stock) error {
-	query := `
-		INSERT INTO stock (product_id, quantity, warehouse_id, location)
-		VALUES ($1, $2, $3, $4)
-	`
-	_, err := s.DB.Exec(query, stock.ProductID, stock.Quantity, stock.WarehouseID, stock.Location)
+func (s *DBStockStore) CreateStock(ctx context.Context, stock *models.Stock) error {
+	q := s.queries()
+	if err := q.Prepare(ctx, queries.InsertStockSQL); err != nil {
+		return fmt.Errorf("failed to prepare stock insert: %w", err)
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	inserted, err := q.WithTx(tx).InsertStock(ctx, queries.InsertStockParams{
+		ProductID:    stock.ProductID,
+		Quantity:     stock.Quantity,
+		WarehouseID:  stock.WarehouseID,
+		Location:     stock.Location,
+		ReorderLevel: stock.ReorderLevel,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to insert stock: %w", err)
 	}
-	return nil
+	stock.ID, stock.Version = inserted.ID, inserted.Version
+
+	if err := events.Enqueue(ctx, tx, events.StockMoved, "stock", stock.ID, stock); err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+	return tx.Commit()
 }
 
 // GetStockByProductID retrieves a stock record from the database by product ID.
@@ -49,45 +80,102 @@ func (s *DBStockStore) CreateStock(stock *models.Stock) error {
 //
 // Returns:
 // - A pointer to the Stock struct if found.
-// - An error if no record is found or if the query fails.
-func (s *DBStockStore) GetStockByProductID(productID int) (*models.Stock, error) {
-	query := `
-		SELECT id, product_id, quantity, warehouse_id, location
-		FROM stock
-		WHERE product_id = $1
-	`
-	row := s.DB.QueryRow(query, productID)
-
-	var stock models.Stock
-	err := row.Scan(&stock.ID, &stock.ProductID, &stock.Quantity, &stock.WarehouseID, &stock.Location)
+// - An error wrapping models.ErrNotFound if no record is found, or the query error.
+func (s *DBStockStore) GetStockByProductID(ctx context.Context, productID int) (*models.Stock, error) {
+	row, err := s.queries().GetStockByProduct(ctx, productID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no stock found for product ID %d", productID)
+			return nil, fmt.Errorf("no stock found for product ID %d: %w", productID, models.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to retrieve stock: %w", err)
 	}
 
-	return &stock, nil
+	return &models.Stock{
+		ID:           row.ID,
+		ProductID:    row.ProductID,
+		Quantity:     row.Quantity,
+		WarehouseID:  row.WarehouseID,
+		Location:     row.Location,
+		ReorderLevel: row.ReorderLevel,
+		Version:      row.Version,
+	}, nil
+}
+
+// StockColumns are the fields stock records can be listed by.
+var StockColumns = pagination.Columns{
+	"id":            {Expr: "id", Type: pagination.Int},
+	"product_id":    {Expr: "product_id", Type: pagination.Int},
+	"warehouse_id":  {Expr: "warehouse_id", Type: pagination.Int},
+	"quantity":      {Expr: "quantity", Type: pagination.Int},
+	"location":      {Expr: "location", Type: pagination.Text},
+	"reorder_level": {Expr: "reorder_level", Type: pagination.Int},
+}
+
+// ListStock retrieves a page of stock records from the database.
+//
+// Parameters:
+// - query: The page, sort order and filters (see StockColumns).
+//
+// Returns:
+// - The page of stock records and the number of records matching the filters.
+// - An error if a filter is invalid or the query fails.
+func (s *DBStockStore) ListStock(ctx context.Context, query models.ListQuery) ([]models.Stock, int, error) {
+	stock := []models.Stock{}
+	total, err := pagination.Query(ctx, s.DB, "id, product_id, quantity, warehouse_id, COALESCE(location, ''), reorder_level, version", "stock",
+		StockColumns, query, func(rows *sql.Rows) error {
+			var entry models.Stock
+			if err := rows.Scan(&entry.ID, &entry.ProductID, &entry.Quantity, &entry.WarehouseID, &entry.Location,
+				&entry.ReorderLevel, &entry.Version); err != nil {
+				return err
+			}
+			stock = append(stock, entry)
+			return nil
+		})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list stock: %w", err)
+	}
+	return stock, total, nil
 }
 
-// UpdateStock updates an existing stock record in the database.
+// UpdateStock updates an existing stock record in the database if it is still at
+// stock.Version, and sets the new version. A StockMoved event is written to the outbox in
+// the same transaction.
 //
 // Parameters:
 // - stock: A pointer to the Stock struct containing the updated stock details.
 //
 // Returns:
+// - An error wrapping models.ErrNotFound if the stock record does not exist.
+// - An error wrapping models.ErrConflict if the record was changed since stock.Version.
 // - An error if the update fails, otherwise nil.
-func (s *DBStockStore) UpdateStock(stock *models.Stock) error {
-	query := `
-		UPDATE stock
-		SET product_id = $1, quantity = $2, warehouse_id = $3, location = $4
-		WHERE id = $5
-	`
-	_, err := s.DB.Exec(query, stock.ProductID, stock.Quantity, stock.WarehouseID, stock.Location, stock.ID)
+func (s *DBStockStore) UpdateStock(ctx context.Context, stock *models.Stock) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	version, err := s.queries().WithTx(tx).UpdateStock(ctx, queries.UpdateStockParams{
+		ProductID:    stock.ProductID,
+		Quantity:     stock.Quantity,
+		WarehouseID:  stock.WarehouseID,
+		Location:     stock.Location,
+		ReorderLevel: stock.ReorderLevel,
+		ID:           stock.ID,
+		Version:      stock.Version,
+	})
+	if err == sql.ErrNoRows {
+		return versioning.Stale(ctx, tx, "SELECT version FROM stock WHERE id = $1", "stock record", stock.ID, stock.Version)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update stock with ID %d: %w", stock.ID, err)
 	}
-	return nil
+	stock.Version = version
+
+	if err := events.Enqueue(ctx, tx, events.StockMoved, "stock", stock.ID, stock); err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+	return tx.Commit()
 }
 
 // DeleteStock removes a stock record from the database by ID.
@@ -96,15 +184,15 @@ func (s *DBStockStore) UpdateStock(stock *models.Stock) error {
 // - id: An integer representing the stock ID to delete.
 //
 // Returns:
+// - An error wrapping models.ErrNotFound if the stock record does not exist.
 // - An error if the deletion fails, otherwise nil.
-func (s *DBStockStore) DeleteStock(id int) error {
-	query := `
-		DELETE FROM stock
-		WHERE id = $1
-	`
-	_, err := s.DB.Exec(query, id)
+func (s *DBStockStore) DeleteStock(ctx context.Context, id int) error {
+	deleted, err := s.queries().DeleteStock(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete stock with ID %d: %w", id, err)
 	}
+	if deleted == 0 {
+		return fmt.Errorf("stock with ID %d: %w", id, models.ErrNotFound)
+	}
 	return nil
 }
@@ -0,0 +1,51 @@
+package stock_handlers
+
+import (
+	"context"
+	"erp/models/db/dbtest"
+	"erp/models/db/queries"
+	"testing"
+)
+
+// BenchmarkGetStockByProductID compares stock lookups with the SQL text sent on every
+// call ("adhoc", as the store did before) against the store's prepared lookup. It needs
+// ERP_BENCH_DATABASE_URL; see the dbtest package.
+func BenchmarkGetStockByProductID(b *testing.B) {
+	ctx := context.Background()
+	db := dbtest.Open(ctx, b)
+
+	var productID, warehouseID int
+	err := db.QueryRowContext(ctx, "INSERT INTO products (name, price) VALUES ('Benchmark product', 1) RETURNING id").Scan(&productID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.ExecContext(ctx, "DELETE FROM products WHERE id = $1", productID) })
+	err = db.QueryRowContext(ctx, "INSERT INTO warehouses (name, capacity) VALUES ('Benchmark warehouse', 100) RETURNING id").Scan(&warehouseID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.ExecContext(ctx, "DELETE FROM warehouses WHERE id = $1", warehouseID) })
+	_, err = db.ExecContext(ctx, "INSERT INTO stock (product_id, quantity, warehouse_id, location) VALUES ($1, 10, $2, 'A1')", productID, warehouseID)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("adhoc", func(b *testing.B) {
+		adhoc := queries.New(db)
+		for i := 0; i < b.N; i++ {
+			if _, err := adhoc.GetStockByProduct(ctx, productID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		store := NewDBStockStore(db)
+		defer store.stmts.Close()
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetStockByProductID(ctx, productID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package stock_handlers_test
+
+import (
+	"context"
+	"testing"
+
+	"erp/controllers/handlers/stock_handlers"
+	"erp/models"
+	"erp/models/db/dbtest"
+	"erp/models/storetest"
+)
+
+// TestDBStockStoreContract runs the stock store contract against PostgreSQL. It needs
+// ERP_BENCH_DATABASE_URL; see the dbtest package.
+func TestDBStockStoreContract(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(ctx, t)
+
+	var warehouseID int
+	err := db.QueryRowContext(ctx, "INSERT INTO warehouses (name, capacity) VALUES ('Contract warehouse', 100) RETURNING id").Scan(&warehouseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.ExecContext(ctx, "DELETE FROM warehouses WHERE id = $1", warehouseID) })
+
+	storetest.TestStockStore(ctx, t, stock_handlers.NewDBStockStore(db), func(t *testing.T) models.Stock {
+		stock := models.Stock{Quantity: 10, WarehouseID: warehouseID, Location: "A1"}
+		err := db.QueryRowContext(ctx, "INSERT INTO products (name, price) VALUES ('Contract product', 1) RETURNING id").Scan(&stock.ProductID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.ExecContext(ctx, "DELETE FROM products WHERE id = $1", stock.ProductID) })
+		return stock
+	})
+}
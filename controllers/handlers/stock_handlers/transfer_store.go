@@ -0,0 +1,530 @@
+package stock_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/controllers/events"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBStockTransferStore implements models.StockTransferStore using a SQL database. Every
+// status change locks the transfer first, so two users cannot dispatch or receive it twice.
+type DBStockTransferStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// transferColumns are the columns of stock_transfers read by scanTransfer.
+const transferColumns = `id, source_warehouse_id, destination_warehouse_id, status, note, requested_by, requested_at,
+	approved_by, approved_at, dispatched_by, dispatched_at, received_by, received_at, cancelled_by, cancelled_at,
+	discrepancy, discrepancy_note`
+
+// transferQueryer is satisfied by both *sql.DB and *sql.Tx.
+type transferQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// CreateTransfer records a requested transfer with its lines.
+//
+// Parameters:
+//   - transfer: A validated transfer with one line per product; its ID and request time are set.
+//
+// Returns:
+//   - error: A validation error if a warehouse or product does not exist, or the query error.
+func (s *DBStockTransferStore) CreateTransfer(ctx context.Context, transfer *models.StockTransfer) error {
+	productIDs := make(pq.Int64Array, len(transfer.Lines))
+	quantities := make(pq.Int64Array, len(transfer.Lines))
+	for i, line := range transfer.Lines {
+		productIDs[i] = int64(line.ProductID)
+		quantities[i] = int64(line.Quantity)
+	}
+	transfer.RequestedAt = time.Now()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO stock_transfers (source_warehouse_id, destination_warehouse_id, status, note, requested_by, requested_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		transfer.SourceWarehouseID, transfer.DestinationWarehouseID, transfer.Status, transfer.Note,
+		transfer.RequestedBy, transfer.RequestedAt,
+	).Scan(&transfer.ID)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("warehouse %d or %d does not exist", transfer.SourceWarehouseID, transfer.DestinationWarehouseID)
+	} else if err != nil {
+		return fmt.Errorf("failed to record transfer: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO stock_transfer_lines (transfer_id, product_id, quantity)
+		 SELECT $1, l.product_id, l.quantity FROM unnest($2::int[], $3::int[]) AS l(product_id, quantity)`,
+		transfer.ID, productIDs, quantities,
+	)
+	if models.IsForeignKeyViolation(err) {
+		return models.Invalid("a product on the transfer does not exist")
+	} else if err != nil {
+		return fmt.Errorf("failed to record transfer lines: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetTransfer retrieves a transfer with its lines.
+//
+// Parameters:
+//   - id: The ID of the transfer.
+//
+// Returns:
+//   - *models.StockTransfer: The transfer.
+//   - error: models.ErrNotFound if the transfer does not exist, or the query error.
+func (s *DBStockTransferStore) GetTransfer(ctx context.Context, id int) (*models.StockTransfer, error) {
+	return getTransfer(ctx, s.DB, id, "")
+}
+
+// ListTransfers retrieves the transfers in a status, or all of them if status is empty,
+// newest first.
+//
+// Parameters:
+//   - status: One of the models.StockTransfer* statuses, or empty.
+//
+// Returns:
+//   - []models.StockTransfer: The transfers with their lines.
+//   - error: The query error.
+func (s *DBStockTransferStore) ListTransfers(ctx context.Context, status string) ([]models.StockTransfer, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+transferColumns+` FROM stock_transfers WHERE $1 = '' OR status = $1 ORDER BY id DESC`, status)
+	if err != nil {
+		return nil, err
+	}
+	transfers := []models.StockTransfer{}
+	index := map[int]int{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[transfer.ID] = len(transfers)
+		transfers = append(transfers, *transfer)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(transfers) == 0 {
+		return transfers, nil
+	}
+
+	ids := make(pq.Int64Array, 0, len(transfers))
+	for _, transfer := range transfers {
+		ids = append(ids, int64(transfer.ID))
+	}
+	rows, err = s.DB.QueryContext(ctx,
+		`SELECT transfer_id, product_id, quantity, received_quantity FROM stock_transfer_lines
+		 WHERE transfer_id = ANY($1) ORDER BY id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var transferID int
+		line, err := scanTransferLine(rows, &transferID)
+		if err != nil {
+			return nil, err
+		}
+		transfer := &transfers[index[transferID]]
+		transfer.Lines = append(transfer.Lines, line)
+	}
+	return transfers, rows.Err()
+}
+
+// ApproveTransfer allows a requested transfer to be dispatched.
+//
+// Returns:
+//   - *models.StockTransfer: The approved transfer.
+//   - error: models.ErrNotFound, a conflict if the transfer is not requested, or the query error.
+func (s *DBStockTransferStore) ApproveTransfer(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, transfer *models.StockTransfer) error {
+		now := time.Now()
+		transfer.Status, transfer.ApprovedBy, transfer.ApprovedAt = models.StockTransferApproved, actor, &now
+		_, err := tx.ExecContext(ctx, `UPDATE stock_transfers SET status = $1, approved_by = $2, approved_at = $3 WHERE id = $4`,
+			transfer.Status, actor, now, id)
+		return err
+	}, models.StockTransferRequested)
+}
+
+// DispatchTransfer takes the stock of every line from the source warehouse and puts the
+// transfer in transit. A StockMoved event is written to the outbox.
+//
+// Returns:
+//   - *models.StockTransfer: The dispatched transfer.
+//   - error: models.ErrNotFound, a conflict if the transfer is not approved or a product is
+//     not in stock at the source in the quantity transferred, or the query error.
+func (s *DBStockTransferStore) DispatchTransfer(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, transfer *models.StockTransfer) error {
+		for _, line := range transfer.Lines {
+			// The quantity check is repeated outside the subquery after waiting for a
+			// concurrent change of the same stock
+			result, err := tx.ExecContext(ctx,
+				`UPDATE stock SET quantity = quantity - $1
+				 WHERE id = (
+				     SELECT id FROM stock WHERE product_id = $2 AND warehouse_id = $3 AND quantity >= $1
+				     ORDER BY quantity DESC, id LIMIT 1
+				 ) AND quantity >= $1`,
+				line.Quantity, line.ProductID, transfer.SourceWarehouseID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to take stock: %w", err)
+			}
+			if n, err := result.RowsAffected(); err != nil {
+				return err
+			} else if n == 0 {
+				return models.Conflict("product %d is not in stock at warehouse %d in a quantity of %d",
+					line.ProductID, transfer.SourceWarehouseID, line.Quantity)
+			}
+		}
+		now := time.Now()
+		transfer.Status, transfer.DispatchedBy, transfer.DispatchedAt = models.StockTransferInTransit, actor, &now
+		_, err := tx.ExecContext(ctx, `UPDATE stock_transfers SET status = $1, dispatched_by = $2, dispatched_at = $3 WHERE id = $4`,
+			transfer.Status, actor, now, id)
+		if err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, tx, events.StockMoved, "stock_transfer", id, transfer)
+	}, models.StockTransferApproved)
+}
+
+// ReceiveTransfer adds the received quantities to the destination warehouse, to its first
+// stock entry of each product or to a new one, and completes the transfer. A shortfall is
+// recorded on the lines and flags the transfer as a discrepancy. A StockMoved event is
+// written to the outbox.
+//
+// Parameters:
+//   - id: The ID of the transfer.
+//   - receipt: The quantity received of every line, checked against the transfer.
+//   - actor: Email of the user confirming the receipt.
+//
+// Returns:
+//   - *models.StockTransfer: The received transfer.
+//   - error: models.ErrNotFound, a conflict if the transfer is not in transit, or the query error.
+func (s *DBStockTransferStore) ReceiveTransfer(ctx context.Context, id int, receipt models.StockTransferReceipt, actor string) (*models.StockTransfer, error) {
+	received := make(map[int]int, len(receipt.Lines))
+	for _, line := range receipt.Lines {
+		received[line.ProductID] = line.ReceivedQuantity
+	}
+	return s.transition(ctx, id, func(tx *sql.Tx, transfer *models.StockTransfer) error {
+		for i := range transfer.Lines {
+			line := &transfer.Lines[i]
+			quantity := received[line.ProductID]
+			line.ReceivedQuantity, line.Short = &quantity, line.Quantity-quantity
+			if line.Short > 0 {
+				transfer.Discrepancy = true
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE stock_transfer_lines SET received_quantity = $1 WHERE transfer_id = $2 AND product_id = $3`,
+				quantity, id, line.ProductID); err != nil {
+				return err
+			}
+			if quantity == 0 {
+				continue
+			}
+			result, err := tx.ExecContext(ctx,
+				`UPDATE stock SET quantity = quantity + $1
+				 WHERE id = (SELECT id FROM stock WHERE product_id = $2 AND warehouse_id = $3 ORDER BY id LIMIT 1)`,
+				quantity, line.ProductID, transfer.DestinationWarehouseID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to add stock: %w", err)
+			}
+			if n, err := result.RowsAffected(); err != nil {
+				return err
+			} else if n == 0 {
+				_, err := tx.ExecContext(ctx, `INSERT INTO stock (product_id, quantity, warehouse_id, location) VALUES ($1, $2, $3, '')`,
+					line.ProductID, quantity, transfer.DestinationWarehouseID)
+				if err != nil {
+					return fmt.Errorf("failed to add stock: %w", err)
+				}
+			}
+		}
+		if transfer.Discrepancy {
+			transfer.DiscrepancyNote = receipt.Note
+		}
+		now := time.Now()
+		transfer.Status, transfer.ReceivedBy, transfer.ReceivedAt = models.StockTransferReceived, actor, &now
+		_, err := tx.ExecContext(ctx,
+			`UPDATE stock_transfers SET status = $1, received_by = $2, received_at = $3, discrepancy = $4, discrepancy_note = $5
+			 WHERE id = $6`,
+			transfer.Status, actor, now, transfer.Discrepancy, transfer.DiscrepancyNote, id)
+		if err != nil {
+			return err
+		}
+		return events.Enqueue(ctx, tx, events.StockMoved, "stock_transfer", id, transfer)
+	}, models.StockTransferInTransit)
+}
+
+// CancelTransfer cancels a transfer that has not been dispatched.
+//
+// Returns:
+//   - *models.StockTransfer: The cancelled transfer.
+//   - error: models.ErrNotFound, a conflict if the transfer has been dispatched or is
+//     already cancelled, or the query error.
+func (s *DBStockTransferStore) CancelTransfer(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return s.transition(ctx, id, func(tx *sql.Tx, transfer *models.StockTransfer) error {
+		now := time.Now()
+		transfer.Status, transfer.CancelledBy, transfer.CancelledAt = models.StockTransferCancelled, actor, &now
+		_, err := tx.ExecContext(ctx, `UPDATE stock_transfers SET status = $1, cancelled_by = $2, cancelled_at = $3 WHERE id = $4`,
+			transfer.Status, actor, now, id)
+		return err
+	}, models.StockTransferRequested, models.StockTransferApproved)
+}
+
+// MoveStock moves a quantity of a product between two warehouses in one transaction. Both
+// warehouses are locked, in ID order so two opposite moves cannot deadlock, while the
+// destination's room is checked and the stock is moved. The quantity is taken from one
+// stock entry at the source holding enough of it and added to the destination's first
+// entry of the product, or to a new one. Both legs are recorded as stock movements and a
+// StockMoved event is written to the outbox.
+//
+// Parameters:
+//   - move: A validated move; its movements are set.
+//   - actor: Email of the user moving the stock.
+//
+// Returns:
+//   - error: A validation error if a warehouse does not exist, a conflict if the source
+//     has less of the product or the destination would hold more than its capacity, or
+//     the query error.
+func (s *DBStockTransferStore) MoveStock(ctx context.Context, move *models.StockMove, actor string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, capacity FROM warehouses WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`,
+		move.SourceWarehouseID, move.DestinationWarehouseID)
+	if err != nil {
+		return err
+	}
+	capacities := map[int]int{}
+	for rows.Next() {
+		var id, capacity int
+		if err := rows.Scan(&id, &capacity); err != nil {
+			rows.Close()
+			return err
+		}
+		capacities[id] = capacity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range []int{move.SourceWarehouseID, move.DestinationWarehouseID} {
+		if _, ok := capacities[id]; !ok {
+			return models.Invalid("warehouse %d does not exist", id)
+		}
+	}
+
+	var stored int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(quantity), 0) FROM stock WHERE warehouse_id = $1`,
+		move.DestinationWarehouseID).Scan(&stored); err != nil {
+		return err
+	}
+	if capacity := capacities[move.DestinationWarehouseID]; stored+move.Quantity > capacity {
+		return models.Conflict("warehouse %d holds %d of its %d units and has no room for %d more",
+			move.DestinationWarehouseID, stored, capacity, move.Quantity)
+	}
+
+	// The quantity check is repeated outside the subquery after waiting for a concurrent
+	// change of the same stock
+	result, err := tx.ExecContext(ctx,
+		`UPDATE stock SET quantity = quantity - $1
+		 WHERE id = (
+		     SELECT id FROM stock WHERE product_id = $2 AND warehouse_id = $3 AND quantity >= $1
+		     ORDER BY quantity DESC, id LIMIT 1
+		 ) AND quantity >= $1`,
+		move.Quantity, move.ProductID, move.SourceWarehouseID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to take stock: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.Conflict("product %d is not in stock at warehouse %d in a quantity of %d",
+			move.ProductID, move.SourceWarehouseID, move.Quantity)
+	}
+	result, err = tx.ExecContext(ctx,
+		`UPDATE stock SET quantity = quantity + $1
+		 WHERE id = (SELECT id FROM stock WHERE product_id = $2 AND warehouse_id = $3 ORDER BY id LIMIT 1)`,
+		move.Quantity, move.ProductID, move.DestinationWarehouseID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add stock: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		_, err := tx.ExecContext(ctx, `INSERT INTO stock (product_id, quantity, warehouse_id, location) VALUES ($1, $2, $3, '')`,
+			move.ProductID, move.Quantity, move.DestinationWarehouseID)
+		if err != nil {
+			return fmt.Errorf("failed to add stock: %w", err)
+		}
+	}
+
+	now := time.Now()
+	move.Movements = []models.StockMovement{
+		{ProductID: move.ProductID, WarehouseID: move.SourceWarehouseID, Quantity: -move.Quantity,
+			Kind: models.StockMovementTransferOut, CounterpartWarehouseID: move.DestinationWarehouseID},
+		{ProductID: move.ProductID, WarehouseID: move.DestinationWarehouseID, Quantity: move.Quantity,
+			Kind: models.StockMovementTransferIn, CounterpartWarehouseID: move.SourceWarehouseID},
+	}
+	for i := range move.Movements {
+		m := &move.Movements[i]
+		m.Note, m.MovedBy, m.MovedAt = move.Note, actor, now
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO stock_movements (product_id, warehouse_id, quantity, kind, counterpart_warehouse_id, note, moved_by, moved_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			m.ProductID, m.WarehouseID, m.Quantity, m.Kind, m.CounterpartWarehouseID, m.Note, m.MovedBy, m.MovedAt,
+		).Scan(&m.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if err := events.Enqueue(ctx, tx, events.StockMoved, "stock_movement", move.Movements[0].ID, move); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListStockMovements returns the movements of a product and a warehouse, newest first; an ID
+// of 0 matches every product or warehouse.
+func (s *DBStockTransferStore) ListStockMovements(ctx context.Context, productID, warehouseID int) ([]models.StockMovement, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, product_id, warehouse_id, quantity, kind, COALESCE(counterpart_warehouse_id, 0), note, moved_by, moved_at
+		 FROM stock_movements
+		 WHERE ($1 = 0 OR product_id = $1) AND ($2 = 0 OR warehouse_id = $2)
+		 ORDER BY moved_at DESC, id DESC`, productID, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var m models.StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.WarehouseID, &m.Quantity, &m.Kind, &m.CounterpartWarehouseID,
+			&m.Note, &m.MovedBy, &m.MovedAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+// transition locks a transfer, checks that it is in one of the statuses in from and applies
+// change in the same transaction.
+func (s *DBStockTransferStore) transition(ctx context.Context, id int, change func(tx *sql.Tx, transfer *models.StockTransfer) error, from ...string) (*models.StockTransfer, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	transfer, err := getTransfer(ctx, tx, id, " FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || transfer.Status == status
+	}
+	if !allowed {
+		return nil, models.Conflict("transfer %d is %s", id, transfer.Status)
+	}
+	if err := change(tx, transfer); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// getTransfer reads a transfer and its lines, adding lock to the query of the transfer.
+func getTransfer(ctx context.Context, q transferQueryer, id int, lock string) (*models.StockTransfer, error) {
+	transfer, err := scanTransfer(q.QueryRowContext(ctx, `SELECT `+transferColumns+` FROM stock_transfers WHERE id = $1`+lock, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("transfer %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT transfer_id, product_id, quantity, received_quantity FROM stock_transfer_lines
+		 WHERE transfer_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var transferID int
+		line, err := scanTransferLine(rows, &transferID)
+		if err != nil {
+			return nil, err
+		}
+		transfer.Lines = append(transfer.Lines, line)
+	}
+	return transfer, rows.Err()
+}
+
+// transferScanner is satisfied by both *sql.Row and *sql.Rows.
+type transferScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTransfer reads a row of transferColumns.
+func scanTransfer(row transferScanner) (*models.StockTransfer, error) {
+	var t models.StockTransfer
+	var approvedBy, dispatchedBy, receivedBy, cancelledBy, discrepancyNote sql.NullString
+	var approvedAt, dispatchedAt, receivedAt, cancelledAt sql.NullTime
+	err := row.Scan(&t.ID, &t.SourceWarehouseID, &t.DestinationWarehouseID, &t.Status, &t.Note, &t.RequestedBy, &t.RequestedAt,
+		&approvedBy, &approvedAt, &dispatchedBy, &dispatchedAt, &receivedBy, &receivedAt, &cancelledBy, &cancelledAt,
+		&t.Discrepancy, &discrepancyNote)
+	if err != nil {
+		return nil, err
+	}
+	t.ApprovedBy, t.ApprovedAt = approvedBy.String, timePtr(approvedAt)
+	t.DispatchedBy, t.DispatchedAt = dispatchedBy.String, timePtr(dispatchedAt)
+	t.ReceivedBy, t.ReceivedAt = receivedBy.String, timePtr(receivedAt)
+	t.CancelledBy, t.CancelledAt = cancelledBy.String, timePtr(cancelledAt)
+	t.DiscrepancyNote = discrepancyNote.String
+	t.Lines = []models.StockTransferLine{}
+	return &t, nil
+}
+
+// scanTransferLine reads a transfer line and the ID of its transfer.
+func scanTransferLine(rows *sql.Rows, transferID *int) (models.StockTransferLine, error) {
+	var line models.StockTransferLine
+	var received sql.NullInt64
+	if err := rows.Scan(transferID, &line.ProductID, &line.Quantity, &received); err != nil {
+		return line, err
+	}
+	if received.Valid {
+		quantity := int(received.Int64)
+		line.ReceivedQuantity, line.Short = &quantity, line.Quantity-quantity
+	}
+	return line, nil
+}
+
+// timePtr returns the time, or nil if it is NULL.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
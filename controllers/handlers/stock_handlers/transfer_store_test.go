@@ -0,0 +1,198 @@
+package stock_handlers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectLockedTransfer expects a transfer from warehouse 1 to 2 in status to be locked and
+// read with its lines of product 7 (5 units) and product 8 (1 unit).
+func expectLockedTransfer(mock sqlmock.Sqlmock, status string) {
+	dispatched := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_transfers WHERE id = $1 FOR UPDATE")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "source_warehouse_id", "destination_warehouse_id", "status", "note",
+			"requested_by", "requested_at", "approved_by", "approved_at", "dispatched_by", "dispatched_at", "received_by",
+			"received_at", "cancelled_by", "cancelled_at", "discrepancy", "discrepancy_note"}).
+			AddRow(4, 1, 2, status, "", "clerk@example.com", dispatched, "admin@example.com", dispatched,
+				"clerk@example.com", dispatched, nil, nil, nil, nil, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_transfer_lines")).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"transfer_id", "product_id", "quantity", "received_quantity"}).
+			AddRow(4, 7, 5, nil).AddRow(4, 8, 1, nil))
+}
+
+func TestDispatchTransferOutOfStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockTransferStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedTransfer(mock, models.StockTransferApproved)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity - $1")).WithArgs(5, 7, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity - $1")).WithArgs(1, 8, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err = store.DispatchTransfer(context.Background(), 4, "clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatchTransferWrongStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockTransferStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedTransfer(mock, models.StockTransferRequested)
+	mock.ExpectRollback()
+
+	_, err = store.DispatchTransfer(context.Background(), 4, "clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceiveTransferShort(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockTransferStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedTransfer(mock, models.StockTransferInTransit)
+	// Product 7 is added to the destination's existing stock
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock_transfer_lines SET received_quantity")).WithArgs(3, 4, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity + $1")).WithArgs(3, 7, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Product 8 never arrived
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock_transfer_lines SET received_quantity")).WithArgs(0, 4, 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock_transfers SET status")).
+		WithArgs(models.StockTransferReceived, "receiver@example.com", sqlmock.AnyArg(), true, "Pallet damaged", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	transfer, err := store.ReceiveTransfer(context.Background(), 4, models.StockTransferReceipt{Lines: []models.StockTransferReceiptLine{
+		{ProductID: 7, ReceivedQuantity: 3}, {ProductID: 8, ReceivedQuantity: 0},
+	}, Note: "Pallet damaged"}, "receiver@example.com")
+	require.NoError(t, err)
+	assert.True(t, transfer.Discrepancy)
+	assert.Equal(t, 2, transfer.Lines[0].Short)
+	assert.Equal(t, 1, transfer.Lines[1].Short)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceiveTransferNewStockEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockTransferStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedTransfer(mock, models.StockTransferInTransit)
+	for _, line := range []struct{ product, quantity int }{{7, 5}, {8, 1}} {
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE stock_transfer_lines SET received_quantity")).WithArgs(line.quantity, 4, line.product).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		// The destination has no stock of the product yet
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity + $1")).WithArgs(line.quantity, line.product, 2).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO stock (product_id, quantity, warehouse_id, location)")).
+			WithArgs(line.product, line.quantity, 2).WillReturnResult(sqlmock.NewResult(9, 1))
+	}
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock_transfers SET status")).
+		WithArgs(models.StockTransferReceived, "receiver@example.com", sqlmock.AnyArg(), false, "", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	transfer, err := store.ReceiveTransfer(context.Background(), 4, models.StockTransferReceipt{Lines: []models.StockTransferReceiptLine{
+		{ProductID: 7, ReceivedQuantity: 5}, {ProductID: 8, ReceivedQuantity: 1},
+	}}, "receiver@example.com")
+	require.NoError(t, err)
+	assert.False(t, transfer.Discrepancy)
+	assert.Equal(t, models.StockTransferReceived, transfer.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectLockedWarehouses expects warehouses 1 and 2 to be locked, with room for 100 units
+// each, and warehouse 2 to hold stored units.
+func expectLockedWarehouses(mock sqlmock.Sqlmock, stored int) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM warehouses WHERE id IN ($1, $2) ORDER BY id FOR UPDATE")).WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "capacity"}).AddRow(1, 100).AddRow(2, 100))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(quantity), 0) FROM stock WHERE warehouse_id = $1")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(stored))
+}
+
+func TestMoveStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockTransferStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedWarehouses(mock, 90)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity - $1")).WithArgs(10, 7, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The destination has no stock of the product yet
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity + $1")).WithArgs(10, 7, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO stock").WithArgs(7, 10, 2).WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(7, 1, -10, models.StockMovementTransferOut, 2, "Rebalance", "clerk@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(7, 2, 10, models.StockMovementTransferIn, 1, "Rebalance", "clerk@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(32))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	move := &models.StockMove{ProductID: 7, Quantity: 10, SourceWarehouseID: 1, DestinationWarehouseID: 2, Note: "Rebalance"}
+	require.NoError(t, store.MoveStock(context.Background(), move, "clerk@example.com"))
+	require.Len(t, move.Movements, 2)
+	assert.Equal(t, 31, move.Movements[0].ID)
+	assert.Equal(t, 10, move.Movements[1].Quantity)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveStockOverCapacity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockTransferStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedWarehouses(mock, 91)
+	mock.ExpectRollback()
+
+	err = store.MoveStock(context.Background(), &models.StockMove{ProductID: 7, Quantity: 10, SourceWarehouseID: 1, DestinationWarehouseID: 2}, "clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveStockUnknownWarehouse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBStockTransferStore{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM warehouses WHERE id IN ($1, $2) ORDER BY id FOR UPDATE")).WithArgs(1, 9).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "capacity"}).AddRow(1, 100))
+	mock.ExpectRollback()
+
+	err = store.MoveStock(context.Background(), &models.StockMove{ProductID: 7, Quantity: 10, SourceWarehouseID: 1, DestinationWarehouseID: 9}, "clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,324 @@
+package stock_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/inventory"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// TransferHandlers provides the endpoints under /stock/transfers. The transfer rules live in
+// the inventory transfer service; the handlers only translate HTTP.
+type TransferHandlers struct {
+	Service *inventory.TransferService
+	Now     func() time.Time
+}
+
+// NewTransferHandlers creates transfer handlers backed by store.
+func NewTransferHandlers(store models.StockTransferStore) *TransferHandlers {
+	return &TransferHandlers{Service: inventory.NewTransferService(store), Now: time.Now}
+}
+
+// TransferRequest is the request body for requesting a transfer. The status and who did
+// what when are set by the server.
+type TransferRequest struct {
+	SourceWarehouseID      int                   `json:"source_warehouse_id"`
+	DestinationWarehouseID int                   `json:"destination_warehouse_id"`
+	Note                   string                `json:"note"`
+	Lines                  []TransferLineRequest `json:"lines"`
+}
+
+// TransferLineRequest is one product of a TransferRequest.
+type TransferLineRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// Transfer returns the transfer described by the request.
+func (req TransferRequest) Transfer() models.StockTransfer {
+	lines := make([]models.StockTransferLine, len(req.Lines))
+	for i, line := range req.Lines {
+		lines[i] = models.StockTransferLine{ProductID: line.ProductID, Quantity: line.Quantity}
+	}
+	return models.StockTransfer{
+		SourceWarehouseID:      req.SourceWarehouseID,
+		DestinationWarehouseID: req.DestinationWarehouseID,
+		Note:                   req.Note,
+		Lines:                  lines,
+	}
+}
+
+// RequestTransfer requests moving products from one warehouse to another. No stock moves
+// until the transfer is approved and dispatched.
+//
+// HTTP Method: POST
+// URL Path: /stock/transfers
+//
+// Request Body:
+//   - JSON with source_warehouse_id, destination_warehouse_id, an optional note and lines of
+//     product_id and quantity (see TransferRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the requested transfer in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the transfer is incomplete, is within one
+//     warehouse or names a warehouse or product that does not exist.
+//   - Status Code: 500 (Internal Server Error) if the transfer cannot be recorded.
+func (h *TransferHandlers) RequestTransfer(w http.ResponseWriter, r *http.Request) {
+	var req TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	transfer := req.Transfer()
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.Request(r.Context(), &transfer, actor); err != nil {
+		httperr.Write(w, err, "Failed to request transfer")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, transfer)
+}
+
+// ListTransfers lists transfers, newest first.
+//
+// HTTP Method: GET
+// URL Path: /stock/transfers?status=in_transit
+// (status is optional: requested, approved, in_transit, received or cancelled)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of transfers in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the transfers cannot be loaded.
+func (h *TransferHandlers) ListTransfers(w http.ResponseWriter, r *http.Request) {
+	transfers, err := h.Service.List(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load transfers")
+		return
+	}
+	respond.JSON(w, http.StatusOK, transfers)
+}
+
+// GetTransfer returns a transfer with its lines.
+//
+// HTTP Method: GET
+// URL Path: /stock/transfers/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the transfer in JSON.
+//   - Status Code: 404 (Not Found) if the transfer does not exist.
+//   - Status Code: 500 (Internal Server Error) if the transfer cannot be loaded.
+func (h *TransferHandlers) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	transfer, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load transfer")
+		return
+	}
+	respond.JSON(w, http.StatusOK, transfer)
+}
+
+// ApproveTransfer approves a requested transfer for dispatch.
+//
+// HTTP Method: POST
+// URL Path: /stock/transfers/{id}/approve
+//
+// Response:
+//   - Status Code: 200 (OK) with the approved transfer in JSON.
+//   - Status Code: 404 (Not Found) if the transfer does not exist.
+//   - Status Code: 409 (Conflict) if the transfer is not awaiting approval.
+//   - Status Code: 500 (Internal Server Error) if the transfer cannot be approved.
+func (h *TransferHandlers) ApproveTransfer(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Approve, "Failed to approve transfer")
+}
+
+// DispatchTransfer ships an approved transfer: its stock is taken from the source
+// warehouse and it is in transit until received.
+//
+// HTTP Method: POST
+// URL Path: /stock/transfers/{id}/dispatch
+//
+// Response:
+//   - Status Code: 200 (OK) with the dispatched transfer in JSON.
+//   - Status Code: 404 (Not Found) if the transfer does not exist.
+//   - Status Code: 409 (Conflict) if the transfer is not approved, or a product is not in
+//     stock at the source warehouse in the quantity transferred.
+//   - Status Code: 500 (Internal Server Error) if the transfer cannot be dispatched.
+func (h *TransferHandlers) DispatchTransfer(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Dispatch, "Failed to dispatch transfer")
+}
+
+// CancelTransfer cancels a transfer that has not been dispatched.
+//
+// HTTP Method: POST
+// URL Path: /stock/transfers/{id}/cancel
+//
+// Response:
+//   - Status Code: 200 (OK) with the cancelled transfer in JSON.
+//   - Status Code: 404 (Not Found) if the transfer does not exist.
+//   - Status Code: 409 (Conflict) if the transfer has been dispatched or is already cancelled.
+//   - Status Code: 500 (Internal Server Error) if the transfer cannot be cancelled.
+func (h *TransferHandlers) CancelTransfer(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.Service.Cancel, "Failed to cancel transfer")
+}
+
+// ReceiveTransfer confirms what arrived at the destination warehouse and adds it to the
+// destination's stock. A short receipt completes the transfer with a discrepancy; the
+// missing quantity is not returned to the source.
+//
+// HTTP Method: POST
+// URL Path: /stock/transfers/{id}/receive
+//
+// Request Body:
+//   - JSON with lines of product_id and received_quantity for the products that did not
+//     arrive in full, and a note explaining the shortfall. An empty body receives everything.
+//
+// Response:
+//   - Status Code: 200 (OK) with the received transfer in JSON, including each line's shortfall.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the transfer does not exist.
+//   - Status Code: 409 (Conflict) if the transfer is not in transit.
+//   - Status Code: 422 (Unprocessable Entity) if a product is not on the transfer, more is
+//     received than was dispatched or a shortfall has no note.
+//   - Status Code: 500 (Internal Server Error) if the receipt cannot be recorded.
+func (h *TransferHandlers) ReceiveTransfer(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var receipt models.StockTransferReceipt
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	transfer, err := h.Service.Receive(r.Context(), id, receipt, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to receive transfer")
+		return
+	}
+	respond.JSON(w, http.StatusOK, transfer)
+}
+
+// MoveRequest is the request body for moving stock directly between warehouses.
+type MoveRequest struct {
+	ProductID              int    `json:"product_id"`
+	Quantity               int    `json:"quantity"`
+	SourceWarehouseID      int    `json:"source_warehouse_id"`
+	DestinationWarehouseID int    `json:"destination_warehouse_id"`
+	Note                   string `json:"note"`
+}
+
+// Move returns the move described by the request.
+func (req MoveRequest) Move() models.StockMove {
+	return models.StockMove{
+		ProductID:              req.ProductID,
+		Quantity:               req.Quantity,
+		SourceWarehouseID:      req.SourceWarehouseID,
+		DestinationWarehouseID: req.DestinationWarehouseID,
+		Note:                   req.Note,
+	}
+}
+
+// MoveStock moves a quantity of a product from one warehouse to another at once, in a
+// single transaction, and records both legs in the stock movement history. Unlike a
+// requested transfer it needs no approval and is never in transit.
+//
+// HTTP Method: POST
+// URL Path: /stock/transfer
+//
+// Request Body:
+//   - JSON with product_id, quantity, source_warehouse_id, destination_warehouse_id and an
+//     optional note (see MoveRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the move and its two movements in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if the source warehouse has less of the product or the
+//     destination warehouse has no room for it.
+//   - Status Code: 422 (Unprocessable Entity) if the move is incomplete, is within one
+//     warehouse or names a warehouse that does not exist.
+//   - Status Code: 500 (Internal Server Error) if the stock cannot be moved.
+func (h *TransferHandlers) MoveStock(w http.ResponseWriter, r *http.Request) {
+	var req MoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	move := req.Move()
+	if err := h.Service.Move(r.Context(), &move, actor); err != nil {
+		httperr.Write(w, err, "Failed to move stock")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, move)
+}
+
+// ListStockMovements returns the stock movement history, newest first.
+//
+// HTTP Method: GET
+// URL Path: /stock/movements?product_id=7&warehouse_id=2 (both optional)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of StockMovements in JSON.
+//   - Status Code: 400 (Bad Request) if product_id or warehouse_id is not a number.
+//   - Status Code: 500 (Internal Server Error) if the movements cannot be loaded.
+func (h *TransferHandlers) ListStockMovements(w http.ResponseWriter, r *http.Request) {
+	ids := map[string]int{"product_id": 0, "warehouse_id": 0}
+	for name := range ids {
+		if value := r.URL.Query().Get(name); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				respond.Error(w, http.StatusBadRequest, name+" must be a number")
+				return
+			}
+			ids[name] = id
+		}
+	}
+	movements, err := h.Service.Movements(r.Context(), ids["product_id"], ids["warehouse_id"])
+	if err != nil {
+		httperr.Write(w, err, "Failed to load stock movements")
+		return
+	}
+	respond.JSON(w, http.StatusOK, movements)
+}
+
+// GetInTransitReport lists the transfers dispatched but not yet received, longest on the
+// road first, with the total quantity in transit.
+//
+// HTTP Method: GET
+// URL Path: /reports/stock_in_transit
+//
+// Response:
+//   - Status Code: 200 (OK) with the StockInTransitReport in JSON.
+//   - Status Code: 500 (Internal Server Error) if the transfers cannot be loaded.
+func (h *TransferHandlers) GetInTransitReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.InTransit(r.Context(), h.Now())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load transfers in transit")
+		return
+	}
+	respond.JSON(w, http.StatusOK, report)
+}
+
+// change applies a status change to the transfer in the URL and writes the result.
+func (h *TransferHandlers) change(w http.ResponseWriter, r *http.Request,
+	apply func(ctx context.Context, id int, actor string) (*models.StockTransfer, error), failure string) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	transfer, err := apply(r.Context(), id, actor)
+	if err != nil {
+		httperr.Write(w, err, failure)
+		return
+	}
+	respond.JSON(w, http.StatusOK, transfer)
+}
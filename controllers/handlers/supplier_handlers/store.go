@@ -0,0 +1,340 @@
+package supplier_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/controllers/suppliers"
+	"erp/controllers/versioning"
+	"erp/models"
+)
+
+// DBSupplierStore provides SQL-backed methods for suppliers, their advances and bills.
+type DBSupplierStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// CreateSupplier inserts a supplier.
+//
+// Parameters:
+//   - supplier: The supplier; its ID is set.
+//
+// Returns:
+//   - error: A models.Conflict error if a supplier with the name exists, or an error if the insert fails.
+func (store *DBSupplierStore) CreateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	err := store.DB.QueryRowContext(ctx,
+		`INSERT INTO suppliers (name, email, phone, active, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, version`,
+		supplier.Name, supplier.Email, supplier.Phone, supplier.Active, supplier.CreatedAt,
+	).Scan(&supplier.ID, &supplier.Version)
+	return duplicateName(err, supplier.Name)
+}
+
+// duplicateName converts a unique violation on the supplier name to a conflict.
+func duplicateName(err error, name string) error {
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("a supplier named %q already exists", name)
+	}
+	return err
+}
+
+// GetSupplier retrieves a supplier by its ID.
+//
+// Returns:
+//   - *models.Supplier: The supplier.
+//   - error: models.ErrNotFound if the supplier does not exist, or an error if the query fails.
+func (store *DBSupplierStore) GetSupplier(ctx context.Context, id int) (*models.Supplier, error) {
+	var supplier models.Supplier
+	err := store.DB.QueryRowContext(ctx, `SELECT id, name, email, phone, active, created_at, version FROM suppliers WHERE id = $1`, id).
+		Scan(&supplier.ID, &supplier.Name, &supplier.Email, &supplier.Phone, &supplier.Active, &supplier.CreatedAt,
+			&supplier.Version)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("supplier %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+// GetSuppliers retrieves every supplier, ordered by name.
+//
+// Returns:
+//   - []models.Supplier: The suppliers.
+//   - error: An error if the query fails.
+func (store *DBSupplierStore) GetSuppliers(ctx context.Context) ([]models.Supplier, error) {
+	rows, err := store.DB.QueryContext(ctx, `SELECT id, name, email, phone, active, created_at, version FROM suppliers ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Supplier{}
+	for rows.Next() {
+		var supplier models.Supplier
+		if err := rows.Scan(&supplier.ID, &supplier.Name, &supplier.Email, &supplier.Phone, &supplier.Active,
+			&supplier.CreatedAt, &supplier.Version); err != nil {
+			return nil, err
+		}
+		list = append(list, supplier)
+	}
+	return list, rows.Err()
+}
+
+// UpdateSupplier changes a supplier's name, contact details and whether it is active, if the
+// supplier is still at supplier.Version, and sets the new version.
+//
+// Returns:
+//   - error: models.ErrNotFound if the supplier does not exist, a models.Conflict error if
+//     another supplier has the name or the supplier was changed since supplier.Version, or
+//     an error if the update fails.
+func (store *DBSupplierStore) UpdateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	err := store.DB.QueryRowContext(ctx,
+		`UPDATE suppliers SET name = $1, email = $2, phone = $3, active = $4 WHERE id = $5 AND version = $6 RETURNING version`,
+		supplier.Name, supplier.Email, supplier.Phone, supplier.Active, supplier.ID, supplier.Version,
+	).Scan(&supplier.Version)
+	if err == sql.ErrNoRows {
+		return versioning.Stale(ctx, store.DB, `SELECT version FROM suppliers WHERE id = $1`, "supplier", supplier.ID, supplier.Version)
+	}
+	return duplicateName(err, supplier.Name)
+}
+
+// CreateSupplierAdvance records an advance and posts it to the ledger in one transaction:
+// the prepayment account is debited and cash credited.
+//
+// Parameters:
+//   - advance: The validated advance; its ID is set.
+//
+// Returns:
+//   - error: An error if the advance cannot be recorded or posted.
+func (store *DBSupplierStore) CreateSupplierAdvance(ctx context.Context, advance *models.SupplierAdvance) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO supplier_advances (supplier_id, amount, adjusted, paid_on, method, reference, created_by, created_at)
+		 VALUES ($1, $2, 0, $3, $4, $5, $6, $7) RETURNING id`,
+		advance.SupplierID, advance.Amount, advance.PaidOn, advance.Method, advance.Reference, advance.CreatedBy,
+		advance.CreatedAt,
+	).Scan(&advance.ID)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Advance %d to supplier %d", advance.ID, advance.SupplierID)
+	err = post(ctx, tx, advance.PaidOn, description, advance.Amount, suppliers.AdvanceAccount, suppliers.CashAccount)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSupplierAdvances lists advances in the order they are offset: oldest payment first.
+//
+// Parameters:
+//   - supplierID: The supplier, or 0 for every supplier.
+//   - unadjustedOnly: Whether to leave out advances fully offset against bills.
+//
+// Returns:
+//   - []models.SupplierAdvance: The advances.
+//   - error: An error if the query fails.
+func (store *DBSupplierStore) GetSupplierAdvances(ctx context.Context, supplierID int, unadjustedOnly bool) ([]models.SupplierAdvance, error) {
+	return queryAdvances(ctx, store.DB,
+		`WHERE ($1 = 0 OR supplier_id = $1) AND (NOT $2 OR adjusted < amount) ORDER BY paid_on, id`,
+		supplierID, unadjustedOnly)
+}
+
+// queryAdvances reads the advances matching a WHERE clause and its arguments.
+func queryAdvances(ctx context.Context, q models.Queryer, where string, args ...interface{}) ([]models.SupplierAdvance, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, supplier_id, amount, adjusted, paid_on, method, reference, created_by, created_at
+		 FROM supplier_advances `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	advances := []models.SupplierAdvance{}
+	for rows.Next() {
+		var advance models.SupplierAdvance
+		if err := rows.Scan(&advance.ID, &advance.SupplierID, &advance.Amount, &advance.Adjusted, &advance.PaidOn,
+			&advance.Method, &advance.Reference, &advance.CreatedBy, &advance.CreatedAt); err != nil {
+			return nil, err
+		}
+		advances = append(advances, advance)
+	}
+	return advances, rows.Err()
+}
+
+// CreateSupplierBill records a bill in one transaction. The supplier's unadjusted advances
+// are locked and offset against it, oldest first. The bill is debited to its account and
+// credited to accounts payable; the advances applied are then moved from the prepayment
+// account to accounts payable.
+//
+// Parameters:
+//   - bill: The validated bill; its ID, applications, advances applied and balance are set.
+//
+// Returns:
+//   - error: A models.Conflict error if the supplier's bill number has been recorded
+//     already, or an error if the bill cannot be recorded or posted.
+func (store *DBSupplierStore) CreateSupplierBill(ctx context.Context, bill *models.SupplierBill) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	advances, err := queryAdvances(ctx, tx,
+		`WHERE supplier_id = $1 AND adjusted < amount ORDER BY paid_on, id FOR UPDATE`, bill.SupplierID)
+	if err != nil {
+		return err
+	}
+	bill.Applications = suppliers.Offset(advances, bill.Amount)
+	bill.AdvanceApplied = 0
+	for _, application := range bill.Applications {
+		bill.AdvanceApplied += application.Amount
+	}
+	bill.AdvanceApplied = roundCents(bill.AdvanceApplied)
+	bill.Balance = roundCents(bill.Amount - bill.AdvanceApplied)
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO supplier_bills (supplier_id, number, amount, account, bill_date, advance_applied, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		bill.SupplierID, bill.Number, bill.Amount, bill.Account, bill.BillDate, bill.AdvanceApplied, bill.CreatedBy,
+		bill.CreatedAt,
+	).Scan(&bill.ID)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("bill %s of supplier %d has been recorded already", bill.Number, bill.SupplierID)
+	}
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Bill %s of supplier %d", bill.Number, bill.SupplierID)
+	if err := post(ctx, tx, bill.BillDate, description, bill.Amount, bill.Account, suppliers.PayableAccount); err != nil {
+		return err
+	}
+	for i := range bill.Applications {
+		application := &bill.Applications[i]
+		application.BillID = bill.ID
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO supplier_advance_applications (advance_id, bill_id, amount) VALUES ($1, $2, $3)`,
+			application.AdvanceID, application.BillID, application.Amount,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE supplier_advances SET adjusted = adjusted + $1 WHERE id = $2`,
+			application.Amount, application.AdvanceID)
+		if err != nil {
+			return err
+		}
+	}
+	if bill.AdvanceApplied > 0 {
+		description := fmt.Sprintf("Advances offset against bill %s of supplier %d", bill.Number, bill.SupplierID)
+		err := post(ctx, tx, bill.BillDate, description, bill.AdvanceApplied, suppliers.PayableAccount, suppliers.AdvanceAccount)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSupplierBills lists a supplier's bills with the advances applied to them, newest first.
+//
+// Returns:
+//   - []models.SupplierBill: The bills.
+//   - error: An error if the query fails.
+func (store *DBSupplierStore) GetSupplierBills(ctx context.Context, supplierID int) ([]models.SupplierBill, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`SELECT id, supplier_id, number, amount, account, bill_date, advance_applied, created_by, created_at
+		 FROM supplier_bills WHERE supplier_id = $1 ORDER BY bill_date DESC, id DESC`, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bills := []models.SupplierBill{}
+	index := map[int]int{}
+	for rows.Next() {
+		var bill models.SupplierBill
+		if err := rows.Scan(&bill.ID, &bill.SupplierID, &bill.Number, &bill.Amount, &bill.Account, &bill.BillDate,
+			&bill.AdvanceApplied, &bill.CreatedBy, &bill.CreatedAt); err != nil {
+			return nil, err
+		}
+		bill.Balance = roundCents(bill.Amount - bill.AdvanceApplied)
+		bill.Applications = []models.AdvanceApplication{}
+		index[bill.ID] = len(bills)
+		bills = append(bills, bill)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	applications, err := store.DB.QueryContext(ctx,
+		`SELECT a.advance_id, a.bill_id, a.amount FROM supplier_advance_applications a
+		 JOIN supplier_bills b ON b.id = a.bill_id WHERE b.supplier_id = $1 ORDER BY a.id`, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer applications.Close()
+	for applications.Next() {
+		var application models.AdvanceApplication
+		if err := applications.Scan(&application.AdvanceID, &application.BillID, &application.Amount); err != nil {
+			return nil, err
+		}
+		if i, ok := index[application.BillID]; ok {
+			bills[i].Applications = append(bills[i].Applications, application)
+		}
+	}
+	return bills, applications.Err()
+}
+
+// GetSupplierBalance totals a supplier's advances and bills.
+//
+// Returns:
+//   - *models.SupplierBalance: The totals.
+//   - error: models.ErrNotFound if the supplier does not exist, or an error if the query fails.
+func (store *DBSupplierStore) GetSupplierBalance(ctx context.Context, supplierID int) (*models.SupplierBalance, error) {
+	balance := models.SupplierBalance{SupplierID: supplierID}
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT s.name,
+		     COALESCE((SELECT SUM(amount) FROM supplier_advances WHERE supplier_id = s.id), 0),
+		     COALESCE((SELECT SUM(amount - adjusted) FROM supplier_advances WHERE supplier_id = s.id), 0),
+		     COALESCE((SELECT SUM(amount) FROM supplier_bills WHERE supplier_id = s.id), 0),
+		     COALESCE((SELECT SUM(advance_applied) FROM supplier_bills WHERE supplier_id = s.id), 0)
+		 FROM suppliers s WHERE s.id = $1`, supplierID,
+	).Scan(&balance.Name, &balance.Advances, &balance.Unadjusted, &balance.Billed, &balance.AdvanceApplied)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("supplier %d not found", supplierID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	balance.Outstanding = roundCents(balance.Billed - balance.AdvanceApplied)
+	return &balance, nil
+}
+
+// post debits one account and credits another with amount.
+func post(ctx context.Context, tx *sql.Tx, date time.Time, description string, amount float64, debit, credit string) error {
+	for _, transaction := range []models.FinancialTransaction{
+		{AccountType: debit, Amount: amount, TransactionDate: date, Description: description},
+		{AccountType: credit, Amount: -amount, TransactionDate: date, Description: description},
+	} {
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
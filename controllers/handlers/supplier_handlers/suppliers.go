@@ -0,0 +1,284 @@
+package supplier_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/suppliers"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SupplierHandler provides HTTP handlers for suppliers, their advances and bills.
+type SupplierHandler struct {
+	Service *suppliers.Service
+}
+
+// RegisterRoutes maps supplier routes to their respective handler functions. The router is
+// expected to be protected with middleware.JWTAuth and limited to accountants and admins.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - service: The supplier service.
+func RegisterRoutes(router *mux.Router, service *suppliers.Service) {
+	handler := &SupplierHandler{Service: service}
+
+	router.HandleFunc("", handler.ListSuppliers).Methods("GET")
+	router.HandleFunc("", handler.CreateSupplier).Methods("POST")
+	router.HandleFunc("/advances", handler.ListAllAdvances).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.GetSupplier).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.UpdateSupplier).Methods("PUT")
+	router.HandleFunc("/{id:[0-9]+}/advances", handler.PayAdvance).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/advances", handler.ListAdvances).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/bills", handler.RecordBill).Methods("POST")
+	router.HandleFunc("/{id:[0-9]+}/bills", handler.ListBills).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}/balance", handler.GetBalance).Methods("GET")
+}
+
+// ListSuppliers returns every supplier.
+//
+// HTTP Method: GET
+// URL Path: /suppliers
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of Suppliers in JSON.
+//   - Status Code: 500 (Internal Server Error) if the suppliers cannot be read.
+func (h *SupplierHandler) ListSuppliers(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Service.Suppliers(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to load suppliers")
+		return
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+// CreateSupplier adds a supplier.
+//
+// HTTP Method: POST
+// URL Path: /suppliers
+//
+// Request Body:
+//   - JSON object with "name", and optionally "email" and "phone".
+//
+// Response:
+//   - Status Code: 201 (Created) with the Supplier in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is not JSON.
+//   - Status Code: 409 (Conflict) if a supplier with the name exists.
+//   - Status Code: 422 (Unprocessable Entity) if the name is missing or the email is not an
+//     address; the error details list the fields.
+//   - Status Code: 500 (Internal Server Error) if the supplier cannot be saved.
+func (h *SupplierHandler) CreateSupplier(w http.ResponseWriter, r *http.Request) {
+	var supplier models.Supplier
+	if !validation.DecodeJSON(w, r, &supplier) {
+		return
+	}
+	if err := h.Service.CreateSupplier(r.Context(), &supplier); err != nil {
+		httperr.Write(w, err, "Failed to create supplier")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, supplier)
+}
+
+// GetSupplier returns a supplier.
+//
+// HTTP Method: GET
+// URL Path: /suppliers/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the Supplier in JSON and its version as the ETag.
+//   - Status Code: 404 (Not Found) if the supplier does not exist.
+//   - Status Code: 500 (Internal Server Error) if the supplier cannot be read.
+func (h *SupplierHandler) GetSupplier(w http.ResponseWriter, r *http.Request) {
+	supplier, err := h.Service.Supplier(r.Context(), supplierID(r))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load supplier")
+		return
+	}
+	versioning.SetETag(w, supplier.Version)
+	respond.JSON(w, http.StatusOK, supplier)
+}
+
+// UpdateSupplier changes a supplier's name, contact details and whether it is active.
+//
+// HTTP Method: PUT
+// URL Path: /suppliers/{id}
+//
+// Request Body:
+//   - JSON object with "name", "email", "phone", "active" and the "version" read; the version
+//     may be sent as an If-Match header instead.
+//
+// Response:
+//   - Status Code: 200 (OK) with the Supplier in JSON and its new version as the ETag.
+//   - Status Code: 400 (Bad Request) if the request body is not JSON.
+//   - Status Code: 404 (Not Found) if the supplier does not exist.
+//   - Status Code: 409 (Conflict) if another supplier has the name, or the supplier was
+//     changed since the version read.
+//   - Status Code: 422 (Unprocessable Entity) if the name is missing or the email is not an
+//     address; the error details list the fields.
+//   - Status Code: 428 (Precondition Required) if no version is given.
+//   - Status Code: 500 (Internal Server Error) if the supplier cannot be saved.
+func (h *SupplierHandler) UpdateSupplier(w http.ResponseWriter, r *http.Request) {
+	var supplier models.Supplier
+	if !validation.DecodeJSON(w, r, &supplier) {
+		return
+	}
+	version, ok := versioning.Expected(w, r, supplier.Version)
+	if !ok {
+		return
+	}
+	supplier.ID, supplier.Version = supplierID(r), version
+	if err := h.Service.UpdateSupplier(r.Context(), &supplier); err != nil {
+		httperr.Write(w, err, "Failed to update supplier")
+		return
+	}
+	versioning.SetETag(w, supplier.Version)
+	respond.JSON(w, http.StatusOK, supplier)
+}
+
+// PayAdvance records an advance paid to a supplier before it bills. It is held as a
+// prepayment until the supplier's bills are offset against it.
+//
+// HTTP Method: POST
+// URL Path: /suppliers/{id}/advances
+//
+// Request Body:
+//   - JSON object with "amount", and optionally "paid_on", "method" and "reference".
+//
+// Response:
+//   - Status Code: 201 (Created) with the SupplierAdvance in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the supplier does not exist.
+//   - Status Code: 422 (Unprocessable Entity) if the amount is not positive or the supplier is inactive.
+//   - Status Code: 500 (Internal Server Error) if the advance cannot be recorded.
+func (h *SupplierHandler) PayAdvance(w http.ResponseWriter, r *http.Request) {
+	var advance models.SupplierAdvance
+	if err := json.NewDecoder(r.Body).Decode(&advance); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	advance.SupplierID = supplierID(r)
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.PayAdvance(r.Context(), &advance, actor); err != nil {
+		httperr.Write(w, err, "Failed to record advance")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, advance)
+}
+
+// ListAdvances lists a supplier's advances, oldest first.
+//
+// HTTP Method: GET
+// URL Path: /suppliers/{id}/advances?unadjusted=true
+// (unadjusted is optional and leaves out advances fully offset against bills)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of SupplierAdvances in JSON.
+//   - Status Code: 500 (Internal Server Error) if the advances cannot be read.
+func (h *SupplierHandler) ListAdvances(w http.ResponseWriter, r *http.Request) {
+	h.listAdvances(w, r, supplierID(r))
+}
+
+// ListAllAdvances lists the advances of every supplier, oldest first, to track the
+// balances not yet offset against bills.
+//
+// HTTP Method: GET
+// URL Path: /suppliers/advances?unadjusted=true
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of SupplierAdvances in JSON.
+//   - Status Code: 500 (Internal Server Error) if the advances cannot be read.
+func (h *SupplierHandler) ListAllAdvances(w http.ResponseWriter, r *http.Request) {
+	h.listAdvances(w, r, 0)
+}
+
+// listAdvances writes the advances of a supplier, or of every supplier if id is 0.
+func (h *SupplierHandler) listAdvances(w http.ResponseWriter, r *http.Request, id int) {
+	unadjusted, _ := strconv.ParseBool(r.URL.Query().Get("unadjusted"))
+	advances, err := h.Service.Advances(r.Context(), id, unadjusted)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load advances")
+		return
+	}
+	respond.JSON(w, http.StatusOK, advances)
+}
+
+// RecordBill records a bill received from a supplier. The supplier's unadjusted advances
+// are offset against it, oldest first, and the response shows what was applied and what is
+// still owed.
+//
+// HTTP Method: POST
+// URL Path: /suppliers/{id}/bills
+//
+// Request Body:
+//   - JSON object with "number", "amount", and optionally "bill_date" and "account" (the
+//     account debited, "expense" by default).
+//
+// Response:
+//   - Status Code: 201 (Created) with the SupplierBill in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 404 (Not Found) if the supplier does not exist.
+//   - Status Code: 409 (Conflict) if the supplier's bill number has been recorded already.
+//   - Status Code: 422 (Unprocessable Entity) if the bill is incomplete or the supplier is inactive.
+//   - Status Code: 500 (Internal Server Error) if the bill cannot be recorded.
+func (h *SupplierHandler) RecordBill(w http.ResponseWriter, r *http.Request) {
+	var bill models.SupplierBill
+	if err := json.NewDecoder(r.Body).Decode(&bill); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	bill.SupplierID = supplierID(r)
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	if err := h.Service.RecordBill(r.Context(), &bill, actor); err != nil {
+		httperr.Write(w, err, "Failed to record bill")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, bill)
+}
+
+// ListBills lists a supplier's bills with the advances applied to them, newest first.
+//
+// HTTP Method: GET
+// URL Path: /suppliers/{id}/bills
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of SupplierBills in JSON.
+//   - Status Code: 500 (Internal Server Error) if the bills cannot be read.
+func (h *SupplierHandler) ListBills(w http.ResponseWriter, r *http.Request) {
+	bills, err := h.Service.Bills(r.Context(), supplierID(r))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load bills")
+		return
+	}
+	respond.JSON(w, http.StatusOK, bills)
+}
+
+// GetBalance summarizes what has been prepaid to and is owed to a supplier.
+//
+// HTTP Method: GET
+// URL Path: /suppliers/{id}/balance
+//
+// Response:
+//   - Status Code: 200 (OK) with the SupplierBalance in JSON.
+//   - Status Code: 404 (Not Found) if the supplier does not exist.
+//   - Status Code: 500 (Internal Server Error) if the balance cannot be read.
+func (h *SupplierHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	balance, err := h.Service.Balance(r.Context(), supplierID(r))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load supplier balance")
+		return
+	}
+	respond.JSON(w, http.StatusOK, balance)
+}
+
+// supplierID returns the supplier ID in the URL.
+func supplierID(r *http.Request) int {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	return id
+}
@@ -0,0 +1,530 @@
+package supplier_return_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"erp/controllers/events"
+	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/controllers/supplierreturns"
+	"erp/models"
+
+	"github.com/lib/pq"
+)
+
+// DBSupplierReturnStore implements models.SupplierReturnStore using a SQL database.
+// Shipping and crediting lock the return first, so its goods leave stock and its debit note
+// is settled once.
+type DBSupplierReturnStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// returnColumns are the columns of a return and its debit note read by scanReturn.
+const returnColumns = `r.id, r.supplier_id, r.purchase_order_id, COALESCE(r.receipt_id, 0), r.warehouse_id,
+	COALESCE(r.hold_id, 0), r.reason, r.status, r.amount, r.created_by, r.created_at, r.shipped_by, r.shipped_at,
+	n.id, n.amount, n.credited, n.issued_by, n.issued_at`
+
+// returnTables are the tables returnColumns are read from.
+const returnTables = `supplier_returns r LEFT JOIN supplier_debit_notes n ON n.supplier_return_id = r.id`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// CreateSupplierReturn records an open return of goods of a receipt. The receipt's order is
+// locked while the quantities are checked, so concurrent returns cannot take back the same
+// goods twice.
+//
+// Parameters:
+//   - ret: The return checked by the service; its ID, supplier, order, warehouse, unit
+//     costs and amount are set.
+//
+// Returns:
+//   - error: A validation error if the receipt does not exist, a conflict if a line
+//     returns more than is left of the receipt, or the query error.
+func (s *DBSupplierReturnStore) CreateSupplierReturn(ctx context.Context, ret *models.SupplierReturn) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	err = tx.QueryRowContext(ctx,
+		`SELECT o.id, o.supplier_id, o.warehouse_id, r.lines
+		 FROM purchase_order_receipts r JOIN purchase_orders o ON o.id = r.purchase_order_id
+		 WHERE r.id = $1 FOR UPDATE OF o`, ret.ReceiptID,
+	).Scan(&ret.PurchaseOrderID, &ret.SupplierID, &ret.WarehouseID, &data)
+	if err == sql.ErrNoRows {
+		return models.Invalid("receipt %d does not exist", ret.ReceiptID)
+	}
+	if err != nil {
+		return err
+	}
+	var lines []models.PurchaseOrderReceiptLine
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	received := make(map[int]int, len(lines))
+	for _, line := range lines {
+		received[line.ProductID] += line.Quantity
+	}
+	returned, err := quantities(ctx, tx,
+		`SELECT l.product_id, SUM(l.quantity) FROM supplier_return_lines l
+		 JOIN supplier_returns r ON r.id = l.supplier_return_id
+		 WHERE r.receipt_id = $1 GROUP BY l.product_id`, ret.ReceiptID)
+	if err != nil {
+		return err
+	}
+	if err := supplierreturns.Returnable(received, returned, ret.Lines); err != nil {
+		return err
+	}
+
+	costs := map[int]float64{}
+	rows, err := tx.QueryContext(ctx, `SELECT product_id, unit_cost FROM purchase_order_lines WHERE purchase_order_id = $1`,
+		ret.PurchaseOrderID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var productID int
+		var cost float64
+		if err := rows.Scan(&productID, &cost); err != nil {
+			rows.Close()
+			return err
+		}
+		costs[productID] = cost
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range ret.Lines {
+		ret.Lines[i].UnitCost = costs[ret.Lines[i].ProductID]
+	}
+	ret.Amount = supplierreturns.Amount(ret.Lines)
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO supplier_returns (supplier_id, purchase_order_id, receipt_id, warehouse_id, reason, status, amount,
+		 created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		ret.SupplierID, ret.PurchaseOrderID, ret.ReceiptID, ret.WarehouseID, ret.Reason, ret.Status, ret.Amount,
+		ret.CreatedBy, ret.CreatedAt,
+	).Scan(&ret.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record supplier return: %w", err)
+	}
+	for _, line := range ret.Lines {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO supplier_return_lines (supplier_return_id, product_id, quantity, unit_cost) VALUES ($1, $2, $3, $4)`,
+			ret.ID, line.ProductID, line.Quantity, line.UnitCost)
+		if err != nil {
+			return fmt.Errorf("failed to record supplier return: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// quantities reads product IDs and quantities selected by a query into a map.
+func quantities(ctx context.Context, q models.Queryer, query string, args ...interface{}) (map[int]int, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := map[int]int{}
+	for rows.Next() {
+		var productID, quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		result[productID] = quantity
+	}
+	return result, rows.Err()
+}
+
+// ListSupplierReturns retrieves the returns in a status, or all of them if status is empty,
+// newest first.
+//
+// Parameters:
+//   - status: One of the models.SupplierReturn* statuses, or empty.
+//   - supplierID: The supplier, or 0 for every supplier.
+//
+// Returns:
+//   - []models.SupplierReturn: The returns with their lines and debit notes.
+//   - error: The query error.
+func (s *DBSupplierReturnStore) ListSupplierReturns(ctx context.Context, status string, supplierID int) ([]models.SupplierReturn, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+returnColumns+` FROM `+returnTables+`
+		 WHERE ($1 = '' OR r.status = $1) AND ($2 = 0 OR r.supplier_id = $2) ORDER BY r.id DESC`, status, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	returns := []models.SupplierReturn{}
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		returns = append(returns, *ret)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return returns, loadDetails(ctx, s.DB, returns)
+}
+
+// GetSupplierReturn retrieves a supplier return with its lines and debit note.
+//
+// Returns:
+//   - error: models.ErrNotFound if the return does not exist, or the query error.
+func (s *DBSupplierReturnStore) GetSupplierReturn(ctx context.Context, id int) (*models.SupplierReturn, error) {
+	return getReturn(ctx, s.DB, id, "")
+}
+
+// ShipSupplierReturn takes the goods of an open return out of the warehouse, unless they
+// were rejected by an inspection and never entered stock, and issues the debit note. If
+// accounts payable has not approved the receipt's payment yet, the debit note is deducted
+// from it; a payment offset in full is soft deleted. A StockMoved event is written to the
+// outbox when stock was taken.
+//
+// Parameters:
+//   - id: The ID of the return.
+//   - actor: Email of the user shipping the goods.
+//   - at: When the goods were shipped.
+//
+// Returns:
+//   - *models.SupplierReturn: The return with its debit note.
+//   - error: models.ErrNotFound, a conflict if the return is not open or the warehouse
+//     holds too little of a product, or the query error.
+func (s *DBSupplierReturnStore) ShipSupplierReturn(ctx context.Context, id int, actor string, at time.Time) (*models.SupplierReturn, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ret, err := getReturn(ctx, tx, id, " FOR UPDATE OF r")
+	if err != nil {
+		return nil, err
+	}
+	if ret.Status != models.SupplierReturnOpen {
+		return nil, models.Conflict("supplier return %d is %s", id, ret.Status)
+	}
+	if ret.HoldID == 0 {
+		for _, line := range ret.Lines {
+			if err := takeStock(ctx, tx, line.ProductID, line.Quantity, ret.WarehouseID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	note := &models.DebitNote{Amount: ret.Amount, Credits: []models.SupplierCredit{}, IssuedBy: actor, IssuedAt: at}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO supplier_debit_notes (supplier_return_id, amount, credited, issued_by, issued_at)
+		 VALUES ($1, $2, 0, $3, $4) RETURNING id`, id, note.Amount, actor, at,
+	).Scan(&note.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue debit note: %w", err)
+	}
+	if err := offsetPayment(ctx, tx, ret, note); err != nil {
+		return nil, err
+	}
+
+	shippedAt := at
+	ret.Status, ret.ShippedBy, ret.ShippedAt, ret.DebitNote = supplierreturns.Status(note.Due()), actor, &shippedAt, note
+	if _, err := tx.ExecContext(ctx, `UPDATE supplier_returns SET status = $1, shipped_by = $2, shipped_at = $3 WHERE id = $4`,
+		ret.Status, actor, at, id); err != nil {
+		return nil, err
+	}
+	if ret.HoldID == 0 {
+		if err := events.Enqueue(ctx, tx, events.StockMoved, "supplier_return", id, ret); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// offsetPayment deducts a debit note from the pending payment drafted for the return's
+// receipt and records the deduction as the note's first credit. Approved payments are left
+// alone; the supplier then owes the whole note.
+func offsetPayment(ctx context.Context, tx *sql.Tx, ret *models.SupplierReturn, note *models.DebitNote) error {
+	var paymentID int
+	var pending float64
+	err := tx.QueryRowContext(ctx,
+		`SELECT p.id, p.amount FROM purchase_order_receipts r JOIN payments p ON p.id = r.payment_id
+		 WHERE r.id = $1 AND p.status = $2 AND p.deleted_at IS NULL FOR UPDATE OF p`,
+		ret.ReceiptID, models.PaymentPending,
+	).Scan(&paymentID, &pending)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	offset := math.Min(note.Amount, pending)
+	if offset <= 0 {
+		return nil
+	}
+	if offset < pending {
+		_, err = tx.ExecContext(ctx, `UPDATE payments SET amount = amount - $1 WHERE id = $2`, offset, paymentID)
+	} else {
+		_, err = tx.ExecContext(ctx, `UPDATE payments SET deleted_at = $1 WHERE id = $2`, note.IssuedAt, paymentID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to offset payment: %w", err)
+	}
+
+	credit := models.SupplierCredit{Method: models.CreditPaymentOffset, Amount: offset, PaymentID: paymentID,
+		Reference: fmt.Sprintf("Payment %d", paymentID), ReceivedOn: note.IssuedAt, RecordedBy: note.IssuedBy,
+		RecordedAt: note.IssuedAt}
+	if err := insertCredit(ctx, tx, note, &credit); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE supplier_debit_notes SET credited = $1 WHERE id = $2`, note.Credited, note.ID)
+	return err
+}
+
+// RecordSupplierCredit settles part of a shipped return's debit note with a refund and
+// posts it to the ledger: cash is debited and purchase returns credited. The return is
+// credited once nothing is due.
+//
+// Parameters:
+//   - id: The ID of the return.
+//   - credit: The refund checked by the service; its ID is set.
+//
+// Returns:
+//   - *models.SupplierReturn: The return with its debit note.
+//   - error: models.ErrNotFound, a conflict if the return is not shipped or the amount
+//     exceeds what is due, or the query error.
+func (s *DBSupplierReturnStore) RecordSupplierCredit(ctx context.Context, id int, credit *models.SupplierCredit) (*models.SupplierReturn, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ret, err := getReturn(ctx, tx, id, " FOR UPDATE OF r")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ret.Status == models.SupplierReturnOpen || ret.DebitNote == nil:
+		return nil, models.Conflict("supplier return %d has not been shipped", id)
+	case ret.Status == models.SupplierReturnCredited:
+		return nil, models.Conflict("supplier return %d has been credited in full", id)
+	}
+	note := ret.DebitNote
+	if due := roundCents(note.Due()); credit.Amount > due {
+		return nil, models.Conflict("only %.2f is due on the debit note of supplier return %d", due, id)
+	}
+	if err := insertCredit(ctx, tx, note, credit); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE supplier_debit_notes SET credited = $1 WHERE id = $2`, note.Credited, note.ID); err != nil {
+		return nil, err
+	}
+	ret.Status = supplierreturns.Status(note.Due())
+	if _, err := tx.ExecContext(ctx, `UPDATE supplier_returns SET status = $1 WHERE id = $2`, ret.Status, id); err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Refund %s of supplier %d for return %d", credit.Reference, ret.SupplierID, id)
+	for _, transaction := range []models.FinancialTransaction{
+		{AccountType: supplierreturns.CashAccount, Amount: credit.Amount, TransactionDate: credit.ReceivedOn, Description: description},
+		{AccountType: supplierreturns.ReturnsAccount, Amount: -credit.Amount, TransactionDate: credit.ReceivedOn, Description: description},
+	} {
+		if err := general_ledger_handlers.InsertTransaction(ctx, tx, &transaction); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// insertCredit records a credit against a debit note and adds it to the note.
+func insertCredit(ctx context.Context, tx *sql.Tx, note *models.DebitNote, credit *models.SupplierCredit) error {
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO supplier_credits (debit_note_id, method, amount, payment_id, reference, received_on, recorded_by,
+		 recorded_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		note.ID, credit.Method, credit.Amount, nullID(credit.PaymentID), credit.Reference, credit.ReceivedOn,
+		credit.RecordedBy, credit.RecordedAt,
+	).Scan(&credit.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record credit: %w", err)
+	}
+	note.Credited = roundCents(note.Credited + credit.Amount)
+	note.Credits = append(note.Credits, *credit)
+	return nil
+}
+
+// takeStock takes a quantity of a product from a warehouse's stock entries, the largest
+// first. The entries are locked so concurrent moves cannot take the same stock.
+func takeStock(ctx context.Context, tx *sql.Tx, productID, quantity, warehouseID int) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, quantity FROM stock WHERE product_id = $1 AND warehouse_id = $2 AND quantity > 0
+		 ORDER BY quantity DESC, id FOR UPDATE`,
+		productID, warehouseID)
+	if err != nil {
+		return fmt.Errorf("failed to take stock: %w", err)
+	}
+	type entry struct{ id, quantity int }
+	var entries []entry
+	available := 0
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+		available += e.quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if available < quantity {
+		return models.Conflict("product %d has %d in stock at warehouse %d but %d are returned",
+			productID, available, warehouseID, quantity)
+	}
+
+	for _, e := range entries {
+		if quantity == 0 {
+			break
+		}
+		taken := min(e.quantity, quantity)
+		if _, err := tx.ExecContext(ctx, `UPDATE stock SET quantity = quantity - $1 WHERE id = $2`, taken, e.id); err != nil {
+			return fmt.Errorf("failed to take stock: %w", err)
+		}
+		quantity -= taken
+	}
+	return nil
+}
+
+// getReturn reads a return with its lines and debit note, adding lock to the query.
+func getReturn(ctx context.Context, q models.Queryer, id int, lock string) (*models.SupplierReturn, error) {
+	ret, err := scanReturn(q.QueryRowContext(ctx, `SELECT `+returnColumns+` FROM `+returnTables+` WHERE r.id = $1`+lock, id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("supplier return %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	returns := []models.SupplierReturn{*ret}
+	if err := loadDetails(ctx, q, returns); err != nil {
+		return nil, err
+	}
+	return &returns[0], nil
+}
+
+// loadDetails reads the lines of returns and the credits of their debit notes.
+func loadDetails(ctx context.Context, q models.Queryer, returns []models.SupplierReturn) error {
+	if len(returns) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(returns))
+	notes := map[int]*models.DebitNote{}
+	ids := make(pq.Int64Array, 0, len(returns))
+	for i := range returns {
+		index[returns[i].ID] = i
+		ids = append(ids, int64(returns[i].ID))
+		if note := returns[i].DebitNote; note != nil {
+			notes[note.ID] = note
+		}
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT supplier_return_id, product_id, quantity, unit_cost FROM supplier_return_lines
+		 WHERE supplier_return_id = ANY($1) ORDER BY id`, ids)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var returnID int
+		var line models.SupplierReturnLine
+		if err := rows.Scan(&returnID, &line.ProductID, &line.Quantity, &line.UnitCost); err != nil {
+			rows.Close()
+			return err
+		}
+		ret := &returns[index[returnID]]
+		ret.Lines = append(ret.Lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(notes) == 0 {
+		return err
+	}
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT c.debit_note_id, c.id, c.method, c.amount, COALESCE(c.payment_id, 0), c.reference, c.received_on,
+		 c.recorded_by, c.recorded_at
+		 FROM supplier_credits c JOIN supplier_debit_notes n ON n.id = c.debit_note_id
+		 WHERE n.supplier_return_id = ANY($1) ORDER BY c.id`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var noteID int
+		var credit models.SupplierCredit
+		if err := rows.Scan(&noteID, &credit.ID, &credit.Method, &credit.Amount, &credit.PaymentID, &credit.Reference,
+			&credit.ReceivedOn, &credit.RecordedBy, &credit.RecordedAt); err != nil {
+			return err
+		}
+		if note, ok := notes[noteID]; ok {
+			note.Credits = append(note.Credits, credit)
+		}
+	}
+	return rows.Err()
+}
+
+// scanReturn reads a row of returnColumns.
+func scanReturn(row rowScanner) (*models.SupplierReturn, error) {
+	var r models.SupplierReturn
+	var shippedBy, issuedBy sql.NullString
+	var shippedAt, issuedAt sql.NullTime
+	var noteID sql.NullInt64
+	var noteAmount, credited sql.NullFloat64
+	err := row.Scan(&r.ID, &r.SupplierID, &r.PurchaseOrderID, &r.ReceiptID, &r.WarehouseID, &r.HoldID, &r.Reason,
+		&r.Status, &r.Amount, &r.CreatedBy, &r.CreatedAt, &shippedBy, &shippedAt,
+		&noteID, &noteAmount, &credited, &issuedBy, &issuedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.Lines = []models.SupplierReturnLine{}
+	r.ShippedBy = shippedBy.String
+	if shippedAt.Valid {
+		r.ShippedAt = &shippedAt.Time
+	}
+	if noteID.Valid {
+		r.DebitNote = &models.DebitNote{ID: int(noteID.Int64), Amount: noteAmount.Float64, Credited: credited.Float64,
+			Credits: []models.SupplierCredit{}, IssuedBy: issuedBy.String, IssuedAt: issuedAt.Time}
+	}
+	return &r, nil
+}
+
+// nullID returns nil for a zero ID, so it is stored as NULL.
+func nullID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,114 @@
+package supplier_return_handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// returnRows are the columns read by scanReturn.
+var returnRows = []string{"id", "supplier_id", "purchase_order_id", "receipt_id", "warehouse_id", "hold_id", "reason",
+	"status", "amount", "created_by", "created_at", "shipped_by", "shipped_at", "note_id", "note_amount", "credited",
+	"issued_by", "issued_at"}
+
+// expectLockedReturn expects return 8 of 4 units of product 7 at 4.5, received on receipt 2
+// of order 4 from supplier 3 into warehouse 2, to be locked and read with its lines. A
+// shipped return has debit note 11 with 6 credited.
+func expectLockedReturn(mock sqlmock.Sqlmock, status string) {
+	created := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	row := []driver.Value{8, 3, 4, 2, 2, 0, "Wrong size", status, 18.0, "buyer@example.com", created}
+	if status == models.SupplierReturnOpen {
+		row = append(row, nil, nil, nil, nil, nil, nil, nil)
+	} else {
+		row = append(row, "buyer@example.com", created, 11, 18.0, 6.0, "buyer@example.com", created)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE r.id = $1 FOR UPDATE OF r")).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows(returnRows).AddRow(row...))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM supplier_return_lines")).
+		WillReturnRows(sqlmock.NewRows([]string{"supplier_return_id", "product_id", "quantity", "unit_cost"}).AddRow(8, 7, 4, 4.5))
+	if status != models.SupplierReturnOpen {
+		mock.ExpectQuery(regexp.QuoteMeta("FROM supplier_credits c")).
+			WillReturnRows(sqlmock.NewRows([]string{"debit_note_id", "id", "method", "amount", "payment_id", "reference",
+				"received_on", "recorded_by", "recorded_at"}).
+				AddRow(11, 1, models.CreditPaymentOffset, 6.0, 9, "Payment 9", created, "buyer@example.com", created))
+	}
+}
+
+func TestShipSupplierReturnOffsetsPendingPayment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBSupplierReturnStore{DB: db}
+	at := time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectLockedReturn(mock, models.SupplierReturnOpen)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, quantity FROM stock")).WithArgs(7, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}).AddRow(30, 10))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock SET quantity = quantity - $1")).WithArgs(4, 30).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO supplier_debit_notes")).WithArgs(8, 18.0, "buyer@example.com", at).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	// Only 6 of the receipt's payment is still pending, so it is offset in full
+	mock.ExpectQuery(regexp.QuoteMeta("FROM purchase_order_receipts r JOIN payments p")).WithArgs(2, models.PaymentPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "amount"}).AddRow(9, 6.0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE payments SET deleted_at = $1")).WithArgs(at, 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO supplier_credits")).
+		WithArgs(11, models.CreditPaymentOffset, 6.0, 9, "Payment 9", at, "buyer@example.com", at).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE supplier_debit_notes SET credited = $1")).WithArgs(6.0, 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE supplier_returns SET status = $1, shipped_by = $2, shipped_at = $3")).
+		WithArgs(models.SupplierReturnShipped, "buyer@example.com", at, 8).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO outbox_messages").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	ret, err := store.ShipSupplierReturn(context.Background(), 8, "buyer@example.com", at)
+	require.NoError(t, err)
+	assert.Equal(t, models.SupplierReturnShipped, ret.Status)
+	assert.Equal(t, 12.0, ret.DebitNote.Due())
+	assert.Len(t, ret.DebitNote.Credits, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShipSupplierReturnShortStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBSupplierReturnStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedReturn(mock, models.SupplierReturnOpen)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, quantity FROM stock")).WithArgs(7, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}).AddRow(30, 3))
+	mock.ExpectRollback()
+
+	_, err = store.ShipSupplierReturn(context.Background(), 8, "buyer@example.com", time.Now())
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordSupplierCreditBeyondDue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBSupplierReturnStore{DB: db}
+
+	mock.ExpectBegin()
+	expectLockedReturn(mock, models.SupplierReturnShipped)
+	mock.ExpectRollback()
+
+	_, err = store.RecordSupplierCredit(context.Background(), 8, &models.SupplierCredit{Method: models.CreditRefund, Amount: 12.5, Reference: "CN-1"})
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.Contains(t, err.Error(), "only 12.00 is due")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,187 @@
+// Package supplier_return_handlers provides HTTP handlers and the database store for returns
+// to vendor: returns of received goods, their debit notes and the credits settling them.
+package supplier_return_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/controllers/supplierreturns"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SupplierReturnHandler provides HTTP handlers for supplier returns. The rules
+// live in the supplierreturns service; the handlers only translate HTTP.
+type SupplierReturnHandler struct {
+	Service *supplierreturns.Service
+}
+
+// ReturnRequest is the request body for raising a supplier return.
+type ReturnRequest struct {
+	ReceiptID int                         `json:"receipt_id"`
+	Reason    string                      `json:"reason"`
+	Lines     []models.SupplierReturnLine `json:"lines"` // product_id and quantity; unit_cost is ignored
+}
+
+// CreditRequest is the request body for recording a supplier's refund.
+type CreditRequest struct {
+	Amount     float64 `json:"amount"`
+	Reference  string  `json:"reference"`
+	ReceivedOn string  `json:"received_on"` // YYYY-MM-DD, today if empty
+}
+
+// CreateReturn raises a return of goods of a purchase order receipt. The supplier, order,
+// warehouse and unit costs are taken from the receipt.
+//
+// HTTP Method: POST
+// URL Path: /supplier_returns
+//
+// Request Body:
+//   - JSON with receipt_id, reason and lines of product_id and quantity (see ReturnRequest).
+//
+// Response:
+//   - Status Code: 201 (Created) with the open SupplierReturn in JSON.
+//   - Status Code: 400 (Bad Request) if the request body is invalid.
+//   - Status Code: 409 (Conflict) if a line returns more than is left of the receipt.
+//   - Status Code: 422 (Unprocessable Entity) if the receipt does not exist or the return
+//     is incomplete.
+//   - Status Code: 500 (Internal Server Error) if the return cannot be recorded.
+func (h *SupplierReturnHandler) CreateReturn(w http.ResponseWriter, r *http.Request) {
+	var req ReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	lines := make([]models.SupplierReturnLine, len(req.Lines))
+	for i, line := range req.Lines {
+		lines[i] = models.SupplierReturnLine{ProductID: line.ProductID, Quantity: line.Quantity}
+	}
+	ret := &models.SupplierReturn{ReceiptID: req.ReceiptID, Reason: req.Reason, Lines: lines}
+	if err := h.Service.Create(r.Context(), ret, actor); err != nil {
+		httperr.Write(w, err, "Failed to create supplier return")
+		return
+	}
+	respond.JSON(w, http.StatusCreated, ret)
+}
+
+// ListReturns lists supplier returns, newest first.
+//
+// HTTP Method: GET
+// URL Path: /supplier_returns?status=shipped&supplier_id=2
+// (both are optional; status is open, shipped or credited)
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of SupplierReturns in JSON.
+//   - Status Code: 400 (Bad Request) if supplier_id is not a number.
+//   - Status Code: 422 (Unprocessable Entity) if the status is unknown.
+//   - Status Code: 500 (Internal Server Error) if the returns cannot be loaded.
+func (h *SupplierReturnHandler) ListReturns(w http.ResponseWriter, r *http.Request) {
+	supplierID := 0
+	if value := r.URL.Query().Get("supplier_id"); value != "" {
+		var err error
+		if supplierID, err = strconv.Atoi(value); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid supplier_id")
+			return
+		}
+	}
+	returns, err := h.Service.Returns(r.Context(), r.URL.Query().Get("status"), supplierID)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load supplier returns")
+		return
+	}
+	respond.JSON(w, http.StatusOK, returns)
+}
+
+// GetReturn returns a supplier return with its lines and debit note.
+//
+// HTTP Method: GET
+// URL Path: /supplier_returns/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the SupplierReturn in JSON.
+//   - Status Code: 404 (Not Found) if the return does not exist.
+//   - Status Code: 500 (Internal Server Error) if the return cannot be loaded.
+func (h *SupplierReturnHandler) GetReturn(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	ret, err := h.Service.Return(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load supplier return")
+		return
+	}
+	respond.JSON(w, http.StatusOK, ret)
+}
+
+// ShipReturn records that the goods of an open return left for the supplier. The goods are
+// taken out of the warehouse and a debit note is issued, deducted from the receipt's payment
+// if accounts payable has not approved it yet.
+//
+// HTTP Method: POST
+// URL Path: /supplier_returns/{id}/shipment
+//
+// Response:
+//   - Status Code: 200 (OK) with the SupplierReturn in JSON, including the debit note.
+//   - Status Code: 404 (Not Found) if the return does not exist.
+//   - Status Code: 409 (Conflict) if the return was shipped already or the warehouse holds
+//     too little of a product.
+//   - Status Code: 500 (Internal Server Error) if the shipment cannot be recorded.
+func (h *SupplierReturnHandler) ShipReturn(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	ret, err := h.Service.Ship(r.Context(), id, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to ship supplier return")
+		return
+	}
+	respond.JSON(w, http.StatusOK, ret)
+}
+
+// RecordCredit records a refund or credit note the supplier sent against a shipped return's
+// debit note and posts it to the general ledger.
+//
+// HTTP Method: POST
+// URL Path: /supplier_returns/{id}/credits
+//
+// Request Body:
+//   - JSON with amount, reference and an optional received_on (see CreditRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with the SupplierReturn in JSON, credited once nothing is due.
+//   - Status Code: 400 (Bad Request) if the request body or date is invalid.
+//   - Status Code: 404 (Not Found) if the return does not exist.
+//   - Status Code: 409 (Conflict) if the return is not shipped or the amount exceeds what
+//     is due.
+//   - Status Code: 422 (Unprocessable Entity) if the amount is not positive or the
+//     reference is missing.
+//   - Status Code: 500 (Internal Server Error) if the credit cannot be recorded.
+func (h *SupplierReturnHandler) RecordCredit(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var req CreditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	credit := &models.SupplierCredit{Amount: req.Amount, Reference: req.Reference}
+	if req.ReceivedOn != "" {
+		receivedOn, err := time.Parse("2006-01-02", req.ReceivedOn)
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid received_on, expected YYYY-MM-DD")
+			return
+		}
+		credit.ReceivedOn = receivedOn
+	}
+	actor, _ := middleware.GetUserEmailFromContext(r.Context())
+	ret, err := h.Service.RecordCredit(r.Context(), id, credit, actor)
+	if err != nil {
+		httperr.Write(w, err, "Failed to record supplier credit")
+		return
+	}
+	respond.JSON(w, http.StatusOK, ret)
+}
@@ -0,0 +1,77 @@
+// Package system_handlers provides the health check and the admin API that switches the
+// API between normal, read-only and maintenance mode.
+package system_handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp/controllers/audit"
+	"erp/models"
+)
+
+// DBSystemModeStore provides SQL-backed methods for the single-row system_mode table.
+type DBSystemModeStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// GetSystemMode reads the current operating mode.
+//
+// Returns:
+//   - *SystemMode: The stored mode, or normal mode if it was never switched.
+//   - error: An error if the query fails.
+func (store *DBSystemModeStore) GetSystemMode(ctx context.Context) (*models.SystemMode, error) {
+	mode := &models.SystemMode{}
+	var changedBy sql.NullString
+	err := store.DB.QueryRowContext(ctx, "SELECT mode, message, changed_by, changed_at FROM system_mode WHERE id = TRUE").
+		Scan(&mode.Mode, &mode.Message, &changedBy, &mode.ChangedAt)
+	if err == sql.ErrNoRows {
+		return &models.SystemMode{Mode: models.ModeNormal}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	mode.ChangedBy = changedBy.String
+	return mode, nil
+}
+
+// SetSystemMode stores the operating mode and records the switch in the audit log.
+//
+// Parameters:
+//   - mode: The new mode, with ChangedBy and ChangedAt set.
+//
+// Returns:
+//   - error: An error if the mode cannot be stored.
+func (store *DBSystemModeStore) SetSystemMode(ctx context.Context, mode *models.SystemMode) error {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO system_mode (id, mode, message, changed_by, changed_at) VALUES (TRUE, $1, $2, $3, $4)
+		 ON CONFLICT (id) DO UPDATE SET mode = EXCLUDED.mode, message = EXCLUDED.message,
+		     changed_by = EXCLUDED.changed_by, changed_at = EXCLUDED.changed_at`,
+		mode.Mode, mode.Message, mode.ChangedBy, mode.ChangedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	details, err := json.Marshal(mode)
+	if err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      mode.ChangedBy,
+		Action:     audit.ActionSystemMode,
+		EntityType: "system",
+		Reason:     mode.Message,
+		Details:    details,
+		CreatedAt:  mode.ChangedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
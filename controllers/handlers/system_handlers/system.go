@@ -0,0 +1,96 @@
+package system_handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/maintenance"
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Pinger is satisfied by *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// SystemHandler provides the health check and the operating mode endpoints.
+type SystemHandler struct {
+	Mode *maintenance.Service
+	DB   Pinger
+}
+
+// Health reports whether the server and its database are up, along with the current
+// operating mode. It is always served, even in maintenance mode.
+//
+// HTTP Method: GET
+// URL Path: /health
+//
+// Response:
+//   - Status Code: 200 (OK) with the status and mode in JSON.
+//   - Status Code: 503 (Service Unavailable) if the database cannot be reached.
+func (h *SystemHandler) Health(w http.ResponseWriter, r *http.Request) {
+	status, code := "ok", http.StatusOK
+	if err := h.DB.PingContext(r.Context()); err != nil {
+		status, code = "database unavailable", http.StatusServiceUnavailable
+	}
+
+	respond.JSON(w, code, map[string]string{"status": status, "mode": h.Mode.Current(r.Context()).Mode})
+}
+
+// GetMode returns the current operating mode, so clients can show a banner.
+//
+// HTTP Method: GET
+// URL Path: /system/mode
+//
+// Response:
+//   - Status Code: 200 (OK) with the SystemMode in JSON.
+func (h *SystemHandler) GetMode(w http.ResponseWriter, r *http.Request) {
+	respond.JSON(w, http.StatusOK, h.Mode.Current(r.Context()))
+}
+
+// SetModeRequest is the request body for switching the operating mode. Who switched the
+// mode and when is recorded by the server.
+type SetModeRequest struct {
+	Mode    string `json:"mode"`
+	Message string `json:"message"`
+}
+
+// SetMode switches the API into normal, read-only or maintenance mode.
+//
+// HTTP Method: PUT
+// URL Path: /system/mode
+//
+// Request Body:
+//   - JSON with the mode ("normal", "read_only" or "maintenance") and an optional message
+//     shown to clients whose requests are rejected.
+//
+// Response:
+//   - Status Code: 200 (OK) with the new SystemMode in JSON.
+//   - Status Code: 400 (Bad Request) if the mode is unknown.
+//   - Status Code: 500 (Internal Server Error) if the mode cannot be stored.
+func (h *SystemHandler) SetMode(w http.ResponseWriter, r *http.Request) {
+	var req SetModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	if !models.IsValidMode(req.Mode) {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("mode must be %q, %q or %q", models.ModeNormal, models.ModeReadOnly, models.ModeMaintenance))
+		return
+	}
+	mode := models.SystemMode{Mode: req.Mode, Message: strings.TrimSpace(req.Message), ChangedAt: time.Now()}
+	mode.ChangedBy, _ = middleware.GetUserEmailFromContext(r.Context())
+
+	if err := h.Mode.Set(r.Context(), &mode); err != nil {
+		httperr.Write(w, err, "Failed to switch mode")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, mode)
+}
@@ -0,0 +1,96 @@
+// Package trash_handlers provides the SQL-backed store of soft deleted records and the
+// endpoints that restore and purge them.
+package trash_handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/models"
+)
+
+// DBTrashStore provides SQL-backed methods for the soft deleted records of the tables in
+// softdelete.Resources. Table names are only taken from that list, never from requests.
+type DBTrashStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// RestoreRecord clears the deleted_at of a record.
+//
+// Returns:
+//   - error: A not found error if the record does not exist or is not deleted, a conflict
+//     if a live record took its unique values, or the query error.
+func (store *DBTrashStore) RestoreRecord(ctx context.Context, resource string, id int) error {
+	result, err := store.DB.ExecContext(ctx,
+		fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", resource), id)
+	if models.IsUniqueViolation(err) {
+		return models.Conflict("%s %d cannot be restored: %s", resource, id, models.ViolationDetail(err))
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.NotFound("no deleted record %d in %s", id, resource)
+	}
+	return nil
+}
+
+// ListDeletedRecords returns the deleted records of a resource, most recently deleted first.
+func (store *DBTrashStore) ListDeletedRecords(ctx context.Context, resource string) ([]models.DeletedRecord, error) {
+	rows, err := store.DB.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id, deleted_at FROM %s WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id", resource))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []models.DeletedRecord{}
+	for rows.Next() {
+		record := models.DeletedRecord{Resource: resource}
+		if err := rows.Scan(&record.ID, &record.DeletedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// PurgeRecords removes the records of a resource deleted before a time, one at a time so
+// that a record other records still reference, which the foreign key refuses to remove,
+// is kept without holding back the others.
+func (store *DBTrashStore) PurgeRecords(ctx context.Context, resource string, before time.Time) (*models.PurgeResult, error) {
+	rows, err := store.DB.QueryContext(ctx, fmt.Sprintf("SELECT id FROM %s WHERE deleted_at < $1 ORDER BY id", resource), before)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &models.PurgeResult{Resource: resource}
+	for _, id := range ids {
+		deleted, err := store.DB.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND deleted_at < $2", resource), id, before)
+		if models.IsForeignKeyViolation(err) {
+			result.Kept++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := deleted.RowsAffected(); n > 0 {
+			result.Purged++
+		}
+	}
+	return result, nil
+}
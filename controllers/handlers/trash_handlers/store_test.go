@@ -0,0 +1,56 @@
+package trash_handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreRecord(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBTrashStore{DB: db}
+
+	restore := regexp.QuoteMeta("UPDATE customers SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL")
+	mock.ExpectExec(restore).WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, store.RestoreRecord(ctx, "customers", 4))
+
+	// A live customer or one that never existed
+	mock.ExpectExec(restore).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.True(t, errors.Is(store.RestoreRecord(ctx, "customers", 5), models.ErrNotFound))
+
+	mock.ExpectExec(restore).WithArgs(6).WillReturnError(&pq.Error{Code: "23505", Detail: "Key (email)=(a@example.com) already exists."})
+	assert.True(t, errors.Is(store.RestoreRecord(ctx, "customers", 6), models.ErrConflict))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeRecordsKeepsReferencedRecords(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &DBTrashStore{DB: db}
+	before := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM customers WHERE deleted_at < $1 ORDER BY id")).WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+	remove := regexp.QuoteMeta("DELETE FROM customers WHERE id = $1 AND deleted_at < $2")
+	mock.ExpectExec(remove).WithArgs(3, before).
+		WillReturnError(&pq.Error{Code: "23503", Constraint: "invoices_customer_id_fkey"})
+	mock.ExpectExec(remove).WithArgs(4, before).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result, err := store.PurgeRecords(context.Background(), "customers", before)
+	require.NoError(t, err)
+	assert.Equal(t, &models.PurgeResult{Resource: "customers", Purged: 1, Kept: 1}, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,115 @@
+package trash_handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/controllers/softdelete"
+
+	"github.com/gorilla/mux"
+)
+
+// TrashHandler provides the HTTP handlers for soft deleted records. The rules live in the
+// softdelete service; the handlers only translate HTTP.
+type TrashHandler struct {
+	Service *softdelete.Service
+}
+
+// PurgeRequest is the request body for purging deleted records.
+type PurgeRequest struct {
+	Resource      string `json:"resource"`
+	DeletedBefore string `json:"deleted_before"` // YYYY-MM-DD; empty purges every deleted record
+}
+
+// RegisterRoutes registers the admin routes of the trash.
+func (h *TrashHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("", h.ListDeleted).Methods("GET")
+	router.HandleFunc("/purge", h.Purge).Methods("POST")
+}
+
+// Restore returns a handler that restores a deleted record of the given resource, e.g.
+// "customers". It is registered on each resource's routes with the permissions needed to
+// delete the record.
+//
+// HTTP Method: POST
+// URL Path: /customers/{id}/restore
+//
+// Response:
+//   - Status Code: 204 (No Content) if the record is restored.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the record does not exist or is not deleted.
+//   - Status Code: 409 (Conflict) if a live record took its unique values, e.g. an email.
+//   - Status Code: 500 (Internal Server Error) if the record cannot be restored.
+func (h *TrashHandler) Restore(resource string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		if err := h.Service.Restore(r.Context(), resource, id); err != nil {
+			httperr.Write(w, err, "Failed to restore record")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListDeleted lists the deleted records of a resource, most recently deleted first.
+//
+// HTTP Method: GET
+// URL Path: /trash?resource=customers
+//
+// Response:
+//   - Status Code: 200 (OK) with a list of DeletedRecords in JSON.
+//   - Status Code: 422 (Unprocessable Entity) if the resource is missing or unknown.
+//   - Status Code: 500 (Internal Server Error) if the records cannot be loaded.
+func (h *TrashHandler) ListDeleted(w http.ResponseWriter, r *http.Request) {
+	records, err := h.Service.Deleted(r.Context(), r.URL.Query().Get("resource"))
+	if err != nil {
+		httperr.Write(w, err, "Failed to load deleted records")
+		return
+	}
+	respond.JSON(w, http.StatusOK, records)
+}
+
+// Purge removes deleted records of a resource for good. Records other records still
+// reference, such as a customer with invoices, are kept in the trash.
+//
+// HTTP Method: POST
+// URL Path: /trash/purge
+//
+// Request Body:
+//   - JSON with the resource and an optional deleted_before date (see PurgeRequest).
+//
+// Response:
+//   - Status Code: 200 (OK) with the PurgeResult in JSON.
+//   - Status Code: 400 (Bad Request) if the request body or date is invalid.
+//   - Status Code: 422 (Unprocessable Entity) if the resource is unknown or the date is in
+//     the future.
+//   - Status Code: 500 (Internal Server Error) if the records cannot be purged.
+func (h *TrashHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	var req PurgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	var before time.Time
+	if req.DeletedBefore != "" {
+		var err error
+		if before, err = time.Parse("2006-01-02", req.DeletedBefore); err != nil {
+			respond.Error(w, http.StatusBadRequest, "Invalid deleted_before, expected YYYY-MM-DD")
+			return
+		}
+	}
+	result, err := h.Service.Purge(r.Context(), req.Resource, before)
+	if err != nil {
+		httperr.Write(w, err, "Failed to purge deleted records")
+		return
+	}
+	respond.JSON(w, http.StatusOK, result)
+}
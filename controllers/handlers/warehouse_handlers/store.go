@@ -2,9 +2,12 @@
 package warehouse_handlers
 
 import (
+	"context"
 	"database/sql"
+	"erp/controllers/pagination"
+	"erp/controllers/versioning"
 	"erp/models"
-	"errors"
+	"fmt"
 )
 
 // DBWarehouseStore implements WarehouseStore using a SQL database.
@@ -14,8 +17,8 @@ type DBWarehouseStore struct {
 
 // CreateWarehouse inserts a new warehouse into the database.
 //
-// This method stores the provided Warehouse object in the database.
-// It returns an error if the operation fails.
+// This method stores the provided Warehouse object in the database and sets its ID and
+// version. It returns an error if the operation fails.
 //
 // Parameters:
 // - warehouse: A pointer to the Warehouse object to be created.
@@ -23,13 +26,13 @@ type DBWarehouseStore struct {
 // Returns:
 // - nil if the warehouse is created successfully.
 // - An error if the creation fails.
-func (s *DBWarehouseStore) CreateWarehouse(warehouse *models.Warehouse) error {
-	_, err := s.DB.Exec(
-		"INSERT INTO warehouses (name, capacity, location) VALUES ($1, $2, $3)",
+func (s *DBWarehouseStore) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	err := s.DB.QueryRowContext(ctx,
+		"INSERT INTO warehouses (name, capacity, location) VALUES ($1, $2, $3) RETURNING id, version",
 		warehouse.Name, warehouse.Capacity, warehouse.Location,
-	)
+	).Scan(&warehouse.ID, &warehouse.Version)
 	if err != nil {
-		return errors.New("failed to create warehouse: " + err.Error())
+		return fmt.Errorf("failed to create warehouse: %w", err)
 	}
 	return nil
 }
@@ -41,56 +44,111 @@ func (s *DBWarehouseStore) CreateWarehouse(warehouse *models.Warehouse) error {
 //
 // Returns:
 // - A pointer to the Warehouse object if found.
-// - An error if the warehouse is not found or the operation fails.
-func (s *DBWarehouseStore) GetWarehouseByID(id int) (*models.Warehouse, error) {
+// - models.ErrNotFound if the warehouse does not exist.
+// - An error if the operation fails.
+func (s *DBWarehouseStore) GetWarehouseByID(ctx context.Context, id int) (*models.Warehouse, error) {
 	var warehouse models.Warehouse
-	err := s.DB.QueryRow(
-		"SELECT id, name, capacity, location FROM warehouses WHERE id = $1",
+	err := s.DB.QueryRowContext(ctx,
+		"SELECT id, name, capacity, location, version FROM warehouses WHERE id = $1 AND deleted_at IS NULL",
 		id,
-	).Scan(&warehouse.ID, &warehouse.Name, &warehouse.Capacity, &warehouse.Location)
+	).Scan(&warehouse.ID, &warehouse.Name, &warehouse.Capacity, &warehouse.Location, &warehouse.Version)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("warehouse not found")
+		return nil, models.NotFound("warehouse %d not found", id)
 	} else if err != nil {
-		return nil, errors.New("failed to retrieve warehouse: " + err.Error())
+		return nil, fmt.Errorf("failed to retrieve warehouse: %w", err)
 	}
 	return &warehouse, nil
 }
 
+// WarehouseColumns are the fields warehouses can be listed by.
+var WarehouseColumns = pagination.Columns{
+	"id":       {Expr: "id", Type: pagination.Int},
+	"name":     {Expr: "name", Type: pagination.Text},
+	"capacity": {Expr: "capacity", Type: pagination.Int},
+	"location": {Expr: "location", Type: pagination.Text},
+}
+
+// ListWarehouses retrieves a page of warehouses from the database.
+//
+// Parameters:
+// - query: The page, sort order and filters (see WarehouseColumns).
+//
+// Returns:
+// - The page of warehouses and the number of warehouses matching the filters.
+// - An error if a filter is invalid or the query fails.
+func (s *DBWarehouseStore) ListWarehouses(ctx context.Context, query models.ListQuery) ([]models.Warehouse, int, error) {
+	warehouses := []models.Warehouse{}
+	total, err := pagination.QueryWhere(ctx, s.DB, "id, name, capacity, location, version", "warehouses",
+		"deleted_at IS NULL", WarehouseColumns, query, func(rows *sql.Rows) error {
+			var warehouse models.Warehouse
+			if err := rows.Scan(&warehouse.ID, &warehouse.Name, &warehouse.Capacity, &warehouse.Location, &warehouse.Version); err != nil {
+				return err
+			}
+			warehouses = append(warehouses, warehouse)
+			return nil
+		})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list warehouses: %w", err)
+	}
+	return warehouses, total, nil
+}
+
 // UpdateWarehouse updates an existing warehouse in the database.
 //
-// This method modifies the details of an existing warehouse based on the provided Warehouse object.
-// It returns an error if the update fails.
+// This method modifies the details of an existing warehouse based on the provided Warehouse object,
+// if the warehouse is still at warehouse.Version, and sets the new version.
 //
 // Parameters:
 // - warehouse: A pointer to the Warehouse object containing updated details.
 //
 // Returns:
 // - nil if the warehouse is updated successfully.
+// - models.ErrNotFound if the warehouse does not exist.
+// - models.ErrConflict if the warehouse was changed since warehouse.Version.
 // - An error if the update fails.
-func (s *DBWarehouseStore) UpdateWarehouse(warehouse *models.Warehouse) error {
-	_, err := s.DB.Exec(
-		"UPDATE warehouses SET name = $1, capacity = $2, location = $3 WHERE id = $4",
-		warehouse.Name, warehouse.Capacity, warehouse.Location, warehouse.ID,
-	)
+func (s *DBWarehouseStore) UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	err := s.DB.QueryRowContext(ctx,
+		"UPDATE warehouses SET name = $1, capacity = $2, location = $3 WHERE id = $4 AND version = $5 AND deleted_at IS NULL RETURNING version",
+		warehouse.Name, warehouse.Capacity, warehouse.Location, warehouse.ID, warehouse.Version,
+	).Scan(&warehouse.Version)
+	if err == sql.ErrNoRows {
+		return versioning.Stale(ctx, s.DB, "SELECT version FROM warehouses WHERE id = $1 AND deleted_at IS NULL", "warehouse",
+			warehouse.ID, warehouse.Version)
+	}
 	if err != nil {
-		return errors.New("failed to update warehouse: " + err.Error())
+		return fmt.Errorf("failed to update warehouse: %w", err)
 	}
 	return nil
 }
 
-// DeleteWarehouse removes a warehouse from the database by its ID.
+// DeleteWarehouse soft deletes a warehouse by its ID: the warehouse is hidden from reads
+// until it is restored or purged.
 //
 // Parameters:
 // - id: The ID of the warehouse to delete.
 //
 // Returns:
 // - nil if the warehouse is deleted successfully.
+// - models.ErrNotFound if the warehouse does not exist.
 // - An error if the deletion fails.
-func (s *DBWarehouseStore) DeleteWarehouse(id int) error {
-	_, err := s.DB.Exec("DELETE FROM warehouses WHERE id = $1", id)
+func (s *DBWarehouseStore) DeleteWarehouse(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, "UPDATE warehouses SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete warehouse: %w", err)
+	}
+	return checkAffected(result, id)
+}
+
+// checkAffected reports models.ErrNotFound if a change to the warehouse with the given ID
+// affected no rows.
+func checkAffected(result sql.Result, id int) error {
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return errors.New("failed to delete warehouse: " + err.Error())
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.NotFound("warehouse %d not found", id)
 	}
 	return nil
 }
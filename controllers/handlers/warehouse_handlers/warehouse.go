@@ -2,7 +2,11 @@
 package warehouse_handlers
 
 import (
-	"encoding/json"
+	"erp/controllers/httperr"
+	"erp/controllers/pagination"
+	"erp/controllers/respond"
+	"erp/controllers/validation"
+	"erp/controllers/versioning"
 	"erp/models"
 	"net/http"
 	"strconv"
@@ -15,6 +19,29 @@ type WarehouseHandlers struct {
 	WarehouseStore models.WarehouseStore
 }
 
+// WarehouseRequest is the request body for creating or updating a warehouse. The ID is
+// assigned by the database or taken from the URL.
+type WarehouseRequest struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	Location string `json:"location"`
+	Version  int    `json:"version"` // The version updated, unless sent in If-Match; ignored on create
+}
+
+// Warehouse returns the warehouse described by the request.
+func (req WarehouseRequest) Warehouse() models.Warehouse {
+	return models.Warehouse{Name: req.Name, Capacity: req.Capacity, Location: req.Location}
+}
+
+// Validate checks that the warehouse has a name, a location and room for stock.
+func (req WarehouseRequest) Validate() error {
+	var c validation.Checker
+	c.Required("name", req.Name)
+	c.Check(req.Capacity > 0, "capacity", "must be greater than zero")
+	c.Required("location", req.Location)
+	return c.Err()
+}
+
 // RegisterRoutes registers all the warehouse-related routes for the HTTP server.
 //
 // This method sets up routes for creating, retrieving, updating, and deleting warehouses.
@@ -22,11 +49,13 @@ type WarehouseHandlers struct {
 //
 // URL Paths:
 // - POST /warehouses: Create a new warehouse
+// - GET /warehouses: List warehouses a page at a time
 // - GET /warehouses/{id}: Retrieve a warehouse by ID
 // - PUT /warehouses/{id}: Update an existing warehouse by ID
 // - DELETE /warehouses/{id}: Delete a warehouse by ID
 func (h *WarehouseHandlers) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/warehouses", h.CreateWarehouse).Methods("POST")
+	router.HandleFunc("/warehouses", h.ListWarehouses).Methods("GET")
 	router.HandleFunc("/warehouses/{id:[0-9]+}", h.GetWarehouseByID).Methods("GET")
 	router.HandleFunc("/warehouses/{id:[0-9]+}", h.UpdateWarehouse).Methods("PUT")
 	router.HandleFunc("/warehouses/{id:[0-9]+}", h.DeleteWarehouse).Methods("DELETE")
@@ -34,7 +63,7 @@ func (h *WarehouseHandlers) RegisterRoutes(router *mux.Router) {
 
 // CreateWarehouse handles the creation of a new warehouse.
 //
-// This handler reads the incoming request body, decodes it into a Warehouse struct,
+// This handler reads the incoming request body, decodes it into a WarehouseRequest,
 // and attempts to store it in the database. On successful creation, it returns
 // a status code 201 Created. If an error occurs, it responds with an appropriate
 // status code and error message.
@@ -43,28 +72,55 @@ func (h *WarehouseHandlers) RegisterRoutes(router *mux.Router) {
 // URL Path: /warehouses
 //
 // Request Body:
-// - JSON representation of a Warehouse object.
+// - JSON with name, capacity and location (see WarehouseRequest).
 //
 // Response:
-// - Status Code: 201 (Created) if the warehouse is successfully created.
-// - Status Code: 400 (Bad Request) if the request body is invalid.
+// - Status Code: 201 (Created) with the created warehouse in JSON.
+// - Status Code: 400 (Bad Request) if the request body is not JSON.
+// - Status Code: 422 (Unprocessable Entity) if a field is invalid; the error details list them.
 // - Status Code: 500 (Internal Server Error) if the creation fails.
 func (h *WarehouseHandlers) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
-	var req models.Warehouse
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	var req WarehouseRequest
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	err = h.WarehouseStore.CreateWarehouse(&req)
+	warehouse := req.Warehouse()
+	err := h.WarehouseStore.CreateWarehouse(r.Context(), &warehouse)
 	if err != nil {
-		http.Error(w, "Could not create warehouse", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not create warehouse")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("Warehouse created successfully"))
+	respond.JSON(w, http.StatusCreated, warehouse)
+}
+
+// ListWarehouses handles listing warehouses a page at a time.
+//
+// HTTP Method: GET
+// URL Path: /warehouses?location=Dhaka&sort=-capacity&page=1&limit=50
+//
+// Query Parameters:
+// - page, limit: The page number (from 1) and its size (default 50, at most 200).
+// - sort: id, name, capacity or location, prefixed with "-" for descending order.
+// - id, name, capacity, location: Filters; text is matched case-insensitively.
+//
+// Response:
+// - Status Code: 200 (OK) with {"items": [...], "total": n, "page": p, "limit": l} in JSON.
+// - Status Code: 422 (Unprocessable Entity) if a parameter is invalid.
+// - Status Code: 500 (Internal Server Error) if the warehouses cannot be listed.
+func (h *WarehouseHandlers) ListWarehouses(w http.ResponseWriter, r *http.Request) {
+	query, err := pagination.Parse(r, WarehouseColumns)
+	if err != nil {
+		httperr.Write(w, err, "Invalid list query")
+		return
+	}
+	warehouses, total, err := h.WarehouseStore.ListWarehouses(r.Context(), query)
+	if err != nil {
+		httperr.Write(w, err, "Could not list warehouses")
+		return
+	}
+	pagination.Write(w, warehouses, total, query)
 }
 
 // GetWarehouseByID handles retrieving a warehouse by its ID.
@@ -78,31 +134,33 @@ func (h *WarehouseHandlers) CreateWarehouse(w http.ResponseWriter, r *http.Reque
 // URL Path: /warehouses/{id}
 //
 // Response:
-// - Status Code: 200 (OK) and the warehouse details in JSON if the warehouse is found.
+// - Status Code: 200 (OK) and the warehouse details in JSON if the warehouse is found, with
+// its version as the ETag.
 // - Status Code: 400 (Bad Request) if the ID is invalid.
 // - Status Code: 404 (Not Found) if the warehouse is not found.
+// - Status Code: 500 (Internal Server Error) if the warehouse could not be read.
 func (h *WarehouseHandlers) GetWarehouseByID(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	warehouseID, err := strconv.Atoi(params["id"])
 	if err != nil {
-		http.Error(w, "Invalid warehouse ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid warehouse ID")
 		return
 	}
 
-	warehouse, err := h.WarehouseStore.GetWarehouseByID(warehouseID)
+	warehouse, err := h.WarehouseStore.GetWarehouseByID(r.Context(), warehouseID)
 	if err != nil {
-		http.Error(w, "Warehouse not found", http.StatusNotFound)
+		httperr.Write(w, err, "Could not load warehouse")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(warehouse)
+	versioning.SetETag(w, warehouse.Version)
+	respond.JSON(w, http.StatusOK, warehouse)
 }
 
 // UpdateWarehouse handles updating an existing warehouse by ID.
 //
 // This handler extracts the warehouse ID from the URL path, decodes the request body
-// into a Warehouse struct, updates the warehouse in the database, and returns a success
+// into a WarehouseRequest, updates the warehouse in the database, and returns a success
 // response. If an error occurs, it responds with an appropriate status code and error
 // message.
 //
@@ -110,65 +168,75 @@ func (h *WarehouseHandlers) GetWarehouseByID(w http.ResponseWriter, r *http.Requ
 // URL Path: /warehouses/{id}
 //
 // Request Body:
-// - JSON representation of a Warehouse object to update.
+// - JSON with name, capacity, location and the version read (see WarehouseRequest); the
+// version may be sent as an If-Match header instead.
 //
 // Response:
-// - Status Code: 200 (OK) if the warehouse is successfully updated.
+// - Status Code: 200 (OK) with the updated warehouse in JSON, and the new version as the
+// ETag.
 // - Status Code: 400 (Bad Request) if the request body or ID is invalid.
+// - Status Code: 404 (Not Found) if the warehouse is not found.
+// - Status Code: 409 (Conflict) if the warehouse was changed since the version read.
+// - Status Code: 422 (Unprocessable Entity) if a field is invalid; the error details list them.
+// - Status Code: 428 (Precondition Required) if no version is given.
 // - Status Code: 500 (Internal Server Error) if the update fails.
 func (h *WarehouseHandlers) UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	warehouseID, err := strconv.Atoi(params["id"])
 	if err != nil {
-		http.Error(w, "Invalid warehouse ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid warehouse ID")
 		return
 	}
 
-	var req models.Warehouse
-	err = json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	var req WarehouseRequest
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	version, ok := versioning.Expected(w, r, req.Version)
+	if !ok {
 		return
 	}
 
-	req.ID = warehouseID
-	err = h.WarehouseStore.UpdateWarehouse(&req)
+	warehouse := req.Warehouse()
+	warehouse.ID, warehouse.Version = warehouseID, version
+	err = h.WarehouseStore.UpdateWarehouse(r.Context(), &warehouse)
 	if err != nil {
-		http.Error(w, "Could not update warehouse", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not update warehouse")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Warehouse updated successfully"))
+	versioning.SetETag(w, warehouse.Version)
+	respond.JSON(w, http.StatusOK, warehouse)
 }
 
 // DeleteWarehouse handles deleting a warehouse by its ID.
 //
 // This handler extracts the warehouse ID from the URL path, deletes the warehouse
-// from the database, and returns a success message. If any error occurs, it
+// from the database, and returns no content. If any error occurs, it
 // responds with an appropriate status code and error message.
 //
 // HTTP Method: DELETE
 // URL Path: /warehouses/{id}
 //
 // Response:
-// - Status Code: 200 (OK) if the warehouse is successfully deleted.
+// - Status Code: 204 (No Content) if the warehouse is successfully deleted.
 // - Status Code: 400 (Bad Request) if the ID is invalid.
+// - Status Code: 404 (Not Found) if the warehouse is not found.
 // - Status Code: 500 (Internal Server Error) if the deletion fails.
 func (h *WarehouseHandlers) DeleteWarehouse(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	warehouseID, err := strconv.Atoi(params["id"])
 	if err != nil {
-		http.Error(w, "Invalid warehouse ID", http.StatusBadRequest)
+		respond.Error(w, http.StatusBadRequest, "Invalid warehouse ID")
 		return
 	}
 
-	err = h.WarehouseStore.DeleteWarehouse(warehouseID)
+	err = h.WarehouseStore.DeleteWarehouse(r.Context(), warehouseID)
 	if err != nil {
-		http.Error(w, "Could not delete warehouse", http.StatusInternalServerError)
+		httperr.Write(w, err, "Could not delete warehouse")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Warehouse deleted successfully"))
+	w.WriteHeader(http.StatusNoContent)
 }
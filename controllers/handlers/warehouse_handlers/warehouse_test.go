@@ -34,9 +34,9 @@ func TestCreateWarehouse(t *testing.T) {
 	}
 
 	// Mock database behavior
-	mock.ExpectExec("INSERT INTO warehouses").
+	mock.ExpectQuery("INSERT INTO warehouses").
 		WithArgs(warehouse.Name, warehouse.Capacity, warehouse.Location).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(1, 1))
 
 	// Create HTTP request and recorder
 	body, _ := json.Marshal(warehouse)
@@ -49,7 +49,10 @@ func TestCreateWarehouse(t *testing.T) {
 
 	// Assert that no error occurred and response is correct
 	assert.Equal(t, http.StatusCreated, rec.Code)
-	assert.Equal(t, "Warehouse created successfully", rec.Body.String())
+	var created models.Warehouse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, 1, created.ID)
+	assert.Equal(t, warehouse.Name, created.Name)
 
 	// Assert that the expected query was executed
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -75,13 +78,14 @@ func TestGetWarehouseByID(t *testing.T) {
 		Name:     "Test Warehouse",
 		Capacity: 500,
 		Location: "Test Location",
+		Version:  1,
 	}
 
 	// Mock database behavior
-	mock.ExpectQuery("SELECT id, name, capacity, location FROM warehouses WHERE id = \\$1").
+	mock.ExpectQuery("SELECT id, name, capacity, location, version FROM warehouses WHERE id = \\$1").
 		WithArgs(warehouse.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "capacity", "location"}).
-			AddRow(warehouse.ID, warehouse.Name, warehouse.Capacity, warehouse.Location))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "capacity", "location", "version"}).
+			AddRow(warehouse.ID, warehouse.Name, warehouse.Capacity, warehouse.Location, warehouse.Version))
 
 	// Create HTTP request and recorder
 	req, _ := http.NewRequest("GET", "/warehouses/1", nil)
@@ -122,12 +126,13 @@ func TestUpdateWarehouse(t *testing.T) {
 		Name:     "Updated Warehouse",
 		Capacity: 600,
 		Location: "Updated Location",
+		Version:  4,
 	}
 
 	// Mock database behavior
-	mock.ExpectExec("UPDATE warehouses SET name = \\$1, capacity = \\$2, location = \\$3 WHERE id = \\$4").
-		WithArgs(warehouse.Name, warehouse.Capacity, warehouse.Location, warehouse.ID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("UPDATE warehouses SET name = \\$1, capacity = \\$2, location = \\$3 WHERE id = \\$4 AND version = \\$5").
+		WithArgs(warehouse.Name, warehouse.Capacity, warehouse.Location, warehouse.ID, warehouse.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(5))
 
 	// Create HTTP request and recorder
 	body, _ := json.Marshal(warehouse)
@@ -143,7 +148,10 @@ func TestUpdateWarehouse(t *testing.T) {
 
 	// Assert that no error occurred and response is correct
 	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.Equal(t, "Warehouse updated successfully", rec.Body.String())
+	var updated models.Warehouse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.Equal(t, 5, updated.Version)
+	assert.Equal(t, `"5"`, rec.Header().Get("ETag"))
 
 	// Assert that the expected query was executed
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -164,7 +172,7 @@ func TestDeleteWarehouse(t *testing.T) {
 	handler := &WarehouseHandlers{WarehouseStore: store}
 
 	// Mock database behavior
-	mock.ExpectExec("DELETE FROM warehouses WHERE id = \\$1").
+	mock.ExpectExec("UPDATE warehouses SET deleted_at = now\\(\\) WHERE id = \\$1 AND deleted_at IS NULL").
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
@@ -179,11 +187,55 @@ func TestDeleteWarehouse(t *testing.T) {
 	handler.DeleteWarehouse(rec, req)
 
 	// Assert that no error occurred and response is correct
-	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.Equal(t, "Warehouse deleted successfully", rec.Body.String())
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
 
 	// Assert that the expected query was executed
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unmet expectations: %v", err)
 	}
 }
+
+// TestListWarehouses tests the ListWarehouses handler.
+func TestListWarehouses(t *testing.T) {
+	// Set up mock database
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("could not create mock db: %v", err)
+	}
+	defer db.Close()
+
+	store := &DBWarehouseStore{DB: db}
+	handler := &WarehouseHandlers{WarehouseStore: store}
+
+	// Mock database behavior: the filter and page are passed as parameters
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM warehouses WHERE deleted_at IS NULL AND LOWER\(location\) = LOWER\(\$1\)`).
+		WithArgs("Dhaka").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT id, name, capacity, location, version FROM warehouses WHERE deleted_at IS NULL AND LOWER\(location\) = LOWER\(\$1\) ORDER BY capacity DESC, id LIMIT \$2 OFFSET \$3`).
+		WithArgs("Dhaka", 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "capacity", "location", "version"}).AddRow(3, "Small", 100, "Dhaka", 2))
+
+	req, _ := http.NewRequest("GET", "/warehouses?location=Dhaka&sort=-capacity&page=2&limit=2", nil)
+	rec := httptest.NewRecorder()
+	handler.ListWarehouses(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var page struct {
+		Items []models.Warehouse `json:"items"`
+		Total int                `json:"total"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, []models.Warehouse{{ID: 3, Name: "Small", Capacity: 100, Location: "Dhaka", Version: 2}}, page.Items)
+
+	// Fields that are not columns of the collection are rejected before any query runs
+	req, _ = http.NewRequest("GET", "/warehouses?sort=manager", nil)
+	rec = httptest.NewRecorder()
+	handler.ListWarehouses(rec, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package webhook_handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"erp/models"
+)
+
+// ErrInvalidSignature is returned when a webhook signature is missing or does not match the payload.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// signatureScheme describes how a signature is computed and encoded for one scheme.
+type signatureScheme struct {
+	newHash func() hash.Hash
+	encode  func([]byte) string
+	prefix  string // Optional prefix providers put in front of the signature (e.g. "sha256=")
+	hex     bool   // Hex signatures are compared case-insensitively
+}
+
+var signatureSchemes = map[string]signatureScheme{
+	models.SignatureSchemeHMACSHA256:       {newHash: sha256.New, encode: hex.EncodeToString, prefix: "sha256=", hex: true},
+	models.SignatureSchemeHMACSHA256Base64: {newHash: sha256.New, encode: base64.StdEncoding.EncodeToString},
+	models.SignatureSchemeHMACSHA1:         {newHash: sha1.New, encode: hex.EncodeToString, prefix: "sha1=", hex: true},
+}
+
+// IsSupportedScheme reports whether scheme is one of the known signature schemes.
+func IsSupportedScheme(scheme string) bool {
+	_, ok := signatureSchemes[scheme]
+	return ok
+}
+
+// VerifySignature checks a webhook signature against the raw request body using the
+// integration's shared secret and signature scheme.
+//
+// Parameters:
+//   - scheme: One of the models.SignatureScheme* constants.
+//   - secret: The shared secret configured for the integration.
+//   - signature: The signature value received in the request header.
+//   - body: The raw request body exactly as received.
+//
+// Returns:
+//   - error: ErrInvalidSignature if the signature does not match, or an error for unknown schemes.
+func VerifySignature(scheme, secret, signature string, body []byte) error {
+	s, ok := signatureSchemes[scheme]
+	if !ok {
+		return fmt.Errorf("unsupported signature scheme %q", scheme)
+	}
+	if signature == "" {
+		return ErrInvalidSignature
+	}
+
+	signature = strings.TrimPrefix(signature, s.prefix)
+	if s.hex {
+		signature = strings.ToLower(signature)
+	}
+
+	mac := hmac.New(s.newHash, []byte(secret))
+	mac.Write(body)
+	expected := s.encode(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package webhook_handlers provides SQL-backed methods to manage webhook integrations
+// and the inbound events received from them.
+package webhook_handlers
+
+import (
+	"context"
+	"database/sql"
+	"erp/models"
+	"time"
+)
+
+// DBWebhookStore provides SQL-backed methods for the webhook_integrations and
+// webhook_events tables.
+type DBWebhookStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// CreateIntegration inserts a new webhook integration and assigns its generated ID.
+//
+// Parameters:
+//   - integration: A pointer to the WebhookIntegration to store.
+//
+// Returns:
+//   - error: An error if the insertion fails, otherwise nil.
+func (store *DBWebhookStore) CreateIntegration(ctx context.Context, integration *models.WebhookIntegration) error {
+	return store.DB.QueryRowContext(ctx,
+		`INSERT INTO webhook_integrations (name, module, secret, signature_scheme, signature_header, event_id_header, active)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		integration.Name, integration.Module, integration.Secret, integration.SignatureScheme,
+		integration.SignatureHeader, integration.EventIDHeader, integration.Active,
+	).Scan(&integration.ID)
+}
+
+// GetIntegrationByID retrieves an integration by its ID.
+//
+// Parameters:
+//   - id: The ID of the integration.
+//
+// Returns:
+//   - *WebhookIntegration: The integration if found.
+//   - error: models.ErrNotFound if no integration exists with the given ID.
+func (store *DBWebhookStore) GetIntegrationByID(ctx context.Context, id int) (*models.WebhookIntegration, error) {
+	return store.getIntegration(ctx, "id = $1", id)
+}
+
+// GetIntegrationByName retrieves an integration by its unique name.
+//
+// Parameters:
+//   - name: The slug of the integration as used in the inbound URL.
+//
+// Returns:
+//   - *WebhookIntegration: The integration if found.
+//   - error: models.ErrNotFound if no integration exists with the given name.
+func (store *DBWebhookStore) GetIntegrationByName(ctx context.Context, name string) (*models.WebhookIntegration, error) {
+	return store.getIntegration(ctx, "name = $1", name)
+}
+
+// getIntegration loads a single integration matching the given WHERE clause.
+func (store *DBWebhookStore) getIntegration(ctx context.Context, where string, args ...interface{}) (*models.WebhookIntegration, error) {
+	var integration models.WebhookIntegration
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT id, name, module, secret, signature_scheme, signature_header, event_id_header, active
+		 FROM webhook_integrations WHERE `+where, args...,
+	).Scan(&integration.ID, &integration.Name, &integration.Module, &integration.Secret,
+		&integration.SignatureScheme, &integration.SignatureHeader, &integration.EventIDHeader, &integration.Active)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &integration, nil
+}
+
+// SaveEvent stores an inbound event. The (integration_id, external_id) pair is unique,
+// so a redelivered event is detected and the existing row is loaded into event instead.
+//
+// Parameters:
+//   - event: A pointer to the WebhookEvent to store.
+//
+// Returns:
+//   - bool: true if a new row was inserted, false if the event is a duplicate.
+//   - error: An error if the query fails.
+func (store *DBWebhookStore) SaveEvent(ctx context.Context, event *models.WebhookEvent) (bool, error) {
+	err := store.DB.QueryRowContext(ctx,
+		`INSERT INTO webhook_events (integration_id, external_id, payload, status, received_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (integration_id, external_id) DO NOTHING
+		 RETURNING id`,
+		event.IntegrationID, event.ExternalID, event.Payload, event.Status, event.ReceivedAt,
+	).Scan(&event.ID)
+	if err == nil {
+		return true, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	existing, err := store.getEvent(ctx, "integration_id = $1 AND external_id = $2", event.IntegrationID, event.ExternalID)
+	if err != nil {
+		return false, err
+	}
+	*event = *existing
+	return false, nil
+}
+
+// GetEventByID retrieves a webhook event by its ID.
+//
+// Parameters:
+//   - id: The ID of the event.
+//
+// Returns:
+//   - *WebhookEvent: The event if found.
+//   - error: models.ErrNotFound if the event does not exist.
+func (store *DBWebhookStore) GetEventByID(ctx context.Context, id int) (*models.WebhookEvent, error) {
+	return store.getEvent(ctx, "id = $1", id)
+}
+
+// MarkEventProcessed records a successful processing attempt for an event.
+//
+// Parameters:
+//   - id: The ID of the event.
+//
+// Returns:
+//   - error: models.ErrNotFound if the event does not exist, or an error if the update fails.
+func (store *DBWebhookStore) MarkEventProcessed(ctx context.Context, id int) error {
+	return store.updateStatus(ctx,
+		"UPDATE webhook_events SET status = $1, attempts = attempts + 1, last_error = '', processed_at = $2 WHERE id = $3",
+		models.WebhookEventProcessed, time.Now(), id,
+	)
+}
+
+// MarkEventFailed records a failed processing attempt for an event.
+//
+// Parameters:
+//   - id: The ID of the event.
+//   - reason: The error message returned by the processor.
+//
+// Returns:
+//   - error: models.ErrNotFound if the event does not exist, or an error if the update fails.
+func (store *DBWebhookStore) MarkEventFailed(ctx context.Context, id int, reason string) error {
+	return store.updateStatus(ctx,
+		"UPDATE webhook_events SET status = $1, attempts = attempts + 1, last_error = $2 WHERE id = $3",
+		models.WebhookEventFailed, reason, id,
+	)
+}
+
+// getEvent loads a single event matching the given WHERE clause.
+func (store *DBWebhookStore) getEvent(ctx context.Context, where string, args ...interface{}) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	var lastError sql.NullString
+	var processedAt sql.NullTime
+	err := store.DB.QueryRowContext(ctx,
+		`SELECT id, integration_id, external_id, payload, status, attempts, last_error, received_at, processed_at
+		 FROM webhook_events WHERE `+where, args...,
+	).Scan(&event.ID, &event.IntegrationID, &event.ExternalID, &event.Payload, &event.Status,
+		&event.Attempts, &lastError, &event.ReceivedAt, &processedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	event.LastError = lastError.String
+	if processedAt.Valid {
+		event.ProcessedAt = &processedAt.Time
+	}
+	return &event, nil
+}
+
+// updateStatus executes a status update and checks that a row was affected.
+func (store *DBWebhookStore) updateStatus(ctx context.Context, query string, args ...interface{}) error {
+	result, err := store.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.NotFound("webhook event %v not found", args[len(args)-1])
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+// Package webhook_handlers provides a generic inbound webhook receiver for callbacks from
+// payment providers, shipping carriers, and banks. Each integration has its own secret and
+// signature scheme; payloads are persisted before processing so redeliveries are idempotent,
+// and verified events are dispatched to module-specific processors.
+package webhook_handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+)
+
+// maxPayloadSize limits the size of an inbound webhook body.
+const maxPayloadSize = 1 << 20
+
+// Processor handles verified webhook events for a single module (e.g. "payments", "shipping").
+// Processors must be safe to call more than once for the same event, since failed events
+// are retried when the provider redelivers them.
+type Processor interface {
+	Process(ctx context.Context, integration *models.WebhookIntegration, event *models.WebhookEvent) error
+}
+
+// ProcessorFunc adapts an ordinary function to the Processor interface.
+type ProcessorFunc func(integration *models.WebhookIntegration, event *models.WebhookEvent) error
+
+// Process calls f(integration, event).
+func (f ProcessorFunc) Process(ctx context.Context, integration *models.WebhookIntegration, event *models.WebhookEvent) error {
+	return f(integration, event)
+}
+
+// WebhookHandler provides HTTP handlers for receiving webhooks and managing integrations.
+type WebhookHandler struct {
+	Store      models.WebhookStore  // Store persists integrations and received events.
+	Processors map[string]Processor // Processors maps an integration's module to its processor.
+}
+
+// RegisterInboundRoutes maps the route external integrations call. It is public: each
+// callback is authenticated by its signature.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the WebhookStore interface.
+//   - processors: Module-specific processors keyed by module name.
+func RegisterInboundRoutes(router *mux.Router, store models.WebhookStore, processors map[string]Processor) {
+	handler := &WebhookHandler{Store: store, Processors: processors}
+
+	router.HandleFunc("/inbound/{integration}", handler.Receive).Methods("POST")
+}
+
+// RegisterRoutes maps the routes that manage integrations and received events. The router
+// is expected to be restricted to administrators, since an integration's secret lets its
+// holder send events the processors apply.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered.
+//   - store: An implementation of the WebhookStore interface.
+//   - processors: Module-specific processors keyed by module name, used to retry events.
+func RegisterRoutes(router *mux.Router, store models.WebhookStore, processors map[string]Processor) {
+	handler := &WebhookHandler{Store: store, Processors: processors}
+
+	router.HandleFunc("/integrations", handler.CreateIntegration).Methods("POST")
+	router.HandleFunc("/events/{id:[0-9]+}", handler.GetEvent).Methods("GET")
+	router.HandleFunc("/events/{id:[0-9]+}/retry", handler.RetryEvent).Methods("POST")
+}
+
+// Receive accepts a callback from an external integration. The signature is verified against
+// the raw body, the payload is persisted, and the event is dispatched to the processor
+// registered for the integration's module. Redelivered events that were already processed
+// are acknowledged without being processed again.
+//
+// HTTP Method: POST
+// URL Path: /inbound/{integration}
+//
+// Response:
+//   - Status Code: 200 (OK) if the event was processed or is a duplicate of a processed event.
+//   - Status Code: 202 (Accepted) if the event was stored but no processor is registered.
+//   - Status Code: 401 (Unauthorized) if the signature is missing or invalid.
+//   - Status Code: 404 (Not Found) if the integration is unknown or inactive.
+//   - Status Code: 500 (Internal Server Error) if storing or processing fails, so the sender retries.
+func (h *WebhookHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	integration, err := h.Store.GetIntegrationByName(r.Context(), mux.Vars(r)["integration"])
+	if errors.Is(err, models.ErrNotFound) || (err == nil && !integration.Active) {
+		respond.Error(w, http.StatusNotFound, "Unknown integration")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to load integration")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Could not read request body")
+		return
+	}
+
+	if err := VerifySignature(integration.SignatureScheme, integration.Secret, r.Header.Get(integration.SignatureHeader), body); err != nil {
+		log.Printf("webhook %s: signature verification failed: %v", integration.Name, err)
+		respond.Error(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	event := &models.WebhookEvent{
+		IntegrationID: integration.ID,
+		ExternalID:    externalID(r, integration, body),
+		Payload:       string(body),
+		Status:        models.WebhookEventReceived,
+		ReceivedAt:    time.Now(),
+	}
+	created, err := h.Store.SaveEvent(r.Context(), event)
+	if err != nil {
+		httperr.Write(w, err, "Failed to store webhook event")
+		return
+	}
+	if !created && event.Status == models.WebhookEventProcessed {
+		writeEventStatus(w, http.StatusOK, event, "duplicate")
+		return
+	}
+
+	h.dispatch(r.Context(), w, integration, event)
+}
+
+// IntegrationRequest is the request body for registering a webhook integration.
+type IntegrationRequest struct {
+	Name            string `json:"name"`
+	Module          string `json:"module"`
+	Secret          string `json:"secret"`
+	SignatureScheme string `json:"signature_scheme"`
+	SignatureHeader string `json:"signature_header"`
+	EventIDHeader   string `json:"event_id_header"`
+	Active          bool   `json:"active"`
+}
+
+// Integration returns the integration described by the request.
+func (req IntegrationRequest) Integration() models.WebhookIntegration {
+	return models.WebhookIntegration{
+		Name:            req.Name,
+		Module:          req.Module,
+		Secret:          req.Secret,
+		SignatureScheme: req.SignatureScheme,
+		SignatureHeader: req.SignatureHeader,
+		EventIDHeader:   req.EventIDHeader,
+		Active:          req.Active,
+	}
+}
+
+// IntegrationResponse is an integration as shown to clients. The secret is never sent back.
+type IntegrationResponse struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Module          string `json:"module"`
+	SignatureScheme string `json:"signature_scheme"`
+	SignatureHeader string `json:"signature_header"`
+	EventIDHeader   string `json:"event_id_header"`
+	Active          bool   `json:"active"`
+}
+
+// NewIntegrationResponse returns the response for an integration.
+func NewIntegrationResponse(integration *models.WebhookIntegration) IntegrationResponse {
+	return IntegrationResponse{
+		ID:              integration.ID,
+		Name:            integration.Name,
+		Module:          integration.Module,
+		SignatureScheme: integration.SignatureScheme,
+		SignatureHeader: integration.SignatureHeader,
+		EventIDHeader:   integration.EventIDHeader,
+		Active:          integration.Active,
+	}
+}
+
+// CreateIntegration registers a new webhook integration.
+//
+// HTTP Method: POST
+// URL Path: /integrations
+//
+// Request Body:
+//   - JSON with the fields of an IntegrationRequest.
+//
+// Response:
+//   - Status Code: 201 (Created) with the created integration (without its secret).
+//   - Status Code: 400 (Bad Request) if required fields are missing or the scheme is unknown.
+//   - Status Code: 500 (Internal Server Error) if the integration could not be saved.
+func (h *WebhookHandler) CreateIntegration(w http.ResponseWriter, r *http.Request) {
+	var req IntegrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid input data")
+		return
+	}
+	integration := req.Integration()
+	if integration.Name == "" || integration.Module == "" || integration.Secret == "" || integration.SignatureHeader == "" {
+		respond.Error(w, http.StatusBadRequest, "name, module, secret and signature_header are required")
+		return
+	}
+	if !IsSupportedScheme(integration.SignatureScheme) {
+		respond.Error(w, http.StatusBadRequest, fmt.Sprintf("Unsupported signature scheme %q", integration.SignatureScheme))
+		return
+	}
+
+	if err := h.Store.CreateIntegration(r.Context(), &integration); err != nil {
+		httperr.Write(w, err, "Failed to create integration")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, NewIntegrationResponse(&integration))
+}
+
+// GetEvent retrieves a stored webhook event by its ID.
+//
+// HTTP Method: GET
+// URL Path: /events/{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the event in JSON format.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the event does not exist.
+func (h *WebhookHandler) GetEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	event, err := h.Store.GetEventByID(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Webhook event not found")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to load webhook event")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, event)
+}
+
+// RetryEvent re-runs the processor for a stored event that previously failed.
+// Events that were already processed are returned unchanged.
+//
+// HTTP Method: POST
+// URL Path: /events/{id}/retry
+//
+// Response:
+//   - Status Code: 200 (OK) if the event is processed.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the event or its integration does not exist.
+//   - Status Code: 500 (Internal Server Error) if processing fails again.
+func (h *WebhookHandler) RetryEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	event, err := h.Store.GetEventByID(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Webhook event not found")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to load webhook event")
+		return
+	}
+	if event.Status == models.WebhookEventProcessed {
+		writeEventStatus(w, http.StatusOK, event, "duplicate")
+		return
+	}
+
+	integration, err := h.Store.GetIntegrationByID(r.Context(), event.IntegrationID)
+	if errors.Is(err, models.ErrNotFound) {
+		respond.Error(w, http.StatusNotFound, "Integration not found")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err, "Failed to load integration")
+		return
+	}
+
+	h.dispatch(r.Context(), w, integration, event)
+}
+
+// dispatch runs the module processor for an event and records the outcome.
+func (h *WebhookHandler) dispatch(ctx context.Context, w http.ResponseWriter, integration *models.WebhookIntegration, event *models.WebhookEvent) {
+	processor, ok := h.Processors[integration.Module]
+	if !ok {
+		writeEventStatus(w, http.StatusAccepted, event, event.Status)
+		return
+	}
+
+	if err := processor.Process(ctx, integration, event); err != nil {
+		log.Printf("webhook %s: processing event %d failed: %v", integration.Name, event.ID, err)
+		if markErr := h.Store.MarkEventFailed(ctx, event.ID, err.Error()); markErr != nil {
+			log.Printf("webhook %s: could not record failure for event %d: %v", integration.Name, event.ID, markErr)
+		}
+		respond.Error(w, http.StatusInternalServerError, "Failed to process webhook event")
+		return
+	}
+
+	if err := h.Store.MarkEventProcessed(ctx, event.ID); err != nil {
+		httperr.Write(w, err, "Failed to record webhook event")
+		return
+	}
+	event.Status = models.WebhookEventProcessed
+	writeEventStatus(w, http.StatusOK, event, event.Status)
+}
+
+// externalID returns the provider's event ID from the configured header, falling back to a
+// hash of the body so identical redeliveries are still detected.
+func externalID(r *http.Request, integration *models.WebhookIntegration, body []byte) string {
+	if integration.EventIDHeader != "" {
+		if id := r.Header.Get(integration.EventIDHeader); id != "" {
+			return id
+		}
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// writeEventStatus writes a short JSON acknowledgement for a webhook event.
+func writeEventStatus(w http.ResponseWriter, status int, event *models.WebhookEvent, state string) {
+	respond.JSON(w, status, map[string]interface{}{
+		"event_id": event.ID,
+		"status":   state,
+	})
+}
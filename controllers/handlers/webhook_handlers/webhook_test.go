@@ -0,0 +1,205 @@
+// Package webhook_handlers contains tests for the inbound webhook receiver using an
+// in-memory WebhookStore implementation.
+package webhook_handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockWebhookStore is an in-memory implementation of the WebhookStore interface.
+type MockWebhookStore struct {
+	integrations map[int]*models.WebhookIntegration // Integrations keyed by ID
+	events       map[int]*models.WebhookEvent       // Events keyed by ID
+	nextID       int                                // Counter for unique event IDs
+}
+
+// NewMockWebhookStore creates a mock store with a single active integration named "bank".
+func NewMockWebhookStore() *MockWebhookStore {
+	return &MockWebhookStore{
+		integrations: map[int]*models.WebhookIntegration{
+			1: {
+				ID: 1, Name: "bank", Module: "banking", Secret: "s3cret",
+				SignatureScheme: models.SignatureSchemeHMACSHA256, SignatureHeader: "X-Signature",
+				EventIDHeader: "X-Event-ID", Active: true,
+			},
+		},
+		events: make(map[int]*models.WebhookEvent),
+	}
+}
+
+func (m *MockWebhookStore) CreateIntegration(ctx context.Context, integration *models.WebhookIntegration) error {
+	integration.ID = len(m.integrations) + 1
+	m.integrations[integration.ID] = integration
+	return nil
+}
+
+func (m *MockWebhookStore) GetIntegrationByID(ctx context.Context, id int) (*models.WebhookIntegration, error) {
+	integration, ok := m.integrations[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	return integration, nil
+}
+
+func (m *MockWebhookStore) GetIntegrationByName(ctx context.Context, name string) (*models.WebhookIntegration, error) {
+	for _, integration := range m.integrations {
+		if integration.Name == name {
+			return integration, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (m *MockWebhookStore) SaveEvent(ctx context.Context, event *models.WebhookEvent) (bool, error) {
+	for _, existing := range m.events {
+		if existing.IntegrationID == event.IntegrationID && existing.ExternalID == event.ExternalID {
+			*event = *existing
+			return false, nil
+		}
+	}
+	m.nextID++
+	event.ID = m.nextID
+	stored := *event
+	m.events[event.ID] = &stored
+	return true, nil
+}
+
+func (m *MockWebhookStore) GetEventByID(ctx context.Context, id int) (*models.WebhookEvent, error) {
+	event, ok := m.events[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+	copied := *event
+	return &copied, nil
+}
+
+func (m *MockWebhookStore) MarkEventProcessed(ctx context.Context, id int) error {
+	m.events[id].Status = models.WebhookEventProcessed
+	m.events[id].Attempts++
+	return nil
+}
+
+func (m *MockWebhookStore) MarkEventFailed(ctx context.Context, id int, reason string) error {
+	m.events[id].Status = models.WebhookEventFailed
+	m.events[id].LastError = reason
+	m.events[id].Attempts++
+	return nil
+}
+
+// sign computes the hex HMAC-SHA256 signature used by the mock integration.
+func sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookRequest builds a signed inbound request for the "bank" integration.
+func newWebhookRequest(body []byte, signature, eventID string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/inbound/bank", bytes.NewReader(body))
+	req.Header.Set("X-Signature", signature)
+	req.Header.Set("X-Event-ID", eventID)
+	return req
+}
+
+// TestReceiveProcessesSignedEvent verifies that a correctly signed event is stored and dispatched.
+func TestReceiveProcessesSignedEvent(t *testing.T) {
+	store := NewMockWebhookStore()
+	calls := 0
+	router := mux.NewRouter()
+	RegisterInboundRoutes(router, store, map[string]Processor{
+		"banking": ProcessorFunc(func(_ *models.WebhookIntegration, _ *models.WebhookEvent) error {
+			calls++
+			return nil
+		}),
+	})
+
+	body := []byte(`{"amount": 100}`)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newWebhookRequest(body, "sha256="+sign(body), "evt_1"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, models.WebhookEventProcessed, store.events[1].Status)
+}
+
+// TestReceiveRejectsInvalidSignature verifies that unsigned or tampered payloads are rejected
+// before anything is persisted.
+func TestReceiveRejectsInvalidSignature(t *testing.T) {
+	store := NewMockWebhookStore()
+	router := mux.NewRouter()
+	RegisterInboundRoutes(router, store, map[string]Processor{})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newWebhookRequest([]byte(`{"amount": 100}`), sign([]byte(`{"amount": 999}`)), "evt_1"))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Empty(t, store.events)
+}
+
+// TestReceiveIsIdempotent verifies that a redelivered event is not processed twice, while a
+// redelivery of a failed event is retried.
+func TestReceiveIsIdempotent(t *testing.T) {
+	store := NewMockWebhookStore()
+	calls := 0
+	fail := true
+	router := mux.NewRouter()
+	RegisterInboundRoutes(router, store, map[string]Processor{
+		"banking": ProcessorFunc(func(_ *models.WebhookIntegration, _ *models.WebhookEvent) error {
+			calls++
+			if fail {
+				return errors.New("ledger unavailable")
+			}
+			return nil
+		}),
+	})
+
+	body := []byte(`{"amount": 100}`)
+	signature := sign(body)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, newWebhookRequest(body, signature, "evt_1"))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, models.WebhookEventFailed, store.events[1].Status)
+
+	fail = false
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, newWebhookRequest(body, signature, "evt_1"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, newWebhookRequest(body, signature, "evt_1"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "duplicate")
+
+	assert.Equal(t, 2, calls)
+	assert.Len(t, store.events, 1)
+}
+
+// TestInboundRoutesDoNotExposeManagement verifies that the public router only accepts
+// callbacks, so integrations cannot be created or events read without signing in.
+func TestInboundRoutesDoNotExposeManagement(t *testing.T) {
+	router := mux.NewRouter()
+	RegisterInboundRoutes(router, NewMockWebhookStore(), map[string]Processor{})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/integrations",
+		bytes.NewReader([]byte(`{"name": "fedex", "module": "shipping", "secret": "mine"}`))))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/events/1", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,281 @@
+// Package hrcases holds the rules of confidential disciplinary and grievance cases. Cases
+// are only open to HR, and HR staff who are a party to a case are kept out of it. Notes,
+// attachments and the history of a case are append-only, and the statistics drawn from the
+// cases carry no identifying details.
+package hrcases
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// transitions lists the statuses a case may move to from each status.
+var transitions = map[string][]string{
+	models.HRCaseOpen:          {models.HRCaseInvestigating, models.HRCaseResolved},
+	models.HRCaseInvestigating: {models.HRCaseResolved},
+	models.HRCaseResolved:      {models.HRCaseInvestigating, models.HRCaseClosed},
+}
+
+// Service applies the HR case rules on top of an HRCaseStore.
+type Service struct {
+	Store models.HRCaseStore
+	Now   func() time.Time
+}
+
+// NewService creates an HR case service backed by store.
+func NewService(store models.HRCaseStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Open checks a case and records it as open.
+//
+// Parameters:
+//   - c: The case; Type, Summary and Parties (user and role) are read. The store fills in
+//     the ID, the parties' names and emails, and the time.
+//   - actor: Email of the HR user opening the case.
+//
+// Returns:
+//   - error: A validation error if the case is incomplete, or the store's error.
+func (s *Service) Open(ctx context.Context, c *models.HRCase, actor string) error {
+	c.Summary = strings.TrimSpace(c.Summary)
+	switch {
+	case c.Type != models.HRCaseDisciplinary && c.Type != models.HRCaseGrievance:
+		return models.Invalid("type must be %q or %q", models.HRCaseDisciplinary, models.HRCaseGrievance)
+	case c.Summary == "":
+		return models.Invalid("a summary is required")
+	}
+	seen := make(map[int]bool, len(c.Parties))
+	roles := make(map[string]bool, len(c.Parties))
+	for i, party := range c.Parties {
+		if err := validParty(party); err != nil {
+			return models.Invalid("party %d: %v", i+1, err)
+		}
+		if seen[party.UserID] {
+			return models.Invalid("party %d: user %d is listed twice", i+1, party.UserID)
+		}
+		seen[party.UserID] = true
+		roles[party.Role] = true
+	}
+	if c.Type == models.HRCaseDisciplinary && !roles[models.HRPartySubject] {
+		return models.Invalid("a disciplinary case needs a %s", models.HRPartySubject)
+	}
+	if c.Type == models.HRCaseGrievance && !roles[models.HRPartyComplainant] {
+		return models.Invalid("a grievance needs a %s", models.HRPartyComplainant)
+	}
+	c.Status = models.HRCaseOpen
+	c.OpenedBy = actor
+	return s.Store.CreateHRCase(ctx, c)
+}
+
+// Get returns a case with its parties, notes, attachments and history.
+//
+// Returns:
+//   - error: A permission error if the actor is a party to the case, or the store's error.
+func (s *Service) Get(ctx context.Context, id int, actor string) (*models.HRCase, error) {
+	c, err := s.Store.GetHRCase(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if IsParty(c, actor) {
+		return nil, models.PermissionDenied("you are a party to HR case %d", id)
+	}
+	return c, nil
+}
+
+// List returns the cases in a status and of a type, or all of them if those are empty,
+// leaving out the cases the actor is a party to.
+func (s *Service) List(ctx context.Context, status, caseType, actor string) ([]models.HRCase, error) {
+	switch status {
+	case "", models.HRCaseOpen, models.HRCaseInvestigating, models.HRCaseResolved, models.HRCaseClosed:
+	default:
+		return nil, models.Invalid("unknown HR case status %q", status)
+	}
+	switch caseType {
+	case "", models.HRCaseDisciplinary, models.HRCaseGrievance:
+	default:
+		return nil, models.Invalid("unknown HR case type %q", caseType)
+	}
+	cases, err := s.Store.ListHRCases(ctx, status, caseType)
+	if err != nil {
+		return nil, err
+	}
+	visible := make([]models.HRCase, 0, len(cases))
+	for _, c := range cases {
+		if !IsParty(&c, actor) {
+			visible = append(visible, c)
+		}
+	}
+	return visible, nil
+}
+
+// AddParty adds a party to a case that is not closed.
+func (s *Service) AddParty(ctx context.Context, id int, party *models.HRCaseParty, actor string) (*models.HRCase, error) {
+	if err := validParty(*party); err != nil {
+		return nil, models.Invalid("%v", err)
+	}
+	c, err := s.Get(ctx, id, actor)
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range c.Parties {
+		if existing.UserID == party.UserID {
+			return nil, models.Conflict("user %d is already a party to HR case %d", party.UserID, id)
+		}
+	}
+	if err := s.Store.AddHRCaseParty(ctx, id, party, actor); err != nil {
+		return nil, err
+	}
+	return s.Store.GetHRCase(ctx, id)
+}
+
+// AddNote adds a note to a case that is not closed. Notes cannot be changed afterwards.
+func (s *Service) AddNote(ctx context.Context, id int, body, actor string) (*models.HRCaseNote, error) {
+	note := &models.HRCaseNote{Body: strings.TrimSpace(body), Author: actor}
+	if note.Body == "" {
+		return nil, models.Invalid("a note cannot be empty")
+	}
+	if _, err := s.Get(ctx, id, actor); err != nil {
+		return nil, err
+	}
+	if err := s.Store.AddHRCaseNote(ctx, id, note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// Attach records a document already put in the private storage against a case that is not
+// closed. Callers check access with Get before storing the document.
+func (s *Service) Attach(ctx context.Context, id int, attachment *models.HRCaseAttachment) error {
+	return s.Store.AddHRCaseAttachment(ctx, id, attachment)
+}
+
+// Attachment returns a document of a case the actor may see.
+func (s *Service) Attachment(ctx context.Context, id, attachmentID int, actor string) (*models.HRCaseAttachment, error) {
+	if _, err := s.Get(ctx, id, actor); err != nil {
+		return nil, err
+	}
+	return s.Store.GetHRCaseAttachment(ctx, id, attachmentID)
+}
+
+// Move changes the status of a case. Resolving a case requires its outcome.
+//
+// Parameters:
+//   - id: The ID of the case.
+//   - to: The new status.
+//   - outcome: The outcome, such as "written warning"; required when resolving.
+//   - actor: Email of the HR user.
+//
+// Returns:
+//   - *models.HRCase: The case after the change.
+//   - error: A validation error if the status is unknown or the outcome missing, a conflict
+//     if the case cannot move to the status, or the store's error.
+func (s *Service) Move(ctx context.Context, id int, to, outcome, actor string) (*models.HRCase, error) {
+	outcome = strings.TrimSpace(outcome)
+	switch to {
+	case models.HRCaseInvestigating, models.HRCaseClosed:
+	case models.HRCaseResolved:
+		if outcome == "" {
+			return nil, models.Invalid("an outcome is required to resolve a case")
+		}
+	default:
+		return nil, models.Invalid("a case cannot be moved to %q", to)
+	}
+	c, err := s.Get(ctx, id, actor)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed(c.Status, to) {
+		return nil, models.Conflict("HR case %d is %s and cannot be moved to %s", id, c.Status, to)
+	}
+	return s.Store.ChangeHRCaseStatus(ctx, id, c.Status, to, outcome, actor)
+}
+
+// Statistics counts the cases opened in [from, to) by type, status and month, with the
+// average time to resolve them. Nothing in it identifies a case or a person. A zero from or
+// to defaults to the start of the year and now.
+func (s *Service) Statistics(ctx context.Context, from, to time.Time) (*models.HRCaseStatistics, error) {
+	if to.IsZero() {
+		to = s.Now()
+	}
+	if from.IsZero() {
+		from = time.Date(to.Year(), 1, 1, 0, 0, 0, 0, to.Location())
+	}
+	if !from.Before(to) {
+		return nil, models.Invalid("from must be before to")
+	}
+	cases, err := s.Store.ListHRCasesOpened(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return Summarise(cases, from, to), nil
+}
+
+// Summarise builds the statistics of cases opened in [from, to).
+func Summarise(cases []models.HRCase, from, to time.Time) *models.HRCaseStatistics {
+	stats := &models.HRCaseStatistics{From: from, To: to, Opened: len(cases),
+		ByType: map[string]int{}, ByStatus: map[string]int{}, ByMonth: []models.HRCaseMonth{}}
+	months := map[string]int{}
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location()); month.Before(to); month = month.AddDate(0, 1, 0) {
+		months[month.Format("2006-01")] = len(stats.ByMonth)
+		stats.ByMonth = append(stats.ByMonth, models.HRCaseMonth{Month: month.Format("2006-01")})
+	}
+
+	var days float64
+	var resolved int
+	for _, c := range cases {
+		stats.ByType[c.Type]++
+		stats.ByStatus[c.Status]++
+		if i, ok := months[c.OpenedAt.Format("2006-01")]; ok {
+			stats.ByMonth[i].Opened++
+		}
+		if c.ResolvedAt == nil {
+			continue
+		}
+		if i, ok := months[c.ResolvedAt.Format("2006-01")]; ok {
+			stats.ByMonth[i].Resolved++
+		}
+		days += c.ResolvedAt.Sub(c.OpenedAt).Hours() / 24
+		resolved++
+	}
+	if resolved > 0 {
+		stats.AverageDaysToResolve = math.Round(days/float64(resolved)*10) / 10
+	}
+	return stats
+}
+
+// IsParty reports whether the user with the given email is a party to the case.
+func IsParty(c *models.HRCase, email string) bool {
+	for _, party := range c.Parties {
+		if party.Email != "" && strings.EqualFold(party.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// validParty checks a party's user and role.
+func validParty(party models.HRCaseParty) error {
+	if party.UserID <= 0 {
+		return fmt.Errorf("user_id is required")
+	}
+	switch party.Role {
+	case models.HRPartySubject, models.HRPartyComplainant, models.HRPartyWitness, models.HRPartyRepresentative:
+		return nil
+	}
+	return fmt.Errorf("unknown role %q", party.Role)
+}
+
+// allowed reports whether a case may move from one status to another.
+func allowed(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package hrcases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps cases in a map; parties get the email <user_id>@example.com.
+type memoryStore struct {
+	cases map[int]*models.HRCase
+	moved string
+}
+
+func (m *memoryStore) CreateHRCase(ctx context.Context, c *models.HRCase) error {
+	if m.cases == nil {
+		m.cases = map[int]*models.HRCase{}
+	}
+	c.ID = len(m.cases) + 1
+	for i := range c.Parties {
+		c.Parties[i].Email = email(c.Parties[i].UserID)
+	}
+	m.cases[c.ID] = c
+	return nil
+}
+
+func (m *memoryStore) GetHRCase(ctx context.Context, id int) (*models.HRCase, error) {
+	c, ok := m.cases[id]
+	if !ok {
+		return nil, models.NotFound("HR case %d not found", id)
+	}
+	return c, nil
+}
+
+func (m *memoryStore) ListHRCases(ctx context.Context, status, caseType string) ([]models.HRCase, error) {
+	var list []models.HRCase
+	for id := 1; id <= len(m.cases); id++ {
+		list = append(list, *m.cases[id])
+	}
+	return list, nil
+}
+
+func (m *memoryStore) ListHRCasesOpened(ctx context.Context, from, to time.Time) ([]models.HRCase, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) AddHRCaseParty(ctx context.Context, caseID int, party *models.HRCaseParty, actor string) error {
+	return nil
+}
+
+func (m *memoryStore) AddHRCaseNote(ctx context.Context, caseID int, note *models.HRCaseNote) error {
+	return nil
+}
+
+func (m *memoryStore) AddHRCaseAttachment(ctx context.Context, caseID int, attachment *models.HRCaseAttachment) error {
+	return nil
+}
+
+func (m *memoryStore) GetHRCaseAttachment(ctx context.Context, caseID, attachmentID int) (*models.HRCaseAttachment, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) ChangeHRCaseStatus(ctx context.Context, id int, from, to, outcome, actor string) (*models.HRCase, error) {
+	m.moved = from + ">" + to
+	m.cases[id].Status = to
+	return m.cases[id], nil
+}
+
+func email(userID int) string {
+	return map[int]string{1: "employee@example.com", 2: "manager@example.com", 3: "hr@example.com"}[userID]
+}
+
+func TestOpenChecksTheCase(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&memoryStore{})
+	for _, c := range []models.HRCase{
+		{Type: "complaint", Summary: "Late", Parties: []models.HRCaseParty{{UserID: 1, Role: models.HRPartySubject}}},
+		{Type: models.HRCaseDisciplinary, Summary: " ", Parties: []models.HRCaseParty{{UserID: 1, Role: models.HRPartySubject}}},
+		{Type: models.HRCaseDisciplinary, Summary: "Late", Parties: []models.HRCaseParty{{UserID: 1, Role: models.HRPartyWitness}}},
+		{Type: models.HRCaseGrievance, Summary: "Bullying", Parties: []models.HRCaseParty{{UserID: 1, Role: models.HRPartySubject}}},
+		{Type: models.HRCaseGrievance, Summary: "Bullying", Parties: []models.HRCaseParty{{UserID: 1, Role: "bystander"}}},
+		{Type: models.HRCaseGrievance, Summary: "Bullying", Parties: []models.HRCaseParty{
+			{UserID: 1, Role: models.HRPartyComplainant}, {UserID: 1, Role: models.HRPartyWitness}}},
+	} {
+		err := s.Open(ctx, &c, "hr@example.com")
+		assert.True(t, errors.Is(err, models.ErrValidation), "%+v", c)
+	}
+
+	c := models.HRCase{Type: models.HRCaseGrievance, Summary: " Bullying ", Parties: []models.HRCaseParty{
+		{UserID: 1, Role: models.HRPartyComplainant}, {UserID: 2, Role: models.HRPartySubject}}}
+	require.NoError(t, s.Open(ctx, &c, "hr@example.com"))
+	assert.Equal(t, models.HRCaseOpen, c.Status)
+	assert.Equal(t, "Bullying", c.Summary)
+}
+
+func TestPartiesCannotSeeTheirCase(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(&memoryStore{})
+	require.NoError(t, s.Open(ctx, &models.HRCase{Type: models.HRCaseDisciplinary, Summary: "Absence",
+		Parties: []models.HRCaseParty{{UserID: 3, Role: models.HRPartySubject}}}, "head@example.com"))
+	require.NoError(t, s.Open(ctx, &models.HRCase{Type: models.HRCaseDisciplinary, Summary: "Late",
+		Parties: []models.HRCaseParty{{UserID: 1, Role: models.HRPartySubject}}}, "head@example.com"))
+
+	_, err := s.Get(ctx, 1, "HR@example.com")
+	assert.ErrorIs(t, err, models.ErrPermissionDenied)
+	_, err = s.AddNote(ctx, 1, "Interviewed the subject", "hr@example.com")
+	assert.ErrorIs(t, err, models.ErrPermissionDenied)
+
+	cases, err := s.List(ctx, "", "", "hr@example.com")
+	require.NoError(t, err)
+	require.Len(t, cases, 1)
+	assert.Equal(t, 2, cases[0].ID)
+}
+
+func TestMoveFollowsTheWorkflow(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	s := NewService(store)
+	require.NoError(t, s.Open(ctx, &models.HRCase{Type: models.HRCaseDisciplinary, Summary: "Late",
+		Parties: []models.HRCaseParty{{UserID: 1, Role: models.HRPartySubject}}}, "hr@example.com"))
+
+	_, err := s.Move(ctx, 1, models.HRCaseClosed, "", "hr@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict, "open cases are resolved before they are closed")
+	_, err = s.Move(ctx, 1, models.HRCaseResolved, " ", "hr@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "resolving needs an outcome")
+	_, err = s.Move(ctx, 1, models.HRCaseOpen, "", "hr@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	_, err = s.Move(ctx, 1, models.HRCaseResolved, "Written warning", "hr@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "open>resolved", store.moved)
+	_, err = s.Move(ctx, 1, models.HRCaseClosed, "", "hr@example.com")
+	require.NoError(t, err)
+	_, err = s.Move(ctx, 1, models.HRCaseInvestigating, "", "hr@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict, "closed cases are final")
+}
+
+func TestSummariseNamesNoOne(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	resolved := time.Date(2025, 2, 11, 9, 0, 0, 0, time.UTC)
+	cases := []models.HRCase{
+		{Type: models.HRCaseGrievance, Status: models.HRCaseResolved, OpenedAt: time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+			ResolvedAt: &resolved, Parties: []models.HRCaseParty{{UserID: 1, Role: models.HRPartyComplainant}}},
+		{Type: models.HRCaseDisciplinary, Status: models.HRCaseOpen, OpenedAt: time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC)},
+		{Type: models.HRCaseGrievance, Status: models.HRCaseInvestigating, OpenedAt: time.Date(2025, 3, 20, 9, 0, 0, 0, time.UTC)},
+	}
+
+	stats := Summarise(cases, from, to)
+	assert.Equal(t, 3, stats.Opened)
+	assert.Equal(t, map[string]int{models.HRCaseGrievance: 2, models.HRCaseDisciplinary: 1}, stats.ByType)
+	assert.Equal(t, map[string]int{models.HRCaseResolved: 1, models.HRCaseOpen: 1, models.HRCaseInvestigating: 1}, stats.ByStatus)
+	assert.Equal(t, []models.HRCaseMonth{{Month: "2025-01", Opened: 1}, {Month: "2025-02", Resolved: 1}, {Month: "2025-03", Opened: 2}},
+		stats.ByMonth)
+	assert.Equal(t, 11.0, stats.AverageDaysToResolve)
+}
@@ -0,0 +1,58 @@
+// Package httperr maps the domain errors of package models to HTTP responses, so every
+// handler answers the same kind of failure with the same status and error envelope (see
+// package respond).
+package httperr
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// statuses maps each kind of domain error to its HTTP status.
+var statuses = map[error]int{
+	models.ErrNotFound:         http.StatusNotFound,
+	models.ErrConflict:         http.StatusConflict,
+	models.ErrValidation:       http.StatusUnprocessableEntity,
+	models.ErrPermissionDenied: http.StatusForbidden,
+}
+
+// Status returns the HTTP status for err: 404, 409, 422 or 403 for domain errors, 503 for
+// a database statement that ran past its timeout, and 500 for anything else.
+func Status(err error) int {
+	if status, ok := statuses[models.Kind(err)]; ok {
+		return status
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// Write answers a request that failed with err. Domain errors are sent with their status
+// and message, and a *models.ValidationError with its fields as details. Other errors are
+// logged and answered with 500, or 503 for timeouts, and the fallback message, so SQL and
+// driver details are not shown to clients.
+//
+// Parameters:
+//   - w: The response writer.
+//   - err: The error returned by a store or service.
+//   - fallback: The message for unexpected errors, e.g. "Failed to update invoice".
+func Write(w http.ResponseWriter, err error, fallback string) {
+	var invalid *models.ValidationError
+	if errors.As(err, &invalid) {
+		respond.ErrorDetails(w, http.StatusUnprocessableEntity, "The request is invalid", invalid.Fields)
+		return
+	}
+	status := Status(err)
+	if status >= http.StatusInternalServerError {
+		log.Printf("%s: %v", fallback, err)
+		respond.Error(w, status, fallback)
+		return
+	}
+	respond.Error(w, status, err.Error())
+}
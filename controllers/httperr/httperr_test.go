@@ -0,0 +1,62 @@
+package httperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{models.ErrNotFound, http.StatusNotFound},
+		{models.NotFound("invoice %d not found", 7), http.StatusNotFound},
+		{fmt.Errorf("load invoice: %w", models.ErrNotFound), http.StatusNotFound},
+		{models.ErrDocumentLocked, http.StatusConflict},
+		{models.Conflict("duplicate key"), http.StatusConflict},
+		{&models.PostingError{Reason: "no customer"}, http.StatusUnprocessableEntity},
+		{models.Invalid("amount must be positive"), http.StatusUnprocessableEntity},
+		{models.PermissionDenied("only the creator may do this"), http.StatusForbidden},
+		{fmt.Errorf("load invoice: %w", context.DeadlineExceeded), http.StatusServiceUnavailable},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Status(tt.err), tt.err.Error())
+	}
+}
+
+func TestWrite(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Write(rr, models.NotFound("invoice %d not found", 7), "Failed to load invoice")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error": {"code": "not_found", "message": "invoice 7 not found"}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	Write(rr, errors.New("pq: relation \"invoices\" does not exist"), "Failed to load invoice")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "internal_error", "message": "Failed to load invoice"}}`, rr.Body.String(),
+		"Internal details are not sent")
+
+	rr = httptest.NewRecorder()
+	Write(rr, fmt.Errorf("database statement %w after 30s", context.DeadlineExceeded), "Failed to load invoice")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "service_unavailable", "message": "Failed to load invoice"}}`, rr.Body.String())
+}
+
+func TestWriteValidationError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Write(rr, &models.ValidationError{Fields: []models.FieldError{{Field: "amount", Message: "must be greater than zero"}}}, "Failed to create bill")
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "validation_failed", "message": "The request is invalid",
+		"details": [{"field": "amount", "message": "must be greater than zero"}]}}`, rr.Body.String())
+}
@@ -0,0 +1,146 @@
+// Package imaging decodes uploaded images and generates thumbnails in the standard sizes
+// used by the storefront and frontend.
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	"image/png"
+)
+
+// Size is a named thumbnail size. Thumbnails fit inside a MaxDimension square and keep
+// the aspect ratio of the original.
+type Size struct {
+	Name         string
+	MaxDimension int
+}
+
+// StandardSizes are generated for every uploaded image.
+var StandardSizes = []Size{
+	{Name: "small", MaxDimension: 150},
+	{Name: "medium", MaxDimension: 400},
+	{Name: "large", MaxDimension: 800},
+}
+
+// SupportedContentTypes maps accepted upload content types to their file extensions.
+var SupportedContentTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Thumbnail is a generated thumbnail, encoded as JPEG or PNG.
+type Thumbnail struct {
+	Size        Size
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Decode parses an image and returns it with its format ("jpeg", "png" or "gif").
+func Decode(data []byte) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported or corrupt image: %w", err)
+	}
+	return img, format, nil
+}
+
+// Thumbnails generates one thumbnail per size. Images are never scaled up. PNG and GIF
+// sources produce PNG thumbnails to keep transparency; JPEG sources produce JPEG thumbnails.
+//
+// Parameters:
+//   - img: The decoded source image.
+//   - format: The source format returned by Decode.
+//   - sizes: The sizes to generate.
+//
+// Returns:
+//   - []Thumbnail: The encoded thumbnails, in the order of sizes.
+//   - error: An error if encoding fails.
+func Thumbnails(img image.Image, format string, sizes []Size) ([]Thumbnail, error) {
+	thumbnails := make([]Thumbnail, 0, len(sizes))
+	for _, size := range sizes {
+		width, height := Fit(img.Bounds().Dx(), img.Bounds().Dy(), size.MaxDimension)
+		scaled := Resize(img, width, height)
+
+		var buf bytes.Buffer
+		contentType := "image/jpeg"
+		var err error
+		if format == "jpeg" {
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85})
+		} else {
+			contentType = "image/png"
+			err = png.Encode(&buf, scaled)
+		}
+		if err != nil {
+			return nil, err
+		}
+		thumbnails = append(thumbnails, Thumbnail{
+			Size:        size,
+			Data:        buf.Bytes(),
+			ContentType: contentType,
+			Width:       width,
+			Height:      height,
+		})
+	}
+	return thumbnails, nil
+}
+
+// Fit returns the dimensions of a width×height image scaled to fit inside a max×max
+// square, keeping the aspect ratio. Images that already fit are returned unchanged.
+func Fit(width, height, max int) (int, int) {
+	if width <= max && height <= max {
+		return width, height
+	}
+	if width >= height {
+		h := height * max / width
+		if h < 1 {
+			h = 1
+		}
+		return max, h
+	}
+	w := width * max / height
+	if w < 1 {
+		w = 1
+	}
+	return w, max
+}
+
+// Resize scales img to width×height by averaging the source pixels that fall into each
+// destination pixel (box filter), which gives smooth results when shrinking.
+func Resize(img image.Image, width, height int) *image.RGBA {
+	src := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := src.Min.Y + y*src.Dy()/height
+		y1 := src.Min.Y + (y+1)*src.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := src.Min.X + x*src.Dx()/width
+			x1 := src.Min.X + (x+1)*src.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,61 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFit(t *testing.T) {
+	w, h := Fit(1600, 900, 400)
+	assert.Equal(t, 400, w)
+	assert.Equal(t, 225, h)
+
+	w, h = Fit(300, 1200, 150)
+	assert.Equal(t, 37, w)
+	assert.Equal(t, 150, h)
+
+	// Small images are not scaled up
+	w, h = Fit(100, 80, 400)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 80, h)
+}
+
+func TestThumbnails(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for y := 0; y < 500; y++ {
+		for x := 0; x < 1000; x++ {
+			src.Set(x, y, color.RGBA{R: 200, G: 10, B: 10, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, src))
+
+	img, format, err := Decode(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "png", format)
+
+	thumbnails, err := Thumbnails(img, format, StandardSizes)
+	assert.NoError(t, err)
+	assert.Len(t, thumbnails, len(StandardSizes))
+
+	small := thumbnails[0]
+	assert.Equal(t, "image/png", small.ContentType)
+	assert.Equal(t, 150, small.Width)
+	assert.Equal(t, 75, small.Height)
+
+	decoded, _, err := Decode(small.Data)
+	assert.NoError(t, err)
+	r, g, _, _ := decoded.At(10, 10).RGBA()
+	assert.Equal(t, uint32(200), r>>8)
+	assert.Equal(t, uint32(10), g>>8)
+}
+
+func TestDecodeRejectsNonImages(t *testing.T) {
+	_, _, err := Decode([]byte("not an image"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,309 @@
+// Package installments splits what is owed on posted invoices into installment schedules.
+// Payments and deposits on an invoice settle its installments in due date order, so each
+// installment's status follows from the amount settled on the invoice. Customers are
+// reminded of each installment around its due date, and the open schedules give the cash
+// expected in the coming months.
+package installments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/models"
+)
+
+// ReminderTemplate is the email template reminders are rendered from.
+const ReminderTemplate = "installment_reminder"
+
+// OverdueMonth is the month of the forecast line holding installments already past due.
+const OverdueMonth = "overdue"
+
+// Schedule describes the installments of a new plan: either the installments themselves,
+// or a count of equal installments due at an interval from a first due date.
+type Schedule struct {
+	Installments   []models.Installment `json:"installments"` // Due date and amount of each installment
+	Count          int                  `json:"count"`
+	FirstDueDate   time.Time            `json:"first_due_date"`
+	IntervalMonths int                  `json:"interval_months"` // 1 if not given
+}
+
+// Service creates installment plans, reminds customers and forecasts inflows.
+type Service struct {
+	Store        models.InstallmentStore
+	Templates    *mailtemplates.Service                              // Renders reminders; nil disables them
+	Send         func(ctx context.Context, msg mailer.Message) error // Queues an email, e.g. in the outbox
+	ReminderDays []int                                               // Days relative to the due date reminders are sent on
+	Now          func() time.Time                                    // Clock, replaced in tests
+}
+
+// NewService creates an installment service.
+func NewService(store models.InstallmentStore, templates *mailtemplates.Service, send func(ctx context.Context, msg mailer.Message) error, reminderDays []int) *Service {
+	return &Service{Store: store, Templates: templates, Send: send, ReminderDays: reminderDays, Now: time.Now}
+}
+
+// CreatePlan splits what is owed on a posted invoice into installments. The installments
+// must add up to the amount owed.
+//
+// Parameters:
+//   - invoiceID: The invoice.
+//   - schedule: The installments, or how to split the amount owed into equal ones.
+//   - actor: Email of the user creating the plan.
+//
+// Returns:
+//   - *models.InstallmentPlan: The plan with each installment's status.
+//   - error: models.ErrNotFound if the invoice does not exist, a validation error if the
+//     invoice is not posted, nothing is owed or the schedule is invalid, a conflict if the
+//     invoice already has a plan, or the store's error.
+func (s *Service) CreatePlan(ctx context.Context, invoiceID int, schedule Schedule, actor string) (*models.InstallmentPlan, error) {
+	balance, err := s.Store.GetInvoiceBalance(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Status != models.InvoiceStatusPosted {
+		return nil, models.Invalid("invoice %d must be posted before it is split into installments", invoiceID)
+	}
+	owed := roundCents(balance.Amount - balance.Settled)
+	if owed <= 0 {
+		return nil, models.Invalid("invoice %d has nothing left to pay", invoiceID)
+	}
+
+	installments := schedule.Installments
+	if len(installments) == 0 {
+		if schedule.Count < 2 {
+			return nil, models.Invalid("give the installments, or a count of at least 2 and a first_due_date")
+		}
+		if schedule.FirstDueDate.IsZero() {
+			return nil, models.Invalid("first_due_date is required")
+		}
+		if schedule.IntervalMonths < 0 {
+			return nil, models.Invalid("interval_months must be positive")
+		}
+		if schedule.IntervalMonths == 0 {
+			schedule.IntervalMonths = 1
+		}
+		installments = Split(owed, schedule.Count, schedule.FirstDueDate, schedule.IntervalMonths)
+	}
+	if err := validate(installments, owed); err != nil {
+		return nil, err
+	}
+
+	now := s.Now()
+	plan := &models.InstallmentPlan{
+		InvoiceID:     invoiceID,
+		CustomerID:    balance.CustomerID,
+		Total:         owed,
+		SettledBefore: balance.Settled,
+		Settled:       balance.Settled,
+		Installments:  installments,
+		CreatedBy:     actor,
+		CreatedAt:     now,
+	}
+	if err := s.Store.CreateInstallmentPlan(ctx, plan); err != nil {
+		return nil, err
+	}
+	Allocate(plan, now)
+	return plan, nil
+}
+
+// validate checks a schedule against the amount owed, sorts it by due date and numbers it.
+func validate(installments []models.Installment, owed float64) error {
+	sort.SliceStable(installments, func(i, j int) bool { return installments[i].DueDate.Before(installments[j].DueDate) })
+	total := 0.0
+	for i := range installments {
+		installment := &installments[i]
+		if installment.DueDate.IsZero() {
+			return models.Invalid("installment %d has no due_date", i+1)
+		}
+		if installment.Amount = roundCents(installment.Amount); installment.Amount <= 0 {
+			return models.Invalid("installment %d must have a positive amount", i+1)
+		}
+		if i > 0 && !installments[i-1].DueDate.Before(installment.DueDate) {
+			return models.Invalid("installments must be due on different dates")
+		}
+		installment.Number = i + 1
+		total += installment.Amount
+	}
+	if roundCents(total) != owed {
+		return models.Invalid("the installments add up to %.2f but %.2f is owed", total, owed)
+	}
+	return nil
+}
+
+// Plan returns the installment plan of an invoice with each installment's status.
+func (s *Service) Plan(ctx context.Context, invoiceID int) (*models.InstallmentPlan, error) {
+	plan, err := s.Store.GetInstallmentPlan(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	Allocate(plan, s.Now())
+	return plan, nil
+}
+
+// DeletePlan removes the installment plan of an invoice, e.g. to agree a new one.
+func (s *Service) DeletePlan(ctx context.Context, invoiceID int) error {
+	return s.Store.DeleteInstallmentPlan(ctx, invoiceID)
+}
+
+// Forecast totals the unpaid part of open installments by the month it is due. Installments
+// already past due are totalled on a line of their own, first.
+func (s *Service) Forecast(ctx context.Context) ([]models.CashInflow, error) {
+	plans, err := s.Store.GetOpenInstallmentPlans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.Now()
+	months := map[string]*models.CashInflow{}
+	for i := range plans {
+		Allocate(&plans[i], now)
+		for _, installment := range plans[i].Installments {
+			unpaid := roundCents(installment.Amount - installment.Paid)
+			if unpaid <= 0 {
+				continue
+			}
+			month := installment.DueDate.Format("2006-01")
+			if installment.Status == models.InstallmentOverdue {
+				month = OverdueMonth
+			}
+			if months[month] == nil {
+				months[month] = &models.CashInflow{Month: month}
+			}
+			months[month].Expected = roundCents(months[month].Expected + unpaid)
+			months[month].Installments++
+		}
+	}
+
+	forecast := []models.CashInflow{}
+	for _, inflow := range months {
+		forecast = append(forecast, *inflow)
+	}
+	sort.Slice(forecast, func(i, j int) bool {
+		if forecast[i].Month == OverdueMonth || forecast[j].Month == OverdueMonth {
+			return forecast[i].Month == OverdueMonth
+		}
+		return forecast[i].Month < forecast[j].Month
+	})
+	return forecast, nil
+}
+
+// Remind emails customers about unpaid installments. An installment is reminded once per
+// reminder day reached: a few days before it is due and at intervals after. When several
+// reminder days have passed since the last run, only the latest is sent. Customers whose
+// contact is not an email address and invoices with an open dispute are skipped. It is run daily by the scheduler.
+//
+// Returns:
+//   - error: The errors of reminders that could not be sent, joined; the others are sent.
+func (s *Service) Remind(ctx context.Context) error {
+	if s.Templates == nil || len(s.ReminderDays) == 0 {
+		return nil
+	}
+	plans, err := s.Store.GetOpenInstallmentPlans(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.Now()
+	var errs []error
+	for i := range plans {
+		plan := &plans[i]
+		if plan.Disputed || !strings.Contains(plan.Contact, "@") {
+			continue
+		}
+		Allocate(plan, now)
+		for _, installment := range plan.Installments {
+			if installment.Status == models.InstallmentPaid {
+				continue
+			}
+			stage, ok := ReminderStage(installment.DueDate, now, s.ReminderDays)
+			if !ok || (installment.ReminderStage != nil && *installment.ReminderStage >= stage) {
+				continue
+			}
+			if err := s.remind(ctx, plan, installment, stage, now); err != nil {
+				errs = append(errs, fmt.Errorf("installment %d of invoice %d: %w", installment.Number, plan.InvoiceID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// remind records and sends one reminder.
+func (s *Service) remind(ctx context.Context, plan *models.InstallmentPlan, installment models.Installment, stage int, now time.Time) error {
+	marked, err := s.Store.MarkInstallmentReminded(ctx, installment.ID, stage, now)
+	if err != nil || !marked {
+		return err
+	}
+	msg, err := s.Templates.Email(ctx, ReminderTemplate, "", []string{plan.Contact}, map[string]string{
+		"customer":    plan.CustomerName,
+		"invoice":     fmt.Sprintf("#%d", plan.InvoiceID),
+		"installment": fmt.Sprintf("%d of %d", installment.Number, len(plan.Installments)),
+		"amount":      fmt.Sprintf("%.2f", installment.Amount-installment.Paid),
+		"due_date":    installment.DueDate.Format("2006-01-02"),
+	})
+	if err != nil {
+		return err
+	}
+	return s.Send(ctx, msg)
+}
+
+// ReminderStage returns the latest reminder day reached on a date for an installment due
+// on dueDate, and whether any has been reached.
+func ReminderStage(dueDate, now time.Time, reminderDays []int) (int, bool) {
+	today := date(now)
+	stage, reached := 0, false
+	for _, days := range reminderDays {
+		if !today.Before(date(dueDate).AddDate(0, 0, days)) && (!reached || days > stage) {
+			stage, reached = days, true
+		}
+	}
+	return stage, reached
+}
+
+// Allocate spreads what has been settled on the invoice since the plan was made over its
+// installments in due date order, and sets each installment's paid amount and status.
+func Allocate(plan *models.InstallmentPlan, now time.Time) {
+	remaining := math.Max(roundCents(plan.Settled-plan.SettledBefore), 0)
+	today := date(now)
+	for i := range plan.Installments {
+		installment := &plan.Installments[i]
+		installment.Paid = math.Min(installment.Amount, remaining)
+		remaining = roundCents(remaining - installment.Paid)
+		switch {
+		case installment.Paid >= installment.Amount:
+			installment.Status = models.InstallmentPaid
+		case date(installment.DueDate).Before(today):
+			installment.Status = models.InstallmentOverdue
+		case installment.Paid > 0:
+			installment.Status = models.InstallmentPartiallyPaid
+		default:
+			installment.Status = models.InstallmentDue
+		}
+	}
+}
+
+// Split divides an amount into count installments due every intervalMonths from the first
+// due date. The last installment takes up the rounding to cents.
+func Split(amount float64, count int, first time.Time, intervalMonths int) []models.Installment {
+	share := roundCents(amount / float64(count))
+	installments := make([]models.Installment, count)
+	for i := range installments {
+		installments[i] = models.Installment{DueDate: first.AddDate(0, i*intervalMonths, 0), Amount: share}
+	}
+	installments[count-1].Amount = roundCents(amount - share*float64(count-1))
+	return installments
+}
+
+// date returns the start of the day of t.
+func date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,205 @@
+package installments
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps one invoice balance and the plans in memory.
+type fakeStore struct {
+	balance  models.InvoiceBalance
+	plans    []models.InstallmentPlan
+	reminded map[int]int // Reminder stage by installment ID
+}
+
+func (f *fakeStore) GetInvoiceBalance(ctx context.Context, invoiceID int) (*models.InvoiceBalance, error) {
+	balance := f.balance
+	return &balance, nil
+}
+
+func (f *fakeStore) CreateInstallmentPlan(ctx context.Context, plan *models.InstallmentPlan) error {
+	plan.ID = len(f.plans) + 1
+	for i := range plan.Installments {
+		plan.Installments[i].ID = plan.ID*10 + i
+	}
+	f.plans = append(f.plans, *plan)
+	return nil
+}
+
+func (f *fakeStore) GetInstallmentPlan(ctx context.Context, invoiceID int) (*models.InstallmentPlan, error) {
+	return &f.plans[0], nil
+}
+
+func (f *fakeStore) DeleteInstallmentPlan(ctx context.Context, invoiceID int) error { return nil }
+
+func (f *fakeStore) GetOpenInstallmentPlans(ctx context.Context) ([]models.InstallmentPlan, error) {
+	return f.plans, nil
+}
+
+func (f *fakeStore) MarkInstallmentReminded(ctx context.Context, installmentID, stage int, at time.Time) (bool, error) {
+	if last, ok := f.reminded[installmentID]; ok && last >= stage {
+		return false, nil
+	}
+	f.reminded[installmentID] = stage
+	return true, nil
+}
+
+// templateStore holds the reminder template.
+type templateStore struct{}
+
+func (templateStore) ListCurrentTemplates(ctx context.Context) ([]models.EmailTemplate, error) {
+	return nil, nil
+}
+
+func (templateStore) GetTemplate(ctx context.Context, key, locale string, version int) (*models.EmailTemplate, error) {
+	return &models.EmailTemplate{Key: key, Locale: "en", Subject: "Invoice {{invoice}} ({{installment}})",
+		Body: "Dear {{customer}}, {{amount}} is due on {{due_date}}."}, nil
+}
+
+func (templateStore) ListTemplateVersions(ctx context.Context, key, locale string) ([]models.EmailTemplate, error) {
+	return nil, nil
+}
+
+func (templateStore) AddTemplateVersion(ctx context.Context, template *models.EmailTemplate) error {
+	return nil
+}
+
+func day(month time.Month, d int) time.Time { return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC) }
+
+func newTestService(now time.Time) (*Service, *fakeStore, *[]mailer.Message) {
+	store := &fakeStore{
+		balance:  models.InvoiceBalance{InvoiceID: 7, CustomerID: 3, Status: models.InvoiceStatusPosted, Amount: 1000, Settled: 100},
+		reminded: map[int]int{},
+	}
+	var sent []mailer.Message
+	service := NewService(store, mailtemplates.NewService(templateStore{}, "en"), func(ctx context.Context, msg mailer.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}, []int{-3, 1, 7})
+	service.Now = func() time.Time { return now }
+	return service, store, &sent
+}
+
+func TestCreatePlanSplitsWhatIsOwed(t *testing.T) {
+	service, _, _ := newTestService(day(5, 1))
+	plan, err := service.CreatePlan(context.Background(), 7, Schedule{Count: 3, FirstDueDate: day(5, 31)}, "sales@example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, 900.0, plan.Total, "the deposit already applied is not scheduled")
+	require.Len(t, plan.Installments, 3)
+	assert.Equal(t, []float64{300, 300, 300},
+		[]float64{plan.Installments[0].Amount, plan.Installments[1].Amount, plan.Installments[2].Amount})
+	assert.Equal(t, day(7, 1), plan.Installments[1].DueDate, "June has no 31st")
+	assert.Equal(t, models.InstallmentDue, plan.Installments[0].Status)
+
+	installments := Split(100, 3, day(1, 15), 2)
+	assert.Equal(t, 33.33, installments[0].Amount)
+	assert.Equal(t, 33.34, installments[2].Amount)
+	assert.Equal(t, day(5, 15), installments[2].DueDate)
+}
+
+func TestCreatePlanValidation(t *testing.T) {
+	ctx := context.Background()
+	service, store, _ := newTestService(day(5, 1))
+	invalid := map[string]Schedule{
+		"nothing given":  {},
+		"one":            {Count: 1, FirstDueDate: day(6, 1)},
+		"no first date":  {Count: 2},
+		"wrong total":    {Installments: []models.Installment{{DueDate: day(6, 1), Amount: 400}, {DueDate: day(7, 1), Amount: 400}}},
+		"same date":      {Installments: []models.Installment{{DueDate: day(6, 1), Amount: 450}, {DueDate: day(6, 1), Amount: 450}}},
+		"no amount":      {Installments: []models.Installment{{DueDate: day(6, 1), Amount: 900}, {DueDate: day(7, 1)}}},
+		"negative month": {Count: 2, FirstDueDate: day(6, 1), IntervalMonths: -1},
+	}
+	for name, schedule := range invalid {
+		_, err := service.CreatePlan(ctx, 7, schedule, "sales@example.com")
+		assert.ErrorIs(t, err, models.ErrValidation, name)
+	}
+
+	store.balance.Status = models.InvoiceStatusDraft
+	_, err := service.CreatePlan(ctx, 7, Schedule{Count: 2, FirstDueDate: day(6, 1)}, "sales@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "draft invoices cannot be split")
+
+	store.balance.Status, store.balance.Settled = models.InvoiceStatusPosted, 1000
+	_, err = service.CreatePlan(ctx, 7, Schedule{Count: 2, FirstDueDate: day(6, 1)}, "sales@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "paid invoices cannot be split")
+	assert.Empty(t, store.plans)
+}
+
+func TestAllocate(t *testing.T) {
+	plan := &models.InstallmentPlan{SettledBefore: 100, Settled: 550, Installments: []models.Installment{
+		{DueDate: day(5, 1), Amount: 300},
+		{DueDate: day(6, 1), Amount: 300},
+		{DueDate: day(7, 1), Amount: 300},
+	}}
+	Allocate(plan, day(6, 10))
+	assert.Equal(t, models.InstallmentPaid, plan.Installments[0].Status)
+	assert.Equal(t, 150.0, plan.Installments[1].Paid)
+	assert.Equal(t, models.InstallmentOverdue, plan.Installments[1].Status)
+	assert.Equal(t, models.InstallmentDue, plan.Installments[2].Status)
+
+	Allocate(plan, day(5, 20))
+	assert.Equal(t, models.InstallmentPartiallyPaid, plan.Installments[1].Status)
+}
+
+func TestForecast(t *testing.T) {
+	service, store, _ := newTestService(day(6, 10))
+	store.plans = []models.InstallmentPlan{
+		{SettledBefore: 0, Settled: 400, Installments: []models.Installment{
+			{DueDate: day(5, 1), Amount: 300}, {DueDate: day(6, 1), Amount: 300}, {DueDate: day(7, 1), Amount: 300},
+		}},
+		{Installments: []models.Installment{{DueDate: day(7, 15), Amount: 50}, {DueDate: day(8, 15), Amount: 50}}},
+	}
+
+	forecast, err := service.Forecast(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.CashInflow{
+		{Month: OverdueMonth, Expected: 200, Installments: 1},
+		{Month: "2024-07", Expected: 350, Installments: 2},
+		{Month: "2024-08", Expected: 50, Installments: 1},
+	}, forecast)
+}
+
+func TestReminderStage(t *testing.T) {
+	days := []int{-3, 1, 7}
+	_, ok := ReminderStage(day(6, 10), day(6, 6), days)
+	assert.False(t, ok)
+	stage, ok := ReminderStage(day(6, 10), day(6, 7), days)
+	assert.True(t, ok)
+	assert.Equal(t, -3, stage)
+	stage, _ = ReminderStage(day(6, 10), day(6, 10), days)
+	assert.Equal(t, -3, stage, "no reminder is due on the due date itself")
+	stage, _ = ReminderStage(day(6, 10), day(6, 30), days)
+	assert.Equal(t, 7, stage, "only the latest stage reached counts")
+}
+
+func TestRemind(t *testing.T) {
+	ctx := context.Background()
+	service, store, sent := newTestService(day(6, 8))
+	store.plans = []models.InstallmentPlan{
+		{ID: 1, InvoiceID: 7, CustomerName: "Acme", Contact: "ap@acme.example", Settled: 300, Installments: []models.Installment{
+			{ID: 10, Number: 1, DueDate: day(6, 1), Amount: 300},
+			{ID: 11, Number: 2, DueDate: day(6, 10), Amount: 300},
+			{ID: 12, Number: 3, DueDate: day(7, 10), Amount: 300},
+		}},
+		{ID: 2, InvoiceID: 8, Contact: "+1 555 0100", Installments: []models.Installment{{ID: 20, Number: 1, DueDate: day(6, 1), Amount: 50}}},
+	}
+
+	require.NoError(t, service.Remind(ctx))
+	require.Len(t, *sent, 1, "paid installments, later ones and customers without an email address are not reminded")
+	msg := (*sent)[0]
+	assert.Equal(t, []string{"ap@acme.example"}, msg.To)
+	assert.Equal(t, "Invoice #7 (2 of 3)", msg.Subject)
+	assert.Equal(t, "Dear Acme, 300.00 is due on 2024-06-10.", msg.Body)
+	assert.Equal(t, map[int]int{11: -3}, store.reminded)
+
+	require.NoError(t, service.Remind(ctx))
+	assert.Len(t, *sent, 1, "a stage is reminded once")
+}
@@ -0,0 +1,99 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"erp/controllers/mailer"
+	"erp/controllers/outbox"
+	"erp/models"
+)
+
+// StockBelowReorderLevel is the event sent to webhooks when stock falls below its reorder
+// level.
+const StockBelowReorderLevel = "stock.below_reorder_level"
+
+// Notifier tells someone about stock that fell below its reorder level.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []models.StockAlert) error
+}
+
+// AlertService reports the stock below its reorder level and notifies when stock falls
+// below it.
+type AlertService struct {
+	Store     models.StockAlertStore
+	Notifiers []Notifier
+}
+
+// NewAlertService creates a low-stock alert service backed by store.
+func NewAlertService(store models.StockAlertStore, notifiers ...Notifier) *AlertService {
+	return &AlertService{Store: store, Notifiers: notifiers}
+}
+
+// Alerts returns the stock below its reorder level in a warehouse, or in every warehouse if
+// warehouseID is 0.
+func (s *AlertService) Alerts(ctx context.Context, warehouseID int) ([]models.StockAlert, error) {
+	return s.Store.ListStockAlerts(ctx, warehouseID)
+}
+
+// Check passes the stock that fell below its reorder level since the last check to every
+// notifier. Stock that stays low is notified once, and again only after it was restocked
+// and fell again. It is run by the scheduler.
+//
+// Returns:
+//   - error: The store error, or the joined errors of the notifiers that failed.
+func (s *AlertService) Check(ctx context.Context) error {
+	crossed, err := s.Store.MarkStockAlerts(ctx)
+	if err != nil || len(crossed) == 0 {
+		return err
+	}
+	log.Printf("inventory: %d stock records fell below their reorder level", len(crossed))
+	var errs []error
+	for _, notifier := range s.Notifiers {
+		if err := notifier.Notify(ctx, crossed); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EmailNotifier emails the list of stock that fell below its reorder level.
+type EmailNotifier struct {
+	Mailer mailer.Mailer
+	To     []string
+}
+
+// Notify sends one email listing the alerts.
+func (n *EmailNotifier) Notify(ctx context.Context, alerts []models.StockAlert) error {
+	var body strings.Builder
+	body.WriteString("The following stock fell below its reorder level:\n\n")
+	for _, a := range alerts {
+		fmt.Fprintf(&body, "- %s (product %d) at %s: %d left, reorder level %d\n",
+			a.ProductName, a.ProductID, a.WarehouseName, a.Quantity, a.ReorderLevel)
+	}
+	return n.Mailer.Send(ctx, mailer.Message{
+		To:      n.To,
+		Subject: fmt.Sprintf("Low stock: %d item(s) below reorder level", len(alerts)),
+		Body:    body.String(),
+	})
+}
+
+// WebhookNotifier posts the alerts to a URL through the outbox, signed with Secret if set.
+type WebhookNotifier struct {
+	DB     outbox.Queryer
+	URL    string
+	Secret string
+}
+
+// Notify queues one webhook with the event name and the alerts.
+func (n *WebhookNotifier) Notify(ctx context.Context, alerts []models.StockAlert) error {
+	body, err := json.Marshal(map[string]interface{}{"event": StockBelowReorderLevel, "alerts": alerts})
+	if err != nil {
+		return err
+	}
+	return outbox.Enqueue(ctx, n.DB, outbox.KindWebhook, outbox.WebhookRequest{URL: n.URL, Body: body, Secret: n.Secret})
+}
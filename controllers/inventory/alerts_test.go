@@ -0,0 +1,152 @@
+package inventory
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"erp/controllers/mailer"
+	"erp/controllers/outbox"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAlertStore marks the stock records it was given, like the database does.
+type memoryAlertStore struct {
+	stock []models.StockAlert
+	below map[int]bool
+}
+
+func (m *memoryAlertStore) ListStockAlerts(ctx context.Context, warehouseID int) ([]models.StockAlert, error) {
+	alerts := []models.StockAlert{}
+	for _, s := range m.stock {
+		if s.Quantity < s.ReorderLevel && (warehouseID == 0 || s.WarehouseID == warehouseID) {
+			alerts = append(alerts, s)
+		}
+	}
+	return alerts, nil
+}
+
+func (m *memoryAlertStore) MarkStockAlerts(ctx context.Context) ([]models.StockAlert, error) {
+	crossed := []models.StockAlert{}
+	for _, s := range m.stock {
+		low := s.Quantity < s.ReorderLevel
+		if low && !m.below[s.StockID] {
+			crossed = append(crossed, s)
+		}
+		m.below[s.StockID] = low
+	}
+	return crossed, nil
+}
+
+// recordingNotifier keeps the alerts it is given.
+type recordingNotifier struct {
+	calls [][]models.StockAlert
+	err   error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alerts []models.StockAlert) error {
+	n.calls = append(n.calls, alerts)
+	return n.err
+}
+
+// recordingMailer keeps the messages it is given.
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestCheckNotifiesOncePerCrossing(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryAlertStore{below: map[int]bool{}, stock: []models.StockAlert{
+		{StockID: 1, ProductID: 7, Quantity: 2, ReorderLevel: 5},
+		{StockID: 2, ProductID: 8, Quantity: 9, ReorderLevel: 5},
+		{StockID: 3, ProductID: 9, Quantity: 0}, // Not watched
+	}}
+	notifier := &recordingNotifier{}
+	service := NewAlertService(store, notifier)
+
+	require.NoError(t, service.Check(ctx))
+	require.Len(t, notifier.calls, 1)
+	assert.Equal(t, 1, notifier.calls[0][0].StockID)
+
+	require.NoError(t, service.Check(ctx))
+	assert.Len(t, notifier.calls, 1, "stock that stays low is not notified again")
+
+	store.stock[0].Quantity = 6 // Restocked
+	require.NoError(t, service.Check(ctx))
+	store.stock[0].Quantity = 1
+	require.NoError(t, service.Check(ctx))
+	assert.Len(t, notifier.calls, 2, "stock that falls again is notified again")
+
+	alerts, err := service.Alerts(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1)
+}
+
+func TestCheckReportsNotifierErrors(t *testing.T) {
+	store := &memoryAlertStore{below: map[int]bool{}, stock: []models.StockAlert{{StockID: 1, Quantity: 0, ReorderLevel: 1}}}
+	failing := &recordingNotifier{err: errors.New("unreachable")}
+	working := &recordingNotifier{}
+
+	err := NewAlertService(store, failing, working).Check(context.Background())
+	assert.ErrorContains(t, err, "unreachable")
+	assert.Len(t, working.calls, 1, "a failing notifier does not stop the others")
+}
+
+func TestEmailNotifier(t *testing.T) {
+	mail := &recordingMailer{}
+	notifier := &EmailNotifier{Mailer: mail, To: []string{"buyer@example.com"}}
+
+	require.NoError(t, notifier.Notify(context.Background(), []models.StockAlert{
+		{ProductID: 7, ProductName: "Widget", WarehouseName: "Main", Quantity: 2, ReorderLevel: 10},
+	}))
+	require.Len(t, mail.sent, 1)
+	assert.Equal(t, []string{"buyer@example.com"}, mail.sent[0].To)
+	assert.Equal(t, "Low stock: 1 item(s) below reorder level", mail.sent[0].Subject)
+	assert.Contains(t, mail.sent[0].Body, "- Widget (product 7) at Main: 2 left, reorder level 10")
+}
+
+// capturedWebhook decodes the outbox payload it is matched against.
+type capturedWebhook struct {
+	request outbox.WebhookRequest
+}
+
+func (c *capturedWebhook) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	return ok && json.Unmarshal(data, &c.request) == nil
+}
+
+func TestWebhookNotifierQueuesInOutbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	queued := &capturedWebhook{}
+	mock.ExpectQuery("INSERT INTO outbox_messages").
+		WithArgs(outbox.KindWebhook, queued, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	notifier := &WebhookNotifier{DB: db, URL: "https://hooks.example.com/stock", Secret: "s3cret"}
+	require.NoError(t, notifier.Notify(context.Background(), []models.StockAlert{{StockID: 1, Quantity: 0, ReorderLevel: 4, Shortfall: 4}}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, "https://hooks.example.com/stock", queued.request.URL)
+	assert.Equal(t, "s3cret", queued.request.Secret)
+	var body struct {
+		Event  string              `json:"event"`
+		Alerts []models.StockAlert `json:"alerts"`
+	}
+	require.NoError(t, json.Unmarshal(queued.request.Body, &body))
+	assert.Equal(t, StockBelowReorderLevel, body.Event)
+	assert.Equal(t, 4, body.Alerts[0].Shortfall)
+}
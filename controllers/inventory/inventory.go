@@ -0,0 +1,67 @@
+// Package inventory holds the stock business rules, so HTTP handlers, jobs and command
+// line tools record stock the same way. The store writes each stock change together with
+// its StockMoved outbox event in one transaction.
+package inventory
+
+import (
+	"context"
+	"strings"
+
+	"erp/controllers/validation"
+	"erp/models"
+)
+
+// Service applies the stock rules on top of a StockStore.
+type Service struct {
+	Store models.StockStore
+}
+
+// NewService creates an inventory service backed by store.
+func NewService(store models.StockStore) *Service {
+	return &Service{Store: store}
+}
+
+// Validate checks that stock names a product and a warehouse and does not hold a negative
+// quantity or reorder level. It returns a *models.ValidationError listing the fields that
+// break a rule otherwise.
+func Validate(stock *models.Stock) error {
+	var c validation.Checker
+	c.ID("product_id", stock.ProductID)
+	c.ID("warehouse_id", stock.WarehouseID)
+	c.NotNegative("quantity", float64(stock.Quantity))
+	c.NotNegative("reorder_level", float64(stock.ReorderLevel))
+	return c.Err()
+}
+
+// Create records a new stock entry after validating it.
+func (s *Service) Create(ctx context.Context, stock *models.Stock) error {
+	stock.Location = strings.TrimSpace(stock.Location)
+	if err := Validate(stock); err != nil {
+		return err
+	}
+	return s.Store.CreateStock(ctx, stock)
+}
+
+// GetByProduct returns the stock entry of a product.
+func (s *Service) GetByProduct(ctx context.Context, productID int) (*models.Stock, error) {
+	return s.Store.GetStockByProductID(ctx, productID)
+}
+
+// List returns a page of stock entries and the number matching the filters.
+func (s *Service) List(ctx context.Context, query models.ListQuery) ([]models.Stock, int, error) {
+	return s.Store.ListStock(ctx, query)
+}
+
+// Update changes an existing stock entry after validating it.
+func (s *Service) Update(ctx context.Context, stock *models.Stock) error {
+	stock.Location = strings.TrimSpace(stock.Location)
+	if err := Validate(stock); err != nil {
+		return err
+	}
+	return s.Store.UpdateStock(ctx, stock)
+}
+
+// Delete removes the stock entry with the given ID.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	return s.Store.DeleteStock(ctx, id)
+}
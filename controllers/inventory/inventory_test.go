@@ -0,0 +1,94 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStockStore keeps stock entries in memory.
+type memoryStockStore struct {
+	stock map[int]models.Stock
+}
+
+func (m *memoryStockStore) CreateStock(ctx context.Context, stock *models.Stock) error {
+	stock.ID = len(m.stock) + 1
+	m.stock[stock.ID] = *stock
+	return nil
+}
+
+func (m *memoryStockStore) GetStockByProductID(ctx context.Context, productID int) (*models.Stock, error) {
+	for _, stock := range m.stock {
+		if stock.ProductID == productID {
+			return &stock, nil
+		}
+	}
+	return nil, models.NotFound("no stock found for product ID %d", productID)
+}
+
+func (m *memoryStockStore) UpdateStock(ctx context.Context, stock *models.Stock) error {
+	if _, ok := m.stock[stock.ID]; !ok {
+		return models.NotFound("stock with ID %d not found", stock.ID)
+	}
+	m.stock[stock.ID] = *stock
+	return nil
+}
+
+func (m *memoryStockStore) ListStock(ctx context.Context, query models.ListQuery) ([]models.Stock, int, error) {
+	stock := []models.Stock{}
+	for _, entry := range m.stock {
+		stock = append(stock, entry)
+	}
+	return stock, len(stock), nil
+}
+
+func (m *memoryStockStore) DeleteStock(ctx context.Context, id int) error {
+	delete(m.stock, id)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		stock models.Stock
+		valid bool
+	}{
+		{"valid", models.Stock{ProductID: 1, WarehouseID: 1, Quantity: 5}, true},
+		{"empty bin", models.Stock{ProductID: 1, WarehouseID: 1}, true},
+		{"no product", models.Stock{WarehouseID: 1, Quantity: 5}, false},
+		{"no warehouse", models.Stock{ProductID: 1, Quantity: 5}, false},
+		{"negative quantity", models.Stock{ProductID: 1, WarehouseID: 1, Quantity: -1}, false},
+		{"reorder level", models.Stock{ProductID: 1, WarehouseID: 1, Quantity: 5, ReorderLevel: 10}, true},
+		{"negative reorder level", models.Stock{ProductID: 1, WarehouseID: 1, ReorderLevel: -1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.stock)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, models.ErrValidation)
+			}
+		})
+	}
+}
+
+func TestServiceRejectsInvalidStock(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStockStore{stock: map[int]models.Stock{}}
+	service := NewService(store)
+
+	assert.ErrorIs(t, service.Create(ctx, &models.Stock{ProductID: 1, WarehouseID: 1, Quantity: -3}), models.ErrValidation)
+	assert.Empty(t, store.stock, "Invalid stock is never stored")
+
+	stock := models.Stock{ProductID: 1, WarehouseID: 1, Quantity: 3, Location: " A1 "}
+	assert.NoError(t, service.Create(ctx, &stock))
+	assert.Equal(t, "A1", store.stock[stock.ID].Location)
+
+	stock.Quantity = -1
+	assert.ErrorIs(t, service.Update(ctx, &stock), models.ErrValidation)
+	assert.Equal(t, 3, store.stock[stock.ID].Quantity)
+}
@@ -0,0 +1,233 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// PickService plans the order pick lists are walked in. Each warehouse has a layout naming
+// the order its zones are visited in and how pickers go through aisles; warehouses without
+// one are walked zone by zone and aisle by aisle in name order, in a serpentine.
+type PickService struct {
+	Store models.PickStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewPickService creates a pick route service backed by store.
+func NewPickService(store models.PickStore) *PickService {
+	return &PickService{Store: store, Now: time.Now}
+}
+
+// Layout returns the layout of a warehouse, or the default one if none was saved.
+func (s *PickService) Layout(ctx context.Context, warehouseID int) (*models.PickLayout, error) {
+	layout, err := s.Store.GetPickLayout(ctx, warehouseID)
+	if errors.Is(err, models.ErrNotFound) {
+		return &models.PickLayout{WarehouseID: warehouseID, Zones: []string{}, Traversal: models.TraversalSerpentine}, nil
+	}
+	return layout, err
+}
+
+// SaveLayout validates and saves the layout of a warehouse.
+//
+// Returns:
+//   - error: A validation error if the traversal is unknown, a zone is blank or listed twice,
+//     or the warehouse does not exist, or the store error.
+func (s *PickService) SaveLayout(ctx context.Context, layout *models.PickLayout, actor string) error {
+	if layout.WarehouseID <= 0 {
+		return models.Invalid("warehouse_id is required")
+	}
+	if layout.Traversal == "" {
+		layout.Traversal = models.TraversalSerpentine
+	}
+	if layout.Traversal != models.TraversalSerpentine && layout.Traversal != models.TraversalReturn {
+		return models.Invalid("traversal must be %s or %s", models.TraversalSerpentine, models.TraversalReturn)
+	}
+	zones := make([]string, 0, len(layout.Zones))
+	seen := map[string]bool{}
+	for _, zone := range layout.Zones {
+		zone = strings.TrimSpace(zone)
+		if zone == "" {
+			return models.Invalid("zones cannot be blank")
+		}
+		if seen[zone] {
+			return models.Invalid("zone %q is listed twice", zone)
+		}
+		seen[zone] = true
+		zones = append(zones, zone)
+	}
+	layout.Zones = zones
+	layout.UpdatedBy, layout.UpdatedAt = actor, s.Now()
+	return s.Store.SavePickLayout(ctx, layout)
+}
+
+// Route plans the order to pick a list in. Lines for the same product are merged.
+//
+// Returns:
+//   - *models.PickRoute: The stops in walking order, and the units no bin holds.
+//   - error: A validation error if the request is incomplete, or the store error.
+func (s *PickService) Route(ctx context.Context, req models.PickRequest) (*models.PickRoute, error) {
+	if req.WarehouseID <= 0 {
+		return nil, models.Invalid("warehouse_id is required")
+	}
+	if len(req.Lines) == 0 {
+		return nil, models.Invalid("at least one line is required")
+	}
+	var lines []models.PickLine
+	index := map[int]int{}
+	for i, line := range req.Lines {
+		if line.ProductID <= 0 || line.Quantity <= 0 {
+			return nil, models.Invalid("line %d needs a product and a positive quantity", i+1)
+		}
+		if j, ok := index[line.ProductID]; ok {
+			lines[j].Quantity += line.Quantity
+			continue
+		}
+		index[line.ProductID] = len(lines)
+		lines = append(lines, line)
+	}
+
+	layout, err := s.Layout(ctx, req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	productIDs := make([]int, len(lines))
+	for i, line := range lines {
+		productIDs[i] = line.ProductID
+	}
+	locations, err := s.Store.ListPickLocations(ctx, req.WarehouseID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	return PickRoute(*layout, lines, locations), nil
+}
+
+// PickRoute chooses the bins to pick lines from and orders the stops. It is the pure part
+// of Route.
+//
+// A line is taken from the first bin in walking order that holds all of it; otherwise from
+// the bins holding the most until it is covered, to make as few stops as possible. The
+// stops are then walked zone by zone in the layout's order and aisle by aisle, and along
+// each aisle from the front, or for a serpentine from the far end on every other aisle
+// entered.
+func PickRoute(layout models.PickLayout, lines []models.PickLine, locations []models.PickLocation) *models.PickRoute {
+	zoneRank := map[string]int{}
+	for i, zone := range layout.Zones {
+		zoneRank[zone] = i
+	}
+	aisleBefore := func(a, b models.Bin) bool {
+		ra, oka := zoneRank[a.Zone]
+		rb, okb := zoneRank[b.Zone]
+		switch {
+		case oka != okb:
+			return oka
+		case oka && ra != rb:
+			return ra < rb
+		case a.Zone != b.Zone:
+			return a.Zone < b.Zone
+		}
+		return naturalLess(a.Aisle, b.Aisle)
+	}
+	walk := func(list []models.PickLocation, reversed func(models.Bin) bool) {
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := list[i].Bin, list[j].Bin
+			if aisleBefore(a, b) || aisleBefore(b, a) {
+				return aisleBefore(a, b)
+			}
+			if a.Position != b.Position {
+				return (a.Position < b.Position) != reversed(a)
+			}
+			if a.Code != b.Code {
+				return a.Code < b.Code
+			}
+			return list[i].ProductID < list[j].ProductID
+		})
+	}
+
+	// Choose the bins in plain front-to-back order first
+	ordered := append([]models.PickLocation(nil), locations...)
+	walk(ordered, func(models.Bin) bool { return false })
+	byProduct := map[int][]models.PickLocation{}
+	for _, l := range ordered {
+		if l.Quantity > 0 {
+			byProduct[l.ProductID] = append(byProduct[l.ProductID], l)
+		}
+	}
+	route := &models.PickRoute{WarehouseID: layout.WarehouseID, Traversal: layout.Traversal,
+		Tasks: []models.PickTask{}, Short: []models.PickLine{}}
+	var stops []models.PickLocation
+	for _, line := range lines {
+		candidates := byProduct[line.ProductID]
+		chosen := -1
+		for i, l := range candidates {
+			if l.Quantity >= line.Quantity {
+				chosen = i
+				break
+			}
+		}
+		if chosen >= 0 {
+			stop := candidates[chosen]
+			stop.Quantity = line.Quantity
+			stops = append(stops, stop)
+			continue
+		}
+		largest := append([]models.PickLocation(nil), candidates...)
+		sort.SliceStable(largest, func(i, j int) bool { return largest[i].Quantity > largest[j].Quantity })
+		remaining := line.Quantity
+		for _, stop := range largest {
+			if remaining == 0 {
+				break
+			}
+			stop.Quantity = min(stop.Quantity, remaining)
+			remaining -= stop.Quantity
+			stops = append(stops, stop)
+		}
+		if remaining > 0 {
+			route.Short = append(route.Short, models.PickLine{ProductID: line.ProductID, Quantity: remaining})
+		}
+	}
+
+	// Number the aisles entered in walking order to know which way each is walked
+	walk(stops, func(models.Bin) bool { return false })
+	type aisle struct{ zone, name string }
+	entered := map[aisle]int{}
+	for _, stop := range stops {
+		key := aisle{stop.Zone, stop.Aisle}
+		if _, ok := entered[key]; !ok {
+			entered[key] = len(entered)
+		}
+	}
+	if layout.Traversal == models.TraversalSerpentine {
+		walk(stops, func(bin models.Bin) bool { return entered[aisle{bin.Zone, bin.Aisle}]%2 == 1 })
+	}
+
+	bins := map[int]bool{}
+	for i, stop := range stops {
+		route.Tasks = append(route.Tasks, models.PickTask{
+			Sequence: i + 1, BinID: stop.ID, Code: stop.Code, Zone: stop.Zone, Aisle: stop.Aisle,
+			Position: stop.Position, ProductID: stop.ProductID, Quantity: stop.Quantity,
+		})
+		bins[stop.ID] = true
+	}
+	route.Stops, route.Aisles = len(bins), len(entered)
+	return route
+}
+
+// naturalLess orders aisle names, comparing names that are whole numbers by value so that
+// aisle 2 comes before aisle 10.
+func naturalLess(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return na < nb
+	case (errA == nil) != (errB == nil):
+		return errA == nil
+	}
+	return a < b
+}
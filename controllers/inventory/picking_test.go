@@ -0,0 +1,146 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPickStore returns a fixed layout and locations.
+type memoryPickStore struct {
+	layout    *models.PickLayout
+	locations []models.PickLocation
+	products  []int
+}
+
+func (m *memoryPickStore) GetPickLayout(ctx context.Context, warehouseID int) (*models.PickLayout, error) {
+	if m.layout == nil {
+		return nil, models.NotFound("warehouse %d has no pick layout", warehouseID)
+	}
+	return m.layout, nil
+}
+
+func (m *memoryPickStore) SavePickLayout(ctx context.Context, layout *models.PickLayout) error {
+	m.layout = layout
+	return nil
+}
+
+func (m *memoryPickStore) ListPickLocations(ctx context.Context, warehouseID int, productIDs []int) ([]models.PickLocation, error) {
+	m.products = productIDs
+	return m.locations, nil
+}
+
+// at is the stock of a product in a bin.
+func at(id int, zone, aisle string, position, productID, quantity int) models.PickLocation {
+	return models.PickLocation{
+		Bin: models.Bin{ID: id, WarehouseID: 1, Code: fmt.Sprintf("%s%s-%d", zone, aisle, position),
+			Zone: zone, Aisle: aisle, Position: position},
+		ProductID: productID,
+		Quantity:  quantity,
+	}
+}
+
+func taskBins(route *models.PickRoute) []int {
+	var ids []int
+	for _, task := range route.Tasks {
+		ids = append(ids, task.BinID)
+	}
+	return ids
+}
+
+func TestPickRouteSerpentine(t *testing.T) {
+	locations := []models.PickLocation{
+		at(1, "A", "1", 1, 10, 5),
+		at(2, "A", "1", 4, 11, 5),
+		at(3, "A", "2", 1, 12, 5),
+		at(4, "A", "2", 3, 13, 5),
+		at(5, "A", "10", 2, 14, 5),
+		at(6, "B", "1", 2, 15, 5),
+	}
+	lines := []models.PickLine{
+		{ProductID: 15, Quantity: 1}, {ProductID: 14, Quantity: 1}, {ProductID: 13, Quantity: 1},
+		{ProductID: 12, Quantity: 1}, {ProductID: 11, Quantity: 1}, {ProductID: 10, Quantity: 1},
+	}
+
+	route := PickRoute(models.PickLayout{Traversal: models.TraversalSerpentine}, lines, locations)
+	assert.Equal(t, []int{1, 2, 4, 3, 5, 6}, taskBins(route), "aisle 2 walked back from the far end, aisle 10 after it")
+	assert.Equal(t, 6, route.Stops)
+	assert.Equal(t, 4, route.Aisles)
+	assert.Equal(t, 1, route.Tasks[0].Sequence)
+	assert.Empty(t, route.Short)
+
+	route = PickRoute(models.PickLayout{Traversal: models.TraversalReturn}, lines, locations)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, taskBins(route))
+
+	route = PickRoute(models.PickLayout{Zones: []string{"B"}, Traversal: models.TraversalSerpentine}, lines, locations)
+	assert.Equal(t, []int{6, 2, 1, 3, 4, 5}, taskBins(route), "listed zones first, then the others by name")
+}
+
+func TestPickRouteChoosesFewestStops(t *testing.T) {
+	locations := []models.PickLocation{
+		at(1, "A", "1", 1, 10, 3),
+		at(2, "A", "1", 2, 10, 8),
+		at(3, "A", "2", 1, 10, 6),
+		at(4, "A", "3", 1, 11, 2),
+	}
+
+	route := PickRoute(models.PickLayout{Traversal: models.TraversalReturn},
+		[]models.PickLine{{ProductID: 10, Quantity: 5}}, locations)
+	assert.Equal(t, []int{2}, taskBins(route), "the first bin holding all of it")
+
+	route = PickRoute(models.PickLayout{Traversal: models.TraversalReturn},
+		[]models.PickLine{{ProductID: 10, Quantity: 12}, {ProductID: 11, Quantity: 5}, {ProductID: 12, Quantity: 1}}, locations)
+	assert.Equal(t, []int{2, 3, 4}, taskBins(route), "the largest bins until covered")
+	assert.Equal(t, 8, route.Tasks[0].Quantity)
+	assert.Equal(t, 4, route.Tasks[1].Quantity)
+	assert.Equal(t, []models.PickLine{{ProductID: 11, Quantity: 3}, {ProductID: 12, Quantity: 1}}, route.Short)
+}
+
+func TestRouteMergesLinesAndUsesDefaultLayout(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryPickStore{locations: []models.PickLocation{at(1, "A", "1", 1, 10, 9)}}
+	service := NewPickService(store)
+
+	route, err := service.Route(ctx, models.PickRequest{WarehouseID: 1, Lines: []models.PickLine{
+		{ProductID: 10, Quantity: 2}, {ProductID: 10, Quantity: 3},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{10}, store.products)
+	assert.Equal(t, models.TraversalSerpentine, route.Traversal)
+	require.Len(t, route.Tasks, 1)
+	assert.Equal(t, 5, route.Tasks[0].Quantity)
+
+	_, err = service.Route(ctx, models.PickRequest{WarehouseID: 1})
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	_, err = service.Route(ctx, models.PickRequest{WarehouseID: 1, Lines: []models.PickLine{{ProductID: 10}}})
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
+
+func TestSaveLayout(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryPickStore{}
+	service := NewPickService(store)
+	service.Now = func() time.Time { return time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC) }
+
+	layout := models.PickLayout{WarehouseID: 1, Zones: []string{" B ", "A"}}
+	require.NoError(t, service.SaveLayout(ctx, &layout, "lead@example.com"))
+	assert.Equal(t, []string{"B", "A"}, store.layout.Zones)
+	assert.Equal(t, models.TraversalSerpentine, store.layout.Traversal)
+	assert.Equal(t, "lead@example.com", store.layout.UpdatedBy)
+
+	for _, bad := range []models.PickLayout{
+		{WarehouseID: 1, Traversal: "zigzag"},
+		{WarehouseID: 1, Zones: []string{"A", "A"}},
+		{WarehouseID: 1, Zones: []string{" "}},
+		{Zones: []string{"A"}},
+	} {
+		assert.True(t, errors.Is(service.SaveLayout(ctx, &bad, ""), models.ErrValidation), "%+v", bad)
+	}
+}
@@ -0,0 +1,87 @@
+package inventory
+
+import (
+	"context"
+	"erp/models"
+)
+
+// PolicyService keeps the minimum and maximum stock of products per warehouse and suggests
+// what to order to restore them. A product is reordered when its stock on hand and on order
+// in a warehouse falls below the minimum, up to the maximum.
+type PolicyService struct {
+	Store models.StockPolicyStore
+}
+
+// NewPolicyService creates a stock policy service backed by store.
+func NewPolicyService(store models.StockPolicyStore) *PolicyService {
+	return &PolicyService{Store: store}
+}
+
+// validatePolicy checks a stock policy.
+func validatePolicy(policy *models.StockPolicy) error {
+	switch {
+	case policy.ProductID <= 0 || policy.WarehouseID <= 0:
+		return models.Invalid("product_id and warehouse_id are required")
+	case policy.MinLevel < 0:
+		return models.Invalid("min_level cannot be negative")
+	case policy.MaxLevel <= 0 || policy.MaxLevel < policy.MinLevel:
+		return models.Invalid("max_level must be positive and at least min_level")
+	}
+	return nil
+}
+
+// CreatePolicy records a policy after validating it.
+func (s *PolicyService) CreatePolicy(ctx context.Context, policy *models.StockPolicy) error {
+	if err := validatePolicy(policy); err != nil {
+		return err
+	}
+	return s.Store.CreateStockPolicy(ctx, policy)
+}
+
+// UpdatePolicy changes a policy after validating it.
+func (s *PolicyService) UpdatePolicy(ctx context.Context, policy *models.StockPolicy) error {
+	if err := validatePolicy(policy); err != nil {
+		return err
+	}
+	return s.Store.UpdateStockPolicy(ctx, policy)
+}
+
+// DeletePolicy removes a policy.
+func (s *PolicyService) DeletePolicy(ctx context.Context, id int) error {
+	return s.Store.DeleteStockPolicy(ctx, id)
+}
+
+// Policy returns a policy.
+func (s *PolicyService) Policy(ctx context.Context, id int) (*models.StockPolicy, error) {
+	return s.Store.GetStockPolicy(ctx, id)
+}
+
+// Policies returns the policies of a warehouse and product; 0 matches any.
+func (s *PolicyService) Policies(ctx context.Context, warehouseID, productID int) ([]models.StockPolicy, error) {
+	return s.Store.ListStockPolicies(ctx, warehouseID, productID)
+}
+
+// Suggestions returns what to order for the products below their policy's minimum in a
+// warehouse, or in every warehouse if warehouseID is 0.
+func (s *PolicyService) Suggestions(ctx context.Context, warehouseID int) ([]models.Replenishment, error) {
+	positions, err := s.Store.ListStockPositions(ctx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	return Replenish(positions), nil
+}
+
+// Replenish keeps the positions whose stock on hand and on order is below the minimum, with
+// the quantity that brings them up to the maximum. It is the pure part of Suggestions.
+func Replenish(positions []models.Replenishment) []models.Replenishment {
+	suggestions := []models.Replenishment{}
+	for _, p := range positions {
+		position := p.OnHand + p.OnOrder
+		if position >= p.MinLevel {
+			continue
+		}
+		p.Quantity = p.MaxLevel - position
+		suggestions = append(suggestions, p)
+	}
+	return suggestions
+}
@@ -0,0 +1,36 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePolicy(t *testing.T) {
+	assert.NoError(t, validatePolicy(&models.StockPolicy{ProductID: 7, WarehouseID: 1, MinLevel: 0, MaxLevel: 10}))
+	for _, bad := range []models.StockPolicy{
+		{WarehouseID: 1, MinLevel: 5, MaxLevel: 10},
+		{ProductID: 7, MinLevel: 5, MaxLevel: 10},
+		{ProductID: 7, WarehouseID: 1, MinLevel: -1, MaxLevel: 10},
+		{ProductID: 7, WarehouseID: 1, MinLevel: 5},
+		{ProductID: 7, WarehouseID: 1, MinLevel: 20, MaxLevel: 10},
+	} {
+		assert.True(t, errors.Is(validatePolicy(&bad), models.ErrValidation), "%+v", bad)
+	}
+}
+
+func TestReplenish(t *testing.T) {
+	suggestions := Replenish([]models.Replenishment{
+		{PolicyID: 1, ProductID: 7, OnHand: 4, MinLevel: 10, MaxLevel: 50},
+		// Stock on order counts towards the minimum
+		{PolicyID: 2, ProductID: 8, OnHand: 4, OnOrder: 6, MinLevel: 10, MaxLevel: 50},
+		{PolicyID: 3, ProductID: 9, OnHand: 2, OnOrder: 3, MinLevel: 10, MaxLevel: 30},
+	})
+	assert.Len(t, suggestions, 2)
+	assert.Equal(t, 46, suggestions[0].Quantity)
+	assert.Equal(t, 25, suggestions[1].Quantity, "up to the maximum, less what is on order")
+	assert.NotNil(t, Replenish(nil))
+}
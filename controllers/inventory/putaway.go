@@ -0,0 +1,264 @@
+package inventory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/config"
+	"erp/models"
+)
+
+// PutawayService keeps the bins of warehouses and suggests where to store received goods.
+// Products are ranked by the order lines picked for them recently: the most picked go to the
+// bins nearest dispatch, products nobody ordered to the farthest, and the rest around the
+// middle. A product is first topped up in the bins that already hold it, then put in empty
+// bins, and only then next to other products.
+type PutawayService struct {
+	Store models.PutawayStore
+	Rules config.PutawayConfig
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewPutawayService creates a putaway service backed by store.
+func NewPutawayService(store models.PutawayStore, rules config.PutawayConfig) *PutawayService {
+	return &PutawayService{Store: store, Rules: rules, Now: time.Now}
+}
+
+// validateBin trims and checks a bin.
+func validateBin(bin *models.Bin) error {
+	bin.Code = strings.TrimSpace(bin.Code)
+	bin.Zone = strings.TrimSpace(bin.Zone)
+	bin.Aisle = strings.TrimSpace(bin.Aisle)
+	switch {
+	case bin.WarehouseID <= 0:
+		return models.Invalid("warehouse_id is required")
+	case bin.Code == "":
+		return models.Invalid("code is required")
+	case bin.Capacity <= 0:
+		return models.Invalid("capacity must be positive")
+	case bin.Distance < 0:
+		return models.Invalid("distance cannot be negative")
+	case bin.Position < 0:
+		return models.Invalid("position cannot be negative")
+	}
+	return nil
+}
+
+// CreateBin records a bin after validating it.
+func (s *PutawayService) CreateBin(ctx context.Context, bin *models.Bin) error {
+	if err := validateBin(bin); err != nil {
+		return err
+	}
+	return s.Store.CreateBin(ctx, bin)
+}
+
+// UpdateBin changes a bin after validating it.
+func (s *PutawayService) UpdateBin(ctx context.Context, bin *models.Bin) error {
+	if err := validateBin(bin); err != nil {
+		return err
+	}
+	return s.Store.UpdateBin(ctx, bin)
+}
+
+// DeleteBin removes a bin. The stock in it keeps its location.
+func (s *PutawayService) DeleteBin(ctx context.Context, id int) error {
+	return s.Store.DeleteBin(ctx, id)
+}
+
+// Bins returns the bins of a warehouse, or of every warehouse if warehouseID is 0.
+func (s *PutawayService) Bins(ctx context.Context, warehouseID int) ([]models.Bin, error) {
+	return s.Store.ListBins(ctx, warehouseID)
+}
+
+// Suggest plans where to store the lines of a receipt. Lines for the same product are
+// merged, and the most picked products are placed first so that they get the nearest bins.
+//
+// Returns:
+//   - []models.PutawayPlan: One plan per product, in the order they were placed.
+//   - error: A validation error if the request is incomplete, a not found error if the
+//     warehouse has no bins, or the store error.
+func (s *PutawayService) Suggest(ctx context.Context, req models.PutawayRequest) ([]models.PutawayPlan, error) {
+	if req.WarehouseID <= 0 {
+		return nil, models.Invalid("warehouse_id is required")
+	}
+	if len(req.Lines) == 0 {
+		return nil, models.Invalid("at least one line is required")
+	}
+	var lines []models.PutawayLine
+	index := map[int]int{}
+	for i, line := range req.Lines {
+		if line.ProductID <= 0 || line.Quantity <= 0 {
+			return nil, models.Invalid("line %d needs a product and a positive quantity", i+1)
+		}
+		if j, ok := index[line.ProductID]; ok {
+			lines[j].Quantity += line.Quantity
+			continue
+		}
+		index[line.ProductID] = len(lines)
+		lines = append(lines, line)
+	}
+
+	bins, err := s.Store.ListBins(ctx, req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if len(bins) == 0 {
+		return nil, models.NotFound("warehouse %d has no bins", req.WarehouseID)
+	}
+	contents, err := s.Store.ListBinStock(ctx, req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	picks, err := s.Store.ListProductPicks(ctx, s.Now().AddDate(0, 0, -s.Rules.LookbackDays))
+	if err != nil {
+		return nil, err
+	}
+	return Plan(lines, bins, contents, picks, s.Rules.FastPercent), nil
+}
+
+// Plan places lines in bins. It is the pure part of Suggest.
+func Plan(lines []models.PutawayLine, bins []models.Bin, contents []models.BinStock, picks []models.ProductPicks,
+	fastPercent int) []models.PutawayPlan {
+	free := make(map[string]int, len(bins))
+	holds := make(map[string]map[int]bool, len(bins))
+	for _, bin := range bins {
+		free[bin.Code] = max(bin.Capacity-bin.Used, 0)
+		holds[bin.Code] = map[int]bool{}
+	}
+	for _, c := range contents {
+		if holds[c.Code] != nil && c.Quantity > 0 {
+			holds[c.Code][c.ProductID] = true
+		}
+	}
+	picked := map[int]int{}
+	for _, p := range picks {
+		picked[p.ProductID] = p.Picks
+	}
+	lines = append([]models.PutawayLine(nil), lines...)
+	sort.SliceStable(lines, func(i, j int) bool { return picked[lines[i].ProductID] > picked[lines[j].ProductID] })
+
+	middle := 0
+	if len(bins) > 0 {
+		nearest, farthest := bins[0].Distance, bins[0].Distance
+		for _, bin := range bins {
+			nearest, farthest = min(nearest, bin.Distance), max(farthest, bin.Distance)
+		}
+		middle = (nearest + farthest) / 2
+	}
+
+	plans := make([]models.PutawayPlan, 0, len(lines))
+	for _, line := range lines {
+		plan := models.PutawayPlan{
+			ProductID:   line.ProductID,
+			Quantity:    line.Quantity,
+			Picks:       picked[line.ProductID],
+			Velocity:    Velocity(line.ProductID, picks, fastPercent),
+			Suggestions: []models.PutawaySuggestion{},
+		}
+		remaining := line.Quantity
+		group := func(bin models.Bin) int {
+			switch {
+			case holds[bin.Code][line.ProductID]:
+				return 0
+			case len(holds[bin.Code]) == 0:
+				return 1
+			}
+			return 2
+		}
+		for _, bin := range binOrder(bins, group, plan.Velocity, middle) {
+			if remaining == 0 {
+				break
+			}
+			room := free[bin.Code]
+			if room == 0 {
+				continue
+			}
+			reason := "already holds this product"
+			switch group(bin) {
+			case 1:
+				reason = "empty bin " + placement(plan.Velocity)
+			case 2:
+				reason = "room next to other products, " + placement(plan.Velocity)
+			}
+			quantity := min(room, remaining)
+			plan.Suggestions = append(plan.Suggestions, models.PutawaySuggestion{
+				BinID: bin.ID, Code: bin.Code, Distance: bin.Distance, Quantity: quantity, Reason: reason,
+			})
+			free[bin.Code] -= quantity
+			holds[bin.Code][line.ProductID] = true
+			remaining -= quantity
+		}
+		plan.Unplaced = remaining
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// Velocity classes a product by its rank among the picked products: the top fastPercent
+// (at least one product) are fast, other picked products medium and the rest slow.
+func Velocity(productID int, picks []models.ProductPicks, fastPercent int) string {
+	var own int
+	for _, p := range picks {
+		if p.ProductID == productID {
+			own = p.Picks
+		}
+	}
+	if own <= 0 {
+		return models.VelocitySlow
+	}
+	picked, ahead := 0, 0
+	for _, p := range picks {
+		if p.Picks > 0 {
+			picked++
+		}
+		if p.Picks > own {
+			ahead++
+		}
+	}
+	fast := max(picked*fastPercent/100, 1)
+	if fastPercent > 0 && ahead < fast {
+		return models.VelocityFast
+	}
+	return models.VelocityMedium
+}
+
+// binOrder sorts bins by group, then for a velocity: nearest dispatch first for fast
+// movers, farthest first for slow ones, and closest to the middle distance first for the
+// rest.
+func binOrder(bins []models.Bin, group func(models.Bin) int, velocity string, middle int) []models.Bin {
+	ordered := append([]models.Bin(nil), bins...)
+	key := func(bin models.Bin) int {
+		switch velocity {
+		case models.VelocityFast:
+			return bin.Distance
+		case models.VelocitySlow:
+			return -bin.Distance
+		}
+		if bin.Distance < middle {
+			return middle - bin.Distance
+		}
+		return bin.Distance - middle
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if gi, gj := group(ordered[i]), group(ordered[j]); gi != gj {
+			return gi < gj
+		}
+		if ki, kj := key(ordered[i]), key(ordered[j]); ki != kj {
+			return ki < kj
+		}
+		return ordered[i].Code < ordered[j].Code
+	})
+	return ordered
+}
+
+func placement(velocity string) string {
+	switch velocity {
+	case models.VelocityFast:
+		return "near dispatch for a fast mover"
+	case models.VelocitySlow:
+		return "away from dispatch for a slow mover"
+	}
+	return "at mid distance"
+}
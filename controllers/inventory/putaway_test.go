@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPutawayStore returns fixed bins, contents and picks.
+type memoryPutawayStore struct {
+	bins     []models.Bin
+	contents []models.BinStock
+	picks    []models.ProductPicks
+	since    time.Time
+}
+
+func (m *memoryPutawayStore) CreateBin(ctx context.Context, bin *models.Bin) error {
+	bin.ID = len(m.bins) + 1
+	m.bins = append(m.bins, *bin)
+	return nil
+}
+
+func (m *memoryPutawayStore) UpdateBin(ctx context.Context, bin *models.Bin) error { return nil }
+func (m *memoryPutawayStore) DeleteBin(ctx context.Context, id int) error          { return nil }
+
+func (m *memoryPutawayStore) ListBins(ctx context.Context, warehouseID int) ([]models.Bin, error) {
+	bins := []models.Bin{}
+	for _, bin := range m.bins {
+		if warehouseID == 0 || bin.WarehouseID == warehouseID {
+			bins = append(bins, bin)
+		}
+	}
+	return bins, nil
+}
+
+func (m *memoryPutawayStore) ListBinStock(ctx context.Context, warehouseID int) ([]models.BinStock, error) {
+	return m.contents, nil
+}
+
+func (m *memoryPutawayStore) ListProductPicks(ctx context.Context, since time.Time) ([]models.ProductPicks, error) {
+	m.since = since
+	return m.picks, nil
+}
+
+// warehouseBins are five bins 10 to 50 steps from dispatch, holding 10 units each.
+func warehouseBins() []models.Bin {
+	return []models.Bin{
+		{ID: 1, WarehouseID: 1, Code: "A1", Capacity: 10, Distance: 10},
+		{ID: 2, WarehouseID: 1, Code: "A2", Capacity: 10, Distance: 20},
+		{ID: 3, WarehouseID: 1, Code: "B1", Capacity: 10, Distance: 30},
+		{ID: 4, WarehouseID: 1, Code: "B2", Capacity: 10, Distance: 40},
+		{ID: 5, WarehouseID: 1, Code: "C1", Capacity: 10, Distance: 50},
+	}
+}
+
+func codes(plan models.PutawayPlan) []string {
+	var list []string
+	for _, s := range plan.Suggestions {
+		list = append(list, s.Code)
+	}
+	return list
+}
+
+func TestVelocity(t *testing.T) {
+	picks := []models.ProductPicks{
+		{ProductID: 1, Picks: 50}, {ProductID: 2, Picks: 40}, {ProductID: 3, Picks: 10},
+		{ProductID: 4, Picks: 5}, {ProductID: 5, Picks: 1},
+	}
+	assert.Equal(t, models.VelocityFast, Velocity(1, picks, 20))
+	assert.Equal(t, models.VelocityMedium, Velocity(2, picks, 20))
+	assert.Equal(t, models.VelocityFast, Velocity(2, picks, 40))
+	assert.Equal(t, models.VelocityMedium, Velocity(5, picks, 20))
+	assert.Equal(t, models.VelocitySlow, Velocity(9, picks, 20), "never picked")
+	assert.Equal(t, models.VelocityFast, Velocity(1, picks[:1], 20), "the top product is fast even below one percent step")
+	assert.Equal(t, models.VelocityMedium, Velocity(1, picks, 0), "no fast movers")
+}
+
+func TestPlanPlacesByVelocity(t *testing.T) {
+	picks := []models.ProductPicks{{ProductID: 1, Picks: 50}, {ProductID: 2, Picks: 5}, {ProductID: 3, Picks: 1}}
+	lines := []models.PutawayLine{
+		{ProductID: 9, Quantity: 5}, // Slow
+		{ProductID: 1, Quantity: 5}, // Fast
+		{ProductID: 2, Quantity: 5}, // Medium
+	}
+
+	plans := Plan(lines, warehouseBins(), nil, picks, 20)
+	require.Len(t, plans, 3)
+	assert.Equal(t, []int{1, 2, 9}, []int{plans[0].ProductID, plans[1].ProductID, plans[2].ProductID}, "most picked first")
+	assert.Equal(t, []string{"A1"}, codes(plans[0]))
+	assert.Equal(t, "empty bin near dispatch for a fast mover", plans[0].Suggestions[0].Reason)
+	assert.Equal(t, []string{"B1"}, codes(plans[1]))
+	assert.Equal(t, []string{"C1"}, codes(plans[2]))
+	assert.Equal(t, models.VelocitySlow, plans[2].Velocity)
+}
+
+func TestPlanPrefersBinsHoldingTheProduct(t *testing.T) {
+	bins := warehouseBins()
+	bins[3].Used = 6 // B2 holds 6 units of product 1
+	bins[0].Used = 2 // A1 holds 2 units of product 4
+	contents := []models.BinStock{{Code: "B2", ProductID: 1, Quantity: 6}, {Code: "A1", ProductID: 4, Quantity: 2}}
+	picks := []models.ProductPicks{{ProductID: 1, Picks: 50}}
+
+	plans := Plan([]models.PutawayLine{{ProductID: 1, Quantity: 20}}, bins, contents, picks, 20)
+	require.Len(t, plans, 1)
+	assert.Equal(t, []string{"B2", "A2", "B1"}, codes(plans[0]), "topped up, then the nearest empty bins")
+	assert.Equal(t, 4, plans[0].Suggestions[0].Quantity)
+	assert.Equal(t, "already holds this product", plans[0].Suggestions[0].Reason)
+	assert.Equal(t, 6, plans[0].Suggestions[2].Quantity)
+	assert.Zero(t, plans[0].Unplaced)
+}
+
+func TestPlanReportsUnplacedUnits(t *testing.T) {
+	bins := warehouseBins()[:1]
+	bins[0].Used = 7
+	contents := []models.BinStock{{Code: "A1", ProductID: 4, Quantity: 7}}
+
+	plans := Plan([]models.PutawayLine{{ProductID: 1, Quantity: 5}}, bins, contents, nil, 20)
+	require.Len(t, plans[0].Suggestions, 1)
+	assert.Equal(t, 3, plans[0].Suggestions[0].Quantity)
+	assert.Equal(t, "room next to other products, away from dispatch for a slow mover", plans[0].Suggestions[0].Reason)
+	assert.Equal(t, 2, plans[0].Unplaced)
+}
+
+func TestPlanSharesBinsBetweenLines(t *testing.T) {
+	bins := warehouseBins()[:1]
+	picks := []models.ProductPicks{{ProductID: 1, Picks: 9}, {ProductID: 2, Picks: 3}}
+
+	plans := Plan([]models.PutawayLine{{ProductID: 2, Quantity: 6}, {ProductID: 1, Quantity: 6}}, bins, nil, picks, 20)
+	assert.Equal(t, 6, plans[0].Suggestions[0].Quantity)
+	assert.Equal(t, 4, plans[1].Suggestions[0].Quantity, "the second product gets what is left")
+	assert.Equal(t, 2, plans[1].Unplaced)
+}
+
+func TestSuggest(t *testing.T) {
+	store := &memoryPutawayStore{bins: warehouseBins(), picks: []models.ProductPicks{{ProductID: 1, Picks: 4}}}
+	service := NewPutawayService(store, config.PutawayConfig{LookbackDays: 30, FastPercent: 20})
+	service.Now = func() time.Time { return time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC) }
+
+	plans, err := service.Suggest(context.Background(), models.PutawayRequest{WarehouseID: 1, Lines: []models.PutawayLine{
+		{ProductID: 1, Quantity: 8}, {ProductID: 1, Quantity: 4},
+	}})
+	require.NoError(t, err)
+	require.Len(t, plans, 1, "lines of a product are merged")
+	assert.Equal(t, 12, plans[0].Quantity)
+	assert.Equal(t, []string{"A1", "A2"}, codes(plans[0]))
+	assert.Equal(t, time.Date(2025, 5, 31, 12, 0, 0, 0, time.UTC), store.since)
+}
+
+func TestSuggestRejects(t *testing.T) {
+	ctx := context.Background()
+	service := NewPutawayService(&memoryPutawayStore{}, config.PutawayConfig{})
+
+	for name, req := range map[string]models.PutawayRequest{
+		"no warehouse": {Lines: []models.PutawayLine{{ProductID: 1, Quantity: 1}}},
+		"no lines":     {WarehouseID: 1},
+		"no quantity":  {WarehouseID: 1, Lines: []models.PutawayLine{{ProductID: 1}}},
+	} {
+		_, err := service.Suggest(ctx, req)
+		assert.True(t, errors.Is(err, models.ErrValidation), name)
+	}
+
+	_, err := service.Suggest(ctx, models.PutawayRequest{WarehouseID: 1, Lines: []models.PutawayLine{{ProductID: 1, Quantity: 1}}})
+	assert.True(t, errors.Is(err, models.ErrNotFound), "a warehouse without bins")
+}
+
+func TestCreateBinValidates(t *testing.T) {
+	ctx := context.Background()
+	service := NewPutawayService(&memoryPutawayStore{}, config.PutawayConfig{})
+
+	bin := models.Bin{WarehouseID: 1, Code: " A1 ", Capacity: 10}
+	require.NoError(t, service.CreateBin(ctx, &bin))
+	assert.Equal(t, "A1", bin.Code)
+
+	for _, bin := range []models.Bin{
+		{Code: "A1", Capacity: 1},
+		{WarehouseID: 1, Capacity: 1},
+		{WarehouseID: 1, Code: "A1"},
+		{WarehouseID: 1, Code: "A1", Capacity: 1, Distance: -1},
+	} {
+		assert.True(t, errors.Is(service.CreateBin(ctx, &bin), models.ErrValidation), "%+v", bin)
+	}
+}
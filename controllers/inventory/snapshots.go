@@ -0,0 +1,98 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"erp/models"
+)
+
+// dateLayout is the format of snapshot days and as-of dates.
+const dateLayout = "2006-01-02"
+
+// SnapshotService takes the nightly stock snapshots and works out past stock balances from
+// them. Movements recorded after a snapshot are applied to it; other changes to stock show
+// in the next snapshot.
+type SnapshotService struct {
+	Store models.StockSnapshotStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewSnapshotService creates a stock snapshot service backed by store.
+func NewSnapshotService(store models.StockSnapshotStore) *SnapshotService {
+	return &SnapshotService{Store: store, Now: time.Now}
+}
+
+// Nightly takes the day's snapshot if it has not been taken yet. It is run by the scheduler.
+func (s *SnapshotService) Nightly(ctx context.Context) error {
+	now := s.Now()
+	snapshot := models.StockSnapshot{TakenOn: now.Format(dateLayout), TakenAt: now}
+	err := s.Store.TakeStockSnapshot(ctx, &snapshot)
+	if errors.Is(err, models.ErrConflict) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("inventory: stock snapshot %s taken with %d balances", snapshot.TakenOn, snapshot.Lines)
+	return nil
+}
+
+// AsOf returns a product's stock at the end of a day, per warehouse.
+//
+// Parameters:
+//   - productID: The product.
+//   - date: The day, as YYYY-MM-DD; today gives the balance up to now.
+//
+// Returns:
+//   - *models.StockBalance: The balance, and the snapshot and movements it was worked out from.
+//   - error: A validation error if the product is missing or the date is invalid or in the
+//     future, a not found error if no snapshot was taken by the end of the day, or the
+//     store error.
+func (s *SnapshotService) AsOf(ctx context.Context, productID int, date string) (*models.StockBalance, error) {
+	if productID <= 0 {
+		return nil, models.Invalid("product_id is required")
+	}
+	now := s.Now()
+	day, err := time.ParseInLocation(dateLayout, date, now.Location())
+	if err != nil {
+		return nil, models.Invalid("date must be formatted as YYYY-MM-DD")
+	}
+	if day.After(now) {
+		return nil, models.Invalid("date cannot be in the future")
+	}
+	end := day.AddDate(0, 0, 1)
+
+	snapshot, balances, err := s.Store.GetStockSnapshotBefore(ctx, productID, end)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, models.NotFound("no stock snapshot was taken by %s", date)
+	}
+	if err != nil {
+		return nil, err
+	}
+	movements, err := s.Store.ListProductMovements(ctx, productID, snapshot.TakenAt, end)
+	if err != nil {
+		return nil, err
+	}
+
+	quantities := map[int]int{}
+	for _, b := range balances {
+		quantities[b.WarehouseID] += b.Quantity
+	}
+	for _, m := range movements {
+		quantities[m.WarehouseID] += m.Quantity
+	}
+	balance := &models.StockBalance{ProductID: productID, Date: date, Warehouses: []models.WarehouseBalance{},
+		Snapshot: *snapshot, Movements: len(movements)}
+	for warehouseID, quantity := range quantities {
+		balance.Warehouses = append(balance.Warehouses, models.WarehouseBalance{WarehouseID: warehouseID, Quantity: quantity})
+		balance.Quantity += quantity
+	}
+	sort.Slice(balance.Warehouses, func(i, j int) bool {
+		return balance.Warehouses[i].WarehouseID < balance.Warehouses[j].WarehouseID
+	})
+	return balance, nil
+}
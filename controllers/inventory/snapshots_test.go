@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySnapshotStore keeps snapshots and movements in memory.
+type memorySnapshotStore struct {
+	snapshots []models.StockSnapshot
+	balances  map[int][]models.WarehouseBalance // By snapshot ID
+	movements []models.StockMovement
+}
+
+func (m *memorySnapshotStore) TakeStockSnapshot(ctx context.Context, snapshot *models.StockSnapshot) error {
+	for _, s := range m.snapshots {
+		if s.TakenOn == snapshot.TakenOn {
+			return models.Conflict("a stock snapshot was taken on %s already", snapshot.TakenOn)
+		}
+	}
+	snapshot.ID = len(m.snapshots) + 1
+	m.snapshots = append(m.snapshots, *snapshot)
+	return nil
+}
+
+func (m *memorySnapshotStore) GetStockSnapshotBefore(ctx context.Context, productID int, before time.Time) (*models.StockSnapshot, []models.WarehouseBalance, error) {
+	var last *models.StockSnapshot
+	for i, s := range m.snapshots {
+		if s.TakenAt.Before(before) && (last == nil || s.TakenAt.After(last.TakenAt)) {
+			last = &m.snapshots[i]
+		}
+	}
+	if last == nil {
+		return nil, nil, models.NotFound("no stock snapshot")
+	}
+	return last, m.balances[last.ID], nil
+}
+
+func (m *memorySnapshotStore) ListProductMovements(ctx context.Context, productID int, from, to time.Time) ([]models.StockMovement, error) {
+	var list []models.StockMovement
+	for _, mv := range m.movements {
+		if mv.ProductID == productID && mv.MovedAt.After(from) && mv.MovedAt.Before(to) {
+			list = append(list, mv)
+		}
+	}
+	return list, nil
+}
+
+func TestStockAsOf(t *testing.T) {
+	ctx := context.Background()
+	at := func(day, hour int) time.Time { return time.Date(2025, 3, day, hour, 0, 0, 0, time.UTC) }
+	store := &memorySnapshotStore{
+		snapshots: []models.StockSnapshot{
+			{ID: 1, TakenOn: "2025-03-01", TakenAt: at(1, 23)},
+			{ID: 2, TakenOn: "2025-03-03", TakenAt: at(3, 23)},
+		},
+		balances: map[int][]models.WarehouseBalance{
+			1: {{WarehouseID: 1, Quantity: 40}},
+			2: {{WarehouseID: 1, Quantity: 30}, {WarehouseID: 2, Quantity: 10}},
+		},
+		movements: []models.StockMovement{
+			{ProductID: 7, WarehouseID: 1, Quantity: -10, MovedAt: at(2, 10)},
+			{ProductID: 7, WarehouseID: 2, Quantity: 10, MovedAt: at(2, 10)},
+			{ProductID: 7, WarehouseID: 2, Quantity: -4, MovedAt: at(3, 23).Add(30 * time.Minute)},
+			{ProductID: 8, WarehouseID: 2, Quantity: 5, MovedAt: at(3, 12)},
+		},
+	}
+	service := NewSnapshotService(store)
+	service.Now = func() time.Time { return at(10, 9) }
+
+	balance, err := service.AsOf(ctx, 7, "2025-03-02")
+	require.NoError(t, err)
+	assert.Equal(t, 1, balance.Snapshot.ID)
+	assert.Equal(t, 2, balance.Movements)
+	assert.Equal(t, []models.WarehouseBalance{{WarehouseID: 1, Quantity: 30}, {WarehouseID: 2, Quantity: 10}}, balance.Warehouses)
+	assert.Equal(t, 40, balance.Quantity)
+
+	balance, err = service.AsOf(ctx, 7, "2025-03-03")
+	require.NoError(t, err)
+	assert.Equal(t, 2, balance.Snapshot.ID)
+	assert.Equal(t, 1, balance.Movements, "the movement after the snapshot on the same day")
+	assert.Equal(t, 36, balance.Quantity)
+
+	_, err = service.AsOf(ctx, 7, "2025-02-28")
+	assert.True(t, errors.Is(err, models.ErrNotFound))
+	for _, bad := range []struct {
+		productID int
+		date      string
+	}{{0, "2025-03-02"}, {7, "03/02/2025"}, {7, "2025-03-11"}} {
+		_, err = service.AsOf(ctx, bad.productID, bad.date)
+		assert.True(t, errors.Is(err, models.ErrValidation), "%+v", bad)
+	}
+}
+
+func TestNightlySnapshotOncePerDay(t *testing.T) {
+	ctx := context.Background()
+	store := &memorySnapshotStore{}
+	service := NewSnapshotService(store)
+	service.Now = func() time.Time { return time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC) }
+
+	require.NoError(t, service.Nightly(ctx))
+	require.NoError(t, service.Nightly(ctx))
+	require.Len(t, store.snapshots, 1)
+	assert.Equal(t, "2025-03-01", store.snapshots[0].TakenOn)
+}
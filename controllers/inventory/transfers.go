@@ -0,0 +1,213 @@
+package inventory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// TransferService applies the rules of stock transfers between warehouses on top of a
+// StockTransferStore.
+type TransferService struct {
+	Store models.StockTransferStore
+}
+
+// NewTransferService creates a transfer service backed by store.
+func NewTransferService(store models.StockTransferStore) *TransferService {
+	return &TransferService{Store: store}
+}
+
+// Request checks a transfer and records it as requested. Lines for the same product are
+// merged.
+//
+// Parameters:
+//   - transfer: The transfer; SourceWarehouseID, DestinationWarehouseID, Note and Lines
+//     (product and quantity) are read. The store fills in the ID and time.
+//   - actor: Email of the user requesting the transfer.
+//
+// Returns:
+//   - error: A validation error if the transfer is incomplete, or the store's error.
+func (s *TransferService) Request(ctx context.Context, transfer *models.StockTransfer, actor string) error {
+	switch {
+	case transfer.SourceWarehouseID <= 0 || transfer.DestinationWarehouseID <= 0:
+		return models.Invalid("source_warehouse_id and destination_warehouse_id are required")
+	case transfer.SourceWarehouseID == transfer.DestinationWarehouseID:
+		return models.Invalid("a transfer must be between two different warehouses")
+	case len(transfer.Lines) == 0:
+		return models.Invalid("a transfer needs at least one line")
+	}
+
+	merged := make([]models.StockTransferLine, 0, len(transfer.Lines))
+	index := make(map[int]int, len(transfer.Lines))
+	for i, line := range transfer.Lines {
+		if line.ProductID <= 0 {
+			return models.Invalid("line %d has no product", i+1)
+		}
+		if line.Quantity <= 0 {
+			return models.Invalid("line %d must have a positive quantity", i+1)
+		}
+		if j, ok := index[line.ProductID]; ok {
+			merged[j].Quantity += line.Quantity
+			continue
+		}
+		index[line.ProductID] = len(merged)
+		merged = append(merged, models.StockTransferLine{ProductID: line.ProductID, Quantity: line.Quantity})
+	}
+	transfer.Lines = merged
+	transfer.Note = strings.TrimSpace(transfer.Note)
+	transfer.Status = models.StockTransferRequested
+	transfer.RequestedBy = actor
+	return s.Store.CreateTransfer(ctx, transfer)
+}
+
+// Get returns a transfer with its lines.
+func (s *TransferService) Get(ctx context.Context, id int) (*models.StockTransfer, error) {
+	return s.Store.GetTransfer(ctx, id)
+}
+
+// List returns the transfers in a status, or all of them if status is empty.
+func (s *TransferService) List(ctx context.Context, status string) ([]models.StockTransfer, error) {
+	switch status {
+	case "", models.StockTransferRequested, models.StockTransferApproved, models.StockTransferInTransit,
+		models.StockTransferReceived, models.StockTransferCancelled:
+		return s.Store.ListTransfers(ctx, status)
+	}
+	return nil, models.Invalid("unknown transfer status %q", status)
+}
+
+// Approve allows a requested transfer to be dispatched.
+func (s *TransferService) Approve(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return s.Store.ApproveTransfer(ctx, id, actor)
+}
+
+// Dispatch takes an approved transfer's stock from the source warehouse and puts it in transit.
+func (s *TransferService) Dispatch(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return s.Store.DispatchTransfer(ctx, id, actor)
+}
+
+// Cancel cancels a transfer that has not been dispatched.
+func (s *TransferService) Cancel(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return s.Store.CancelTransfer(ctx, id, actor)
+}
+
+// Receive confirms what arrived at the destination and adds it to the destination's stock.
+// Products left out of the receipt are taken as received in full, and a shortfall must be
+// explained in the note.
+//
+// Parameters:
+//   - id: The ID of the transfer.
+//   - receipt: The quantities received of some or all products, and a note.
+//   - actor: Email of the user confirming the receipt.
+//
+// Returns:
+//   - *models.StockTransfer: The received transfer, with the shortfall of each line.
+//   - error: A validation error if a product is not on the transfer, more arrived than
+//     was dispatched or a shortfall has no note, or the store's error.
+func (s *TransferService) Receive(ctx context.Context, id int, receipt models.StockTransferReceipt, actor string) (*models.StockTransfer, error) {
+	transfer, err := s.Store.GetTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dispatched := make(map[int]int, len(transfer.Lines))
+	for _, line := range transfer.Lines {
+		dispatched[line.ProductID] = line.Quantity
+	}
+
+	received := make(map[int]int, len(receipt.Lines))
+	for i, line := range receipt.Lines {
+		quantity, ok := dispatched[line.ProductID]
+		switch {
+		case !ok:
+			return nil, models.Invalid("receipt line %d: product %d is not on the transfer", i+1, line.ProductID)
+		case line.ReceivedQuantity < 0:
+			return nil, models.Invalid("receipt line %d: received_quantity must not be negative", i+1)
+		case line.ReceivedQuantity > quantity:
+			return nil, models.Invalid("receipt line %d: %d received but only %d dispatched", i+1, line.ReceivedQuantity, quantity)
+		}
+		if _, ok := received[line.ProductID]; ok {
+			return nil, models.Invalid("receipt line %d: product %d is listed twice", i+1, line.ProductID)
+		}
+		received[line.ProductID] = line.ReceivedQuantity
+	}
+
+	full := models.StockTransferReceipt{Note: strings.TrimSpace(receipt.Note)}
+	short := false
+	for _, line := range transfer.Lines {
+		quantity, ok := received[line.ProductID]
+		if !ok {
+			quantity = line.Quantity
+		}
+		short = short || quantity < line.Quantity
+		full.Lines = append(full.Lines, models.StockTransferReceiptLine{ProductID: line.ProductID, ReceivedQuantity: quantity})
+	}
+	if short && full.Note == "" {
+		return nil, models.Invalid("a note explaining the shortfall is required")
+	}
+	return s.Store.ReceiveTransfer(ctx, id, full, actor)
+}
+
+// Move checks a direct transfer of a product and moves it between the warehouses at once.
+//
+// Parameters:
+//   - move: The move; ProductID, Quantity, SourceWarehouseID, DestinationWarehouseID and
+//     Note are read. The store sets the movements.
+//   - actor: Email of the user moving the stock.
+//
+// Returns:
+//   - error: A validation error if the move is incomplete or within one warehouse, or the
+//     store's error.
+func (s *TransferService) Move(ctx context.Context, move *models.StockMove, actor string) error {
+	switch {
+	case move.ProductID <= 0:
+		return models.Invalid("product_id is required")
+	case move.Quantity <= 0:
+		return models.Invalid("quantity must be positive")
+	case move.SourceWarehouseID <= 0 || move.DestinationWarehouseID <= 0:
+		return models.Invalid("source_warehouse_id and destination_warehouse_id are required")
+	case move.SourceWarehouseID == move.DestinationWarehouseID:
+		return models.Invalid("a transfer must be between two different warehouses")
+	}
+	move.Note = strings.TrimSpace(move.Note)
+	return s.Store.MoveStock(ctx, move, actor)
+}
+
+// Movements returns the stock movements of a product and a warehouse, newest first; an ID of
+// 0 matches every product or warehouse.
+func (s *TransferService) Movements(ctx context.Context, productID, warehouseID int) ([]models.StockMovement, error) {
+	return s.Store.ListStockMovements(ctx, productID, warehouseID)
+}
+
+// InTransit reports the transfers dispatched but not yet received, longest on the road first.
+//
+// Parameters:
+//   - now: The time the report is made.
+//
+// Returns:
+//   - *models.StockInTransitReport: The transfers, how long each has been in transit and
+//     the total quantity.
+//   - error: The store's error.
+func (s *TransferService) InTransit(ctx context.Context, now time.Time) (*models.StockInTransitReport, error) {
+	transfers, err := s.Store.ListTransfers(ctx, models.StockTransferInTransit)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.StockInTransitReport{AsOf: now, Transfers: []models.InTransitTransfer{}}
+	for _, transfer := range transfers {
+		entry := models.InTransitTransfer{StockTransfer: transfer}
+		if transfer.DispatchedAt != nil {
+			entry.DaysInTransit = int(now.Sub(*transfer.DispatchedAt).Hours() / 24)
+		}
+		for _, line := range transfer.Lines {
+			report.Units += line.Quantity
+		}
+		report.Transfers = append(report.Transfers, entry)
+	}
+	sort.SliceStable(report.Transfers, func(i, j int) bool {
+		a, b := report.Transfers[i].DispatchedAt, report.Transfers[j].DispatchedAt
+		return a != nil && b != nil && a.Before(*b)
+	})
+	return report, nil
+}
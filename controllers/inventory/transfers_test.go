@@ -0,0 +1,172 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryTransferStore keeps transfers in memory and records the last receipt and move.
+type memoryTransferStore struct {
+	transfers map[int]*models.StockTransfer
+	receipt   models.StockTransferReceipt
+	move      *models.StockMove
+}
+
+func (m *memoryTransferStore) CreateTransfer(ctx context.Context, transfer *models.StockTransfer) error {
+	transfer.ID = len(m.transfers) + 1
+	m.transfers[transfer.ID] = transfer
+	return nil
+}
+
+func (m *memoryTransferStore) GetTransfer(ctx context.Context, id int) (*models.StockTransfer, error) {
+	transfer, ok := m.transfers[id]
+	if !ok {
+		return nil, models.NotFound("transfer %d not found", id)
+	}
+	return transfer, nil
+}
+
+func (m *memoryTransferStore) ListTransfers(ctx context.Context, status string) ([]models.StockTransfer, error) {
+	var transfers []models.StockTransfer
+	for id := 1; id <= len(m.transfers); id++ {
+		if transfer := m.transfers[id]; status == "" || transfer.Status == status {
+			transfers = append(transfers, *transfer)
+		}
+	}
+	return transfers, nil
+}
+
+func (m *memoryTransferStore) ApproveTransfer(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return m.transfers[id], nil
+}
+
+func (m *memoryTransferStore) DispatchTransfer(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return m.transfers[id], nil
+}
+
+func (m *memoryTransferStore) ReceiveTransfer(ctx context.Context, id int, receipt models.StockTransferReceipt, actor string) (*models.StockTransfer, error) {
+	m.receipt = receipt
+	return m.transfers[id], nil
+}
+
+func (m *memoryTransferStore) CancelTransfer(ctx context.Context, id int, actor string) (*models.StockTransfer, error) {
+	return m.transfers[id], nil
+}
+
+func (m *memoryTransferStore) MoveStock(ctx context.Context, move *models.StockMove, actor string) error {
+	m.move = move
+	return nil
+}
+
+func (m *memoryTransferStore) ListStockMovements(ctx context.Context, productID, warehouseID int) ([]models.StockMovement, error) {
+	return nil, nil
+}
+
+func TestRequestTransfer(t *testing.T) {
+	ctx := context.Background()
+	service := NewTransferService(&memoryTransferStore{transfers: map[int]*models.StockTransfer{}})
+
+	transfer := models.StockTransfer{SourceWarehouseID: 1, DestinationWarehouseID: 2, Note: " Restock ", Lines: []models.StockTransferLine{
+		{ProductID: 7, Quantity: 2}, {ProductID: 8, Quantity: 1}, {ProductID: 7, Quantity: 3},
+	}}
+	require.NoError(t, service.Request(ctx, &transfer, "clerk@example.com"))
+	assert.Equal(t, []models.StockTransferLine{{ProductID: 7, Quantity: 5}, {ProductID: 8, Quantity: 1}}, transfer.Lines)
+	assert.Equal(t, models.StockTransferRequested, transfer.Status)
+	assert.Equal(t, "Restock", transfer.Note)
+	assert.Equal(t, "clerk@example.com", transfer.RequestedBy)
+
+	for _, invalid := range []models.StockTransfer{
+		{SourceWarehouseID: 1, Lines: []models.StockTransferLine{{ProductID: 7, Quantity: 1}}},
+		{SourceWarehouseID: 1, DestinationWarehouseID: 1, Lines: []models.StockTransferLine{{ProductID: 7, Quantity: 1}}},
+		{SourceWarehouseID: 1, DestinationWarehouseID: 2},
+		{SourceWarehouseID: 1, DestinationWarehouseID: 2, Lines: []models.StockTransferLine{{ProductID: 7}}},
+	} {
+		assert.ErrorIs(t, service.Request(ctx, &invalid, "clerk@example.com"), models.ErrValidation, "%+v", invalid)
+	}
+}
+
+func TestMoveStock(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryTransferStore{transfers: map[int]*models.StockTransfer{}}
+	service := NewTransferService(store)
+
+	move := models.StockMove{ProductID: 7, Quantity: 4, SourceWarehouseID: 1, DestinationWarehouseID: 2, Note: " Rebalance "}
+	require.NoError(t, service.Move(ctx, &move, "clerk@example.com"))
+	assert.Equal(t, "Rebalance", store.move.Note)
+
+	for _, invalid := range []models.StockMove{
+		{Quantity: 4, SourceWarehouseID: 1, DestinationWarehouseID: 2},
+		{ProductID: 7, SourceWarehouseID: 1, DestinationWarehouseID: 2},
+		{ProductID: 7, Quantity: -1, SourceWarehouseID: 1, DestinationWarehouseID: 2},
+		{ProductID: 7, Quantity: 4, SourceWarehouseID: 1},
+		{ProductID: 7, Quantity: 4, SourceWarehouseID: 2, DestinationWarehouseID: 2},
+	} {
+		store.move = nil
+		assert.ErrorIs(t, service.Move(ctx, &invalid, "clerk@example.com"), models.ErrValidation, "%+v", invalid)
+		assert.Nil(t, store.move)
+	}
+}
+
+func TestReceiveTransfer(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryTransferStore{transfers: map[int]*models.StockTransfer{
+		1: {ID: 1, Status: models.StockTransferInTransit, Lines: []models.StockTransferLine{
+			{ProductID: 7, Quantity: 5}, {ProductID: 8, Quantity: 1},
+		}},
+	}}
+	service := NewTransferService(store)
+	receipt := func(productID, quantity int, note string) models.StockTransferReceipt {
+		return models.StockTransferReceipt{Lines: []models.StockTransferReceiptLine{{ProductID: productID, ReceivedQuantity: quantity}}, Note: note}
+	}
+
+	// Products left out are received in full
+	_, err := service.Receive(ctx, 1, models.StockTransferReceipt{}, "receiver@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []models.StockTransferReceiptLine{{ProductID: 7, ReceivedQuantity: 5}, {ProductID: 8, ReceivedQuantity: 1}}, store.receipt.Lines)
+
+	_, err = service.Receive(ctx, 1, receipt(7, 3, " Two damaged "), "receiver@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []models.StockTransferReceiptLine{{ProductID: 7, ReceivedQuantity: 3}, {ProductID: 8, ReceivedQuantity: 1}}, store.receipt.Lines)
+	assert.Equal(t, "Two damaged", store.receipt.Note)
+
+	for _, invalid := range []models.StockTransferReceipt{
+		receipt(7, 3, ""),       // A shortfall needs a note
+		receipt(7, 6, ""),       // More than was dispatched
+		receipt(9, 1, ""),       // Not on the transfer
+		receipt(7, -1, "Count"), // Negative
+	} {
+		_, err := service.Receive(ctx, 1, invalid, "receiver@example.com")
+		assert.ErrorIs(t, err, models.ErrValidation, "%+v", invalid)
+	}
+	_, err = service.Receive(ctx, 2, models.StockTransferReceipt{}, "receiver@example.com")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestInTransitReport(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	dispatched := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+	service := NewTransferService(&memoryTransferStore{transfers: map[int]*models.StockTransfer{
+		1: {ID: 1, Status: models.StockTransferInTransit, DispatchedAt: dispatched(1), Lines: []models.StockTransferLine{{ProductID: 7, Quantity: 5}}},
+		2: {ID: 2, Status: models.StockTransferReceived, DispatchedAt: dispatched(9), Lines: []models.StockTransferLine{{ProductID: 7, Quantity: 4}}},
+		3: {ID: 3, Status: models.StockTransferInTransit, DispatchedAt: dispatched(4), Lines: []models.StockTransferLine{
+			{ProductID: 7, Quantity: 2}, {ProductID: 8, Quantity: 1},
+		}},
+	}})
+
+	report, err := service.InTransit(context.Background(), now)
+	require.NoError(t, err)
+	require.Len(t, report.Transfers, 2)
+	assert.Equal(t, 3, report.Transfers[0].ID)
+	assert.Equal(t, 4, report.Transfers[0].DaysInTransit)
+	assert.Equal(t, 1, report.Transfers[1].DaysInTransit)
+	assert.Equal(t, 8, report.Units)
+}
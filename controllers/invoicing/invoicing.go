@@ -0,0 +1,132 @@
+// Package invoicing holds the invoice business rules: invoices start as drafts, only drafts
+// can be changed, posting records the ledger transactions and voiding is audited. HTTP
+// handlers, jobs and command line tools share these rules by calling Service instead of the
+// store.
+package invoicing
+
+import (
+	"context"
+	"strings"
+
+	"erp/models"
+)
+
+// MaxVoidBatch limits how many invoices a single void may touch.
+const MaxVoidBatch = 500
+
+// Overrides holds the fields changed on a cloned invoice. Nil fields are copied from the
+// source invoice.
+type Overrides struct {
+	SalesOrderID *int
+	CustomerID   *int
+	Amount       *float64
+}
+
+// VoidResult describes a completed void.
+type VoidResult struct {
+	IDs    []int  `json:"voided"`
+	Reason string `json:"reason"`
+}
+
+// Service applies the invoice rules on top of an InvoiceStore.
+type Service struct {
+	Store models.InvoiceStore
+}
+
+// NewService creates an invoice service backed by store.
+func NewService(store models.InvoiceStore) *Service {
+	return &Service{Store: store}
+}
+
+// Create stores invoice as a new draft, whatever status it was given.
+func (s *Service) Create(ctx context.Context, invoice *models.Invoice) error {
+	invoice.Status = models.InvoiceStatusDraft
+	return s.Store.CreateInvoice(ctx, invoice)
+}
+
+// Get returns the invoice with the given ID.
+func (s *Service) Get(ctx context.Context, id int) (*models.Invoice, error) {
+	return s.Store.GetInvoiceByID(ctx, id)
+}
+
+// List returns a page of invoices and the number matching the filters.
+func (s *Service) List(ctx context.Context, query models.ListQuery) ([]models.Invoice, int, error) {
+	return s.Store.ListInvoices(ctx, query)
+}
+
+// Update changes a draft invoice. The status is kept as a draft; invoices change status
+// only through Post and Void. It returns models.ErrDocumentLocked if the invoice is no
+// longer a draft.
+func (s *Service) Update(ctx context.Context, invoice *models.Invoice) error {
+	invoice.Status = models.InvoiceStatusDraft
+	return s.Store.UpdateInvoice(ctx, invoice)
+}
+
+// Delete removes a draft invoice. It returns models.ErrDocumentLocked if the invoice is no
+// longer a draft.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	return s.Store.DeleteInvoice(ctx, id)
+}
+
+// Post validates a draft invoice, records its ledger transactions and locks it. The store
+// does all of this in one transaction.
+func (s *Service) Post(ctx context.Context, id int) (*models.Invoice, error) {
+	return s.Store.PostInvoice(ctx, id)
+}
+
+// Clone copies the invoice with the given ID as a new draft, applying overrides. The copy
+// starts as a draft regardless of the source invoice's status.
+func (s *Service) Clone(ctx context.Context, id int, overrides Overrides) (*models.Invoice, error) {
+	source, err := s.Store.GetInvoiceByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := models.Invoice{
+		SalesOrderID: source.SalesOrderID,
+		CustomerID:   source.CustomerID,
+		Amount:       source.Amount,
+	}
+	if overrides.SalesOrderID != nil {
+		clone.SalesOrderID = *overrides.SalesOrderID
+	}
+	if overrides.CustomerID != nil {
+		clone.CustomerID = *overrides.CustomerID
+	}
+	if overrides.Amount != nil {
+		clone.Amount = *overrides.Amount
+	}
+
+	if err := s.Create(ctx, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// Void voids a batch of draft invoices on behalf of actor. The reason is required and
+// recorded in the audit log with the actor. Duplicate IDs are voided once. Nothing is voided if any invoice is missing or not voidable; the store then
+// returns a *models.BatchRejectedError.
+func (s *Service) Void(ctx context.Context, ids []int, reason, actor string) (*VoidResult, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, models.Invalid("a reason is required to void invoices")
+	}
+	if len(ids) == 0 || len(ids) > MaxVoidBatch {
+		return nil, models.Invalid("between 1 and %d invoice IDs are required", MaxVoidBatch)
+	}
+
+	// Drop duplicate IDs so each invoice is voided and audited once
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	if err := s.Store.VoidInvoices(ctx, unique, reason, actor); err != nil {
+		return nil, err
+	}
+	return &VoidResult{IDs: unique, Reason: reason}, nil
+}
@@ -0,0 +1,143 @@
+package invoicing
+
+import (
+	"context"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryInvoiceStore keeps invoices in memory and records the last void.
+type memoryInvoiceStore struct {
+	invoices   map[int]*models.Invoice
+	nextID     int
+	voidedIDs  []int
+	voidReason string
+	voidActor  string
+}
+
+func newMemoryInvoiceStore() *memoryInvoiceStore {
+	return &memoryInvoiceStore{invoices: map[int]*models.Invoice{}, nextID: 1}
+}
+
+func (m *memoryInvoiceStore) CreateInvoice(ctx context.Context, invoice *models.Invoice) error {
+	invoice.ID = m.nextID
+	m.nextID++
+	stored := *invoice
+	m.invoices[invoice.ID] = &stored
+	return nil
+}
+
+func (m *memoryInvoiceStore) GetInvoiceByID(ctx context.Context, id int) (*models.Invoice, error) {
+	invoice, ok := m.invoices[id]
+	if !ok {
+		return nil, models.NotFound("invoice %d not found", id)
+	}
+	copied := *invoice
+	return &copied, nil
+}
+
+func (m *memoryInvoiceStore) UpdateInvoice(ctx context.Context, invoice *models.Invoice) error {
+	stored, ok := m.invoices[invoice.ID]
+	if !ok {
+		return models.NotFound("invoice %d not found", invoice.ID)
+	}
+	if stored.Status != models.InvoiceStatusDraft {
+		return models.ErrDocumentLocked
+	}
+	*stored = *invoice
+	return nil
+}
+
+func (m *memoryInvoiceStore) DeleteInvoice(ctx context.Context, id int) error {
+	delete(m.invoices, id)
+	return nil
+}
+
+func (m *memoryInvoiceStore) VoidInvoices(ctx context.Context, ids []int, reason, actor string) error {
+	m.voidedIDs, m.voidReason, m.voidActor = ids, reason, actor
+	return nil
+}
+
+func (m *memoryInvoiceStore) ListInvoices(ctx context.Context, query models.ListQuery) ([]models.Invoice, int, error) {
+	invoices := []models.Invoice{}
+	for id := 1; id < m.nextID; id++ {
+		if invoice, ok := m.invoices[id]; ok {
+			invoices = append(invoices, *invoice)
+		}
+	}
+	return invoices, len(invoices), nil
+}
+
+func (m *memoryInvoiceStore) PostInvoice(ctx context.Context, id int) (*models.Invoice, error) {
+	invoice, err := m.GetInvoiceByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := invoice.ValidateForPosting(); err != nil {
+		return nil, err
+	}
+	m.invoices[id].Status = models.InvoiceStatusPosted
+	return m.GetInvoiceByID(ctx, id)
+}
+
+func TestCreateAndUpdateKeepDrafts(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryInvoiceStore()
+	service := NewService(store)
+
+	invoice := models.Invoice{CustomerID: 1, Amount: 100, Status: models.InvoiceStatusPosted}
+	require.NoError(t, service.Create(ctx, &invoice))
+	assert.Equal(t, models.InvoiceStatusDraft, store.invoices[invoice.ID].Status, "New invoices are always drafts")
+
+	invoice.Status = models.InvoiceStatusVoid
+	require.NoError(t, service.Update(ctx, &invoice))
+	assert.Equal(t, models.InvoiceStatusDraft, store.invoices[invoice.ID].Status, "Updates cannot change the status")
+
+	_, err := service.Post(ctx, invoice.ID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.Update(ctx, &invoice), models.ErrDocumentLocked)
+}
+
+func TestCloneAppliesOverrides(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryInvoiceStore()
+	service := NewService(store)
+	source := models.Invoice{SalesOrderID: 7, CustomerID: 1, Amount: 100}
+	require.NoError(t, service.Create(ctx, &source))
+	_, err := service.Post(ctx, source.ID)
+	require.NoError(t, err)
+
+	amount := 250.0
+	clone, err := service.Clone(ctx, source.ID, Overrides{Amount: &amount})
+	require.NoError(t, err)
+	assert.NotEqual(t, source.ID, clone.ID)
+	assert.Equal(t, models.Invoice{ID: clone.ID, SalesOrderID: 7, CustomerID: 1, Amount: 250, Status: models.InvoiceStatusDraft}, *clone)
+
+	_, err = service.Clone(ctx, 99, Overrides{})
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestVoidValidatesAndDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryInvoiceStore()
+	service := NewService(store)
+
+	_, err := service.Void(ctx, []int{1}, "   ", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "A reason is required")
+	_, err = service.Void(ctx, nil, "duplicate", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "At least one ID is required")
+	_, err = service.Void(ctx, make([]int, MaxVoidBatch+1), "duplicate", "finance@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation, "Batches are limited")
+	assert.Nil(t, store.voidedIDs, "Invalid requests never reach the store")
+
+	result, err := service.Void(ctx, []int{3, 1, 3}, " duplicate ", "finance@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, &VoidResult{IDs: []int{3, 1}, Reason: "duplicate"}, result)
+	assert.Equal(t, []int{3, 1}, store.voidedIDs)
+	assert.Equal(t, "duplicate", store.voidReason)
+	assert.Equal(t, "finance@example.com", store.voidActor)
+}
@@ -0,0 +1,181 @@
+// Package jobs runs long tasks, such as backups and bulk imports, in the background and
+// records their status and progress in the jobs table so clients can poll for the outcome.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"erp/models"
+)
+
+// progressInterval limits how often progress is written to the database.
+const progressInterval = time.Second
+
+// Func is the work of a job. It reports progress through p and returns a result that is
+// stored as JSON with the job. ctx is not cancelled when the request that started the job
+// finishes.
+type Func func(ctx context.Context, p *Progress) (interface{}, error)
+
+// Runner starts jobs and records their outcome.
+type Runner struct {
+	Store models.JobStore
+	Now   func() time.Time // Clock, replaced in tests
+
+	wg sync.WaitGroup
+}
+
+// NewRunner creates a job runner.
+func NewRunner(store models.JobStore) *Runner {
+	return &Runner{Store: store, Now: time.Now}
+}
+
+// Start creates a queued job and runs fn in the background.
+//
+// Parameters:
+//   - ctx: The context of the request that starts the job. fn keeps its values but is not
+//     cancelled with it.
+//   - kind: The kind of job, e.g. "backup".
+//   - actor: Email of the user who started the job, or "scheduler".
+//   - fn: The work to perform.
+//
+// Returns:
+//   - *Job: The queued job, with its ID set.
+//   - error: An error if the job cannot be created.
+func (r *Runner) Start(ctx context.Context, kind, actor string, fn Func) (*models.Job, error) {
+	job, err := r.create(ctx, kind, actor)
+	if err != nil {
+		return nil, err
+	}
+	queued := *job
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(context.WithoutCancel(ctx), job, fn)
+	}()
+	return &queued, nil
+}
+
+// Run creates a job and runs fn before returning, for callers such as the scheduler that
+// are already in the background.
+//
+// Returns:
+//   - *Job: The finished job.
+//   - error: An error if the job cannot be created, or the error returned by fn.
+func (r *Runner) Run(ctx context.Context, kind, actor string, fn Func) (*models.Job, error) {
+	job, err := r.create(ctx, kind, actor)
+	if err != nil {
+		return nil, err
+	}
+	return job, r.run(ctx, job, fn)
+}
+
+// Wait blocks until every job started with Start has finished.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// create stores a new queued job.
+func (r *Runner) create(ctx context.Context, kind, actor string) (*models.Job, error) {
+	job := &models.Job{Kind: kind, Status: models.JobQueued, CreatedBy: actor, CreatedAt: r.Now()}
+	if err := r.Store.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// run executes fn and stores its outcome. A panic in fn fails the job instead of taking
+// down the server.
+func (r *Runner) run(ctx context.Context, job *models.Job, fn Func) (err error) {
+	if err := r.Store.StartJob(ctx, job.ID, r.Now()); err != nil {
+		log.Printf("jobs: could not start %s job %d: %v", job.Kind, job.ID, err)
+	}
+
+	progress := &Progress{runner: r, jobID: job.ID, ctx: ctx}
+	var result interface{}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+		progress.flush()
+		r.finish(ctx, job, result, err)
+	}()
+	result, err = fn(ctx, progress)
+	return err
+}
+
+// finish stores the result or error of a job and mirrors it on job.
+func (r *Runner) finish(ctx context.Context, job *models.Job, result interface{}, jobErr error) {
+	var raw json.RawMessage
+	if jobErr == nil && result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			jobErr = fmt.Errorf("encode result: %w", err)
+		} else {
+			raw = data
+		}
+	}
+
+	job.Status, job.Result, job.Error = models.JobSucceeded, raw, ""
+	if jobErr != nil {
+		job.Status, job.Error = models.JobFailed, jobErr.Error()
+		log.Printf("jobs: %s job %d failed: %v", job.Kind, job.ID, jobErr)
+	}
+	finishedAt := r.Now()
+	job.FinishedAt = &finishedAt
+	if err := r.Store.FinishJob(ctx, job.ID, raw, job.Error, finishedAt); err != nil {
+		log.Printf("jobs: could not finish %s job %d: %v", job.Kind, job.ID, err)
+	}
+}
+
+// Progress reports how much of a job is done. Updates are written at most once per second.
+// The zero value counts progress without storing it, for work run outside of a job.
+type Progress struct {
+	runner    *Runner
+	jobID     int
+	ctx       context.Context // The job's context, for progress writes
+	processed int64
+	total     int64
+	written   time.Time
+	dirty     bool
+}
+
+// JobID returns the ID of the job being run, or 0 for work run outside of a job.
+func (p *Progress) JobID() int {
+	return p.jobID
+}
+
+// SetTotal sets the expected amount of work.
+func (p *Progress) SetTotal(total int64) {
+	p.total = total
+	p.update()
+}
+
+// Add records n more units of work as done.
+func (p *Progress) Add(n int64) {
+	p.processed += n
+	p.update()
+}
+
+// update writes the progress if the last write is old enough.
+func (p *Progress) update() {
+	p.dirty = true
+	if p.runner != nil && p.runner.Now().Sub(p.written) >= progressInterval {
+		p.flush()
+	}
+}
+
+// flush writes pending progress to the store.
+func (p *Progress) flush() {
+	if !p.dirty || p.runner == nil {
+		return
+	}
+	p.dirty, p.written = false, p.runner.Now()
+	if err := p.runner.Store.UpdateJobProgress(p.ctx, p.jobID, p.processed, p.total); err != nil {
+		log.Printf("jobs: could not update progress of job %d: %v", p.jobID, err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package kpi watches business metrics against thresholds set by administrators. A
+// scheduled job measures each metric and records an alert, which notifies the finance
+// roles, whenever a rule crosses its threshold.
+package kpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Alert history page sizes
+const (
+	DefaultAlertLimit = 50
+	MaxAlertLimit     = 500
+)
+
+// Service manages KPI rules and evaluates them.
+type Service struct {
+	Store models.KPIStore
+}
+
+// NewService creates a KPI service backed by store.
+func NewService(store models.KPIStore) *Service {
+	return &Service{Store: store}
+}
+
+// Validate checks that a rule has a name, a known metric and a known comparison.
+func Validate(rule *models.KPIRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return models.Invalid("name is required")
+	}
+	if !models.KPIMetrics[rule.Metric] {
+		return models.Invalid("unknown metric %q", rule.Metric)
+	}
+	if _, ok := models.KPIOperators[rule.Operator]; !ok {
+		return models.Invalid("operator must be one of >, >=, < or <=")
+	}
+	return nil
+}
+
+// Create stores a new rule after validating it.
+func (s *Service) Create(ctx context.Context, rule *models.KPIRule) error {
+	if err := Validate(rule); err != nil {
+		return err
+	}
+	rule.Breached = false
+	rule.CreatedAt = time.Now()
+	return s.Store.CreateKPIRule(ctx, rule)
+}
+
+// Update changes a rule after validating it. Its breach state is cleared.
+func (s *Service) Update(ctx context.Context, rule *models.KPIRule) error {
+	if err := Validate(rule); err != nil {
+		return err
+	}
+	return s.Store.UpdateKPIRule(ctx, rule)
+}
+
+// Get returns a rule by ID.
+func (s *Service) Get(ctx context.Context, id int) (*models.KPIRule, error) {
+	return s.Store.GetKPIRule(ctx, id)
+}
+
+// List returns every rule.
+func (s *Service) List(ctx context.Context) ([]models.KPIRule, error) {
+	return s.Store.ListKPIRules(ctx, false)
+}
+
+// Delete removes a rule. Its alerts are kept.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	return s.Store.DeleteKPIRule(ctx, id)
+}
+
+// Alert returns an alert by ID.
+func (s *Service) Alert(ctx context.Context, id int) (*models.KPIAlert, error) {
+	return s.Store.GetKPIAlert(ctx, id)
+}
+
+// Alerts returns the newest alerts of a rule, or of all rules when ruleID is 0. A limit
+// of 0 means DefaultAlertLimit.
+func (s *Service) Alerts(ctx context.Context, ruleID, limit int) ([]models.KPIAlert, error) {
+	if limit == 0 {
+		limit = DefaultAlertLimit
+	}
+	if limit < 0 || limit > MaxAlertLimit {
+		return nil, models.Invalid("limit must be between 1 and %d", MaxAlertLimit)
+	}
+	return s.Store.ListKPIAlerts(ctx, ruleID, limit)
+}
+
+// Evaluate measures the metric of every active rule, once per metric, and records the
+// results. A rule that fails to evaluate does not stop the others.
+//
+// Returns:
+//   - []models.KPIAlert: The alerts raised by this evaluation.
+//   - error: The joined errors of the rules that could not be evaluated.
+func (s *Service) Evaluate(ctx context.Context, now time.Time) ([]models.KPIAlert, error) {
+	rules, err := s.Store.ListKPIRules(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	type measurement struct {
+		value float64
+		ok    bool
+		err   error
+	}
+	measured := make(map[string]measurement)
+	alerts := []models.KPIAlert{}
+	var errs []error
+	for i := range rules {
+		rule := &rules[i]
+		m, done := measured[rule.Metric]
+		if !done {
+			m.value, m.ok, m.err = s.Store.MeasureKPI(ctx, rule.Metric, now)
+			measured[rule.Metric] = m
+		}
+		if m.err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", rule.ID, m.err))
+			continue
+		}
+		if !m.ok {
+			continue
+		}
+		alert, err := s.Store.RecordKPIEvaluation(ctx, rule, m.value, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", rule.ID, err))
+			continue
+		}
+		if alert != nil {
+			log.Printf("kpi: %s", alert.Message)
+			alerts = append(alerts, *alert)
+		}
+	}
+	return alerts, errors.Join(errs...)
+}
@@ -0,0 +1,161 @@
+package kpi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryKPIStore keeps rules in memory and returns fixed metric values.
+type memoryKPIStore struct {
+	rules    []models.KPIRule
+	values   map[string]float64 // Metrics without a value are unavailable
+	failing  map[string]bool
+	measured map[string]int
+	alerts   []models.KPIAlert
+}
+
+func newMemoryKPIStore(values map[string]float64) *memoryKPIStore {
+	return &memoryKPIStore{values: values, failing: map[string]bool{}, measured: map[string]int{}}
+}
+
+func (m *memoryKPIStore) CreateKPIRule(ctx context.Context, rule *models.KPIRule) error {
+	rule.ID = len(m.rules) + 1
+	m.rules = append(m.rules, *rule)
+	return nil
+}
+
+func (m *memoryKPIStore) GetKPIRule(ctx context.Context, id int) (*models.KPIRule, error) {
+	for i := range m.rules {
+		if m.rules[i].ID == id {
+			rule := m.rules[i]
+			return &rule, nil
+		}
+	}
+	return nil, models.NotFound("KPI rule %d not found", id)
+}
+
+func (m *memoryKPIStore) ListKPIRules(ctx context.Context, activeOnly bool) ([]models.KPIRule, error) {
+	rules := []models.KPIRule{}
+	for _, rule := range m.rules {
+		if rule.Active || !activeOnly {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (m *memoryKPIStore) UpdateKPIRule(ctx context.Context, rule *models.KPIRule) error { return nil }
+
+func (m *memoryKPIStore) DeleteKPIRule(ctx context.Context, id int) error { return nil }
+
+func (m *memoryKPIStore) MeasureKPI(ctx context.Context, metric string, now time.Time) (float64, bool, error) {
+	m.measured[metric]++
+	if m.failing[metric] {
+		return 0, false, errors.New("query failed")
+	}
+	value, ok := m.values[metric]
+	return value, ok, nil
+}
+
+func (m *memoryKPIStore) RecordKPIEvaluation(ctx context.Context, rule *models.KPIRule, value float64, now time.Time) (*models.KPIAlert, error) {
+	stored := &m.rules[rule.ID-1]
+	breached := rule.Breaches(value)
+	entered := breached && !stored.Breached
+	stored.Breached = breached
+	if !entered {
+		return nil, nil
+	}
+	alert := rule.Alert(value, now)
+	alert.ID = len(m.alerts) + 1
+	m.alerts = append(m.alerts, *alert)
+	return alert, nil
+}
+
+func (m *memoryKPIStore) GetKPIAlert(ctx context.Context, id int) (*models.KPIAlert, error) {
+	return nil, nil
+}
+
+func (m *memoryKPIStore) ListKPIAlerts(ctx context.Context, ruleID, limit int) ([]models.KPIAlert, error) {
+	return m.alerts, nil
+}
+
+func TestCreateValidatesRules(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(newMemoryKPIStore(nil))
+	invalid := []models.KPIRule{
+		{Name: " ", Metric: models.KPIReceivables, Operator: ">"},
+		{Name: "Margin", Metric: "gross_margin", Operator: ">"},
+		{Name: "Receivables", Metric: models.KPIReceivables, Operator: "=="},
+	}
+	for _, rule := range invalid {
+		assert.ErrorIs(t, service.Create(ctx, &rule), models.ErrValidation, rule.Name)
+	}
+
+	rule := models.KPIRule{Name: " High receivables ", Metric: models.KPIReceivables, Operator: ">", Threshold: 50000, Active: true}
+	require.NoError(t, service.Create(ctx, &rule))
+	assert.Equal(t, "High receivables", rule.Name)
+	assert.False(t, rule.CreatedAt.IsZero())
+}
+
+func TestEvaluateAlertsWhenRulesEnterBreach(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryKPIStore(map[string]float64{models.KPIReceivables: 62000, models.KPIRevenueDropPct: 4})
+	service := NewService(store)
+	for _, rule := range []models.KPIRule{
+		{Name: "High receivables", Metric: models.KPIReceivables, Operator: ">", Threshold: 50000, Active: true},
+		{Name: "Very high receivables", Metric: models.KPIReceivables, Operator: ">", Threshold: 100000, Active: true},
+		{Name: "Revenue drop", Metric: models.KPIRevenueDropPct, Operator: ">", Threshold: 10, Active: true},
+		{Name: "Slow stock", Metric: models.KPIStockTurnover, Operator: "<", Threshold: 2, Active: true},
+		{Name: "Paused", Metric: models.KPIMonthlyRevenue, Operator: "<", Threshold: 1, Active: false},
+	} {
+		require.NoError(t, service.Create(ctx, &rule))
+	}
+
+	alerts, err := service.Evaluate(ctx, time.Now())
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "High receivables: receivables is 62000.00 (> 50000.00)", alerts[0].Message)
+	assert.Equal(t, 1, store.measured[models.KPIReceivables], "Each metric is measured once per evaluation")
+	assert.Zero(t, store.measured[models.KPIMonthlyRevenue], "Inactive rules are not evaluated")
+
+	alerts, err = service.Evaluate(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, alerts, "A rule still in breach does not alert again")
+
+	store.values[models.KPIReceivables] = 40000
+	_, err = service.Evaluate(ctx, time.Now())
+	require.NoError(t, err)
+	store.values[models.KPIReceivables] = 55000
+	alerts, err = service.Evaluate(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1, "A recovered rule alerts on its next breach")
+}
+
+func TestEvaluateContinuesAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryKPIStore(map[string]float64{models.KPIMonthlyRevenue: 500})
+	store.failing[models.KPIReceivables] = true
+	service := NewService(store)
+	require.NoError(t, service.Create(ctx, &models.KPIRule{Name: "Receivables", Metric: models.KPIReceivables, Operator: ">", Active: true}))
+	require.NoError(t, service.Create(ctx, &models.KPIRule{Name: "Low revenue", Metric: models.KPIMonthlyRevenue, Operator: "<", Threshold: 1000, Active: true}))
+
+	alerts, err := service.Evaluate(ctx, time.Now())
+	assert.ErrorContains(t, err, "rule 1: query failed")
+	assert.Len(t, alerts, 1)
+}
+
+func TestAlertsLimit(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(newMemoryKPIStore(nil))
+	_, err := service.Alerts(ctx, 0, MaxAlertLimit+1)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = service.Alerts(ctx, 0, 0)
+	assert.NoError(t, err)
+}
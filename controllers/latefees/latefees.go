@@ -0,0 +1,283 @@
+// Package latefees charges customers for paying late. A late fee rule gives the payment
+// terms and grace period of invoices and either an interest rate or a flat fee. Each day,
+// posted invoices still owing past their due date and grace period are charged: interest on
+// the balance for each month that has ended, or the flat fee once. Every charge is billed
+// on a posted penalty invoice, so it shows on the customer's statement and is collected
+// like any other invoice.
+package latefees
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// IncomeAccount is the account late fees are credited to.
+const IncomeAccount = "late_fee_income"
+
+// Service validates late fee rules and charges late fees.
+type Service struct {
+	Store models.LateFeeStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a late fee service.
+func NewService(store models.LateFeeStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Rules returns the late fee rules.
+func (s *Service) Rules(ctx context.Context) ([]models.LateFeeRule, error) {
+	return s.Store.GetLateFeeRules(ctx)
+}
+
+// CreateRule checks a rule and saves it.
+//
+// Parameters:
+//   - rule: The rule; its ID and update time are set.
+//   - actor: Email of the user creating the rule.
+//
+// Returns:
+//   - error: A validation error if the rule is incomplete, a conflict if its customer
+//     already has a rule, or the store's error.
+func (s *Service) CreateRule(ctx context.Context, rule *models.LateFeeRule, actor string) error {
+	if err := Validate(rule); err != nil {
+		return err
+	}
+	rule.UpdatedBy, rule.UpdatedAt = actor, s.Now()
+	return s.Store.CreateLateFeeRule(ctx, rule)
+}
+
+// UpdateRule checks a rule and replaces the stored one. Fees already charged are not changed.
+func (s *Service) UpdateRule(ctx context.Context, rule *models.LateFeeRule, actor string) error {
+	if err := Validate(rule); err != nil {
+		return err
+	}
+	rule.UpdatedBy, rule.UpdatedAt = actor, s.Now()
+	return s.Store.UpdateLateFeeRule(ctx, rule)
+}
+
+// DeleteRule removes a rule. Fees already charged are not changed.
+func (s *Service) DeleteRule(ctx context.Context, id int) error {
+	return s.Store.DeleteLateFeeRule(ctx, id)
+}
+
+// Validate checks a rule's name, method and amounts. The amount of the other method is
+// cleared.
+func Validate(rule *models.LateFeeRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	switch {
+	case rule.Name == "":
+		return models.Invalid("name is required")
+	case rule.PaymentTermsDays < 0 || rule.GraceDays < 0:
+		return models.Invalid("payment_terms_days and grace_days cannot be negative")
+	case rule.MinimumBalance < 0:
+		return models.Invalid("minimum_balance cannot be negative")
+	}
+	switch rule.Method {
+	case models.LateFeeInterest:
+		if rule.AnnualRate <= 0 || rule.AnnualRate > 100 {
+			return models.Invalid("annual_rate must be a percentage above 0 and at most 100")
+		}
+		rule.FlatFee = 0
+	case models.LateFeeFlat:
+		if rule.FlatFee <= 0 {
+			return models.Invalid("flat_fee must be positive")
+		}
+		rule.AnnualRate = 0
+	default:
+		return models.Invalid("method must be %q or %q", models.LateFeeInterest, models.LateFeeFlat)
+	}
+	return nil
+}
+
+// Preview returns the late fees a run would charge now, without charging them.
+func (s *Service) Preview(ctx context.Context) ([]models.LateFeeCharge, error) {
+	invoices, err := s.Store.GetOverdueInvoices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.Store.GetLateFeeRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.Now()
+	charges := []models.LateFeeCharge{}
+	for _, invoice := range invoices {
+		if rule := ruleFor(rules, invoice.CustomerID); rule != nil {
+			charges = append(charges, Assess(*rule, invoice, now)...)
+		}
+	}
+	return charges, nil
+}
+
+// Run charges the late fees due now, each on a penalty invoice. Fees another run charged
+// in the meantime are skipped.
+//
+// Returns:
+//   - []models.LateFeeCharge: The fees charged.
+//   - error: The errors of fees that could not be charged, joined; the others are charged.
+func (s *Service) Run(ctx context.Context) ([]models.LateFeeCharge, error) {
+	due, err := s.Preview(ctx)
+	if err != nil {
+		return nil, err
+	}
+	charged := []models.LateFeeCharge{}
+	var errs []error
+	for i := range due {
+		err := s.Store.ChargeLateFee(ctx, &due[i])
+		switch {
+		case models.Kind(err) == models.ErrConflict:
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("late fee for invoice %d: %w", due[i].InvoiceID, err))
+		default:
+			charged = append(charged, due[i])
+		}
+	}
+	return charged, errors.Join(errs...)
+}
+
+// Daily runs the late fees; it is run by the scheduler.
+func (s *Service) Daily(ctx context.Context) error {
+	_, err := s.Run(ctx)
+	return err
+}
+
+// Charges lists the late fees charged for an invoice, or every late fee if invoiceID is 0.
+func (s *Service) Charges(ctx context.Context, invoiceID int) ([]models.LateFeeCharge, error) {
+	return s.Store.GetLateFeeCharges(ctx, invoiceID)
+}
+
+// Statement returns a customer's statement for a period, with the late fees charged in it.
+// Without to, the statement runs to today; without from, from the first of to's month.
+//
+// Parameters:
+//   - customerID: The ID of the customer.
+//   - from: The first day of the period, or the zero time.
+//   - to: The last day of the period, or the zero time.
+//
+// Returns:
+//   - *models.CustomerStatement: The statement with running balances.
+//   - error: A validation error if from is after to, models.ErrNotFound if the customer does
+//     not exist, or the store's error.
+func (s *Service) Statement(ctx context.Context, customerID int, from, to time.Time) (*models.CustomerStatement, error) {
+	if to.IsZero() {
+		to = s.Now()
+	}
+	to = date(to)
+	if from.IsZero() {
+		from = time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	from = date(from)
+	if from.After(to) {
+		return nil, models.Invalid("from must not be after to")
+	}
+	statement, err := s.Store.GetCustomerStatement(ctx, customerID, to)
+	if err != nil {
+		return nil, err
+	}
+	Balance(statement, from, to)
+	return statement, nil
+}
+
+// Balance works out the balances of a statement whose lines run up to to. Lines before
+// from are summed into the opening balance and dropped.
+func Balance(statement *models.CustomerStatement, from, to time.Time) {
+	statement.From, statement.To = from, to
+	statement.OpeningBalance, statement.LateFees = 0, 0
+	lines := []models.StatementLine{}
+	balance := 0.0
+	for _, line := range statement.Lines {
+		balance = roundCents(balance + line.Debit - line.Credit)
+		if date(line.Date).Before(from) {
+			statement.OpeningBalance = balance
+			continue
+		}
+		if line.Type == models.StatementLateFee {
+			statement.LateFees = roundCents(statement.LateFees + line.Debit)
+		}
+		line.Balance = balance
+		lines = append(lines, line)
+	}
+	statement.Lines = lines
+	statement.ClosingBalance = balance
+}
+
+// ruleFor returns the active rule of a customer, or else the active rule for all customers.
+func ruleFor(rules []models.LateFeeRule, customerID int) *models.LateFeeRule {
+	var general *models.LateFeeRule
+	for i := range rules {
+		rule := &rules[i]
+		switch {
+		case !rule.Active:
+		case rule.CustomerID == nil:
+			general = rule
+		case *rule.CustomerID == customerID:
+			return rule
+		}
+	}
+	return general
+}
+
+// Assess works out the late fees due on an invoice under a rule. Nothing is due until the
+// grace period after the due date has passed, or on balances below the rule's minimum.
+// Interest is charged from the due date, or from the end of the period last charged, to
+// the start of the current month, on the balance now.
+func Assess(rule models.LateFeeRule, invoice models.OverdueInvoice, now time.Time) []models.LateFeeCharge {
+	today := date(now)
+	due := date(invoice.PostedOn).AddDate(0, 0, rule.PaymentTermsDays)
+	if !today.After(due.AddDate(0, 0, rule.GraceDays)) || invoice.Balance <= 0 || invoice.Balance < rule.MinimumBalance {
+		return nil
+	}
+	charge := models.LateFeeCharge{
+		InvoiceID:  invoice.InvoiceID,
+		CustomerID: invoice.CustomerID,
+		RuleID:     rule.ID,
+		Method:     rule.Method,
+		Balance:    invoice.Balance,
+		ChargedAt:  now,
+	}
+
+	switch rule.Method {
+	case models.LateFeeFlat:
+		if invoice.FlatCharged {
+			return nil
+		}
+		charge.Amount = roundCents(rule.FlatFee)
+	case models.LateFeeInterest:
+		from := due
+		if invoice.InterestThrough != nil && date(*invoice.InterestThrough).After(from) {
+			from = date(*invoice.InterestThrough)
+		}
+		to := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if !to.After(from) {
+			return nil
+		}
+		days := math.Round(to.Sub(from).Hours() / 24)
+		charge.Amount = roundCents(invoice.Balance * rule.AnnualRate / 100 * days / 365)
+		charge.PeriodFrom, charge.PeriodTo = &from, &to
+	default:
+		return nil
+	}
+	if charge.Amount <= 0 {
+		return nil
+	}
+	return []models.LateFeeCharge{charge}
+}
+
+// date returns the day of t at midnight UTC, so that days can be counted across zones.
+func date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
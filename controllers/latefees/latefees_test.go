@@ -0,0 +1,186 @@
+package latefees
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps rules, overdue invoices and charges in memory. Charges made for an invoice
+// listed in conflicts fail as if another run had charged them.
+type fakeStore struct {
+	rules     []models.LateFeeRule
+	invoices  []models.OverdueInvoice
+	charges   []models.LateFeeCharge
+	conflicts map[int]bool
+	statement models.CustomerStatement
+}
+
+func (f *fakeStore) CreateLateFeeRule(ctx context.Context, rule *models.LateFeeRule) error {
+	rule.ID = len(f.rules) + 1
+	f.rules = append(f.rules, *rule)
+	return nil
+}
+
+func (f *fakeStore) GetLateFeeRules(ctx context.Context) ([]models.LateFeeRule, error) {
+	return f.rules, nil
+}
+
+func (f *fakeStore) UpdateLateFeeRule(ctx context.Context, rule *models.LateFeeRule) error {
+	return nil
+}
+
+func (f *fakeStore) DeleteLateFeeRule(ctx context.Context, id int) error { return nil }
+
+func (f *fakeStore) GetOverdueInvoices(ctx context.Context) ([]models.OverdueInvoice, error) {
+	return f.invoices, nil
+}
+
+func (f *fakeStore) ChargeLateFee(ctx context.Context, charge *models.LateFeeCharge) error {
+	if f.conflicts[charge.InvoiceID] {
+		return models.Conflict("charged already")
+	}
+	charge.ID = len(f.charges) + 1
+	charge.PenaltyInvoiceID = 100 + charge.ID
+	f.charges = append(f.charges, *charge)
+	return nil
+}
+
+func (f *fakeStore) GetLateFeeCharges(ctx context.Context, invoiceID int) ([]models.LateFeeCharge, error) {
+	return f.charges, nil
+}
+
+func (f *fakeStore) GetCustomerStatement(ctx context.Context, customerID int, to time.Time) (*models.CustomerStatement, error) {
+	statement := f.statement
+	statement.Lines = append([]models.StatementLine(nil), f.statement.Lines...)
+	return &statement, nil
+}
+
+func day(value string) time.Time {
+	t, _ := time.Parse("2006-01-02", value)
+	return t
+}
+
+func TestAssessInterest(t *testing.T) {
+	rule := models.LateFeeRule{ID: 1, Method: models.LateFeeInterest, PaymentTermsDays: 30, GraceDays: 5, AnnualRate: 12}
+	invoice := models.OverdueInvoice{InvoiceID: 7, CustomerID: 3, PostedOn: day("2024-01-01"), Balance: 1000}
+
+	// Due on 31 January; nothing is charged within the grace period
+	assert.Empty(t, Assess(rule, invoice, day("2024-02-05")))
+
+	// After the grace period, interest runs from the due date to the start of the month
+	charges := Assess(rule, invoice, day("2024-02-06"))
+	require.Len(t, charges, 1)
+	assert.Equal(t, day("2024-01-31"), *charges[0].PeriodFrom)
+	assert.Equal(t, day("2024-02-01"), *charges[0].PeriodTo)
+	assert.Equal(t, 0.33, charges[0].Amount) // 1000 × 12% × 1/365
+	assert.Equal(t, 7, charges[0].InvoiceID)
+	assert.Equal(t, 1, charges[0].RuleID)
+
+	// Periods already charged are not charged again
+	through := day("2024-02-01")
+	invoice.InterestThrough = &through
+	assert.Empty(t, Assess(rule, invoice, day("2024-02-20")))
+	charges = Assess(rule, invoice, day("2024-03-02"))
+	require.Len(t, charges, 1)
+	assert.Equal(t, day("2024-03-01"), *charges[0].PeriodTo)
+	assert.Equal(t, 9.53, charges[0].Amount) // 1000 × 12% × 29/365
+}
+
+func TestAssessFlat(t *testing.T) {
+	rule := models.LateFeeRule{Method: models.LateFeeFlat, PaymentTermsDays: 14, FlatFee: 25, MinimumBalance: 50}
+	invoice := models.OverdueInvoice{InvoiceID: 7, PostedOn: day("2024-01-01"), Balance: 200}
+
+	charges := Assess(rule, invoice, day("2024-01-16"))
+	require.Len(t, charges, 1)
+	assert.Equal(t, 25.0, charges[0].Amount)
+	assert.Nil(t, charges[0].PeriodFrom)
+
+	invoice.FlatCharged = true
+	assert.Empty(t, Assess(rule, invoice, day("2024-01-16")), "flat fees are charged once")
+
+	invoice.FlatCharged, invoice.Balance = false, 40
+	assert.Empty(t, Assess(rule, invoice, day("2024-01-16")), "balances below the minimum are not charged")
+}
+
+func TestValidate(t *testing.T) {
+	rule := models.LateFeeRule{Name: " Standard ", Method: models.LateFeeFlat, FlatFee: 10, AnnualRate: 5}
+	require.NoError(t, Validate(&rule))
+	assert.Equal(t, "Standard", rule.Name)
+	assert.Zero(t, rule.AnnualRate)
+
+	for _, rule := range []models.LateFeeRule{
+		{Method: models.LateFeeFlat, FlatFee: 10},
+		{Name: "a", Method: "daily"},
+		{Name: "a", Method: models.LateFeeInterest},
+		{Name: "a", Method: models.LateFeeInterest, AnnualRate: 150},
+		{Name: "a", Method: models.LateFeeFlat},
+		{Name: "a", Method: models.LateFeeFlat, FlatFee: 10, GraceDays: -1},
+	} {
+		err := Validate(&rule)
+		assert.ErrorIs(t, err, models.ErrValidation, "%+v", rule)
+	}
+}
+
+func TestRuleFor(t *testing.T) {
+	customer := 3
+	rules := []models.LateFeeRule{
+		{ID: 1, Active: true},
+		{ID: 2, CustomerID: &customer, Active: true},
+	}
+	assert.Equal(t, 2, ruleFor(rules, 3).ID)
+	assert.Equal(t, 1, ruleFor(rules, 4).ID)
+
+	rules[1].Active = false
+	assert.Equal(t, 1, ruleFor(rules, 3).ID, "inactive customer rules fall back to the general rule")
+	rules[0].Active = false
+	assert.Nil(t, ruleFor(rules, 3))
+}
+
+func TestRunSkipsChargedFees(t *testing.T) {
+	store := &fakeStore{
+		rules: []models.LateFeeRule{{ID: 1, Method: models.LateFeeFlat, FlatFee: 15, Active: true}},
+		invoices: []models.OverdueInvoice{
+			{InvoiceID: 7, CustomerID: 3, PostedOn: day("2024-01-01"), Balance: 100},
+			{InvoiceID: 8, CustomerID: 3, PostedOn: day("2024-01-01"), Balance: 100},
+		},
+		conflicts: map[int]bool{8: true},
+	}
+	service := NewService(store)
+	service.Now = func() time.Time { return day("2024-01-10") }
+
+	charged, err := service.Run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, charged, 1)
+	assert.Equal(t, 7, charged[0].InvoiceID)
+	assert.Equal(t, 101, charged[0].PenaltyInvoiceID)
+}
+
+func TestStatement(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{statement: models.CustomerStatement{CustomerID: 3, Lines: []models.StatementLine{
+		{Date: day("2024-01-05"), Type: models.StatementInvoice, InvoiceID: 7, Debit: 500},
+		{Date: day("2024-01-20"), Type: models.StatementPayment, InvoiceID: 7, Credit: 200},
+		{Date: day("2024-02-06"), Type: models.StatementLateFee, InvoiceID: 9, Debit: 3.5},
+		{Date: day("2024-02-10"), Type: models.StatementPayment, InvoiceID: 7, Credit: 100},
+	}}}
+	service := NewService(store)
+	service.Now = func() time.Time { return day("2024-02-15") }
+
+	statement, err := service.Statement(ctx, 3, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, day("2024-02-01"), statement.From)
+	assert.Equal(t, 300.0, statement.OpeningBalance)
+	require.Len(t, statement.Lines, 2)
+	assert.Equal(t, 303.5, statement.Lines[0].Balance)
+	assert.Equal(t, 3.5, statement.LateFees)
+	assert.Equal(t, 203.5, statement.ClosingBalance)
+
+	_, err = service.Statement(ctx, 3, day("2024-03-01"), day("2024-02-01"))
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
@@ -0,0 +1,175 @@
+package leave
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// statusPending is the status of leave requests awaiting approval.
+const statusPending = "Pending"
+
+// Balance returns an employee's balance of every leave type with a policy for the current
+// year, with the entitlement accrued up to today. Employees may read their own balance; HR
+// everyone's.
+//
+// Parameters:
+//   - userID: The employee.
+//   - email: Email of the user asking.
+//   - privileged: Whether the user asking may read every employee's balance.
+//
+// Returns:
+//   - []models.LeaveBalance: One balance per leave type with a policy.
+//   - error: models.ErrValidation if the user is missing, models.ErrNotFound if they do not
+//     exist, models.ErrPermissionDenied if the balance is another employee's, or an error if
+//     the balances cannot be read.
+func (s *Service) Balance(ctx context.Context, userID int, email string, privileged bool) ([]models.LeaveBalance, error) {
+	if userID <= 0 {
+		return nil, models.Invalid("user_id is required")
+	}
+	var owner string
+	err := s.DB.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, userID).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.NotFound("user %d not found", userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !privileged && (email == "" || !strings.EqualFold(owner, email)) {
+		return nil, models.PermissionDenied("the leave balance of user %d belongs to another employee", userID)
+	}
+	now := s.Now()
+	return balances(ctx, s.DB, userID, now.Year(), now, 0)
+}
+
+// Check checks that an employee's balance covers a leave request, in the transaction that
+// saves the request or approves it. Leave spanning New Year is checked against the balance
+// of each year, with what has accrued by its last day in that year; other pending requests
+// are held against the balance. Leave types without a policy, such as unpaid leave, are not
+// limited.
+//
+// Parameters:
+//   - tx: The transaction, in which the employee should be locked.
+//   - request: The leave request.
+//   - requestID: The ID of the request when it is being approved, so it is not held against
+//     itself, or 0 for a new request.
+//
+// Returns:
+//   - error: models.ErrValidation if the dates are reversed or the balance is insufficient,
+//     or an error if the balance cannot be read.
+func Check(ctx context.Context, tx *sql.Tx, request *models.Leave, requestID int) error {
+	start, end := date(request.StartDate), date(request.EndDate)
+	if end.Before(start) {
+		return models.Invalid("end_date must not be before start_date")
+	}
+	for year := start.Year(); year <= end.Year(); year++ {
+		from := maxDate(start, time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC))
+		to := minDate(end, time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC))
+		days := to.Sub(from).Hours()/24 + 1
+
+		list, err := balances(ctx, tx, request.UserID, year, to, requestID)
+		if err != nil {
+			return err
+		}
+		for _, balance := range list {
+			if balance.LeaveType == request.LeaveType && days > balance.Available {
+				return models.Invalid("insufficient %s balance for %d: %g days requested, %g available",
+					request.LeaveType, year, days, balance.Available)
+			}
+		}
+	}
+	return nil
+}
+
+// balances reads an employee's balances of a year, accrued up to asOf. The leave request
+// with ID excludeID is not counted as pending.
+func balances(ctx context.Context, q models.Queryer, userID, year int, asOf time.Time, excludeID int) ([]models.LeaveBalance, error) {
+	list, err := policies(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	adjustments, err := sums(ctx, q,
+		`SELECT user_id, leave_type, SUM(days) FROM leave_adjustments WHERE year = $1 AND user_id = $2
+		 GROUP BY user_id, leave_type`, year, userID)
+	if err != nil {
+		return nil, err
+	}
+	first, last := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	taken, err := sums(ctx, q,
+		`SELECT user_id, leave_type, SUM(LEAST(end_date, $2::date) - GREATEST(start_date, $1::date) + 1)
+		 FROM leave WHERE status = $3 AND start_date <= $2 AND end_date >= $1 AND user_id = $4
+		 GROUP BY user_id, leave_type`, first, last, statusApproved, userID)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := sums(ctx, q,
+		`SELECT user_id, leave_type, SUM(LEAST(end_date, $2::date) - GREATEST(start_date, $1::date) + 1)
+		 FROM leave WHERE status = $3 AND start_date <= $2 AND end_date >= $1 AND user_id = $4 AND id <> $5
+		 GROUP BY user_id, leave_type`, first, last, statusPending, userID, excludeID)
+	if err != nil {
+		return nil, err
+	}
+	return balanceLines(userID, year, asOf, list, adjustments, taken, pending), nil
+}
+
+// balanceLines works out an employee's balance of every leave type with a policy.
+func balanceLines(userID, year int, asOf time.Time, list []models.LeavePolicy,
+	adjustments, taken, pending map[balanceKey]float64) []models.LeaveBalance {
+	lines := []models.LeaveBalance{}
+	for _, policy := range list {
+		key := balanceKey{userID, policy.LeaveType}
+		line := models.LeaveBalance{
+			UserID:      userID,
+			LeaveType:   policy.LeaveType,
+			Year:        year,
+			Accrual:     policy.Accrual,
+			Entitlement: policy.AnnualDays,
+			Accrued:     accrued(policy, year, asOf),
+			Adjustments: adjustments[key],
+			Taken:       taken[key],
+			Pending:     pending[key],
+		}
+		line.Available = round(line.Accrued + line.Adjustments - line.Taken - line.Pending)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// accrued is the part of a policy's entitlement for a year that has accrued by asOf. Monthly
+// accrual credits a twelfth on the 1st of each month, so all of it has accrued by December.
+func accrued(policy models.LeavePolicy, year int, asOf time.Time) float64 {
+	if policy.Accrual != models.LeaveAccrualMonthly {
+		return policy.AnnualDays
+	}
+	months := 12
+	switch {
+	case asOf.Year() < year:
+		months = 0
+	case asOf.Year() == year:
+		months = int(asOf.Month())
+	}
+	return round(policy.AnnualDays * float64(months) / 12)
+}
+
+// date drops the time of day, as leave is stored by date.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func minDate(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxDate(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
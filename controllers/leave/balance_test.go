@@ -0,0 +1,63 @@
+package leave
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalanceLines(t *testing.T) {
+	policies := []models.LeavePolicy{
+		{LeaveType: "Sick Leave", AnnualDays: 10, Accrual: models.LeaveAccrualAnnual},
+		{LeaveType: "Vacation", AnnualDays: 18, Accrual: models.LeaveAccrualMonthly},
+	}
+	adjustments := map[balanceKey]float64{{1, "Vacation"}: 1.5}
+	taken := map[balanceKey]float64{{1, "Vacation"}: 4, {1, "Sick Leave"}: 2, {2, "Vacation"}: 9}
+	pending := map[balanceKey]float64{{1, "Vacation"}: 2}
+
+	lines := balanceLines(1, 2025, time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC), policies, adjustments, taken, pending)
+
+	assert.Equal(t, []models.LeaveBalance{
+		{UserID: 1, LeaveType: "Sick Leave", Year: 2025, Accrual: "annual", Entitlement: 10, Accrued: 10, Taken: 2, Available: 8},
+		// Four twelfths by April
+		{UserID: 1, LeaveType: "Vacation", Year: 2025, Accrual: "monthly", Entitlement: 18, Accrued: 6, Adjustments: 1.5,
+			Taken: 4, Pending: 2, Available: 1.5},
+	}, lines)
+}
+
+func TestAccrued(t *testing.T) {
+	monthly := models.LeavePolicy{AnnualDays: 20, Accrual: models.LeaveAccrualMonthly}
+	assert.Equal(t, 1.67, accrued(monthly, 2025, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 20.0, accrued(monthly, 2025, time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 20.0, accrued(monthly, 2024, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 0.0, accrued(monthly, 2026, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)))
+
+	annual := models.LeavePolicy{AnnualDays: 20}
+	assert.Equal(t, 20.0, accrued(annual, 2025, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestBalanceIsOwnUnlessPrivileged(t *testing.T) {
+	ctx := context.Background()
+	service, mock := newTestService(t, time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC))
+
+	_, err := service.Balance(ctx, 0, "ana@example.com", false)
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT email FROM users WHERE id = $1")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("ben@example.com"))
+	_, err = service.Balance(ctx, 2, "ana@example.com", false)
+	assert.ErrorIs(t, err, models.ErrPermissionDenied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetPolicyChecksAccrual(t *testing.T) {
+	service, _ := newTestService(t, time.Now())
+	err := service.SetPolicy(context.Background(), models.LeavePolicy{LeaveType: "Vacation", AnnualDays: 20, Accrual: "weekly"}, "admin@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
@@ -0,0 +1,607 @@
+// Package leave keeps leave balances across years. Every leave type has a yearly
+// entitlement set by its policy, and HR corrects individual balances with adjustments. At
+// year end the unused balance of each employee is closed: up to the policy's maximum is
+// carried into the next year and the rest is forfeited, each as an adjustment linked to the
+// year-end run. Entitlements accrue either in full on January 1st or monthly, and leave
+// requests are only accepted while the balance covers them.
+package leave
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"erp/controllers/audit"
+	"erp/controllers/jobs"
+	"erp/models"
+)
+
+// KindYearEnd is the job kind of year-end runs.
+const KindYearEnd = "leave_year_end"
+
+// statusApproved is the status of leave requests that count as taken.
+const statusApproved = "Approved"
+
+// Service manages leave policies, adjustments and year-end processing.
+type Service struct {
+	DB   *sql.DB
+	Jobs *jobs.Runner
+	Now  func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a leave service.
+func NewService(db *sql.DB, runner *jobs.Runner) *Service {
+	return &Service{DB: db, Jobs: runner, Now: time.Now}
+}
+
+// Policies returns the policy of every leave type, ordered by type.
+//
+// Returns:
+//   - []models.LeavePolicy: The policies.
+//   - error: An error if the query fails.
+func (s *Service) Policies(ctx context.Context) ([]models.LeavePolicy, error) {
+	return policies(ctx, s.DB)
+}
+
+// SetPolicy creates or replaces the policy of a leave type and records the change in the
+// audit log. The entitlement and accrual apply to balances at once, the carry-forward from
+// the next year-end run.
+//
+// Parameters:
+//   - policy: The leave type with its annual days, accrual rule and maximum carry-forward;
+//     the accrual defaults to annual.
+//   - actor: Email of the admin making the change.
+//
+// Returns:
+//   - error: models.ErrValidation if the type is missing, a number is negative or the accrual
+//     is unknown, or an error if the policy cannot be saved.
+func (s *Service) SetPolicy(ctx context.Context, policy models.LeavePolicy, actor string) error {
+	if policy.LeaveType == "" {
+		return models.Invalid("leave_type is required")
+	}
+	if policy.AnnualDays < 0 || policy.MaxCarryForward < 0 {
+		return models.Invalid("annual_days and max_carry_forward must not be negative")
+	}
+	if policy.Accrual == "" {
+		policy.Accrual = models.LeaveAccrualAnnual
+	}
+	if policy.Accrual != models.LeaveAccrualAnnual && policy.Accrual != models.LeaveAccrualMonthly {
+		return models.Invalid("accrual must be %s or %s", models.LeaveAccrualAnnual, models.LeaveAccrualMonthly)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := s.Now()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO leave_policies (leave_type, annual_days, accrual, max_carry_forward, updated_by, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (leave_type) DO UPDATE SET annual_days = EXCLUDED.annual_days, accrual = EXCLUDED.accrual,
+		     max_carry_forward = EXCLUDED.max_carry_forward, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		policy.LeaveType, policy.AnnualDays, policy.Accrual, policy.MaxCarryForward, actor, now,
+	)
+	if err != nil {
+		return err
+	}
+	details, err := json.Marshal(map[string]interface{}{
+		"leave_type": policy.LeaveType, "annual_days": policy.AnnualDays, "accrual": policy.Accrual,
+		"max_carry_forward": policy.MaxCarryForward,
+	})
+	if err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		Action:     audit.ActionLeavePolicy,
+		EntityType: "leave_policy",
+		Details:    details,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Adjust records manual adjustments of leave balances in one transaction; either all of
+// them are saved or none is.
+//
+// Parameters:
+//   - adjustments: The adjustments, each with a user, leave type, year, non-zero days and a reason.
+//   - actor: Email of the admin making the adjustments.
+//
+// Returns:
+//   - []models.LeaveAdjustment: The saved adjustments with their IDs.
+//   - error: models.ErrValidation if an adjustment is incomplete, names a leave type without
+//     a policy or an unknown user, or an error if they cannot be saved.
+func (s *Service) Adjust(ctx context.Context, adjustments []models.LeaveAdjustment, actor string) ([]models.LeaveAdjustment, error) {
+	if len(adjustments) == 0 {
+		return nil, models.Invalid("no adjustments given")
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	known, err := policies(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	types := map[string]bool{}
+	for _, policy := range known {
+		types[policy.LeaveType] = true
+	}
+	now := s.Now()
+	for i := range adjustments {
+		a := &adjustments[i]
+		switch {
+		case a.UserID <= 0 || a.Year <= 0:
+			return nil, models.Invalid("adjustment %d: user_id and year are required", i+1)
+		case !types[a.LeaveType]:
+			return nil, models.Invalid("adjustment %d: leave type %q has no policy", i+1, a.LeaveType)
+		case a.Days == 0 || math.IsNaN(a.Days) || math.IsInf(a.Days, 0):
+			return nil, models.Invalid("adjustment %d: days must be a non-zero number", i+1)
+		case a.Reason == "":
+			return nil, models.Invalid("adjustment %d: reason is required", i+1)
+		}
+		a.Kind, a.RunID, a.CreatedBy, a.CreatedAt = models.LeaveAdjustmentManual, nil, actor, now
+		if err := insertAdjustment(ctx, tx, a); err != nil {
+			if models.IsForeignKeyViolation(err) {
+				return nil, models.Invalid("adjustment %d: user %d does not exist", i+1, a.UserID)
+			}
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return adjustments, nil
+}
+
+// Adjustments lists the adjustments made to balances of a year, oldest first.
+//
+// Parameters:
+//   - year: The year the adjustments apply to.
+//   - userID: The employee whose adjustments are listed, or 0 for everyone.
+//
+// Returns:
+//   - []models.LeaveAdjustment: The adjustments.
+//   - error: An error if the query fails.
+func (s *Service) Adjustments(ctx context.Context, year, userID int) ([]models.LeaveAdjustment, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, user_id, leave_type, year, days, kind, reason, run_id, created_by, created_at
+		 FROM leave_adjustments WHERE year = $1 AND ($2 = 0 OR user_id = $2)
+		 ORDER BY created_at, id`, year, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := []models.LeaveAdjustment{}
+	for rows.Next() {
+		var a models.LeaveAdjustment
+		var runID sql.NullInt64
+		if err := rows.Scan(&a.ID, &a.UserID, &a.LeaveType, &a.Year, &a.Days, &a.Kind, &a.Reason,
+			&runID, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if runID.Valid {
+			id := int(runID.Int64)
+			a.RunID = &id
+		}
+		adjustments = append(adjustments, a)
+	}
+	return adjustments, rows.Err()
+}
+
+// Preview computes what closing a year would carry forward and forfeit, without saving
+// anything.
+//
+// Parameters:
+//   - year: The year to close.
+//
+// Returns:
+//   - *models.LeaveYearEndRun: The outcome per employee and leave type, marked as a preview.
+//   - error: models.ErrValidation if the year has not ended, models.ErrConflict if it is
+//     already closed, or an error if the balances cannot be read.
+func (s *Service) Preview(ctx context.Context, year int) (*models.LeaveYearEndRun, error) {
+	if err := s.closable(ctx, year); err != nil {
+		return nil, err
+	}
+	run, err := compute(ctx, s.DB, year)
+	if err != nil {
+		return nil, err
+	}
+	run.Preview = true
+	return run, nil
+}
+
+// Start closes a year in the background.
+//
+// Parameters:
+//   - year: The year to close.
+//   - actor: Email of the admin who requested the run.
+//
+// Returns:
+//   - *Job: The queued year-end job.
+//   - error: models.ErrValidation if the year has not ended, models.ErrConflict if it is
+//     already closed, or an error if the job cannot be created.
+func (s *Service) Start(ctx context.Context, year int, actor string) (*models.Job, error) {
+	if err := s.closable(ctx, year); err != nil {
+		return nil, err
+	}
+	return s.Jobs.Start(ctx, KindYearEnd, actor, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		return s.Process(ctx, p, year, actor)
+	})
+}
+
+// YearEnd closes the previous year if it has not been closed yet. It is run daily by the
+// scheduler and only acts in January, so a server that was down on New Year's Day catches
+// up without closing a year months later, after policies have changed.
+func (s *Service) YearEnd(ctx context.Context) error {
+	now := s.Now()
+	if now.Month() != time.January {
+		return nil
+	}
+	err := s.closable(ctx, now.Year()-1)
+	if errors.Is(err, models.ErrConflict) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.Jobs.Run(ctx, KindYearEnd, "scheduler", func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		return s.Process(ctx, p, now.Year()-1, "scheduler")
+	})
+	return err
+}
+
+// Process closes a year in one transaction. For every employee and leave type with unused
+// days, the balance of the year is zeroed with a year_end_close adjustment and up to the
+// policy's maximum is credited to the next year with a carry_forward adjustment.
+//
+// Parameters:
+//   - p: Reports the number of lines processed.
+//   - year: The year to close.
+//   - actor: Email of the admin, or "scheduler".
+//
+// Returns:
+//   - *models.LeaveYearEndRun: The saved run with its outcome per employee and leave type.
+//   - error: models.ErrConflict if the year is already closed, or an error if it cannot be
+//     processed; nothing is saved then.
+func (s *Service) Process(ctx context.Context, p *jobs.Progress, year int, actor string) (*models.LeaveYearEndRun, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	run, err := compute(ctx, tx, year)
+	if err != nil {
+		return nil, err
+	}
+	p.SetTotal(int64(len(run.Lines)))
+
+	if id := p.JobID(); id != 0 {
+		run.JobID = &id
+	}
+	now := s.Now()
+	run.Actor, run.ProcessedAt = actor, &now
+	lines, err := json.Marshal(run.Lines)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO leave_year_end_runs (year, job_id, employees, carried_forward, forfeited, lines, actor, processed_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		run.Year, run.JobID, run.Employees, run.CarriedForward, run.Forfeited, lines, run.Actor, now,
+	).Scan(&run.ID)
+	if models.IsUniqueViolation(err) {
+		return nil, models.Conflict("leave year %d is already closed", year)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range run.Lines {
+		p.Add(1)
+		if line.Unused <= 0 {
+			continue
+		}
+		adjustments := []models.LeaveAdjustment{{
+			UserID: line.UserID, LeaveType: line.LeaveType, Year: year, Days: -line.Unused,
+			Kind:   models.LeaveAdjustmentYearEndClose,
+			Reason: fmt.Sprintf("%g days forfeited at the close of %d", line.Forfeited, year),
+		}}
+		if line.CarriedForward > 0 {
+			adjustments = append(adjustments, models.LeaveAdjustment{
+				UserID: line.UserID, LeaveType: line.LeaveType, Year: year + 1, Days: line.CarriedForward,
+				Kind:   models.LeaveAdjustmentCarryForward,
+				Reason: fmt.Sprintf("Carried forward from %d", year),
+			})
+		}
+		for i := range adjustments {
+			adjustments[i].RunID, adjustments[i].CreatedBy, adjustments[i].CreatedAt = &run.ID, actor, now
+			if err := insertAdjustment(ctx, tx, &adjustments[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Balances returns an employee's balance of every leave type for a year: the entitlement,
+// the adjustments and the approved leave within the year. Nothing is carried forward or
+// forfeited; CarriedForward and Forfeited show what closing the year would do.
+//
+// Parameters:
+//   - userID: The employee.
+//   - year: The leave year.
+//
+// Returns:
+//   - []models.LeaveYearEndLine: One line per leave type with a policy.
+//   - error: An error if the balances cannot be read.
+func (s *Service) Balances(ctx context.Context, userID, year int) ([]models.LeaveYearEndLine, error) {
+	list, err := policies(ctx, s.DB)
+	if err != nil {
+		return nil, err
+	}
+	adjustments, err := sums(ctx, s.DB,
+		`SELECT user_id, leave_type, SUM(days) FROM leave_adjustments WHERE year = $1 AND user_id = $2
+		 GROUP BY user_id, leave_type`, year, userID)
+	if err != nil {
+		return nil, err
+	}
+	first, last := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	taken, err := sums(ctx, s.DB,
+		`SELECT user_id, leave_type, SUM(LEAST(end_date, $2::date) - GREATEST(start_date, $1::date) + 1)
+		 FROM leave WHERE status = $3 AND start_date <= $2 AND end_date >= $1 AND user_id = $4
+		 GROUP BY user_id, leave_type`, first, last, statusApproved, userID)
+	if err != nil {
+		return nil, err
+	}
+	return closeYear(year, list, []models.LeaveYearEndLine{{UserID: userID}}, adjustments, taken).Lines, nil
+}
+
+// Runs returns the year-end runs, latest year first, without their lines.
+//
+// Returns:
+//   - []models.LeaveYearEndRun: The runs.
+//   - error: An error if the query fails.
+func (s *Service) Runs(ctx context.Context) ([]models.LeaveYearEndRun, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, year, job_id, employees, carried_forward, forfeited, actor, processed_at
+		 FROM leave_year_end_runs ORDER BY year DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.LeaveYearEndRun{}
+	for rows.Next() {
+		run, err := scanRun(rows, nil)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// Run returns the summary report of the run that closed a year, with its outcome per
+// employee and leave type.
+//
+// Parameters:
+//   - year: The closed year.
+//
+// Returns:
+//   - *models.LeaveYearEndRun: The run.
+//   - error: models.ErrNotFound if the year has not been closed, or an error if the query fails.
+func (s *Service) Run(ctx context.Context, year int) (*models.LeaveYearEndRun, error) {
+	var lines []byte
+	row := s.DB.QueryRowContext(ctx,
+		`SELECT id, year, job_id, employees, carried_forward, forfeited, actor, processed_at, lines
+		 FROM leave_year_end_runs WHERE year = $1`, year)
+	run, err := scanRun(row, &lines)
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("leave year %d has not been closed", year)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(lines, &run.Lines); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// closable checks that a year has ended and has not been closed yet.
+func (s *Service) closable(ctx context.Context, year int) error {
+	if year >= s.Now().Year() {
+		return models.Invalid("leave year %d has not ended", year)
+	}
+	var exists bool
+	if err := s.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM leave_year_end_runs WHERE year = $1)`, year).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return models.Conflict("leave year %d is already closed", year)
+	}
+	return nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRun reads a run, and its lines into lines if it is not nil.
+func scanRun(row scanner, lines *[]byte) (*models.LeaveYearEndRun, error) {
+	var run models.LeaveYearEndRun
+	var jobID sql.NullInt64
+	var processedAt time.Time
+	dest := []interface{}{&run.ID, &run.Year, &jobID, &run.Employees, &run.CarriedForward, &run.Forfeited,
+		&run.Actor, &processedAt}
+	if lines != nil {
+		dest = append(dest, lines)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if jobID.Valid {
+		id := int(jobID.Int64)
+		run.JobID = &id
+	}
+	run.ProcessedAt = &processedAt
+	return &run, nil
+}
+
+// insertAdjustment saves an adjustment and sets its ID.
+func insertAdjustment(ctx context.Context, tx *sql.Tx, a *models.LeaveAdjustment) error {
+	return tx.QueryRowContext(ctx,
+		`INSERT INTO leave_adjustments (user_id, leave_type, year, days, kind, reason, run_id, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		a.UserID, a.LeaveType, a.Year, a.Days, a.Kind, a.Reason, a.RunID, a.CreatedBy, a.CreatedAt,
+	).Scan(&a.ID)
+}
+
+// policies reads the policy of every leave type.
+func policies(ctx context.Context, q models.Queryer) ([]models.LeavePolicy, error) {
+	rows, err := q.QueryContext(ctx, `SELECT leave_type, annual_days, accrual, max_carry_forward, updated_by, updated_at
+		FROM leave_policies ORDER BY leave_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.LeavePolicy{}
+	for rows.Next() {
+		var p models.LeavePolicy
+		var updatedAt time.Time
+		if err := rows.Scan(&p.LeaveType, &p.AnnualDays, &p.Accrual, &p.MaxCarryForward, &p.UpdatedBy, &updatedAt); err != nil {
+			return nil, err
+		}
+		p.UpdatedAt = &updatedAt
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// balanceKey identifies the balance of one employee and leave type.
+type balanceKey struct {
+	UserID    int
+	LeaveType string
+}
+
+// compute reads the balances of a year and works out what is carried forward and forfeited.
+func compute(ctx context.Context, q models.Queryer, year int) (*models.LeaveYearEndRun, error) {
+	list, err := policies(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT id, email FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	var users []models.LeaveYearEndLine
+	for rows.Next() {
+		var u models.LeaveYearEndLine
+		if err := rows.Scan(&u.UserID, &u.Email); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	adjustments, err := sums(ctx, q,
+		`SELECT user_id, leave_type, SUM(days) FROM leave_adjustments WHERE year = $1 GROUP BY user_id, leave_type`, year)
+	if err != nil {
+		return nil, err
+	}
+	// Leave spanning New Year counts only its days within the year
+	first, last := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	taken, err := sums(ctx, q,
+		`SELECT user_id, leave_type, SUM(LEAST(end_date, $2::date) - GREATEST(start_date, $1::date) + 1)
+		 FROM leave WHERE status = $3 AND start_date <= $2 AND end_date >= $1
+		 GROUP BY user_id, leave_type`, first, last, statusApproved)
+	if err != nil {
+		return nil, err
+	}
+	return closeYear(year, list, users, adjustments, taken), nil
+}
+
+// sums reads per-employee, per-leave-type totals.
+func sums(ctx context.Context, q models.Queryer, query string, args ...interface{}) (map[balanceKey]float64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[balanceKey]float64{}
+	for rows.Next() {
+		var key balanceKey
+		var total float64
+		if err := rows.Scan(&key.UserID, &key.LeaveType, &total); err != nil {
+			return nil, err
+		}
+		totals[key] = total
+	}
+	return totals, rows.Err()
+}
+
+// closeYear works out, for every employee and leave type with a policy, the unused balance
+// and how much of it is carried forward and forfeited.
+func closeYear(year int, list []models.LeavePolicy, users []models.LeaveYearEndLine,
+	adjustments, taken map[balanceKey]float64) *models.LeaveYearEndRun {
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+	run := &models.LeaveYearEndRun{Year: year, Lines: []models.LeaveYearEndLine{}}
+	employees := map[int]bool{}
+	for _, user := range users {
+		for _, policy := range list {
+			key := balanceKey{user.UserID, policy.LeaveType}
+			line := models.LeaveYearEndLine{
+				UserID:      user.UserID,
+				Email:       user.Email,
+				LeaveType:   policy.LeaveType,
+				Entitlement: policy.AnnualDays,
+				Adjustments: adjustments[key],
+				Taken:       taken[key],
+			}
+			line.Unused = round(line.Entitlement + line.Adjustments - line.Taken)
+			if line.Unused > 0 {
+				line.CarriedForward = math.Min(line.Unused, policy.MaxCarryForward)
+				line.Forfeited = round(line.Unused - line.CarriedForward)
+				employees[user.UserID] = true
+			}
+			run.CarriedForward += line.CarriedForward
+			run.Forfeited += line.Forfeited
+			run.Lines = append(run.Lines, line)
+		}
+	}
+	run.Employees = len(employees)
+	run.CarriedForward, run.Forfeited = round(run.CarriedForward), round(run.Forfeited)
+	return run
+}
+
+// round rounds days to two decimals, so half days add up exactly.
+func round(days float64) float64 {
+	return math.Round(days*100) / 100
+}
@@ -0,0 +1,144 @@
+package leave
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/controllers/jobs"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, now time.Time) (*Service, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	service := NewService(db, nil)
+	service.Now = func() time.Time { return now }
+	return service, mock
+}
+
+func TestCloseYear(t *testing.T) {
+	policies := []models.LeavePolicy{
+		{LeaveType: "Sick Leave", AnnualDays: 10, MaxCarryForward: 0},
+		{LeaveType: "Vacation", AnnualDays: 20, MaxCarryForward: 5},
+	}
+	users := []models.LeaveYearEndLine{{UserID: 2, Email: "ben@example.com"}, {UserID: 1, Email: "ana@example.com"}}
+	adjustments := map[balanceKey]float64{{1, "Vacation"}: 2.5}
+	taken := map[balanceKey]float64{{1, "Vacation"}: 20, {2, "Vacation"}: 17, {2, "Sick Leave"}: 12}
+
+	run := closeYear(2025, policies, users, adjustments, taken)
+
+	assert.Equal(t, []models.LeaveYearEndLine{
+		{UserID: 1, Email: "ana@example.com", LeaveType: "Sick Leave", Entitlement: 10, Unused: 10, Forfeited: 10},
+		{UserID: 1, Email: "ana@example.com", LeaveType: "Vacation", Entitlement: 20, Adjustments: 2.5, Taken: 20,
+			Unused: 2.5, CarriedForward: 2.5},
+		// More sick leave was taken than available; the overdraft is left as it is
+		{UserID: 2, Email: "ben@example.com", LeaveType: "Sick Leave", Entitlement: 10, Taken: 12, Unused: -2},
+		{UserID: 2, Email: "ben@example.com", LeaveType: "Vacation", Entitlement: 20, Taken: 17, Unused: 3, CarriedForward: 3},
+	}, run.Lines)
+	assert.Equal(t, 2, run.Employees)
+	assert.Equal(t, 5.5, run.CarriedForward)
+	assert.Equal(t, 10.0, run.Forfeited)
+}
+
+// expectBalances expects the queries compute makes for 2025, with one employee who has
+// 8 unused vacation days.
+func expectBalances(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM leave_policies")).
+		WillReturnRows(sqlmock.NewRows([]string{"leave_type", "annual_days", "accrual", "max_carry_forward", "updated_by", "updated_at"}).
+			AddRow("Vacation", 20, "annual", 5, "admin@example.com", time.Now()))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email FROM users")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(1, "ana@example.com"))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM leave_adjustments WHERE year = $1")).WithArgs(2025).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "leave_type", "sum"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM leave WHERE status = $3")).
+		WithArgs(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), "Approved").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "leave_type", "sum"}).AddRow(1, "Vacation", 12))
+}
+
+func TestProcess(t *testing.T) {
+	now := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	service, mock := newTestService(t, now)
+
+	mock.ExpectBegin()
+	expectBalances(mock)
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO leave_year_end_runs")).
+		WithArgs(2025, nil, 1, 5.0, 3.0, sqlmock.AnyArg(), "scheduler", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO leave_adjustments")).
+		WithArgs(1, "Vacation", 2025, -8.0, "year_end_close", "3 days forfeited at the close of 2025", 7, "scheduler", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO leave_adjustments")).
+		WithArgs(1, "Vacation", 2026, 5.0, "carry_forward", "Carried forward from 2025", 7, "scheduler", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	run, err := service.Process(context.Background(), &jobs.Progress{}, 2025, "scheduler")
+	require.NoError(t, err)
+	assert.Equal(t, 7, run.ID)
+	assert.False(t, run.Preview)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPreview(t *testing.T) {
+	ctx := context.Background()
+	service, mock := newTestService(t, time.Date(2025, 12, 20, 0, 0, 0, 0, time.UTC))
+
+	// The year has not ended
+	_, err := service.Preview(ctx, 2025)
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	service.Now = func() time.Time { return time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC) }
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).WithArgs(2025).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectBalances(mock)
+	run, err := service.Preview(ctx, 2025)
+	require.NoError(t, err)
+	assert.True(t, run.Preview)
+	assert.Equal(t, 5.0, run.CarriedForward)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).WithArgs(2025).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	_, err = service.Preview(ctx, 2025)
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestYearEndOnlyRunsInJanuary(t *testing.T) {
+	ctx := context.Background()
+	service, mock := newTestService(t, time.Date(2026, 2, 1, 3, 0, 0, 0, time.UTC))
+	require.NoError(t, service.YearEnd(ctx))
+
+	// Already closed in January
+	service.Now = func() time.Time { return time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC) }
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS")).WithArgs(2025).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	require.NoError(t, service.YearEnd(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdjustValidation(t *testing.T) {
+	service, mock := newTestService(t, time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC))
+
+	for _, adjustment := range []models.LeaveAdjustment{
+		{UserID: 1, LeaveType: "Unpaid", Year: 2026, Days: 1, Reason: "Correction"},
+		{UserID: 1, LeaveType: "Vacation", Year: 2026, Reason: "Correction"},
+		{UserID: 1, LeaveType: "Vacation", Year: 2026, Days: 1},
+		{LeaveType: "Vacation", Year: 2026, Days: 1, Reason: "Correction"},
+	} {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("FROM leave_policies")).
+			WillReturnRows(sqlmock.NewRows([]string{"leave_type", "annual_days", "accrual", "max_carry_forward", "updated_by", "updated_at"}).
+				AddRow("Vacation", 20, "annual", 5, "admin@example.com", time.Now()))
+		mock.ExpectRollback()
+		_, err := service.Adjust(context.Background(), []models.LeaveAdjustment{adjustment}, "admin@example.com")
+		assert.ErrorIs(t, err, models.ErrValidation, "%+v", adjustment)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
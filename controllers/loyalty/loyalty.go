@@ -0,0 +1,95 @@
+// Package loyalty runs the customer loyalty program. Customers earn points when their
+// invoices are posted, spend them as a discount on draft invoices and lose points that are
+// not spent before they expire.
+package loyalty
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"erp/config"
+	"erp/controllers/events"
+	"erp/models"
+)
+
+// HistoryLimit is the number of transactions returned with a loyalty account.
+const HistoryLimit = 50
+
+// Service accrues, redeems and expires loyalty points. It is an events.Subscriber: points
+// are accrued from InvoicePosted events delivered by the outbox.
+type Service struct {
+	Store models.LoyaltyStore
+	Rules config.LoyaltyConfig // Accrual, redemption and expiry rules
+}
+
+// NewService creates a loyalty service with the configured rules.
+func NewService(store models.LoyaltyStore, rules config.LoyaltyConfig) *Service {
+	return &Service{Store: store, Rules: rules}
+}
+
+// Points returns the points earned by invoicing amount. Partial points are dropped.
+func (s *Service) Points(amount float64) int {
+	if amount <= 0 {
+		return 0
+	}
+	// The small epsilon keeps amounts such as 0.29 * 100 from rounding down a point
+	return int(math.Floor(amount*s.Rules.PointsPerUnit + 1e-9))
+}
+
+// Handle accrues points for an InvoicePosted event and ignores other events. The store
+// accrues each invoice once, so redelivered events are harmless.
+func (s *Service) Handle(ctx context.Context, event *models.DomainEvent) error {
+	if event.EventType != events.InvoicePosted {
+		return nil
+	}
+	var invoice models.Invoice
+	if err := json.Unmarshal(event.Payload, &invoice); err != nil {
+		return err
+	}
+	points := s.Points(invoice.Amount)
+	if invoice.CustomerID == 0 || points == 0 {
+		return nil
+	}
+
+	expiresAt := event.OccurredAt.AddDate(0, 0, s.Rules.ExpiryDays)
+	_, err := s.Store.Accrue(ctx, invoice.CustomerID, event.AggregateID, points, expiresAt)
+	return err
+}
+
+// Redeem spends points of a customer as a discount on one of their draft invoices. The
+// discount is the points' value and is taken off the invoice amount.
+//
+// Parameters:
+//   - customerID: The customer spending the points.
+//   - invoiceID: A draft invoice of that customer.
+//   - points: The number of points to spend.
+//
+// Returns:
+//   - *models.LoyaltyTransaction: The redemption.
+//   - error: A validation error for a non-positive number of points, or the store's error.
+func (s *Service) Redeem(ctx context.Context, customerID, invoiceID, points int) (*models.LoyaltyTransaction, error) {
+	if points <= 0 {
+		return nil, models.Invalid("points to redeem must be positive")
+	}
+	discount := math.Round(float64(points)*s.Rules.PointValue*100) / 100
+	return s.Store.Redeem(ctx, customerID, invoiceID, points, discount, time.Now())
+}
+
+// Account returns a customer's balance, its value and their recent transactions.
+func (s *Service) Account(ctx context.Context, customerID int) (*models.LoyaltyAccount, error) {
+	account, err := s.Store.GetLoyaltyAccount(ctx, customerID, HistoryLimit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	account.Value = math.Round(float64(account.Balance)*s.Rules.PointValue*100) / 100
+	return account, nil
+}
+
+// Expire expires the unspent points of every accrual that is due. It is run daily by the
+// scheduler.
+func (s *Service) Expire(ctx context.Context, now time.Time) error {
+	_, err := s.Store.ExpirePoints(ctx, now)
+	return err
+}
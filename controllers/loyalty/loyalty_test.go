@@ -0,0 +1,111 @@
+package loyalty
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/controllers/events"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryLoyaltyStore records accruals by invoice and redemptions in memory.
+type memoryLoyaltyStore struct {
+	accruals    map[int]models.LoyaltyTransaction
+	redemptions []models.LoyaltyTransaction
+	balance     int
+}
+
+func newMemoryLoyaltyStore() *memoryLoyaltyStore {
+	return &memoryLoyaltyStore{accruals: map[int]models.LoyaltyTransaction{}}
+}
+
+func (m *memoryLoyaltyStore) Accrue(ctx context.Context, customerID, invoiceID, points int, expiresAt time.Time) (bool, error) {
+	if _, ok := m.accruals[invoiceID]; ok {
+		return false, nil
+	}
+	m.accruals[invoiceID] = models.LoyaltyTransaction{CustomerID: customerID, Kind: models.LoyaltyAccrual, Points: points, InvoiceID: &invoiceID, ExpiresAt: &expiresAt}
+	m.balance += points
+	return true, nil
+}
+
+func (m *memoryLoyaltyStore) Redeem(ctx context.Context, customerID, invoiceID, points int, discount float64, now time.Time) (*models.LoyaltyTransaction, error) {
+	if points > m.balance {
+		return nil, models.ErrInsufficientPoints
+	}
+	m.balance -= points
+	redemption := models.LoyaltyTransaction{CustomerID: customerID, Kind: models.LoyaltyRedemption, Points: -points, InvoiceID: &invoiceID, Discount: discount, CreatedAt: now}
+	m.redemptions = append(m.redemptions, redemption)
+	return &redemption, nil
+}
+
+func (m *memoryLoyaltyStore) GetLoyaltyAccount(ctx context.Context, customerID int, limit int, now time.Time) (*models.LoyaltyAccount, error) {
+	return &models.LoyaltyAccount{CustomerID: customerID, Balance: m.balance}, nil
+}
+
+func (m *memoryLoyaltyStore) ExpirePoints(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+var rules = config.LoyaltyConfig{PointsPerUnit: 1, PointValue: 0.05, ExpiryDays: 30}
+
+func postedEvent(t *testing.T, invoice models.Invoice, at time.Time) *models.DomainEvent {
+	payload, err := json.Marshal(invoice)
+	require.NoError(t, err)
+	return &models.DomainEvent{EventType: events.InvoicePosted, AggregateType: "invoice", AggregateID: invoice.ID, Payload: payload, OccurredAt: at}
+}
+
+func TestPoints(t *testing.T) {
+	service := NewService(newMemoryLoyaltyStore(), config.LoyaltyConfig{PointsPerUnit: 100})
+	assert.Equal(t, 29, service.Points(0.29), "Floating point error must not cost a point")
+	assert.Equal(t, 12345, service.Points(123.456))
+	assert.Equal(t, 0, service.Points(-5))
+}
+
+func TestHandleAccruesOncePerInvoice(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryLoyaltyStore()
+	service := NewService(store, rules)
+	postedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := postedEvent(t, models.Invoice{ID: 7, CustomerID: 3, Amount: 120.75}, postedAt)
+
+	require.NoError(t, service.Handle(ctx, event))
+	require.NoError(t, service.Handle(ctx, event), "Redelivered events are ignored")
+	require.Len(t, store.accruals, 1)
+	accrual := store.accruals[7]
+	assert.Equal(t, 3, accrual.CustomerID)
+	assert.Equal(t, 120, accrual.Points)
+	assert.Equal(t, postedAt.AddDate(0, 0, 30), *accrual.ExpiresAt)
+
+	require.NoError(t, service.Handle(ctx, postedEvent(t, models.Invoice{ID: 8, Amount: 50}, postedAt)))
+	require.NoError(t, service.Handle(ctx, &models.DomainEvent{EventType: events.InvoiceCreated, AggregateID: 9, Payload: json.RawMessage(`{"customer_id": 3, "amount": 10}`)}))
+	assert.Len(t, store.accruals, 1, "Invoices without a customer and other events earn nothing")
+}
+
+func TestRedeem(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryLoyaltyStore()
+	service := NewService(store, rules)
+	require.NoError(t, service.Handle(ctx, postedEvent(t, models.Invoice{ID: 1, CustomerID: 3, Amount: 100}, time.Now())))
+
+	_, err := service.Redeem(ctx, 3, 2, 0)
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	redemption, err := service.Redeem(ctx, 3, 2, 33)
+	require.NoError(t, err)
+	assert.Equal(t, -33, redemption.Points)
+	assert.Equal(t, 1.65, redemption.Discount)
+
+	_, err = service.Redeem(ctx, 3, 2, 68)
+	assert.ErrorIs(t, err, models.ErrInsufficientPoints)
+
+	account, err := service.Account(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 67, account.Balance)
+	assert.Equal(t, 3.35, account.Value)
+}
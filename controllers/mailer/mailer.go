@@ -0,0 +1,83 @@
+// Package mailer provides a pluggable interface for sending email, with an SMTP
+// implementation for production and a log implementation for development and tests.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+
+	"erp/config"
+)
+
+// Message is an email to be sent.
+type Message struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+	HTML    bool     `json:"html,omitempty"`
+}
+
+// Mailer sends email messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New creates the mailer selected by the configuration.
+//
+// Parameters:
+//   - cfg: The mail section of the application configuration.
+//
+// Returns:
+//   - Mailer: The configured mailer.
+//   - error: An error if the driver is unknown.
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "log", "":
+		return &LogMailer{}, nil
+	case "smtp":
+		return &SMTPMailer{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// SMTPMailer sends email through an SMTP server using PLAIN authentication.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the message to all recipients.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("email has no recipients")
+	}
+
+	contentType := "text/plain"
+	if msg.HTML {
+		contentType = "text/html"
+	}
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: %s; charset=UTF-8\r\n\r\n%s",
+		m.From, strings.Join(msg.To, ", "), msg.Subject, contentType, msg.Body)
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(fmt.Sprintf("%s:%d", m.Host, m.Port), auth, m.From, msg.To, []byte(body))
+}
+
+// LogMailer writes emails to the application log instead of sending them.
+type LogMailer struct{}
+
+// Send logs the message.
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("email to %s: %s", strings.Join(msg.To, ", "), msg.Subject)
+	return nil
+}
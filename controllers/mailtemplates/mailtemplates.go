@@ -0,0 +1,222 @@
+// Package mailtemplates renders notification emails from templates that staff can edit and
+// preview without a deploy. Templates are kept per key and locale, and every edit adds a
+// version, so earlier wording can be restored.
+package mailtemplates
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"erp/controllers/mailer"
+	"erp/models"
+)
+
+var (
+	keyPattern      = regexp.MustCompile(`^[a-z][a-z0-9_]{0,99}$`)
+	localePattern   = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+	variablePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// Service stores, finds and renders email templates.
+type Service struct {
+	Store         models.EmailTemplateStore
+	DefaultLocale string // Used when a template does not exist in the requested locale
+}
+
+// NewService creates an email template service.
+func NewService(store models.EmailTemplateStore, defaultLocale string) *Service {
+	return &Service{Store: store, DefaultLocale: defaultLocale}
+}
+
+// Check validates a draft of a template. If it declares no variables, it is given those of
+// the current version.
+//
+// Returns:
+//   - error: A models.Invalid error if the template is invalid, or an error if the
+//     current version cannot be loaded.
+func (s *Service) Check(ctx context.Context, template *models.EmailTemplate) error {
+	if template.Variables == nil {
+		current, err := s.Store.GetTemplate(ctx, template.Key, template.Locale, 0)
+		if err != nil && models.Kind(err) != models.ErrNotFound {
+			return err
+		}
+		if current != nil {
+			template.Variables = current.Variables
+		}
+	}
+	return Validate(template)
+}
+
+// Save checks a template and stores it as the next version of its key and locale.
+//
+// Parameters:
+//   - template: The template; its ID, version and creation time are set.
+//   - actor: Email of the user making the change.
+//
+// Returns:
+//   - error: A models.Invalid error if the template is invalid, or an error if it cannot
+//     be stored.
+func (s *Service) Save(ctx context.Context, template *models.EmailTemplate, actor string) error {
+	if err := s.Check(ctx, template); err != nil {
+		return err
+	}
+	template.CreatedBy = actor
+	return s.Store.AddTemplateVersion(ctx, template)
+}
+
+// Restore makes an earlier version current again by saving a copy of it as a new version.
+//
+// Returns:
+//   - *models.EmailTemplate: The new version.
+//   - error: A models.NotFound error if the version does not exist, or an error if it
+//     cannot be stored.
+func (s *Service) Restore(ctx context.Context, key, locale string, version int, actor string) (*models.EmailTemplate, error) {
+	old, err := s.Store.GetTemplate(ctx, key, locale, version)
+	if err != nil {
+		return nil, err
+	}
+	restored := *old
+	restored.Note = fmt.Sprintf("Restored version %d", version)
+	if err := s.Save(ctx, &restored, actor); err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// Find returns the current template for a locale. If there is none, the template of the
+// locale's language (e.g. "fr" for "fr-CA") is used, then that of the default locale.
+//
+// Returns:
+//   - *models.EmailTemplate: The template.
+//   - error: A models.NotFound error if the template exists in none of these locales.
+func (s *Service) Find(ctx context.Context, key, locale string) (*models.EmailTemplate, error) {
+	language, _, _ := strings.Cut(locale, "-")
+	for _, candidate := range []string{locale, language, s.DefaultLocale} {
+		if candidate == "" {
+			continue
+		}
+		template, err := s.Store.GetTemplate(ctx, key, candidate, 0)
+		if models.Kind(err) == models.ErrNotFound {
+			continue
+		}
+		return template, err
+	}
+	return nil, models.NotFound("email template %q not found", key)
+}
+
+// Email renders the current template for a locale into a message to send, e.g. through
+// the outbox.
+//
+// Parameters:
+//   - key: The template key.
+//   - locale: The recipient's locale; see Find.
+//   - to: The recipients.
+//   - values: The value of each variable.
+//
+// Returns:
+//   - mailer.Message: The email.
+//   - error: A models.NotFound error if the template does not exist, a models.Invalid error
+//     if a variable it uses has no value, or an error if it cannot be loaded.
+func (s *Service) Email(ctx context.Context, key, locale string, to []string, values map[string]string) (mailer.Message, error) {
+	template, err := s.Find(ctx, key, locale)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	msg, err := Render(template, values)
+	msg.To = to
+	return msg, err
+}
+
+// Render replaces the variables of a template with their values. Values are escaped in
+// HTML bodies, and line breaks are removed from the subject.
+//
+// Returns:
+//   - mailer.Message: The email, without recipients.
+//   - error: A models.Invalid error if a variable the template uses has no value.
+func Render(template *models.EmailTemplate, values map[string]string) (mailer.Message, error) {
+	subject, err := replace(template.Subject, func(name string) (string, bool) {
+		value, ok := values[name]
+		return strings.Join(strings.Fields(value), " "), ok
+	})
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	body, err := replace(template.Body, func(name string) (string, bool) {
+		value, ok := values[name]
+		if template.HTML {
+			value = html.EscapeString(value)
+		}
+		return value, ok
+	})
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{Subject: subject, Body: body, HTML: template.HTML}, nil
+}
+
+// Validate checks a template's key, locale and text, and that it only uses the variables
+// it declares.
+func Validate(template *models.EmailTemplate) error {
+	if !keyPattern.MatchString(template.Key) {
+		return models.Invalid("key must be lower case letters, digits and underscores")
+	}
+	if !localePattern.MatchString(template.Locale) {
+		return models.Invalid("locale %q is not a language tag such as en or fr-CA", template.Locale)
+	}
+	if strings.TrimSpace(template.Subject) == "" || strings.TrimSpace(template.Body) == "" {
+		return models.Invalid("subject and body are required")
+	}
+	declared := map[string]bool{}
+	for _, name := range template.Variables {
+		if !variablePattern.MatchString(name) {
+			return models.Invalid("variable %q must be lower case letters, digits and underscores", name)
+		}
+		declared[name] = true
+	}
+	var used []string
+	for _, text := range []string{template.Subject, template.Body} {
+		_, err := replace(text, func(name string) (string, bool) {
+			used = append(used, name)
+			return "", true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range used {
+		if !declared[name] {
+			return models.Invalid("variable %q is not declared", name)
+		}
+	}
+	return nil
+}
+
+// replace replaces each {{name}} in text by its value.
+func replace(text string, value func(name string) (string, bool)) (string, error) {
+	var out strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			out.WriteString(text)
+			return out.String(), nil
+		}
+		end := strings.Index(text[start:], "}}")
+		if end < 0 {
+			return "", models.Invalid("{{ without a closing }}")
+		}
+		name := strings.TrimSpace(text[start+2 : start+end])
+		if !variablePattern.MatchString(name) {
+			return "", models.Invalid("{{%s}} is not a valid variable", name)
+		}
+		v, ok := value(name)
+		if !ok {
+			return "", models.Invalid("variable %q has no value", name)
+		}
+		out.WriteString(text[:start])
+		out.WriteString(v)
+		text = text[start+end+2:]
+	}
+}
@@ -0,0 +1,134 @@
+package mailtemplates
+
+import (
+	"context"
+	"testing"
+
+	"erp/controllers/mailer"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps template versions in memory.
+type fakeStore struct {
+	versions []models.EmailTemplate
+}
+
+func (f *fakeStore) ListCurrentTemplates(ctx context.Context) ([]models.EmailTemplate, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) GetTemplate(ctx context.Context, key, locale string, version int) (*models.EmailTemplate, error) {
+	var found *models.EmailTemplate
+	for i, t := range f.versions {
+		if t.Key == key && t.Locale == locale && (version == 0 || t.Version == version) {
+			found = &f.versions[i]
+		}
+	}
+	if found == nil {
+		return nil, models.NotFound("email template %s (%s) not found", key, locale)
+	}
+	template := *found
+	return &template, nil
+}
+
+func (f *fakeStore) ListTemplateVersions(ctx context.Context, key, locale string) ([]models.EmailTemplate, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) AddTemplateVersion(ctx context.Context, t *models.EmailTemplate) error {
+	t.Version = 1
+	for _, v := range f.versions {
+		if v.Key == t.Key && v.Locale == t.Locale {
+			t.Version = v.Version + 1
+		}
+	}
+	f.versions = append(f.versions, *t)
+	return nil
+}
+
+var reminder = models.EmailTemplate{
+	Key: "payment_reminder", Locale: "en", Version: 1,
+	Subject:   "Invoice {{ invoice }} is due",
+	Body:      "Dear {{customer}}, please pay {{amount}}.",
+	Variables: []string{"customer", "invoice", "amount"},
+}
+
+func TestRender(t *testing.T) {
+	msg, err := Render(&reminder, map[string]string{"customer": "Acme <Ltd>", "invoice": "7\r\nBcc: x@example.com", "amount": "10.00"})
+	require.NoError(t, err)
+	assert.Equal(t, mailer.Message{Subject: "Invoice 7 Bcc: x@example.com is due", Body: "Dear Acme <Ltd>, please pay 10.00."}, msg)
+
+	html := reminder
+	html.HTML = true
+	msg, err = Render(&html, map[string]string{"customer": "Acme <Ltd>", "invoice": "7", "amount": "10.00"})
+	require.NoError(t, err)
+	assert.Equal(t, "Dear Acme &lt;Ltd&gt;, please pay 10.00.", msg.Body)
+
+	_, err = Render(&reminder, map[string]string{"customer": "Acme"})
+	assert.EqualError(t, err, `variable "invoice" has no value`)
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		change func(*models.EmailTemplate)
+		want   string
+	}{
+		"undeclared variable": {func(t *models.EmailTemplate) { t.Body += " {{due_date}}" }, `variable "due_date" is not declared`},
+		"unclosed variable":   {func(t *models.EmailTemplate) { t.Subject = "Due {{invoice" }, "{{ without a closing }}"},
+		"bad variable":        {func(t *models.EmailTemplate) { t.Body = "{{ Amount! }}" }, "{{Amount!}} is not a valid variable"},
+		"bad locale":          {func(t *models.EmailTemplate) { t.Locale = "English" }, `locale "English" is not a language tag`},
+		"empty body":          {func(t *models.EmailTemplate) { t.Body = " " }, "subject and body are required"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			template := reminder
+			tc.change(&template)
+			err := Validate(&template)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, models.ErrValidation)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+	assert.NoError(t, Validate(&reminder))
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{versions: []models.EmailTemplate{reminder}}
+	service := NewService(store, "en")
+
+	edited := &models.EmailTemplate{Key: "payment_reminder", Locale: "en", Subject: "Friendly reminder: {{invoice}}", Body: "Hi {{customer}}"}
+	require.NoError(t, service.Save(ctx, edited, "finance@example.com"))
+	assert.Equal(t, 2, edited.Version)
+	assert.Equal(t, reminder.Variables, edited.Variables, "Variables are kept from the current version")
+	assert.Equal(t, "finance@example.com", edited.CreatedBy)
+
+	restored, err := service.Restore(ctx, "payment_reminder", "en", 1, "admin@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 3, restored.Version)
+	assert.Equal(t, reminder.Subject, restored.Subject)
+	assert.Equal(t, "Restored version 1", restored.Note)
+}
+
+func TestEmailFallsBackToLanguageAndDefaultLocale(t *testing.T) {
+	ctx := context.Background()
+	french := reminder
+	french.Locale, french.Subject = "fr", "Facture {{invoice}}"
+	service := NewService(&fakeStore{versions: []models.EmailTemplate{reminder, french}}, "en")
+	values := map[string]string{"customer": "Acme", "invoice": "7", "amount": "10.00"}
+
+	msg, err := service.Email(ctx, "payment_reminder", "fr-CA", []string{"ap@acme.example"}, values)
+	require.NoError(t, err)
+	assert.Equal(t, "Facture 7", msg.Subject)
+	assert.Equal(t, []string{"ap@acme.example"}, msg.To)
+
+	msg, err = service.Email(ctx, "payment_reminder", "de", nil, values)
+	require.NoError(t, err)
+	assert.Equal(t, "Invoice 7 is due", msg.Subject)
+
+	_, err = service.Email(ctx, "welcome", "en", nil, values)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
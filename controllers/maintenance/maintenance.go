@@ -0,0 +1,148 @@
+// Package maintenance switches the API into read-only or maintenance mode, for example to
+// stop writes while a migration runs. The mode is stored in the database so every server
+// instance follows it.
+package maintenance
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"erp/controllers/middleware"
+	"erp/controllers/rbac"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// DefaultTTL is how long a server keeps using the mode it last read before checking again.
+const DefaultTTL = 5 * time.Second
+
+// retryAfterSeconds is sent to rejected clients as a hint for when to try again.
+const retryAfterSeconds = "60"
+
+// PermissionChecker reports whether a role grants one of the required permissions,
+// typically the rbac service.
+type PermissionChecker interface {
+	Allowed(ctx context.Context, role string, required ...string) (bool, error)
+}
+
+// Service caches the operating mode and rejects requests that the mode does not allow.
+type Service struct {
+	Store models.SystemModeStore
+	TTL   time.Duration
+
+	mu       sync.Mutex
+	current  *models.SystemMode
+	loadedAt time.Time
+}
+
+// NewService creates a mode service with the given cache lifetime.
+func NewService(store models.SystemModeStore, ttl time.Duration) *Service {
+	return &Service{Store: store, TTL: ttl}
+}
+
+// Current returns the operating mode. If the mode cannot be read the last known mode is
+// kept, or normal mode if none was read yet, so a database outage does not lock out reads.
+func (s *Service) Current(ctx context.Context) models.SystemMode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil || time.Since(s.loadedAt) > s.TTL {
+		mode, err := s.Store.GetSystemMode(ctx)
+		if err != nil {
+			log.Printf("maintenance: could not read system mode: %v", err)
+			if s.current == nil {
+				return models.SystemMode{Mode: models.ModeNormal}
+			}
+			return *s.current
+		}
+		s.current, s.loadedAt = mode, time.Now()
+	}
+	return *s.current
+}
+
+// Writable reports whether the current mode accepts changes. Background work that writes,
+// such as scheduled jobs and the outbox dispatcher, checks it before each run.
+func (s *Service) Writable(ctx context.Context) bool {
+	return s.Current(ctx).Mode == models.ModeNormal
+}
+
+// Set switches the operating mode. It takes effect on this server immediately and on
+// other servers within TTL.
+func (s *Service) Set(ctx context.Context, mode *models.SystemMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.Store.SetSystemMode(ctx, mode); err != nil {
+		return err
+	}
+	s.current, s.loadedAt = mode, time.Now()
+	return nil
+}
+
+// Guard is a middleware that rejects requests the current mode does not allow with 503
+// Service Unavailable. In read-only mode only GET, HEAD and OPTIONS requests are served;
+// in maintenance mode nothing is. Administrators, whose role grants rbac.All, are always
+// served, so they can take backups and switch the mode back; the user is read from a
+// bearer token when one is sent. Requests for one of the exempt paths, such as the health
+// check and login, are always served too.
+func (s *Service) Guard(access PermissionChecker, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return middleware.OptionalJWTAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, path := range exempt {
+				if r.URL.Path == path {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			mode := s.Current(r.Context())
+			if allowed(mode.Mode, r.Method) || administrator(r, access) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			reject(w, mode)
+		}))
+	}
+}
+
+// administrator reports whether the request is made by a user whose role grants rbac.All.
+func administrator(r *http.Request, access PermissionChecker) bool {
+	role, err := middleware.GetUserRoleFromContext(r.Context())
+	if err != nil {
+		return false
+	}
+	ok, err := access.Allowed(r.Context(), role, rbac.All)
+	if err != nil {
+		log.Printf("maintenance: could not check role %s: %v", role, err)
+		return false
+	}
+	return ok
+}
+
+// allowed reports whether a request with the given method may be served in a mode.
+func allowed(mode, method string) bool {
+	switch mode {
+	case models.ModeMaintenance:
+		return false
+	case models.ModeReadOnly:
+		return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+	}
+	return true
+}
+
+// reject writes the 503 response explaining why the request was refused.
+func reject(w http.ResponseWriter, mode models.SystemMode) {
+	message := mode.Message
+	if message == "" {
+		if mode.Mode == models.ModeReadOnly {
+			message = "The system is in read-only mode; changes are not accepted right now."
+		} else {
+			message = "The system is down for maintenance."
+		}
+	}
+
+	w.Header().Set("Retry-After", retryAfterSeconds)
+	respond.ErrorDetails(w, http.StatusServiceUnavailable, message, map[string]string{"mode": mode.Mode})
+}
@@ -0,0 +1,132 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/controllers/rbac"
+	"erp/controllers/utils"
+	"erp/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryModeStore keeps the mode in memory and can simulate a database outage.
+type memoryModeStore struct {
+	mode *models.SystemMode
+	err  error
+}
+
+func (m *memoryModeStore) GetSystemMode(ctx context.Context) (*models.SystemMode, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.mode == nil {
+		return &models.SystemMode{Mode: models.ModeNormal}, nil
+	}
+	copy := *m.mode
+	return &copy, nil
+}
+
+func (m *memoryModeStore) SetSystemMode(ctx context.Context, mode *models.SystemMode) error {
+	m.mode = mode
+	return nil
+}
+
+// adminAccess grants rbac.All to the Admin role only.
+type adminAccess struct{}
+
+func (adminAccess) Allowed(ctx context.Context, role string, required ...string) (bool, error) {
+	return role == "Admin" && len(required) == 1 && required[0] == rbac.All, nil
+}
+
+func setupRouter(service *Service) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(service.Guard(adminAccess{}, "/health"))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/invoices", ok).Methods("GET", "POST")
+	router.HandleFunc("/health", ok).Methods("GET")
+	router.HandleFunc("/health/details", ok).Methods("GET")
+	router.HandleFunc("/system/mode", ok).Methods("PUT")
+	return router
+}
+
+func status(router *mux.Router, method, path string) int {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+	return rr.Code
+}
+
+// statusAs serves a request made by a user with the given role.
+func statusAs(t *testing.T, router *mux.Router, role, method, path string) int {
+	token, err := utils.GenerateJWT("user@example.com", role)
+	assert.NoError(t, err)
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestGuardModes(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(&memoryModeStore{}, time.Hour)
+	router := setupRouter(service)
+
+	assert.Equal(t, http.StatusOK, status(router, "POST", "/invoices"))
+
+	assert.NoError(t, service.Set(ctx, &models.SystemMode{Mode: models.ModeReadOnly, Message: "Migrating the ledger"}))
+	assert.Equal(t, http.StatusOK, status(router, "GET", "/invoices"))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/invoices", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error": {"code": "service_unavailable", "message": "Migrating the ledger",
+		"details": {"mode": "read_only"}}}`, rr.Body.String())
+
+	assert.NoError(t, service.Set(ctx, &models.SystemMode{Mode: models.ModeMaintenance}))
+	assert.Equal(t, http.StatusServiceUnavailable, status(router, "GET", "/invoices"))
+	assert.Equal(t, http.StatusOK, status(router, "GET", "/health"))
+	assert.Equal(t, http.StatusServiceUnavailable, status(router, "GET", "/health/details"), "exempt paths match exactly")
+	assert.Equal(t, http.StatusServiceUnavailable, status(router, "PUT", "/system/mode"))
+}
+
+func TestGuardServesAdministrators(t *testing.T) {
+	service := NewService(&memoryModeStore{mode: &models.SystemMode{Mode: models.ModeMaintenance}}, time.Hour)
+	router := setupRouter(service)
+
+	assert.Equal(t, http.StatusOK, statusAs(t, router, "Admin", "PUT", "/system/mode"))
+	assert.Equal(t, http.StatusOK, statusAs(t, router, "Admin", "POST", "/invoices"))
+	assert.Equal(t, http.StatusServiceUnavailable, statusAs(t, router, "Accountant", "GET", "/invoices"))
+	assert.Equal(t, http.StatusServiceUnavailable, statusAs(t, router, "Accountant", "PUT", "/system/mode"))
+}
+
+func TestWritable(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(&memoryModeStore{}, time.Hour)
+	assert.True(t, service.Writable(ctx))
+	for _, mode := range []string{models.ModeReadOnly, models.ModeMaintenance} {
+		assert.NoError(t, service.Set(ctx, &models.SystemMode{Mode: mode}))
+		assert.False(t, service.Writable(ctx), mode)
+	}
+}
+
+func TestCurrentKeepsLastModeOnError(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryModeStore{err: errors.New("connection refused")}
+	service := NewService(store, 0)
+	assert.Equal(t, models.ModeNormal, service.Current(ctx).Mode)
+
+	store.err = nil
+	store.mode = &models.SystemMode{Mode: models.ModeReadOnly}
+	assert.Equal(t, models.ModeReadOnly, service.Current(ctx).Mode)
+
+	store.err = errors.New("connection refused")
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, models.ModeReadOnly, service.Current(ctx).Mode)
+}
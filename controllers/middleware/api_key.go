@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// APIKeyHeader is the request header carrying the API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyContext is the context key for the authenticated *models.APIKey.
+const APIKeyContext contextKey = "api_key"
+
+// HashAPIKey returns the hash under which an API key is stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyAuth middleware authenticates external clients by API key, checks that the key
+// grants scope and applies the key's per-minute rate limit. Limits are counted per key
+// and are separate from internal routes.
+func APIKeyAuth(store models.APIKeyStore, limiter *RateLimiter, defaultLimit int, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.Header.Get(APIKeyHeader)
+			if raw == "" {
+				respond.Error(w, http.StatusUnauthorized, "API key missing")
+				return
+			}
+
+			key, err := store.GetAPIKeyByHash(r.Context(), HashAPIKey(raw))
+			if err != nil || !key.Active {
+				respond.Error(w, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+			if !key.HasScope(scope) {
+				respond.Error(w, http.StatusForbidden, "API key does not grant "+scope)
+				return
+			}
+
+			limit := key.RateLimit
+			if limit <= 0 {
+				limit = defaultLimit
+			}
+			remaining, retryAfter, ok := limiter.Allow(strconv.Itoa(key.ID), limit)
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				respond.Error(w, http.StatusTooManyRequests, "Rate limit exceeded")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), APIKeyContext, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetAPIKeyFromContext extracts the authenticated API key from the request context
+func GetAPIKeyFromContext(ctx context.Context) (*models.APIKey, error) {
+	key, ok := ctx.Value(APIKeyContext).(*models.APIKey)
+	if !ok {
+		return nil, fmt.Errorf("api key not found in context")
+	}
+	return key, nil
+}
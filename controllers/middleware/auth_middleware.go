@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"erp/controllers/respond"
 	"erp/controllers/utils"
 )
 
@@ -13,43 +14,119 @@ type contextKey string
 
 const UserEmail contextKey = "email"
 
+// UserRole is the context key for the role name taken from the JWT claims.
+const UserRole contextKey = "role"
+
+// UserTokenID is the context key for the ID ("jti") of the access token, used to revoke it.
+const UserTokenID contextKey = "jti"
+
+// RevocationList reports whether an access token was revoked before it expired.
+type RevocationList interface {
+	IsTokenRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// revocations is the list JWTAuth checks tokens against; nil disables the check.
+var revocations RevocationList
+
+// UseRevocationList makes JWTAuth refuse access tokens on list, and OptionalJWTAuth ignore
+// them. It is called once at startup, before the server accepts requests.
+func UseRevocationList(list RevocationList) {
+	revocations = list
+}
+
+// revoked reports whether the token with the claims is on the revocation list. Tokens issued
+// without an ID cannot be revoked.
+func revoked(ctx context.Context, claims map[string]interface{}) (bool, error) {
+	id, _ := claims["jti"].(string)
+	if revocations == nil || id == "" {
+		return false, nil
+	}
+	return revocations.IsTokenRevoked(ctx, id)
+}
+
 // JWTAuth middleware to validate JWT and extract user information
 func JWTAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header missing", http.StatusUnauthorized)
+			respond.Error(w, http.StatusUnauthorized, "Authorization header missing")
 			return
 		}
 
 		// Expecting "Bearer <token>"
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			http.Error(w, "Bearer token missing", http.StatusUnauthorized)
+			respond.Error(w, http.StatusUnauthorized, "Bearer token missing")
 			return
 		}
 
 		claims, err := utils.ValidateJWT(tokenString)
 		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			respond.Error(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		isRevoked, err := revoked(r.Context(), claims)
+		if err != nil {
+			respond.Error(w, http.StatusInternalServerError, "Could not check token")
+			return
+		}
+		if isRevoked {
+			respond.Error(w, http.StatusUnauthorized, "Token revoked")
 			return
 		}
 		// log.Println("Claims:", claims)
 		// Get userID from token claims
 		email, ok := claims["email"].(string) // Extract email
 		if !ok {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+			respond.Error(w, http.StatusUnauthorized, "Invalid token claims")
 			return
 		}
 
 		// Add the userID to the context
 		ctx := context.WithValue(r.Context(), UserEmail, email)
+		if role, ok := claims["role"].(string); ok {
+			ctx = context.WithValue(ctx, UserRole, role)
+		}
+		if id, ok := claims["jti"].(string); ok {
+			ctx = context.WithValue(ctx, UserTokenID, id)
+		}
 
 		// Pass the request with updated context to the next handler
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// OptionalJWTAuth adds the user's email and role to the context when the request carries a
+// valid bearer token, and otherwise passes the request on unchanged. It is used on public
+// routes whose behavior depends on who is asking, such as feature-flagged endpoints.
+func OptionalJWTAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if tokenString == "" || tokenString == r.Header.Get("Authorization") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := utils.ValidateJWT(tokenString)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isRevoked, err := revoked(r.Context(), claims); err != nil || isRevoked {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		if email, ok := claims["email"].(string); ok {
+			ctx = context.WithValue(ctx, UserEmail, email)
+		}
+		if role, ok := claims["role"].(string); ok {
+			ctx = context.WithValue(ctx, UserRole, role)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // GetUserEmailFromContext extracts the email from the request context
 func GetUserEmailFromContext(ctx context.Context) (string, error) {
 	email, ok := ctx.Value(UserEmail).(string)
@@ -58,3 +135,42 @@ func GetUserEmailFromContext(ctx context.Context) (string, error) {
 	}
 	return email, nil
 }
+
+// GetUserRoleFromContext extracts the role name from the request context
+func GetUserRoleFromContext(ctx context.Context) (string, error) {
+	role, ok := ctx.Value(UserRole).(string)
+	if !ok || role == "" {
+		return "", fmt.Errorf("role not found in context")
+	}
+	return role, nil
+}
+
+// GetTokenIDFromContext extracts the ID of the access token from the request context
+func GetTokenIDFromContext(ctx context.Context) (string, error) {
+	id, ok := ctx.Value(UserTokenID).(string)
+	if !ok || id == "" {
+		return "", fmt.Errorf("token ID not found in context")
+	}
+	return id, nil
+}
+
+// RequireRole middleware allows the request only if the authenticated user has one of the
+// given roles. It must be chained after JWTAuth.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, err := GetUserRoleFromContext(r.Context())
+			if err != nil {
+				respond.Error(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			for _, allowed := range roles {
+				if strings.EqualFold(role, allowed) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			respond.Error(w, http.StatusForbidden, "Forbidden")
+		})
+	}
+}
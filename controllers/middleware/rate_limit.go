@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter counts requests per client in fixed one-minute windows.
+type RateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+	now     func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter creates an empty rate limiter.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{windows: make(map[string]*rateWindow), now: time.Now}
+}
+
+// Allow records a request from client and reports whether it is within limit requests per
+// minute. It also returns the number of requests left in the window and, when the request
+// is refused, how long until the window resets.
+func (l *RateLimiter) Allow(client string, limit int) (remaining int, retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	window, exists := l.windows[client]
+	if !exists || now.Sub(window.start) >= time.Minute {
+		window = &rateWindow{start: now}
+		l.windows[client] = window
+	}
+	if window.count >= limit {
+		return 0, window.start.Add(time.Minute).Sub(now), false
+	}
+	window.count++
+	return limit - window.count, 0, true
+}
@@ -0,0 +1,171 @@
+// Package orgchart builds the reporting hierarchy of the organization from the manager each
+// employee reports to, so that it can be drawn in one request. The chart can be cut down to
+// a department or to the people under one manager, and limited in depth.
+package orgchart
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"erp/models"
+)
+
+// unassigned is the department employees without one are grouped under.
+const unassigned = "Unassigned"
+
+// Options narrows the org chart.
+type Options struct {
+	Root       int    // Start at this employee instead of at everyone without a manager
+	Department string // Only employees of this department; "Unassigned" for those without one
+	Depth      int    // Levels to include, 1 being the roots alone; 0 for all of them
+}
+
+// Service reads and changes the reporting hierarchy through an OrgChartStore.
+type Service struct {
+	Store models.OrgChartStore
+}
+
+// NewService creates an org chart service backed by store.
+func NewService(store models.OrgChartStore) *Service {
+	return &Service{Store: store}
+}
+
+// Chart returns the org chart of the active employees.
+//
+// Returns:
+//   - *models.OrgChart: The hierarchy and its departments.
+//   - error: A validation error if the depth is negative, a not found error if the root is
+//     not an active employee of the department, or the store's error.
+func (s *Service) Chart(ctx context.Context, opts Options) (*models.OrgChart, error) {
+	if opts.Depth < 0 {
+		return nil, models.Invalid("depth cannot be negative")
+	}
+	employees, err := s.Store.ListOrgEmployees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return Build(employees, opts)
+}
+
+// SetManager sets who an employee reports to, or clears it when managerID is nil.
+//
+// Returns:
+//   - error: A validation error if the employee would report to themselves or to someone
+//     who reports to them, a not found error if the employee does not exist, or the
+//     store's error.
+func (s *Service) SetManager(ctx context.Context, userID int, managerID *int) error {
+	if managerID != nil && *managerID == userID {
+		return models.Invalid("user %d cannot report to themselves", userID)
+	}
+	return s.Store.SetManager(ctx, userID, managerID)
+}
+
+// Build arranges employees into an org chart. An employee whose manager is not in the chart,
+// because they have none, the manager has left or is in another department, is a root.
+// Roots and reports are ordered by name.
+func Build(employees []models.OrgEmployee, opts Options) (*models.OrgChart, error) {
+	byID := make(map[int]models.OrgEmployee, len(employees))
+	for _, e := range employees {
+		byID[e.ID] = e
+	}
+	included := make(map[int]models.OrgEmployee, len(employees))
+	for _, e := range employees {
+		if opts.Department == "" || strings.EqualFold(department(e), opts.Department) {
+			included[e.ID] = e
+		}
+	}
+
+	reports := map[int][]models.OrgEmployee{}
+	var roots []models.OrgEmployee
+	for _, e := range included {
+		if e.ManagerID != nil {
+			if _, ok := included[*e.ManagerID]; ok {
+				reports[*e.ManagerID] = append(reports[*e.ManagerID], e)
+				continue
+			}
+		}
+		if opts.Root == 0 {
+			roots = append(roots, e)
+		}
+	}
+	if opts.Root != 0 {
+		root, ok := included[opts.Root]
+		if !ok {
+			return nil, models.NotFound("employee %d is not in the org chart", opts.Root)
+		}
+		roots = []models.OrgEmployee{root}
+	}
+
+	chart := &models.OrgChart{Roots: []*models.OrgNode{}}
+	var shown []models.OrgEmployee
+	visited := map[int]bool{}
+	var node func(e models.OrgEmployee, level int) *models.OrgNode
+	node = func(e models.OrgEmployee, level int) *models.OrgNode {
+		visited[e.ID] = true
+		shown = append(shown, e)
+		n := &models.OrgNode{OrgEmployee: e, DirectReports: len(reports[e.ID]), Reports: []*models.OrgNode{}}
+		if opts.Depth != 0 && level >= opts.Depth {
+			return n
+		}
+		for _, r := range byName(reports[e.ID]) {
+			if !visited[r.ID] {
+				n.Reports = append(n.Reports, node(r, level+1))
+			}
+		}
+		return n
+	}
+	for _, root := range byName(roots) {
+		chart.Roots = append(chart.Roots, node(root, 1))
+	}
+	chart.Departments = departments(shown, byID)
+	return chart, nil
+}
+
+// departments groups the employees shown in a chart by department, in department order.
+// The heads of a department are those whose manager, if any, works elsewhere.
+func departments(shown []models.OrgEmployee, byID map[int]models.OrgEmployee) []models.OrgDepartment {
+	groups := map[string]*models.OrgDepartment{}
+	for _, e := range byName(shown) {
+		name := department(e)
+		group := groups[name]
+		if group == nil {
+			group = &models.OrgDepartment{Department: name, Heads: []int{}}
+			groups[name] = group
+		}
+		group.Headcount++
+		manager, ok := models.OrgEmployee{}, false
+		if e.ManagerID != nil {
+			manager, ok = byID[*e.ManagerID]
+		}
+		if !ok || department(manager) != name {
+			group.Heads = append(group.Heads, e.ID)
+		}
+	}
+	list := make([]models.OrgDepartment, 0, len(groups))
+	for _, group := range groups {
+		list = append(list, *group)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Department < list[j].Department })
+	return list
+}
+
+// department returns the department an employee is grouped under.
+func department(e models.OrgEmployee) string {
+	if e.Department == "" {
+		return unassigned
+	}
+	return e.Department
+}
+
+// byName returns employees ordered by name, then ID.
+func byName(employees []models.OrgEmployee) []models.OrgEmployee {
+	sorted := append([]models.OrgEmployee(nil), employees...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
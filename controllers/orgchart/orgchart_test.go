@@ -0,0 +1,90 @@
+package orgchart
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reportsTo(id int) *int { return &id }
+
+// staff is a small company: Ada runs it, Ben runs Sales with Cy under him, and Di works in
+// Finance for Ada. Ed has no department and reports to someone who has left.
+var staff = []models.OrgEmployee{
+	{ID: 1, Name: "Ada", Department: "Executive"},
+	{ID: 2, Name: "Ben", Department: "Sales", ManagerID: reportsTo(1)},
+	{ID: 3, Name: "Cy", Department: "Sales", ManagerID: reportsTo(2)},
+	{ID: 4, Name: "Di", Department: "Finance", ManagerID: reportsTo(1)},
+	{ID: 5, Name: "Ed", ManagerID: reportsTo(9)},
+}
+
+// names returns the names of nodes.
+func names(nodes []*models.OrgNode) []string {
+	list := []string{}
+	for _, n := range nodes {
+		list = append(list, n.Name)
+	}
+	return list
+}
+
+func TestBuild(t *testing.T) {
+	chart, err := Build(staff, Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Ada", "Ed"}, names(chart.Roots))
+	ada := chart.Roots[0]
+	assert.Equal(t, 2, ada.DirectReports)
+	assert.Equal(t, []string{"Ben", "Di"}, names(ada.Reports))
+	assert.Equal(t, []string{"Cy"}, names(ada.Reports[0].Reports))
+
+	assert.Equal(t, []models.OrgDepartment{
+		{Department: "Executive", Headcount: 1, Heads: []int{1}},
+		{Department: "Finance", Headcount: 1, Heads: []int{4}},
+		{Department: "Sales", Headcount: 2, Heads: []int{2}},
+		{Department: "Unassigned", Headcount: 1, Heads: []int{5}},
+	}, chart.Departments)
+}
+
+func TestBuildWithOptions(t *testing.T) {
+	chart, err := Build(staff, Options{Depth: 2})
+	require.NoError(t, err)
+	ben := chart.Roots[0].Reports[0]
+	assert.Empty(t, ben.Reports, "the third level is cut off")
+	assert.Equal(t, 1, ben.DirectReports, "but still counted")
+	assert.Equal(t, 1, chart.Departments[2].Headcount, "departments count the employees shown")
+
+	chart, err = Build(staff, Options{Department: "sales"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ben"}, names(chart.Roots), "Ben heads Sales within the department")
+	assert.Equal(t, []string{"Cy"}, names(chart.Roots[0].Reports))
+
+	chart, err = Build(staff, Options{Root: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ben"}, names(chart.Roots))
+
+	_, err = Build(staff, Options{Root: 4, Department: "Sales"})
+	assert.True(t, errors.Is(err, models.ErrNotFound))
+}
+
+func TestBuildSurvivesLoops(t *testing.T) {
+	loop := []models.OrgEmployee{
+		{ID: 1, Name: "Ada"},
+		{ID: 2, Name: "Ben", ManagerID: reportsTo(3)},
+		{ID: 3, Name: "Cy", ManagerID: reportsTo(2)},
+	}
+	chart, err := Build(loop, Options{Root: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Cy"}, names(chart.Roots[0].Reports))
+	assert.Empty(t, chart.Roots[0].Reports[0].Reports, "Ben is not repeated under Cy")
+}
+
+func TestSetManagerToThemselves(t *testing.T) {
+	s := NewService(nil)
+	err := s.SetManager(context.Background(), 3, reportsTo(3))
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"erp/models"
+)
+
+// Dispatcher delivers due outbox messages to the handler registered for their kind.
+// Failed deliveries are retried with exponential backoff until MaxAttempts is reached,
+// after which the message is marked dead for manual inspection.
+type Dispatcher struct {
+	Store       models.OutboxStore
+	Handlers    map[string]Handler
+	Interval    time.Duration // How often the outbox is polled
+	BatchSize   int           // Maximum number of messages claimed per poll
+	MaxAttempts int           // Attempts before a message is marked dead
+	BaseBackoff time.Duration // Delay before the first retry; doubled on every further failure
+	MaxBackoff  time.Duration // Upper bound for the retry delay
+
+	// Writable reports whether messages may be delivered, typically the maintenance
+	// service; while it returns false the outbox is not polled. Nil always delivers.
+	Writable func(ctx context.Context) bool
+}
+
+// Run dispatches due messages every Interval until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		if d.Writable == nil || d.Writable(ctx) {
+			d.DispatchDue(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue claims one batch of due messages and delivers them.
+//
+// Returns:
+//   - int: The number of messages delivered successfully.
+func (d *Dispatcher) DispatchDue(ctx context.Context) int {
+	// Lease claimed messages for longer than a delivery can take so that another
+	// dispatcher does not pick them up while this one is still working on them.
+	messages, err := d.Store.ClaimDue(ctx, d.BatchSize, time.Now().Add(d.MaxBackoff+time.Minute))
+	if err != nil {
+		log.Printf("outbox: could not claim messages: %v", err)
+		return 0
+	}
+
+	delivered := 0
+	for _, msg := range messages {
+		if err := d.deliver(ctx, msg); err != nil {
+			d.fail(ctx, msg, err)
+			continue
+		}
+		if err := d.Store.MarkDelivered(ctx, msg.ID); err != nil {
+			log.Printf("outbox: could not mark message %d as delivered: %v", msg.ID, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// deliver runs the handler for the message's kind.
+func (d *Dispatcher) deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	handler, ok := d.Handlers[msg.Kind]
+	if !ok {
+		return fmt.Errorf("no handler registered for outbox kind %q", msg.Kind)
+	}
+	return handler.Deliver(ctx, msg)
+}
+
+// fail records a failed delivery and schedules a retry, or marks the message dead once
+// it has used up its attempts.
+func (d *Dispatcher) fail(ctx context.Context, msg *models.OutboxMessage, cause error) {
+	attempts := msg.Attempts + 1
+	log.Printf("outbox: delivering %s message %d failed (attempt %d): %v", msg.Kind, msg.ID, attempts, cause)
+
+	var err error
+	if attempts >= d.MaxAttempts {
+		err = d.Store.MarkDead(ctx, msg.ID, cause.Error())
+	} else {
+		err = d.Store.Reschedule(ctx, msg.ID, time.Now().Add(d.Backoff(attempts)), cause.Error())
+	}
+	if err != nil {
+		log.Printf("outbox: could not record failure for message %d: %v", msg.ID, err)
+	}
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed
+// attempts: BaseBackoff, 2×BaseBackoff, 4×BaseBackoff, … capped at MaxBackoff.
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return delay
+}
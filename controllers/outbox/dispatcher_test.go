@@ -0,0 +1,133 @@
+// Package outbox contains tests for the outbox dispatcher's retry and backoff behaviour.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// MockOutboxStore is an in-memory implementation of the OutboxStore interface.
+type MockOutboxStore struct {
+	messages map[int]*models.OutboxMessage
+}
+
+func (m *MockOutboxStore) ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]*models.OutboxMessage, error) {
+	var due []*models.OutboxMessage
+	for _, msg := range m.messages {
+		if msg.Status == models.OutboxPending && !msg.NextAttemptAt.After(time.Now()) && len(due) < limit {
+			copied := *msg
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (m *MockOutboxStore) MarkDelivered(ctx context.Context, id int) error {
+	m.messages[id].Status = models.OutboxDelivered
+	m.messages[id].Attempts++
+	return nil
+}
+
+func (m *MockOutboxStore) Reschedule(ctx context.Context, id int, nextAttemptAt time.Time, reason string) error {
+	m.messages[id].Attempts++
+	m.messages[id].NextAttemptAt = nextAttemptAt
+	m.messages[id].LastError = reason
+	return nil
+}
+
+func (m *MockOutboxStore) MarkDead(ctx context.Context, id int, reason string) error {
+	m.messages[id].Status = models.OutboxDead
+	m.messages[id].Attempts++
+	m.messages[id].LastError = reason
+	return nil
+}
+
+// newDispatcher creates a dispatcher over a store holding one pending email message.
+func newDispatcher(handler Handler) (*Dispatcher, *MockOutboxStore) {
+	store := &MockOutboxStore{messages: map[int]*models.OutboxMessage{
+		1: {ID: 1, Kind: KindEmail, Status: models.OutboxPending, NextAttemptAt: time.Now()},
+	}}
+	return &Dispatcher{
+		Store:       store,
+		Handlers:    map[string]Handler{KindEmail: handler},
+		BatchSize:   10,
+		MaxAttempts: 3,
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Second,
+	}, store
+}
+
+// TestBackoffDoublesUpToMaximum verifies the exponential retry schedule.
+func TestBackoffDoublesUpToMaximum(t *testing.T) {
+	d := &Dispatcher{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, d.Backoff(1))
+	assert.Equal(t, 2*time.Second, d.Backoff(2))
+	assert.Equal(t, 4*time.Second, d.Backoff(3))
+	assert.Equal(t, 5*time.Second, d.Backoff(4))
+}
+
+// TestDispatchReschedulesAndMarksDead verifies that failed deliveries are retried later and
+// given up on after MaxAttempts.
+func TestDispatchReschedulesAndMarksDead(t *testing.T) {
+	ctx := context.Background()
+	d, store := newDispatcher(HandlerFunc(func(ctx context.Context, msg *models.OutboxMessage) error {
+		return errors.New("smtp unavailable")
+	}))
+
+	assert.Equal(t, 0, d.DispatchDue(ctx))
+	msg := store.messages[1]
+	assert.Equal(t, models.OutboxPending, msg.Status)
+	assert.True(t, msg.NextAttemptAt.After(time.Now()))
+
+	// Not due yet, so nothing is attempted
+	d.DispatchDue(ctx)
+	assert.Equal(t, 1, msg.Attempts)
+
+	msg.NextAttemptAt = time.Now()
+	d.DispatchDue(ctx)
+	msg.NextAttemptAt = time.Now()
+	d.DispatchDue(ctx)
+	assert.Equal(t, models.OutboxDead, msg.Status)
+	assert.Equal(t, 3, msg.Attempts)
+	assert.Equal(t, "smtp unavailable", msg.LastError)
+}
+
+// TestDispatchDelivers verifies that successfully delivered messages are marked delivered.
+func TestDispatchDelivers(t *testing.T) {
+	ctx := context.Background()
+	d, store := newDispatcher(HandlerFunc(func(ctx context.Context, msg *models.OutboxMessage) error {
+		return nil
+	}))
+
+	assert.Equal(t, 1, d.DispatchDue(ctx))
+	assert.Equal(t, models.OutboxDelivered, store.messages[1].Status)
+	assert.Equal(t, 0, d.DispatchDue(ctx))
+}
+
+// TestRunPausesWhileNotWritable verifies that the outbox is not polled while writes are
+// paused.
+func TestRunPausesWhileNotWritable(t *testing.T) {
+	d, store := newDispatcher(HandlerFunc(func(ctx context.Context, msg *models.OutboxMessage) error {
+		return nil
+	}))
+	d.Interval = time.Millisecond
+	d.Writable = func(ctx context.Context) bool { return false }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	d.Run(ctx)
+	assert.Equal(t, models.OutboxPending, store.messages[1].Status)
+
+	d.Writable = func(ctx context.Context) bool { return true }
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	d.Run(ctx)
+	assert.Equal(t, models.OutboxDelivered, store.messages[1].Status)
+}
@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"erp/controllers/mailer"
+	"erp/models"
+)
+
+// EmailHandler delivers KindEmail messages, whose payload is a mailer.Message.
+type EmailHandler struct {
+	Mailer mailer.Mailer
+}
+
+// Deliver sends the email.
+func (h *EmailHandler) Deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	var email mailer.Message
+	if err := json.Unmarshal(msg.Payload, &email); err != nil {
+		return err
+	}
+	return h.Mailer.Send(ctx, email)
+}
+
+// Mailer queues emails in the outbox, so the dispatcher delivers them with retries. It
+// satisfies mailer.Mailer for code that sends email directly.
+type Mailer struct {
+	DB Queryer
+}
+
+// Send queues the email.
+func (m *Mailer) Send(ctx context.Context, msg mailer.Message) error {
+	return Enqueue(ctx, m.DB, KindEmail, msg)
+}
+
+// WebhookRequest is the payload of a KindWebhook message.
+type WebhookRequest struct {
+	URL     string            `json:"url"`
+	Body    json.RawMessage   `json:"body"`
+	Secret  string            `json:"secret,omitempty"` // Signs the body with HMAC-SHA256 when set
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// WebhookHandler delivers KindWebhook messages as HTTP POST requests.
+type WebhookHandler struct {
+	Client *http.Client // Optional HTTP client; a client with a timeout is used if nil
+}
+
+// Deliver posts the body to the target URL. Any non-2xx response is treated as a failure.
+// The outbox message ID is sent in X-Delivery-ID so receivers can deduplicate retries.
+func (h *WebhookHandler) Deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	var hook WebhookRequest
+	if err := json.Unmarshal(msg.Payload, &hook); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(hook.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Delivery-ID", fmt.Sprint(msg.ID))
+	for key, value := range hook.Headers {
+		req.Header.Set(key, value)
+	}
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(hook.Body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", hook.URL, resp.Status)
+	}
+	return nil
+}
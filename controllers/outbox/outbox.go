@@ -0,0 +1,66 @@
+// Package outbox implements the transactional outbox. Side-effect intents (emails, outgoing
+// webhooks, domain events) are inserted in the same database transaction as the business
+// change, and a Dispatcher delivers them afterwards with retries and exponential backoff.
+// A side effect is therefore never lost when the change commits, and never sent when it
+// rolls back.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"erp/models"
+)
+
+// Kinds of side effects delivered through the outbox.
+const (
+	KindEmail   = "email"
+	KindWebhook = "webhook"
+	KindEvent   = "event"
+)
+
+// Queryer is satisfied by both *sql.DB and *sql.Tx, so intents can be enqueued inside a
+// transaction or on their own.
+type Queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Enqueue writes a side-effect intent to the outbox.
+//
+// Parameters:
+//   - q: The transaction (or database) the intent is written with.
+//   - kind: One of the Kind* constants; selects the handler that delivers the message.
+//   - payload: Any JSON-serializable value understood by that handler.
+//
+// Returns:
+//   - error: An error if the payload cannot be serialized or the insert fails.
+func Enqueue(ctx context.Context, q Queryer, kind string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var id int
+	return q.QueryRowContext(ctx,
+		`INSERT INTO outbox_messages (kind, payload, status, attempts, next_attempt_at, created_at)
+		 VALUES ($1, $2, $3, 0, $4, $4) RETURNING id`,
+		kind, data, models.OutboxPending, now,
+	).Scan(&id)
+}
+
+// Handler delivers outbox messages of one kind. Delivery may happen more than once, so
+// handlers (and their receivers) should be idempotent.
+type Handler interface {
+	Deliver(ctx context.Context, msg *models.OutboxMessage) error
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface.
+type HandlerFunc func(ctx context.Context, msg *models.OutboxMessage) error
+
+// Deliver calls f(ctx, msg).
+func (f HandlerFunc) Deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	return f(ctx, msg)
+}
@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"erp/models"
+)
+
+// DBOutboxStore provides SQL-backed methods for the outbox_messages table.
+type DBOutboxStore struct {
+	DB *sql.DB // DB represents the database connection.
+}
+
+// ClaimDue leases up to limit due pending messages, oldest first. Rows locked by another
+// dispatcher are skipped.
+//
+// Parameters:
+//   - limit: The maximum number of messages to claim.
+//   - leaseUntil: The time until which claimed messages are hidden from other dispatchers.
+//
+// Returns:
+//   - []*OutboxMessage: The claimed messages.
+//   - error: An error if the query fails.
+func (store *DBOutboxStore) ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]*models.OutboxMessage, error) {
+	rows, err := store.DB.QueryContext(ctx,
+		`UPDATE outbox_messages SET next_attempt_at = $1
+		 WHERE id IN (
+		     SELECT id FROM outbox_messages
+		     WHERE status = $2 AND next_attempt_at <= $3
+		     ORDER BY id LIMIT $4
+		     FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING id, kind, payload, status, attempts, next_attempt_at, last_error, created_at`,
+		leaseUntil, models.OutboxPending, time.Now(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		var msg models.OutboxMessage
+		var payload []byte
+		var lastError sql.NullString
+		if err := rows.Scan(&msg.ID, &msg.Kind, &payload, &msg.Status, &msg.Attempts,
+			&msg.NextAttemptAt, &lastError, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		msg.Payload = payload
+		msg.LastError = lastError.String
+		messages = append(messages, &msg)
+	}
+	return messages, rows.Err()
+}
+
+// MarkDelivered records a successful delivery.
+//
+// Parameters:
+//   - id: The ID of the message.
+//
+// Returns:
+//   - error: models.ErrNotFound if the message does not exist, or an error if the update fails.
+func (store *DBOutboxStore) MarkDelivered(ctx context.Context, id int) error {
+	return store.update(ctx,
+		"UPDATE outbox_messages SET status = $1, attempts = attempts + 1, delivered_at = $2, last_error = NULL WHERE id = $3",
+		models.OutboxDelivered, time.Now(), id,
+	)
+}
+
+// Reschedule records a failed delivery and sets the time of the next attempt.
+//
+// Parameters:
+//   - id: The ID of the message.
+//   - nextAttemptAt: When the message becomes due again.
+//   - reason: The error returned by the handler.
+//
+// Returns:
+//   - error: models.ErrNotFound if the message does not exist, or an error if the update fails.
+func (store *DBOutboxStore) Reschedule(ctx context.Context, id int, nextAttemptAt time.Time, reason string) error {
+	return store.update(ctx,
+		"UPDATE outbox_messages SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2 WHERE id = $3",
+		nextAttemptAt, reason, id,
+	)
+}
+
+// MarkDead records the final failed delivery of a message that will not be retried.
+//
+// Parameters:
+//   - id: The ID of the message.
+//   - reason: The error returned by the handler.
+//
+// Returns:
+//   - error: models.ErrNotFound if the message does not exist, or an error if the update fails.
+func (store *DBOutboxStore) MarkDead(ctx context.Context, id int, reason string) error {
+	return store.update(ctx,
+		"UPDATE outbox_messages SET status = $1, attempts = attempts + 1, last_error = $2 WHERE id = $3",
+		models.OutboxDead, reason, id,
+	)
+}
+
+// update executes an update statement and checks that a row was affected.
+func (store *DBOutboxStore) update(ctx context.Context, query string, args ...interface{}) error {
+	result, err := store.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.NotFound("outbox message %v not found", args[len(args)-1])
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package pagination lists collections a page at a time. Handlers read ?page, ?limit, ?sort
+// and field filters from the query string with Parse, stores run the parameterized count
+// and page queries with Query, and handlers answer with Write:
+//
+//	GET /invoices?status=posted&customer_id=5&sort=-amount&page=2&limit=20
+//
+// Only the fields a collection declares in its Columns can be filtered and sorted by, so
+// query strings never reach the SQL text.
+package pagination
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+const (
+	DefaultLimit = 50  // Records per page when ?limit is not given
+	MaxLimit     = 200 // Most records a page may hold
+)
+
+// Type is the type of a column's values, used to check filter values.
+type Type int
+
+const (
+	Text   Type = iota // Matched case-insensitively
+	Int                // Whole numbers, e.g. IDs
+	Number             // Decimal numbers, e.g. amounts
+	Date               // Dates formatted as YYYY-MM-DD; timestamps match on their day
+)
+
+// Column is a field of a collection that lists can be filtered and sorted by.
+type Column struct {
+	Expr string // SQL expression of the field, e.g. "i.customer_id"
+	Type Type
+}
+
+// Columns maps the field names used in query strings to their columns. Every collection
+// has an "id" column, which lists are sorted by by default and ties are broken by.
+type Columns map[string]Column
+
+// Parse reads a list query from the query string: ?page (from 1), ?limit (1 to MaxLimit),
+// ?sort (a field, prefixed with "-" to sort in descending order) and a filter for any
+// other field of the collection.
+//
+// Parameters:
+//   - r: The request.
+//   - columns: The fields of the collection.
+//
+// Returns:
+//   - models.ListQuery: The query, sorted by ID if no sort is given.
+//   - error: A validation error if a parameter is invalid or names an unknown field.
+func Parse(r *http.Request, columns Columns) (models.ListQuery, error) {
+	query := models.ListQuery{Page: 1, Limit: DefaultLimit, Sort: "id", Filters: map[string]string{}}
+	var err error
+	for key, values := range r.URL.Query() {
+		value := values[0]
+		switch key {
+		case "page":
+			if query.Page, err = strconv.Atoi(value); err != nil || query.Page < 1 {
+				return query, models.Invalid("page must be a whole number from 1")
+			}
+		case "limit":
+			if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 || query.Limit > MaxLimit {
+				return query, models.Invalid("limit must be between 1 and %d", MaxLimit)
+			}
+		case "sort":
+			field := strings.TrimPrefix(value, "-")
+			if _, ok := columns[field]; !ok {
+				return query, models.Invalid("cannot sort by %q", field)
+			}
+			query.Sort, query.Desc = field, strings.HasPrefix(value, "-")
+		default:
+			column, ok := columns[key]
+			if !ok {
+				return query, models.Invalid("cannot filter by %q", key)
+			}
+			if _, err := column.arg(value); err != nil {
+				return query, models.Invalid("%s: %v", key, err)
+			}
+			query.Filters[key] = value
+		}
+	}
+	return query, nil
+}
+
+// Query runs a list query: it counts the records matching the filters and scans the page.
+//
+// Parameters:
+//   - db: The database connection.
+//   - fields: The select list, e.g. "id, name".
+//   - from: The FROM clause, e.g. "invoices i" or a join.
+//   - columns: The fields of the collection.
+//   - query: The list query, as returned by Parse.
+//   - scan: Called for each row of the page.
+//
+// Returns:
+//   - int: The number of records matching the filters.
+//   - error: An error if a filter or the sort names an unknown field, or a query or scan fails.
+func Query(ctx context.Context, db *sql.DB, fields, from string, columns Columns, query models.ListQuery, scan func(rows *sql.Rows) error) (int, error) {
+	return QueryWhere(ctx, db, fields, from, "", columns, query, scan)
+}
+
+// QueryWhere runs a list query like Query, restricted to the records matching a fixed SQL
+// condition, e.g. "deleted_at IS NULL", which is ANDed with the filters.
+func QueryWhere(ctx context.Context, db *sql.DB, fields, from, base string, columns Columns, query models.ListQuery, scan func(rows *sql.Rows) error) (int, error) {
+	keys := make([]string, 0, len(query.Filters))
+	for key := range query.Filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var conditions []string
+	if base != "" {
+		conditions = append(conditions, base)
+	}
+	var args []interface{}
+	for _, key := range keys {
+		column, ok := columns[key]
+		if !ok {
+			return 0, models.Invalid("cannot filter by %q", key)
+		}
+		arg, err := column.arg(query.Filters[key])
+		if err != nil {
+			return 0, models.Invalid("%s: %v", key, err)
+		}
+		args = append(args, arg)
+		conditions = append(conditions, column.condition(len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+from+where, args...).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	id := columns["id"].Expr
+	order := id
+	if query.Sort != "" && query.Sort != "id" {
+		column, ok := columns[query.Sort]
+		if !ok {
+			return 0, models.Invalid("cannot sort by %q", query.Sort)
+		}
+		order = column.Expr
+	}
+	direction := "ASC"
+	if query.Desc {
+		direction = "DESC"
+	}
+	statement := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s %s, %s LIMIT $%d OFFSET $%d",
+		fields, from, where, order, direction, id, len(args)+1, len(args)+2)
+	rows, err := db.QueryContext(ctx, statement, append(args, query.Limit, query.Offset())...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return 0, err
+		}
+	}
+	return total, rows.Err()
+}
+
+// Bounds returns the slice bounds of a page of n records, for stores that list in memory.
+func Bounds(n int, query models.ListQuery) (start, end int) {
+	start = query.Offset()
+	if start > n {
+		start = n
+	}
+	end = start + query.Limit
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+// Write answers with a page of records as a models.Page in JSON.
+func Write(w http.ResponseWriter, items interface{}, total int, query models.ListQuery) {
+	respond.JSON(w, http.StatusOK, models.Page{Items: items, Total: total, Page: query.Page, Limit: query.Limit})
+}
+
+// arg converts a filter value to the column's type.
+func (c Column) arg(value string) (interface{}, error) {
+	switch c.Type {
+	case Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a whole number", value)
+		}
+		return n, nil
+	case Number:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return n, nil
+	case Date:
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return nil, fmt.Errorf("%q is not a date formatted as YYYY-MM-DD", value)
+		}
+		return value, nil
+	}
+	return value, nil
+}
+
+// condition returns the SQL condition comparing the column with parameter n.
+func (c Column) condition(n int) string {
+	switch c.Type {
+	case Text:
+		return fmt.Sprintf("LOWER(%s) = LOWER($%d)", c.Expr, n)
+	case Date:
+		return fmt.Sprintf("CAST(%s AS DATE) = $%d", c.Expr, n)
+	}
+	return fmt.Sprintf("%s = $%d", c.Expr, n)
+}
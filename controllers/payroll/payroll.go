@@ -0,0 +1,234 @@
+// Package payroll works out monthly pay from attendance and approved leave. Each salary
+// grade has an hourly rate; hours worked beyond the working day are overtime, paid at a
+// multiple of the rate, and approved leave is paid as full working days unless its type is
+// unpaid. A month is run once, and its gross pay is posted to the ledger as salary expense
+// owed to the employees.
+package payroll
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/config"
+	"erp/models"
+)
+
+// periodLayout is the format of payroll periods.
+const periodLayout = "2006-01"
+
+// dayLayout keys attendance and leave by calendar day.
+const dayLayout = "2006-01-02"
+
+// Service manages pay rates and runs the monthly payroll.
+type Service struct {
+	Store  models.PayrollStore
+	Config config.PayrollConfig
+	Now    func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a payroll service backed by store.
+func NewService(store models.PayrollStore, cfg config.PayrollConfig) *Service {
+	return &Service{Store: store, Config: cfg, Now: time.Now}
+}
+
+// Rates returns the pay rate of every grade.
+func (s *Service) Rates(ctx context.Context) ([]models.PayRate, error) {
+	return s.Store.ListPayRates(ctx)
+}
+
+// SaveRate checks a pay rate and creates or replaces the rate of its grade. Months already
+// run are not changed.
+//
+// Returns:
+//   - error: A validation error if the grade is blank, the rate is not positive or the
+//     overtime multiplier is below 1, or the store's error.
+func (s *Service) SaveRate(ctx context.Context, rate *models.PayRate, actor string) error {
+	rate.Grade = strings.TrimSpace(rate.Grade)
+	switch {
+	case rate.Grade == "":
+		return models.Invalid("grade is required")
+	case rate.HourlyRate <= 0:
+		return models.Invalid("hourly_rate must be positive")
+	case rate.OvertimeMultiplier != 0 && rate.OvertimeMultiplier < 1:
+		return models.Invalid("overtime_multiplier must be at least 1")
+	}
+	rate.UpdatedBy, rate.UpdatedAt = actor, s.Now()
+	return s.Store.SavePayRate(ctx, rate)
+}
+
+// DeleteRate removes the rate of a grade. Its employees are skipped by later runs.
+func (s *Service) DeleteRate(ctx context.Context, grade string) error {
+	return s.Store.DeletePayRate(ctx, grade)
+}
+
+// Record returns an employee's pay for a month that has been run.
+//
+// Returns:
+//   - error: A validation error if the period is invalid, a not found error if the employee
+//     was not paid for it, or the store's error.
+func (s *Service) Record(ctx context.Context, employeeID int, period string) (*models.PayrollRecord, error) {
+	if _, err := time.Parse(periodLayout, period); err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	return s.Store.GetPayrollRecord(ctx, employeeID, period)
+}
+
+// Run works out the pay of every employee with a user account for a month and posts it to
+// the ledger, dated the last day of the month. Employees without a graded pay rate, hired
+// after the month or with nothing to pay are skipped, with the reason in the run.
+//
+// Parameters:
+//   - period: The month, as YYYY-MM.
+//   - actor: Email of the user running the payroll.
+//
+// Returns:
+//   - *models.PayrollRun: The posted run with every employee's pay.
+//   - error: A validation error if the period is invalid or has not ended, a conflict if it
+//     has been run already, or the store's error.
+func (s *Service) Run(ctx context.Context, period, actor string) (*models.PayrollRun, error) {
+	from, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	to := from.AddDate(0, 1, 0)
+	now := s.Now()
+	if to.After(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)) {
+		return nil, models.Invalid("%s has not ended", period)
+	}
+
+	rates, err := s.Store.ListPayRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rateOf := map[string]models.PayRate{}
+	for _, rate := range rates {
+		rateOf[rate.Grade] = rate
+	}
+	employees, err := s.Store.ListPayrollEmployees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attendance, err := s.Store.ListAttendance(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	leave, err := s.Store.ListApprovedLeave(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hours worked per user and day, by the day they checked in
+	worked := map[int]map[string]float64{}
+	for _, a := range attendance {
+		if worked[a.UserID] == nil {
+			worked[a.UserID] = map[string]float64{}
+		}
+		worked[a.UserID][a.CheckIn.Format(dayLayout)] += a.TotalHours
+	}
+	leaveOf := map[int][]models.Leave{}
+	for _, l := range leave {
+		leaveOf[l.UserID] = append(leaveOf[l.UserID], l)
+	}
+
+	run := &models.PayrollRun{Period: period, Actor: actor, Records: []models.PayrollRecord{}}
+	sort.Slice(employees, func(i, j int) bool { return employees[i].ID < employees[j].ID })
+	for _, employee := range employees {
+		if employee.UserID == nil {
+			continue
+		}
+		if !employee.HireDate.Before(to) {
+			run.Skipped = append(run.Skipped, fmt.Sprintf("%s: hired after %s", employee.Name, period))
+			continue
+		}
+		if employee.SalaryGrade == "" {
+			run.Skipped = append(run.Skipped, fmt.Sprintf("%s: no salary grade", employee.Name))
+			continue
+		}
+		rate, ok := rateOf[employee.SalaryGrade]
+		if !ok {
+			run.Skipped = append(run.Skipped, fmt.Sprintf("%s: no pay rate for grade %s", employee.Name, employee.SalaryGrade))
+			continue
+		}
+
+		record := s.pay(employee, rate, worked[*employee.UserID], leaveOf[*employee.UserID], from, to)
+		record.Period = period
+		if record.Gross == 0 {
+			run.Skipped = append(run.Skipped, fmt.Sprintf("%s: no attendance or paid leave", employee.Name))
+			continue
+		}
+		run.Records = append(run.Records, record)
+		run.Total += record.Gross
+	}
+	run.Total = roundCents(run.Total)
+
+	if err := s.Store.SavePayrollRun(ctx, run, to.AddDate(0, 0, -1)); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// pay works out one employee's pay for the month from the hours they worked each day and
+// their approved leave. Leave is counted on weekdays within the month on which they did not
+// work.
+func (s *Service) pay(employee models.Employee, rate models.PayRate, worked map[string]float64,
+	leave []models.Leave, from, to time.Time) models.PayrollRecord {
+	multiplier := rate.OvertimeMultiplier
+	if multiplier == 0 {
+		multiplier = s.Config.OvertimeMultiplier
+	}
+	record := models.PayrollRecord{
+		EmployeeID: employee.ID, UserID: *employee.UserID, Employee: employee.Name, Email: employee.Email,
+		Grade: rate.Grade, HourlyRate: rate.HourlyRate,
+	}
+	for _, hours := range worked {
+		regular := min(hours, s.Config.HoursPerDay)
+		record.RegularHours += regular
+		record.OvertimeHours += hours - regular
+	}
+
+	counted := map[string]bool{}
+	for _, l := range leave {
+		unpaid := s.unpaid(l.LeaveType)
+		start := time.Date(l.StartDate.Year(), l.StartDate.Month(), l.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+		for day := start; !day.After(l.EndDate) && day.Before(to); day = day.AddDate(0, 0, 1) {
+			key := day.Format(dayLayout)
+			if day.Before(from) || day.Weekday() == time.Saturday || day.Weekday() == time.Sunday ||
+				worked[key] > 0 || counted[key] {
+				continue
+			}
+			counted[key] = true
+			if unpaid {
+				record.UnpaidLeaveDays++
+			} else {
+				record.LeaveDays++
+			}
+		}
+	}
+
+	record.RegularHours = math.Round(record.RegularHours*100) / 100
+	record.OvertimeHours = math.Round(record.OvertimeHours*100) / 100
+	record.RegularPay = roundCents(record.RegularHours * rate.HourlyRate)
+	record.OvertimePay = roundCents(record.OvertimeHours * rate.HourlyRate * multiplier)
+	record.LeavePay = roundCents(record.LeaveDays * s.Config.HoursPerDay * rate.HourlyRate)
+	record.Gross = roundCents(record.RegularPay + record.OvertimePay + record.LeavePay)
+	return record
+}
+
+// unpaid reports whether a leave type is configured as unpaid.
+func (s *Service) unpaid(leaveType string) bool {
+	for _, t := range s.Config.UnpaidLeave {
+		if strings.EqualFold(strings.TrimSpace(t), strings.TrimSpace(leaveType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// roundCents rounds an amount to two decimal places.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
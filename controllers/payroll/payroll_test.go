@@ -0,0 +1,162 @@
+package payroll
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/config"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps rates, employees, attendance and leave in memory, and the runs saved.
+type fakeStore struct {
+	rates      []models.PayRate
+	employees  []models.Employee
+	attendance []models.Attendance
+	leave      []models.Leave
+	runs       map[string]*models.PayrollRun
+	posted     time.Time
+}
+
+func (f *fakeStore) ListPayRates(ctx context.Context) ([]models.PayRate, error) { return f.rates, nil }
+
+func (f *fakeStore) SavePayRate(ctx context.Context, rate *models.PayRate) error {
+	f.rates = append(f.rates, *rate)
+	return nil
+}
+
+func (f *fakeStore) DeletePayRate(ctx context.Context, grade string) error { return nil }
+
+func (f *fakeStore) ListPayrollEmployees(ctx context.Context) ([]models.Employee, error) {
+	return f.employees, nil
+}
+
+func (f *fakeStore) ListAttendance(ctx context.Context, from, to time.Time) ([]models.Attendance, error) {
+	return f.attendance, nil
+}
+
+func (f *fakeStore) ListApprovedLeave(ctx context.Context, from, to time.Time) ([]models.Leave, error) {
+	return f.leave, nil
+}
+
+func (f *fakeStore) SavePayrollRun(ctx context.Context, run *models.PayrollRun, postingDate time.Time) error {
+	if f.runs[run.Period] != nil {
+		return models.Conflict("payroll for %s has been run already", run.Period)
+	}
+	f.runs[run.Period], f.posted = run, postingDate
+	return nil
+}
+
+func (f *fakeStore) GetPayrollRecord(ctx context.Context, employeeID int, period string) (*models.PayrollRecord, error) {
+	return nil, models.NotFound("employee %d was not paid for %s", employeeID, period)
+}
+
+func day(d, hour int) time.Time { return time.Date(2025, 6, d, hour, 0, 0, 0, time.UTC) }
+
+func user(id int) *int { return &id }
+
+func newTestService(store *fakeStore) *Service {
+	service := NewService(store, config.PayrollConfig{HoursPerDay: 8, OvertimeMultiplier: 1.5, UnpaidLeave: []string{"Unpaid"}})
+	service.Now = func() time.Time { return time.Date(2025, 7, 3, 0, 0, 0, 0, time.UTC) }
+	return service
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	hired := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{
+		runs:  map[string]*models.PayrollRun{},
+		rates: []models.PayRate{{Grade: "G1", HourlyRate: 20}, {Grade: "G2", HourlyRate: 30, OvertimeMultiplier: 2}},
+		employees: []models.Employee{
+			{ID: 1, UserID: user(11), Name: "Ada", SalaryGrade: "G1", HireDate: hired},
+			{ID: 2, UserID: user(12), Name: "Ben", SalaryGrade: "G2", HireDate: hired},
+			{ID: 3, UserID: user(13), Name: "Cy", HireDate: hired},
+			{ID: 4, UserID: user(14), Name: "Di", SalaryGrade: "G9", HireDate: hired},
+			{ID: 5, UserID: user(15), Name: "Ed", SalaryGrade: "G1", HireDate: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: 6, UserID: user(16), Name: "Flo", SalaryGrade: "G1", HireDate: hired},
+		},
+		attendance: []models.Attendance{
+			// Ada: 10 hours on Monday the 2nd, split across two check-ins, and 6 on Tuesday
+			{UserID: 11, CheckIn: day(2, 8), TotalHours: 4},
+			{UserID: 11, CheckIn: day(2, 13), TotalHours: 6},
+			{UserID: 11, CheckIn: day(3, 9), TotalHours: 6},
+			// Ben: 9 hours on Wednesday the 4th, during their leave
+			{UserID: 12, CheckIn: day(4, 9), TotalHours: 9},
+		},
+		leave: []models.Leave{
+			// Ada: Friday the 6th to Monday the 9th, two working days
+			{UserID: 11, LeaveType: "Annual", StartDate: day(6, 0), EndDate: day(9, 0), Status: "Approved"},
+			// Ben: May 30th to Thursday June 5th; the 4th was worked
+			{UserID: 12, LeaveType: "Sick", StartDate: time.Date(2025, 5, 30, 0, 0, 0, 0, time.UTC), EndDate: day(5, 0), Status: "Approved"},
+			{UserID: 12, LeaveType: "unpaid", StartDate: day(10, 0), EndDate: day(10, 0), Status: "Approved"},
+		},
+	}
+
+	run, err := newTestService(store).Run(ctx, "2025-06", "payroll@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC), store.posted)
+	assert.Equal(t, "payroll@example.com", run.Actor)
+	assert.Equal(t, []string{
+		"Cy: no salary grade",
+		"Di: no pay rate for grade G9",
+		"Ed: hired after 2025-06",
+		"Flo: no attendance or paid leave",
+	}, run.Skipped)
+	require.Len(t, run.Records, 2)
+
+	ada := run.Records[0]
+	assert.Equal(t, 14.0, ada.RegularHours)
+	assert.Equal(t, 2.0, ada.OvertimeHours)
+	assert.Equal(t, 2.0, ada.LeaveDays)
+	assert.Equal(t, 280.0, ada.RegularPay)
+	assert.Equal(t, 60.0, ada.OvertimePay)
+	assert.Equal(t, 320.0, ada.LeavePay)
+	assert.Equal(t, 660.0, ada.Gross)
+
+	ben := run.Records[1]
+	assert.Equal(t, 8.0, ben.RegularHours)
+	assert.Equal(t, 1.0, ben.OvertimeHours)
+	assert.Equal(t, 3.0, ben.LeaveDays, "June 2nd, 3rd and 5th")
+	assert.Equal(t, 1.0, ben.UnpaidLeaveDays)
+	assert.Equal(t, 60.0, ben.OvertimePay, "the grade's own multiplier")
+	assert.Equal(t, 240.0+60+720, ben.Gross)
+	assert.Equal(t, 1680.0, run.Total)
+
+	_, err = newTestService(store).Run(ctx, "2025-06", "")
+	assert.True(t, errors.Is(err, models.ErrConflict))
+}
+
+func TestRunChecksPeriod(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(&fakeStore{runs: map[string]*models.PayrollRun{}})
+	for _, period := range []string{"2025-7", "June", "2025-07"} {
+		_, err := service.Run(ctx, period, "")
+		assert.True(t, errors.Is(err, models.ErrValidation), period)
+	}
+	_, err := service.Record(ctx, 1, "2025/06")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
+
+func TestSaveRate(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{}
+	service := newTestService(store)
+
+	rate := models.PayRate{Grade: " G1 ", HourlyRate: 20}
+	require.NoError(t, service.SaveRate(ctx, &rate, "payroll@example.com"))
+	assert.Equal(t, "G1", store.rates[0].Grade)
+	assert.Equal(t, "payroll@example.com", store.rates[0].UpdatedBy)
+
+	for _, bad := range []models.PayRate{
+		{HourlyRate: 20},
+		{Grade: "G1"},
+		{Grade: "G1", HourlyRate: 20, OvertimeMultiplier: 0.5},
+	} {
+		assert.True(t, errors.Is(service.SaveRate(ctx, &bad, ""), models.ErrValidation), "%+v", bad)
+	}
+}
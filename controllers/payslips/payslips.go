@@ -0,0 +1,203 @@
+// Package payslips lays out employees' payslips and emails them. A payslip is built from an
+// employee's statutory record of a period; employees may read their own, and HR and payroll
+// staff everyone's.
+package payslips
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/models"
+)
+
+// EmailTemplate is the email template payslip notices are rendered from.
+const EmailTemplate = "payslip"
+
+// periodLayout is the format of a pay period.
+const periodLayout = "2006-01"
+
+// Service reads payslips and emails them.
+type Service struct {
+	Store     models.PayslipStore
+	Templates *mailtemplates.Service                              // Renders payslip notices
+	Send      func(ctx context.Context, msg mailer.Message) error // Queues an email, e.g. in the outbox
+	BaseURL   string                                              // Public address of the API the download links point to
+}
+
+// NewService creates a payslip service.
+func NewService(store models.PayslipStore, templates *mailtemplates.Service, send func(ctx context.Context, msg mailer.Message) error, baseURL string) *Service {
+	return &Service{Store: store, Templates: templates, Send: send, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Payslip returns a payslip for a user who may read it: its employee, or HR and payroll
+// staff.
+//
+// Parameters:
+//   - id: The payslip.
+//   - email: Email of the signed-in user.
+//   - privileged: Whether the user may read every employee's payslips.
+//
+// Returns:
+//   - *models.Payslip: The payslip with its deductions and year-to-date totals.
+//   - error: models.ErrNotFound if it does not exist, models.ErrPermissionDenied if it is
+//     another employee's, or the store's error.
+func (s *Service) Payslip(ctx context.Context, id int, email string, privileged bool) (*models.Payslip, error) {
+	payslip, err := s.Store.GetPayslip(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !privileged && (email == "" || !strings.EqualFold(payslip.Email, email)) {
+		return nil, models.PermissionDenied("payslip %d belongs to another employee", id)
+	}
+	return payslip, nil
+}
+
+// Payslips lists the payslips of a period.
+func (s *Service) Payslips(ctx context.Context, period string) ([]models.Payslip, error) {
+	if _, err := time.Parse(periodLayout, period); err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	return s.Store.GetPayslips(ctx, period, "")
+}
+
+// Mine lists the payslips of the signed-in employee.
+func (s *Service) Mine(ctx context.Context, email string) ([]models.Payslip, error) {
+	if email == "" {
+		return nil, models.PermissionDenied("sign in to see your payslips")
+	}
+	return s.Store.GetPayslips(ctx, "", email)
+}
+
+// Summary returns an employee's pay for a year by month with the year's totals, for a user
+// who may read it: the employee, or HR and payroll staff.
+//
+// Parameters:
+//   - userID: The employee.
+//   - year: The year, e.g. "2024"; "" for the current year.
+//   - email: Email of the signed-in user.
+//   - privileged: Whether the user may read every employee's pay.
+//
+// Returns:
+//   - *models.PayrollSummary: The months the employee was paid and the totals.
+//   - error: A validation error if the year is invalid, models.ErrNotFound if the employee
+//     does not exist, models.ErrPermissionDenied if it is another employee's, or the store's
+//     error.
+func (s *Service) Summary(ctx context.Context, userID int, year, email string, privileged bool) (*models.PayrollSummary, error) {
+	y := time.Now().Year()
+	if year != "" {
+		t, err := time.Parse("2006", year)
+		if err != nil {
+			return nil, models.Invalid("year must be formatted as YYYY")
+		}
+		y = t.Year()
+	}
+	summary, err := s.Store.GetPayrollSummary(ctx, userID, y)
+	if err != nil {
+		return nil, err
+	}
+	if !privileged && (email == "" || !strings.EqualFold(summary.Email, email)) {
+		return nil, models.PermissionDenied("the payroll summary of user %d belongs to another employee", userID)
+	}
+	summary.Totals = models.PayrollAmounts{}
+	for _, month := range summary.Months {
+		summary.Totals.Gross += month.Gross
+		summary.Totals.Tax += month.Tax
+		summary.Totals.Benefits += month.Benefits
+		summary.Totals.Net += month.Net
+		summary.Totals.Employer += month.Employer
+	}
+	return summary, nil
+}
+
+// Email tells every employee with a payslip for a period that it is ready, with a link to
+// download it. It is run after the period's payroll is recorded; running it again sends the
+// notices again.
+//
+// Parameters:
+//   - period: The pay period, formatted as YYYY-MM.
+//
+// Returns:
+//   - int: The number of notices queued.
+//   - error: A validation error if the period is invalid, or an error if the payslips cannot
+//     be read or a notice cannot be rendered or queued; the notices before it are queued.
+func (s *Service) Email(ctx context.Context, period string) (int, error) {
+	payslips, err := s.Payslips(ctx, period)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, payslip := range payslips {
+		if payslip.Email == "" {
+			continue
+		}
+		msg, err := s.Templates.Email(ctx, EmailTemplate, "", []string{payslip.Email}, map[string]string{
+			"employee":   payslip.Employee,
+			"period":     payslip.Period,
+			"gross":      fmt.Sprintf("%.2f", payslip.Gross),
+			"deductions": fmt.Sprintf("%.2f", payslip.TotalDeductions),
+			"net":        fmt.Sprintf("%.2f", payslip.Net),
+			"link":       fmt.Sprintf("%s/payroll/payslips/%d/pdf", s.BaseURL, payslip.ID),
+		})
+		if err != nil {
+			return sent, err
+		}
+		if err := s.Send(ctx, msg); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Document lays out a payslip for printing: the gross pay and each deduction, the net pay,
+// and the year-to-date totals.
+func Document(payslip *models.Payslip, company *models.CompanyProfile) *models.PrintedDocument {
+	doc := &models.PrintedDocument{
+		Title:   "Payslip",
+		Number:  fmt.Sprintf("PS-%s-%05d", payslip.Period, payslip.ID),
+		Date:    payslip.RecordedAt,
+		Company: company,
+		Parties: []models.DocumentParty{{Label: "Employee", Lines: nonEmpty(payslip.Employee, payslip.Email, payslip.Department)}},
+		Details: []models.DocumentField{{Label: "Pay period", Value: payslip.Period}},
+		Columns: []string{"Description", "Earnings", "Deductions"},
+		Lines:   [][]string{{"Gross pay", money(payslip.Gross), ""}},
+	}
+	for _, line := range payslip.Deductions {
+		doc.Lines = append(doc.Lines, []string{line.Name, "", money(line.Employee)})
+	}
+	doc.Totals = []models.DocumentField{
+		{Label: "Total deductions", Value: money(payslip.TotalDeductions)},
+		{Label: "Net pay", Value: money(payslip.Net)},
+	}
+	if payslip.Employer > 0 {
+		doc.Totals = append(doc.Totals, models.DocumentField{Label: "Employer contributions", Value: money(payslip.Employer)})
+	}
+	if ytd := payslip.YearToDate; ytd != nil {
+		doc.Totals = append(doc.Totals,
+			models.DocumentField{Label: "Gross year to date", Value: money(ytd.Gross)},
+			models.DocumentField{Label: "Deductions year to date", Value: money(ytd.Deductions)},
+			models.DocumentField{Label: "Net year to date", Value: money(ytd.Net)},
+		)
+	}
+	return doc
+}
+
+// money formats an amount with two decimals.
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// nonEmpty returns the values that are not empty.
+func nonEmpty(values ...string) []string {
+	var lines []string
+	for _, value := range values {
+		if value != "" {
+			lines = append(lines, value)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,174 @@
+package payslips
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore serves payslips from a slice.
+type fakeStore struct {
+	payslips []models.Payslip
+}
+
+func (f *fakeStore) GetPayslip(ctx context.Context, id int) (*models.Payslip, error) {
+	for _, payslip := range f.payslips {
+		if payslip.ID == id {
+			return &payslip, nil
+		}
+	}
+	return nil, models.NotFound("payslip %d not found", id)
+}
+
+func (f *fakeStore) GetPayslips(ctx context.Context, period, email string) ([]models.Payslip, error) {
+	var list []models.Payslip
+	for _, payslip := range f.payslips {
+		if (period == "" || payslip.Period == period) && (email == "" || payslip.Email == email) {
+			list = append(list, payslip)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeStore) GetPayrollSummary(ctx context.Context, userID, year int) (*models.PayrollSummary, error) {
+	if userID != 1 {
+		return nil, models.NotFound("user %d not found", userID)
+	}
+	return &models.PayrollSummary{UserID: 1, Employee: "Ana", Email: "ana@example.com", Year: year, Months: []models.PayrollMonth{
+		{Period: "2024-04", PayrollAmounts: models.PayrollAmounts{Gross: 5000, Tax: 500, Benefits: 375, Net: 4125, Employer: 600}},
+		{Period: "2024-05", PayrollAmounts: models.PayrollAmounts{Gross: 5200, Tax: 520, Benefits: 390, Net: 4290, Employer: 624}},
+	}}, nil
+}
+
+// templateStore holds the payslip template.
+type templateStore struct{}
+
+func (templateStore) ListCurrentTemplates(ctx context.Context) ([]models.EmailTemplate, error) {
+	return nil, nil
+}
+
+func (templateStore) GetTemplate(ctx context.Context, key, locale string, version int) (*models.EmailTemplate, error) {
+	return &models.EmailTemplate{Key: key, Locale: "en", Subject: "Payslip {{period}}",
+		Body: "Dear {{employee}}, net pay {{net}}: {{link}}"}, nil
+}
+
+func (templateStore) ListTemplateVersions(ctx context.Context, key, locale string) ([]models.EmailTemplate, error) {
+	return nil, nil
+}
+
+func (templateStore) AddTemplateVersion(ctx context.Context, template *models.EmailTemplate) error {
+	return nil
+}
+
+func newTestService() (*Service, *[]mailer.Message) {
+	store := &fakeStore{payslips: []models.Payslip{
+		{ID: 1, Employee: "Ana", Email: "ana@example.com", Period: "2024-05", Gross: 5000, TotalDeductions: 875, Net: 4125},
+		{ID: 2, Employee: "Ben", Email: "ben@example.com", Period: "2024-05", Gross: 3000, TotalDeductions: 300, Net: 2700},
+		{ID: 3, Employee: "Ana", Email: "ana@example.com", Period: "2024-04", Gross: 5000, TotalDeductions: 875, Net: 4125},
+	}}
+	var sent []mailer.Message
+	service := NewService(store, mailtemplates.NewService(templateStore{}, "en"), func(ctx context.Context, msg mailer.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}, "https://erp.example.com/")
+	return service, &sent
+}
+
+func TestPayslipIsRestrictedToItsEmployee(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService()
+
+	payslip, err := service.Payslip(ctx, 1, "Ana@Example.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, "Ana", payslip.Employee)
+
+	_, err = service.Payslip(ctx, 1, "ben@example.com", false)
+	assert.True(t, errors.Is(err, models.ErrPermissionDenied))
+	_, err = service.Payslip(ctx, 1, "", false)
+	assert.True(t, errors.Is(err, models.ErrPermissionDenied))
+
+	_, err = service.Payslip(ctx, 1, "hr@example.com", true)
+	assert.NoError(t, err, "HR and payroll staff read every payslip")
+	_, err = service.Payslip(ctx, 9, "hr@example.com", true)
+	assert.True(t, errors.Is(err, models.ErrNotFound))
+}
+
+func TestPayslipsAndMine(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService()
+
+	list, err := service.Payslips(ctx, "2024-05")
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+	_, err = service.Payslips(ctx, "May 2024")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+
+	mine, err := service.Mine(ctx, "ana@example.com")
+	require.NoError(t, err)
+	assert.Len(t, mine, 2)
+}
+
+func TestSummaryTotalsTheYear(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService()
+
+	summary, err := service.Summary(ctx, 1, "2024", "ana@example.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, 2024, summary.Year)
+	assert.Len(t, summary.Months, 2)
+	assert.Equal(t, models.PayrollAmounts{Gross: 10200, Tax: 1020, Benefits: 765, Net: 8415, Employer: 1224}, summary.Totals)
+
+	_, err = service.Summary(ctx, 1, "2024", "ben@example.com", false)
+	assert.True(t, errors.Is(err, models.ErrPermissionDenied))
+	_, err = service.Summary(ctx, 1, "2024", "hr@example.com", true)
+	assert.NoError(t, err, "HR and payroll staff read every employee's summary")
+	_, err = service.Summary(ctx, 1, "24", "ana@example.com", false)
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	_, err = service.Summary(ctx, 9, "", "hr@example.com", true)
+	assert.True(t, errors.Is(err, models.ErrNotFound))
+}
+
+func TestEmailSendsALinkToEachPayslip(t *testing.T) {
+	service, sent := newTestService()
+
+	n, err := service.Email(context.Background(), "2024-05")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.Len(t, *sent, 2)
+	assert.Equal(t, []string{"ana@example.com"}, (*sent)[0].To)
+	assert.Equal(t, "Payslip 2024-05", (*sent)[0].Subject)
+	assert.Equal(t, "Dear Ana, net pay 4125.00: https://erp.example.com/payroll/payslips/1/pdf", (*sent)[0].Body)
+}
+
+func TestDocument(t *testing.T) {
+	payslip := &models.Payslip{
+		ID: 12, Employee: "Ana", Email: "ana@example.com", Period: "2024-05",
+		Gross: 5000, TotalDeductions: 875, Net: 4125, Employer: 600,
+		RecordedAt: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC),
+		Deductions: []models.StatutoryLine{
+			{Code: "IT", Name: "Income tax", Employee: 500},
+			{Code: "PF", Name: "Provident fund", Employee: 375, Employer: 600},
+		},
+		YearToDate: &models.PayslipTotals{Gross: 25000, Deductions: 4375, Net: 20625, Employer: 3000},
+	}
+
+	doc := Document(payslip, nil)
+	assert.Equal(t, "PS-2024-05-00012", doc.Number)
+	assert.Equal(t, [][]string{
+		{"Gross pay", "5000.00", ""},
+		{"Income tax", "", "500.00"},
+		{"Provident fund", "", "375.00"},
+	}, doc.Lines)
+	assert.Equal(t, []string{"Ana", "ana@example.com"}, doc.Parties[0].Lines)
+	assert.Contains(t, doc.Totals, models.DocumentField{Label: "Net pay", Value: "4125.00"})
+	assert.Contains(t, doc.Totals, models.DocumentField{Label: "Employer contributions", Value: "600.00"})
+	assert.Contains(t, doc.Totals, models.DocumentField{Label: "Net year to date", Value: "20625.00"})
+}
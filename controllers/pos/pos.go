@@ -0,0 +1,81 @@
+// Package pos records sales rung up at retail counters. A sale is checked and its lines
+// merged before it reaches the store, which records it in a handful of statements so a
+// counter gets its receipt quickly.
+package pos
+
+import (
+	"context"
+	"strings"
+
+	"erp/models"
+)
+
+// MaxLines is the largest number of distinct products in one sale.
+const MaxLines = 200
+
+// Service validates and records POS sales.
+type Service struct {
+	Store models.POSStore
+}
+
+// NewService creates a POS service.
+func NewService(store models.POSStore) *Service {
+	return &Service{Store: store}
+}
+
+// RecordSale checks a sale and records it. Lines for the same product are merged, so each
+// product's stock is taken once.
+//
+// Parameters:
+//   - sale: The sale to record; Register, WarehouseID, CustomerID, Lines (product and
+//     quantity), PaymentMethod and, for cash, Tendered are read. The store fills in the rest.
+//
+// Returns:
+//   - error: A validation error if the sale is incomplete, or the store's error.
+func (s *Service) RecordSale(ctx context.Context, sale *models.POSSale) error {
+	sale.Register = strings.TrimSpace(sale.Register)
+	switch {
+	case sale.Register == "":
+		return models.Invalid("register is required")
+	case sale.WarehouseID <= 0:
+		return models.Invalid("warehouse_id is required")
+	case sale.PaymentMethod != models.POSPaymentCash && sale.PaymentMethod != models.POSPaymentCard:
+		return models.Invalid("payment_method must be %q or %q", models.POSPaymentCash, models.POSPaymentCard)
+	case sale.Tendered < 0:
+		return models.Invalid("tendered must not be negative")
+	case len(sale.Lines) == 0:
+		return models.Invalid("a sale needs at least one line")
+	}
+
+	lines, err := mergeLines(sale.Lines)
+	if err != nil {
+		return err
+	}
+	sale.Lines = lines
+	return s.Store.RecordSale(ctx, sale)
+}
+
+// mergeLines returns the lines with one line per product, in the order products first
+// appear.
+func mergeLines(lines []models.POSSaleLine) ([]models.POSSaleLine, error) {
+	merged := make([]models.POSSaleLine, 0, len(lines))
+	index := make(map[int]int, len(lines))
+	for i, line := range lines {
+		if line.ProductID <= 0 {
+			return nil, models.Invalid("line %d has no product", i+1)
+		}
+		if line.Quantity <= 0 {
+			return nil, models.Invalid("line %d must have a positive quantity", i+1)
+		}
+		if j, ok := index[line.ProductID]; ok {
+			merged[j].Quantity += line.Quantity
+			continue
+		}
+		index[line.ProductID] = len(merged)
+		merged = append(merged, models.POSSaleLine{ProductID: line.ProductID, Quantity: line.Quantity})
+	}
+	if len(merged) > MaxLines {
+		return nil, models.Invalid("a sale can have at most %d products", MaxLines)
+	}
+	return merged, nil
+}
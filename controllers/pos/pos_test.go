@@ -0,0 +1,74 @@
+package pos
+
+import (
+	"context"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPOSStore keeps the last sale it was asked to record.
+type recordingPOSStore struct {
+	sale *models.POSSale
+}
+
+func (s *recordingPOSStore) RecordSale(ctx context.Context, sale *models.POSSale) error {
+	s.sale = sale
+	return nil
+}
+
+func TestRecordSaleMergesLines(t *testing.T) {
+	store := &recordingPOSStore{}
+	sale := models.POSSale{
+		Register:      " front-1 ",
+		WarehouseID:   2,
+		PaymentMethod: models.POSPaymentCash,
+		Lines: []models.POSSaleLine{
+			{ProductID: 5, Quantity: 1},
+			{ProductID: 8, Quantity: 2},
+			{ProductID: 5, Quantity: 3},
+		},
+	}
+	require.NoError(t, NewService(store).RecordSale(context.Background(), &sale))
+
+	assert.Equal(t, "front-1", store.sale.Register)
+	assert.Equal(t, []models.POSSaleLine{{ProductID: 5, Quantity: 4}, {ProductID: 8, Quantity: 2}}, store.sale.Lines)
+}
+
+func TestRecordSaleRejectsIncompleteSales(t *testing.T) {
+	valid := func() models.POSSale {
+		return models.POSSale{
+			Register:      "front-1",
+			WarehouseID:   2,
+			PaymentMethod: models.POSPaymentCard,
+			Lines:         []models.POSSaleLine{{ProductID: 5, Quantity: 1}},
+		}
+	}
+	tests := map[string]func(*models.POSSale){
+		"no register":       func(s *models.POSSale) { s.Register = " " },
+		"no warehouse":      func(s *models.POSSale) { s.WarehouseID = 0 },
+		"unknown method":    func(s *models.POSSale) { s.PaymentMethod = "cheque" },
+		"negative tendered": func(s *models.POSSale) { s.Tendered = -1 },
+		"no lines":          func(s *models.POSSale) { s.Lines = nil },
+		"no product":        func(s *models.POSSale) { s.Lines[0].ProductID = 0 },
+		"zero quantity":     func(s *models.POSSale) { s.Lines[0].Quantity = 0 },
+		"too many products": func(s *models.POSSale) {
+			s.Lines = nil
+			for id := 1; id <= MaxLines+1; id++ {
+				s.Lines = append(s.Lines, models.POSSaleLine{ProductID: id, Quantity: 1})
+			}
+		},
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := &recordingPOSStore{}
+			sale := valid()
+			change(&sale)
+			assert.ErrorIs(t, NewService(store).RecordSale(context.Background(), &sale), models.ErrValidation)
+			assert.Nil(t, store.sale, "Invalid sales do not reach the store")
+		})
+	}
+}
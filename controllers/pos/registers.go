@@ -0,0 +1,111 @@
+package pos
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// RegisterService opens and closes cash register sessions and reports on them.
+type RegisterService struct {
+	Store models.RegisterStore
+}
+
+// NewRegisterService creates a register service.
+func NewRegisterService(store models.RegisterStore) *RegisterService {
+	return &RegisterService{Store: store}
+}
+
+// Open starts a session at a register with a float of cash in the drawer.
+//
+// Parameters:
+//   - register: The register's name, e.g. "front-1".
+//   - openingFloat: The cash in the drawer at opening.
+//   - actor: Email of the user opening the register.
+//
+// Returns:
+//   - *models.RegisterSession: The open session.
+//   - error: A validation error, models.ErrRegisterOpen if the register is already open,
+//     or the store's error.
+func (s *RegisterService) Open(ctx context.Context, register string, openingFloat float64, actor string) (*models.RegisterSession, error) {
+	register = strings.TrimSpace(register)
+	if register == "" {
+		return nil, models.Invalid("register is required")
+	}
+	if openingFloat < 0 {
+		return nil, models.Invalid("opening_float must not be negative")
+	}
+	session := &models.RegisterSession{
+		Register:     register,
+		OpeningFloat: roundCents(openingFloat),
+		OpenedBy:     actor,
+		OpenedAt:     time.Now(),
+		Movements:    []models.CashMovement{},
+	}
+	if err := s.Store.OpenSession(ctx, session); err != nil {
+		return nil, err
+	}
+	session.ExpectedCash = session.OpeningFloat
+	return session, nil
+}
+
+// Current returns the open session of a register with the cash expected in the drawer.
+func (s *RegisterService) Current(ctx context.Context, register string) (*models.RegisterSession, error) {
+	return s.Store.GetOpenSession(ctx, strings.TrimSpace(register))
+}
+
+// Move records cash put into or taken out of a register outside of sales.
+//
+// Returns:
+//   - error: A validation error if the kind, amount or reason is missing, or the store's error.
+func (s *RegisterService) Move(ctx context.Context, register string, movement *models.CashMovement) error {
+	movement.Reason = strings.TrimSpace(movement.Reason)
+	movement.Amount = roundCents(movement.Amount)
+	switch {
+	case movement.Kind != models.CashPayIn && movement.Kind != models.CashPayOut:
+		return models.Invalid("kind must be %q or %q", models.CashPayIn, models.CashPayOut)
+	case movement.Amount <= 0:
+		return models.Invalid("amount must be positive")
+	case movement.Reason == "":
+		return models.Invalid("reason is required")
+	}
+	movement.CreatedAt = time.Now()
+	return s.Store.AddCashMovement(ctx, strings.TrimSpace(register), movement)
+}
+
+// Close closes a register's open session with the cash counted in the drawer. The
+// difference from the expected cash is posted to the over or short account.
+func (s *RegisterService) Close(ctx context.Context, register string, countedCash float64, actor string) (*models.RegisterSession, error) {
+	if countedCash < 0 {
+		return nil, models.Invalid("counted_cash must not be negative")
+	}
+	return s.Store.CloseSession(ctx, strings.TrimSpace(register), roundCents(countedCash), actor, time.Now())
+}
+
+// ZReport returns the end-of-day report of a register.
+//
+// Parameters:
+//   - register: The register's name.
+//   - date: The day as YYYY-MM-DD; empty for today.
+//
+// Returns:
+//   - *models.ZReport: The report.
+//   - error: A validation error if the date is malformed, or the store's error.
+func (s *RegisterService) ZReport(ctx context.Context, register, date string) (*models.ZReport, error) {
+	day := time.Now()
+	if date != "" {
+		var err error
+		if day, err = time.ParseInLocation("2006-01-02", date, time.Local); err != nil {
+			return nil, models.Invalid("date must be formatted as YYYY-MM-DD")
+		}
+	}
+	return s.Store.GetZReport(ctx, strings.TrimSpace(register), day)
+}
+
+// roundCents rounds an amount to whole cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
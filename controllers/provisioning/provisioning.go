@@ -0,0 +1,310 @@
+// Package provisioning keeps the ERP users in sync with the HR system. Records from a CSV
+// import or the SCIM API are matched to existing users, mapped to ERP roles and departments,
+// and applied together, or only reported in a dry run.
+package provisioning
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"erp/config"
+	"erp/models"
+)
+
+// RoleRule gives users whose title or department matches a pattern an ERP role. Patterns
+// ignore case and may end with "*" to match a prefix.
+type RoleRule struct {
+	Field   string // "title" or "department"
+	Pattern string
+	Role    string
+}
+
+// ParseRoleRule reads a rule written as "field=pattern:Role", e.g. "title=Sales*:Sales Group".
+func ParseRoleRule(text string) (RoleRule, error) {
+	match, role, ok := strings.Cut(text, ":")
+	field, pattern, ok2 := strings.Cut(match, "=")
+	rule := RoleRule{Field: strings.TrimSpace(field), Pattern: strings.TrimSpace(pattern), Role: strings.TrimSpace(role)}
+	if !ok || !ok2 || rule.Pattern == "" || rule.Role == "" {
+		return RoleRule{}, fmt.Errorf("role rule %q is not of the form field=pattern:Role", text)
+	}
+	if rule.Field != "title" && rule.Field != "department" {
+		return RoleRule{}, fmt.Errorf("role rule %q: field must be title or department", text)
+	}
+	return rule, nil
+}
+
+// Matches reports whether the rule applies to a record.
+func (r RoleRule) Matches(record *models.ProvisionRecord) bool {
+	value := record.Title
+	if r.Field == "department" {
+		value = record.Department
+	}
+	value, pattern := strings.ToLower(strings.TrimSpace(value)), strings.ToLower(r.Pattern)
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+	return value == pattern
+}
+
+// Service applies provisioning records to the user directory.
+type Service struct {
+	Store       models.ProvisioningStore
+	DefaultRole string
+	Rules       []RoleRule
+	Departments map[string]string // ERP department of each HR department, by lower-case name
+}
+
+// NewService creates a provisioning service with the configured mapping rules.
+//
+// Returns:
+//   - *Service: The service.
+//   - error: An error if a role rule or department mapping is malformed.
+func NewService(store models.ProvisioningStore, cfg config.ProvisioningConfig) (*Service, error) {
+	s := &Service{Store: store, DefaultRole: cfg.DefaultRole, Departments: map[string]string{}}
+	for _, text := range cfg.RoleRules {
+		rule, err := ParseRoleRule(text)
+		if err != nil {
+			return nil, err
+		}
+		s.Rules = append(s.Rules, rule)
+	}
+	for _, text := range cfg.Departments {
+		from, to, ok := strings.Cut(text, ":")
+		if !ok || strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			return nil, fmt.Errorf("department mapping %q is not of the form HR department:ERP department", text)
+		}
+		s.Departments[strings.ToLower(strings.TrimSpace(from))] = strings.TrimSpace(to)
+	}
+	return s, nil
+}
+
+// Role returns the ERP role of a record: its own role if it has one, else that of the
+// first matching rule, else the default role.
+func (s *Service) Role(record *models.ProvisionRecord) string {
+	if record.Role != "" {
+		return record.Role
+	}
+	for _, rule := range s.Rules {
+		if rule.Matches(record) {
+			return rule.Role
+		}
+	}
+	return s.DefaultRole
+}
+
+// Department returns the ERP department of a record.
+func (s *Service) Department(record *models.ProvisionRecord) string {
+	if department, ok := s.Departments[strings.ToLower(record.Department)]; ok {
+		return department
+	}
+	return record.Department
+}
+
+// Users returns every user in the directory.
+func (s *Service) Users(ctx context.Context) ([]models.DirectoryUser, error) {
+	return s.Store.ListDirectoryUsers(ctx)
+}
+
+// User returns one user of the directory.
+func (s *Service) User(ctx context.Context, id int) (*models.DirectoryUser, error) {
+	return s.Store.GetDirectoryUser(ctx, id)
+}
+
+// Apply matches each record to a user by ID, external ID or email and creates, updates or
+// deactivates users accordingly. Records that clash with another user or an earlier record,
+// or that are incomplete, are skipped and reported; the others are saved in one
+// transaction. A dry run reports the same outcome without saving anything.
+//
+// Parameters:
+//   - records: The records, in import order.
+//   - dryRun: Whether to only report what would change.
+//
+// Returns:
+//   - *models.ProvisionReport: The outcome of each record.
+//   - error: An error if the directory cannot be read or the changes cannot be saved.
+func (s *Service) Apply(ctx context.Context, records []models.ProvisionRecord, dryRun bool) (*models.ProvisionReport, error) {
+	users, err := s.Store.ListDirectoryUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.Store.RoleNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d := newDirectory(users, roles)
+
+	report := &models.ProvisionReport{DryRun: dryRun, Counts: map[models.ProvisionAction]int{}, Results: []models.ProvisionResult{}}
+	var changed []*models.DirectoryUser
+	for i := range records {
+		result := s.plan(d, &records[i])
+		if result.User != nil && (result.Action == models.ProvisionCreated || result.Action == models.ProvisionUpdated || result.Action == models.ProvisionDeactivated) {
+			changed = append(changed, result.User)
+		}
+		report.Counts[result.Action]++
+		report.Results = append(report.Results, result)
+	}
+
+	if dryRun || len(changed) == 0 {
+		return report, nil
+	}
+	if err := s.Store.SaveDirectoryUsers(ctx, changed); err != nil {
+		return nil, err
+	}
+	for i := range report.Results {
+		if user := report.Results[i].User; user != nil {
+			report.Results[i].UserID = user.ID
+		}
+	}
+	return report, nil
+}
+
+// plan works out the outcome of one record and records its effect in the directory, so
+// later records see it.
+func (s *Service) plan(d *directory, record *models.ProvisionRecord) models.ProvisionResult {
+	record.Email = strings.ToLower(strings.TrimSpace(record.Email))
+	record.ExternalID = strings.TrimSpace(record.ExternalID)
+	record.Name = strings.TrimSpace(record.Name)
+	result := models.ProvisionResult{ExternalID: record.ExternalID, Email: record.Email}
+	skip := func(action models.ProvisionAction, format string, args ...interface{}) models.ProvisionResult {
+		result.Action, result.Detail = action, fmt.Sprintf(format, args...)
+		return result
+	}
+
+	if _, err := mail.ParseAddress(record.Email); err != nil {
+		return skip(models.ProvisionInvalid, "a valid email is required")
+	}
+	if d.seen[record.Email] || (record.ExternalID != "" && d.seen["external:"+record.ExternalID]) {
+		return skip(models.ProvisionConflict, "the user appears more than once in the import")
+	}
+	d.seen[record.Email] = true
+	if record.ExternalID != "" {
+		d.seen["external:"+record.ExternalID] = true
+	}
+
+	role := s.Role(record)
+	if !d.roles[role] {
+		return skip(models.ProvisionInvalid, "role %q does not exist", role)
+	}
+	department := s.Department(record)
+
+	user, err := d.match(record)
+	if err != nil {
+		return skip(models.ProvisionConflict, "%s", err)
+	}
+	if owner := d.byEmail[record.Email]; owner != nil && owner != user {
+		return skip(models.ProvisionConflict, "the email belongs to user %d", owner.ID)
+	}
+
+	if user == nil {
+		if !record.Active {
+			return skip(models.ProvisionUnchanged, "inactive users are not created")
+		}
+		if record.Name == "" {
+			return skip(models.ProvisionInvalid, "a name is required")
+		}
+		user = &models.DirectoryUser{ExternalID: record.ExternalID, Name: record.Name, Email: record.Email, Role: role, Department: department, Active: true}
+		d.add(user)
+		result.Action, result.User = models.ProvisionCreated, user
+		return result
+	}
+
+	updated := *user
+	set := func(field string, current *string, value string) {
+		if value != "" && *current != value {
+			result.Changes = append(result.Changes, fmt.Sprintf("%s: %s -> %s", field, *current, value))
+			*current = value
+		}
+	}
+	set("external_id", &updated.ExternalID, record.ExternalID)
+	set("name", &updated.Name, record.Name)
+	set("email", &updated.Email, record.Email)
+	set("role", &updated.Role, role)
+	set("department", &updated.Department, department)
+	if updated.Active != record.Active {
+		result.Changes = append(result.Changes, fmt.Sprintf("active: %t -> %t", updated.Active, record.Active))
+		updated.Active = record.Active
+	}
+
+	result.UserID = user.ID
+	switch {
+	case len(result.Changes) == 0:
+		result.Action = models.ProvisionUnchanged
+	case user.Active && !updated.Active:
+		result.Action = models.ProvisionDeactivated
+	default:
+		result.Action = models.ProvisionUpdated
+	}
+	d.replace(user, &updated)
+	result.User = &updated
+	return result
+}
+
+// directory indexes the users while a run is planned.
+type directory struct {
+	byID       map[int]*models.DirectoryUser
+	byExternal map[string]*models.DirectoryUser
+	byEmail    map[string]*models.DirectoryUser
+	roles      map[string]bool
+	seen       map[string]bool // Emails and "external:" IDs of the records already planned
+}
+
+func newDirectory(users []models.DirectoryUser, roles []string) *directory {
+	d := &directory{
+		byID:       map[int]*models.DirectoryUser{},
+		byExternal: map[string]*models.DirectoryUser{},
+		byEmail:    map[string]*models.DirectoryUser{},
+		roles:      map[string]bool{},
+		seen:       map[string]bool{},
+	}
+	for i := range users {
+		d.add(&users[i])
+	}
+	for _, role := range roles {
+		d.roles[role] = true
+	}
+	return d
+}
+
+func (d *directory) add(user *models.DirectoryUser) {
+	if user.ID != 0 {
+		d.byID[user.ID] = user
+	}
+	if user.ExternalID != "" {
+		d.byExternal[user.ExternalID] = user
+	}
+	d.byEmail[strings.ToLower(user.Email)] = user
+}
+
+func (d *directory) replace(old, user *models.DirectoryUser) {
+	delete(d.byExternal, old.ExternalID)
+	delete(d.byEmail, strings.ToLower(old.Email))
+	d.add(user)
+}
+
+// match returns the user a record describes, or nil for a new user. A user is found by ID,
+// then by external ID, then by email; a user found by email must not already be linked to
+// another external ID.
+func (d *directory) match(record *models.ProvisionRecord) (*models.DirectoryUser, error) {
+	if record.ID != 0 {
+		user := d.byID[record.ID]
+		if user == nil {
+			return nil, fmt.Errorf("user %d does not exist", record.ID)
+		}
+		if other := d.byExternal[record.ExternalID]; record.ExternalID != "" && other != nil && other != user {
+			return nil, fmt.Errorf("the external ID belongs to user %d", other.ID)
+		}
+		return user, nil
+	}
+	if record.ExternalID != "" {
+		if user := d.byExternal[record.ExternalID]; user != nil {
+			return user, nil
+		}
+	}
+	user := d.byEmail[record.Email]
+	if user != nil && user.ExternalID != "" && record.ExternalID != "" {
+		return nil, fmt.Errorf("the email belongs to user %d with external ID %s", user.ID, user.ExternalID)
+	}
+	return user, nil
+}
@@ -0,0 +1,132 @@
+package provisioning
+
+import (
+	"context"
+	"testing"
+
+	"erp/config"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps the directory in memory and records what is saved.
+type fakeStore struct {
+	users []models.DirectoryUser
+	saved []*models.DirectoryUser
+}
+
+func (f *fakeStore) ListDirectoryUsers(ctx context.Context) ([]models.DirectoryUser, error) {
+	return append([]models.DirectoryUser(nil), f.users...), nil
+}
+
+func (f *fakeStore) GetDirectoryUser(ctx context.Context, id int) (*models.DirectoryUser, error) {
+	for _, u := range f.users {
+		if u.ID == id {
+			return &u, nil
+		}
+	}
+	return nil, models.NotFound("user %d not found", id)
+}
+
+func (f *fakeStore) RoleNames(ctx context.Context) ([]string, error) {
+	return []string{"Accountant", "Admin", "Employee", "Sales Group"}, nil
+}
+
+func (f *fakeStore) SaveDirectoryUsers(ctx context.Context, users []*models.DirectoryUser) error {
+	for i, u := range users {
+		if u.ID == 0 {
+			u.ID = 100 + i
+		}
+	}
+	f.saved = append(f.saved, users...)
+	return nil
+}
+
+func newTestService(t *testing.T) (*Service, *fakeStore) {
+	store := &fakeStore{users: []models.DirectoryUser{
+		{ID: 1, ExternalID: "E1", Name: "Ana", Email: "ana@example.com", Role: "Employee", Department: "Finance", Active: true},
+		{ID: 2, Name: "Ben", Email: "ben@example.com", Role: "Admin", Department: "IT", Active: true},
+		{ID: 3, ExternalID: "E3", Name: "Cy", Email: "cy@example.com", Role: "Sales Group", Department: "Sales", Active: true},
+	}}
+	service, err := NewService(store, config.ProvisioningConfig{
+		DefaultRole: "Employee",
+		RoleRules:   []string{"title=Sales*:Sales Group", "department=FIN:Accountant"},
+		Departments: []string{"FIN:Finance"},
+	})
+	require.NoError(t, err)
+	return service, store
+}
+
+func TestApply(t *testing.T) {
+	service, store := newTestService(t)
+
+	report, err := service.Apply(context.Background(), []models.ProvisionRecord{
+		{ExternalID: "E1", Name: "Ana", Email: "ana@example.com", Department: "FIN", Active: true},
+		{ExternalID: "E2", Name: "Ben", Email: "BEN@example.com", Department: "IT", Role: "Admin", Active: true},
+		{ExternalID: "E3", Email: "cy@example.com", Title: "Sales Rep", Department: "Sales", Active: false},
+		{ExternalID: "E4", Name: "Dee", Email: "dee@example.com", Title: "Sales Manager", Active: true},
+		{ExternalID: "E5", Name: "Eve", Email: "ana@example.com", Active: true},
+		{ExternalID: "E6", Name: "Fay", Email: "fay@example.com", Role: "Owner", Active: true},
+		{ExternalID: "E7", Name: "Gus", Email: "gus@example.com", Active: false},
+		{ExternalID: "E4", Name: "Dee", Email: "dee2@example.com", Active: true},
+	}, false)
+	require.NoError(t, err)
+
+	actions := make([]models.ProvisionAction, len(report.Results))
+	for i, result := range report.Results {
+		actions[i] = result.Action
+	}
+	assert.Equal(t, []models.ProvisionAction{
+		models.ProvisionUpdated, models.ProvisionUpdated, models.ProvisionDeactivated, models.ProvisionCreated,
+		models.ProvisionConflict, models.ProvisionInvalid, models.ProvisionUnchanged, models.ProvisionConflict,
+	}, actions)
+	assert.Equal(t, []string{"role: Employee -> Accountant"}, report.Results[0].Changes, "FIN maps to Finance and to the Accountant role")
+	assert.Equal(t, []string{"external_id:  -> E2"}, report.Results[1].Changes, "An existing user is linked by email")
+	assert.Equal(t, "the user appears more than once in the import", report.Results[4].Detail)
+	assert.Equal(t, `role "Owner" does not exist`, report.Results[5].Detail)
+	assert.Equal(t, 2, report.Counts[models.ProvisionConflict])
+
+	require.Len(t, store.saved, 4)
+	assert.Equal(t, models.DirectoryUser{ID: 103, ExternalID: "E4", Name: "Dee", Email: "dee@example.com", Role: "Sales Group", Active: true}, *store.saved[3])
+	assert.Equal(t, 103, report.Results[3].UserID)
+}
+
+func TestApplyConflicts(t *testing.T) {
+	service, store := newTestService(t)
+
+	report, err := service.Apply(context.Background(), []models.ProvisionRecord{
+		{ExternalID: "E9", Name: "Ana", Email: "ana@example.com", Active: true},
+		{ExternalID: "E3", Name: "Cy", Email: "ben@example.com", Role: "Sales Group", Active: true},
+		{ID: 2, ExternalID: "E1", Email: "benjamin@example.com", Active: true},
+	}, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "the email belongs to user 1 with external ID E1", report.Results[0].Detail)
+	assert.Equal(t, "the email belongs to user 2", report.Results[1].Detail)
+	assert.Equal(t, "the external ID belongs to user 1", report.Results[2].Detail)
+	assert.Empty(t, store.saved)
+}
+
+func TestApplyDryRun(t *testing.T) {
+	service, store := newTestService(t)
+
+	report, err := service.Apply(context.Background(), []models.ProvisionRecord{
+		{ID: 2, Email: "ben@example.com", Role: "Admin", Department: "IT", Active: false},
+	}, true)
+	require.NoError(t, err)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, models.ProvisionDeactivated, report.Results[0].Action)
+	assert.Equal(t, []string{"active: true -> false"}, report.Results[0].Changes)
+	assert.Empty(t, store.saved, "A dry run saves nothing")
+}
+
+func TestNewServiceRejectsMalformedRules(t *testing.T) {
+	_, err := NewService(&fakeStore{}, config.ProvisioningConfig{RoleRules: []string{"grade=7:Admin"}})
+	assert.EqualError(t, err, `role rule "grade=7:Admin": field must be title or department`)
+
+	_, err = NewService(&fakeStore{}, config.ProvisioningConfig{RoleRules: []string{"title=Sales"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,245 @@
+// Package purchasing holds the rules of purchase orders: they are drafted for a supplier
+// and a warehouse, approved, received into stock, and closed. Receiving goods drafts the
+// payment to the supplier in accounts payable, so procurement and finance stay in step.
+package purchasing
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Overrides holds the fields changed on a cloned purchase order. Nil fields are copied from
+// the source order, except ExpectedOn, which is left empty.
+type Overrides struct {
+	SupplierID  *int
+	WarehouseID *int
+	ExpectedOn  *time.Time
+	Note        *string
+}
+
+// Service applies the purchase order rules on top of a PurchaseOrderStore.
+type Service struct {
+	Store models.PurchaseOrderStore
+}
+
+// NewService creates a purchasing service backed by store.
+func NewService(store models.PurchaseOrderStore) *Service {
+	return &Service{Store: store}
+}
+
+// Create checks an order and records it as a draft. Lines for the same product are merged.
+//
+// Parameters:
+//   - order: The order; SupplierID, WarehouseID, ExpectedOn, Note and Lines (product,
+//     quantity and unit cost) are read. The store fills in the ID and time.
+//   - actor: Email of the user raising the order.
+//
+// Returns:
+//   - error: A validation error if the order is incomplete, or the store's error.
+func (s *Service) Create(ctx context.Context, order *models.PurchaseOrder, actor string) error {
+	switch {
+	case order.SupplierID <= 0 || order.WarehouseID <= 0:
+		return models.Invalid("supplier_id and warehouse_id are required")
+	case len(order.Lines) == 0:
+		return models.Invalid("a purchase order needs at least one line")
+	}
+
+	merged := make([]models.PurchaseOrderLine, 0, len(order.Lines))
+	index := make(map[int]int, len(order.Lines))
+	for i, line := range order.Lines {
+		switch {
+		case line.ProductID <= 0:
+			return models.Invalid("line %d has no product", i+1)
+		case line.Quantity <= 0:
+			return models.Invalid("line %d must have a positive quantity", i+1)
+		case line.UnitCost < 0:
+			return models.Invalid("line %d: unit_cost cannot be negative", i+1)
+		}
+		if j, ok := index[line.ProductID]; ok {
+			if merged[j].UnitCost != line.UnitCost {
+				return models.Invalid("product %d is listed twice at different costs", line.ProductID)
+			}
+			merged[j].Quantity += line.Quantity
+			continue
+		}
+		index[line.ProductID] = len(merged)
+		merged = append(merged, models.PurchaseOrderLine{ProductID: line.ProductID, Quantity: line.Quantity,
+			UnitCost: roundCents(line.UnitCost)})
+	}
+	order.Lines = merged
+	order.Note = strings.TrimSpace(order.Note)
+	order.Status = models.PurchaseOrderDraft
+	order.CreatedBy = actor
+	order.Receipts = []models.PurchaseOrderReceipt{}
+	if err := s.Store.CreatePurchaseOrder(ctx, order); err != nil {
+		return err
+	}
+	Total(order)
+	return nil
+}
+
+// Get returns an order with its lines and receipts.
+func (s *Service) Get(ctx context.Context, id int) (*models.PurchaseOrder, error) {
+	return s.Store.GetPurchaseOrder(ctx, id)
+}
+
+// List returns the orders in a status, or all of them if status is empty, optionally of
+// one supplier.
+func (s *Service) List(ctx context.Context, status string, supplierID int) ([]models.PurchaseOrder, error) {
+	switch status {
+	case "", models.PurchaseOrderDraft, models.PurchaseOrderApproved, models.PurchaseOrderPartiallyReceived,
+		models.PurchaseOrderReceived, models.PurchaseOrderClosed:
+		return s.Store.ListPurchaseOrders(ctx, status, supplierID)
+	}
+	return nil, models.Invalid("unknown purchase order status %q", status)
+}
+
+// Clone copies the order with the given ID as a new draft raised by actor, applying
+// overrides. The copy orders the same quantities at the same costs, whatever the source
+// order's status and whatever was received against it.
+//
+// Parameters:
+//   - id: The ID of the order to copy.
+//   - overrides: The fields to change on the copy.
+//   - actor: Email of the user raising the copy.
+//
+// Returns:
+//   - *models.PurchaseOrder: The new draft order.
+//   - error: models.ErrNotFound if the source order does not exist, or the error of Create.
+func (s *Service) Clone(ctx context.Context, id int, overrides Overrides, actor string) (*models.PurchaseOrder, error) {
+	source, err := s.Store.GetPurchaseOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := models.PurchaseOrder{
+		SupplierID:         source.SupplierID,
+		WarehouseID:        source.WarehouseID,
+		Note:               source.Note,
+		InspectionRequired: source.InspectionRequired,
+		Lines:              make([]models.PurchaseOrderLine, len(source.Lines)),
+	}
+	for i, line := range source.Lines {
+		clone.Lines[i] = models.PurchaseOrderLine{ProductID: line.ProductID, Quantity: line.Quantity, UnitCost: line.UnitCost}
+	}
+	if overrides.SupplierID != nil {
+		clone.SupplierID = *overrides.SupplierID
+	}
+	if overrides.WarehouseID != nil {
+		clone.WarehouseID = *overrides.WarehouseID
+	}
+	if overrides.ExpectedOn != nil {
+		clone.ExpectedOn = overrides.ExpectedOn
+	}
+	if overrides.Note != nil {
+		clone.Note = *overrides.Note
+	}
+
+	if err := s.Create(ctx, &clone, actor); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// Approve allows a draft order to be sent to the supplier and received.
+func (s *Service) Approve(ctx context.Context, id int, actor string) (*models.PurchaseOrder, error) {
+	return s.Store.ApprovePurchaseOrder(ctx, id, actor)
+}
+
+// Receive records a delivery against an approved order: the goods are added to the order's
+// warehouse and a pending payment for their cost is drafted in accounts payable. An empty
+// receipt receives everything outstanding.
+//
+// Parameters:
+//   - id: The ID of the order.
+//   - lines: The quantities received of some or all products.
+//   - actor: Email of the user receiving the goods.
+//
+// Returns:
+//   - *models.PurchaseOrder: The order with the new receipt.
+//   - error: A validation error if a product is not on the order, is listed twice, has no
+//     positive quantity or is received beyond what is outstanding, or the store's error.
+func (s *Service) Receive(ctx context.Context, id int, lines []models.PurchaseOrderReceiptLine, actor string) (*models.PurchaseOrder, error) {
+	order, err := s.Store.GetPurchaseOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	outstanding := make(map[int]int, len(order.Lines))
+	for _, line := range order.Lines {
+		outstanding[line.ProductID] = line.Outstanding()
+	}
+
+	if len(lines) == 0 {
+		for _, line := range order.Lines {
+			if line.Outstanding() > 0 {
+				lines = append(lines, models.PurchaseOrderReceiptLine{ProductID: line.ProductID, Quantity: line.Outstanding()})
+			}
+		}
+		if len(lines) == 0 {
+			return nil, models.Conflict("purchase order %d has nothing left to receive", id)
+		}
+	}
+	seen := make(map[int]bool, len(lines))
+	for i, line := range lines {
+		remaining, ok := outstanding[line.ProductID]
+		switch {
+		case !ok:
+			return nil, models.Invalid("receipt line %d: product %d is not on the purchase order", i+1, line.ProductID)
+		case seen[line.ProductID]:
+			return nil, models.Invalid("receipt line %d: product %d is listed twice", i+1, line.ProductID)
+		case line.Quantity <= 0:
+			return nil, models.Invalid("receipt line %d must have a positive quantity", i+1)
+		case line.Quantity > remaining:
+			return nil, models.Invalid("receipt line %d: %d received but only %d outstanding", i+1, line.Quantity, remaining)
+		}
+		seen[line.ProductID] = true
+	}
+	return s.Store.ReceivePurchaseOrder(ctx, id, lines, actor)
+}
+
+// Close closes an approved order, whether or not everything has been received. Nothing more
+// can be received against it.
+func (s *Service) Close(ctx context.Context, id int, actor string) (*models.PurchaseOrder, error) {
+	return s.Store.ClosePurchaseOrder(ctx, id, actor)
+}
+
+// Total sets the order's total cost.
+func Total(order *models.PurchaseOrder) {
+	order.Total = 0
+	for _, line := range order.Lines {
+		order.Total = roundCents(order.Total + float64(line.Quantity)*line.UnitCost)
+	}
+}
+
+// ReceiptAmount returns the cost of the goods received, at the order's unit costs.
+func ReceiptAmount(order *models.PurchaseOrder, lines []models.PurchaseOrderReceiptLine) float64 {
+	costs := make(map[int]float64, len(order.Lines))
+	for _, line := range order.Lines {
+		costs[line.ProductID] = line.UnitCost
+	}
+	amount := 0.0
+	for _, line := range lines {
+		amount = roundCents(amount + float64(line.Quantity)*costs[line.ProductID])
+	}
+	return amount
+}
+
+// Status returns the status of an approved order after a receipt: received once nothing is
+// outstanding, partially received otherwise.
+func Status(order *models.PurchaseOrder) string {
+	for _, line := range order.Lines {
+		if line.Outstanding() > 0 {
+			return models.PurchaseOrderPartiallyReceived
+		}
+	}
+	return models.PurchaseOrderReceived
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,126 @@
+package purchasing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps one purchase order and records the last receipt.
+type memoryStore struct {
+	order    *models.PurchaseOrder
+	received []models.PurchaseOrderReceiptLine
+}
+
+func (m *memoryStore) CreatePurchaseOrder(ctx context.Context, order *models.PurchaseOrder) error {
+	order.ID = 1
+	m.order = order
+	return nil
+}
+
+func (m *memoryStore) GetPurchaseOrder(ctx context.Context, id int) (*models.PurchaseOrder, error) {
+	if m.order == nil || m.order.ID != id {
+		return nil, models.NotFound("purchase order %d not found", id)
+	}
+	return m.order, nil
+}
+
+func (m *memoryStore) ListPurchaseOrders(ctx context.Context, status string, supplierID int) ([]models.PurchaseOrder, error) {
+	return nil, nil
+}
+
+func (m *memoryStore) ApprovePurchaseOrder(ctx context.Context, id int, actor string) (*models.PurchaseOrder, error) {
+	return m.order, nil
+}
+
+func (m *memoryStore) ReceivePurchaseOrder(ctx context.Context, id int, lines []models.PurchaseOrderReceiptLine, actor string) (*models.PurchaseOrder, error) {
+	m.received = lines
+	return m.order, nil
+}
+
+func (m *memoryStore) ClosePurchaseOrder(ctx context.Context, id int, actor string) (*models.PurchaseOrder, error) {
+	return m.order, nil
+}
+
+func newOrder(t *testing.T) (*Service, *memoryStore) {
+	store := &memoryStore{}
+	s := NewService(store)
+	order := models.PurchaseOrder{SupplierID: 3, WarehouseID: 2, Lines: []models.PurchaseOrderLine{
+		{ProductID: 7, Quantity: 4, UnitCost: 4.5},
+		{ProductID: 8, Quantity: 3, UnitCost: 20},
+		{ProductID: 7, Quantity: 6, UnitCost: 4.5},
+	}}
+	require.NoError(t, s.Create(context.Background(), &order, "buyer@example.com"))
+	return s, store
+}
+
+func TestCreateMergesLines(t *testing.T) {
+	_, store := newOrder(t)
+	assert.Equal(t, models.PurchaseOrderDraft, store.order.Status)
+	assert.Equal(t, []models.PurchaseOrderLine{{ProductID: 7, Quantity: 10, UnitCost: 4.5}, {ProductID: 8, Quantity: 3, UnitCost: 20}},
+		store.order.Lines)
+	assert.Equal(t, 105.0, store.order.Total)
+
+	s := NewService(&memoryStore{})
+	err := s.Create(context.Background(), &models.PurchaseOrder{SupplierID: 3, Lines: []models.PurchaseOrderLine{{ProductID: 7, Quantity: 1}}}, "buyer@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation), "a warehouse is required")
+}
+
+func TestReceiveEverythingOutstanding(t *testing.T) {
+	s, store := newOrder(t)
+	store.order.Lines[0].Received = 4
+	store.order.Lines[1].Received = 3
+
+	_, err := s.Receive(context.Background(), 1, nil, "clerk@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []models.PurchaseOrderReceiptLine{{ProductID: 7, Quantity: 6}}, store.received)
+	assert.Equal(t, 27.0, ReceiptAmount(store.order, store.received))
+}
+
+func TestReceiveChecksTheLines(t *testing.T) {
+	ctx := context.Background()
+	s, store := newOrder(t)
+	for _, lines := range [][]models.PurchaseOrderReceiptLine{
+		{{ProductID: 9, Quantity: 1}},
+		{{ProductID: 8, Quantity: 0}},
+		{{ProductID: 8, Quantity: 4}},
+		{{ProductID: 8, Quantity: 1}, {ProductID: 8, Quantity: 1}},
+	} {
+		_, err := s.Receive(ctx, 1, lines, "clerk@example.com")
+		assert.True(t, errors.Is(err, models.ErrValidation), "%v", lines)
+	}
+	assert.Nil(t, store.received)
+
+	for i := range store.order.Lines {
+		store.order.Lines[i].Received = store.order.Lines[i].Quantity
+	}
+	_, err := s.Receive(ctx, 1, nil, "clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.Equal(t, models.PurchaseOrderReceived, Status(store.order))
+}
+
+func TestCloneStartsANewDraft(t *testing.T) {
+	s, store := newOrder(t)
+	store.order.Status = models.PurchaseOrderPartiallyReceived
+	store.order.Lines[0].Received = 4
+	warehouseID, note := 5, "Reorder"
+
+	clone, err := s.Clone(context.Background(), 1, Overrides{WarehouseID: &warehouseID, Note: &note}, "clerk@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.PurchaseOrderDraft, clone.Status)
+	assert.Equal(t, 3, clone.SupplierID)
+	assert.Equal(t, 5, clone.WarehouseID)
+	assert.Equal(t, "Reorder", clone.Note)
+	assert.Equal(t, "clerk@example.com", clone.CreatedBy)
+	assert.Equal(t, []models.PurchaseOrderLine{{ProductID: 7, Quantity: 10, UnitCost: 4.5}, {ProductID: 8, Quantity: 3, UnitCost: 20}},
+		clone.Lines)
+	assert.Equal(t, 105.0, clone.Total)
+
+	_, err = s.Clone(context.Background(), 2, Overrides{}, "clerk@example.com")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
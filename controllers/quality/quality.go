@@ -0,0 +1,116 @@
+// Package quality holds the rules of quality control holds. Goods received against a
+// purchase order that requires inspection are quarantined instead of being added to stock.
+// An inspection of a sample releases them into the warehouse or rejects them, and rejected
+// goods are put on a return document for the supplier (see package supplierreturns).
+package quality
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// maxPhotos caps the photos attached to an inspection.
+const maxPhotos = 20
+
+// Service applies the quality control rules on top of a QualityStore.
+type Service struct {
+	Store models.QualityStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a quality control service backed by store.
+func NewService(store models.QualityStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Holds returns the holds in a status, or all of them if status is empty, optionally of one
+// purchase order.
+func (s *Service) Holds(ctx context.Context, status string, purchaseOrderID int) ([]models.QualityHold, error) {
+	switch status {
+	case "", models.HoldQuarantined, models.HoldReleased, models.HoldRejected, models.HoldPartiallyRejected:
+		return s.Store.ListQualityHolds(ctx, status, purchaseOrderID)
+	}
+	return nil, models.Invalid("unknown quality hold status %q", status)
+}
+
+// Hold returns a hold with its inspection, if any.
+func (s *Service) Hold(ctx context.Context, id int) (*models.QualityHold, error) {
+	return s.Store.GetQualityHold(ctx, id)
+}
+
+// Inspect records the inspection of a quarantined hold. A pass releases the whole quantity
+// into the warehouse's stock. A fail rejects the rejected quantity, all of it unless told
+// otherwise, releases the rest, and raises a supplier return for the rejected goods.
+//
+// Parameters:
+//   - id: The ID of the hold.
+//   - inspection: The sample size, result, rejected quantity, notes and photos.
+//   - actor: Email of the inspector.
+//
+// Returns:
+//   - *models.QualityHold: The hold with its inspection.
+//   - error: A validation error if the sample size or rejected quantity does not fit the
+//     hold, the result is unknown or a failure has no notes, models.ErrNotFound, a conflict
+//     if the hold was inspected already, or the store's error.
+func (s *Service) Inspect(ctx context.Context, id int, inspection *models.Inspection, actor string) (*models.QualityHold, error) {
+	hold, err := s.Store.GetQualityHold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != models.HoldQuarantined {
+		return nil, models.Conflict("quality hold %d is %s", id, hold.Status)
+	}
+
+	inspection.Notes = strings.TrimSpace(inspection.Notes)
+	switch inspection.Result {
+	case models.InspectionPass:
+		if inspection.RejectedQuantity != 0 {
+			return nil, models.Invalid("a passed inspection cannot reject goods")
+		}
+	case models.InspectionFail:
+		if inspection.RejectedQuantity == 0 {
+			inspection.RejectedQuantity = hold.Quantity
+		}
+		if inspection.Notes == "" {
+			return nil, models.Invalid("notes are required when an inspection fails")
+		}
+	default:
+		return nil, models.Invalid("result must be %s or %s", models.InspectionPass, models.InspectionFail)
+	}
+	switch {
+	case inspection.SampleSize <= 0:
+		return nil, models.Invalid("sample_size must be positive")
+	case inspection.SampleSize > hold.Quantity:
+		return nil, models.Invalid("sample_size cannot exceed the %d units held", hold.Quantity)
+	case inspection.RejectedQuantity < 0 || inspection.RejectedQuantity > hold.Quantity:
+		return nil, models.Invalid("rejected_quantity must be between 1 and the %d units held", hold.Quantity)
+	}
+
+	photos := make([]string, 0, len(inspection.Photos))
+	for _, photo := range inspection.Photos {
+		if photo = strings.TrimSpace(photo); photo != "" {
+			photos = append(photos, photo)
+		}
+	}
+	if len(photos) > maxPhotos {
+		return nil, models.Invalid("an inspection has at most %d photos", maxPhotos)
+	}
+	inspection.Photos = photos
+	inspection.InspectedBy, inspection.InspectedAt = actor, s.Now()
+	inspection.SupplierReturnID = 0
+	return s.Store.InspectQualityHold(ctx, id, inspection)
+}
+
+// Status returns the status of a hold after its inspection.
+func Status(quantity, rejected int) string {
+	switch rejected {
+	case 0:
+		return models.HoldReleased
+	case quantity:
+		return models.HoldRejected
+	}
+	return models.HoldPartiallyRejected
+}
@@ -0,0 +1,101 @@
+package quality
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore holds hold 1, 10 units quarantined, and keeps the inspection it is given.
+type mockStore struct {
+	status     string
+	inspection *models.Inspection
+}
+
+func (m *mockStore) ListQualityHolds(ctx context.Context, status string, purchaseOrderID int) ([]models.QualityHold, error) {
+	return []models.QualityHold{}, nil
+}
+
+func (m *mockStore) GetQualityHold(ctx context.Context, id int) (*models.QualityHold, error) {
+	if id != 1 {
+		return nil, models.NotFound("quality hold %d not found", id)
+	}
+	return &models.QualityHold{ID: 1, Quantity: 10, Status: m.status}, nil
+}
+
+func (m *mockStore) InspectQualityHold(ctx context.Context, id int, inspection *models.Inspection) (*models.QualityHold, error) {
+	m.inspection = inspection
+	hold := &models.QualityHold{ID: id, Quantity: 10, Rejected: inspection.RejectedQuantity, Inspection: inspection}
+	hold.Released = hold.Quantity - hold.Rejected
+	hold.Status = Status(hold.Quantity, hold.Rejected)
+	return hold, nil
+}
+
+func newService() (*Service, *mockStore) {
+	store := &mockStore{status: models.HoldQuarantined}
+	service := NewService(store)
+	service.Now = func() time.Time { return time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC) }
+	return service, store
+}
+
+func TestInspectFailRejectsEverythingByDefault(t *testing.T) {
+	ctx := context.Background()
+	service, store := newService()
+
+	hold, err := service.Inspect(ctx, 1, &models.Inspection{SampleSize: 5, Result: models.InspectionFail,
+		Notes: " Cracked casing ", Photos: []string{"https://example.com/1.jpg", " "}}, "qc@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.HoldRejected, hold.Status)
+	assert.Equal(t, 10, store.inspection.RejectedQuantity)
+	assert.Equal(t, "Cracked casing", store.inspection.Notes)
+	assert.Equal(t, []string{"https://example.com/1.jpg"}, store.inspection.Photos)
+	assert.Equal(t, "qc@example.com", store.inspection.InspectedBy)
+	assert.Equal(t, time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC), store.inspection.InspectedAt)
+
+	hold, err = service.Inspect(ctx, 1, &models.Inspection{SampleSize: 5, Result: models.InspectionFail, RejectedQuantity: 4,
+		Notes: "Four dented"}, "qc@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.HoldPartiallyRejected, hold.Status)
+	assert.Equal(t, 6, hold.Released)
+
+	hold, err = service.Inspect(ctx, 1, &models.Inspection{SampleSize: 2, Result: models.InspectionPass}, "qc@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.HoldReleased, hold.Status)
+}
+
+func TestInspectValidation(t *testing.T) {
+	ctx := context.Background()
+	service, store := newService()
+
+	for name, inspection := range map[string]models.Inspection{
+		"unknown result":        {SampleSize: 2, Result: "maybe"},
+		"no sample":             {Result: models.InspectionPass},
+		"sample beyond hold":    {SampleSize: 11, Result: models.InspectionPass},
+		"pass rejecting goods":  {SampleSize: 2, Result: models.InspectionPass, RejectedQuantity: 1},
+		"fail without notes":    {SampleSize: 2, Result: models.InspectionFail},
+		"rejected beyond hold":  {SampleSize: 2, Result: models.InspectionFail, RejectedQuantity: 11, Notes: "Bad"},
+		"negative rejected qty": {SampleSize: 2, Result: models.InspectionFail, RejectedQuantity: -1, Notes: "Bad"},
+	} {
+		_, err := service.Inspect(ctx, 1, &inspection, "qc@example.com")
+		assert.ErrorIs(t, err, models.ErrValidation, name)
+	}
+	assert.Nil(t, store.inspection)
+
+	_, err := service.Inspect(ctx, 2, &models.Inspection{SampleSize: 2, Result: models.InspectionPass}, "qc@example.com")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+
+	store.status = models.HoldReleased
+	_, err = service.Inspect(ctx, 1, &models.Inspection{SampleSize: 2, Result: models.InspectionPass}, "qc@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict, "A hold is inspected once")
+}
+
+func TestHoldsRejectsUnknownStatus(t *testing.T) {
+	service, _ := newService()
+	_, err := service.Holds(context.Background(), "lost", 0)
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
@@ -0,0 +1,205 @@
+// Package rbac enforces the permissions stored on roles. Routes name the permissions that
+// may use them; a request is let through when the role in the user's token grants one of
+// them. Roles are loaded through the role store and cached.
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"erp/controllers/middleware"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// Known permissions. A role's permissions are stored comma-separated in roles.permissions.
+const (
+	// All is granted to administrators and satisfies every check; requiring it limits a
+	// route to administrators.
+	All       = "all_permissions"
+	Basic     = "basic_permissions"
+	Sales     = "sales_permissions"
+	Purchase  = "purchase_permissions"
+	Finance   = "finance_permissions"
+	Corporate = "corporate_permissions"
+	HR        = "hr_permissions"
+)
+
+// Registry describes the known permissions. Routes may only require permissions listed here.
+var Registry = map[string]string{
+	All:       "Administration; grants every permission",
+	Basic:     "Self-service features available to every employee",
+	Sales:     "Customers, invoices, point of sale and inventory for selling",
+	Purchase:  "Suppliers, bills and inventory for purchasing",
+	Finance:   "General ledger, payables, receivables, payroll and financial reports",
+	Corporate: "Company-wide reports",
+	HR:        "Leave approval and other people management",
+}
+
+// Parse splits the permissions stored on a role.
+func Parse(permissions string) []string {
+	var list []string
+	for _, permission := range strings.Split(permissions, ",") {
+		if permission = strings.TrimSpace(permission); permission != "" {
+			list = append(list, permission)
+		}
+	}
+	return list
+}
+
+// Grants reports whether granted permissions include All or one of the required ones.
+func Grants(granted []string, required ...string) bool {
+	for _, permission := range granted {
+		if permission == All {
+			return true
+		}
+		for _, want := range required {
+			if permission == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Holds reports whether granted permissions include one of the required ones. Unlike
+// Grants, All does not satisfy it: it is for confidential data that administrators must
+// not see by virtue of being administrators.
+func Holds(granted []string, required ...string) bool {
+	for _, permission := range granted {
+		for _, want := range required {
+			if permission == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cachedRole is a role's permissions and when they were loaded.
+type cachedRole struct {
+	permissions []string
+	loadedAt    time.Time
+}
+
+// Service checks roles' permissions. Roles are cached for TTL, so permission changes take
+// effect within it.
+type Service struct {
+	Roles models.RoleStore
+	TTL   time.Duration
+
+	mu    sync.Mutex
+	roles map[string]cachedRole
+}
+
+// NewService creates a permission service with the given cache lifetime.
+func NewService(roles models.RoleStore, ttl time.Duration) *Service {
+	return &Service{Roles: roles, TTL: ttl}
+}
+
+// Allowed reports whether a role grants one of the required permissions. Unknown roles
+// grant nothing.
+//
+// Parameters:
+//   - role: The role name from the user's token.
+//   - required: The permissions, any of which is enough.
+//
+// Returns:
+//   - bool: Whether the role is allowed.
+//   - error: An error if the role cannot be loaded.
+func (s *Service) Allowed(ctx context.Context, role string, required ...string) (bool, error) {
+	granted, err := s.load(ctx, role)
+	if err != nil {
+		return false, err
+	}
+	return Grants(granted, required...), nil
+}
+
+// AllowedExplicitly reports whether a role holds one of the required permissions itself;
+// All does not count (see Holds).
+func (s *Service) AllowedExplicitly(ctx context.Context, role string, required ...string) (bool, error) {
+	granted, err := s.load(ctx, role)
+	if err != nil {
+		return false, err
+	}
+	return Holds(granted, required...), nil
+}
+
+// Require is a middleware that lets a request through (403 Forbidden otherwise) if the
+// user's role grants one of the given permissions. It must be chained after
+// middleware.JWTAuth. It panics if a permission is not in the Registry, so a misspelt
+// permission stops the server at startup instead of locking a route.
+func (s *Service) Require(required ...string) func(http.Handler) http.Handler {
+	return s.require(required, s.Allowed)
+}
+
+// RequireExplicit is like Require, but the role must hold one of the permissions itself:
+// administrators are refused unless their role also lists it.
+func (s *Service) RequireExplicit(required ...string) func(http.Handler) http.Handler {
+	return s.require(required, s.AllowedExplicitly)
+}
+
+// require builds the middleware of Require and RequireExplicit around a permission check.
+func (s *Service) require(required []string, allowed func(ctx context.Context, role string, required ...string) (bool, error)) func(http.Handler) http.Handler {
+	if len(required) == 0 {
+		panic("rbac: Require needs at least one permission")
+	}
+	for _, permission := range required {
+		if _, ok := Registry[permission]; !ok {
+			panic(fmt.Sprintf("rbac: unknown permission %q", permission))
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, err := middleware.GetUserRoleFromContext(r.Context())
+			if err != nil {
+				respond.Error(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			ok, err := allowed(r.Context(), role, required...)
+			if err != nil {
+				log.Printf("rbac: could not load role %q: %v", role, err)
+				respond.Error(w, http.StatusInternalServerError, "Failed to check permissions")
+				return
+			}
+			if !ok {
+				respond.Error(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// load returns a role's cached permissions, reloading them once they expire. The role is
+// read without holding the lock, so a slow lookup does not hold up requests of other roles.
+func (s *Service) load(ctx context.Context, role string) ([]string, error) {
+	s.mu.Lock()
+	cached, ok := s.roles[role]
+	s.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) <= s.TTL {
+		return cached.permissions, nil
+	}
+
+	var granted []string
+	stored, err := s.Roles.GetRoleByName(ctx, role)
+	if err == nil {
+		granted = Parse(stored.Permissions)
+	} else if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.roles == nil {
+		s.roles = map[string]cachedRole{}
+	}
+	s.roles[role] = cachedRole{permissions: granted, loadedAt: time.Now()}
+	return granted, nil
+}
@@ -0,0 +1,165 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"erp/controllers/middleware"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRoleStore serves roles from a map and counts the lookups.
+type fakeRoleStore struct {
+	roles   map[string]string
+	err     error
+	lookups int
+}
+
+func (f *fakeRoleStore) GetRoleByID(ctx context.Context, id int) (*models.Role, error) {
+	return nil, errors.New("not used")
+}
+
+func (f *fakeRoleStore) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	f.lookups++
+	if f.err != nil {
+		return nil, f.err
+	}
+	permissions, ok := f.roles[name]
+	if !ok {
+		return nil, models.NotFound("role %q not found", name)
+	}
+	return &models.Role{RoleName: name, Permissions: permissions}, nil
+}
+
+func request(role string) *http.Request {
+	req := httptest.NewRequest("GET", "/general_ledger/1", nil)
+	if role != "" {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserRole, role))
+	}
+	return req
+}
+
+func serve(handler http.Handler, req *http.Request) int {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestParseAndGrants(t *testing.T) {
+	assert.Equal(t, []string{Finance, HR}, Parse(" finance_permissions, hr_permissions ,"))
+	assert.Empty(t, Parse(""))
+
+	assert.True(t, Grants([]string{Finance, HR}, HR))
+	assert.True(t, Grants([]string{All}, Finance), "all permissions pass every check")
+	assert.False(t, Grants([]string{Sales}, Finance, Purchase))
+	assert.False(t, Grants(nil, Finance))
+}
+
+func TestRequire(t *testing.T) {
+	store := &fakeRoleStore{roles: map[string]string{
+		"Admin":       All,
+		"Accountant":  Finance,
+		"Sales Group": Sales,
+		"Controller":  "finance_permissions,hr_permissions",
+	}}
+	service := NewService(store, time.Minute)
+	handler := service.Require(Finance)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	assert.Equal(t, http.StatusOK, serve(handler, request("Admin")))
+	assert.Equal(t, http.StatusOK, serve(handler, request("Accountant")))
+	assert.Equal(t, http.StatusOK, serve(handler, request("Controller")))
+	assert.Equal(t, http.StatusForbidden, serve(handler, request("Sales Group")))
+	assert.Equal(t, http.StatusForbidden, serve(handler, request("Intern")), "unknown roles grant nothing")
+	assert.Equal(t, http.StatusForbidden, serve(handler, request("")), "requests without a role are refused")
+}
+
+func TestRequireExplicit(t *testing.T) {
+	store := &fakeRoleStore{roles: map[string]string{
+		"Admin":    All,
+		"HR":       HR,
+		"HR Admin": "all_permissions,hr_permissions",
+	}}
+	service := NewService(store, time.Minute)
+	handler := service.RequireExplicit(HR)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	assert.Equal(t, http.StatusOK, serve(handler, request("HR")))
+	assert.Equal(t, http.StatusOK, serve(handler, request("HR Admin")))
+	assert.Equal(t, http.StatusForbidden, serve(handler, request("Admin")), "all permissions do not stand in for HR")
+	assert.False(t, Holds([]string{All}, HR))
+}
+
+func TestRequireCachesRoles(t *testing.T) {
+	store := &fakeRoleStore{roles: map[string]string{"Accountant": Finance}}
+	service := NewService(store, time.Minute)
+	handler := service.Require(Finance)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve(handler, request("Accountant"))
+	serve(handler, request("Accountant"))
+	serve(handler, request("Intern"))
+	serve(handler, request("Intern"))
+	assert.Equal(t, 2, store.lookups, "known and unknown roles are cached")
+
+	service.TTL = 0
+	time.Sleep(time.Millisecond)
+	serve(handler, request("Accountant"))
+	assert.Equal(t, 3, store.lookups, "expired roles are reloaded")
+}
+
+// blockingRoleStore closes entered when the Slow role is looked up, and holds up the lookup
+// until release is closed.
+type blockingRoleStore struct {
+	fakeRoleStore
+	entered, release chan struct{}
+}
+
+func (b *blockingRoleStore) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	if name == "Slow" {
+		close(b.entered)
+		<-b.release
+		return &models.Role{RoleName: name}, nil
+	}
+	return &models.Role{RoleName: name, Permissions: Finance}, nil
+}
+
+func TestSlowRoleLookupDoesNotBlockOthers(t *testing.T) {
+	store := &blockingRoleStore{entered: make(chan struct{}), release: make(chan struct{})}
+	service := NewService(store, time.Minute)
+	slow := make(chan struct{})
+	go func() {
+		service.Allowed(context.Background(), "Slow", Finance)
+		close(slow)
+	}()
+	<-store.entered
+
+	done := make(chan bool)
+	go func() {
+		allowed, _ := service.Allowed(context.Background(), "Accountant", Finance)
+		done <- allowed
+	}()
+	select {
+	case allowed := <-done:
+		assert.True(t, allowed)
+	case <-time.After(time.Second):
+		t.Fatal("the lookup of one role waited for another")
+	}
+	close(store.release)
+	<-slow
+}
+
+func TestRequireStoreError(t *testing.T) {
+	service := NewService(&fakeRoleStore{err: errors.New("connection refused")}, time.Minute)
+	handler := service.Require(Finance)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	assert.Equal(t, http.StatusInternalServerError, serve(handler, request("Accountant")))
+}
+
+func TestRequireUnknownPermission(t *testing.T) {
+	service := NewService(&fakeRoleStore{}, time.Minute)
+	assert.Panics(t, func() { service.Require("finance") })
+	assert.Panics(t, func() { service.Require() })
+}
@@ -0,0 +1,163 @@
+// Package recognition recognizes the revenue of service invoices over time. A draft invoice
+// can be given a schedule of N months; when it is posted, its revenue is credited to
+// deferred revenue and split straight-line over the months. Each month, once it has ended,
+// its share is moved from deferred revenue to revenue.
+package recognition
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"erp/models"
+)
+
+// Ledger accounts used by revenue recognition.
+const (
+	DeferredAccount = "deferred_revenue" // Liability for revenue invoiced but not yet earned
+	RevenueAccount  = "revenue"
+)
+
+// MaxMonths is the longest schedule accepted.
+const MaxMonths = 120
+
+// periodLayout is the format of a recognition period.
+const periodLayout = "2006-01"
+
+// Service validates revenue schedules and recognizes revenue.
+type Service struct {
+	Store models.RevenueScheduleStore
+	Day   int              // Day of the month from which the previous month is recognized
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a revenue recognition service.
+func NewService(store models.RevenueScheduleStore, day int) *Service {
+	return &Service{Store: store, Day: day, Now: time.Now}
+}
+
+// SetSchedule sets how a draft invoice's revenue is recognized once it is posted.
+//
+// Parameters:
+//   - schedule: The invoice, the number of months and optionally the first month.
+//   - actor: Email of the user setting it.
+//
+// Returns:
+//   - error: A validation error if the number of months is out of range,
+//     models.ErrNotFound if the invoice does not exist, models.ErrDocumentLocked if it is
+//     not a draft, or the store's error.
+func (s *Service) SetSchedule(ctx context.Context, schedule *models.RevenueSchedule, actor string) error {
+	if schedule.Months < 1 || schedule.Months > MaxMonths {
+		return models.Invalid("months must be between 1 and %d", MaxMonths)
+	}
+	if schedule.StartMonth != nil {
+		start := month(*schedule.StartMonth)
+		schedule.StartMonth = &start
+	}
+	schedule.Amount, schedule.Recognized, schedule.PostedOn, schedule.Lines = 0, 0, nil, []models.RevenueLine{}
+	schedule.CreatedBy, schedule.CreatedAt = actor, s.Now()
+	return s.Store.SetRevenueSchedule(ctx, schedule)
+}
+
+// Schedule returns the revenue schedule of an invoice.
+func (s *Service) Schedule(ctx context.Context, invoiceID int) (*models.RevenueSchedule, error) {
+	return s.Store.GetRevenueSchedule(ctx, invoiceID)
+}
+
+// DeleteSchedule removes the schedule of a draft invoice, so its revenue is recognized when
+// it is posted.
+func (s *Service) DeleteSchedule(ctx context.Context, invoiceID int) error {
+	return s.Store.DeleteRevenueSchedule(ctx, invoiceID)
+}
+
+// Run recognizes the revenue of a month that has ended, and of earlier months not
+// recognized yet.
+//
+// Parameters:
+//   - period: The month, formatted as YYYY-MM.
+//
+// Returns:
+//   - *models.RecognitionRun: What was recognized.
+//   - error: A validation error if the period is invalid or has not ended, or the store's error.
+func (s *Service) Run(ctx context.Context, period string) (*models.RecognitionRun, error) {
+	start, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	if !start.Before(month(s.Now())) {
+		return nil, models.Invalid("revenue is recognized once the month has ended")
+	}
+	run, err := s.Store.RecognizeRevenue(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	run.Period = period
+	return run, nil
+}
+
+// Monthly recognizes the previous month. It is run daily by the scheduler and acts from
+// Day of the month on; recognizing a month twice does nothing.
+func (s *Service) Monthly(ctx context.Context) error {
+	now := s.Now()
+	if now.Day() < s.Day {
+		return nil
+	}
+	_, err := s.Run(ctx, month(now).AddDate(0, -1, 0).Format(periodLayout))
+	return err
+}
+
+// Deferred reports the revenue deferred at the end of a day and reconciles it with the
+// ledger.
+func (s *Service) Deferred(ctx context.Context, asOf time.Time) (*models.DeferredRevenueReport, error) {
+	if asOf.IsZero() {
+		asOf = s.Now()
+	}
+	year, mon, day := asOf.Date()
+	asOf = time.Date(year, mon, day, 0, 0, 0, 0, time.UTC)
+	invoices, ledger, err := s.Store.GetDeferredRevenue(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.DeferredRevenueReport{AsOf: asOf, Invoices: invoices, Ledger: roundCents(ledger)}
+	for _, invoice := range invoices {
+		report.Scheduled = roundCents(report.Scheduled + invoice.Deferred)
+	}
+	report.Difference = roundCents(report.Ledger - report.Scheduled)
+	return report, nil
+}
+
+// Split divides an amount straight-line over months from start. Each month gets the amount
+// divided evenly, rounded down to cents; the last month takes the rounding difference.
+func Split(amount float64, months int, start time.Time) []models.RevenueLine {
+	if months < 1 {
+		return nil
+	}
+	start = month(start)
+	share := math.Floor(amount/float64(months)*100+1e-9) / 100
+	lines := make([]models.RevenueLine, months)
+	total := 0.0
+	for i := range lines {
+		lines[i].Month = start.AddDate(0, i, 0)
+		lines[i].Amount = share
+		if i == months-1 {
+			lines[i].Amount = roundCents(amount - total)
+		}
+		total = roundCents(total + share)
+	}
+	return lines
+}
+
+// MonthEnd returns the last day of t's month, the date revenue of that month is recognized on.
+func MonthEnd(t time.Time) time.Time {
+	return month(t).AddDate(0, 1, -1)
+}
+
+// month returns the first day of t's month in UTC.
+func month(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,125 @@
+package recognition
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore records the months recognized and returns a fixed deferred revenue report.
+type fakeStore struct {
+	schedule   *models.RevenueSchedule
+	recognized []time.Time
+	deferred   []models.DeferredRevenue
+	ledger     float64
+}
+
+func (f *fakeStore) SetRevenueSchedule(ctx context.Context, schedule *models.RevenueSchedule) error {
+	f.schedule = schedule
+	return nil
+}
+
+func (f *fakeStore) DeleteRevenueSchedule(ctx context.Context, invoiceID int) error { return nil }
+
+func (f *fakeStore) GetRevenueSchedule(ctx context.Context, invoiceID int) (*models.RevenueSchedule, error) {
+	return f.schedule, nil
+}
+
+func (f *fakeStore) RecognizeRevenue(ctx context.Context, through time.Time) (*models.RecognitionRun, error) {
+	f.recognized = append(f.recognized, through)
+	return &models.RecognitionRun{}, nil
+}
+
+func (f *fakeStore) GetDeferredRevenue(ctx context.Context, asOf time.Time) ([]models.DeferredRevenue, float64, error) {
+	return f.deferred, f.ledger, nil
+}
+
+func newService(store *fakeStore, now time.Time) *Service {
+	service := NewService(store, 3)
+	service.Now = func() time.Time { return now }
+	return service
+}
+
+func TestSplit(t *testing.T) {
+	lines := Split(1000, 12, time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC))
+	require.Len(t, lines, 12)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), lines[0].Month)
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), lines[11].Month)
+	assert.Equal(t, 83.33, lines[0].Amount)
+	assert.Equal(t, 83.37, lines[11].Amount, "the last month takes the rounding difference")
+
+	total := 0.0
+	for _, line := range lines {
+		total += line.Amount
+	}
+	assert.InDelta(t, 1000, total, 0.001)
+
+	lines = Split(0.7, 7, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 0.1, lines[0].Amount)
+	assert.Equal(t, 0.1, lines[6].Amount)
+}
+
+func TestMonthEnd(t *testing.T) {
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), MonthEnd(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), MonthEnd(time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestSetSchedule(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{}
+	service := newService(store, time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC))
+
+	assert.ErrorIs(t, service.SetSchedule(ctx, &models.RevenueSchedule{InvoiceID: 7}, "finance@example.com"), models.ErrValidation)
+	assert.ErrorIs(t, service.SetSchedule(ctx, &models.RevenueSchedule{InvoiceID: 7, Months: 121}, "finance@example.com"), models.ErrValidation)
+
+	start := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, service.SetSchedule(ctx, &models.RevenueSchedule{InvoiceID: 7, Months: 12, StartMonth: &start}, "finance@example.com"))
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), *store.schedule.StartMonth)
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{}
+	service := newService(store, time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC))
+
+	_, err := service.Run(ctx, "2024-05")
+	assert.ErrorIs(t, err, models.ErrValidation, "the current month has not ended")
+	_, err = service.Run(ctx, "May")
+	assert.ErrorIs(t, err, models.ErrValidation)
+
+	run, err := service.Run(ctx, "2024-04")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-04", run.Period)
+	assert.Equal(t, []time.Time{time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}, store.recognized)
+}
+
+func TestMonthly(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{}
+	require.NoError(t, newService(store, time.Date(2024, 5, 2, 6, 0, 0, 0, time.UTC)).Monthly(ctx))
+	assert.Empty(t, store.recognized, "nothing is recognized before the configured day")
+
+	require.NoError(t, newService(store, time.Date(2024, 5, 3, 6, 0, 0, 0, time.UTC)).Monthly(ctx))
+	assert.Equal(t, []time.Time{time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}, store.recognized)
+}
+
+func TestDeferredReconciles(t *testing.T) {
+	store := &fakeStore{
+		deferred: []models.DeferredRevenue{{InvoiceID: 7, Amount: 1200, Recognized: 300, Deferred: 900},
+			{InvoiceID: 8, Amount: 600, Recognized: 0, Deferred: 600}},
+		ledger: 1450,
+	}
+	service := newService(store, time.Date(2024, 5, 6, 15, 0, 0, 0, time.UTC))
+
+	report, err := service.Deferred(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), report.AsOf)
+	assert.Equal(t, 1500.0, report.Scheduled)
+	assert.Equal(t, 1450.0, report.Ledger)
+	assert.Equal(t, -50.0, report.Difference)
+}
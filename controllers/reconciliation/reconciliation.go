@@ -0,0 +1,258 @@
+// Package reconciliation keeps the month-end worksheets that reconcile control accounts with
+// their subledgers. Receivables are compared with the balances of customer invoices, payables
+// with the supplier bills outstanding, and the bank with the closing balance of its
+// statement. The difference is explained with reconciling items, and a worksheet is signed
+// off, by someone other than who prepared it, once nothing is left unexplained.
+package reconciliation
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// periodLayout is the format of reconciliation periods.
+const periodLayout = "2006-01"
+
+// Control accounts that can be reconciled.
+const (
+	ReceivableAccount = "accounts_receivable"
+	PayableAccount    = "accounts_payable"
+	BankAccount       = "cash"
+)
+
+// Service applies the reconciliation rules on top of a ReconciliationStore.
+type Service struct {
+	Store models.ReconciliationStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a reconciliation service backed by store.
+func NewService(store models.ReconciliationStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Prepare opens the worksheet of a control account for a month.
+//
+// Parameters:
+//   - r: Account, Period and, for the bank, StatementBalance are read; the rest is set.
+//   - actor: Email of the user preparing the worksheet.
+//
+// Returns:
+//   - error: A validation error if the account is not a control account, the period has
+//     not started or the bank has no statement balance, a conflict if the worksheet exists,
+//     or the store's error.
+func (s *Service) Prepare(ctx context.Context, r *models.Reconciliation, actor string) error {
+	first, err := s.period(r.Period)
+	if err != nil {
+		return err
+	}
+	switch r.Account {
+	case ReceivableAccount, PayableAccount:
+		r.StatementBalance = nil
+	case BankAccount:
+		if r.StatementBalance == nil {
+			return models.Invalid("statement_balance is required to reconcile the bank")
+		}
+	default:
+		return models.Invalid("account must be %q, %q or %q", ReceivableAccount, PayableAccount, BankAccount)
+	}
+	r.Period = first.Format(periodLayout)
+	r.Status, r.PreparedBy, r.PreparedAt = models.ReconciliationOpen, actor, s.Now()
+	r.Items = []models.ReconcilingItem{}
+	if err := s.Store.CreateReconciliation(ctx, r); err != nil {
+		return err
+	}
+	return s.refresh(ctx, r)
+}
+
+// Get returns a worksheet. The figures of an open worksheet are worked out afresh.
+func (s *Service) Get(ctx context.Context, id int) (*models.Reconciliation, error) {
+	r, err := s.Store.GetReconciliation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r, s.refresh(ctx, r)
+}
+
+// List returns the worksheets matching the filters that are not empty, with their figures.
+// Open worksheets with differences left to explain are the unreconciled ones.
+func (s *Service) List(ctx context.Context, account, period, status string) ([]models.Reconciliation, error) {
+	switch status {
+	case "", models.ReconciliationOpen, models.ReconciliationSignedOff:
+	default:
+		return nil, models.Invalid("unknown reconciliation status %q", status)
+	}
+	if period != "" {
+		if _, err := time.Parse(periodLayout, period); err != nil {
+			return nil, models.Invalid("period must be formatted as YYYY-MM")
+		}
+	}
+	list, err := s.Store.ListReconciliations(ctx, account, period, status)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if err := s.refresh(ctx, &list[i]); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// SetStatementBalance corrects the statement balance of an open bank worksheet.
+func (s *Service) SetStatementBalance(ctx context.Context, id int, balance float64) (*models.Reconciliation, error) {
+	r, err := s.Store.GetReconciliation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Account != BankAccount {
+		return nil, models.Invalid("only bank worksheets have a statement balance")
+	}
+	if err := s.Store.SetStatementBalance(ctx, id, balance); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// AddItem records a reconciling item on an open worksheet.
+//
+// Returns:
+//   - *models.Reconciliation: The worksheet with the item.
+//   - error: A validation error if the item has no description or amount, a conflict if
+//     the worksheet is signed off, or the store's error.
+func (s *Service) AddItem(ctx context.Context, id int, item *models.ReconcilingItem, actor string) (*models.Reconciliation, error) {
+	item.Description = strings.TrimSpace(item.Description)
+	if item.Description == "" || item.Amount == 0 {
+		return nil, models.Invalid("a reconciling item needs a description and an amount")
+	}
+	item.Amount = round(item.Amount)
+	item.CreatedBy, item.CreatedAt = actor, s.Now()
+	if err := s.Store.AddReconcilingItem(ctx, id, item); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// DeleteItem removes a reconciling item from an open worksheet.
+func (s *Service) DeleteItem(ctx context.Context, id, itemID int) (*models.Reconciliation, error) {
+	if err := s.Store.DeleteReconcilingItem(ctx, id, itemID); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// SignOff signs off a worksheet whose difference is fully explained.
+//
+// Returns:
+//   - *models.Reconciliation: The signed-off worksheet.
+//   - error: A permission error if the actor prepared it, a conflict if it is signed off
+//     already or a difference is unexplained, or the store's error.
+func (s *Service) SignOff(ctx context.Context, id int, actor, note string) (*models.Reconciliation, error) {
+	r, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case r.Status != models.ReconciliationOpen:
+		return nil, models.Conflict("reconciliation %d is %s", id, r.Status)
+	case r.PreparedBy == actor:
+		return nil, models.PermissionDenied("reconciliation %d must be signed off by someone other than who prepared it", id)
+	case r.Unexplained != 0:
+		return nil, models.Conflict("reconciliation %d has %.2f unexplained", id, r.Unexplained)
+	}
+	if err := s.Store.SignOffReconciliation(ctx, r, actor, strings.TrimSpace(note)); err != nil {
+		return nil, err
+	}
+	return s.Store.GetReconciliation(ctx, id)
+}
+
+// LedgerLines returns the postings to a worksheet's account during its month, to trace a
+// difference.
+func (s *Service) LedgerLines(ctx context.Context, id int) ([]models.FinancialTransaction, error) {
+	r, err := s.Store.GetReconciliation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	first, _ := time.Parse(periodLayout, r.Period)
+	return s.Store.LedgerLines(ctx, r.Account, first, first.AddDate(0, 1, -1))
+}
+
+// SubledgerLines returns the open documents making up a worksheet's subledger balance at
+// the end of its month. The bank has none; its subledger is the statement.
+func (s *Service) SubledgerLines(ctx context.Context, id int) ([]models.SubledgerLine, error) {
+	r, err := s.Store.GetReconciliation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	first, _ := time.Parse(periodLayout, r.Period)
+	return s.subledger(ctx, r.Account, first.AddDate(0, 1, -1))
+}
+
+// refresh works out the figures of an open worksheet. Signed-off worksheets keep the
+// figures they were signed off with.
+func (s *Service) refresh(ctx context.Context, r *models.Reconciliation) error {
+	if r.Status == models.ReconciliationOpen {
+		first, _ := time.Parse(periodLayout, r.Period)
+		gl, err := s.Store.LedgerBalance(ctx, r.Account, first)
+		if err != nil {
+			return err
+		}
+		if r.Account == PayableAccount {
+			gl = -gl // Payables carry a credit balance
+		}
+		r.GLBalance = round(gl)
+		if r.StatementBalance != nil {
+			r.SubledgerBalance = round(*r.StatementBalance)
+		} else {
+			lines, err := s.subledger(ctx, r.Account, first.AddDate(0, 1, -1))
+			if err != nil {
+				return err
+			}
+			r.SubledgerBalance = 0
+			for _, line := range lines {
+				r.SubledgerBalance += line.Amount
+			}
+			r.SubledgerBalance = round(r.SubledgerBalance)
+		}
+		r.Difference = round(r.SubledgerBalance - r.GLBalance)
+	}
+	r.Explained = 0
+	for _, item := range r.Items {
+		r.Explained += item.Amount
+	}
+	r.Explained = round(r.Explained)
+	r.Unexplained = round(r.Difference - r.Explained)
+	return nil
+}
+
+// subledger returns the open documents of an account's subledger at the end of day end.
+func (s *Service) subledger(ctx context.Context, account string, end time.Time) ([]models.SubledgerLine, error) {
+	switch account {
+	case ReceivableAccount:
+		return s.Store.ReceivableLines(ctx, account, end)
+	case PayableAccount:
+		return s.Store.PayableLines(ctx, end)
+	}
+	return []models.SubledgerLine{}, nil
+}
+
+// period parses a worksheet's month, which must have started.
+func (s *Service) period(period string) (time.Time, error) {
+	first, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return first, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	if first.After(s.Now()) {
+		return first, models.Invalid("period %s has not started", period)
+	}
+	return first, nil
+}
+
+// round rounds an amount to cents.
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,123 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps one worksheet and fixed ledger and subledger balances.
+type fakeStore struct {
+	models.ReconciliationStore
+	worksheet  *models.Reconciliation
+	ledger     float64
+	receivable []models.SubledgerLine
+	payable    []models.SubledgerLine
+	signedOff  bool
+}
+
+func (f *fakeStore) CreateReconciliation(ctx context.Context, r *models.Reconciliation) error {
+	r.ID = 1
+	copied := *r
+	f.worksheet = &copied
+	return nil
+}
+
+func (f *fakeStore) GetReconciliation(ctx context.Context, id int) (*models.Reconciliation, error) {
+	copied := *f.worksheet
+	copied.Items = append([]models.ReconcilingItem{}, f.worksheet.Items...)
+	return &copied, nil
+}
+
+func (f *fakeStore) AddReconcilingItem(ctx context.Context, id int, item *models.ReconcilingItem) error {
+	f.worksheet.Items = append(f.worksheet.Items, *item)
+	return nil
+}
+
+func (f *fakeStore) SignOffReconciliation(ctx context.Context, r *models.Reconciliation, actor, note string) error {
+	f.signedOff = true
+	return nil
+}
+
+func (f *fakeStore) LedgerBalance(ctx context.Context, account string, period time.Time) (float64, error) {
+	return f.ledger, nil
+}
+
+func (f *fakeStore) ReceivableLines(ctx context.Context, account string, end time.Time) ([]models.SubledgerLine, error) {
+	return f.receivable, nil
+}
+
+func (f *fakeStore) PayableLines(ctx context.Context, end time.Time) ([]models.SubledgerLine, error) {
+	return f.payable, nil
+}
+
+func newService(store *fakeStore) *Service {
+	s := NewService(store)
+	s.Now = func() time.Time { return time.Date(2025, 7, 3, 0, 0, 0, 0, time.UTC) }
+	return s
+}
+
+func TestPrepareChecksTheWorksheet(t *testing.T) {
+	s := newService(&fakeStore{})
+	for _, r := range []models.Reconciliation{
+		{Account: "revenue", Period: "2025-06"},
+		{Account: ReceivableAccount, Period: "June"},
+		{Account: ReceivableAccount, Period: "2025-08"},
+		{Account: BankAccount, Period: "2025-06"},
+	} {
+		err := s.Prepare(context.Background(), &r, "ana@example.com")
+		assert.True(t, errors.Is(err, models.ErrValidation), "%+v", r)
+	}
+}
+
+func TestReconcilePayables(t *testing.T) {
+	ctx := context.Background()
+	// The ledger holds a 1,500 credit; the bills open come to 1,200, as a 300 journal
+	// reduced the payable by hand
+	store := &fakeStore{ledger: -1500, payable: []models.SubledgerLine{{Amount: 700}, {Amount: 500}}}
+	s := newService(store)
+	worksheet := &models.Reconciliation{Account: PayableAccount, Period: "2025-06"}
+	require.NoError(t, s.Prepare(ctx, worksheet, "ana@example.com"))
+	assert.Equal(t, 1500.0, worksheet.GLBalance)
+	assert.Equal(t, 1200.0, worksheet.SubledgerBalance)
+	assert.Equal(t, -300.0, worksheet.Difference)
+	assert.Equal(t, -300.0, worksheet.Unexplained)
+
+	_, err := s.SignOff(ctx, 1, "bo@example.com", "")
+	assert.True(t, errors.Is(err, models.ErrConflict), "the difference is unexplained")
+
+	worksheet, err = s.AddItem(ctx, 1, &models.ReconcilingItem{Description: " Accrual journal 14 ", Amount: -300}, "ana@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Accrual journal 14", worksheet.Items[0].Description)
+	assert.Equal(t, 0.0, worksheet.Unexplained)
+
+	_, err = s.SignOff(ctx, 1, "ana@example.com", "")
+	assert.True(t, errors.Is(err, models.ErrPermissionDenied), "the preparer cannot sign off")
+	_, err = s.SignOff(ctx, 1, "bo@example.com", "Agreed to the bill listing")
+	require.NoError(t, err)
+	assert.True(t, store.signedOff)
+}
+
+func TestReconcileTheBankWithItsStatement(t *testing.T) {
+	statement := 980.0
+	s := newService(&fakeStore{ledger: 1000})
+	worksheet := &models.Reconciliation{Account: BankAccount, Period: "2025-06", StatementBalance: &statement}
+	require.NoError(t, s.Prepare(context.Background(), worksheet, "ana@example.com"))
+	assert.Equal(t, 980.0, worksheet.SubledgerBalance)
+	assert.Equal(t, -20.0, worksheet.Difference)
+}
+
+func TestSignedOffWorksheetsKeepTheirFigures(t *testing.T) {
+	store := &fakeStore{ledger: 999, worksheet: &models.Reconciliation{ID: 1, Account: ReceivableAccount,
+		Period: "2025-06", Status: models.ReconciliationSignedOff, GLBalance: 400, SubledgerBalance: 400}}
+	worksheet, err := newService(store).Get(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 400.0, worksheet.GLBalance)
+	assert.Equal(t, 0.0, worksheet.Unexplained)
+}
@@ -0,0 +1,246 @@
+package reportbuilder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// maxInValues is the most values an "in" filter may list.
+const maxInValues = 100
+
+// Query is a validated report query translated to SQL.
+type Query struct {
+	SQL     string
+	Args    []interface{}
+	Columns []string // Names of the output columns
+	Types   []string // Field type of each output column
+}
+
+// Build validates a report query against the whitelist and translates it to a
+// parameterized SELECT. Only whitelisted table and column names reach the SQL text;
+// filter values are always passed as arguments.
+//
+// Parameters:
+//   - q: The report query.
+//   - maxRows: The most rows the query may return; larger limits are capped.
+//
+// Returns:
+//   - *Query: The SQL, its arguments and the output columns.
+//   - error: A validation error describing the first problem found.
+func Build(q models.ReportQuery, maxRows int) (*Query, error) {
+	entity, ok := Entities[q.Entity]
+	if !ok {
+		return nil, models.Invalid("unknown entity %q", q.Entity)
+	}
+	field := func(name, use string) (Field, error) {
+		f, ok := entity.Fields[name]
+		if !ok {
+			return Field{}, models.Invalid("%s has no field %q to %s", q.Entity, name, use)
+		}
+		return f, nil
+	}
+
+	grouped := len(q.GroupBy) > 0 || len(q.Aggregates) > 0
+	groupBy := map[string]bool{}
+	for _, name := range q.GroupBy {
+		if _, err := field(name, "group by"); err != nil {
+			return nil, err
+		}
+		groupBy[name] = true
+	}
+
+	out := &Query{}
+	var selects []string
+	seen := map[string]bool{}
+	addColumn := func(expr, name, typ string) error {
+		if seen[name] {
+			return models.Invalid("column %q is selected twice", name)
+		}
+		seen[name] = true
+		selects = append(selects, fmt.Sprintf(`%s AS "%s"`, expr, name))
+		out.Columns = append(out.Columns, name)
+		out.Types = append(out.Types, typ)
+		return nil
+	}
+	for _, name := range q.Columns {
+		f, err := field(name, "select")
+		if err != nil {
+			return nil, err
+		}
+		if grouped && !groupBy[name] {
+			return nil, models.Invalid("column %q must be in group_by when the report is grouped", name)
+		}
+		if err := addColumn(f.Column, name, f.Type); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range q.Aggregates {
+		expr, name, typ, err := aggregate(a, field)
+		if err != nil {
+			return nil, err
+		}
+		if err := addColumn(expr, name, typ); err != nil {
+			return nil, err
+		}
+	}
+	if len(selects) == 0 {
+		return nil, models.Invalid("select at least one column or aggregate")
+	}
+
+	var where []string
+	for _, filter := range q.Filters {
+		f, err := field(filter.Field, "filter on")
+		if err != nil {
+			return nil, err
+		}
+		condition, args, err := filterCondition(f, filter, len(out.Args))
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, condition)
+		out.Args = append(out.Args, args...)
+	}
+
+	var order []string
+	for _, o := range q.OrderBy {
+		if !seen[o.Column] {
+			return nil, models.Invalid("cannot order by %q, which is not an output column", o.Column)
+		}
+		direction := "ASC"
+		if o.Descending {
+			direction = "DESC"
+		}
+		order = append(order, fmt.Sprintf(`"%s" %s`, o.Column, direction))
+	}
+	if len(order) == 0 {
+		order = []string{"1"}
+	}
+
+	limit := q.Limit
+	if limit < 0 {
+		return nil, models.Invalid("limit must not be negative")
+	}
+	if limit == 0 || limit > maxRows {
+		limit = maxRows
+	}
+
+	var sql strings.Builder
+	fmt.Fprintf(&sql, "SELECT %s FROM %s", strings.Join(selects, ", "), entity.Table)
+	if len(where) > 0 {
+		fmt.Fprintf(&sql, " WHERE %s", strings.Join(where, " AND "))
+	}
+	if len(q.GroupBy) > 0 {
+		columns := make([]string, len(q.GroupBy))
+		for i, name := range q.GroupBy {
+			columns[i] = entity.Fields[name].Column
+		}
+		fmt.Fprintf(&sql, " GROUP BY %s", strings.Join(columns, ", "))
+	}
+	out.Args = append(out.Args, limit)
+	fmt.Fprintf(&sql, " ORDER BY %s LIMIT $%d", strings.Join(order, ", "), len(out.Args))
+	out.SQL = sql.String()
+	return out, nil
+}
+
+// aggregate returns the SQL expression, output name and type of an aggregate.
+func aggregate(a models.ReportAggregate, field func(name, use string) (Field, error)) (string, string, string, error) {
+	if a.Func == models.AggregateCount && a.Field == "" {
+		return "COUNT(*)", "count", TypeNumber, nil
+	}
+	f, err := field(a.Field, a.Func)
+	if err != nil {
+		return "", "", "", err
+	}
+	name := a.Func + "_" + a.Field
+	switch a.Func {
+	case models.AggregateCount:
+		return "COUNT(" + f.Column + ")", name, TypeNumber, nil
+	case models.AggregateSum, models.AggregateAvg:
+		if f.Type != TypeNumber {
+			return "", "", "", models.Invalid("cannot %s %q, which is not a number", a.Func, a.Field)
+		}
+		return strings.ToUpper(a.Func) + "(" + f.Column + ")", name, TypeNumber, nil
+	case models.AggregateMin, models.AggregateMax:
+		return strings.ToUpper(a.Func) + "(" + f.Column + ")", name, f.Type, nil
+	default:
+		return "", "", "", models.Invalid("unknown aggregate %q", a.Func)
+	}
+}
+
+// filterCondition returns the SQL condition of a filter and its arguments, numbered after
+// the n arguments already used.
+func filterCondition(f Field, filter models.ReportFilter, n int) (string, []interface{}, error) {
+	comparisons := map[string]string{
+		models.FilterEq: "=", models.FilterNe: "<>",
+		models.FilterLt: "<", models.FilterLte: "<=", models.FilterGt: ">", models.FilterGte: ">=",
+	}
+	switch op := filter.Op; op {
+	case models.FilterEq, models.FilterNe, models.FilterLt, models.FilterLte, models.FilterGt, models.FilterGte:
+		if f.Type == TypeText && op != models.FilterEq && op != models.FilterNe {
+			return "", nil, models.Invalid("%s only supports eq, ne, in and contains", filter.Field)
+		}
+		value, err := filterValue(f, filter.Field, filter.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s %s $%d", f.Column, comparisons[op], n+1), []interface{}{value}, nil
+	case models.FilterIn:
+		values, ok := filter.Value.([]interface{})
+		if !ok || len(values) == 0 || len(values) > maxInValues {
+			return "", nil, models.Invalid("the in filter on %s needs a list of 1 to %d values", filter.Field, maxInValues)
+		}
+		placeholders := make([]string, len(values))
+		args := make([]interface{}, len(values))
+		for i, v := range values {
+			value, err := filterValue(f, filter.Field, v)
+			if err != nil {
+				return "", nil, err
+			}
+			placeholders[i], args[i] = fmt.Sprintf("$%d", n+i+1), value
+		}
+		return fmt.Sprintf("%s IN (%s)", f.Column, strings.Join(placeholders, ", ")), args, nil
+	case models.FilterContains:
+		text, ok := filter.Value.(string)
+		if f.Type != TypeText || !ok {
+			return "", nil, models.Invalid("the contains filter needs a text field and a text value")
+		}
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+		return fmt.Sprintf("%s ILIKE $%d", f.Column, n+1), []interface{}{pattern}, nil
+	case models.FilterIsNull:
+		isNull, ok := filter.Value.(bool)
+		if !ok {
+			return "", nil, models.Invalid("the is_null filter on %s needs true or false", filter.Field)
+		}
+		if isNull {
+			return f.Column + " IS NULL", nil, nil
+		}
+		return f.Column + " IS NOT NULL", nil, nil
+	default:
+		return "", nil, models.Invalid("unknown filter operator %q", op)
+	}
+}
+
+// filterValue checks that a filter value matches the field's type and converts it for the
+// database driver.
+func filterValue(f Field, name string, value interface{}) (interface{}, error) {
+	switch f.Type {
+	case TypeNumber:
+		if number, ok := value.(float64); ok {
+			return number, nil
+		}
+	case TypeDate:
+		if text, ok := value.(string); ok {
+			if date, err := time.Parse("2006-01-02", text); err == nil {
+				return date, nil
+			}
+		}
+	default:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+	}
+	return nil, models.Invalid("invalid value %v for %s, expected a %s", value, name, f.Type)
+}
@@ -0,0 +1,92 @@
+package reportbuilder
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decode reads a report query as a client would send it.
+func decode(t *testing.T, body string) models.ReportQuery {
+	var q models.ReportQuery
+	require.NoError(t, json.Unmarshal([]byte(body), &q))
+	return q
+}
+
+func TestBuild(t *testing.T) {
+	q := decode(t, `{
+		"entity": "invoices",
+		"columns": ["status"],
+		"filters": [
+			{"field": "amount", "op": "gte", "value": 100},
+			{"field": "status", "op": "in", "value": ["posted", "paid"]},
+			{"field": "customer_id", "op": "is_null", "value": false}
+		],
+		"group_by": ["status"],
+		"aggregates": [{"func": "count"}, {"func": "sum", "field": "amount"}],
+		"order_by": [{"column": "sum_amount", "descending": true}]
+	}`)
+	query, err := Build(q, 500)
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT status AS "status", COUNT(*) AS "count", SUM(amount) AS "sum_amount" FROM invoices`+
+		` WHERE amount >= $1 AND status IN ($2, $3) AND customer_id IS NOT NULL`+
+		` GROUP BY status ORDER BY "sum_amount" DESC LIMIT $4`, query.SQL)
+	assert.Equal(t, []interface{}{100.0, "posted", "paid", 500}, query.Args)
+	assert.Equal(t, []string{"status", "count", "sum_amount"}, query.Columns)
+}
+
+func TestBuildRejectsQueriesOutsideTheWhitelist(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown entity":      `{"entity": "users", "columns": ["email"]}`,
+		"unknown column":      `{"entity": "invoices", "columns": ["password_hash"]}`,
+		"injected column":     `{"entity": "invoices", "columns": ["id; DROP TABLE invoices"]}`,
+		"no columns":          `{"entity": "invoices"}`,
+		"ungrouped column":    `{"entity": "invoices", "columns": ["id"], "aggregates": [{"func": "count"}]}`,
+		"sum of text":         `{"entity": "invoices", "aggregates": [{"func": "sum", "field": "status"}]}`,
+		"unknown aggregate":   `{"entity": "invoices", "aggregates": [{"func": "stddev", "field": "amount"}]}`,
+		"unknown operator":    `{"entity": "invoices", "columns": ["id"], "filters": [{"field": "id", "op": "like", "value": 1}]}`,
+		"wrong value type":    `{"entity": "invoices", "columns": ["id"], "filters": [{"field": "amount", "op": "eq", "value": "1 OR 1=1"}]}`,
+		"invalid date":        `{"entity": "payments", "columns": ["id"], "filters": [{"field": "payment_date", "op": "gt", "value": "yesterday"}]}`,
+		"range on text":       `{"entity": "invoices", "columns": ["id"], "filters": [{"field": "status", "op": "lt", "value": "b"}]}`,
+		"order by non-output": `{"entity": "invoices", "columns": ["id"], "order_by": [{"column": "amount"}]}`,
+	} {
+		_, err := Build(decode(t, body), 500)
+		assert.ErrorIs(t, err, models.ErrValidation, name)
+	}
+}
+
+func TestRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	service := NewService(db, 100)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT payment_date AS "payment_date", SUM(amount) AS "sum_amount" FROM payments`+
+		` WHERE payment_method ILIKE $1 GROUP BY payment_date ORDER BY 1 LIMIT $2`)).
+		WithArgs(`%50\%%`, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_date", "sum_amount"}).
+			AddRow(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), []byte("1250.50")).
+			AddRow(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), nil))
+
+	result, err := service.Run(context.Background(), decode(t, `{
+		"entity": "payments", "columns": ["payment_date"], "group_by": ["payment_date"],
+		"aggregates": [{"func": "sum", "field": "amount"}],
+		"filters": [{"field": "payment_method", "op": "contains", "value": "50%"}]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{"2026-03-01", 1250.5}, {"2026-03-02", nil}}, result.Rows)
+
+	var csv strings.Builder
+	require.NoError(t, WriteCSV(&csv, result))
+	assert.Equal(t, "payment_date,sum_amount\n2026-03-01,1250.5\n2026-03-02,\n", csv.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,193 @@
+// Package reportbuilder runs custom tabular reports defined by users without code. A report
+// picks an entity, columns, filters, groupings and aggregates; every name is checked against
+// a whitelist of entities and fields before it is translated to SQL, so users can only read
+// the data exposed here.
+package reportbuilder
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"erp/models"
+)
+
+// Field types.
+const (
+	TypeText   = "text"
+	TypeNumber = "number"
+	TypeDate   = "date"
+)
+
+// Field is a column a report can use.
+type Field struct {
+	Column string `json:"-"`
+	Type   string `json:"type"`
+}
+
+// Entity is a table reports can be built on.
+type Entity struct {
+	Table  string           `json:"-"`
+	Fields map[string]Field `json:"fields"`
+}
+
+// Entities is the whitelist of entities and their fields.
+var Entities = map[string]Entity{
+	"customers": {Table: "customers", Fields: map[string]Field{
+		"id":      {"id", TypeNumber},
+		"name":    {"name", TypeText},
+		"contact": {"contact", TypeText},
+	}},
+	"products": {Table: "products", Fields: map[string]Field{
+		"id":        {"id", TypeNumber},
+		"name":      {"name", TypeText},
+		"brand":     {"brand", TypeText},
+		"season":    {"season", TypeText},
+		"price":     {"price", TypeNumber},
+		"unit_cost": {"unit_cost", TypeNumber},
+	}},
+	"sales_orders": {Table: "sales_orders", Fields: map[string]Field{
+		"id":          {"id", TypeNumber},
+		"customer_id": {"customer_id", TypeNumber},
+		"product_id":  {"product_id", TypeNumber},
+		"order_date":  {"order_date", TypeDate},
+		"quantity":    {"quantity", TypeNumber},
+	}},
+	"invoices": {Table: "invoices", Fields: map[string]Field{
+		"id":             {"id", TypeNumber},
+		"sales_order_id": {"sales_order_id", TypeNumber},
+		"customer_id":    {"customer_id", TypeNumber},
+		"amount":         {"amount", TypeNumber},
+		"status":         {"status", TypeText},
+	}},
+	"payments": {Table: "payments", Fields: map[string]Field{
+		"id":             {"id", TypeNumber},
+		"invoice_id":     {"invoice_id", TypeNumber},
+		"amount":         {"amount", TypeNumber},
+		"payment_date":   {"payment_date", TypeDate},
+		"payment_method": {"payment_method", TypeText},
+		"status":         {"status", TypeText},
+	}},
+	"financial_transactions": {Table: "financial_transactions", Fields: map[string]Field{
+		"id":               {"id", TypeNumber},
+		"account_type":     {"account_type", TypeText},
+		"amount":           {"amount", TypeNumber},
+		"transaction_date": {"transaction_date", TypeDate},
+		"transaction_type": {"transaction_type", TypeText},
+		"invoice_id":       {"invoice_id", TypeNumber},
+		"payment_id":       {"payment_id", TypeNumber},
+	}},
+	"receivables": {Table: "receivables", Fields: map[string]Field{
+		"id":             {"id", TypeNumber},
+		"customer_name":  {"customer_name", TypeText},
+		"amount":         {"amount", TypeNumber},
+		"due_date":       {"due_date", TypeDate},
+		"invoice_number": {"invoice_number", TypeText},
+		"status":         {"status", TypeText},
+		"payment_date":   {"payment_date", TypeDate},
+	}},
+}
+
+// Service validates and runs custom reports.
+type Service struct {
+	DB      *sql.DB
+	MaxRows int // Most rows a report returns
+}
+
+// NewService creates a report builder service.
+func NewService(db *sql.DB, maxRows int) *Service {
+	return &Service{DB: db, MaxRows: maxRows}
+}
+
+// Validate checks a report query against the whitelist without running it.
+func (s *Service) Validate(q models.ReportQuery) error {
+	_, err := Build(q, s.MaxRows)
+	return err
+}
+
+// Run validates and runs a report query.
+//
+// Parameters:
+//   - q: The report query.
+//
+// Returns:
+//   - *models.ReportResult: The output columns and rows. Numbers are float64, dates
+//     YYYY-MM-DD strings and empty fields nil.
+//   - error: A validation error if the query is not allowed, or the database error.
+func (s *Service) Run(ctx context.Context, q models.ReportQuery) (*models.ReportResult, error) {
+	query, err := Build(q, s.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx, query.SQL, query.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &models.ReportResult{Columns: query.Columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		values := make([]interface{}, len(query.Columns))
+		pointers := make([]interface{}, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			if values[i], err = normalize(value, query.Types[i]); err != nil {
+				return nil, fmt.Errorf("column %s: %w", query.Columns[i], err)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
+
+// normalize converts a value read by the database driver to its JSON form.
+func normalize(value interface{}, typ string) (interface{}, error) {
+	switch v := value.(type) {
+	case []byte:
+		if typ == TypeNumber {
+			return strconv.ParseFloat(string(v), 64)
+		}
+		return string(v), nil
+	case int64:
+		return float64(v), nil
+	case time.Time:
+		return v.Format("2006-01-02"), nil
+	default:
+		return v, nil
+	}
+}
+
+// WriteCSV writes a report result as CSV with a header row.
+func WriteCSV(w io.Writer, result *models.ReportResult) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(result.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i, value := range row {
+			switch v := value.(type) {
+			case nil:
+				record[i] = ""
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,90 @@
+// Package respond writes the JSON responses of the API, so every handler answers in the same
+// shape. A successful response carries the resource itself; a failed one carries an error
+// envelope:
+//
+//	{"error": {"code": "not_found", "message": "invoice 7 not found"}}
+//
+// The code is stable and meant for programs, the message for people. Details, when present,
+// hold structured data about the failure, such as the rejected fields of an invalid request:
+//
+//	{"error": {"code": "validation_failed", "message": "The request is invalid",
+//	 "details": [{"field": "amount", "message": "must be greater than zero"}]}}
+package respond
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ErrorBody describes what went wrong with a request.
+type ErrorBody struct {
+	Code    string      `json:"code"` // e.g. "not_found"; see Code
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Envelope is the body of every error response.
+type Envelope struct {
+	Error ErrorBody `json:"error"`
+}
+
+// codes holds the error codes that do not follow from the status text.
+var codes = map[int]string{
+	http.StatusUnprocessableEntity: "validation_failed",
+	http.StatusInternalServerError: "internal_error",
+}
+
+// Code returns the error code for an HTTP status: the status text in snake case, e.g.
+// "not_found" for 404 or "too_many_requests" for 429, except "validation_failed" for 422
+// and "internal_error" for 500.
+func Code(status int) string {
+	if code, ok := codes[status]; ok {
+		return code
+	}
+	text := strings.ToLower(http.StatusText(status))
+	if text == "" {
+		return "error"
+	}
+	return strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+}
+
+// JSON answers a request with v encoded as JSON.
+//
+// Parameters:
+//   - w: The response writer. Headers such as ETag or Location must be set before the call.
+//   - status: The HTTP status, e.g. 201 for a created record.
+//   - v: The response body.
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Error answers a failed request with an error envelope whose code follows from the status.
+//
+// Parameters:
+//   - w: The response writer.
+//   - status: The HTTP status, e.g. 404.
+//   - message: What went wrong, e.g. "Invoice not found".
+func Error(w http.ResponseWriter, status int, message string) {
+	ErrorDetails(w, status, message, nil)
+}
+
+// ErrorDetails answers a failed request with an error envelope carrying details, such as
+// the list of models.FieldError of an invalid request.
+func ErrorDetails(w http.ResponseWriter, status int, message string, details interface{}) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	JSON(w, status, Envelope{Error: ErrorBody{Code: Code(status), Message: message, Details: details}})
+}
+
+// NotFound answers a request for a route that does not exist, or is hidden from the caller,
+// the way an unknown resource is answered.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Error(w, http.StatusNotFound, "No route matches "+r.URL.Path)
+}
+
+// MethodNotAllowed answers a request whose route exists but not for its method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, http.StatusMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
@@ -0,0 +1,60 @@
+package respond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusBadRequest:           "bad_request",
+		http.StatusNotFound:             "not_found",
+		http.StatusConflict:             "conflict",
+		http.StatusUnprocessableEntity:  "validation_failed",
+		http.StatusPreconditionRequired: "precondition_required",
+		http.StatusTooManyRequests:      "too_many_requests",
+		http.StatusInternalServerError:  "internal_error",
+		599:                             "error",
+	} {
+		assert.Equal(t, want, Code(status), status)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	rr := httptest.NewRecorder()
+	JSON(rr, http.StatusCreated, map[string]int{"id": 7})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id": 7}`, rr.Body.String())
+}
+
+func TestError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Error(rr, http.StatusNotFound, "Invoice not found")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error": {"code": "not_found", "message": "Invoice not found"}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	ErrorDetails(rr, http.StatusUnprocessableEntity, "The request is invalid",
+		[]models.FieldError{{Field: "amount", Message: "must be greater than zero"}})
+	assert.JSONEq(t, `{"error": {"code": "validation_failed", "message": "The request is invalid",
+		"details": [{"field": "amount", "message": "must be greater than zero"}]}}`, rr.Body.String())
+}
+
+func TestNotFound(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NotFound(rr, httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "not_found", "message": "No route matches /nowhere"}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	MethodNotAllowed(rr, httptest.NewRequest(http.MethodPatch, "/customers", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.JSONEq(t, `{"error": {"code": "method_not_allowed", "message": "PATCH is not allowed on /customers"}}`, rr.Body.String())
+}
@@ -0,0 +1,317 @@
+// Package retention purges old records so that logs and delivery history do not grow
+// without bound. Every data class has a built-in retention period that admins can change
+// or switch off; financial classes are kept at least the legal minimum whatever the
+// policy says. Each purge run records what it deleted in retention_purges.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"erp/controllers/audit"
+	"erp/controllers/jobs"
+	"erp/models"
+)
+
+// KindPurge is the job kind of purge runs.
+const KindPurge = "retention_purge"
+
+// batchSize is the number of rows deleted per statement, so a purge never holds long locks.
+const batchSize = 5000
+
+// Class is a kind of data with a retention policy.
+type Class struct {
+	Name        string
+	Description string
+	Table       string
+	Column      string // Timestamp compared with the cutoff
+	Condition   string // SQL condition records must also meet to be purged; empty for all
+	Financial   bool   // Kept at least the legal minimum
+	DefaultDays int    // Built-in retention period; 0 keeps the records forever
+}
+
+// Classes lists the data classes that can be purged.
+var Classes = []Class{
+	{Name: "audit_log", Description: "Audit log entries", Table: "audit_log", Column: "created_at", Financial: true, DefaultDays: 2557},
+	{Name: "audit_logs", Description: "Audit trail of changes made through the API", Table: "audit_logs", Column: "created_at", Financial: true, DefaultDays: 2557},
+	{Name: "sod_violations", Description: "Segregation of duties violations", Table: "sod_violations", Column: "occurred_at", Financial: true, DefaultDays: 2557},
+	{Name: "notifications", Description: "In-app notifications", Table: "notifications", Column: "created_at", DefaultDays: 180},
+	{Name: "outbox_messages", Description: "Delivery history of outgoing emails, webhooks and events", Table: "outbox_messages",
+		Column: "created_at", Condition: "status <> '" + models.OutboxPending + "'", DefaultDays: 90},
+	{Name: "webhook_events", Description: "Inbound webhook payloads", Table: "webhook_events",
+		Column: "received_at", Condition: "status <> '" + models.WebhookEventReceived + "'", DefaultDays: 90},
+	{Name: "access_log", Description: "Sign-in attempts", Table: "access_log", Column: "occurred_at", DefaultDays: 365},
+	{Name: "sandbox_captures", Description: "Emails and webhooks captured in sandbox mode", Table: "sandbox_captures", Column: "captured_at", DefaultDays: 30},
+}
+
+// Service manages retention policies and purges expired records.
+type Service struct {
+	DB               *sql.DB
+	Jobs             *jobs.Runner
+	FinancialMinimum int              // Legal minimum in days for financial classes
+	Now              func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a retention service.
+func NewService(db *sql.DB, runner *jobs.Runner, financialMinimum int) *Service {
+	return &Service{DB: db, Jobs: runner, FinancialMinimum: financialMinimum, Now: time.Now}
+}
+
+// class returns the data class with the given name.
+func class(name string) (Class, bool) {
+	for _, c := range Classes {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Class{}, false
+}
+
+// Policies returns the policy of every data class, in the order of Classes.
+//
+// Returns:
+//   - []models.RetentionPolicy: The policies, with built-in ones for classes never changed.
+//   - error: An error if the stored policies cannot be read.
+func (s *Service) Policies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT data_class, retention_days, updated_by, updated_at FROM retention_policies")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := map[string]models.RetentionPolicy{}
+	for rows.Next() {
+		var p models.RetentionPolicy
+		var days sql.NullInt64
+		var updatedAt time.Time
+		if err := rows.Scan(&p.DataClass, &days, &p.UpdatedBy, &updatedAt); err != nil {
+			return nil, err
+		}
+		if days.Valid {
+			value := int(days.Int64)
+			p.RetentionDays = &value
+		}
+		p.UpdatedAt = &updatedAt
+		stored[p.DataClass] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	policies := make([]models.RetentionPolicy, len(Classes))
+	for i, c := range Classes {
+		policy, ok := stored[c.Name]
+		if !ok {
+			policy = models.RetentionPolicy{DataClass: c.Name, Default: true}
+			if c.DefaultDays > 0 {
+				days := c.DefaultDays
+				policy.RetentionDays = &days
+			}
+		}
+		policy.Description, policy.Financial = c.Description, c.Financial
+		if c.Financial {
+			policy.MinimumDays = s.FinancialMinimum
+		}
+		policies[i] = policy
+	}
+	return policies, nil
+}
+
+// SetPolicy changes the retention period of a data class and records the change in the
+// audit log.
+//
+// Parameters:
+//   - dataClass: The name of the class.
+//   - days: The new retention period in days, or nil to keep the records forever.
+//   - actor: Email of the admin making the change.
+//
+// Returns:
+//   - error: models.ErrNotFound for unknown classes, models.ErrValidation if the period is
+//     not positive or below the legal minimum, or an error if the change cannot be saved.
+func (s *Service) SetPolicy(ctx context.Context, dataClass string, days *int, actor string) error {
+	c, ok := class(dataClass)
+	if !ok {
+		return models.NotFound("unknown data class %s", dataClass)
+	}
+	if days != nil {
+		if *days <= 0 {
+			return models.Invalid("retention_days must be positive")
+		}
+		if c.Financial && *days < s.FinancialMinimum {
+			return models.Invalid("%s is financial data and must be kept at least %d days", dataClass, s.FinancialMinimum)
+		}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := s.Now()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO retention_policies (data_class, retention_days, updated_by, updated_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (data_class) DO UPDATE SET retention_days = EXCLUDED.retention_days,
+		     updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+		dataClass, days, actor, now,
+	)
+	if err != nil {
+		return err
+	}
+	details := fmt.Sprintf(`{"data_class": %q, "retention_days": null}`, dataClass)
+	if days != nil {
+		details = fmt.Sprintf(`{"data_class": %q, "retention_days": %d}`, dataClass, *days)
+	}
+	err = audit.Record(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		Action:     audit.ActionRetentionPolicy,
+		EntityType: "retention_policy",
+		Details:    []byte(details),
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Start begins a purge in the background.
+//
+// Parameters:
+//   - actor: Email of the admin who requested the purge.
+//
+// Returns:
+//   - *Job: The queued purge job.
+//   - error: An error if the job cannot be created.
+func (s *Service) Start(ctx context.Context, actor string) (*models.Job, error) {
+	return s.Jobs.Start(ctx, KindPurge, actor, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		return s.Purge(ctx, p, actor)
+	})
+}
+
+// Nightly purges expired records. It is run by the scheduler.
+func (s *Service) Nightly(ctx context.Context) error {
+	_, err := s.Jobs.Run(ctx, KindPurge, "scheduler", func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		return s.Purge(ctx, p, "scheduler")
+	})
+	return err
+}
+
+// Purge deletes the records older than their class's retention period, in batches, and
+// records what was deleted from each class. Classes kept forever are skipped.
+//
+// Parameters:
+//   - p: Reports the number of classes purged.
+//   - actor: Email of the admin, or "scheduler".
+//
+// Returns:
+//   - []models.RetentionPurge: What was deleted from each class.
+//   - error: An error if a class cannot be purged; classes purged before it stay purged.
+func (s *Service) Purge(ctx context.Context, p *jobs.Progress, actor string) ([]models.RetentionPurge, error) {
+	policies, err := s.Policies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.SetTotal(int64(len(policies)))
+
+	var jobID *int
+	if id := p.JobID(); id != 0 {
+		jobID = &id
+	}
+	purges := []models.RetentionPurge{}
+	for _, policy := range policies {
+		p.Add(1)
+		if policy.RetentionDays == nil {
+			continue
+		}
+		days := *policy.RetentionDays
+		if policy.Financial && days < s.FinancialMinimum {
+			days = s.FinancialMinimum
+		}
+		c, _ := class(policy.DataClass)
+		purge := models.RetentionPurge{
+			JobID:         jobID,
+			DataClass:     c.Name,
+			RetentionDays: days,
+			Cutoff:        s.Now().AddDate(0, 0, -days),
+			Actor:         actor,
+		}
+		if purge.RowsDeleted, err = s.deleteBefore(ctx, c, purge.Cutoff); err != nil {
+			return purges, fmt.Errorf("purge %s: %w", c.Name, err)
+		}
+		purge.PurgedAt = s.Now()
+		err := s.DB.QueryRowContext(ctx,
+			`INSERT INTO retention_purges (job_id, data_class, retention_days, cutoff, rows_deleted, actor, purged_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			purge.JobID, purge.DataClass, purge.RetentionDays, purge.Cutoff, purge.RowsDeleted, purge.Actor, purge.PurgedAt,
+		).Scan(&purge.ID)
+		if err != nil {
+			return purges, err
+		}
+		purges = append(purges, purge)
+	}
+	return purges, nil
+}
+
+// deleteBefore deletes the class's records older than cutoff in batches.
+func (s *Service) deleteBefore(ctx context.Context, c Class, cutoff time.Time) (int64, error) {
+	condition := ""
+	if c.Condition != "" {
+		condition = " AND " + c.Condition
+	}
+	query := fmt.Sprintf(`DELETE FROM %[1]s WHERE id IN (SELECT id FROM %[1]s WHERE %[2]s < $1%[3]s LIMIT $2)`,
+		c.Table, c.Column, condition)
+
+	var total int64
+	for {
+		result, err := s.DB.ExecContext(ctx, query, cutoff, batchSize)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// Purges returns the purges made between from and to, newest first.
+//
+// Parameters:
+//   - from, to: The range of purge times; to is exclusive.
+//
+// Returns:
+//   - []models.RetentionPurge: The purges.
+//   - error: An error if the query fails.
+func (s *Service) Purges(ctx context.Context, from, to time.Time) ([]models.RetentionPurge, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, job_id, data_class, retention_days, cutoff, rows_deleted, actor, purged_at
+		 FROM retention_purges WHERE purged_at >= $1 AND purged_at < $2
+		 ORDER BY purged_at DESC, id DESC`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	purges := []models.RetentionPurge{}
+	for rows.Next() {
+		var purge models.RetentionPurge
+		var jobID sql.NullInt64
+		if err := rows.Scan(&purge.ID, &jobID, &purge.DataClass, &purge.RetentionDays, &purge.Cutoff,
+			&purge.RowsDeleted, &purge.Actor, &purge.PurgedAt); err != nil {
+			return nil, err
+		}
+		if jobID.Valid {
+			id := int(jobID.Int64)
+			purge.JobID = &id
+		}
+		purges = append(purges, purge)
+	}
+	return purges, rows.Err()
+}
@@ -0,0 +1,90 @@
+package retention
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"erp/controllers/jobs"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	service := NewService(db, nil, 2557)
+	service.Now = func() time.Time { return time.Date(2026, 3, 1, 4, 0, 0, 0, time.UTC) }
+	return service, mock
+}
+
+func TestSetPolicyValidation(t *testing.T) {
+	ctx := context.Background()
+	service, mock := newTestService(t)
+	days := func(n int) *int { return &n }
+
+	assert.ErrorIs(t, service.SetPolicy(ctx, "invoices", days(30), "admin@example.com"), models.ErrNotFound)
+	assert.ErrorIs(t, service.SetPolicy(ctx, "notifications", days(0), "admin@example.com"), models.ErrValidation)
+	assert.ErrorIs(t, service.SetPolicy(ctx, "audit_log", days(365), "admin@example.com"), models.ErrValidation)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO retention_policies")).
+		WithArgs("audit_log", nil, "admin@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).
+		WithArgs("admin@example.com", "retention_policy_update", "retention_policy", sqlmock.AnyArg(), sqlmock.AnyArg(),
+			[]byte(`{"data_class": "audit_log", "retention_days": null}`), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	require.NoError(t, service.SetPolicy(ctx, "audit_log", nil, "admin@example.com"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeHonorsFinancialMinimum(t *testing.T) {
+	service, mock := newTestService(t)
+	now := service.Now()
+
+	// audit_log is stored below the legal minimum, notifications is kept forever and the
+	// other classes use their built-in periods
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT data_class, retention_days, updated_by, updated_at FROM retention_policies")).
+		WillReturnRows(sqlmock.NewRows([]string{"data_class", "retention_days", "updated_by", "updated_at"}).
+			AddRow("audit_log", 30, "admin@example.com", now).
+			AddRow("notifications", nil, "admin@example.com", now))
+	for _, c := range Classes {
+		if c.Name == "notifications" {
+			continue
+		}
+		days := c.DefaultDays
+		if c.Financial {
+			days = 2557
+		}
+		cutoff := now.AddDate(0, 0, -days)
+		deleted := int64(3)
+		if c.Name == "audit_log" {
+			// A full batch is followed by another
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM audit_log WHERE id IN (SELECT id FROM audit_log WHERE created_at < $1 LIMIT $2)")).
+				WithArgs(cutoff, batchSize).WillReturnResult(sqlmock.NewResult(0, batchSize))
+		}
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM " + c.Table)).
+			WithArgs(cutoff, batchSize).WillReturnResult(sqlmock.NewResult(0, deleted))
+		if c.Name == "audit_log" {
+			deleted += batchSize
+		}
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO retention_purges")).
+			WithArgs(nil, c.Name, days, cutoff, deleted, "scheduler", now).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	}
+
+	purges, err := service.Purge(context.Background(), &jobs.Progress{}, "scheduler")
+	require.NoError(t, err)
+	require.Len(t, purges, len(Classes)-1)
+	assert.Equal(t, "audit_log", purges[0].DataClass)
+	assert.Equal(t, 2557, purges[0].RetentionDays)
+	assert.Equal(t, int64(batchSize+3), purges[0].RowsDeleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
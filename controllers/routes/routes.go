@@ -1,31 +1,255 @@
 package routes
 
 import (
+	"context"
 	"database/sql"
-	"erp/controllers/handlers/invoice_handlers"
+	"erp/config"
+	"erp/controllers/accesslog"
+	"erp/controllers/accounts"
+	"erp/controllers/allocation"
+	"erp/controllers/antivirus"
+	"erp/controllers/approvals"
+	"erp/controllers/audit"
+	"erp/controllers/backup"
+	"erp/controllers/banking"
+	"erp/controllers/benefits"
+	"erp/controllers/budgeting"
+	"erp/controllers/bundles"
+	"erp/controllers/capacity"
+	"erp/controllers/deliveryroutes"
+	"erp/controllers/deposits"
+	"erp/controllers/disputes"
+	"erp/controllers/documents"
+	"erp/controllers/employees"
+	"erp/controllers/esign"
+	"erp/controllers/expenses"
+	"erp/controllers/export"
+	"erp/controllers/features"
+	"erp/controllers/forecasting"
+	"erp/controllers/giftcards"
+	"erp/controllers/handlers/access_log_handlers"
+	"erp/controllers/handlers/account_handlers"
 	"erp/controllers/handlers/accounts_payable_handlers"
+	"erp/controllers/handlers/allocation_handlers"
+	"erp/controllers/handlers/api_key_handlers"
+	"erp/controllers/handlers/approval_handlers"
+	"erp/controllers/handlers/attendance_handlers"
+	"erp/controllers/handlers/audit_handlers"
 	"erp/controllers/handlers/auth_handlers"
+	"erp/controllers/handlers/backup_handlers"
+	"erp/controllers/handlers/bank_handlers"
+	"erp/controllers/handlers/benefit_handlers"
+	"erp/controllers/handlers/budget_handlers"
+	"erp/controllers/handlers/bundle_handlers"
+	"erp/controllers/handlers/capacity_handlers"
+	"erp/controllers/handlers/catalog_handlers"
 	"erp/controllers/handlers/customer_data_management_handlers" // Import customer handlers package
+	"erp/controllers/handlers/delivery_route_handlers"
+	"erp/controllers/handlers/deposit_handlers"
+	"erp/controllers/handlers/dispute_handlers"
+	"erp/controllers/handlers/ecommerce_handlers"
+	"erp/controllers/handlers/email_template_handlers"
+	"erp/controllers/handlers/employee_handlers"
+	"erp/controllers/handlers/expense_handlers"
+	"erp/controllers/handlers/export_handlers"
+	"erp/controllers/handlers/feature_flag_handlers"
+	"erp/controllers/handlers/financial_record_handlers"
+	"erp/controllers/handlers/forecast_handlers"
 	"erp/controllers/handlers/general_ledger_handlers"
+	"erp/controllers/handlers/gift_card_handlers"
+	"erp/controllers/handlers/graphql_handlers"
+	"erp/controllers/handlers/hr_case_handlers"
+	"erp/controllers/handlers/installment_handlers"
+	"erp/controllers/handlers/invoice_handlers"
+	"erp/controllers/handlers/job_handlers"
+	"erp/controllers/handlers/kpi_handlers"
+	"erp/controllers/handlers/late_fee_handlers"
+	"erp/controllers/handlers/leave_handlers"
+	"erp/controllers/handlers/loyalty_handlers"
+	"erp/controllers/handlers/notification_handlers"
+	"erp/controllers/handlers/org_chart_handlers"
+	"erp/controllers/handlers/payroll_handlers"
+	"erp/controllers/handlers/payslip_handlers"
+	"erp/controllers/handlers/pos_handlers"
+	"erp/controllers/handlers/preference_handlers"
+	"erp/controllers/handlers/product_handlers"
+	"erp/controllers/handlers/provisioning_handlers"
+	"erp/controllers/handlers/purchase_order_handlers"
+	"erp/controllers/handlers/quality_handlers"
+	"erp/controllers/handlers/quarantine_handlers"
+	"erp/controllers/handlers/recognition_handlers"
+	"erp/controllers/handlers/reconciliation_handlers"
+	"erp/controllers/handlers/report_builder_handlers"
+	"erp/controllers/handlers/report_handlers"
+	"erp/controllers/handlers/retention_handlers"
+	"erp/controllers/handlers/sales_order_handlers"
+	"erp/controllers/handlers/sandbox_handlers"
+	"erp/controllers/handlers/settings_handlers"
+	"erp/controllers/handlers/settlement_handlers"
+	"erp/controllers/handlers/shipment_handlers"
+	"erp/controllers/handlers/signature_handlers"
+	"erp/controllers/handlers/standing_order_handlers"
+	"erp/controllers/handlers/statutory_handlers"
+	"erp/controllers/handlers/stock_handlers"
+	"erp/controllers/handlers/supplier_handlers"
+	"erp/controllers/handlers/supplier_return_handlers"
+	"erp/controllers/handlers/system_handlers"
+	"erp/controllers/handlers/trash_handlers"
+	"erp/controllers/handlers/warehouse_handlers"
+	"erp/controllers/handlers/webhook_handlers"
+	"erp/controllers/hrcases"
+	"erp/controllers/installments"
+	"erp/controllers/inventory"
+	"erp/controllers/jobs"
+	"erp/controllers/kpi"
+	"erp/controllers/latefees"
+	"erp/controllers/leave"
+	"erp/controllers/loyalty"
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/controllers/maintenance"
+	"erp/controllers/middleware"
+	"erp/controllers/orgchart"
+	"erp/controllers/outbox"
+	"erp/controllers/payroll"
+	"erp/controllers/payslips"
+	"erp/controllers/pos"
+	"erp/controllers/provisioning"
+	"erp/controllers/purchasing"
+	"erp/controllers/quality"
+	"erp/controllers/rbac"
+	"erp/controllers/recognition"
+	"erp/controllers/reconciliation"
+	"erp/controllers/reportbuilder"
+	"erp/controllers/respond"
+	"erp/controllers/retention"
+	"erp/controllers/salesorders"
+	"erp/controllers/sandbox"
+	"erp/controllers/settings"
+	"erp/controllers/settlements"
+	"erp/controllers/shipping"
+	"erp/controllers/softdelete"
+	"erp/controllers/standingorders"
+	"erp/controllers/statutory"
+	"erp/controllers/storage"
+	"erp/controllers/supplierreturns"
+	"erp/controllers/suppliers"
+	"erp/controllers/utils"
+	"erp/models"
+	"log"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // InitRoutes initializes all routes in the application, mapping URL paths to handlers.
-// It injects dependencies, like database connections, into handlers and stores.
-func InitRoutes(db *sql.DB) *mux.Router {
+// It injects dependencies, like database connections and configuration, into handlers and stores.
+func InitRoutes(db *sql.DB, cfg *config.Config) *mux.Router {
 	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(respond.NotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(respond.MethodNotAllowed)
 
-	// Initialize auth handlers and routes
+	// Routes are gated by the permissions granted to the user's role: subrouters Use
+	// access.Require and single routes are wrapped with withPermissions. Admins' role grants
+	// every permission.
 	roleStore := &auth_handlers.DBRoleStore{DB: db}
+	access := rbac.NewService(roleStore, cfg.RBAC.CacheTTL)
+	withPermissions := func(handler http.HandlerFunc, permissions ...string) http.Handler {
+		return middleware.JWTAuth(access.Require(permissions...)(handler))
+	}
+
+	// Feature flags gate behaviors that are being rolled out gradually
+	featureFlags := features.NewService(&feature_flag_handlers.DBFeatureFlagStore{DB: db}, cfg.Features.CacheTTL)
+	postingEnabled := featureFlags.Require(features.DoubleEntryPosting)
+
+	// Read-only and maintenance mode reject requests before they reach a handler, except those
+	// of administrators, who take backups before a migration and switch the mode back. The
+	// health check and login and token refresh (so an administrator can sign in) stay
+	// available to everyone.
+	systemMode := maintenance.NewService(&system_handlers.DBSystemModeStore{DB: db}, maintenance.DefaultTTL)
+	router.Use(systemMode.Guard(access, "/health", "/auth/login", "/auth/refresh"))
+	systemHandler := &system_handlers.SystemHandler{Mode: systemMode, DB: db}
+	router.HandleFunc("/health", systemHandler.Health).Methods("GET")
+	router.HandleFunc("/system/mode", systemHandler.GetMode).Methods("GET")
+	router.Handle("/system/mode", withPermissions(systemHandler.SetMode, rbac.All)).Methods("PUT")
+
+	// Every change made through the API is recorded in the audit trail, with the fields of the
+	// entity it changed read beforehand with a GET through the router; admins review it at /audit
+	requestAuditStore := &audit_handlers.DBRequestAuditStore{DB: db}
+	router.Use(audit.NewTrail(requestAuditStore, router).Middleware)
+	auditRouter := router.PathPrefix("/audit").Subrouter()
+	auditRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	auditHandler := &audit_handlers.AuditHandler{Store: requestAuditStore, Now: time.Now}
+	auditHandler.RegisterRoutes(auditRouter)
+
+	// Initialize background job polling; users see the jobs they started
+	jobStore := &job_handlers.DBJobStore{DB: db}
+	jobRunner := jobs.NewRunner(jobStore)
+	jobRouter := router.PathPrefix("/jobs").Subrouter()
+	jobRouter.Use(middleware.JWTAuth)
+	job_handlers.RegisterRoutes(jobRouter, jobStore)
+
+	// Initialize auth handlers and routes
 	userStore := &auth_handlers.DBUserStore{
 		DB:        db,
 		RoleStore: roleStore,
 	}
-	authHandlers := &auth_handlers.AuthHandlers{UserStore: userStore}
+	// Sign-in attempts are recorded in the access log; users are notified of sign-ins from a
+	// new location or device
+	accessLogStore := &access_log_handlers.DBAccessLogStore{DB: db}
+	accessLog := accesslog.NewService(accessLogStore, &notification_handlers.DBNotificationStore{DB: db})
+	// Access tokens are short-lived and renewed with rotating refresh tokens; tokens revoked
+	// at logout are refused by JWTAuth until they expire
+	utils.AccessTokenTTL = cfg.Auth.AccessTTL
+	tokenStore := &auth_handlers.DBTokenStore{DB: db}
+	middleware.UseRevocationList(tokenStore)
+	// Password reset links are emailed through the outbox from an editable template
+	emailTemplates := mailtemplates.NewService(&email_template_handlers.DBEmailTemplateStore{DB: db}, cfg.Mail.Locale)
+	authHandlers := &auth_handlers.AuthHandlers{
+		UserStore:  userStore,
+		SignUpRole: cfg.Provision.DefaultRole,
+		AccessLog:  accessLog,
+		Tokens:     tokenStore,
+		RefreshTTL: cfg.Auth.RefreshTTL,
+		Resets:     &auth_handlers.DBPasswordResetStore{DB: db},
+		Templates:  emailTemplates,
+		Mailer:     &outbox.Mailer{DB: db},
+		ResetURL:   cfg.Auth.ResetURL,
+		ResetTTL:   cfg.Auth.ResetTTL,
+	}
 	authRouter := router.PathPrefix("/auth").Subrouter()
 	authHandlers.RegisterRoutes(authRouter)
 
+	// Users are kept in sync with the HR system by CSV import or through the SCIM API
+	provisioningService, err := provisioning.NewService(&provisioning_handlers.DBProvisioningStore{DB: db}, cfg.Provision)
+	if err != nil {
+		log.Fatal("Failed to configure user provisioning:", err)
+	}
+	provisioningHandlers := &provisioning_handlers.ProvisioningHandlers{Service: provisioningService}
+	router.Handle("/users/import", withPermissions(provisioningHandlers.ImportUsers, rbac.All)).Methods("POST")
+	scimRouter := router.PathPrefix("/scim/v2").Subrouter()
+	scimRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	provisioning_handlers.RegisterSCIMRoutes(scimRouter, provisioningHandlers)
+
+	// Attendance history kept in spreadsheets is imported from CSV by admins
+	attendanceImports := &attendance_handlers.AttendanceImportHandler{Store: &attendance_handlers.DBAttendanceImportStore{DB: db}}
+	router.Handle("/attendance/import", withPermissions(attendanceImports.ImportAttendance, rbac.All)).Methods("POST")
+	router.Handle("/attendance/imports/{id:[0-9]+}/errors", withPermissions(attendanceImports.GetImportErrors, rbac.All)).Methods("GET")
+
+	// Employees check in and out themselves; the hours are worked out by the server
+	attendanceSessions := &attendance_handlers.AttendanceSessionHandler{Store: &attendance_handlers.DBAttendanceStore{DB: db}}
+	router.Handle("/attendance/check-in", middleware.JWTAuth(http.HandlerFunc(attendanceSessions.CheckIn))).Methods("POST")
+	router.Handle("/attendance/check-out", middleware.JWTAuth(http.HandlerFunc(attendanceSessions.CheckOut))).Methods("POST")
+
+	// DELETE soft deletes business records; the users who may delete a record restore it at
+	// /{resource}/{id}/restore, and admins review the trash and purge it at /trash
+	trashHandler := &trash_handlers.TrashHandler{Service: softdelete.NewService(&trash_handlers.DBTrashStore{DB: db})}
+	trashRouter := router.PathPrefix("/trash").Subrouter()
+	trashRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	trashHandler.RegisterRoutes(trashRouter)
+
 	// Customer-related routes
 	customerStore := &customer_data_management_handlers.DBStore{DB: db} // Assuming your customer store is in this package
 	customerHandlers := &customer_data_management_handlers.CustomerHandlers{Store: customerStore}
@@ -33,45 +257,695 @@ func InitRoutes(db *sql.DB) *mux.Router {
 	// Create a subrouter for customer routes
 	customerRouter := router.PathPrefix("/customers").Subrouter()
 
-	// Register customer routes
-	customerRouter.HandleFunc("", customerHandlers.CreateCustomerHandler).Methods("POST")               // Create customer
-	customerRouter.HandleFunc("/{id:[0-9]+}", customerHandlers.GetCustomerByIDHandler).Methods("GET")   // Get customer by ID
-	customerRouter.HandleFunc("/{id:[0-9]+}", customerHandlers.UpdateCustomerHandler).Methods("PUT")    // Update customer
-	customerRouter.HandleFunc("/{id:[0-9]+}", customerHandlers.DeleteCustomerHandler).Methods("DELETE") // Delete customer
+	// Register customer routes (sales and finance)
+	customerRouter.Handle("", withPermissions(customerHandlers.CreateCustomerHandler, rbac.Sales, rbac.Finance)).Methods("POST")               // Create customer
+	customerRouter.Handle("", withPermissions(customerHandlers.ListCustomersHandler, rbac.Sales, rbac.Finance)).Methods("GET")                 // List customers
+	customerRouter.Handle("/{id:[0-9]+}", withPermissions(customerHandlers.GetCustomerByIDHandler, rbac.Sales, rbac.Finance)).Methods("GET")   // Get customer by ID
+	customerRouter.Handle("/{id:[0-9]+}", withPermissions(customerHandlers.UpdateCustomerHandler, rbac.Sales, rbac.Finance)).Methods("PUT")    // Update customer
+	customerRouter.Handle("/{id:[0-9]+}", withPermissions(customerHandlers.DeleteCustomerHandler, rbac.Sales, rbac.Finance)).Methods("DELETE") // Delete customer
+	customerRouter.Handle("/{id:[0-9]+}/restore", withPermissions(trashHandler.Restore("customers"), rbac.Sales, rbac.Finance)).Methods("POST")
+
+	// Loyalty points: balances for signed-in users, redemption for finance and sales
+	loyaltyHandlers := &loyalty_handlers.LoyaltyHandlers{Service: loyalty.NewService(&loyalty_handlers.DBLoyaltyStore{DB: db}, cfg.Loyalty)}
+	customerRouter.Handle("/{id:[0-9]+}/loyalty", middleware.JWTAuth(http.HandlerFunc(loyaltyHandlers.GetLoyaltyAccount))).Methods("GET")
+	customerRouter.Handle("/{id:[0-9]+}/loyalty/redemptions", withPermissions(loyaltyHandlers.RedeemPoints, rbac.Finance, rbac.Sales)).Methods("POST")
 
 	// Protected routes: requires JWT authentication (example)
 	// router.Handle("/dashboard", middleware.JWTAuth(http.HandlerFunc(dashboard.Dashboard))).Methods("GET")
-	// Initialize general ledger handlers and routes
+	// Initialize journal entry handlers and routes (registered before the general ledger's /{id} routes)
+	// Segregation of duties: creators may not approve their own payments and journal entries
+	approvalEngine := approvals.NewEngine(db)
+	sodPolicyRouter := router.PathPrefix("/sod_policies").Subrouter()
+	sodPolicyRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	approval_handlers.RegisterRoutes(sodPolicyRouter, &approval_handlers.DBSoDPolicyStore{DB: db})
+
+	journalEntryStore := &general_ledger_handlers.DBJournalEntryStore{DB: db, Approvals: approvalEngine}
+	journalEntryRouter := router.PathPrefix("/general_ledger/journal_entries").Subrouter()
+	journalEntryRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	// The flagged post route is registered first so it takes precedence over the ungated one;
+	// creation reads the signed-in user, who is recorded as the creator
+	journalEntryHandler := &general_ledger_handlers.JournalEntryHandler{Store: journalEntryStore}
+	journalEntryHandler.RegisterRoutes(journalEntryRouter)
+	journalEntryRouter.Handle("/{id:[0-9]+}/post", postingEnabled(http.HandlerFunc(journalEntryHandler.PostJournalEntry))).Methods("POST")
+
+	// Initialize general ledger handlers and routes (finance)
 	generalLedgerStore := &general_ledger_handlers.DBFinancialTransactionStore{DB: db}
 	generalLedgerRouter := router.PathPrefix("/general_ledger").Subrouter()
+	generalLedgerRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
 	general_ledger_handlers.RegisterRoutes(generalLedgerRouter, generalLedgerStore)
+	ledgerBatchHandler := &general_ledger_handlers.BatchHandler{Store: generalLedgerStore, Jobs: jobRunner, Now: time.Now}
+	generalLedgerRouter.HandleFunc("/batch", ledgerBatchHandler.PostBatch).Methods("POST")
+
+	// Initialize chart of accounts handlers and routes (finance)
+	accountRouter := router.PathPrefix("/accounts").Subrouter()
+	accountRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	account_handlers.RegisterRoutes(accountRouter, accounts.NewService(&account_handlers.DBAccountStore{DB: db}))
+
+	// Financial records charge amounts to the chart of accounts (finance); their handlers
+	// register full paths, so the subrouter matches on the path prefix
+	recordRouter := router.MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return strings.HasPrefix(r.URL.Path, "/records")
+	}).Subrouter()
+	recordRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	financial_record_handlers.RegisterRoutes(recordRouter, &financial_record_handlers.DBFinancialRecordStore{DB: db})
+	recordRouter.HandleFunc("/records/{id:[0-9]+}/restore", trashHandler.Restore("financial_records")).Methods("POST")
+
+	// Month-end reconciliation of the receivable, payable and bank control accounts with their
+	// subledgers (accountants and administrators); sign-offs go to the audit log
+	reconciliationRouter := router.PathPrefix("/reconciliations").Subrouter()
+	reconciliationRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	reconciliation_handlers.RegisterRoutes(reconciliationRouter, &reconciliation_handlers.ReconciliationHandler{
+		Service: reconciliation.NewService(&reconciliation_handlers.DBReconciliationStore{DB: db}),
+	})
+
+	// Bank statement lines are imported by finance; receipts naming an invoice pay it and the
+	// rest wait in the suspense account until they are reclassified
+	bankRouter := router.PathPrefix("/bank").Subrouter()
+	bankRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	bank_handlers.RegisterRoutes(bankRouter, &bank_handlers.BankHandler{
+		Service: banking.NewService(&bank_handlers.DBBankStore{DB: db, Accounts: cfg.Bank}),
+	})
+
+	// Finance forecasts the cash balance from open receivables, payables, payroll and the
+	// recurring invoices it keeps here
+	forecastRouter := router.PathPrefix("/forecasts").Subrouter()
+	forecastRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	forecast_handlers.RegisterRoutes(forecastRouter, &forecast_handlers.ForecastHandler{
+		Service: forecasting.NewService(&forecast_handlers.DBForecastStore{DB: db}, cfg.Bank.BankAccount, cfg.Forecast),
+	})
 
-	// Initialize accounts payable handlers and routes
-	accountsPayableStore := &accounts_payable_handlers.DBPaymentStore{DB: db} // PaymentStore implementation
+	// Finance keeps budget scenarios, compares them with the ledger and reports the variances
+	// of the active plan
+	budgetRouter := router.PathPrefix("/budgets").Subrouter()
+	budgetRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	budget_handlers.RegisterRoutes(budgetRouter, &budget_handlers.BudgetHandler{
+		Service: budgeting.NewService(&budget_handlers.DBBudgetStore{DB: db}),
+	})
+
+	// Initialize accounts payable handlers and routes (finance and purchasing)
+	accountsPayableStore := &accounts_payable_handlers.DBPaymentStore{DB: db, Approvals: approvalEngine} // PaymentStore implementation
 	accountsPayableRouter := router.PathPrefix("/accounts_payable").Subrouter()
-	accounts_payable_handlers.RegisterRoutes(accountsPayableRouter, accountsPayableStore, generalLedgerStore)
+	accountsPayableRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance, rbac.Purchase))
+	// Bills wait for approval while approval workflows are switched on; creation reads the
+	// signed-in user, who may not approve their own bill
+	accountsPayableHandler := &accounts_payable_handlers.AccountsPayableHandler{
+		PaymentStore:     accountsPayableStore,
+		TransactionStore: generalLedgerStore,
+		ApprovalRequired: func(r *http.Request) bool { return featureFlags.EnabledForRequest(r, features.ApprovalWorkflows) },
+	}
+	accountsPayableRouter.Handle("/{id:[0-9]+}/approve", access.Require(rbac.Finance)(http.HandlerFunc(accountsPayableHandler.ApproveBill))).Methods("POST")
+	accountsPayableHandler.RegisterRoutes(accountsPayableRouter)
+	accountsPayableRouter.HandleFunc("/{id:[0-9]+}/restore", trashHandler.Restore("payments")).Methods("POST")
 
-	// Initialize accounts receivable handlers and routes
-	accountReceivableStore := &accounts_payable_handlers.DBPaymentStore{DB: db} // PaymentStore implementation
+	// Initialize accounts receivable handlers and routes (finance). They share the payments
+	// of accounts payable, so bills go through the same approval workflow
 	accountReceivableRouter := router.PathPrefix("/accounts_receivable").Subrouter()
-	accounts_payable_handlers.RegisterRoutes(accountReceivableRouter, accountReceivableStore, generalLedgerStore)
+	accountReceivableRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	accountReceivableRouter.HandleFunc("/{id:[0-9]+}/approve", accountsPayableHandler.ApproveBill).Methods("POST")
+	accountsPayableHandler.RegisterRoutes(accountReceivableRouter)
+	accountReceivableRouter.HandleFunc("/{id:[0-9]+}/restore", trashHandler.Restore("payments")).Methods("POST")
 
 	// initialize financial transaction handlers and routes
 	// todo: implement financial transaction handlers
 	// Initialize invoice handlers and routes
 	invoiceStore := &invoice_handlers.DBInvoiceStore{DB: db}
-	invoiceHandlers := &invoice_handlers.InvoiceHandlers{Store: invoiceStore}
+	invoiceHandlers := invoice_handlers.NewInvoiceHandlers(invoiceStore)
 
-	// Create a subrouter for invoice routes
+	// Create a subrouter for invoice routes (finance and sales)
 	invoiceRouter := router.PathPrefix("/invoices").Subrouter()
+	invoiceRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance, rbac.Sales))
 
 	// Register invoice routes
-	invoiceRouter.HandleFunc("", invoiceHandlers.CreateInvoiceHandler).Methods("POST")             // Create invoice
-	invoiceRouter.HandleFunc("/{id:[0-9]+}", invoiceHandlers.GetInvoiceByIDHandler).Methods("GET") // Get invoice by ID
+	invoiceRouter.HandleFunc("", invoiceHandlers.CreateInvoiceHandler).Methods("POST")                  // Create invoice
+	invoiceRouter.HandleFunc("", invoiceHandlers.ListInvoicesHandler).Methods("GET")                    // List invoices
+	invoiceRouter.HandleFunc("/{id:[0-9]+}", invoiceHandlers.GetInvoiceByIDHandler).Methods("GET")      // Get invoice by ID
+	invoiceRouter.HandleFunc("/{id:[0-9]+}", invoiceHandlers.UpdateInvoiceHandler).Methods("PUT")       // Update draft invoice
+	invoiceRouter.HandleFunc("/{id:[0-9]+}", invoiceHandlers.DeleteInvoiceHandler).Methods("DELETE")    // Delete draft invoice
+	invoiceRouter.HandleFunc("/{id:[0-9]+}/restore", trashHandler.Restore("invoices")).Methods("POST")  // Restore deleted draft invoice
+	invoiceRouter.HandleFunc("/{id:[0-9]+}/clone", invoiceHandlers.CloneInvoiceHandler).Methods("POST") // Copy invoice as a new draft
+	invoiceRouter.Handle("/void", withPermissions(invoiceHandlers.VoidInvoicesHandler, rbac.Finance)).Methods("POST")
+	// Posting to the ledger is rolled out behind the double-entry posting flag
+	invoiceRouter.Handle("/{id:[0-9]+}/post", postingEnabled(http.HandlerFunc(invoiceHandlers.PostInvoiceHandler))).Methods("POST")
+
+	// Gift cards and store credit: issued and applied by finance and sales, looked up by signed-in users
+	giftCardHandlers := &gift_card_handlers.GiftCardHandlers{Service: giftcards.NewService(&gift_card_handlers.DBGiftCardStore{DB: db}, cfg.GiftCards)}
+	router.Handle("/gift_cards", withPermissions(giftCardHandlers.IssueGiftCard, rbac.Finance, rbac.Sales)).Methods("POST")
+	router.Handle("/gift_cards/{code}", middleware.JWTAuth(http.HandlerFunc(giftCardHandlers.GetGiftCard))).Methods("GET")
+	customerRouter.Handle("/{id:[0-9]+}/gift_cards", middleware.JWTAuth(http.HandlerFunc(giftCardHandlers.GetCustomerGiftCards))).Methods("GET")
+	invoiceRouter.Handle("/{id:[0-9]+}/gift_card_payments", withPermissions(giftCardHandlers.PayInvoiceWithGiftCard, rbac.Finance, rbac.Sales)).Methods("POST")
+
+	// Installment plans split what is owed on posted invoices; finance and sales agree them
+	installmentHandlers := &installment_handlers.InstallmentHandlers{Service: installments.NewService(
+		&installment_handlers.DBInstallmentStore{DB: db}, nil, nil, nil)}
+	invoiceRouter.Handle("/{id:[0-9]+}/installments", withPermissions(installmentHandlers.GetPlan, rbac.Finance, rbac.Sales)).Methods("GET")
+	invoiceRouter.Handle("/{id:[0-9]+}/installments", withPermissions(installmentHandlers.CreatePlan, rbac.Finance, rbac.Sales)).Methods("POST")
+	invoiceRouter.Handle("/{id:[0-9]+}/installments", withPermissions(installmentHandlers.DeletePlan, rbac.Finance)).Methods("DELETE")
+	router.Handle("/installments/forecast", withPermissions(installmentHandlers.Forecast, rbac.Finance)).Methods("GET")
+
+	// Customer deposits on sales orders: received by finance and sales, applied when the
+	// order's invoice is posted and refunded by finance if the order is cancelled
+	depositRouter := router.PathPrefix("/deposits").Subrouter()
+	depositRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance, rbac.Sales))
+	depositService := deposits.NewService(&deposit_handlers.DBCustomerDepositStore{DB: db})
+	deposit_handlers.RegisterRoutes(depositRouter, depositService)
+
+	// Revenue recognition of service invoices: schedules are set on draft invoices and the
+	// monthly run and deferred revenue report are for finance
+	recognitionService := recognition.NewService(&recognition_handlers.DBRevenueScheduleStore{DB: db}, cfg.Recognition.Day)
+	recognitionHandlers := &recognition_handlers.RecognitionHandler{Service: recognitionService}
+	invoiceRouter.Handle("/{id:[0-9]+}/revenue_schedule", withPermissions(recognitionHandlers.GetSchedule, rbac.Finance, rbac.Sales)).Methods("GET")
+	invoiceRouter.Handle("/{id:[0-9]+}/revenue_schedule", withPermissions(recognitionHandlers.SetSchedule, rbac.Finance)).Methods("PUT")
+	invoiceRouter.Handle("/{id:[0-9]+}/revenue_schedule", withPermissions(recognitionHandlers.DeleteSchedule, rbac.Finance)).Methods("DELETE")
+	recognitionRouter := router.PathPrefix("/revenue_recognition").Subrouter()
+	recognitionRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	recognition_handlers.RegisterRoutes(recognitionRouter, recognitionService)
+
+	// Invoice disputes: raised by finance and sales for a customer, resolved by finance. Open
+	// disputes hold the invoice's late fees and installment reminders
+	disputeService := disputes.NewService(&dispute_handlers.DBDisputeStore{DB: db})
+	disputeHandlers := &dispute_handlers.DisputeHandler{Service: disputeService}
+	invoiceRouter.Handle("/{id:[0-9]+}/disputes", withPermissions(disputeHandlers.RaiseDispute, rbac.Finance, rbac.Sales)).Methods("POST")
+	invoiceRouter.Handle("/{id:[0-9]+}/disputes", withPermissions(disputeHandlers.ListInvoiceDisputes, rbac.Finance, rbac.Sales)).Methods("GET")
+	disputeRouter := router.PathPrefix("/disputes").Subrouter()
+	disputeRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	dispute_handlers.RegisterRoutes(disputeRouter, disputeService)
+
+	// Late fees on overdue invoices: rules and manual runs for finance; customer statements
+	// list the penalty invoices with the rest of the customer's account
+	lateFeeService := latefees.NewService(&late_fee_handlers.DBLateFeeStore{DB: db})
+	lateFeeRouter := router.PathPrefix("/late_fees").Subrouter()
+	lateFeeRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	late_fee_handlers.RegisterRoutes(lateFeeRouter, lateFeeService)
+	lateFeeHandlers := &late_fee_handlers.LateFeeHandler{Service: lateFeeService}
+	customerRouter.Handle("/{id:[0-9]+}/statement", withPermissions(lateFeeHandlers.Statement, rbac.Finance, rbac.Sales)).Methods("GET")
+
+	// Statutory payroll deductions: configuration, per-employee records and remittance reports
+	// are for finance. Records also carry the employee's benefit elections in force.
+	benefitService := benefits.NewService(&benefit_handlers.DBBenefitStore{DB: db})
+	statutoryService := statutory.NewService(&statutory_handlers.DBStatutoryStore{DB: db})
+	statutoryService.Benefits = benefitService
+	statutoryRouter := router.PathPrefix("/payroll/statutory").Subrouter()
+	statutoryRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	statutory_handlers.RegisterRoutes(statutoryRouter, statutoryService)
+
+	// HR defines benefit plans and enrollment windows; employees elect their own benefits
+	// while a window is open, and HR and finance read the cost report
+	benefitHandler := &benefit_handlers.BenefitHandler{Service: benefitService, Access: access}
+	router.Handle("/benefits/plans", middleware.JWTAuth(http.HandlerFunc(benefitHandler.ListPlans))).Methods("GET")
+	router.Handle("/benefits/plans", withPermissions(benefitHandler.CreatePlan, benefit_handlers.HRPermissions...)).Methods("POST")
+	router.Handle("/benefits/plans/{id:[0-9]+}", withPermissions(benefitHandler.UpdatePlan, benefit_handlers.HRPermissions...)).Methods("PUT")
+	router.Handle("/benefits/windows", middleware.JWTAuth(http.HandlerFunc(benefitHandler.ListWindows))).Methods("GET")
+	router.Handle("/benefits/windows", withPermissions(benefitHandler.CreateWindow, benefit_handlers.HRPermissions...)).Methods("POST")
+	router.Handle("/benefits/windows/{id:[0-9]+}/elections", middleware.JWTAuth(http.HandlerFunc(benefitHandler.Elect))).Methods("POST")
+	router.Handle("/benefits/elections/mine", middleware.JWTAuth(http.HandlerFunc(benefitHandler.ListMyElections))).Methods("GET")
+	router.Handle("/benefits/elections", withPermissions(benefitHandler.ListElections, benefit_handlers.HRPermissions...)).Methods("GET")
+	router.Handle("/benefits/reports/cost", withPermissions(benefitHandler.CostReport, rbac.HR, rbac.Finance)).Methods("GET")
+
+	// HR drafts the final settlement of employees who leave and records their exit
+	// interview; finance reads settlements and approves them, which posts the payable
+	settlementHandler := &settlement_handlers.SettlementHandler{Service: settlements.NewService(
+		&settlement_handlers.DBFinalSettlementStore{DB: db}, leave.NewService(db, jobRunner), cfg.Settlements.EncashableLeave)}
+	router.Handle("/final_settlements", withPermissions(settlementHandler.CreateSettlement, rbac.HR)).Methods("POST")
+	router.Handle("/final_settlements", withPermissions(settlementHandler.ListSettlements, rbac.HR, rbac.Finance)).Methods("GET")
+	router.Handle("/final_settlements/{id:[0-9]+}", withPermissions(settlementHandler.GetSettlement, rbac.HR, rbac.Finance)).Methods("GET")
+	router.Handle("/final_settlements/{id:[0-9]+}", withPermissions(settlementHandler.RecalculateSettlement, rbac.HR)).Methods("PUT")
+	router.Handle("/final_settlements/{id:[0-9]+}/exit_interview", withPermissions(settlementHandler.RecordExitInterview, rbac.HR)).Methods("PUT")
+	router.Handle("/final_settlements/{id:[0-9]+}/cancel", withPermissions(settlementHandler.CancelSettlement, rbac.HR)).Methods("POST")
+	router.Handle("/final_settlements/{id:[0-9]+}/approve", withPermissions(settlementHandler.ApproveSettlement, rbac.Finance)).Methods("POST")
+
+	// HR keeps the employee profiles; attendance and leave are only accepted for users that
+	// have one
+	employeeRouter := router.PathPrefix("/employees").Subrouter()
+	employeeRouter.Use(middleware.JWTAuth, access.Require(rbac.HR))
+	employee_handlers.RegisterRoutes(employeeRouter, &employee_handlers.EmployeeHandler{
+		Service: employees.NewService(&employee_handlers.DBEmployeeStore{DB: db}),
+	})
+	employeeRouter.HandleFunc("/{id:[0-9]+}/restore", trashHandler.Restore("employees")).Methods("POST")
+
+	// The org chart is drawn from who each employee reports to; every signed-in user can
+	// read it and HR sets the reporting lines
+	orgChartHandler := &org_chart_handlers.OrgChartHandler{Service: orgchart.NewService(&org_chart_handlers.DBOrgChartStore{DB: db})}
+	router.Handle("/org-chart", middleware.JWTAuth(http.HandlerFunc(orgChartHandler.GetOrgChart))).Methods("GET")
+	router.Handle("/users/{id:[0-9]+}/manager", withPermissions(orgChartHandler.SetManager, rbac.HR)).Methods("PUT")
+
+	// HR and corporate planners plan employees' hours on projects and compare them with the
+	// hours available after approved leave
+	planningRouter := router.PathPrefix("/planning").Subrouter()
+	planningRouter.Use(middleware.JWTAuth, access.Require(rbac.HR, rbac.Corporate))
+	capacity_handlers.RegisterRoutes(planningRouter, &capacity_handlers.CapacityHandler{
+		Service: capacity.NewService(&capacity_handlers.DBCapacityStore{DB: db}, cfg.Capacity.HoursPerDay, cfg.Capacity.UnderUtilization),
+	})
+
+	// Initialize product handlers and routes
+	productStore := product_handlers.NewDBProductStore(db)
+	priceUpdateHandlers := &product_handlers.PriceUpdateHandlers{Store: productStore}
+	router.Handle("/products/price-update", withPermissions(priceUpdateHandlers.PriceUpdate, rbac.Sales)).Methods("POST")
+	// Products, warehouses and stock levels are kept by sales and purchasing; their handlers
+	// register full paths, so the subrouter matches on the path prefix
+	inventoryRouter := router.MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return strings.HasPrefix(r.URL.Path, "/products") || strings.HasPrefix(r.URL.Path, "/warehouses") ||
+			strings.HasPrefix(r.URL.Path, "/stock")
+	}).Subrouter()
+	inventoryRouter.Use(middleware.JWTAuth, access.Require(rbac.Sales, rbac.Purchase))
+	productHandlers := &product_handlers.ProductHandlers{ProductStore: productStore}
+	productHandlers.RegisterRoutes(inventoryRouter)
+	inventoryRouter.HandleFunc("/products/{id:[0-9]+}/restore", trashHandler.Restore("products")).Methods("POST")
+	// Bundles are products made of other products; selling one reserves and ships its
+	// components
+	bundleStore := &bundle_handlers.DBBundleStore{DB: db}
+	bundleHandler := &bundle_handlers.BundleHandler{Service: bundles.NewService(bundleStore)}
+	bundleHandler.RegisterRoutes(inventoryRouter)
+
+	// Initialize product image handlers and routes; files are kept in the attachment backend
+	fileStorage, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal("Failed to configure storage:", err)
+	}
+	// Uploads are scanned for malware when a scanner is configured; flagged files are kept in
+	// a private quarantine directory that admins review
+	quarantineStore := &quarantine_handlers.DBQuarantineStore{DB: db}
+	antivirusService, err := antivirus.New(cfg.Antivirus, quarantineStore)
+	if err != nil {
+		log.Fatal("Failed to configure antivirus:", err)
+	}
+	quarantineRouter := router.PathPrefix("/quarantine").Subrouter()
+	quarantineRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	quarantine_handlers.RegisterRoutes(quarantineRouter, quarantineStore, &storage.LocalStorage{Dir: cfg.Antivirus.QuarantineDir})
+	productImageHandlers := &product_handlers.ProductImageHandlers{
+		ImageStore:    &product_handlers.DBProductImageStore{DB: db},
+		ProductStore:  productStore,
+		Storage:       fileStorage,
+		MaxUploadSize: cfg.Storage.MaxUploadSize,
+		Antivirus:     antivirusService,
+	}
+	productImageHandlers.RegisterRoutes(inventoryRouter)
+
+	// Supporting documents of manual journal entries are kept in the attachment backend too
+	journalAttachments := &general_ledger_handlers.JournalEntryHandler{Store: journalEntryStore, Storage: fileStorage, MaxUploadSize: cfg.Storage.MaxUploadSize,
+		Antivirus: antivirusService}
+	journalEntryRouter.HandleFunc("/{id:[0-9]+}/attachment", journalAttachments.UploadAttachment).Methods("POST")
+	if cfg.Storage.Driver == "local" && strings.HasPrefix(cfg.Storage.BaseURL, "/") {
+		prefix := strings.TrimRight(cfg.Storage.BaseURL, "/") + "/"
+		router.PathPrefix(prefix).Handler(http.StripPrefix(prefix, fileServer(cfg.Storage.Dir))).Methods("GET")
+	}
+
+	// Disciplinary and grievance cases are confidential: only roles that hold the HR
+	// permission themselves may use them, administrators included, and their documents are
+	// kept apart from the attachment backend
+	hrCaseRouter := router.PathPrefix("/hr_cases").Subrouter()
+	hrCaseRouter.Use(middleware.JWTAuth, access.RequireExplicit(rbac.HR))
+	hr_case_handlers.RegisterRoutes(hrCaseRouter, &hr_case_handlers.HRCaseHandler{
+		Service:       hrcases.NewService(&hr_case_handlers.DBHRCaseStore{DB: db}),
+		Files:         &storage.LocalStorage{Dir: cfg.HRCases.Dir},
+		MaxUploadSize: cfg.Storage.MaxUploadSize,
+		Antivirus:     antivirusService,
+	})
+
+	// Initialize stock handlers and routes
+	stockStore := stock_handlers.NewDBStockStore(db)
+	stockHandlers := stock_handlers.NewStockHandlers(stockStore)
+	stockHandlers.RegisterRoutes(inventoryRouter)
+	alertHandlers := &stock_handlers.AlertHandlers{Service: inventory.NewAlertService(&stock_handlers.DBStockAlertStore{DB: db})}
+	inventoryRouter.HandleFunc("/stock/alerts", alertHandlers.ListStockAlerts).Methods("GET")
+	putawayHandlers := &stock_handlers.PutawayHandlers{
+		Service: inventory.NewPutawayService(&stock_handlers.DBPutawayStore{DB: db}, cfg.Putaway),
+	}
+	putawayHandlers.RegisterRoutes(inventoryRouter)
+	policyHandlers := &stock_handlers.PolicyHandlers{Service: inventory.NewPolicyService(&stock_handlers.DBStockPolicyStore{DB: db})}
+	policyHandlers.RegisterRoutes(inventoryRouter)
+	pickHandlers := &stock_handlers.PickHandlers{Service: inventory.NewPickService(&stock_handlers.DBPickStore{DB: db})}
+	pickHandlers.RegisterRoutes(inventoryRouter)
+	snapshotHandlers := &stock_handlers.SnapshotHandlers{
+		Service: inventory.NewSnapshotService(&stock_handlers.DBStockSnapshotStore{DB: db}),
+	}
+	snapshotHandlers.RegisterRoutes(inventoryRouter)
+
+	// Initialize warehouse handlers and routes
+	warehouseStore := &warehouse_handlers.DBWarehouseStore{DB: db}
+	warehouseHandlers := &warehouse_handlers.WarehouseHandlers{WarehouseStore: warehouseStore}
+	warehouseHandlers.RegisterRoutes(inventoryRouter)
+	inventoryRouter.HandleFunc("/warehouses/{id:[0-9]+}/restore", trashHandler.Restore("warehouses")).Methods("POST")
+
+	// Transfers between warehouses are approved by an admin; stock leaves the source when a
+	// transfer is dispatched by sales or purchasing and reaches the destination when it is
+	// received
+	transferHandlers := stock_handlers.NewTransferHandlers(&stock_handlers.DBStockTransferStore{DB: db})
+	transferRouter := router.PathPrefix("/stock/transfers").Subrouter()
+	transferRouter.Handle("", middleware.JWTAuth(http.HandlerFunc(transferHandlers.RequestTransfer))).Methods("POST")
+	transferRouter.Handle("", middleware.JWTAuth(http.HandlerFunc(transferHandlers.ListTransfers))).Methods("GET")
+	transferRouter.Handle("/{id:[0-9]+}", middleware.JWTAuth(http.HandlerFunc(transferHandlers.GetTransfer))).Methods("GET")
+	transferRouter.Handle("/{id:[0-9]+}/approve", withPermissions(transferHandlers.ApproveTransfer, rbac.All)).Methods("POST")
+	transferRouter.Handle("/{id:[0-9]+}/dispatch", withPermissions(transferHandlers.DispatchTransfer, rbac.Sales, rbac.Purchase)).Methods("POST")
+	transferRouter.Handle("/{id:[0-9]+}/receive", withPermissions(transferHandlers.ReceiveTransfer, rbac.Sales, rbac.Purchase)).Methods("POST")
+	transferRouter.Handle("/{id:[0-9]+}/cancel", withPermissions(transferHandlers.CancelTransfer, rbac.Sales, rbac.Purchase)).Methods("POST")
+	// Sales and purchasing, who keep stock levels, may also move stock between warehouses at
+	// once, without approval; every move is kept in the stock movement history
+	router.Handle("/stock/transfer", withPermissions(transferHandlers.MoveStock, rbac.Sales, rbac.Purchase)).Methods("POST")
+	router.Handle("/stock/movements", withPermissions(transferHandlers.ListStockMovements, rbac.Sales, rbac.Purchase)).Methods("GET")
+
+	// Sales orders are drafted, confirmed once their stock is available (reserving it),
+	// fulfilled when shipped and cancelled until then
+	salesOrderRouter := router.PathPrefix("/sales_orders").Subrouter()
+	salesOrderRouter.Use(middleware.JWTAuth, access.Require(rbac.Sales))
+	salesOrderService := salesorders.NewService(&sales_order_handlers.DBSalesOrderStore{DB: db}, stockStore, bundleStore)
+	sales_order_handlers.RegisterRoutes(salesOrderRouter, salesOrderService)
+
+	// Standing orders repeat a customer's order on a schedule; a draft sales order is
+	// generated for each delivery, unless it is skipped or the standing order is paused
+	standingOrderRouter := router.PathPrefix("/standing_orders").Subrouter()
+	standingOrderRouter.Use(middleware.JWTAuth, access.Require(rbac.Sales))
+	standing_order_handlers.RegisterRoutes(standingOrderRouter,
+		standingorders.NewService(&standing_order_handlers.DBStandingOrderStore{DB: db}, salesOrderService))
+
+	// Expense claims are checked against the expense policies when submitted; approvers see
+	// the violations, and hard violations block reimbursement until overridden
+	expenseHandlers := &expense_handlers.ExpenseHandlers{Service: expenses.NewService(
+		&expense_handlers.DBExpenseStore{DB: db}, cfg.Expenses.Approvers, cfg.Expenses.OverrideRoles)}
+	expenseRouter := router.PathPrefix("/expenses").Subrouter()
+	expenseRouter.Handle("/policies", middleware.JWTAuth(http.HandlerFunc(expenseHandlers.ListPolicies))).Methods("GET")
+	expenseRouter.Handle("/policies/{category}", withPermissions(expenseHandlers.UpdatePolicy, rbac.All)).Methods("PUT")
+	expenseRouter.Handle("/rates/mileage", middleware.JWTAuth(http.HandlerFunc(expenseHandlers.ListMileageRates))).Methods("GET")
+	expenseRouter.Handle("/rates/mileage/{vehicle_type}", withPermissions(expenseHandlers.UpdateMileageRate, rbac.All)).Methods("PUT")
+	expenseRouter.Handle("/rates/mileage/{vehicle_type}", withPermissions(expenseHandlers.DeleteMileageRate, rbac.All)).Methods("DELETE")
+	expenseRouter.Handle("/rates/per_diem", middleware.JWTAuth(http.HandlerFunc(expenseHandlers.ListPerDiemRates))).Methods("GET")
+	expenseRouter.Handle("/rates/per_diem/{destination}", withPermissions(expenseHandlers.UpdatePerDiemRate, rbac.All)).Methods("PUT")
+	expenseRouter.Handle("/rates/per_diem/{destination}", withPermissions(expenseHandlers.DeletePerDiemRate, rbac.All)).Methods("DELETE")
+	expenseRouter.Handle("", middleware.JWTAuth(http.HandlerFunc(expenseHandlers.SubmitClaim))).Methods("POST")
+	expenseRouter.Handle("", withRoles(expenseHandlers.ListClaims, cfg.Expenses.Approvers...)).Methods("GET")
+	expenseRouter.Handle("/mine", middleware.JWTAuth(http.HandlerFunc(expenseHandlers.ListMyClaims))).Methods("GET")
+	expenseRouter.Handle("/{id:[0-9]+}", middleware.JWTAuth(http.HandlerFunc(expenseHandlers.GetClaim))).Methods("GET")
+	expenseRouter.Handle("/{id:[0-9]+}/approve", withRoles(expenseHandlers.ApproveClaim, cfg.Expenses.Approvers...)).Methods("POST")
+	expenseRouter.Handle("/{id:[0-9]+}/reject", withRoles(expenseHandlers.RejectClaim, cfg.Expenses.Approvers...)).Methods("POST")
+	expenseRouter.Handle("/{id:[0-9]+}/override", middleware.JWTAuth(http.HandlerFunc(expenseHandlers.OverrideClaim))).Methods("POST")
+	expenseRouter.Handle("/{id:[0-9]+}/reimburse", withRoles(expenseHandlers.ReimburseClaim, cfg.Expenses.Approvers...)).Methods("POST")
+
+	// Point of sale: retail counters record paid sales in one call and reconcile their registers
+	posHandlers := &pos_handlers.POSHandlers{Service: pos.NewService(&pos_handlers.DBPOSStore{DB: db})}
+	router.Handle("/pos/sales", withPermissions(posHandlers.RecordSale, rbac.Sales)).Methods("POST")
+	registerStore := &pos_handlers.DBRegisterStore{DB: db, Accounts: cfg.Registers}
+	registerHandlers := &pos_handlers.RegisterHandlers{Service: pos.NewRegisterService(registerStore)}
+	registerRouter := router.PathPrefix("/pos/registers/{register}").Subrouter()
+	registerRouter.Handle("/sessions", withPermissions(registerHandlers.OpenSession, rbac.Sales)).Methods("POST")
+	registerRouter.Handle("/session", withPermissions(registerHandlers.GetOpenSession, rbac.Sales)).Methods("GET")
+	registerRouter.Handle("/session/movements", withPermissions(registerHandlers.AddCashMovement, rbac.Sales)).Methods("POST")
+	registerRouter.Handle("/session/close", withPermissions(registerHandlers.CloseSession, rbac.Sales)).Methods("POST")
+	registerRouter.Handle("/z_report", withPermissions(registerHandlers.GetZReport, rbac.Finance, rbac.Sales)).Methods("GET")
+
+	// Documents are sent to customers for acceptance through signed public links; the signing
+	// page needs no account and the signature images are kept in the attachment backend
+	signatureHandlers := &signature_handlers.SignatureHandlers{Service: esign.NewService(&signature_handlers.DBSignatureStore{DB: db}, fileStorage, cfg.Signature)}
+	invoiceRouter.Handle("/{id:[0-9]+}/signature_requests", withPermissions(signatureHandlers.CreateSignatureRequest("invoice"), rbac.Finance, rbac.Sales)).Methods("POST")
+	invoiceRouter.Handle("/{id:[0-9]+}/signature_requests", middleware.JWTAuth(signatureHandlers.ListSignatureRequests("invoice"))).Methods("GET")
+	router.Handle("/signature_requests/{id:[0-9]+}", middleware.JWTAuth(http.HandlerFunc(signatureHandlers.GetSignatureRequest))).Methods("GET")
+	router.HandleFunc("/public/signatures/{token}", signatureHandlers.GetPublicSignature).Methods("GET")
+	router.HandleFunc("/public/signatures/{token}", signatureHandlers.AcceptPublicSignature).Methods("POST")
+
+	// Initialize shipment handlers and routes; labels are kept in the attachment backend
+	carriers, err := shipping.New(cfg.Shipping)
+	if err != nil {
+		log.Fatal("Failed to configure shipping carriers:", err)
+	}
+	shipmentStore := &shipment_handlers.DBShipmentStore{DB: db}
+	shipmentRouter := router.PathPrefix("/shipments").Subrouter()
+	shipmentRouter.Use(middleware.JWTAuth, access.Require(rbac.Sales, rbac.Purchase))
+	shipment_handlers.RegisterRoutes(shipmentRouter, shipmentStore, carriers, fileStorage)
+
+	// Shipments without a carrier are delivered by the company's drivers: sales staff group
+	// them into routes per vehicle for a day, and drivers record the proof of delivery at
+	// each stop from the driver app
+	deliveryRouteHandler := &delivery_route_handlers.DeliveryRouteHandler{
+		Service:       deliveryroutes.NewService(&delivery_route_handlers.DBDeliveryRouteStore{DB: db}, fileStorage),
+		Access:        access,
+		MaxUploadSize: cfg.Storage.MaxUploadSize,
+	}
+	dispatchPermissions := delivery_route_handlers.DispatchPermissions
+	router.Handle("/delivery_routes", withPermissions(deliveryRouteHandler.PlanRoute, dispatchPermissions...)).Methods("POST")
+	router.Handle("/delivery_routes", withPermissions(deliveryRouteHandler.ListRoutes, dispatchPermissions...)).Methods("GET")
+	router.Handle("/delivery_routes/unrouted", withPermissions(deliveryRouteHandler.ListUnrouted, dispatchPermissions...)).Methods("GET")
+	router.Handle("/delivery_routes/mine", middleware.JWTAuth(http.HandlerFunc(deliveryRouteHandler.ListMyRoutes))).Methods("GET")
+	router.Handle("/delivery_routes/{id:[0-9]+}", middleware.JWTAuth(http.HandlerFunc(deliveryRouteHandler.GetRoute))).Methods("GET")
+	router.Handle("/delivery_routes/{id:[0-9]+}", withPermissions(deliveryRouteHandler.DeleteRoute, dispatchPermissions...)).Methods("DELETE")
+	router.Handle("/delivery_routes/{id:[0-9]+}/stops", withPermissions(deliveryRouteHandler.AddStop, dispatchPermissions...)).Methods("POST")
+	router.Handle("/delivery_routes/{id:[0-9]+}/stops/{stop_id:[0-9]+}", withPermissions(deliveryRouteHandler.RemoveStop, dispatchPermissions...)).Methods("DELETE")
+	router.Handle("/delivery_routes/{id:[0-9]+}/optimize", withPermissions(deliveryRouteHandler.OptimizeRoute, dispatchPermissions...)).Methods("POST")
+	router.Handle("/delivery_routes/{id:[0-9]+}/sequence", withPermissions(deliveryRouteHandler.SequenceRoute, dispatchPermissions...)).Methods("PUT")
+	router.Handle("/delivery_routes/{id:[0-9]+}/dispatch", withPermissions(deliveryRouteHandler.DispatchRoute, dispatchPermissions...)).Methods("POST")
+	router.Handle("/delivery_routes/{id:[0-9]+}/stops/{stop_id:[0-9]+}/proof", middleware.JWTAuth(http.HandlerFunc(deliveryRouteHandler.RecordDelivery))).Methods("POST")
+	router.Handle("/delivery_routes/{id:[0-9]+}/stops/{stop_id:[0-9]+}/failure", middleware.JWTAuth(http.HandlerFunc(deliveryRouteHandler.RecordFailure))).Methods("POST")
+
+	// Initialize inbound webhook handlers and routes
+	// Module processors are registered here as integrations (payments, shipping, banking) are added
+	webhookStore := &webhook_handlers.DBWebhookStore{DB: db}
+	webhookProcessors := map[string]webhook_handlers.Processor{
+		"shipping": &shipment_handlers.TrackingProcessor{Store: shipmentStore, Carriers: carriers},
+	}
+	webhookRouter := router.PathPrefix("/webhooks").Subrouter()
+	webhook_handlers.RegisterInboundRoutes(webhookRouter, webhookStore, webhookProcessors)
+	webhookAdminRouter := router.PathPrefix("/webhooks").Subrouter()
+	webhookAdminRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	webhook_handlers.RegisterRoutes(webhookAdminRouter, webhookStore, webhookProcessors)
+
+	// Initialize API key management routes (administrators only)
+	apiKeyStore := &api_key_handlers.DBAPIKeyStore{DB: db}
+	apiKeyRouter := router.PathPrefix("/api_keys").Subrouter()
+	apiKeyRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	api_key_handlers.RegisterRoutes(apiKeyRouter, apiKeyStore)
+
+	// Initialize the public catalog API, authenticated by API key with its own rate limits.
+	// A key's limit is shared by all external routes it calls.
+	apiKeyLimiter := middleware.NewRateLimiter()
+	catalogRouter := router.PathPrefix("/catalog").Subrouter()
+	catalogRouter.Use(middleware.APIKeyAuth(apiKeyStore, apiKeyLimiter, cfg.Catalog.RateLimit, models.ScopeCatalogRead))
+	catalog_handlers.RegisterRoutes(catalogRouter, &catalog_handlers.CatalogHandler{
+		Store:              &catalog_handlers.DBCatalogStore{DB: db},
+		ProductsMaxAge:     cfg.Catalog.ProductsMaxAge,
+		AvailabilityMaxAge: cfg.Catalog.AvailabilityMaxAge,
+	})
+
+	// Initialize e-commerce order ingestion, authenticated by API key
+	ecommerceRouter := router.PathPrefix("/integrations/ecommerce").Subrouter()
+	ecommerceRouter.Use(middleware.APIKeyAuth(apiKeyStore, apiKeyLimiter, cfg.Catalog.RateLimit, models.ScopeOrdersWrite))
+	ecommerce_handlers.RegisterRoutes(ecommerceRouter, &ecommerce_handlers.DBEcommerceOrderStore{DB: db})
+
+	// Initialize the signed-in user's notification center
+	notificationRouter := router.PathPrefix("/notifications").Subrouter()
+	notificationRouter.Use(middleware.JWTAuth)
+	notification_handlers.RegisterRoutes(notificationRouter, &notification_handlers.DBNotificationStore{DB: db})
+
+	// Initialize the signed-in user's preferences
+	meRouter := router.PathPrefix("/me").Subrouter()
+	meRouter.Use(middleware.JWTAuth)
+	preference_handlers.RegisterRoutes(meRouter, &preference_handlers.DBPreferenceStore{DB: db})
+	feature_flag_handlers.RegisterUserRoutes(meRouter, featureFlags)
+
+	// Initialize feature flag management routes (administrators only)
+	featureFlagRouter := router.PathPrefix("/feature_flags").Subrouter()
+	featureFlagRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	feature_flag_handlers.RegisterRoutes(featureFlagRouter, featureFlags)
+
+	// Initialize organization-wide settings (administrators only); the service is shared
+	// with the modules that print the company details
+	settingsService := settings.NewService(&settings_handlers.DBSettingStore{DB: db}, cfg.Settings.CacheTTL)
+	settingsRouter := router.PathPrefix("/settings").Subrouter()
+	settingsRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	settings_handlers.RegisterRoutes(settingsRouter, settingsService)
+
+	// Email templates can be edited by admins and finance; emails are queued in the outbox
+	emailTemplateHandler := &email_template_handlers.EmailTemplateHandler{
+		Templates: emailTemplates,
+		Send: func(ctx context.Context, msg mailer.Message) error {
+			return outbox.Enqueue(ctx, db, outbox.KindEmail, msg)
+		},
+	}
+	emailTemplateRouter := router.PathPrefix("/email_templates").Subrouter()
+	emailTemplateRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	email_template_handlers.RegisterRoutes(emailTemplateRouter, emailTemplateHandler)
+
+	// Employees download their own payslips and yearly pay summaries; HR and payroll staff
+	// read everyone's, list payslips and email the notices after a payroll run
+	payslipHandler := &payslip_handlers.PayslipHandler{
+		Service: payslips.NewService(&payslip_handlers.DBPayslipStore{DB: db}, emailTemplateHandler.Templates,
+			emailTemplateHandler.Send, cfg.Payslips.BaseURL),
+		Access:  access,
+		Company: settingsService,
+	}
+	router.Handle("/payroll/payslips", withPermissions(payslipHandler.ListPayslips, payslip_handlers.PayrollPermissions...)).Methods("GET")
+	router.Handle("/payroll/payslips/mine", middleware.JWTAuth(http.HandlerFunc(payslipHandler.ListMyPayslips))).Methods("GET")
+	router.Handle("/payroll/payslips/emails", withPermissions(payslipHandler.EmailPayslips, payslip_handlers.PayrollPermissions...)).Methods("POST")
+	router.Handle("/payroll/payslips/{id:[0-9]+}/pdf", middleware.JWTAuth(http.HandlerFunc(payslipHandler.GetPayslipPDF))).Methods("GET")
+	router.Handle("/employees/{id:[0-9]+}/payroll_summary", middleware.JWTAuth(http.HandlerFunc(payslipHandler.GetPayrollSummary))).Methods("GET")
+
+	// Monthly payroll from attendance and approved leave: finance sets pay rates and runs a
+	// month, posting it to the ledger; HR and finance read each employee's pay
+	payrollHandler := &payroll_handlers.PayrollHandler{
+		Payroll: payroll.NewService(&payroll_handlers.DBPayrollStore{DB: db}, cfg.Payroll),
+	}
+	router.Handle("/payroll/rates", withPermissions(payrollHandler.ListRates, rbac.Finance)).Methods("GET")
+	router.Handle("/payroll/rates/{grade}", withPermissions(payrollHandler.SaveRate, rbac.Finance)).Methods("PUT")
+	router.Handle("/payroll/rates/{grade}", withPermissions(payrollHandler.DeleteRate, rbac.Finance)).Methods("DELETE")
+	router.Handle("/payroll/run", withPermissions(payrollHandler.RunPayroll, rbac.Finance)).Methods("POST")
+	router.Handle("/payroll/{employee_id:[0-9]+}/{period}", withPermissions(payrollHandler.GetPayrollRecord, payslip_handlers.PayrollPermissions...)).Methods("GET")
+
+	// Printed documents are numbered per series and carry the company header from settings
+	documentNumbers := &documents.Numbers{DB: db}
+	shipment_handlers.RegisterDeliveryNoteRoutes(shipmentRouter, &shipment_handlers.DeliveryNoteHandler{
+		Store:   shipmentStore,
+		Items:   shipmentStore,
+		Numbers: documentNumbers,
+		Company: settingsService,
+	})
+
+	// Initialize backups (administrators only); files go to a private directory, never to
+	// the publicly served attachment storage
+	backupService := backup.NewService(db, &storage.LocalStorage{Dir: cfg.Backup.Dir}, jobRunner, cfg.Backup.Keep)
+	backupRouter := router.PathPrefix("/backups").Subrouter()
+	backupRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	backup_handlers.RegisterRoutes(backupRouter, backupService, jobStore)
+
+	// Initialize data retention (administrators only); purged records are reported per class
+	retentionRouter := router.PathPrefix("/retention").Subrouter()
+	retentionRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	retention_handlers.RegisterRoutes(retentionRouter, retention.NewService(db, jobRunner, cfg.Retention.FinancialMinimum))
+
+	// Employees request leave against their balance and read it; HR approves or rejects
+	// requests and keeps leave policies, balance adjustments and year-end processing
+	leaveStore := &leave_handlers.DBLeaveStore{DB: db}
+	router.Handle("/leave/requests", middleware.JWTAuth(leave_handlers.CreateLeaveHandler(leaveStore, access))).Methods("POST")
+	router.Handle("/leave/requests/status", withPermissions(leave_handlers.UpdateLeaveStatusHandler(leaveStore), rbac.HR)).Methods("PUT")
+	leaveRouter := router.PathPrefix("/leave").Subrouter()
+	leaveRouter.Use(middleware.JWTAuth, access.Require(rbac.HR))
+	leaveService := leave.NewService(db, jobRunner)
+	leave_handlers.RegisterBalanceRoutes(leaveRouter, leaveService)
+	entitlementHandler := &leave_handlers.EntitlementHandler{Entitlements: leaveService, Access: access}
+	router.Handle("/leaves/balance", middleware.JWTAuth(http.HandlerFunc(entitlementHandler.GetBalance))).Methods("GET")
+
+	// Initialize suppliers with their advances and bills (accountants and administrators);
+	// advances are held as prepayments until bills of the supplier are offset against them
+	supplierRouter := router.PathPrefix("/suppliers").Subrouter()
+	supplierRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	supplierStore := &supplier_handlers.DBSupplierStore{DB: db}
+	supplier_handlers.RegisterRoutes(supplierRouter, suppliers.NewService(supplierStore))
+
+	// Purchase orders are raised and received by purchasing staff and approved by finance.
+	// Receiving goods adds them to the order's warehouse and drafts a pending payment in
+	// accounts payable, approved there like any other payment. Orders are cloned into new
+	// drafts and printed for their suppliers with the next purchase order number
+	purchasingService := purchasing.NewService(&purchase_order_handlers.DBPurchaseOrderStore{DB: db})
+	purchaseOrderHandler := &purchase_order_handlers.PurchaseOrderHandler{Service: purchasingService}
+	purchaseOrderDocuments := &purchase_order_handlers.DocumentHandler{
+		Service:    purchasingService,
+		Suppliers:  supplierStore,
+		Warehouses: warehouseStore,
+		Products:   productStore,
+		Numbers:    documentNumbers,
+		Company:    settingsService,
+	}
+	purchaseOrderRouter := router.PathPrefix("/purchase_orders").Subrouter()
+	purchaseOrderRouter.Handle("", withPermissions(purchaseOrderHandler.CreatePurchaseOrder, rbac.Purchase)).Methods("POST")
+	purchaseOrderRouter.Handle("", withPermissions(purchaseOrderHandler.ListPurchaseOrders, rbac.Purchase, rbac.Finance)).Methods("GET")
+	purchaseOrderRouter.Handle("/{id:[0-9]+}", withPermissions(purchaseOrderHandler.GetPurchaseOrder, rbac.Purchase, rbac.Finance)).Methods("GET")
+	purchaseOrderRouter.Handle("/{id:[0-9]+}/approve", withPermissions(purchaseOrderHandler.ApprovePurchaseOrder, rbac.Finance)).Methods("POST")
+	purchaseOrderRouter.Handle("/{id:[0-9]+}/receive", withPermissions(purchaseOrderHandler.ReceivePurchaseOrder, rbac.Purchase)).Methods("POST")
+	purchaseOrderRouter.Handle("/{id:[0-9]+}/close", withPermissions(purchaseOrderHandler.ClosePurchaseOrder, rbac.Purchase, rbac.Finance)).Methods("POST")
+	purchaseOrderRouter.Handle("/{id:[0-9]+}/clone", withPermissions(purchaseOrderHandler.ClonePurchaseOrder, rbac.Purchase)).Methods("POST")
+	purchaseOrderRouter.Handle("/{id:[0-9]+}/pdf", withPermissions(purchaseOrderDocuments.GetPurchaseOrderPDF, rbac.Purchase, rbac.Finance)).Methods("GET")
+
+	// Receipts of orders that require inspection are quarantined in quality holds. Purchasing
+	// staff inspect them, releasing the goods into stock or rejecting them onto a supplier
+	// return
+	qualityHandler := &quality_handlers.QualityHandler{Service: quality.NewService(&quality_handlers.DBQualityStore{DB: db})}
+	qualityRouter := router.PathPrefix("/quality").Subrouter()
+	qualityRouter.Handle("/holds", withPermissions(qualityHandler.ListHolds, rbac.Purchase)).Methods("GET")
+	qualityRouter.Handle("/holds/{id:[0-9]+}", withPermissions(qualityHandler.GetHold, rbac.Purchase)).Methods("GET")
+	qualityRouter.Handle("/holds/{id:[0-9]+}/inspection", withPermissions(qualityHandler.InspectHold, rbac.Purchase)).Methods("POST")
+
+	// Purchasing staff return goods to suppliers, which issues debit notes; finance records
+	// the refunds settling them
+	supplierReturnHandler := &supplier_return_handlers.SupplierReturnHandler{
+		Service: supplierreturns.NewService(&supplier_return_handlers.DBSupplierReturnStore{DB: db})}
+	supplierReturnRouter := router.PathPrefix("/supplier_returns").Subrouter()
+	supplierReturnRouter.Handle("", withPermissions(supplierReturnHandler.CreateReturn, rbac.Purchase)).Methods("POST")
+	supplierReturnRouter.Handle("", withPermissions(supplierReturnHandler.ListReturns, rbac.Purchase, rbac.Finance)).Methods("GET")
+	supplierReturnRouter.Handle("/{id:[0-9]+}", withPermissions(supplierReturnHandler.GetReturn, rbac.Purchase, rbac.Finance)).Methods("GET")
+	supplierReturnRouter.Handle("/{id:[0-9]+}/shipment", withPermissions(supplierReturnHandler.ShipReturn, rbac.Purchase)).Methods("POST")
+	supplierReturnRouter.Handle("/{id:[0-9]+}/credits", withPermissions(supplierReturnHandler.RecordCredit, rbac.Finance)).Methods("POST")
+
+	// Initialize cost center allocation of shared expenses (accountants and administrators)
+	allocationRouter := router.PathPrefix("/allocations").Subrouter()
+	allocationRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	allocation_handlers.RegisterRoutes(allocationRouter,
+		allocation.NewService(&allocation_handlers.DBAllocationStore{DB: db}, cfg.Allocation.Day))
+
+	// Initialize the data warehouse export (administrators only); extracts go to their own
+	// private directory
+	exportService := export.NewService(db, &storage.LocalStorage{Dir: cfg.Export.Dir}, jobRunner, cfg.Export.Tables, cfg.Export.Lag)
+	exportRouter := router.PathPrefix("/exports").Subrouter()
+	exportRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	export_handlers.RegisterRoutes(exportRouter, exportService, jobStore)
+
+	// Sandbox deployments for sales demos can be reset to a seed snapshot (administrators only)
+	if cfg.Sandbox.Enabled {
+		sandboxRouter := router.PathPrefix("/admin/sandbox").Subrouter()
+		sandboxRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+		sandbox_handlers.RegisterRoutes(sandboxRouter, sandbox.NewService(db, &storage.LocalStorage{Dir: cfg.Sandbox.Dir}))
+	}
+
+	// Initialize the GraphQL endpoint for nested reads; it is switched on with a feature flag,
+	// open to sales and finance, and the fields a caller can read depend on their permissions
+	graphSchema := graphql_handlers.NewSchema(graphql_handlers.Stores{
+		CustomerGraphStore: customerStore,
+		InvoiceGraphStore:  invoiceStore,
+		PaymentGraphStore:  accountsPayableStore,
+	}, cfg.GraphQL.MaxDepth, cfg.GraphQL.MaxComplexity)
+	graphqlRouter := router.PathPrefix("/graphql").Subrouter()
+	graphqlRouter.Use(middleware.JWTAuth, featureFlags.Require(features.GraphQLAPI), access.Require(rbac.Sales, rbac.Finance))
+	graphql_handlers.RegisterRoutes(graphqlRouter, graphSchema, access)
+
+	// Initialize report handlers and routes; financial reports are for finance and corporate,
+	// stock in transit for every signed-in user
+	router.Handle("/reports/stock_in_transit", middleware.JWTAuth(http.HandlerFunc(transferHandlers.GetInTransitReport))).Methods("GET")
+	reportStore := &report_handlers.DBReportStore{DB: db, ExpenseAccounts: cfg.Reports.ExpenseAccounts}
+	reportRouter := router.PathPrefix("/reports").Subrouter()
+	reportRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance, rbac.Corporate))
+	report_handlers.RegisterRoutes(reportRouter, reportStore, cfg.Reports.MaxStaleness, settingsService, cfg.Reports.DeadStockDays)
+	reportBuilderRouter := reportRouter.PathPrefix("/builder").Subrouter()
+	reportBuilderRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	report_builder_handlers.RegisterRoutes(reportBuilderRouter, &report_builder_handlers.DBReportDefinitionStore{DB: db},
+		reportbuilder.NewService(db, cfg.Reports.BuilderMaxRows))
+	accessLogHandler := &access_log_handlers.AccessLogHandler{Store: accessLogStore, Now: time.Now}
+	reportRouter.Handle("/access_log", withPermissions(accessLogHandler.GetAccessLog, rbac.All)).Methods("GET")
+
+	// Initialize KPI alert rules (administrators only) and their alert history, which the
+	// finance team can read too; the rules are evaluated by the scheduler
+	kpiService := kpi.NewService(&kpi_handlers.DBKPIStore{DB: db})
+	kpiRuleRouter := router.PathPrefix("/kpi_rules").Subrouter()
+	kpiRuleRouter.Use(middleware.JWTAuth, access.Require(rbac.All))
+	kpi_handlers.RegisterRoutes(kpiRuleRouter, kpiService)
+	kpiAlertRouter := router.PathPrefix("/kpi_alerts").Subrouter()
+	kpiAlertRouter.Use(middleware.JWTAuth, access.Require(rbac.Finance))
+	kpi_handlers.RegisterAlertRoutes(kpiAlertRouter, kpiService)
 
 	return router
 }
 
+// fileServer serves stored files from dir without listing directory contents.
+func fileServer(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			respond.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// withRoles requires a valid JWT whose role is one of the given roles. It is used where the
+// roles are configured, such as expense approvers; other routes require permissions.
+func withRoles(handler http.HandlerFunc, roles ...string) http.Handler {
+	return middleware.JWTAuth(middleware.RequireRole(roles...)(handler))
+}
+
 // Protected routes: requires JWT authentication
 // router.Handle("/dashboard", middleware.JWTAuth(http.HandlerFunc(dashboard.Dashboard))).Methods("GET")
 
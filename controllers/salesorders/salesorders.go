@@ -0,0 +1,183 @@
+// Package salesorders holds the rules of the sales order workflow: orders are drafted with
+// their lines, confirmed when the stock is there, fulfilled when shipped and cancelled until
+// then.
+package salesorders
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp/controllers/bundles"
+	"erp/models"
+)
+
+// stockPage is the number of stock entries read at a time when adding up a product's stock.
+const stockPage = 200
+
+// Service applies the sales order rules on top of a SalesOrderStore, checking availability
+// against a StockStore. Bundles are checked by their components.
+type Service struct {
+	Store   models.SalesOrderStore
+	Stock   models.StockStore
+	Bundles models.BundleStore
+	Now     func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a sales order service.
+func NewService(store models.SalesOrderStore, stock models.StockStore, bundles models.BundleStore) *Service {
+	return &Service{Store: store, Stock: stock, Bundles: bundles, Now: time.Now}
+}
+
+// Create checks an order and records it as a draft. Lines for the same product are merged.
+//
+// Parameters:
+//   - order: The order; CustomerID, OrderDate (today by default), Note and Lines (product,
+//     quantity and an optional unit price) are read. The ID, status and totals are set.
+//   - actor: Email of the user taking the order.
+//
+// Returns:
+//   - error: A validation error if the order is incomplete, or the store's error.
+func (s *Service) Create(ctx context.Context, order *models.SalesOrder, actor string) error {
+	switch {
+	case order.CustomerID <= 0:
+		return models.Invalid("customer_id is required")
+	case len(order.Lines) == 0:
+		return models.Invalid("a sales order needs at least one line")
+	}
+
+	merged := make([]models.SalesOrderLine, 0, len(order.Lines))
+	index := make(map[int]int, len(order.Lines))
+	for i, line := range order.Lines {
+		switch {
+		case line.ProductID <= 0:
+			return models.Invalid("line %d has no product", i+1)
+		case line.Quantity <= 0:
+			return models.Invalid("line %d must have a positive quantity", i+1)
+		case line.UnitPrice < 0:
+			return models.Invalid("line %d: unit_price cannot be negative", i+1)
+		}
+		if j, ok := index[line.ProductID]; ok {
+			if merged[j].UnitPrice != line.UnitPrice {
+				return models.Invalid("product %d is listed twice at different prices", line.ProductID)
+			}
+			merged[j].Quantity += line.Quantity
+			continue
+		}
+		index[line.ProductID] = len(merged)
+		merged = append(merged, models.SalesOrderLine{ProductID: line.ProductID, Quantity: line.Quantity,
+			UnitPrice: roundCents(line.UnitPrice)})
+	}
+
+	now := s.Now()
+	if order.OrderDate.IsZero() {
+		order.OrderDate = now
+	}
+	order.OrderDate = time.Date(order.OrderDate.Year(), order.OrderDate.Month(), order.OrderDate.Day(), 0, 0, 0, 0, time.UTC)
+	order.Lines = merged
+	order.Note = strings.TrimSpace(order.Note)
+	order.Status = models.SalesOrderDraft
+	order.CreatedBy, order.CreatedAt = actor, &now
+	if err := s.Store.CreateSalesOrder(ctx, order); err != nil {
+		return err
+	}
+	Total(order)
+	return nil
+}
+
+// Get returns an order with its lines.
+func (s *Service) Get(ctx context.Context, id int) (*models.SalesOrder, error) {
+	return s.Store.GetSalesOrder(ctx, id)
+}
+
+// List returns the orders in a status, or all of them if status is empty, optionally of
+// one customer.
+func (s *Service) List(ctx context.Context, status string, customerID int) ([]models.SalesOrder, error) {
+	switch status {
+	case "", models.SalesOrderDraft, models.SalesOrderConfirmed, models.SalesOrderFulfilled, models.SalesOrderCancelled:
+		return s.Store.ListSalesOrders(ctx, status, customerID)
+	}
+	return nil, models.Invalid("unknown sales order status %q", status)
+}
+
+// Confirm checks that every line of a draft order is in stock, across all warehouses, and
+// confirms it, reserving the stock. A bundle line needs its components in stock.
+//
+// Returns:
+//   - *models.SalesOrder: The confirmed order.
+//   - error: models.ErrNotFound if the order does not exist, a conflict if it is not a draft
+//     or a product is not in stock in the quantity ordered, or the store's error.
+func (s *Service) Confirm(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	order, err := s.Store.GetSalesOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.SalesOrderDraft {
+		return nil, models.Conflict("sales order %d is %s", id, order.Status)
+	}
+	ordered := make(map[int]int, len(order.Lines))
+	for _, line := range order.Lines {
+		ordered[line.ProductID] += line.Quantity
+	}
+	found, err := s.Bundles.Bundles(ctx, bundles.ProductIDs(ordered))
+	if err != nil {
+		return nil, err
+	}
+	demand := bundles.Expand(ordered, found)
+	for _, productID := range bundles.ProductIDs(demand) {
+		available, err := s.OnHand(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		if available < demand[productID] {
+			return nil, models.Conflict("product %d has %d in stock but %d are ordered", productID, available, demand[productID])
+		}
+	}
+	return s.Store.ConfirmSalesOrder(ctx, id, actor)
+}
+
+// Fulfill ships a confirmed order: its stock is taken and its reservations are fulfilled.
+func (s *Service) Fulfill(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	return s.Store.FulfillSalesOrder(ctx, id, actor)
+}
+
+// Cancel cancels an order that has not been fulfilled and releases its reservations.
+func (s *Service) Cancel(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	return s.Store.CancelSalesOrder(ctx, id, actor)
+}
+
+// OnHand adds up a product's stock in every warehouse.
+func (s *Service) OnHand(ctx context.Context, productID int) (int, error) {
+	query := models.ListQuery{Page: 1, Limit: stockPage, Filters: map[string]string{"product_id": strconv.Itoa(productID)}}
+	quantity := 0
+	for {
+		entries, total, err := s.Stock.ListStock(ctx, query)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			quantity += entry.Quantity
+		}
+		if len(entries) == 0 || query.Page*query.Limit >= total {
+			return quantity, nil
+		}
+		query.Page++
+	}
+}
+
+// Total sets the amount of every line and the order's total.
+func Total(order *models.SalesOrder) {
+	order.Total = 0
+	for i := range order.Lines {
+		line := &order.Lines[i]
+		line.Amount = roundCents(float64(line.Quantity) * line.UnitPrice)
+		order.Total = roundCents(order.Total + line.Amount)
+	}
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
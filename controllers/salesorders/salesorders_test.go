@@ -0,0 +1,168 @@
+package salesorders
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryOrderStore keeps orders in memory.
+type memoryOrderStore struct {
+	orders map[int]*models.SalesOrder
+}
+
+func (m *memoryOrderStore) CreateSalesOrder(ctx context.Context, order *models.SalesOrder) error {
+	order.ID = len(m.orders) + 1
+	for i := range order.Lines {
+		if order.Lines[i].UnitPrice == 0 {
+			order.Lines[i].UnitPrice = 2.5
+		}
+	}
+	copied := *order
+	m.orders[order.ID] = &copied
+	return nil
+}
+
+func (m *memoryOrderStore) GetSalesOrder(ctx context.Context, id int) (*models.SalesOrder, error) {
+	if order, ok := m.orders[id]; ok {
+		return order, nil
+	}
+	return nil, models.NotFound("sales order %d not found", id)
+}
+
+func (m *memoryOrderStore) ListSalesOrders(ctx context.Context, status string, customerID int) ([]models.SalesOrder, error) {
+	return nil, nil
+}
+
+func (m *memoryOrderStore) ConfirmSalesOrder(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	m.orders[id].Status = models.SalesOrderConfirmed
+	return m.orders[id], nil
+}
+
+func (m *memoryOrderStore) FulfillSalesOrder(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	return nil, nil
+}
+
+func (m *memoryOrderStore) CancelSalesOrder(ctx context.Context, id int, actor string) (*models.SalesOrder, error) {
+	return nil, nil
+}
+
+// memoryStockStore lists stock entries a page at a time, filtered by product.
+type memoryStockStore struct {
+	models.StockStore
+	entries []models.Stock
+}
+
+func (m *memoryStockStore) ListStock(ctx context.Context, query models.ListQuery) ([]models.Stock, int, error) {
+	productID, _ := strconv.Atoi(query.Filters["product_id"])
+	var matching []models.Stock
+	for _, entry := range m.entries {
+		if entry.ProductID == productID {
+			matching = append(matching, entry)
+		}
+	}
+	start := min(query.Offset(), len(matching))
+	end := min(start+query.Limit, len(matching))
+	return matching[start:end], len(matching), nil
+}
+
+// memoryBundleStore holds bundle 9: two of product 1 and one of product 2.
+type memoryBundleStore struct {
+	models.BundleStore
+}
+
+func (m *memoryBundleStore) Bundles(ctx context.Context, productIDs []int) (map[int]*models.Bundle, error) {
+	found := map[int]*models.Bundle{}
+	for _, id := range productIDs {
+		if id == 9 {
+			found[9] = &models.Bundle{ProductID: 9, Components: []models.BundleComponent{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}}}
+		}
+	}
+	return found, nil
+}
+
+func newService() (*Service, *memoryOrderStore, *memoryStockStore) {
+	orders := &memoryOrderStore{orders: map[int]*models.SalesOrder{}}
+	stock := &memoryStockStore{}
+	s := NewService(orders, stock, &memoryBundleStore{})
+	s.Now = func() time.Time { return time.Date(2024, 5, 3, 15, 30, 0, 0, time.UTC) }
+	return s, orders, stock
+}
+
+func TestCreateMergesLinesAndTotals(t *testing.T) {
+	s, _, _ := newService()
+	order := models.SalesOrder{CustomerID: 4, Lines: []models.SalesOrderLine{
+		{ProductID: 1, Quantity: 2, UnitPrice: 10},
+		{ProductID: 2, Quantity: 4},
+		{ProductID: 1, Quantity: 1, UnitPrice: 10},
+	}}
+	require.NoError(t, s.Create(context.Background(), &order, "sales@example.com"))
+
+	assert.Equal(t, models.SalesOrderDraft, order.Status)
+	assert.Equal(t, time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), order.OrderDate)
+	assert.Equal(t, []models.SalesOrderLine{
+		{ProductID: 1, Quantity: 3, UnitPrice: 10, Amount: 30},
+		{ProductID: 2, Quantity: 4, UnitPrice: 2.5, Amount: 10},
+	}, order.Lines)
+	assert.Equal(t, 40.0, order.Total)
+}
+
+func TestCreateRejectsIncompleteOrders(t *testing.T) {
+	s, _, _ := newService()
+	for _, order := range []models.SalesOrder{
+		{Lines: []models.SalesOrderLine{{ProductID: 1, Quantity: 1}}},
+		{CustomerID: 4},
+		{CustomerID: 4, Lines: []models.SalesOrderLine{{ProductID: 1}}},
+		{CustomerID: 4, Lines: []models.SalesOrderLine{{ProductID: 1, Quantity: 1, UnitPrice: 5}, {ProductID: 1, Quantity: 1, UnitPrice: 6}}},
+	} {
+		assert.True(t, errors.Is(s.Create(context.Background(), &order, "sales@example.com"), models.ErrValidation))
+	}
+}
+
+func TestConfirmChecksStockAcrossWarehouses(t *testing.T) {
+	ctx := context.Background()
+	s, orders, stock := newService()
+	order := models.SalesOrder{CustomerID: 4, Lines: []models.SalesOrderLine{{ProductID: 1, Quantity: 300}}}
+	require.NoError(t, s.Create(ctx, &order, "sales@example.com"))
+
+	for i := 0; i < 250; i++ {
+		stock.entries = append(stock.entries, models.Stock{ProductID: 1, Quantity: 1, WarehouseID: i + 1})
+	}
+	stock.entries = append(stock.entries, models.Stock{ProductID: 2, Quantity: 100})
+	_, err := s.Confirm(ctx, order.ID, "sales@example.com")
+	assert.Error(t, err)
+	assert.Equal(t, models.SalesOrderDraft, orders.orders[order.ID].Status)
+
+	stock.entries = append(stock.entries, models.Stock{ProductID: 1, Quantity: 50, WarehouseID: 1})
+	confirmed, err := s.Confirm(ctx, order.ID, "sales@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.SalesOrderConfirmed, confirmed.Status)
+
+	_, err = s.Confirm(ctx, order.ID, "sales@example.com")
+	assert.Error(t, err, "only drafts are confirmed")
+}
+
+func TestConfirmChecksBundleComponents(t *testing.T) {
+	ctx := context.Background()
+	s, _, stock := newService()
+	order := models.SalesOrder{CustomerID: 4, Lines: []models.SalesOrderLine{{ProductID: 9, Quantity: 3}, {ProductID: 1, Quantity: 1}}}
+	require.NoError(t, s.Create(ctx, &order, "sales@example.com"))
+
+	// Three bundles and a loose unit need 7 of product 1
+	stock.entries = []models.Stock{{ProductID: 1, Quantity: 6}, {ProductID: 2, Quantity: 3}}
+	_, err := s.Confirm(ctx, order.ID, "sales@example.com")
+	assert.True(t, errors.Is(err, models.ErrConflict))
+	assert.Contains(t, err.Error(), "product 1 has 6 in stock but 7 are ordered")
+
+	stock.entries = append(stock.entries, models.Stock{ProductID: 1, Quantity: 1, WarehouseID: 2})
+	confirmed, err := s.Confirm(ctx, order.ID, "sales@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.SalesOrderConfirmed, confirmed.Status)
+}
@@ -0,0 +1,234 @@
+// Package sandbox runs a deployment as a sandbox for sales demos. Its data can be reset to a
+// saved seed snapshot, and outgoing emails and webhooks are recorded in a capture log
+// instead of being sent.
+//
+// Resets are confined to databases marked as a sandbox with a row in sandbox_environment,
+// so a production database is never wiped even if SANDBOX_MODE is turned on by mistake.
+package sandbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"erp/controllers/backup"
+	"erp/controllers/jobs"
+	"erp/controllers/mailer"
+	"erp/controllers/outbox"
+	"erp/controllers/storage"
+	"erp/models"
+)
+
+// seedKey is the storage key of the description of the current seed snapshot.
+const seedKey = "seed.json"
+
+var (
+	// ErrNotSandbox is returned when the database is not marked as a sandbox.
+	ErrNotSandbox = models.Conflict("the database is not marked as a sandbox")
+	// ErrNoSeed is returned when a reset is requested before a seed snapshot is saved.
+	ErrNoSeed = models.NotFound("no seed snapshot has been saved")
+)
+
+// Status describes the sandbox.
+type Status struct {
+	Environment string         `json:"environment,omitempty"` // Name of the sandbox; empty if the database is not marked
+	Seed        *backup.Result `json:"seed,omitempty"`        // The snapshot resets restore
+}
+
+// Service saves seed snapshots, resets the data and captures outgoing messages.
+type Service struct {
+	DB      *sql.DB
+	Storage storage.Storage // Where the seed snapshot is kept; must not be publicly served
+	Backups *backup.Service // Takes the seed snapshots
+	mu      sync.Mutex      // Serializes seeding and resets
+}
+
+// NewService creates a sandbox service keeping its seed snapshot in store.
+func NewService(db *sql.DB, store storage.Storage) *Service {
+	return &Service{DB: db, Storage: store, Backups: backup.NewService(db, store, nil, 0)}
+}
+
+// Status returns the sandbox's name and current seed snapshot.
+func (s *Service) Status(ctx context.Context) (*Status, error) {
+	name, err := environment(ctx, s.DB)
+	if err != nil && err != ErrNotSandbox {
+		return nil, err
+	}
+	seed, err := s.seed()
+	if err != nil && err != ErrNoSeed {
+		return nil, err
+	}
+	return &Status{Environment: name, Seed: seed}, nil
+}
+
+// SaveSeed snapshots the current data as the state resets return to, replacing the
+// previous snapshot.
+//
+// Parameters:
+//   - actor: Email of the admin saving the seed.
+//
+// Returns:
+//   - *backup.Result: The snapshot file and its row counts.
+//   - error: ErrNotSandbox if the database is not marked as a sandbox, or an error if the
+//     snapshot cannot be taken.
+func (s *Service) SaveSeed(ctx context.Context, actor string) (*backup.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := environment(ctx, s.DB); err != nil {
+		return nil, err
+	}
+	previous, err := s.seed()
+	if err != nil && err != ErrNoSeed {
+		return nil, err
+	}
+	result, err := s.Backups.Backup(ctx, &jobs.Progress{})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Storage.Put(seedKey, data, "application/json"); err != nil {
+		return nil, err
+	}
+	if previous != nil && previous.Key != result.Key {
+		s.Storage.Delete(previous.Key)
+	}
+	log.Printf("sandbox: %s saved the seed snapshot %s", actor, result.Key)
+	return result, nil
+}
+
+// Reset replaces all data with the seed snapshot in one transaction. The snapshot is
+// verified first, so a damaged file leaves the data untouched.
+//
+// Parameters:
+//   - actor: Email of the admin resetting the sandbox.
+//
+// Returns:
+//   - map[string]int64: The number of rows restored per table.
+//   - error: ErrNotSandbox, ErrNoSeed, or an error if the snapshot is damaged or cannot be restored.
+func (s *Service) Reset(ctx context.Context, actor string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seed, err := s.seed()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Backups.Verify(ctx, seed, &jobs.Progress{}); err != nil {
+		return nil, fmt.Errorf("seed snapshot is damaged: %w", err)
+	}
+	file, err := storage.Open(s.Storage, seed.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// Checked in the transaction that wipes the data, so the marker cannot be removed in between
+	if _, err := environment(ctx, tx); err != nil {
+		return nil, err
+	}
+	counts, err := backup.Restore(ctx, tx, file)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	log.Printf("sandbox: %s reset the data to the seed snapshot %s", actor, seed.Key)
+	return counts, nil
+}
+
+// Capture records an outbox message instead of delivering it. It is registered as the
+// outbox handler for emails and webhooks in sandbox mode.
+func (s *Service) Capture(ctx context.Context, msg *models.OutboxMessage) error {
+	capture := models.SandboxCapture{Kind: msg.Kind, Payload: msg.Payload, CapturedAt: time.Now()}
+	switch msg.Kind {
+	case outbox.KindEmail:
+		var email mailer.Message
+		if err := json.Unmarshal(msg.Payload, &email); err != nil {
+			return err
+		}
+		capture.Recipient, capture.Subject = strings.Join(email.To, ", "), email.Subject
+	case outbox.KindWebhook:
+		var hook outbox.WebhookRequest
+		if err := json.Unmarshal(msg.Payload, &hook); err != nil {
+			return err
+		}
+		capture.Recipient = hook.URL
+	}
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO sandbox_captures (kind, recipient, subject, payload, captured_at) VALUES ($1, $2, $3, $4, $5)`,
+		capture.Kind, capture.Recipient, capture.Subject, []byte(capture.Payload), capture.CapturedAt,
+	)
+	return err
+}
+
+// Captures returns the most recently captured messages, newest first.
+//
+// Parameters:
+//   - limit: The maximum number of messages returned.
+//
+// Returns:
+//   - []models.SandboxCapture: The captured messages.
+//   - error: An error if the query fails.
+func (s *Service) Captures(ctx context.Context, limit int) ([]models.SandboxCapture, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, kind, recipient, subject, payload, captured_at FROM sandbox_captures
+		 ORDER BY captured_at DESC, id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	captures := []models.SandboxCapture{}
+	for rows.Next() {
+		var c models.SandboxCapture
+		var payload []byte
+		if err := rows.Scan(&c.ID, &c.Kind, &c.Recipient, &c.Subject, &payload, &c.CapturedAt); err != nil {
+			return nil, err
+		}
+		c.Payload = payload
+		captures = append(captures, c)
+	}
+	return captures, rows.Err()
+}
+
+// seed reads the description of the current seed snapshot.
+func (s *Service) seed() (*backup.Result, error) {
+	data, err := s.Storage.Get(seedKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNoSeed
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result backup.Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("read %s: %w", seedKey, err)
+	}
+	return &result, nil
+}
+
+// environment returns the name of the sandbox, or ErrNotSandbox if the database is not marked.
+func environment(ctx context.Context, q models.Queryer) (string, error) {
+	var name string
+	err := q.QueryRowContext(ctx, `SELECT name FROM sandbox_environment`).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", ErrNotSandbox
+	}
+	return name, err
+}
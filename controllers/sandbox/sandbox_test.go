@@ -0,0 +1,70 @@
+package sandbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"erp/controllers/backup"
+	"erp/controllers/outbox"
+	"erp/controllers/storage"
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	service := NewService(db, storage.NewMemoryStorage())
+
+	email := json.RawMessage(`{"to":["a@example.com","b@example.com"],"subject":"Invoice INV-7"}`)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sandbox_captures")).
+		WithArgs(outbox.KindEmail, "a@example.com, b@example.com", "Invoice INV-7", []byte(email), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, service.Capture(ctx, &models.OutboxMessage{Kind: outbox.KindEmail, Payload: email}))
+
+	hook := json.RawMessage(`{"url":"https://hooks.example.com/erp","body":{}}`)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sandbox_captures")).
+		WithArgs(outbox.KindWebhook, "https://hooks.example.com/erp", "", []byte(hook), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	require.NoError(t, service.Capture(ctx, &models.OutboxMessage{Kind: outbox.KindWebhook, Payload: hook}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetIsConfinedToSandboxes(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	store := storage.NewMemoryStorage()
+	service := NewService(db, store)
+
+	_, err = service.Reset(ctx, "admin@example.com")
+	assert.ErrorIs(t, err, ErrNoSeed)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"format":"erp-backup","version":1}` + "\n" + `{"manifest":{}}` + "\n"))
+	zw.Close()
+	require.NoError(t, store.Put("backups/seed.json.gz", buf.Bytes(), "application/gzip"))
+	seed, _ := json.Marshal(backup.Result{Key: "backups/seed.json.gz", Tables: map[string]int64{}})
+	require.NoError(t, store.Put(seedKey, seed, "application/json"))
+
+	// A production database has no marker, so nothing is truncated
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT name FROM sandbox_environment")).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err = service.Reset(ctx, "admin@example.com")
+	assert.ErrorIs(t, err, ErrNotSandbox)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
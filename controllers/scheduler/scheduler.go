@@ -0,0 +1,118 @@
+// Package scheduler runs background jobs at fixed intervals or at a fixed time of day.
+// Jobs are registered at startup and run in their own goroutines until the scheduler's
+// context is cancelled.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of scheduled work. ctx is cancelled when the scheduler stops.
+type Job func(ctx context.Context) error
+
+// entry is a registered job together with the function that computes its next run.
+type entry struct {
+	name string
+	job  Job
+	next func(now time.Time) time.Time
+}
+
+// Scheduler runs registered jobs in the background.
+type Scheduler struct {
+	// Writable reports whether jobs may change data, typically the maintenance service. A
+	// run due while it returns false is skipped, and the job waits for its next scheduled
+	// time. Nil runs every job.
+	Writable func(ctx context.Context) bool
+
+	mu      sync.Mutex
+	entries []entry
+}
+
+// New creates an empty scheduler.
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers a job that runs every interval, starting one interval after Run is called.
+//
+// Parameters:
+//   - name: A descriptive name used in logs.
+//   - interval: The time between runs.
+//   - job: The work to perform.
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	s.add(entry{name: name, job: job, next: func(now time.Time) time.Time {
+		return now.Add(interval)
+	}})
+}
+
+// Daily registers a job that runs once a day at the given local time.
+//
+// Parameters:
+//   - name: A descriptive name used in logs.
+//   - hour, minute: The time of day the job runs.
+//   - job: The work to perform.
+func (s *Scheduler) Daily(name string, hour, minute int, job Job) {
+	s.add(entry{name: name, job: job, next: func(now time.Time) time.Time {
+		return NextDaily(now, hour, minute)
+	}})
+}
+
+// NextDaily returns the next occurrence of hour:minute strictly after now.
+func NextDaily(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Run starts all registered jobs and blocks until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	entries := append([]entry(nil), s.entries...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func(e entry) {
+			defer wg.Done()
+			s.loop(ctx, e)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// add registers an entry.
+func (s *Scheduler) add(e entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+}
+
+// loop waits for each scheduled time of a job and runs it.
+func (s *Scheduler) loop(ctx context.Context, e entry) {
+	for {
+		timer := time.NewTimer(time.Until(e.next(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if s.Writable != nil && !s.Writable(ctx) {
+			log.Printf("scheduler: job %s skipped: writes are paused", e.name)
+			continue
+		}
+		start := time.Now()
+		if err := e.job(ctx); err != nil {
+			log.Printf("scheduler: job %s failed: %v", e.name, err)
+			continue
+		}
+		log.Printf("scheduler: job %s finished in %s", e.name, time.Since(start).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,160 @@
+// Package settings provides cached, typed access to the organization-wide settings such as
+// the company name and default currency. Values are stored in the settings table; keys that
+// were never saved fall back to their defaults.
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"erp/models"
+)
+
+// Service reads and updates settings. Reads are served from a cache that is reloaded
+// after TTL or as soon as this service saves a change.
+type Service struct {
+	Store models.SettingStore
+	TTL   time.Duration
+
+	mu       sync.Mutex
+	values   map[string]json.RawMessage
+	loadedAt time.Time
+}
+
+// NewService creates a settings service with the given cache lifetime.
+func NewService(store models.SettingStore, ttl time.Duration) *Service {
+	return &Service{Store: store, TTL: ttl}
+}
+
+// All returns every setting, with defaults filled in for keys that were never saved.
+//
+// Returns:
+//   - map[string]json.RawMessage: The values keyed by setting key.
+//   - error: An error if the settings cannot be loaded.
+func (s *Service) All(ctx context.Context) (map[string]json.RawMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil || time.Since(s.loadedAt) > s.TTL {
+		stored, err := s.Store.GetSettings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]json.RawMessage, len(models.SettingDefinitions))
+		for key, definition := range models.SettingDefinitions {
+			if raw, ok := stored[key]; ok {
+				values[key] = raw
+				continue
+			}
+			raw, err := json.Marshal(definition.Default)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = raw
+		}
+		s.values, s.loadedAt = values, time.Now()
+	}
+
+	result := make(map[string]json.RawMessage, len(s.values))
+	for key, raw := range s.values {
+		result[key] = raw
+	}
+	return result, nil
+}
+
+// Get decodes the value of one setting into target.
+//
+// Parameters:
+//   - key: One of the models.Setting* keys.
+//   - target: A pointer to a value of the setting's type.
+//
+// Returns:
+//   - error: models.ErrUnknownSetting for unknown keys, or an error if loading fails.
+func (s *Service) Get(ctx context.Context, key string, target interface{}) error {
+	if _, ok := models.SettingDefinitions[key]; !ok {
+		return fmt.Errorf("%w: %s", models.ErrUnknownSetting, key)
+	}
+	values, err := s.All(ctx)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(values[key], target)
+}
+
+// String returns a text setting, or an empty string if it cannot be loaded.
+func (s *Service) String(ctx context.Context, key string) string {
+	var value string
+	if err := s.Get(ctx, key, &value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// Company returns the company profile used on documents and reports.
+//
+// Returns:
+//   - *CompanyProfile: The profile built from the company and finance settings.
+//   - error: An error if the settings cannot be loaded.
+func (s *Service) Company(ctx context.Context) (*models.CompanyProfile, error) {
+	values, err := s.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &models.CompanyProfile{}
+	fields := map[string]interface{}{
+		models.SettingCompanyName:            &profile.Name,
+		models.SettingCompanyAddress:         &profile.Address,
+		models.SettingCompanyLogoURL:         &profile.LogoURL,
+		models.SettingDefaultCurrency:        &profile.Currency,
+		models.SettingTaxRegistrationNumbers: &profile.TaxRegistrationNumbers,
+		models.SettingInvoiceFooter:          &profile.InvoiceFooter,
+	}
+	for key, target := range fields {
+		if err := json.Unmarshal(values[key], target); err != nil {
+			return nil, fmt.Errorf("setting %s: %w", key, err)
+		}
+	}
+	return profile, nil
+}
+
+// Update validates and stores several settings at once. Nothing is saved if any value
+// is invalid.
+//
+// Parameters:
+//   - values: Raw JSON values keyed by setting key.
+//   - actor: Email of the user making the change, for the audit log.
+//
+// Returns:
+//   - error: A *models.InvalidSettingsError listing every invalid value, or an error if saving fails.
+func (s *Service) Update(ctx context.Context, values map[string]json.RawMessage, actor string) error {
+	normalized := make(map[string]json.RawMessage, len(values))
+	invalid := map[string]string{}
+	for key, value := range values {
+		definition, ok := models.SettingDefinitions[key]
+		if !ok {
+			invalid[key] = models.ErrUnknownSetting.Error()
+			continue
+		}
+		raw, err := definition.Validate(value)
+		if err != nil {
+			invalid[key] = err.Error()
+			continue
+		}
+		normalized[key] = raw
+	}
+	if len(invalid) > 0 {
+		return &models.InvalidSettingsError{Fields: invalid}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.Store.SaveSettings(ctx, normalized, actor); err != nil {
+		return err
+	}
+	s.values = nil
+	return nil
+}
@@ -0,0 +1,80 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySettingStore keeps settings in memory and counts loads.
+type memorySettingStore struct {
+	values map[string]json.RawMessage
+	loads  int
+}
+
+func (m *memorySettingStore) GetSettings(ctx context.Context) (map[string]json.RawMessage, error) {
+	m.loads++
+	result := map[string]json.RawMessage{}
+	for key, value := range m.values {
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (m *memorySettingStore) SaveSettings(ctx context.Context, values map[string]json.RawMessage, actor string) error {
+	for key, value := range values {
+		m.values[key] = value
+	}
+	return nil
+}
+
+func TestDefaultsAndCaching(t *testing.T) {
+	ctx := context.Background()
+	store := &memorySettingStore{values: map[string]json.RawMessage{models.SettingCompanyName: json.RawMessage(`"Acme Ltd"`)}}
+	service := NewService(store, time.Hour)
+
+	company, err := service.Company(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme Ltd", company.Name)
+	assert.Equal(t, "USD", company.Currency)
+	assert.Equal(t, "USD", service.String(ctx, models.SettingDefaultCurrency))
+	assert.Equal(t, 1, store.loads)
+
+	var value string
+	assert.ErrorIs(t, service.Get(ctx, "company.motto", &value), models.ErrUnknownSetting)
+}
+
+func TestUpdateValidatesAndInvalidatesCache(t *testing.T) {
+	ctx := context.Background()
+	store := &memorySettingStore{values: map[string]json.RawMessage{}}
+	service := NewService(store, time.Hour)
+	assert.Equal(t, "USD", service.String(ctx, models.SettingDefaultCurrency))
+
+	err := service.Update(ctx, map[string]json.RawMessage{
+		models.SettingDefaultCurrency: json.RawMessage(`"dollars"`),
+		models.SettingCompanyLogoURL:  json.RawMessage(`"ftp://logo"`),
+		models.SettingCompanyName:     json.RawMessage(`"Acme Ltd"`),
+		"company.motto":               json.RawMessage(`"Fast"`),
+	}, "admin@example.com")
+	var invalid *models.InvalidSettingsError
+	assert.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Fields, 3)
+	assert.Empty(t, store.values)
+
+	err = service.Update(ctx, map[string]json.RawMessage{
+		models.SettingDefaultCurrency:        json.RawMessage(`"bdt"`),
+		models.SettingTaxRegistrationNumbers: json.RawMessage(`{"BIN":"000123456-0101"}`),
+	}, "admin@example.com")
+	assert.NoError(t, err)
+
+	company, err := service.Company(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "BDT", company.Currency)
+	assert.Equal(t, map[string]string{"BIN": "000123456-0101"}, company.TaxRegistrationNumbers)
+	assert.Equal(t, 2, store.loads)
+}
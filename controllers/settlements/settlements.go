@@ -0,0 +1,236 @@
+// Package settlements works out the final settlement of employees who leave: the salary
+// not yet paid and the encashable leave they have earned, less outstanding loans and
+// advances and the value of company assets they have not returned. HR drafts and
+// recalculates the settlement during offboarding, records the exit interview, and finance
+// approves it, which posts the payable to the ledger.
+package settlements
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Ledger accounts a final settlement is posted to.
+const (
+	ExpenseAccount  = "salaries"          // Salary due and leave encashment
+	AdvancesAccount = "employee_advances" // Loans and advances recovered
+	AssetAccount    = "asset_recoveries"  // Value of the assets not returned
+	PayableAccount  = "salaries_payable"  // Net owed to the employee; a debit when they owe the company
+)
+
+// LeaveBalances reads an employee's leave balances for a year. It is implemented by the
+// leave service.
+type LeaveBalances interface {
+	Balances(ctx context.Context, userID, year int) ([]models.LeaveYearEndLine, error)
+}
+
+// Service applies the final settlement rules on top of a FinalSettlementStore.
+type Service struct {
+	Store      models.FinalSettlementStore
+	Leave      LeaveBalances
+	Encashable []string // Leave types paid out on leaving
+	Now        func() time.Time
+}
+
+// NewService creates a final settlement service.
+func NewService(store models.FinalSettlementStore, leave LeaveBalances, encashable []string) *Service {
+	return &Service{Store: store, Leave: leave, Encashable: encashable, Now: time.Now}
+}
+
+// Create works out an employee's settlement and records it as a draft.
+//
+// Parameters:
+//   - settlement: UserID, LastWorkingDay, PaidThrough, MonthlySalary, Recoveries and Assets
+//     are read; the amounts are worked out and the store fills in the ID and employee.
+//   - actor: Email of the HR user.
+//
+// Returns:
+//   - error: A validation error if the inputs are invalid, a conflict if the employee
+//     already has a settlement, or the store's error.
+func (s *Service) Create(ctx context.Context, settlement *models.FinalSettlement, actor string) error {
+	if err := s.Calculate(ctx, settlement); err != nil {
+		return err
+	}
+	settlement.Status = models.SettlementDraft
+	settlement.CreatedBy = actor
+	return s.Store.CreateFinalSettlement(ctx, settlement)
+}
+
+// Get returns a settlement statement.
+func (s *Service) Get(ctx context.Context, id int) (*models.FinalSettlement, error) {
+	return s.Store.GetFinalSettlement(ctx, id)
+}
+
+// List returns the settlements in a status, or all of them if status is empty.
+func (s *Service) List(ctx context.Context, status string) ([]models.FinalSettlement, error) {
+	switch status {
+	case "", models.SettlementDraft, models.SettlementApproved, models.SettlementCancelled:
+		return s.Store.ListFinalSettlements(ctx, status)
+	}
+	return nil, models.Invalid("unknown settlement status %q", status)
+}
+
+// Recalculate replaces the inputs of a draft, for example once assets are returned, and
+// works the amounts out again with the current leave balances.
+//
+// Parameters:
+//   - id: The ID of the settlement.
+//   - input: LastWorkingDay, PaidThrough, MonthlySalary, Recoveries and Assets are read.
+//
+// Returns:
+//   - *models.FinalSettlement: The recalculated settlement.
+//   - error: A validation error if the inputs are invalid, a conflict if the settlement is
+//     no longer a draft, or the store's error.
+func (s *Service) Recalculate(ctx context.Context, id int, input models.FinalSettlement) (*models.FinalSettlement, error) {
+	settlement, err := s.Store.GetFinalSettlement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if settlement.Status != models.SettlementDraft {
+		return nil, models.Conflict("settlement %d is %s", id, settlement.Status)
+	}
+	settlement.LastWorkingDay, settlement.PaidThrough = input.LastWorkingDay, input.PaidThrough
+	settlement.MonthlySalary = input.MonthlySalary
+	settlement.Recoveries, settlement.Assets = input.Recoveries, input.Assets
+	if err := s.Calculate(ctx, settlement); err != nil {
+		return nil, err
+	}
+	if err := s.Store.UpdateFinalSettlement(ctx, settlement); err != nil {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// Interview records the exit interview of the employee a settlement is for.
+func (s *Service) Interview(ctx context.Context, id int, interview *models.ExitInterview, actor string) error {
+	interview.Reason = strings.TrimSpace(interview.Reason)
+	interview.Feedback = strings.TrimSpace(interview.Feedback)
+	if interview.Reason == "" {
+		return models.Invalid("a reason for leaving is required")
+	}
+	interview.ConductedBy, interview.ConductedAt = actor, s.Now()
+	return s.Store.SaveExitInterview(ctx, id, interview)
+}
+
+// Approve approves a draft settlement and posts it to the ledger (see Postings). The HR
+// user who drafted it cannot approve it.
+func (s *Service) Approve(ctx context.Context, id int, actor string) (*models.FinalSettlement, error) {
+	return s.Store.ApproveFinalSettlement(ctx, id, actor)
+}
+
+// Cancel cancels a draft settlement, for example when the employee stays.
+func (s *Service) Cancel(ctx context.Context, id int, actor string) (*models.FinalSettlement, error) {
+	return s.Store.CancelFinalSettlement(ctx, id, actor)
+}
+
+// Calculate checks a settlement's inputs and works out its amounts. The daily rate is the
+// monthly salary × 12 / 365. Unpaid days run from the day after PaidThrough to the last
+// working day, and are negative when salary was paid beyond it. The encashable leave
+// earned is the yearly entitlement for the months of the year started by the last working
+// day, plus adjustments, less leave taken.
+func (s *Service) Calculate(ctx context.Context, settlement *models.FinalSettlement) error {
+	switch {
+	case settlement.UserID <= 0:
+		return models.Invalid("user_id is required")
+	case settlement.LastWorkingDay.IsZero() || settlement.PaidThrough.IsZero():
+		return models.Invalid("last_working_day and paid_through are required")
+	case settlement.MonthlySalary < 0:
+		return models.Invalid("monthly_salary cannot be negative")
+	}
+	var deductions float64
+	for i, recovery := range settlement.Recoveries {
+		settlement.Recoveries[i].Description = strings.TrimSpace(recovery.Description)
+		if settlement.Recoveries[i].Description == "" || recovery.Amount <= 0 {
+			return models.Invalid("recovery %d needs a description and a positive amount", i+1)
+		}
+		deductions += recovery.Amount
+	}
+	settlement.AssetRecovery = 0
+	for i, asset := range settlement.Assets {
+		settlement.Assets[i].Description = strings.TrimSpace(asset.Description)
+		if settlement.Assets[i].Description == "" || asset.Value < 0 {
+			return models.Invalid("asset %d needs a description and a value that is not negative", i+1)
+		}
+		if !asset.Returned {
+			settlement.AssetRecovery += asset.Value
+		}
+	}
+	if settlement.Recoveries == nil {
+		settlement.Recoveries = []models.SettlementRecovery{}
+	}
+	if settlement.Assets == nil {
+		settlement.Assets = []models.SettlementAsset{}
+	}
+
+	settlement.DailyRate = roundCents(settlement.MonthlySalary * 12 / 365)
+	settlement.UnpaidDays = int(math.Round(settlement.LastWorkingDay.Sub(settlement.PaidThrough).Hours() / 24))
+	settlement.SalaryDue = roundCents(float64(settlement.UnpaidDays) * settlement.DailyRate)
+
+	lines, err := s.Leave.Balances(ctx, settlement.UserID, settlement.LastWorkingDay.Year())
+	if err != nil {
+		return err
+	}
+	settlement.Leave, settlement.LeaveEncashment = []models.SettlementLeave{}, 0
+	months := float64(settlement.LastWorkingDay.Month())
+	for _, line := range lines {
+		if !s.encashable(line.LeaveType) {
+			continue
+		}
+		days := math.Round((line.Entitlement*months/12+line.Adjustments-line.Taken)*100) / 100
+		if days <= 0 {
+			continue
+		}
+		amount := roundCents(days * settlement.DailyRate)
+		settlement.Leave = append(settlement.Leave, models.SettlementLeave{LeaveType: line.LeaveType, Days: days, Amount: amount})
+		settlement.LeaveEncashment = roundCents(settlement.LeaveEncashment + amount)
+	}
+
+	settlement.AssetRecovery = roundCents(settlement.AssetRecovery)
+	settlement.Gross = roundCents(settlement.SalaryDue + settlement.LeaveEncashment)
+	settlement.Deductions = roundCents(deductions + settlement.AssetRecovery)
+	settlement.Net = roundCents(settlement.Gross - settlement.Deductions)
+	return nil
+}
+
+// Postings returns the balanced ledger lines of an approved settlement, dated at: the gross
+// is debited to salaries, the recoveries credited to employee advances and asset
+// recoveries, and the net credited to salaries payable. Zero lines are left out.
+func Postings(settlement *models.FinalSettlement, at time.Time) []models.FinancialTransaction {
+	recoveries := roundCents(settlement.Deductions - settlement.AssetRecovery)
+	description := "Final settlement of " + settlement.Email
+	var lines []models.FinancialTransaction
+	for _, line := range []struct {
+		account string
+		amount  float64
+	}{
+		{ExpenseAccount, settlement.Gross},
+		{AdvancesAccount, -recoveries},
+		{AssetAccount, -settlement.AssetRecovery},
+		{PayableAccount, -settlement.Net},
+	} {
+		if line.amount != 0 {
+			lines = append(lines, models.FinancialTransaction{AccountType: line.account, Amount: line.amount,
+				TransactionDate: at, Description: description})
+		}
+	}
+	return lines
+}
+
+// encashable reports whether a leave type is paid out on leaving.
+func (s *Service) encashable(leaveType string) bool {
+	for _, t := range s.Encashable {
+		if strings.EqualFold(t, leaveType) {
+			return true
+		}
+	}
+	return false
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,89 @@
+package settlements
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLeave returns the same balances for every employee and year.
+type fakeLeave []models.LeaveYearEndLine
+
+func (f fakeLeave) Balances(ctx context.Context, userID, year int) ([]models.LeaveYearEndLine, error) {
+	return f, nil
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestCalculate(t *testing.T) {
+	s := NewService(nil, fakeLeave{
+		{LeaveType: "Vacation", Entitlement: 24, Adjustments: 2, Taken: 5},
+		{LeaveType: "Sick Leave", Entitlement: 10},
+	}, []string{"vacation"})
+	settlement := &models.FinalSettlement{
+		UserID:         4,
+		LastWorkingDay: day("2025-06-20"),
+		PaidThrough:    day("2025-05-31"),
+		MonthlySalary:  3650,
+		Recoveries:     []models.SettlementRecovery{{Description: " Salary advance ", Amount: 500}},
+		Assets: []models.SettlementAsset{
+			{Description: "Laptop", Value: 900, Returned: true},
+			{Description: "Phone", Value: 300},
+		},
+	}
+	require.NoError(t, s.Calculate(context.Background(), settlement))
+
+	assert.Equal(t, 120.0, settlement.DailyRate)
+	assert.Equal(t, 20, settlement.UnpaidDays)
+	assert.Equal(t, 2400.0, settlement.SalaryDue)
+	// 24 days a year earned for six months, plus 2 adjusted, less 5 taken; sick leave is not encashed
+	assert.Equal(t, []models.SettlementLeave{{LeaveType: "Vacation", Days: 9, Amount: 1080}}, settlement.Leave)
+	assert.Equal(t, "Salary advance", settlement.Recoveries[0].Description)
+	assert.Equal(t, 300.0, settlement.AssetRecovery)
+	assert.Equal(t, 3480.0, settlement.Gross)
+	assert.Equal(t, 800.0, settlement.Deductions)
+	assert.Equal(t, 2680.0, settlement.Net)
+
+	lines := Postings(settlement, time.Now())
+	var total float64
+	for _, line := range lines {
+		total += line.Amount
+	}
+	assert.Len(t, lines, 4)
+	assert.InDelta(t, 0, total, 0.001, "postings balance")
+	assert.Equal(t, -2680.0, lines[3].Amount)
+}
+
+func TestCalculateChecksTheInputs(t *testing.T) {
+	s := NewService(nil, fakeLeave{}, nil)
+	for _, settlement := range []models.FinalSettlement{
+		{LastWorkingDay: day("2025-06-20"), PaidThrough: day("2025-05-31")},
+		{UserID: 4, PaidThrough: day("2025-05-31")},
+		{UserID: 4, LastWorkingDay: day("2025-06-20"), PaidThrough: day("2025-05-31"), MonthlySalary: -1},
+		{UserID: 4, LastWorkingDay: day("2025-06-20"), PaidThrough: day("2025-05-31"),
+			Recoveries: []models.SettlementRecovery{{Description: "Loan"}}},
+		{UserID: 4, LastWorkingDay: day("2025-06-20"), PaidThrough: day("2025-05-31"),
+			Assets: []models.SettlementAsset{{Value: 10}}},
+	} {
+		err := s.Calculate(context.Background(), &settlement)
+		assert.True(t, errors.Is(err, models.ErrValidation), "%+v", settlement)
+	}
+}
+
+func TestSalaryPaidBeyondTheLastDayIsRecovered(t *testing.T) {
+	s := NewService(nil, fakeLeave{}, nil)
+	settlement := &models.FinalSettlement{UserID: 4, LastWorkingDay: day("2025-06-20"), PaidThrough: day("2025-06-30"),
+		MonthlySalary: 3650}
+	require.NoError(t, s.Calculate(context.Background(), settlement))
+	assert.Equal(t, -10, settlement.UnpaidDays)
+	assert.Equal(t, -1200.0, settlement.Net)
+}
@@ -0,0 +1,175 @@
+package shipping
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// FlatRateService is one service level offered by a FlatRateCarrier.
+type FlatRateService struct {
+	Code          string
+	Multiplier    float64 // Applied to the base price of the shipment
+	EstimatedDays int
+}
+
+// FlatRateCarrier prices parcels from a base rate plus a rate per started kilogram. It suits
+// local couriers without an API: labels are generated here and tracking updates arrive
+// through webhooks.
+type FlatRateCarrier struct {
+	CarrierName string
+	Currency    string
+	Base        float64
+	PerKg       float64
+	Services    []FlatRateService
+}
+
+// NewFlatRateCarrier creates a flat rate carrier with standard and express services.
+func NewFlatRateCarrier(name, currency string, base, perKg float64) *FlatRateCarrier {
+	return &FlatRateCarrier{
+		CarrierName: name,
+		Currency:    currency,
+		Base:        base,
+		PerKg:       perKg,
+		Services: []FlatRateService{
+			{Code: "standard", Multiplier: 1, EstimatedDays: 3},
+			{Code: "express", Multiplier: 2, EstimatedDays: 1},
+		},
+	}
+}
+
+// Name returns the carrier's name.
+func (c *FlatRateCarrier) Name() string {
+	return c.CarrierName
+}
+
+// Rates quotes every service of the carrier.
+func (c *FlatRateCarrier) Rates(shipment *models.Shipment) ([]models.ShippingRate, error) {
+	rates := make([]models.ShippingRate, 0, len(c.Services))
+	for _, service := range c.Services {
+		rates = append(rates, c.rate(shipment, service))
+	}
+	return rates, nil
+}
+
+// rate prices a shipment for one service, rounded to cents.
+func (c *FlatRateCarrier) rate(shipment *models.Shipment, service FlatRateService) models.ShippingRate {
+	amount := (c.Base + c.PerKg*math.Ceil(shipment.WeightKg)) * service.Multiplier
+	return models.ShippingRate{
+		Carrier:       c.CarrierName,
+		Service:       service.Code,
+		Amount:        math.Round(amount*100) / 100,
+		Currency:      c.Currency,
+		EstimatedDays: service.EstimatedDays,
+	}
+}
+
+// CreateShipment issues a tracking number and a plain text label for the shipment.
+func (c *FlatRateCarrier) CreateShipment(shipment *models.Shipment, service string) (*Label, error) {
+	for _, s := range c.Services {
+		if s.Code != service {
+			continue
+		}
+		trackingNumber, err := c.newTrackingNumber()
+		if err != nil {
+			return nil, err
+		}
+		rate := c.rate(shipment, s)
+		return &Label{
+			TrackingNumber: trackingNumber,
+			Cost:           rate.Amount,
+			Currency:       rate.Currency,
+			Data:           []byte(c.renderLabel(shipment, service, trackingNumber)),
+			ContentType:    "text/plain; charset=utf-8",
+		}, nil
+	}
+	return nil, fmt.Errorf("%w %q for carrier %s", ErrUnknownService, service, c.CarrierName)
+}
+
+// Track is not supported; the courier reports tracking updates through webhooks.
+func (c *FlatRateCarrier) Track(trackingNumber string) ([]models.TrackingEvent, error) {
+	return nil, ErrTrackingUnavailable
+}
+
+// flatRateUpdate is the webhook payload a flat rate courier sends for a tracking update.
+type flatRateUpdate struct {
+	TrackingNumber string    `json:"tracking_number"`
+	Status         string    `json:"status"`
+	Description    string    `json:"description"`
+	Location       string    `json:"location"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// ParseWebhook reads a single tracking update, or an object with an "updates" list.
+func (c *FlatRateCarrier) ParseWebhook(payload []byte) ([]models.TrackingEvent, error) {
+	var body struct {
+		flatRateUpdate
+		Updates []flatRateUpdate `json:"updates"`
+	}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, fmt.Errorf("invalid tracking payload: %w", err)
+	}
+	updates := body.Updates
+	if len(updates) == 0 {
+		updates = []flatRateUpdate{body.flatRateUpdate}
+	}
+
+	events := make([]models.TrackingEvent, 0, len(updates))
+	for _, u := range updates {
+		status := strings.ToLower(u.Status)
+		if u.TrackingNumber == "" || !models.IsTrackingStatus(status) {
+			return nil, fmt.Errorf("invalid tracking update for %q with status %q", u.TrackingNumber, u.Status)
+		}
+		if u.OccurredAt.IsZero() {
+			u.OccurredAt = time.Now()
+		}
+		events = append(events, models.TrackingEvent{
+			TrackingNumber: u.TrackingNumber,
+			Status:         status,
+			Description:    u.Description,
+			Location:       u.Location,
+			OccurredAt:     u.OccurredAt,
+		})
+	}
+	return events, nil
+}
+
+// newTrackingNumber returns a random tracking number prefixed with the carrier's initials.
+func (c *FlatRateCarrier) newTrackingNumber() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	prefix := strings.ToUpper(c.CarrierName)
+	if len(prefix) > 3 {
+		prefix = prefix[:3]
+	}
+	return prefix + strings.ToUpper(hex.EncodeToString(buf)), nil
+}
+
+// renderLabel formats a printable label.
+func (c *FlatRateCarrier) renderLabel(shipment *models.Shipment, service, trackingNumber string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s\n", strings.ToUpper(c.CarrierName), strings.ToUpper(service))
+	fmt.Fprintf(&b, "Tracking: %s\n", trackingNumber)
+	fmt.Fprintf(&b, "Weight: %.2f kg\n\n", shipment.WeightKg)
+	writeAddress(&b, "FROM", shipment.FromAddress)
+	b.WriteString("\n")
+	writeAddress(&b, "TO", shipment.ToAddress)
+	return b.String()
+}
+
+// writeAddress writes an address block to a label.
+func writeAddress(b *strings.Builder, title string, a models.Address) {
+	fmt.Fprintf(b, "%s:\n%s\n%s\n", title, a.Name, a.Line1)
+	if a.Line2 != "" {
+		fmt.Fprintf(b, "%s\n", a.Line2)
+	}
+	fmt.Fprintf(b, "%s %s\n%s\n", a.PostalCode, a.City, a.Country)
+}
@@ -0,0 +1,52 @@
+package shipping
+
+import (
+	"strings"
+	"testing"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatRateCarrierRates(t *testing.T) {
+	carrier := NewFlatRateCarrier("courier", "USD", 5, 1.5)
+
+	rates, err := carrier.Rates(&models.Shipment{WeightKg: 2.2})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ShippingRate{
+		{Carrier: "courier", Service: "standard", Amount: 9.5, Currency: "USD", EstimatedDays: 3},
+		{Carrier: "courier", Service: "express", Amount: 19, Currency: "USD", EstimatedDays: 1},
+	}, rates)
+}
+
+func TestFlatRateCarrierCreateShipment(t *testing.T) {
+	carrier := NewFlatRateCarrier("courier", "USD", 5, 1.5)
+	shipment := &models.Shipment{WeightKg: 1, ToAddress: models.Address{Name: "Jane Doe", Line1: "1 Main St", City: "Dhaka", Country: "BD"}}
+
+	label, err := carrier.CreateShipment(shipment, "express")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(label.TrackingNumber, "COU"))
+	assert.Equal(t, 13.0, label.Cost)
+	assert.Contains(t, string(label.Data), label.TrackingNumber)
+	assert.Contains(t, string(label.Data), "Jane Doe")
+
+	_, err = carrier.CreateShipment(shipment, "overnight")
+	assert.ErrorIs(t, err, ErrUnknownService)
+}
+
+func TestFlatRateCarrierParseWebhook(t *testing.T) {
+	carrier := NewFlatRateCarrier("courier", "USD", 5, 1.5)
+
+	events, err := carrier.ParseWebhook([]byte(`{"tracking_number":"COU1","status":"IN_TRANSIT","occurred_at":"2024-05-01T10:00:00Z"}`))
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, models.ShipmentInTransit, events[0].Status)
+
+	events, err = carrier.ParseWebhook([]byte(`{"updates":[{"tracking_number":"COU1","status":"out_for_delivery"},{"tracking_number":"COU1","status":"delivered"}]}`))
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	_, err = carrier.ParseWebhook([]byte(`{"tracking_number":"COU1","status":"lost_in_space"}`))
+	assert.Error(t, err)
+}
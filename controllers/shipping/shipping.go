@@ -0,0 +1,68 @@
+// Package shipping provides a pluggable interface for shipping carriers: quoting rates,
+// buying labels and tracking parcels. Carriers are looked up by name, which is also the
+// name of the webhook integration that delivers their tracking updates.
+package shipping
+
+import (
+	"errors"
+	"fmt"
+
+	"erp/config"
+	"erp/models"
+)
+
+// ErrUnknownService is returned when a label is requested for a service the carrier does not offer.
+var ErrUnknownService = errors.New("unknown shipping service")
+
+// ErrTrackingUnavailable is returned by carriers that only report tracking through webhooks.
+var ErrTrackingUnavailable = errors.New("carrier does not support tracking lookups")
+
+// Label is a purchased shipping label.
+type Label struct {
+	TrackingNumber string
+	Cost           float64
+	Currency       string
+	Data           []byte // The printable label
+	ContentType    string // MIME type of Data
+}
+
+// Carrier is a shipping carrier adapter.
+type Carrier interface {
+	// Name returns the carrier's unique name.
+	Name() string
+	// Rates quotes the carrier's services for a shipment.
+	Rates(shipment *models.Shipment) ([]models.ShippingRate, error)
+	// CreateShipment buys a label for the shipment using the given service.
+	CreateShipment(shipment *models.Shipment, service string) (*Label, error)
+	// Track looks up the tracking history of a parcel.
+	Track(trackingNumber string) ([]models.TrackingEvent, error)
+	// ParseWebhook extracts tracking updates from a webhook payload sent by the carrier.
+	ParseWebhook(payload []byte) ([]models.TrackingEvent, error)
+}
+
+// Carriers maps carrier names to their adapters.
+type Carriers map[string]Carrier
+
+// New creates the carriers enabled by the configuration.
+//
+// Parameters:
+//   - cfg: The shipping section of the application configuration.
+//
+// Returns:
+//   - Carriers: The configured carriers keyed by name.
+//   - error: An error if a carrier is misconfigured.
+func New(cfg config.ShippingConfig) (Carriers, error) {
+	carriers := Carriers{}
+	if cfg.FlatRateCarrier != "" {
+		if cfg.FlatRateBase < 0 || cfg.FlatRatePerKg < 0 {
+			return nil, fmt.Errorf("flat rate carrier %q has negative rates", cfg.FlatRateCarrier)
+		}
+		carriers.Add(NewFlatRateCarrier(cfg.FlatRateCarrier, cfg.Currency, cfg.FlatRateBase, cfg.FlatRatePerKg))
+	}
+	return carriers, nil
+}
+
+// Add registers a carrier under its name.
+func (c Carriers) Add(carrier Carrier) {
+	c[carrier.Name()] = carrier
+}
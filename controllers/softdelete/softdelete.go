@@ -0,0 +1,92 @@
+// Package softdelete holds the rules of soft deletes. DELETE handlers of business records
+// set the record's deleted_at rather than removing the row, and reads skip rows with one.
+// A deleted record can be restored by the users who may delete it, and administrators
+// purge the records deleted before a cutoff for good.
+package softdelete
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Resources are the tables whose records are soft deleted, by the name used in the API.
+var Resources = map[string]bool{
+	"customers":         true,
+	"products":          true,
+	"invoices":          true,
+	"payments":          true,
+	"employees":         true,
+	"warehouses":        true,
+	"receivables":       true,
+	"financial_records": true,
+}
+
+// Service applies the soft delete rules on top of a TrashStore.
+type Service struct {
+	Store models.TrashStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a soft delete service backed by store.
+func NewService(store models.TrashStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// check returns a validation error naming the known resources if resource is not one.
+func check(resource string) error {
+	if Resources[resource] {
+		return nil
+	}
+	names := make([]string, 0, len(Resources))
+	for name := range Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return models.Invalid("resource must be one of %s", strings.Join(names, ", "))
+}
+
+// Restore brings back a deleted record.
+//
+// Returns:
+//   - error: A validation error if the resource is unknown, a not found error if the record
+//     does not exist or is not deleted, a conflict if a live record took its unique values,
+//     or the store's error.
+func (s *Service) Restore(ctx context.Context, resource string, id int) error {
+	if err := check(resource); err != nil {
+		return err
+	}
+	return s.Store.RestoreRecord(ctx, resource, id)
+}
+
+// Deleted returns the deleted records of a resource, most recently deleted first.
+func (s *Service) Deleted(ctx context.Context, resource string) ([]models.DeletedRecord, error) {
+	if err := check(resource); err != nil {
+		return nil, err
+	}
+	return s.Store.ListDeletedRecords(ctx, resource)
+}
+
+// Purge removes the records of a resource deleted before a cutoff, or all of its deleted
+// records if before is zero. Records other records still reference stay in the trash.
+//
+// Returns:
+//   - *models.PurgeResult: How many records were purged and kept.
+//   - error: A validation error if the resource is unknown or the cutoff is in the future,
+//     or the store's error.
+func (s *Service) Purge(ctx context.Context, resource string, before time.Time) (*models.PurgeResult, error) {
+	if err := check(resource); err != nil {
+		return nil, err
+	}
+	now := s.Now()
+	switch {
+	case before.IsZero():
+		before = now
+	case before.After(now):
+		return nil, models.Invalid("deleted_before cannot be in the future")
+	}
+	return s.Store.PurgeRecords(ctx, resource, before)
+}
@@ -0,0 +1,68 @@
+package softdelete
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore keeps the cutoff it is asked to purge with.
+type mockStore struct {
+	before time.Time
+}
+
+func (m *mockStore) RestoreRecord(ctx context.Context, resource string, id int) error {
+	return nil
+}
+
+func (m *mockStore) ListDeletedRecords(ctx context.Context, resource string) ([]models.DeletedRecord, error) {
+	return []models.DeletedRecord{}, nil
+}
+
+func (m *mockStore) PurgeRecords(ctx context.Context, resource string, before time.Time) (*models.PurgeResult, error) {
+	m.before = before
+	return &models.PurgeResult{Resource: resource}, nil
+}
+
+var now = time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)
+
+func newService() (*Service, *mockStore) {
+	store := &mockStore{}
+	service := NewService(store)
+	service.Now = func() time.Time { return now }
+	return service, store
+}
+
+func TestUnknownResourceIsRejected(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newService()
+
+	err := service.Restore(ctx, "users", 1)
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	assert.Contains(t, err.Error(), "customers, employees, financial_records")
+
+	_, err = service.Deleted(ctx, "")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
+
+func TestPurgeDefaultsToEveryDeletedRecord(t *testing.T) {
+	service, store := newService()
+
+	result, err := service.Purge(context.Background(), "customers", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "customers", result.Resource)
+	assert.Equal(t, now, store.before)
+}
+
+func TestPurgeRejectsFutureCutoff(t *testing.T) {
+	service, _ := newService()
+
+	_, err := service.Purge(context.Background(), "invoices", now.AddDate(0, 0, 1))
+	assert.True(t, errors.Is(err, models.ErrValidation))
+}
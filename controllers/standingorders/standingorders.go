@@ -0,0 +1,408 @@
+// Package standingorders runs customers' standing orders: the same lines delivered weekly,
+// every other week or monthly on a set day. Each day, a draft sales order is generated for
+// every delivery whose lead time has begun, unless the delivery was skipped or the standing
+// order is paused. The forecast shows the deliveries coming up and what became of them.
+package standingorders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/controllers/salesorders"
+	"erp/models"
+)
+
+// Forecast lengths, in days
+const (
+	DefaultForecastDays = 28
+	MaxForecastDays     = 366
+)
+
+// MaxLeadDays is the longest lead time a standing order can have.
+const MaxLeadDays = 28
+
+// Service validates standing orders and generates their sales orders.
+type Service struct {
+	Store  models.StandingOrderStore
+	Orders *salesorders.Service // Drafts the generated sales orders
+	Now    func() time.Time     // Clock, replaced in tests
+}
+
+// NewService creates a standing order service.
+func NewService(store models.StandingOrderStore, orders *salesorders.Service) *Service {
+	return &Service{Store: store, Orders: orders, Now: time.Now}
+}
+
+// Create checks a standing order and records it as active.
+//
+// Parameters:
+//   - order: The order; its schedule, Note and Lines are read, and StartDate is today if
+//     unset. The ID, status and times are set.
+//   - actor: Email of the user creating it.
+//
+// Returns:
+//   - error: A validation error if the order is incomplete or names a customer or product
+//     that does not exist, or the store's error.
+func (s *Service) Create(ctx context.Context, order *models.StandingOrder, actor string) error {
+	now := s.Now()
+	if order.StartDate.IsZero() {
+		order.StartDate = now
+	}
+	if err := Validate(order); err != nil {
+		return err
+	}
+	order.Status, order.PausedUntil = models.StandingOrderActive, nil
+	order.CreatedBy, order.CreatedAt, order.UpdatedAt = actor, now, now
+	return s.Store.CreateStandingOrder(ctx, order)
+}
+
+// Update replaces a standing order's schedule and lines; its status is kept. Without a
+// start date, the current one is kept. Sales orders already generated are not changed.
+func (s *Service) Update(ctx context.Context, order *models.StandingOrder) (*models.StandingOrder, error) {
+	current, err := s.Store.GetStandingOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if order.StartDate.IsZero() {
+		order.StartDate = current.StartDate
+	}
+	if err := Validate(order); err != nil {
+		return nil, err
+	}
+	order.UpdatedAt = s.Now()
+	if err := s.Store.UpdateStandingOrder(ctx, order); err != nil {
+		return nil, err
+	}
+	return s.Store.GetStandingOrder(ctx, order.ID)
+}
+
+// Get returns a standing order with its lines.
+func (s *Service) Get(ctx context.Context, id int) (*models.StandingOrder, error) {
+	return s.Store.GetStandingOrder(ctx, id)
+}
+
+// List returns the standing orders of a customer, or every standing order if customerID is 0.
+func (s *Service) List(ctx context.Context, customerID int) ([]models.StandingOrder, error) {
+	return s.Store.ListStandingOrders(ctx, customerID)
+}
+
+// Delete removes a standing order. The sales orders it generated are kept.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	return s.Store.DeleteStandingOrder(ctx, id)
+}
+
+// Pause stops a standing order's deliveries until it is resumed or, if until is given,
+// until that day.
+func (s *Service) Pause(ctx context.Context, id int, until *time.Time) (*models.StandingOrder, error) {
+	if until != nil {
+		day := date(*until)
+		if !day.After(date(s.Now())) {
+			return nil, models.Invalid("paused_until must be after today")
+		}
+		until = &day
+	}
+	return s.Store.SetStandingOrderStatus(ctx, id, models.StandingOrderPaused, until)
+}
+
+// Resume makes a paused standing order's deliveries again, from the next one due.
+func (s *Service) Resume(ctx context.Context, id int) (*models.StandingOrder, error) {
+	return s.Store.SetStandingOrderStatus(ctx, id, models.StandingOrderActive, nil)
+}
+
+// Skip cancels a single upcoming delivery of a standing order.
+//
+// Parameters:
+//   - id: The ID of the standing order.
+//   - deliveryDate: The day of the delivery.
+//   - reason: Why it is skipped, optional.
+//   - actor: Email of the user skipping it.
+//
+// Returns:
+//   - *models.StandingOrderSkip: The skip recorded.
+//   - error: models.ErrNotFound if the standing order does not exist, a validation error if
+//     no delivery falls on the day or it is in the past, a conflict if the delivery is
+//     already skipped or its sales order has been generated, or the store's error.
+func (s *Service) Skip(ctx context.Context, id int, deliveryDate time.Time, reason, actor string) (*models.StandingOrderSkip, error) {
+	order, err := s.Store.GetStandingOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.Now()
+	day := date(deliveryDate)
+	if day.Before(date(now)) {
+		return nil, models.Invalid("delivery_date %s is in the past", day.Format("2006-01-02"))
+	}
+	if dates := Dates(*order, day, day); len(dates) == 0 {
+		return nil, models.Invalid("standing order %d has no delivery on %s", id, day.Format("2006-01-02"))
+	}
+	recorded, err := s.Store.RecordedDeliveries(ctx, id, day, day)
+	if err != nil {
+		return nil, err
+	}
+	for _, delivery := range recorded {
+		if delivery.Status == models.DeliveryGenerated {
+			return nil, models.Conflict("the delivery on %s is already sales order %d; cancel that order instead",
+				day.Format("2006-01-02"), *delivery.SalesOrderID)
+		}
+	}
+	skip := &models.StandingOrderSkip{StandingOrderID: id, DeliveryDate: day, Reason: strings.TrimSpace(reason),
+		SkippedBy: actor, SkippedAt: now}
+	if err := s.Store.SkipDelivery(ctx, skip); err != nil {
+		return nil, err
+	}
+	return skip, nil
+}
+
+// Unskip restores a skipped delivery. If its lead time has begun, its sales order is
+// generated on the next run.
+func (s *Service) Unskip(ctx context.Context, id int, deliveryDate time.Time) error {
+	return s.Store.UnskipDelivery(ctx, id, date(deliveryDate))
+}
+
+// Forecast returns the deliveries of the coming days, from today, by delivery date.
+//
+// Parameters:
+//   - standingOrderID: The standing order, or 0 for every standing order.
+//   - days: The number of days covered; 0 for DefaultForecastDays.
+//
+// Returns:
+//   - []models.StandingDelivery: Every delivery, scheduled, skipped, paused or generated.
+//   - error: A validation error if days is out of range, models.ErrNotFound if the
+//     standing order does not exist, or the store's error.
+func (s *Service) Forecast(ctx context.Context, standingOrderID, days int) ([]models.StandingDelivery, error) {
+	if days == 0 {
+		days = DefaultForecastDays
+	}
+	if days < 0 || days > MaxForecastDays {
+		return nil, models.Invalid("days must be from 1 to %d", MaxForecastDays)
+	}
+	var orders []models.StandingOrder
+	if standingOrderID > 0 {
+		order, err := s.Store.GetStandingOrder(ctx, standingOrderID)
+		if err != nil {
+			return nil, err
+		}
+		orders = []models.StandingOrder{*order}
+	} else {
+		var err error
+		if orders, err = s.Store.ListStandingOrders(ctx, 0); err != nil {
+			return nil, err
+		}
+	}
+	from := date(s.Now())
+	to := from.AddDate(0, 0, days-1)
+	recorded, err := s.Store.RecordedDeliveries(ctx, standingOrderID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return Plan(orders, recorded, from, to), nil
+}
+
+// Generate drafts a sales order for every scheduled delivery, from today on, whose lead
+// time has begun. Deliveries another run generated in the meantime are skipped.
+//
+// Parameters:
+//   - actor: Email of the user, or "scheduler".
+//
+// Returns:
+//   - []models.SalesOrder: The sales orders drafted.
+//   - error: The errors of deliveries whose order could not be drafted, joined; the
+//     others are drafted.
+func (s *Service) Generate(ctx context.Context, actor string) ([]models.SalesOrder, error) {
+	orders, err := s.Store.ListStandingOrders(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	today := date(s.Now())
+	to := today.AddDate(0, 0, MaxLeadDays)
+	recorded, err := s.Store.RecordedDeliveries(ctx, 0, today, to)
+	if err != nil {
+		return nil, err
+	}
+
+	generated := []models.SalesOrder{}
+	var errs []error
+	for _, delivery := range Plan(orders, recorded, today, to) {
+		if delivery.Status != models.DeliveryScheduled || delivery.OrderDate.After(today) {
+			continue
+		}
+		standingOrderID, deliveryDate := delivery.StandingOrderID, delivery.DeliveryDate
+		order := models.SalesOrder{CustomerID: delivery.CustomerID, StandingOrderID: &standingOrderID,
+			DeliveryDate: &deliveryDate, Note: fmt.Sprintf("Standing order %d", standingOrderID)}
+		for _, line := range delivery.Lines {
+			order.Lines = append(order.Lines, models.SalesOrderLine{ProductID: line.ProductID, Quantity: line.Quantity,
+				UnitPrice: line.UnitPrice})
+		}
+		err := s.Orders.Create(ctx, &order, actor)
+		switch {
+		case models.Kind(err) == models.ErrConflict:
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("standing order %d delivery on %s: %w", standingOrderID,
+				deliveryDate.Format("2006-01-02"), err))
+		default:
+			generated = append(generated, order)
+		}
+	}
+	return generated, errors.Join(errs...)
+}
+
+// Daily generates the sales orders due; it is run by the scheduler.
+func (s *Service) Daily(ctx context.Context) error {
+	_, err := s.Generate(ctx, "scheduler")
+	return err
+}
+
+// Validate checks a standing order's customer, schedule and lines. Dates are truncated to
+// the day and unit prices rounded to cents.
+func Validate(order *models.StandingOrder) error {
+	order.Note = strings.TrimSpace(order.Note)
+	switch order.Recurrence {
+	case models.StandingOrderWeekly, models.StandingOrderBiweekly:
+		if order.DeliveryDay < 0 || order.DeliveryDay > 6 {
+			return models.Invalid("delivery_day must be a weekday from 0 (Sunday) to 6 (Saturday)")
+		}
+	case models.StandingOrderMonthly:
+		if order.DeliveryDay < 1 || order.DeliveryDay > 28 {
+			return models.Invalid("delivery_day must be a day of the month from 1 to 28")
+		}
+	default:
+		return models.Invalid("recurrence must be %q, %q or %q",
+			models.StandingOrderWeekly, models.StandingOrderBiweekly, models.StandingOrderMonthly)
+	}
+	switch {
+	case order.CustomerID <= 0:
+		return models.Invalid("customer_id is required")
+	case order.LeadDays < 0 || order.LeadDays > MaxLeadDays:
+		return models.Invalid("lead_days must be from 0 to %d", MaxLeadDays)
+	case len(order.Lines) == 0:
+		return models.Invalid("a standing order needs at least one line")
+	}
+	order.StartDate = date(order.StartDate)
+	if order.EndDate != nil {
+		end := date(*order.EndDate)
+		if end.Before(order.StartDate) {
+			return models.Invalid("end_date must not be before start_date")
+		}
+		order.EndDate = &end
+	}
+
+	seen := make(map[int]bool, len(order.Lines))
+	for i := range order.Lines {
+		line := &order.Lines[i]
+		switch {
+		case line.ProductID <= 0:
+			return models.Invalid("line %d has no product", i+1)
+		case line.Quantity <= 0:
+			return models.Invalid("line %d must have a positive quantity", i+1)
+		case line.UnitPrice < 0:
+			return models.Invalid("line %d: unit_price cannot be negative", i+1)
+		case seen[line.ProductID]:
+			return models.Invalid("product %d is listed twice", line.ProductID)
+		}
+		seen[line.ProductID] = true
+		line.UnitPrice = math.Round(line.UnitPrice*100) / 100
+	}
+	return nil
+}
+
+// Plan lists the deliveries of standing orders from from to to, inclusive, by delivery
+// date and then standing order. Deliveries that were skipped or generated take their
+// recorded status; the others are paused if they fall while their order is paused, and
+// scheduled otherwise.
+func Plan(orders []models.StandingOrder, recorded []models.StandingDelivery, from, to time.Time) []models.StandingDelivery {
+	type key struct {
+		id  int
+		day string
+	}
+	outcomes := make(map[key]models.StandingDelivery, len(recorded))
+	for _, delivery := range recorded {
+		outcomes[key{delivery.StandingOrderID, delivery.DeliveryDate.Format("2006-01-02")}] = delivery
+	}
+
+	deliveries := []models.StandingDelivery{}
+	for _, order := range orders {
+		for _, day := range Dates(order, from, to) {
+			delivery := models.StandingDelivery{
+				StandingOrderID: order.ID,
+				CustomerID:      order.CustomerID,
+				DeliveryDate:    day,
+				OrderDate:       day.AddDate(0, 0, -order.LeadDays),
+				Status:          models.DeliveryScheduled,
+				Lines:           order.Lines,
+			}
+			if outcome, ok := outcomes[key{order.ID, day.Format("2006-01-02")}]; ok {
+				delivery.Status, delivery.SalesOrderID, delivery.Reason = outcome.Status, outcome.SalesOrderID, outcome.Reason
+			} else if Paused(order, day) {
+				delivery.Status = models.DeliveryPaused
+			}
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		if !deliveries[i].DeliveryDate.Equal(deliveries[j].DeliveryDate) {
+			return deliveries[i].DeliveryDate.Before(deliveries[j].DeliveryDate)
+		}
+		return deliveries[i].StandingOrderID < deliveries[j].StandingOrderID
+	})
+	return deliveries
+}
+
+// Dates returns the days from from to to, inclusive, that a standing order delivers on,
+// within its start and end dates.
+func Dates(order models.StandingOrder, from, to time.Time) []time.Time {
+	start := date(order.StartDate)
+	from, to = date(from), date(to)
+	if from.Before(start) {
+		from = start
+	}
+	if order.EndDate != nil && to.After(date(*order.EndDate)) {
+		to = date(*order.EndDate)
+	}
+	// A biweekly order delivers on its first delivery day from the start date, and every
+	// fourteen days after it
+	first := start.AddDate(0, 0, (order.DeliveryDay-int(start.Weekday())+7)%7)
+
+	var dates []time.Time
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		switch order.Recurrence {
+		case models.StandingOrderWeekly:
+			if int(day.Weekday()) == order.DeliveryDay {
+				dates = append(dates, day)
+			}
+		case models.StandingOrderBiweekly:
+			if int(day.Weekday()) == order.DeliveryDay && days(first, day)%14 == 0 {
+				dates = append(dates, day)
+			}
+		case models.StandingOrderMonthly:
+			if day.Day() == order.DeliveryDay {
+				dates = append(dates, day)
+			}
+		}
+	}
+	return dates
+}
+
+// Paused reports whether a standing order is paused on a day.
+func Paused(order models.StandingOrder, day time.Time) bool {
+	if order.Status != models.StandingOrderPaused {
+		return false
+	}
+	return order.PausedUntil == nil || date(day).Before(date(*order.PausedUntil))
+}
+
+// days counts the days from one midnight to another.
+func days(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+// date returns the day of t at midnight UTC, so that days can be counted across zones.
+func date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,212 @@
+package standingorders
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/controllers/salesorders"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStandingOrderStore holds standing orders and the deliveries recorded for them.
+type memoryStandingOrderStore struct {
+	models.StandingOrderStore
+	orders   []models.StandingOrder
+	recorded []models.StandingDelivery
+	skips    []models.StandingOrderSkip
+}
+
+func (m *memoryStandingOrderStore) GetStandingOrder(ctx context.Context, id int) (*models.StandingOrder, error) {
+	for i := range m.orders {
+		if m.orders[i].ID == id {
+			return &m.orders[i], nil
+		}
+	}
+	return nil, models.NotFound("standing order %d not found", id)
+}
+
+func (m *memoryStandingOrderStore) ListStandingOrders(ctx context.Context, customerID int) ([]models.StandingOrder, error) {
+	return m.orders, nil
+}
+
+func (m *memoryStandingOrderStore) RecordedDeliveries(ctx context.Context, standingOrderID int, from, to time.Time) ([]models.StandingDelivery, error) {
+	var found []models.StandingDelivery
+	for _, delivery := range m.recorded {
+		if (standingOrderID == 0 || delivery.StandingOrderID == standingOrderID) &&
+			!delivery.DeliveryDate.Before(from) && !delivery.DeliveryDate.After(to) {
+			found = append(found, delivery)
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryStandingOrderStore) SkipDelivery(ctx context.Context, skip *models.StandingOrderSkip) error {
+	m.skips = append(m.skips, *skip)
+	return nil
+}
+
+// memorySalesOrderStore records the sales orders drafted, refusing a second order for a
+// standing order's delivery as the database does.
+type memorySalesOrderStore struct {
+	models.SalesOrderStore
+	orders []models.SalesOrder
+}
+
+func (m *memorySalesOrderStore) CreateSalesOrder(ctx context.Context, order *models.SalesOrder) error {
+	for _, existing := range m.orders {
+		if existing.StandingOrderID != nil && order.StandingOrderID != nil &&
+			*existing.StandingOrderID == *order.StandingOrderID && existing.DeliveryDate.Equal(*order.DeliveryDate) {
+			return models.Conflict("delivery already has a sales order")
+		}
+	}
+	order.ID = len(m.orders) + 1
+	m.orders = append(m.orders, *order)
+	return nil
+}
+
+func day(value string) time.Time {
+	t, _ := time.Parse("2006-01-02", value)
+	return t
+}
+
+func newService(store *memoryStandingOrderStore, orders *memorySalesOrderStore, today string) *Service {
+	now := day(today).Add(6 * time.Hour)
+	service := NewService(store, &salesorders.Service{Store: orders, Now: func() time.Time { return now }})
+	service.Now = func() time.Time { return now }
+	return service
+}
+
+func TestDatesFollowRecurrence(t *testing.T) {
+	// 2026-10-14 is a Wednesday
+	weekly := models.StandingOrder{Recurrence: models.StandingOrderWeekly, DeliveryDay: 1, StartDate: day("2026-10-14")}
+	assert.Equal(t, []time.Time{day("2026-10-19"), day("2026-10-26"), day("2026-11-02")},
+		Dates(weekly, day("2026-10-01"), day("2026-11-08")))
+
+	// Every other Monday from the first Monday after the start
+	biweekly := weekly
+	biweekly.Recurrence = models.StandingOrderBiweekly
+	assert.Equal(t, []time.Time{day("2026-11-02"), day("2026-11-16")},
+		Dates(biweekly, day("2026-10-27"), day("2026-11-22")))
+
+	end := day("2026-12-31")
+	monthly := models.StandingOrder{Recurrence: models.StandingOrderMonthly, DeliveryDay: 15, StartDate: day("2026-10-16"),
+		EndDate: &end}
+	assert.Equal(t, []time.Time{day("2026-11-15"), day("2026-12-15")},
+		Dates(monthly, day("2026-10-01"), day("2027-03-01")))
+}
+
+func TestValidateChecksSchedule(t *testing.T) {
+	order := &models.StandingOrder{CustomerID: 4, Recurrence: models.StandingOrderMonthly, DeliveryDay: 30,
+		Lines: []models.StandingOrderLine{{ProductID: 1, Quantity: 10}}}
+	err := Validate(order)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Contains(t, err.Error(), "from 1 to 28")
+
+	order.DeliveryDay = 1
+	order.Lines = append(order.Lines, models.StandingOrderLine{ProductID: 1, Quantity: 2})
+	err = Validate(order)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.Contains(t, err.Error(), "product 1 is listed twice")
+}
+
+func TestGenerateDraftsDeliveriesWithinLeadTime(t *testing.T) {
+	ctx := context.Background()
+	lines := []models.StandingOrderLine{{ProductID: 3, Quantity: 12, UnitPrice: 1.5}}
+	until := day("2026-10-21")
+	generatedID := 40
+	store := &memoryStandingOrderStore{
+		orders: []models.StandingOrder{
+			// Mondays with two days' lead: the delivery on the 19th is drafted on the 17th
+			{ID: 1, CustomerID: 4, Recurrence: models.StandingOrderWeekly, DeliveryDay: 1, LeadDays: 2,
+				StartDate: day("2026-10-01"), Status: models.StandingOrderActive, Lines: lines},
+			// Saturdays, but the delivery today was skipped
+			{ID: 2, CustomerID: 5, Recurrence: models.StandingOrderWeekly, DeliveryDay: 6,
+				StartDate: day("2026-10-01"), Status: models.StandingOrderActive, Lines: lines},
+			// Sundays, paused until the 21st
+			{ID: 3, CustomerID: 6, Recurrence: models.StandingOrderWeekly, DeliveryDay: 0,
+				StartDate: day("2026-10-01"), Status: models.StandingOrderPaused, PausedUntil: &until, Lines: lines},
+			// Tuesdays with five days' lead, already generated
+			{ID: 4, CustomerID: 7, Recurrence: models.StandingOrderWeekly, DeliveryDay: 2, LeadDays: 5,
+				StartDate: day("2026-10-01"), Status: models.StandingOrderActive, Lines: lines},
+		},
+		recorded: []models.StandingDelivery{
+			{StandingOrderID: 2, DeliveryDate: day("2026-10-17"), Status: models.DeliverySkipped},
+			{StandingOrderID: 4, DeliveryDate: day("2026-10-20"), Status: models.DeliveryGenerated, SalesOrderID: &generatedID},
+		},
+	}
+	orders := &memorySalesOrderStore{}
+	service := newService(store, orders, "2026-10-17")
+
+	generated, err := service.Generate(ctx, "scheduler")
+	require.NoError(t, err)
+	require.Len(t, generated, 1)
+	assert.Equal(t, 4, generated[0].CustomerID)
+	assert.Equal(t, 1, *generated[0].StandingOrderID)
+	assert.Equal(t, day("2026-10-19"), *generated[0].DeliveryDate)
+	assert.Equal(t, models.SalesOrderDraft, generated[0].Status)
+	assert.Equal(t, []models.SalesOrderLine{{ProductID: 3, Quantity: 12, UnitPrice: 1.5, Amount: 18}}, generated[0].Lines)
+
+	// A second run finds the delivery taken
+	generated, err = service.Generate(ctx, "scheduler")
+	require.NoError(t, err)
+	assert.Empty(t, generated)
+	assert.Len(t, orders.orders, 1)
+}
+
+func TestForecastMarksWhatBecameOfDeliveries(t *testing.T) {
+	ctx := context.Background()
+	until := day("2026-10-26")
+	store := &memoryStandingOrderStore{
+		orders: []models.StandingOrder{
+			{ID: 1, CustomerID: 4, Recurrence: models.StandingOrderWeekly, DeliveryDay: 1, StartDate: day("2026-10-01"),
+				Status: models.StandingOrderPaused, PausedUntil: &until},
+		},
+		recorded: []models.StandingDelivery{
+			{StandingOrderID: 1, DeliveryDate: day("2026-11-02"), Status: models.DeliverySkipped, Reason: "Closed for stocktake"},
+		},
+	}
+	service := newService(store, &memorySalesOrderStore{}, "2026-10-17")
+
+	forecast, err := service.Forecast(ctx, 1, 21)
+	require.NoError(t, err)
+	require.Len(t, forecast, 3)
+	assert.Equal(t, models.DeliveryPaused, forecast[0].Status)
+	assert.Equal(t, models.DeliveryScheduled, forecast[1].Status)
+	assert.Equal(t, day("2026-10-26"), forecast[1].DeliveryDate)
+	assert.Equal(t, models.DeliverySkipped, forecast[2].Status)
+	assert.Equal(t, "Closed for stocktake", forecast[2].Reason)
+
+	_, err = service.Forecast(ctx, 1, MaxForecastDays+1)
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestSkipNeedsAnUpcomingDelivery(t *testing.T) {
+	ctx := context.Background()
+	salesOrderID := 40
+	store := &memoryStandingOrderStore{
+		orders: []models.StandingOrder{
+			{ID: 1, CustomerID: 4, Recurrence: models.StandingOrderWeekly, DeliveryDay: 1, StartDate: day("2026-10-01"),
+				Status: models.StandingOrderActive},
+		},
+		recorded: []models.StandingDelivery{
+			{StandingOrderID: 1, DeliveryDate: day("2026-10-19"), Status: models.DeliveryGenerated, SalesOrderID: &salesOrderID},
+		},
+	}
+	service := newService(store, &memorySalesOrderStore{}, "2026-10-17")
+
+	_, err := service.Skip(ctx, 1, day("2026-10-20"), "", "sales@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = service.Skip(ctx, 1, day("2026-10-12"), "", "sales@example.com")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = service.Skip(ctx, 1, day("2026-10-19"), "", "sales@example.com")
+	assert.ErrorIs(t, err, models.ErrConflict)
+
+	skip, err := service.Skip(ctx, 1, day("2026-10-26"), " Holiday ", "sales@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Holiday", skip.Reason)
+	assert.Len(t, store.skips, 1)
+}
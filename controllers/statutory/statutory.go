@@ -0,0 +1,283 @@
+// Package statutory works out the deductions the law requires from wages: income tax by
+// slabs, provident fund and insurance. The deductions are configured, each employee's
+// deductions are recorded per pay period together with what the employer contributes, and
+// the records of a period are totalled for remittance to the authorities.
+package statutory
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// periodLayout is the format of a pay period.
+const periodLayout = "2006-01"
+
+// BenefitSource supplies the payroll lines of an employee's benefit elections for a pay
+// period, typically the benefits service.
+type BenefitSource interface {
+	PayrollLines(ctx context.Context, userID int, period string) ([]models.StatutoryLine, error)
+}
+
+// Service validates statutory deductions and records them for pay periods.
+type Service struct {
+	Store    models.StatutoryStore
+	Now      func() time.Time // Clock, replaced in tests
+	Benefits BenefitSource    // Optional; adds the employee's benefits to their records
+}
+
+// NewService creates a statutory deduction service.
+func NewService(store models.StatutoryStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Deductions returns the configured deductions.
+func (s *Service) Deductions(ctx context.Context) ([]models.StatutoryDeduction, error) {
+	return s.Store.GetStatutoryDeductions(ctx)
+}
+
+// CreateDeduction checks a deduction and saves it.
+//
+// Parameters:
+//   - deduction: The deduction; its ID and update time are set.
+//   - actor: Email of the user creating it.
+//
+// Returns:
+//   - error: A validation error if the deduction is incomplete, a conflict if its code is
+//     taken, or the store's error.
+func (s *Service) CreateDeduction(ctx context.Context, deduction *models.StatutoryDeduction, actor string) error {
+	if err := Validate(deduction); err != nil {
+		return err
+	}
+	deduction.UpdatedBy, deduction.UpdatedAt = actor, s.Now()
+	return s.Store.CreateStatutoryDeduction(ctx, deduction)
+}
+
+// UpdateDeduction checks a deduction and replaces the stored one. Periods already recorded
+// are not changed.
+func (s *Service) UpdateDeduction(ctx context.Context, deduction *models.StatutoryDeduction, actor string) error {
+	if err := Validate(deduction); err != nil {
+		return err
+	}
+	deduction.UpdatedBy, deduction.UpdatedAt = actor, s.Now()
+	return s.Store.UpdateStatutoryDeduction(ctx, deduction)
+}
+
+// DeleteDeduction removes a deduction. Periods already recorded are not changed.
+func (s *Service) DeleteDeduction(ctx context.Context, id int) error {
+	return s.Store.DeleteStatutoryDeduction(ctx, id)
+}
+
+// Validate checks a deduction's code, name, kind and rates. Fields that do not apply to its
+// kind are cleared.
+func Validate(deduction *models.StatutoryDeduction) error {
+	deduction.Code = strings.TrimSpace(deduction.Code)
+	deduction.Name = strings.TrimSpace(deduction.Name)
+	switch {
+	case deduction.Code == "" || deduction.Name == "":
+		return models.Invalid("code and name are required")
+	case !validRate(deduction.EmployeeRate) || !validRate(deduction.EmployerRate):
+		return models.Invalid("employee_rate and employer_rate must be percentages from 0 to 100")
+	case deduction.Ceiling < 0:
+		return models.Invalid("ceiling cannot be negative")
+	}
+
+	switch deduction.Kind {
+	case models.StatutoryIncomeTax:
+		if len(deduction.Slabs) == 0 {
+			return models.Invalid("income tax needs at least one slab")
+		}
+		for i, slab := range deduction.Slabs {
+			last := i == len(deduction.Slabs)-1
+			switch {
+			case !validRate(slab.Rate):
+				return models.Invalid("slab %d: rate must be a percentage from 0 to 100", i+1)
+			case last && slab.UpTo != 0:
+				return models.Invalid("the last slab must have no upper bound (up_to 0)")
+			case !last && (slab.UpTo <= 0 || (i > 0 && slab.UpTo <= deduction.Slabs[i-1].UpTo)):
+				return models.Invalid("slab %d: up_to must be above the previous slab's", i+1)
+			}
+		}
+		deduction.EmployeeRate, deduction.EmployerRate, deduction.Ceiling = 0, 0, 0
+	case models.StatutoryProvidentFund, models.StatutoryInsurance:
+		if deduction.EmployeeRate == 0 && deduction.EmployerRate == 0 {
+			return models.Invalid("employee_rate or employer_rate is required")
+		}
+		deduction.Slabs = nil
+	default:
+		return models.Invalid("kind must be %q, %q or %q",
+			models.StatutoryIncomeTax, models.StatutoryProvidentFund, models.StatutoryInsurance)
+	}
+	return nil
+}
+
+// Preview works out the deductions from a monthly gross wage without recording them.
+func (s *Service) Preview(ctx context.Context, gross float64) (*models.StatutoryRecord, error) {
+	if gross < 0 {
+		return nil, models.Invalid("gross cannot be negative")
+	}
+	deductions, err := s.Store.GetStatutoryDeductions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record := Compute(deductions, gross)
+	return &record, nil
+}
+
+// Record works out an employee's deductions for a pay period, with the benefits they elected,
+// and records them, replacing any recorded before for the period.
+//
+// Parameters:
+//   - userID: The employee.
+//   - period: The pay period, formatted as YYYY-MM.
+//   - gross: The employee's gross wage for the period.
+//   - actor: Email of the user recording it.
+//
+// Returns:
+//   - *models.StatutoryRecord: The deductions recorded.
+//   - error: A validation error if the period or gross is invalid, models.ErrNotFound if
+//     the employee does not exist, or the store's error.
+func (s *Service) Record(ctx context.Context, userID int, period string, gross float64, actor string) (*models.StatutoryRecord, error) {
+	if _, err := time.Parse(periodLayout, period); err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	if userID <= 0 {
+		return nil, models.Invalid("user_id is required")
+	}
+	record, err := s.Preview(ctx, gross)
+	if err != nil {
+		return nil, err
+	}
+	if s.Benefits != nil {
+		lines, err := s.Benefits.PayrollLines(ctx, userID, period)
+		if err != nil {
+			return nil, err
+		}
+		AddLines(record, lines)
+	}
+	record.UserID, record.Period, record.RecordedBy, record.RecordedAt = userID, period, actor, s.Now()
+	if err := s.Store.SaveStatutoryRecord(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Records lists the deductions recorded for a period; userID 0 lists every employee's.
+func (s *Service) Records(ctx context.Context, period string, userID int) ([]models.StatutoryRecord, error) {
+	if _, err := time.Parse(periodLayout, period); err != nil {
+		return nil, models.Invalid("period must be formatted as YYYY-MM")
+	}
+	return s.Store.GetStatutoryRecords(ctx, period, userID)
+}
+
+// Remittance totals the deductions recorded for a period by deduction: what was withheld
+// from employees and what the employer contributes, to be paid to the authorities.
+func (s *Service) Remittance(ctx context.Context, period string) (*models.RemittanceReport, error) {
+	records, err := s.Records(ctx, period, 0)
+	if err != nil {
+		return nil, err
+	}
+	return Remit(period, records), nil
+}
+
+// Compute works out the active deductions from a monthly gross wage.
+func Compute(deductions []models.StatutoryDeduction, gross float64) models.StatutoryRecord {
+	gross = roundCents(gross)
+	record := models.StatutoryRecord{Gross: gross, Lines: []models.StatutoryLine{}}
+	for _, deduction := range deductions {
+		if !deduction.Active {
+			continue
+		}
+		line := models.StatutoryLine{Code: deduction.Code, Name: deduction.Name, Kind: deduction.Kind, Base: gross}
+		if deduction.Kind == models.StatutoryIncomeTax {
+			line.Employee = roundCents(AnnualTax(deduction.Slabs, gross*12) / 12)
+		} else {
+			if deduction.Ceiling > 0 && line.Base > deduction.Ceiling {
+				line.Base = deduction.Ceiling
+			}
+			line.Employee = roundCents(line.Base * deduction.EmployeeRate / 100)
+			line.Employer = roundCents(line.Base * deduction.EmployerRate / 100)
+		}
+		record.Lines = append(record.Lines, line)
+		record.TotalEmployee = roundCents(record.TotalEmployee + line.Employee)
+		record.TotalEmployer = roundCents(record.TotalEmployer + line.Employer)
+	}
+	record.Net = roundCents(gross - record.TotalEmployee)
+	return record
+}
+
+// AddLines adds lines, such as benefits, to a record and updates its totals and net pay.
+func AddLines(record *models.StatutoryRecord, lines []models.StatutoryLine) {
+	for _, line := range lines {
+		record.Lines = append(record.Lines, line)
+		record.TotalEmployee = roundCents(record.TotalEmployee + line.Employee)
+		record.TotalEmployer = roundCents(record.TotalEmployer + line.Employer)
+	}
+	record.Net = roundCents(record.Gross - record.TotalEmployee)
+}
+
+// AnnualTax works out the tax on an annual income: each slab's rate applies to the part of
+// the income between the previous slab's bound and its own.
+func AnnualTax(slabs []models.TaxSlab, income float64) float64 {
+	tax, lower := 0.0, 0.0
+	for _, slab := range slabs {
+		upper := slab.UpTo
+		if upper == 0 || upper > income {
+			upper = income
+		}
+		if upper > lower {
+			tax += (upper - lower) * slab.Rate / 100
+		}
+		if slab.UpTo == 0 || slab.UpTo >= income {
+			break
+		}
+		lower = slab.UpTo
+	}
+	return tax
+}
+
+// Remit totals the records of a period by deduction code, in code order. Benefit lines are
+// paid to the plan providers, so they are left out.
+func Remit(period string, records []models.StatutoryRecord) *models.RemittanceReport {
+	report := &models.RemittanceReport{Period: period, Employees: len(records), Lines: []models.RemittanceLine{}}
+	byCode := map[string]*models.RemittanceLine{}
+	for _, record := range records {
+		for _, line := range record.Lines {
+			if line.Kind == models.StatutoryBenefit {
+				continue
+			}
+			total := byCode[line.Code]
+			if total == nil {
+				total = &models.RemittanceLine{Code: line.Code, Name: line.Name, Kind: line.Kind}
+				byCode[line.Code] = total
+			}
+			total.Employees++
+			total.Wages = roundCents(total.Wages + line.Base)
+			total.Employee = roundCents(total.Employee + line.Employee)
+			total.Employer = roundCents(total.Employer + line.Employer)
+			total.Total = roundCents(total.Employee + total.Employer)
+		}
+	}
+	for _, line := range byCode {
+		report.Lines = append(report.Lines, *line)
+		report.TotalEmployee = roundCents(report.TotalEmployee + line.Employee)
+		report.TotalEmployer = roundCents(report.TotalEmployer + line.Employer)
+	}
+	sort.Slice(report.Lines, func(i, j int) bool { return report.Lines[i].Code < report.Lines[j].Code })
+	report.Total = roundCents(report.TotalEmployee + report.TotalEmployer)
+	return report
+}
+
+// validRate reports whether a rate is a percentage from 0 to 100.
+func validRate(rate float64) bool {
+	return rate >= 0 && rate <= 100
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
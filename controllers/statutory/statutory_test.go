@@ -0,0 +1,175 @@
+package statutory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps deductions and records in memory.
+type fakeStore struct {
+	deductions []models.StatutoryDeduction
+	records    []models.StatutoryRecord
+}
+
+func (f *fakeStore) CreateStatutoryDeduction(ctx context.Context, deduction *models.StatutoryDeduction) error {
+	deduction.ID = len(f.deductions) + 1
+	f.deductions = append(f.deductions, *deduction)
+	return nil
+}
+
+func (f *fakeStore) GetStatutoryDeductions(ctx context.Context) ([]models.StatutoryDeduction, error) {
+	return f.deductions, nil
+}
+
+func (f *fakeStore) UpdateStatutoryDeduction(ctx context.Context, deduction *models.StatutoryDeduction) error {
+	return nil
+}
+
+func (f *fakeStore) DeleteStatutoryDeduction(ctx context.Context, id int) error { return nil }
+
+func (f *fakeStore) SaveStatutoryRecord(ctx context.Context, record *models.StatutoryRecord) error {
+	f.records = append(f.records, *record)
+	return nil
+}
+
+func (f *fakeStore) GetStatutoryRecords(ctx context.Context, period string, userID int) ([]models.StatutoryRecord, error) {
+	return f.records, nil
+}
+
+var (
+	incomeTax = models.StatutoryDeduction{Code: "IT", Name: "Income tax", Kind: models.StatutoryIncomeTax, Active: true,
+		Slabs: []models.TaxSlab{{UpTo: 12000, Rate: 0}, {UpTo: 48000, Rate: 10}, {Rate: 20}}}
+	providentFund = models.StatutoryDeduction{Code: "PF", Name: "Provident fund", Kind: models.StatutoryProvidentFund,
+		EmployeeRate: 10, EmployerRate: 12, Ceiling: 3000, Active: true}
+	insurance = models.StatutoryDeduction{Code: "SI", Name: "Social insurance", Kind: models.StatutoryInsurance,
+		EmployeeRate: 1.5, EmployerRate: 3.25, Active: true}
+)
+
+func TestAnnualTax(t *testing.T) {
+	slabs := incomeTax.Slabs
+	assert.Equal(t, 0.0, AnnualTax(slabs, 10000))
+	assert.InDelta(t, 800, AnnualTax(slabs, 20000), 0.001)
+	assert.InDelta(t, 3600+0.2*12000, AnnualTax(slabs, 60000), 0.001)
+	assert.InDelta(t, 3600, AnnualTax(slabs, 48000), 0.001, "income at a slab bound is taxed only up to it")
+}
+
+func TestCompute(t *testing.T) {
+	record := Compute([]models.StatutoryDeduction{incomeTax, providentFund, insurance}, 5000)
+
+	require.Len(t, record.Lines, 3)
+	assert.Equal(t, 500.0, record.Lines[0].Employee, "tax on 60000 a year is 6000, 500 a month")
+	assert.Equal(t, 0.0, record.Lines[0].Employer)
+	assert.Equal(t, 3000.0, record.Lines[1].Base, "provident fund applies up to the ceiling")
+	assert.Equal(t, 300.0, record.Lines[1].Employee)
+	assert.Equal(t, 360.0, record.Lines[1].Employer)
+	assert.Equal(t, 5000.0, record.Lines[2].Base)
+	assert.Equal(t, 75.0, record.Lines[2].Employee)
+	assert.Equal(t, 162.5, record.Lines[2].Employer)
+	assert.Equal(t, 875.0, record.TotalEmployee)
+	assert.Equal(t, 522.5, record.TotalEmployer)
+	assert.Equal(t, 4125.0, record.Net)
+}
+
+func TestComputeSkipsInactiveDeductions(t *testing.T) {
+	inactive := providentFund
+	inactive.Active = false
+	record := Compute([]models.StatutoryDeduction{inactive}, 5000)
+	assert.Empty(t, record.Lines)
+	assert.Equal(t, 5000.0, record.Net)
+}
+
+func TestValidate(t *testing.T) {
+	valid := incomeTax
+	valid.Slabs = append([]models.TaxSlab(nil), incomeTax.Slabs...)
+	valid.EmployeeRate = 5
+	require.NoError(t, Validate(&valid))
+	assert.Equal(t, 0.0, valid.EmployeeRate, "rates do not apply to income tax")
+
+	for name, deduction := range map[string]models.StatutoryDeduction{
+		"no code":             {Name: "PF", Kind: models.StatutoryProvidentFund, EmployeeRate: 10},
+		"unknown kind":        {Code: "X", Name: "X", Kind: "levy", EmployeeRate: 10},
+		"no rates":            {Code: "PF", Name: "PF", Kind: models.StatutoryProvidentFund},
+		"rate above 100":      {Code: "PF", Name: "PF", Kind: models.StatutoryProvidentFund, EmployerRate: 120},
+		"negative ceiling":    {Code: "PF", Name: "PF", Kind: models.StatutoryProvidentFund, EmployeeRate: 10, Ceiling: -1},
+		"no slabs":            {Code: "IT", Name: "IT", Kind: models.StatutoryIncomeTax},
+		"bounded top slab":    {Code: "IT", Name: "IT", Kind: models.StatutoryIncomeTax, Slabs: []models.TaxSlab{{UpTo: 100, Rate: 5}}},
+		"descending slabs":    {Code: "IT", Name: "IT", Kind: models.StatutoryIncomeTax, Slabs: []models.TaxSlab{{UpTo: 100, Rate: 5}, {UpTo: 50, Rate: 10}, {Rate: 20}}},
+		"unbounded mid slabs": {Code: "IT", Name: "IT", Kind: models.StatutoryIncomeTax, Slabs: []models.TaxSlab{{Rate: 5}, {Rate: 10}}},
+	} {
+		err := Validate(&deduction)
+		assert.True(t, errors.Is(err, models.ErrValidation), name)
+	}
+}
+
+func TestRecordAndRemittance(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{deductions: []models.StatutoryDeduction{providentFund, insurance}}
+	service := NewService(store)
+	service.Now = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	_, err := service.Record(ctx, 1, "May 2024", 5000, "hr@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	_, err = service.Record(ctx, 1, "2024-05", -1, "hr@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+
+	record, err := service.Record(ctx, 1, "2024-05", 5000, "hr@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-05", record.Period)
+	assert.Equal(t, "hr@example.com", record.RecordedBy)
+	_, err = service.Record(ctx, 2, "2024-05", 2000, "hr@example.com")
+	require.NoError(t, err)
+
+	report, err := service.Remittance(ctx, "2024-05")
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Employees)
+	require.Len(t, report.Lines, 2)
+	assert.Equal(t, "PF", report.Lines[0].Code)
+	assert.Equal(t, 5000.0, report.Lines[0].Wages, "3000 up to the ceiling and 2000")
+	assert.Equal(t, 500.0, report.Lines[0].Employee)
+	assert.Equal(t, 600.0, report.Lines[0].Employer)
+	assert.Equal(t, 1100.0, report.Lines[0].Total)
+	assert.Equal(t, "SI", report.Lines[1].Code)
+	assert.Equal(t, 7000.0, report.Lines[1].Wages)
+	assert.Equal(t, 105.0, report.Lines[1].Employee)
+	assert.Equal(t, 227.5, report.Lines[1].Employer)
+	assert.Equal(t, 605.0, report.TotalEmployee)
+	assert.Equal(t, 827.5, report.TotalEmployer)
+	assert.Equal(t, 1432.5, report.Total)
+}
+
+// benefitLines returns a health plan line for employee 1.
+type benefitLines struct{}
+
+func (benefitLines) PayrollLines(ctx context.Context, userID int, period string) ([]models.StatutoryLine, error) {
+	if userID != 1 {
+		return nil, nil
+	}
+	return []models.StatutoryLine{{Code: "HEALTH", Name: "Health (family)", Kind: models.StatutoryBenefit, Employee: 150, Employer: 300}}, nil
+}
+
+func TestRecordAddsBenefits(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{deductions: []models.StatutoryDeduction{providentFund}}
+	service := NewService(store)
+	service.Benefits = benefitLines{}
+
+	record, err := service.Record(ctx, 1, "2024-05", 5000, "hr@example.com")
+	require.NoError(t, err)
+	require.Len(t, record.Lines, 2)
+	assert.Equal(t, "HEALTH", record.Lines[1].Code)
+	assert.Equal(t, 450.0, record.TotalEmployee, "300 provident fund and 150 health")
+	assert.Equal(t, 660.0, record.TotalEmployer)
+	assert.Equal(t, 4550.0, record.Net)
+
+	report, err := service.Remittance(ctx, "2024-05")
+	require.NoError(t, err)
+	require.Len(t, report.Lines, 1, "benefits are not remitted to the authorities")
+	assert.Equal(t, "PF", report.Lines[0].Code)
+}
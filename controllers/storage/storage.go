@@ -0,0 +1,254 @@
+// Package storage stores file attachments such as product images. Files are addressed by
+// a key (a slash-separated path) and served to clients from a public URL.
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"erp/config"
+)
+
+// ErrNotFound is returned when no file is stored under a key.
+var ErrNotFound = errors.New("file not found")
+
+// Storage is an attachment backend.
+type Storage interface {
+	// Put stores data under key, replacing any existing file.
+	Put(key string, data []byte, contentType string) error
+	// Get returns the file stored under key.
+	Get(key string) ([]byte, error)
+	// Delete removes the file stored under key. Deleting a missing file is not an error.
+	Delete(key string) error
+	// URL returns the public URL of the file stored under key.
+	URL(key string) string
+}
+
+// Streamer is implemented by backends that can write and read files without holding them
+// in memory, which matters for large files such as backups.
+type Streamer interface {
+	// Create returns a writer for key. The file replaces any existing one when the writer
+	// is closed; until then readers see the old file.
+	Create(key string) (io.WriteCloser, error)
+	// Open returns a reader for the file stored under key.
+	Open(key string) (io.ReadCloser, error)
+}
+
+// Create returns a writer for key, streaming to s if it is a Streamer and otherwise
+// buffering the file and storing it with Put on Close.
+func Create(s Storage, key, contentType string) (io.WriteCloser, error) {
+	if streamer, ok := s.(Streamer); ok {
+		return streamer.Create(key)
+	}
+	return &bufferedFile{put: func(data []byte) error { return s.Put(key, data, contentType) }}, nil
+}
+
+// Open returns a reader for key, streaming from s if it is a Streamer.
+func Open(s Storage, key string) (io.ReadCloser, error) {
+	if streamer, ok := s.(Streamer); ok {
+		return streamer.Open(key)
+	}
+	data, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// bufferedFile collects written data and stores it on Close.
+type bufferedFile struct {
+	bytes.Buffer
+	put func(data []byte) error
+}
+
+// Close stores the buffered data.
+func (f *bufferedFile) Close() error {
+	return f.put(f.Bytes())
+}
+
+// New creates the storage backend selected by the configuration.
+//
+// Parameters:
+//   - cfg: The storage configuration.
+//
+// Returns:
+//   - Storage: The configured backend.
+//   - error: An error if the driver is unknown.
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return &LocalStorage{Dir: cfg.Dir, BaseURL: cfg.BaseURL}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// LocalStorage stores files in a directory on the local disk.
+type LocalStorage struct {
+	Dir     string // Root directory for stored files
+	BaseURL string // URL prefix the directory is served from, e.g. "/files"
+}
+
+// path resolves a key inside the storage directory, rejecting keys that escape it.
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+// Put writes data to the file for key, creating parent directories as needed.
+func (s *LocalStorage) Put(key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Get reads the file for key.
+func (s *LocalStorage) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Delete removes the file for key.
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Create writes to a temporary file next to the file for key and renames it into place
+// when the writer is closed.
+func (s *LocalStorage) Create(key string) (io.WriteCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &localFile{File: file, path: path}, nil
+}
+
+// Open opens the file for key.
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// localFile is a temporary file that is renamed to path when closed.
+type localFile struct {
+	*os.File
+	path string
+}
+
+// Close flushes the temporary file and moves it into place. If anything fails the
+// temporary file is removed and the existing file is left untouched.
+func (f *localFile) Close() error {
+	err := f.File.Sync()
+	if closeErr := f.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.File.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.File.Name())
+	}
+	return err
+}
+
+// URL returns BaseURL joined with key.
+func (s *LocalStorage) URL(key string) string {
+	return strings.TrimRight(s.BaseURL, "/") + "/" + key
+}
+
+// MemoryStorage keeps files in memory. It is used in tests.
+type MemoryStorage struct {
+	mu    sync.Mutex
+	Files map[string][]byte
+}
+
+// NewMemoryStorage creates an empty in-memory storage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{Files: make(map[string][]byte)}
+}
+
+// Put stores a copy of data under key.
+func (s *MemoryStorage) Put(key string, data []byte, contentType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Files[key] = bytes.Clone(data)
+	return nil
+}
+
+// Get returns the data stored under key.
+func (s *MemoryStorage) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.Files[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// Delete removes key.
+func (s *MemoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Files, key)
+	return nil
+}
+
+// URL returns a pseudo URL for key.
+func (s *MemoryStorage) URL(key string) string {
+	return "/files/" + key
+}
+
+// ReadAll reads at most limit bytes from r, returning an error if r holds more.
+func ReadAll(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file exceeds the %d byte limit", limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,167 @@
+// Package supplierreturns holds the rules of returns to vendor (RTV). Defective or excess
+// goods of a purchase order receipt are put on a return; shipping it takes the goods out of
+// the warehouse and issues a debit note against the supplier for their cost. The debit note
+// is first deducted from the receipt's payment if accounts payable has not approved it yet,
+// and the rest is settled by the refunds or credit notes the supplier sends.
+package supplierreturns
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"erp/models"
+)
+
+// Ledger accounts a refund of a debit note is posted to.
+const (
+	CashAccount    = "cash"
+	ReturnsAccount = "purchase_returns" // Reduces the cost of purchases
+)
+
+// Service applies the supplier return rules on top of a SupplierReturnStore.
+type Service struct {
+	Store models.SupplierReturnStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a supplier return service backed by store.
+func NewService(store models.SupplierReturnStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Create records an open return of goods of a receipt.
+//
+// Parameters:
+//   - ret: The receipt, reason and lines (product and quantity); the rest is filled in.
+//   - actor: Email of the user raising the return.
+//
+// Returns:
+//   - error: A validation error if the receipt, reason or lines are missing, a quantity is
+//     not positive or a product is listed twice, or the store's error.
+func (s *Service) Create(ctx context.Context, ret *models.SupplierReturn, actor string) error {
+	ret.Reason = strings.TrimSpace(ret.Reason)
+	switch {
+	case ret.ReceiptID <= 0:
+		return models.Invalid("receipt_id is required")
+	case ret.Reason == "":
+		return models.Invalid("reason is required")
+	case len(ret.Lines) == 0:
+		return models.Invalid("a return needs at least one line")
+	}
+	seen := make(map[int]bool, len(ret.Lines))
+	for _, line := range ret.Lines {
+		switch {
+		case line.Quantity <= 0:
+			return models.Invalid("product %d: quantity must be positive", line.ProductID)
+		case seen[line.ProductID]:
+			return models.Invalid("product %d is listed more than once", line.ProductID)
+		}
+		seen[line.ProductID] = true
+	}
+	ret.HoldID, ret.Status, ret.CreatedBy, ret.CreatedAt = 0, models.SupplierReturnOpen, actor, s.Now()
+	return s.Store.CreateSupplierReturn(ctx, ret)
+}
+
+// Returnable checks the lines of a return against what a receipt brought in less what
+// earlier returns of the receipt took back, both by product.
+//
+// Returns:
+//   - error: A conflict naming the first line that returns a product the receipt did not
+//     bring in or more of it than is left.
+func Returnable(received, returned map[int]int, lines []models.SupplierReturnLine) error {
+	for _, line := range lines {
+		left := received[line.ProductID] - returned[line.ProductID]
+		if line.Quantity > left {
+			return models.Conflict("product %d: %d returned but only %d of the receipt is left to return",
+				line.ProductID, line.Quantity, max(left, 0))
+		}
+	}
+	return nil
+}
+
+// Amount returns the cost of the goods on a return.
+func Amount(lines []models.SupplierReturnLine) float64 {
+	amount := 0.0
+	for _, line := range lines {
+		amount += float64(line.Quantity) * line.UnitCost
+	}
+	return roundCents(amount)
+}
+
+// Returns returns the supplier returns in a status, or all of them if status is empty,
+// optionally of one supplier.
+func (s *Service) Returns(ctx context.Context, status string, supplierID int) ([]models.SupplierReturn, error) {
+	switch status {
+	case "", models.SupplierReturnOpen, models.SupplierReturnShipped, models.SupplierReturnCredited:
+		return s.Store.ListSupplierReturns(ctx, status, supplierID)
+	}
+	return nil, models.Invalid("unknown supplier return status %q", status)
+}
+
+// Return returns a supplier return with its lines and debit note.
+func (s *Service) Return(ctx context.Context, id int) (*models.SupplierReturn, error) {
+	return s.Store.GetSupplierReturn(ctx, id)
+}
+
+// Ship records that the goods of an open return left for the supplier.
+//
+// Returns:
+//   - *models.SupplierReturn: The return with its debit note.
+//   - error: models.ErrNotFound, a conflict if the return was shipped already or the
+//     warehouse no longer holds the goods, or the store's error.
+func (s *Service) Ship(ctx context.Context, id int, actor string) (*models.SupplierReturn, error) {
+	ret, err := s.Store.GetSupplierReturn(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Status != models.SupplierReturnOpen {
+		return nil, models.Conflict("supplier return %d is %s", id, ret.Status)
+	}
+	return s.Store.ShipSupplierReturn(ctx, id, actor, s.Now())
+}
+
+// RecordCredit records a refund or credit note the supplier sent against a shipped
+// return's debit note. The return is credited once nothing is due.
+//
+// Parameters:
+//   - id: The ID of the return.
+//   - credit: The amount, a reference such as the supplier's credit note number, and an
+//     optional date received.
+//   - actor: Email of the user recording it.
+//
+// Returns:
+//   - *models.SupplierReturn: The return with its debit note.
+//   - error: A validation error if the amount is not positive or the reference is missing,
+//     models.ErrNotFound, a conflict if the return is not shipped or the amount exceeds
+//     what is due, or the store's error.
+func (s *Service) RecordCredit(ctx context.Context, id int, credit *models.SupplierCredit, actor string) (*models.SupplierReturn, error) {
+	credit.Reference = strings.TrimSpace(credit.Reference)
+	switch {
+	case credit.Amount <= 0:
+		return nil, models.Invalid("amount must be positive")
+	case credit.Reference == "":
+		return nil, models.Invalid("reference is required")
+	}
+	now := s.Now()
+	if credit.ReceivedOn.IsZero() {
+		credit.ReceivedOn = now
+	}
+	credit.Amount = roundCents(credit.Amount)
+	credit.Method, credit.PaymentID, credit.RecordedBy, credit.RecordedAt = models.CreditRefund, 0, actor, now
+	return s.Store.RecordSupplierCredit(ctx, id, credit)
+}
+
+// Status returns the status of a shipped return whose debit note has the given amount due.
+func Status(due float64) string {
+	if roundCents(due) <= 0 {
+		return models.SupplierReturnCredited
+	}
+	return models.SupplierReturnShipped
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,134 @@
+package supplierreturns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore holds return 1 in the given status and keeps what it is asked to record.
+type mockStore struct {
+	status  string
+	created *models.SupplierReturn
+	credit  *models.SupplierCredit
+	shipped bool
+}
+
+func (m *mockStore) CreateSupplierReturn(ctx context.Context, ret *models.SupplierReturn) error {
+	m.created = ret
+	return nil
+}
+
+func (m *mockStore) ListSupplierReturns(ctx context.Context, status string, supplierID int) ([]models.SupplierReturn, error) {
+	return []models.SupplierReturn{}, nil
+}
+
+func (m *mockStore) GetSupplierReturn(ctx context.Context, id int) (*models.SupplierReturn, error) {
+	if id != 1 {
+		return nil, models.NotFound("supplier return %d not found", id)
+	}
+	return &models.SupplierReturn{ID: 1, Status: m.status}, nil
+}
+
+func (m *mockStore) ShipSupplierReturn(ctx context.Context, id int, actor string, at time.Time) (*models.SupplierReturn, error) {
+	m.shipped = true
+	return &models.SupplierReturn{ID: id, Status: models.SupplierReturnShipped}, nil
+}
+
+func (m *mockStore) RecordSupplierCredit(ctx context.Context, id int, credit *models.SupplierCredit) (*models.SupplierReturn, error) {
+	m.credit = credit
+	return &models.SupplierReturn{ID: id, Status: models.SupplierReturnShipped}, nil
+}
+
+var now = time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)
+
+func newService(status string) (*Service, *mockStore) {
+	store := &mockStore{status: status}
+	service := NewService(store)
+	service.Now = func() time.Time { return now }
+	return service, store
+}
+
+func TestCreateValidation(t *testing.T) {
+	ctx := context.Background()
+	service, store := newService(models.SupplierReturnOpen)
+
+	for name, ret := range map[string]models.SupplierReturn{
+		"no receipt":    {Reason: "Wrong size", Lines: []models.SupplierReturnLine{{ProductID: 5, Quantity: 1}}},
+		"no reason":     {ReceiptID: 2, Reason: " ", Lines: []models.SupplierReturnLine{{ProductID: 5, Quantity: 1}}},
+		"no lines":      {ReceiptID: 2, Reason: "Wrong size"},
+		"zero quantity": {ReceiptID: 2, Reason: "Wrong size", Lines: []models.SupplierReturnLine{{ProductID: 5}}},
+		"listed twice":  {ReceiptID: 2, Reason: "Wrong size", Lines: []models.SupplierReturnLine{{ProductID: 5, Quantity: 1}, {ProductID: 5, Quantity: 2}}},
+	} {
+		err := service.Create(ctx, &ret, "buyer@example.com")
+		assert.True(t, errors.Is(err, models.ErrValidation), name)
+	}
+	assert.Nil(t, store.created)
+
+	ret := &models.SupplierReturn{ReceiptID: 2, Reason: " Wrong size ", HoldID: 7,
+		Lines: []models.SupplierReturnLine{{ProductID: 5, Quantity: 3}}}
+	require.NoError(t, service.Create(ctx, ret, "buyer@example.com"))
+	assert.Equal(t, "Wrong size", store.created.Reason)
+	assert.Equal(t, models.SupplierReturnOpen, store.created.Status)
+	assert.Equal(t, 0, store.created.HoldID)
+	assert.Equal(t, now, store.created.CreatedAt)
+}
+
+func TestReturnableCountsEarlierReturns(t *testing.T) {
+	received := map[int]int{5: 10, 6: 4}
+	returned := map[int]int{5: 7}
+
+	assert.NoError(t, Returnable(received, returned, []models.SupplierReturnLine{{ProductID: 5, Quantity: 3}, {ProductID: 6, Quantity: 4}}))
+
+	err := Returnable(received, returned, []models.SupplierReturnLine{{ProductID: 5, Quantity: 4}})
+	assert.True(t, errors.Is(err, models.ErrConflict))
+	assert.Contains(t, err.Error(), "only 3 of the receipt is left")
+
+	// A product the receipt did not bring in
+	err = Returnable(received, returned, []models.SupplierReturnLine{{ProductID: 9, Quantity: 1}})
+	assert.True(t, errors.Is(err, models.ErrConflict))
+}
+
+func TestAmount(t *testing.T) {
+	assert.Equal(t, 10.35, Amount([]models.SupplierReturnLine{{Quantity: 3, UnitCost: 1.1}, {Quantity: 1, UnitCost: 7.05}}))
+}
+
+func TestShipRequiresOpenReturn(t *testing.T) {
+	ctx := context.Background()
+	service, store := newService(models.SupplierReturnShipped)
+
+	_, err := service.Ship(ctx, 1, "buyer@example.com")
+	assert.True(t, errors.Is(err, models.ErrConflict))
+	assert.False(t, store.shipped)
+
+	_, err = service.Ship(ctx, 2, "buyer@example.com")
+	assert.True(t, errors.Is(err, models.ErrNotFound))
+}
+
+func TestRecordCredit(t *testing.T) {
+	ctx := context.Background()
+	service, store := newService(models.SupplierReturnShipped)
+
+	_, err := service.RecordCredit(ctx, 1, &models.SupplierCredit{Amount: 0, Reference: "CN-1"}, "ap@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	_, err = service.RecordCredit(ctx, 1, &models.SupplierCredit{Amount: 5, Reference: " "}, "ap@example.com")
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	assert.Nil(t, store.credit)
+
+	_, err = service.RecordCredit(ctx, 1, &models.SupplierCredit{Amount: 40.004, Reference: " CN-881 ", PaymentID: 3}, "ap@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, &models.SupplierCredit{Method: models.CreditRefund, Amount: 40, Reference: "CN-881",
+		ReceivedOn: now, RecordedBy: "ap@example.com", RecordedAt: now}, store.credit)
+}
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, models.SupplierReturnShipped, Status(0.5))
+	assert.Equal(t, models.SupplierReturnCredited, Status(0.001))
+	assert.Equal(t, models.SupplierReturnCredited, Status(0))
+}
@@ -0,0 +1,194 @@
+// Package suppliers keeps the supplier register and the advances paid to suppliers before
+// they bill. An advance is held as a prepayment (the supplier_advances account) until a
+// bill of the supplier arrives; the bill is then offset against the oldest unadjusted
+// advances and only the rest is owed.
+package suppliers
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"erp/controllers/validation"
+	"erp/models"
+)
+
+// Ledger accounts used by supplier advances and bills.
+const (
+	AdvanceAccount   = "supplier_advances" // Prepayments to suppliers
+	PayableAccount   = "accounts_payable"
+	CashAccount      = "cash"
+	DefaultBillDebit = "expense"
+)
+
+// Service validates suppliers, advances and bills.
+type Service struct {
+	Store models.SupplierStore
+	Now   func() time.Time // Clock, replaced in tests
+}
+
+// NewService creates a supplier service.
+func NewService(store models.SupplierStore) *Service {
+	return &Service{Store: store, Now: time.Now}
+}
+
+// Suppliers returns every supplier.
+func (s *Service) Suppliers(ctx context.Context) ([]models.Supplier, error) {
+	return s.Store.GetSuppliers(ctx)
+}
+
+// Supplier returns a supplier.
+func (s *Service) Supplier(ctx context.Context, id int) (*models.Supplier, error) {
+	return s.Store.GetSupplier(ctx, id)
+}
+
+// validateSupplier trims and checks a supplier's name and contact details, returning a
+// *models.ValidationError that lists every invalid field.
+func validateSupplier(supplier *models.Supplier) error {
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	supplier.Email = strings.TrimSpace(supplier.Email)
+	supplier.Phone = strings.TrimSpace(supplier.Phone)
+
+	var c validation.Checker
+	c.Required("name", supplier.Name)
+	c.Email("email", supplier.Email)
+	return c.Err()
+}
+
+// CreateSupplier adds a supplier. New suppliers are active.
+//
+// Returns:
+//   - error: A validation error if the name is missing or the email is not an address, or
+//     the store's error.
+func (s *Service) CreateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	if err := validateSupplier(supplier); err != nil {
+		return err
+	}
+	supplier.Active, supplier.CreatedAt = true, s.Now()
+	return s.Store.CreateSupplier(ctx, supplier)
+}
+
+// UpdateSupplier changes a supplier's details. Inactive suppliers cannot be paid or billed.
+func (s *Service) UpdateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	if err := validateSupplier(supplier); err != nil {
+		return err
+	}
+	return s.Store.UpdateSupplier(ctx, supplier)
+}
+
+// PayAdvance records an advance paid to a supplier. Cash is credited and the prepayment
+// account debited until bills are offset against it.
+//
+// Parameters:
+//   - advance: The advance with its supplier, amount and optional payment date, method and reference.
+//   - actor: Email of the user recording it.
+//
+// Returns:
+//   - error: A validation error if the amount is not positive or the supplier is inactive,
+//     models.ErrNotFound if the supplier does not exist, or the store's error.
+func (s *Service) PayAdvance(ctx context.Context, advance *models.SupplierAdvance, actor string) error {
+	if advance.Amount <= 0 {
+		return models.Invalid("amount must be positive")
+	}
+	if err := s.activeSupplier(ctx, advance.SupplierID); err != nil {
+		return err
+	}
+	now := s.Now()
+	if advance.PaidOn.IsZero() {
+		advance.PaidOn = now
+	}
+	advance.Amount = roundCents(advance.Amount)
+	advance.Adjusted, advance.CreatedBy, advance.CreatedAt = 0, actor, now
+	return s.Store.CreateSupplierAdvance(ctx, advance)
+}
+
+// Advances lists advances, oldest first. supplierID 0 lists every supplier's.
+func (s *Service) Advances(ctx context.Context, supplierID int, unadjustedOnly bool) ([]models.SupplierAdvance, error) {
+	return s.Store.GetSupplierAdvances(ctx, supplierID, unadjustedOnly)
+}
+
+// RecordBill records a supplier's bill. The supplier's unadjusted advances are offset
+// against it, oldest first, so only the rest is owed.
+//
+// Parameters:
+//   - bill: The bill with its supplier, number, amount and optional account and date; the
+//     advances applied and the balance are set.
+//   - actor: Email of the user recording it.
+//
+// Returns:
+//   - error: A validation error if the bill is incomplete or the supplier is inactive,
+//     models.ErrNotFound if the supplier does not exist, a conflict if the supplier's bill
+//     number has been recorded already, or the store's error.
+func (s *Service) RecordBill(ctx context.Context, bill *models.SupplierBill, actor string) error {
+	bill.Number, bill.Account = strings.TrimSpace(bill.Number), strings.TrimSpace(bill.Account)
+	switch {
+	case bill.Number == "":
+		return models.Invalid("number is required")
+	case bill.Amount <= 0:
+		return models.Invalid("amount must be positive")
+	case bill.Account == AdvanceAccount || bill.Account == PayableAccount:
+		return models.Invalid("a bill cannot be charged to %s", bill.Account)
+	}
+	if err := s.activeSupplier(ctx, bill.SupplierID); err != nil {
+		return err
+	}
+	now := s.Now()
+	if bill.Account == "" {
+		bill.Account = DefaultBillDebit
+	}
+	if bill.BillDate.IsZero() {
+		bill.BillDate = now
+	}
+	bill.Amount = roundCents(bill.Amount)
+	bill.CreatedBy, bill.CreatedAt = actor, now
+	return s.Store.CreateSupplierBill(ctx, bill)
+}
+
+// Bills lists a supplier's bills, newest first.
+func (s *Service) Bills(ctx context.Context, supplierID int) ([]models.SupplierBill, error) {
+	return s.Store.GetSupplierBills(ctx, supplierID)
+}
+
+// Balance summarizes a supplier's advances and bills.
+func (s *Service) Balance(ctx context.Context, supplierID int) (*models.SupplierBalance, error) {
+	return s.Store.GetSupplierBalance(ctx, supplierID)
+}
+
+// activeSupplier checks that a supplier exists and is active.
+func (s *Service) activeSupplier(ctx context.Context, id int) error {
+	supplier, err := s.Store.GetSupplier(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !supplier.Active {
+		return models.Invalid("supplier %q is inactive", supplier.Name)
+	}
+	return nil
+}
+
+// Offset applies advances, oldest first, to a bill amount. It returns the part of each
+// advance used; the bill keeps the rest as owed. The advances must be in the order they
+// are to be used.
+func Offset(advances []models.SupplierAdvance, amount float64) []models.AdvanceApplication {
+	applications := []models.AdvanceApplication{}
+	remaining := roundCents(amount)
+	for _, advance := range advances {
+		if remaining <= 0 {
+			break
+		}
+		available := roundCents(advance.Unadjusted())
+		if available <= 0 {
+			continue
+		}
+		applied := math.Min(available, remaining)
+		applications = append(applications, models.AdvanceApplication{AdvanceID: advance.ID, Amount: applied})
+		remaining = roundCents(remaining - applied)
+	}
+	return applications
+}
+
+// roundCents rounds an amount to cents.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
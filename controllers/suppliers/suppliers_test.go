@@ -0,0 +1,146 @@
+package suppliers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps suppliers, advances and bills in memory and offsets advances like the
+// database store does.
+type fakeStore struct {
+	suppliers map[int]*models.Supplier
+	advances  []models.SupplierAdvance
+	bills     []models.SupplierBill
+}
+
+func (f *fakeStore) CreateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	supplier.ID = len(f.suppliers) + 1
+	f.suppliers[supplier.ID] = supplier
+	return nil
+}
+
+func (f *fakeStore) GetSupplier(ctx context.Context, id int) (*models.Supplier, error) {
+	supplier, ok := f.suppliers[id]
+	if !ok {
+		return nil, models.NotFound("supplier %d not found", id)
+	}
+	return supplier, nil
+}
+
+func (f *fakeStore) GetSuppliers(ctx context.Context) ([]models.Supplier, error) { return nil, nil }
+
+func (f *fakeStore) UpdateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	f.suppliers[supplier.ID] = supplier
+	return nil
+}
+
+func (f *fakeStore) CreateSupplierAdvance(ctx context.Context, advance *models.SupplierAdvance) error {
+	advance.ID = len(f.advances) + 1
+	f.advances = append(f.advances, *advance)
+	return nil
+}
+
+func (f *fakeStore) GetSupplierAdvances(ctx context.Context, supplierID int, unadjustedOnly bool) ([]models.SupplierAdvance, error) {
+	return f.advances, nil
+}
+
+func (f *fakeStore) CreateSupplierBill(ctx context.Context, bill *models.SupplierBill) error {
+	bill.ID = len(f.bills) + 1
+	bill.Applications = Offset(f.advances, bill.Amount)
+	for _, application := range bill.Applications {
+		bill.AdvanceApplied += application.Amount
+		f.advances[application.AdvanceID-1].Adjusted += application.Amount
+	}
+	bill.Balance = bill.Amount - bill.AdvanceApplied
+	f.bills = append(f.bills, *bill)
+	return nil
+}
+
+func (f *fakeStore) GetSupplierBills(ctx context.Context, supplierID int) ([]models.SupplierBill, error) {
+	return f.bills, nil
+}
+
+func (f *fakeStore) GetSupplierBalance(ctx context.Context, supplierID int) (*models.SupplierBalance, error) {
+	return nil, nil
+}
+
+var day = time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+func newTestService() (*Service, *fakeStore) {
+	store := &fakeStore{suppliers: map[int]*models.Supplier{}}
+	service := NewService(store)
+	service.Now = func() time.Time { return day }
+	return service, store
+}
+
+func TestOffset(t *testing.T) {
+	advances := []models.SupplierAdvance{
+		{ID: 1, Amount: 100, Adjusted: 100},
+		{ID: 2, Amount: 300, Adjusted: 50},
+		{ID: 3, Amount: 500},
+	}
+
+	applications := Offset(advances, 400)
+	assert.Equal(t, []models.AdvanceApplication{{AdvanceID: 2, Amount: 250}, {AdvanceID: 3, Amount: 150}}, applications,
+		"fully adjusted advances are skipped and the oldest is used first")
+
+	applications = Offset(advances, 2000)
+	assert.Equal(t, []models.AdvanceApplication{{AdvanceID: 2, Amount: 250}, {AdvanceID: 3, Amount: 500}}, applications)
+
+	assert.Empty(t, Offset(nil, 100))
+}
+
+func TestRecordBillOffsetsAdvances(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+	supplier := &models.Supplier{Name: " Acme "}
+	require.NoError(t, service.CreateSupplier(ctx, supplier))
+	assert.Equal(t, "Acme", supplier.Name)
+	assert.True(t, supplier.Active)
+
+	require.NoError(t, service.PayAdvance(ctx, &models.SupplierAdvance{SupplierID: supplier.ID, Amount: 250}, "ap@example.com"))
+	assert.Equal(t, day, store.advances[0].PaidOn)
+
+	bill := &models.SupplierBill{SupplierID: supplier.ID, Number: "INV-1", Amount: 100}
+	require.NoError(t, service.RecordBill(ctx, bill, "ap@example.com"))
+	assert.Equal(t, DefaultBillDebit, bill.Account)
+	assert.Equal(t, 100.0, bill.AdvanceApplied)
+	assert.Equal(t, 0.0, bill.Balance)
+
+	bill = &models.SupplierBill{SupplierID: supplier.ID, Number: "INV-2", Amount: 200, Account: "inventory"}
+	require.NoError(t, service.RecordBill(ctx, bill, "ap@example.com"))
+	assert.Equal(t, 150.0, bill.AdvanceApplied)
+	assert.Equal(t, 50.0, bill.Balance, "only the part not covered by advances is owed")
+	assert.Equal(t, 0.0, store.advances[0].Unadjusted())
+}
+
+func TestValidation(t *testing.T) {
+	ctx := context.Background()
+	service, store := newTestService()
+	assert.ErrorIs(t, service.CreateSupplier(ctx, &models.Supplier{Name: " "}), models.ErrValidation)
+	store.suppliers[1] = &models.Supplier{ID: 1, Name: "Acme", Active: true}
+	store.suppliers[2] = &models.Supplier{ID: 2, Name: "Gone"}
+
+	assert.ErrorIs(t, service.PayAdvance(ctx, &models.SupplierAdvance{SupplierID: 1}, "ap@example.com"), models.ErrValidation)
+	assert.ErrorIs(t, service.PayAdvance(ctx, &models.SupplierAdvance{SupplierID: 2, Amount: 10}, "ap@example.com"), models.ErrValidation,
+		"inactive suppliers cannot be paid")
+	assert.ErrorIs(t, service.PayAdvance(ctx, &models.SupplierAdvance{SupplierID: 9, Amount: 10}, "ap@example.com"), models.ErrNotFound)
+
+	invalid := []models.SupplierBill{
+		{SupplierID: 1, Amount: 10},
+		{SupplierID: 1, Number: "INV-1"},
+		{SupplierID: 1, Number: "INV-1", Amount: 10, Account: AdvanceAccount},
+		{SupplierID: 1, Number: "INV-1", Amount: 10, Account: PayableAccount},
+		{SupplierID: 2, Number: "INV-1", Amount: 10},
+	}
+	for _, bill := range invalid {
+		assert.ErrorIs(t, service.RecordBill(ctx, &bill, "ap@example.com"), models.ErrValidation)
+	}
+	assert.Empty(t, store.bills)
+}
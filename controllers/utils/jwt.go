@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
     "time"
 
@@ -9,6 +11,10 @@ import (
 
 var jwtKey = []byte("your_secret_key")
 
+// AccessTokenTTL is how long an access token is valid. Clients get a new one with their
+// refresh token; it is set from the configuration at startup.
+var AccessTokenTTL = 24 * time.Hour
+
 // Claims defines the structure for JWT claims
 type Claims struct {
     Email string `json:"email"`
@@ -16,14 +22,21 @@ type Claims struct {
     jwt.StandardClaims
 }
 
-// GenerateJWT creates a new JWT for a user
+// GenerateJWT creates a new JWT for a user. Each token carries a unique ID ("jti") so that
+// it can be revoked before it expires.
 func GenerateJWT(email, role string) (string, error) {
-    expirationTime := time.Now().Add(24 * time.Hour)
+    id, err := NewTokenID()
+    if err != nil {
+        return "", err
+    }
+    now := time.Now()
     claims := &Claims{
         Email: email,
         Role:  role,
         StandardClaims: jwt.StandardClaims{
-            ExpiresAt: expirationTime.Unix(),
+            Id:        id,
+            IssuedAt:  now.Unix(),
+            ExpiresAt: now.Add(AccessTokenTTL).Unix(),
         },
     }
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -48,3 +61,12 @@ func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
 	}
 	return nil, fmt.Errorf("invalid token")
 }
+
+// NewTokenID returns a random identifier for a token.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
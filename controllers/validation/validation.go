@@ -0,0 +1,187 @@
+// Package validation checks request bodies before handlers act on them. Each request type
+// has a Validate method that runs its field rules with a Checker:
+//
+//	func (req ReceivableRequest) Validate() error {
+//		var c validation.Checker
+//		c.Required("customer_name", req.CustomerName)
+//		c.Positive("amount", req.Amount)
+//		return c.Err()
+//	}
+//
+// and handlers read the body with Decode. Services whose rules need more than the request,
+// such as the clock, use a Checker too, and their handlers read the body with DecodeJSON.
+// A body that is not JSON, or whose fields have the
+// wrong JSON type, is answered with 400; one that breaks a rule with 422. Both are error
+// envelopes of package respond whose details list the rejected fields:
+//
+//	{"error": {"code": "validation_failed", "message": "The request is invalid",
+//	 "details": [{"field": "name", "message": "is required"}]}}
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"erp/controllers/httperr"
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// Validator is a request body with field rules.
+type Validator interface {
+	// Validate returns a *models.ValidationError listing the fields that break a rule.
+	Validate() error
+}
+
+// Decode reads the JSON request body into v and validates it. It answers the request itself
+// when the body cannot be decoded (400 Bad Request) or is invalid (422 Unprocessable Entity).
+//
+// Parameters:
+//   - w: The response writer, written to only on failure.
+//   - r: The request.
+//   - v: A pointer to the request body type.
+//
+// Returns:
+//   - bool: False if the request was answered.
+func Decode(w http.ResponseWriter, r *http.Request, v Validator) bool {
+	if !DecodeJSON(w, r, v) {
+		return false
+	}
+	if err := v.Validate(); err != nil {
+		httperr.Write(w, err, "Failed to validate request")
+		return false
+	}
+	return true
+}
+
+// DecodeJSON reads the JSON request body into v without validating it, for bodies whose
+// rules are applied by a service. It answers the request with 400 Bad Request when the body
+// cannot be decoded.
+//
+// Returns:
+//   - bool: False if the request was answered.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		message, fields := decodeError(err)
+		if fields == nil {
+			respond.Error(w, http.StatusBadRequest, message)
+		} else {
+			respond.ErrorDetails(w, http.StatusBadRequest, message, fields)
+		}
+		return false
+	}
+	return true
+}
+
+// decodeError explains a JSON decoding error, naming the field when it has the wrong type.
+func decodeError(err error) (string, []models.FieldError) {
+	var syntax *json.SyntaxError
+	var wrongType *json.UnmarshalTypeError
+	var badTime *time.ParseError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "The request body is empty", nil
+	case errors.As(err, &wrongType) && wrongType.Field != "":
+		return "The request body has a field of the wrong type",
+			[]models.FieldError{{Field: wrongType.Field, Message: "must be a JSON " + jsonType(wrongType.Type.Kind().String())}}
+	case errors.As(err, &badTime):
+		return fmt.Sprintf("The request body has a time %s that is not formatted as RFC 3339, e.g. 2025-01-31T00:00:00Z", badTime.Value), nil
+	case errors.As(err, &syntax), errors.Is(err, io.ErrUnexpectedEOF):
+		return "The request body is not valid JSON", nil
+	}
+	return "The request body could not be read", nil
+}
+
+// jsonType names the JSON type a Go kind is decoded from.
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "bool":
+		return "boolean"
+	case kind == "slice", kind == "array":
+		return "array"
+	case kind == "struct", kind == "map":
+		return "object"
+	}
+	return kind
+}
+
+// Checker collects the field errors of a request. The zero value is ready to use.
+type Checker struct {
+	fields []models.FieldError
+}
+
+// Check records message for field unless ok.
+func (c *Checker) Check(ok bool, field, format string, args ...interface{}) {
+	if !ok {
+		c.fields = append(c.fields, models.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// Required checks that a text field is not blank.
+func (c *Checker) Required(field, value string) {
+	c.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// ID checks that a field references a record, i.e. holds a positive ID.
+func (c *Checker) ID(field string, id int) {
+	c.Check(id > 0, field, "must be the ID of an existing record")
+}
+
+// Positive checks that an amount is greater than zero.
+func (c *Checker) Positive(field string, value float64) {
+	c.Check(value > 0, field, "must be greater than zero")
+}
+
+// NotNegative checks that an amount or quantity is zero or more.
+func (c *Checker) NotNegative(field string, value float64) {
+	c.Check(value >= 0, field, "must not be negative")
+}
+
+// Email checks that a field holds a single email address, such as name@example.com. Blank
+// values pass; combine with Required for mandatory addresses.
+func (c *Checker) Email(field, value string) {
+	if value == "" {
+		return
+	}
+	address, err := mail.ParseAddress(value)
+	c.Check(err == nil && address.Address == value && strings.Contains(value[strings.LastIndex(value, "@"):], "."),
+		field, "must be an email address such as name@example.com")
+}
+
+// OneOf checks that a field holds one of the allowed values.
+func (c *Checker) OneOf(field, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	c.Check(false, field, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+// Date checks that a time field is set.
+func (c *Checker) Date(field string, t time.Time) {
+	c.Check(!t.IsZero(), field, "is required")
+}
+
+// Ordered checks that the time in field is not before the one in fromField. Unset times
+// pass, as Date reports them.
+func (c *Checker) Ordered(fromField string, from time.Time, field string, t time.Time) {
+	c.Check(from.IsZero() || t.IsZero() || !t.Before(from), field, "must not be before %s", fromField)
+}
+
+// Err returns a *models.ValidationError listing the recorded field errors, or nil if there
+// are none.
+func (c *Checker) Err() error {
+	if len(c.fields) == 0 {
+		return nil
+	}
+	return &models.ValidationError{Fields: c.fields}
+}
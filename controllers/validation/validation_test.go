@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"erp/controllers/respond"
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// billRequest is a request body with one rule of each kind.
+type billRequest struct {
+	Payee  string    `json:"payee"`
+	Email  string    `json:"email"`
+	Amount float64   `json:"amount"`
+	Method string    `json:"method"`
+	Issued time.Time `json:"issued"`
+	Due    time.Time `json:"due"`
+}
+
+func (req billRequest) Validate() error {
+	var c Checker
+	c.Required("payee", req.Payee)
+	c.Email("email", req.Email)
+	c.Positive("amount", req.Amount)
+	c.OneOf("method", req.Method, []string{"cash", "bank"})
+	c.Date("issued", req.Issued)
+	c.Ordered("issued", req.Issued, "due", req.Due)
+	return c.Err()
+}
+
+func TestCheckerListsEveryField(t *testing.T) {
+	err := billRequest{Email: "ap@example", Amount: -5, Method: "card",
+		Due: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}.Validate()
+
+	var invalid *models.ValidationError
+	require.True(t, errors.As(err, &invalid))
+	assert.True(t, errors.Is(err, models.ErrValidation))
+	assert.Equal(t, []models.FieldError{
+		{Field: "payee", Message: "is required"},
+		{Field: "email", Message: "must be an email address such as name@example.com"},
+		{Field: "amount", Message: "must be greater than zero"},
+		{Field: "method", Message: "must be one of cash, bank"},
+		{Field: "issued", Message: "is required"},
+	}, invalid.Fields)
+
+	err = billRequest{Payee: "Acme", Email: "ap@acme.example.com", Amount: 5, Method: "bank",
+		Issued: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), Due: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}.Validate()
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, []models.FieldError{{Field: "due", Message: "must not be before issued"}}, invalid.Fields)
+
+	var none Checker
+	assert.NoError(t, none.Err())
+}
+
+func TestEmail(t *testing.T) {
+	for value, ok := range map[string]bool{
+		"":                    true, // Blank passes; Required reports it
+		"ap@acme.com":         true,
+		"ap@acme":             false,
+		"Acme <ap@acme.com>":  false,
+		"ap@acme.com, b@c.de": false,
+		"not an address":      false,
+	} {
+		var c Checker
+		c.Email("email", value)
+		assert.Equal(t, ok, c.Err() == nil, value)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		body   string
+		status int // 0 if the body is accepted
+		fields []models.FieldError
+	}{
+		{body: `{"payee": "Acme", "amount": 5, "method": "cash", "issued": "2025-01-01T00:00:00Z"}`},
+		{body: ``, status: http.StatusBadRequest},
+		{body: `{"payee": `, status: http.StatusBadRequest},
+		{body: `{"payee": "Acme", "amount": "5"}`, status: http.StatusBadRequest,
+			fields: []models.FieldError{{Field: "amount", Message: "must be a JSON number"}}},
+		{body: `{"payee": "Acme", "issued": "2025-01-01"}`, status: http.StatusBadRequest},
+		{body: `{"payee": "Acme", "amount": 5, "method": "cash"}`, status: http.StatusUnprocessableEntity,
+			fields: []models.FieldError{{Field: "issued", Message: "is required"}}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/bills", strings.NewReader(tt.body))
+		rr := httptest.NewRecorder()
+		var req billRequest
+
+		ok := Decode(rr, r, &req)
+		assert.Equal(t, tt.status == 0, ok, tt.body)
+		if ok {
+			assert.Equal(t, "Acme", req.Payee)
+			continue
+		}
+		assert.Equal(t, tt.status, rr.Code, tt.body)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		var body struct {
+			Error struct {
+				Code    string              `json:"code"`
+				Message string              `json:"message"`
+				Details []models.FieldError `json:"details"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+		assert.Equal(t, respond.Code(tt.status), body.Error.Code)
+		assert.NotEmpty(t, body.Error.Message)
+		assert.Equal(t, tt.fields, body.Error.Details, tt.body)
+	}
+}
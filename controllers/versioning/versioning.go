@@ -0,0 +1,89 @@
+// Package versioning implements optimistic concurrency for updates. Every versioned record
+// carries a version that each update increments. Reads return it in the record and as an
+// ETag; an update must send the version it was based on, in an If-Match header or the
+// request body:
+//
+//	PUT /customers/5
+//	If-Match: "3"
+//
+// A database trigger increments the version on every update of the row, whoever makes it,
+// and stores compare and swap in SQL (UPDATE ... WHERE id = $1 AND version = $2 RETURNING
+// version), so an update based on a version someone else has replaced changes nothing and is
+// answered with 409 Conflict instead of silently overwriting the other change.
+package versioning
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"erp/controllers/respond"
+	"erp/models"
+)
+
+// Queryer is satisfied by both *sql.DB and *sql.Tx.
+type Queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Expected reads the version an update is based on: the If-Match header if present,
+// otherwise the version in the request body. It answers the request itself when there is
+// none (428 Precondition Required) or the header is not a version (400 Bad Request).
+//
+// Parameters:
+//   - w: The response writer, written to only on failure.
+//   - r: The update request.
+//   - body: The version decoded from the request body, 0 if it had none.
+//
+// Returns:
+//   - int: The expected version.
+//   - bool: False if the request was answered.
+func Expected(w http.ResponseWriter, r *http.Request, body int) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if body <= 0 {
+			respond.Error(w, http.StatusPreconditionRequired, `The version being updated is required, as an If-Match header or "version" in the body`)
+			return 0, false
+		}
+		return body, true
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version <= 0 {
+		respond.Error(w, http.StatusBadRequest, "Invalid If-Match header, expected a version such as \"3\"")
+		return 0, false
+	}
+	return version, true
+}
+
+// SetETag sends a record's version as its ETag, for clients to send back in If-Match.
+func SetETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// Stale explains why a compare-and-swap update changed no row: the record does not exist,
+// or it is no longer at the version the update was based on.
+//
+// Parameters:
+//   - q: The transaction (or database) the update ran on.
+//   - query: Selects the record's current version by ID ($1), e.g.
+//     "SELECT version FROM customers WHERE id = $1 AND deleted_at IS NULL".
+//   - noun: What the record is, for the message, e.g. "customer".
+//   - id: The ID of the record.
+//   - version: The version the update was based on.
+//
+// Returns:
+//   - error: models.ErrNotFound, a conflict naming the current version, or the query error.
+func Stale(ctx context.Context, q Queryer, query, noun string, id, version int) error {
+	var current int
+	err := q.QueryRowContext(ctx, query, id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return models.NotFound("%s %d not found", noun, id)
+	}
+	if err != nil {
+		return err
+	}
+	return models.Conflict("%s %d was changed by someone else: version %d was updated but it is at version %d now; reload it and try again",
+		noun, id, version, current)
+}
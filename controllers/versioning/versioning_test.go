@@ -0,0 +1,73 @@
+package versioning
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"erp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpected(t *testing.T) {
+	tests := []struct {
+		header string
+		body   int
+		want   int
+		status int // 0 if the version is accepted
+	}{
+		{header: `"3"`, want: 3},
+		{header: `W/"3"`, body: 2, want: 3}, // The header wins over the body
+		{header: "7", want: 7},
+		{body: 5, want: 5},
+		{status: http.StatusPreconditionRequired},
+		{header: `"abc"`, body: 5, status: http.StatusBadRequest},
+		{header: `"0"`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/customers/5", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		rr := httptest.NewRecorder()
+
+		version, ok := Expected(rr, r, tt.body)
+		assert.Equal(t, tt.status == 0, ok, tt.header)
+		if ok {
+			assert.Equal(t, tt.want, version, tt.header)
+		} else {
+			assert.Equal(t, tt.status, rr.Code, tt.header)
+		}
+	}
+}
+
+func TestSetETag(t *testing.T) {
+	rr := httptest.NewRecorder()
+	SetETag(rr, 4)
+	assert.Equal(t, `"4"`, rr.Header().Get("ETag"))
+}
+
+func TestStale(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	const query = "SELECT version FROM customers WHERE id = $1"
+	mock.ExpectQuery("SELECT version FROM customers").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	err = Stale(ctx, db, query, "customer", 5, 3)
+	assert.True(t, errors.Is(err, models.ErrConflict))
+	assert.Contains(t, err.Error(), "version 3 was updated but it is at version 4 now")
+
+	mock.ExpectQuery("SELECT version FROM customers").WithArgs(6).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	assert.True(t, errors.Is(Stale(ctx, db, query, "customer", 6, 3), models.ErrNotFound))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,356 @@
+// Package scaffold writes the starting code of a new CRUD module from a small JSON spec,
+// so every module begins with the same model, store, handlers, routes and tests instead
+// of a copy of whichever module was nearest. A spec names the entity and its fields:
+//
+//	{
+//	  "entity": "Supplier",
+//	  "description": "Supplier is a company goods are bought from.",
+//	  "fields": [
+//	    {"name": "name", "type": "string", "required": true},
+//	    {"name": "credit_limit", "type": "float64"}
+//	  ]
+//	}
+//
+// The plural defaults to the entity name with an "s" appended and the table to the snake
+// case plural; both may be set with "plural" and "table". Field types are string, int,
+// int64, float64, bool and time.Time. Every entity gets an "id" primary key, which is not
+// listed in the spec.
+//
+// The generated code is a starting point that is edited by hand afterwards, so files that
+// already exist are left alone unless they are overwritten explicitly.
+package scaffold
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"erp/models/db/querygen"
+)
+
+// Field is one column of a scaffolded entity.
+type Field struct {
+	Name     string `json:"name"`     // Column and JSON name in snake_case
+	Type     string `json:"type"`     // Go type, see the package documentation
+	Required bool   `json:"required"` // Whether create and update requests must set it
+}
+
+// Spec describes the entity of a new module.
+type Spec struct {
+	Entity      string  `json:"entity"`      // Exported Go name, e.g. "PurchaseOrder"
+	Plural      string  `json:"plural"`      // Plural Go name; defaults to Entity + "s"
+	Table       string  `json:"table"`       // Table name; defaults to the snake case plural
+	Description string  `json:"description"` // Doc comment of the model type
+	Fields      []Field `json:"fields"`
+}
+
+// Output holds the code generated for one spec.
+type Output struct {
+	Model     []byte // models/<entity>.go: the model and its store interface
+	Store     []byte // store.go: the SQL store
+	Handlers  []byte // <entity>.go: the request type, handlers and RegisterRoutes
+	Tests     []byte // <entity>_test.go: the in-memory store and the handler tests
+	Migration string // The CREATE TABLE statement for a new migration in models/db/migrations
+}
+
+// sqlTypes maps the supported field types to their column definitions.
+var sqlTypes = map[string]string{
+	"string":    "VARCHAR(255) NOT NULL",
+	"int":       "INT NOT NULL",
+	"int64":     "BIGINT NOT NULL",
+	"float64":   "DECIMAL(10, 2) NOT NULL",
+	"bool":      "BOOLEAN NOT NULL",
+	"time.Time": "TIMESTAMP NOT NULL",
+}
+
+// sqlDefaults holds the defaults of optional columns.
+var sqlDefaults = map[string]string{
+	"string":  " DEFAULT ''",
+	"int":     " DEFAULT 0",
+	"int64":   " DEFAULT 0",
+	"float64": " DEFAULT 0",
+	"bool":    " DEFAULT FALSE",
+}
+
+var (
+	entityPattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	snakePattern  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// ParseSpec reads and checks a JSON spec and fills in the defaults.
+//
+// Parameters:
+//   - src: The contents of the spec file.
+//
+// Returns:
+//   - *Spec: The checked spec.
+//   - error: An error describing the first problem with the spec.
+func ParseSpec(src []byte) (*Spec, error) {
+	var spec Spec
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+
+	if !entityPattern.MatchString(spec.Entity) {
+		return nil, fmt.Errorf("entity %q must be an exported Go name such as PurchaseOrder", spec.Entity)
+	}
+	if spec.Plural == "" {
+		spec.Plural = spec.Entity + "s"
+	}
+	if !entityPattern.MatchString(spec.Plural) || spec.Plural == spec.Entity {
+		return nil, fmt.Errorf("plural %q must be an exported Go name that differs from the entity", spec.Plural)
+	}
+	if spec.Table == "" {
+		spec.Table = snake(spec.Plural)
+	}
+	if !snakePattern.MatchString(spec.Table) {
+		return nil, fmt.Errorf("table %q must be a snake_case name", spec.Table)
+	}
+	if spec.Description == "" {
+		spec.Description = fmt.Sprintf("%s represents %s %s.", spec.Entity, article(words(spec.Entity)), words(spec.Entity))
+	}
+
+	if len(spec.Fields) == 0 {
+		return nil, fmt.Errorf("entity %s has no fields", spec.Entity)
+	}
+	seen := map[string]bool{}
+	for _, field := range spec.Fields {
+		switch {
+		case !snakePattern.MatchString(field.Name):
+			return nil, fmt.Errorf("field %q must be a snake_case name", field.Name)
+		case field.Name == "id":
+			return nil, fmt.Errorf("field id is added to every entity and must not be listed")
+		case seen[field.Name]:
+			return nil, fmt.Errorf("field %s is listed twice", field.Name)
+		case sqlTypes[field.Type] == "":
+			return nil, fmt.Errorf("field %s has unsupported type %q", field.Name, field.Type)
+		case field.Required && field.Type == "bool":
+			return nil, fmt.Errorf("field %s is a bool and cannot be required", field.Name)
+		}
+		seen[field.Name] = true
+	}
+	return &spec, nil
+}
+
+// Generate writes the code of a module for spec. The store, handlers and tests belong to
+// package pkg; the model belongs to package models. The handlers file gets a go:generate
+// line that runs the generator on specFile, the spec's base name, again.
+func Generate(spec *Spec, pkg, specFile string) (*Output, error) {
+	data := newTemplateData(spec, pkg)
+	data.SpecFile = specFile
+	out := &Output{Migration: migration(spec)}
+	for _, file := range []struct {
+		tmpl *template.Template
+		dst  *[]byte
+	}{
+		{modelTemplate, &out.Model},
+		{storeTemplate, &out.Store},
+		{handlersTemplate, &out.Handlers},
+		{testsTemplate, &out.Tests},
+	} {
+		var b bytes.Buffer
+		if err := file.tmpl.Execute(&b, data); err != nil {
+			return nil, err
+		}
+		code, err := format.Source(b.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s: generated code does not parse: %w", file.tmpl.Name(), err)
+		}
+		*file.dst = code
+	}
+	return out, nil
+}
+
+// FileName returns the base name of the model, handlers and tests files of an entity,
+// e.g. "purchase_order" for PurchaseOrder.
+func FileName(spec *Spec) string {
+	return snake(spec.Entity)
+}
+
+// migration returns the CREATE TABLE statement of spec.
+func migration(spec *Spec) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- %s Table\nCREATE TABLE %s (\n    id SERIAL PRIMARY KEY", spec.Entity, spec.Table)
+	for _, field := range spec.Fields {
+		fmt.Fprintf(&b, ",\n    %s %s", field.Name, sqlTypes[field.Type])
+		if !field.Required {
+			b.WriteString(sqlDefaults[field.Type])
+		}
+	}
+	b.WriteString("\n);\n")
+	return b.String()
+}
+
+// templateField is a Field with the names used by the templates.
+type templateField struct {
+	Field
+	GoName string // Exported Go name, e.g. CreditLimit
+	Sample string // Go expression for a sample value in tests
+	Rule   string // validation.Checker call rejecting a required field that is not set
+}
+
+// templateData is the data passed to the templates.
+type templateData struct {
+	*Spec
+	Package   string
+	SpecFile  string
+	Var       string // Variable name of one entity, e.g. purchaseOrder
+	PluralVar string // Variable name of a list, e.g. purchaseOrders
+	Words     string // The entity in words, e.g. purchase order
+	A         string // The indefinite article of Words, "a" or "an"
+	Path      string // The route prefix used in examples, e.g. /purchase_orders
+	Fields    []templateField
+	Columns   string // Comma separated columns without id
+	NeedsTime bool
+	Required  bool // Whether any field is required
+}
+
+func newTemplateData(spec *Spec, pkg string) templateData {
+	data := templateData{
+		Spec:      spec,
+		Package:   pkg,
+		Var:       lowerFirst(spec.Entity),
+		PluralVar: lowerFirst(spec.Plural),
+		Words:     words(spec.Entity),
+		A:         article(words(spec.Entity)),
+		Path:      "/" + spec.Table,
+	}
+	columns := make([]string, 0, len(spec.Fields))
+	for _, field := range spec.Fields {
+		tf := templateField{Field: field, GoName: querygen.GoName(field.Name)}
+		switch field.Type {
+		case "string":
+			tf.Sample = fmt.Sprintf("%q", "Sample "+strings.ReplaceAll(field.Name, "_", " "))
+			tf.Rule = fmt.Sprintf("c.Required(%q, req.%s)", field.Name, tf.GoName)
+		case "int", "int64":
+			tf.Sample = "7"
+			tf.Rule = fmt.Sprintf("c.Check(req.%s != 0, %q, \"is required\")", tf.GoName, field.Name)
+		case "float64":
+			tf.Sample = "12.5"
+			tf.Rule = fmt.Sprintf("c.Check(req.%s != 0, %q, \"is required\")", tf.GoName, field.Name)
+		case "bool":
+			tf.Sample = "true"
+		case "time.Time":
+			tf.Sample = "time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)"
+			tf.Rule = fmt.Sprintf("c.Date(%q, req.%s)", field.Name, tf.GoName)
+			data.NeedsTime = true
+		}
+		data.Required = data.Required || field.Required
+		data.Fields = append(data.Fields, tf)
+		columns = append(columns, field.Name)
+	}
+	data.Columns = strings.Join(columns, ", ")
+	return data
+}
+
+// Placeholders returns "$1, $2, ..." for the fields.
+func (d templateData) Placeholders() string {
+	placeholders := make([]string, len(d.Fields))
+	for i := range d.Fields {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// Assignments returns "name = $1, email = $2, ..." for an UPDATE statement.
+func (d templateData) Assignments() string {
+	assignments := make([]string, len(d.Fields))
+	for i, field := range d.Fields {
+		assignments[i] = fmt.Sprintf("%s = $%d", field.Name, i+1)
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// IDPlaceholder returns the placeholder of the ID in an UPDATE statement.
+func (d templateData) IDPlaceholder() string {
+	return fmt.Sprintf("$%d", len(d.Fields)+1)
+}
+
+// Args returns the arguments of an INSERT or UPDATE statement for the variable v.
+func (d templateData) Args(v string) string {
+	args := make([]string, len(d.Fields))
+	for i, field := range d.Fields {
+		args[i] = v + "." + field.GoName
+	}
+	return strings.Join(args, ", ")
+}
+
+// ScanArgs returns the Scan destinations of a row, including the ID.
+func (d templateData) ScanArgs(v string) string {
+	args := []string{"&" + v + ".ID"}
+	for _, field := range d.Fields {
+		args = append(args, "&"+v+"."+field.GoName)
+	}
+	return strings.Join(args, ", ")
+}
+
+// snake converts a Go name to snake_case, e.g. PurchaseOrders to purchase_orders.
+func snake(name string) string {
+	return strings.ReplaceAll(words(name), " ", "_")
+}
+
+// words splits a Go name into lower case words, e.g. PurchaseOrder to "purchase order".
+// Runs of capitals such as SKU are kept together.
+func words(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := !unicode.IsUpper(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// article returns the indefinite article for a lower case word.
+func article(word string) string {
+	if strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+// lowerFirst returns a Go name as an unexported variable name, e.g. SKUCode to skuCode.
+func lowerFirst(name string) string {
+	parts := strings.Fields(words(name))
+	for i := 1; i < len(parts); i++ {
+		parts[i] = querygen.GoName(parts[i])
+	}
+	return strings.Join(parts, "")
+}
+
+// tag returns a struct tag with the JSON name of a field.
+func tag(name string) string {
+	return fmt.Sprintf("`json:%q`", name)
+}
+
+// sortedImports returns the import block of a generated file. Standard library imports
+// come first, then the module's own packages and then third-party packages.
+func sortedImports(std []string, own []string, thirdParty []string) string {
+	var groups []string
+	for _, group := range [][]string{std, own, thirdParty} {
+		if len(group) == 0 {
+			continue
+		}
+		sort.Strings(group)
+		lines := make([]string, len(group))
+		for i, path := range group {
+			lines[i] = fmt.Sprintf("\t%q", path)
+		}
+		groups = append(groups, strings.Join(lines, "\n"))
+	}
+	return "import (\n" + strings.Join(groups, "\n\n") + "\n)"
+}
@@ -0,0 +1,91 @@
+package scaffold
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const purchaseOrderSpec = `{
+  "entity": "PurchaseOrder",
+  "fields": [
+    {"name": "supplier_id", "type": "int", "required": true},
+    {"name": "reference", "type": "string"},
+    {"name": "ordered_at", "type": "time.Time"}
+  ]
+}`
+
+func TestParseSpecDefaults(t *testing.T) {
+	spec, err := ParseSpec([]byte(purchaseOrderSpec))
+	require.NoError(t, err)
+
+	assert.Equal(t, "PurchaseOrders", spec.Plural)
+	assert.Equal(t, "purchase_orders", spec.Table)
+	assert.Equal(t, "PurchaseOrder represents a purchase order.", spec.Description)
+	assert.Equal(t, "purchase_order", FileName(spec))
+}
+
+func TestParseSpecRejectsInvalidSpecs(t *testing.T) {
+	tests := map[string]string{
+		"unexported entity": `{"entity": "supplier", "fields": [{"name": "name", "type": "string"}]}`,
+		"no fields":         `{"entity": "Supplier"}`,
+		"listed id":         `{"entity": "Supplier", "fields": [{"name": "id", "type": "int"}]}`,
+		"camel case field":  `{"entity": "Supplier", "fields": [{"name": "creditLimit", "type": "float64"}]}`,
+		"duplicate field":   `{"entity": "Supplier", "fields": [{"name": "name", "type": "string"}, {"name": "name", "type": "string"}]}`,
+		"unsupported type":  `{"entity": "Supplier", "fields": [{"name": "tags", "type": "[]string"}]}`,
+		"required bool":     `{"entity": "Supplier", "fields": [{"name": "active", "type": "bool", "required": true}]}`,
+		"unknown key":       `{"entity": "Supplier", "colour": "red", "fields": [{"name": "name", "type": "string"}]}`,
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSpec([]byte(src))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	spec, err := ParseSpec([]byte(purchaseOrderSpec))
+	require.NoError(t, err)
+	out, err := Generate(spec, "purchase_order_handlers", "purchase_order.json")
+	require.NoError(t, err)
+
+	files := map[string][]byte{"model": out.Model, "store": out.Store, "handlers": out.Handlers, "tests": out.Tests}
+	for name, code := range files {
+		_, err := parser.ParseFile(token.NewFileSet(), name+".go", code, parser.ParseComments)
+		require.NoError(t, err, name)
+	}
+
+	assert.Contains(t, string(out.Model), "type PurchaseOrderStore interface")
+	assert.Contains(t, string(out.Model), "SupplierID int       `json:\"supplier_id\"`")
+	assert.Contains(t, string(out.Store), `"INSERT INTO purchase_orders (supplier_id, reference, ordered_at) VALUES ($1, $2, $3) RETURNING id"`)
+	assert.Contains(t, string(out.Store), `"UPDATE purchase_orders SET supplier_id = $1, reference = $2, ordered_at = $3 WHERE id = $4"`)
+	assert.Contains(t, string(out.Store), `models.NotFound("purchase order %d not found", id)`)
+	assert.Contains(t, string(out.Handlers), "//go:generate go run erp/cmd/scaffold purchase_order.json")
+	assert.Contains(t, string(out.Handlers), "func RegisterRoutes(router *mux.Router, store models.PurchaseOrderStore)")
+	assert.Contains(t, string(out.Handlers), `c.Check(req.SupplierID != 0, "supplier_id", "is required")`)
+	assert.Contains(t, string(out.Handlers), "if !validation.Decode(w, r, &req) {")
+	assert.Contains(t, string(out.Tests), `"create without required fields"`)
+
+	assert.Equal(t, `-- PurchaseOrder Table
+CREATE TABLE purchase_orders (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL,
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    ordered_at TIMESTAMP NOT NULL
+);
+`, out.Migration)
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, "purchase order", words("PurchaseOrder"))
+	assert.Equal(t, "sku code", words("SKUCode"))
+	assert.Equal(t, "purchase_orders", snake("PurchaseOrders"))
+	assert.Equal(t, "purchaseOrder", lowerFirst("PurchaseOrder"))
+	assert.Equal(t, "skuCode", lowerFirst("SKUCode"))
+	assert.Equal(t, "an", article("invoice line"))
+	assert.Equal(t, "a", article("supplier"))
+}
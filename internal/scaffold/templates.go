@@ -0,0 +1,505 @@
+package scaffold
+
+import "text/template"
+
+// ModelImports returns the import block of the model file.
+func (d templateData) ModelImports() string {
+	if d.NeedsTime {
+		return sortedImports([]string{"context", "time"}, nil, nil)
+	}
+	return `import "context"`
+}
+
+// StoreImports returns the import block of the store file.
+func (d templateData) StoreImports() string {
+	return sortedImports([]string{"context", "database/sql", "fmt"}, []string{"erp/models"}, nil)
+}
+
+// HandlersImports returns the import block of the handlers file.
+func (d templateData) HandlersImports() string {
+	std := []string{"net/http", "strconv"}
+	if d.NeedsTime {
+		std = append(std, "time")
+	}
+	erp := []string{"erp/controllers/httperr", "erp/controllers/respond", "erp/controllers/validation", "erp/models"}
+	return sortedImports(std, erp, []string{"github.com/gorilla/mux"})
+}
+
+// TestsImports returns the import block of the tests file.
+func (d templateData) TestsImports() string {
+	std := []string{"bytes", "context", "encoding/json", "net/http", "net/http/httptest", "testing"}
+	if d.NeedsTime {
+		std = append(std, "time")
+	}
+	return sortedImports(std, []string{"erp/models"}, []string{"github.com/gorilla/mux", "github.com/stretchr/testify/assert"})
+}
+
+var funcs = template.FuncMap{"tag": tag}
+
+var modelTemplate = template.Must(template.New("model").Funcs(funcs).Parse(`package models
+
+{{.ModelImports}}
+
+// {{.Description}}
+type {{.Entity}} struct {
+	ID int {{tag "id"}}
+{{- range .Fields}}
+	{{.GoName}} {{.Type}} {{tag .Name}}
+{{- end}}
+}
+
+// {{.Entity}}Store defines an interface for {{.Words}}-related database operations
+type {{.Entity}}Store interface {
+	Create{{.Entity}}(ctx context.Context, {{.Var}} *{{.Entity}}) error
+	Get{{.Entity}}ByID(ctx context.Context, id int) (*{{.Entity}}, error)
+	List{{.Plural}}(ctx context.Context) ([]{{.Entity}}, error)
+	Update{{.Entity}}(ctx context.Context, {{.Var}} *{{.Entity}}) error
+	Delete{{.Entity}}(ctx context.Context, id int) error
+}
+`))
+
+var storeTemplate = template.Must(template.New("store").Funcs(funcs).Parse(`package {{.Package}}
+
+{{.StoreImports}}
+
+// DB{{.Entity}}Store implements models.{{.Entity}}Store using a SQL database.
+type DB{{.Entity}}Store struct {
+	DB *sql.DB
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan{{.Entity}} reads {{.A}} {{.Words}} from a row of the columns selected by the queries below.
+func scan{{.Entity}}(row rowScanner) (models.{{.Entity}}, error) {
+	var {{.Var}} models.{{.Entity}}
+	err := row.Scan({{.ScanArgs .Var}})
+	return {{.Var}}, err
+}
+
+// Create{{.Entity}} inserts a new {{.Words}} into the database and sets its ID.
+//
+// Parameters:
+//   - {{.Var}}: A pointer to the {{.Entity}} to be created.
+//
+// Returns:
+//   - An error if the creation fails, otherwise nil.
+func (s *DB{{.Entity}}Store) Create{{.Entity}}(ctx context.Context, {{.Var}} *models.{{.Entity}}) error {
+	err := s.DB.QueryRowContext(ctx,
+		"INSERT INTO {{.Table}} ({{.Columns}}) VALUES ({{.Placeholders}}) RETURNING id",
+		{{.Args .Var}},
+	).Scan(&{{.Var}}.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create {{.Words}}: %w", err)
+	}
+	return nil
+}
+
+// Get{{.Entity}}ByID retrieves {{.A}} {{.Words}} from the database by its ID.
+//
+// Parameters:
+//   - id: The ID of the {{.Words}} to retrieve.
+//
+// Returns:
+//   - A pointer to the {{.Entity}} if found.
+//   - models.ErrNotFound if the {{.Words}} does not exist.
+//   - An error if the operation fails.
+func (s *DB{{.Entity}}Store) Get{{.Entity}}ByID(ctx context.Context, id int) (*models.{{.Entity}}, error) {
+	{{.Var}}, err := scan{{.Entity}}(s.DB.QueryRowContext(ctx, "SELECT id, {{.Columns}} FROM {{.Table}} WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, models.NotFound("{{.Words}} %d not found", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to retrieve {{.Words}}: %w", err)
+	}
+	return &{{.Var}}, nil
+}
+
+// List{{.Plural}} retrieves all {{.Words}} records ordered by ID.
+//
+// Returns:
+//   - The {{.Words}} records; an empty slice if there are none.
+//   - An error if the operation fails.
+func (s *DB{{.Entity}}Store) List{{.Plural}}(ctx context.Context) ([]models.{{.Entity}}, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, {{.Columns}} FROM {{.Table}} ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list {{.Words}} records: %w", err)
+	}
+	defer rows.Close()
+
+	{{.PluralVar}} := []models.{{.Entity}}{}
+	for rows.Next() {
+		{{.Var}}, err := scan{{.Entity}}(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read {{.Words}}: %w", err)
+		}
+		{{.PluralVar}} = append({{.PluralVar}}, {{.Var}})
+	}
+	return {{.PluralVar}}, rows.Err()
+}
+
+// Update{{.Entity}} updates an existing {{.Words}} in the database.
+//
+// Parameters:
+//   - {{.Var}}: A pointer to the {{.Entity}} containing the updated details.
+//
+// Returns:
+//   - models.ErrNotFound if the {{.Words}} does not exist.
+//   - An error if the update fails, otherwise nil.
+func (s *DB{{.Entity}}Store) Update{{.Entity}}(ctx context.Context, {{.Var}} *models.{{.Entity}}) error {
+	result, err := s.DB.ExecContext(ctx,
+		"UPDATE {{.Table}} SET {{.Assignments}} WHERE id = {{.IDPlaceholder}}",
+		{{.Args .Var}}, {{.Var}}.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update {{.Words}}: %w", err)
+	}
+	return checkAffected(result, {{.Var}}.ID)
+}
+
+// Delete{{.Entity}} removes {{.A}} {{.Words}} from the database by its ID.
+//
+// Parameters:
+//   - id: The ID of the {{.Words}} to delete.
+//
+// Returns:
+//   - models.ErrNotFound if the {{.Words}} does not exist.
+//   - An error if the deletion fails, otherwise nil.
+func (s *DB{{.Entity}}Store) Delete{{.Entity}}(ctx context.Context, id int) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM {{.Table}} WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete {{.Words}}: %w", err)
+	}
+	return checkAffected(result, id)
+}
+
+// checkAffected reports models.ErrNotFound if a change to the {{.Words}} with the given ID
+// affected no rows.
+func checkAffected(result sql.Result, id int) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.NotFound("{{.Words}} %d not found", id)
+	}
+	return nil
+}
+`))
+
+var handlersTemplate = template.Must(template.New("handlers").Funcs(funcs).Parse(`// Package {{.Package}} provides HTTP handlers for creating, retrieving, listing, updating
+// and deleting {{.Words}} records.
+package {{.Package}}
+
+//go:generate go run erp/cmd/scaffold {{.SpecFile}}
+
+{{.HandlersImports}}
+
+// {{.Entity}}Handlers provides HTTP handlers for managing {{.Words}} records.
+type {{.Entity}}Handlers struct {
+	Store models.{{.Entity}}Store
+}
+
+// {{.Entity}}Request is the request body for creating or updating {{.A}} {{.Words}}. The ID is
+// assigned by the database or taken from the URL.
+type {{.Entity}}Request struct {
+{{- range .Fields}}
+	{{.GoName}} {{.Type}} {{tag .Name}}
+{{- end}}
+}
+
+// Validate checks that the required fields are set.
+func (req {{.Entity}}Request) Validate() error {
+	var c validation.Checker
+{{- range .Fields}}{{if .Required}}
+	{{.Rule}}
+{{- end}}{{end}}
+	return c.Err()
+}
+
+// {{.Entity}} returns the {{.Words}} described by the request.
+func (req {{.Entity}}Request) {{.Entity}}() models.{{.Entity}} {
+	return models.{{.Entity}}{
+{{- range .Fields}}
+		{{.GoName}}: req.{{.GoName}},
+{{- end}}
+	}
+}
+
+// RegisterRoutes maps {{.Words}} routes to their respective handler functions.
+//
+// Parameters:
+//   - router: The HTTP router (from the Gorilla Mux library) where the routes are registered,
+//     usually a subrouter for {{.Path}}.
+//   - store: An implementation of the {{.Entity}}Store interface.
+func RegisterRoutes(router *mux.Router, store models.{{.Entity}}Store) {
+	handler := &{{.Entity}}Handlers{Store: store}
+
+	router.HandleFunc("", handler.Create{{.Entity}}).Methods("POST")
+	router.HandleFunc("", handler.List{{.Plural}}).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.Get{{.Entity}}).Methods("GET")
+	router.HandleFunc("/{id:[0-9]+}", handler.Update{{.Entity}}).Methods("PUT")
+	router.HandleFunc("/{id:[0-9]+}", handler.Delete{{.Entity}}).Methods("DELETE")
+}
+
+// Create{{.Entity}} creates a new {{.Words}}.
+//
+// HTTP Method: POST
+// URL Path: /
+//
+// Request Body:
+//   - JSON with the fields of {{.Entity}}Request.
+//
+// Response:
+//   - Status Code: 201 (Created) with the created {{.Words}} in JSON format.
+//   - Status Code: 400 (Bad Request) if the input data is not JSON.
+{{- if .Required}}
+//   - Status Code: 422 (Unprocessable Entity) if a required field is missing; the error
+//     details list them.
+{{- end}}
+//   - Status Code: 500 (Internal Server Error) if the {{.Words}} could not be saved.
+func (h *{{.Entity}}Handlers) Create{{.Entity}}(w http.ResponseWriter, r *http.Request) {
+	var req {{.Entity}}Request
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	{{.Var}} := req.{{.Entity}}()
+	if err := h.Store.Create{{.Entity}}(r.Context(), &{{.Var}}); err != nil {
+		httperr.Write(w, err, "Failed to create {{.Words}}")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, {{.Var}})
+}
+
+// Get{{.Entity}} retrieves {{.A}} {{.Words}} by its ID.
+//
+// HTTP Method: GET
+// URL Path: /{id}
+//
+// Response:
+//   - Status Code: 200 (OK) with the {{.Words}} in JSON format.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the {{.Words}} does not exist.
+//   - Status Code: 500 (Internal Server Error) if the {{.Words}} could not be loaded.
+func (h *{{.Entity}}Handlers) Get{{.Entity}}(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid {{.Words}} ID")
+		return
+	}
+
+	{{.Var}}, err := h.Store.Get{{.Entity}}ByID(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err, "Failed to load {{.Words}}")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, {{.Var}})
+}
+
+// List{{.Plural}} returns all {{.Words}} records.
+//
+// HTTP Method: GET
+// URL Path: /
+//
+// Response:
+//   - Status Code: 200 (OK) with the list of {{.Words}} records.
+//   - Status Code: 500 (Internal Server Error) if the records could not be read.
+func (h *{{.Entity}}Handlers) List{{.Plural}}(w http.ResponseWriter, r *http.Request) {
+	{{.PluralVar}}, err := h.Store.List{{.Plural}}(r.Context())
+	if err != nil {
+		httperr.Write(w, err, "Failed to list {{.Words}} records")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, {{.PluralVar}})
+}
+
+// Update{{.Entity}} updates an existing {{.Words}}.
+//
+// HTTP Method: PUT
+// URL Path: /{id}
+//
+// Request Body:
+//   - JSON with the fields of {{.Entity}}Request.
+//
+// Response:
+//   - Status Code: 200 (OK) with the updated {{.Words}} in JSON format.
+//   - Status Code: 400 (Bad Request) if the ID is invalid or the input data is not JSON.
+//   - Status Code: 404 (Not Found) if the {{.Words}} does not exist.
+{{- if .Required}}
+//   - Status Code: 422 (Unprocessable Entity) if a required field is missing; the error
+//     details list them.
+{{- end}}
+//   - Status Code: 500 (Internal Server Error) if the update fails.
+func (h *{{.Entity}}Handlers) Update{{.Entity}}(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid {{.Words}} ID")
+		return
+	}
+
+	var req {{.Entity}}Request
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	{{.Var}} := req.{{.Entity}}()
+	{{.Var}}.ID = id
+	if err := h.Store.Update{{.Entity}}(r.Context(), &{{.Var}}); err != nil {
+		httperr.Write(w, err, "Failed to update {{.Words}}")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, {{.Var}})
+}
+
+// Delete{{.Entity}} deletes {{.A}} {{.Words}} by its ID.
+//
+// HTTP Method: DELETE
+// URL Path: /{id}
+//
+// Response:
+//   - Status Code: 204 (No Content) if the {{.Words}} was deleted.
+//   - Status Code: 400 (Bad Request) if the ID is invalid.
+//   - Status Code: 404 (Not Found) if the {{.Words}} does not exist.
+//   - Status Code: 500 (Internal Server Error) if the deletion fails.
+func (h *{{.Entity}}Handlers) Delete{{.Entity}}(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "Invalid {{.Words}} ID")
+		return
+	}
+
+	if err := h.Store.Delete{{.Entity}}(r.Context(), id); err != nil {
+		httperr.Write(w, err, "Failed to delete {{.Words}}")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+`))
+
+var testsTemplate = template.Must(template.New("tests").Funcs(funcs).Parse(`package {{.Package}}
+
+{{.TestsImports}}
+
+// memory{{.Entity}}Store is an in-memory models.{{.Entity}}Store for the handler tests.
+type memory{{.Entity}}Store struct {
+	{{.PluralVar}} map[int]models.{{.Entity}}
+	nextID int
+}
+
+func newMemory{{.Entity}}Store() *memory{{.Entity}}Store {
+	return &memory{{.Entity}}Store{ {{- .PluralVar}}: map[int]models.{{.Entity}}{}, nextID: 1}
+}
+
+func (m *memory{{.Entity}}Store) Create{{.Entity}}(ctx context.Context, {{.Var}} *models.{{.Entity}}) error {
+	{{.Var}}.ID = m.nextID
+	m.nextID++
+	m.{{.PluralVar}}[{{.Var}}.ID] = *{{.Var}}
+	return nil
+}
+
+func (m *memory{{.Entity}}Store) Get{{.Entity}}ByID(ctx context.Context, id int) (*models.{{.Entity}}, error) {
+	{{.Var}}, ok := m.{{.PluralVar}}[id]
+	if !ok {
+		return nil, models.NotFound("{{.Words}} %d not found", id)
+	}
+	return &{{.Var}}, nil
+}
+
+func (m *memory{{.Entity}}Store) List{{.Plural}}(ctx context.Context) ([]models.{{.Entity}}, error) {
+	{{.PluralVar}} := []models.{{.Entity}}{}
+	for id := 1; id < m.nextID; id++ {
+		if {{.Var}}, ok := m.{{.PluralVar}}[id]; ok {
+			{{.PluralVar}} = append({{.PluralVar}}, {{.Var}})
+		}
+	}
+	return {{.PluralVar}}, nil
+}
+
+func (m *memory{{.Entity}}Store) Update{{.Entity}}(ctx context.Context, {{.Var}} *models.{{.Entity}}) error {
+	if _, ok := m.{{.PluralVar}}[{{.Var}}.ID]; !ok {
+		return models.NotFound("{{.Words}} %d not found", {{.Var}}.ID)
+	}
+	m.{{.PluralVar}}[{{.Var}}.ID] = *{{.Var}}
+	return nil
+}
+
+func (m *memory{{.Entity}}Store) Delete{{.Entity}}(ctx context.Context, id int) error {
+	if _, ok := m.{{.PluralVar}}[id]; !ok {
+		return models.NotFound("{{.Words}} %d not found", id)
+	}
+	delete(m.{{.PluralVar}}, id)
+	return nil
+}
+
+// sample{{.Entity}}Request returns a request with every field set.
+func sample{{.Entity}}Request() {{.Entity}}Request {
+	return {{.Entity}}Request{
+{{- range .Fields}}
+		{{.GoName}}: {{.Sample}},
+{{- end}}
+	}
+}
+
+// Test{{.Entity}}Handlers runs the {{.Words}} routes in order against one store: the
+// {{.Words}} created first is read, listed, updated and deleted.
+func Test{{.Entity}}Handlers(t *testing.T) {
+	store := newMemory{{.Entity}}Store()
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("{{.Path}}").Subrouter(), store)
+
+	sample, _ := json.Marshal(sample{{.Entity}}Request())
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   []byte
+		status int
+	}{
+		{"create", "POST", "{{.Path}}", sample, http.StatusCreated},
+		{"create with invalid payload", "POST", "{{.Path}}", []byte("{"), http.StatusBadRequest},
+{{- if .Required}}
+		{"create without required fields", "POST", "{{.Path}}", []byte("{}"), http.StatusUnprocessableEntity},
+{{- end}}
+		{"get", "GET", "{{.Path}}/1", nil, http.StatusOK},
+		{"get missing", "GET", "{{.Path}}/99", nil, http.StatusNotFound},
+		{"list", "GET", "{{.Path}}", nil, http.StatusOK},
+		{"update", "PUT", "{{.Path}}/1", sample, http.StatusOK},
+		{"update missing", "PUT", "{{.Path}}/99", sample, http.StatusNotFound},
+		{"delete", "DELETE", "{{.Path}}/1", nil, http.StatusNoContent},
+		{"delete again", "DELETE", "{{.Path}}/1", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+// TestCreate{{.Entity}}StoresRequest checks that the created {{.Words}} holds the request's fields.
+func TestCreate{{.Entity}}StoresRequest(t *testing.T) {
+	store := newMemory{{.Entity}}Store()
+	router := mux.NewRouter()
+	RegisterRoutes(router.PathPrefix("{{.Path}}").Subrouter(), store)
+
+	body, _ := json.Marshal(sample{{.Entity}}Request())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "{{.Path}}", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	want := sample{{.Entity}}Request().{{.Entity}}()
+	want.ID = 1
+	var got models.{{.Entity}}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, want, got)
+	assert.Equal(t, want, store.{{.PluralVar}}[1])
+}
+`))
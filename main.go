@@ -1,26 +1,226 @@
 package main
 
 import (
+	"context"
+	"erp/config"
+	"erp/controllers/allocation"
+	"erp/controllers/backup"
+	"erp/controllers/events"
+	"erp/controllers/export"
+	"erp/controllers/giftcards"
+	"erp/controllers/handlers/allocation_handlers"
+	"erp/controllers/handlers/bundle_handlers"
+	"erp/controllers/handlers/email_template_handlers"
+	"erp/controllers/handlers/gift_card_handlers"
+	"erp/controllers/handlers/installment_handlers"
+	"erp/controllers/handlers/job_handlers"
+	"erp/controllers/handlers/kpi_handlers"
+	"erp/controllers/handlers/late_fee_handlers"
+	"erp/controllers/handlers/loyalty_handlers"
+	"erp/controllers/handlers/notification_handlers"
+	"erp/controllers/handlers/product_handlers"
+	"erp/controllers/handlers/recognition_handlers"
+	"erp/controllers/handlers/report_handlers"
+	"erp/controllers/handlers/sales_order_handlers"
+	"erp/controllers/handlers/standing_order_handlers"
+	"erp/controllers/handlers/stock_handlers"
+	"erp/controllers/handlers/system_handlers"
+	"erp/controllers/installments"
+	"erp/controllers/inventory"
+	"erp/controllers/jobs"
+	"erp/controllers/kpi"
+	"erp/controllers/latefees"
+	"erp/controllers/leave"
+	"erp/controllers/loyalty"
+	"erp/controllers/mailer"
+	"erp/controllers/mailtemplates"
+	"erp/controllers/maintenance"
+	"erp/controllers/outbox"
+	"erp/controllers/recognition"
+	"erp/controllers/retention"
 	"erp/controllers/routes"
+	"erp/controllers/salesorders"
+	"erp/controllers/sandbox"
+	"erp/controllers/scheduler"
+	"erp/controllers/standingorders"
+	"erp/controllers/storage"
 	"erp/models/db"
+	"erp/models/db/migrations"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/handlers"
 	_ "github.com/lib/pq"
 )
 
 func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Initialize the database connection
 	var err error
-	dbInstance, err := db.InitDB() // Use a local variable to avoid global state
+	dbInstance, err := db.InitDB(ctx) // Use a local variable to avoid global state
 	if err != nil {
 		log.Fatal("Failed to connect to the database:", err)
 	}
 	defer dbInstance.Close()
 
+	cfg := config.Load()
+
+	// Bring the schema up to date before anything reads it; several servers starting at once
+	// take turns, and the first applies each migration
+	if cfg.Migrations.OnStart {
+		runner, err := migrations.New(dbInstance)
+		if err != nil {
+			log.Fatal("Failed to load migrations:", err)
+		}
+		runner.Logf = log.Printf
+		if _, err := runner.Up(ctx); err != nil {
+			log.Fatal("Failed to migrate the database:", err)
+		}
+	}
+
+	// The loyalty program accrues points from posted invoices and expires them nightly
+	loyaltyService := loyalty.NewService(&loyalty_handlers.DBLoyaltyStore{DB: dbInstance}, cfg.Loyalty)
+
+	// Background work stops writing in read-only and maintenance mode, like the API
+	systemMode := maintenance.NewService(&system_handlers.DBSystemModeStore{DB: dbInstance}, maintenance.DefaultTTL)
+
+	// Start the dispatcher that delivers emails, webhooks and domain events from the outbox
+	publisher, err := events.NewPublisher(cfg.Events)
+	if err != nil {
+		log.Fatal("Failed to configure event publisher:", err)
+	}
+	defer publisher.Close()
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		log.Fatal("Failed to configure mailer:", err)
+	}
+	dispatcher := &outbox.Dispatcher{
+		Store: &outbox.DBOutboxStore{DB: dbInstance},
+		Handlers: map[string]outbox.Handler{
+			outbox.KindEmail:   &outbox.EmailHandler{Mailer: mail},
+			outbox.KindWebhook: &outbox.WebhookHandler{},
+			outbox.KindEvent: &events.PublishHandler{
+				Publisher: publisher,
+				Subscribers: []events.Subscriber{
+					&notification_handlers.Generator{Store: &notification_handlers.DBNotificationStore{DB: dbInstance}},
+					loyaltyService, // Accrues points for posted invoices
+				},
+			},
+		},
+		Interval:    cfg.Outbox.Interval,
+		BatchSize:   cfg.Outbox.BatchSize,
+		MaxAttempts: cfg.Outbox.MaxAttempts,
+		BaseBackoff: cfg.Outbox.BaseBackoff,
+		MaxBackoff:  cfg.Outbox.MaxBackoff,
+	}
+	if cfg.Sandbox.Enabled {
+		// Demo deployments never reach real inboxes or endpoints
+		log.Println("Sandbox mode: outgoing emails and webhooks are captured, not sent")
+		capture := outbox.HandlerFunc(sandbox.NewService(dbInstance, &storage.LocalStorage{Dir: cfg.Sandbox.Dir}).Capture)
+		dispatcher.Handlers[outbox.KindEmail] = capture
+		dispatcher.Handlers[outbox.KindWebhook] = capture
+	}
+	dispatcher.Writable = systemMode.Writable
+	go dispatcher.Run(ctx)
+
+	// Start the scheduler that keeps report summary tables up to date, evaluates KPI alert
+	// rules, applies scheduled prices, expires loyalty points and gift cards, takes the
+	// nightly backup, purges expired records, closes the leave year, allocates shared
+	// expenses to cost centers, reminds customers of invoice installments, charges late fees
+	// on overdue invoices, recognizes deferred service revenue, checks stock against its
+	// reorder level, snapshots stock nightly and drafts the sales orders of standing orders
+	reportStore := &report_handlers.DBReportStore{DB: dbInstance}
+	sched := scheduler.New()
+	sched.Writable = systemMode.Writable
+	sched.Every("refresh report summaries", cfg.Reports.RefreshInterval, func(ctx context.Context) error {
+		return report_handlers.Refresh(ctx, reportStore, time.Now())
+	})
+	kpiService := kpi.NewService(&kpi_handlers.DBKPIStore{DB: dbInstance})
+	sched.Every("evaluate KPI rules", cfg.Reports.KPIInterval, func(ctx context.Context) error {
+		_, err := kpiService.Evaluate(ctx, time.Now())
+		return err
+	})
+	productStore := product_handlers.NewDBProductStore(dbInstance)
+	sched.Every("apply scheduled price changes", time.Hour, func(ctx context.Context) error {
+		_, err := productStore.ApplyDuePriceChanges(ctx, time.Now())
+		return err
+	})
+	sched.Daily("expire loyalty points", 1, 0, func(ctx context.Context) error {
+		return loyaltyService.Expire(ctx, time.Now())
+	})
+	giftCardService := giftcards.NewService(&gift_card_handlers.DBGiftCardStore{DB: dbInstance}, cfg.GiftCards)
+	sched.Daily("expire gift cards", 1, 30, func(ctx context.Context) error {
+		return giftCardService.Expire(ctx, time.Now())
+	})
+	if cfg.Backup.Hour >= 0 {
+		backupService := backup.NewService(dbInstance, &storage.LocalStorage{Dir: cfg.Backup.Dir},
+			jobs.NewRunner(&job_handlers.DBJobStore{DB: dbInstance}), cfg.Backup.Keep)
+		sched.Daily("nightly backup", cfg.Backup.Hour, 0, backupService.Nightly)
+	}
+	if cfg.Export.Hour >= 0 {
+		exportService := export.NewService(dbInstance, &storage.LocalStorage{Dir: cfg.Export.Dir},
+			jobs.NewRunner(&job_handlers.DBJobStore{DB: dbInstance}), cfg.Export.Tables, cfg.Export.Lag)
+		sched.Daily("nightly warehouse export", cfg.Export.Hour, 0, exportService.Nightly)
+	}
+	if cfg.Retention.Hour >= 0 {
+		retentionService := retention.NewService(dbInstance, jobs.NewRunner(&job_handlers.DBJobStore{DB: dbInstance}),
+			cfg.Retention.FinancialMinimum)
+		sched.Daily("purge expired records", cfg.Retention.Hour, 0, retentionService.Nightly)
+	}
+	if cfg.Leave.YearEndHour >= 0 {
+		leaveService := leave.NewService(dbInstance, jobs.NewRunner(&job_handlers.DBJobStore{DB: dbInstance}))
+		sched.Daily("close leave year", cfg.Leave.YearEndHour, 0, leaveService.YearEnd)
+	}
+	if cfg.Allocation.Hour >= 0 {
+		allocationService := allocation.NewService(&allocation_handlers.DBAllocationStore{DB: dbInstance}, cfg.Allocation.Day)
+		sched.Daily("allocate shared expenses", cfg.Allocation.Hour, 0, allocationService.Monthly)
+	}
+	if cfg.Installments.ReminderHour >= 0 {
+		installmentService := installments.NewService(&installment_handlers.DBInstallmentStore{DB: dbInstance},
+			mailtemplates.NewService(&email_template_handlers.DBEmailTemplateStore{DB: dbInstance}, cfg.Mail.Locale),
+			func(ctx context.Context, msg mailer.Message) error {
+				return outbox.Enqueue(ctx, dbInstance, outbox.KindEmail, msg)
+			},
+			cfg.Installments.ReminderDays)
+		sched.Daily("remind installments", cfg.Installments.ReminderHour, 0, installmentService.Remind)
+	}
+	if cfg.LateFees.Hour >= 0 {
+		lateFeeService := latefees.NewService(&late_fee_handlers.DBLateFeeStore{DB: dbInstance})
+		sched.Daily("charge late fees", cfg.LateFees.Hour, 0, lateFeeService.Daily)
+	}
+	if cfg.Recognition.Hour >= 0 {
+		recognitionService := recognition.NewService(&recognition_handlers.DBRevenueScheduleStore{DB: dbInstance}, cfg.Recognition.Day)
+		sched.Daily("recognize deferred revenue", cfg.Recognition.Hour, 0, recognitionService.Monthly)
+	}
+	if cfg.StockAlerts.Interval > 0 {
+		var notifiers []inventory.Notifier
+		if len(cfg.StockAlerts.Emails) > 0 {
+			notifiers = append(notifiers, &inventory.EmailNotifier{Mailer: &outbox.Mailer{DB: dbInstance}, To: cfg.StockAlerts.Emails})
+		}
+		if cfg.StockAlerts.WebhookURL != "" {
+			notifiers = append(notifiers, &inventory.WebhookNotifier{DB: dbInstance, URL: cfg.StockAlerts.WebhookURL,
+				Secret: cfg.StockAlerts.WebhookSecret})
+		}
+		alertService := inventory.NewAlertService(&stock_handlers.DBStockAlertStore{DB: dbInstance}, notifiers...)
+		sched.Every("check stock reorder levels", cfg.StockAlerts.Interval, alertService.Check)
+	}
+	if cfg.Snapshots.Hour >= 0 {
+		snapshotService := inventory.NewSnapshotService(&stock_handlers.DBStockSnapshotStore{DB: dbInstance})
+		sched.Daily("take stock snapshot", cfg.Snapshots.Hour, 30, snapshotService.Nightly)
+	}
+	if cfg.Standing.Hour >= 0 {
+		salesOrderService := salesorders.NewService(&sales_order_handlers.DBSalesOrderStore{DB: dbInstance},
+			stock_handlers.NewDBStockStore(dbInstance), &bundle_handlers.DBBundleStore{DB: dbInstance})
+		standingOrderService := standingorders.NewService(&standing_order_handlers.DBStandingOrderStore{DB: dbInstance}, salesOrderService)
+		sched.Daily("generate standing orders", cfg.Standing.Hour, 0, standingOrderService.Daily)
+	}
+	go sched.Run(ctx)
+
 	// Initialize the routes, passing the db instance
-	router := routes.InitRoutes(dbInstance)
+	router := routes.InitRoutes(dbInstance, cfg)
 
 	// Set up CORS
 	corsObj := handlers.AllowedOrigins([]string{"*"}) // You can replace "*" with your frontend URL
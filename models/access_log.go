@@ -0,0 +1,52 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Reasons recorded for failed sign-in attempts
+const (
+	AccessUnknownUser     = "unknown_user"
+	AccessInvalidPassword = "invalid_password"
+	AccessDeactivated     = "deactivated"
+	AccessPasswordNotSet  = "password_not_set"
+)
+
+// AccessEvent is one sign-in attempt. The location is approximated by the client's network
+// (its /24 IPv4 or /48 IPv6 prefix) and the device by its user agent; a successful sign-in
+// is flagged when either has not been seen in the user's earlier successful sign-ins.
+type AccessEvent struct {
+	ID           int       `json:"id"`
+	Email        string    `json:"email"` // As entered, so failures for unknown users are kept too
+	Success      bool      `json:"success"`
+	Reason       string    `json:"reason,omitempty"` // One of the Access* reasons for failures
+	IP           string    `json:"ip"`
+	Network      string    `json:"network"`
+	ForwardedFor string    `json:"forwarded_for,omitempty"` // X-Forwarded-For as sent by the client; not verified
+	UserAgent    string    `json:"user_agent"`
+	NewLocation  bool      `json:"new_location"`
+	NewDevice    bool      `json:"new_device"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+// AccessLogFilter selects access events. Zero values do not filter.
+type AccessLogFilter struct {
+	Email   string
+	IP      string
+	Success *bool
+	NewOnly bool // Only sign-ins from a new location or device
+	From    time.Time
+	To      time.Time // Exclusive
+	Limit   int
+}
+
+// AccessLogStore defines an interface for access log database operations
+type AccessLogStore interface {
+	// RecordAccess stores a sign-in attempt. For successful sign-ins it first sets
+	// NewLocation and NewDevice from the user's earlier successful sign-ins; a user's first
+	// sign-in is never flagged.
+	RecordAccess(ctx context.Context, event *AccessEvent) error
+	// ListAccessEvents returns the matching events, newest first.
+	ListAccessEvents(ctx context.Context, filter AccessLogFilter) ([]AccessEvent, error)
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Account types of the chart of accounts
+const (
+	AccountAsset     = "asset"
+	AccountLiability = "liability"
+	AccountEquity    = "equity"
+	AccountIncome    = "income"
+	AccountExpense   = "expense"
+)
+
+// Account is an account of the chart of accounts. Codes are hierarchical: dot-separated
+// numbers, the parent of "1.2.3" being "1.2". An account has its parent's type.
+type Account struct {
+	ID          int       `json:"id"`
+	Code        string    `json:"code"` // e.g. "1.1.2"; unique and fixed once created
+	Name        string    `json:"name"`
+	Type        string    `json:"type"` // One of the Account* type constants; fixed once created
+	ParentID    *int      `json:"parent_id,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"` // Inactive accounts cannot be used on new records
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"` // Incremented by every update; a rename names the version it changes
+}
+
+// AccountStore defines the operations for the chart of accounts.
+type AccountStore interface {
+	CreateAccount(ctx context.Context, account *Account) error
+	GetAccount(ctx context.Context, id int) (*Account, error)
+	GetAccountByCode(ctx context.Context, code string) (*Account, error)
+	// ListAccounts returns the accounts of a type, or of every type if accountType is
+	// empty, in code order.
+	ListAccounts(ctx context.Context, accountType string, activeOnly bool) ([]Account, error)
+	// UpdateAccount changes an account's name and description if it is still at
+	// account.Version, and sets the new version. It returns a conflict if the account was
+	// changed since.
+	UpdateAccount(ctx context.Context, account *Account) error
+	// SetAccountActive activates or deactivates an account. An account with active
+	// sub-accounts cannot be deactivated, nor one under an inactive parent activated.
+	SetAccountActive(ctx context.Context, id int, active bool) (*Account, error)
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Allocation methods: how a rule splits a shared expense across cost centers.
+const (
+	AllocationPercentage = "percentage" // Fixed percentages per cost center
+	AllocationHeadcount  = "headcount"  // In proportion to the active users of each department
+)
+
+// AllocationRule redistributes the monthly balance of a shared expense account, such as
+// rent, to the accounts of the cost centers that use it.
+type AllocationRule struct {
+	ID            int                `json:"id"`
+	Name          string             `json:"name"`
+	SourceAccount string             `json:"source_account"`
+	Method        string             `json:"method"`
+	Targets       []AllocationTarget `json:"targets"`
+	Active        bool               `json:"active"`
+	UpdatedBy     string             `json:"updated_by,omitempty"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// AllocationTarget is one cost center of a rule and the account its share is posted to.
+// For headcount rules the cost center is a department.
+type AllocationTarget struct {
+	CostCenter string  `json:"cost_center"`
+	Account    string  `json:"account"`
+	Percent    float64 `json:"percent,omitempty"` // Share of percentage rules
+}
+
+// AllocationRun is the allocation of one month. Every share posted is traced in Lines.
+// A preview is computed the same way but nothing is posted.
+type AllocationRun struct {
+	ID       int              `json:"id,omitempty"`
+	Period   string           `json:"period"` // YYYY-MM
+	Preview  bool             `json:"preview"`
+	Total    float64          `json:"total"` // Amount redistributed over all rules
+	Lines    []AllocationLine `json:"lines"`
+	Skipped  []string         `json:"skipped,omitempty"` // Why rules were not applied
+	Actor    string           `json:"actor,omitempty"`   // Email of the user, or "scheduler"
+	PostedAt *time.Time       `json:"posted_at,omitempty"`
+}
+
+// AllocationLine traces one share: which rule moved how much of the source account's
+// balance to a cost center, and the driver it was computed from.
+type AllocationLine struct {
+	RuleID        int     `json:"rule_id"`
+	RuleName      string  `json:"rule_name"`
+	SourceAccount string  `json:"source_account"`
+	SourceAmount  float64 `json:"source_amount"` // Balance of the source account for the month
+	CostCenter    string  `json:"cost_center"`
+	Account       string  `json:"account"`
+	Driver        float64 `json:"driver"` // The percentage or the headcount
+	Share         float64 `json:"share"`  // Fraction of the source amount, 0 to 1
+	Amount        float64 `json:"amount"`
+}
+
+// AllocationStore defines an interface for allocation database operations
+type AllocationStore interface {
+	CreateAllocationRule(ctx context.Context, rule *AllocationRule) error
+	GetAllocationRules(ctx context.Context) ([]AllocationRule, error)
+	UpdateAllocationRule(ctx context.Context, rule *AllocationRule) error
+	DeleteAllocationRule(ctx context.Context, id int) error
+	// GetAccountActivity returns the sum of an account's transactions dated in [from, to).
+	GetAccountActivity(ctx context.Context, account string, from, to time.Time) (float64, error)
+	// GetHeadcount returns the number of active users per department.
+	GetHeadcount(ctx context.Context) (map[string]int, error)
+	// SaveAllocationRun posts the run's lines to the ledger and records them, unless the
+	// month has been allocated already.
+	SaveAllocationRun(ctx context.Context, run *AllocationRun, postingDate time.Time) error
+	GetAllocationRuns(ctx context.Context) ([]AllocationRun, error)
+	GetAllocationRun(ctx context.Context, period string) (*AllocationRun, error)
+}
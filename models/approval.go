@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Document types that go through an approval step
+const (
+	DocumentPayment      = "payment"       // Approved with POST /accounts_payable/{id}/approve
+	DocumentJournalEntry = "journal_entry" // Approved by posting it
+)
+
+// Payment statuses. Payments wait for approval while the approval workflows flag is on;
+// otherwise they are approved as soon as they are recorded.
+const (
+	PaymentPending  = "pending"
+	PaymentApproved = "approved"
+)
+
+// ErrSameCreatorApprover is returned when a user tries to approve a document they created
+// while the segregation-of-duties policy for the document type is enabled.
+var ErrSameCreatorApprover = PermissionDenied("segregation of duties: a document cannot be approved by the user who created it")
+
+// SoDPolicy is the segregation-of-duties policy of one document type. While enabled, the
+// user who created a document may not approve it.
+type SoDPolicy struct {
+	DocumentType string     `json:"document_type"`
+	Enabled      bool       `json:"enabled"`
+	UpdatedBy    string     `json:"updated_by,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+// SoDViolation records a user approving, or trying to approve, a document they created.
+// Blocked attempts were refused; the others were allowed because the policy was disabled.
+type SoDViolation struct {
+	ID           int       `json:"id"`
+	DocumentType string    `json:"document_type"`
+	DocumentID   int       `json:"document_id"`
+	User         string    `json:"user"`
+	Blocked      bool      `json:"blocked"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+// SoDViolationReport lists the segregation-of-duties violations over a date range for
+// compliance review.
+type SoDViolationReport struct {
+	From       string          `json:"from"` // YYYY-MM-DD, inclusive
+	To         string          `json:"to"`   // YYYY-MM-DD, inclusive
+	Violations []SoDViolation  `json:"violations"`
+	Blocked    int             `json:"blocked"` // Attempts that were refused
+	Allowed    int             `json:"allowed"` // Approvals made while the policy was disabled
+	Company    *CompanyProfile `json:"company,omitempty"`
+}
+
+// SoDPolicyStore defines an interface for segregation-of-duties policy database operations
+type SoDPolicyStore interface {
+	GetSoDPolicies(ctx context.Context) ([]SoDPolicy, error)
+	// SaveSoDPolicy enables or disables the policy of a known document type and records the
+	// change in the audit log; it returns ErrNotFound for unknown document types.
+	SaveSoDPolicy(ctx context.Context, policy *SoDPolicy) error
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Attendance represents employee attendance
 type Attendance struct {
@@ -13,8 +16,8 @@ type Attendance struct {
 
 // AttendanceStore defines an interface for attendance-related database operations
 type AttendanceStore interface {
-	CreateAttendance(attendance *Attendance) error
-	GetAttendanceByUserID(userID int) ([]*Attendance, error)
-	UpdateAttendance(attendance *Attendance) error
-	DeleteAttendance(id int) error
+	CreateAttendance(ctx context.Context, attendance *Attendance) error
+	GetAttendanceByUserID(ctx context.Context, userID int) ([]*Attendance, error)
+	UpdateAttendance(ctx context.Context, attendance *Attendance) error
+	DeleteAttendance(ctx context.Context, id int) error
 }
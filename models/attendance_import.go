@@ -0,0 +1,41 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// AttendanceImportError is a row of an attendance import that was rejected.
+type AttendanceImportError struct {
+	Row    int      `json:"row"` // Line in the file, counting the header as line 1
+	Reason string   `json:"reason"`
+	Fields []string `json:"-"` // The row as read, repeated in the error report
+}
+
+// AttendanceImport is the outcome of importing attendance history from a CSV file.
+type AttendanceImport struct {
+	ID             int                     `json:"id"`
+	FileName       string                  `json:"file_name,omitempty"`
+	DryRun         bool                    `json:"dry_run"`  // Rows were checked but not saved
+	Imported       int                     `json:"imported"` // Rows saved, or that would be saved in a dry run
+	Rejected       int                     `json:"rejected"`
+	Errors         []AttendanceImportError `json:"errors"`
+	ErrorReportURL string                  `json:"error_report_url,omitempty"` // CSV of the rejected rows
+	ImportedBy     string                  `json:"imported_by"`
+	CreatedAt      time.Time               `json:"created_at"`
+}
+
+// AttendanceImportStore defines the operations used to import attendance history.
+type AttendanceImportStore interface {
+	// GetUserIDsByEmail returns the IDs of the users with an employee profile keyed by
+	// lower-case email.
+	GetUserIDsByEmail(ctx context.Context) (map[string]int, error)
+	// GetAttendanceDays returns the days each user already has attendance on between from
+	// and to (inclusive), as YYYY-MM-DD keyed by user ID.
+	GetAttendanceDays(ctx context.Context, from, to time.Time) (map[int]map[string]bool, error)
+	// SaveAttendanceImport saves the records and the import with its error report in one
+	// transaction, and sets the import's ID and CreatedAt.
+	SaveAttendanceImport(ctx context.Context, imp *AttendanceImport, records []Attendance, errorReport []byte) error
+	// GetAttendanceImportErrors returns the error report of an import.
+	GetAttendanceImportErrors(ctx context.Context, id int) ([]byte, error)
+}
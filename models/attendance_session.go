@@ -0,0 +1,18 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// AttendanceSessionStore opens and closes the attendance records of employees checking in
+// and out. An employee has at most one open record, one without a check-out.
+type AttendanceSessionStore interface {
+	// CheckIn opens a record at a time for the user with an email. It returns a validation
+	// error if they have no employee profile, and a conflict if they have an open record.
+	CheckIn(ctx context.Context, email string, at time.Time) (*Attendance, error)
+	// CheckOut closes the user's open record at a time and sets its total hours. It returns
+	// a conflict if they have no open record, and a validation error if the time is before
+	// the check-in.
+	CheckOut(ctx context.Context, email string, at time.Time) (*Attendance, error)
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records who performed a sensitive action on a document and why.
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	Actor      string          `json:"actor"`       // Email of the user who performed the action
+	Action     string          `json:"action"`      // e.g. "void"
+	EntityType string          `json:"entity_type"` // e.g. "invoice"
+	EntityID   int             `json:"entity_id"`
+	Reason     string          `json:"reason"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// RequestAudit records a change made through the API: who made it, the request and the
+// fields it changed. Changes are recorded for every resource by the audit middleware, unlike
+// AuditEntry, which handlers write for sensitive actions with a reason.
+type RequestAudit struct {
+	ID         int             `json:"id"`
+	Actor      string          `json:"actor"` // Email of the signed-in user; empty for anonymous and API key requests
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	EntityType string          `json:"entity_type"` // The path before the entity ID, e.g. "stock/policies"
+	EntityID   string          `json:"entity_id,omitempty"`
+	Status     int             `json:"status"`            // HTTP status of the response
+	Changes    json.RawMessage `json:"changes,omitempty"` // Changed fields, as {"field": {"from": ..., "to": ...}}
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// RequestAuditFilter selects request audits. Zero values do not filter.
+type RequestAuditFilter struct {
+	Actor      string
+	EntityType string
+	EntityID   string
+	From       time.Time
+	To         time.Time // Exclusive
+	Limit      int
+}
+
+// RequestAuditStore defines an interface for the audit trail of API changes
+type RequestAuditStore interface {
+	// RecordRequestAudit stores a change and populates its ID.
+	RecordRequestAudit(ctx context.Context, audit *RequestAudit) error
+	// ListRequestAudits returns the changes matching the filter, newest first.
+	ListRequestAudits(ctx context.Context, filter RequestAuditFilter) ([]RequestAudit, error)
+	// GetRequestAudit returns a change, or a not found error.
+	GetRequestAudit(ctx context.Context, id int) (*RequestAudit, error)
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// PaymentMethodBankTransfer is the payment method of payments received on the bank statement.
+const PaymentMethodBankTransfer = "bank_transfer"
+
+// Outcomes of importing a bank statement line.
+const (
+	BankLineMatched   = "matched"   // Applied as a payment to the invoice it names
+	BankLineSuspense  = "suspense"  // Posted to the suspense account until it is resolved
+	BankLineDuplicate = "duplicate" // Imported before under the same external ID; not stored again
+)
+
+// Suspense item statuses.
+const (
+	SuspenseOpen     = "open"
+	SuspenseResolved = "resolved"
+)
+
+// BankStatementLine is a movement on the bank statement. Receipts are positive and payments
+// negative.
+type BankStatementLine struct {
+	ID             int       `json:"id"`
+	ExternalID     string    `json:"external_id,omitempty"` // The bank's ID of the movement; lines are imported once per ID
+	Date           time.Time `json:"date"`
+	Amount         float64   `json:"amount"`
+	Description    string    `json:"description"`
+	Reference      string    `json:"reference,omitempty"`
+	Status         string    `json:"status"`
+	InvoiceID      *int      `json:"invoice_id,omitempty"`
+	PaymentID      *int      `json:"payment_id,omitempty"`
+	SuspenseItemID *int      `json:"suspense_item_id,omitempty"`
+	ImportedBy     string    `json:"imported_by"`
+	ImportedAt     time.Time `json:"imported_at"`
+}
+
+// SuspenseItem is a bank statement line held in the suspense account until someone finds
+// where it belongs and reclassifies it to a customer's invoice or another account.
+type SuspenseItem struct {
+	ID              int        `json:"id"`
+	LineID          int        `json:"line_id"`
+	Date            time.Time  `json:"date"` // Date of the bank line
+	Amount          float64    `json:"amount"`
+	Description     string     `json:"description"`
+	Reference       string     `json:"reference,omitempty"`
+	Account         string     `json:"account"` // Suspense account the line was posted to
+	Status          string     `json:"status"`
+	AgeDays         int        `json:"age_days"` // Days since the bank line, up to its resolution
+	ResolvedAccount string     `json:"resolved_account,omitempty"`
+	InvoiceID       *int       `json:"invoice_id,omitempty"`
+	PaymentID       *int       `json:"payment_id,omitempty"`
+	Note            string     `json:"note,omitempty"`
+	ResolvedBy      string     `json:"resolved_by,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// SuspenseResolution says where a suspense item belongs: a customer's invoice it pays, or
+// another account.
+type SuspenseResolution struct {
+	InvoiceID *int   `json:"invoice_id,omitempty"`
+	Account   string `json:"account,omitempty"`
+	Note      string `json:"note"`
+}
+
+// SuspenseAgingBucket is the open suspense items of an age range.
+type SuspenseAgingBucket struct {
+	Bucket  string  `json:"bucket"` // e.g. "31-60"
+	MinDays int     `json:"min_days"`
+	Count   int     `json:"count"`
+	Amount  float64 `json:"amount"`
+}
+
+// SuspenseAging is the open suspense items by age on a day.
+type SuspenseAging struct {
+	AsOf    time.Time             `json:"as_of"`
+	Buckets []SuspenseAgingBucket `json:"buckets"`
+	Count   int                   `json:"count"`
+	Amount  float64               `json:"amount"`
+}
+
+// BankStore defines the operations for imported bank statement lines and the suspense items
+// left by those that could not be matched.
+type BankStore interface {
+	// ImportBankLine records a statement line with its ledger postings. If invoiceID is not
+	// nil and that invoice is posted with at least the line's amount outstanding, the line
+	// is applied to it as a payment; otherwise it is posted to the suspense account and a
+	// suspense item is opened. The line's ID, status and links are set. It returns a
+	// conflict if a line with the same external ID was imported before.
+	ImportBankLine(ctx context.Context, line *BankStatementLine, invoiceID *int) error
+	GetSuspenseItem(ctx context.Context, id int) (*SuspenseItem, error)
+	// ListSuspenseItems returns the suspense items with the status, or all of them if it is
+	// empty, oldest bank line first.
+	ListSuspenseItems(ctx context.Context, status string) ([]SuspenseItem, error)
+	// ResolveSuspenseItem moves an open item out of the suspense account as res says,
+	// recording a payment when it pays an invoice, and records the resolution in the audit
+	// log. It returns a conflict if the item is resolved already, and a validation error if
+	// the invoice is not posted or has less outstanding than the item.
+	ResolveSuspenseItem(ctx context.Context, id int, res SuspenseResolution, actor string, now time.Time) (*SuspenseItem, error)
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BatchItemError explains why one document in a batch operation was rejected.
+type BatchItemError struct {
+	ID    int    `json:"id"`
+	Error string `json:"error"`
+}
+
+// BatchRejectedError is returned when a batch operation is refused because some of its
+// documents are not eligible. Nothing in the batch is changed.
+type BatchRejectedError struct {
+	Items []BatchItemError `json:"rejected"`
+}
+
+func (e *BatchRejectedError) Error() string {
+	reasons := make([]string, len(e.Items))
+	for i, item := range e.Items {
+		reasons[i] = fmt.Sprintf("%d: %s", item.ID, item.Error)
+	}
+	return "batch rejected: " + strings.Join(reasons, "; ")
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Benefit plan kinds
+const (
+	BenefitHealthInsurance = "health_insurance" // Cover in tiers, usually shared by the employee and the employer
+	BenefitAllowance       = "allowance"        // A fixed monthly amount funded by the employer
+)
+
+// StatutoryBenefit is the kind of the payroll lines benefit elections add to statutory
+// records. They are paid to the plan providers, not remitted to the authorities.
+const StatutoryBenefit = "benefit"
+
+// BenefitTier is a level of cover of a benefit plan and what it costs each month.
+type BenefitTier struct {
+	Name         string  `json:"name"`          // e.g. "employee", "employee_spouse", "family"
+	EmployeeCost float64 `json:"employee_cost"` // Withheld from the employee's pay each month
+	EmployerCost float64 `json:"employer_cost"` // Paid by the employer each month
+}
+
+// BenefitPlan is a benefit employees may elect, such as a health insurance plan or an
+// allowance.
+type BenefitPlan struct {
+	ID        int           `json:"id"`
+	Code      string        `json:"code"` // e.g. "HEALTH"; unique
+	Name      string        `json:"name"`
+	Kind      string        `json:"kind"`
+	Tiers     []BenefitTier `json:"tiers"`
+	Active    bool          `json:"active"` // Inactive plans cannot be elected; elections made stay in force
+	UpdatedBy string        `json:"updated_by,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// EnrollmentWindow is a period in which employees elect their benefits. Elections made in
+// a window take effect from its coverage start.
+type EnrollmentWindow struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"` // e.g. "Open enrollment 2025"
+	OpensOn       time.Time `json:"opens_on"`
+	ClosesOn      time.Time `json:"closes_on"`      // Last day elections are accepted
+	CoverageStart time.Time `json:"coverage_start"` // First day of the month the elections take effect
+	CreatedBy     string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BenefitElection is an employee's choice of tier in a plan, made in an enrollment window.
+// An election without a tier waives the plan. An employee's election in a plan stays in
+// force until one made in a later window takes effect.
+type BenefitElection struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	Employee      string    `json:"employee,omitempty"`
+	Email         string    `json:"email,omitempty"`
+	Department    string    `json:"department,omitempty"`
+	WindowID      int       `json:"window_id"`
+	PlanID        int       `json:"plan_id"`
+	PlanCode      string    `json:"plan_code,omitempty"`
+	PlanName      string    `json:"plan_name,omitempty"`
+	Tier          string    `json:"tier,omitempty"` // Empty when the plan is waived
+	EmployeeCost  float64   `json:"employee_cost"`  // Costs of the tier when it was elected
+	EmployerCost  float64   `json:"employer_cost"`
+	EffectiveFrom time.Time `json:"effective_from"` // Coverage start of the window
+	ElectedBy     string    `json:"elected_by,omitempty"`
+	ElectedAt     time.Time `json:"elected_at"`
+}
+
+// BenefitCostLine is the monthly cost of the benefits of one department's employees.
+type BenefitCostLine struct {
+	Department   string  `json:"department"`
+	Employees    int     `json:"employees"` // Employees enrolled in at least one plan
+	Elections    int     `json:"elections"`
+	EmployeeCost float64 `json:"employee_cost"`
+	EmployerCost float64 `json:"employer_cost"`
+	Total        float64 `json:"total"`
+}
+
+// BenefitCostReport totals the benefit elections in force in a month by department.
+type BenefitCostReport struct {
+	Period       string            `json:"period"` // e.g. "2024-05"
+	Lines        []BenefitCostLine `json:"lines"`
+	EmployeeCost float64           `json:"employee_cost"`
+	EmployerCost float64           `json:"employer_cost"`
+	Total        float64           `json:"total"`
+}
+
+// BenefitStore defines an interface for benefit plan, enrollment and election database
+// operations
+type BenefitStore interface {
+	// CreateBenefitPlan returns a conflict if the code is taken.
+	CreateBenefitPlan(ctx context.Context, plan *BenefitPlan) error
+	GetBenefitPlan(ctx context.Context, id int) (*BenefitPlan, error)
+	GetBenefitPlans(ctx context.Context) ([]BenefitPlan, error)
+	UpdateBenefitPlan(ctx context.Context, plan *BenefitPlan) error
+	CreateEnrollmentWindow(ctx context.Context, window *EnrollmentWindow) error
+	GetEnrollmentWindow(ctx context.Context, id int) (*EnrollmentWindow, error)
+	GetEnrollmentWindows(ctx context.Context) ([]EnrollmentWindow, error)
+	// SaveBenefitElection records an election, replacing the employee's election in the
+	// same plan and window. The user is looked up by email when UserID is 0; it returns
+	// models.ErrNotFound if the user does not exist.
+	SaveBenefitElection(ctx context.Context, election *BenefitElection) error
+	// GetBenefitElections lists elections, newest first. A userID of 0 or an empty email
+	// matches every employee.
+	GetBenefitElections(ctx context.Context, userID int, email string) ([]BenefitElection, error)
+	// GetElectionsInForce lists the elections in force on a day, one per employee and
+	// plan, waivers included; userID 0 lists every employee's.
+	GetElectionsInForce(ctx context.Context, userID int, on time.Time) ([]BenefitElection, error)
+}
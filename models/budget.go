@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Budget scenario kinds.
+const (
+	BudgetBase        = "base"
+	BudgetOptimistic  = "optimistic"
+	BudgetPessimistic = "pessimistic"
+)
+
+// BudgetScenario is a version of a fiscal year's budget. A year may have several, e.g. a
+// base plan and what-if variants cloned from it; at most one of them is the active plan
+// that actuals are reported against.
+type BudgetScenario struct {
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	FiscalYear  int          `json:"fiscal_year"`
+	Kind        string       `json:"kind"`                  // BudgetBase, BudgetOptimistic or BudgetPessimistic
+	BasedOnID   *int         `json:"based_on_id,omitempty"` // Scenario it was cloned from
+	Active      bool         `json:"active"`
+	Lines       []BudgetLine `json:"lines,omitempty"`
+	Income      float64      `json:"income"`  // Total of the income lines
+	Expense     float64      `json:"expense"` // Total of the expense lines
+	CreatedBy   string       `json:"created_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+	ActivatedBy string       `json:"activated_by,omitempty"`
+	ActivatedAt *time.Time   `json:"activated_at,omitempty"`
+}
+
+// BudgetLine is the amount budgeted on a ledger account in a month. Income lines are
+// compared with the credits of the account and expense lines with its debits.
+type BudgetLine struct {
+	Account string  `json:"account"`
+	Type    string  `json:"type"`   // AccountIncome or AccountExpense
+	Period  string  `json:"period"` // YYYY-MM
+	Amount  float64 `json:"amount"`
+}
+
+// BudgetRule changes the matching lines of a scenario by a percentage. Empty fields match
+// every account, type or month.
+type BudgetRule struct {
+	Account string  `json:"account,omitempty"`
+	Type    string  `json:"type,omitempty"`
+	From    string  `json:"from,omitempty"` // First month, YYYY-MM
+	To      string  `json:"to,omitempty"`   // Last month, YYYY-MM
+	Percent float64 `json:"percent"`        // e.g. 10 to raise by a tenth, -5 to lower
+}
+
+// BudgetClone describes a scenario to be cloned from another and the rules adjusting it.
+type BudgetClone struct {
+	Name  string       `json:"name"`
+	Kind  string       `json:"kind"` // The source's kind if empty
+	Rules []BudgetRule `json:"rules"`
+}
+
+// AccountMovement is the debits and credits of a ledger account in a month.
+type AccountMovement struct {
+	Account string  `json:"account"`
+	Period  string  `json:"period"` // YYYY-MM
+	Debit   float64 `json:"debit"`
+	Credit  float64 `json:"credit"`
+}
+
+// BudgetComparisonLine is an account's budget for the year in each scenario compared, and
+// its actual movement so far.
+type BudgetComparisonLine struct {
+	Account string    `json:"account"`
+	Type    string    `json:"type"`
+	Budgets []float64 `json:"budgets"` // In the order of the comparison's scenarios
+	Actual  float64   `json:"actual"`
+}
+
+// BudgetComparison sets the scenarios of a fiscal year side by side against the actuals.
+type BudgetComparison struct {
+	FiscalYear int                    `json:"fiscal_year"`
+	Scenarios  []BudgetScenario       `json:"scenarios"` // Without their lines
+	Lines      []BudgetComparisonLine `json:"lines"`
+	Net        []float64              `json:"net"` // Income less expense of each scenario
+	NetActual  float64                `json:"net_actual"`
+}
+
+// BudgetVarianceLine compares an account's actual movement with the active plan, for a
+// month and for the year to date. Variances are actual less budget; they are favorable when
+// income is above or expense below budget.
+type BudgetVarianceLine struct {
+	Account             string  `json:"account"`
+	Type                string  `json:"type"`
+	Budget              float64 `json:"budget"`
+	Actual              float64 `json:"actual"`
+	Variance            float64 `json:"variance"`
+	VariancePercent     float64 `json:"variance_percent"` // Of the budget; 0 when nothing was budgeted
+	Favorable           bool    `json:"favorable"`
+	YearBudget          float64 `json:"ytd_budget"`
+	YearActual          float64 `json:"ytd_actual"`
+	YearVariance        float64 `json:"ytd_variance"`
+	YearVariancePercent float64 `json:"ytd_variance_percent"`
+	YearFavorable       bool    `json:"ytd_favorable"`
+}
+
+// BudgetVariance reports the actuals of a month against the active plan of its year.
+type BudgetVariance struct {
+	Period   string               `json:"period"`
+	Scenario BudgetScenario       `json:"scenario"` // The active plan, without its lines
+	Lines    []BudgetVarianceLine `json:"lines"`
+}
+
+// BudgetStore defines the operations for budget scenarios.
+type BudgetStore interface {
+	// CreateBudgetScenario records a scenario with its lines. It returns a conflict if the
+	// year already has a scenario of the name.
+	CreateBudgetScenario(ctx context.Context, scenario *BudgetScenario) error
+	// GetBudgetScenario returns a scenario with its lines.
+	GetBudgetScenario(ctx context.Context, id int) (*BudgetScenario, error)
+	// ListBudgetScenarios returns the scenarios of a year, or of every year if it is 0,
+	// without their lines.
+	ListBudgetScenarios(ctx context.Context, fiscalYear int) ([]BudgetScenario, error)
+	// ReplaceBudgetLines replaces the lines of a scenario.
+	ReplaceBudgetLines(ctx context.Context, id int, lines []BudgetLine) error
+	// ActivateBudgetScenario makes a scenario the active plan of its year, and the year's
+	// other scenarios inactive.
+	ActivateBudgetScenario(ctx context.Context, id int, actor string, now time.Time) (*BudgetScenario, error)
+	// DeleteBudgetScenario returns a conflict if the scenario is the active plan.
+	DeleteBudgetScenario(ctx context.Context, id int) error
+	// AccountMovements returns the debits and credits of each ledger account by month, for
+	// the months from first to last.
+	AccountMovements(ctx context.Context, first, last string) ([]AccountMovement, error)
+}
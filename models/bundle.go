@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// How a bundle is priced
+const (
+	BundlePriceFixed      = "fixed"      // The bundle product's own price
+	BundlePriceComponents = "components" // The components' prices added up, less the bundle's discount
+)
+
+// Bundle makes a product a kit of other products sold as one line. A bundle holds no stock
+// of its own: selling it reserves and ships its components, and it is available as many
+// times as its scarcest component allows.
+type Bundle struct {
+	ProductID       int               `json:"product_id"`
+	Pricing         string            `json:"pricing"`
+	DiscountPercent float64           `json:"discount_percent"` // Off the components' prices, for BundlePriceComponents
+	Components      []BundleComponent `json:"components"`
+	Price           float64           `json:"price"`               // What one bundle sells for
+	Available       int               `json:"available,omitempty"` // Bundles that can be made from free stock, when read on its own
+	UpdatedBy       string            `json:"updated_by"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// BundleComponent is the quantity of one product in a bundle.
+type BundleComponent struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"` // The component's current price
+}
+
+// BundleStore defines an interface for bundle database operations.
+type BundleStore interface {
+	// SaveBundle makes a product a bundle or replaces its definition. It returns
+	// ErrNotFound if the product does not exist, and a validation error if a component
+	// does not exist or is a bundle itself, or the product is a component of a bundle.
+	SaveBundle(ctx context.Context, bundle *Bundle) error
+	// GetBundle returns a bundle with its price and availability.
+	GetBundle(ctx context.Context, productID int) (*Bundle, error)
+	// DeleteBundle makes a bundle a plain product again.
+	DeleteBundle(ctx context.Context, productID int) error
+	// Bundles returns the bundles among products, keyed by product ID, with their prices;
+	// products that are not bundles are left out.
+	Bundles(ctx context.Context, productIDs []int) (map[int]*Bundle, error)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Capacity statuses of an employee or department in a month.
+const (
+	CapacityOverAllocated  = "over_allocated"  // More hours planned than available
+	CapacityUnderAllocated = "under_allocated" // Less of the available hours planned than the configured share
+	CapacityBalanced       = "balanced"
+)
+
+// ProjectAllocation is the hours an employee is planned to work on a project in a month.
+type ProjectAllocation struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Employee   string    `json:"employee,omitempty"`
+	Department string    `json:"department,omitempty"`
+	Project    string    `json:"project"`
+	Period     string    `json:"period"` // YYYY-MM
+	Hours      float64   `json:"hours"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CapacityEmployee is an active employee whose hours count towards capacity.
+type CapacityEmployee struct {
+	UserID     int    `json:"user_id"`
+	Name       string `json:"name"`
+	Department string `json:"department"`
+}
+
+// CapacityLine compares the hours available with the hours planned, for an employee, a
+// department or the whole organization.
+type CapacityLine struct {
+	UserID         int     `json:"user_id,omitempty"`
+	Name           string  `json:"name,omitempty"`
+	Department     string  `json:"department,omitempty"`
+	Headcount      int     `json:"headcount"`
+	ScheduledHours float64 `json:"scheduled_hours"` // Working days × hours per day
+	LeaveHours     float64 `json:"leave_hours"`     // Approved leave on working days
+	AvailableHours float64 `json:"available_hours"`
+	DemandHours    float64 `json:"demand_hours"`
+	Utilization    float64 `json:"utilization"` // Demand as a percentage of the available hours
+	Status         string  `json:"status"`
+}
+
+// CapacityDepartment is a department's capacity with that of its employees.
+type CapacityDepartment struct {
+	CapacityLine
+	Employees []CapacityLine `json:"employees"`
+}
+
+// CapacityReport is the capacity of the organization in a month.
+type CapacityReport struct {
+	Period      string               `json:"period"`
+	WorkingDays int                  `json:"working_days"`
+	HoursPerDay float64              `json:"hours_per_day"`
+	Departments []CapacityDepartment `json:"departments"`
+	Total       CapacityLine         `json:"total"`
+}
+
+// CapacityStore defines the operations for capacity planning.
+type CapacityStore interface {
+	// CreateProjectAllocation returns a conflict if the employee already has hours on the
+	// project in the month.
+	CreateProjectAllocation(ctx context.Context, allocation *ProjectAllocation) error
+	// ListProjectAllocations returns the allocations of a month, with the employees' names
+	// and departments.
+	ListProjectAllocations(ctx context.Context, period string) ([]ProjectAllocation, error)
+	DeleteProjectAllocation(ctx context.Context, id int) error
+	// CapacityEmployees returns the active employees.
+	CapacityEmployees(ctx context.Context) ([]CapacityEmployee, error)
+	// ApprovedLeave returns the approved leave overlapping the days from first to last.
+	ApprovedLeave(ctx context.Context, first, last time.Time) ([]Leave, error)
+}
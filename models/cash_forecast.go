@@ -0,0 +1,129 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Cash forecast scenarios.
+const (
+	CashScenarioExpected    = "expected"    // Customers pay after their average delay
+	CashScenarioOptimistic  = "optimistic"  // Customers pay a standard deviation sooner than usual
+	CashScenarioPessimistic = "pessimistic" // Customers pay a standard deviation later; disputed and doubtful receivables are left out
+)
+
+// Sources of the payables in a cash forecast.
+const (
+	PayableSupplierBill   = "supplier_bill" // Part of a supplier bill not settled by advances
+	PayablePendingPayment = "payment"       // Payment waiting for approval in accounts payable
+)
+
+// OpenReceivable is a posted invoice with a balance still owed.
+type OpenReceivable struct {
+	InvoiceID  int       `json:"invoice_id"`
+	CustomerID int       `json:"customer_id"`
+	PostedOn   time.Time `json:"posted_on"`
+	Balance    float64   `json:"balance"`
+	Disputed   bool      `json:"disputed"` // The invoice has an open dispute
+}
+
+// PaymentTiming is a customer's payment on an invoice and how many days after the invoice
+// was posted it was made.
+type PaymentTiming struct {
+	CustomerID int     `json:"customer_id"`
+	Days       int     `json:"days"`
+	Amount     float64 `json:"amount"`
+}
+
+// PaymentBehavior is how long a customer takes to pay, learned from their payments weighted
+// by amount. CustomerID 0 is every customer together, used for customers without payments.
+type PaymentBehavior struct {
+	CustomerID  int     `json:"customer_id"`
+	Payments    int     `json:"payments"`
+	AverageDays float64 `json:"average_days"`
+	StdDevDays  float64 `json:"std_dev_days"`
+}
+
+// OpenPayable is an amount the company owes and has not paid yet.
+type OpenPayable struct {
+	Source string    `json:"source"` // PayableSupplierBill or PayablePendingPayment
+	ID     int       `json:"id"`
+	Date   time.Time `json:"date"` // Bill date of a bill; payment date of a payment
+	Amount float64   `json:"amount"`
+}
+
+// PayrollCost is the cost of a payroll period: the gross pay and the employer contributions.
+type PayrollCost struct {
+	Period    string  `json:"period"` // e.g. "2025-05"
+	Employees int     `json:"employees"`
+	Gross     float64 `json:"gross"`
+	Employer  float64 `json:"employer"`
+}
+
+// RecurringInvoice is an amount a customer is invoiced every few months, from StartsOn
+// until EndsOn if it is set.
+type RecurringInvoice struct {
+	ID             int        `json:"id"`
+	CustomerID     int        `json:"customer_id"`
+	CustomerName   string     `json:"customer_name,omitempty"`
+	Description    string     `json:"description"`
+	Amount         float64    `json:"amount"`
+	IntervalMonths int        `json:"interval_months"`
+	StartsOn       time.Time  `json:"starts_on"`
+	EndsOn         *time.Time `json:"ends_on,omitempty"`
+	CreatedBy      string     `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CashForecastWeek is the cash expected in and out in a week, and the balance at its end.
+type CashForecastWeek struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Receivables float64   `json:"receivables"` // Collections on open invoices
+	Recurring   float64   `json:"recurring"`   // Collections on recurring invoices not yet issued
+	Payables    float64   `json:"payables"`    // Supplier bills and pending payments
+	Payroll     float64   `json:"payroll"`
+	Net         float64   `json:"net"`
+	Closing     float64   `json:"closing"`
+}
+
+// CashScenario is a cash forecast by week under one set of assumptions.
+type CashScenario struct {
+	Scenario   string             `json:"scenario"`
+	Weeks      []CashForecastWeek `json:"weeks"`
+	Closing    float64            `json:"closing"`
+	Lowest     float64            `json:"lowest"`      // Lowest closing balance of a week
+	LowestWeek time.Time          `json:"lowest_week"` // Start of the week with the lowest balance
+}
+
+// CashForecast projects the cash balance by week over a horizon, in each scenario.
+type CashForecast struct {
+	AsOf        time.Time         `json:"as_of"`
+	HorizonDays int               `json:"horizon_days"`
+	Account     string            `json:"account"` // Ledger account holding the cash
+	Opening     float64           `json:"opening"`
+	Scenarios   []CashScenario    `json:"scenarios"`
+	Payroll     *PayrollCost      `json:"payroll,omitempty"` // Last payroll, repeated every month
+	Customers   []PaymentBehavior `json:"customers"`         // Payment behavior of the customers forecast, and of all customers
+}
+
+// CashForecastStore defines the operations for forecasting cash.
+type CashForecastStore interface {
+	// CashBalance returns the ledger balance of an account at the end of a day.
+	CashBalance(ctx context.Context, account string, asOf time.Time) (float64, error)
+	// OpenReceivables returns the posted invoices with a balance, net of payments, deposits
+	// and amounts allowed on resolved disputes.
+	OpenReceivables(ctx context.Context) ([]OpenReceivable, error)
+	// PaymentTimings returns the customers' payments on posted invoices made since a day.
+	PaymentTimings(ctx context.Context, since time.Time) ([]PaymentTiming, error)
+	// OpenPayables returns the supplier bills not settled by advances and the payments
+	// waiting for approval, oldest first.
+	OpenPayables(ctx context.Context) ([]OpenPayable, error)
+	// LatestPayroll returns the cost of the last payroll period recorded, or nil if there
+	// is none.
+	LatestPayroll(ctx context.Context) (*PayrollCost, error)
+	// CreateRecurringInvoice returns a validation error if the customer does not exist.
+	CreateRecurringInvoice(ctx context.Context, invoice *RecurringInvoice) error
+	ListRecurringInvoices(ctx context.Context) ([]RecurringInvoice, error)
+	DeleteRecurringInvoice(ctx context.Context, id int) error
+}
@@ -0,0 +1,27 @@
+package models
+
+import "context"
+
+// CatalogProduct is the public view of a product. It only carries fields that are safe to
+// expose to the storefront; internal fields such as costs are never included.
+type CatalogProduct struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Brand  string  `json:"brand"`
+	Season string  `json:"season"`
+	Price  float64 `json:"price"`
+}
+
+// ProductAvailability is the quantity of a product available across all warehouses.
+type ProductAvailability struct {
+	ProductID int  `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+	InStock   bool `json:"in_stock"`
+}
+
+// CatalogStore defines an interface for read-only catalog queries
+type CatalogStore interface {
+	ListCatalogProducts(ctx context.Context, limit, offset int) ([]CatalogProduct, error)
+	GetCatalogProduct(ctx context.Context, id int) (*CatalogProduct, error)
+	GetAvailability(ctx context.Context, productIDs []int) ([]ProductAvailability, error)
+}
@@ -1,8 +1,6 @@
 package models
 
-import "errors"
-
-var ErrNotFound = errors.New("resource not found")
+import "context"
 
 // Customer represents a customer in the system
 type Customer struct {
@@ -10,12 +8,17 @@ type Customer struct {
 	Name         string `json:"name"`
 	Contact      string `json:"contact"`
 	OrderHistory string `json:"order_history"`
+	Version      int    `json:"version"` // Incremented by every update; an update names the version it changes
 }
 
 // CustomerStore defines an interface for customer-related database operations
 type CustomerStore interface {
-	CreateCustomer(customer *Customer) error
-	GetCustomerByID(id int) (*Customer, error)
-	UpdateCustomer(customer *Customer) error
-	DeleteCustomer(id int) error
+	CreateCustomer(ctx context.Context, customer *Customer) error
+	GetCustomerByID(ctx context.Context, id int) (*Customer, error)
+	// UpdateCustomer changes a customer if it is still at customer.Version, and sets the
+	// new version. It returns a conflict if someone else updated it since.
+	UpdateCustomer(ctx context.Context, customer *Customer) error
+	DeleteCustomer(ctx context.Context, id int) error
+	// ListCustomers returns a page of customers and the number matching the filters.
+	ListCustomers(ctx context.Context, query ListQuery) ([]Customer, int, error)
 }
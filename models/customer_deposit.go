@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// CustomerDeposit is money received from a customer against a sales order before it is
+// invoiced. It is held as a liability until the order's invoice is posted, when it is
+// applied to the invoice; what is left of it can be refunded if the order is cancelled.
+type CustomerDeposit struct {
+	ID           int        `json:"id"`
+	SalesOrderID int        `json:"sales_order_id"`
+	CustomerID   int        `json:"customer_id"` // Taken from the sales order
+	Amount       float64    `json:"amount"`
+	Applied      float64    `json:"applied"`  // Amount applied to invoices so far
+	Refunded     float64    `json:"refunded"` // Amount returned to the customer
+	ReceivedOn   time.Time  `json:"received_on"`
+	Method       string     `json:"method,omitempty"`
+	Reference    string     `json:"reference,omitempty"`
+	RefundReason string     `json:"refund_reason,omitempty"`
+	RefundedAt   *time.Time `json:"refunded_at,omitempty"`
+	CreatedBy    string     `json:"created_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Unapplied returns the part of the deposit neither applied to invoices nor refunded.
+func (d CustomerDeposit) Unapplied() float64 {
+	return d.Amount - d.Applied - d.Refunded
+}
+
+// CustomerDepositStore defines an interface for customer deposit database operations
+type CustomerDepositStore interface {
+	// CreateCustomerDeposit records a deposit against a sales order and posts it to the
+	// ledger as a liability. It returns models.ErrNotFound if the sales order does not exist.
+	CreateCustomerDeposit(ctx context.Context, deposit *CustomerDeposit) error
+	GetCustomerDeposit(ctx context.Context, id int) (*CustomerDeposit, error)
+	// GetCustomerDeposits lists deposits, oldest first; salesOrderID 0 lists every order's.
+	GetCustomerDeposits(ctx context.Context, salesOrderID int, unappliedOnly bool) ([]CustomerDeposit, error)
+	// RefundCustomerDeposits refunds the unapplied part of a sales order's deposits, posts
+	// the refunds and records them in the audit log. It returns the deposits refunded.
+	RefundCustomerDeposits(ctx context.Context, salesOrderID int, reason, actor string, at time.Time) ([]CustomerDeposit, error)
+}
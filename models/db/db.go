@@ -1,18 +1,20 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 var DB *sql.DB
 
-func InitDB() (*sql.DB, error) {
+func InitDB(ctx context.Context) (*sql.DB, error) {
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
@@ -30,14 +32,24 @@ func InitDB() (*sql.DB, error) {
 	// Create connection string
 	connStr := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=%s", dbUser, dbPassword, dbName, dbHost, dbPort, sslMode)
 
+	// Bound every statement by DB_QUERY_TIMEOUT, so a slow database cannot hang requests
+	timeout := DefaultQueryTimeout
+	if value := os.Getenv("DB_QUERY_TIMEOUT"); value != "" {
+		timeout, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_QUERY_TIMEOUT %q: %w", value, err)
+		}
+	}
+
 	// Open connection to the database
-	db, err := sql.Open("postgres", connStr)
+	connector, err := pq.NewConnector(connStr)
 	if err != nil {
 		return nil, err
 	}
+	db := sql.OpenDB(&TimeoutConnector{Connector: connector, Timeout: timeout})
 
 	// Ping the database to test the connection
-	err = db.Ping()
+	err = db.PingContext(ctx)
 	if err != nil {
 		return nil, err
 	}
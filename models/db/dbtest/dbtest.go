@@ -0,0 +1,33 @@
+// Package dbtest opens the PostgreSQL database that benchmarks and integration tests run
+// against. Point ERP_BENCH_DATABASE_URL at a disposable database with the application
+// schema; callers are skipped when it is not set.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// Open connects to the database in ERP_BENCH_DATABASE_URL, skipping tb if it is unset.
+// The connection is closed when tb finishes.
+func Open(ctx context.Context, tb testing.TB) *sql.DB {
+	tb.Helper()
+	url := os.Getenv("ERP_BENCH_DATABASE_URL")
+	if url == "" {
+		tb.Skip("ERP_BENCH_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
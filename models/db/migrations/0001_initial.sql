@@ -1,14 +1,3 @@
--- User Table
-CREATE TABLE users (
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(100) NOT NULL,
-    email VARCHAR(100) UNIQUE NOT NULL,
-    password VARCHAR(255),
-    role_id INT REFERENCES roles(id) ON DELETE SET NULL,
-    department VARCHAR(100),
-    needs_new_pass BOOLEAN DEFAULT FALSE
-);
-
 -- Role Table
 CREATE TABLE roles (
     id SERIAL PRIMARY KEY,
@@ -26,6 +15,17 @@ VALUES
     ('Corporate', 'corporate_permissions')
 ON CONFLICT (role_name) DO NOTHING;
 
+-- User Table
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(100) UNIQUE NOT NULL,
+    password VARCHAR(255),
+    role_id INT REFERENCES roles(id) ON DELETE SET NULL,
+    department VARCHAR(100),
+    needs_new_pass BOOLEAN DEFAULT FALSE
+);
+
 -- Attendance Table
 CREATE TABLE attendance (
     id SERIAL PRIMARY KEY,
@@ -54,6 +54,14 @@ CREATE TABLE products (
     price DECIMAL(10, 2) NOT NULL
 );
 
+-- Warehouse Table
+CREATE TABLE warehouses (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    capacity INT NOT NULL,
+    location VARCHAR(100)
+);
+
 -- Stock Table
 CREATE TABLE stock (
     id SERIAL PRIMARY KEY,
@@ -63,14 +71,6 @@ CREATE TABLE stock (
     location VARCHAR(100)
 );
 
--- Warehouse Table
-CREATE TABLE warehouses (
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(100) NOT NULL,
-    capacity INT NOT NULL,
-    location VARCHAR(100)
-);
-
 -- Customer Table
 CREATE TABLE customers (
     id SERIAL PRIMARY KEY,
@@ -97,14 +97,6 @@ CREATE TABLE invoices (
     status VARCHAR(20)
 );
 
--- Financial Transaction Table
-CREATE TABLE financial_transactions (
-    id SERIAL PRIMARY KEY,
-    account_type VARCHAR(50) NOT NULL,
-    amount DECIMAL(10, 2) NOT NULL,
-    transaction_date DATE NOT NULL
-);
-
 -- Payment Table
 CREATE TABLE payments (
     id SERIAL PRIMARY KEY,
@@ -114,7 +106,7 @@ CREATE TABLE payments (
     payment_method VARCHAR(50)
 );
 
--- Financial Transaction Table with Foreign Keys
+-- Financial Transaction Table
 CREATE TABLE financial_transactions (
     id SERIAL PRIMARY KEY,
     account_type VARCHAR(50) NOT NULL,  -- 'accounts_receivable', 'revenue', 'expense', etc.
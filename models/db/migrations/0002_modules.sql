@@ -0,0 +1,1866 @@
+-- Tables added after the initial schema. Databases created with psql from the original
+-- schema file kept only the first of its two financial_transactions definitions, so the
+-- columns of the second are added where they are missing.
+ALTER TABLE financial_transactions ADD COLUMN IF NOT EXISTS transaction_type VARCHAR(50);
+ALTER TABLE financial_transactions ADD COLUMN IF NOT EXISTS invoice_id INT REFERENCES invoices(id) ON DELETE SET NULL;
+ALTER TABLE financial_transactions ADD COLUMN IF NOT EXISTS payment_id INT REFERENCES payments(id) ON DELETE SET NULL;
+ALTER TABLE financial_transactions ADD COLUMN IF NOT EXISTS description TEXT;
+
+-- Webhook Integration Table
+CREATE TABLE webhook_integrations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    module VARCHAR(50) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    signature_scheme VARCHAR(30) NOT NULL,
+    signature_header VARCHAR(100) NOT NULL,
+    event_id_header VARCHAR(100),
+    active BOOLEAN DEFAULT TRUE
+);
+
+-- Webhook Event Table (inbound payloads, unique per integration and provider event ID)
+CREATE TABLE webhook_events (
+    id SERIAL PRIMARY KEY,
+    integration_id INT REFERENCES webhook_integrations(id) ON DELETE CASCADE,
+    external_id VARCHAR(255) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT DEFAULT 0,
+    last_error TEXT,
+    received_at TIMESTAMP NOT NULL,
+    processed_at TIMESTAMP,
+    UNIQUE (integration_id, external_id)
+);
+
+-- Outbox Table (side-effect intents written in the same transaction as the business change)
+CREATE TABLE outbox_messages (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,  -- 'email', 'webhook', 'event'
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL,  -- 'pending', 'delivered', 'dead'
+    attempts INT DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_messages_due ON outbox_messages (next_attempt_at) WHERE status = 'pending';
+
+-- Receivable Table
+CREATE TABLE IF NOT EXISTS receivables (
+    id SERIAL PRIMARY KEY,
+    customer_name VARCHAR(100) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    due_date DATE NOT NULL,
+    invoice_number VARCHAR(50),
+    status VARCHAR(20),  -- 'pending', 'paid', 'overdue'
+    payment_date DATE
+);
+
+-- Account Period Balance Table (trial balance summary, maintained with each ledger change)
+CREATE TABLE account_period_balances (
+    account_type VARCHAR(50) NOT NULL,
+    period DATE NOT NULL,  -- First day of the month
+    debit_total DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    credit_total DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (account_type, period)
+);
+
+-- Customer Outstanding Table (AR aging summary, rebuilt by the scheduler)
+CREATE TABLE customer_outstanding (
+    customer_name VARCHAR(100) PRIMARY KEY,
+    current_amount DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    days_1_30 DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    days_31_60 DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    days_61_90 DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    over_90 DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    total DECIMAL(14, 2) NOT NULL DEFAULT 0
+);
+
+-- Report Refresh Table (when each summary was last updated)
+CREATE TABLE report_refreshes (
+    report_name VARCHAR(50) PRIMARY KEY,
+    refreshed_at TIMESTAMP NOT NULL
+);
+
+-- Audit Log Table
+CREATE TABLE audit_log (
+    id SERIAL PRIMARY KEY,
+    actor VARCHAR(100) NOT NULL,  -- Email of the user who performed the action
+    action VARCHAR(50) NOT NULL,  -- 'void', etc.
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id INT NOT NULL,
+    reason TEXT,
+    details JSONB,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log (entity_type, entity_id);
+
+-- Journal Entry Table (manual entries, created as drafts and posted to the ledger)
+CREATE TABLE journal_entries (
+    id SERIAL PRIMARY KEY,
+    entry_date DATE NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',  -- 'draft', 'posted'
+    posted_at TIMESTAMP
+);
+
+-- Journal Entry Line Table
+CREATE TABLE journal_entry_lines (
+    journal_entry_id INT REFERENCES journal_entries(id) ON DELETE CASCADE,
+    line_no INT NOT NULL,
+    account_type VARCHAR(50) NOT NULL,
+    debit DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    credit DECIMAL(14, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (journal_entry_id, line_no)
+);
+
+-- Ledger lines created by posting a journal entry
+ALTER TABLE financial_transactions ADD COLUMN journal_entry_id INT REFERENCES journal_entries(id) ON DELETE SET NULL;
+
+-- Invoices are created as drafts and only reach the ledger once posted
+ALTER TABLE invoices ALTER COLUMN status SET DEFAULT 'draft';
+
+-- Price List Entry Table (scheduled product prices, applied on their effective date)
+CREATE TABLE price_list_entries (
+    id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES products(id) ON DELETE CASCADE,
+    price DECIMAL(10, 2) NOT NULL,
+    effective_date DATE NOT NULL,
+    reason TEXT,
+    created_by VARCHAR(100),
+    applied_at TIMESTAMP
+);
+
+CREATE INDEX idx_price_list_entries_due ON price_list_entries (effective_date) WHERE applied_at IS NULL;
+
+-- Product Image Table (files are kept in the attachment backend)
+CREATE TABLE product_images (
+    id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES products(id) ON DELETE CASCADE,
+    filename VARCHAR(255),
+    content_type VARCHAR(50) NOT NULL,
+    size_bytes INT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    thumbnails JSONB,  -- Thumbnail size name to storage key
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_product_images_product ON product_images (product_id);
+
+-- API Key Table (credentials for external systems such as the e-commerce site)
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,  -- SHA-256 of the key; the plaintext is never stored
+    scopes TEXT[] NOT NULL,  -- e.g. 'catalog:read'
+    rate_limit INT DEFAULT 0,  -- Requests per minute, 0 uses the default
+    active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP
+);
+
+-- Stock Reservation Table (stock held for open sales orders)
+CREATE TABLE stock_reservations (
+    id SERIAL PRIMARY KEY,
+    product_id INT REFERENCES products(id) ON DELETE CASCADE,
+    sales_order_id INT REFERENCES sales_orders(id) ON DELETE CASCADE,
+    quantity INT NOT NULL,
+    status VARCHAR(20) NOT NULL,  -- 'active', 'released', 'fulfilled'
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_stock_reservations_product ON stock_reservations (product_id) WHERE status = 'active';
+
+-- E-commerce Order Table (maps external orders to ERP records, one row per external order)
+CREATE TABLE ecommerce_orders (
+    id SERIAL PRIMARY KEY,
+    source_id INT REFERENCES api_keys(id) ON DELETE CASCADE,  -- API key the order arrived with
+    external_order_id VARCHAR(100) NOT NULL,
+    customer_id INT REFERENCES customers(id) ON DELETE SET NULL,
+    payment_status VARCHAR(20) NOT NULL,
+    mapping JSONB,  -- Customer, sales order and reservation IDs returned to the caller
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (source_id, external_order_id)
+);
+
+-- Shipment Table
+CREATE TABLE shipments (
+    id SERIAL PRIMARY KEY,
+    sales_order_id INT REFERENCES sales_orders(id) ON DELETE SET NULL,
+    from_address JSONB NOT NULL,
+    to_address JSONB NOT NULL,
+    weight_kg DECIMAL(10, 3) NOT NULL,
+    status VARCHAR(30) NOT NULL,  -- 'pending', 'label_purchased', 'in_transit', 'out_for_delivery', 'delivered', 'exception', 'returned'
+    carrier VARCHAR(50),
+    service VARCHAR(50),
+    tracking_number VARCHAR(100),
+    label_url TEXT,
+    cost DECIMAL(15, 2),
+    currency VARCHAR(3),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (carrier, tracking_number)
+);
+
+-- Shipment Tracking Event Table (status updates reported by carriers)
+CREATE TABLE shipment_tracking_events (
+    id SERIAL PRIMARY KEY,
+    shipment_id INT REFERENCES shipments(id) ON DELETE CASCADE,
+    tracking_number VARCHAR(100) NOT NULL,
+    status VARCHAR(30) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    location VARCHAR(255) NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP NOT NULL,
+    UNIQUE (shipment_id, status, occurred_at)
+);
+
+-- Notification Table (in-app notification center)
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    event_id INT,  -- Outbox message ID of the domain event that generated the notification
+    type VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    link VARCHAR(255) NOT NULL DEFAULT '',
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, event_id)
+);
+
+CREATE INDEX idx_notifications_unread ON notifications (user_id, created_at) WHERE read_at IS NULL;
+
+-- User Preference Table (one validated JSON document per user)
+CREATE TABLE user_preferences (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    preferences JSONB NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Setting Table (organization-wide settings; keys that are not stored use their defaults)
+CREATE TABLE settings (
+    key VARCHAR(100) PRIMARY KEY,
+    value JSONB NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    updated_by VARCHAR(100)
+);
+
+-- Feature Flag Table (known flags that are not stored use their defaults)
+CREATE TABLE feature_flags (
+    key VARCHAR(100) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    roles TEXT[] NOT NULL DEFAULT '{}',   -- Role names the flag is limited to; empty means all
+    users TEXT[] NOT NULL DEFAULT '{}',   -- Emails of users who always get the flag
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- System Mode Table (single row holding the API's operating mode)
+CREATE TABLE system_mode (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    mode VARCHAR(20) NOT NULL,  -- 'normal', 'read_only', 'maintenance'
+    message TEXT NOT NULL DEFAULT '',
+    changed_by VARCHAR(100),
+    changed_at TIMESTAMP NOT NULL
+);
+
+-- Job Table (long-running background tasks such as backups, polled by clients)
+CREATE TABLE jobs (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,     -- e.g. 'backup', 'backup_verify'
+    status VARCHAR(20) NOT NULL,   -- 'queued', 'running', 'succeeded', 'failed'
+    processed BIGINT NOT NULL DEFAULT 0,
+    total BIGINT NOT NULL DEFAULT 0,
+    result JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_jobs_kind_created_at ON jobs (kind, created_at DESC);
+
+-- Loyalty Transaction Table (accrual rows keep their unspent, unexpired points in remaining)
+CREATE TABLE loyalty_transactions (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,             -- 'accrual', 'redemption', 'expiry'
+    points INT NOT NULL,                   -- Negative for redemptions and expiries
+    remaining INT NOT NULL DEFAULT 0,
+    invoice_id INT REFERENCES invoices(id) ON DELETE SET NULL,
+    discount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_loyalty_accrual_invoice ON loyalty_transactions (invoice_id) WHERE kind = 'accrual';
+CREATE INDEX idx_loyalty_customer_created_at ON loyalty_transactions (customer_id, created_at DESC);
+
+-- Gift Card Table (gift cards and store credit; the balance is a liability until spent or expired)
+CREATE TABLE gift_cards (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL UNIQUE,
+    kind VARCHAR(20) NOT NULL,             -- 'gift_card', 'store_credit'
+    customer_id INT REFERENCES customers(id) ON DELETE SET NULL,
+    initial_amount DECIMAL(10, 2) NOT NULL,
+    balance DECIMAL(10, 2) NOT NULL CHECK (balance >= 0),
+    expires_at TIMESTAMP,
+    issued_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_gift_cards_customer ON gift_cards (customer_id);
+CREATE INDEX idx_gift_cards_expires_at ON gift_cards (expires_at) WHERE balance > 0;
+
+-- Gift Card Transaction Table
+CREATE TABLE gift_card_transactions (
+    id SERIAL PRIMARY KEY,
+    gift_card_id INT NOT NULL REFERENCES gift_cards(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,             -- 'issue', 'redeem', 'expiry'
+    amount DECIMAL(10, 2) NOT NULL,        -- Negative for redemptions and expiries
+    invoice_id INT REFERENCES invoices(id) ON DELETE SET NULL,
+    payment_id INT REFERENCES payments(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_gift_card_transactions_card ON gift_card_transactions (gift_card_id, created_at DESC);
+
+-- POS Sale Table (sales rung up at retail counters; paid in full when recorded)
+CREATE TABLE pos_sales (
+    id SERIAL PRIMARY KEY,
+    register VARCHAR(50) NOT NULL,
+    warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    customer_id INT REFERENCES customers(id) ON DELETE SET NULL,
+    total DECIMAL(10, 2) NOT NULL,
+    payment_method VARCHAR(20) NOT NULL,   -- 'cash', 'card'
+    tendered DECIMAL(10, 2) NOT NULL,
+    change_due DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_pos_sales_register_created_at ON pos_sales (register, created_at);
+
+-- POS Sale Line Table
+CREATE TABLE pos_sale_lines (
+    id SERIAL PRIMARY KEY,
+    sale_id INT NOT NULL REFERENCES pos_sales(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10, 2) NOT NULL
+);
+
+CREATE INDEX idx_pos_sale_lines_sale ON pos_sale_lines (sale_id);
+
+-- Register Session Table (one open session per register at a time)
+CREATE TABLE register_sessions (
+    id SERIAL PRIMARY KEY,
+    register VARCHAR(50) NOT NULL,
+    opening_float DECIMAL(10, 2) NOT NULL,
+    opened_by VARCHAR(255) NOT NULL,
+    opened_at TIMESTAMP NOT NULL,
+    expected_cash DECIMAL(10, 2),          -- Set when the session is closed
+    counted_cash DECIMAL(10, 2),
+    variance DECIMAL(10, 2),
+    closed_by VARCHAR(255),
+    closed_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_register_sessions_open ON register_sessions (register) WHERE closed_at IS NULL;
+CREATE INDEX idx_register_sessions_register_opened_at ON register_sessions (register, opened_at);
+
+-- Register Cash Movement Table
+CREATE TABLE register_cash_movements (
+    id SERIAL PRIMARY KEY,
+    session_id INT NOT NULL REFERENCES register_sessions(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,             -- 'pay_in', 'pay_out'
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    reason VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- POS sales are counted against the session open at their register when they are recorded
+ALTER TABLE pos_sales ADD COLUMN session_id INT REFERENCES register_sessions(id) ON DELETE SET NULL;
+CREATE INDEX idx_pos_sales_session ON pos_sales (session_id);
+
+-- Signature Request Table (documents sent to customers for acceptance through a signed public link)
+CREATE TABLE signature_requests (
+    id SERIAL PRIMARY KEY,
+    document_type VARCHAR(50) NOT NULL,    -- 'invoice'
+    document_id INT NOT NULL,
+    owner VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,           -- 'pending', 'accepted'
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    signer_name VARCHAR(255),
+    signer_ip VARCHAR(64),
+    forwarded_for VARCHAR(255),
+    signature_key VARCHAR(255),
+    signed_at TIMESTAMP
+);
+
+CREATE INDEX idx_signature_requests_document ON signature_requests (document_type, document_id);
+
+-- Standard unit cost of each product, used to value stock and the cost of goods sold
+ALTER TABLE products ADD COLUMN unit_cost DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- KPI Rule Table (thresholds on analytics metrics evaluated by the scheduler)
+CREATE TABLE kpi_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    metric VARCHAR(50) NOT NULL,           -- 'receivables', 'stock_turnover', 'monthly_revenue', 'monthly_revenue_drop_pct'
+    operator VARCHAR(2) NOT NULL,          -- '>', '>=', '<', '<='
+    threshold DECIMAL(15, 2) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    breached BOOLEAN NOT NULL DEFAULT FALSE,
+    last_value DECIMAL(15, 2),
+    last_evaluated_at TIMESTAMP,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- KPI Alert Table (history of rules entering breach)
+CREATE TABLE kpi_alerts (
+    id SERIAL PRIMARY KEY,
+    rule_id INT REFERENCES kpi_rules(id) ON DELETE SET NULL,
+    rule_name VARCHAR(100) NOT NULL,
+    metric VARCHAR(50) NOT NULL,
+    operator VARCHAR(2) NOT NULL,
+    threshold DECIMAL(15, 2) NOT NULL,
+    value DECIMAL(15, 2) NOT NULL,
+    message TEXT NOT NULL,
+    triggered_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_kpi_alerts_rule ON kpi_alerts (rule_id, triggered_at);
+
+-- Change tracking for the data warehouse export: updated_at is set on insert and on every
+-- update, so each export picks up the rows changed since the previous one
+CREATE FUNCTION touch_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['customers', 'products', 'warehouses', 'stock', 'sales_orders', 'invoices',
+        'payments', 'financial_transactions', 'pos_sales', 'pos_sale_lines', 'gift_cards',
+        'gift_card_transactions', 'loyalty_transactions']
+    LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT now()', t);
+        EXECUTE format('CREATE INDEX %I ON %I (updated_at, id)', 'idx_' || t || '_updated_at', t);
+        EXECUTE format('CREATE TRIGGER %I BEFORE UPDATE ON %I FOR EACH ROW EXECUTE FUNCTION touch_updated_at()',
+            t || '_touch_updated_at', t);
+    END LOOP;
+END;
+$$;
+
+-- Export Watermark Table (the newest row of each table already exported to the data warehouse)
+CREATE TABLE export_watermarks (
+    table_name VARCHAR(100) PRIMARY KEY,
+    last_updated_at TIMESTAMP NOT NULL,
+    last_id INT NOT NULL,
+    rows_exported BIGINT NOT NULL DEFAULT 0,  -- Total over all runs
+    exported_at TIMESTAMP NOT NULL
+);
+
+-- Users provisioned from the HR system: external_id is their ID there, and deactivated
+-- users are kept (their records reference them) but can no longer sign in
+ALTER TABLE users ADD COLUMN external_id VARCHAR(255) UNIQUE;
+ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Email Template Table (every edit adds a version; the newest version of a key and locale is sent)
+CREATE TABLE email_templates (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(100) NOT NULL,
+    locale VARCHAR(20) NOT NULL,
+    version INT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    html BOOLEAN NOT NULL DEFAULT FALSE,
+    variables TEXT[] NOT NULL DEFAULT '{}',
+    note TEXT,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (key, locale, version)
+);
+
+INSERT INTO email_templates (key, locale, version, subject, body, variables, note, created_by) VALUES
+    ('user_invite', 'en', 1, 'Your {{company}} ERP account',
+     E'Hello {{name}},\n\nAn account has been created for you. Set your password at {{link}} to sign in.\n',
+     '{company,name,link}', 'Initial version', 'system'),
+    ('approval_request', 'en', 1, '{{document}} is waiting for your approval',
+     E'Hello {{name}},\n\n{{requester}} asked you to approve {{document}}: {{link}}\n',
+     '{name,requester,document,link}', 'Initial version', 'system'),
+    ('payment_reminder', 'en', 1, 'Reminder: invoice {{invoice}} is due',
+     E'Dear {{customer}},\n\nInvoice {{invoice}} for {{amount}} was due on {{due_date}}. Please arrange payment at your earliest convenience.\n\n{{company}}\n',
+     '{customer,invoice,amount,due_date,company}', 'Initial version', 'system');
+
+-- Document Series Table (numbering of generated documents; each series counts up without gaps)
+CREATE TABLE document_series (
+    series VARCHAR(50) PRIMARY KEY,
+    prefix VARCHAR(20) NOT NULL,
+    padding INT NOT NULL DEFAULT 5,  -- Digits the number is padded to
+    next_value INT NOT NULL DEFAULT 1
+);
+
+INSERT INTO document_series (series, prefix) VALUES ('delivery_note', 'DN-'), ('purchase_order', 'PO-');
+
+-- Document Number Table (the number given to each record's document, so reprints keep it)
+CREATE TABLE document_numbers (
+    series VARCHAR(50) NOT NULL REFERENCES document_series(series),
+    entity_id INT NOT NULL,
+    number VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (series, entity_id)
+);
+
+-- Manual journal entries need a justification to be posted; the supporting document is optional
+ALTER TABLE journal_entries ADD COLUMN justification TEXT NOT NULL DEFAULT '';
+ALTER TABLE journal_entries ADD COLUMN attachment_url TEXT;
+ALTER TABLE journal_entries ADD COLUMN posted_by VARCHAR(100);
+
+-- Sandbox Environment Table (a row marks the database as a sandbox whose data may be reset;
+-- insert it by hand on demo databases only: INSERT INTO sandbox_environment (name) VALUES ('demo'))
+CREATE TABLE sandbox_environment (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),  -- At most one row
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Sandbox Capture Table (emails and webhooks a sandbox recorded instead of sending)
+CREATE TABLE sandbox_captures (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,  -- Outbox kind: 'email', 'webhook'
+    recipient TEXT NOT NULL,    -- Email addresses or webhook URL
+    subject TEXT NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    captured_at TIMESTAMP NOT NULL
+);
+
+-- Segregation of duties: the user who records a payment or journal entry may not approve it.
+-- Payments wait for approval while the approval workflows flag is on; existing ones are approved
+ALTER TABLE payments ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'approved';  -- 'pending', 'approved'
+ALTER TABLE payments ADD COLUMN created_by VARCHAR(100);
+ALTER TABLE payments ADD COLUMN approved_by VARCHAR(100);
+ALTER TABLE payments ADD COLUMN approved_at TIMESTAMP;
+ALTER TABLE journal_entries ADD COLUMN created_by VARCHAR(100);
+
+-- SoD Policy Table (whether creators are barred from approving each document type)
+CREATE TABLE sod_policies (
+    document_type VARCHAR(50) PRIMARY KEY,  -- 'payment', 'journal_entry'
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by VARCHAR(100),
+    updated_at TIMESTAMP
+);
+
+INSERT INTO sod_policies (document_type) VALUES ('payment'), ('journal_entry');
+
+-- SoD Violation Table (users approving their own documents: refused attempts, and approvals
+-- allowed while the policy was disabled)
+CREATE TABLE sod_violations (
+    id SERIAL PRIMARY KEY,
+    document_type VARCHAR(50) NOT NULL,
+    document_id INT NOT NULL,
+    user_email VARCHAR(100) NOT NULL,
+    blocked BOOLEAN NOT NULL,
+    occurred_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_sod_violations_occurred_at ON sod_violations (occurred_at);
+
+-- Access Log Table (sign-in attempts for security review; network is the client's /24 IPv4
+-- or /48 IPv6 prefix, compared with earlier sign-ins to flag new locations)
+CREATE TABLE access_log (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,  -- As entered, so attempts for unknown users are kept
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(50) NOT NULL DEFAULT '',  -- Why a failed attempt was refused
+    ip VARCHAR(45) NOT NULL,
+    network VARCHAR(50) NOT NULL,
+    forwarded_for TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    new_location BOOLEAN NOT NULL DEFAULT FALSE,
+    new_device BOOLEAN NOT NULL DEFAULT FALSE,
+    occurred_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_access_log_email ON access_log (lower(email), occurred_at);
+CREATE INDEX idx_access_log_occurred_at ON access_log (occurred_at);
+
+-- Retention Policy Table (per data class overrides of the built-in retention periods;
+-- retention_days NULL keeps the records forever)
+CREATE TABLE retention_policies (
+    data_class VARCHAR(50) PRIMARY KEY,
+    retention_days INT CHECK (retention_days > 0),
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Retention Purge Table (what each purge run deleted; never purged itself)
+CREATE TABLE retention_purges (
+    id SERIAL PRIMARY KEY,
+    job_id INT REFERENCES jobs(id) ON DELETE SET NULL,
+    data_class VARCHAR(50) NOT NULL,
+    retention_days INT NOT NULL,
+    cutoff TIMESTAMP NOT NULL,
+    rows_deleted BIGINT NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    purged_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_retention_purges_purged_at ON retention_purges (purged_at);
+
+-- Quarantined File Table (uploads flagged by the virus scanner; the files are kept in the
+-- private quarantine directory under storage_key)
+CREATE TABLE quarantined_files (
+    id SERIAL PRIMARY KEY,
+    source VARCHAR(50) NOT NULL,
+    entity_id INT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes INT NOT NULL,
+    signature VARCHAR(255) NOT NULL,
+    storage_key VARCHAR(500) NOT NULL,
+    uploaded_by VARCHAR(100) NOT NULL,
+    detected_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_quarantined_files_detected_at ON quarantined_files (detected_at);
+
+-- Report Definition Table (custom reports saved from the report builder; query holds the
+-- entity, columns, filters, grouping and aggregates as JSON)
+CREATE TABLE report_definitions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    query JSONB NOT NULL,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Attendance Import Table (CSV imports of attendance history; error_report is the CSV of
+-- the rejected rows, NULL if none were rejected)
+CREATE TABLE attendance_imports (
+    id SERIAL PRIMARY KEY,
+    file_name VARCHAR(255),
+    dry_run BOOLEAN NOT NULL,
+    imported INT NOT NULL,
+    rejected INT NOT NULL,
+    error_report BYTEA,
+    imported_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Leave Policy Table (yearly entitlement per leave type and the most unused days carried
+-- into the next year)
+CREATE TABLE leave_policies (
+    leave_type VARCHAR(50) PRIMARY KEY,
+    annual_days DECIMAL(6, 2) NOT NULL CHECK (annual_days >= 0),
+    max_carry_forward DECIMAL(6, 2) NOT NULL CHECK (max_carry_forward >= 0),
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Leave Year-End Run Table (one row per closed year; lines holds the outcome per employee
+-- and leave type as JSON)
+CREATE TABLE leave_year_end_runs (
+    id SERIAL PRIMARY KEY,
+    year INT UNIQUE NOT NULL,
+    job_id INT REFERENCES jobs(id) ON DELETE SET NULL,
+    employees INT NOT NULL,
+    carried_forward DECIMAL(10, 2) NOT NULL,
+    forfeited DECIMAL(10, 2) NOT NULL,
+    lines JSONB NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMP NOT NULL
+);
+
+-- Leave Adjustment Table (changes to an employee's balance of a year: manual corrections,
+-- and the closing and carry-forward entries of year-end runs)
+CREATE TABLE leave_adjustments (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    leave_type VARCHAR(50) NOT NULL,
+    year INT NOT NULL,
+    days DECIMAL(6, 2) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    run_id INT REFERENCES leave_year_end_runs(id),
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_leave_adjustments_year_user ON leave_adjustments (year, user_id);
+
+-- Stock Transfer Table (moves between warehouses: requested, approved, in_transit once the
+-- source's stock is taken, received once the destination's is added, or cancelled)
+CREATE TABLE stock_transfers (
+    id SERIAL PRIMARY KEY,
+    source_warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    destination_warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    status VARCHAR(20) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    requested_by VARCHAR(100) NOT NULL,
+    requested_at TIMESTAMP NOT NULL,
+    approved_by VARCHAR(100),
+    approved_at TIMESTAMP,
+    dispatched_by VARCHAR(100),
+    dispatched_at TIMESTAMP,
+    received_by VARCHAR(100),
+    received_at TIMESTAMP,
+    cancelled_by VARCHAR(100),
+    cancelled_at TIMESTAMP,
+    discrepancy BOOLEAN NOT NULL DEFAULT FALSE,  -- less was received than dispatched
+    discrepancy_note TEXT,
+    CHECK (source_warehouse_id <> destination_warehouse_id)
+);
+
+CREATE INDEX idx_stock_transfers_status ON stock_transfers (status);
+
+-- Stock Transfer Line Table (received_quantity is NULL until the transfer is received)
+CREATE TABLE stock_transfer_lines (
+    id SERIAL PRIMARY KEY,
+    transfer_id INT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received_quantity INT CHECK (received_quantity >= 0 AND received_quantity <= quantity),
+    UNIQUE (transfer_id, product_id)
+);
+
+-- Allocation Rule Table (moves the monthly balance of a shared expense account to the
+-- accounts of cost centers; targets is a JSON list of cost_center, account and percent)
+CREATE TABLE allocation_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    source_account VARCHAR(100) NOT NULL,
+    method VARCHAR(20) NOT NULL,  -- percentage or headcount
+    targets JSONB NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Allocation Run Table (one per allocated month, so a month is never posted twice)
+CREATE TABLE allocation_runs (
+    id SERIAL PRIMARY KEY,
+    period CHAR(7) NOT NULL UNIQUE,  -- YYYY-MM
+    total NUMERIC(12, 2) NOT NULL,
+    skipped TEXT[] NOT NULL DEFAULT '{}',
+    actor VARCHAR(100) NOT NULL,
+    posted_at TIMESTAMP NOT NULL
+);
+
+-- Allocation Line Table (the trace: every share posted, with the rule's name kept in case
+-- the rule is deleted later)
+CREATE TABLE allocation_lines (
+    id SERIAL PRIMARY KEY,
+    run_id INT NOT NULL REFERENCES allocation_runs(id) ON DELETE CASCADE,
+    rule_id INT NOT NULL,
+    rule_name VARCHAR(100) NOT NULL,
+    source_account VARCHAR(100) NOT NULL,
+    source_amount NUMERIC(12, 2) NOT NULL,
+    cost_center VARCHAR(100) NOT NULL,
+    account VARCHAR(100) NOT NULL,
+    driver NUMERIC(12, 4) NOT NULL,
+    share NUMERIC(9, 8) NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL
+);
+
+CREATE INDEX idx_allocation_lines_run ON allocation_lines (run_id);
+
+-- Expense Policy Table (limits per expense category; 0 disables a check)
+CREATE TABLE expense_policies (
+    category VARCHAR(100) PRIMARY KEY,
+    spending_limit NUMERIC(12, 2) NOT NULL DEFAULT 0,     -- items above are flagged for the approver
+    hard_limit NUMERIC(12, 2) NOT NULL DEFAULT 0,         -- items above block reimbursement
+    receipt_threshold NUMERIC(12, 2) NOT NULL DEFAULT 0,  -- items above need a receipt
+    per_diem_rate NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Expense Claim Table (items and the policy violations found on submission are JSON lists;
+-- status is submitted, approved, rejected or reimbursed)
+CREATE TABLE expense_claims (
+    id SERIAL PRIMARY KEY,
+    employee VARCHAR(100) NOT NULL,
+    purpose TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    items JSONB NOT NULL,
+    total NUMERIC(12, 2) NOT NULL,
+    violations JSONB NOT NULL DEFAULT '[]',
+    submitted_at TIMESTAMP NOT NULL,
+    reviewed_by VARCHAR(100),
+    reviewed_at TIMESTAMP,
+    review_note TEXT NOT NULL DEFAULT '',
+    overridden_by VARCHAR(100),
+    overridden_at TIMESTAMP,
+    override_reason TEXT NOT NULL DEFAULT '',
+    reimbursed_by VARCHAR(100),
+    reimbursed_at TIMESTAMP
+);
+
+CREATE INDEX idx_expense_claims_employee ON expense_claims (employee);
+CREATE INDEX idx_expense_claims_status ON expense_claims (status);
+
+-- Expense Mileage Rate Table (amount per kilometre by vehicle type)
+CREATE TABLE expense_mileage_rates (
+    vehicle_type VARCHAR(50) PRIMARY KEY,
+    rate NUMERIC(10, 4) NOT NULL CHECK (rate > 0),
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Expense Per-Diem Rate Table (daily allowance by destination; "default" covers the rest,
+-- and long trips get long_stay_rate after long_stay_after days)
+CREATE TABLE expense_per_diem_rates (
+    destination VARCHAR(100) PRIMARY KEY,
+    daily_rate NUMERIC(10, 2) NOT NULL CHECK (daily_rate > 0),
+    long_stay_rate NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    long_stay_after INT NOT NULL DEFAULT 0,
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Supplier Table
+CREATE TABLE suppliers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL UNIQUE,
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    phone VARCHAR(50) NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Supplier Advance Table (payments made before billing, held in supplier_advances until
+-- adjusted reaches amount through offsets against bills)
+CREATE TABLE supplier_advances (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    adjusted NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (adjusted >= 0 AND adjusted <= amount),
+    paid_on DATE NOT NULL,
+    method VARCHAR(50) NOT NULL DEFAULT '',
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_supplier_advances_supplier ON supplier_advances (supplier_id, paid_on);
+
+-- Supplier Bill Table (advance_applied is the part settled by advances)
+CREATE TABLE supplier_bills (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    number VARCHAR(100) NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    account VARCHAR(50) NOT NULL,
+    bill_date DATE NOT NULL,
+    advance_applied NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (supplier_id, number)
+);
+
+-- Supplier Advance Application Table (which advances settled which bills)
+CREATE TABLE supplier_advance_applications (
+    id SERIAL PRIMARY KEY,
+    advance_id INT NOT NULL REFERENCES supplier_advances(id),
+    bill_id INT NOT NULL REFERENCES supplier_bills(id),
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0)
+);
+
+-- Customer Deposit Table (money received on a sales order before it is invoiced, held in
+-- customer_deposits until applied to the order's invoices or refunded)
+CREATE TABLE customer_deposits (
+    id SERIAL PRIMARY KEY,
+    sales_order_id INT NOT NULL REFERENCES sales_orders(id),
+    customer_id INT REFERENCES customers(id) ON DELETE SET NULL,
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    applied NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    refunded NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    received_on DATE NOT NULL,
+    method VARCHAR(50) NOT NULL DEFAULT '',
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    refund_reason TEXT NOT NULL DEFAULT '',
+    refunded_at TIMESTAMP,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CHECK (applied >= 0 AND refunded >= 0 AND applied + refunded <= amount)
+);
+
+CREATE INDEX idx_customer_deposits_sales_order ON customer_deposits (sales_order_id, received_on);
+
+-- Customer Deposit Application Table (which deposits were applied to which posted invoices)
+CREATE TABLE customer_deposit_applications (
+    id SERIAL PRIMARY KEY,
+    deposit_id INT NOT NULL REFERENCES customer_deposits(id),
+    invoice_id INT NOT NULL REFERENCES invoices(id),
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    applied_at TIMESTAMP NOT NULL
+);
+
+-- Installment Plan Table (what was owed on a posted invoice when it was split into
+-- installments; payments and deposits after settled_before settle the installments in order)
+CREATE TABLE installment_plans (
+    id SERIAL PRIMARY KEY,
+    invoice_id INT NOT NULL UNIQUE REFERENCES invoices(id) ON DELETE CASCADE,
+    customer_id INT REFERENCES customers(id) ON DELETE SET NULL,
+    total NUMERIC(12, 2) NOT NULL CHECK (total > 0),
+    settled_before NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Installment Table (reminder_stage is the day relative to the due date of the last reminder)
+CREATE TABLE installments (
+    id SERIAL PRIMARY KEY,
+    plan_id INT NOT NULL REFERENCES installment_plans(id) ON DELETE CASCADE,
+    number INT NOT NULL,
+    due_date DATE NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    reminder_stage INT,
+    reminded_at TIMESTAMP,
+    UNIQUE (plan_id, number)
+);
+
+INSERT INTO email_templates (key, locale, version, subject, body, variables, note, created_by) VALUES
+    ('installment_reminder', 'en', 1, 'Installment {{installment}} of invoice {{invoice}} is due on {{due_date}}',
+     E'Dear {{customer}},\n\nInstallment {{installment}} of invoice {{invoice}}, for {{amount}}, is due on {{due_date}}. If you have already paid it, please disregard this reminder.\n',
+     '{customer,invoice,installment,amount,due_date}', 'Initial version', 'system');
+
+-- Late Fee Rule Table (at most one rule per customer, and one without a customer that
+-- applies to all others; invoices are due payment_terms_days after posting)
+CREATE TABLE late_fee_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    customer_id INT REFERENCES customers(id) ON DELETE CASCADE,
+    method VARCHAR(20) NOT NULL CHECK (method IN ('interest', 'flat')),
+    payment_terms_days INT NOT NULL DEFAULT 0 CHECK (payment_terms_days >= 0),
+    grace_days INT NOT NULL DEFAULT 0 CHECK (grace_days >= 0),
+    annual_rate NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    flat_fee NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    minimum_balance NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_late_fee_rules_customer ON late_fee_rules ((COALESCE(customer_id, 0)));
+
+-- Late Fee Charge Table (each fee is billed on a posted penalty invoice; a flat fee is charged
+-- once per invoice and interest once per period)
+CREATE TABLE late_fee_charges (
+    id SERIAL PRIMARY KEY,
+    invoice_id INT NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    customer_id INT REFERENCES customers(id) ON DELETE SET NULL,
+    penalty_invoice_id INT NOT NULL UNIQUE REFERENCES invoices(id) ON DELETE CASCADE,
+    rule_id INT NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    balance NUMERIC(12, 2) NOT NULL,
+    period_from DATE,
+    period_to DATE,
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    charged_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_late_fee_charges_flat ON late_fee_charges (invoice_id) WHERE method = 'flat';
+CREATE UNIQUE INDEX idx_late_fee_charges_interest ON late_fee_charges (invoice_id, period_to) WHERE method = 'interest';
+
+-- Invoice Dispute Table (an invoice has at most one open dispute; resolved_amount is what
+-- was credited or written down, and reduces what is owed on the invoice)
+CREATE TABLE invoice_disputes (
+    id SERIAL PRIMARY KEY,
+    invoice_id INT NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    customer_id INT REFERENCES customers(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('open', 'resolved')),
+    resolution VARCHAR(20) CHECK (resolution IN ('credit_note', 'adjustment', 'uphold')),
+    resolved_amount NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (resolved_amount >= 0 AND resolved_amount <= amount),
+    resolution_note TEXT,
+    credit_note_id INT,
+    raised_by VARCHAR(100) NOT NULL,
+    raised_at TIMESTAMP NOT NULL,
+    resolved_by VARCHAR(100),
+    resolved_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_invoice_disputes_open ON invoice_disputes (invoice_id) WHERE status = 'open';
+CREATE INDEX idx_invoice_disputes_status ON invoice_disputes (status, raised_at);
+
+-- Credit Note Table (credit notes issued to resolve disputes)
+CREATE TABLE credit_notes (
+    id SERIAL PRIMARY KEY,
+    invoice_id INT NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    customer_id INT REFERENCES customers(id) ON DELETE SET NULL,
+    dispute_id INT NOT NULL UNIQUE REFERENCES invoice_disputes(id) ON DELETE CASCADE,
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL,
+    issued_by VARCHAR(100) NOT NULL,
+    issued_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE invoice_disputes ADD FOREIGN KEY (credit_note_id) REFERENCES credit_notes(id);
+
+-- Revenue Schedule Table (revenue of a service invoice recognized straight-line over months;
+-- set on the draft, amount and posted_on are filled in when the invoice is posted)
+CREATE TABLE revenue_schedules (
+    invoice_id INT PRIMARY KEY REFERENCES invoices(id) ON DELETE CASCADE,
+    months INT NOT NULL CHECK (months BETWEEN 1 AND 120),
+    start_month DATE,
+    amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    posted_on DATE,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Revenue Schedule Line Table (recognized_on is the ledger date of the month's recognition)
+CREATE TABLE revenue_schedule_lines (
+    id SERIAL PRIMARY KEY,
+    invoice_id INT NOT NULL REFERENCES revenue_schedules(invoice_id) ON DELETE CASCADE,
+    month DATE NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL,
+    recognized_on DATE,
+    UNIQUE (invoice_id, month)
+);
+
+CREATE INDEX idx_revenue_schedule_lines_pending ON revenue_schedule_lines (month) WHERE recognized_on IS NULL;
+
+-- Statutory Deduction Table (income tax by slabs, or provident fund and insurance as
+-- percentages of wages from the employee and the employer, up to the ceiling if set)
+CREATE TABLE statutory_deductions (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(30) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('income_tax', 'provident_fund', 'insurance')),
+    slabs JSONB,  -- Income tax bands of annual income: [{"up_to": 300000, "rate": 0}, ...]
+    employee_rate NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    employer_rate NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    ceiling NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Statutory Record Table (an employee's statutory deductions for a pay period)
+CREATE TABLE statutory_records (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period CHAR(7) NOT NULL,  -- YYYY-MM
+    gross NUMERIC(12, 2) NOT NULL,
+    total_employee NUMERIC(12, 2) NOT NULL,
+    total_employer NUMERIC(12, 2) NOT NULL,
+    net NUMERIC(12, 2) NOT NULL,
+    recorded_by VARCHAR(100) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, period)
+);
+
+CREATE INDEX idx_statutory_records_period ON statutory_records (period);
+
+-- Statutory Record Line Table (what each deduction took; kept when the deduction changes)
+CREATE TABLE statutory_record_lines (
+    id SERIAL PRIMARY KEY,
+    record_id INT NOT NULL REFERENCES statutory_records(id) ON DELETE CASCADE,
+    code VARCHAR(30) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    base NUMERIC(12, 2) NOT NULL,
+    employee NUMERIC(12, 2) NOT NULL,
+    employer NUMERIC(12, 2) NOT NULL
+);
+
+-- HR Role (approves leave requests and keeps leave policies). A role's permissions are
+-- comma-separated, e.g. 'finance_permissions,hr_permissions'
+INSERT INTO roles (role_name, permissions)
+VALUES ('HR', 'hr_permissions')
+ON CONFLICT (role_name) DO NOTHING;
+
+-- Payslip notice, emailed to each employee after a payroll run with a link to the payslip
+INSERT INTO email_templates (key, locale, version, subject, body, variables, note, created_by) VALUES
+    ('payslip', 'en', 1, 'Your payslip for {{period}}',
+     E'Dear {{employee}},\n\nYour payslip for {{period}} is ready. Gross pay: {{gross}}, deductions: {{deductions}}, net pay: {{net}}.\n\nDownload it at {{link}} (sign in required).\n',
+     '{employee,period,gross,deductions,net,link}', 'Initial version', 'system');
+
+-- Benefit Plan Table (health insurance tiers and allowances employees may elect)
+CREATE TABLE benefit_plans (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(30) NOT NULL UNIQUE,
+    name VARCHAR(60) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('health_insurance', 'allowance')),
+    tiers JSONB NOT NULL,  -- [{"name": "family", "employee_cost": 150, "employer_cost": 300}, ...]; names up to 30 characters
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Enrollment Window Table (when employees elect benefits and when the elections take effect)
+CREATE TABLE enrollment_windows (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    opens_on DATE NOT NULL,
+    closes_on DATE NOT NULL,
+    coverage_start DATE NOT NULL,  -- First day of a month
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CHECK (closes_on >= opens_on)
+);
+
+-- Benefit Election Table (an employee's tier in a plan, chosen in a window; no tier waives the plan)
+CREATE TABLE benefit_elections (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    window_id INT NOT NULL REFERENCES enrollment_windows(id),
+    plan_id INT NOT NULL REFERENCES benefit_plans(id),
+    tier VARCHAR(30),
+    employee_cost NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    employer_cost NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    effective_from DATE NOT NULL,
+    elected_by VARCHAR(100) NOT NULL,
+    elected_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, plan_id, window_id)
+);
+
+CREATE INDEX idx_benefit_elections_in_force ON benefit_elections (user_id, plan_id, effective_from DESC);
+
+-- Sales orders move from draft to confirmed (stock reserved) to fulfilled (stock taken), or
+-- are cancelled. Orders taken before they had lines keep their one product in product_id
+-- and quantity and count as confirmed, as their stock was reserved when they were taken.
+ALTER TABLE sales_orders ALTER COLUMN quantity DROP NOT NULL;
+ALTER TABLE sales_orders ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'confirmed';  -- 'draft', 'confirmed', 'fulfilled', 'cancelled'
+ALTER TABLE sales_orders ADD COLUMN note TEXT NOT NULL DEFAULT '';
+ALTER TABLE sales_orders ADD COLUMN created_by VARCHAR(100);
+ALTER TABLE sales_orders ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE sales_orders ADD COLUMN confirmed_by VARCHAR(100);
+ALTER TABLE sales_orders ADD COLUMN confirmed_at TIMESTAMP;
+ALTER TABLE sales_orders ADD COLUMN fulfilled_by VARCHAR(100);
+ALTER TABLE sales_orders ADD COLUMN fulfilled_at TIMESTAMP;
+ALTER TABLE sales_orders ADD COLUMN cancelled_by VARCHAR(100);
+ALTER TABLE sales_orders ADD COLUMN cancelled_at TIMESTAMP;
+
+CREATE INDEX idx_sales_orders_status ON sales_orders (status);
+
+-- Sales Order Line Table
+CREATE TABLE sales_order_lines (
+    id SERIAL PRIMARY KEY,
+    sales_order_id INT NOT NULL REFERENCES sales_orders(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10, 2) NOT NULL,
+    UNIQUE (sales_order_id, product_id)
+);
+
+-- Purchase Order Table (goods ordered from a supplier for one warehouse)
+CREATE TABLE purchase_orders (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    status VARCHAR(20) NOT NULL,  -- 'draft', 'approved', 'partially_received', 'received', 'closed'
+    expected_on DATE,
+    note TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    approved_by VARCHAR(100),
+    approved_at TIMESTAMP,
+    closed_by VARCHAR(100),
+    closed_at TIMESTAMP
+);
+
+CREATE INDEX idx_purchase_orders_status ON purchase_orders (status);
+
+-- Purchase Order Line Table (received counts the quantity received so far)
+CREATE TABLE purchase_order_lines (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10, 2) NOT NULL,
+    received INT NOT NULL DEFAULT 0 CHECK (received >= 0 AND received <= quantity),
+    UNIQUE (purchase_order_id, product_id)
+);
+
+-- Payments drafted in accounts payable when a purchase order is received have no invoice
+ALTER TABLE payments ADD COLUMN purchase_order_id INT REFERENCES purchase_orders(id) ON DELETE SET NULL;
+
+-- Purchase Order Receipt Table (one row per delivery; lines is a JSON list of product_id and quantity)
+CREATE TABLE purchase_order_receipts (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    lines JSONB NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL,
+    payment_id INT REFERENCES payments(id) ON DELETE SET NULL,
+    received_by VARCHAR(100) NOT NULL,
+    received_at TIMESTAMP NOT NULL
+);
+
+-- HR Case Table (confidential disciplinary and grievance cases; only roles holding hr_permissions see them)
+CREATE TABLE hr_cases (
+    id SERIAL PRIMARY KEY,
+    type VARCHAR(20) NOT NULL,    -- 'disciplinary' or 'grievance'
+    summary TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,  -- 'open', 'investigating', 'resolved', 'closed'
+    outcome TEXT NOT NULL DEFAULT '',
+    opened_by VARCHAR(100) NOT NULL,
+    opened_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+    closed_at TIMESTAMP
+);
+
+CREATE INDEX idx_hr_cases_opened_at ON hr_cases (opened_at);
+
+-- HR Case Party Table (role is 'subject', 'complainant', 'witness' or 'representative')
+CREATE TABLE hr_case_parties (
+    id SERIAL PRIMARY KEY,
+    case_id INT NOT NULL REFERENCES hr_cases(id),
+    user_id INT NOT NULL REFERENCES users(id),
+    role VARCHAR(20) NOT NULL,
+    added_by VARCHAR(100) NOT NULL,
+    added_at TIMESTAMP NOT NULL,
+    UNIQUE (case_id, user_id)
+);
+
+-- HR Case Note Table
+CREATE TABLE hr_case_notes (
+    id SERIAL PRIMARY KEY,
+    case_id INT NOT NULL REFERENCES hr_cases(id),
+    body TEXT NOT NULL,
+    author VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- HR Case Attachment Table (files are kept in the private HR_CASE_DIR, never publicly served)
+CREATE TABLE hr_case_attachments (
+    id SERIAL PRIMARY KEY,
+    case_id INT NOT NULL REFERENCES hr_cases(id),
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes INT NOT NULL,
+    storage_key VARCHAR(500) NOT NULL,
+    uploaded_by VARCHAR(100) NOT NULL,
+    uploaded_at TIMESTAMP NOT NULL
+);
+
+-- HR Case Event Table (the history of each case: who did what when)
+CREATE TABLE hr_case_events (
+    id SERIAL PRIMARY KEY,
+    case_id INT NOT NULL REFERENCES hr_cases(id),
+    action VARCHAR(30) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    actor VARCHAR(100) NOT NULL,
+    at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_hr_case_events_case ON hr_case_events (case_id, id);
+
+-- The parties, notes, attachments and history of HR cases are append-only: the database
+-- refuses to change or delete them, whoever asks, so the record of a case cannot be rewritten
+CREATE FUNCTION forbid_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['hr_case_parties', 'hr_case_notes', 'hr_case_attachments', 'hr_case_events']
+    LOOP
+        EXECUTE format('CREATE TRIGGER %I BEFORE UPDATE OR DELETE ON %I FOR EACH ROW EXECUTE FUNCTION forbid_change()',
+            t || '_append_only', t);
+    END LOOP;
+END;
+$$;
+
+-- Account Table (the chart of accounts; codes are dot-separated and an account's parent has
+-- its code without the last part, e.g. 1.1 for 1.1.20)
+CREATE TABLE accounts (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL CHECK (code ~ '^[0-9]+(\.[0-9]+)*$'),
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('asset', 'liability', 'equity', 'income', 'expense')),
+    parent_id INT REFERENCES accounts(id),
+    description TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_accounts_parent ON accounts (parent_id);
+
+-- Financial Record Table (each record is posted to an account of the chart of accounts)
+CREATE TABLE IF NOT EXISTS financial_records (
+    id SERIAL PRIMARY KEY,
+    transaction_id INT NOT NULL,
+    account_id INT NOT NULL REFERENCES accounts(id),
+    amount DECIMAL(12, 2) NOT NULL,
+    transaction_date TIMESTAMP NOT NULL,
+    transaction_type VARCHAR(50) NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+-- Final Settlement Table (what is owed to or by an employee who leaves; leave, recoveries and
+-- assets are JSON lists, exit_interview a JSON object)
+CREATE TABLE final_settlements (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    last_working_day DATE NOT NULL,
+    paid_through DATE NOT NULL,
+    monthly_salary DECIMAL(12, 2) NOT NULL,
+    daily_rate DECIMAL(12, 2) NOT NULL,
+    unpaid_days INT NOT NULL,
+    salary_due DECIMAL(12, 2) NOT NULL,
+    leave JSONB NOT NULL,
+    leave_encashment DECIMAL(12, 2) NOT NULL,
+    recoveries JSONB NOT NULL,
+    assets JSONB NOT NULL,
+    asset_recovery DECIMAL(12, 2) NOT NULL,
+    gross DECIMAL(12, 2) NOT NULL,
+    deductions DECIMAL(12, 2) NOT NULL,
+    net DECIMAL(12, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL,  -- 'draft', 'approved', 'cancelled'
+    exit_interview JSONB,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    approved_by VARCHAR(100),
+    approved_at TIMESTAMP,
+    cancelled_by VARCHAR(100),
+    cancelled_at TIMESTAMP
+);
+
+-- An employee has at most one settlement that is not cancelled
+CREATE UNIQUE INDEX idx_final_settlements_user ON final_settlements (user_id) WHERE status <> 'cancelled';
+
+-- Reporting lines for the org chart; an employee never reports to themselves
+ALTER TABLE users ADD COLUMN manager_id INT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE users ADD CONSTRAINT users_manager_not_self CHECK (manager_id <> id);
+CREATE INDEX idx_users_manager ON users (manager_id);
+
+-- Project Allocation Table (hours an employee is planned to work on a project in a month;
+-- period is the first day of the month)
+CREATE TABLE project_allocations (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    project VARCHAR(100) NOT NULL,
+    period DATE NOT NULL,
+    hours DECIMAL(8, 2) NOT NULL CHECK (hours > 0),
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, project, period)
+);
+
+-- Reconciliation Table (month-end worksheet of a control account; the balances are stored
+-- when it is signed off, and worked out live while it is open)
+CREATE TABLE reconciliations (
+    id SERIAL PRIMARY KEY,
+    account VARCHAR(50) NOT NULL,
+    period DATE NOT NULL,  -- First day of the month
+    statement_balance DECIMAL(14, 2),  -- Bank statement closing balance
+    gl_balance DECIMAL(14, 2),
+    subledger_balance DECIMAL(14, 2),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',  -- 'open', 'signed_off'
+    prepared_by VARCHAR(100) NOT NULL,
+    prepared_at TIMESTAMP NOT NULL,
+    signed_off_by VARCHAR(100),
+    signed_off_at TIMESTAMP,
+    sign_off_note TEXT,
+    UNIQUE (account, period)
+);
+
+-- Reconciling Item Table (explanations of a worksheet's difference)
+CREATE TABLE reconciling_items (
+    id SERIAL PRIMARY KEY,
+    reconciliation_id INT NOT NULL REFERENCES reconciliations(id) ON DELETE CASCADE,
+    description TEXT NOT NULL,
+    amount DECIMAL(14, 2) NOT NULL,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Refresh Token Table (only the hash of a token is kept; each use spends the token and
+-- issues the next of its family, and a spent token used again revokes the family)
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family VARCHAR(64) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family);
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens (user_id);
+
+-- Revoked Token Table (access tokens revoked at logout, kept until they would have expired)
+CREATE TABLE revoked_tokens (
+    token_id VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Password Reset Table (one-time tokens emailed to users who forgot their password; only the
+-- hash of a token is kept)
+CREATE TABLE password_resets (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX idx_password_resets_user ON password_resets (user_id);
+
+INSERT INTO email_templates (key, locale, version, subject, body, variables, note, created_by) VALUES
+    ('password_reset', 'en', 1, 'Reset your ERP password',
+     E'Hello {{name}},\n\nSomeone asked to reset the password of your account. Choose a new password at {{link}}. The link works once and expires in {{expires}}.\n\nIf you did not ask for this, you can ignore this email; your password is unchanged.\n',
+     '{name,link,expires}', 'Initial version', 'system');
+
+-- Bank Statement Line Table (movements imported from the bank statement; receipts are
+-- positive and payments negative)
+CREATE TABLE bank_statement_lines (
+    id SERIAL PRIMARY KEY,
+    external_id VARCHAR(100) UNIQUE,  -- The bank's ID of the movement, so it is imported once
+    line_date DATE NOT NULL,
+    amount DECIMAL(14, 2) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,  -- 'matched', 'suspense'
+    invoice_id INT REFERENCES invoices(id) ON DELETE SET NULL,
+    payment_id INT REFERENCES payments(id) ON DELETE SET NULL,
+    imported_by VARCHAR(100) NOT NULL,
+    imported_at TIMESTAMP NOT NULL
+);
+
+-- Suspense Item Table (bank lines posted to the suspense account until they are
+-- reclassified to an invoice or another account)
+CREATE TABLE suspense_items (
+    id SERIAL PRIMARY KEY,
+    line_id INT NOT NULL UNIQUE REFERENCES bank_statement_lines(id) ON DELETE CASCADE,
+    amount DECIMAL(14, 2) NOT NULL,
+    account VARCHAR(50) NOT NULL,  -- Suspense account the line was posted to
+    status VARCHAR(20) NOT NULL DEFAULT 'open',  -- 'open', 'resolved'
+    resolved_account VARCHAR(50),
+    invoice_id INT REFERENCES invoices(id) ON DELETE SET NULL,
+    payment_id INT REFERENCES payments(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    resolved_by VARCHAR(100),
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_suspense_items_status ON suspense_items (status);
+
+-- Recurring Invoice Table (amounts customers are invoiced every interval_months from
+-- starts_on, collected in the cash forecast)
+CREATE TABLE recurring_invoices (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    description TEXT NOT NULL DEFAULT '',
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    interval_months INT NOT NULL CHECK (interval_months BETWEEN 1 AND 12),
+    starts_on DATE NOT NULL,
+    ends_on DATE,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Stock Movement Table (history of stock changes by warehouse; quantity is negative when
+-- stock leaves the warehouse. A direct transfer records a transfer_out and a transfer_in leg)
+CREATE TABLE stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    warehouse_id INT NOT NULL REFERENCES warehouses(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity <> 0),
+    kind VARCHAR(20) NOT NULL,  -- 'transfer_out', 'transfer_in'
+    counterpart_warehouse_id INT REFERENCES warehouses(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    moved_by VARCHAR(100) NOT NULL,
+    moved_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_stock_movements_product ON stock_movements (product_id, warehouse_id, moved_at);
+
+-- Budget Scenario Table (versions of a fiscal year's budget; at most one per year is the
+-- active plan that variances are reported against)
+CREATE TABLE budget_scenarios (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    fiscal_year INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,  -- 'base', 'optimistic', 'pessimistic'
+    based_on_id INT REFERENCES budget_scenarios(id) ON DELETE SET NULL,
+    active BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    activated_by VARCHAR(100),
+    activated_at TIMESTAMP,
+    UNIQUE (fiscal_year, name)
+);
+
+CREATE UNIQUE INDEX idx_budget_scenarios_active ON budget_scenarios (fiscal_year) WHERE active;
+
+-- Budget Line Table (amount budgeted on a ledger account in a month of the scenario's year)
+CREATE TABLE budget_lines (
+    scenario_id INT NOT NULL REFERENCES budget_scenarios(id) ON DELETE CASCADE,
+    account VARCHAR(50) NOT NULL,  -- financial_transactions.account_type
+    type VARCHAR(10) NOT NULL,  -- 'income', 'expense'
+    period CHAR(7) NOT NULL,  -- YYYY-MM
+    amount NUMERIC(14, 2) NOT NULL CHECK (amount >= 0),
+    PRIMARY KEY (scenario_id, account, period)
+);
+
+-- Reorder levels: stock below its reorder level is reported low, and below_reorder records
+-- the state notified by the last low-stock check
+ALTER TABLE stock ADD COLUMN reorder_level INT NOT NULL DEFAULT 0 CHECK (reorder_level >= 0);
+ALTER TABLE stock ADD COLUMN below_reorder BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Warehouse Bin Table (storage locations; stock is in a bin when its location is the code)
+CREATE TABLE warehouse_bins (
+    id SERIAL PRIMARY KEY,
+    warehouse_id INT NOT NULL REFERENCES warehouses(id) ON DELETE CASCADE,
+    code VARCHAR(100) NOT NULL,
+    capacity INT NOT NULL CHECK (capacity > 0),  -- units
+    distance INT NOT NULL DEFAULT 0 CHECK (distance >= 0),  -- walking distance from dispatch
+    UNIQUE (warehouse_id, code)
+);
+
+CREATE INDEX idx_sales_orders_fulfilled_at ON sales_orders (fulfilled_at);
+
+-- Employee Table (HR profiles, kept apart from the user accounts employees sign in with;
+-- attendance and leave are only accepted for users that have one)
+CREATE TABLE employees (
+    id SERIAL PRIMARY KEY,
+    user_id INT UNIQUE REFERENCES users(id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    designation VARCHAR(100) NOT NULL,
+    salary_grade VARCHAR(20),
+    department VARCHAR(100),
+    manager_id INT REFERENCES employees(id) ON DELETE SET NULL,
+    hire_date DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT employees_manager_not_self CHECK (manager_id <> id)
+);
+
+CREATE INDEX idx_employees_department ON employees (department);
+CREATE INDEX idx_employees_manager_id ON employees (manager_id);
+
+-- Pick routes: bins are placed along the aisles of zones, and each warehouse may save the
+-- order its zones are visited in and how pickers walk the aisles
+ALTER TABLE warehouse_bins ADD COLUMN zone VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE warehouse_bins ADD COLUMN aisle VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE warehouse_bins ADD COLUMN position INT NOT NULL DEFAULT 0 CHECK (position >= 0);
+
+CREATE TABLE warehouse_pick_layouts (
+    warehouse_id INT PRIMARY KEY REFERENCES warehouses(id) ON DELETE CASCADE,
+    zones TEXT[] NOT NULL DEFAULT '{}',
+    traversal VARCHAR(20) NOT NULL,  -- 'serpentine', 'return'
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Payroll: hourly pay rates per salary grade, and the months run with every employee's pay
+-- (name and email are kept in case the profile is deleted later)
+CREATE TABLE pay_rates (
+    grade VARCHAR(20) PRIMARY KEY,
+    hourly_rate NUMERIC(10, 2) NOT NULL CHECK (hourly_rate > 0),
+    overtime_multiplier NUMERIC(4, 2) NOT NULL DEFAULT 0,  -- 0 for the configured default
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE payroll_runs (
+    id SERIAL PRIMARY KEY,
+    period CHAR(7) NOT NULL UNIQUE,  -- YYYY-MM
+    total NUMERIC(12, 2) NOT NULL,
+    skipped TEXT[] NOT NULL DEFAULT '{}',
+    actor VARCHAR(100) NOT NULL,
+    posted_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE payroll_records (
+    id SERIAL PRIMARY KEY,
+    run_id INT NOT NULL REFERENCES payroll_runs(id) ON DELETE CASCADE,
+    employee_id INT REFERENCES employees(id) ON DELETE SET NULL,
+    user_id INT NOT NULL,
+    employee VARCHAR(100) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    period CHAR(7) NOT NULL,
+    grade VARCHAR(20) NOT NULL,
+    hourly_rate NUMERIC(10, 2) NOT NULL,
+    regular_hours NUMERIC(7, 2) NOT NULL,
+    overtime_hours NUMERIC(7, 2) NOT NULL,
+    leave_days NUMERIC(5, 2) NOT NULL,
+    unpaid_leave_days NUMERIC(5, 2) NOT NULL,
+    regular_pay NUMERIC(12, 2) NOT NULL,
+    overtime_pay NUMERIC(12, 2) NOT NULL,
+    leave_pay NUMERIC(12, 2) NOT NULL,
+    gross NUMERIC(12, 2) NOT NULL,
+    UNIQUE (employee_id, period)
+);
+
+-- Stock snapshots: the stock of every product in every warehouse, copied nightly, that
+-- past balances are worked out from together with the stock movements recorded since
+CREATE TABLE stock_snapshots (
+    id SERIAL PRIMARY KEY,
+    taken_on DATE NOT NULL UNIQUE,
+    taken_at TIMESTAMP NOT NULL,
+    lines INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE stock_snapshot_lines (
+    snapshot_id INT NOT NULL REFERENCES stock_snapshots(id) ON DELETE CASCADE,
+    product_id INT NOT NULL,
+    warehouse_id INT NOT NULL,
+    quantity INT NOT NULL,
+    PRIMARY KEY (snapshot_id, product_id, warehouse_id)
+);
+
+CREATE INDEX idx_stock_snapshots_taken_at ON stock_snapshots (taken_at);
+
+-- Leave entitlements accrue either in full on January 1st ('annual') or a twelfth on the
+-- 1st of each month ('monthly'); requests are checked against the balance when they are
+-- made and approved
+ALTER TABLE leave_policies ADD COLUMN accrual VARCHAR(10) NOT NULL DEFAULT 'annual';
+
+CREATE INDEX idx_leave_user_dates ON leave (user_id, start_date, end_date);
+
+-- Employees check in and out themselves: a check-in opens a record without a check-out,
+-- and each user has at most one open record
+CREATE UNIQUE INDEX idx_attendance_open ON attendance (user_id) WHERE check_out IS NULL;
+
+-- Stock Policy Table (minimum and maximum stock of a product in a warehouse, over every
+-- location; it replaces the reorder levels of the product's stock records there. below_min
+-- records the state notified by the last low-stock check)
+CREATE TABLE stock_policies (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    warehouse_id INT NOT NULL REFERENCES warehouses(id) ON DELETE CASCADE,
+    min_level INT NOT NULL CHECK (min_level >= 0),
+    max_level INT NOT NULL CHECK (max_level > 0 AND max_level >= min_level),
+    below_min BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (product_id, warehouse_id)
+);
+
+-- Audit trail of every change made through the API, recorded by the audit middleware
+CREATE TABLE audit_logs (
+    id SERIAL PRIMARY KEY,
+    actor VARCHAR(100) NOT NULL DEFAULT '',  -- Email of the signed-in user; empty for anonymous and API key requests
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    entity_type VARCHAR(100) NOT NULL,  -- The path before the entity ID, e.g. 'stock/policies'
+    entity_id VARCHAR(50) NOT NULL DEFAULT '',
+    status INT NOT NULL,
+    changes JSONB,  -- {"field": {"from": ..., "to": ...}}
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_logs_created ON audit_logs (created_at);
+CREATE INDEX idx_audit_logs_entity ON audit_logs (entity_type, entity_id, created_at);
+CREATE INDEX idx_audit_logs_actor ON audit_logs (lower(actor), created_at);
+
+-- Purchase orders can require their deliveries to be inspected before they are stocked
+ALTER TABLE purchase_orders ADD COLUMN inspection_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Quality Hold Table (goods received for inspection, held outside stock; released and
+-- rejected are set by the inspection)
+CREATE TABLE quality_holds (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    receipt_id INT NOT NULL REFERENCES purchase_order_receipts(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL,  -- 'quarantined', 'released', 'rejected', 'partially_rejected'
+    released INT NOT NULL DEFAULT 0,
+    rejected INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    CHECK (released + rejected <= quantity)
+);
+
+CREATE INDEX idx_quality_holds_status ON quality_holds (status, product_id, warehouse_id);
+
+-- Supplier Return Table (goods sent back to a supplier, such as those rejected by an inspection)
+CREATE TABLE supplier_returns (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id),
+    receipt_id INT REFERENCES purchase_order_receipts(id) ON DELETE SET NULL,
+    warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    hold_id INT REFERENCES quality_holds(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,  -- 'open'
+    amount DECIMAL(12, 2) NOT NULL,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_supplier_returns_supplier ON supplier_returns (supplier_id, status);
+
+CREATE TABLE supplier_return_lines (
+    id SERIAL PRIMARY KEY,
+    supplier_return_id INT NOT NULL REFERENCES supplier_returns(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10, 2) NOT NULL
+);
+
+-- Quality Inspection Table (one per hold; photos is a JSON list of URLs or storage references)
+CREATE TABLE quality_inspections (
+    hold_id INT PRIMARY KEY REFERENCES quality_holds(id) ON DELETE CASCADE,
+    sample_size INT NOT NULL CHECK (sample_size > 0),
+    result VARCHAR(10) NOT NULL,  -- 'pass' or 'fail'
+    rejected_quantity INT NOT NULL DEFAULT 0,
+    notes TEXT NOT NULL DEFAULT '',
+    photos JSONB NOT NULL DEFAULT '[]',
+    supplier_return_id INT REFERENCES supplier_returns(id) ON DELETE SET NULL,
+    inspected_by VARCHAR(100) NOT NULL,
+    inspected_at TIMESTAMP NOT NULL
+);
+
+-- Soft deletes: DELETE sets deleted_at and reads skip rows that have one, until the record is
+-- restored or an admin purges it
+ALTER TABLE customers ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE invoices ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE payments ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE employees ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE warehouses ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE receivables ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE financial_records ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX idx_customers_deleted ON customers (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_products_deleted ON products (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_invoices_deleted ON invoices (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_payments_deleted ON payments (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_employees_deleted ON employees (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_warehouses_deleted ON warehouses (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_receivables_deleted ON receivables (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_financial_records_deleted ON financial_records (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Returns to vendor: shipping a return issues a debit note against the supplier, settled by
+-- offsetting the receipt's pending payment and by the refunds the supplier sends
+-- (status is now 'open', 'shipped' or 'credited')
+ALTER TABLE supplier_returns ADD COLUMN shipped_by VARCHAR(100);
+ALTER TABLE supplier_returns ADD COLUMN shipped_at TIMESTAMP;
+
+CREATE TABLE supplier_debit_notes (
+    id SERIAL PRIMARY KEY,
+    supplier_return_id INT NOT NULL UNIQUE REFERENCES supplier_returns(id) ON DELETE CASCADE,
+    amount DECIMAL(12, 2) NOT NULL,
+    credited DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    issued_by VARCHAR(100) NOT NULL,
+    issued_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE supplier_credits (
+    id SERIAL PRIMARY KEY,
+    debit_note_id INT NOT NULL REFERENCES supplier_debit_notes(id) ON DELETE CASCADE,
+    method VARCHAR(20) NOT NULL,  -- 'payment_offset', 'refund'
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    payment_id INT REFERENCES payments(id) ON DELETE SET NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    received_on DATE NOT NULL,
+    recorded_by VARCHAR(100) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL
+);
+
+-- Bundle Table (a product sold as a kit of other products; it holds no stock of its own)
+CREATE TABLE bundles (
+    product_id INT PRIMARY KEY REFERENCES products(id) ON DELETE CASCADE,
+    pricing VARCHAR(20) NOT NULL,  -- 'fixed', 'components'
+    discount_percent DECIMAL(5, 2) NOT NULL DEFAULT 0 CHECK (discount_percent >= 0 AND discount_percent < 100),
+    updated_by VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE bundle_components (
+    bundle_id INT NOT NULL REFERENCES bundles(product_id) ON DELETE CASCADE,
+    component_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, component_id)
+);
+
+CREATE INDEX idx_bundle_components_component ON bundle_components (component_id);
+
+-- Optimistic concurrency: version starts at 1 and every update of the row increments it,
+-- whoever makes it. Updates through the API name the version they were based on and change
+-- nothing if the row has moved on since
+CREATE FUNCTION bump_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['customers', 'products', 'warehouses', 'stock', 'invoices', 'payments',
+        'employees', 'suppliers', 'accounts', 'financial_records']
+    LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN version INT NOT NULL DEFAULT 1', t);
+        EXECUTE format('CREATE TRIGGER %I BEFORE UPDATE ON %I FOR EACH ROW EXECUTE FUNCTION bump_version()',
+            t || '_bump_version', t);
+    END LOOP;
+END;
+$$;
+
+-- Standing Order Table (a customer's order repeated on a delivery schedule; a draft sales
+-- order is generated for each delivery, lead_days before it)
+CREATE TABLE standing_orders (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    recurrence VARCHAR(20) NOT NULL,  -- 'weekly', 'biweekly', 'monthly'
+    delivery_day INT NOT NULL,        -- Weekday 0 (Sunday) to 6, or day of the month 1 to 28
+    lead_days INT NOT NULL DEFAULT 0 CHECK (lead_days >= 0),
+    start_date DATE NOT NULL,
+    end_date DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',  -- 'active', 'paused'
+    paused_until DATE,
+    note TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(100),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE standing_order_lines (
+    standing_order_id INT NOT NULL REFERENCES standing_orders(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10, 2) NOT NULL DEFAULT 0,  -- 0 bills the product's price at the time
+    PRIMARY KEY (standing_order_id, product_id)
+);
+
+-- Single deliveries of a standing order that are not to be made
+CREATE TABLE standing_order_skips (
+    standing_order_id INT NOT NULL REFERENCES standing_orders(id) ON DELETE CASCADE,
+    delivery_date DATE NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    skipped_by VARCHAR(100),
+    skipped_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (standing_order_id, delivery_date)
+);
+
+-- Sales orders generated from a standing order name it and their delivery day; each delivery
+-- gets one order, even if it is cancelled
+ALTER TABLE sales_orders ADD COLUMN standing_order_id INT REFERENCES standing_orders(id) ON DELETE SET NULL;
+ALTER TABLE sales_orders ADD COLUMN delivery_date DATE;
+CREATE UNIQUE INDEX idx_sales_orders_standing_delivery ON sales_orders (standing_order_id, delivery_date);
@@ -0,0 +1,37 @@
+-- Delivery Route Table (one vehicle's local deliveries on a day, driven by the company's own
+-- driver; shipments without a carrier are delivered this way)
+CREATE TABLE delivery_routes (
+    id SERIAL PRIMARY KEY,
+    delivery_date DATE NOT NULL,
+    vehicle VARCHAR(100) NOT NULL,
+    driver_email VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'planned',  -- 'planned', 'dispatched', 'completed'
+    depot_latitude DOUBLE PRECISION,                -- Where the route starts, for sequencing
+    depot_longitude DOUBLE PRECISION,
+    created_by VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    dispatched_at TIMESTAMP,
+    completed_at TIMESTAMP
+);
+CREATE INDEX idx_delivery_routes_date ON delivery_routes (delivery_date, driver_email);
+
+-- The shipments delivered on a route, in order, with the proof of delivery the driver
+-- recorded; signatures and photos are kept in the attachment backend
+CREATE TABLE delivery_stops (
+    id SERIAL PRIMARY KEY,
+    route_id INT NOT NULL REFERENCES delivery_routes(id) ON DELETE CASCADE,
+    shipment_id INT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    sequence INT NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- 'pending', 'delivered', 'failed'
+    recipient_name VARCHAR(255) NOT NULL DEFAULT '',
+    signature_key TEXT NOT NULL DEFAULT '',
+    photo_key TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',                  -- The driver's note, or why the delivery failed
+    completed_at TIMESTAMP,                         -- When it was delivered or failed, on the device
+    recorded_by VARCHAR(100) NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_delivery_stops_route ON delivery_stops (route_id, sequence);
+-- A shipment is on one route at a time; after a failed delivery it can be routed again
+CREATE UNIQUE INDEX idx_delivery_stops_pending_shipment ON delivery_stops (shipment_id) WHERE status = 'pending';
@@ -0,0 +1,15 @@
+-- Optimistic concurrency for ledger transactions, journal entries and leave requests, as
+-- for the records versioned in 0002: version starts at 1 and bump_version increments it on
+-- every update of the row
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['financial_transactions', 'journal_entries', 'leave']
+    LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN version INT NOT NULL DEFAULT 1', t);
+        EXECUTE format('CREATE TRIGGER %I BEFORE UPDATE ON %I FOR EACH ROW EXECUTE FUNCTION bump_version()',
+            t || '_bump_version', t);
+    END LOOP;
+END;
+$$;
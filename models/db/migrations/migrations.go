@@ -0,0 +1,273 @@
+// Package migrations keeps the database schema up to date. The schema is a series of SQL
+// files embedded in the binary, named NNNN_description.sql and applied in order of their
+// number. Each file is applied once, in a transaction of its own, and recorded in the
+// schema_migrations table with a checksum of its contents, so an applied file that is later
+// edited is reported instead of silently diverging from the databases it ran on.
+//
+// To change the schema, add a file with the next number; never edit one that has been
+// applied. Databases created with psql before the runner existed already have the initial
+// schema: when the users table exists but schema_migrations does not, the first migration
+// is recorded as applied without running it, and the later ones add the tables since.
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"erp/models/db"
+)
+
+// files holds the migrations built into the binary.
+//
+//go:embed *.sql
+var files embed.FS
+
+// lockKey identifies the advisory lock taken while a migration is applied, so two servers
+// starting at once do not apply the same file twice.
+const lockKey = 327_0001
+
+// fileName matches migration file names and captures the version and description.
+var fileName = regexp.MustCompile(`^(\d{4})_(\w+)\.sql$`)
+
+// Migration is one schema change.
+type Migration struct {
+	Version  int
+	Name     string // The description from the file name
+	SQL      string
+	Checksum string // SHA-256 of SQL, hex encoded
+}
+
+// Status is a migration and whether it has been applied to a database.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"` // Unset while pending
+	Modified  bool       `json:"modified,omitempty"`   // The file changed after it was applied
+}
+
+// Runner applies migrations to a database.
+type Runner struct {
+	DB         *sql.DB
+	Migrations []Migration
+	Logf       func(format string, args ...interface{}) // Reports each migration applied; may be nil
+}
+
+// New creates a runner for the migrations built into the binary.
+func New(db *sql.DB) (*Runner, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{DB: db, Migrations: migrations}, nil
+}
+
+// Load reads the migrations in the top directory of fsys, in order of version. Files that
+// are not .sql are ignored.
+//
+// Returns:
+//   - []Migration: The migrations.
+//   - error: An error if a .sql file is misnamed, two files share a version, or a file
+//     cannot be read.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named NNNN_description.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(contents)
+		migrations = append(migrations, Migration{Version: version, Name: match[2], SQL: string(contents),
+			Checksum: hex.EncodeToString(sum[:])})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every migration that has not been applied yet, in order. It stops at the
+// first that fails; that migration is rolled back and the ones before it stay applied.
+// Migrations may rewrite large tables, so their statements are not bound by the query
+// timeout.
+//
+// Returns:
+//   - []Migration: The migrations applied.
+//   - error: An error if a migration fails or an applied file has been modified.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	ctx = db.WithoutTimeout(ctx)
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied := []Migration{}
+	for _, migration := range r.Migrations {
+		done, err := r.apply(ctx, migration)
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// Status lists every migration, applied or pending, in order. Migrations recorded in the
+// database but missing from the binary, as when running an older build, are listed too.
+// Status only reads, so it works on read-only replicas; before the first Up there is no
+// record of migrations and the list is empty.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	if err := r.DB.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to inspect the schema: %w", err)
+	}
+	if !exists {
+		return []Status{}, nil
+	}
+	rows, err := r.DB.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type record struct {
+		name, checksum string
+		appliedAt      time.Time
+	}
+	records := map[int]record{}
+	for rows.Next() {
+		var version int
+		var rec record
+		if err := rows.Scan(&version, &rec.name, &rec.checksum, &rec.appliedAt); err != nil {
+			return nil, err
+		}
+		records[version] = rec
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	for _, migration := range r.Migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if rec, ok := records[migration.Version]; ok {
+			appliedAt := rec.appliedAt
+			status.AppliedAt, status.Modified = &appliedAt, rec.checksum != migration.Checksum
+			delete(records, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for version, rec := range records {
+		appliedAt := rec.appliedAt
+		statuses = append(statuses, Status{Version: version, Name: rec.name, AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// ensureTable creates the table that records applied migrations. A database whose initial
+// schema was created before the runner is recognized by its users table and gets the
+// first migration recorded.
+func (r *Runner) ensureTable(ctx context.Context) error {
+	var exists, legacy bool
+	err := r.DB.QueryRowContext(ctx,
+		`SELECT to_regclass('schema_migrations') IS NOT NULL, to_regclass('users') IS NOT NULL`).Scan(&exists, &legacy)
+	if err != nil {
+		return fmt.Errorf("failed to inspect the schema: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	_, err = r.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	if legacy && len(r.Migrations) > 0 {
+		first := r.Migrations[0]
+		_, err = r.DB.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+			ON CONFLICT (version) DO NOTHING`, first.Version, first.Name, first.Checksum)
+		if err != nil {
+			return fmt.Errorf("failed to record the existing schema: %w", err)
+		}
+		r.logf("recorded migration %04d_%s for the existing schema", first.Version, first.Name)
+	}
+	return nil
+}
+
+// apply runs a migration unless it has been applied, holding the advisory lock so that
+// another server waits for it and then finds it applied.
+//
+// Returns:
+//   - bool: Whether the migration was applied now.
+//   - error: An error if it fails, or was applied from a file that has since changed.
+func (r *Runner) apply(ctx context.Context, migration Migration) (bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return false, err
+	}
+	var checksum string
+	err = tx.QueryRowContext(ctx, `SELECT checksum FROM schema_migrations WHERE version = $1`, migration.Version).Scan(&checksum)
+	switch {
+	case err == nil && checksum != migration.Checksum:
+		return false, fmt.Errorf("migration %04d_%s has changed since it was applied; add a new migration instead",
+			migration.Version, migration.Name)
+	case err == nil:
+		return false, nil
+	case err != sql.ErrNoRows:
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return false, fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+		migration.Version, migration.Name, migration.Checksum)
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	r.logf("applied migration %04d_%s", migration.Version, migration.Name)
+	return true, nil
+}
+
+// logf reports progress if the runner has a logger.
+func (r *Runner) logf(format string, args ...interface{}) {
+	if r.Logf != nil {
+		r.Logf(format, args...)
+	}
+}
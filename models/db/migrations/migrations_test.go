@@ -0,0 +1,137 @@
+package migrations
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_add_notes.sql": {Data: []byte("ALTER TABLE customers ADD COLUMN notes TEXT;")},
+		"0001_initial.sql":   {Data: []byte("CREATE TABLE customers (id SERIAL PRIMARY KEY);")},
+		"README.md":          {Data: []byte("not a migration")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "initial", migrations[0].Name)
+	assert.Equal(t, "add_notes", migrations[1].Name)
+	assert.Len(t, migrations[1].Checksum, 64)
+
+	_, err = Load(fstest.MapFS{"0001_a.sql": {}, "0001_b.sql": {}})
+	assert.ErrorContains(t, err, "share version 1")
+	_, err = Load(fstest.MapFS{"initial.sql": {}})
+	assert.ErrorContains(t, err, "NNNN_description.sql")
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	runner, err := New(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, runner.Migrations)
+	assert.Equal(t, 1, runner.Migrations[0].Version)
+	for i, migration := range runner.Migrations {
+		assert.Equal(t, i+1, migration.Version, "migration versions have no gaps")
+	}
+}
+
+func TestUpAppliesPendingMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	migrations, err := Load(fstest.MapFS{
+		"0001_initial.sql":   {Data: []byte("CREATE TABLE customers (id SERIAL PRIMARY KEY);")},
+		"0002_add_notes.sql": {Data: []byte("ALTER TABLE customers ADD COLUMN notes TEXT;")},
+	})
+	require.NoError(t, err)
+	runner := &Runner{DB: db, Migrations: migrations}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('schema_migrations')")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists", "legacy"}).AddRow(true, true))
+	// The first migration is applied already
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT checksum FROM schema_migrations")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow(migrations[0].Checksum))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT checksum FROM schema_migrations")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"checksum"}))
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE customers ADD COLUMN notes TEXT;")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations")).WithArgs(2, "add_notes", migrations[1].Checksum).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	applied, err := runner.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, 2, applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpRecordsExistingSchemaAndRefusesEditedMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	migrations, err := Load(fstest.MapFS{
+		"0001_initial.sql": {Data: []byte("CREATE TABLE customers (id SERIAL PRIMARY KEY);")},
+	})
+	require.NoError(t, err)
+	runner := &Runner{DB: db, Migrations: migrations}
+
+	// The users table exists without schema_migrations: the schema was created with psql
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('schema_migrations')")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists", "legacy"}).AddRow(false, true))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations")).WithArgs(1, "initial", migrations[0].Checksum).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT checksum FROM schema_migrations")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("0123"))
+	mock.ExpectRollback()
+
+	applied, err := runner.Up(context.Background())
+	assert.ErrorContains(t, err, "0001_initial has changed since it was applied")
+	assert.Empty(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusOnlyReads(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	migrations, err := Load(fstest.MapFS{
+		"0001_initial.sql":   {Data: []byte("CREATE TABLE customers (id SERIAL PRIMARY KEY);")},
+		"0002_add_notes.sql": {Data: []byte("ALTER TABLE customers ADD COLUMN notes TEXT;")},
+	})
+	require.NoError(t, err)
+	runner := &Runner{DB: db, Migrations: migrations}
+
+	// Without schema_migrations nothing is created and nothing is listed
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('schema_migrations')")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	statuses, err := runner.Status(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('schema_migrations')")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, name, checksum, applied_at FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "initial", migrations[0].Checksum, time.Date(2025, 1, 2, 3, 4, 0, 0, time.UTC)))
+	statuses, err = runner.Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.False(t, statuses[0].Modified)
+	assert.Nil(t, statuses[1].AppliedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
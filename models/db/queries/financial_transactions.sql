@@ -0,0 +1,55 @@
+-- Queries of the general ledger store (financial_transactions).
+
+-- name: InsertFinancialTransaction :one
+-- param: account_type string
+-- param: amount float64
+-- param: transaction_date time.Time
+-- param: description string
+-- param: invoice_id *int
+-- param: journal_entry_id *int
+-- column: id int
+INSERT INTO financial_transactions (account_type, amount, transaction_date, description, invoice_id, journal_entry_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id;
+
+-- name: GetFinancialTransaction :one
+-- param: id int
+-- column: id int
+-- column: account_type string
+-- column: amount float64
+-- column: transaction_date time.Time
+-- column: version int
+SELECT id, account_type, amount, transaction_date, version
+FROM financial_transactions
+WHERE id = $1;
+
+-- name: LockFinancialTransaction :one
+-- param: id int
+-- column: account_type string
+-- column: amount float64
+-- column: transaction_date time.Time
+SELECT account_type, amount, transaction_date
+FROM financial_transactions
+WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateFinancialTransaction :one
+-- param: account_type string
+-- param: amount float64
+-- param: transaction_date time.Time
+-- param: id int
+-- param: version int
+-- column: version int
+UPDATE financial_transactions
+SET account_type = $1, amount = $2, transaction_date = $3
+WHERE id = $4 AND version = $5
+RETURNING version;
+
+-- name: DeleteFinancialTransaction :one
+-- param: id int
+-- column: account_type string
+-- column: amount float64
+-- column: transaction_date time.Time
+DELETE FROM financial_transactions
+WHERE id = $1
+RETURNING account_type, amount, transaction_date;
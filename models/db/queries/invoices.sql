@@ -0,0 +1,81 @@
+-- Queries of the invoice store.
+
+-- name: InsertInvoice :one
+-- param: sales_order_id int
+-- param: customer_id int
+-- param: amount float64
+-- param: status string
+-- column: id int
+-- column: version int
+INSERT INTO invoices (sales_order_id, customer_id, amount, status)
+VALUES ($1, $2, $3, $4)
+RETURNING id, version;
+
+-- name: GetInvoice :one
+-- param: id int
+-- column: id int
+-- column: sales_order_id int
+-- column: customer_id int
+-- column: amount float64
+-- column: status sql.NullString
+-- column: version int
+SELECT id, sales_order_id, customer_id, amount, status, version
+FROM invoices
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: LockInvoice :one
+-- param: id int
+-- column: id int
+-- column: sales_order_id int
+-- column: customer_id int
+-- column: amount float64
+-- column: status sql.NullString
+-- column: version int
+SELECT id, sales_order_id, customer_id, amount, status, version
+FROM invoices
+WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: UpdateDraftInvoice :one
+-- param: sales_order_id int
+-- param: customer_id int
+-- param: amount float64
+-- param: id int
+-- param: draft_status string
+-- param: version int
+-- column: version int
+UPDATE invoices
+SET sales_order_id = $1, customer_id = $2, amount = $3
+WHERE id = $4 AND status = $5 AND version = $6 AND deleted_at IS NULL
+RETURNING version;
+
+-- name: DeleteDraftInvoice :execrows
+-- param: id int
+-- param: draft_status string
+UPDATE invoices
+SET deleted_at = now()
+WHERE id = $1 AND status = $2 AND deleted_at IS NULL;
+
+-- name: InvoiceExists :one
+-- param: id int
+-- column: exists bool
+SELECT EXISTS (SELECT 1 FROM invoices WHERE id = $1 AND deleted_at IS NULL) AS exists;
+
+-- name: SetInvoiceStatus :exec
+-- param: status string
+-- param: id int
+UPDATE invoices SET status = $1 WHERE id = $2;
+
+-- name: LockInvoiceStatuses :many
+-- param: ids pq.Int64Array
+-- column: id int
+-- column: status sql.NullString
+SELECT id, status
+FROM invoices
+WHERE id = ANY($1) AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: SetInvoiceStatuses :exec
+-- param: status string
+-- param: ids pq.Int64Array
+UPDATE invoices SET status = $1 WHERE id = ANY($2);
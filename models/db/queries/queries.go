@@ -0,0 +1,118 @@
+// Package queries holds the typed query layer used by the stores. The functions in the
+// *.sql.go files are generated from the annotated *.sql files next to them; edit the SQL
+// and run go generate rather than editing the Go code.
+package queries
+
+//go:generate go run erp/cmd/querygen .
+
+import (
+	"context"
+	"database/sql"
+
+	"erp/models/db"
+)
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Queries runs the generated queries on a database or transaction.
+type Queries struct {
+	db    DBTX
+	pool  *sql.DB        // Set when statements are prepared
+	stmts *db.Statements // Cache of prepared statements, or nil
+	tx    *sql.Tx        // Set when running inside a transaction
+}
+
+// New returns queries that send their SQL text on every call.
+func New(d DBTX) *Queries {
+	tx, _ := d.(*sql.Tx)
+	return &Queries{db: d, tx: tx}
+}
+
+// Prepared returns queries that prepare each statement once, in stmts, and reuse it.
+func Prepared(pool *sql.DB, stmts *db.Statements) *Queries {
+	return &Queries{db: pool, pool: pool, stmts: stmts}
+}
+
+// WithTx returns queries that run inside tx. Statements q has already prepared are bound
+// to the transaction with tx.Stmt; others run as plain SQL, because preparing them would
+// take a second connection while the transaction holds one. Call Prepare before Begin
+// for the statements a transaction should reuse.
+func (q *Queries) WithTx(tx *sql.Tx) *Queries {
+	return &Queries{db: tx, pool: q.pool, stmts: q.stmts, tx: tx}
+}
+
+// Prepare prepares the given statements ahead of use. It does nothing for queries made
+// with New.
+//
+// Parameters:
+//   - queries: The SQL constants of the generated queries, e.g. InsertInvoiceSQL.
+//
+// Returns:
+//   - error: An error if a statement cannot be prepared.
+func (q *Queries) Prepare(ctx context.Context, queries ...string) error {
+	if q.stmts == nil {
+		return nil
+	}
+	for _, query := range queries {
+		if _, err := q.stmts.Prepare(ctx, q.pool, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stmt returns the prepared statement for query, bound to the transaction if there is
+// one, or nil if the query should run as plain SQL.
+func (q *Queries) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	if q.stmts == nil {
+		return nil, nil
+	}
+	if q.tx != nil {
+		if stmt, ok := q.stmts.Lookup(query); ok {
+			return q.tx.StmtContext(ctx, stmt), nil
+		}
+		return nil, nil
+	}
+	return q.stmts.Prepare(ctx, q.pool, query)
+}
+
+// queryRow runs a query that returns at most one row.
+func (q *Queries) queryRow(ctx context.Context, query string, args ...interface{}) (*sql.Row, error) {
+	stmt, err := q.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt != nil {
+		return stmt.QueryRowContext(ctx, args...), nil
+	}
+	return q.db.QueryRowContext(ctx, query, args...), nil
+}
+
+// query runs a query that returns rows.
+func (q *Queries) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := q.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return q.db.QueryContext(ctx, query, args...)
+}
+
+// exec runs a statement that returns no rows.
+func (q *Queries) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := q.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt != nil {
+		return stmt.ExecContext(ctx, args...)
+	}
+	return q.db.ExecContext(ctx, query, args...)
+}
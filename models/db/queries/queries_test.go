@@ -0,0 +1,65 @@
+package queries
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"erp/models/db"
+	"erp/models/db/querygen"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGeneratedCodeIsCurrent fails when a .sql file was edited without running
+// go generate ./models/db/queries.
+func TestGeneratedCodeIsCurrent(t *testing.T) {
+	files, err := querygen.GenerateDir(".", "queries")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for name, want := range files {
+		got, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got), "%s is out of date; run go generate ./models/db/queries", name)
+	}
+}
+
+// TestPreparedQueriesReuseStatements verifies that a statement is prepared once, reused
+// by later calls, and bound to transactions that run after it was prepared.
+func TestPreparedQueriesReuseStatements(t *testing.T) {
+	ctx := context.Background()
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+	var stmts db.Statements
+	q := Prepared(conn, &stmts)
+
+	exists := mock.ExpectPrepare("SELECT EXISTS")
+	exists.ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	exists.ExpectQuery().WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	found, err := q.InvoiceExists(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = q.InvoiceExists(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// Inside the transaction the prepared lookup is reused and the unprepared update runs
+	// as plain SQL.
+	mock.ExpectBegin()
+	exists.ExpectQuery().WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("UPDATE invoices SET status").WithArgs("posted", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	tx, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	txq := q.WithTx(tx)
+	found, err = txq.InvoiceExists(ctx, 3)
+	require.NoError(t, err)
+	assert.True(t, found)
+	require.NoError(t, txq.SetInvoiceStatus(ctx, "posted", 3))
+	require.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,45 @@
+-- Queries of the stock store.
+
+-- name: InsertStock :one
+-- param: product_id int
+-- param: quantity int
+-- param: warehouse_id int
+-- param: location string
+-- param: reorder_level int
+-- column: id int
+-- column: version int
+INSERT INTO stock (product_id, quantity, warehouse_id, location, reorder_level)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, version;
+
+-- name: GetStockByProduct :one
+-- param: product_id int
+-- column: id int
+-- column: product_id int
+-- column: quantity int
+-- column: warehouse_id int
+-- column: location string
+-- column: reorder_level int
+-- column: version int
+SELECT id, product_id, quantity, warehouse_id, location, reorder_level, version
+FROM stock
+WHERE product_id = $1;
+
+-- name: UpdateStock :one
+-- param: product_id int
+-- param: quantity int
+-- param: warehouse_id int
+-- param: location string
+-- param: reorder_level int
+-- param: id int
+-- param: version int
+-- column: version int
+UPDATE stock
+SET product_id = $1, quantity = $2, warehouse_id = $3, location = $4, reorder_level = $5
+WHERE id = $6 AND version = $7
+RETURNING version;
+
+-- name: DeleteStock :execrows
+-- param: id int
+DELETE FROM stock
+WHERE id = $1;
@@ -0,0 +1,556 @@
+// Package querygen generates type-safe Go functions from annotated SQL files, so stores
+// no longer hand-write Scan calls whose argument order must match the SELECT list.
+//
+// Each query in a .sql file starts with a name line, followed by the Go types of its
+// parameters ($1, $2, ...) and result columns, in order:
+//
+//	-- name: GetInvoice :one
+//	-- param: id int
+//	-- column: id int
+//	-- column: status sql.NullString
+//	SELECT id, status FROM invoices WHERE id = $1;
+//
+// The kinds are :one (a single row, sql.ErrNoRows if there is none), :many (a slice of
+// rows), :exec (no result) and :execrows (the number of affected rows). The generator
+// refuses to write code when the columns returned by the SELECT list or RETURNING clause
+// do not match the declared columns by name and position, or when the declared parameters
+// do not match the placeholders used. Types may come from the sql, time, json and pq
+// packages.
+package querygen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query kinds
+const (
+	KindOne      = ":one"
+	KindMany     = ":many"
+	KindExec     = ":exec"
+	KindExecRows = ":execrows"
+)
+
+// Field is a named, typed parameter or result column.
+type Field struct {
+	Name string // Column or parameter name in snake_case
+	Type string // Go type, e.g. "int", "*time.Time", "sql.NullString"
+}
+
+// Query is one annotated statement.
+type Query struct {
+	Name    string
+	Kind    string
+	Params  []Field
+	Columns []Field
+	SQL     string
+	Line    int // Line of the name annotation, for error messages
+}
+
+var (
+	nameLine     = regexp.MustCompile(`^--\s*name:\s*([A-Z][A-Za-z0-9]*)\s+(:[a-z]+)\s*$`)
+	fieldLine    = regexp.MustCompile(`^--\s*(param|column):\s*([a-z_][a-z0-9_]*)\s+(\S+)\s*$`)
+	placeholder  = regexp.MustCompile(`\$(\d+)`)
+	identifier   = regexp.MustCompile(`^"?([A-Za-z_][A-Za-z0-9_]*)"?$`)
+	aliasPattern = regexp.MustCompile(`(?i)\s+AS\s+"?([A-Za-z_][A-Za-z0-9_]*)"?$`)
+)
+
+// Parse reads the queries of one SQL file and checks each of them.
+//
+// Parameters:
+//   - file: The file name, used in error messages.
+//   - src: The file's contents.
+//
+// Returns:
+//   - []Query: The queries in file order.
+//   - error: An error describing the first invalid annotation or mismatch.
+func Parse(file string, src []byte) ([]Query, error) {
+	var queries []Query
+	var current *Query
+	var body []string
+
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		current.SQL = strings.TrimSuffix(strings.TrimSpace(strings.Join(body, "\n")), ";")
+		if err := check(current); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", file, current.Line, current.Name, err)
+		}
+		queries = append(queries, *current)
+		current, body = nil, nil
+		return nil
+	}
+
+	for i, line := range strings.Split(string(src), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if m := nameLine.FindStringSubmatch(trimmed); m != nil {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			current = &Query{Name: m[1], Kind: m[2], Line: i + 1}
+			continue
+		}
+		if current == nil {
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return nil, fmt.Errorf("%s:%d: SQL before the first -- name: annotation", file, i+1)
+			}
+			continue
+		}
+		if m := fieldLine.FindStringSubmatch(trimmed); m != nil && len(body) == 0 {
+			field := Field{Name: m[2], Type: m[3]}
+			if m[1] == "param" {
+				current.Params = append(current.Params, field)
+			} else {
+				current.Columns = append(current.Columns, field)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "--") && len(body) == 0 {
+			return nil, fmt.Errorf("%s:%d: unrecognized annotation %q", file, i+1, trimmed)
+		}
+		body = append(body, strings.TrimRight(line, " \t\r"))
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// check verifies a query's kind, parameters and columns against its SQL.
+func check(q *Query) error {
+	if q.SQL == "" {
+		return fmt.Errorf("no SQL")
+	}
+
+	switch q.Kind {
+	case KindOne, KindMany:
+		if len(q.Columns) == 0 {
+			return fmt.Errorf("%s queries need at least one -- column:", q.Kind)
+		}
+		returned, err := ResultColumns(q.SQL)
+		if err != nil {
+			return err
+		}
+		declared := make([]string, len(q.Columns))
+		for i, c := range q.Columns {
+			declared[i] = c.Name
+		}
+		if strings.Join(returned, ",") != strings.Join(declared, ",") {
+			return fmt.Errorf("SQL returns columns (%s) but the annotations declare (%s)",
+				strings.Join(returned, ", "), strings.Join(declared, ", "))
+		}
+	case KindExec, KindExecRows:
+		if len(q.Columns) > 0 {
+			return fmt.Errorf("%s queries cannot declare columns", q.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown kind %s", q.Kind)
+	}
+
+	used := map[int]bool{}
+	for _, m := range placeholder.FindAllStringSubmatch(q.SQL, -1) {
+		n, _ := strconv.Atoi(m[1])
+		used[n] = true
+	}
+	for n := range used {
+		if n < 1 || n > len(q.Params) {
+			return fmt.Errorf("SQL uses $%d but %d parameters are declared", n, len(q.Params))
+		}
+	}
+	for n := 1; n <= len(q.Params); n++ {
+		if !used[n] {
+			return fmt.Errorf("parameter %s ($%d) is not used in the SQL", q.Params[n-1].Name, n)
+		}
+	}
+
+	for _, fields := range [][]Field{q.Params, q.Columns} {
+		seen := map[string]bool{}
+		for _, f := range fields {
+			if seen[f.Name] {
+				return fmt.Errorf("%s is declared twice", f.Name)
+			}
+			seen[f.Name] = true
+		}
+	}
+	return nil
+}
+
+// ResultColumns returns the names of the columns a statement returns: the RETURNING list
+// if there is one, otherwise the list of the outermost SELECT. Expressions other than
+// plain or qualified column names need an alias ("COUNT(*) AS total").
+func ResultColumns(sql string) ([]string, error) {
+	list, ok := topLevelClause(sql, "RETURNING", "")
+	if !ok {
+		list, ok = topLevelClause(sql, "SELECT", "FROM")
+	}
+	if !ok {
+		return nil, fmt.Errorf("no SELECT list or RETURNING clause found")
+	}
+
+	var names []string
+	for _, expr := range splitTopLevel(list) {
+		expr = strings.TrimSpace(expr)
+		if m := aliasPattern.FindStringSubmatch(expr); m != nil {
+			names = append(names, strings.ToLower(m[1]))
+			continue
+		}
+		name := expr
+		if dot := strings.LastIndex(expr, "."); dot >= 0 {
+			name = expr[dot+1:]
+		}
+		m := identifier.FindStringSubmatch(name)
+		if m == nil || name == "*" {
+			return nil, fmt.Errorf("result column %q needs an alias", expr)
+		}
+		names = append(names, strings.ToLower(m[1]))
+	}
+	return names, nil
+}
+
+// topLevelClause returns the text between the first top-level keyword start and the next
+// top-level keyword end, or the end of the statement if there is no such keyword.
+func topLevelClause(sql, start, end string) (string, bool) {
+	from := -1
+	depth := 0
+	quoted := false
+	upper := strings.ToUpper(sql)
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && isKeywordAt(upper, i, start) && from < 0:
+			from = i + len(start)
+			i = from - 1
+		case depth == 0 && from >= 0 && end != "" && isKeywordAt(upper, i, end):
+			return sql[from:i], true
+		}
+	}
+	if from >= 0 {
+		return sql[from:], true
+	}
+	return "", false
+}
+
+// isKeywordAt reports whether keyword appears as a whole word at position i of s.
+func isKeywordAt(s string, i int, keyword string) bool {
+	if !strings.HasPrefix(s[i:], keyword) {
+		return false
+	}
+	if i > 0 && isWordByte(s[i-1]) {
+		return false
+	}
+	after := i + len(keyword)
+	return after == len(s) || !isWordByte(s[after])
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// splitTopLevel splits a list on commas that are not inside parentheses or quotes.
+func splitTopLevel(list string) []string {
+	var parts []string
+	depth, last := 0, 0
+	quoted := false
+	for i := 0; i < len(list); i++ {
+		switch c := list[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, list[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, list[last:])
+}
+
+// GoName converts a snake_case name to an exported Go name, e.g. sales_order_id to
+// SalesOrderID.
+func GoName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		switch upper := strings.ToUpper(part); upper {
+		case "ID", "URL", "API", "SQL", "JSON", "HTTP", "SKU":
+			b.WriteString(upper)
+		default:
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// packagePaths maps the package qualifiers allowed in annotation types to import paths.
+// Standard library packages sort before third-party ones, which goimports separates.
+var packagePaths = map[string]string{
+	"json": "encoding/json",
+	"sql":  "database/sql",
+	"time": "time",
+	"pq":   "github.com/lib/pq",
+}
+
+// typeImports returns the body of the import block needed by the generated functions,
+// which take a context, and by the annotated types.
+func typeImports(queries []Query) (string, error) {
+	paths := map[string]bool{"context": true}
+	for _, q := range queries {
+		for _, f := range append(append([]Field{}, q.Params...), q.Columns...) {
+			typ := strings.TrimLeft(f.Type, "*[]")
+			dot := strings.Index(typ, ".")
+			if dot < 0 {
+				continue
+			}
+			path, ok := packagePaths[typ[:dot]]
+			if !ok {
+				return "", fmt.Errorf("%s: unknown package in type %s", q.Name, f.Type)
+			}
+			paths[path] = true
+		}
+	}
+
+	var std, third []string
+	for path := range paths {
+		if strings.Contains(path, ".") {
+			third = append(third, strconv.Quote(path))
+		} else {
+			std = append(std, strconv.Quote(path))
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(third)
+	var groups []string
+	for _, group := range [][]string{std, third} {
+		if len(group) > 0 {
+			groups = append(groups, strings.Join(group, "\n"))
+		}
+	}
+	return strings.Join(groups, "\n\n"), nil
+}
+
+// argName converts a snake_case name to an unexported Go identifier.
+func argName(name string) string {
+	goName := GoName(name)
+	if goName == "ID" {
+		return "id"
+	}
+	runes := []rune(goName)
+	runes[0] = unicode.ToLower(runes[0])
+	arg := string(runes)
+	switch arg {
+	case "type", "func", "var", "range", "default", "map", "select", "case", "go", "package":
+		return arg + "_"
+	}
+	return arg
+}
+
+// Generate returns the formatted Go source for the queries of one SQL file.
+//
+// Parameters:
+//   - pkg: The package name of the generated file.
+//   - file: The SQL file name, recorded in the header.
+//   - queries: The parsed queries.
+//
+// Returns:
+//   - []byte: The gofmt-ed source.
+//   - error: An error if the source cannot be formatted.
+func Generate(pkg, file string, queries []Query) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by querygen from %s. DO NOT EDIT.\n\npackage %s\n\n", file, pkg)
+
+	var body bytes.Buffer
+	for _, q := range queries {
+		writeQuery(&body, q)
+	}
+
+	imports, err := typeImports(queries)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&b, "import (\n%s\n)\n\n", imports)
+	}
+	b.Write(body.Bytes())
+
+	formatted, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code for %s: %w", file, err)
+	}
+	return formatted, nil
+}
+
+// writeQuery writes the SQL constant, row and parameter types, and method of a query.
+func writeQuery(b *bytes.Buffer, q Query) {
+	fmt.Fprintf(b, "// %sSQL is the statement run by %s.\nconst %sSQL = `\n%s\n`\n\n", q.Name, q.Name, q.Name, q.SQL)
+
+	rowType := ""
+	switch {
+	case len(q.Columns) == 1:
+		rowType = q.Columns[0].Type
+	case len(q.Columns) > 1:
+		rowType = q.Name + "Row"
+		fmt.Fprintf(b, "// %s is a row returned by %s.\ntype %s struct {\n", rowType, q.Name, rowType)
+		for _, c := range q.Columns {
+			fmt.Fprintf(b, "\t%s %s\n", GoName(c.Name), c.Type)
+		}
+		b.WriteString("}\n\n")
+	}
+
+	// Up to two parameters are passed as arguments; more are passed in a struct so
+	// values cannot be swapped by position.
+	var params, args []string
+	if len(q.Params) > 2 {
+		paramType := q.Name + "Params"
+		fmt.Fprintf(b, "// %s holds the parameters of %s.\ntype %s struct {\n", paramType, q.Name, paramType)
+		for _, p := range q.Params {
+			fmt.Fprintf(b, "\t%s %s\n", GoName(p.Name), p.Type)
+			args = append(args, "arg."+GoName(p.Name))
+		}
+		b.WriteString("}\n\n")
+		params = []string{"arg " + paramType}
+	} else {
+		for _, p := range q.Params {
+			params = append(params, argName(p.Name)+" "+p.Type)
+			args = append(args, argName(p.Name))
+		}
+	}
+	argList := strings.Join(args, ", ")
+	if argList != "" {
+		argList = ", " + argList
+	}
+
+	var scanDest []string
+	if len(q.Columns) == 1 {
+		scanDest = []string{"&i"}
+	} else {
+		for _, c := range q.Columns {
+			scanDest = append(scanDest, "&i."+GoName(c.Name))
+		}
+	}
+	scan := strings.Join(scanDest, ", ")
+	params = append([]string{"ctx context.Context"}, params...)
+	signature := fmt.Sprintf("func (q *Queries) %s(%s)", q.Name, strings.Join(params, ", "))
+
+	switch q.Kind {
+	case KindOne:
+		fmt.Fprintf(b, "// %s runs %sSQL and returns its row, or sql.ErrNoRows.\n", q.Name, q.Name)
+		fmt.Fprintf(b, `%s (%s, error) {
+	var i %s
+	row, err := q.queryRow(ctx, %sSQL%s)
+	if err != nil {
+		return i, err
+	}
+	err = row.Scan(%s)
+	return i, err
+}
+
+`, signature, rowType, rowType, q.Name, argList, scan)
+	case KindMany:
+		fmt.Fprintf(b, "// %s runs %sSQL and returns its rows.\n", q.Name, q.Name)
+		fmt.Fprintf(b, `%s ([]%s, error) {
+	rows, err := q.query(ctx, %sSQL%s)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []%s{}
+	for rows.Next() {
+		var i %s
+		if err := rows.Scan(%s); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+`, signature, rowType, q.Name, argList, rowType, rowType, scan)
+	case KindExec:
+		fmt.Fprintf(b, "// %s runs %sSQL.\n", q.Name, q.Name)
+		fmt.Fprintf(b, `%s error {
+	_, err := q.exec(ctx, %sSQL%s)
+	return err
+}
+
+`, signature, q.Name, argList)
+	case KindExecRows:
+		fmt.Fprintf(b, "// %s runs %sSQL and returns the number of affected rows.\n", q.Name, q.Name)
+		fmt.Fprintf(b, `%s (int64, error) {
+	result, err := q.exec(ctx, %sSQL%s)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+`, signature, q.Name, argList)
+	}
+}
+
+// OutputName returns the generated file name for a SQL file, e.g. invoices.sql.go.
+func OutputName(sqlFile string) string {
+	return filepath.Base(sqlFile) + ".go"
+}
+
+// GenerateDir generates code for every .sql file in dir.
+//
+// Parameters:
+//   - dir: The directory holding the SQL files and the package.
+//   - pkg: The package name of the generated files.
+//
+// Returns:
+//   - map[string][]byte: The generated source keyed by output file name.
+//   - error: An error if a file cannot be read, parsed or generated.
+func GenerateDir(dir, pkg string) (map[string][]byte, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	out := make(map[string][]byte, len(files))
+	names := map[string]string{}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		base := filepath.Base(file)
+		queries, err := Parse(base, src)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range queries {
+			if other, dup := names[q.Name]; dup {
+				return nil, fmt.Errorf("%s: query %s is also defined in %s", base, q.Name, other)
+			}
+			names[q.Name] = base
+		}
+		code, err := Generate(pkg, base, queries)
+		if err != nil {
+			return nil, err
+		}
+		out[OutputName(file)] = code
+	}
+	return out, nil
+}
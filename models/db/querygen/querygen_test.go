@@ -0,0 +1,146 @@
+package querygen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	src := `-- Queries of the test store.
+
+-- name: GetItem :one
+-- param: id int
+-- column: id int
+-- column: name string
+SELECT id, name
+FROM items
+WHERE id = $1;
+
+-- name: DeleteItem :execrows
+-- param: id int
+DELETE FROM items WHERE id = $1;
+`
+	queries, err := Parse("items.sql", []byte(src))
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+
+	assert.Equal(t, "GetItem", queries[0].Name)
+	assert.Equal(t, KindOne, queries[0].Kind)
+	assert.Equal(t, []Field{{Name: "id", Type: "int"}}, queries[0].Params)
+	assert.Equal(t, []Field{{Name: "id", Type: "int"}, {Name: "name", Type: "string"}}, queries[0].Columns)
+	assert.Equal(t, "SELECT id, name\nFROM items\nWHERE id = $1", queries[0].SQL)
+	assert.Equal(t, 3, queries[0].Line)
+	assert.Equal(t, KindExecRows, queries[1].Kind)
+}
+
+// TestParseRejectsMismatches verifies that annotations which do not match their SQL are
+// reported with the file, line and query name instead of generating wrong code.
+func TestParseRejectsMismatches(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "column order",
+			src:  "-- name: Q :one\n-- param: id int\n-- column: name string\n-- column: id int\nSELECT id, name FROM items WHERE id = $1;",
+			want: "items.sql:1: Q: SQL returns columns (id, name) but the annotations declare (name, id)",
+		},
+		{
+			name: "missing column",
+			src:  "-- name: Q :many\n-- column: id int\nSELECT id, name FROM items;",
+			want: "SQL returns columns (id, name) but the annotations declare (id)",
+		},
+		{
+			name: "undeclared placeholder",
+			src:  "-- name: Q :exec\n-- param: id int\nUPDATE items SET name = $2 WHERE id = $1;",
+			want: "SQL uses $2 but 1 parameters are declared",
+		},
+		{
+			name: "unused parameter",
+			src:  "-- name: Q :exec\n-- param: id int\n-- param: name string\nDELETE FROM items WHERE id = $1;",
+			want: "parameter name ($2) is not used in the SQL",
+		},
+		{
+			name: "expression without alias",
+			src:  "-- name: Q :one\n-- column: count int64\nSELECT COUNT(*) FROM items;",
+			want: `result column "COUNT(*)" needs an alias`,
+		},
+		{
+			name: "columns on exec",
+			src:  "-- name: Q :exec\n-- column: id int\nDELETE FROM items RETURNING id;",
+			want: ":exec queries cannot declare columns",
+		},
+		{
+			name: "unknown annotation",
+			src:  "-- name: Q :exec\n-- parm: id int\nDELETE FROM items WHERE id = $1;",
+			want: "items.sql:2: unrecognized annotation",
+		},
+		{
+			name: "SQL without a name",
+			src:  "DELETE FROM items;",
+			want: "items.sql:1: SQL before the first -- name: annotation",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("items.sql", []byte(tt.src))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestResultColumns(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id, i.name, COALESCE(notes, '') AS notes FROM items i", []string{"id", "name", "notes"}},
+		{"SELECT id, (SELECT MAX(amount) FROM lines l WHERE l.item_id = items.id) AS top FROM items", []string{"id", "top"}},
+		{"SELECT EXISTS (SELECT 1 FROM items WHERE id = $1) AS exists", []string{"exists"}},
+		{"INSERT INTO items (name) VALUES ($1) RETURNING id, created_at", []string{"id", "created_at"}},
+		{"SELECT id FROM items WHERE name = 'from'", []string{"id"}},
+	}
+	for _, tt := range tests {
+		got, err := ResultColumns(tt.sql)
+		require.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestGoName(t *testing.T) {
+	assert.Equal(t, "SalesOrderID", GoName("sales_order_id"))
+	assert.Equal(t, "ID", GoName("id"))
+	assert.Equal(t, "ImageURL", GoName("image_url"))
+	assert.Equal(t, "Type", GoName("type"))
+	assert.Equal(t, "type_", argName("type"))
+	assert.Equal(t, "salesOrderID", argName("sales_order_id"))
+}
+
+func TestGenerate(t *testing.T) {
+	src := `-- name: ListItems :many
+-- param: ids pq.Int64Array
+-- column: id int
+-- column: updated_at sql.NullTime
+SELECT id, updated_at FROM items WHERE id = ANY($1);
+`
+	queries, err := Parse("items.sql", []byte(src))
+	require.NoError(t, err)
+	code, err := Generate("store", "items.sql", queries)
+	require.NoError(t, err)
+
+	out := string(code)
+	assert.True(t, strings.HasPrefix(out, "// Code generated by querygen from items.sql. DO NOT EDIT.\n"))
+	assert.Contains(t, out, "import (\n\t\"context\"\n\t\"database/sql\"\n\n\t\"github.com/lib/pq\"\n)")
+	assert.Contains(t, out, "type ListItemsRow struct {")
+	assert.Contains(t, out, "func (q *Queries) ListItems(ctx context.Context, ids pq.Int64Array) ([]ListItemsRow, error) {")
+	assert.Contains(t, out, "rows.Scan(&i.ID, &i.UpdatedAt)")
+
+	queries[0].Params[0].Type = "uuid.UUID"
+	_, err = Generate("store", "items.sql", queries)
+	assert.EqualError(t, err, "items.sql: ListItems: unknown package in type uuid.UUID")
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Statements prepares each query once and reuses the prepared statement afterwards, so
+// hot queries are parsed and planned by PostgreSQL once per connection instead of on
+// every call. The zero value is ready to use; stores embed one per instance.
+type Statements struct {
+	mu        sync.Mutex
+	stmts     map[string]*sql.Stmt
+	preparing map[string]*preparation
+}
+
+// preparation is a statement being prepared. done is closed once stmt and err are set.
+type preparation struct {
+	done chan struct{}
+	stmt *sql.Stmt
+	err  error
+}
+
+// Prepare returns the prepared statement for query, preparing it on first use. The
+// round trip to the database runs outside the lock, so a slow prepare only holds up the
+// callers waiting for the same query; they share its result instead of preparing again.
+//
+// Parameters:
+//   - db: The database the statement is prepared on.
+//   - query: The SQL text, used as the cache key.
+//
+// Returns:
+//   - *sql.Stmt: The prepared statement. Use tx.Stmt to run it inside a transaction.
+//   - error: An error if the statement cannot be prepared; nothing is cached then.
+func (s *Statements) Prepare(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	s.mu.Lock()
+	if stmt, ok := s.stmts[query]; ok {
+		s.mu.Unlock()
+		return stmt, nil
+	}
+	if p, ok := s.preparing[query]; ok {
+		s.mu.Unlock()
+		select {
+		case <-p.done:
+			return p.stmt, p.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	p := &preparation{done: make(chan struct{})}
+	if s.preparing == nil {
+		s.preparing = make(map[string]*preparation)
+	}
+	s.preparing[query] = p
+	s.mu.Unlock()
+
+	p.stmt, p.err = db.PrepareContext(ctx, query)
+
+	s.mu.Lock()
+	delete(s.preparing, query)
+	if p.err == nil {
+		if s.stmts == nil {
+			s.stmts = make(map[string]*sql.Stmt)
+		}
+		s.stmts[query] = p.stmt
+	}
+	s.mu.Unlock()
+	close(p.done)
+	return p.stmt, p.err
+}
+
+// Lookup returns the prepared statement for query if it has already been prepared.
+func (s *Statements) Lookup(query string) (*sql.Stmt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, ok := s.stmts[query]
+	return stmt, ok
+}
+
+// Close closes every prepared statement.
+func (s *Statements) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var first error
+	for query, stmt := range s.stmts {
+		if err := stmt.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(s.stmts, query)
+	}
+	return first
+}
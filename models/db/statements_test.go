@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prepareConnector opens prepareConns that share one count of prepared statements.
+type prepareConnector struct {
+	prepared atomic.Int64
+	release  chan struct{}      // Statements starting with "blocked" wait for it to close
+	started  chan chan struct{} // If set, "slow" statements send on it a channel that cuts their round trip short
+}
+
+func (c *prepareConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &prepareConn{connector: c}, nil
+}
+func (c *prepareConnector) Driver() driver.Driver { return nil }
+
+// prepareConn prepares statements with a simulated round trip: "blocked" statements wait
+// for their connector's release, "slow" ones take a millisecond and the rest none.
+type prepareConn struct {
+	connector *prepareConnector
+}
+
+func (c *prepareConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	switch {
+	case strings.HasPrefix(query, "blocked"):
+		select {
+		case <-c.connector.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	case strings.HasPrefix(query, "slow"):
+		finish := make(chan struct{})
+		if c.connector.started != nil {
+			c.connector.started <- finish
+		}
+		select {
+		case <-finish:
+		case <-time.After(time.Millisecond):
+		}
+	}
+	c.connector.prepared.Add(1)
+	return prepareStmt{}, nil
+}
+
+func (c *prepareConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+func (c *prepareConn) Close() error              { return nil }
+func (c *prepareConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type prepareStmt struct{}
+
+func (prepareStmt) Close() error  { return nil }
+func (prepareStmt) NumInput() int { return -1 }
+func (prepareStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (prepareStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func openPrepareFake(tb testing.TB) (*sql.DB, *prepareConnector) {
+	connector := &prepareConnector{release: make(chan struct{})}
+	database := sql.OpenDB(connector)
+	tb.Cleanup(func() { database.Close() })
+	return database, connector
+}
+
+func TestPrepareCachesStatements(t *testing.T) {
+	database, connector := openPrepareFake(t)
+	var stmts Statements
+	defer stmts.Close()
+
+	first, err := stmts.Prepare(context.Background(), database, "fast")
+	require.NoError(t, err)
+	second, err := stmts.Prepare(context.Background(), database, "fast")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.EqualValues(t, 1, connector.prepared.Load())
+
+	stmt, ok := stmts.Lookup("fast")
+	assert.True(t, ok)
+	assert.Same(t, first, stmt)
+}
+
+func TestSlowPrepareDoesNotBlockOtherQueries(t *testing.T) {
+	database, connector := openPrepareFake(t)
+	var stmts Statements
+	defer stmts.Close()
+
+	// Two callers ask for the same blocked statement while another query is prepared
+	var wg sync.WaitGroup
+	results := make([]*sql.Stmt, 2)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stmt, err := stmts.Prepare(context.Background(), database, "blocked")
+			assert.NoError(t, err)
+			results[i] = stmt
+		}()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := stmts.Prepare(context.Background(), database, "fast")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Preparing one query waited for another")
+	}
+
+	close(connector.release)
+	wg.Wait()
+	assert.Same(t, results[0], results[1], "Callers of the same query share one statement")
+	assert.EqualValues(t, 2, connector.prepared.Load())
+}
+
+func TestPrepareWaitHonoursContext(t *testing.T) {
+	database, connector := openPrepareFake(t)
+	var stmts Statements
+	defer stmts.Close()
+
+	go stmts.Prepare(context.Background(), database, "blocked")
+	require.Eventually(t, func() bool {
+		stmts.mu.Lock()
+		defer stmts.mu.Unlock()
+		return stmts.preparing["blocked"] != nil
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := stmts.Prepare(ctx, database, "blocked")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(connector.release)
+	require.Eventually(t, func() bool {
+		_, ok := stmts.Lookup("blocked")
+		return ok
+	}, time.Second, time.Millisecond)
+}
+
+// BenchmarkPrepareCached measures the lookups of already prepared statements from
+// parallel callers, the path every store query takes after its first call.
+func BenchmarkPrepareCached(b *testing.B) {
+	database, _ := openPrepareFake(b)
+	var stmts Statements
+	defer stmts.Close()
+	ctx := context.Background()
+	if _, err := stmts.Prepare(ctx, database, "fast"); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := stmts.Prepare(ctx, database, "fast"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkPrepareCachedDuringSlowPrepare measures one lookup of an already prepared
+// statement while another query is being prepared with a one millisecond round trip, as
+// when a store meets a query for the first time on a busy database.
+func BenchmarkPrepareCachedDuringSlowPrepare(b *testing.B) {
+	database, connector := openPrepareFake(b)
+	connector.started = make(chan chan struct{})
+	var stmts Statements
+	defer stmts.Close()
+	ctx := context.Background()
+	if _, err := stmts.Prepare(ctx, database, "fast"); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			stmts.Prepare(ctx, database, fmt.Sprintf("slow %d", i))
+		}()
+		finish := <-connector.started
+
+		if _, err := stmts.Prepare(ctx, database, "fast"); err != nil {
+			b.Fatal(err)
+		}
+		close(finish)
+		<-done
+	}
+}
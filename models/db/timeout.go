@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// DefaultQueryTimeout is how long a statement may run when DB_QUERY_TIMEOUT is not set.
+const DefaultQueryTimeout = 30 * time.Second
+
+// noTimeoutKey marks a context whose statements are not bound by the query timeout.
+type noTimeoutKey struct{}
+
+// WithoutTimeout returns a context whose statements may run past the query timeout, for
+// work that is expected to take long, such as migrations and backups. Cancelling ctx still
+// cancels them.
+func WithoutTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTimeoutKey{}, true)
+}
+
+// TimeoutConnector opens connections that cancel any statement running longer than
+// Timeout, so a slow database fails requests instead of holding them, and their
+// connections, indefinitely. Each statement is bound on its own, including those run
+// inside a transaction; the rows of a query must be read within the same time. A zero
+// Timeout disables the limit.
+type TimeoutConnector struct {
+	driver.Connector
+	Timeout time.Duration
+}
+
+// Connect opens a connection with the wrapped connector.
+func (c *TimeoutConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &timeoutConn{Conn: conn, timeout: c.Timeout}, nil
+}
+
+// bound returns the context a statement runs with, and the function that releases it.
+func bound(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 || ctx.Value(noTimeoutKey{}) != nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// timedOut replaces the error of a statement cancelled by its deadline, which the driver
+// reports as a cancelled statement or a broken connection, with one that wraps
+// context.DeadlineExceeded.
+func timedOut(ctx context.Context, timeout time.Duration, err error) error {
+	if err != nil && err != io.EOF && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("database statement %w after %s: %v", context.DeadlineExceeded, timeout, err)
+	}
+	return err
+}
+
+// timeoutConn bounds the statements run on a driver connection.
+type timeoutConn struct {
+	driver.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel := bound(ctx, c.timeout)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		cancel()
+		return nil, timedOut(ctx, c.timeout, err)
+	}
+	return &timeoutRows{Rows: rows, ctx: ctx, cancel: cancel, timeout: c.timeout}, nil
+}
+
+func (c *timeoutConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel := bound(ctx, c.timeout)
+	defer cancel()
+	result, err := execer.ExecContext(ctx, query, args)
+	return result, timedOut(ctx, c.timeout, err)
+}
+
+func (c *timeoutConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	ctx, cancel := bound(ctx, c.timeout)
+	defer cancel()
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, timedOut(ctx, c.timeout, err)
+	}
+	return &timeoutStmt{Stmt: stmt, timeout: c.timeout}, nil
+}
+
+// BeginTx starts a transaction with ctx itself: the driver watches it until the
+// transaction ends, so it is the statements inside that are bound.
+func (c *timeoutConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts != (driver.TxOptions{}) {
+		return nil, fmt.Errorf("db: driver does not support transaction options")
+	}
+	return c.Conn.Begin()
+}
+
+func (c *timeoutConn) Ping(ctx context.Context) error {
+	pinger, ok := c.Conn.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := bound(ctx, c.timeout)
+	defer cancel()
+	return timedOut(ctx, c.timeout, pinger.Ping(ctx))
+}
+
+func (c *timeoutConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *timeoutConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// timeoutStmt bounds each run of a prepared statement.
+type timeoutStmt struct {
+	driver.Stmt
+	timeout time.Duration
+}
+
+func (s *timeoutStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := bound(ctx, s.timeout)
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	if err != nil {
+		cancel()
+		return nil, timedOut(ctx, s.timeout, err)
+	}
+	return &timeoutRows{Rows: rows, ctx: ctx, cancel: cancel, timeout: s.timeout}, nil
+}
+
+func (s *timeoutStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := bound(ctx, s.timeout)
+	defer cancel()
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err := execer.ExecContext(ctx, args)
+		return result, timedOut(ctx, s.timeout, err)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+// namedValues converts the arguments of a statement for drivers without context support,
+// which take them by position only.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("db: driver does not support named argument %s", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// timeoutRows releases the deadline of a query once its rows are closed.
+type timeoutRows struct {
+	driver.Rows
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+func (r *timeoutRows) Next(dest []driver.Value) error {
+	return timedOut(r.ctx, r.timeout, r.Rows.Next(dest))
+}
+
+func (r *timeoutRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+func (r *timeoutRows) HasNextResultSet() bool {
+	if sets, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return sets.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *timeoutRows) NextResultSet() error {
+	if sets, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return sets.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *timeoutRows) ColumnTypeScanType(index int) reflect.Type {
+	if types, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return types.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *timeoutRows) ColumnTypeDatabaseTypeName(index int) string {
+	if types, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return types.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *timeoutRows) ColumnTypeLength(index int) (int64, bool) {
+	if types, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return types.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *timeoutRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if types, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return types.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
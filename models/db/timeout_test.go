@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConnector opens fakeConns.
+type fakeConnector struct {
+	conn *fakeConn
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c *fakeConnector) Driver() driver.Driver                            { return nil }
+
+// fakeConn runs "slow" until its context is done, as a driver cancelling a statement
+// would, and answers every other statement at once. It records whether each statement's
+// context had a deadline.
+type fakeConn struct {
+	deadlines []bool
+}
+
+func (c *fakeConn) run(ctx context.Context, query string) error {
+	_, ok := ctx.Deadline()
+	c.deadlines = append(c.deadlines, ok)
+	if query == "slow" {
+		<-ctx.Done()
+		return errors.New("pq: canceling statement due to user request")
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.run(ctx, query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.run(ctx, query); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+// fakeRows holds a single row with the value 1.
+type fakeRows struct {
+	read bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.read {
+		return io.EOF
+	}
+	r.read = true
+	dest[0] = int64(1)
+	return nil
+}
+
+// openFake opens a database on a fakeConn whose statements time out after timeout.
+func openFake(t *testing.T, timeout time.Duration) (*sql.DB, *fakeConn) {
+	conn := &fakeConn{}
+	database := sql.OpenDB(&TimeoutConnector{Connector: &fakeConnector{conn: conn}, Timeout: timeout})
+	t.Cleanup(func() { database.Close() })
+	return database, conn
+}
+
+func TestTimeoutCancelsSlowStatements(t *testing.T) {
+	database, _ := openFake(t, 20*time.Millisecond)
+
+	_, err := database.ExecContext(context.Background(), "slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = database.QueryRowContext(context.Background(), "slow").Scan(new(int))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTimeoutLeavesFastStatements(t *testing.T) {
+	database, conn := openFake(t, time.Minute)
+
+	var value int
+	require.NoError(t, database.QueryRowContext(context.Background(), "fast").Scan(&value))
+	assert.Equal(t, 1, value)
+	_, err := database.ExecContext(context.Background(), "fast")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, true}, conn.deadlines, "Each statement gets a deadline")
+}
+
+func TestWithoutTimeout(t *testing.T) {
+	database, conn := openFake(t, time.Minute)
+
+	_, err := database.ExecContext(WithoutTimeout(context.Background()), "fast")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false}, conn.deadlines)
+
+	// A zero timeout disables the limit for every statement
+	database, conn = openFake(t, 0)
+	_, err = database.ExecContext(context.Background(), "fast")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false}, conn.deadlines)
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Delivery route statuses. A route is planned until the driver leaves with it, which sends
+// its shipments out for delivery, and completed once every stop is delivered or failed.
+const (
+	RoutePlanned    = "planned"
+	RouteDispatched = "dispatched"
+	RouteCompleted  = "completed"
+)
+
+// Delivery stop statuses
+const (
+	StopPending   = "pending"
+	StopDelivered = "delivered"
+	StopFailed    = "failed" // The shipment can be put on another route
+)
+
+// DeliveryRoute is one vehicle's local deliveries on a day: shipments without a carrier,
+// delivered by the company's own driver in the order of their stops.
+type DeliveryRoute struct {
+	ID             int            `json:"id"`
+	DeliveryDate   time.Time      `json:"delivery_date"`
+	Vehicle        string         `json:"vehicle"`
+	DriverEmail    string         `json:"driver_email"`
+	Status         string         `json:"status"`
+	DepotLatitude  *float64       `json:"depot_latitude,omitempty"` // Where the route starts, for sequencing
+	DepotLongitude *float64       `json:"depot_longitude,omitempty"`
+	Stops          []DeliveryStop `json:"stops"`
+	DistanceKm     float64        `json:"distance_km,omitempty"` // Straight-line length of the route through the stops with coordinates
+	CreatedBy      string         `json:"created_by"`
+	CreatedAt      time.Time      `json:"created_at"`
+	DispatchedAt   *time.Time     `json:"dispatched_at,omitempty"`
+	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+}
+
+// DeliveryStop is the delivery of one shipment on a route, with its proof of delivery.
+type DeliveryStop struct {
+	ID            int        `json:"id"`
+	RouteID       int        `json:"route_id"`
+	ShipmentID    int        `json:"shipment_id"`
+	SalesOrderID  *int       `json:"sales_order_id,omitempty"`
+	Sequence      int        `json:"sequence"` // Position on the route, from 1
+	Address       Address    `json:"address"`  // The shipment's destination
+	Latitude      *float64   `json:"latitude,omitempty"`
+	Longitude     *float64   `json:"longitude,omitempty"`
+	Status        string     `json:"status"`
+	RecipientName string     `json:"recipient_name,omitempty"`
+	SignatureKey  string     `json:"-"`
+	SignatureURL  string     `json:"signature_url,omitempty"`
+	PhotoKey      string     `json:"-"`
+	PhotoURL      string     `json:"photo_url,omitempty"`
+	Note          string     `json:"note,omitempty"`         // The driver's note, or why the delivery failed
+	CompletedAt   *time.Time `json:"completed_at,omitempty"` // When the driver delivered it or gave up, on the device
+	RecordedBy    string     `json:"recorded_by,omitempty"`  // Who recorded the outcome
+}
+
+// UnroutedShipment is a local shipment waiting for a route.
+type UnroutedShipment struct {
+	ShipmentID   int     `json:"shipment_id"`
+	SalesOrderID *int    `json:"sales_order_id,omitempty"`
+	Status       string  `json:"status"` // pending, or exception after a failed delivery
+	Address      Address `json:"address"`
+	WeightKg     float64 `json:"weight_kg"`
+}
+
+// DeliveryRouteStore defines an interface for delivery route database operations. Changes
+// to a route's stops are only made while it is planned, and return a conflict otherwise.
+type DeliveryRouteStore interface {
+	// CreateDeliveryRoute records a planned route with its stops, in the order of their
+	// Sequence. It returns a validation error if a shipment does not exist or is not a local
+	// shipment waiting for delivery, and a conflict if one is on another route.
+	CreateDeliveryRoute(ctx context.Context, route *DeliveryRoute) error
+	GetDeliveryRoute(ctx context.Context, id int) (*DeliveryRoute, error)
+	// ListDeliveryRoutes returns the routes of a day, of one driver, or both; zero values
+	// match everything.
+	ListDeliveryRoutes(ctx context.Context, date time.Time, driverEmail string) ([]DeliveryRoute, error)
+	DeleteDeliveryRoute(ctx context.Context, id int) error
+	// AddDeliveryStop adds a stop at the end of a route, with the same checks as
+	// CreateDeliveryRoute.
+	AddDeliveryStop(ctx context.Context, stop *DeliveryStop) error
+	RemoveDeliveryStop(ctx context.Context, routeID, stopID int) error
+	// SequenceDeliveryStops numbers a route's stops in the order given, which must list
+	// each of them once.
+	SequenceDeliveryStops(ctx context.Context, routeID int, stopIDs []int) error
+	// DispatchDeliveryRoute sends a planned route's shipments out for delivery.
+	DispatchDeliveryRoute(ctx context.Context, id int, at time.Time) (*DeliveryRoute, error)
+	// CompleteDeliveryStop records the outcome of a pending stop on a dispatched route,
+	// moves its shipment to delivered or exception, and completes the route once no stop is
+	// pending.
+	CompleteDeliveryStop(ctx context.Context, stop *DeliveryStop) error
+	// UnroutedShipments returns the local shipments that are not on a route.
+	UnroutedShipments(ctx context.Context) ([]UnroutedShipment, error)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Dispute statuses
+const (
+	DisputeOpen     = "open"
+	DisputeResolved = "resolved"
+)
+
+// Dispute resolutions: how a dispute was settled.
+const (
+	ResolutionCreditNote = "credit_note" // A credit note is issued to the customer for the amount allowed
+	ResolutionAdjustment = "adjustment"  // The receivable is written down by the amount allowed
+	ResolutionUphold     = "uphold"      // The charge stands and the invoice is owed in full
+)
+
+// InvoiceDispute is a customer's challenge to charges on a posted invoice. While it is open,
+// no late fees are charged and no installment reminders are sent for the invoice.
+type InvoiceDispute struct {
+	ID             int        `json:"id"`
+	InvoiceID      int        `json:"invoice_id"`
+	CustomerID     int        `json:"customer_id"`
+	Reason         string     `json:"reason"`
+	Amount         float64    `json:"amount"` // Amount the customer disputes
+	Status         string     `json:"status"`
+	Resolution     string     `json:"resolution,omitempty"`
+	ResolvedAmount float64    `json:"resolved_amount"` // Amount credited or written down; 0 if upheld
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	CreditNoteID   *int       `json:"credit_note_id,omitempty"`
+	RaisedBy       string     `json:"raised_by,omitempty"`
+	RaisedAt       time.Time  `json:"raised_at"`
+	ResolvedBy     string     `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+// CreditNote reduces what a customer owes on an invoice.
+type CreditNote struct {
+	ID         int       `json:"id"`
+	InvoiceID  int       `json:"invoice_id"`
+	CustomerID int       `json:"customer_id"`
+	DisputeID  int       `json:"dispute_id"`
+	Amount     float64   `json:"amount"`
+	Reason     string    `json:"reason"`
+	IssuedBy   string    `json:"issued_by"`
+	IssuedAt   time.Time `json:"issued_at"`
+}
+
+// DisputeAgingBucket totals the open disputes of an age range.
+type DisputeAgingBucket struct {
+	Bucket   string  `json:"bucket"` // e.g. "0-30" days since the dispute was raised
+	Disputes int     `json:"disputes"`
+	Amount   float64 `json:"amount"`
+}
+
+// DisputeAging reports how long open disputes have been waiting for resolution.
+type DisputeAging struct {
+	AsOf     time.Time            `json:"as_of"`
+	Buckets  []DisputeAgingBucket `json:"buckets"`
+	Disputes int                  `json:"disputes"`
+	Amount   float64              `json:"amount"`
+}
+
+// DisputeStore defines an interface for invoice dispute database operations
+type DisputeStore interface {
+	// GetInvoiceBalance returns models.ErrNotFound if the invoice does not exist. Settled
+	// includes the amounts allowed on resolved disputes.
+	GetInvoiceBalance(ctx context.Context, invoiceID int) (*InvoiceBalance, error)
+	// CreateDispute returns a conflict if the invoice already has an open dispute.
+	CreateDispute(ctx context.Context, dispute *InvoiceDispute) error
+	GetDispute(ctx context.Context, id int) (*InvoiceDispute, error)
+	// GetDisputes lists disputes, newest first. invoiceID 0 and status "" match any.
+	GetDisputes(ctx context.Context, invoiceID int, status string) ([]InvoiceDispute, error)
+	// ResolveDispute records the resolution of an open dispute and, for credit notes and
+	// adjustments, posts the amount allowed. It returns a conflict if the dispute is not open.
+	ResolveDispute(ctx context.Context, dispute *InvoiceDispute) error
+	GetCreditNote(ctx context.Context, id int) (*CreditNote, error)
+}
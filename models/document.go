@@ -0,0 +1,24 @@
+package models
+
+// Document lifecycle statuses shared by invoices and journal entries. Drafts are editable
+// and have no ledger effect; posting validates the document, creates its ledger
+// transactions and locks it.
+const (
+	StatusDraft  = "draft"
+	StatusPosted = "posted"
+)
+
+// ErrDocumentLocked is returned when a change is attempted on a document that is no longer a draft.
+var ErrDocumentLocked = Conflict("document is not a draft and can no longer be changed")
+
+// PostingError is returned when a draft fails validation and cannot be posted.
+type PostingError struct {
+	Reason string
+}
+
+func (e *PostingError) Error() string {
+	return "cannot post document: " + e.Reason
+}
+
+// Unwrap makes posting errors validation errors.
+func (e *PostingError) Unwrap() error { return ErrValidation }
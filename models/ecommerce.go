@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Payment statuses reported by the e-commerce site
+const (
+	ExternalPaymentPending  = "pending"
+	ExternalPaymentPaid     = "paid"
+	ExternalPaymentRefunded = "refunded"
+)
+
+// ExternalOrder is an order placed on the e-commerce site.
+type ExternalOrder struct {
+	ExternalOrderID string              `json:"external_order_id"`
+	Customer        ExternalCustomer    `json:"customer"`
+	Lines           []ExternalOrderLine `json:"lines"`
+	PaymentStatus   string              `json:"payment_status"`
+	OrderedAt       time.Time           `json:"ordered_at"`
+}
+
+// ExternalCustomer identifies the buyer. Customers are matched by email.
+type ExternalCustomer struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// ExternalOrderLine is one product on an external order.
+type ExternalOrderLine struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// IngestedOrder maps an external order to the records created for it.
+type IngestedOrder struct {
+	ExternalOrderID string         `json:"external_order_id"`
+	CustomerID      int            `json:"customer_id"`
+	CustomerCreated bool           `json:"customer_created"`
+	PaymentStatus   string         `json:"payment_status"`
+	Lines           []IngestedLine `json:"lines"`
+}
+
+// IngestedLine maps one external order line to its sales order and stock reservation.
+type IngestedLine struct {
+	ProductID    int `json:"product_id"`
+	SalesOrderID int `json:"sales_order_id"`
+	Reserved     int `json:"reserved"`    // Quantity reserved from available stock
+	Backordered  int `json:"backordered"` // Quantity that could not be reserved
+}
+
+// EcommerceOrderStore defines an interface for ingesting external orders
+type EcommerceOrderStore interface {
+	// IngestOrder records an external order once per source and external order ID. When the
+	// order was already ingested, the original mapping is returned and created is false.
+	IngestOrder(ctx context.Context, sourceID int, order *ExternalOrder) (result *IngestedOrder, created bool, err error)
+}
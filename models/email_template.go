@@ -0,0 +1,37 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// EmailTemplate is one version of the subject and body of an email in one locale. Text
+// between {{ and }} is replaced by the value of that variable when the email is rendered.
+// Versions are never changed; editing a template adds a version, and the newest version
+// is the one sent.
+type EmailTemplate struct {
+	ID        int       `json:"id"`
+	Key       string    `json:"key"`    // e.g. "payment_reminder"
+	Locale    string    `json:"locale"` // e.g. "en" or "fr-CA"
+	Version   int       `json:"version"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	HTML      bool      `json:"html"`
+	Variables []string  `json:"variables"`      // The variables the subject and body may use
+	Note      string    `json:"note,omitempty"` // Why the version was made
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EmailTemplateStore defines an interface for storing email template versions.
+type EmailTemplateStore interface {
+	// ListCurrentTemplates returns the newest version of every template and locale.
+	ListCurrentTemplates(ctx context.Context) ([]EmailTemplate, error)
+	// GetTemplate returns a version of a template, or the newest one if version is 0.
+	GetTemplate(ctx context.Context, key, locale string, version int) (*EmailTemplate, error)
+	// ListTemplateVersions returns every version of a template, newest first.
+	ListTemplateVersions(ctx context.Context, key, locale string) ([]EmailTemplate, error)
+	// AddTemplateVersion stores the template as the next version of its key and locale and
+	// sets its ID, Version and CreatedAt.
+	AddTemplateVersion(ctx context.Context, template *EmailTemplate) error
+}
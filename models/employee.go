@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Employee is the HR profile of a person employed by the organization, kept apart from the
+// user account they sign in with. Attendance and leave are recorded against the account, so
+// only users with a profile can have them.
+type Employee struct {
+	ID          int       `json:"id"`
+	UserID      *int      `json:"user_id"` // Sign-in account, if the employee has one
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Designation string    `json:"designation"` // Job title, e.g. "Warehouse Supervisor"
+	SalaryGrade string    `json:"salary_grade,omitempty"`
+	Department  string    `json:"department,omitempty"`
+	ManagerID   *int      `json:"manager_id"` // Employee they report to
+	HireDate    time.Time `json:"hire_date"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"` // Incremented by every update; an update names the version it changes
+}
+
+// EmployeeStore defines the operations on employee profiles.
+type EmployeeStore interface {
+	// CreateEmployee returns a conflict if the user or email already has a profile, or a
+	// validation error if the user or manager does not exist.
+	CreateEmployee(ctx context.Context, employee *Employee) error
+	// GetEmployee returns a not found error if there is no such employee.
+	GetEmployee(ctx context.Context, id int) (*Employee, error)
+	// UpdateEmployee changes a profile if it is still at employee.Version and sets the new
+	// version. It returns a not found error if there is no such employee, a conflict if it
+	// was changed since, or the errors of CreateEmployee.
+	UpdateEmployee(ctx context.Context, employee *Employee) error
+	// DeleteEmployee removes a profile; the employees reporting to it are left without a
+	// manager.
+	DeleteEmployee(ctx context.Context, id int) error
+	// ListEmployees returns a page of the employees matching the query's filters, with the
+	// number of matches.
+	ListEmployees(ctx context.Context, query ListQuery) ([]Employee, int, error)
+	// SearchEmployees returns up to limit employees whose name, email or designation
+	// contains text, ignoring case.
+	SearchEmployees(ctx context.Context, text string, limit int) ([]Employee, error)
+}
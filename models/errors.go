@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of domain errors. Stores and services return errors that wrap one of these, so
+// handlers can tell them apart with errors.Is and answer with the matching HTTP status
+// (see the httperr package) without knowing which store produced them.
+var (
+	// ErrNotFound is returned when a record does not exist.
+	ErrNotFound = errors.New("resource not found")
+	// ErrConflict is returned when a change clashes with the current state of a record,
+	// e.g. a duplicate key or a document that is no longer a draft.
+	ErrConflict = errors.New("conflict with the current state")
+	// ErrValidation is returned when well-formed input breaks a business rule.
+	ErrValidation = errors.New("validation failed")
+	// ErrPermissionDenied is returned when the caller may not perform an operation.
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// domainError is an error of one of the kinds above with a message for the client.
+type domainError struct {
+	kind    error
+	message string
+}
+
+func (e *domainError) Error() string { return e.message }
+
+func (e *domainError) Unwrap() error { return e.kind }
+
+// NotFound returns an error wrapping ErrNotFound, e.g. NotFound("invoice %d not found", id).
+func NotFound(format string, args ...interface{}) error {
+	return &domainError{kind: ErrNotFound, message: fmt.Sprintf(format, args...)}
+}
+
+// Conflict returns an error wrapping ErrConflict.
+func Conflict(format string, args ...interface{}) error {
+	return &domainError{kind: ErrConflict, message: fmt.Sprintf(format, args...)}
+}
+
+// Invalid returns an error wrapping ErrValidation.
+func Invalid(format string, args ...interface{}) error {
+	return &domainError{kind: ErrValidation, message: fmt.Sprintf(format, args...)}
+}
+
+// PermissionDenied returns an error wrapping ErrPermissionDenied.
+func PermissionDenied(format string, args ...interface{}) error {
+	return &domainError{kind: ErrPermissionDenied, message: fmt.Sprintf(format, args...)}
+}
+
+// Kind returns the kind of a domain error: ErrNotFound, ErrConflict, ErrValidation or
+// ErrPermissionDenied, or nil if err is not a domain error.
+func Kind(err error) error {
+	for _, kind := range []error{ErrNotFound, ErrConflict, ErrValidation, ErrPermissionDenied} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
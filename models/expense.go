@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Expense claim statuses. A submitted claim is approved or rejected by an approver; an
+// approved claim is reimbursed unless it has hard policy violations that were not overridden.
+const (
+	ExpenseSubmitted  = "submitted"
+	ExpenseApproved   = "approved"
+	ExpenseRejected   = "rejected"
+	ExpenseReimbursed = "reimbursed"
+)
+
+// Severities of expense policy violations.
+const (
+	ViolationSoft = "soft" // Flagged for the approver
+	ViolationHard = "hard" // Blocks reimbursement unless overridden
+)
+
+// Kinds of expense items. The amount of mileage and per-diem items is computed from the
+// rate tables when the claim is submitted.
+const (
+	ExpenseItemStandard = ""         // Amount as paid, backed by a receipt
+	ExpenseItemMileage  = "mileage"  // Distance driven × the rate of the vehicle type
+	ExpenseItemPerDiem  = "per_diem" // Days away × the rate of the destination
+)
+
+// ExpensePolicy sets the limits of an expense category. A zero amount disables that check.
+type ExpensePolicy struct {
+	Category         string    `json:"category"`
+	Limit            float64   `json:"limit"`             // Amount per item above which the item is flagged
+	HardLimit        float64   `json:"hard_limit"`        // Amount per item above which reimbursement is blocked
+	ReceiptThreshold float64   `json:"receipt_threshold"` // Amount per item above which a receipt is required
+	PerDiemRate      float64   `json:"per_diem_rate"`     // Daily allowance for items claimed by the day
+	UpdatedBy        string    `json:"updated_by,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ExpenseItem is one expense of a claim.
+type ExpenseItem struct {
+	Kind        string    `json:"kind,omitempty"` // One of the ExpenseItem* kinds
+	Category    string    `json:"category"`
+	Description string    `json:"description"`
+	Date        time.Time `json:"date"`
+	Amount      float64   `json:"amount"`
+	Days        int       `json:"days,omitempty"`         // Days covered, for per-diem items
+	Receipt     string    `json:"receipt,omitempty"`      // Reference to the receipt, such as an attachment URL
+	VehicleType string    `json:"vehicle_type,omitempty"` // For mileage items
+	Distance    float64   `json:"distance,omitempty"`     // Kilometres driven, for mileage items
+	Destination string    `json:"destination,omitempty"`  // For per-diem items
+	Rate        float64   `json:"rate,omitempty"`         // Rate the amount was computed from
+}
+
+// MileageRate is the amount reimbursed per kilometre driven in a type of vehicle.
+type MileageRate struct {
+	VehicleType string    `json:"vehicle_type"`
+	Rate        float64   `json:"rate"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PerDiemRate is the daily allowance for travel to a destination. Long trips can get a
+// lower rate from a number of days on. The destination "default" applies to destinations
+// without a rate of their own.
+type PerDiemRate struct {
+	Destination   string    `json:"destination"`
+	DailyRate     float64   `json:"daily_rate"`
+	LongStayRate  float64   `json:"long_stay_rate"`  // Daily rate from day LongStayAfter + 1 on
+	LongStayAfter int       `json:"long_stay_after"` // Days at the daily rate; 0 if there is no long-stay rate
+	UpdatedBy     string    `json:"updated_by,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ExpenseViolation is a breach of an expense policy found when a claim is submitted.
+type ExpenseViolation struct {
+	Item     int    `json:"item"` // Position of the item in the claim, from 1
+	Rule     string `json:"rule"` // limit, hard_limit, receipt, per_diem or category
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// ExpenseClaim is an employee's request to be reimbursed for expenses paid on the
+// company's behalf.
+type ExpenseClaim struct {
+	ID             int                `json:"id"`
+	Employee       string             `json:"employee"` // Email of the claimant
+	Purpose        string             `json:"purpose"`
+	Status         string             `json:"status"`
+	Items          []ExpenseItem      `json:"items"`
+	Total          float64            `json:"total"`
+	Violations     []ExpenseViolation `json:"violations"`
+	SubmittedAt    time.Time          `json:"submitted_at"`
+	ReviewedBy     *string            `json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time         `json:"reviewed_at,omitempty"`
+	ReviewNote     string             `json:"review_note,omitempty"`
+	OverriddenBy   *string            `json:"overridden_by,omitempty"`
+	OverriddenAt   *time.Time         `json:"overridden_at,omitempty"`
+	OverrideReason string             `json:"override_reason,omitempty"`
+	ReimbursedBy   *string            `json:"reimbursed_by,omitempty"`
+	ReimbursedAt   *time.Time         `json:"reimbursed_at,omitempty"`
+}
+
+// HardViolations reports whether the claim breaks a policy in a way that blocks reimbursement.
+func (c *ExpenseClaim) HardViolations() bool {
+	for _, violation := range c.Violations {
+		if violation.Severity == ViolationHard {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocked reports whether the claim has hard violations that have not been overridden.
+func (c *ExpenseClaim) Blocked() bool {
+	return c.HardViolations() && c.OverriddenBy == nil
+}
+
+// ExpenseStore defines an interface for expense database operations
+type ExpenseStore interface {
+	GetExpensePolicies(ctx context.Context) ([]ExpensePolicy, error)
+	// SetExpensePolicy creates or replaces a category's policy and records it in the audit log.
+	SetExpensePolicy(ctx context.Context, policy *ExpensePolicy) error
+	CreateExpenseClaim(ctx context.Context, claim *ExpenseClaim) error
+	GetExpenseClaim(ctx context.Context, id int) (*ExpenseClaim, error)
+	// GetExpenseClaims lists claims, newest first; empty filters match every claim.
+	GetExpenseClaims(ctx context.Context, employee, status string) ([]ExpenseClaim, error)
+	// UpdateExpenseClaim saves a review or override of a claim still in status from.
+	UpdateExpenseClaim(ctx context.Context, claim *ExpenseClaim, from string) error
+	// ReimburseExpenseClaim marks an approved claim reimbursed and posts it to the ledger.
+	ReimburseExpenseClaim(ctx context.Context, claim *ExpenseClaim) error
+	GetMileageRates(ctx context.Context) ([]MileageRate, error)
+	SetMileageRate(ctx context.Context, rate *MileageRate) error
+	DeleteMileageRate(ctx context.Context, vehicleType string) error
+	GetPerDiemRates(ctx context.Context) ([]PerDiemRate, error)
+	SetPerDiemRate(ctx context.Context, rate *PerDiemRate) error
+	DeletePerDiemRate(ctx context.Context, destination string) error
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ExportWatermark is the high-water mark of a table in the data warehouse export: the
+// newest row, by updated_at and then id, that has been written to an extract. The next
+// export picks up the rows changed after it.
+type ExportWatermark struct {
+	Table         string    `json:"table"`
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+	LastID        int       `json:"last_id"`
+	RowsExported  int64     `json:"rows_exported"` // Total over all runs
+	ExportedAt    time.Time `json:"exported_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// FeatureFlag switches a behavior on for everyone or for targeted roles and users.
+type FeatureFlag struct {
+	Key         string    `json:"key"`
+	Description string    `json:"description"`
+	Enabled     bool      `json:"enabled"`         // Master switch; a disabled flag is off for everyone
+	Roles       []string  `json:"roles,omitempty"` // Roles the flag is limited to; empty means all roles
+	Users       []string  `json:"users,omitempty"` // Emails of users who get the flag regardless of role
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EnabledFor reports whether the flag is on for a user with the given role and email.
+// An enabled flag without targets is on for everyone, including anonymous requests.
+func (f *FeatureFlag) EnabledFor(role, email string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if len(f.Roles) == 0 && len(f.Users) == 0 {
+		return true
+	}
+	for _, r := range f.Roles {
+		if role != "" && strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	for _, u := range f.Users {
+		if email != "" && strings.EqualFold(u, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// FeatureFlagStore defines an interface for feature flag-related database operations
+type FeatureFlagStore interface {
+	ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error)
+	// SaveFeatureFlag creates or replaces the flag with the same key.
+	SaveFeatureFlag(ctx context.Context, flag *FeatureFlag) error
+	// DeleteFeatureFlag removes a stored flag. It returns ErrNotFound if none is stored.
+	DeleteFeatureFlag(ctx context.Context, key string) error
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Final settlement statuses. A draft is recalculated as offboarding goes on; once approved
+// it is posted to the ledger and cannot change.
+const (
+	SettlementDraft     = "draft"
+	SettlementApproved  = "approved"
+	SettlementCancelled = "cancelled"
+)
+
+// FinalSettlement is what is owed to, or by, an employee who leaves: the salary not yet
+// paid, encashed leave, less outstanding loans and advances and the value of company
+// assets not returned.
+type FinalSettlement struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id"`
+	Employee       string    `json:"employee,omitempty"`
+	Email          string    `json:"email,omitempty"`
+	LastWorkingDay time.Time `json:"last_working_day"`
+	PaidThrough    time.Time `json:"paid_through"` // Last day salary has been paid for
+	MonthlySalary  float64   `json:"monthly_salary"`
+	DailyRate      float64   `json:"daily_rate"` // Monthly salary × 12 / 365
+
+	UnpaidDays      int                  `json:"unpaid_days"`
+	SalaryDue       float64              `json:"salary_due"`
+	Leave           []SettlementLeave    `json:"leave"`
+	LeaveEncashment float64              `json:"leave_encashment"`
+	Recoveries      []SettlementRecovery `json:"recoveries"` // Outstanding loans and advances
+	Assets          []SettlementAsset    `json:"assets"`
+	AssetRecovery   float64              `json:"asset_recovery"` // Value of the assets not returned
+	Gross           float64              `json:"gross"`          // Salary due and leave encashment
+	Deductions      float64              `json:"deductions"`     // Recoveries and asset recovery
+	Net             float64              `json:"net"`            // Negative when the employee owes the company
+
+	Status     string         `json:"status"`
+	Interview  *ExitInterview `json:"exit_interview,omitempty"`
+	CreatedBy  string         `json:"created_by"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ApprovedBy string         `json:"approved_by,omitempty"`
+	ApprovedAt *time.Time     `json:"approved_at,omitempty"`
+}
+
+// SettlementLeave is the encashable balance of a leave type at the last working day.
+type SettlementLeave struct {
+	LeaveType string  `json:"leave_type"`
+	Days      float64 `json:"days"` // Entitlement earned up to the last working day, plus adjustments, less leave taken
+	Amount    float64 `json:"amount"`
+}
+
+// SettlementRecovery is a loan or advance still owed by the employee.
+type SettlementRecovery struct {
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+// SettlementAsset is a company asset issued to the employee. Its value is recovered unless
+// it is returned.
+type SettlementAsset struct {
+	Description string  `json:"description"` // e.g. "Laptop, tag 0042"
+	Value       float64 `json:"value"`
+	Returned    bool    `json:"returned"`
+}
+
+// ExitInterview is what a leaving employee said at their exit interview.
+type ExitInterview struct {
+	Reason      string    `json:"reason"` // e.g. "career growth", "relocation"
+	Feedback    string    `json:"feedback"`
+	WouldRejoin bool      `json:"would_rejoin"`
+	ConductedBy string    `json:"conducted_by"`
+	ConductedAt time.Time `json:"conducted_at"`
+}
+
+// FinalSettlementStore defines the operations for final settlements.
+type FinalSettlementStore interface {
+	// CreateFinalSettlement returns a conflict if the employee already has a settlement
+	// that is not cancelled.
+	CreateFinalSettlement(ctx context.Context, settlement *FinalSettlement) error
+	GetFinalSettlement(ctx context.Context, id int) (*FinalSettlement, error)
+	// ListFinalSettlements returns the settlements in a status, or all of them if status is
+	// empty, newest first.
+	ListFinalSettlements(ctx context.Context, status string) ([]FinalSettlement, error)
+	// UpdateFinalSettlement saves a recalculated draft.
+	UpdateFinalSettlement(ctx context.Context, settlement *FinalSettlement) error
+	SaveExitInterview(ctx context.Context, id int, interview *ExitInterview) error
+	// ApproveFinalSettlement approves a draft and posts it to the ledger.
+	ApproveFinalSettlement(ctx context.Context, id int, actor string) (*FinalSettlement, error)
+	CancelFinalSettlement(ctx context.Context, id int, actor string) (*FinalSettlement, error)
+}
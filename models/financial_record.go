@@ -1,7 +1,10 @@
 // Package models defines the structure for financial records and their store interface.
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // FinancialRecord represents a financial record.
 type FinancialRecord struct {
@@ -12,12 +15,17 @@ type FinancialRecord struct {
 	TransactionDate time.Time `json:"transaction_date"`
 	TransactionType string    `json:"transaction_type"`
 	Description     string    `json:"description"`
+	Version         int       `json:"version"` // Incremented by every update; an update names the version it changes
 }
 
 // FinancialRecordStore is an interface that defines CRUD operations for financial records.
 type FinancialRecordStore interface {
-	CreateFinancialRecord(record *FinancialRecord) error
-	GetFinancialRecordByID(id int) (*FinancialRecord, error)
-	UpdateFinancialRecord(record *FinancialRecord) error
-	DeleteFinancialRecord(id int) error
+	CreateFinancialRecord(ctx context.Context, record *FinancialRecord) error
+	GetFinancialRecordByID(ctx context.Context, id int) (*FinancialRecord, error)
+	// UpdateFinancialRecord changes a record if it is still at record.Version, and sets the
+	// new version. It returns a conflict if the record was changed since.
+	UpdateFinancialRecord(ctx context.Context, record *FinancialRecord) error
+	DeleteFinancialRecord(ctx context.Context, id int) error
+	// ListFinancialRecords returns a page of financial records and the number matching the filters.
+	ListFinancialRecords(ctx context.Context, query ListQuery) ([]FinancialRecord, int, error)
 }
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // FinancialTransaction represents a financial transaction in the system
 type FinancialTransaction struct {
@@ -9,12 +12,24 @@ type FinancialTransaction struct {
 	Amount          float64   `json:"amount"`
 	TransactionDate time.Time `json:"transaction_date"`
 	Description     string    `json:"description"`
+	InvoiceID       *int      `json:"invoice_id,omitempty"`       // Set when posted from an invoice
+	JournalEntryID  *int      `json:"journal_entry_id,omitempty"` // Set when posted from a journal entry
+	Version         int       `json:"version"`                    // Incremented by every update; an update names the version it changes
 }
 
 // FinancialTransactionStore defines an interface for financial transaction-related database operations
 type FinancialTransactionStore interface {
-	CreateTransaction(transaction *FinancialTransaction) error
-	GetTransactionByID(id int) (*FinancialTransaction, error)
-	UpdateTransaction(transaction *FinancialTransaction) error
-	DeleteTransaction(id int) error
+	CreateTransaction(ctx context.Context, transaction *FinancialTransaction) error
+	GetTransactionByID(ctx context.Context, id int) (*FinancialTransaction, error)
+	// UpdateTransaction changes a transaction if it is still at transaction.Version, and sets
+	// the new version.
+	UpdateTransaction(ctx context.Context, transaction *FinancialTransaction) error
+	DeleteTransaction(ctx context.Context, id int) error
+}
+
+// LedgerBatchStore defines an interface for posting many financial transactions at once
+type LedgerBatchStore interface {
+	// PostBatch records all transactions or none, calling progress with the size of each
+	// chunk once it is written.
+	PostBatch(ctx context.Context, transactions []FinancialTransaction, progress func(written int)) error
 }
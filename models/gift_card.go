@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Kinds of gift cards
+const (
+	GiftCardSold   = "gift_card"    // Bought by a customer; the sale is paid in cash
+	GiftCardCredit = "store_credit" // Issued to a customer, e.g. for a return
+)
+
+// PaymentMethodGiftCard is the payment method of payments made from a gift card balance.
+const PaymentMethodGiftCard = "gift_card"
+
+// Kinds of gift card transactions
+const (
+	GiftCardIssue  = "issue"  // The initial balance
+	GiftCardRedeem = "redeem" // Balance applied to an invoice payment
+	GiftCardExpiry = "expiry" // Balance left when the card expired
+)
+
+// Errors returned when a gift card cannot pay for an invoice
+var (
+	ErrGiftCardExpired       = Conflict("the gift card has expired")
+	ErrInsufficientGiftCard  = Conflict("the gift card balance is too low")
+	ErrGiftCardCodeCollision = Conflict("the gift card code is already in use")
+)
+
+// GiftCard is a gift card or store credit. Its balance is a liability until it is
+// spent on invoices or expires.
+type GiftCard struct {
+	ID            int                   `json:"id"`
+	Code          string                `json:"code"`
+	Kind          string                `json:"kind"`
+	CustomerID    *int                  `json:"customer_id,omitempty"` // Required for store credit
+	InitialAmount float64               `json:"initial_amount"`
+	Balance       float64               `json:"balance"`
+	ExpiresAt     *time.Time            `json:"expires_at,omitempty"`
+	IssuedAt      time.Time             `json:"issued_at"`
+	Transactions  []GiftCardTransaction `json:"transactions,omitempty"` // Newest first
+}
+
+// GiftCardTransaction is one change to a gift card's balance.
+type GiftCardTransaction struct {
+	ID         int       `json:"id"`
+	GiftCardID int       `json:"gift_card_id"`
+	Kind       string    `json:"kind"`
+	Amount     float64   `json:"amount"` // Negative for redemptions and expiries
+	InvoiceID  *int      `json:"invoice_id,omitempty"`
+	PaymentID  *int      `json:"payment_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// GiftCardStore defines an interface for gift card database operations
+type GiftCardStore interface {
+	// IssueGiftCard stores a new card with its issue transaction and posts the liability to
+	// the ledger. It returns ErrGiftCardCodeCollision if the code is taken.
+	IssueGiftCard(ctx context.Context, card *GiftCard) error
+	// GetGiftCardByCode returns a card with its transactions.
+	GetGiftCardByCode(ctx context.Context, code string) (*GiftCard, error)
+	// GetGiftCardsByCustomer returns the cards issued to a customer, newest first.
+	GetGiftCardsByCustomer(ctx context.Context, customerID int) ([]GiftCard, error)
+	// ApplyGiftCard pays amount of a posted invoice from a card's balance. The payment, the
+	// redemption and the ledger lines are recorded in one transaction.
+	ApplyGiftCard(ctx context.Context, code string, invoiceID int, amount float64, now time.Time) (*Payment, error)
+	// ExpireGiftCards clears the balance of every card that expired at or before now and
+	// returns the amount expired.
+	ExpireGiftCards(ctx context.Context, now time.Time) (float64, error)
+}
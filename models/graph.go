@@ -0,0 +1,39 @@
+package models
+
+import "context"
+
+// CustomerGraphStore defines the batched customer reads behind the GraphQL endpoint. The
+// customer store implements it.
+type CustomerGraphStore interface {
+	// CustomersByID returns the customers with the given IDs; missing IDs are left out.
+	CustomersByID(ctx context.Context, ids []int) (map[int]*Customer, error)
+	// CustomersPage returns limit customers ordered by ID, after skipping offset.
+	CustomersPage(ctx context.Context, limit, offset int) ([]Customer, error)
+}
+
+// InvoiceGraphStore defines the batched invoice reads behind the GraphQL endpoint. The
+// invoice store implements it.
+type InvoiceGraphStore interface {
+	// InvoicesByID returns the invoices with the given IDs; missing IDs are left out.
+	InvoicesByID(ctx context.Context, ids []int) (map[int]*Invoice, error)
+	// InvoicesByCustomer returns the invoices of the given customers by customer ID,
+	// ordered by ID. An empty status returns invoices of every status.
+	InvoicesByCustomer(ctx context.Context, customerIDs []int, status string) (map[int][]Invoice, error)
+}
+
+// PaymentGraphStore defines the batched payment reads behind the GraphQL endpoint. The
+// payment store implements it.
+type PaymentGraphStore interface {
+	// PaymentsByInvoice returns the payments of the given invoices by invoice ID, oldest
+	// first.
+	PaymentsByInvoice(ctx context.Context, invoiceIDs []int) (map[int][]Payment, error)
+}
+
+// GraphStore defines the batched reads behind the GraphQL endpoint. Each method loads the
+// records for many keys in one query, so a nested query costs one call per level. Deleted
+// records are left out.
+type GraphStore interface {
+	CustomerGraphStore
+	InvoiceGraphStore
+	PaymentGraphStore
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// HR case types
+const (
+	HRCaseDisciplinary = "disciplinary" // Raised by HR about an employee's conduct
+	HRCaseGrievance    = "grievance"    // Raised by an employee about how they were treated
+)
+
+// HR case statuses. A case moves from open to investigating to resolved, and is closed once
+// any appeal is over; a resolved case may be reopened for investigation, a closed one may not.
+const (
+	HRCaseOpen          = "open"
+	HRCaseInvestigating = "investigating"
+	HRCaseResolved      = "resolved"
+	HRCaseClosed        = "closed"
+)
+
+// Roles of the parties to an HR case
+const (
+	HRPartySubject        = "subject"     // The employee whose conduct is in question
+	HRPartyComplainant    = "complainant" // The employee who raised the grievance or complaint
+	HRPartyWitness        = "witness"
+	HRPartyRepresentative = "representative" // A colleague or union representative accompanying a party
+)
+
+// Actions recorded in the history of an HR case
+const (
+	HRCaseOpened          = "opened"
+	HRCasePartyAdded      = "party_added"
+	HRCaseNoteAdded       = "note_added"
+	HRCaseAttachmentAdded = "attachment_added"
+	HRCaseStatusChanged   = "status_changed"
+)
+
+// HRCase is a confidential disciplinary or grievance case. Only HR can see it, and HR staff
+// who are a party to it cannot. Notes, attachments and the history are append-only.
+type HRCase struct {
+	ID          int                `json:"id"`
+	Type        string             `json:"type"`
+	Summary     string             `json:"summary"`
+	Status      string             `json:"status"`
+	Outcome     string             `json:"outcome,omitempty"` // Set when the case is resolved
+	Parties     []HRCaseParty      `json:"parties"`
+	Notes       []HRCaseNote       `json:"notes,omitempty"`
+	Attachments []HRCaseAttachment `json:"attachments,omitempty"`
+	History     []HRCaseEvent      `json:"history,omitempty"`
+	OpenedBy    string             `json:"opened_by"`
+	OpenedAt    time.Time          `json:"opened_at"`
+	ResolvedAt  *time.Time         `json:"resolved_at,omitempty"`
+	ClosedAt    *time.Time         `json:"closed_at,omitempty"`
+}
+
+// HRCaseParty is an employee involved in an HR case.
+type HRCaseParty struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Role   string `json:"role"`
+}
+
+// HRCaseNote is a note on an HR case, such as a summary of an interview. Notes cannot be
+// changed or deleted; a correction is a new note.
+type HRCaseNote struct {
+	ID        int       `json:"id"`
+	Body      string    `json:"body"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HRCaseAttachment is a document filed with an HR case. It is kept in private storage and
+// only downloaded through the case.
+type HRCaseAttachment struct {
+	ID          int       `json:"id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int       `json:"size_bytes"`
+	StorageKey  string    `json:"-"`
+	UploadedBy  string    `json:"uploaded_by"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// HRCaseEvent is an entry of the history of an HR case.
+type HRCaseEvent struct {
+	ID     int       `json:"id"`
+	Action string    `json:"action"` // One of the HRCase* action constants
+	Detail string    `json:"detail,omitempty"`
+	Actor  string    `json:"actor"`
+	At     time.Time `json:"at"`
+}
+
+// HRCaseStatistics summarises the HR cases opened in a period without identifying anyone:
+// it holds counts and durations only, no names, parties or departments.
+type HRCaseStatistics struct {
+	From                 time.Time      `json:"from"`
+	To                   time.Time      `json:"to"`
+	Opened               int            `json:"opened"`
+	ByType               map[string]int `json:"by_type"`
+	ByStatus             map[string]int `json:"by_status"`
+	ByMonth              []HRCaseMonth  `json:"by_month"`
+	AverageDaysToResolve float64        `json:"average_days_to_resolve"` // Over the cases resolved so far
+}
+
+// HRCaseMonth counts the HR cases opened and resolved in a month.
+type HRCaseMonth struct {
+	Month    string `json:"month"` // YYYY-MM
+	Opened   int    `json:"opened"`
+	Resolved int    `json:"resolved"`
+}
+
+// HRCaseStore defines the operations for HR cases. Each change records an entry in the
+// case's history in the same transaction.
+type HRCaseStore interface {
+	CreateHRCase(ctx context.Context, c *HRCase) error
+	GetHRCase(ctx context.Context, id int) (*HRCase, error)
+	// ListHRCases returns cases with their parties but without notes, attachments or history,
+	// newest first. Empty filters match every case.
+	ListHRCases(ctx context.Context, status, caseType string) ([]HRCase, error)
+	// ListHRCasesOpened returns the cases opened in [from, to), without parties.
+	ListHRCasesOpened(ctx context.Context, from, to time.Time) ([]HRCase, error)
+	AddHRCaseParty(ctx context.Context, caseID int, party *HRCaseParty, actor string) error
+	AddHRCaseNote(ctx context.Context, caseID int, note *HRCaseNote) error
+	AddHRCaseAttachment(ctx context.Context, caseID int, attachment *HRCaseAttachment) error
+	GetHRCaseAttachment(ctx context.Context, caseID, attachmentID int) (*HRCaseAttachment, error)
+	// ChangeHRCaseStatus moves a case from status from to status to, failing with a conflict
+	// if it is no longer in from.
+	ChangeHRCaseStatus(ctx context.Context, id int, from, to, outcome, actor string) (*HRCase, error)
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Installment statuses, worked out from the payments on the invoice
+const (
+	InstallmentDue           = "due"
+	InstallmentPartiallyPaid = "partially_paid"
+	InstallmentOverdue       = "overdue"
+	InstallmentPaid          = "paid"
+)
+
+// InstallmentPlan splits what is owed on a posted invoice into installments due on
+// different dates. Payments on the invoice settle the installments in due date order.
+type InstallmentPlan struct {
+	ID            int           `json:"id"`
+	InvoiceID     int           `json:"invoice_id"`
+	CustomerID    int           `json:"customer_id"`
+	CustomerName  string        `json:"customer_name,omitempty"`
+	Contact       string        `json:"-"`              // Customer's contact, where reminders are emailed
+	Total         float64       `json:"total"`          // Amount owed on the invoice when the plan was made
+	SettledBefore float64       `json:"settled_before"` // Payments and deposits on the invoice before the plan
+	Settled       float64       `json:"settled"`        // Payments and deposits on the invoice now
+	Installments  []Installment `json:"installments"`
+	Disputed      bool          `json:"disputed,omitempty"` // The invoice has an open dispute; reminders are held
+	CreatedBy     string        `json:"created_by,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// Installment is one scheduled part of an installment plan.
+type Installment struct {
+	ID            int        `json:"id"`
+	Number        int        `json:"number"` // 1 for the first installment due
+	DueDate       time.Time  `json:"due_date"`
+	Amount        float64    `json:"amount"`
+	Paid          float64    `json:"paid"`
+	Status        string     `json:"status"`
+	ReminderStage *int       `json:"reminder_stage,omitempty"` // Days from the due date of the last reminder sent
+	RemindedAt    *time.Time `json:"reminded_at,omitempty"`
+}
+
+// InvoiceBalance is what has been invoiced and settled on an invoice.
+type InvoiceBalance struct {
+	InvoiceID  int     `json:"invoice_id"`
+	CustomerID int     `json:"customer_id"`
+	Status     string  `json:"status"`
+	Amount     float64 `json:"amount"`
+	Settled    float64 `json:"settled"` // Payments and deposits applied
+}
+
+// CashInflow is the cash expected from open installments in a month.
+type CashInflow struct {
+	Month        string  `json:"month"` // e.g. "2024-05"; "overdue" for installments already past due
+	Expected     float64 `json:"expected"`
+	Installments int     `json:"installments"`
+}
+
+// InstallmentStore defines an interface for installment plan database operations
+type InstallmentStore interface {
+	// GetInvoiceBalance returns models.ErrNotFound if the invoice does not exist.
+	GetInvoiceBalance(ctx context.Context, invoiceID int) (*InvoiceBalance, error)
+	// CreateInstallmentPlan returns a conflict if the invoice already has a plan.
+	CreateInstallmentPlan(ctx context.Context, plan *InstallmentPlan) error
+	// GetInstallmentPlan returns the plan of an invoice with the amount settled on it now.
+	GetInstallmentPlan(ctx context.Context, invoiceID int) (*InstallmentPlan, error)
+	DeleteInstallmentPlan(ctx context.Context, invoiceID int) error
+	// GetOpenInstallmentPlans returns the plans not yet paid in full, with their customers.
+	GetOpenInstallmentPlans(ctx context.Context) ([]InstallmentPlan, error)
+	// MarkInstallmentReminded records that the reminder of a stage was sent. It returns
+	// false if a reminder of that stage or a later one was sent already.
+	MarkInstallmentReminded(ctx context.Context, installmentID, stage int, at time.Time) (bool, error)
+}
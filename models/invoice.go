@@ -1,5 +1,14 @@
 package models
 
+import "context"
+
+// Invoice statuses
+const (
+	InvoiceStatusDraft  = StatusDraft
+	InvoiceStatusPosted = StatusPosted
+	InvoiceStatusVoid   = "void"
+)
+
 // Invoice represents an invoice in the system
 type Invoice struct {
 	ID           int     `json:"id"`
@@ -7,13 +16,46 @@ type Invoice struct {
 	CustomerID   int     `json:"customer_id"`
 	Amount       float64 `json:"amount"`
 	Status       string  `json:"status"`
+	// DepositApplied is the part of the sales order's deposits applied when the invoice was
+	// posted; it is only reported by PostInvoice.
+	DepositApplied float64 `json:"deposit_applied,omitempty"`
+	Version        int     `json:"version"` // Incremented by every change, including posting and voiding
 }
 
 // InvoiceStore defines an interface for invoice-related database operations
 type InvoiceStore interface {
-	CreateInvoice(invoice *Invoice) error
-	GetInvoiceByID(id int) (*Invoice, error)
-	UpdateInvoice(invoice *Invoice) error
-	DeleteInvoice(id int) error
-	
+	CreateInvoice(ctx context.Context, invoice *Invoice) error
+	GetInvoiceByID(ctx context.Context, id int) (*Invoice, error)
+	// UpdateInvoice changes a draft invoice if it is still at invoice.Version, and sets the
+	// new version. It returns a conflict if the invoice was changed since.
+	UpdateInvoice(ctx context.Context, invoice *Invoice) error
+	DeleteInvoice(ctx context.Context, id int) error
+	// VoidInvoices voids all the given invoices in one transaction, recording the reason and
+	// actor in the audit log. It returns a *BatchRejectedError and changes nothing if any
+	// invoice is missing or not voidable.
+	VoidInvoices(ctx context.Context, ids []int, reason, actor string) error
+	// PostInvoice validates a draft invoice, records its ledger transactions and locks it.
+	PostInvoice(ctx context.Context, id int) (*Invoice, error)
+	// ListInvoices returns a page of invoices and the number matching the filters.
+	ListInvoices(ctx context.Context, query ListQuery) ([]Invoice, int, error)
+}
+
+// ValidateForPosting checks that an invoice is complete enough to be posted to the ledger.
+func (invoice *Invoice) ValidateForPosting() error {
+	if invoice.Status != InvoiceStatusDraft {
+		return ErrDocumentLocked
+	}
+	if invoice.CustomerID <= 0 {
+		return &PostingError{Reason: "invoice has no customer"}
+	}
+	if invoice.Amount <= 0 {
+		return &PostingError{Reason: "invoice amount must be positive"}
+	}
+	return nil
+}
+
+// IsInvoiceVoidable reports whether an invoice in the given status may be voided.
+// Only draft invoices can be voided; issued invoices must be reversed with a credit note.
+func IsInvoiceVoidable(status string) bool {
+	return status == InvoiceStatusDraft
 }
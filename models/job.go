@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Background job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job tracks a long-running task, such as a backup, that runs in the background after the
+// request that started it has returned.
+type Job struct {
+	ID         int             `json:"id"`
+	Kind       string          `json:"kind"` // e.g. "backup", "backup_verify"
+	Status     string          `json:"status"`
+	Processed  int64           `json:"processed"` // Units of work done so far, e.g. rows exported
+	Total      int64           `json:"total"`     // Expected units of work, or 0 if unknown
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedBy  string          `json:"created_by"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// JobStore defines an interface for job-related database operations
+type JobStore interface {
+	// CreateJob stores a new queued job and sets its ID.
+	CreateJob(ctx context.Context, job *Job) error
+	// StartJob marks a job as running.
+	StartJob(ctx context.Context, id int, at time.Time) error
+	// UpdateJobProgress records how much of a running job is done.
+	UpdateJobProgress(ctx context.Context, id int, processed, total int64) error
+	// FinishJob marks a job as succeeded, or failed if errMsg is not empty.
+	FinishJob(ctx context.Context, id int, result json.RawMessage, errMsg string, at time.Time) error
+	// GetJob returns a job by ID, or ErrNotFound.
+	GetJob(ctx context.Context, id int) (*Job, error)
+	// ListJobs returns the most recent jobs of a kind, newest first.
+	ListJobs(ctx context.Context, kind string, limit int) ([]Job, error)
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// JournalEntry is a manual, balanced set of ledger lines. It is created as a draft and
+// only affects the ledger once posted. Auditors require every manual adjustment to say
+// why it was made, so an entry cannot be posted without a justification.
+type JournalEntry struct {
+	ID            int           `json:"id"`
+	EntryDate     time.Time     `json:"entry_date"`
+	Description   string        `json:"description"`
+	Justification string        `json:"justification"`
+	AttachmentURL string        `json:"attachment_url,omitempty"` // Optional supporting document
+	Status        string        `json:"status"`
+	CreatedBy     string        `json:"created_by,omitempty"` // Email of the user who created the entry
+	PostedAt      *time.Time    `json:"posted_at,omitempty"`
+	PostedBy      string        `json:"posted_by,omitempty"` // Email of the user who posted the entry
+	Lines         []JournalLine `json:"lines"`
+	Version       int           `json:"version"` // Incremented by every change, including attaching and posting
+}
+
+// JournalLine debits or credits one account. Exactly one of Debit and Credit is non-zero.
+type JournalLine struct {
+	AccountType string  `json:"account_type"`
+	Debit       float64 `json:"debit"`
+	Credit      float64 `json:"credit"`
+}
+
+// JournalEntryStore defines an interface for journal entry-related database operations
+type JournalEntryStore interface {
+	CreateJournalEntry(ctx context.Context, entry *JournalEntry) error
+	GetJournalEntryByID(ctx context.Context, id int) (*JournalEntry, error)
+	// UpdateJournalEntry replaces the header and lines of a draft if it is still at
+	// entry.Version, and sets the new version; it returns ErrDocumentLocked once posted.
+	UpdateJournalEntry(ctx context.Context, entry *JournalEntry) error
+	// SetJournalAttachment links a supporting document to a draft; it returns
+	// ErrDocumentLocked once posted.
+	SetJournalAttachment(ctx context.Context, id int, url string) error
+	// PostJournalEntry validates a draft, records one ledger transaction per line, locks it
+	// and records the posting and its justification in the audit log. Posting approves the
+	// entry, so it is subject to the segregation-of-duties policy.
+	PostJournalEntry(ctx context.Context, id int, actor string) (*JournalEntry, error)
+}
+
+// ValidateForPosting checks that a journal entry is a justified, balanced draft.
+func (entry *JournalEntry) ValidateForPosting() error {
+	if entry.Status != StatusDraft {
+		return ErrDocumentLocked
+	}
+	if strings.TrimSpace(entry.Justification) == "" {
+		return &PostingError{Reason: "a justification is required for manual adjustments"}
+	}
+	if len(entry.Lines) < 2 {
+		return &PostingError{Reason: "a journal entry needs at least two lines"}
+	}
+
+	var debit, credit float64
+	for i, line := range entry.Lines {
+		if line.AccountType == "" {
+			return &PostingError{Reason: fmt.Sprintf("line %d has no account", i+1)}
+		}
+		if line.Debit < 0 || line.Credit < 0 || (line.Debit == 0) == (line.Credit == 0) {
+			return &PostingError{Reason: fmt.Sprintf("line %d must have either a debit or a credit", i+1)}
+		}
+		debit += line.Debit
+		credit += line.Credit
+	}
+	// Compare in cents to avoid floating point noise
+	if math.Round(debit*100) != math.Round(credit*100) {
+		return &PostingError{Reason: fmt.Sprintf("debits (%.2f) and credits (%.2f) do not balance", debit, credit)}
+	}
+	return nil
+}
+
+// Amount returns the signed ledger amount of the line: debits are positive, credits negative.
+func (line JournalLine) Amount() float64 {
+	return line.Debit - line.Credit
+}
+
+// Total returns the sum of the entry's debits, which equals its credits once balanced.
+func (entry *JournalEntry) Total() float64 {
+	var total float64
+	for _, line := range entry.Lines {
+		total += line.Debit
+	}
+	return total
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Metrics that KPI rules can watch
+const (
+	KPIReceivables    = "receivables"              // Outstanding receivables
+	KPIStockTurnover  = "stock_turnover"           // COGS of the last 12 months over the current stock value
+	KPIMonthlyRevenue = "monthly_revenue"          // Revenue of the last complete month
+	KPIRevenueDropPct = "monthly_revenue_drop_pct" // How much last month's revenue fell from the month before, in percent
+)
+
+// KPIMetrics lists the metrics that KPI rules can watch.
+var KPIMetrics = map[string]bool{
+	KPIReceivables:    true,
+	KPIStockTurnover:  true,
+	KPIMonthlyRevenue: true,
+	KPIRevenueDropPct: true,
+}
+
+// KPIOperators lists the comparisons a KPI rule can make between a metric and its threshold.
+var KPIOperators = map[string]func(value, threshold float64) bool{
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+}
+
+// KPIRule raises an alert when a metric crosses a threshold, e.g. receivables > 50000.
+// A rule alerts when it enters breach and again only after it has recovered.
+type KPIRule struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	Metric          string     `json:"metric"`
+	Operator        string     `json:"operator"`
+	Threshold       float64    `json:"threshold"`
+	Active          bool       `json:"active"`
+	Breached        bool       `json:"breached"` // Whether the last evaluation crossed the threshold
+	LastValue       *float64   `json:"last_value,omitempty"`
+	LastEvaluatedAt *time.Time `json:"last_evaluated_at,omitempty"`
+	CreatedBy       string     `json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Breaches reports whether value crosses the rule's threshold.
+func (rule *KPIRule) Breaches(value float64) bool {
+	compare, ok := KPIOperators[rule.Operator]
+	return ok && compare(value, rule.Threshold)
+}
+
+// Alert returns the alert raised by the rule for value.
+func (rule *KPIRule) Alert(value float64, now time.Time) *KPIAlert {
+	return &KPIAlert{
+		RuleID:      rule.ID,
+		RuleName:    rule.Name,
+		Metric:      rule.Metric,
+		Operator:    rule.Operator,
+		Threshold:   rule.Threshold,
+		Value:       value,
+		Message:     fmt.Sprintf("%s: %s is %.2f (%s %.2f)", rule.Name, rule.Metric, value, rule.Operator, rule.Threshold),
+		TriggeredAt: now,
+	}
+}
+
+// KPIAlert records a KPI rule entering breach. The rule's name, comparison and threshold
+// are copied so the history still reads correctly after the rule changes.
+type KPIAlert struct {
+	ID          int       `json:"id"`
+	RuleID      int       `json:"rule_id"`
+	RuleName    string    `json:"rule_name"`
+	Metric      string    `json:"metric"`
+	Operator    string    `json:"operator"`
+	Threshold   float64   `json:"threshold"`
+	Value       float64   `json:"value"`
+	Message     string    `json:"message"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// KPIStore defines an interface for KPI rule and alert database operations
+type KPIStore interface {
+	CreateKPIRule(ctx context.Context, rule *KPIRule) error
+	GetKPIRule(ctx context.Context, id int) (*KPIRule, error)
+	// ListKPIRules returns every rule, or only the active ones, ordered by ID.
+	ListKPIRules(ctx context.Context, activeOnly bool) ([]KPIRule, error)
+	// UpdateKPIRule changes a rule's definition and clears its breach state, so the new
+	// definition alerts on its next breach.
+	UpdateKPIRule(ctx context.Context, rule *KPIRule) error
+	DeleteKPIRule(ctx context.Context, id int) error
+	// MeasureKPI computes a metric at now. It returns false if the metric has no value,
+	// such as a turnover without stock or a revenue drop without prior revenue.
+	MeasureKPI(ctx context.Context, metric string, now time.Time) (float64, bool, error)
+	// RecordKPIEvaluation stores a rule's latest value and breach state. If the rule enters
+	// breach, the alert is recorded with a KPIAlertTriggered event in the same transaction
+	// and returned; otherwise it returns nil.
+	RecordKPIEvaluation(ctx context.Context, rule *KPIRule, value float64, now time.Time) (*KPIAlert, error)
+	GetKPIAlert(ctx context.Context, id int) (*KPIAlert, error)
+	// ListKPIAlerts returns up to limit alerts, newest first, of one rule or of all rules
+	// when ruleID is 0.
+	ListKPIAlerts(ctx context.Context, ruleID, limit int) ([]KPIAlert, error)
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Late fee methods: how a rule charges for an overdue invoice.
+const (
+	LateFeeInterest = "interest" // Interest on the balance, charged monthly
+	LateFeeFlat     = "flat"     // A fixed fee, charged once per invoice
+)
+
+// LateFeeRule says when a posted invoice is overdue and what is charged for it. A rule
+// with a customer applies to that customer's invoices; the rule without one applies to
+// all other customers.
+type LateFeeRule struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	CustomerID       *int      `json:"customer_id,omitempty"`
+	Method           string    `json:"method"`
+	PaymentTermsDays int       `json:"payment_terms_days"`    // Days after posting the invoice is due
+	GraceDays        int       `json:"grace_days"`            // Days after the due date before anything is charged
+	AnnualRate       float64   `json:"annual_rate,omitempty"` // Percent a year, for interest rules
+	FlatFee          float64   `json:"flat_fee,omitempty"`
+	MinimumBalance   float64   `json:"minimum_balance"` // Balances below it are not charged
+	Active           bool      `json:"active"`
+	UpdatedBy        string    `json:"updated_by,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// OverdueInvoice is a posted invoice with a balance, as seen by the late fee run.
+type OverdueInvoice struct {
+	InvoiceID       int        `json:"invoice_id"`
+	CustomerID      int        `json:"customer_id"`
+	PostedOn        time.Time  `json:"posted_on"`
+	Balance         float64    `json:"balance"`
+	FlatCharged     bool       `json:"flat_charged"`               // Whether a flat fee was charged already
+	InterestThrough *time.Time `json:"interest_through,omitempty"` // End of the last interest period charged
+}
+
+// LateFeeCharge is a late fee charged for an invoice. Each charge is billed on a penalty
+// invoice of its own, which is posted when the charge is made.
+type LateFeeCharge struct {
+	ID               int        `json:"id,omitempty"`
+	InvoiceID        int        `json:"invoice_id"`
+	CustomerID       int        `json:"customer_id"`
+	PenaltyInvoiceID int        `json:"penalty_invoice_id,omitempty"`
+	RuleID           int        `json:"rule_id"`
+	Method           string     `json:"method"`
+	Balance          float64    `json:"balance"`               // Overdue balance the fee was computed on
+	PeriodFrom       *time.Time `json:"period_from,omitempty"` // Interest period, end exclusive
+	PeriodTo         *time.Time `json:"period_to,omitempty"`
+	Amount           float64    `json:"amount"`
+	ChargedAt        time.Time  `json:"charged_at"`
+}
+
+// Statement line types
+const (
+	StatementInvoice = "invoice"
+	StatementLateFee = "late_fee" // A penalty invoice
+	StatementPayment = "payment"
+	StatementDeposit = "deposit" // A customer deposit applied to an invoice
+
+	StatementCreditNote = ResolutionCreditNote // A credit note issued to resolve a dispute
+	StatementAdjustment = ResolutionAdjustment // A receivable written down to resolve a dispute
+)
+
+// StatementLine is one entry on a customer statement. Invoices are debits and payments credits.
+type StatementLine struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"`
+	InvoiceID   int       `json:"invoice_id"`
+	Description string    `json:"description"`
+	Debit       float64   `json:"debit"`
+	Credit      float64   `json:"credit"`
+	Balance     float64   `json:"balance"` // Running balance after the line
+}
+
+// CustomerStatement lists what a customer was invoiced and paid in a period, late fees included.
+type CustomerStatement struct {
+	CustomerID     int             `json:"customer_id"`
+	CustomerName   string          `json:"customer_name"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance float64         `json:"opening_balance"`
+	Lines          []StatementLine `json:"lines"`
+	LateFees       float64         `json:"late_fees"` // Late fees charged in the period
+	ClosingBalance float64         `json:"closing_balance"`
+}
+
+// LateFeeStore defines an interface for late fee database operations
+type LateFeeStore interface {
+	// CreateLateFeeRule returns a conflict if the customer already has a rule.
+	CreateLateFeeRule(ctx context.Context, rule *LateFeeRule) error
+	GetLateFeeRules(ctx context.Context) ([]LateFeeRule, error)
+	UpdateLateFeeRule(ctx context.Context, rule *LateFeeRule) error
+	DeleteLateFeeRule(ctx context.Context, id int) error
+	// GetOverdueInvoices returns the posted invoices with a balance, other than penalty
+	// invoices and invoices with an open dispute.
+	GetOverdueInvoices(ctx context.Context) ([]OverdueInvoice, error)
+	// ChargeLateFee issues and posts the penalty invoice of a charge and records the charge.
+	// It returns a conflict if the fee was charged already.
+	ChargeLateFee(ctx context.Context, charge *LateFeeCharge) error
+	// GetLateFeeCharges lists the charges for an invoice, or every charge if invoiceID is 0.
+	GetLateFeeCharges(ctx context.Context, invoiceID int) ([]LateFeeCharge, error)
+	// GetCustomerStatement returns a customer with the statement lines up to and including a
+	// day, oldest first and without balances. It returns models.ErrNotFound if the customer
+	// does not exist.
+	GetCustomerStatement(ctx context.Context, customerID int, to time.Time) (*CustomerStatement, error)
+}
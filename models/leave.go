@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Leave represents employee leave
 type Leave struct {
@@ -10,12 +13,13 @@ type Leave struct {
 	StartDate time.Time `json:"start_date"`
 	EndDate   time.Time `json:"end_date"`
 	Status    string    `json:"status"`
+	Version   int       `json:"version"` // Incremented by every update; a decision names the version it changes
 }
 
 // LeaveStore defines an interface for leave-related database operations
 type LeaveStore interface {
-	CreateLeave(leave *Leave) error
-	GetLeaveByUserID(userID int) ([]*Leave, error)
-	UpdateLeaveStatus(id int, status string) error
-	DeleteLeave(id int) error
+	CreateLeave(ctx context.Context, leave *Leave) error
+	GetLeaveByUserID(ctx context.Context, userID int) ([]*Leave, error)
+	UpdateLeaveStatus(ctx context.Context, id int, status string, version int) (int, error)
+	DeleteLeave(ctx context.Context, id int) error
 }
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Kinds of leave adjustments
+const (
+	LeaveAdjustmentManual       = "manual"         // Entered by HR
+	LeaveAdjustmentYearEndClose = "year_end_close" // Zeroes the unused balance of the closed year
+	LeaveAdjustmentCarryForward = "carry_forward"  // Credits the carried days to the next year
+)
+
+// Accrual rules of leave policies
+const (
+	LeaveAccrualAnnual  = "annual"  // The whole entitlement is available from January 1st
+	LeaveAccrualMonthly = "monthly" // A twelfth of the entitlement is credited on the 1st of each month
+)
+
+// LeavePolicy is the yearly entitlement of a leave type, how it accrues over the year and
+// how much of it can be carried into the next year. Unused days above MaxCarryForward are
+// forfeited at year end.
+type LeavePolicy struct {
+	LeaveType       string     `json:"leave_type"`
+	AnnualDays      float64    `json:"annual_days"`
+	Accrual         string     `json:"accrual"` // LeaveAccrualAnnual (the default) or LeaveAccrualMonthly
+	MaxCarryForward float64    `json:"max_carry_forward"`
+	UpdatedBy       string     `json:"updated_by,omitempty"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+}
+
+// LeaveAdjustment changes an employee's balance of a leave type for one year. Positive days
+// add leave and negative days remove it.
+type LeaveAdjustment struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	LeaveType string    `json:"leave_type"`
+	Year      int       `json:"year"`
+	Days      float64   `json:"days"`
+	Kind      string    `json:"kind"`
+	Reason    string    `json:"reason"`
+	RunID     *int      `json:"run_id,omitempty"` // Year-end run that made the adjustment
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LeaveBalance is what an employee has left of a leave type in a year. Pending requests are
+// held against the balance; approving one moves its days to Taken.
+type LeaveBalance struct {
+	UserID      int     `json:"user_id"`
+	LeaveType   string  `json:"leave_type"`
+	Year        int     `json:"year"`
+	Accrual     string  `json:"accrual"`
+	Entitlement float64 `json:"entitlement"` // Annual days of the policy
+	Accrued     float64 `json:"accrued"`     // Part of the entitlement accrued so far
+	Adjustments float64 `json:"adjustments"`
+	Taken       float64 `json:"taken"`   // Days of approved leave in the year
+	Pending     float64 `json:"pending"` // Days of leave requests awaiting approval
+	Available   float64 `json:"available"`
+}
+
+// LeaveYearEndLine is the year-end outcome for one employee and leave type.
+type LeaveYearEndLine struct {
+	UserID         int     `json:"user_id"`
+	Email          string  `json:"email"`
+	LeaveType      string  `json:"leave_type"`
+	Entitlement    float64 `json:"entitlement"`
+	Adjustments    float64 `json:"adjustments"` // Adjustments made during the year
+	Taken          float64 `json:"taken"`       // Days of approved leave in the year
+	Unused         float64 `json:"unused"`      // Negative if more was taken than available
+	CarriedForward float64 `json:"carried_forward"`
+	Forfeited      float64 `json:"forfeited"`
+}
+
+// LeaveYearEndRun summarizes the closing of a leave year. A preview is computed the same
+// way but nothing is saved.
+type LeaveYearEndRun struct {
+	ID             int                `json:"id,omitempty"`
+	Year           int                `json:"year"`
+	Preview        bool               `json:"preview"`
+	JobID          *int               `json:"job_id,omitempty"`
+	Employees      int                `json:"employees"` // Employees with unused leave
+	CarriedForward float64            `json:"carried_forward"`
+	Forfeited      float64            `json:"forfeited"`
+	Lines          []LeaveYearEndLine `json:"lines"`
+	Actor          string             `json:"actor,omitempty"` // Email of the admin, or "scheduler"
+	ProcessedAt    *time.Time         `json:"processed_at,omitempty"`
+}
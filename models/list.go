@@ -0,0 +1,23 @@
+package models
+
+// ListQuery selects a page of a collection, such as GET /invoices?status=posted&sort=-amount&page=2.
+type ListQuery struct {
+	Page    int               // Page number, starting at 1
+	Limit   int               // Records per page
+	Sort    string            // Field the records are sorted by; ties are broken by ID
+	Desc    bool              // Whether the records are sorted in descending order
+	Filters map[string]string // Fields and the values they must equal; text is matched case-insensitively
+}
+
+// Offset returns the number of records before the page.
+func (q ListQuery) Offset() int {
+	return (q.Page - 1) * q.Limit
+}
+
+// Page is a page of a collection with the number of records matching the filters.
+type Page struct {
+	Items interface{} `json:"items"`
+	Total int         `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Kinds of loyalty transactions
+const (
+	LoyaltyAccrual    = "accrual"    // Points earned by a posted invoice
+	LoyaltyRedemption = "redemption" // Points spent as a discount on a draft invoice
+	LoyaltyExpiry     = "expiry"     // Points of an accrual that expired unspent
+)
+
+// ErrInsufficientPoints is returned when a customer redeems more points than they hold.
+var ErrInsufficientPoints = Conflict("the customer does not have enough loyalty points")
+
+// LoyaltyTransaction is one change to a customer's loyalty point balance. Accruals add
+// points and expire at ExpiresAt; redemptions and expiries subtract them.
+type LoyaltyTransaction struct {
+	ID         int        `json:"id"`
+	CustomerID int        `json:"customer_id"`
+	Kind       string     `json:"kind"`
+	Points     int        `json:"points"` // Negative for redemptions and expiries
+	InvoiceID  *int       `json:"invoice_id,omitempty"`
+	Discount   float64    `json:"discount,omitempty"` // Amount taken off the invoice by a redemption
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// LoyaltyAccount is a customer's point balance with their most recent transactions.
+type LoyaltyAccount struct {
+	CustomerID     int                  `json:"customer_id"`
+	Balance        int                  `json:"balance"`
+	Value          float64              `json:"value"`                 // What the balance is worth when redeemed
+	NextExpiry     *time.Time           `json:"next_expiry,omitempty"` // When the oldest unspent points expire
+	ExpiringPoints int                  `json:"expiring_points"`       // Points expiring at NextExpiry
+	Transactions   []LoyaltyTransaction `json:"transactions"`          // Newest first
+}
+
+// LoyaltyStore defines an interface for loyalty point database operations
+type LoyaltyStore interface {
+	// Accrue records points earned by a posted invoice, expiring at expiresAt. An invoice
+	// accrues points once; later calls for the same invoice return false.
+	Accrue(ctx context.Context, customerID, invoiceID, points int, expiresAt time.Time) (bool, error)
+	// Redeem spends points of the customer's oldest unexpired accruals and takes discount
+	// off the amount of a draft invoice of that customer, in one transaction. It returns
+	// ErrInsufficientPoints if the customer holds fewer points and ErrDocumentLocked if
+	// the invoice is not a draft.
+	Redeem(ctx context.Context, customerID, invoiceID, points int, discount float64, now time.Time) (*LoyaltyTransaction, error)
+	// GetLoyaltyAccount returns the customer's balance, next expiry and up to limit of their
+	// newest transactions. Value is left for the caller to fill in.
+	GetLoyaltyAccount(ctx context.Context, customerID int, limit int, now time.Time) (*LoyaltyAccount, error)
+	// ExpirePoints records an expiry for the unspent points of every accrual that expired
+	// at or before now and returns the number of points expired.
+	ExpirePoints(ctx context.Context, now time.Time) (int, error)
+}
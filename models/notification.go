@@ -0,0 +1,40 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Notification is an in-app message shown to one user, typically generated from a domain event.
+type Notification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	EventID   *int       `json:"event_id,omitempty"` // Domain event the notification was generated from
+	Type      string     `json:"type"`               // Event type or other category, e.g. "InvoicePosted"
+	Title     string     `json:"title"`
+	Body      string     `json:"body,omitempty"`
+	Link      string     `json:"link,omitempty"` // API path of the related record
+	Read      bool       `json:"read"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationStore defines an interface for notification-related database operations
+type NotificationStore interface {
+	// GetUserIDByEmail resolves the authenticated user's ID.
+	GetUserIDByEmail(ctx context.Context, email string) (int, error)
+	ListNotifications(ctx context.Context, userID int, unreadOnly bool, limit int) ([]Notification, error)
+	CountUnread(ctx context.Context, userID int) (int, error)
+	// MarkRead marks one of the user's notifications as read. It returns ErrNotFound if the
+	// notification does not exist or belongs to another user.
+	MarkRead(ctx context.Context, userID, id int) error
+	// MarkAllRead marks the given notifications, or all of them if ids is empty, as read
+	// and returns how many were changed.
+	MarkAllRead(ctx context.Context, userID int, ids []int) (int, error)
+	// NotifyRoles creates a copy of the notification for every user with one of the roles.
+	// A user receives at most one notification per event.
+	NotifyRoles(ctx context.Context, roles []string, notification *Notification) (int, error)
+	// NotifyUsers creates a copy of the notification for every user with one of the emails.
+	// A user receives at most one notification per event.
+	NotifyUsers(ctx context.Context, emails []string, notification *Notification) (int, error)
+}
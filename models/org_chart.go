@@ -0,0 +1,43 @@
+package models
+
+import "context"
+
+// OrgEmployee is an active user as placed in the reporting hierarchy.
+type OrgEmployee struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Department string `json:"department"`
+	ManagerID  *int   `json:"manager_id"`
+}
+
+// OrgNode is an employee in the org chart with the people who report to them.
+type OrgNode struct {
+	OrgEmployee
+	DirectReports int        `json:"direct_reports"` // Counted even when Reports is cut off by the depth limit
+	Reports       []*OrgNode `json:"reports"`
+}
+
+// OrgDepartment groups the employees of a department in the org chart.
+type OrgDepartment struct {
+	Department string `json:"department"` // "Unassigned" for employees without one
+	Headcount  int    `json:"headcount"`
+	Heads      []int  `json:"heads"` // Employees whose manager is outside the department, or who have none
+}
+
+// OrgChart is the reporting hierarchy. Roots are the employees without a manager in the
+// chart, such as the chief executive, or the heads of a department when it is filtered.
+type OrgChart struct {
+	Roots       []*OrgNode      `json:"roots"`
+	Departments []OrgDepartment `json:"departments"`
+}
+
+// OrgChartStore defines the operations for the reporting hierarchy.
+type OrgChartStore interface {
+	// ListOrgEmployees returns the active users with their managers.
+	ListOrgEmployees(ctx context.Context) ([]OrgEmployee, error)
+	// SetManager sets or, with a nil managerID, clears who a user reports to. It returns a
+	// validation error if the manager does not exist or reports to the user, directly or
+	// through others.
+	SetManager(ctx context.Context, userID int, managerID *int) error
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Delivery states of an outbox message
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead" // Gave up after the maximum number of attempts
+)
+
+// OutboxMessage is a side-effect intent (send an email, call a webhook, publish an event)
+// written in the same database transaction as the business change that caused it.
+type OutboxMessage struct {
+	ID            int             `json:"id"`
+	Kind          string          `json:"kind"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// OutboxStore defines the operations the outbox dispatcher needs to deliver messages
+type OutboxStore interface {
+	// ClaimDue returns up to limit pending messages whose next attempt is due, and leases
+	// them until leaseUntil so concurrent dispatchers do not deliver them twice.
+	ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]*OutboxMessage, error)
+	MarkDelivered(ctx context.Context, id int) error
+	Reschedule(ctx context.Context, id int, nextAttemptAt time.Time, reason string) error
+	MarkDead(ctx context.Context, id int, reason string) error
+}
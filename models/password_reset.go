@@ -0,0 +1,40 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// PasswordReset is a one-time token emailed to a user who forgot their password. Only its
+// hash is stored, and it can be used once before it expires.
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// ForgotPasswordRequest represents the request structure for asking for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents the request structure for setting a password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// PasswordResetStore defines the operations for password reset tokens.
+type PasswordResetStore interface {
+	// CreatePasswordReset stores a reset token for the user with the email; the ID and user
+	// ID are set. The user's earlier unused tokens stop working.
+	CreatePasswordReset(ctx context.Context, reset *PasswordReset) error
+	// ResetPassword spends the unexpired, unused reset token with the hash and sets the
+	// user's password. The user's refresh tokens are revoked, so every session has to sign
+	// in again. It returns the user's email.
+	ResetPassword(ctx context.Context, hash, hashedPassword string, now time.Time) (string, error)
+}
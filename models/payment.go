@@ -1,20 +1,36 @@
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Payment represents a payment in the system
 type Payment struct {
 	ID           int       `json:"id"`
 	InvoiceID    int       `json:"invoice_id"`
+	PurchaseOrderID int    `json:"purchase_order_id,omitempty"` // Set on the draft created when a purchase order is received
 	Amount       float64   `json:"amount"`
 	PaymentDate  time.Time `json:"payment_date"`
 	PaymentMethod string   `json:"payment_method"`
+	Status        string     `json:"status"`                // PaymentPending or PaymentApproved
+	CreatedBy     string     `json:"created_by,omitempty"`  // Email of the user who recorded the payment
+	ApprovedBy    string     `json:"approved_by,omitempty"` // Email of the approver; empty if no approval was required
+	ApprovedAt    *time.Time `json:"approved_at,omitempty"`
+	Version       int        `json:"version"` // Incremented by every update; an update names the version it changes
 }
 
 // PaymentStore defines an interface for payment-related database operations
 type PaymentStore interface {
-	CreatePayment(payment *Payment) error
-	GetPaymentByID(id int) (*Payment, error)
-	UpdatePayment(payment *Payment) error
-	DeletePayment(id int) error
+	CreatePayment(ctx context.Context, payment *Payment) error
+	GetPaymentByID(ctx context.Context, id int) (*Payment, error)
+	// UpdatePayment changes a payment if it is still at payment.Version and sets the new
+	// version. It returns a conflict if the payment was approved or changed since.
+	UpdatePayment(ctx context.Context, payment *Payment) error
+	DeletePayment(ctx context.Context, id int) error
+	// ApprovePayment approves a pending payment, subject to the segregation-of-duties
+	// policy; it returns an ErrConflict error if the payment is not pending.
+	ApprovePayment(ctx context.Context, id int, actor string) (*Payment, error)
+	// ListPayments returns a page of payments and the number matching the filters.
+	ListPayments(ctx context.Context, query ListQuery) ([]Payment, int, error)
 }
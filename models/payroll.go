@@ -0,0 +1,68 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// PayRate is the hourly pay of a salary grade.
+type PayRate struct {
+	Grade              string    `json:"grade"`
+	HourlyRate         float64   `json:"hourly_rate"`
+	OvertimeMultiplier float64   `json:"overtime_multiplier,omitempty"` // Overtime pay per hour over the hourly rate; 0 for the default
+	UpdatedBy          string    `json:"updated_by,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// PayrollRecord is an employee's pay for a month, worked out from their attendance and
+// approved leave.
+type PayrollRecord struct {
+	ID              int     `json:"id,omitempty"`
+	EmployeeID      int     `json:"employee_id"`
+	UserID          int     `json:"user_id"`
+	Employee        string  `json:"employee"`
+	Email           string  `json:"email"`
+	Period          string  `json:"period"` // YYYY-MM
+	Grade           string  `json:"grade"`
+	HourlyRate      float64 `json:"hourly_rate"`
+	RegularHours    float64 `json:"regular_hours"`
+	OvertimeHours   float64 `json:"overtime_hours"` // Hours over the working day
+	LeaveDays       float64 `json:"leave_days"`     // Working days of paid leave not worked
+	UnpaidLeaveDays float64 `json:"unpaid_leave_days"`
+	RegularPay      float64 `json:"regular_pay"`
+	OvertimePay     float64 `json:"overtime_pay"`
+	LeavePay        float64 `json:"leave_pay"`
+	Gross           float64 `json:"gross"`
+}
+
+// PayrollRun is the payroll of a month for every employee, posted to the ledger.
+type PayrollRun struct {
+	ID       int             `json:"id,omitempty"`
+	Period   string          `json:"period"` // YYYY-MM
+	Total    float64         `json:"total"`  // Gross pay of all employees
+	Records  []PayrollRecord `json:"records"`
+	Skipped  []string        `json:"skipped,omitempty"` // Employees not paid, and why
+	Actor    string          `json:"actor,omitempty"`
+	PostedAt *time.Time      `json:"posted_at,omitempty"`
+}
+
+// PayrollStore defines the data payroll is worked out from and where runs are kept.
+type PayrollStore interface {
+	ListPayRates(ctx context.Context) ([]PayRate, error)
+	// SavePayRate creates or replaces the rate of a grade.
+	SavePayRate(ctx context.Context, rate *PayRate) error
+	// DeletePayRate returns a not found error if the grade has no rate.
+	DeletePayRate(ctx context.Context, grade string) error
+	// ListPayrollEmployees returns the employees with a user account, who have attendance
+	// and leave.
+	ListPayrollEmployees(ctx context.Context) ([]Employee, error)
+	// ListAttendance returns the attendance checked in from from to before to.
+	ListAttendance(ctx context.Context, from, to time.Time) ([]Attendance, error)
+	// ListApprovedLeave returns the approved leave overlapping the days from from to before to.
+	ListApprovedLeave(ctx context.Context, from, to time.Time) ([]Leave, error)
+	// SavePayrollRun records a run and posts its gross pay to the ledger on postingDate. It
+	// returns a conflict if the month has been run already.
+	SavePayrollRun(ctx context.Context, run *PayrollRun, postingDate time.Time) error
+	// GetPayrollRecord returns a not found error if the employee was not paid for the month.
+	GetPayrollRecord(ctx context.Context, employeeID int, period string) (*PayrollRecord, error)
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Payslip is an employee's pay for a period: the gross wage, the statutory deductions
+// withheld and the net pay, with the totals of the year so far. It is built from the
+// employee's statutory record of the period.
+type Payslip struct {
+	ID              int             `json:"id"` // ID of the statutory record
+	UserID          int             `json:"user_id"`
+	Employee        string          `json:"employee"`
+	Email           string          `json:"email"`
+	Department      string          `json:"department,omitempty"`
+	Period          string          `json:"period"` // e.g. "2024-05"
+	Gross           float64         `json:"gross"`
+	TotalDeductions float64         `json:"total_deductions"`
+	Net             float64         `json:"net"`
+	Employer        float64         `json:"employer"` // Employer contributions, not deducted from pay
+	Deductions      []StatutoryLine `json:"deductions,omitempty"`
+	YearToDate      *PayslipTotals  `json:"year_to_date,omitempty"`
+	RecordedAt      time.Time       `json:"recorded_at"`
+}
+
+// PayslipTotals are the pay totals of an employee's year up to and including a period.
+type PayslipTotals struct {
+	Gross      float64 `json:"gross"`
+	Deductions float64 `json:"deductions"`
+	Net        float64 `json:"net"`
+	Employer   float64 `json:"employer"`
+}
+
+// PayrollAmounts are pay totals of an employee for a month or a year.
+type PayrollAmounts struct {
+	Gross    float64 `json:"gross"`
+	Tax      float64 `json:"tax"`      // Income tax withheld
+	Benefits float64 `json:"benefits"` // Provident fund and insurance withheld from the employee
+	Net      float64 `json:"net"`
+	Employer float64 `json:"employer"` // Employer contributions, not deducted from pay
+}
+
+// PayrollMonth is an employee's pay for one month of the year.
+type PayrollMonth struct {
+	Period string `json:"period"` // e.g. "2024-05"
+	PayrollAmounts
+}
+
+// PayrollSummary is an employee's pay for a year by month, for annual tax statements.
+type PayrollSummary struct {
+	UserID     int            `json:"user_id"`
+	Employee   string         `json:"employee"`
+	Email      string         `json:"email"`
+	Department string         `json:"department,omitempty"`
+	Year       int            `json:"year"`
+	Months     []PayrollMonth `json:"months"` // Months the employee was paid, in order
+	Totals     PayrollAmounts `json:"totals"`
+}
+
+// PayslipStore defines an interface for reading payslips
+type PayslipStore interface {
+	// GetPayslip returns a payslip with its deductions and year-to-date totals, or
+	// models.ErrNotFound.
+	GetPayslip(ctx context.Context, id int) (*Payslip, error)
+	// GetPayslips lists payslips without their deductions, newest period first. An empty
+	// period or email matches every period or employee.
+	GetPayslips(ctx context.Context, period, email string) ([]Payslip, error)
+	// GetPayrollSummary returns an employee's pay for a year by month, without totals, or
+	// models.ErrNotFound if the user does not exist.
+	GetPayrollSummary(ctx context.Context, userID, year int) (*PayrollSummary, error)
+}
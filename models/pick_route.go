@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Ways of walking the aisles of a warehouse when picking.
+const (
+	TraversalSerpentine = "serpentine" // Through each aisle and out the far end, alternating direction
+	TraversalReturn     = "return"     // Into each aisle from the front and back out the same way
+)
+
+// PickLayout is how pickers walk a warehouse: the order its zones are visited in and how
+// they go through the aisles of a zone.
+type PickLayout struct {
+	WarehouseID int       `json:"warehouse_id"`
+	Zones       []string  `json:"zones"` // Zones not listed are visited after these, by name
+	Traversal   string    `json:"traversal"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// PickLine is a product to pick.
+type PickLine struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// PickRequest asks for the route to pick a list of products in a warehouse.
+type PickRequest struct {
+	WarehouseID int        `json:"warehouse_id"`
+	Lines       []PickLine `json:"lines"`
+}
+
+// PickLocation is the stock of a product in a bin.
+type PickLocation struct {
+	Bin
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// PickTask is a stop on a pick route: the units of a product to take from a bin.
+type PickTask struct {
+	Sequence  int    `json:"sequence"` // From 1
+	BinID     int    `json:"bin_id"`
+	Code      string `json:"code"`
+	Zone      string `json:"zone"`
+	Aisle     string `json:"aisle"`
+	Position  int    `json:"position"`
+	ProductID int    `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// PickRoute is the order to pick a list in.
+type PickRoute struct {
+	WarehouseID int        `json:"warehouse_id"`
+	Traversal   string     `json:"traversal"`
+	Tasks       []PickTask `json:"tasks"`
+	Stops       int        `json:"stops"`  // Bins visited
+	Aisles      int        `json:"aisles"` // Aisles entered
+	Short       []PickLine `json:"short"`  // Units no bin holds
+}
+
+// PickStore defines the data pick routes are planned from.
+type PickStore interface {
+	// GetPickLayout returns a not found error if the warehouse has no saved layout.
+	GetPickLayout(ctx context.Context, warehouseID int) (*PickLayout, error)
+	// SavePickLayout returns a validation error if the warehouse does not exist.
+	SavePickLayout(ctx context.Context, layout *PickLayout) error
+	// ListPickLocations returns the stock of the products in the bins of a warehouse.
+	ListPickLocations(ctx context.Context, warehouseID int, productIDs []int) ([]PickLocation, error)
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Payment methods accepted at the point of sale
+const (
+	POSPaymentCash = "cash"
+	POSPaymentCard = "card"
+)
+
+// POSSale is a sale rung up at a retail counter. It is paid in full when it is recorded and
+// takes its stock from the warehouse the register belongs to.
+type POSSale struct {
+	ID            int           `json:"id"`
+	Register      string        `json:"register"`
+	SessionID     *int          `json:"session_id,omitempty"` // The register session open when the sale was recorded
+	WarehouseID   int           `json:"warehouse_id"`
+	CustomerID    *int          `json:"customer_id,omitempty"` // Walk-in sales have no customer
+	Lines         []POSSaleLine `json:"lines"`
+	Total         float64       `json:"total"`
+	PaymentMethod string        `json:"payment_method"`
+	Tendered      float64       `json:"tendered"` // Cash handed over; the total for card payments
+	Change        float64       `json:"change"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// POSSaleLine is one product of a POS sale, sold at the product's price.
+type POSSaleLine struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Amount    float64 `json:"amount"`
+}
+
+// POSStore defines an interface for point of sale database operations
+type POSStore interface {
+	// RecordSale takes the stock of every line, prices the lines, records the sale and its
+	// payment and posts it to the ledger, all in one transaction. It fills in the prices,
+	// total, change, ID and time of the sale.
+	RecordSale(ctx context.Context, sale *POSSale) error
+}
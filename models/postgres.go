@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes of the constraint violations stores turn into domain errors.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// Queryer is satisfied by both *sql.DB and *sql.Tx, so the reads of a store can run inside
+// a transaction or on their own.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// IsUniqueViolation reports whether err is a Postgres unique violation, e.g. a duplicate
+// code. With constraints, only a violation of one of them counts.
+func IsUniqueViolation(err error, constraints ...string) bool {
+	return isViolation(err, uniqueViolation, constraints)
+}
+
+// IsForeignKeyViolation reports whether err is a Postgres foreign key violation: a row
+// refers to one that does not exist, or a deleted row is still referred to. With
+// constraints, only a violation of one of them counts.
+func IsForeignKeyViolation(err error, constraints ...string) bool {
+	return isViolation(err, foreignKeyViolation, constraints)
+}
+
+// ViolationDetail returns the detail Postgres gives for a constraint violation, e.g.
+// "Key (email)=(a@example.com) already exists.", or "" if err is not one.
+func ViolationDetail(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == "23" {
+		return pqErr.Detail
+	}
+	return ""
+}
+
+func isViolation(err error, code string, constraints []string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || string(pqErr.Code) != code {
+		return false
+	}
+	if len(constraints) == 0 {
+		return true
+	}
+	for _, constraint := range constraints {
+		if pqErr.Constraint == constraint {
+			return true
+		}
+	}
+	return false
+}
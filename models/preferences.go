@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Supported values of the user preference fields
+var (
+	DateFormats          = []string{"YYYY-MM-DD", "DD/MM/YYYY", "MM/DD/YYYY", "DD.MM.YYYY"}
+	LandingPages         = []string{"dashboard", "invoices", "customers", "products", "stock", "reports", "notifications"}
+	NotificationChannels = []string{"in_app", "email"}
+)
+
+// UserPreferences holds the settings a user carries across devices.
+type UserPreferences struct {
+	DefaultWarehouseID   *int     `json:"default_warehouse_id"`
+	DateFormat           string   `json:"date_format"`
+	LandingPage          string   `json:"landing_page"`
+	NotificationChannels []string `json:"notification_channels"`
+}
+
+// DefaultPreferences returns the preferences of a user who has not saved any.
+func DefaultPreferences() *UserPreferences {
+	return &UserPreferences{
+		DateFormat:           "YYYY-MM-DD",
+		LandingPage:          "dashboard",
+		NotificationChannels: []string{"in_app"},
+	}
+}
+
+// Validate checks that every field holds a supported value. The default warehouse is
+// checked separately against the warehouses table.
+func (p *UserPreferences) Validate() error {
+	if p.DefaultWarehouseID != nil && *p.DefaultWarehouseID <= 0 {
+		return errors.New("default_warehouse_id must be a warehouse ID")
+	}
+	if !contains(DateFormats, p.DateFormat) {
+		return fmt.Errorf("date_format must be one of %v", DateFormats)
+	}
+	if !contains(LandingPages, p.LandingPage) {
+		return fmt.Errorf("landing_page must be one of %v", LandingPages)
+	}
+	seen := map[string]bool{}
+	for _, channel := range p.NotificationChannels {
+		if !contains(NotificationChannels, channel) {
+			return fmt.Errorf("notification_channels may only contain %v", NotificationChannels)
+		}
+		if seen[channel] {
+			return fmt.Errorf("notification channel %q is listed twice", channel)
+		}
+		seen[channel] = true
+	}
+	return nil
+}
+
+// contains reports whether value is one of values.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// PreferenceStore defines an interface for user preference-related database operations
+type PreferenceStore interface {
+	GetUserIDByEmail(ctx context.Context, email string) (int, error)
+	// GetPreferences returns the user's saved preferences, or the defaults if none are saved.
+	GetPreferences(ctx context.Context, userID int) (*UserPreferences, error)
+	SavePreferences(ctx context.Context, userID int, preferences *UserPreferences) error
+	WarehouseExists(ctx context.Context, id int) (bool, error)
+}
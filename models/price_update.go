@@ -0,0 +1,55 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// PriceRule adjusts the price of matching products. Empty filters match every product.
+// The adjustments are applied in order: Percent, then Amount, then RoundTo.
+type PriceRule struct {
+	Brand      string  `json:"brand,omitempty"`       // Only products of this brand
+	Season     string  `json:"season,omitempty"`      // Only products of this season
+	ProductIDs []int   `json:"product_ids,omitempty"` // Only these products
+	Percent    float64 `json:"percent,omitempty"`     // e.g. 5 for +5%, -10 for -10%
+	Amount     float64 `json:"amount,omitempty"`      // Fixed amount added to the price
+	RoundTo    float64 `json:"round_to,omitempty"`    // Price ending to round up to, e.g. 0.99
+}
+
+// PriceUpdateRequest is a set of price rules to preview or apply.
+type PriceUpdateRequest struct {
+	Rules         []PriceRule `json:"rules"`
+	DryRun        bool        `json:"dry_run"`
+	EffectiveDate *time.Time  `json:"effective_date,omitempty"` // Schedule the change in the price list instead of applying it now
+	Reason        string      `json:"reason"`
+}
+
+// PriceChange is the proposed or applied new price of one product.
+type PriceChange struct {
+	ProductID int     `json:"product_id"`
+	Name      string  `json:"name"`
+	OldPrice  float64 `json:"old_price"`
+	NewPrice  float64 `json:"new_price"`
+}
+
+// PriceUpdateResult describes the outcome of a price update request.
+type PriceUpdateResult struct {
+	DryRun        bool          `json:"dry_run"`
+	Scheduled     bool          `json:"scheduled"`
+	EffectiveDate *time.Time    `json:"effective_date,omitempty"`
+	Changes       []PriceChange `json:"changes"`
+}
+
+// ErrStalePrice is returned when a product's price changed between computing and applying a price update.
+var ErrStalePrice = Conflict("product price changed while the update was being applied")
+
+// PriceListStore defines an interface for bulk and scheduled price changes
+type PriceListStore interface {
+	GetAllProducts(ctx context.Context) ([]Product, error)
+	// ApplyPriceChanges updates the prices and records each change in the audit log in one transaction.
+	ApplyPriceChanges(ctx context.Context, changes []PriceChange, reason, actor string) error
+	// SchedulePriceChanges adds the changes to the price list to take effect on effectiveDate.
+	SchedulePriceChanges(ctx context.Context, changes []PriceChange, effectiveDate time.Time, reason, actor string) error
+	// ApplyDuePriceChanges applies scheduled price list entries whose effective date has been reached.
+	ApplyDuePriceChanges(ctx context.Context, now time.Time) (int, error)
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// PrintedDocument is a business document laid out for printing, such as a delivery note or a
+// purchase order. It is rendered as HTML or PDF by package documents.
+type PrintedDocument struct {
+	Title   string          `json:"title"`  // e.g. "Delivery Note"
+	Number  string          `json:"number"` // Assigned by the numbering service
+	Date    time.Time       `json:"date"`
+	Company *CompanyProfile `json:"company,omitempty"`
+	Parties []DocumentParty `json:"parties,omitempty"` // Addresses, e.g. "Ship to"
+	Details []DocumentField `json:"details,omitempty"` // e.g. the order and tracking number
+	Columns []string        `json:"columns"`
+	Lines   [][]string      `json:"lines"` // One value per column
+	Totals  []DocumentField `json:"totals,omitempty"`
+	Notes   string          `json:"notes,omitempty"`
+}
+
+// DocumentParty is a labelled address block on a document.
+type DocumentParty struct {
+	Label string   `json:"label"`
+	Lines []string `json:"lines"`
+}
+
+// DocumentField is a labelled value on a document.
+type DocumentField struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Lines returns the address as the lines printed on a document.
+func (a Address) Lines() []string {
+	lines := []string{a.Name, a.Line1}
+	if a.Line2 != "" {
+		lines = append(lines, a.Line2)
+	}
+	return append(lines, strings.TrimSpace(a.PostalCode+" "+a.City), a.Country)
+}
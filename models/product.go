@@ -1,5 +1,7 @@
 package models
 
+import "context"
+
 // Product represents a product in the inventory
 type Product struct {
 	ID      int     `json:"id"`
@@ -7,12 +9,18 @@ type Product struct {
 	Brand   string  `json:"brand"`
 	Season  string  `json:"season"`
 	Price   float64 `json:"price"`
+	UnitCost float64 `json:"unit_cost"` // Standard cost used to value stock and cost of goods sold
+	Version int     `json:"version"`   // Incremented by every change, including price list updates
 }
 
 // ProductStore defines an interface for product-related database operations
 type ProductStore interface {
-	CreateProduct(product *Product) error
-	GetProductByID(id int) (*Product, error)
-	UpdateProduct(product *Product) error
-	DeleteProduct(id int) error
+	CreateProduct(ctx context.Context, product *Product) error
+	GetProductByID(ctx context.Context, id int) (*Product, error)
+	// UpdateProduct changes a product if it is still at product.Version, and sets the new
+	// version. It returns a conflict if the product was changed since.
+	UpdateProduct(ctx context.Context, product *Product) error
+	DeleteProduct(ctx context.Context, id int) error
+	// ListProducts returns a page of products and the number matching the filters.
+	ListProducts(ctx context.Context, query ListQuery) ([]Product, int, error)
 }
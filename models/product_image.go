@@ -0,0 +1,30 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// ProductImage is an image attached to a product, with thumbnails in the standard sizes.
+type ProductImage struct {
+	ID          int               `json:"id"`
+	ProductID   int               `json:"product_id"`
+	Filename    string            `json:"filename"`
+	ContentType string            `json:"content_type"`
+	SizeBytes   int               `json:"size_bytes"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	StorageKey  string            `json:"-"`                    // Key of the original in the attachment backend
+	ThumbKeys   map[string]string `json:"-"`                    // Thumbnail size name to storage key
+	URL         string            `json:"url"`                  // Public URL of the original
+	Thumbnails  map[string]string `json:"thumbnails,omitempty"` // Thumbnail size name to public URL
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ProductImageStore defines an interface for product image-related database operations
+type ProductImageStore interface {
+	CreateProductImage(ctx context.Context, image *ProductImage) error
+	GetProductImages(ctx context.Context, productID int) ([]ProductImage, error)
+	GetProductImage(ctx context.Context, productID, imageID int) (*ProductImage, error)
+	DeleteProductImage(ctx context.Context, productID, imageID int) error
+}
@@ -0,0 +1,69 @@
+package models
+
+import "context"
+
+// ProvisionAction is what provisioning did, or would do in a dry run, with one record.
+type ProvisionAction string
+
+const (
+	ProvisionCreated     ProvisionAction = "created"
+	ProvisionUpdated     ProvisionAction = "updated"
+	ProvisionDeactivated ProvisionAction = "deactivated"
+	ProvisionUnchanged   ProvisionAction = "unchanged"
+	ProvisionConflict    ProvisionAction = "conflict" // The record clashes with another user or record
+	ProvisionInvalid     ProvisionAction = "invalid"  // The record is incomplete or maps to an unknown role
+)
+
+// ProvisionRecord is a user as the HR system describes it. The department and, unless a
+// role is given, the title are mapped to ERP values by the provisioning rules.
+type ProvisionRecord struct {
+	ID         int    `json:"-"`           // ERP user to update, when the caller addresses one directly
+	ExternalID string `json:"external_id"` // The user's ID in the HR system
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Title      string `json:"title,omitempty"`
+	Department string `json:"department,omitempty"`
+	Role       string `json:"role,omitempty"` // ERP role; overrides the role rules
+	Active     bool   `json:"active"`
+}
+
+// DirectoryUser is an ERP user as seen by provisioning.
+type DirectoryUser struct {
+	ID         int    `json:"id"`
+	ExternalID string `json:"external_id,omitempty"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	Department string `json:"department"`
+	Active     bool   `json:"active"`
+}
+
+// ProvisionResult is the outcome for one record.
+type ProvisionResult struct {
+	Row        int             `json:"row,omitempty"` // Line of the record in an imported file
+	ExternalID string          `json:"external_id,omitempty"`
+	Email      string          `json:"email"`
+	Action     ProvisionAction `json:"action"`
+	UserID     int             `json:"user_id,omitempty"`
+	Changes    []string        `json:"changes,omitempty"` // e.g. "role: Employee -> Accountant"
+	Detail     string          `json:"detail,omitempty"`  // Why a record was skipped
+	User       *DirectoryUser  `json:"-"`                 // The user after the change
+}
+
+// ProvisionReport lists the outcome of every record of a provisioning run.
+type ProvisionReport struct {
+	DryRun  bool                    `json:"dry_run"`
+	Counts  map[ProvisionAction]int `json:"counts"`
+	Results []ProvisionResult       `json:"results"`
+}
+
+// ProvisioningStore defines an interface for the user directory kept in sync with the HR
+// system.
+type ProvisioningStore interface {
+	ListDirectoryUsers(ctx context.Context) ([]DirectoryUser, error)
+	GetDirectoryUser(ctx context.Context, id int) (*DirectoryUser, error)
+	RoleNames(ctx context.Context) ([]string, error)
+	// SaveDirectoryUsers creates the users without an ID, setting their ID, and updates the
+	// others, in one transaction.
+	SaveDirectoryUsers(ctx context.Context, users []*DirectoryUser) error
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Purchase order statuses. An order is drafted, approved, received in one or more receipts
+// and closed; closing an order that is not fully received means the rest will not come.
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderApproved          = "approved"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+	PurchaseOrderClosed            = "closed"
+)
+
+// PurchaseOrder is an order for products from a supplier, delivered to one warehouse. Each
+// receipt adds the goods to the warehouse's stock, or to quality holds if the order requires
+// inspection, and drafts a pending payment in accounts payable for their cost.
+type PurchaseOrder struct {
+	ID                 int                    `json:"id"`
+	SupplierID         int                    `json:"supplier_id"`
+	WarehouseID        int                    `json:"warehouse_id"` // Where the goods are received
+	Status             string                 `json:"status"`
+	ExpectedOn         *time.Time             `json:"expected_on,omitempty"`
+	Note               string                 `json:"note,omitempty"`
+	InspectionRequired bool                   `json:"inspection_required"` // Receipts are quarantined in quality holds until inspected
+	Lines              []PurchaseOrderLine    `json:"lines"`
+	Total              float64                `json:"total"`
+	Receipts           []PurchaseOrderReceipt `json:"receipts"`
+	CreatedBy          string                 `json:"created_by"`
+	CreatedAt          time.Time              `json:"created_at"`
+	ApprovedBy         string                 `json:"approved_by,omitempty"`
+	ApprovedAt         *time.Time             `json:"approved_at,omitempty"`
+	ClosedBy           string                 `json:"closed_by,omitempty"`
+	ClosedAt           *time.Time             `json:"closed_at,omitempty"`
+}
+
+// PurchaseOrderLine is the quantity of one product ordered, its cost and how much of it has
+// been received.
+type PurchaseOrderLine struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitCost  float64 `json:"unit_cost"`
+	Received  int     `json:"received"`
+}
+
+// Outstanding returns the quantity still to be received.
+func (l PurchaseOrderLine) Outstanding() int {
+	return l.Quantity - l.Received
+}
+
+// PurchaseOrderReceipt is a delivery received against a purchase order and the payment
+// drafted for it.
+type PurchaseOrderReceipt struct {
+	ID         int                        `json:"id"`
+	Lines      []PurchaseOrderReceiptLine `json:"lines"`
+	Amount     float64                    `json:"amount"`     // Cost of the goods received
+	PaymentID  int                        `json:"payment_id"` // The pending payment in accounts payable
+	ReceivedBy string                     `json:"received_by"`
+	ReceivedAt time.Time                  `json:"received_at"`
+}
+
+// PurchaseOrderReceiptLine is the quantity of one product received.
+type PurchaseOrderReceiptLine struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// PurchaseOrderStore defines an interface for purchase order database operations. The
+// status changes lock the order, check its current status and return a conflict if it is
+// not in the expected state.
+type PurchaseOrderStore interface {
+	// CreatePurchaseOrder returns a validation error if the supplier, warehouse or a product
+	// does not exist.
+	CreatePurchaseOrder(ctx context.Context, order *PurchaseOrder) error
+	GetPurchaseOrder(ctx context.Context, id int) (*PurchaseOrder, error)
+	ListPurchaseOrders(ctx context.Context, status string, supplierID int) ([]PurchaseOrder, error)
+	ApprovePurchaseOrder(ctx context.Context, id int, actor string) (*PurchaseOrder, error)
+	// ReceivePurchaseOrder adds the received quantities to the order's warehouse, or puts
+	// them in quality holds if the order requires inspection, records the receipt and drafts
+	// a pending payment for its cost, in one transaction. It returns a conflict if more is
+	// received than is outstanding.
+	ReceivePurchaseOrder(ctx context.Context, id int, lines []PurchaseOrderReceiptLine, actor string) (*PurchaseOrder, error)
+	ClosePurchaseOrder(ctx context.Context, id int, actor string) (*PurchaseOrder, error)
+}
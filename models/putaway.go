@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Putaway velocity classes, from the product's share of the order lines picked recently.
+const (
+	VelocityFast   = "fast"
+	VelocityMedium = "medium"
+	VelocitySlow   = "slow"
+)
+
+// Bin is a storage location in a warehouse. Stock is in a bin when its location is the
+// bin's code.
+type Bin struct {
+	ID          int    `json:"id"`
+	WarehouseID int    `json:"warehouse_id"`
+	Code        string `json:"code"`
+	Zone        string `json:"zone"`
+	Aisle       string `json:"aisle"`
+	Position    int    `json:"position"` // Place along the aisle, counted from its front
+	Capacity    int    `json:"capacity"` // Units the bin holds
+	Distance    int    `json:"distance"` // Walking distance from dispatch; lower is nearer
+	Used        int    `json:"used"`     // Units in the bin
+}
+
+// BinStock is the quantity of a product in a bin.
+type BinStock struct {
+	Code      string `json:"code"`
+	ProductID int    `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// ProductPicks is the number of order lines of a product picked in a period.
+type ProductPicks struct {
+	ProductID int `json:"product_id"`
+	Picks     int `json:"picks"`
+}
+
+// PutawayLine is a product received into a warehouse.
+type PutawayLine struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// PutawayRequest asks where to store the lines of a receipt.
+type PutawayRequest struct {
+	WarehouseID int           `json:"warehouse_id"`
+	Lines       []PutawayLine `json:"lines"`
+}
+
+// PutawaySuggestion is a bin to store part of a received product in.
+type PutawaySuggestion struct {
+	BinID    int    `json:"bin_id"`
+	Code     string `json:"code"`
+	Distance int    `json:"distance"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+}
+
+// PutawayPlan is where to store a received product.
+type PutawayPlan struct {
+	ProductID   int                 `json:"product_id"`
+	Quantity    int                 `json:"quantity"`
+	Picks       int                 `json:"picks"`
+	Velocity    string              `json:"velocity"` // VelocityFast, VelocityMedium or VelocitySlow
+	Suggestions []PutawaySuggestion `json:"suggestions"`
+	Unplaced    int                 `json:"unplaced"` // Units no bin has room for
+}
+
+// PutawayStore defines the operations on bins and the data putaway is planned from.
+type PutawayStore interface {
+	// CreateBin returns a conflict if the warehouse has a bin of the code, or a validation
+	// error if the warehouse does not exist.
+	CreateBin(ctx context.Context, bin *Bin) error
+	UpdateBin(ctx context.Context, bin *Bin) error
+	DeleteBin(ctx context.Context, id int) error
+	// ListBins returns the bins of a warehouse, or of every warehouse if warehouseID is 0,
+	// nearest first, with the units they hold.
+	ListBins(ctx context.Context, warehouseID int) ([]Bin, error)
+	// ListBinStock returns the quantity of each product in each bin of a warehouse.
+	ListBinStock(ctx context.Context, warehouseID int) ([]BinStock, error)
+	// ListProductPicks returns the products picked for orders fulfilled since a time.
+	ListProductPicks(ctx context.Context, since time.Time) ([]ProductPicks, error)
+}
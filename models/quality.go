@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Quality hold statuses. Goods received against a purchase order that requires inspection
+// are quarantined until an inspection releases them into stock, rejects them, or rejects
+// part of them and releases the rest.
+const (
+	HoldQuarantined       = "quarantined"
+	HoldReleased          = "released"
+	HoldRejected          = "rejected"
+	HoldPartiallyRejected = "partially_rejected"
+)
+
+// Inspection results
+const (
+	InspectionPass = "pass"
+	InspectionFail = "fail"
+)
+
+// QualityHold is the quantity of a product from one receipt held in quarantine, outside the
+// warehouse's stock, until it is inspected.
+type QualityHold struct {
+	ID              int         `json:"id"`
+	PurchaseOrderID int         `json:"purchase_order_id"`
+	ReceiptID       int         `json:"receipt_id"`
+	SupplierID      int         `json:"supplier_id"`
+	ProductID       int         `json:"product_id"`
+	WarehouseID     int         `json:"warehouse_id"`
+	Quantity        int         `json:"quantity"`
+	Status          string      `json:"status"`
+	Released        int         `json:"released"` // Quantity added to stock by the inspection
+	Rejected        int         `json:"rejected"` // Quantity returned to the supplier
+	Inspection      *Inspection `json:"inspection,omitempty"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+// Inspection is the quality check of a hold. A pass releases the whole quantity; a fail
+// rejects the rejected quantity, all of it by default, and releases the rest.
+type Inspection struct {
+	SampleSize       int       `json:"sample_size"` // Units checked
+	Result           string    `json:"result"`      // pass or fail
+	RejectedQuantity int       `json:"rejected_quantity"`
+	Notes            string    `json:"notes"`
+	Photos           []string  `json:"photos"` // URLs or storage references of photos of the goods
+	SupplierReturnID int       `json:"supplier_return_id,omitempty"`
+	InspectedBy      string    `json:"inspected_by"`
+	InspectedAt      time.Time `json:"inspected_at"`
+}
+
+// QualityStore defines an interface for quality holds.
+type QualityStore interface {
+	// ListQualityHolds returns the holds in a status, or all of them if status is empty,
+	// optionally of one purchase order.
+	ListQualityHolds(ctx context.Context, status string, purchaseOrderID int) ([]QualityHold, error)
+	GetQualityHold(ctx context.Context, id int) (*QualityHold, error)
+	// InspectQualityHold records the inspection of a quarantined hold, adds the released
+	// quantity to the warehouse's stock and raises a supplier return for the rejected
+	// quantity, in one transaction. It returns a conflict if the hold was inspected already.
+	InspectQualityHold(ctx context.Context, id int, inspection *Inspection) (*QualityHold, error)
+}
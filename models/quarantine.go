@@ -0,0 +1,36 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Upload sources of quarantined files.
+const (
+	UploadJournalAttachment = "journal_attachment"
+	UploadProductImage      = "product_image"
+	UploadHRCaseAttachment  = "hr_case_attachment"
+)
+
+// QuarantinedFile is an upload the virus scanner flagged. The file was rejected and is kept
+// in the private quarantine storage until an admin deletes it.
+type QuarantinedFile struct {
+	ID          int       `json:"id"`
+	Source      string    `json:"source"`    // Where it was uploaded, one of the Upload* constants
+	EntityID    int       `json:"entity_id"` // The record it was uploaded to
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int       `json:"size_bytes"`
+	Signature   string    `json:"signature"` // Name of the malware found by the scanner
+	StorageKey  string    `json:"-"`
+	UploadedBy  string    `json:"uploaded_by"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// QuarantineStore defines the operations for the record of quarantined files.
+type QuarantineStore interface {
+	CreateQuarantinedFile(ctx context.Context, file *QuarantinedFile) error
+	ListQuarantinedFiles(ctx context.Context, limit int) ([]QuarantinedFile, error)
+	GetQuarantinedFile(ctx context.Context, id int) (*QuarantinedFile, error)
+	DeleteQuarantinedFile(ctx context.Context, id int) error
+}
@@ -1,7 +1,10 @@
 // Package models defines the data models for various entities in the ERP system.
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Receivable represents a customer's receivable record, which tracks amounts due from customers.
 type Receivable struct {
@@ -16,9 +19,9 @@ type Receivable struct {
 
 // ReceivableStore is the interface that wraps methods for managing receivable records.
 type ReceivableStore interface {
-	CreateReceivable(receivable *Receivable) error
-	GetReceivableByID(id int) (*Receivable, error)
-	UpdateReceivable(receivable *Receivable) error
-	DeleteReceivable(id int) error
-	GetAllReceivables() ([]Receivable, error)
+	CreateReceivable(ctx context.Context, receivable *Receivable) error
+	GetReceivableByID(ctx context.Context, id int) (*Receivable, error)
+	UpdateReceivable(ctx context.Context, receivable *Receivable) error
+	DeleteReceivable(ctx context.Context, id int) error
+	GetAllReceivables(ctx context.Context) ([]Receivable, error)
 }
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Reconciliation worksheet statuses. A signed-off worksheet keeps the figures it was signed
+// off with and cannot change.
+const (
+	ReconciliationOpen      = "open"
+	ReconciliationSignedOff = "signed_off"
+)
+
+// Reconciliation is the worksheet reconciling a control account with its subledger at the
+// end of a month. Amounts are in the account's normal balance: debits for receivables and
+// bank, credits for payables.
+type Reconciliation struct {
+	ID               int               `json:"id"`
+	Account          string            `json:"account"`
+	Period           string            `json:"period"`                      // YYYY-MM
+	StatementBalance *float64          `json:"statement_balance,omitempty"` // Bank statement closing balance; the bank's subledger
+	GLBalance        float64           `json:"gl_balance"`
+	SubledgerBalance float64           `json:"subledger_balance"`
+	Difference       float64           `json:"difference"`  // Subledger less general ledger
+	Explained        float64           `json:"explained"`   // Sum of the reconciling items
+	Unexplained      float64           `json:"unexplained"` // Difference the reconciling items do not explain
+	Items            []ReconcilingItem `json:"items"`
+	Status           string            `json:"status"`
+	PreparedBy       string            `json:"prepared_by"`
+	PreparedAt       time.Time         `json:"prepared_at"`
+	SignedOffBy      string            `json:"signed_off_by,omitempty"`
+	SignedOffAt      *time.Time        `json:"signed_off_at,omitempty"`
+	SignOffNote      string            `json:"sign_off_note,omitempty"`
+}
+
+// ReconcilingItem explains part of the difference on a worksheet, such as a deposit in
+// transit or a journal posted to the control account by hand.
+type ReconcilingItem struct {
+	ID          int       `json:"id"`
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SubledgerLine is an open document of a control account's subledger at the end of a month:
+// a customer invoice for receivables or a supplier bill for payables.
+type SubledgerLine struct {
+	Document  string    `json:"document"` // "invoice" or "supplier_bill"
+	ID        int       `json:"id"`
+	Party     string    `json:"party"` // Customer or supplier
+	Reference string    `json:"reference,omitempty"`
+	Date      time.Time `json:"date"` // Date of the first posting, or the bill date
+	Amount    float64   `json:"amount"`
+}
+
+// ReconciliationStore defines the operations for reconciliation worksheets.
+type ReconciliationStore interface {
+	// CreateReconciliation returns a conflict if the account already has a worksheet for
+	// the period.
+	CreateReconciliation(ctx context.Context, r *Reconciliation) error
+	GetReconciliation(ctx context.Context, id int) (*Reconciliation, error)
+	// ListReconciliations filters on any of account, period and status that are not empty.
+	ListReconciliations(ctx context.Context, account, period, status string) ([]Reconciliation, error)
+	// SetStatementBalance replaces the statement balance of an open worksheet.
+	SetStatementBalance(ctx context.Context, id int, balance float64) error
+	// AddReconcilingItem and DeleteReconcilingItem return a conflict unless the worksheet
+	// is open.
+	AddReconcilingItem(ctx context.Context, reconciliationID int, item *ReconcilingItem) error
+	DeleteReconcilingItem(ctx context.Context, reconciliationID, itemID int) error
+	// SignOffReconciliation stores the worksheet's figures, marks it signed off and records
+	// the sign-off in the audit log. It returns a conflict if the worksheet is not open or
+	// its reconciling items no longer add up to r.Explained.
+	SignOffReconciliation(ctx context.Context, r *Reconciliation, actor, note string) error
+
+	// LedgerBalance returns the debits less credits of an account up to the end of a month.
+	LedgerBalance(ctx context.Context, account string, period time.Time) (float64, error)
+	// LedgerLines returns the postings to an account from first to last, oldest first.
+	LedgerLines(ctx context.Context, account string, first, last time.Time) ([]FinancialTransaction, error)
+	// ReceivableLines returns the customer invoices with a receivable balance at the end of
+	// the day, from the postings to the receivable account that carry the invoice.
+	ReceivableLines(ctx context.Context, account string, end time.Time) ([]SubledgerLine, error)
+	// PayableLines returns the supplier bills dated up to end, less the advances applied.
+	PayableLines(ctx context.Context, end time.Time) ([]SubledgerLine, error)
+}
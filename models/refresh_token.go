@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshToken is a long-lived credential a client exchanges for a new access token. Only
+// its hash is stored. Each use spends the token and issues the next one of its family, so a
+// spent token that is presented again has been copied, and its whole family is revoked.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	Email     string // Email of the user, read with the token
+	Family    string // Shared by the tokens issued from the same sign-in
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// RefreshRequest is the body of a refresh or logout request.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	AllSessions  bool   `json:"all_sessions,omitempty"` // On logout, revoke every refresh token of the user
+}
+
+// TokenStore defines the operations for refresh tokens and revoked access tokens.
+type TokenStore interface {
+	// CreateRefreshToken stores the first refresh token of a family for the user with the
+	// token's email; the ID and user ID are set.
+	CreateRefreshToken(ctx context.Context, t *RefreshToken) error
+	// RotateRefreshToken spends the unexpired refresh token with the hash and stores next in
+	// its family. It returns the spent token, with its user's email. If the token had been
+	// spent or revoked already, it revokes the family instead.
+	RotateRefreshToken(ctx context.Context, hash string, next *RefreshToken, now time.Time) (*RefreshToken, error)
+	// RevokeRefreshTokens revokes the family of the user's refresh token with the hash, or
+	// every refresh token of the user if hash is empty.
+	RevokeRefreshTokens(ctx context.Context, email, hash string, now time.Time) error
+	// RevokeAccessToken puts an access token on the revocation list until it expires.
+	RevokeAccessToken(ctx context.Context, id string, expiresAt time.Time) error
+	// IsTokenRevoked reports whether an access token is on the revocation list.
+	IsTokenRevoked(ctx context.Context, id string) (bool, error)
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Kinds of cash movements in a register session
+const (
+	CashPayIn  = "pay_in"  // Cash put into the drawer, e.g. extra change
+	CashPayOut = "pay_out" // Cash taken out of the drawer, e.g. a drop to the safe
+)
+
+// Errors returned by register sessions
+var (
+	ErrRegisterOpen   = Conflict("the register already has an open session")
+	ErrRegisterClosed = NotFound("the register has no open session")
+)
+
+// RegisterSession is one shift of a cash register, from opening with a float of cash to
+// closing with the cash counted in the drawer.
+type RegisterSession struct {
+	ID           int            `json:"id"`
+	Register     string         `json:"register"`
+	OpeningFloat float64        `json:"opening_float"`
+	OpenedBy     string         `json:"opened_by"`
+	OpenedAt     time.Time      `json:"opened_at"`
+	CashSales    float64        `json:"cash_sales"`    // Cash taken by POS sales during the session
+	ExpectedCash float64        `json:"expected_cash"` // Float plus cash sales and pay-ins, less pay-outs
+	CountedCash  *float64       `json:"counted_cash,omitempty"`
+	Variance     *float64       `json:"variance,omitempty"` // Counted less expected; negative when short
+	ClosedBy     string         `json:"closed_by,omitempty"`
+	ClosedAt     *time.Time     `json:"closed_at,omitempty"`
+	Movements    []CashMovement `json:"movements"`
+}
+
+// CashMovement is cash put into or taken out of a register outside of sales.
+type CashMovement struct {
+	ID        int       `json:"id"`
+	SessionID int       `json:"session_id"`
+	Kind      string    `json:"kind"`
+	Amount    float64   `json:"amount"` // Always positive; Kind gives the direction
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ZReport is the end-of-day summary of a register: its sales by payment method and the
+// sessions opened that day with their variances.
+type ZReport struct {
+	Register   string             `json:"register"`
+	Date       string             `json:"date"` // YYYY-MM-DD
+	SalesCount int                `json:"sales_count"`
+	Sales      map[string]float64 `json:"sales"` // Totals by payment method
+	Total      float64            `json:"total"`
+	Sessions   []RegisterSession  `json:"sessions"`
+	Variance   float64            `json:"variance"` // Sum over the closed sessions
+}
+
+// RegisterStore defines an interface for cash register session database operations
+type RegisterStore interface {
+	// OpenSession starts a session; it returns ErrRegisterOpen if one is already open.
+	OpenSession(ctx context.Context, session *RegisterSession) error
+	// GetOpenSession returns the open session of a register with its expected cash so far.
+	GetOpenSession(ctx context.Context, register string) (*RegisterSession, error)
+	// AddCashMovement records a pay-in or pay-out in the register's open session.
+	AddCashMovement(ctx context.Context, register string, movement *CashMovement) error
+	// CloseSession closes the register's open session with the cash counted, records the
+	// variance and posts it to the over or short account, in one transaction.
+	CloseSession(ctx context.Context, register string, countedCash float64, closedBy string, closedAt time.Time) (*RegisterSession, error)
+	// GetZReport summarizes a register's sales and sessions on the day of date.
+	GetZReport(ctx context.Context, register string, date time.Time) (*ZReport, error)
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Names of the summary tables tracked for freshness
+const (
+	ReportTrialBalance = "trial_balance"
+	ReportARAging      = "ar_aging"
+)
+
+// Freshness tells report consumers how current the underlying summary data is.
+type Freshness struct {
+	RefreshedAt time.Time `json:"refreshed_at"`
+	AgeSeconds  int64     `json:"age_seconds"`
+	Stale       bool      `json:"stale"`
+}
+
+// TrialBalanceLine is the cumulative debit and credit total of one account.
+type TrialBalanceLine struct {
+	AccountType string  `json:"account_type"`
+	Debit       float64 `json:"debit"`
+	Credit      float64 `json:"credit"`
+	Balance     float64 `json:"balance"` // Debit minus credit
+}
+
+// TrialBalance lists account balances up to and including a period.
+type TrialBalance struct {
+	AsOf        string             `json:"as_of"` // Period in YYYY-MM format
+	Lines       []TrialBalanceLine `json:"lines"`
+	TotalDebit  float64            `json:"total_debit"`
+	TotalCredit float64            `json:"total_credit"`
+	Freshness   Freshness          `json:"freshness"`
+	Company     *CompanyProfile    `json:"company,omitempty"`
+}
+
+// CustomerAging is the outstanding receivable amount of one customer split by days overdue.
+type CustomerAging struct {
+	CustomerName string  `json:"customer_name"`
+	Current      float64 `json:"current"` // Not yet due
+	Days1To30    float64 `json:"days_1_30"`
+	Days31To60   float64 `json:"days_31_60"`
+	Days61To90   float64 `json:"days_61_90"`
+	Over90       float64 `json:"over_90"`
+	Total        float64 `json:"total"`
+}
+
+// ARAgingReport lists outstanding receivables per customer.
+type ARAgingReport struct {
+	Customers []CustomerAging `json:"customers"`
+	Total     float64         `json:"total"`
+	Freshness Freshness       `json:"freshness"`
+	Company   *CompanyProfile `json:"company,omitempty"`
+}
+
+// Groupings of the profitability report
+const (
+	ProfitabilityByProduct  = "product"
+	ProfitabilityByCustomer = "customer"
+)
+
+// ProfitabilityLine is the margin earned on one product or customer over a date range.
+// Sales without a product or customer, such as walk-in POS sales, are grouped under ID 0.
+type ProfitabilityLine struct {
+	ID                int                  `json:"id"`
+	Name              string               `json:"name"`
+	Quantity          int                  `json:"quantity"`
+	GrossRevenue      float64              `json:"gross_revenue"` // Before discounts
+	Discounts         float64              `json:"discounts"`
+	NetRevenue        float64              `json:"net_revenue"`
+	COGS              float64              `json:"cogs"` // Quantity sold at the products' unit cost
+	GrossMargin       float64              `json:"gross_margin"`
+	GrossMarginPct    float64              `json:"gross_margin_pct"`   // Gross margin as a percentage of net revenue
+	AllocatedExpenses float64              `json:"allocated_expenses"` // Share of the period's expenses by net revenue
+	NetMargin         float64              `json:"net_margin"`
+	Deltas            *ProfitabilityDeltas `json:"deltas,omitempty"` // Change from the comparison period
+}
+
+// Period comparison modes of the date-range reports
+const (
+	ComparePreviousPeriod = "previous_period" // The same number of days just before the range
+	ComparePreviousYear   = "previous_year"   // The same dates a year earlier
+)
+
+// Delta is the change of an amount from the comparison period to the current one.
+type Delta struct {
+	Previous float64  `json:"previous"`
+	Change   float64  `json:"change"`
+	Percent  *float64 `json:"percent"` // Change as a percentage of the previous amount; null if it was 0
+}
+
+// ProfitabilityDeltas are the changes of a profitability line's amounts.
+type ProfitabilityDeltas struct {
+	Quantity          Delta `json:"quantity"`
+	GrossRevenue      Delta `json:"gross_revenue"`
+	Discounts         Delta `json:"discounts"`
+	NetRevenue        Delta `json:"net_revenue"`
+	COGS              Delta `json:"cogs"`
+	GrossMargin       Delta `json:"gross_margin"`
+	AllocatedExpenses Delta `json:"allocated_expenses"`
+	NetMargin         Delta `json:"net_margin"`
+}
+
+// ProfitabilityReport lists gross and net margins per product or per customer.
+type ProfitabilityReport struct {
+	GroupBy       string               `json:"group_by"`
+	From          string               `json:"from"` // YYYY-MM-DD, inclusive
+	To            string               `json:"to"`   // YYYY-MM-DD, inclusive
+	Lines         []ProfitabilityLine  `json:"lines"`
+	Expenses      float64              `json:"expenses"` // Expenses booked in the range, before allocation
+	Total         ProfitabilityLine    `json:"total"`
+	Company       *CompanyProfile      `json:"company,omitempty"`
+	Compare       string               `json:"compare,omitempty"`  // Comparison mode, one of the Compare* constants
+	Previous      *ProfitabilityReport `json:"previous,omitempty"` // The report for the comparison period
+	ExpensesDelta *Delta               `json:"expenses_delta,omitempty"`
+}
+
+// JournalAdjustment is a posted manual journal entry as listed for auditors.
+type JournalAdjustment struct {
+	ID            int       `json:"id"`
+	EntryDate     string    `json:"entry_date"` // YYYY-MM-DD
+	Description   string    `json:"description"`
+	Justification string    `json:"justification"`
+	AttachmentURL string    `json:"attachment_url,omitempty"`
+	PostedBy      string    `json:"posted_by"`
+	PostedAt      time.Time `json:"posted_at"`
+	Total         float64   `json:"total"` // Sum of the debits
+}
+
+// JournalAdjustmentReport lists the manual adjustments dated in a range and why they were made.
+type JournalAdjustmentReport struct {
+	From        string              `json:"from"` // YYYY-MM-DD, inclusive
+	To          string              `json:"to"`   // YYYY-MM-DD, inclusive
+	Adjustments []JournalAdjustment `json:"adjustments"`
+	Total       float64             `json:"total"`
+	Company     *CompanyProfile     `json:"company,omitempty"`
+}
+
+// ReportStore defines an interface for reading and refreshing report summary tables
+type ReportStore interface {
+	// GetTrialBalance returns account balances for all periods up to and including asOf
+	// (the first day of a month) and the time the summary was last updated.
+	GetTrialBalance(ctx context.Context, asOf time.Time) ([]TrialBalanceLine, time.Time, error)
+	// GetARAging returns the outstanding amounts per customer and the time they were computed.
+	GetARAging(ctx context.Context) ([]CustomerAging, time.Time, error)
+	RefreshAccountBalances(ctx context.Context) error
+	RefreshCustomerOutstanding(ctx context.Context, asOf time.Time) error
+	// GetProfitability returns the quantity, gross revenue, discounts and COGS of the sales
+	// between from and to (inclusive dates) grouped by product or customer, and the total
+	// of the expense accounts over the same range. Margins are left for the caller.
+	GetProfitability(ctx context.Context, groupBy string, from, to time.Time) ([]ProfitabilityLine, float64, error)
+	// GetJournalAdjustments returns the posted journal entries dated between from and to
+	// (inclusive), oldest first.
+	GetJournalAdjustments(ctx context.Context, from, to time.Time) ([]JournalAdjustment, error)
+	// GetSoDViolations returns the segregation-of-duties violations that occurred between
+	// from and to (inclusive dates), oldest first.
+	GetSoDViolations(ctx context.Context, from, to time.Time) ([]SoDViolation, error)
+	// GetInventoryTurnover returns, for every product, the units issued from from to before
+	// to, the units on hand now, the average units on hand in the stock snapshots taken over
+	// the same range (the units on hand now if none were taken), and when its stock last
+	// moved. Values and ratios are left for the caller.
+	GetInventoryTurnover(ctx context.Context, from, to time.Time) ([]InventoryTurnoverLine, error)
+}
+
+// Groupings of the inventory turnover report. Products are categorized by brand.
+const (
+	TurnoverByProduct = "product"
+	TurnoverByBrand   = "brand"
+)
+
+// InventoryTurnoverLine is how fast the stock of a product, or of a brand, was sold over a
+// period.
+type InventoryTurnoverLine struct {
+	ID            int        `json:"id,omitempty"` // Product; 0 for brands
+	Name          string     `json:"name"`         // Product or brand name
+	Brand         string     `json:"brand,omitempty"`
+	UnitCost      float64    `json:"unit_cost,omitempty"`
+	UnitsIssued   int        `json:"units_issued"` // Fulfilled on sales orders and sold at the POS
+	COGS          float64    `json:"cogs"`         // Units issued at the unit cost
+	OnHand        int        `json:"on_hand"`
+	Value         float64    `json:"value"`           // Units on hand at the unit cost
+	AverageOnHand float64    `json:"average_on_hand"` // Over the period's snapshots, or on hand now without any
+	AverageValue  float64    `json:"average_value"`   // Average units on hand at the unit cost
+	Turnover      float64    `json:"turnover"`        // COGS over the average value; 0 without stock
+	LastMovement  *time.Time `json:"last_movement"`   // Last transfer, sale or receipt
+	IdleDays      int        `json:"idle_days"`       // Days since the last movement; 0 if it never moved
+	Dead          bool       `json:"dead,omitempty"`  // Stock on hand that has not moved in the dead stock days
+}
+
+// InventoryTurnoverReport is the turnover of every product or brand over a period, with the
+// products whose stock has not moved for a while, so it can be cleared.
+type InventoryTurnoverReport struct {
+	Period         string                  `json:"period"`
+	From           string                  `json:"from"` // YYYY-MM-DD
+	To             string                  `json:"to"`   // YYYY-MM-DD, inclusive
+	GroupBy        string                  `json:"group_by"`
+	Lines          []InventoryTurnoverLine `json:"lines"`
+	COGS           float64                 `json:"cogs"`
+	AverageValue   float64                 `json:"average_value"`
+	Turnover       float64                 `json:"turnover"`
+	DeadStockDays  int                     `json:"dead_stock_days"`
+	DeadStock      []InventoryTurnoverLine `json:"dead_stock"` // Products, most valuable first
+	DeadStockValue float64                 `json:"dead_stock_value"`
+	Company        *CompanyProfile         `json:"company,omitempty"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Report builder filter operators.
+const (
+	FilterEq       = "eq"
+	FilterNe       = "ne"
+	FilterLt       = "lt"
+	FilterLte      = "lte"
+	FilterGt       = "gt"
+	FilterGte      = "gte"
+	FilterIn       = "in"
+	FilterContains = "contains" // Case-insensitive substring match on text fields
+	FilterIsNull   = "is_null"  // Value true matches empty fields, false the others
+)
+
+// Report builder aggregate functions.
+const (
+	AggregateCount = "count"
+	AggregateSum   = "sum"
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+)
+
+// ReportQuery describes a custom tabular report over one entity. Every name is checked
+// against the report builder's whitelist before the report runs.
+type ReportQuery struct {
+	Entity     string            `json:"entity"`
+	Columns    []string          `json:"columns"`
+	Filters    []ReportFilter    `json:"filters,omitempty"`
+	GroupBy    []string          `json:"group_by,omitempty"`
+	Aggregates []ReportAggregate `json:"aggregates,omitempty"`
+	OrderBy    []ReportOrder     `json:"order_by,omitempty"`
+	Limit      int               `json:"limit,omitempty"` // Defaults to the builder's maximum
+}
+
+// ReportFilter restricts the rows of a custom report. Filters are combined with AND.
+type ReportFilter struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`    // One of the Filter* operators
+	Value interface{} `json:"value"` // A list for FilterIn, a boolean for FilterIsNull
+}
+
+// ReportAggregate is a computed column of a grouped report, named e.g. "sum_amount" or "count".
+type ReportAggregate struct {
+	Func  string `json:"func"`            // One of the Aggregate* functions
+	Field string `json:"field,omitempty"` // Not needed for count
+}
+
+// ReportOrder sorts a custom report by one of its output columns.
+type ReportOrder struct {
+	Column     string `json:"column"`
+	Descending bool   `json:"descending,omitempty"`
+}
+
+// ReportDefinition is a saved custom report.
+type ReportDefinition struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Query       ReportQuery `json:"query"`
+	CreatedBy   string      `json:"created_by"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// ReportResult is the output of a custom report.
+type ReportResult struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// ReportDefinitionStore defines the operations for saved custom reports.
+type ReportDefinitionStore interface {
+	CreateReportDefinition(ctx context.Context, definition *ReportDefinition) error
+	ListReportDefinitions(ctx context.Context) ([]ReportDefinition, error)
+	GetReportDefinition(ctx context.Context, id int) (*ReportDefinition, error)
+	UpdateReportDefinition(ctx context.Context, definition *ReportDefinition) error
+	DeleteReportDefinition(ctx context.Context, id int) error
+}
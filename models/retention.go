@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// RetentionPolicy is how long the records of one data class are kept before the nightly
+// purge deletes them. Financial classes are kept at least MinimumDays whatever the policy.
+type RetentionPolicy struct {
+	DataClass     string     `json:"data_class"`
+	Description   string     `json:"description"`
+	RetentionDays *int       `json:"retention_days"` // nil keeps the records forever
+	Default       bool       `json:"default"`        // Whether the built-in policy applies
+	Financial     bool       `json:"financial"`
+	MinimumDays   int        `json:"minimum_days,omitempty"` // Legal minimum for financial classes
+	UpdatedBy     string     `json:"updated_by,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+// RetentionPurge records what one purge run deleted from a data class.
+type RetentionPurge struct {
+	ID            int       `json:"id"`
+	JobID         *int      `json:"job_id,omitempty"`
+	DataClass     string    `json:"data_class"`
+	RetentionDays int       `json:"retention_days"` // Period applied, after the legal minimum
+	Cutoff        time.Time `json:"cutoff"`         // Records older than this were deleted
+	RowsDeleted   int64     `json:"rows_deleted"`
+	Actor         string    `json:"actor"` // Email of the admin, or "scheduler"
+	PurgedAt      time.Time `json:"purged_at"`
+}
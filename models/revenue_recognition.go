@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// RevenueSchedule recognizes the revenue of an invoice straight-line over a number of
+// months, e.g. for an annual service contract. It is set on a draft invoice; when the
+// invoice is posted its revenue is deferred and the monthly lines are generated.
+type RevenueSchedule struct {
+	InvoiceID  int           `json:"invoice_id"`
+	CustomerID int           `json:"customer_id"`
+	Months     int           `json:"months"`
+	StartMonth *time.Time    `json:"start_month,omitempty"` // First month recognized; the posting month if not set
+	Amount     float64       `json:"amount"`                // Revenue deferred, set when the invoice is posted
+	Recognized float64       `json:"recognized"`
+	PostedOn   *time.Time    `json:"posted_on,omitempty"`
+	Lines      []RevenueLine `json:"lines"`
+	CreatedBy  string        `json:"created_by,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// RevenueLine is the revenue of a schedule recognized in one month.
+type RevenueLine struct {
+	ID           int        `json:"id"`
+	Month        time.Time  `json:"month"` // First day of the month
+	Amount       float64    `json:"amount"`
+	RecognizedOn *time.Time `json:"recognized_on,omitempty"` // Ledger date of the recognition, the month's last day
+}
+
+// RecognitionRun is the result of recognizing the revenue due up to a month.
+type RecognitionRun struct {
+	Period   string  `json:"period"` // e.g. "2024-05"
+	Invoices int     `json:"invoices"`
+	Lines    int     `json:"lines"`
+	Amount   float64 `json:"amount"`
+}
+
+// DeferredRevenue is what is still deferred of one invoice's revenue.
+type DeferredRevenue struct {
+	InvoiceID  int     `json:"invoice_id"`
+	CustomerID int     `json:"customer_id"`
+	Amount     float64 `json:"amount"`
+	Recognized float64 `json:"recognized"`
+	Deferred   float64 `json:"deferred"`
+}
+
+// DeferredRevenueReport lists the revenue still deferred on a day and reconciles its total
+// with the balance of the deferred revenue account in the ledger.
+type DeferredRevenueReport struct {
+	AsOf       time.Time         `json:"as_of"`
+	Invoices   []DeferredRevenue `json:"invoices"`
+	Scheduled  float64           `json:"scheduled"`  // Deferred according to the schedules
+	Ledger     float64           `json:"ledger"`     // Credit balance of the deferred revenue account
+	Difference float64           `json:"difference"` // Ledger minus scheduled; 0 when they reconcile
+}
+
+// RevenueScheduleStore defines an interface for revenue recognition database operations
+type RevenueScheduleStore interface {
+	// SetRevenueSchedule sets or replaces the schedule of an invoice. It returns
+	// models.ErrNotFound if the invoice does not exist and models.ErrDocumentLocked if it
+	// is not a draft.
+	SetRevenueSchedule(ctx context.Context, schedule *RevenueSchedule) error
+	// DeleteRevenueSchedule returns models.ErrDocumentLocked if the invoice is not a draft.
+	DeleteRevenueSchedule(ctx context.Context, invoiceID int) error
+	GetRevenueSchedule(ctx context.Context, invoiceID int) (*RevenueSchedule, error)
+	// RecognizeRevenue posts the unrecognized lines of months up to and including the given
+	// one, each dated the last day of its month.
+	RecognizeRevenue(ctx context.Context, through time.Time) (*RecognitionRun, error)
+	// GetDeferredRevenue returns the posted schedules with revenue deferred on a day, and the
+	// balance of the deferred revenue account on that day.
+	GetDeferredRevenue(ctx context.Context, asOf time.Time) ([]DeferredRevenue, float64, error)
+}
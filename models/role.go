@@ -1,5 +1,7 @@
 package models
 
+import "context"
+
 // Role represents a role in the system
 type Role struct {
 	ID          int    `json:"id"`
@@ -9,6 +11,6 @@ type Role struct {
 
 // RoleStore defines an interface for role-related database operations
 type RoleStore interface {
-	GetRoleByID(id int) (*Role, error)
-	GetRoleByName(roleName string) (*Role, error)
+	GetRoleByID(ctx context.Context, id int) (*Role, error)
+	GetRoleByName(ctx context.Context, roleName string) (*Role, error)
 }
@@ -1,20 +1,63 @@
 package models
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
-// SalesOrder represents a sales order in the system
+// Sales order statuses. An order is drafted, confirmed once its stock is available (which
+// reserves it) and fulfilled when the goods are shipped; it can be cancelled until it is
+// fulfilled.
+const (
+	SalesOrderDraft     = "draft"
+	SalesOrderConfirmed = "confirmed"
+	SalesOrderFulfilled = "fulfilled"
+	SalesOrderCancelled = "cancelled"
+)
+
+// SalesOrder is a customer's order for one or more products. Invoices reference it by ID.
 type SalesOrder struct {
-	ID         int       `json:"id"`
-	CustomerID int       `json:"customer_id"`
-	ProductID  int       `json:"product_id"`
-	OrderDate  time.Time `json:"order_date"`
-	Quantity   int       `json:"quantity"`
+	ID              int              `json:"id"`
+	CustomerID      int              `json:"customer_id"`
+	OrderDate       time.Time        `json:"order_date"`
+	Status          string           `json:"status"`
+	Note            string           `json:"note,omitempty"`
+	Lines           []SalesOrderLine `json:"lines"`
+	Total           float64          `json:"total"`
+	CreatedBy       string           `json:"created_by,omitempty"`
+	CreatedAt       *time.Time       `json:"created_at,omitempty"` // Unset for orders taken before sales orders had lines
+	ConfirmedBy     string           `json:"confirmed_by,omitempty"`
+	ConfirmedAt     *time.Time       `json:"confirmed_at,omitempty"`
+	FulfilledBy     string           `json:"fulfilled_by,omitempty"`
+	FulfilledAt     *time.Time       `json:"fulfilled_at,omitempty"`
+	CancelledBy     string           `json:"cancelled_by,omitempty"`
+	CancelledAt     *time.Time       `json:"cancelled_at,omitempty"`
+	StandingOrderID *int             `json:"standing_order_id,omitempty"` // Set on orders generated from a standing order
+	DeliveryDate    *time.Time       `json:"delivery_date,omitempty"`     // Day a standing order's delivery is due
+}
+
+// SalesOrderLine is the quantity of one product on a sales order and its price.
+type SalesOrderLine struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"` // The product's price when the order was created, unless given
+	Amount    float64 `json:"amount"`     // Quantity times unit price
 }
 
-// SalesOrderStore defines an interface for sales order-related database operations
+// SalesOrderStore defines an interface for sales order database operations. The status
+// changes lock the order, check its current status and return a conflict if it is not in
+// the expected state.
 type SalesOrderStore interface {
-	CreateSalesOrder(order *SalesOrder) error
-	GetSalesOrderByID(id int) (*SalesOrder, error)
-	UpdateSalesOrder(order *SalesOrder) error
-	DeleteSalesOrder(id int) error
+	// CreateSalesOrder records a draft order; lines without a unit price take the product's
+	// price. It returns a validation error if the customer or a product does not exist, and
+	// a conflict if a standing order's delivery already has its order.
+	CreateSalesOrder(ctx context.Context, order *SalesOrder) error
+	GetSalesOrder(ctx context.Context, id int) (*SalesOrder, error)
+	ListSalesOrders(ctx context.Context, status string, customerID int) ([]SalesOrder, error)
+	// ConfirmSalesOrder reserves the stock of every line.
+	ConfirmSalesOrder(ctx context.Context, id int, actor string) (*SalesOrder, error)
+	// FulfillSalesOrder takes every line's stock and marks its reservations fulfilled.
+	FulfillSalesOrder(ctx context.Context, id int, actor string) (*SalesOrder, error)
+	// CancelSalesOrder releases the order's reservations.
+	CancelSalesOrder(ctx context.Context, id int, actor string) (*SalesOrder, error)
 }
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SandboxCapture is an email or webhook that a sandbox recorded instead of sending.
+type SandboxCapture struct {
+	ID         int             `json:"id"`
+	Kind       string          `json:"kind"`      // Outbox kind, e.g. "email"
+	Recipient  string          `json:"recipient"` // Email addresses or webhook URL
+	Subject    string          `json:"subject,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+	CapturedAt time.Time       `json:"captured_at"`
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Keys of the organization-wide settings
+const (
+	SettingCompanyName            = "company.name"
+	SettingCompanyAddress         = "company.address"
+	SettingCompanyLogoURL         = "company.logo_url"
+	SettingDefaultCurrency        = "finance.default_currency"
+	SettingTaxRegistrationNumbers = "finance.tax_registration_numbers"
+	SettingInvoiceFooter          = "documents.invoice_footer"
+)
+
+// Value types of settings
+const (
+	SettingTypeString   = "string"   // Single line of text
+	SettingTypeText     = "text"     // Multi-line text
+	SettingTypeURL      = "url"      // Absolute http(s) URL or a path on this server
+	SettingTypeCurrency = "currency" // ISO 4217 currency code
+	SettingTypeLabels   = "labels"   // Map of labels to values, e.g. {"VAT": "123"}
+)
+
+// SettingDefinition describes a setting: its type, default value and length limit.
+type SettingDefinition struct {
+	Key       string      `json:"key"`
+	Type      string      `json:"type"`
+	Default   interface{} `json:"default"`
+	MaxLength int         `json:"max_length,omitempty"`
+	Required  bool        `json:"required"` // Whether the value may be empty
+}
+
+// SettingDefinitions lists every known setting. Values for other keys are rejected.
+var SettingDefinitions = map[string]SettingDefinition{
+	SettingCompanyName:            {Key: SettingCompanyName, Type: SettingTypeString, Default: "My Company", MaxLength: 200, Required: true},
+	SettingCompanyAddress:         {Key: SettingCompanyAddress, Type: SettingTypeText, Default: "", MaxLength: 500},
+	SettingCompanyLogoURL:         {Key: SettingCompanyLogoURL, Type: SettingTypeURL, Default: "", MaxLength: 500},
+	SettingDefaultCurrency:        {Key: SettingDefaultCurrency, Type: SettingTypeCurrency, Default: "USD", Required: true},
+	SettingTaxRegistrationNumbers: {Key: SettingTaxRegistrationNumbers, Type: SettingTypeLabels, Default: map[string]string{}, MaxLength: 100},
+	SettingInvoiceFooter:          {Key: SettingInvoiceFooter, Type: SettingTypeText, Default: "", MaxLength: 1000},
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Validate decodes a raw JSON value for the setting and checks it against the setting's
+// type. It returns the value normalized for storage.
+func (d SettingDefinition) Validate(raw json.RawMessage) (json.RawMessage, error) {
+	if d.Type == SettingTypeLabels {
+		var labels map[string]string
+		if err := json.Unmarshal(raw, &labels); err != nil || labels == nil {
+			return nil, fmt.Errorf("%s must be an object of text values", d.Key)
+		}
+		for label, value := range labels {
+			if strings.TrimSpace(label) == "" || strings.TrimSpace(value) == "" {
+				return nil, fmt.Errorf("%s may not contain empty labels or values", d.Key)
+			}
+			if len(label) > d.MaxLength || len(value) > d.MaxLength {
+				return nil, fmt.Errorf("%s entries are limited to %d characters", d.Key, d.MaxLength)
+			}
+		}
+		return json.Marshal(labels)
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("%s must be a string", d.Key)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if d.Required {
+			return nil, fmt.Errorf("%s is required", d.Key)
+		}
+		return json.Marshal(value)
+	}
+	if d.MaxLength > 0 && len(value) > d.MaxLength {
+		return nil, fmt.Errorf("%s is limited to %d characters", d.Key, d.MaxLength)
+	}
+
+	switch d.Type {
+	case SettingTypeString:
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%s must be a single line", d.Key)
+		}
+	case SettingTypeURL:
+		u, err := url.Parse(value)
+		if err != nil || !(u.Scheme == "http" || u.Scheme == "https" || (u.Scheme == "" && strings.HasPrefix(value, "/"))) {
+			return nil, fmt.Errorf("%s must be an http(s) URL or a path", d.Key)
+		}
+	case SettingTypeCurrency:
+		value = strings.ToUpper(value)
+		if !currencyCode.MatchString(value) {
+			return nil, fmt.Errorf("%s must be a three-letter ISO 4217 code", d.Key)
+		}
+	}
+	return json.Marshal(value)
+}
+
+// ErrUnknownSetting is returned for keys that are not in SettingDefinitions.
+var ErrUnknownSetting = Invalid("unknown setting")
+
+// InvalidSettingsError is returned when a settings update is refused. Fields maps each
+// rejected key to the reason; nothing in the update is saved.
+type InvalidSettingsError struct {
+	Fields map[string]string `json:"invalid"`
+}
+
+func (e *InvalidSettingsError) Error() string {
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	reasons := make([]string, len(keys))
+	for i, key := range keys {
+		reasons[i] = fmt.Sprintf("%s: %s", key, e.Fields[key])
+	}
+	return "invalid settings: " + strings.Join(reasons, "; ")
+}
+
+// Unwrap makes invalid settings validation errors.
+func (e *InvalidSettingsError) Unwrap() error { return ErrValidation }
+
+// CompanyProfile is the organization's identity as printed on documents and reports.
+type CompanyProfile struct {
+	Name                   string            `json:"name"`
+	Address                string            `json:"address,omitempty"`
+	LogoURL                string            `json:"logo_url,omitempty"`
+	Currency               string            `json:"currency"`
+	TaxRegistrationNumbers map[string]string `json:"tax_registration_numbers,omitempty"`
+	InvoiceFooter          string            `json:"invoice_footer,omitempty"`
+}
+
+// SettingStore defines an interface for setting-related database operations
+type SettingStore interface {
+	// GetSettings returns every stored setting value keyed by setting key.
+	GetSettings(ctx context.Context) (map[string]json.RawMessage, error)
+	// SaveSettings stores the values in one transaction and records the change in the audit log.
+	SaveSettings(ctx context.Context, values map[string]json.RawMessage, actor string) error
+}
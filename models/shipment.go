@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Shipment statuses. A shipment is pending until a label is purchased; carriers then
+// report the remaining states through tracking updates.
+const (
+	ShipmentPending        = "pending"
+	ShipmentLabelPurchased = "label_purchased"
+	ShipmentInTransit      = "in_transit"
+	ShipmentOutForDelivery = "out_for_delivery"
+	ShipmentDelivered      = "delivered"
+	ShipmentException      = "exception"
+	ShipmentReturned       = "returned"
+)
+
+// ErrLabelPurchased is returned when a label is requested for a shipment that already has one.
+var ErrLabelPurchased = Conflict("a label has already been purchased for this shipment")
+
+// IsTrackingStatus reports whether status is one a carrier may report for a shipment.
+func IsTrackingStatus(status string) bool {
+	switch status {
+	case ShipmentInTransit, ShipmentOutForDelivery, ShipmentDelivered, ShipmentException, ShipmentReturned:
+		return true
+	}
+	return false
+}
+
+// Address is a postal address used for shipping.
+type Address struct {
+	Name       string `json:"name"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"` // ISO 3166-1 alpha-2 code
+}
+
+// Shipment is a parcel sent to a customer, optionally for a sales order.
+type Shipment struct {
+	ID             int       `json:"id"`
+	SalesOrderID   *int      `json:"sales_order_id,omitempty"`
+	FromAddress    Address   `json:"from_address"`
+	ToAddress      Address   `json:"to_address"`
+	WeightKg       float64   `json:"weight_kg"`
+	Status         string    `json:"status"`
+	Carrier        string    `json:"carrier,omitempty"`
+	Service        string    `json:"service,omitempty"`
+	TrackingNumber string    `json:"tracking_number,omitempty"`
+	LabelURL       string    `json:"label_url,omitempty"`
+	Cost           float64   `json:"cost,omitempty"`
+	Currency       string    `json:"currency,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// ShippingRate is a carrier's price quote for one service level.
+type ShippingRate struct {
+	Carrier       string  `json:"carrier"`
+	Service       string  `json:"service"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	EstimatedDays int     `json:"estimated_days"`
+}
+
+// TrackingEvent is a status update reported by a carrier for a shipment.
+type TrackingEvent struct {
+	ID             int       `json:"id"`
+	ShipmentID     int       `json:"shipment_id"`
+	TrackingNumber string    `json:"tracking_number"`
+	Status         string    `json:"status"`
+	Description    string    `json:"description,omitempty"`
+	Location       string    `json:"location,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// ShipmentStore defines an interface for shipment-related database operations
+type ShipmentStore interface {
+	CreateShipment(ctx context.Context, shipment *Shipment) error
+	GetShipmentByID(ctx context.Context, id int) (*Shipment, error)
+	GetShipmentByTracking(ctx context.Context, carrier, trackingNumber string) (*Shipment, error)
+	// SaveLabel records a purchased label. It returns ErrLabelPurchased if the shipment
+	// is no longer pending.
+	SaveLabel(ctx context.Context, shipment *Shipment) error
+	// AddTrackingEvent stores a tracking update and moves the shipment to its status unless
+	// a later update is already recorded. Duplicate updates are ignored and return false.
+	AddTrackingEvent(ctx context.Context, event *TrackingEvent) (bool, error)
+	GetTrackingEvents(ctx context.Context, shipmentID int) ([]TrackingEvent, error)
+}
+
+// PackingItem is a product packed in a shipment, as listed on its delivery note.
+type PackingItem struct {
+	ProductID *int   `json:"product_id,omitempty"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+}
+
+// PackingListStore reads what a shipment contains.
+type PackingListStore interface {
+	// GetPackingList returns the items packed in a shipment: the products of its sales
+	// order, or nothing for a shipment without one.
+	GetPackingList(ctx context.Context, shipmentID int) ([]PackingItem, error)
+}
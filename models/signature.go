@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Statuses of signature requests
+const (
+	SignaturePending  = "pending"
+	SignatureAccepted = "accepted"
+)
+
+// Errors returned when a signing link can no longer be used
+var (
+	ErrSignatureLinkExpired = Conflict("the signing link has expired")
+	ErrAlreadySigned        = Conflict("the document has already been accepted")
+)
+
+// SignatureRequest asks a customer to accept a document through a signed public link.
+// Once accepted it records who signed, when and from where.
+type SignatureRequest struct {
+	ID           int        `json:"id"`
+	DocumentType string     `json:"document_type"` // e.g. "invoice"
+	DocumentID   int        `json:"document_id"`
+	Owner        string     `json:"owner"` // Email of the user who sent the request; notified on acceptance
+	Status       string     `json:"status"`
+	Link         string     `json:"link,omitempty"` // Only returned when the request is created
+	ExpiresAt    time.Time  `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	SignerName   string     `json:"signer_name,omitempty"`
+	SignerIP     string     `json:"signer_ip,omitempty"`
+	ForwardedFor string     `json:"forwarded_for,omitempty"` // X-Forwarded-For as sent by the client; not verified
+	SignatureKey string     `json:"-"`                       // Storage key of the signature image
+	SignatureURL string     `json:"signature_url,omitempty"`
+	SignedAt     *time.Time `json:"signed_at,omitempty"`
+}
+
+// SignableDocument is the summary of a document shown to the customer before they sign.
+type SignableDocument struct {
+	Type         string  `json:"type"`
+	ID           int     `json:"id"`
+	CustomerName string  `json:"customer_name"`
+	Amount       float64 `json:"amount"`
+	Status       string  `json:"status"`
+}
+
+// SignatureStore defines an interface for signature request database operations
+type SignatureStore interface {
+	// CreateSignatureRequest stores a pending request. It returns ErrNotFound if the
+	// document does not exist.
+	CreateSignatureRequest(ctx context.Context, request *SignatureRequest) error
+	GetSignatureRequest(ctx context.Context, id int) (*SignatureRequest, error)
+	// ListSignatureRequests returns the requests for a document, newest first.
+	ListSignatureRequests(ctx context.Context, documentType string, documentID int) ([]SignatureRequest, error)
+	// GetSignableDocument returns the summary of a document shown to the signer.
+	GetSignableDocument(ctx context.Context, documentType string, documentID int) (*SignableDocument, error)
+	// AcceptSignatureRequest records the signer of a pending, unexpired request, marks it
+	// accepted and writes a DocumentAccepted event, in one transaction. It returns
+	// ErrAlreadySigned or ErrSignatureLinkExpired if the request can no longer be accepted.
+	AcceptSignatureRequest(ctx context.Context, request *SignatureRequest, now time.Time) error
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// How often a standing order is delivered
+const (
+	StandingOrderWeekly   = "weekly"   // DeliveryDay is a weekday, 0 (Sunday) to 6 (Saturday)
+	StandingOrderBiweekly = "biweekly" // Every other week on a weekday, counted from the start date
+	StandingOrderMonthly  = "monthly"  // DeliveryDay is a day of the month, 1 to 28
+)
+
+// Standing order statuses. A paused order is not delivered until it is resumed, or until
+// its PausedUntil date if it has one.
+const (
+	StandingOrderActive = "active"
+	StandingOrderPaused = "paused"
+)
+
+// What becomes of a standing order's delivery
+const (
+	DeliveryScheduled = "scheduled" // Its sales order will be drafted LeadDays before it
+	DeliverySkipped   = "skipped"   // Skipped on request; no sales order is drafted
+	DeliveryPaused    = "paused"    // Falls while the standing order is paused
+	DeliveryGenerated = "generated" // Its sales order has been drafted
+)
+
+// StandingOrder is a customer's repeating order: the same lines delivered on a schedule.
+// A draft sales order is generated for each delivery, LeadDays before it.
+type StandingOrder struct {
+	ID          int                 `json:"id"`
+	CustomerID  int                 `json:"customer_id"`
+	Recurrence  string              `json:"recurrence"`
+	DeliveryDay int                 `json:"delivery_day"` // Weekday or day of the month, depending on the recurrence
+	LeadDays    int                 `json:"lead_days"`    // Days before the delivery its sales order is drafted
+	StartDate   time.Time           `json:"start_date"`   // First day a delivery can fall on
+	EndDate     *time.Time          `json:"end_date,omitempty"`
+	Status      string              `json:"status"`
+	PausedUntil *time.Time          `json:"paused_until,omitempty"` // Deliveries from this day on are made again; unset pauses until resumed
+	Note        string              `json:"note,omitempty"`
+	Lines       []StandingOrderLine `json:"lines"`
+	CreatedBy   string              `json:"created_by"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// StandingOrderLine is the quantity of one product delivered each time.
+type StandingOrderLine struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"` // 0 bills the product's price when each sales order is drafted
+}
+
+// StandingOrderSkip is a single delivery of a standing order that is not to be made.
+type StandingOrderSkip struct {
+	StandingOrderID int       `json:"standing_order_id"`
+	DeliveryDate    time.Time `json:"delivery_date"`
+	Reason          string    `json:"reason,omitempty"`
+	SkippedBy       string    `json:"skipped_by"`
+	SkippedAt       time.Time `json:"skipped_at"`
+}
+
+// StandingDelivery is one delivery of a standing order, as forecast or recorded.
+type StandingDelivery struct {
+	StandingOrderID int                 `json:"standing_order_id"`
+	CustomerID      int                 `json:"customer_id"`
+	DeliveryDate    time.Time           `json:"delivery_date"`
+	OrderDate       time.Time           `json:"order_date"` // Day its sales order is drafted
+	Status          string              `json:"status"`
+	SalesOrderID    *int                `json:"sales_order_id,omitempty"` // Set once generated
+	Reason          string              `json:"reason,omitempty"`         // Why it was skipped
+	Lines           []StandingOrderLine `json:"lines,omitempty"`
+}
+
+// StandingOrderStore defines an interface for standing order database operations.
+type StandingOrderStore interface {
+	// CreateStandingOrder records a standing order with its lines. It returns a validation
+	// error if the customer or a product does not exist.
+	CreateStandingOrder(ctx context.Context, order *StandingOrder) error
+	GetStandingOrder(ctx context.Context, id int) (*StandingOrder, error)
+	// ListStandingOrders returns the standing orders of a customer, or of every customer if
+	// customerID is 0, with their lines.
+	ListStandingOrders(ctx context.Context, customerID int) ([]StandingOrder, error)
+	// UpdateStandingOrder replaces an order's schedule and lines. Sales orders already
+	// generated are not changed.
+	UpdateStandingOrder(ctx context.Context, order *StandingOrder) error
+	DeleteStandingOrder(ctx context.Context, id int) error
+	// SetStandingOrderStatus pauses or resumes an order.
+	SetStandingOrderStatus(ctx context.Context, id int, status string, pausedUntil *time.Time) (*StandingOrder, error)
+	// SkipDelivery records a skipped delivery. It returns a conflict if the delivery is
+	// already skipped.
+	SkipDelivery(ctx context.Context, skip *StandingOrderSkip) error
+	// UnskipDelivery removes a skip; it returns ErrNotFound if the delivery was not skipped.
+	UnskipDelivery(ctx context.Context, standingOrderID int, deliveryDate time.Time) error
+	// RecordedDeliveries returns the deliveries from from to to, inclusive, that were
+	// skipped or generated, of one standing order or of all of them if standingOrderID is 0.
+	RecordedDeliveries(ctx context.Context, standingOrderID int, from, to time.Time) ([]StandingDelivery, error)
+}
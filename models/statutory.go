@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Statutory deduction kinds
+const (
+	StatutoryIncomeTax     = "income_tax"     // Withheld from the employee by annual income slabs
+	StatutoryProvidentFund = "provident_fund" // A percentage of wages from the employee and the employer
+	StatutoryInsurance     = "insurance"      // A percentage of wages from the employee and the employer
+)
+
+// TaxSlab is one band of annual taxable income and the rate charged on the part of income
+// within it.
+type TaxSlab struct {
+	UpTo float64 `json:"up_to"` // Upper bound of the band; 0 for the top band
+	Rate float64 `json:"rate"`  // Percent
+}
+
+// StatutoryDeduction is a deduction the law requires from wages. Income tax is worked out
+// from its slabs on the annualized wage; provident fund and insurance are percentages of
+// the wage, up to the ceiling if there is one, paid by the employee and the employer.
+type StatutoryDeduction struct {
+	ID           int       `json:"id"`
+	Code         string    `json:"code"` // e.g. "PF"; unique
+	Name         string    `json:"name"`
+	Kind         string    `json:"kind"`
+	Slabs        []TaxSlab `json:"slabs,omitempty"`         // Income tax only, in ascending order
+	EmployeeRate float64   `json:"employee_rate,omitempty"` // Percent of wages withheld from the employee
+	EmployerRate float64   `json:"employer_rate,omitempty"` // Percent of wages the employer contributes
+	Ceiling      float64   `json:"ceiling,omitempty"`       // Monthly wage the rates apply up to; 0 for none
+	Active       bool      `json:"active"`
+	UpdatedBy    string    `json:"updated_by,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// StatutoryLine is what one deduction takes from an employee's wage for a period.
+type StatutoryLine struct {
+	Code     string  `json:"code"`
+	Name     string  `json:"name"`
+	Kind     string  `json:"kind"`
+	Base     float64 `json:"base"`     // Wage the deduction was computed on
+	Employee float64 `json:"employee"` // Withheld from the employee
+	Employer float64 `json:"employer"` // Contributed by the employer
+}
+
+// StatutoryRecord is the statutory deductions of one employee's wage for a period.
+type StatutoryRecord struct {
+	ID            int             `json:"id,omitempty"`
+	UserID        int             `json:"user_id"`
+	Period        string          `json:"period"` // e.g. "2024-05"
+	Gross         float64         `json:"gross"`
+	Lines         []StatutoryLine `json:"lines"`
+	TotalEmployee float64         `json:"total_employee"`
+	TotalEmployer float64         `json:"total_employer"`
+	Net           float64         `json:"net"` // Gross less what is withheld from the employee
+	RecordedBy    string          `json:"recorded_by,omitempty"`
+	RecordedAt    time.Time       `json:"recorded_at"`
+}
+
+// RemittanceLine is what is owed to the authorities for one deduction in a period.
+type RemittanceLine struct {
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Kind      string  `json:"kind"`
+	Employees int     `json:"employees"`
+	Wages     float64 `json:"wages"` // Total base the deduction was computed on
+	Employee  float64 `json:"employee"`
+	Employer  float64 `json:"employer"`
+	Total     float64 `json:"total"`
+}
+
+// RemittanceReport totals a period's statutory deductions by deduction, for remittance.
+type RemittanceReport struct {
+	Period        string           `json:"period"`
+	Employees     int              `json:"employees"`
+	Lines         []RemittanceLine `json:"lines"`
+	TotalEmployee float64          `json:"total_employee"`
+	TotalEmployer float64          `json:"total_employer"`
+	Total         float64          `json:"total"`
+}
+
+// StatutoryStore defines an interface for statutory deduction database operations
+type StatutoryStore interface {
+	// CreateStatutoryDeduction returns a conflict if the code is taken.
+	CreateStatutoryDeduction(ctx context.Context, deduction *StatutoryDeduction) error
+	GetStatutoryDeductions(ctx context.Context) ([]StatutoryDeduction, error)
+	UpdateStatutoryDeduction(ctx context.Context, deduction *StatutoryDeduction) error
+	DeleteStatutoryDeduction(ctx context.Context, id int) error
+	// SaveStatutoryRecord records an employee's deductions for a period, replacing any
+	// recorded before. It returns models.ErrNotFound if the user does not exist.
+	SaveStatutoryRecord(ctx context.Context, record *StatutoryRecord) error
+	// GetStatutoryRecords lists the records of a period; userID 0 matches every employee.
+	GetStatutoryRecords(ctx context.Context, period string, userID int) ([]StatutoryRecord, error)
+}
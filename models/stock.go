@@ -1,18 +1,26 @@
 package models
 
+import "context"
+
 // Stock represents inventory stock information
 type Stock struct {
-	ID          int    `json:"id"`
-	ProductID   int    `json:"product_id"`
-	Quantity    int    `json:"quantity"`
-	WarehouseID int    `json:"warehouse_id"`
-	Location    string `json:"location"`
+	ID           int    `json:"id"`
+	ProductID    int    `json:"product_id"`
+	Quantity     int    `json:"quantity"`
+	WarehouseID  int    `json:"warehouse_id"`
+	Location     string `json:"location"`
+	ReorderLevel int    `json:"reorder_level"` // Quantity below which the stock is reported low; 0 if not watched
+	Version      int    `json:"version"`       // Incremented by every change, including receipts, picks and transfers
 }
 
 // StockStore defines an interface for stock-related database operations
 type StockStore interface {
-	CreateStock(stock *Stock) error
-	GetStockByProductID(productID int) (*Stock, error)
-	UpdateStock(stock *Stock) error
-	DeleteStock(id int) error
+	CreateStock(ctx context.Context, stock *Stock) error
+	GetStockByProductID(ctx context.Context, productID int) (*Stock, error)
+	// UpdateStock changes a stock record if it is still at stock.Version, and sets the new
+	// version. It returns a conflict if the record was changed since.
+	UpdateStock(ctx context.Context, stock *Stock) error
+	DeleteStock(ctx context.Context, id int) error
+	// ListStock returns a page of stock records and the number matching the filters.
+	ListStock(ctx context.Context, query ListQuery) ([]Stock, int, error)
 }
@@ -0,0 +1,31 @@
+package models
+
+import "context"
+
+// StockAlert is a stock record holding less than its reorder level, or a product holding
+// less than the minimum of its stock policy in a warehouse. Policy alerts have no StockID
+// or Location, and their ReorderLevel is the policy's minimum.
+type StockAlert struct {
+	StockID       int    `json:"stock_id,omitempty"`
+	PolicyID      int    `json:"policy_id,omitempty"`
+	ProductID     int    `json:"product_id"`
+	ProductName   string `json:"product_name"`
+	WarehouseID   int    `json:"warehouse_id"`
+	WarehouseName string `json:"warehouse_name"`
+	Location      string `json:"location"`
+	Quantity      int    `json:"quantity"`
+	ReorderLevel  int    `json:"reorder_level"`
+	MaxLevel      int    `json:"max_level,omitempty"` // Maximum of the stock policy
+	Shortfall     int    `json:"shortfall"`           // ReorderLevel less Quantity
+}
+
+// StockAlertStore finds the stock below its reorder level or policy minimum.
+type StockAlertStore interface {
+	// ListStockAlerts returns the stock records below their reorder level and the policies
+	// below their minimum in a warehouse, or in every warehouse if warehouseID is 0, largest
+	// shortfall first. Records of a product with a policy in their warehouse are left out.
+	ListStockAlerts(ctx context.Context, warehouseID int) ([]StockAlert, error)
+	// MarkStockAlerts records which stock records and policies are low and returns those
+	// that fell low since the last call.
+	MarkStockAlerts(ctx context.Context) ([]StockAlert, error)
+}
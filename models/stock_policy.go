@@ -0,0 +1,42 @@
+package models
+
+import "context"
+
+// StockPolicy is the minimum and maximum stock of a product in a warehouse. It replaces the
+// reorder levels of the product's stock records there: the product is low when its stock in
+// the warehouse, over every location, falls below MinLevel, and is reordered up to MaxLevel.
+type StockPolicy struct {
+	ID          int `json:"id"`
+	ProductID   int `json:"product_id"`
+	WarehouseID int `json:"warehouse_id"`
+	MinLevel    int `json:"min_level"`
+	MaxLevel    int `json:"max_level"`
+}
+
+// Replenishment is the stock position of a product with a policy in a warehouse, and the
+// quantity to order to bring it back up to the policy's maximum.
+type Replenishment struct {
+	PolicyID      int    `json:"policy_id"`
+	ProductID     int    `json:"product_id"`
+	ProductName   string `json:"product_name"`
+	WarehouseID   int    `json:"warehouse_id"`
+	WarehouseName string `json:"warehouse_name"`
+	OnHand        int    `json:"on_hand"`
+	OnOrder       int    `json:"on_order"` // Ordered on open purchase orders and not received yet, or quarantined for inspection
+	MinLevel      int    `json:"min_level"`
+	MaxLevel      int    `json:"max_level"`
+	Quantity      int    `json:"quantity"` // Suggested order quantity
+}
+
+// StockPolicyStore defines the operations on stock policies.
+type StockPolicyStore interface {
+	CreateStockPolicy(ctx context.Context, policy *StockPolicy) error
+	UpdateStockPolicy(ctx context.Context, policy *StockPolicy) error
+	DeleteStockPolicy(ctx context.Context, id int) error
+	GetStockPolicy(ctx context.Context, id int) (*StockPolicy, error)
+	// ListStockPolicies returns the policies of a warehouse and product; 0 matches any.
+	ListStockPolicies(ctx context.Context, warehouseID, productID int) ([]StockPolicy, error)
+	// ListStockPositions returns the stock on hand and on order of every product with a
+	// policy in a warehouse, or in every warehouse if warehouseID is 0. Quantity is not set.
+	ListStockPositions(ctx context.Context, warehouseID int) ([]Replenishment, error)
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// StockSnapshot is a copy of the stock of every product in every warehouse, taken nightly so
+// that past balances can be worked out.
+type StockSnapshot struct {
+	ID      int       `json:"id"`
+	TakenOn string    `json:"taken_on"` // YYYY-MM-DD; one snapshot is taken a day
+	TakenAt time.Time `json:"taken_at"`
+	Lines   int       `json:"lines"` // Product and warehouse balances copied
+}
+
+// WarehouseBalance is the quantity of a product in a warehouse.
+type WarehouseBalance struct {
+	WarehouseID int `json:"warehouse_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// StockBalance is the stock of a product at the end of a past day: the balances of the last
+// snapshot taken by then, plus the movements recorded after it.
+type StockBalance struct {
+	ProductID  int                `json:"product_id"`
+	Date       string             `json:"date"` // YYYY-MM-DD
+	Quantity   int                `json:"quantity"`
+	Warehouses []WarehouseBalance `json:"warehouses"`
+	Snapshot   StockSnapshot      `json:"snapshot"`  // The snapshot the balance starts from
+	Movements  int                `json:"movements"` // Movements applied after the snapshot
+}
+
+// StockSnapshotStore defines the operations on stock snapshots.
+type StockSnapshotStore interface {
+	// TakeStockSnapshot copies the current stock and sets the snapshot's ID and lines. It
+	// returns a conflict if a snapshot was taken on the day already.
+	TakeStockSnapshot(ctx context.Context, snapshot *StockSnapshot) error
+	// GetStockSnapshotBefore returns the last snapshot taken before a time and a product's
+	// balances in it, or a not found error if none was taken by then.
+	GetStockSnapshotBefore(ctx context.Context, productID int, before time.Time) (*StockSnapshot, []WarehouseBalance, error)
+	// ListProductMovements returns a product's movements after from and before to, oldest
+	// first.
+	ListProductMovements(ctx context.Context, productID int, from, to time.Time) ([]StockMovement, error)
+}
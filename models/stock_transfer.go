@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Stock transfer statuses. A transfer is requested, approved, dispatched from the source
+// warehouse (in transit) and received at the destination; it can be cancelled until it is
+// dispatched.
+const (
+	StockTransferRequested = "requested"
+	StockTransferApproved  = "approved"
+	StockTransferInTransit = "in_transit"
+	StockTransferReceived  = "received"
+	StockTransferCancelled = "cancelled"
+)
+
+// StockTransfer moves products from one warehouse to another. The source's stock is taken
+// when the transfer is dispatched and the destination's is added when it is received, so
+// goods on the road are counted in neither warehouse.
+type StockTransfer struct {
+	ID                     int                 `json:"id"`
+	SourceWarehouseID      int                 `json:"source_warehouse_id"`
+	DestinationWarehouseID int                 `json:"destination_warehouse_id"`
+	Status                 string              `json:"status"`
+	Note                   string              `json:"note,omitempty"`
+	Lines                  []StockTransferLine `json:"lines"`
+	RequestedBy            string              `json:"requested_by"`
+	RequestedAt            time.Time           `json:"requested_at"`
+	ApprovedBy             string              `json:"approved_by,omitempty"`
+	ApprovedAt             *time.Time          `json:"approved_at,omitempty"`
+	DispatchedBy           string              `json:"dispatched_by,omitempty"`
+	DispatchedAt           *time.Time          `json:"dispatched_at,omitempty"`
+	ReceivedBy             string              `json:"received_by,omitempty"`
+	ReceivedAt             *time.Time          `json:"received_at,omitempty"`
+	CancelledBy            string              `json:"cancelled_by,omitempty"`
+	CancelledAt            *time.Time          `json:"cancelled_at,omitempty"`
+	Discrepancy            bool                `json:"discrepancy"`                // Less was received than dispatched
+	DiscrepancyNote        string              `json:"discrepancy_note,omitempty"` // Explanation of the shortfall
+}
+
+// StockTransferLine is the quantity of one product on a transfer.
+type StockTransferLine struct {
+	ProductID        int  `json:"product_id"`
+	Quantity         int  `json:"quantity"`
+	ReceivedQuantity *int `json:"received_quantity,omitempty"` // Set on receipt
+	Short            int  `json:"short,omitempty"`             // Dispatched but not received
+}
+
+// StockTransferReceipt is what arrived at the destination. Products not listed are taken
+// as received in full.
+type StockTransferReceipt struct {
+	Lines []StockTransferReceiptLine `json:"lines"`
+	Note  string                     `json:"note"` // Required when less arrived than was dispatched
+}
+
+// StockTransferReceiptLine is the quantity of one product that arrived.
+type StockTransferReceiptLine struct {
+	ProductID        int `json:"product_id"`
+	ReceivedQuantity int `json:"received_quantity"`
+}
+
+// StockInTransitReport lists the transfers dispatched but not yet received.
+type StockInTransitReport struct {
+	AsOf      time.Time           `json:"as_of"`
+	Transfers []InTransitTransfer `json:"transfers"`
+	Units     int                 `json:"units"` // Total quantity in transit
+}
+
+// InTransitTransfer is a transfer on the road and how long it has been.
+type InTransitTransfer struct {
+	StockTransfer
+	DaysInTransit int `json:"days_in_transit"`
+}
+
+// Stock movement kinds.
+const (
+	StockMovementTransferOut = "transfer_out" // Taken from a warehouse by a direct transfer
+	StockMovementTransferIn  = "transfer_in"  // Added to a warehouse by a direct transfer
+)
+
+// StockMove moves a quantity of a product from one warehouse to another at once, without
+// the approval and dispatch of a StockTransfer.
+type StockMove struct {
+	ProductID              int             `json:"product_id"`
+	Quantity               int             `json:"quantity"`
+	SourceWarehouseID      int             `json:"source_warehouse_id"`
+	DestinationWarehouseID int             `json:"destination_warehouse_id"`
+	Note                   string          `json:"note,omitempty"`
+	Movements              []StockMovement `json:"movements"` // The leg out of the source and the leg into the destination
+}
+
+// StockMovement is a change of a product's stock in a warehouse, kept as its movement
+// history.
+type StockMovement struct {
+	ID                     int       `json:"id"`
+	ProductID              int       `json:"product_id"`
+	WarehouseID            int       `json:"warehouse_id"`
+	Quantity               int       `json:"quantity"` // Positive into the warehouse, negative out of it
+	Kind                   string    `json:"kind"`
+	CounterpartWarehouseID int       `json:"counterpart_warehouse_id,omitempty"` // The other warehouse of a transfer
+	Note                   string    `json:"note,omitempty"`
+	MovedBy                string    `json:"moved_by"`
+	MovedAt                time.Time `json:"moved_at"`
+}
+
+// StockTransferStore defines an interface for stock transfer database operations. The
+// status changes check the current status with the transfer locked and return a conflict
+// if the transfer is not in the expected state.
+type StockTransferStore interface {
+	CreateTransfer(ctx context.Context, transfer *StockTransfer) error
+	GetTransfer(ctx context.Context, id int) (*StockTransfer, error)
+	ListTransfers(ctx context.Context, status string) ([]StockTransfer, error)
+	ApproveTransfer(ctx context.Context, id int, actor string) (*StockTransfer, error)
+	// DispatchTransfer takes every line's stock from the source warehouse.
+	DispatchTransfer(ctx context.Context, id int, actor string) (*StockTransfer, error)
+	// ReceiveTransfer adds the received quantities to the destination warehouse and records
+	// any shortfall as a discrepancy.
+	ReceiveTransfer(ctx context.Context, id int, receipt StockTransferReceipt, actor string) (*StockTransfer, error)
+	CancelTransfer(ctx context.Context, id int, actor string) (*StockTransfer, error)
+	// MoveStock takes the quantity from the source warehouse and adds it to the destination
+	// in one transaction, recording both legs as movements. It returns a conflict if the
+	// source has less of the product or the destination has no room for it, and a
+	// validation error if a warehouse does not exist.
+	MoveStock(ctx context.Context, move *StockMove, actor string) error
+	// ListStockMovements returns the movements of a product and a warehouse, newest first;
+	// an ID of 0 matches every product or warehouse.
+	ListStockMovements(ctx context.Context, productID, warehouseID int) ([]StockMovement, error)
+}
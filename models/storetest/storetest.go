@@ -0,0 +1,368 @@
+// Package storetest holds conformance suites for the store interfaces in package models.
+// Every implementation of an interface, the in-memory fakes used by handler tests as well
+// as the SQL stores, runs the same suite, so handlers can rely on one set of semantics:
+//
+//   - Records that do not exist are reported with an error wrapping models.ErrNotFound,
+//     by lookups, updates and deletes alike.
+//   - Stores keep their own copy of a record. Changing a struct after passing it to the
+//     store, or after getting it back, does not change what is stored.
+//   - Versioned records are updated by compare-and-swap: an update based on a version that
+//     was updated since fails with an error wrapping models.ErrConflict and changes nothing.
+//
+// The suites create their own records and never assume an empty store, so they can run
+// against a shared test database.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"erp/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MissingID is an ID that no record in a store under test has.
+const MissingID = 2147483000
+
+// TestCustomerStore checks an implementation of models.CustomerStore.
+func TestCustomerStore(ctx context.Context, t *testing.T, store models.CustomerStore) {
+	create := func(t *testing.T) models.Customer {
+		customer := models.Customer{Name: "Contract customer", Contact: "contract@example.com", OrderHistory: "none"}
+		require.NoError(t, store.CreateCustomer(ctx, &customer))
+		require.NotZero(t, customer.ID, "CreateCustomer must set the ID")
+		return customer
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		customer := create(t)
+		found, err := store.GetCustomerByID(ctx, customer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, customer, *found)
+	})
+
+	t.Run("stores a copy", func(t *testing.T) {
+		customer := models.Customer{Name: "Original"}
+		require.NoError(t, store.CreateCustomer(ctx, &customer))
+		customer.Name = "Changed by the caller"
+		found, err := store.GetCustomerByID(ctx, customer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Original", found.Name)
+
+		found.Name = "Changed after get"
+		again, err := store.GetCustomerByID(ctx, customer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Original", again.Name)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		customer := create(t)
+		customer.Name, customer.Contact = "Renamed", "renamed@example.com"
+		require.NoError(t, store.UpdateCustomer(ctx, &customer))
+		found, err := store.GetCustomerByID(ctx, customer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, customer, *found)
+	})
+
+	t.Run("stale update", func(t *testing.T) {
+		customer := create(t)
+		stale := customer
+		customer.Name = "First writer"
+		require.NoError(t, store.UpdateCustomer(ctx, &customer))
+		assert.Equal(t, stale.Version+1, customer.Version, "UpdateCustomer must set the new version")
+
+		stale.Name = "Second writer"
+		assertConflict(t, store.UpdateCustomer(ctx, &stale))
+		found, err := store.GetCustomerByID(ctx, customer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, customer, *found)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		customer := create(t)
+		require.NoError(t, store.DeleteCustomer(ctx, customer.ID))
+		_, err := store.GetCustomerByID(ctx, customer.ID)
+		assertNotFound(t, err)
+		assertNotFound(t, store.DeleteCustomer(ctx, customer.ID))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		name := fmt.Sprintf("Listed customer %d", time.Now().UnixNano())
+		var ids []int
+		for i := 0; i < 3; i++ {
+			customer := models.Customer{Name: name}
+			require.NoError(t, store.CreateCustomer(ctx, &customer))
+			ids = append(ids, customer.ID)
+		}
+
+		query := models.ListQuery{Page: 1, Limit: 2, Sort: "id", Filters: map[string]string{"name": strings.ToUpper(name)}}
+		page, total, err := store.ListCustomers(ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total, "names are matched case-insensitively")
+		require.Len(t, page, 2)
+		assert.Equal(t, ids[:2], []int{page[0].ID, page[1].ID}, "pages are sorted by ID")
+
+		query.Page = 2
+		page, total, err = store.ListCustomers(ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		require.Len(t, page, 1)
+		assert.Equal(t, ids[2], page[0].ID)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := store.GetCustomerByID(ctx, MissingID)
+		assertNotFound(t, err)
+		assertNotFound(t, store.UpdateCustomer(ctx, &models.Customer{ID: MissingID, Name: "Nobody"}))
+		assertNotFound(t, store.DeleteCustomer(ctx, MissingID))
+	})
+}
+
+// TestInvoiceStore checks an implementation of models.InvoiceStore. newInvoice returns a
+// postable invoice whose customer and sales order exist in the store's database.
+func TestInvoiceStore(ctx context.Context, t *testing.T, store models.InvoiceStore, newInvoice func(t *testing.T) models.Invoice) {
+	create := func(t *testing.T) models.Invoice {
+		invoice := newInvoice(t)
+		require.NoError(t, store.CreateInvoice(ctx, &invoice))
+		require.NotZero(t, invoice.ID, "CreateInvoice must set the ID")
+		return invoice
+	}
+	status := func(t *testing.T, id int) string {
+		found, err := store.GetInvoiceByID(ctx, id)
+		require.NoError(t, err)
+		return found.Status
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		invoice := newInvoice(t)
+		invoice.Status = models.InvoiceStatusPosted
+		require.NoError(t, store.CreateInvoice(ctx, &invoice))
+		assert.Equal(t, models.InvoiceStatusDraft, invoice.Status, "New invoices are drafts")
+
+		found, err := store.GetInvoiceByID(ctx, invoice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, invoice, *found)
+	})
+
+	t.Run("stores a copy", func(t *testing.T) {
+		invoice := create(t)
+		amount := invoice.Amount
+		invoice.Amount++
+		found, err := store.GetInvoiceByID(ctx, invoice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, amount, found.Amount)
+
+		found.Amount++
+		again, err := store.GetInvoiceByID(ctx, invoice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, amount, again.Amount)
+	})
+
+	t.Run("update keeps the status", func(t *testing.T) {
+		invoice := create(t)
+		invoice.Amount += 10
+		invoice.Status = models.InvoiceStatusPosted
+		require.NoError(t, store.UpdateInvoice(ctx, &invoice))
+
+		found, err := store.GetInvoiceByID(ctx, invoice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, invoice.Amount, found.Amount)
+		assert.Equal(t, models.InvoiceStatusDraft, found.Status)
+	})
+
+	t.Run("post locks the invoice", func(t *testing.T) {
+		invoice := create(t)
+		posted, err := store.PostInvoice(ctx, invoice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.InvoiceStatusPosted, posted.Status)
+		assert.Equal(t, models.InvoiceStatusPosted, status(t, invoice.ID))
+
+		_, err = store.PostInvoice(ctx, invoice.ID)
+		assert.ErrorIs(t, err, models.ErrDocumentLocked)
+		assert.ErrorIs(t, store.UpdateInvoice(ctx, &invoice), models.ErrDocumentLocked)
+		assert.ErrorIs(t, store.DeleteInvoice(ctx, invoice.ID), models.ErrDocumentLocked)
+		assert.Equal(t, models.InvoiceStatusPosted, status(t, invoice.ID))
+	})
+
+	t.Run("post rejects invalid drafts", func(t *testing.T) {
+		invoice := newInvoice(t)
+		invoice.Amount = 0
+		require.NoError(t, store.CreateInvoice(ctx, &invoice))
+		_, err := store.PostInvoice(ctx, invoice.ID)
+		var postingErr *models.PostingError
+		assert.ErrorAs(t, err, &postingErr)
+		assert.Equal(t, models.InvoiceStatusDraft, status(t, invoice.ID))
+	})
+
+	t.Run("void", func(t *testing.T) {
+		first, second := create(t), create(t)
+		require.NoError(t, store.VoidInvoices(ctx, []int{first.ID, second.ID}, "Contract test", "contract@example.com"))
+		assert.Equal(t, models.InvoiceStatusVoid, status(t, first.ID))
+		assert.Equal(t, models.InvoiceStatusVoid, status(t, second.ID))
+	})
+
+	t.Run("void rejects the whole batch", func(t *testing.T) {
+		draft, posted := create(t), create(t)
+		_, err := store.PostInvoice(ctx, posted.ID)
+		require.NoError(t, err)
+
+		err = store.VoidInvoices(ctx, []int{draft.ID, posted.ID, MissingID}, "Contract test", "contract@example.com")
+		var rejected *models.BatchRejectedError
+		require.ErrorAs(t, err, &rejected)
+		var ids []int
+		for _, item := range rejected.Items {
+			ids = append(ids, item.ID)
+		}
+		assert.ElementsMatch(t, []int{posted.ID, MissingID}, ids)
+		assert.Equal(t, models.InvoiceStatusDraft, status(t, draft.ID))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		invoice := create(t)
+		larger := newInvoice(t)
+		larger.CustomerID, larger.Amount = invoice.CustomerID, invoice.Amount+100
+		require.NoError(t, store.CreateInvoice(ctx, &larger))
+
+		query := models.ListQuery{Page: 1, Limit: 200, Sort: "amount", Desc: true, Filters: map[string]string{
+			"customer_id": strconv.Itoa(invoice.CustomerID),
+			"status":      strings.ToUpper(models.InvoiceStatusDraft),
+		}}
+		page, total, err := store.ListInvoices(ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, len(page), total)
+		var ids []int
+		for i, found := range page {
+			assert.Equal(t, invoice.CustomerID, found.CustomerID)
+			assert.Equal(t, models.InvoiceStatusDraft, found.Status)
+			if i > 0 {
+				assert.GreaterOrEqual(t, page[i-1].Amount, found.Amount, "sorted by descending amount")
+			}
+			ids = append(ids, found.ID)
+		}
+		assert.Contains(t, ids, invoice.ID)
+		assert.Contains(t, ids, larger.ID)
+
+		query.Limit = 1
+		page, again, err := store.ListInvoices(ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, total, again, "the total counts every page")
+		assert.Len(t, page, 1)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		invoice := create(t)
+		require.NoError(t, store.DeleteInvoice(ctx, invoice.ID))
+		_, err := store.GetInvoiceByID(ctx, invoice.ID)
+		assertNotFound(t, err)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := store.GetInvoiceByID(ctx, MissingID)
+		assertNotFound(t, err)
+		invoice := newInvoice(t)
+		invoice.ID = MissingID
+		assertNotFound(t, store.UpdateInvoice(ctx, &invoice))
+		assertNotFound(t, store.DeleteInvoice(ctx, MissingID))
+		_, err = store.PostInvoice(ctx, MissingID)
+		assertNotFound(t, err)
+	})
+}
+
+// TestStockStore checks an implementation of models.StockStore. newStock returns a stock
+// record for a product that exists and has no stock record yet.
+func TestStockStore(ctx context.Context, t *testing.T, store models.StockStore, newStock func(t *testing.T) models.Stock) {
+	create := func(t *testing.T) models.Stock {
+		stock := newStock(t)
+		require.NoError(t, store.CreateStock(ctx, &stock))
+		require.NotZero(t, stock.ID, "CreateStock must set the ID")
+		return stock
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		stock := create(t)
+		found, err := store.GetStockByProductID(ctx, stock.ProductID)
+		require.NoError(t, err)
+		assert.Equal(t, stock, *found)
+	})
+
+	t.Run("stores a copy", func(t *testing.T) {
+		stock := create(t)
+		quantity := stock.Quantity
+		stock.Quantity++
+		found, err := store.GetStockByProductID(ctx, stock.ProductID)
+		require.NoError(t, err)
+		assert.Equal(t, quantity, found.Quantity)
+
+		found.Quantity++
+		again, err := store.GetStockByProductID(ctx, stock.ProductID)
+		require.NoError(t, err)
+		assert.Equal(t, quantity, again.Quantity)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		stock := create(t)
+		stock.Quantity, stock.Location = stock.Quantity+5, "B2"
+		require.NoError(t, store.UpdateStock(ctx, &stock))
+		found, err := store.GetStockByProductID(ctx, stock.ProductID)
+		require.NoError(t, err)
+		assert.Equal(t, stock, *found)
+	})
+
+	t.Run("stale update", func(t *testing.T) {
+		stock := create(t)
+		stale := stock
+		stock.Quantity++
+		require.NoError(t, store.UpdateStock(ctx, &stock))
+		assert.Equal(t, stale.Version+1, stock.Version, "UpdateStock must set the new version")
+
+		stale.Quantity += 10
+		assertConflict(t, store.UpdateStock(ctx, &stale))
+		found, err := store.GetStockByProductID(ctx, stock.ProductID)
+		require.NoError(t, err)
+		assert.Equal(t, stock, *found)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		stock := create(t)
+		require.NoError(t, store.DeleteStock(ctx, stock.ID))
+		_, err := store.GetStockByProductID(ctx, stock.ProductID)
+		assertNotFound(t, err)
+		assertNotFound(t, store.DeleteStock(ctx, stock.ID))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		stock := create(t)
+		query := models.ListQuery{Page: 1, Limit: 1, Sort: "id", Filters: map[string]string{"product_id": strconv.Itoa(stock.ProductID)}}
+		page, total, err := store.ListStock(ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, []models.Stock{stock}, page)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := store.GetStockByProductID(ctx, MissingID)
+		assertNotFound(t, err)
+		stock := newStock(t)
+		stock.ID = MissingID
+		assertNotFound(t, store.UpdateStock(ctx, &stock))
+		assertNotFound(t, store.DeleteStock(ctx, MissingID))
+	})
+}
+
+// assertNotFound checks that err reports a missing record.
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+	assert.True(t, errors.Is(err, models.ErrNotFound), "want an error wrapping models.ErrNotFound, got %v", err)
+}
+
+// assertConflict checks that err reports an update based on an outdated version.
+func assertConflict(t *testing.T, err error) {
+	t.Helper()
+	assert.True(t, errors.Is(err, models.ErrConflict), "want an error wrapping models.ErrConflict, got %v", err)
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Supplier is a vendor the company buys from.
+type Supplier struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Phone     string    `json:"phone,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"version"` // Incremented by every update; an update names the version it changes
+}
+
+// SupplierAdvance is a payment made to a supplier before it has billed. It is held as a
+// prepayment until bills of the supplier use it up.
+type SupplierAdvance struct {
+	ID         int       `json:"id"`
+	SupplierID int       `json:"supplier_id"`
+	Amount     float64   `json:"amount"`
+	Adjusted   float64   `json:"adjusted"` // Amount offset against bills so far
+	PaidOn     time.Time `json:"paid_on"`
+	Method     string    `json:"method,omitempty"`
+	Reference  string    `json:"reference,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Unadjusted returns the part of the advance not yet offset against bills.
+func (a SupplierAdvance) Unadjusted() float64 {
+	return a.Amount - a.Adjusted
+}
+
+// SupplierBill is a bill received from a supplier. Unadjusted advances of the supplier are
+// offset against it when it is recorded, oldest first.
+type SupplierBill struct {
+	ID             int                  `json:"id"`
+	SupplierID     int                  `json:"supplier_id"`
+	Number         string               `json:"number"` // The supplier's bill number
+	Amount         float64              `json:"amount"`
+	Account        string               `json:"account"` // Account debited, "expense" by default
+	BillDate       time.Time            `json:"bill_date"`
+	AdvanceApplied float64              `json:"advance_applied"`
+	Balance        float64              `json:"balance"` // Amount still owed after advances
+	Applications   []AdvanceApplication `json:"applications"`
+	CreatedBy      string               `json:"created_by,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+}
+
+// AdvanceApplication is the part of an advance offset against a bill.
+type AdvanceApplication struct {
+	AdvanceID int     `json:"advance_id"`
+	BillID    int     `json:"bill_id"`
+	Amount    float64 `json:"amount"`
+}
+
+// SupplierBalance summarizes what the company has prepaid to and owes a supplier.
+type SupplierBalance struct {
+	SupplierID     int     `json:"supplier_id"`
+	Name           string  `json:"name"`
+	Advances       float64 `json:"advances"`   // Total advances paid
+	Unadjusted     float64 `json:"unadjusted"` // Advances not yet offset against bills
+	Billed         float64 `json:"billed"`
+	AdvanceApplied float64 `json:"advance_applied"`
+	Outstanding    float64 `json:"outstanding"` // Billed less advances applied
+}
+
+// SupplierStore defines an interface for supplier database operations
+type SupplierStore interface {
+	CreateSupplier(ctx context.Context, supplier *Supplier) error
+	GetSupplier(ctx context.Context, id int) (*Supplier, error)
+	GetSuppliers(ctx context.Context) ([]Supplier, error)
+	// UpdateSupplier changes a supplier if it is still at supplier.Version, and sets the new
+	// version. It returns a conflict if the supplier was changed since.
+	UpdateSupplier(ctx context.Context, supplier *Supplier) error
+	// CreateSupplierAdvance records an advance and posts it to the ledger as a prepayment.
+	CreateSupplierAdvance(ctx context.Context, advance *SupplierAdvance) error
+	// GetSupplierAdvances lists advances, oldest first; supplierID 0 lists every supplier's.
+	GetSupplierAdvances(ctx context.Context, supplierID int, unadjustedOnly bool) ([]SupplierAdvance, error)
+	// CreateSupplierBill records a bill, offsets the supplier's unadjusted advances against
+	// it and posts both to the ledger.
+	CreateSupplierBill(ctx context.Context, bill *SupplierBill) error
+	GetSupplierBills(ctx context.Context, supplierID int) ([]SupplierBill, error)
+	GetSupplierBalance(ctx context.Context, supplierID int) (*SupplierBalance, error)
+}
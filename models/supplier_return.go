@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Supplier return statuses. A return to vendor (RTV) is open until the goods are shipped to
+// the supplier, which takes them out of stock and issues a debit note for their cost. It is
+// credited once the supplier has settled the whole debit note.
+const (
+	SupplierReturnOpen     = "open"
+	SupplierReturnShipped  = "shipped"
+	SupplierReturnCredited = "credited"
+)
+
+// Ways a debit note is settled
+const (
+	CreditPaymentOffset = "payment_offset" // Deducted from the receipt's payment while it is still pending
+	CreditRefund        = "refund"         // Money or a credit note received from the supplier
+)
+
+// SupplierReturn is a document returning goods of a purchase order receipt to the supplier,
+// such as defective or excess goods, or goods rejected by a quality inspection.
+type SupplierReturn struct {
+	ID              int                  `json:"id"`
+	SupplierID      int                  `json:"supplier_id"`
+	PurchaseOrderID int                  `json:"purchase_order_id"`
+	ReceiptID       int                  `json:"receipt_id"`
+	WarehouseID     int                  `json:"warehouse_id"`
+	HoldID          int                  `json:"hold_id,omitempty"` // The quality hold it was raised for; its goods never entered stock
+	Reason          string               `json:"reason"`
+	Status          string               `json:"status"`
+	Lines           []SupplierReturnLine `json:"lines"`
+	Amount          float64              `json:"amount"` // Cost of the goods returned, at the order's unit costs
+	CreatedBy       string               `json:"created_by"`
+	CreatedAt       time.Time            `json:"created_at"`
+	ShippedBy       string               `json:"shipped_by,omitempty"`
+	ShippedAt       *time.Time           `json:"shipped_at,omitempty"`
+	DebitNote       *DebitNote           `json:"debit_note,omitempty"` // Issued when the goods are shipped
+}
+
+// SupplierReturnLine is the quantity of one product returned.
+type SupplierReturnLine struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitCost  float64 `json:"unit_cost"`
+}
+
+// DebitNote is the claim on a supplier for the cost of returned goods.
+type DebitNote struct {
+	ID       int              `json:"id"`
+	Amount   float64          `json:"amount"`
+	Credited float64          `json:"credited"` // Settled so far by payment offsets and refunds
+	Credits  []SupplierCredit `json:"credits"`
+	IssuedBy string           `json:"issued_by"`
+	IssuedAt time.Time        `json:"issued_at"`
+}
+
+// Due returns the part of the debit note the supplier has yet to settle.
+func (n *DebitNote) Due() float64 {
+	return n.Amount - n.Credited
+}
+
+// SupplierCredit is a settlement of a debit note.
+type SupplierCredit struct {
+	ID         int       `json:"id"`
+	Method     string    `json:"method"` // CreditPaymentOffset or CreditRefund
+	Amount     float64   `json:"amount"`
+	PaymentID  int       `json:"payment_id,omitempty"` // The pending payment reduced by an offset
+	Reference  string    `json:"reference"`            // e.g. the supplier's credit note number
+	ReceivedOn time.Time `json:"received_on"`
+	RecordedBy string    `json:"recorded_by"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// SupplierReturnStore defines an interface for supplier returns and their debit notes.
+type SupplierReturnStore interface {
+	// CreateSupplierReturn records an open return of goods of a receipt, taking the
+	// supplier, order and warehouse from the receipt and the unit costs from the order. It
+	// returns a validation error if the receipt does not exist and a conflict if more is
+	// returned than the receipt brought in and earlier returns left.
+	CreateSupplierReturn(ctx context.Context, ret *SupplierReturn) error
+	// ListSupplierReturns returns the returns in a status, or all of them if status is
+	// empty, optionally of one supplier.
+	ListSupplierReturns(ctx context.Context, status string, supplierID int) ([]SupplierReturn, error)
+	GetSupplierReturn(ctx context.Context, id int) (*SupplierReturn, error)
+	// ShipSupplierReturn takes the goods of an open return out of stock, issues its debit
+	// note and offsets it against the receipt's payment while that is pending, in one
+	// transaction. It returns a conflict if the return is not open or the stock is short.
+	ShipSupplierReturn(ctx context.Context, id int, actor string, at time.Time) (*SupplierReturn, error)
+	// RecordSupplierCredit settles part of a shipped return's debit note and posts it to the
+	// ledger. It returns a conflict if the return is not shipped or the credit exceeds what
+	// is due.
+	RecordSupplierCredit(ctx context.Context, id int, credit *SupplierCredit) (*SupplierReturn, error)
+}
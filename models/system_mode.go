@@ -0,0 +1,35 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// API operating modes
+const (
+	ModeNormal      = "normal"
+	ModeReadOnly    = "read_only"   // Reads are served, mutating requests are rejected
+	ModeMaintenance = "maintenance" // All requests are rejected
+)
+
+// SystemMode is the API's current operating mode, switched by administrators during
+// migrations and other maintenance.
+type SystemMode struct {
+	Mode      string    `json:"mode"`
+	Message   string    `json:"message,omitempty"` // Shown to clients whose requests are rejected
+	ChangedBy string    `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// IsValidMode reports whether mode is one of the operating modes.
+func IsValidMode(mode string) bool {
+	return mode == ModeNormal || mode == ModeReadOnly || mode == ModeMaintenance
+}
+
+// SystemModeStore defines an interface for reading and switching the operating mode
+type SystemModeStore interface {
+	// GetSystemMode returns the current mode, or normal mode if it was never switched.
+	GetSystemMode(ctx context.Context) (*SystemMode, error)
+	// SetSystemMode stores the mode and records the switch in the audit log.
+	SetSystemMode(ctx context.Context, mode *SystemMode) error
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// DeletedRecord is a soft deleted record: DELETE handlers set its deleted_at instead of
+// removing the row, so it is hidden from reads until it is restored or purged.
+type DeletedRecord struct {
+	Resource  string    `json:"resource"` // The table, e.g. "customers"
+	ID        int       `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// PurgeResult is the outcome of purging the soft deleted records of a resource.
+type PurgeResult struct {
+	Resource string `json:"resource"`
+	Purged   int    `json:"purged"` // Records removed for good
+	Kept     int    `json:"kept"`   // Records still referenced by other records, left in the trash
+}
+
+// TrashStore defines an interface for soft deleted records.
+type TrashStore interface {
+	// RestoreRecord clears the deleted_at of a record. It returns a not found error if the
+	// record does not exist or is not deleted, and a conflict if a live record took its
+	// unique values in the meantime.
+	RestoreRecord(ctx context.Context, resource string, id int) error
+	// ListDeletedRecords returns the deleted records of a resource, most recently deleted first.
+	ListDeletedRecords(ctx context.Context, resource string) ([]DeletedRecord, error)
+	// PurgeRecords removes the records of a resource deleted before a time. Records other
+	// records still reference are kept.
+	PurgeRecords(ctx context.Context, resource string, before time.Time) (*PurgeResult, error)
+}
@@ -1,4 +1,6 @@
-package models // or package types, based on your preference
+package models
+
+import "context" // or package types, based on your preference
 
 // User represents a user in the system
 type User struct {
@@ -9,6 +11,7 @@ type User struct {
 	Role         Role   `json:"role"`
 	Department   string `json:"department"`
 	NeedsNewPass bool   `json:"needsNewPass,omitempty"`
+	Active       bool   `json:"active"` // Deactivated users cannot sign in
 }
 
 // LoginCredentials represents the structure for user login
@@ -21,10 +24,24 @@ type LoginCredentials struct {
 type SignUpRequest struct {
 	Name       string `json:"name"`
 	Email      string `json:"email"`
-	Role       string `json:"role"`
 	Department string `json:"department"`
 }
 
+// CheckUserRequest represents the request structure for checking whether a user must set a password
+type CheckUserRequest struct {
+	Email string `json:"email"`
+}
+
+// CheckUserResponse represents the response structure for checking a user
+type CheckUserResponse struct {
+	NeedsNewPass bool `json:"needsNewPass"`
+}
+
+// MessageResponse represents the response of an account action that returns no record
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
 // SetNewPasswordRequest represents the request structure for setting a new password
 type SetNewPasswordRequest struct {
 	Email       string `json:"email"`
@@ -33,7 +50,7 @@ type SetNewPasswordRequest struct {
 
 // UserStore defines an interface for user-related database operations
 type UserStore interface {
-	CreateUser(name, email, role, department string) error
-	GetUserByEmail(email string) (*User, error)
-	UpdatePassword(email, hashedPassword string) error
+	CreateUser(ctx context.Context, name, email, role, department string) error
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdatePassword(ctx context.Context, email, hashedPassword string) error
 }
@@ -0,0 +1,26 @@
+package models
+
+import "strings"
+
+// FieldError explains why one field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"` // JSON name of the field, e.g. "email" or "items[2].quantity"
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a request breaks one or more field rules. It lists every
+// rejected field, so clients can show all the mistakes at once.
+type ValidationError struct {
+	Fields []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		reasons[i] = field.Field + ": " + field.Message
+	}
+	return "invalid request: " + strings.Join(reasons, "; ")
+}
+
+// Unwrap makes field errors validation errors.
+func (e *ValidationError) Unwrap() error { return ErrValidation }
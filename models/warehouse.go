@@ -1,17 +1,24 @@
 package models
 
+import "context"
+
 // Warehouse represents warehouse details
 type Warehouse struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
 	Capacity int    `json:"capacity"`
 	Location string `json:"location"`
+	Version  int    `json:"version"` // Incremented by every update; an update names the version it changes
 }
 
 // WarehouseStore defines an interface for warehouse-related database operations
 type WarehouseStore interface {
-	CreateWarehouse(warehouse *Warehouse) error
-	GetWarehouseByID(id int) (*Warehouse, error)
-	UpdateWarehouse(warehouse *Warehouse) error
-	DeleteWarehouse(id int) error
+	CreateWarehouse(ctx context.Context, warehouse *Warehouse) error
+	GetWarehouseByID(ctx context.Context, id int) (*Warehouse, error)
+	// UpdateWarehouse changes a warehouse if it is still at warehouse.Version, and sets the
+	// new version. It returns a conflict if the warehouse was changed since.
+	UpdateWarehouse(ctx context.Context, warehouse *Warehouse) error
+	DeleteWarehouse(ctx context.Context, id int) error
+	// ListWarehouses returns a page of warehouses and the number matching the filters.
+	ListWarehouses(ctx context.Context, query ListQuery) ([]Warehouse, int, error)
 }
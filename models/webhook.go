@@ -0,0 +1,59 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Supported signature schemes for inbound webhooks
+const (
+	SignatureSchemeHMACSHA256       = "hmac-sha256"        // Hex-encoded HMAC-SHA256, optionally prefixed with "sha256="
+	SignatureSchemeHMACSHA256Base64 = "hmac-sha256-base64" // Base64-encoded HMAC-SHA256
+	SignatureSchemeHMACSHA1         = "hmac-sha1"          // Hex-encoded HMAC-SHA1, optionally prefixed with "sha1="
+)
+
+// Processing states of a received webhook event
+const (
+	WebhookEventReceived  = "received"
+	WebhookEventProcessed = "processed"
+	WebhookEventFailed    = "failed"
+)
+
+// WebhookIntegration describes an external system (payment provider, carrier, bank)
+// that is allowed to deliver callbacks to the inbound webhook endpoint.
+type WebhookIntegration struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`             // Unique slug used in the inbound URL
+	Module          string `json:"module"`           // Key of the processor that handles this integration's events
+	Secret          string `json:"secret,omitempty"` // Shared secret used to verify signatures
+	SignatureScheme string `json:"signature_scheme"`
+	SignatureHeader string `json:"signature_header"` // Header carrying the signature (e.g. "X-Signature")
+	EventIDHeader   string `json:"event_id_header"`  // Header carrying the provider's event ID, used for idempotency
+	Active          bool   `json:"active"`
+}
+
+// WebhookEvent is a persisted inbound webhook payload.
+type WebhookEvent struct {
+	ID            int        `json:"id"`
+	IntegrationID int        `json:"integration_id"`
+	ExternalID    string     `json:"external_id"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	ReceivedAt    time.Time  `json:"received_at"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+}
+
+// WebhookStore defines an interface for webhook-related database operations
+type WebhookStore interface {
+	CreateIntegration(ctx context.Context, integration *WebhookIntegration) error
+	GetIntegrationByID(ctx context.Context, id int) (*WebhookIntegration, error)
+	GetIntegrationByName(ctx context.Context, name string) (*WebhookIntegration, error)
+	// SaveEvent persists an event unless one with the same integration and external ID
+	// already exists. It returns false and loads the existing event when it is a duplicate.
+	SaveEvent(ctx context.Context, event *WebhookEvent) (bool, error)
+	GetEventByID(ctx context.Context, id int) (*WebhookEvent, error)
+	MarkEventProcessed(ctx context.Context, id int) error
+	MarkEventFailed(ctx context.Context, id int, reason string) error
+}